@@ -83,6 +83,62 @@ func (m *Server) getTicket(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (m *Server) getIdentityTicket(w http.ResponseWriter, r *http.Request) {
+	var (
+		plaintext []byte
+		err       error
+		jobj      proto.AuthGetIdentityTicketReq
+		ts        int64
+		clientID  string
+		message   string
+	)
+
+	if !m.identity.enabled() {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "identity authentication not enabled"})
+		return
+	}
+
+	if plaintext, err = m.extractClientReqInfo(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = json.Unmarshal(plaintext, &jobj); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if ts, err = proto.ParseVerifier(jobj.Verifier, jobj.ReplyKey); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = proto.IsValidServiceID(jobj.ServiceID); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if err = proto.IsValidMsgReqType(jobj.ServiceID, jobj.Type); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if clientID, err = m.identity.authenticate(&jobj); err != nil {
+		log.LogWarnf("action[getIdentityTicket] provider[%v] remoteAddr[%v] authenticate failed: %v", jobj.Provider, r.RemoteAddr, err)
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if message, err = m.genGetIdentityTicketAuthResp(&jobj, clientID, ts, r); err != nil {
+		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	log.LogInfof("action[getIdentityTicket] provider[%v] client[%v] service[%v] remoteAddr[%v] ticket granted", jobj.Provider, clientID, jobj.ServiceID, r.RemoteAddr)
+	sendOkReply(w, r, newSuccessHTTPAuthReply(message))
+	return
+}
+
 func (m *Server) raftNodeOp(w http.ResponseWriter, r *http.Request) {
 	var (
 		plaintext []byte
@@ -238,6 +294,17 @@ func (m *Server) apiAccessEntry(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	case proto.MsgAuthGetCapsReq:
+	case proto.MsgAuthRotateKeyReq:
+		if keyInfo.ID == proto.AuthServiceID {
+			sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: "AuthServiceID is reserved"})
+			return
+		}
+		if len(keyInfo.AuthKey) > 0 {
+			if err = keyInfo.IsValidAuthKey(); err != nil {
+				sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+				return
+			}
+		}
 	default:
 		sendErrReply(w, r, &proto.HTTPAuthReply{Code: proto.ErrCodeParamError, Msg: fmt.Errorf("invalid request messge type %x", int32(apiReq.Type)).Error()})
 		return
@@ -275,6 +342,8 @@ func (m *Server) apiAccessEntry(w http.ResponseWriter, r *http.Request) {
 		newKeyInfo, err = m.handleDeleteCaps(&keyInfo)
 	case proto.MsgAuthGetCapsReq:
 		newKeyInfo, err = m.handleGetCaps(&keyInfo)
+	case proto.MsgAuthRotateKeyReq:
+		newKeyInfo, err = m.handleRotateKey(&keyInfo)
 	}
 
 	if err != nil {
@@ -334,6 +403,10 @@ func (m *Server) handleGetCaps(keyInfo *keystore.KeyInfo) (res *keystore.KeyInfo
 	return
 }
 
+func (m *Server) handleRotateKey(keyInfo *keystore.KeyInfo) (res *keystore.KeyInfo, err error) {
+	return m.cluster.RotateKey(keyInfo.ID, keyInfo)
+}
+
 func (m *Server) extractClientReqInfo(r *http.Request) (plaintext []byte, err error) {
 	var (
 		message string
@@ -477,13 +550,14 @@ func (m *Server) getSecretKeyInfo(id string) (keyInfo *keystore.KeyInfo, err err
 
 func (m *Server) genGetTicketAuthResp(req *proto.AuthGetTicketReq, ts int64, r *http.Request) (message string, err error) {
 	var (
-		jticket    []byte
-		jresp      []byte
-		resp       proto.AuthGetTicketResp
-		serviceKey []byte
-		clientKey  []byte
-		caps       []byte
-		keyInfo    *keystore.KeyInfo
+		jticket        []byte
+		jresp          []byte
+		resp           proto.AuthGetTicketResp
+		serviceKey     []byte
+		clientKey      []byte
+		caps           []byte
+		keyInfo        *keystore.KeyInfo
+		serviceKeyInfo *keystore.KeyInfo
 	)
 
 	resp.Type = req.Type + 1
@@ -498,9 +572,10 @@ func (m *Server) genGetTicketAuthResp(req *proto.AuthGetTicketReq, ts int64, r *
 	caps = keyInfo.Caps
 
 	// Use service key to encrypt ticket
-	if serviceKey, err = m.getSecretKey(req.ServiceID); err != nil {
+	if serviceKeyInfo, err = m.getSecretKeyInfo(req.ServiceID); err != nil {
 		return
 	}
+	serviceKey = serviceKeyInfo.AuthKey
 
 	ticket := m.genTicket(serviceKey, resp.ServiceID, iputil.RealIP(r), caps)
 	resp.SessionKey = ticket.SessionKey
@@ -512,6 +587,7 @@ func (m *Server) genGetTicketAuthResp(req *proto.AuthGetTicketReq, ts int64, r *
 	if resp.Ticket, err = cryptoutil.EncodeMessage(jticket, serviceKey); err != nil {
 		return
 	}
+	resp.Ticket = proto.EncodeTicketKeyID(serviceKeyInfo.KeyVersion, resp.Ticket)
 
 	if jresp, err = json.Marshal(resp); err != nil {
 		return
@@ -529,6 +605,57 @@ func (m *Server) genGetTicketAuthResp(req *proto.AuthGetTicketReq, ts int64, r *
 	return
 }
 
+// genGetIdentityTicketAuthResp grants the ticket with the caps of the client mapped from the external identity,
+// and uses the reply key of the request to encrypt the response message as the client has no key.
+func (m *Server) genGetIdentityTicketAuthResp(req *proto.AuthGetIdentityTicketReq, clientID string, ts int64, r *http.Request) (message string, err error) {
+	var (
+		jticket        []byte
+		jresp          []byte
+		resp           proto.AuthGetTicketResp
+		serviceKey     []byte
+		keyInfo        *keystore.KeyInfo
+		serviceKeyInfo *keystore.KeyInfo
+	)
+
+	resp.Type = req.Type + 1
+	resp.ClientID = clientID
+	resp.ServiceID = req.ServiceID
+	// increase ts by one for client verify server
+	resp.Verifier = ts + 1
+
+	if keyInfo, err = m.getIdentityKeyInfo(clientID); err != nil {
+		return
+	}
+
+	// Use service key to encrypt ticket
+	if serviceKeyInfo, err = m.getSecretKeyInfo(req.ServiceID); err != nil {
+		return
+	}
+	serviceKey = serviceKeyInfo.AuthKey
+
+	ticket := m.genTicket(serviceKey, resp.ServiceID, iputil.RealIP(r), keyInfo.Caps)
+	resp.SessionKey = ticket.SessionKey
+
+	if jticket, err = json.Marshal(ticket); err != nil {
+		return
+	}
+
+	if resp.Ticket, err = cryptoutil.EncodeMessage(jticket, serviceKey); err != nil {
+		return
+	}
+	resp.Ticket = proto.EncodeTicketKeyID(serviceKeyInfo.KeyVersion, resp.Ticket)
+
+	if jresp, err = json.Marshal(resp); err != nil {
+		return
+	}
+
+	if message, err = cryptoutil.EncodeMessage(jresp, req.ReplyKey); err != nil {
+		return
+	}
+
+	return
+}
+
 func validateGetTicketReqFormat(req *proto.AuthGetTicketReq) (err error) {
 	if err = proto.IsValidClientID(req.ClientID); err != nil {
 		return
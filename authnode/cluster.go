@@ -209,6 +209,47 @@ errHandler:
 	return
 }
 
+// RotateKey replaces the key of a service with a new version, which is the specified key or a generated one.
+// The service accepts the tickets encrypted with the previous versions until they are retired on the service,
+// so the tickets issued before the rotation keep valid until they expire.
+func (c *Cluster) RotateKey(id string, keyInfo *keystore.KeyInfo) (res *keystore.KeyInfo, err error) {
+	var cur *keystore.KeyInfo
+	c.fsm.opKeyMutex.Lock()
+	defer c.fsm.opKeyMutex.Unlock()
+	if cur, err = c.fsm.GetKey(id); err != nil {
+		err = proto.ErrKeyNotExists
+		goto errHandler
+	}
+	if cur.Role != "service" {
+		err = fmt.Errorf("only the key of a service can be rotated")
+		goto errHandler
+	}
+	res = new(keystore.KeyInfo)
+	*res = *cur
+	res.KeyVersion = keyInfo.KeyVersion
+	if res.KeyVersion == 0 {
+		res.KeyVersion = cur.KeyVersion + 1
+	} else if res.KeyVersion <= cur.KeyVersion {
+		err = fmt.Errorf("key version %v should be larger than the current %v", res.KeyVersion, cur.KeyVersion)
+		goto errHandler
+	}
+	res.AuthKey = keyInfo.AuthKey
+	if len(res.AuthKey) == 0 {
+		res.Ts = time.Now().Unix()
+		res.AuthKey = cryptoutil.GenSecretKey([]byte(c.AuthRootKey), res.Ts, id)
+	}
+	if err = c.syncRotateKey(res); err != nil {
+		goto errHandler
+	}
+	c.fsm.PutKey(res)
+	log.LogInfof("action[RotateKey], clusterID[%v] ID:%v rotated to key version %v", c.Name, id, res.KeyVersion)
+	return
+errHandler:
+	err = fmt.Errorf("action[RotateKey], clusterID[%v] ID:%v, err:%v ", c.Name, id, err.Error())
+	log.LogError(errors.Stack(err))
+	return nil, err
+}
+
 // DeleteCaps delete caps from the key
 func (c *Cluster) DeleteCaps(id string, keyInfo *keystore.KeyInfo) (res *keystore.KeyInfo, err error) {
 	var (
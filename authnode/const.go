@@ -26,6 +26,7 @@ const (
 	opSyncAddCaps    uint32 = 0x04
 	opSyncDeleteCaps uint32 = 0x05
 	opSyncGetCaps    uint32 = 0x06
+	opSyncRotateKey  uint32 = 0x07
 )
 
 const (
@@ -76,6 +76,8 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case proto.ClientGetTicket:
 		m.getTicket(w, r)
+	case proto.ClientGetIdentityTicket:
+		m.getIdentityTicket(w, r)
 	case proto.AdminCreateKey:
 		fallthrough
 	case proto.AdminGetKey:
@@ -87,6 +89,8 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case proto.AdminDeleteCaps:
 		fallthrough
 	case proto.AdminGetCaps:
+		fallthrough
+	case proto.AdminRotateKey:
 		m.apiAccessEntry(w, r)
 	case proto.AdminAddRaftNode:
 		fallthrough
@@ -105,12 +109,14 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (m *Server) handleFunctions() {
 	http.HandleFunc(proto.ClientGetTicket, m.getTicket)
+	http.HandleFunc(proto.ClientGetIdentityTicket, m.getIdentityTicket)
 	http.Handle(proto.AdminCreateKey, m.handlerWithInterceptor())
 	http.Handle(proto.AdminGetKey, m.handlerWithInterceptor())
 	http.Handle(proto.AdminDeleteKey, m.handlerWithInterceptor())
 	http.Handle(proto.AdminAddCaps, m.handlerWithInterceptor())
 	http.Handle(proto.AdminDeleteCaps, m.handlerWithInterceptor())
 	http.Handle(proto.AdminGetCaps, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRotateKey, m.handlerWithInterceptor())
 	http.Handle(proto.AdminAddRaftNode, m.handlerWithInterceptor())
 	http.Handle(proto.AdminRemoveRaftNode, m.handlerWithInterceptor())
 	http.Handle(proto.OSAddCaps, m.handlerWithInterceptor())
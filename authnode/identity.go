@@ -0,0 +1,127 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/keystore"
+)
+
+// The clients may authenticate with the external identities verified by the OIDC provider or the LDAP server
+// instead of the keys provisioned in the keystore. The external identity is mapped to the ID of a client key
+// in the keystore, whose capabilities are granted to the ticket, so the keys of the clients are managed
+// by the authnode only and never distributed.
+
+// identity configuration keys
+const (
+	cfgOIDCIssuer         = "oidcIssuer"
+	cfgOIDCClientID       = "oidcClientID"
+	cfgOIDCIdentityClaim  = "oidcIdentityClaim"
+	cfgLDAPURL            = "ldapURL"
+	cfgLDAPUserDN         = "ldapUserDN"
+	cfgIdentityMapping    = "identityMapping"
+	defaultIdentityClaim  = "sub"
+	identityMapSeparator  = "="
+	identityClientKeyRole = "client"
+)
+
+// identityProvider verifies the credential of the external identity.
+type identityProvider interface {
+	// authenticate returns the external identity if the credential in the request is valid.
+	authenticate(req *proto.AuthGetIdentityTicketReq) (identity string, err error)
+}
+
+// identityConfig holds the identity providers and the mapping from the external identities to the client IDs,
+// where the external identities are prefixed by the provider, e.g. "oidc:alice@example.com".
+type identityConfig struct {
+	providers map[string]identityProvider
+	mapping   map[string]string
+}
+
+func parseIdentityConfig(cfg *config.Config) (ic *identityConfig, err error) {
+	ic = &identityConfig{
+		providers: make(map[string]identityProvider),
+		mapping:   make(map[string]string),
+	}
+	if issuer := cfg.GetString(cfgOIDCIssuer); issuer != "" {
+		clientID := cfg.GetString(cfgOIDCClientID)
+		if clientID == "" {
+			return nil, fmt.Errorf("%v,err: %v is required by %v", proto.ErrInvalidCfg, cfgOIDCClientID, cfgOIDCIssuer)
+		}
+		claim := cfg.GetString(cfgOIDCIdentityClaim)
+		if claim == "" {
+			claim = defaultIdentityClaim
+		}
+		ic.providers[proto.IdentityProviderOIDC] = newOIDCProvider(issuer, clientID, claim)
+	}
+	if url := cfg.GetString(cfgLDAPURL); url != "" {
+		var provider *ldapProvider
+		if provider, err = newLDAPProvider(url, cfg.GetString(cfgLDAPUserDN)); err != nil {
+			return nil, fmt.Errorf("%v,err: %v", proto.ErrInvalidCfg, err)
+		}
+		ic.providers[proto.IdentityProviderLDAP] = provider
+	}
+	for _, item := range cfg.GetStringSlice(cfgIdentityMapping) {
+		i := strings.LastIndex(item, identityMapSeparator)
+		if i <= 0 {
+			return nil, fmt.Errorf("%v,err: invalid identity mapping [%v]", proto.ErrInvalidCfg, item)
+		}
+		identity, clientID := item[:i], item[i+1:]
+		if err = proto.IsValidClientID(clientID); err != nil {
+			return nil, fmt.Errorf("%v,err: %v", proto.ErrInvalidCfg, err)
+		}
+		ic.mapping[identity] = clientID
+	}
+	return
+}
+
+func (ic *identityConfig) enabled() bool {
+	return len(ic.providers) > 0
+}
+
+// authenticate verifies the credential by the provider of the request and returns the mapped client ID.
+func (ic *identityConfig) authenticate(req *proto.AuthGetIdentityTicketReq) (clientID string, err error) {
+	provider, ok := ic.providers[req.Provider]
+	if !ok {
+		return "", fmt.Errorf("identity provider [%s] not supported", req.Provider)
+	}
+	identity, err := provider.authenticate(req)
+	if err != nil {
+		return
+	}
+	if clientID, ok = ic.mapping[req.Provider+colonSplit+identity]; !ok {
+		clientID = identity
+	}
+	if err = proto.IsValidClientID(clientID); err != nil {
+		return "", fmt.Errorf("identity [%s:%s] not mapped to a client", req.Provider, identity)
+	}
+	return
+}
+
+// getIdentityKeyInfo returns the key of the client mapped from the external identity,
+// which must be a client key so that the identity never gets the keys of the services.
+func (m *Server) getIdentityKeyInfo(clientID string) (keyInfo *keystore.KeyInfo, err error) {
+	if keyInfo, err = m.cluster.GetKey(clientID); err != nil {
+		return
+	}
+	if keyInfo.Role != identityClientKeyRole {
+		return nil, fmt.Errorf("key [%s] is not a client key", clientID)
+	}
+	return
+}
@@ -0,0 +1,257 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const (
+	ldapScheme       = "ldap"
+	ldapsScheme      = "ldaps"
+	ldapDefaultPort  = "389"
+	ldapsDefaultPort = "636"
+	ldapUserHolder   = "%s"
+	ldapTimeout      = 10 * time.Second
+	ldapMaxReplySize = 1 << 16
+)
+
+// BER tags of the LDAP messages (RFC 4511)
+const (
+	berTagInteger       = 0x02
+	berTagOctetString   = 0x04
+	berTagEnumerated    = 0x0a
+	berTagSequence      = 0x30
+	ldapTagBindRequest  = 0x60
+	ldapTagBindResponse = 0x61
+	ldapTagUnbind       = 0x42
+	ldapTagSimpleAuth   = 0x80
+	ldapVersion         = 3
+	ldapResultSuccess   = 0
+)
+
+// ldapProvider verifies the user and the password by the simple bind to the LDAP server
+// with the DN of the user, which is generated from the template by replacing the placeholder with the user.
+type ldapProvider struct {
+	addr   string
+	useTLS bool
+	userDN string
+}
+
+func newLDAPProvider(rawURL, userDN string) (p *ldapProvider, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	p = &ldapProvider{addr: u.Host, userDN: userDN}
+	port := ldapDefaultPort
+	switch u.Scheme {
+	case ldapScheme:
+	case ldapsScheme:
+		p.useTLS = true
+		port = ldapsDefaultPort
+	default:
+		return nil, fmt.Errorf("invalid LDAP URL [%s]", rawURL)
+	}
+	if u.Port() == "" {
+		p.addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	if strings.Count(userDN, ldapUserHolder) != 1 {
+		return nil, fmt.Errorf("LDAP user DN [%s] should contain one %s", userDN, ldapUserHolder)
+	}
+	return
+}
+
+func (p *ldapProvider) authenticate(req *proto.AuthGetIdentityTicketReq) (identity string, err error) {
+	// the bind without password is an unauthenticated bind which always succeeds
+	if req.User == "" || req.Password == "" {
+		return "", fmt.Errorf("LDAP user and password are required")
+	}
+	if err = p.bind(strings.Replace(p.userDN, ldapUserHolder, escapeDNValue(req.User), 1), req.Password); err != nil {
+		return
+	}
+	return req.User, nil
+}
+
+func (p *ldapProvider) bind(dn, password string) (err error) {
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	var conn net.Conn
+	if p.useTLS {
+		host, _, _ := net.SplitHostPort(p.addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", p.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect LDAP server [%s] failed: %v", p.addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ldapTimeout))
+
+	bindReq := berEncode(ldapTagBindRequest, berInteger(ldapVersion),
+		berEncode(berTagOctetString, []byte(dn)), berEncode(ldapTagSimpleAuth, []byte(password)))
+	if _, err = conn.Write(berEncode(berTagSequence, berInteger(1), bindReq)); err != nil {
+		return fmt.Errorf("send LDAP bind request failed: %v", err)
+	}
+	tag, reply, err := berRead(conn)
+	if err != nil || tag != berTagSequence {
+		return fmt.Errorf("read LDAP bind response failed: tag[%x] err[%v]", tag, err)
+	}
+	code, msg, err := parseBindResponse(reply)
+	if err != nil {
+		return
+	}
+	conn.Write(berEncode(berTagSequence, berInteger(2), berEncode(ldapTagUnbind)))
+	if code != ldapResultSuccess {
+		return fmt.Errorf("LDAP bind failed: code[%v] msg[%s]", code, msg)
+	}
+	return
+}
+
+// parseBindResponse returns the result code and the diagnostic message of the bind response.
+func parseBindResponse(reply []byte) (code int, msg string, err error) {
+	tag, _, rest, err := berNext(reply)
+	if err != nil || tag != berTagInteger {
+		return 0, "", fmt.Errorf("invalid LDAP message ID")
+	}
+	tag, resp, _, err := berNext(rest)
+	if err != nil || tag != ldapTagBindResponse {
+		return 0, "", fmt.Errorf("invalid LDAP bind response: tag[%x]", tag)
+	}
+	tag, result, resp, err := berNext(resp)
+	if err != nil || tag != berTagEnumerated || len(result) == 0 {
+		return 0, "", fmt.Errorf("invalid LDAP result code")
+	}
+	for _, b := range result {
+		code = code<<8 | int(b)
+	}
+	// skip the matched DN
+	if _, _, resp, err = berNext(resp); err == nil {
+		if _, diagnostic, _, err := berNext(resp); err == nil {
+			msg = string(diagnostic)
+		}
+	}
+	return code, msg, nil
+}
+
+func berInteger(v int) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return berEncode(berTagInteger, b)
+}
+
+func berEncode(tag byte, values ...[]byte) []byte {
+	var value []byte
+	for _, v := range values {
+		value = append(value, v...)
+	}
+	b := []byte{tag}
+	if l := len(value); l < 0x80 {
+		b = append(b, byte(l))
+	} else {
+		var length []byte
+		for ; l > 0; l >>= 8 {
+			length = append([]byte{byte(l)}, length...)
+		}
+		b = append(b, 0x80|byte(len(length)))
+		b = append(b, length...)
+	}
+	return append(b, value...)
+}
+
+// berNext returns the tag and the value of the first element in b, and the rest of b.
+func berNext(b []byte) (tag byte, value, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	tag, l, n := b[0], int(b[1]), 2
+	if l&0x80 != 0 {
+		size := l & 0x7f
+		if size == 0 || size > 4 || len(b) < 2+size {
+			return 0, nil, nil, fmt.Errorf("invalid BER length")
+		}
+		l = 0
+		for _, c := range b[2 : 2+size] {
+			l = l<<8 | int(c)
+		}
+		n += size
+	}
+	if l < 0 || len(b)-n < l {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	return tag, b[n : n+l], b[n+l:], nil
+}
+
+// berRead reads an element from r.
+func berRead(r io.Reader) (tag byte, value []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	tag, l := header[0], int(header[1])
+	if l&0x80 != 0 {
+		size := l & 0x7f
+		if size == 0 || size > 4 {
+			return 0, nil, fmt.Errorf("invalid BER length")
+		}
+		length := make([]byte, size)
+		if _, err = io.ReadFull(r, length); err != nil {
+			return
+		}
+		l = 0
+		for _, c := range length {
+			l = l<<8 | int(c)
+		}
+	}
+	if l < 0 || l > ldapMaxReplySize {
+		return 0, nil, fmt.Errorf("LDAP reply too large: %v", l)
+	}
+	value = make([]byte, l)
+	_, err = io.ReadFull(r, value)
+	return
+}
+
+// escapeDNValue escapes the special characters of the attribute value in the DN (RFC 4514).
+func escapeDNValue(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case strings.IndexByte(`,+"\<>;=`, c) >= 0,
+			(c == ' ' || c == '#') && i == 0,
+			c == ' ' && i == len(v)-1:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,303 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const (
+	oidcDiscoveryPath    = "/.well-known/openid-configuration"
+	oidcRequestTimeout   = 10 * time.Second
+	oidcKeysSyncInterval = time.Minute
+	oidcClockSkew        = time.Minute
+)
+
+// oidcProvider verifies the ID tokens issued by the OIDC provider, which are the JWTs signed by the keys
+// published in the JWKS of the provider. The keys are fetched on demand and refreshed when a token is
+// signed by an unknown key, so the rotation of the keys by the provider is followed.
+type oidcProvider struct {
+	issuer   string
+	clientID string
+	claim    string
+	client   *http.Client
+
+	sync.Mutex
+	keys     map[string]crypto.PublicKey
+	syncTime time.Time
+}
+
+func newOIDCProvider(issuer, clientID, claim string) *oidcProvider {
+	return &oidcProvider{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		clientID: clientID,
+		claim:    claim,
+		client:   &http.Client{Timeout: oidcRequestTimeout},
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (p *oidcProvider) authenticate(req *proto.AuthGetIdentityTicketReq) (identity string, err error) {
+	parts := strings.Split(req.Token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid ID token")
+	}
+	var (
+		header    jwtHeader
+		claims    map[string]interface{}
+		data      []byte
+		signature []byte
+		key       crypto.PublicKey
+	)
+	if data, err = base64.RawURLEncoding.DecodeString(parts[0]); err != nil {
+		return "", fmt.Errorf("invalid ID token header: %v", err)
+	}
+	if err = json.Unmarshal(data, &header); err != nil {
+		return "", fmt.Errorf("invalid ID token header: %v", err)
+	}
+	if signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return "", fmt.Errorf("invalid ID token signature: %v", err)
+	}
+	if key, err = p.getKey(header.Kid); err != nil {
+		return
+	}
+	if err = verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return
+	}
+	if data, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return "", fmt.Errorf("invalid ID token claims: %v", err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err = decoder.Decode(&claims); err != nil {
+		return "", fmt.Errorf("invalid ID token claims: %v", err)
+	}
+	if err = p.verifyClaims(claims); err != nil {
+		return
+	}
+	if identity, _ = claims[p.claim].(string); identity == "" {
+		return "", fmt.Errorf("claim [%s] not found in ID token", p.claim)
+	}
+	return
+}
+
+func (p *oidcProvider) verifyClaims(claims map[string]interface{}) (err error) {
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.issuer {
+		return fmt.Errorf("ID token issuer [%v] mismatch", claims["iss"])
+	}
+	audienceMatched := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audienceMatched = aud == p.clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == p.clientID {
+				audienceMatched = true
+			}
+		}
+	}
+	if !audienceMatched {
+		return fmt.Errorf("ID token audience [%v] mismatch", claims["aud"])
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(json.Number)
+	if !ok {
+		return fmt.Errorf("ID token without expiration")
+	}
+	if t, err := exp.Int64(); err != nil || now.After(time.Unix(t, 0).Add(oidcClockSkew)) {
+		return fmt.Errorf("ID token expired")
+	}
+	if nbf, ok := claims["nbf"].(json.Number); ok {
+		if t, err := nbf.Int64(); err != nil || now.Add(oidcClockSkew).Before(time.Unix(t, 0)) {
+			return fmt.Errorf("ID token not valid yet")
+		}
+	}
+	return
+}
+
+// getKey returns the key of the kid, and refreshes the keys of the provider if not found.
+func (p *oidcProvider) getKey(kid string) (key crypto.PublicKey, err error) {
+	p.Lock()
+	defer p.Unlock()
+	if key = p.findKey(kid); key != nil {
+		return
+	}
+	if time.Since(p.syncTime) < oidcKeysSyncInterval {
+		return nil, fmt.Errorf("ID token signed by unknown key [%s]", kid)
+	}
+	p.syncTime = time.Now()
+	if err = p.syncKeys(); err != nil {
+		return nil, fmt.Errorf("fetch keys of [%s] failed: %v", p.issuer, err)
+	}
+	if key = p.findKey(kid); key == nil {
+		return nil, fmt.Errorf("ID token signed by unknown key [%s]", kid)
+	}
+	return
+}
+
+func (p *oidcProvider) findKey(kid string) crypto.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+func (p *oidcProvider) syncKeys() (err error) {
+	var (
+		discovery struct {
+			JwksURI string `json:"jwks_uri"`
+		}
+		jwks struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+	)
+	if err = p.getJSON(p.issuer+oidcDiscoveryPath, &discovery); err != nil {
+		return
+	}
+	if discovery.JwksURI == "" {
+		return fmt.Errorf("jwks_uri not found")
+	}
+	if err = p.getJSON(discovery.JwksURI, &jwks); err != nil {
+		return
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+	return
+}
+
+func (p *oidcProvider) getJSON(url string, v interface{}) (err error) {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get [%s] failed: status[%v]", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (jwk *jsonWebKey) publicKey() (key crypto.PublicKey, err error) {
+	switch jwk.Kty {
+	case "RSA":
+		var n, e []byte
+		if n, err = base64.RawURLEncoding.DecodeString(jwk.N); err != nil {
+			return
+		}
+		if e, err = base64.RawURLEncoding.DecodeString(jwk.E); err != nil {
+			return
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var (
+			curve elliptic.Curve
+			x, y  []byte
+		)
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve [%s]", jwk.Crv)
+		}
+		if x, err = base64.RawURLEncoding.DecodeString(jwk.X); err != nil {
+			return
+		}
+		if y, err = base64.RawURLEncoding.DecodeString(jwk.Y); err != nil {
+			return
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type [%s]", jwk.Kty)
+}
+
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, signature []byte) (err error) {
+	var (
+		hash      crypto.Hash
+		curveSize int
+	)
+	switch alg {
+	case "RS256", "ES256":
+		hash, curveSize = crypto.SHA256, 256
+	case "RS384", "ES384":
+		hash, curveSize = crypto.SHA384, 384
+	case "RS512", "ES512":
+		hash, curveSize = crypto.SHA512, 521
+	default:
+		return fmt.Errorf("unsupported ID token algorithm [%s]", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] == 'R' && rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil {
+			return
+		}
+	case *ecdsa.PublicKey:
+		size := (curveSize + 7) / 8
+		if alg[0] == 'E' && k.Curve.Params().BitSize == curveSize && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(k, digest, r, s) {
+				return
+			}
+		}
+	}
+	return fmt.Errorf("ID token signature verification failed")
+}
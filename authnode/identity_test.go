@@ -0,0 +1,183 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package authnode
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign fail: err(%v)", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key fail: err(%v)", err)
+	}
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidcDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: "k1",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	issuer = server.URL
+
+	p := newOIDCProvider(issuer, "cfs", "email")
+	claims := map[string]interface{}{
+		"iss":   issuer,
+		"aud":   []string{"other", "cfs"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": "alice@example.com",
+	}
+	identity, err := p.authenticate(&proto.AuthGetIdentityTicketReq{Token: signJWT(t, key, "k1", claims)})
+	if err != nil || identity != "alice@example.com" {
+		t.Fatalf("authenticate fail: identity(%v) err(%v)", identity, err)
+	}
+
+	claims["aud"] = "other"
+	if _, err = p.authenticate(&proto.AuthGetIdentityTicketReq{Token: signJWT(t, key, "k1", claims)}); err == nil {
+		t.Fatalf("token of other audience should be refused")
+	}
+	claims["aud"] = "cfs"
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	if _, err = p.authenticate(&proto.AuthGetIdentityTicketReq{Token: signJWT(t, key, "k1", claims)}); err == nil {
+		t.Fatalf("expired token should be refused")
+	}
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err = p.authenticate(&proto.AuthGetIdentityTicketReq{Token: signJWT(t, other, "k1", claims)}); err == nil {
+		t.Fatalf("token signed by other key should be refused")
+	}
+}
+
+// serveLDAP accepts a bind request and replies the result code by the password.
+func serveLDAP(ln net.Listener, dns chan<- string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_, msg, err := berRead(conn)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		_, _, rest, _ := berNext(msg)
+		_, bindReq, _, _ := berNext(rest)
+		_, _, bindReq, _ = berNext(bindReq)
+		_, dn, bindReq, _ := berNext(bindReq)
+		_, password, _, _ := berNext(bindReq)
+		dns <- string(dn)
+		code := byte(49)
+		if string(password) == "secret" {
+			code = ldapResultSuccess
+		}
+		resp := berEncode(ldapTagBindResponse, berEncode(berTagEnumerated, []byte{code}),
+			berEncode(berTagOctetString), berEncode(berTagOctetString, []byte("done")))
+		conn.Write(berEncode(berTagSequence, berInteger(1), resp))
+		conn.Close()
+	}
+}
+
+func TestLDAPProvider(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail: err(%v)", err)
+	}
+	defer ln.Close()
+	dns := make(chan string, 4)
+	go serveLDAP(ln, dns)
+
+	if _, err = newLDAPProvider("ldap://"+ln.Addr().String(), "ou=people,dc=example,dc=com"); err == nil {
+		t.Fatalf("user DN without placeholder should be refused")
+	}
+	p, err := newLDAPProvider("ldap://"+ln.Addr().String(), "uid=%s,ou=people,dc=example,dc=com")
+	if err != nil {
+		t.Fatalf("new provider fail: err(%v)", err)
+	}
+	identity, err := p.authenticate(&proto.AuthGetIdentityTicketReq{User: "bob", Password: "secret"})
+	if err != nil || identity != "bob" {
+		t.Fatalf("authenticate fail: identity(%v) err(%v)", identity, err)
+	}
+	if dn := <-dns; dn != "uid=bob,ou=people,dc=example,dc=com" {
+		t.Fatalf("unexpected dn: %v", dn)
+	}
+	if _, err = p.authenticate(&proto.AuthGetIdentityTicketReq{User: "bob,ou=admin", Password: "wrong"}); err == nil {
+		t.Fatalf("wrong password should be refused")
+	}
+	if dn := <-dns; dn != `uid=bob\,ou\=admin,ou=people,dc=example,dc=com` {
+		t.Fatalf("user not escaped: %v", dn)
+	}
+	if _, err = p.authenticate(&proto.AuthGetIdentityTicketReq{User: "bob"}); err == nil {
+		t.Fatalf("empty password should be refused")
+	}
+}
+
+func TestIdentityMapping(t *testing.T) {
+	ic := &identityConfig{
+		providers: map[string]identityProvider{proto.IdentityProviderLDAP: &ldapProvider{}},
+		mapping:   map[string]string{"ldap:alice@example.com": "alice"},
+	}
+	if _, err := ic.authenticate(&proto.AuthGetIdentityTicketReq{Provider: proto.IdentityProviderOIDC}); err == nil {
+		t.Fatalf("provider not configured should be refused")
+	}
+	ic.providers[proto.IdentityProviderLDAP] = identityProviderFunc(func(req *proto.AuthGetIdentityTicketReq) (string, error) {
+		return req.User, nil
+	})
+	for user, expected := range map[string]string{"alice@example.com": "alice", "bob": "bob", "carol@example.com": ""} {
+		clientID, err := ic.authenticate(&proto.AuthGetIdentityTicketReq{Provider: proto.IdentityProviderLDAP, User: user})
+		if clientID != expected || (expected == "") != (err != nil) {
+			t.Fatalf("unexpected mapping of %v: clientID(%v) err(%v)", user, clientID, err)
+		}
+	}
+}
+
+type identityProviderFunc func(req *proto.AuthGetIdentityTicketReq) (string, error)
+
+func (f identityProviderFunc) authenticate(req *proto.AuthGetIdentityTicketReq) (string, error) {
+	return f(req)
+}
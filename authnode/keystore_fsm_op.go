@@ -83,6 +83,10 @@ func (c *Cluster) syncAddCaps(keyInfo *keystore.KeyInfo) (err error) {
 	return c.syncPutKeyInfo(opSyncAddCaps, keyInfo)
 }
 
+func (c *Cluster) syncRotateKey(keyInfo *keystore.KeyInfo) (err error) {
+	return c.syncPutKeyInfo(opSyncRotateKey, keyInfo)
+}
+
 func (c *Cluster) syncDeleteKey(keyInfo *keystore.KeyInfo) (err error) {
 	return c.syncPutKeyInfo(opSyncDeleteKey, keyInfo)
 }
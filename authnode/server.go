@@ -27,9 +27,9 @@ import (
 	"github.com/cubefs/cubefs/raftstore"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/config"
-	"github.com/cubefs/cubefs/util/cryptoutil"
 
 	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/kms"
 	"github.com/cubefs/cubefs/util/log"
 )
 
@@ -68,6 +68,7 @@ type Server struct {
 	wg           sync.WaitGroup
 	authProxy    *AuthProxy
 	metaReady    bool
+	identity     *identityConfig
 }
 
 // configuration keys
@@ -90,6 +91,31 @@ const (
 	EnableHTTPS       = "enableHTTPS"
 )
 
+// ConfigSchema declares the configuration keys of the authnode.
+var ConfigSchema = config.MergeSchemas(kms.ConfigSchema, config.Schema{
+	ClusterName:          config.TypeString,
+	ID:                   config.TypeString,
+	IP:                   config.TypeString,
+	Port:                 config.TypeString,
+	WalDir:               config.TypeString,
+	StoreDir:             config.TypeString,
+	CfgRetainLogs:        config.TypeString,
+	cfgTickInterval:      config.TypeFloat,
+	cfgElectionTick:      config.TypeFloat,
+	AuthSecretKey:        config.TypeString,
+	AuthRootKey:          config.TypeString,
+	EnableHTTPS:          config.TypeBool,
+	cfgPeers:             config.TypeString,
+	heartbeatPortKey:     config.TypeInt,
+	replicaPortKey:       config.TypeInt,
+	cfgOIDCIssuer:        config.TypeString,
+	cfgOIDCClientID:      config.TypeString,
+	cfgOIDCIdentityClaim: config.TypeString,
+	cfgLDAPURL:           config.TypeString,
+	cfgLDAPUserDN:        config.TypeString,
+	cfgIdentityMapping:   config.TypeStringSlice,
+})
+
 // NewServer creates a new server
 func NewServer() *Server {
 	return &Server{}
@@ -202,13 +228,19 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 	m.initCluster()
 	m.cluster.partition = m.partition
 
+	// the keys of the config may be wrapped by the KMS
+	var kmsProvider kms.Provider
+	if kmsProvider, err = kms.NewProvider(cfg); err != nil {
+		return fmt.Errorf("action[Start] failed %v,err: KMS: %v", proto.ErrInvalidCfg, err)
+	}
+
 	AuthSecretKey := cfg.GetString(AuthSecretKey)
-	if m.cluster.AuthSecretKey, err = cryptoutil.Base64Decode(AuthSecretKey); err != nil {
+	if m.cluster.AuthSecretKey, err = kms.DecodeKey(kmsProvider, AuthSecretKey); err != nil {
 		return fmt.Errorf("action[Start] failed %v,err: auth service Key invalid=%s", proto.ErrInvalidCfg, AuthSecretKey)
 	}
 
 	AuthRootKey := cfg.GetString(AuthRootKey)
-	if m.cluster.AuthRootKey, err = cryptoutil.Base64Decode(AuthRootKey); err != nil {
+	if m.cluster.AuthRootKey, err = kms.DecodeKey(kmsProvider, AuthRootKey); err != nil {
 		return fmt.Errorf("action[Start] failed %v,err: auth root Key invalid=%s", proto.ErrInvalidCfg, AuthRootKey)
 	}
 
@@ -226,6 +258,14 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 	}
 	m.authProxy = m.newAuthProxy()
 
+	if m.identity, err = parseIdentityConfig(cfg); err != nil {
+		return fmt.Errorf("action[Start] failed,err[%v]", err)
+	}
+	// the passwords and the tokens of the external identities are sent in plaintext without HTTPS
+	if m.identity.enabled() && !m.cluster.PKIKey.EnableHTTPS {
+		return fmt.Errorf("action[Start] failed %v,err: identity authentication requires %v", proto.ErrInvalidCfg, EnableHTTPS)
+	}
+
 	m.cluster.scheduleTask()
 	m.startHTTPService()
 	m.wg.Add(1)
@@ -20,19 +20,22 @@ import (
 
 // requst path
 const (
-	GetTicket      = "getticket"
-	CreateKey      = "createkey"
-	DeleteKey      = "deletekey"
-	GetKey         = "getkey"
-	AddCaps        = "addcaps"
-	DeleteCaps     = "deletecaps"
-	AddRaftNode    = "addraftnode"
-	RemoveRaftNode = "removeraftnode"
-	OSAddCaps      = "osaddcaps"
-	OSDeleteCaps   = "osdeletecaps"
-	OSGetCaps      = "osgetcaps"
-	HTTP           = "http://"
-	HTTPS          = "https://"
+	GetTicket        = "getticket"
+	CreateKey        = "createkey"
+	DeleteKey        = "deletekey"
+	GetKey           = "getkey"
+	AddCaps          = "addcaps"
+	DeleteCaps       = "deletecaps"
+	RotateKey        = "rotatekey"
+	AddRaftNode      = "addraftnode"
+	RemoveRaftNode   = "removeraftnode"
+	OSAddCaps        = "osaddcaps"
+	OSDeleteCaps     = "osdeletecaps"
+	OSGetCaps        = "osgetcaps"
+	AddServiceKey    = "addservicekey"
+	RetireServiceKey = "retireservicekey"
+	HTTP             = "http://"
+	HTTPS            = "https://"
 )
 
 const (
@@ -42,20 +45,24 @@ const (
 	AccessKey  = "access_key"
 	AuthKey    = "auth_key"
 	SessionKey = "session_key"
+	KeyVersion = "key_version"
 )
 
 var action2PathMap = map[string]string{
-	GetTicket:      proto.ClientGetTicket,
-	CreateKey:      proto.AdminCreateKey,
-	DeleteKey:      proto.AdminDeleteKey,
-	GetKey:         proto.AdminGetKey,
-	AddCaps:        proto.AdminAddCaps,
-	DeleteCaps:     proto.AdminDeleteCaps,
-	AddRaftNode:    proto.AdminAddRaftNode,
-	RemoveRaftNode: proto.AdminRemoveRaftNode,
-	OSAddCaps:      proto.OSAddCaps,
-	OSDeleteCaps:   proto.OSDeleteCaps,
-	OSGetCaps:      proto.OSGetCaps,
+	GetTicket:        proto.ClientGetTicket,
+	CreateKey:        proto.AdminCreateKey,
+	DeleteKey:        proto.AdminDeleteKey,
+	GetKey:           proto.AdminGetKey,
+	AddCaps:          proto.AdminAddCaps,
+	DeleteCaps:       proto.AdminDeleteCaps,
+	RotateKey:        proto.AdminRotateKey,
+	AddRaftNode:      proto.AdminAddRaftNode,
+	RemoveRaftNode:   proto.AdminRemoveRaftNode,
+	OSAddCaps:        proto.OSAddCaps,
+	OSDeleteCaps:     proto.OSDeleteCaps,
+	OSGetCaps:        proto.OSGetCaps,
+	AddServiceKey:    proto.AdminAddServiceKey,
+	RetireServiceKey: proto.AdminRetireServiceKey,
 }
 
 var (
@@ -233,6 +240,8 @@ func accessAuthServer() {
 		msg = proto.MsgAuthAddCapsReq
 	case DeleteCaps:
 		msg = proto.MsgAuthDeleteCapsReq
+	case RotateKey:
+		msg = proto.MsgAuthRotateKeyReq
 	case AddRaftNode:
 		msg = proto.MsgAuthAddRaftNodeReq
 	case RemoveRaftNode:
@@ -301,6 +310,19 @@ func accessAuthServer() {
 				Caps: []byte(dataCFG.GetString(Caps)),
 			},
 		}
+	case RotateKey:
+		var authKey []byte
+		if authKey, err = cryptoutil.Base64Decode(dataCFG.GetString(AuthKey)); err != nil {
+			panic(err)
+		}
+		message = proto.AuthAPIAccessReq{
+			APIReq: *apiReq,
+			KeyInfo: keystore.KeyInfo{
+				ID:         dataCFG.GetString(ID),
+				AuthKey:    authKey,
+				KeyVersion: uint32(dataCFG.GetInt64(KeyVersion)),
+			},
+		}
 	case AddRaftNode:
 		fallthrough
 	case RemoveRaftNode:
@@ -356,6 +378,8 @@ func accessAuthServer() {
 	case AddCaps:
 		fallthrough
 	case DeleteCaps:
+		fallthrough
+	case RotateKey:
 		var resp proto.AuthAPIAccessResp
 		if resp, err = proto.ParseAuthAPIAccessResp(body, sessionKey); err != nil {
 			panic(err)
@@ -411,10 +435,92 @@ func accessAuthServer() {
 
 }
 
+// accessMasterServer adds or retires a version of the master service key with a ticket of MasterService.
+func accessMasterServer() {
+	var (
+		msg        proto.MsgType
+		sessionKey []byte
+		err        error
+		body       []byte
+		reply      proto.HTTPReply
+	)
+
+	switch flaginfo.api.request {
+	case AddServiceKey:
+		msg = proto.MsgMasterAddServiceKeyReq
+	case RetireServiceKey:
+		msg = proto.MsgMasterRetireServiceKeyReq
+	default:
+		panic(fmt.Errorf("wrong requst [%s]", flaginfo.api.request))
+	}
+
+	ticketCFG, err := config.LoadConfigFile(flaginfo.api.ticket)
+	if err != nil {
+		panic(err)
+	}
+
+	apiReq := &proto.APIAccessReq{
+		Type:      msg,
+		ClientID:  ticketCFG.GetString(ID),
+		ServiceID: proto.MasterServiceID,
+	}
+
+	if sessionKey, err = cryptoutil.Base64Decode(ticketCFG.GetString(SessionKey)); err != nil {
+		panic(err)
+	}
+
+	if apiReq.Verifier, _, err = cryptoutil.GenVerifier(sessionKey); err != nil {
+		panic(err)
+	}
+	apiReq.Ticket = ticketCFG.GetString("ticket")
+
+	dataCFG, err := config.LoadConfigFile(flaginfo.api.data)
+	if err != nil {
+		panic(err)
+	}
+
+	message := proto.MasterServiceKeyReq{
+		APIReq: *apiReq,
+		KeyID:  uint32(dataCFG.GetInt64(ID)),
+	}
+	if flaginfo.api.request == AddServiceKey {
+		var authKey []byte
+		if authKey, err = cryptoutil.Base64Decode(dataCFG.GetString(AuthKey)); err != nil {
+			panic(err)
+		}
+		// the key is encrypted by the session key, only the master holding the service key decrypts it
+		if message.Key, err = cryptoutil.EncodeMessage(authKey, sessionKey); err != nil {
+			panic(err)
+		}
+	}
+
+	url := flaginfo.api.host + action2PathMap[flaginfo.api.request]
+
+	if flaginfo.https.enable {
+		body, err = sendReqX(url, message, &flaginfo.https.cert)
+	} else {
+		body, err = sendReq(url, message)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	if err = json.Unmarshal(body, &reply); err != nil {
+		panic(fmt.Errorf("invalid reply [%s]: %v", body, err))
+	}
+	if reply.Code != proto.ErrCodeSuccess {
+		panic(fmt.Errorf("code [%d] msg [%s]", reply.Code, reply.Msg))
+	}
+	fmt.Printf("%v\n", reply.Data)
+}
+
 func accessAPI() {
 	switch flaginfo.api.service {
 	case proto.AuthServiceID:
 		accessAuthServer()
+	case proto.MasterServiceID:
+		accessMasterServer()
 	default:
 		panic(fmt.Errorf("server type error [%s]", flaginfo.api.service))
 	}
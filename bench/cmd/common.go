@@ -0,0 +1,360 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+var (
+	MasterAddr  string
+	VolName     string
+	BenchPath   string
+	Concurrency []int
+	Keep        bool
+	ReportFile  string
+)
+
+// checkArgs checks the common args.
+func checkArgs() error {
+	if MasterAddr == "" || VolName == "" {
+		return fmt.Errorf("Lack of mandatory args: master(%v) vol(%v)", MasterAddr, VolName)
+	}
+	if len(Concurrency) == 0 {
+		return fmt.Errorf("No concurrency specified")
+	}
+	for _, c := range Concurrency {
+		if c <= 0 {
+			return fmt.Errorf("Invalid concurrency: %v", c)
+		}
+	}
+	return nil
+}
+
+func initLog(module string) error {
+	if _, err := log.InitLog("benchlog", module, log.InfoLevel, nil); err != nil {
+		return fmt.Errorf("Init log failed: %v", err)
+	}
+	return nil
+}
+
+// volume is the metadata and the data clients of the volume benchmarked.
+type volume struct {
+	mw *meta.MetaWrapper
+	ec *stream.ExtentClient
+}
+
+func openVolume() (v *volume, err error) {
+	masters := strings.Split(MasterAddr, meta.HostsSeparator)
+	v = &volume{}
+	if v.mw, err = meta.NewMetaWrapper(&meta.MetaConfig{
+		Volume:  VolName,
+		Masters: masters,
+	}); err != nil {
+		return nil, fmt.Errorf("NewMetaWrapper failed: vol(%v) err(%v)", VolName, err)
+	}
+	if v.ec, err = stream.NewExtentClient(&stream.ExtentConfig{
+		Volume:            VolName,
+		Masters:           masters,
+		OnAppendExtentKey: v.mw.AppendExtentKey,
+		OnGetExtents:      v.mw.GetExtents,
+		OnTruncate:        v.mw.Truncate,
+		OnPunchHole:       v.mw.PunchHole,
+	}); err != nil {
+		_ = v.mw.Close()
+		return nil, fmt.Errorf("NewExtentClient failed: vol(%v) err(%v)", VolName, err)
+	}
+	return v, nil
+}
+
+func (v *volume) close() {
+	_ = v.ec.Close()
+	_ = v.mw.Close()
+}
+
+// mkdirAll creates the directory and its parents if they do not exist, and returns its inode.
+func (v *volume) mkdirAll(dir string) (ino uint64, err error) {
+	ino = proto.RootIno
+	for _, name := range strings.Split(dir, "/") {
+		if name == "" {
+			continue
+		}
+		if ino, err = v.mkdir(ino, name); err != nil {
+			return 0, fmt.Errorf("Mkdir failed: path(%v) err(%v)", dir, err)
+		}
+	}
+	return
+}
+
+// mkdir creates the directory in the parent, or returns the existing one.
+func (v *volume) mkdir(parent uint64, name string) (uint64, error) {
+	ino, mode, err := v.mw.Lookup_ll(parent, name)
+	if err == nil {
+		if !proto.IsDir(mode) {
+			return 0, syscall.ENOTDIR
+		}
+		return ino, nil
+	}
+	if err != syscall.ENOENT {
+		return 0, err
+	}
+	info, err := v.mw.Create_ll(parent, name, proto.Mode(os.ModeDir|0755), 0, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	return info.Inode, nil
+}
+
+// remove removes the file or the empty directory from the parent and evicts its inode.
+func (v *volume) remove(parent uint64, name string, isDir bool) error {
+	info, err := v.mw.Delete_ll(parent, name, isDir)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		_ = v.ec.EvictStream(info.Inode)
+		_ = v.mw.Evict(info.Inode)
+	}
+	return nil
+}
+
+// workDirs creates a directory for each worker in the directory, and returns their inodes.
+func (v *volume) workDirs(dir string, workers int) (inodes []uint64, err error) {
+	parent, err := v.mkdirAll(dir)
+	if err != nil {
+		return
+	}
+	inodes = make([]uint64, workers)
+	for i := range inodes {
+		if inodes[i], err = v.mkdir(parent, workerName(i)); err != nil {
+			return nil, fmt.Errorf("Mkdir failed: path(%v/%v) err(%v)", dir, workerName(i), err)
+		}
+	}
+	return
+}
+
+// removeAll removes the directory and everything in it.
+func (v *volume) removeAll(dir string) error {
+	ino, err := v.mw.LookupPath(dir)
+	if err == syscall.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = v.removeChildren(ino); err != nil {
+		return err
+	}
+	parent, err := v.mw.LookupPath(path.Dir(dir))
+	if err != nil {
+		return err
+	}
+	return v.remove(parent, path.Base(dir), true)
+}
+
+func (v *volume) removeChildren(ino uint64) error {
+	dentries, err := v.mw.ReadDir_ll(ino)
+	if err != nil {
+		return err
+	}
+	for _, den := range dentries {
+		isDir := proto.IsDir(den.Type)
+		if isDir {
+			if err = v.removeChildren(den.Inode); err != nil {
+				return err
+			}
+		}
+		if err = v.remove(ino, den.Name, isDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func workerName(i int) string {
+	return fmt.Sprintf("w%d", i)
+}
+
+// recorder records the latencies of the operations of a worker.
+type recorder struct {
+	latencies []time.Duration
+	errors    int64
+	bytes     int64
+}
+
+func (r *recorder) record(start time.Time, n int, err error) {
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.latencies = append(r.latencies, time.Since(start))
+	r.bytes += int64(n)
+}
+
+// result is the result of a workload run by the workers.
+type result struct {
+	Workload    string  `json:"workload"`
+	Concurrency int     `json:"concurrency"`
+	BlockSize   int     `json:"blockSize,omitempty"`
+	Ops         int     `json:"ops"`
+	Errors      int64   `json:"errors"`
+	Bytes       int64   `json:"bytes"`
+	ElapsedSec  float64 `json:"elapsedSec"`
+	OpsPerSec   float64 `json:"opsPerSec"`
+	MBPerSec    float64 `json:"mbPerSec"`
+	AvgUs       int64   `json:"avgUs"`
+	P50Us       int64   `json:"p50Us"`
+	P90Us       int64   `json:"p90Us"`
+	P99Us       int64   `json:"p99Us"`
+	P999Us      int64   `json:"p999Us"`
+	MaxUs       int64   `json:"maxUs"`
+}
+
+// run runs the workload by the workers concurrently, and returns the merged result.
+func run(workload string, workers, blockSize int, fn func(worker int, r *recorder)) *result {
+	recorders := make([]*recorder, workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range recorders {
+		recorders[i] = &recorder{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i, recorders[i])
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	res := &result{Workload: workload, Concurrency: workers, BlockSize: blockSize, ElapsedSec: elapsed.Seconds()}
+	var latencies []time.Duration
+	for _, r := range recorders {
+		latencies = append(latencies, r.latencies...)
+		res.Errors += r.errors
+		res.Bytes += r.bytes
+	}
+	res.Ops = len(latencies)
+	if res.Ops == 0 {
+		return res
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	percentile := func(p float64) int64 {
+		return latencies[int(float64(len(latencies)-1)*p)].Microseconds()
+	}
+	res.OpsPerSec = float64(res.Ops) / elapsed.Seconds()
+	res.MBPerSec = float64(res.Bytes) / elapsed.Seconds() / util.MB
+	res.AvgUs = (total / time.Duration(len(latencies))).Microseconds()
+	res.P50Us, res.P90Us, res.P99Us, res.P999Us = percentile(0.5), percentile(0.9), percentile(0.99), percentile(0.999)
+	res.MaxUs = latencies[len(latencies)-1].Microseconds()
+	return res
+}
+
+var (
+	resultTablePattern = "%-10v  %-6v  %-8v  %-10v  %-8v  %-10v  %-10v  %-10v  %-10v  %-10v  %-10v  %-10v  %-10v"
+	resultTableHeader  = fmt.Sprintf(resultTablePattern, "WORKLOAD", "CONC", "BLOCK", "OPS", "ERRORS", "OPS/S", "MB/S",
+		"AVG(us)", "P50(us)", "P90(us)", "P99(us)", "P999(us)", "MAX(us)")
+)
+
+func formatResult(res *result) string {
+	block := "-"
+	if res.BlockSize > 0 {
+		block = formatSize(res.BlockSize)
+	}
+	return fmt.Sprintf(resultTablePattern, res.Workload, res.Concurrency, block, res.Ops, res.Errors,
+		fmt.Sprintf("%.1f", res.OpsPerSec), fmt.Sprintf("%.2f", res.MBPerSec),
+		res.AvgUs, res.P50Us, res.P90Us, res.P99Us, res.P999Us, res.MaxUs)
+}
+
+// reporter prints the results as they come, and writes them all to the report file at last.
+type reporter struct {
+	results []*result
+}
+
+func (rp *reporter) add(res *result) {
+	if len(rp.results) == 0 {
+		fmt.Println(resultTableHeader)
+	}
+	rp.results = append(rp.results, res)
+	fmt.Println(formatResult(res))
+	log.LogInfof("bench result: %+v", res)
+}
+
+func (rp *reporter) flush() error {
+	if ReportFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(rp.results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(ReportFile, data, 0644); err != nil {
+		return fmt.Errorf("Write report failed: %v", err)
+	}
+	return nil
+}
+
+// parseSize parses the size with the optional unit K, M or G.
+func parseSize(s string) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = util.KB
+	case strings.HasSuffix(s, "M"):
+		unit = util.MB
+	case strings.HasSuffix(s, "G"):
+		unit = util.GB
+	}
+	if unit != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("Invalid size: %v", s)
+	}
+	return n * unit, nil
+}
+
+func formatSize(n int) string {
+	switch {
+	case n >= util.GB && n%util.GB == 0:
+		return fmt.Sprintf("%dG", n/util.GB)
+	case n >= util.MB && n%util.MB == 0:
+		return fmt.Sprintf("%dM", n/util.MB)
+	case n >= util.KB && n%util.KB == 0:
+		return fmt.Sprintf("%dK", n/util.KB)
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// data workloads
+const (
+	workloadWrite     = "write"
+	workloadRead      = "read"
+	workloadRandWrite = "randwrite"
+	workloadRandRead  = "randread"
+)
+
+func newDataCmd() *cobra.Command {
+	var (
+		workloads  []string
+		blockSizes []string
+		fileSize   string
+	)
+	var c = &cobra.Command{
+		Use:   "data",
+		Short: "benchmark sequential and random reads and writes",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := DataBench(workloads, blockSizes, fileSize); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	c.Flags().StringSliceVarP(&workloads, "workloads", "", []string{workloadWrite, workloadRead, workloadRandWrite, workloadRandRead},
+		"workloads to run, in the order of write, read, randwrite and randread")
+	c.Flags().StringSliceVarP(&blockSizes, "block-size", "b", []string{"4K", "128K", "1M"}, "sizes of the reads and the writes, the workloads are run with each of them")
+	c.Flags().StringVarP(&fileSize, "file-size", "s", "256M", "size of the file of each worker")
+	return c
+}
+
+// DataBench runs the data workloads with each concurrency and block size. Every worker writes its own
+// file sequentially first, which is not reported if the write workload is not selected, then reads it
+// sequentially, and overwrites and reads it at the random offsets aligned to the block size, as many
+// times as the blocks of the file.
+func DataBench(workloads, blockSizes []string, fileSize string) (err error) {
+	defer log.LogFlush()
+	if err = checkArgs(); err != nil {
+		return
+	}
+	selected := make(map[string]bool)
+	for _, w := range workloads {
+		switch w {
+		case workloadWrite, workloadRead, workloadRandWrite, workloadRandRead:
+			selected[w] = true
+		default:
+			return fmt.Errorf("Invalid workload: %v", w)
+		}
+	}
+	size, err := parseSize(fileSize)
+	if err != nil {
+		return
+	}
+	var sizes []int
+	for _, s := range blockSizes {
+		var bs int
+		if bs, err = parseSize(s); err != nil {
+			return
+		}
+		if bs > size {
+			return fmt.Errorf("Block size %v is larger than the file size %v", s, fileSize)
+		}
+		sizes = append(sizes, bs)
+	}
+	if err = initLog("data"); err != nil {
+		return
+	}
+	v, err := openVolume()
+	if err != nil {
+		return
+	}
+	defer v.close()
+
+	rp := &reporter{}
+	runDir := path.Join(BenchPath, time.Now().Format("20060102150405"))
+	for _, workers := range Concurrency {
+		for _, bs := range sizes {
+			dir := path.Join(runDir, fmt.Sprintf("data-c%d-b%s", workers, strings.ToLower(formatSize(bs))))
+			if err = dataBench(v, rp, dir, workers, bs, size, selected); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if !Keep {
+		if e := v.removeAll(runDir); e != nil {
+			log.LogWarnf("remove directory failed: path(%v) err(%v)", runDir, e)
+		}
+	}
+	if err != nil {
+		return
+	}
+	return rp.flush()
+}
+
+func dataBench(v *volume, rp *reporter, dir string, workers, blockSize, fileSize int, selected map[string]bool) error {
+	dirs, err := v.workDirs(dir, workers)
+	if err != nil {
+		return err
+	}
+	inodes := make([]uint64, workers)
+	for i := range inodes {
+		info, err := v.mw.Create_ll(dirs[i], "data", proto.Mode(0644), 0, 0, nil)
+		if err != nil {
+			return fmt.Errorf("Create file failed: path(%v/%v/data) err(%v)", dir, workerName(i), err)
+		}
+		inodes[i] = info.Inode
+		if err = v.ec.OpenStream(info.Inode); err != nil {
+			return fmt.Errorf("Open stream failed: ino(%v) err(%v)", info.Inode, err)
+		}
+	}
+	defer func() {
+		for _, ino := range inodes {
+			_ = v.ec.CloseStream(ino)
+			_ = v.ec.EvictStream(ino)
+		}
+	}()
+	blocks := fileSize / blockSize
+
+	res := run(workloadWrite, workers, blockSize, func(worker int, r *recorder) {
+		buf := make([]byte, blockSize)
+		rand.Read(buf)
+		for i := 0; i < blocks; i++ {
+			start := time.Now()
+			n, err := v.ec.Write(inodes[worker], i*blockSize, buf, 0)
+			r.record(start, n, err)
+		}
+		if err := v.ec.Flush(inodes[worker]); err != nil {
+			r.errors++
+		}
+	})
+	if res.Errors > 0 {
+		rp.add(res)
+		return fmt.Errorf("Write failed, %v errors", res.Errors)
+	}
+	if selected[workloadWrite] {
+		rp.add(res)
+	}
+	if selected[workloadRead] {
+		rp.add(run(workloadRead, workers, blockSize, func(worker int, r *recorder) {
+			buf := make([]byte, blockSize)
+			for i := 0; i < blocks; i++ {
+				start := time.Now()
+				n, err := v.ec.Read(inodes[worker], buf, i*blockSize, blockSize)
+				if err == io.EOF {
+					err = nil
+				}
+				r.record(start, n, err)
+			}
+		}))
+	}
+	if selected[workloadRandWrite] {
+		rp.add(run(workloadRandWrite, workers, blockSize, func(worker int, r *recorder) {
+			buf := make([]byte, blockSize)
+			rand.Read(buf)
+			rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			for i := 0; i < blocks; i++ {
+				start := time.Now()
+				n, err := v.ec.Write(inodes[worker], rnd.Intn(blocks)*blockSize, buf, 0)
+				r.record(start, n, err)
+			}
+			if err := v.ec.Flush(inodes[worker]); err != nil {
+				r.errors++
+			}
+		}))
+	}
+	if selected[workloadRandRead] {
+		rp.add(run(workloadRandRead, workers, blockSize, func(worker int, r *recorder) {
+			buf := make([]byte, blockSize)
+			rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			for i := 0; i < blocks; i++ {
+				start := time.Now()
+				n, err := v.ec.Read(inodes[worker], buf, rnd.Intn(blocks)*blockSize, blockSize)
+				if err == io.EOF {
+					err = nil
+				}
+				r.record(start, n, err)
+			}
+		}))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+func newMetaCmd() *cobra.Command {
+	var files int
+	var c = &cobra.Command{
+		Use:   "meta",
+		Short: "benchmark creating, stating and deleting files",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := MetaBench(files); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	c.Flags().IntVarP(&files, "files", "n", 1000, "number of the files of each worker")
+	return c
+}
+
+// MetaBench runs the create, the stat and the delete workloads with each concurrency. Every worker
+// creates its files in its own directory, stats them by lookup and getattr, and deletes them.
+func MetaBench(files int) (err error) {
+	defer log.LogFlush()
+	if err = checkArgs(); err != nil {
+		return
+	}
+	if files <= 0 {
+		return fmt.Errorf("Invalid files: %v", files)
+	}
+	if err = initLog("meta"); err != nil {
+		return
+	}
+	v, err := openVolume()
+	if err != nil {
+		return
+	}
+	defer v.close()
+
+	rp := &reporter{}
+	runDir := path.Join(BenchPath, time.Now().Format("20060102150405"))
+	for _, workers := range Concurrency {
+		if err = metaBench(v, rp, path.Join(runDir, fmt.Sprintf("meta-c%d", workers)), workers, files); err != nil {
+			break
+		}
+	}
+	if !Keep {
+		if e := v.removeAll(runDir); e != nil {
+			log.LogWarnf("remove directory failed: path(%v) err(%v)", runDir, e)
+		}
+	}
+	if err != nil {
+		return
+	}
+	return rp.flush()
+}
+
+func metaBench(v *volume, rp *reporter, dir string, workers, files int) error {
+	dirs, err := v.workDirs(dir, workers)
+	if err != nil {
+		return err
+	}
+	fileName := func(i int) string {
+		return fmt.Sprintf("f%d", i)
+	}
+
+	rp.add(run("create", workers, 0, func(worker int, r *recorder) {
+		for i := 0; i < files; i++ {
+			start := time.Now()
+			_, err := v.mw.Create_ll(dirs[worker], fileName(i), proto.Mode(0644), 0, 0, nil)
+			r.record(start, 0, err)
+		}
+	}))
+	rp.add(run("stat", workers, 0, func(worker int, r *recorder) {
+		for i := 0; i < files; i++ {
+			start := time.Now()
+			ino, _, err := v.mw.Lookup_ll(dirs[worker], fileName(i))
+			if err == nil {
+				_, err = v.mw.InodeGet_ll(ino)
+			}
+			r.record(start, 0, err)
+		}
+	}))
+	if Keep {
+		return nil
+	}
+	rp.add(run("delete", workers, 0, func(worker int, r *recorder) {
+		for i := 0; i < files; i++ {
+			start := time.Now()
+			r.record(start, 0, v.remove(dirs[worker], fileName(i), false))
+		}
+	}))
+	return nil
+}
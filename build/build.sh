@@ -286,6 +286,39 @@ build_fsck() {
     popd >/dev/null
 }
 
+build_migrate() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-migrate   "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-migrate ${SrcPath}/migrate/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_bench() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-bench     "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-bench ${SrcPath}/bench/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_inspect() {
+    #inspect need gorocksdb too
+    pre_build_server
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-inspect   "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-inspect ${SrcPath}/inspect/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
+build_operator() {
+    pre_build
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-operator  "
+    go build $MODFLAGS -ldflags "${LDFlags}" -o ${BuildBinPath}/cfs-operator ${SrcPath}/operator/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
 build_libsdk() {
     pre_build_server
     case `uname` in
@@ -359,6 +392,18 @@ case "$cmd" in
     "fsck")
         build_fsck
         ;;
+    "migrate")
+        build_migrate
+        ;;
+    "bench")
+        build_bench
+        ;;
+    "inspect")
+        build_inspect
+        ;;
+    "operator")
+        build_operator
+        ;;
     "libsdk")
         build_libsdk
         ;;
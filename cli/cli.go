@@ -36,6 +36,17 @@ func runCLI() (err error) {
 		fmt.Printf("init cli log err[%v]", err)
 		return
 	}
+	// the unknown cluster of --cluster is reported by the command, which may also be a flag of the command itself
+	var cluster, masterAddr = cmd.ClusterFlags(os.Args[1:])
+	if selected, e := cfg.Select(cluster, masterAddr); e == nil {
+		cfg = selected
+	} else if cluster == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %v, the default cluster is used\n", e)
+	}
+	if err = cfg.EnableTLS(); err != nil {
+		fmt.Printf("init cli TLS err[%v]", err)
+		return
+	}
 	cfsCli := setupCommands(cfg)
 	if err = cfsCli.Execute(); err != nil {
 		log.LogErrorf("Command fail, err:%v", err)
@@ -76,6 +87,7 @@ following command to execute:
 	cfsRootCmd.CFSCmd.AddCommand(completionCmd)
 
 	cfsRootCmd.CFSCmd.AddCommand(cmd.GenClusterCfgCmd)
+	cfsRootCmd.CFSCmd.AddCommand(cmd.NewShellCmd(setupCommands))
 	return cfsRootCmd.CFSCmd
 }
 
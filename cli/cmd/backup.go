@@ -0,0 +1,701 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdBackupUse   = CliResourceBackup + " [COMMAND]"
+	cmdBackupShort = "Back up the cluster metadata to archives"
+	cmdBackupLong  = `Back up the metadata of the cluster to archives, and verify and restore the archives.
+
+An archive is a gzipped tar file holding the views of the master, which are the cluster, the zones,
+the topology, the users and the volumes with their partitions, and the inodes and the dentries exported
+by the leader of every meta partition. The manifest at the head of the archive records the size and
+the SHA-256 checksum of every entry.
+
+The servers cannot load the metadata back, so restore only extracts the archive after verifying it.
+The inodes and the dentries of every volume are also merged into inode.dump and dentry.dump, which
+are accepted by cfs-fsck through --inode-list and --dentry-list.`
+
+	backupArchivePrefix   = "cfs-backup-"
+	backupArchiveSuffix   = ".tar.gz"
+	backupManifestName    = "manifest.json"
+	backupInodeDumpName   = "inode.dump"
+	backupDentryDumpName  = "dentry.dump"
+	backupDefaultProfPort = "17220"
+)
+
+func newBackupCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdBackupUse,
+		Short: cmdBackupShort,
+		Long:  cmdBackupLong,
+		Args:  cobra.MinimumNArgs(0),
+	}
+	cmd.AddCommand(
+		newBackupCreateCmd(client),
+		newBackupListCmd(),
+		newBackupVerifyCmd(),
+		newBackupRestoreCmd(),
+	)
+	return cmd
+}
+
+// backupEntry is a file of the archive recorded by the manifest.
+type backupEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// backupManifest is the first entry of the archive, which describes the other entries.
+type backupManifest struct {
+	Cluster    string         `json:"cluster"`
+	CreateTime string         `json:"createTime"`
+	Version    string         `json:"version"`
+	Volumes    []string       `json:"volumes"`
+	Entries    []*backupEntry `json:"entries"`
+}
+
+// backupStage collects the entries of an archive in a temporary directory, so that the
+// manifest can be written ahead of them.
+type backupStage struct {
+	dir     string
+	entries []*backupEntry
+}
+
+// add writes the entry from the reader, and records its size and checksum.
+func (s *backupStage) add(name string, r io.Reader) (err error) {
+	var filePath = filepath.Join(s.dir, filepath.FromSlash(name))
+	if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return
+	}
+	var file *os.File
+	if file, err = os.Create(filePath); err != nil {
+		return
+	}
+	defer file.Close()
+	var hash = sha256.New()
+	var size int64
+	if size, err = io.Copy(io.MultiWriter(file, hash), r); err != nil {
+		return fmt.Errorf("write entry %v failed: %v", name, err)
+	}
+	s.entries = append(s.entries, &backupEntry{Name: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	return
+}
+
+func (s *backupStage) addJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.add(name, strings.NewReader(string(data)))
+}
+
+// addURL writes the entry from the body of the GET request to the url.
+func (s *backupStage) addURL(name, url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("export %v failed: status(%v)", url, resp.Status)
+	}
+	return s.add(name, resp.Body)
+}
+
+// writeArchive writes the manifest and the entries to the archive of the path.
+func (s *backupStage) writeArchive(archivePath string, manifest *backupManifest) (err error) {
+	manifest.Entries = s.entries
+	var data []byte
+	if data, err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		return
+	}
+	var tmpPath = archivePath + ".tmp"
+	var file *os.File
+	if file, err = os.Create(tmpPath); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	var gw = gzip.NewWriter(file)
+	var tw = tar.NewWriter(gw)
+	var modTime = time.Now()
+	if err = tw.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(data)), ModTime: modTime}); err != nil {
+		_ = file.Close()
+		return
+	}
+	if _, err = tw.Write(data); err != nil {
+		_ = file.Close()
+		return
+	}
+	for _, entry := range s.entries {
+		if err = s.copyEntry(tw, entry, modTime); err != nil {
+			_ = file.Close()
+			return
+		}
+	}
+	if err = tw.Close(); err != nil {
+		_ = file.Close()
+		return
+	}
+	if err = gw.Close(); err != nil {
+		_ = file.Close()
+		return
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return
+	}
+	if err = file.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmpPath, archivePath)
+}
+
+func (s *backupStage) copyEntry(tw *tar.Writer, entry *backupEntry, modTime time.Time) (err error) {
+	var file *os.File
+	if file, err = os.Open(filepath.Join(s.dir, filepath.FromSlash(entry.Name))); err != nil {
+		return
+	}
+	defer file.Close()
+	if err = tw.WriteHeader(&tar.Header{Name: entry.Name, Mode: 0644, Size: entry.Size, ModTime: modTime}); err != nil {
+		return
+	}
+	_, err = io.CopyN(tw, file, entry.Size)
+	return
+}
+
+// metaExportURL returns the url exporting the inodes or the dentries from the leader of the meta partition.
+func metaExportURL(mp *proto.MetaPartitionView, profPort, op string) string {
+	return fmt.Sprintf("http://%s:%s/%s?pid=%d", strings.Split(mp.LeaderAddr, ":")[0], profPort, op, mp.PartitionID)
+}
+
+func backupVolEntryName(volName, name string) string {
+	return path.Join("volumes", volName, name)
+}
+
+func backupMetaEntryName(volName string, pid uint64, name string) string {
+	return path.Join("meta", volName, fmt.Sprintf("%d", pid), name)
+}
+
+// createBackup exports the metadata of the volumes to the stage.
+func createBackup(client *master.MasterClient, stage *backupStage, volNames []string, profPort string) (err error) {
+	var zones []*proto.ZoneView
+	if zones, err = client.AdminAPI().ListZones(); err != nil {
+		return
+	}
+	if err = stage.addJSON("master/zones.json", zones); err != nil {
+		return
+	}
+	var topo *proto.TopologyView
+	if topo, err = client.AdminAPI().Topo(); err != nil {
+		return
+	}
+	if err = stage.addJSON("master/topology.json", topo); err != nil {
+		return
+	}
+	var users []*proto.UserInfo
+	if users, err = client.UserAPI().ListUsers(""); err != nil {
+		return
+	}
+	if err = stage.addJSON("master/users.json", users); err != nil {
+		return
+	}
+	for _, volName := range volNames {
+		var vv *proto.SimpleVolView
+		if vv, err = client.AdminAPI().GetVolumeSimpleInfo(volName); err != nil {
+			return fmt.Errorf("get volume %v failed: %v", volName, err)
+		}
+		if err = stage.addJSON(backupVolEntryName(volName, "volume.json"), vv); err != nil {
+			return
+		}
+		var dpv *proto.DataPartitionsView
+		if dpv, err = client.ClientAPI().GetDataPartitions(volName); err != nil {
+			return fmt.Errorf("get data partitions of volume %v failed: %v", volName, err)
+		}
+		if err = stage.addJSON(backupVolEntryName(volName, "data_partitions.json"), dpv); err != nil {
+			return
+		}
+		var mps []*proto.MetaPartitionView
+		if mps, err = client.ClientAPI().GetMetaPartitions(volName); err != nil {
+			return fmt.Errorf("get meta partitions of volume %v failed: %v", volName, err)
+		}
+		if err = stage.addJSON(backupVolEntryName(volName, "meta_partitions.json"), mps); err != nil {
+			return
+		}
+		sort.Slice(mps, func(i, j int) bool { return mps[i].PartitionID < mps[j].PartitionID })
+		for _, mp := range mps {
+			if mp.LeaderAddr == "" {
+				return fmt.Errorf("meta partition %v of volume %v has no leader", mp.PartitionID, volName)
+			}
+			if err = stage.addURL(backupMetaEntryName(volName, mp.PartitionID, backupInodeDumpName), metaExportURL(mp, profPort, "getAllInodes")); err != nil {
+				return
+			}
+			if err = stage.addURL(backupMetaEntryName(volName, mp.PartitionID, backupDentryDumpName), metaExportURL(mp, profPort, "getAllDentry")); err != nil {
+				return
+			}
+		}
+		stdout("Volume %v: %v meta partitions exported\n", volName, len(mps))
+	}
+	return
+}
+
+const (
+	cmdBackupCreateUse   = CliOpCreate
+	cmdBackupCreateShort = "Create a backup archive of the cluster metadata"
+)
+
+func newBackupCreateCmd(client *master.MasterClient) *cobra.Command {
+	var optDir, optProfPort string
+	var optVols []string
+	var cmd = &cobra.Command{
+		Use:   cmdBackupCreateUse,
+		Short: cmdBackupCreateShort,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var cv *proto.ClusterView
+			if cv, err = client.AdminAPI().GetCluster(); err != nil {
+				return
+			}
+			var volNames = optVols
+			if len(volNames) == 0 {
+				var vols []*proto.VolInfo
+				if vols, err = client.AdminAPI().ListVols(""); err != nil {
+					return
+				}
+				for _, vol := range vols {
+					volNames = append(volNames, vol.Name)
+				}
+			}
+			sort.Strings(volNames)
+			if err = os.MkdirAll(optDir, 0755); err != nil {
+				return
+			}
+			var stage = &backupStage{}
+			if stage.dir, err = ioutil.TempDir(optDir, "."+backupArchivePrefix); err != nil {
+				return
+			}
+			defer os.RemoveAll(stage.dir)
+
+			var now = time.Now()
+			if err = stage.addJSON("master/cluster.json", cv); err != nil {
+				return
+			}
+			if err = createBackup(client, stage, volNames, optProfPort); err != nil {
+				err = fmt.Errorf("Create backup failed:\n%v", err)
+				return
+			}
+			var manifest = &backupManifest{
+				Cluster:    cv.Name,
+				CreateTime: formatTimeToString(now),
+				Version:    proto.BuildVersion(),
+				Volumes:    volNames,
+			}
+			var archivePath = filepath.Join(optDir, fmt.Sprintf("%s%s-%s%s", backupArchivePrefix, cv.Name, now.Format("20060102150405"), backupArchiveSuffix))
+			if err = stage.writeArchive(archivePath, manifest); err != nil {
+				err = fmt.Errorf("Write archive failed:\n%v", err)
+				return
+			}
+			outputMsg("Backup created: %v\n", archivePath)
+		},
+	}
+	cmd.Flags().StringVar(&optDir, CliFlagBackupDir, ".", "Specify the directory of the archives")
+	cmd.Flags().StringVar(&optProfPort, CliFlagMetaProfPort, backupDefaultProfPort, "Specify the prof port of the meta nodes")
+	cmd.Flags().StringSliceVar(&optVols, CliFlagVolume, nil, "Specify the volumes to back up, all volumes by default")
+	return cmd
+}
+
+// openBackup opens the archive, and returns the manifest and the reader positioned after it.
+func openBackup(archivePath string) (file *os.File, tr *tar.Reader, manifest *backupManifest, err error) {
+	if file, err = os.Open(archivePath); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+		}
+	}()
+	var gr *gzip.Reader
+	if gr, err = gzip.NewReader(file); err != nil {
+		return
+	}
+	tr = tar.NewReader(gr)
+	var header *tar.Header
+	if header, err = tr.Next(); err != nil {
+		err = fmt.Errorf("read manifest failed: %v", err)
+		return
+	}
+	if header.Name != backupManifestName {
+		err = fmt.Errorf("the first entry is %v rather than %v", header.Name, backupManifestName)
+		return
+	}
+	manifest = &backupManifest{}
+	if err = json.NewDecoder(tr).Decode(manifest); err != nil {
+		err = fmt.Errorf("decode manifest failed: %v", err)
+	}
+	return
+}
+
+// validBackupEntryName reports whether the entry can be extracted inside the target directory.
+func validBackupEntryName(name string) bool {
+	return name != "" && !path.IsAbs(name) && path.Clean(name) == name && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// backupVerifyResult is the result of verifying an archive.
+type backupVerifyResult struct {
+	Archive  string   `json:"archive"`
+	Cluster  string   `json:"cluster"`
+	Entries  int      `json:"entries"`
+	Problems []string `json:"problems"`
+}
+
+// verifyBackup checks every entry of the archive against the manifest. If extract is not nil,
+// it is called with every entry, and the entry is read by it.
+func verifyBackup(archivePath string, extract func(entry *backupEntry, r io.Reader) error) (result *backupVerifyResult, err error) {
+	result = &backupVerifyResult{Archive: archivePath, Problems: make([]string, 0)}
+	var file *os.File
+	var tr *tar.Reader
+	var manifest *backupManifest
+	if file, tr, manifest, err = openBackup(archivePath); err != nil {
+		return
+	}
+	defer file.Close()
+	result.Cluster = manifest.Cluster
+	result.Entries = len(manifest.Entries)
+
+	var expected = make(map[string]*backupEntry, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		if !validBackupEntryName(entry.Name) {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %v: invalid name", entry.Name))
+			continue
+		}
+		expected[entry.Name] = entry
+	}
+	for {
+		var header *tar.Header
+		if header, err = tr.Next(); err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("archive truncated or corrupted: %v", err))
+			err = nil
+			break
+		}
+		entry, ok := expected[header.Name]
+		if !ok {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %v: not in the manifest", header.Name))
+			continue
+		}
+		delete(expected, header.Name)
+		var hash = sha256.New()
+		var r = &countingReader{r: io.TeeReader(tr, hash)}
+		if extract != nil {
+			if err = extract(entry, r); err != nil {
+				return
+			}
+		}
+		if _, err = io.Copy(ioutil.Discard, r); err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %v: read failed: %v", header.Name, err))
+			err = nil
+			break
+		}
+		if r.n != entry.Size {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %v: size %v, expect %v", entry.Name, r.n, entry.Size))
+		} else if sum := hex.EncodeToString(hash.Sum(nil)); sum != entry.SHA256 {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %v: checksum %v, expect %v", entry.Name, sum, entry.SHA256))
+		}
+	}
+	var missing = make([]string, 0, len(expected))
+	for name := range expected {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		result.Problems = append(result.Problems, fmt.Sprintf("entry %v: missing", name))
+	}
+	return
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += int64(n)
+	return
+}
+
+func formatBackupVerifyResult(result *backupVerifyResult) string {
+	var sb = strings.Builder{}
+	sb.WriteString(fmt.Sprintf("  Archive   : %v\n", result.Archive))
+	sb.WriteString(fmt.Sprintf("  Cluster   : %v\n", result.Cluster))
+	sb.WriteString(fmt.Sprintf("  Entries   : %v\n", result.Entries))
+	if len(result.Problems) == 0 {
+		sb.WriteString("  Result    : OK\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("  Result    : %v problems\n", len(result.Problems)))
+	for _, problem := range result.Problems {
+		sb.WriteString(fmt.Sprintf("    %v\n", problem))
+	}
+	return sb.String()
+}
+
+// backupArchiveInfo is a row of the archive list.
+type backupArchiveInfo struct {
+	Archive    string   `json:"archive"`
+	Cluster    string   `json:"cluster"`
+	CreateTime string   `json:"createTime"`
+	Volumes    []string `json:"volumes"`
+	Entries    int      `json:"entries"`
+	Size       uint64   `json:"size"`
+	Error      string   `json:"error,omitempty"`
+}
+
+var (
+	backupTablePattern = "%-50v    %-16v    %-20v    %-8v    %-8v    %-10v"
+	backupTableHeader  = fmt.Sprintf(backupTablePattern, "ARCHIVE", "CLUSTER", "CREATE TIME", "VOLUMES", "ENTRIES", "SIZE")
+)
+
+func formatBackupTableRow(info *backupArchiveInfo) string {
+	if info.Error != "" {
+		return fmt.Sprintf(backupTablePattern, info.Archive, "N/A", info.Error, "N/A", "N/A", formatSize(info.Size))
+	}
+	return fmt.Sprintf(backupTablePattern, info.Archive, info.Cluster, info.CreateTime, len(info.Volumes), info.Entries, formatSize(info.Size))
+}
+
+const (
+	cmdBackupListUse   = CliOpList
+	cmdBackupListShort = "List the backup archives in the directory"
+)
+
+func newBackupListCmd() *cobra.Command {
+	var optDir string
+	var cmd = &cobra.Command{
+		Use:   cmdBackupListUse,
+		Short: cmdBackupListShort,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var infos []os.FileInfo
+			if infos, err = ioutil.ReadDir(optDir); err != nil {
+				return
+			}
+			var archives = make([]*backupArchiveInfo, 0)
+			for _, fi := range infos {
+				if fi.IsDir() || !strings.HasPrefix(fi.Name(), backupArchivePrefix) || !strings.HasSuffix(fi.Name(), backupArchiveSuffix) {
+					continue
+				}
+				var info = &backupArchiveInfo{Archive: fi.Name(), Size: uint64(fi.Size())}
+				file, _, manifest, err := openBackup(filepath.Join(optDir, fi.Name()))
+				if err != nil {
+					info.Error = "invalid archive"
+				} else {
+					_ = file.Close()
+					info.Cluster, info.CreateTime, info.Volumes, info.Entries = manifest.Cluster, manifest.CreateTime, manifest.Volumes, len(manifest.Entries)
+				}
+				archives = append(archives, info)
+			}
+			output(archives, func() {
+				stdout("%v\n", backupTableHeader)
+				for _, info := range archives {
+					stdout("%v\n", formatBackupTableRow(info))
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVar(&optDir, CliFlagBackupDir, ".", "Specify the directory of the archives")
+	return cmd
+}
+
+const (
+	cmdBackupVerifyUse   = CliOpVerify + " [ARCHIVE]"
+	cmdBackupVerifyShort = "Verify the entries of the archive against its manifest"
+)
+
+func newBackupVerifyCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdBackupVerifyUse,
+		Short: cmdBackupVerifyShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			result, err := verifyBackup(args[0], nil)
+			if err != nil {
+				errout("Error: verify archive %v failed:\n%v\n", args[0], err)
+			}
+			output(result, func() {
+				stdout("%s", formatBackupVerifyResult(result))
+			})
+			if len(result.Problems) > 0 {
+				errout("Error: archive %v is corrupted\n", args[0])
+			}
+		},
+	}
+	return cmd
+}
+
+const (
+	cmdBackupRestoreUse   = CliOpRestore + " [ARCHIVE]"
+	cmdBackupRestoreShort = "Verify and extract the archive to a directory"
+)
+
+func newBackupRestoreCmd() *cobra.Command {
+	var optTarget string
+	var cmd = &cobra.Command{
+		Use:   cmdBackupRestoreUse,
+		Short: cmdBackupRestoreShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var archivePath = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			if optTarget == "" {
+				err = fmt.Errorf("the target directory is not specified by --%v", CliFlagRestoreTarget)
+				return
+			}
+			var result *backupVerifyResult
+			if result, err = verifyBackup(archivePath, nil); err != nil {
+				return
+			}
+			if len(result.Problems) > 0 {
+				stdout("%s", formatBackupVerifyResult(result))
+				err = fmt.Errorf("archive %v is corrupted, nothing restored", archivePath)
+				return
+			}
+			if err = restoreBackup(archivePath, optTarget); err != nil {
+				err = fmt.Errorf("Restore backup failed:\n%v", err)
+				return
+			}
+			outputMsg("Backup %v restored to %v\n", archivePath, optTarget)
+		},
+	}
+	cmd.Flags().StringVar(&optTarget, CliFlagRestoreTarget, "", "Specify the directory to restore to, which must not exist or be empty")
+	return cmd
+}
+
+// restoreBackup extracts the verified archive to the target, and merges the inodes and the dentries
+// of every volume in the order of the meta partitions.
+func restoreBackup(archivePath, target string) (err error) {
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(target); err == nil && len(infos) > 0 {
+		return fmt.Errorf("target directory %v is not empty", target)
+	}
+	if err = os.MkdirAll(target, 0755); err != nil {
+		return
+	}
+	var dumps = make(map[string]*os.File)
+	defer func() {
+		for _, file := range dumps {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}()
+	// the entries are written in the order of the meta partitions by create
+	var merge = func(entry *backupEntry, filePath string) (err error) {
+		var parts = strings.Split(entry.Name, "/")
+		if len(parts) != 4 || parts[0] != "meta" {
+			return
+		}
+		var dumpPath = filepath.Join(target, "volumes", parts[1], parts[3])
+		var dump = dumps[dumpPath]
+		if dump == nil {
+			if err = os.MkdirAll(filepath.Dir(dumpPath), 0755); err != nil {
+				return
+			}
+			if dump, err = os.Create(dumpPath); err != nil {
+				return
+			}
+			dumps[dumpPath] = dump
+		}
+		var file *os.File
+		if file, err = os.Open(filePath); err != nil {
+			return
+		}
+		defer file.Close()
+		if _, err = io.Copy(dump, file); err != nil {
+			return
+		}
+		_, err = dump.Write([]byte("\n"))
+		return
+	}
+	var result *backupVerifyResult
+	result, err = verifyBackup(archivePath, func(entry *backupEntry, r io.Reader) (err error) {
+		var filePath = filepath.Join(target, filepath.FromSlash(entry.Name))
+		if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return
+		}
+		var file *os.File
+		if file, err = os.Create(filePath); err != nil {
+			return
+		}
+		if _, err = io.Copy(file, r); err != nil {
+			_ = file.Close()
+			return
+		}
+		if err = file.Close(); err != nil {
+			return
+		}
+		return merge(entry, filePath)
+	})
+	if err == nil && len(result.Problems) > 0 {
+		err = fmt.Errorf("archive changed while restoring: %v", strings.Join(result.Problems, "; "))
+	}
+	return
+}
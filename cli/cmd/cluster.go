@@ -39,6 +39,7 @@ func (cmd *ChubaoFSCmd) newClusterCmd(client *master.MasterClient) *cobra.Comman
 		newClusterFreezeCmd(client),
 		newClusterSetThresholdCmd(client),
 		newClusterDeleteParasCmd(client),
+		newClusterDoctorCmd(client),
 	)
 	return clusterCmd
 }
@@ -53,6 +54,8 @@ const (
 	nodeMarkDeleteRateKey    = "markDeleteRate"
 	nodeDeleteWorkerSleepMs  = "deleteWorkerSleepMs"
 	nodeAutoRepairRateKey    = "autoRepairRate"
+	nodeDiskClientIORateKey  = "diskClientIORate"
+	nodeDiskRepairIORateKey  = "diskRepairIORate"
 )
 
 func newClusterInfoCmd(client *master.MasterClient) *cobra.Command {
@@ -66,44 +69,56 @@ func newClusterInfoCmd(client *master.MasterClient) *cobra.Command {
 			if cv, err = client.AdminAPI().GetCluster(); err != nil {
 				errout("Error: %v", err)
 			}
-			stdout("[Cluster]\n")
-			stdout(formatClusterView(cv))
 			if delPara, err = client.AdminAPI().GetDeleteParas(); err != nil {
 				errout("Error: %v", err)
 			}
-			stdout(fmt.Sprintf("  BatchCount         : %v\n", delPara[nodeDeleteBatchCountKey]))
-			stdout(fmt.Sprintf("  MarkDeleteRate     : %v\n", delPara[nodeMarkDeleteRateKey]))
-			stdout(fmt.Sprintf("  DeleteWorkerSleepMs: %v\n", delPara[nodeDeleteWorkerSleepMs]))
-			stdout(fmt.Sprintf("  AutoRepairRate     : %v\n", delPara[nodeAutoRepairRateKey]))
-			stdout("\n")
+			var data = struct {
+				Cluster     *proto.ClusterView `json:"cluster"`
+				DeleteParas map[string]string  `json:"deleteParas"`
+			}{Cluster: cv, DeleteParas: delPara}
+			output(&data, func() {
+				stdout("[Cluster]\n")
+				stdout(formatClusterView(cv))
+				stdout(fmt.Sprintf("  BatchCount         : %v\n", delPara[nodeDeleteBatchCountKey]))
+				stdout(fmt.Sprintf("  MarkDeleteRate     : %v\n", delPara[nodeMarkDeleteRateKey]))
+				stdout(fmt.Sprintf("  DeleteWorkerSleepMs: %v\n", delPara[nodeDeleteWorkerSleepMs]))
+				stdout(fmt.Sprintf("  AutoRepairRate     : %v\n", delPara[nodeAutoRepairRateKey]))
+				stdout("  DiskClientIORate   : %v\n", delPara[nodeDiskClientIORateKey])
+				stdout("  DiskRepairIORate   : %v\n", delPara[nodeDiskRepairIORateKey])
+				stdout("\n")
+			})
 		},
 	}
 	return cmd
 }
 
 func newClusterStatCmd(client *master.MasterClient) *cobra.Command {
+	var optWatch watchOption
 	var cmd = &cobra.Command{
 		Use:   CliOpStatus,
 		Short: cmdClusterStatShort,
 		Run: func(cmd *cobra.Command, args []string) {
-			var (
-				err error
-				cs  *proto.ClusterStatInfo
-			)
+			var err error
 			defer func() {
 				if err != nil {
 					errout("Error: %v", err)
 				}
 			}()
-			if cs, err = client.AdminAPI().GetClusterStat(); err != nil {
-				err = fmt.Errorf("Get cluster info fail:\n%v\n", err)
+			err = optWatch.run(cmd, func() (err error) {
+				var cs *proto.ClusterStatInfo
+				if cs, err = client.AdminAPI().GetClusterStat(); err != nil {
+					return fmt.Errorf("Get cluster info fail:\n%v\n", err)
+				}
+				output(cs, func() {
+					stdout("[Cluster Status]\n")
+					stdout(formatClusterStat(cs))
+					stdout("\n")
+				})
 				return
-			}
-			stdout("[Cluster Status]\n")
-			stdout(formatClusterStat(cs))
-			stdout("\n")
+			})
 		},
 	}
+	optWatch.addFlags(cmd)
 	return cmd
 }
 
@@ -137,9 +152,9 @@ If 'freeze=true', ChubaoFS WILL NOT automatically allocate new data partitions `
 				return
 			}
 			if enable {
-				stdout("Freeze cluster successful!\n")
+				outputMsg("Freeze cluster successful!\n")
 			} else {
-				stdout("Unfreeze cluster successful!\n")
+				outputMsg("Unfreeze cluster successful!\n")
 			}
 		},
 	}
@@ -174,7 +189,7 @@ If the memory usage reaches this threshold, all the mata partition will be readO
 			if err = client.AdminAPI().SetMetaNodeThreshold(threshold); err != nil {
 				return
 			}
-			stdout("MetaNode threshold is set to %v!\n", threshold)
+			outputMsg("MetaNode threshold is set to %v!\n", threshold)
 		},
 	}
 	return cmd
@@ -198,7 +213,7 @@ func newClusterDeleteParasCmd(client *master.MasterClient) *cobra.Command {
 			if err = client.AdminAPI().SetDeleteParas(optDelBatchCount, optMarkDeleteRate, optDelWorkerSleepMs, optAutoRepairRate); err != nil {
 				return
 			}
-			stdout("Delete parameters has been set successfully. \n")
+			outputMsg("Delete parameters has been set successfully. \n")
 		},
 	}
 	cmd.Flags().StringVar(&optAutoRepairRate, CliFlagAutoRepairRate, "", "DataNode auto repair rate")
@@ -0,0 +1,451 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdClusterDoctorShort = "Diagnose the cluster and suggest the remediation"
+
+	defaultDoctorUsageSkew   = 0.3
+	defaultDoctorHighUsage   = 0.9
+	defaultDoctorClockSkew   = 3 * time.Second
+	defaultDoctorConcurrency = 16
+	doctorMasterTimeout      = 3
+)
+
+// severities of the findings, in the descending priority
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+var severityPriority = map[string]int{
+	SeverityCritical: 0,
+	SeverityWarning:  1,
+	SeverityInfo:     2,
+}
+
+// doctorFinding is a problem found by a check of the doctor, with the suggested remediation.
+type doctorFinding struct {
+	Severity    string   `json:"severity"`
+	Check       string   `json:"check"`
+	Summary     string   `json:"summary"`
+	Details     []string `json:"details,omitempty"`
+	Remediation []string `json:"remediation,omitempty"`
+}
+
+// doctorReport is the result of the checks, the findings are sorted by the severity.
+type doctorReport struct {
+	Cluster  string           `json:"cluster"`
+	Checks   []string         `json:"checks"`
+	Findings []*doctorFinding `json:"findings"`
+}
+
+// clusterDoctor runs the checks against the cluster and collects the findings.
+type clusterDoctor struct {
+	client      *master.MasterClient
+	usageSkew   float64
+	highUsage   float64
+	clockSkew   time.Duration
+	concurrency int
+
+	view      *proto.ClusterView
+	dataNodes []*proto.DataNodeInfo
+	metaNodes []*proto.MetaNodeInfo
+	findings  []*doctorFinding
+}
+
+func newClusterDoctorCmd(client *master.MasterClient) *cobra.Command {
+	var doctor = &clusterDoctor{client: client}
+	var cmd = &cobra.Command{
+		Use:   CliOpDoctor,
+		Short: cmdClusterDoctorShort,
+		Long: `Run the checks of the master quorum, the partition replica health, the disk usage skew,
+the version skew, the clock skew and the dangling decommissions, and print the findings
+by their severity, each with the suggested remediation APIs and commands.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var report, err = doctor.run()
+			if err != nil {
+				errout("Error: %v\n", err)
+			}
+			output(report, func() {
+				stdout("%s", formatDoctorReport(report))
+			})
+		},
+	}
+	cmd.Flags().Float64Var(&doctor.usageSkew, CliFlagUsageSkew, defaultDoctorUsageSkew,
+		"Max difference of the usage ratio between the data nodes")
+	cmd.Flags().Float64Var(&doctor.highUsage, CliFlagHighUsage, defaultDoctorHighUsage,
+		"Usage ratio above which a data node is reported")
+	cmd.Flags().DurationVar(&doctor.clockSkew, CliFlagClockSkew, defaultDoctorClockSkew,
+		"Max clock skew of the nodes against the master")
+	cmd.Flags().IntVar(&doctor.concurrency, CliFlagConcurrency, defaultDoctorConcurrency,
+		"Number of the nodes queried concurrently")
+	return cmd
+}
+
+func (d *clusterDoctor) run() (report *doctorReport, err error) {
+	if d.concurrency <= 0 {
+		d.concurrency = 1
+	}
+	d.findings = nil
+	if d.view, err = d.client.AdminAPI().GetCluster(); err != nil {
+		return nil, fmt.Errorf("get cluster view fail: %v", err)
+	}
+	d.loadNodes()
+	var checks = []struct {
+		name string
+		run  func()
+	}{
+		{"master quorum", d.checkMasterQuorum},
+		{"replica health", d.checkReplicaHealth},
+		{"disk usage", d.checkDiskUsage},
+		{"version skew", d.checkVersionSkew},
+		{"clock skew", d.checkClockSkew},
+		{"dangling decommission", d.checkDanglingDecommission},
+	}
+	report = &doctorReport{Cluster: d.view.Name, Findings: make([]*doctorFinding, 0)}
+	for _, check := range checks {
+		check.run()
+		report.Checks = append(report.Checks, check.name)
+	}
+	sort.SliceStable(d.findings, func(i, j int) bool {
+		return severityPriority[d.findings[i].Severity] < severityPriority[d.findings[j].Severity]
+	})
+	report.Findings = append(report.Findings, d.findings...)
+	return report, nil
+}
+
+func (d *clusterDoctor) report(severity, check, summary string, details []string, remediation ...string) {
+	d.findings = append(d.findings, &doctorFinding{
+		Severity:    severity,
+		Check:       check,
+		Summary:     summary,
+		Details:     details,
+		Remediation: remediation,
+	})
+}
+
+// loadNodes loads the details of the active nodes with the bounded concurrency,
+// the nodes failed to load are reported.
+func (d *clusterDoctor) loadNodes() {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []string
+		limit    = make(chan struct{}, d.concurrency)
+	)
+	var load = func(addr string, get func(addr string) error) {
+		defer wg.Done()
+		limit <- struct{}{}
+		defer func() { <-limit }()
+		if err := get(addr); err != nil {
+			mu.Lock()
+			failures = append(failures, fmt.Sprintf("%v: %v", addr, err))
+			mu.Unlock()
+		}
+	}
+	for _, node := range d.view.DataNodes {
+		if !node.Status {
+			continue
+		}
+		wg.Add(1)
+		go load(node.Addr, func(addr string) error {
+			info, err := d.client.NodeAPI().GetDataNode(addr)
+			if err == nil {
+				mu.Lock()
+				d.dataNodes = append(d.dataNodes, info)
+				mu.Unlock()
+			}
+			return err
+		})
+	}
+	for _, node := range d.view.MetaNodes {
+		if !node.Status {
+			continue
+		}
+		wg.Add(1)
+		go load(node.Addr, func(addr string) error {
+			info, err := d.client.NodeAPI().GetMetaNode(addr)
+			if err == nil {
+				mu.Lock()
+				d.metaNodes = append(d.metaNodes, info)
+				mu.Unlock()
+			}
+			return err
+		})
+	}
+	wg.Wait()
+	sort.Slice(d.dataNodes, func(i, j int) bool { return d.dataNodes[i].Addr < d.dataNodes[j].Addr })
+	sort.Slice(d.metaNodes, func(i, j int) bool { return d.metaNodes[i].Addr < d.metaNodes[j].Addr })
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		d.report(SeverityWarning, "node info", fmt.Sprintf("%v active nodes cannot be queried, the checks below skip them", len(failures)),
+			failures, fmt.Sprintf("GET %v?addr=<ADDR> or %v?addr=<ADDR>", proto.GetDataNode, proto.GetMetaNode))
+	}
+}
+
+// checkMasterQuorum asks every master for the leader it follows, which is answered without proxying to the leader.
+func (d *clusterDoctor) checkMasterQuorum() {
+	const check = "master quorum"
+	var (
+		masters     = d.client.Nodes()
+		reachable   int
+		unreachable []string
+		leaders     = make(map[string][]string)
+	)
+	for _, addr := range masters {
+		var mc = master.NewMasterClient([]string{addr}, false)
+		mc.SetTimeout(doctorMasterTimeout)
+		info, err := mc.AdminAPI().GetClusterInfo()
+		if err != nil {
+			unreachable = append(unreachable, fmt.Sprintf("%v: %v", addr, err))
+			continue
+		}
+		reachable++
+		leaders[info.LeaderAddr] = append(leaders[info.LeaderAddr], addr)
+	}
+	var quorum = len(masters)/2 + 1
+	if reachable < quorum {
+		d.report(SeverityCritical, check,
+			fmt.Sprintf("only %v of %v masters are reachable, the quorum of %v is lost", reachable, len(masters), quorum),
+			unreachable, "restart the unreachable masters and check the network between them",
+			fmt.Sprintf("GET %v on each master", proto.AdminGetIP))
+	} else if len(unreachable) > 0 {
+		d.report(SeverityWarning, check,
+			fmt.Sprintf("%v of %v masters are unreachable, the quorum holds", len(unreachable), len(masters)),
+			unreachable, "restart the unreachable masters before another one fails")
+	}
+	if len(leaders) > 1 || leaders[""] != nil {
+		var details []string
+		for leader, addrs := range leaders {
+			if leader == "" {
+				leader = "<none>"
+			}
+			details = append(details, fmt.Sprintf("%v follow leader %v", strings.Join(addrs, ","), leader))
+		}
+		sort.Strings(details)
+		d.report(SeverityCritical, check, "masters disagree on the leader", details,
+			"check the raft peers and the logs of the masters",
+			fmt.Sprintf("GET %v on each master", proto.AdminGetIP))
+	}
+}
+
+func (d *clusterDoctor) checkReplicaHealth() {
+	const check = "replica health"
+	if dd, err := d.client.AdminAPI().DiagnoseDataPartition(); err != nil {
+		d.report(SeverityWarning, check, fmt.Sprintf("diagnose data partitions fail: %v", err), nil,
+			fmt.Sprintf("GET %v", proto.AdminDiagnoseDataPartition))
+	} else {
+		if len(dd.CorruptDataPartitionIDs) > 0 {
+			d.report(SeverityCritical, check,
+				fmt.Sprintf("%v data partitions have lost the majority of their replicas", len(dd.CorruptDataPartitionIDs)),
+				[]string{"partitions: " + formatIDList(dd.CorruptDataPartitionIDs)},
+				fmt.Sprintf("cfs-cli datapartition info [DATA PARTITION ID] (GET %v)", proto.AdminGetDataPartition),
+				fmt.Sprintf("cfs-cli datapartition add-replica [ADDRESS] [DATA PARTITION ID] (GET %v)", proto.AdminAddDataReplica))
+		}
+		if len(dd.LackReplicaDataPartitionIDs) > 0 {
+			d.report(SeverityWarning, check,
+				fmt.Sprintf("%v data partitions lack replicas", len(dd.LackReplicaDataPartitionIDs)),
+				[]string{"partitions: " + formatIDList(dd.LackReplicaDataPartitionIDs)},
+				fmt.Sprintf("cfs-cli datapartition add-replica [ADDRESS] [DATA PARTITION ID] (GET %v)", proto.AdminAddDataReplica))
+		}
+		if len(dd.InactiveDataNodes) > 0 {
+			d.report(SeverityWarning, check,
+				fmt.Sprintf("%v data nodes are inactive", len(dd.InactiveDataNodes)), dd.InactiveDataNodes,
+				"restart the data nodes, or decommission them if they cannot be recovered",
+				fmt.Sprintf("cfs-cli datanode decommission [NODE ADDRESS] (GET %v)", proto.DecommissionDataNode))
+		}
+	}
+	if md, err := d.client.AdminAPI().DiagnoseMetaPartition(); err != nil {
+		d.report(SeverityWarning, check, fmt.Sprintf("diagnose meta partitions fail: %v", err), nil,
+			fmt.Sprintf("GET %v", proto.AdminDiagnoseMetaPartition))
+	} else {
+		if len(md.CorruptMetaPartitionIDs) > 0 {
+			d.report(SeverityCritical, check,
+				fmt.Sprintf("%v meta partitions have lost the majority of their replicas", len(md.CorruptMetaPartitionIDs)),
+				[]string{"partitions: " + formatIDList(md.CorruptMetaPartitionIDs)},
+				fmt.Sprintf("cfs-cli metapartition info [META PARTITION ID] (GET %v)", proto.ClientMetaPartition),
+				fmt.Sprintf("cfs-cli metapartition add-replica [ADDRESS] [META PARTITION ID] (GET %v)", proto.AdminAddMetaReplica))
+		}
+		if len(md.LackReplicaMetaPartitionIDs) > 0 {
+			d.report(SeverityWarning, check,
+				fmt.Sprintf("%v meta partitions lack replicas", len(md.LackReplicaMetaPartitionIDs)),
+				[]string{"partitions: " + formatIDList(md.LackReplicaMetaPartitionIDs)},
+				fmt.Sprintf("cfs-cli metapartition add-replica [ADDRESS] [META PARTITION ID] (GET %v)", proto.AdminAddMetaReplica))
+		}
+		if len(md.InactiveMetaNodes) > 0 {
+			d.report(SeverityWarning, check,
+				fmt.Sprintf("%v meta nodes are inactive", len(md.InactiveMetaNodes)), md.InactiveMetaNodes,
+				"restart the meta nodes, or decommission them if they cannot be recovered",
+				fmt.Sprintf("cfs-cli metanode decommission [NODE ADDRESS] (GET %v)", proto.DecommissionMetaNode))
+		}
+	}
+}
+
+// checkDiskUsage reports the data nodes nearly full, and the skew of the usage between the data nodes.
+func (d *clusterDoctor) checkDiskUsage() {
+	const check = "disk usage"
+	if len(d.dataNodes) == 0 {
+		return
+	}
+	var (
+		full     []string
+		min, max = d.dataNodes[0], d.dataNodes[0]
+	)
+	for _, dn := range d.dataNodes {
+		if dn.UsageRatio >= d.highUsage {
+			full = append(full, fmt.Sprintf("%v: %.2f%%", dn.Addr, dn.UsageRatio*100))
+		}
+		if dn.UsageRatio < min.UsageRatio {
+			min = dn
+		}
+		if dn.UsageRatio > max.UsageRatio {
+			max = dn
+		}
+	}
+	if len(full) > 0 {
+		d.report(SeverityWarning, check,
+			fmt.Sprintf("%v data nodes are above %.0f%% usage", len(full), d.highUsage*100), full,
+			"add data nodes, or expand the disks of the full nodes",
+			fmt.Sprintf("cfs-cli datapartition decommission [ADDRESS] [DATA PARTITION ID] (GET %v) to move partitions away", proto.AdminDecommissionDataPartition))
+	}
+	if skew := max.UsageRatio - min.UsageRatio; skew > d.usageSkew {
+		d.report(SeverityInfo, check,
+			fmt.Sprintf("usage of the data nodes is skewed by %.2f%%", skew*100),
+			[]string{
+				fmt.Sprintf("highest %v: %.2f%%", max.Addr, max.UsageRatio*100),
+				fmt.Sprintf("lowest %v: %.2f%%", min.Addr, min.UsageRatio*100),
+			},
+			fmt.Sprintf("cfs-cli datapartition decommission [ADDRESS] [DATA PARTITION ID] (GET %v) on %v", proto.AdminDecommissionDataPartition, max.Addr))
+	}
+}
+
+// checkVersionSkew reports the nodes running the different versions, grouped by the version, which is critical
+// if the versions are incompatible.
+func (d *clusterDoctor) checkVersionSkew() {
+	var versions = make(map[string][]string)
+	for _, dn := range d.dataNodes {
+		versions[dn.Version] = append(versions[dn.Version], dn.Addr)
+	}
+	for _, mn := range d.metaNodes {
+		versions[mn.Version] = append(versions[mn.Version], mn.Addr)
+	}
+	if len(versions) <= 1 {
+		return
+	}
+	var details = make([]string, 0, len(versions))
+	var severity = SeverityWarning
+	for version, addrs := range versions {
+		for peer := range versions {
+			if compatible, reason, err := proto.CheckVersionCompatible(version, peer); err == nil && !compatible && version < peer {
+				severity = SeverityCritical
+				details = append(details, fmt.Sprintf("%v and %v are incompatible: %v", version, peer, reason))
+			}
+		}
+		if version == "" {
+			version = "<unknown>"
+		}
+		details = append(details, fmt.Sprintf("%v: %v nodes (%v)", version, len(addrs), strings.Join(addrs, ",")))
+	}
+	sort.Strings(details)
+	d.report(severity, "version skew", fmt.Sprintf("nodes run %v different versions", len(versions)), details,
+		"finish the rolling upgrade of the nodes", "cfs-cli version check lists the incompatible versions")
+}
+
+func (d *clusterDoctor) checkClockSkew() {
+	var details []string
+	var skewed = func(addr string, skew time.Duration) {
+		if skew > d.clockSkew || -skew > d.clockSkew {
+			details = append(details, fmt.Sprintf("%v: %v", addr, skew))
+		}
+	}
+	for _, dn := range d.dataNodes {
+		skewed(dn.Addr, dn.ClockSkew)
+	}
+	for _, mn := range d.metaNodes {
+		skewed(mn.Addr, mn.ClockSkew)
+	}
+	if len(details) == 0 {
+		return
+	}
+	d.report(SeverityWarning, "clock skew",
+		fmt.Sprintf("clocks of %v nodes are skewed by more than %v against the master", len(details), d.clockSkew), details,
+		"synchronize the clocks of the nodes by NTP")
+}
+
+// checkDanglingDecommission reports the partitions whose decommission has not been completed.
+func (d *clusterDoctor) checkDanglingDecommission() {
+	const check = "dangling decommission"
+	var format = func(views []proto.BadPartitionView) (count int, details []string) {
+		for _, view := range views {
+			count += len(view.PartitionIDs)
+			details = append(details, fmt.Sprintf("%v: %v", view.Path, formatIDList(view.PartitionIDs)))
+		}
+		return
+	}
+	if count, details := format(d.view.BadPartitionIDs); count > 0 {
+		d.report(SeverityWarning, check, fmt.Sprintf("%v data partitions are still being decommissioned", count), details,
+			fmt.Sprintf("cfs-cli datapartition decommission [ADDRESS] [DATA PARTITION ID] (GET %v) to retry", proto.AdminDecommissionDataPartition))
+	}
+	if count, details := format(d.view.BadMetaPartitionIDs); count > 0 {
+		d.report(SeverityWarning, check, fmt.Sprintf("%v meta partitions are still being decommissioned", count), details,
+			fmt.Sprintf("cfs-cli metapartition decommission [ADDRESS] [META PARTITION ID] (GET %v) to retry", proto.AdminDecommissionMetaPartition))
+	}
+}
+
+func formatIDList(ids []uint64) string {
+	var sb = strings.Builder{}
+	for i, id := range ids {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("%v", id))
+	}
+	return sb.String()
+}
+
+func formatDoctorReport(report *doctorReport) string {
+	var sb = strings.Builder{}
+	sb.WriteString(fmt.Sprintf("[Cluster Doctor] %v\n", report.Cluster))
+	sb.WriteString(fmt.Sprintf("  Checks   : %v\n", strings.Join(report.Checks, ", ")))
+	if len(report.Findings) == 0 {
+		sb.WriteString("  Findings : none, the cluster looks healthy\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("  Findings : %v\n", len(report.Findings)))
+	for i, finding := range report.Findings {
+		sb.WriteString(fmt.Sprintf("\n%v. [%v] %v: %v\n", i+1, strings.ToUpper(finding.Severity), finding.Check, finding.Summary))
+		for _, detail := range finding.Details {
+			sb.WriteString(fmt.Sprintf("     - %v\n", detail))
+		}
+		for _, remediation := range finding.Remediation {
+			sb.WriteString(fmt.Sprintf("     > %v\n", remediation))
+		}
+	}
+	return sb.String()
+}
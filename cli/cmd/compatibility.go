@@ -83,20 +83,32 @@ func newMetaCompatibilityCmd() *cobra.Command {
 			if err != nil {
 				return
 			}
-			stdout("[Meta partition is %v, verify result]\n", id)
-			if err = verifyDentry(client, mp); err != nil {
+			var result = &metaCompatibilityOutput{PartitionID: id}
+			if result.Dentries, err = verifyDentry(client, mp); err != nil {
 				return
 			}
-			if err = verifyInode(client, mp); err != nil {
+			if result.Inodes, err = verifyInode(client, mp); err != nil {
 				return
 			}
-			stdout("All meta has checked\n")
+			output(result, func() {
+				stdout("[Meta partition is %v, verify result]\n", id)
+				stdout("The number of dentry is %v, all dentry are consistent \n", result.Dentries)
+				stdout("The number of inodes is %v, all inodes are consistent \n", result.Inodes)
+				stdout("All meta has checked\n")
+			})
 		},
 	}
 	return cmd
 }
 
-func verifyDentry(client *api.MetaHttpClient, mp metanode.MetaPartition) (err error) {
+// metaCompatibilityOutput is the result of the verification of a meta partition, whose dentries and inodes are consistent.
+type metaCompatibilityOutput struct {
+	PartitionID uint64 `json:"partitionID"`
+	Dentries    int    `json:"dentries"`
+	Inodes      int    `json:"inodes"`
+}
+
+func verifyDentry(client *api.MetaHttpClient, mp metanode.MetaPartition) (count int, err error) {
 	dentryMap, err := client.GetAllDentry(mp.GetBaseConfig().PartitionId)
 	if err != nil {
 		return
@@ -122,13 +134,11 @@ func verifyDentry(client *api.MetaHttpClient, mp metanode.MetaPartition) (err er
 		}
 		return true
 	})
-	if err == nil {
-		stdout("The number of dentry is %v, all dentry are consistent \n", mp.GetDentryTree().Len())
-	}
+	count = mp.GetDentryTree().Len()
 	return
 }
 
-func verifyInode(client *api.MetaHttpClient, mp metanode.MetaPartition) (err error) {
+func verifyInode(client *api.MetaHttpClient, mp metanode.MetaPartition) (count int, err error) {
 	inodesMap, err := client.GetAllInodes(mp.GetBaseConfig().PartitionId)
 	if err != nil {
 		return
@@ -174,8 +184,6 @@ func verifyInode(client *api.MetaHttpClient, mp metanode.MetaPartition) (err err
 		}
 		return true
 	})
-	if err == nil {
-		stdout("The number of inodes is %v, all inodes are consistent \n", mp.GetInodeTree().Len())
-	}
+	count = mp.GetInodeTree().Len()
 	return
 }
@@ -20,8 +20,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"strings"
 
+	"github.com/cubefs/cubefs/util/tlsutil"
 	"github.com/spf13/cobra"
 )
 
@@ -42,11 +44,123 @@ var (
 }
 `)
 	defaultConfigTimeout uint16 = 60
+	defaultClusterName          = "default"
 )
 
+// optCluster and optMaster are the cluster and the master address specified by the global flags.
+var optCluster, optMaster string
+
 type Config struct {
-	MasterAddr []string `json:"masterAddr"`
-	Timeout    uint16   `json:"timeout"`
+	MasterAddr  []string `json:"masterAddr"`
+	Timeout     uint16   `json:"timeout"`
+	TLSCertFile string   `json:"tlsCertFile"`
+	TLSKeyFile  string   `json:"tlsKeyFile"`
+	TLSCAFile   string   `json:"tlsCAFile"`
+
+	// Clusters are the named cluster profiles, which are selected by --cluster and are the contexts of the shell.
+	Clusters map[string]*ClusterProfile `json:"clusters,omitempty"`
+	// CurrentCluster is the cluster selected without --cluster, the default one if it is empty.
+	CurrentCluster string `json:"currentCluster,omitempty"`
+
+	// Name is the name of the cluster selected.
+	Name string `json:"-"`
+}
+
+// ClusterProfile is the master address and the settings of a named cluster. The timeout and the TLS files
+// of the config are used if they are not set.
+type ClusterProfile struct {
+	MasterAddr  []string `json:"masterAddr"`
+	Timeout     uint16   `json:"timeout,omitempty"`
+	TLSCertFile string   `json:"tlsCertFile,omitempty"`
+	TLSKeyFile  string   `json:"tlsKeyFile,omitempty"`
+	TLSCAFile   string   `json:"tlsCAFile,omitempty"`
+}
+
+// UnmarshalJSON accepts the master address alone, which is how the clusters were saved before the profiles.
+func (profile *ClusterProfile) UnmarshalJSON(data []byte) error {
+	var masterAddr []string
+	if err := json.Unmarshal(data, &masterAddr); err == nil {
+		*profile = ClusterProfile{MasterAddr: masterAddr}
+		return nil
+	}
+	type plain ClusterProfile
+	return json.Unmarshal(data, (*plain)(profile))
+}
+
+func (profile *ClusterProfile) hasTLS() bool {
+	return profile.TLSCertFile != "" || profile.TLSKeyFile != "" || profile.TLSCAFile != ""
+}
+
+// Select returns the config of the named cluster, or of the current cluster if the name is empty, whose master
+// address is replaced by masterAddr if it is not empty. The name can also be a master address.
+func (config *Config) Select(cluster, masterAddr string) (*Config, error) {
+	var selected = *config
+	selected.CurrentCluster = ""
+	if cluster == "" {
+		cluster = config.CurrentCluster
+	}
+	profile, ok := config.Clusters[cluster]
+	switch {
+	case cluster == "" || cluster == defaultClusterName:
+		selected.Name = defaultClusterName
+	case ok:
+		selected.Name = cluster
+		selected.MasterAddr = profile.MasterAddr
+		if profile.Timeout != 0 {
+			selected.Timeout = profile.Timeout
+		}
+		if profile.hasTLS() {
+			selected.TLSCertFile, selected.TLSKeyFile, selected.TLSCAFile = profile.TLSCertFile, profile.TLSKeyFile, profile.TLSCAFile
+		}
+	case strings.Contains(cluster, ":"):
+		selected.Name = cluster
+		selected.MasterAddr = strings.Split(cluster, ",")
+	default:
+		return nil, fmt.Errorf("unknown cluster %v", cluster)
+	}
+	if masterAddr != "" {
+		selected.MasterAddr = strings.Split(masterAddr, ",")
+	}
+	return &selected, nil
+}
+
+// EnableTLS enables the mutual TLS by the TLS files of the config, or disables it if they are not set.
+func (config *Config) EnableTLS() error {
+	if config.TLSCertFile == "" && config.TLSKeyFile == "" && config.TLSCAFile == "" {
+		tlsutil.Disable()
+		return nil
+	}
+	return tlsutil.Enable(config.TLSCertFile, config.TLSKeyFile, config.TLSCAFile)
+}
+
+// ClusterFlags returns the values of the global flags selecting the cluster in the args, which are
+// needed before the commands are built.
+func ClusterFlags(args []string) (cluster, masterAddr string) {
+	for i := 0; i < len(args); i++ {
+		var value *string
+		var arg = args[i]
+		switch {
+		case arg == "--":
+			return
+		case arg == "--"+CliFlagCluster || arg == "--"+CliFlagMaster:
+			if i+1 < len(args) {
+				i++
+				value = &args[i]
+			}
+		case strings.HasPrefix(arg, "--"+CliFlagCluster+"="), strings.HasPrefix(arg, "--"+CliFlagMaster+"="):
+			var v = arg[strings.Index(arg, "=")+1:]
+			value = &v
+		}
+		if value == nil {
+			continue
+		}
+		if strings.HasPrefix(arg, "--"+CliFlagCluster) {
+			cluster = *value
+		} else {
+			masterAddr = *value
+		}
+	}
+	return
 }
 
 func newConfigCmd() *cobra.Command {
@@ -56,21 +170,27 @@ func newConfigCmd() *cobra.Command {
 	}
 	cmd.AddCommand(newConfigSetCmd())
 	cmd.AddCommand(newConfigInfoCmd())
+	cmd.AddCommand(newConfigUseCmd())
+	cmd.AddCommand(newConfigDeleteCmd())
 	return cmd
 }
 
 const (
-	cmdConfigSetShort  = "set value of config file"
-	cmdConfigInfoShort = "show info of config file"
+	cmdConfigSetShort    = "set value of config file"
+	cmdConfigInfoShort   = "show info of config file"
+	cmdConfigUseShort    = "select the cluster used without --cluster"
+	cmdConfigDeleteShort = "delete a cluster from config file"
 )
 
 func newConfigSetCmd() *cobra.Command {
 	var optMasterHosts string
 	var optTimeout uint16
+	var optCluster string
+	var optTLSCertFile, optTLSKeyFile, optTLSCAFile string
 	var cmd = &cobra.Command{
 		Use:   CliOpSet,
 		Short: cmdConfigSetShort,
-		Long:  `Set the config file`,
+		Long:  `Set the config file, or the profile of the named cluster by --cluster`,
 		Run: func(cmd *cobra.Command, args []string) {
 			var err error
 			defer func() {
@@ -78,21 +198,154 @@ func newConfigSetCmd() *cobra.Command {
 					errout("Error: %v", err)
 				}
 			}()
-			if optMasterHosts == "" && optTimeout == 0 {
-				stdout(fmt.Sprintf("No change. Input 'cfs-cli config set -h' for help.\n"))
+			if optMasterHosts == "" && optTimeout == 0 && optTLSCertFile == "" && optTLSKeyFile == "" && optTLSCAFile == "" {
+				outputMsg("No change. Input 'cfs-cli config set -h' for help.\n")
 				return
 			}
-			if err = setConfig(optMasterHosts, optTimeout); err != nil {
+			var config *Config
+			if config, err = LoadConfig(); err != nil {
 				return
 			}
-			stdout(fmt.Sprintf("Config has been set successfully!\n"))
+			var masterAddr, timeout = &config.MasterAddr, &config.Timeout
+			var tlsCertFile, tlsKeyFile, tlsCAFile = &config.TLSCertFile, &config.TLSKeyFile, &config.TLSCAFile
+			if optCluster != "" && optCluster != defaultClusterName {
+				var profile = config.Clusters[optCluster]
+				if profile == nil {
+					if optMasterHosts == "" {
+						err = fmt.Errorf("master address of cluster %v is not specified", optCluster)
+						return
+					}
+					if strings.Contains(optCluster, ":") {
+						err = fmt.Errorf("invalid cluster name %v", optCluster)
+						return
+					}
+					if config.Clusters == nil {
+						config.Clusters = make(map[string]*ClusterProfile)
+					}
+					profile = &ClusterProfile{}
+					config.Clusters[optCluster] = profile
+				}
+				masterAddr, timeout = &profile.MasterAddr, &profile.Timeout
+				tlsCertFile, tlsKeyFile, tlsCAFile = &profile.TLSCertFile, &profile.TLSKeyFile, &profile.TLSCAFile
+			}
+			if optMasterHosts != "" {
+				*masterAddr = strings.Split(optMasterHosts, ",")
+			}
+			if optTimeout != 0 {
+				*timeout = optTimeout
+			}
+			if cmd.Flags().Changed(CliFlagTLSCertFile) {
+				*tlsCertFile = optTLSCertFile
+			}
+			if cmd.Flags().Changed(CliFlagTLSKeyFile) {
+				*tlsKeyFile = optTLSKeyFile
+			}
+			if cmd.Flags().Changed(CliFlagTLSCAFile) {
+				*tlsCAFile = optTLSCAFile
+			}
+			// the cluster with the invalid TLS files cannot be used, even to correct the config
+			if *tlsCertFile != "" || *tlsKeyFile != "" || *tlsCAFile != "" {
+				if _, err = tlsutil.NewConfig(*tlsCertFile, *tlsKeyFile, *tlsCAFile); err != nil {
+					return
+				}
+			}
+			if err = saveConfig(config); err != nil {
+				return
+			}
+			outputMsg("Config has been set successfully!\n")
 		},
 	}
 	cmd.Flags().StringVar(&optMasterHosts, "addr", "",
 		"Specify master address {HOST}:{PORT}[,{HOST}:{PORT}]")
 	cmd.Flags().Uint16Var(&optTimeout, "timeout", 0, "Specify timeout for requests [Unit: s]")
+	cmd.Flags().StringVar(&optCluster, CliFlagCluster, "", "Set the profile of the named cluster instead of the default one")
+	cmd.Flags().StringVar(&optTLSCertFile, CliFlagTLSCertFile, "", "Specify the certificate file of the mutual TLS")
+	cmd.Flags().StringVar(&optTLSKeyFile, CliFlagTLSKeyFile, "", "Specify the key file of the mutual TLS")
+	cmd.Flags().StringVar(&optTLSCAFile, CliFlagTLSCAFile, "", "Specify the CA file of the mutual TLS")
 	return cmd
 }
+
+func newConfigUseCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliOpUse + " [CLUSTER]",
+		Short: cmdConfigUseShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var cluster = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var config *Config
+			if config, err = LoadConfig(); err != nil {
+				return
+			}
+			if _, ok := config.Clusters[cluster]; !ok && cluster != defaultClusterName {
+				err = fmt.Errorf("unknown cluster %v", cluster)
+				return
+			}
+			if config.CurrentCluster = cluster; cluster == defaultClusterName {
+				config.CurrentCluster = ""
+			}
+			if err = saveConfig(config); err != nil {
+				return
+			}
+			outputMsg("Current cluster is %v now.\n", cluster)
+		},
+		ValidArgsFunction: validClusters,
+	}
+	return cmd
+}
+
+func newConfigDeleteCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliOpDelete + " [CLUSTER]",
+		Short: cmdConfigDeleteShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var cluster = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var config *Config
+			if config, err = LoadConfig(); err != nil {
+				return
+			}
+			if _, ok := config.Clusters[cluster]; !ok {
+				err = fmt.Errorf("unknown cluster %v", cluster)
+				return
+			}
+			delete(config.Clusters, cluster)
+			if config.CurrentCluster == cluster {
+				config.CurrentCluster = ""
+			}
+			if err = saveConfig(config); err != nil {
+				return
+			}
+			outputMsg("Cluster %v has been deleted.\n", cluster)
+		},
+		ValidArgsFunction: validClusters,
+	}
+	return cmd
+}
+
+// validClusters completes the names of the clusters of the config.
+func validClusters(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return filterPrefix(append([]string{defaultClusterName}, config.clusterNames()...), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
 func newConfigInfoCmd() *cobra.Command {
 	var optFilterStatus string
 	var optFilterWritable string
@@ -105,7 +358,9 @@ func newConfigInfoCmd() *cobra.Command {
 				_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				OsExitWithLogFlush()
 			}
-			printConfigInfo(config)
+			output(config, func() {
+				printConfigInfo(config)
+			})
 		},
 	}
 	cmd.Flags().StringVar(&optFilterWritable, "filter-writable", "", "Filter node writable status")
@@ -114,31 +369,44 @@ func newConfigInfoCmd() *cobra.Command {
 }
 
 func printConfigInfo(config *Config) {
+	var current = config.CurrentCluster
+	if current == "" {
+		current = defaultClusterName
+	}
 	stdout("Config info:\n")
 	stdout("  Master  Address    : %v\n", config.MasterAddr)
 	stdout("  Request Timeout [s]: %v\n", config.Timeout)
-}
-
-func setConfig(masterHosts string, timeout uint16) (err error) {
-	var config *Config
-	if config, err = LoadConfig(); err != nil {
-		return
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" || config.TLSCAFile != "" {
+		stdout("  TLS     Files      : cert(%v) key(%v) ca(%v)\n", config.TLSCertFile, config.TLSKeyFile, config.TLSCAFile)
 	}
-	hosts := strings.Split(masterHosts, ",")
-	if masterHosts != "" && len(hosts) > 0 {
-		config.MasterAddr = hosts
+	stdout("  Current Cluster    : %v\n", current)
+	for _, name := range config.clusterNames() {
+		var profile = config.Clusters[name]
+		stdout("  Cluster %-12v: %v\n", name, profile.MasterAddr)
+		if profile.Timeout != 0 {
+			stdout("    Request Timeout [s]: %v\n", profile.Timeout)
+		}
+		if profile.hasTLS() {
+			stdout("    TLS     Files      : cert(%v) key(%v) ca(%v)\n", profile.TLSCertFile, profile.TLSKeyFile, profile.TLSCAFile)
+		}
 	}
-	if timeout != 0 {
-		config.Timeout = timeout
+}
+
+// clusterNames returns the sorted names of the clusters.
+func (config *Config) clusterNames() (names []string) {
+	for name := range config.Clusters {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return
+}
+
+func saveConfig(config *Config) (err error) {
 	var configData []byte
 	if configData, err = json.Marshal(config); err != nil {
 		return
 	}
-	if err = ioutil.WriteFile(defaultConfigPath, configData, 0600); err != nil {
-		return
-	}
-	return nil
+	return ioutil.WriteFile(defaultConfigPath, configData, 0600)
 }
 
 func LoadConfig() (*Config, error) {
@@ -34,8 +34,17 @@ const (
 	CliOpReset             = "reset"
 	CliOpReplicate         = "add-replica"
 	CliOpDelReplica        = "del-replica"
+	CliOpAddLearner        = "add-learner"
+	CliOpPromoteLearner    = "promote-learner"
 	CliOpExpand            = "expand"
 	CliOpShrink            = "shrink"
+	CliOpCheckConsistency  = "check-consistency"
+	CliOpConsistency       = "consistency"
+	CliOpDoctor            = "doctor"
+	CliOpVerify            = "verify"
+	CliOpRestore           = "restore"
+	CliOpUse               = "use"
+	CliOpDu                = "du"
 
 	//Shorthand format of operation name
 	CliOpDecommissionShortHand = "dec"
@@ -43,12 +52,18 @@ const (
 	//resource name
 	CliResourceDataNode      = "datanode [COMMAND]"
 	CliResourceMetaNode      = "metanode"
+	CliResourceFlashNode     = "flashnode"
+	CliResourceQuota         = "quota"
 	CliResourceDataPartition = "datapartition"
 	CliResourceMetaPartition = "metapartition"
 	CliResourceTopology      = "topology"
 	CliResourceRaftNode      = "raftnode"
 	CliResourceDisk          = "disk"
 	CliResourceConfig        = "config"
+	CliResourceSnapshot      = "snapshot"
+	CliResourceBackup        = "backup"
+	CliResourceSlowOp        = "slowop"
+	CliResourceVersion       = "version"
 
 	//Flags
 	CliFlagName               = "name"
@@ -73,6 +88,43 @@ const (
 	CliFlagDelWorkerSleepMs   = "delete-worker-sleep-ms"
 	CliFlagMarkDelRate        = "mark-delete-rate"
 	CliFlagCrossZone          = "crossZone"
+	CliFlagCaseInsensitive    = "case-insensitive"
+	CliFlagCompression        = "compression"
+	CliFlagFlashCache         = "flash-cache"
+	CliFlagQuotaKind          = "kind"
+	CliFlagMaxBytes           = "max-bytes"
+	CliFlagMaxInodes          = "max-inodes"
+	CliFlagEncrypted          = "encrypted"
+	CliFlagChecksum           = "checksum"
+	CliFlagRepair             = "repair"
+	CliFlagSnapshotPath       = "path"
+	CliFlagConsistent         = "consistent"
+	CliFlagCatalog            = "catalog"
+	CliFlagUsageSkew          = "usage-skew"
+	CliFlagHighUsage          = "high-usage"
+	CliFlagClockSkew          = "clock-skew"
+	CliFlagConcurrency        = "concurrency"
+	CliFlagBackupDir          = "dir"
+	CliFlagMetaProfPort       = "meta-prof-port"
+	CliFlagVolume             = "vol"
+	CliFlagRestoreTarget      = "to"
+	CliFlagCluster            = "cluster"
+	CliFlagMaster             = "master"
+	CliFlagTLSCertFile        = "tls-cert-file"
+	CliFlagTLSKeyFile         = "tls-key-file"
+	CliFlagTLSCAFile          = "tls-ca-file"
+	CliFlagHosts              = "hosts"
+	CliFlagSince              = "since"
+	CliFlagUntil              = "until"
+	CliFlagMinCost            = "min"
+	CliFlagGroupBy            = "by"
+	CliFlagTop                = "top"
+	CliFlagDirPath            = "path"
+	CliFlagDepth              = "depth"
+	CliFlagSortBy             = "sort"
+	CliFlagPlan               = "plan"
+	CliFlagRepairRate         = "rate"
+	CliFlagAll                = "all"
 
 	//CliFlagSetDataPartitionCount	= "count" use dp-count instead
 
@@ -67,8 +67,7 @@ func newDataNodeListCmd(client *master.MasterClient) *cobra.Command {
 			sort.SliceStable(view.DataNodes, func(i, j int) bool {
 				return view.DataNodes[i].ID < view.DataNodes[j].ID
 			})
-			stdout("[Data nodes]\n")
-			stdout("%v\n", formatNodeViewTableHeader())
+			var nodes = make([]proto.NodeView, 0, len(view.DataNodes))
 			for _, node := range view.DataNodes {
 				if optFilterStatus != "" &&
 					!strings.Contains(formatNodeStatus(node.Status), optFilterStatus) {
@@ -78,8 +77,15 @@ func newDataNodeListCmd(client *master.MasterClient) *cobra.Command {
 					!strings.Contains(formatYesNo(node.IsWritable), optFilterWritable) {
 					continue
 				}
-				stdout("%v\n", formatNodeView(&node, true))
+				nodes = append(nodes, node)
 			}
+			output(nodes, func() {
+				stdout("[Data nodes]\n")
+				stdout("%v\n", formatNodeViewTableHeader())
+				for _, node := range nodes {
+					stdout("%v\n", formatNodeView(&node, true))
+				}
+			})
 		},
 	}
 	cmd.Flags().StringVar(&optFilterWritable, "filter-writable", "", "Filter node writable status")
@@ -105,8 +111,10 @@ func newDataNodeInfoCmd(client *master.MasterClient) *cobra.Command {
 			if datanodeInfo, err = client.NodeAPI().GetDataNode(nodeAddr); err != nil {
 				return
 			}
-			stdout("[Data node info]\n")
-			stdout(formatDataNodeDetail(datanodeInfo, false))
+			output(datanodeInfo, func() {
+				stdout("[Data node info]\n")
+				stdout(formatDataNodeDetail(datanodeInfo, false))
+			})
 
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -120,6 +128,8 @@ func newDataNodeInfoCmd(client *master.MasterClient) *cobra.Command {
 }
 
 func newDataNodeDecommissionCmd(client *master.MasterClient) *cobra.Command {
+	var optPlan bool
+	var optRate uint64
 	var cmd = &cobra.Command{
 		Use:   CliOpDecommission + " [NODE ADDRESS]",
 		Short: cmdDataNodeDecommissionInfoShort,
@@ -133,10 +143,21 @@ func newDataNodeDecommissionCmd(client *master.MasterClient) *cobra.Command {
 				}
 			}()
 			nodeAddr = args[0]
+			if optPlan {
+				var plan *decommissionPlan
+				if plan, err = planDataNodeDecommission(client, nodeAddr, optRate); err != nil {
+					return
+				}
+				output(plan, func() {
+					stdout("[Decommission plan of data node]\n")
+					stdout("%s", formatDecommissionPlan(plan))
+				})
+				return
+			}
 			if err = client.NodeAPI().DataNodeDecommission(nodeAddr); err != nil {
 				return
 			}
-			stdout("Decommission data node successfully\n")
+			outputMsg("Decommission data node successfully\n")
 
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -146,5 +167,7 @@ func newDataNodeDecommissionCmd(client *master.MasterClient) *cobra.Command {
 			return validDataNodes(client, toComplete), cobra.ShellCompDirectiveNoFileComp
 		},
 	}
+	cmd.Flags().BoolVar(&optPlan, CliFlagPlan, false, "Print the plan and check the capacity of the targets without decommission")
+	cmd.Flags().Uint64Var(&optRate, CliFlagRepairRate, 0, "Repair bytes per second of each disk of the targets, the limits in effect by default")
 	return cmd
 }
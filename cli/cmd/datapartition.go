@@ -39,6 +39,8 @@ func newDataPartitionCmd(client *master.MasterClient) *cobra.Command {
 		newDataPartitionDecommissionCmd(client),
 		newDataPartitionReplicateCmd(client),
 		newDataPartitionDeleteReplicaCmd(client),
+		newDataPartitionCheckConsistencyCmd(client),
+		newDataPartitionConsistencyCmd(client),
 	)
 	return cmd
 }
@@ -49,9 +51,12 @@ const (
 	cmdDataPartitionDecommissionShort  = "Decommission a replication of the data partition to a new address"
 	cmdDataPartitionReplicateShort     = "Add a replication of the data partition on a new address"
 	cmdDataPartitionDeleteReplicaShort = "Delete a replication of the data partition on a fixed address"
+	cmdDataPartitionCheckConsistShort  = "Compare the extents of the replicas of a data partition"
+	cmdDataPartitionConsistencyShort   = "Display the result of the latest comparison of the replicas of a data partition"
 )
 
 func newDataPartitionGetCmd(client *master.MasterClient) *cobra.Command {
+	var optWatch watchOption
 	var cmd = &cobra.Command{
 		Use:   CliOpInfo + " [DATA PARTITION ID]",
 		Short: cmdDataPartitionGetShort,
@@ -60,7 +65,6 @@ func newDataPartitionGetCmd(client *master.MasterClient) *cobra.Command {
 			var (
 				err         error
 				partitionID uint64
-				partition   *proto.DataPartitionInfo
 			)
 			defer func() {
 				if err != nil {
@@ -70,15 +74,30 @@ func newDataPartitionGetCmd(client *master.MasterClient) *cobra.Command {
 			if partitionID, err = strconv.ParseUint(args[0], 10, 64); err != nil {
 				return
 			}
-			if partition, err = client.AdminAPI().GetDataPartition("", partitionID); err != nil {
+			err = optWatch.run(cmd, func() (err error) {
+				var partition *proto.DataPartitionInfo
+				if partition, err = client.AdminAPI().GetDataPartition("", partitionID); err != nil {
+					return
+				}
+				output(partition, func() {
+					stdout(formatDataPartitionInfo(partition))
+				})
 				return
-			}
-			stdout(formatDataPartitionInfo(partition))
+			})
 		},
 	}
+	optWatch.addFlags(cmd)
 	return cmd
 }
 
+// dataPartitionCheckOutput is the result of the check of the data partitions.
+type dataPartitionCheckOutput struct {
+	InactiveDataNodes         []*proto.DataNodeInfo      `json:"inactiveDataNodes"`
+	CorruptDataPartitions     []*proto.DataPartitionInfo `json:"corruptDataPartitions"`
+	LackReplicaDataPartitions []*proto.DataPartitionInfo `json:"lackReplicaDataPartitions"`
+	BadDataPartitions         []proto.BadPartitionView   `json:"badDataPartitions"`
+}
+
 func newListCorruptDataPartitionCmd(client *master.MasterClient) *cobra.Command {
 	var cmd = &cobra.Command{
 		Use:   CliOpCheck,
@@ -92,7 +111,6 @@ The "reset" command will be released in next version`,
 		Run: func(cmd *cobra.Command, args []string) {
 			var (
 				diagnosis *proto.DataPartitionDiagnosis
-				dataNodes []*proto.DataNodeInfo
 				err       error
 			)
 			defer func() {
@@ -103,24 +121,17 @@ The "reset" command will be released in next version`,
 			if diagnosis, err = client.AdminAPI().DiagnoseDataPartition(); err != nil {
 				return
 			}
-			stdout("[Inactive Data nodes]:\n")
-			stdout("%v\n", formatDataNodeDetailTableHeader())
+			var result = &dataPartitionCheckOutput{BadDataPartitions: diagnosis.BadDataPartitionIDs}
 			for _, addr := range diagnosis.InactiveDataNodes {
 				var node *proto.DataNodeInfo
 				if node, err = client.NodeAPI().GetDataNode(addr); err != nil {
 					return
 				}
-				dataNodes = append(dataNodes, node)
+				result.InactiveDataNodes = append(result.InactiveDataNodes, node)
 			}
-			sort.SliceStable(dataNodes, func(i, j int) bool {
-				return dataNodes[i].ID < dataNodes[j].ID
+			sort.SliceStable(result.InactiveDataNodes, func(i, j int) bool {
+				return result.InactiveDataNodes[i].ID < result.InactiveDataNodes[j].ID
 			})
-			for _, node := range dataNodes {
-				stdout("%v\n", formatDataNodeDetail(node, true))
-			}
-			stdout("\n")
-			stdout("[Corrupt data partitions](no leader):\n")
-			stdout("%v\n", partitionInfoTableHeader)
 			sort.SliceStable(diagnosis.CorruptDataPartitionIDs, func(i, j int) bool {
 				return diagnosis.CorruptDataPartitionIDs[i] < diagnosis.CorruptDataPartitionIDs[j]
 			})
@@ -130,12 +141,8 @@ The "reset" command will be released in next version`,
 					err = fmt.Errorf("Partition not found, err:[%v] ", err)
 					return
 				}
-				stdout("%v\n", formatDataPartitionInfoRow(partition))
+				result.CorruptDataPartitions = append(result.CorruptDataPartitions, partition)
 			}
-
-			stdout("\n")
-			stdout("%v\n", "[Partition lack replicas]:")
-			stdout("%v\n", partitionInfoTableHeader)
 			sort.SliceStable(diagnosis.LackReplicaDataPartitionIDs, func(i, j int) bool {
 				return diagnosis.LackReplicaDataPartitionIDs[i] < diagnosis.LackReplicaDataPartitionIDs[j]
 			})
@@ -146,22 +153,42 @@ The "reset" command will be released in next version`,
 					return
 				}
 				if partition != nil {
-					stdout("%v\n", formatDataPartitionInfoRow(partition))
+					result.LackReplicaDataPartitions = append(result.LackReplicaDataPartitions, partition)
 				}
 			}
-
-			stdout("\n")
-			stdout("%v\n", "[Bad data partitions(decommission not completed)]:")
-			badPartitionTablePattern := "%-8v    %-10v\n"
-			stdout(badPartitionTablePattern, "PATH", "PARTITION ID")
-			for _, bdpv := range diagnosis.BadDataPartitionIDs {
-				sort.SliceStable(bdpv.PartitionIDs, func(i, j int) bool {
-					return bdpv.PartitionIDs[i] < bdpv.PartitionIDs[j]
+			for _, bpv := range result.BadDataPartitions {
+				sort.SliceStable(bpv.PartitionIDs, func(i, j int) bool {
+					return bpv.PartitionIDs[i] < bpv.PartitionIDs[j]
 				})
-				for _, pid := range bdpv.PartitionIDs {
-					stdout(badPartitionTablePattern, bdpv.Path, pid)
-				}
 			}
+			output(result, func() {
+				stdout("[Inactive Data nodes]:\n")
+				stdout("%v\n", formatDataNodeDetailTableHeader())
+				for _, node := range result.InactiveDataNodes {
+					stdout("%v\n", formatDataNodeDetail(node, true))
+				}
+				stdout("\n")
+				stdout("[Corrupt data partitions](no leader):\n")
+				stdout("%v\n", partitionInfoTableHeader)
+				for _, partition := range result.CorruptDataPartitions {
+					stdout("%v\n", formatDataPartitionInfoRow(partition))
+				}
+				stdout("\n")
+				stdout("%v\n", "[Partition lack replicas]:")
+				stdout("%v\n", partitionInfoTableHeader)
+				for _, partition := range result.LackReplicaDataPartitions {
+					stdout("%v\n", formatDataPartitionInfoRow(partition))
+				}
+				stdout("\n")
+				stdout("%v\n", "[Bad data partitions(decommission not completed)]:")
+				badPartitionTablePattern := "%-8v    %-10v\n"
+				stdout(badPartitionTablePattern, "PATH", "PARTITION ID")
+				for _, bpv := range result.BadDataPartitions {
+					for _, pid := range bpv.PartitionIDs {
+						stdout(badPartitionTablePattern, bpv.Path, pid)
+					}
+				}
+			})
 			return
 		},
 	}
@@ -191,6 +218,7 @@ func newDataPartitionDecommissionCmd(client *master.MasterClient) *cobra.Command
 			if err = client.AdminAPI().DecommissionDataPartition(partitionID, address); err != nil {
 				return
 			}
+			outputMsg("Decommission data partition %v on %v successfully\n", partitionID, address)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -224,6 +252,7 @@ func newDataPartitionReplicateCmd(client *master.MasterClient) *cobra.Command {
 			if err = client.AdminAPI().AddDataReplica(partitionID, address); err != nil {
 				return
 			}
+			outputMsg("Add replica of data partition %v on %v successfully\n", partitionID, address)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -257,6 +286,7 @@ func newDataPartitionDeleteReplicaCmd(client *master.MasterClient) *cobra.Comman
 			if err = client.AdminAPI().DeleteDataReplica(partitionID, address); err != nil {
 				return
 			}
+			outputMsg("Delete replica of data partition %v on %v successfully\n", partitionID, address)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -267,3 +297,66 @@ func newDataPartitionDeleteReplicaCmd(client *master.MasterClient) *cobra.Comman
 	}
 	return cmd
 }
+
+func newDataPartitionCheckConsistencyCmd(client *master.MasterClient) *cobra.Command {
+	var optRepair bool
+	var cmd = &cobra.Command{
+		Use:   CliOpCheckConsistency + " [DATA PARTITION ID]",
+		Short: cmdDataPartitionCheckConsistShort,
+		Long: `The leader of the data partition compares the size and the CRC of every extent on all the replicas.
+The extents modified in the last 10 minutes are not compared. With "--repair", the replicas differing
+from the majority are repaired from a replica of the majority. The result is displayed by the
+"consistency" command once the comparison is done.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err         error
+				partitionID uint64
+			)
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if partitionID, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+				return
+			}
+			if err = client.AdminAPI().CheckDataPartition(partitionID, optRepair); err != nil {
+				return
+			}
+			outputMsg("Check of data partition %v started.\n", partitionID)
+		},
+	}
+	cmd.Flags().BoolVar(&optRepair, CliFlagRepair, false, "Repair the replicas differing from the majority")
+	return cmd
+}
+
+func newDataPartitionConsistencyCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliOpConsistency + " [DATA PARTITION ID]",
+		Short: cmdDataPartitionConsistencyShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err         error
+				partitionID uint64
+				result      *proto.CheckDataPartitionResponse
+			)
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if partitionID, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+				return
+			}
+			if result, err = client.AdminAPI().GetDataPartitionCheck(partitionID); err != nil {
+				return
+			}
+			output(result, func() {
+				stdout("%v", formatDataPartitionCheck(result))
+			})
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,370 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util"
+)
+
+const (
+	// the data nodes with less available space are not writable on the master
+	decommissionPlanDataReserved = 10 * util.GB
+	// the memory usage threshold of the meta nodes not reporting it
+	decommissionPlanMetaThreshold = 0.75
+	// the nodes loaded concurrently for the plan
+	decommissionPlanConcurrency = 16
+)
+
+// decommissionPlanTarget is a node receiving the replicas displaced by the decommission.
+type decommissionPlanTarget struct {
+	Addr        string `json:"addr"`
+	Zone        string `json:"zone"`
+	NodeSetID   uint64 `json:"nodeSetID"`
+	Partitions  int    `json:"partitions"`
+	Bytes       uint64 `json:"bytes"`
+	AvailBefore uint64 `json:"availBefore"`
+	AvailAfter  uint64 `json:"availAfter"`
+	Rate        uint64 `json:"rate"` // repair bytes per second of the node, 0 for no limit
+	Duration    string `json:"duration,omitempty"`
+}
+
+// decommissionPlan is the placement of the replicas of a node to decommission, computed the way the master
+// chooses the new hosts: the nodes in the same node set, then the ones in the same zone, then the others.
+type decommissionPlan struct {
+	Node       string                    `json:"node"`
+	Zone       string                    `json:"zone"`
+	NodeSetID  uint64                    `json:"nodeSetID"`
+	Partitions int                       `json:"partitions"`
+	Bytes      uint64                    `json:"bytes"`
+	Feasible   bool                      `json:"feasible"`
+	Duration   string                    `json:"duration,omitempty"` // empty if any target is not limited
+	Targets    []*decommissionPlanTarget `json:"targets"`
+	Unplaced   []uint64                  `json:"unplaced"` // the partitions without a target
+}
+
+// planNode is a node of the cluster as the planner sees it.
+type planNode struct {
+	addr       string
+	zone       string
+	nodeSetID  uint64
+	avail      uint64
+	rate       uint64 // repair bytes per second, 0 for no limit
+	partitions map[uint64]bool
+	target     *decommissionPlanTarget
+}
+
+type planPartition struct {
+	id   uint64
+	size uint64
+}
+
+// planDecommission places the partitions of the source to the candidates, the larger partitions first, each
+// to the node with the most available space in the nearest scope which does not host the partition yet.
+func planDecommission(src *planNode, partitions []*planPartition, candidates []*planNode) *decommissionPlan {
+	var plan = &decommissionPlan{
+		Node:       src.addr,
+		Zone:       src.zone,
+		NodeSetID:  src.nodeSetID,
+		Partitions: len(partitions),
+		Targets:    make([]*decommissionPlanTarget, 0),
+		Unplaced:   make([]uint64, 0),
+	}
+	sort.SliceStable(partitions, func(i, j int) bool {
+		return partitions[i].size > partitions[j].size
+	})
+	var scopes = []func(node *planNode) bool{
+		func(node *planNode) bool { return node.zone == src.zone && node.nodeSetID == src.nodeSetID },
+		func(node *planNode) bool { return node.zone == src.zone },
+		func(node *planNode) bool { return true },
+	}
+	for _, partition := range partitions {
+		plan.Bytes += partition.size
+		var chosen *planNode
+		for _, inScope := range scopes {
+			for _, node := range candidates {
+				if !inScope(node) || node.partitions[partition.id] || node.avail < partition.size {
+					continue
+				}
+				if chosen == nil || node.avail > chosen.avail {
+					chosen = node
+				}
+			}
+			if chosen != nil {
+				break
+			}
+		}
+		if chosen == nil {
+			plan.Unplaced = append(plan.Unplaced, partition.id)
+			continue
+		}
+		if chosen.target == nil {
+			chosen.target = &decommissionPlanTarget{
+				Addr:        chosen.addr,
+				Zone:        chosen.zone,
+				NodeSetID:   chosen.nodeSetID,
+				AvailBefore: chosen.avail,
+				Rate:        chosen.rate,
+			}
+			plan.Targets = append(plan.Targets, chosen.target)
+		}
+		chosen.avail -= partition.size
+		chosen.partitions[partition.id] = true
+		chosen.target.Partitions++
+		chosen.target.Bytes += partition.size
+		chosen.target.AvailAfter = chosen.avail
+	}
+	plan.Feasible = len(plan.Unplaced) == 0
+
+	// the targets receive the replicas in parallel, so the slowest of them takes the longest
+	var longest time.Duration
+	var limited = true
+	for _, target := range plan.Targets {
+		if target.Rate == 0 {
+			limited = false
+			continue
+		}
+		var duration = time.Duration(float64(target.Bytes) / float64(target.Rate) * float64(time.Second))
+		target.Duration = duration.Round(time.Second).String()
+		if duration > longest {
+			longest = duration
+		}
+	}
+	if limited {
+		plan.Duration = longest.Round(time.Second).String()
+	}
+	sort.SliceStable(plan.Targets, func(i, j int) bool {
+		return plan.Targets[i].Bytes > plan.Targets[j].Bytes
+	})
+	return plan
+}
+
+// loadNodesConcurrently calls load on the active nodes except the source with the bounded concurrency.
+func loadNodesConcurrently(nodes []proto.NodeView, srcAddr string, load func(addr string) error) (err error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []string
+		limit    = make(chan struct{}, decommissionPlanConcurrency)
+	)
+	for _, node := range nodes {
+		if !node.Status || node.Addr == srcAddr {
+			continue
+		}
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			if err := load(addr); err != nil {
+				mu.Lock()
+				failures = append(failures, fmt.Sprintf("%v: %v", addr, err))
+				mu.Unlock()
+			}
+		}(node.Addr)
+	}
+	wg.Wait()
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return fmt.Errorf("load nodes failed:\n  %v", strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+// planDataNodeDecommission plans the decommission of a data node. The repair rate of a target is the rate
+// per disk of its own limits or the cluster's, times its disks, unless the rate per disk is given.
+func planDataNodeDecommission(client *master.MasterClient, nodeAddr string, diskRate uint64) (plan *decommissionPlan, err error) {
+	var view *proto.ClusterView
+	if view, err = client.AdminAPI().GetCluster(); err != nil {
+		return
+	}
+	var src *proto.DataNodeInfo
+	if src, err = client.NodeAPI().GetDataNode(nodeAddr); err != nil {
+		return
+	}
+	var clusterRate uint64
+	if diskRate == 0 {
+		var info *proto.ClusterInfo
+		if info, err = client.AdminAPI().GetClusterInfo(); err != nil {
+			return
+		}
+		clusterRate = info.DataNodeDiskRepairIOLimitRate
+	}
+	var (
+		mu    sync.Mutex
+		nodes []*proto.DataNodeInfo
+	)
+	if err = loadNodesConcurrently(view.DataNodes, src.Addr, func(addr string) error {
+		info, err := client.NodeAPI().GetDataNode(addr)
+		if err == nil {
+			mu.Lock()
+			nodes = append(nodes, info)
+			mu.Unlock()
+		}
+		return err
+	}); err != nil {
+		return
+	}
+
+	// the size of a partition is the largest one reported by its replicas, since the node to
+	// decommission may be dead and report nothing
+	var sizes = make(map[uint64]uint64)
+	var candidates = make([]*planNode, 0, len(nodes))
+	var now = time.Now()
+	for _, node := range append(nodes, src) {
+		var disks = make(map[string]bool)
+		for _, report := range node.DataPartitionReports {
+			disks[report.DiskPath] = true
+			if report.Used > sizes[report.PartitionID] {
+				sizes[report.PartitionID] = report.Used
+			}
+		}
+		if node == src || !node.IsActive || !node.IsWriteAble || node.RdOnly {
+			continue
+		}
+		var candidate = &planNode{
+			addr:       node.Addr,
+			zone:       node.ZoneName,
+			nodeSetID:  node.NodeSetID,
+			partitions: make(map[uint64]bool, len(node.PersistenceDataPartitions)),
+		}
+		if node.AvailableSpace > decommissionPlanDataReserved {
+			candidate.avail = node.AvailableSpace - decommissionPlanDataReserved
+		}
+		for _, id := range node.PersistenceDataPartitions {
+			candidate.partitions[id] = true
+		}
+		var rate = diskRate
+		if rate == 0 {
+			rate = clusterRate
+			if node.RepairLimit != nil {
+				_, rate = node.RepairLimit.LimitsAt(now)
+			}
+		}
+		if len(disks) == 0 {
+			disks[""] = true
+		}
+		candidate.rate = rate * uint64(len(disks))
+		candidates = append(candidates, candidate)
+	}
+	var partitions = make([]*planPartition, 0, len(src.PersistenceDataPartitions))
+	for _, id := range src.PersistenceDataPartitions {
+		partitions = append(partitions, &planPartition{id: id, size: sizes[id]})
+	}
+	return planDecommission(&planNode{addr: src.Addr, zone: src.ZoneName, nodeSetID: src.NodeSetID},
+		partitions, candidates), nil
+}
+
+// planMetaNodeDecommission plans the decommission of a meta node. The memory of the node is taken as shared
+// evenly by its partitions, and the targets are not limited unless the rate per node is given.
+func planMetaNodeDecommission(client *master.MasterClient, nodeAddr string, nodeRate uint64) (plan *decommissionPlan, err error) {
+	var view *proto.ClusterView
+	if view, err = client.AdminAPI().GetCluster(); err != nil {
+		return
+	}
+	var src *proto.MetaNodeInfo
+	if src, err = client.NodeAPI().GetMetaNode(nodeAddr); err != nil {
+		return
+	}
+	var (
+		mu    sync.Mutex
+		nodes []*proto.MetaNodeInfo
+	)
+	if err = loadNodesConcurrently(view.MetaNodes, src.Addr, func(addr string) error {
+		info, err := client.NodeAPI().GetMetaNode(addr)
+		if err == nil {
+			mu.Lock()
+			nodes = append(nodes, info)
+			mu.Unlock()
+		}
+		return err
+	}); err != nil {
+		return
+	}
+
+	var candidates = make([]*planNode, 0, len(nodes))
+	for _, node := range nodes {
+		if !node.IsActive || !node.IsWriteAble || node.RdOnly {
+			continue
+		}
+		var candidate = &planNode{
+			addr:       node.Addr,
+			zone:       node.ZoneName,
+			nodeSetID:  node.NodeSetID,
+			rate:       nodeRate,
+			partitions: make(map[uint64]bool, len(node.PersistenceMetaPartitions)),
+		}
+		var threshold = float64(node.Threshold)
+		if threshold <= 0 {
+			threshold = decommissionPlanMetaThreshold
+		}
+		if limit := uint64(float64(node.Total) * threshold); limit > node.Used {
+			candidate.avail = limit - node.Used
+		}
+		for _, id := range node.PersistenceMetaPartitions {
+			candidate.partitions[id] = true
+		}
+		candidates = append(candidates, candidate)
+	}
+	var partitions = make([]*planPartition, 0, len(src.PersistenceMetaPartitions))
+	for _, id := range src.PersistenceMetaPartitions {
+		partitions = append(partitions, &planPartition{id: id, size: src.Used / uint64(len(src.PersistenceMetaPartitions))})
+	}
+	return planDecommission(&planNode{addr: src.Addr, zone: src.ZoneName, nodeSetID: src.NodeSetID},
+		partitions, candidates), nil
+}
+
+var (
+	decommissionPlanTablePattern = "%-24v    %-12v    %-8v    %-10v    %-12v    %-12v    %-12v    %-12v    %v"
+	decommissionPlanTableHeader  = fmt.Sprintf(decommissionPlanTablePattern,
+		"TARGET", "ZONE", "NODESET", "PARTITIONS", "SIZE", "AVAIL", "AVAIL AFTER", "RATE", "DURATION")
+)
+
+func formatDecommissionPlan(plan *decommissionPlan) string {
+	var sb = strings.Builder{}
+	var duration = plan.Duration
+	if duration == "" {
+		duration = "unknown, some targets are not limited"
+	}
+	sb.WriteString(fmt.Sprintf("  Node                : %v\n", plan.Node))
+	sb.WriteString(fmt.Sprintf("  Zone                : %v\n", plan.Zone))
+	sb.WriteString(fmt.Sprintf("  Node set            : %v\n", plan.NodeSetID))
+	sb.WriteString(fmt.Sprintf("  Partitions          : %v\n", plan.Partitions))
+	sb.WriteString(fmt.Sprintf("  Size                : %v\n", formatSize(plan.Bytes)))
+	sb.WriteString(fmt.Sprintf("  Feasible            : %v\n", formatYesNo(plan.Feasible)))
+	sb.WriteString(fmt.Sprintf("  Estimated duration  : %v\n", duration))
+	if len(plan.Unplaced) > 0 {
+		sb.WriteString(fmt.Sprintf("  Without target      : %v\n", plan.Unplaced))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(decommissionPlanTableHeader + "\n")
+	for _, target := range plan.Targets {
+		var rate, duration = "unlimited", "-"
+		if target.Rate > 0 {
+			rate, duration = formatSize(target.Rate)+"/s", target.Duration
+		}
+		sb.WriteString(fmt.Sprintf(decommissionPlanTablePattern, target.Addr, target.Zone, target.NodeSetID,
+			target.Partitions, formatSize(target.Bytes), formatSize(target.AvailBefore), formatSize(target.AvailAfter),
+			rate, duration) + "\n")
+	}
+	return sb.String()
+}
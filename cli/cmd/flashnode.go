@@ -0,0 +1,118 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdFlashNodeShort = "Manage flash nodes"
+)
+
+func newFlashNodeCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliResourceFlashNode,
+		Short: cmdFlashNodeShort,
+	}
+	cmd.AddCommand(
+		newFlashNodeListCmd(client),
+		newFlashNodeInfoCmd(client),
+		newFlashNodeDecommissionCmd(client),
+	)
+	return cmd
+}
+
+const (
+	cmdFlashNodeListShort         = "List information of flash nodes"
+	cmdFlashNodeInfoShort         = "Show information of a flash node"
+	cmdFlashNodeDecommissionShort = "Remove a flash node from the cluster"
+)
+
+func newFlashNodeListCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:     CliOpList,
+		Short:   cmdFlashNodeListShort,
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			var nodes []*proto.FlashNodeView
+			if nodes, err = client.NodeAPI().ListFlashNodes(); err != nil {
+				return
+			}
+			output(nodes, func() {
+				stdout("[Flash nodes]\n")
+				stdout("%v\n", formatFlashNodeTableHeader())
+				for _, node := range nodes {
+					stdout("%v\n", formatFlashNodeDetail(node, true))
+				}
+			})
+		},
+	}
+	return cmd
+}
+
+func newFlashNodeInfoCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliOpInfo + " [NODE ADDRESS]",
+		Short: cmdFlashNodeInfoShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var node *proto.FlashNodeView
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if node, err = client.NodeAPI().GetFlashNode(args[0]); err != nil {
+				return
+			}
+			output(node, func() {
+				stdout("[Flash node info]\n")
+				stdout("%s", formatFlashNodeDetail(node, false))
+			})
+		},
+	}
+	return cmd
+}
+
+func newFlashNodeDecommissionCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliOpDecommission + " [NODE ADDRESS]",
+		Short: cmdFlashNodeDecommissionShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if err = client.NodeAPI().FlashNodeDecommission(args[0]); err != nil {
+				return
+			}
+			outputMsg("Decommission flash node successfully\n")
+		},
+	}
+	return cmd
+}
@@ -100,6 +100,11 @@ func formatSimpleVolView(svv *proto.SimpleVolView) string {
 	sb.WriteString(fmt.Sprintf("  Authenticate         : %v\n", formatEnabledDisabled(svv.Authenticate)))
 	sb.WriteString(fmt.Sprintf("  Follower read        : %v\n", formatEnabledDisabled(svv.FollowerRead)))
 	sb.WriteString(fmt.Sprintf("  Cross zone           : %v\n", formatEnabledDisabled(svv.CrossZone)))
+	sb.WriteString(fmt.Sprintf("  Case insensitive     : %v\n", formatEnabledDisabled(svv.CaseInsensitive)))
+	sb.WriteString(fmt.Sprintf("  Compression          : %v\n", formatCompression(svv.Compression)))
+	sb.WriteString(fmt.Sprintf("  Flash cache          : %v\n", formatEnabledDisabled(svv.FlashCache)))
+	sb.WriteString(fmt.Sprintf("  Encrypted            : %v\n", formatEnabledDisabled(svv.Encrypted)))
+	sb.WriteString(fmt.Sprintf("  Checksum             : %v\n", svv.Checksum))
 	sb.WriteString(fmt.Sprintf("  Inode count          : %v\n", svv.InodeCount))
 	sb.WriteString(fmt.Sprintf("  Dentry count         : %v\n", svv.DentryCount))
 	sb.WriteString(fmt.Sprintf("  Max metaPartition ID : %v\n", svv.MaxMetaPartitionID))
@@ -225,6 +230,11 @@ func formatMetaPartitionInfo(partition *proto.MetaPartitionInfo) string {
 		sb.WriteString(fmt.Sprintf("%v\n", formatPeer(peer)))
 	}
 	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("Learners :\n"))
+	for _, learner := range partition.Learners {
+		sb.WriteString(fmt.Sprintf("%v\n", formatPeer(learner)))
+	}
+	sb.WriteString("\n")
 	sb.WriteString(fmt.Sprintf("Hosts :\n"))
 	for _, host := range partition.Hosts {
 		sb.WriteString(fmt.Sprintf("  [%v]", host))
@@ -334,6 +344,13 @@ func formatEnabledDisabled(b bool) string {
 	return "Disabled"
 }
 
+func formatCompression(compression string) string {
+	if compression == "" {
+		return proto.CompressionNone
+	}
+	return compression
+}
+
 func formatNodeStatus(status bool) string {
 	if status {
 		return "Active"
@@ -356,6 +373,31 @@ func formatSize(size uint64) string {
 	return fmt.Sprintf("%v %v", fixedSize, units[fixedUnitIndex])
 }
 
+func formatDataPartitionCheck(result *proto.CheckDataPartitionResponse) string {
+	var sb = strings.Builder{}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("PartitionID   : %v\n", result.PartitionId))
+	sb.WriteString(fmt.Sprintf("Hosts         : %v\n", strings.Join(result.Hosts, ", ")))
+	sb.WriteString(fmt.Sprintf("Repair        : %v\n", result.Repair))
+	sb.WriteString(fmt.Sprintf("StartTime     : %v\n", formatTime(result.StartTime)))
+	sb.WriteString(fmt.Sprintf("EndTime       : %v\n", formatTime(result.EndTime)))
+	if result.Status == proto.TaskFailed {
+		sb.WriteString(fmt.Sprintf("Error         : %v\n", result.Result))
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("Checked       : %v\n", result.CheckedExtents))
+	sb.WriteString(fmt.Sprintf("Skipped       : %v\n", result.SkippedExtents))
+	sb.WriteString(fmt.Sprintf("Differences   : %v\n", len(result.Diffs)))
+	for _, diff := range result.Diffs {
+		sb.WriteString(fmt.Sprintf("  Extent[%v] reason[%v] majority[%v] bad[%v] repaired[%v]\n",
+			diff.ExtentID, diff.Reason, diff.Majority, strings.Join(diff.BadHosts, ", "), diff.Repaired))
+		for _, replica := range diff.Replicas {
+			sb.WriteString(fmt.Sprintf("    %-22v exist[%v] size[%v] crc[%v]\n", replica.Addr, replica.Exist, replica.Size, replica.Crc))
+		}
+	}
+	return sb.String()
+}
+
 func formatTime(timeUnix int64) string {
 	return time.Unix(timeUnix, 0).Format("2006-01-02 15:04:05")
 }
@@ -439,12 +481,93 @@ func formatDataNodeDetail(dn *proto.DataNodeInfo, rowTable bool) string {
 	sb.WriteString(fmt.Sprintf("  Zone                : %v\n", dn.ZoneName))
 	sb.WriteString(fmt.Sprintf("  IsActive            : %v\n", formatNodeStatus(dn.IsActive)))
 	sb.WriteString(fmt.Sprintf("  Report time         : %v\n", formatTimeToString(dn.ReportTime)))
+	sb.WriteString(fmt.Sprintf("  Version             : %v\n", dn.Version))
+	sb.WriteString(fmt.Sprintf("  Clock skew          : %v\n", dn.ClockSkew))
 	sb.WriteString(fmt.Sprintf("  Partition count     : %v\n", dn.DataPartitionCount))
 	sb.WriteString(fmt.Sprintf("  Bad disks           : %v\n", dn.BadDisks))
 	sb.WriteString(fmt.Sprintf("  Persist partitions  : %v\n", dn.PersistenceDataPartitions))
 	return sb.String()
 }
 
+var flashNodeTableRowPattern = "%-6v    %-6v    %-18v    %-8v    %-8v    %-8v    %-10v    %-10v"
+
+func formatFlashNodeTableHeader() string {
+	return fmt.Sprintf(flashNodeTableRowPattern, "ID", "ZONE", "ADDRESS", "USED", "TOTAL", "STATUS", "HIT RATIO", "REPORT TIME")
+}
+
+func formatFlashNodeDetail(fn *proto.FlashNodeView, rowTable bool) string {
+	var hitRatio float64
+	if fn.Hits+fn.Misses > 0 {
+		hitRatio = float64(fn.Hits) / float64(fn.Hits+fn.Misses)
+	}
+	if rowTable {
+		return fmt.Sprintf(flashNodeTableRowPattern, fn.ID, fn.ZoneName, fn.Addr, formatSize(fn.Used), formatSize(fn.Total),
+			formatNodeStatus(fn.IsActive), fmt.Sprintf("%.2f%%", hitRatio*100), formatTimeToString(fn.ReportTime))
+	}
+	var sb = strings.Builder{}
+	sb.WriteString(fmt.Sprintf("  ID                  : %v\n", fn.ID))
+	sb.WriteString(fmt.Sprintf("  Address             : %v\n", fn.Addr))
+	sb.WriteString(fmt.Sprintf("  Zone                : %v\n", fn.ZoneName))
+	sb.WriteString(fmt.Sprintf("  Used                : %v\n", formatSize(fn.Used)))
+	sb.WriteString(fmt.Sprintf("  Total               : %v\n", formatSize(fn.Total)))
+	sb.WriteString(fmt.Sprintf("  Hits                : %v\n", fn.Hits))
+	sb.WriteString(fmt.Sprintf("  Misses              : %v\n", fn.Misses))
+	sb.WriteString(fmt.Sprintf("  Hit ratio           : %.2f%%\n", hitRatio*100))
+	sb.WriteString(fmt.Sprintf("  IsActive            : %v\n", formatNodeStatus(fn.IsActive)))
+	sb.WriteString(fmt.Sprintf("  Report time         : %v\n", formatTimeToString(fn.ReportTime)))
+	sb.WriteString(fmt.Sprintf("  Version             : %v\n", fn.Version))
+	return sb.String()
+}
+
+var quotaTableRowPattern = "%-6v    %-24v    %-10v    %-10v    %-12v    %-12v    %-8v"
+
+func formatQuotaTableHeader() string {
+	return fmt.Sprintf(quotaTableRowPattern, "KIND", "NAME", "USED", "MAX BYTES", "INODES", "MAX INODES", "EXCEEDED")
+}
+
+func formatQuota(quota *proto.QuotaInfo, rowTable bool) string {
+	var exceeded = make([]string, 0, 2)
+	if quota.BytesExceeded {
+		exceeded = append(exceeded, "bytes")
+	}
+	if quota.InodesExceeded {
+		exceeded = append(exceeded, "inodes")
+	}
+	if rowTable {
+		var name = quota.Name
+		if quota.Kind == proto.QuotaKindDir {
+			name = quota.Name + ":" + quota.Path
+		}
+		return fmt.Sprintf(quotaTableRowPattern, quota.Kind, name, formatSize(quota.UsedBytes), formatQuotaLimit(quota.MaxBytes, true),
+			quota.UsedInodes, formatQuotaLimit(quota.MaxInodes, false), strings.Join(exceeded, ","))
+	}
+	var sb = strings.Builder{}
+	sb.WriteString(fmt.Sprintf("  Kind                : %v\n", quota.Kind))
+	sb.WriteString(fmt.Sprintf("  Name                : %v\n", quota.Name))
+	sb.WriteString(fmt.Sprintf("  Used bytes          : %v\n", formatSize(quota.UsedBytes)))
+	sb.WriteString(fmt.Sprintf("  Max bytes           : %v\n", formatQuotaLimit(quota.MaxBytes, true)))
+	sb.WriteString(fmt.Sprintf("  Used inodes         : %v\n", quota.UsedInodes))
+	sb.WriteString(fmt.Sprintf("  Max inodes          : %v\n", formatQuotaLimit(quota.MaxInodes, false)))
+	sb.WriteString(fmt.Sprintf("  Exceeded            : %v\n", strings.Join(exceeded, ",")))
+	if quota.Kind == proto.QuotaKindOwner {
+		sb.WriteString(fmt.Sprintf("  Volumes             : %v\n", strings.Join(quota.Vols, ",")))
+	}
+	if quota.Kind == proto.QuotaKindDir {
+		sb.WriteString(fmt.Sprintf("  Path                : %v\n", quota.Path))
+	}
+	return sb.String()
+}
+
+func formatQuotaLimit(limit uint64, size bool) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	if size {
+		return formatSize(limit)
+	}
+	return strconv.FormatUint(limit, 10)
+}
+
 var metaNodeDetailTableRowPattern = "%-6v    %-6v    %-18v    %-6v    %-6v    %-6v    %-10v"
 
 func formatMetaNodeDetailTableHeader() string {
@@ -466,6 +589,8 @@ func formatMetaNodeDetail(mn *proto.MetaNodeInfo, rowTable bool) string {
 	sb.WriteString(fmt.Sprintf("  Zone                : %v\n", mn.ZoneName))
 	sb.WriteString(fmt.Sprintf("  IsActive            : %v\n", formatNodeStatus(mn.IsActive)))
 	sb.WriteString(fmt.Sprintf("  Report time         : %v\n", formatTimeToString(mn.ReportTime)))
+	sb.WriteString(fmt.Sprintf("  Version             : %v\n", mn.Version))
+	sb.WriteString(fmt.Sprintf("  Clock skew          : %v\n", mn.ClockSkew))
 	sb.WriteString(fmt.Sprintf("  Partition count     : %v\n", mn.MetaPartitionCount))
 	sb.WriteString(fmt.Sprintf("  Persist partitions  : %v\n", mn.PersistenceMetaPartitions))
 	return sb.String()
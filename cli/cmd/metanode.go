@@ -68,8 +68,7 @@ func newMetaNodeListCmd(client *master.MasterClient) *cobra.Command {
 			sort.SliceStable(view.MetaNodes, func(i, j int) bool {
 				return view.MetaNodes[i].ID < view.MetaNodes[j].ID
 			})
-			stdout("[Meta nodes]\n")
-			stdout("%v\n", formatNodeViewTableHeader())
+			var nodes = make([]proto.NodeView, 0, len(view.MetaNodes))
 			for _, node := range view.MetaNodes {
 				if optFilterStatus != "" &&
 					!strings.Contains(formatNodeStatus(node.Status), optFilterStatus) {
@@ -79,8 +78,15 @@ func newMetaNodeListCmd(client *master.MasterClient) *cobra.Command {
 					!strings.Contains(formatYesNo(node.IsWritable), optFilterWritable) {
 					continue
 				}
-				stdout("%v\n", formatNodeView(&node, true))
+				nodes = append(nodes, node)
 			}
+			output(nodes, func() {
+				stdout("[Meta nodes]\n")
+				stdout("%v\n", formatNodeViewTableHeader())
+				for _, node := range nodes {
+					stdout("%v\n", formatNodeView(&node, true))
+				}
+			})
 		},
 	}
 	cmd.Flags().StringVar(&optFilterWritable, "filter-writable", "", "Filter node writable status")
@@ -106,8 +112,10 @@ func newMetaNodeInfoCmd(client *master.MasterClient) *cobra.Command {
 			if metanodeInfo, err = client.NodeAPI().GetMetaNode(nodeAddr); err != nil {
 				return
 			}
-			stdout("[Meta node info]\n")
-			stdout(formatMetaNodeDetail(metanodeInfo, false))
+			output(metanodeInfo, func() {
+				stdout("[Meta node info]\n")
+				stdout(formatMetaNodeDetail(metanodeInfo, false))
+			})
 
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -120,6 +128,8 @@ func newMetaNodeInfoCmd(client *master.MasterClient) *cobra.Command {
 	return cmd
 }
 func newMetaNodeDecommissionCmd(client *master.MasterClient) *cobra.Command {
+	var optPlan bool
+	var optRate uint64
 	var cmd = &cobra.Command{
 		Use:   CliOpDecommission + " [NODE ADDRESS]",
 		Short: cmdMetaNodeDecommissionInfoShort,
@@ -133,10 +143,21 @@ func newMetaNodeDecommissionCmd(client *master.MasterClient) *cobra.Command {
 				}
 			}()
 			nodeAddr = args[0]
+			if optPlan {
+				var plan *decommissionPlan
+				if plan, err = planMetaNodeDecommission(client, nodeAddr, optRate); err != nil {
+					return
+				}
+				output(plan, func() {
+					stdout("[Decommission plan of meta node]\n")
+					stdout("%s", formatDecommissionPlan(plan))
+				})
+				return
+			}
 			if err = client.NodeAPI().MetaNodeDecommission(nodeAddr); err != nil {
 				return
 			}
-			stdout("Decommission meta node successfully\n")
+			outputMsg("Decommission meta node successfully\n")
 
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -146,5 +167,7 @@ func newMetaNodeDecommissionCmd(client *master.MasterClient) *cobra.Command {
 			return validMetaNodes(client, toComplete), cobra.ShellCompDirectiveNoFileComp
 		},
 	}
+	cmd.Flags().BoolVar(&optPlan, CliFlagPlan, false, "Print the plan and check the capacity of the targets without decommission")
+	cmd.Flags().Uint64Var(&optRate, CliFlagRepairRate, 0, "Replication bytes per second of each target, not limited by default")
 	return cmd
 }
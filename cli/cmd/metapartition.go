@@ -39,19 +39,24 @@ func newMetaPartitionCmd(client *master.MasterClient) *cobra.Command {
 		newMetaPartitionDecommissionCmd(client),
 		newMetaPartitionReplicateCmd(client),
 		newMetaPartitionDeleteReplicaCmd(client),
+		newMetaPartitionAddLearnerCmd(client),
+		newMetaPartitionPromoteLearnerCmd(client),
 	)
 	return cmd
 }
 
 const (
-	cmdMetaPartitionGetShort           = "Display detail information of a meta partition"
-	cmdCheckCorruptMetaPartitionShort  = "Check out corrupt meta partitions"
-	cmdMetaPartitionDecommissionShort  = "Decommission a replication of the meta partition to a new address"
-	cmdMetaPartitionReplicateShort     = "Add a replication of the meta partition on a new address"
-	cmdMetaPartitionDeleteReplicaShort = "Delete a replication of the meta partition on a fixed address"
+	cmdMetaPartitionGetShort            = "Display detail information of a meta partition"
+	cmdCheckCorruptMetaPartitionShort   = "Check out corrupt meta partitions"
+	cmdMetaPartitionDecommissionShort   = "Decommission a replication of the meta partition to a new address"
+	cmdMetaPartitionReplicateShort      = "Add a replication of the meta partition on a new address"
+	cmdMetaPartitionDeleteReplicaShort  = "Delete a replication of the meta partition on a fixed address"
+	cmdMetaPartitionAddLearnerShort     = "Add a non-voting replication of the meta partition on a new address"
+	cmdMetaPartitionPromoteLearnerShort = "Promote a non-voting replication of the meta partition to a voting one"
 )
 
 func newMetaPartitionGetCmd(client *master.MasterClient) *cobra.Command {
+	var optWatch watchOption
 	var cmd = &cobra.Command{
 		Use:   CliOpInfo + " [META PARTITION ID]",
 		Short: cmdMetaPartitionGetShort,
@@ -60,7 +65,6 @@ func newMetaPartitionGetCmd(client *master.MasterClient) *cobra.Command {
 			var (
 				err         error
 				partitionID uint64
-				partition   *proto.MetaPartitionInfo
 			)
 			defer func() {
 				if err != nil {
@@ -70,15 +74,30 @@ func newMetaPartitionGetCmd(client *master.MasterClient) *cobra.Command {
 			if partitionID, err = strconv.ParseUint(args[0], 10, 64); err != nil {
 				return
 			}
-			if partition, err = client.ClientAPI().GetMetaPartition(partitionID); err != nil {
+			err = optWatch.run(cmd, func() (err error) {
+				var partition *proto.MetaPartitionInfo
+				if partition, err = client.ClientAPI().GetMetaPartition(partitionID); err != nil {
+					return
+				}
+				output(partition, func() {
+					stdout(formatMetaPartitionInfo(partition))
+				})
 				return
-			}
-			stdout(formatMetaPartitionInfo(partition))
+			})
 		},
 	}
+	optWatch.addFlags(cmd)
 	return cmd
 }
 
+// metaPartitionCheckOutput is the result of the check of the meta partitions.
+type metaPartitionCheckOutput struct {
+	InactiveMetaNodes         []*proto.MetaNodeInfo      `json:"inactiveMetaNodes"`
+	CorruptMetaPartitions     []*proto.MetaPartitionInfo `json:"corruptMetaPartitions"`
+	LackReplicaMetaPartitions []*proto.MetaPartitionInfo `json:"lackReplicaMetaPartitions"`
+	BadMetaPartitions         []proto.BadPartitionView   `json:"badMetaPartitions"`
+}
+
 func newListCorruptMetaPartitionCmd(client *master.MasterClient) *cobra.Command {
 	var cmd = &cobra.Command{
 		Use:   CliOpCheck,
@@ -92,7 +111,6 @@ the corrupt nodes, the few remaining replicas can not reach an agreement with on
 		Run: func(cmd *cobra.Command, args []string) {
 			var (
 				diagnosis *proto.MetaPartitionDiagnosis
-				metaNodes []*proto.MetaNodeInfo
 				err       error
 			)
 			defer func() {
@@ -103,26 +121,17 @@ the corrupt nodes, the few remaining replicas can not reach an agreement with on
 			if diagnosis, err = client.AdminAPI().DiagnoseMetaPartition(); err != nil {
 				return
 			}
-			stdout("[Inactive Meta nodes]:\n")
-			stdout("%v\n", formatMetaNodeDetailTableHeader())
-			sort.SliceStable(diagnosis.InactiveMetaNodes, func(i, j int) bool {
-				return diagnosis.InactiveMetaNodes[i] < diagnosis.InactiveMetaNodes[j]
-			})
+			var result = &metaPartitionCheckOutput{BadMetaPartitions: diagnosis.BadMetaPartitionIDs}
 			for _, addr := range diagnosis.InactiveMetaNodes {
 				var node *proto.MetaNodeInfo
-				node, err = client.NodeAPI().GetMetaNode(addr)
-				metaNodes = append(metaNodes, node)
+				if node, err = client.NodeAPI().GetMetaNode(addr); err != nil {
+					return
+				}
+				result.InactiveMetaNodes = append(result.InactiveMetaNodes, node)
 			}
-			sort.SliceStable(metaNodes, func(i, j int) bool {
-				return metaNodes[i].ID < metaNodes[j].ID
+			sort.SliceStable(result.InactiveMetaNodes, func(i, j int) bool {
+				return result.InactiveMetaNodes[i].ID < result.InactiveMetaNodes[j].ID
 			})
-			for _, node := range metaNodes {
-				stdout("%v\n", formatMetaNodeDetail(node, true))
-			}
-
-			stdout("\n")
-			stdout("[Corrupt meta partitions](no leader):\n")
-			stdout("%v\n", partitionInfoTableHeader)
 			sort.SliceStable(diagnosis.CorruptMetaPartitionIDs, func(i, j int) bool {
 				return diagnosis.CorruptMetaPartitionIDs[i] < diagnosis.CorruptMetaPartitionIDs[j]
 			})
@@ -132,12 +141,8 @@ the corrupt nodes, the few remaining replicas can not reach an agreement with on
 					err = fmt.Errorf("Partition not found, err:[%v] ", err)
 					return
 				}
-				stdout("%v\n", formatMetaPartitionInfoRow(partition))
+				result.CorruptMetaPartitions = append(result.CorruptMetaPartitions, partition)
 			}
-
-			stdout("\n")
-			stdout("%v\n", "[Meta partition lack replicas]:")
-			stdout("%v\n", partitionInfoTableHeader)
 			sort.SliceStable(diagnosis.LackReplicaMetaPartitionIDs, func(i, j int) bool {
 				return diagnosis.LackReplicaMetaPartitionIDs[i] < diagnosis.LackReplicaMetaPartitionIDs[j]
 			})
@@ -148,22 +153,42 @@ the corrupt nodes, the few remaining replicas can not reach an agreement with on
 					return
 				}
 				if partition != nil {
-					stdout("%v\n", formatMetaPartitionInfoRow(partition))
+					result.LackReplicaMetaPartitions = append(result.LackReplicaMetaPartitions, partition)
 				}
 			}
-
-			stdout("\n")
-			stdout("%v\n", "[Bad meta partitions(decommission not completed)]:")
-			badPartitionTablePattern := "%-8v    %-10v\n"
-			stdout(badPartitionTablePattern, "PATH", "PARTITION ID")
-			for _, bmpv := range diagnosis.BadMetaPartitionIDs {
-				sort.SliceStable(bmpv.PartitionIDs, func(i, j int) bool {
-					return bmpv.PartitionIDs[i] < bmpv.PartitionIDs[j]
+			for _, bpv := range result.BadMetaPartitions {
+				sort.SliceStable(bpv.PartitionIDs, func(i, j int) bool {
+					return bpv.PartitionIDs[i] < bpv.PartitionIDs[j]
 				})
-				for _, pid := range bmpv.PartitionIDs {
-					stdout(badPartitionTablePattern, bmpv.Path, pid)
-				}
 			}
+			output(result, func() {
+				stdout("[Inactive Meta nodes]:\n")
+				stdout("%v\n", formatMetaNodeDetailTableHeader())
+				for _, node := range result.InactiveMetaNodes {
+					stdout("%v\n", formatMetaNodeDetail(node, true))
+				}
+				stdout("\n")
+				stdout("[Corrupt meta partitions](no leader):\n")
+				stdout("%v\n", partitionInfoTableHeader)
+				for _, partition := range result.CorruptMetaPartitions {
+					stdout("%v\n", formatMetaPartitionInfoRow(partition))
+				}
+				stdout("\n")
+				stdout("%v\n", "[Meta partition lack replicas]:")
+				stdout("%v\n", partitionInfoTableHeader)
+				for _, partition := range result.LackReplicaMetaPartitions {
+					stdout("%v\n", formatMetaPartitionInfoRow(partition))
+				}
+				stdout("\n")
+				stdout("%v\n", "[Bad meta partitions(decommission not completed)]:")
+				badPartitionTablePattern := "%-8v    %-10v\n"
+				stdout(badPartitionTablePattern, "PATH", "PARTITION ID")
+				for _, bpv := range result.BadMetaPartitions {
+					for _, pid := range bpv.PartitionIDs {
+						stdout(badPartitionTablePattern, bpv.Path, pid)
+					}
+				}
+			})
 			return
 		},
 	}
@@ -190,6 +215,7 @@ func newMetaPartitionDecommissionCmd(client *master.MasterClient) *cobra.Command
 			if err = client.AdminAPI().DecommissionMetaPartition(partitionID, address); err != nil {
 				return
 			}
+			outputMsg("Decommission meta partition %v on %v successfully\n", partitionID, address)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -221,6 +247,7 @@ func newMetaPartitionReplicateCmd(client *master.MasterClient) *cobra.Command {
 			if err = client.AdminAPI().AddMetaReplica(partitionID, address); err != nil {
 				return
 			}
+			outputMsg("Add replica of meta partition %v on %v successfully\n", partitionID, address)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -255,6 +282,77 @@ func newMetaPartitionDeleteReplicaCmd(client *master.MasterClient) *cobra.Comman
 			if err = client.AdminAPI().DeleteMetaReplica(partitionID, address); err != nil {
 				return
 			}
+			outputMsg("Delete replica of meta partition %v on %v successfully\n", partitionID, address)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validMetaNodes(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	return cmd
+}
+
+func newMetaPartitionAddLearnerCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliOpAddLearner + " [ADDRESS] [META PARTITION ID]",
+		Short: cmdMetaPartitionAddLearnerShort,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err         error
+				partitionID uint64
+			)
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			address := args[0]
+			partitionID, err = strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				return
+			}
+			if err = client.AdminAPI().AddMetaReplicaLearner(partitionID, address); err != nil {
+				return
+			}
+			outputMsg("Add learner of meta partition %v on %v successfully\n", partitionID, address)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validMetaNodes(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	return cmd
+}
+
+func newMetaPartitionPromoteLearnerCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliOpPromoteLearner + " [ADDRESS] [META PARTITION ID]",
+		Short: cmdMetaPartitionPromoteLearnerShort,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err         error
+				partitionID uint64
+			)
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			address := args[0]
+			partitionID, err = strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				return
+			}
+			if err = client.AdminAPI().PromoteMetaReplicaLearner(partitionID, address); err != nil {
+				return
+			}
+			outputMsg("Promote learner of meta partition %v on %v successfully\n", partitionID, address)
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -0,0 +1,86 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// output formats of the commands
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// optOutput is the output format specified by the global --output flag.
+var optOutput = OutputTable
+
+// outputMessage is the structured output of the commands which only report the result.
+type outputMessage struct {
+	Message string `json:"message"`
+}
+
+func validOutput(format string) bool {
+	return format == OutputTable || format == OutputJSON || format == OutputYAML
+}
+
+// output prints the data in JSON or YAML by the output format, or calls table to print the tables.
+// The data of YAML is converted from JSON, so that the fields are named by their JSON tags in both.
+func output(data interface{}, table func()) {
+	if optOutput == OutputTable {
+		table()
+		return
+	}
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		errout("Error: encode output fail: %v\n", err)
+	}
+	if optOutput == OutputYAML {
+		var value interface{}
+		if err = yaml.Unmarshal(encoded, &value); err != nil {
+			errout("Error: encode output fail: %v\n", err)
+		}
+		if encoded, err = yaml.Marshal(value); err != nil {
+			errout("Error: encode output fail: %v\n", err)
+		}
+		_, _ = os.Stdout.Write(encoded)
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "%s\n", encoded)
+}
+
+// outputMsg prints the message reporting the result of a command, which is {"message": ...} in JSON or YAML.
+func outputMsg(format string, a ...interface{}) {
+	var message = fmt.Sprintf(format, a...)
+	output(&outputMessage{Message: strings.TrimSpace(message)}, func() {
+		stdout("%s", message)
+	})
+}
+
+// prompt prints the confirmation to stdout, or to stderr if the output is JSON or YAML,
+// so that the structured output is not broken by the confirmation.
+func prompt(format string, a ...interface{}) {
+	if optOutput == OutputTable {
+		stdout(format, a...)
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, format, a...)
+}
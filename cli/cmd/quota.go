@@ -0,0 +1,176 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdQuotaShort = "Manage the quotas of the volumes, the owners and the directories"
+)
+
+func newQuotaCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   CliResourceQuota,
+		Short: cmdQuotaShort,
+	}
+	cmd.AddCommand(
+		newQuotaListCmd(client),
+		newQuotaInfoCmd(client),
+		newQuotaSetCmd(client),
+	)
+	return cmd
+}
+
+const (
+	cmdQuotaListShort = "List the quotas with their usages"
+	cmdQuotaInfoShort = "Show the quota of a volume, an owner or a directory of a volume"
+	cmdQuotaSetShort  = "Set the limits of the quota of a volume, an owner or a directory of a volume, 0 for no limit"
+)
+
+func newQuotaListCmd(client *master.MasterClient) *cobra.Command {
+	var optKind string
+	var cmd = &cobra.Command{
+		Use:     CliOpList,
+		Short:   cmdQuotaListShort,
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			var quotas []*proto.QuotaInfo
+			if quotas, err = client.AdminAPI().ListQuotas(optKind); err != nil {
+				return
+			}
+			output(quotas, func() {
+				stdout("%v\n", formatQuotaTableHeader())
+				for _, quota := range quotas {
+					stdout("%v\n", formatQuota(quota, true))
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVar(&optKind, CliFlagQuotaKind, "", "Filter the kind of the quotas [vol | owner | dir]")
+	return cmd
+}
+
+func newQuotaInfoCmd(client *master.MasterClient) *cobra.Command {
+	var optPath string
+	var cmd = &cobra.Command{
+		Use:   CliOpInfo + " [KIND] [NAME]",
+		Short: cmdQuotaInfoShort,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var quota *proto.QuotaInfo
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if quota, err = client.AdminAPI().GetQuota(args[0], args[1], optPath); err != nil {
+				return
+			}
+			output(quota, func() {
+				stdout("[Quota info]\n")
+				stdout("%s", formatQuota(quota, false))
+			})
+		},
+	}
+	cmd.Flags().StringVar(&optPath, CliFlagDirPath, "", "Specify the directory of the volume, required by the kind dir")
+	return cmd
+}
+
+func newQuotaSetCmd(client *master.MasterClient) *cobra.Command {
+	var optMaxBytes uint64
+	var optMaxInodes uint64
+	var optPath string
+	var cmd = &cobra.Command{
+		Use:   CliOpSet + " [KIND] [NAME]",
+		Short: cmdQuotaSetShort,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var kind, name = args[0], args[1]
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			var maxBytes, maxInodes, authKey = uint64(0), uint64(0), ""
+			switch kind {
+			case proto.QuotaKindVol:
+				var vv *proto.SimpleVolView
+				if vv, err = client.AdminAPI().GetVolumeSimpleInfo(name); err != nil {
+					return
+				}
+				authKey = calcAuthKey(vv.Owner)
+				fallthrough
+			case proto.QuotaKindOwner:
+				var quota *proto.QuotaInfo
+				if quota, err = client.AdminAPI().GetQuota(kind, name, ""); err == nil {
+					maxBytes, maxInodes = quota.MaxBytes, quota.MaxInodes
+				} else if kind == proto.QuotaKindOwner {
+					// the owner having neither volumes nor quota
+					err = nil
+				} else {
+					return
+				}
+			case proto.QuotaKindDir:
+				if optPath == "" {
+					err = fmt.Errorf("the path of the directory is required by the kind %v", kind)
+					return
+				}
+				var vv *proto.SimpleVolView
+				if vv, err = client.AdminAPI().GetVolumeSimpleInfo(name); err != nil {
+					return
+				}
+				authKey = calcAuthKey(vv.Owner)
+				var quota *proto.QuotaInfo
+				if quota, err = client.AdminAPI().GetQuota(kind, name, optPath); err == nil {
+					maxBytes, maxInodes = quota.MaxBytes, quota.MaxInodes
+				} else {
+					// the directory having no quota yet
+					err = nil
+				}
+			default:
+				err = fmt.Errorf("invalid quota kind [%v]", kind)
+				return
+			}
+			if cmd.Flags().Changed(CliFlagMaxBytes) {
+				maxBytes = optMaxBytes
+			}
+			if cmd.Flags().Changed(CliFlagMaxInodes) {
+				maxInodes = optMaxInodes
+			}
+			if err = client.AdminAPI().SetQuota(kind, name, optPath, maxBytes, maxInodes, authKey); err != nil {
+				return
+			}
+			outputMsg("Quota has been set successfully.\n")
+		},
+	}
+	cmd.Flags().Uint64Var(&optMaxBytes, CliFlagMaxBytes, 0, "Specify the bytes allowed, a multiple of 1GB for a volume")
+	cmd.Flags().Uint64Var(&optMaxInodes, CliFlagMaxInodes, 0, "Specify the inodes allowed")
+	cmd.Flags().StringVar(&optPath, CliFlagDirPath, "", "Specify the directory of the volume, required by the kind dir")
+	return cmd
+}
@@ -40,9 +40,24 @@ func NewRootCmd(client *master.MasterClient) *ChubaoFSCmd {
 			Use:   path.Base(os.Args[0]),
 			Short: cmdRootShort,
 			Args:  cobra.MinimumNArgs(0),
+			PersistentPreRun: func(cmd *cobra.Command, args []string) {
+				if !validOutput(optOutput) {
+					errout("Error: invalid output format %v, expect %v, %v or %v\n", optOutput, OutputTable, OutputJSON, OutputYAML)
+				}
+				// the cluster is selected by ClusterFlags before the commands are built, which is only checked here
+				if optCluster != "" {
+					config, err := LoadConfig()
+					if err == nil {
+						_, err = config.Select(optCluster, optMaster)
+					}
+					if err != nil {
+						errout("Error: %v\n", err)
+					}
+				}
+			},
 			Run: func(cmd *cobra.Command, args []string) {
 				if optShowVersion {
-					stdout(proto.DumpVersion("CLI"))
+					outputMsg("%s", proto.DumpVersion("CLI"))
 					return
 				}
 			},
@@ -50,6 +65,9 @@ func NewRootCmd(client *master.MasterClient) *ChubaoFSCmd {
 	}
 
 	cmd.CFSCmd.Flags().BoolVarP(&optShowVersion, "version", "v", false, "Show version information")
+	cmd.CFSCmd.PersistentFlags().StringVar(&optOutput, "output", OutputTable, "Specify the output format [table | json | yaml]")
+	cmd.CFSCmd.PersistentFlags().StringVar(&optCluster, CliFlagCluster, "", "Specify the cluster name or the master address, the current cluster of the config by default")
+	cmd.CFSCmd.PersistentFlags().StringVar(&optMaster, CliFlagMaster, "", "Specify the master address {HOST}:{PORT}[,{HOST}:{PORT}] to override the one of the cluster")
 
 	cmd.CFSCmd.AddCommand(
 		cmd.newClusterCmd(client),
@@ -57,11 +75,16 @@ func NewRootCmd(client *master.MasterClient) *ChubaoFSCmd {
 		newUserCmd(client),
 		newMetaNodeCmd(client),
 		newDataNodeCmd(client),
+		newFlashNodeCmd(client),
+		newQuotaCmd(client),
 		newDataPartitionCmd(client),
 		newMetaPartitionCmd(client),
 		newConfigCmd(),
 		newCompatibilityCmd(),
 		newZoneCmd(client),
+		newBackupCmd(client),
+		newSlowOpCmd(),
+		newVersionCmd(client),
 	)
 	return cmd
 }
@@ -76,7 +99,12 @@ func errout(format string, a ...interface{}) {
 	OsExitWithLogFlush()
 }
 
+// OsExitWithLogFlush exits the process after flushing the logs, or aborts the current command if it
+// runs in the shell.
 func OsExitWithLogFlush() {
 	log.LogFlush()
+	if interactive {
+		panic(errShellAbort)
+	}
 	os.Exit(1)
 }
@@ -0,0 +1,496 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	cmdShellUse   = "shell"
+	cmdShellShort = "Run the commands interactively with completion and history"
+	cmdShellLong  = `Run the commands interactively. The commands, the flags, the volume names,
+the user IDs, the node addresses and the zone names are completed by the TAB key, the
+names and the addresses are fetched from the master of the current cluster.
+
+Besides the commands of the CLI, the shell accepts:
+  use [CLUSTER | {HOST}:{PORT}[,{HOST}:{PORT}]]   Switch or show the current cluster
+  history                                       Show the command history
+  exit, quit                                    Leave the shell
+
+The clusters are saved by 'config set --cluster [NAME] --addr [ADDRESS]'. The global flags --cluster and
+--master start the shell with the cluster, and select the cluster of a command in the shell.`
+
+	shellHistoryName    = ".cfs-cli_history"
+	shellHistoryLimit   = 1000
+	shellCompletionTTL  = 10 * time.Second
+	shellCompletionWait = 3 // timeout of the requests fetching the names [Unit: s]
+)
+
+var (
+	// interactive tells the commands running in the shell, which must not exit the process on errors.
+	interactive bool
+	// errShellAbort aborts the command running in the shell instead of exiting the process.
+	errShellAbort = errors.New("command aborted")
+
+	placeholderRegexp = regexp.MustCompile(`\[([A-Z ]+)\]`)
+)
+
+// NewShellCmd returns the shell command, which builds the command tree of each line by newRoot,
+// as the flags of the commands keep the values of the previous run.
+func NewShellCmd(newRoot func(cfg *Config) *cobra.Command) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdShellUse,
+		Short: cmdShellShort,
+		Long:  cmdShellLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			if interactive {
+				stdout("Already in the shell.\n")
+				return
+			}
+			var config, selected *Config
+			if config, err = LoadConfig(); err != nil {
+				return
+			}
+			if selected, err = config.Select(optCluster, optMaster); err != nil {
+				return
+			}
+			var s = &shell{config: config, newRoot: newRoot}
+			s.editor = newLineEditor(os.Stdin, os.Stdout, path.Join(defaultHomeDir, shellHistoryName), s.complete)
+			if err = s.switchCluster(selected); err != nil {
+				return
+			}
+			s.run()
+		},
+	}
+	return cmd
+}
+
+type shell struct {
+	config  *Config
+	newRoot func(cfg *Config) *cobra.Command
+	editor  *lineEditor
+
+	current *Config // config of the current cluster
+	client  *master.MasterClient
+
+	cacheLock sync.Mutex
+	cache     map[string]*shellNames
+}
+
+// shellNames are the names of a kind of resource fetched from the master.
+type shellNames struct {
+	names     []string
+	fetchTime time.Time
+}
+
+func (s *shell) run() {
+	interactive = true
+	defer func() {
+		interactive = false
+	}()
+	stdout("Type 'help' for the commands, TAB to complete and 'exit' to leave.\n")
+	for {
+		line, err := s.editor.readLine(s.prompt())
+		if err == errLineInterrupted {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.LogErrorf("shell: read line fail: err(%v)", err)
+			}
+			stdout("\n")
+			return
+		}
+		args, err := splitShellArgs(line)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		s.editor.addHistory(line)
+		switch args[0] {
+		case "exit", "quit":
+			return
+		case "history":
+			for i, history := range s.editor.history {
+				stdout("%5d  %v\n", i+1, history)
+			}
+		case "use":
+			if len(args) == 1 {
+				s.printClusters()
+				continue
+			}
+			if err = s.use(args[1]); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		default:
+			s.execute(args)
+		}
+	}
+}
+
+func (s *shell) prompt() string {
+	return fmt.Sprintf("cfs-cli(%v)> ", s.current.Name)
+}
+
+// execute runs the command of the args on the current cluster, or on the cluster selected by the global flags of the args.
+func (s *shell) execute(args []string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errShellAbort {
+				panic(r)
+			}
+			_, _ = fmt.Fprintln(os.Stderr)
+		}
+	}()
+	var config = s.current
+	if cluster, masterAddr := ClusterFlags(args); cluster != "" || masterAddr != "" {
+		// the named cluster is selected from the config, and the master address alone overrides the current cluster
+		var base, err = s.config, error(nil)
+		if cluster == "" {
+			base = s.current
+		}
+		if config, err = base.Select(cluster, masterAddr); err == nil {
+			err = config.EnableTLS()
+		}
+		// the TLS of the current cluster is restored even if the one of the selected cluster fails
+		defer func() {
+			_ = s.current.EnableTLS()
+		}()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+	}
+	var root = s.newRoot(config)
+	root.SetArgs(args)
+	if err := root.Execute(); err != nil {
+		log.LogErrorf("shell: command fail: args(%v) err(%v)", args, err)
+	}
+}
+
+// use switches the current cluster to the named cluster of the config, or to the master address.
+func (s *shell) use(cluster string) error {
+	// reload the config for the clusters saved in the shell
+	if config, err := LoadConfig(); err == nil {
+		s.config = config
+	}
+	if cluster == "" {
+		cluster = defaultClusterName
+	}
+	selected, err := s.config.Select(cluster, "")
+	if err != nil {
+		return err
+	}
+	return s.switchCluster(selected)
+}
+
+// switchCluster makes the selected cluster current, whose mutual TLS is enabled for the commands and the completion.
+func (s *shell) switchCluster(selected *Config) error {
+	if err := selected.EnableTLS(); err != nil {
+		if s.current != nil {
+			_ = s.current.EnableTLS()
+		}
+		return err
+	}
+	s.current = selected
+	s.client = master.NewMasterClient(selected.MasterAddr, false)
+	s.client.SetTimeout(shellCompletionWait)
+	s.cacheLock.Lock()
+	s.cache = make(map[string]*shellNames)
+	s.cacheLock.Unlock()
+	return nil
+}
+
+func (s *shell) printClusters() {
+	var current = func(name string) string {
+		if name == s.current.Name {
+			return "*"
+		}
+		return " "
+	}
+	stdout("%v %-12v %v\n", current(defaultClusterName), defaultClusterName, s.config.MasterAddr)
+	for _, name := range s.config.clusterNames() {
+		stdout("%v %-12v %v\n", current(name), name, s.config.Clusters[name].MasterAddr)
+	}
+	if _, ok := s.config.Clusters[s.current.Name]; !ok && s.current.Name != defaultClusterName {
+		stdout("* %-12v %v\n", s.current.Name, s.current.MasterAddr)
+	}
+}
+
+// complete returns the candidates of the last word of the line, which are the commands, the flags
+// or the names of the resources expected by the command.
+func (s *shell) complete(line string) (candidates []string) {
+	args, err := splitShellArgs(line)
+	if err != nil {
+		return nil
+	}
+	var word string
+	if len(args) > 0 && !strings.HasSuffix(line, " ") {
+		word, args = args[len(args)-1], args[:len(args)-1]
+	}
+
+	if len(args) > 0 && args[0] == "use" {
+		if len(args) == 1 {
+			candidates = append([]string{defaultClusterName}, s.config.clusterNames()...)
+		}
+		return filterPrefix(candidates, word)
+	}
+
+	var config = *s.current
+	var cmd = s.newRoot(&config)
+	var positional []string
+	var expectValue bool
+	for _, arg := range args {
+		if expectValue {
+			expectValue = false
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			expectValue = flagExpectsValue(cmd, arg)
+			continue
+		}
+		if len(positional) == 0 {
+			if sub := findSubCommand(cmd, arg); sub != nil {
+				cmd = sub
+				continue
+			}
+		}
+		positional = append(positional, arg)
+	}
+	if expectValue {
+		return nil
+	}
+
+	if strings.HasPrefix(word, "-") {
+		var addFlag = func(flag *pflag.Flag) {
+			if !flag.Hidden {
+				candidates = append(candidates, "--"+flag.Name)
+			}
+		}
+		cmd.Flags().VisitAll(addFlag)
+		cmd.InheritedFlags().VisitAll(addFlag)
+		return filterPrefix(candidates, word)
+	}
+	if len(positional) == 0 && cmd.HasAvailableSubCommands() {
+		for _, sub := range cmd.Commands() {
+			if sub.IsAvailableCommand() {
+				candidates = append(candidates, sub.Name())
+			}
+		}
+		if !cmd.HasParent() {
+			candidates = append(candidates, "use", "history", "exit")
+		}
+		return filterPrefix(candidates, word)
+	}
+	var placeholders = placeholderRegexp.FindAllStringSubmatch(cmd.Use, -1)
+	if len(positional) < len(placeholders) {
+		candidates = s.resourceNames(cmd, placeholders[len(positional)][1])
+	}
+	return filterPrefix(candidates, word)
+}
+
+// resourceNames returns the names of the resource described by the placeholder of the usage of the command.
+func (s *shell) resourceNames(cmd *cobra.Command, placeholder string) []string {
+	var resource = cmd
+	for resource.HasParent() && resource.Parent().HasParent() {
+		resource = resource.Parent()
+	}
+	switch placeholder {
+	case "VOLUME", "VOLUME NAME":
+		return s.fetchNames("volume", func() (names []string, err error) {
+			vols, err := s.client.AdminAPI().ListVols("")
+			for _, vol := range vols {
+				names = append(names, vol.Name)
+			}
+			return
+		})
+	case "USER ID":
+		return s.fetchNames("user", func() (names []string, err error) {
+			users, err := s.client.UserAPI().ListUsers("")
+			for _, user := range users {
+				names = append(names, user.UserID)
+			}
+			return
+		})
+	case "NODE ADDRESS", "ADDRESS":
+		switch resource.Name() {
+		case "datanode", CliResourceDataPartition:
+			return s.fetchNodeAddrs("datanode")
+		case CliResourceMetaNode, CliResourceMetaPartition:
+			return s.fetchNodeAddrs(CliResourceMetaNode)
+		}
+	case "NAME":
+		if resource.Name() == "zone" {
+			return s.fetchNames("zone", func() (names []string, err error) {
+				zones, err := s.client.AdminAPI().ListZones()
+				for _, zone := range zones {
+					names = append(names, zone.Name)
+				}
+				return
+			})
+		}
+	}
+	return nil
+}
+
+func (s *shell) fetchNodeAddrs(kind string) []string {
+	return s.fetchNames(kind, func() (names []string, err error) {
+		cv, err := s.client.AdminAPI().GetCluster()
+		if err != nil {
+			return
+		}
+		var nodes = cv.DataNodes
+		if kind == CliResourceMetaNode {
+			nodes = cv.MetaNodes
+		}
+		for _, node := range nodes {
+			names = append(names, node.Addr)
+		}
+		return
+	})
+}
+
+// fetchNames returns the names of the kind cached for a while, which are fetched by fetch if they expire.
+func (s *shell) fetchNames(kind string, fetch func() ([]string, error)) []string {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	if cached, ok := s.cache[kind]; ok && time.Since(cached.fetchTime) < shellCompletionTTL {
+		return cached.names
+	}
+	names, err := fetch()
+	if err != nil {
+		log.LogWarnf("shell: fetch names fail: cluster(%v) kind(%v) err(%v)", s.current.Name, kind, err)
+		return nil
+	}
+	sort.Strings(names)
+	s.cache[kind] = &shellNames{names: names, fetchTime: time.Now()}
+	return names
+}
+
+func findSubCommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == name || sub.HasAlias(name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// flagExpectsValue returns true if the flag of the arg takes the next arg as its value.
+func flagExpectsValue(cmd *cobra.Command, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	var flag *pflag.Flag
+	if strings.HasPrefix(arg, "--") {
+		flag = cmd.Flags().Lookup(arg[2:])
+		if flag == nil {
+			flag = cmd.InheritedFlags().Lookup(arg[2:])
+		}
+	} else if len(arg) == 2 {
+		flag = cmd.Flags().ShorthandLookup(arg[1:])
+		if flag == nil {
+			flag = cmd.InheritedFlags().ShorthandLookup(arg[1:])
+		}
+	}
+	return flag != nil && flag.NoOptDefVal == ""
+}
+
+func filterPrefix(candidates []string, prefix string) (filtered []string) {
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return
+}
+
+// splitShellArgs splits the line into the args by the spaces, except the ones quoted by the single or the double quotes.
+func splitShellArgs(line string) (args []string, err error) {
+	var arg strings.Builder
+	var quote rune
+	var inArg bool
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return
+}
+
+// loadShellHistory returns the last lines of the history file.
+func loadShellHistory(historyPath string) (history []string) {
+	file, err := os.Open(historyPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var scanner = bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > shellHistoryLimit {
+		history = history[len(history)-shellHistoryLimit:]
+	}
+	return
+}
@@ -0,0 +1,292 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// control keys of the line editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// errLineInterrupted is returned by readLine if the line is discarded by Ctrl-C.
+var errLineInterrupted = errors.New("line interrupted")
+
+// lineEditor reads the lines from the terminal in the raw mode, with the emacs key bindings,
+// the history and the completion by the TAB key. It reads the lines without editing if the
+// input is not a terminal.
+type lineEditor struct {
+	in          *os.File
+	reader      *bufio.Reader
+	out         io.Writer
+	historyPath string
+	history     []string
+	complete    func(line string) []string
+
+	prompt  string
+	buf     []rune
+	pos     int
+	histPos int
+	saved   []rune // the line being edited when the history is browsed
+}
+
+func newLineEditor(in *os.File, out io.Writer, historyPath string, complete func(line string) []string) *lineEditor {
+	return &lineEditor{
+		in:          in,
+		reader:      bufio.NewReader(in),
+		out:         out,
+		historyPath: historyPath,
+		history:     loadShellHistory(historyPath),
+		complete:    complete,
+	}
+}
+
+// addHistory appends the line to the history and the history file, except the repeated one.
+func (e *lineEditor) addHistory(line string) {
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > shellHistoryLimit {
+		e.history = e.history[len(e.history)-shellHistoryLimit:]
+	}
+	file, err := os.OpenFile(e.historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	_, _ = file.WriteString(line + "\n")
+	_ = file.Close()
+}
+
+// readLine returns the line read after the prompt, io.EOF if the input ends, or errLineInterrupted.
+func (e *lineEditor) readLine(prompt string) (line string, err error) {
+	restore, err := makeRaw(int(e.in.Fd()))
+	if err != nil {
+		_, _ = fmt.Fprint(e.out, prompt)
+		if line, err = e.reader.ReadString('\n'); err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+
+	e.prompt, e.buf, e.pos, e.histPos, e.saved = prompt, nil, 0, len(e.history), nil
+	e.refresh()
+	for {
+		var r rune
+		if r, _, err = e.reader.ReadRune(); err != nil {
+			return "", err
+		}
+		switch r {
+		case keyEnter, '\n':
+			_, _ = fmt.Fprint(e.out, "\n")
+			return string(e.buf), nil
+		case keyCtrlC:
+			_, _ = fmt.Fprint(e.out, "^C\n")
+			return "", errLineInterrupted
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				return "", io.EOF
+			}
+			e.deleteRunes(e.pos, e.pos+1)
+		case keyTab:
+			e.completeWord()
+		case keyBackspace, keyDelete:
+			e.deleteRunes(e.pos-1, e.pos)
+		case keyCtrlA:
+			e.moveTo(0)
+		case keyCtrlE:
+			e.moveTo(len(e.buf))
+		case keyCtrlB:
+			e.moveTo(e.pos - 1)
+		case keyCtrlF:
+			e.moveTo(e.pos + 1)
+		case keyCtrlK:
+			e.deleteRunes(e.pos, len(e.buf))
+		case keyCtrlU:
+			e.deleteRunes(0, e.pos)
+		case keyCtrlW:
+			var start = e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.deleteRunes(start, e.pos)
+		case keyCtrlL:
+			_, _ = fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			e.refresh()
+		case keyCtrlP:
+			e.browseHistory(-1)
+		case keyCtrlN:
+			e.browseHistory(1)
+		case keyEscape:
+			e.readEscape()
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+	}
+}
+
+// readEscape handles the escape sequences of the arrow, the home, the end and the delete keys.
+func (e *lineEditor) readEscape() {
+	if r, _, err := e.reader.ReadRune(); err != nil || (r != '[' && r != 'O') {
+		return
+	}
+	r, _, err := e.reader.ReadRune()
+	if err != nil {
+		return
+	}
+	switch r {
+	case 'A':
+		e.browseHistory(-1)
+	case 'B':
+		e.browseHistory(1)
+	case 'C':
+		e.moveTo(e.pos + 1)
+	case 'D':
+		e.moveTo(e.pos - 1)
+	case 'H':
+		e.moveTo(0)
+	case 'F':
+		e.moveTo(len(e.buf))
+	case '1', '3', '4', '7', '8':
+		if next, _, err := e.reader.ReadRune(); err != nil || next != '~' {
+			return
+		}
+		switch r {
+		case '1', '7':
+			e.moveTo(0)
+		case '4', '8':
+			e.moveTo(len(e.buf))
+		case '3':
+			e.deleteRunes(e.pos, e.pos+1)
+		}
+	}
+}
+
+func (e *lineEditor) insert(runes []rune) {
+	var buf = make([]rune, 0, len(e.buf)+len(runes))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, runes...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(runes)
+	e.refresh()
+}
+
+func (e *lineEditor) deleteRunes(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(e.buf) {
+		end = len(e.buf)
+	}
+	if start >= end {
+		return
+	}
+	e.buf = append(e.buf[:start], e.buf[end:]...)
+	e.pos = start
+	e.refresh()
+}
+
+func (e *lineEditor) moveTo(pos int) {
+	if pos < 0 || pos > len(e.buf) {
+		return
+	}
+	e.pos = pos
+	e.refresh()
+}
+
+// browseHistory replaces the line by the previous or the next line of the history.
+func (e *lineEditor) browseHistory(step int) {
+	var pos = e.histPos + step
+	if pos < 0 || pos > len(e.history) {
+		return
+	}
+	if e.histPos == len(e.history) {
+		e.saved = e.buf
+	}
+	e.histPos = pos
+	if pos == len(e.history) {
+		e.buf = e.saved
+	} else {
+		e.buf = []rune(e.history[pos])
+	}
+	e.pos = len(e.buf)
+	e.refresh()
+}
+
+// completeWord completes the word before the cursor by the unique candidate or the common prefix
+// of the candidates, and lists the candidates if the word cannot be extended.
+func (e *lineEditor) completeWord() {
+	var head = string(e.buf[:e.pos])
+	var candidates = e.complete(head)
+	if len(candidates) == 0 {
+		return
+	}
+	var word = []rune(head[strings.LastIndex(head, " ")+1:])
+	if len(candidates) == 1 {
+		e.insert([]rune(strings.TrimPrefix(candidates[0], string(word)) + " "))
+		return
+	}
+	var prefix = candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) > len(string(word)) {
+		e.insert([]rune(strings.TrimPrefix(prefix, string(word))))
+		return
+	}
+	_, _ = fmt.Fprintf(e.out, "\n%v\n", strings.Join(candidates, "  "))
+	e.refresh()
+}
+
+// refresh redraws the prompt and the line, and places the cursor.
+func (e *lineEditor) refresh() {
+	var line = fmt.Sprintf("\r%v%v\x1b[K", e.prompt, string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		line += fmt.Sprintf("\x1b[%dD", back)
+	}
+	_, _ = fmt.Fprint(e.out, line)
+}
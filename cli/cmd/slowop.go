@@ -0,0 +1,478 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdSlowOpUse   = CliResourceSlowOp + " [LOG FILE]..."
+	cmdSlowOpShort = "Aggregate the slowest operations of the node logs and the clients"
+	cmdSlowOpLong  = `Aggregate the slow operations in the time window by the volume, the partition, the disk,
+the operation or the host, and list the slowest of them.
+
+The operations are parsed from the log files of the nodes, which may be collected from many hosts
+and gzipped, and queried from the /debug/ops of the clients given by --hosts. A log line is taken as an
+operation if it has the cost, in the form of "cost(120ms)" or "cost[120]" in milliseconds, such as the
+slow IO logged by the data nodes. The volume, the partition and the disk are picked from the fields of
+the line, such as "vol(ltptest)", "partition(12)" and "disk(/data1)".`
+
+	slowOpDefaultSince   = time.Hour
+	slowOpDefaultTop     = 10
+	slowOpClientPath     = "/debug/ops"
+	slowOpClientTimeout  = 10 * time.Second
+	slowOpLogTimeFormat  = "2006/01/02 15:04:05.000000"
+	slowOpJSONTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	slowOpOpTimeFormat   = "2006-01-02 15:04:05.000"
+	slowOpTimeFormat     = "2006-01-02 15:04:05"
+	slowOpMaxLineSize    = 1024 * 1024
+)
+
+// keys the slow operations are grouped by
+const (
+	SlowOpByVolume    = "volume"
+	SlowOpByPartition = "partition"
+	SlowOpByDisk      = "disk"
+	SlowOpByOp        = "op"
+	SlowOpByHost      = "host"
+)
+
+var (
+	slowOpCostPattern      = slowOpFieldPattern("cost")
+	slowOpActionPattern    = slowOpFieldPattern("action")
+	slowOpTypePattern      = slowOpFieldPattern("type", "opcode")
+	slowOpVolumePattern    = slowOpFieldPattern("vol", "volume", "volName")
+	slowOpPartitionPattern = slowOpFieldPattern("partitionID", "partition", "mpID", "dpID")
+	slowOpDiskPattern      = slowOpFieldPattern("disk", "diskPath")
+	slowOpTotalPattern     = slowOpFieldPattern("total")
+)
+
+// slowOpFieldPattern matches the fields logged in the form of "name(value)" or "name[value]", as util/log does,
+// and the fields joined by "_" such as "Req(1)_Partition(12)" of the packets.
+func slowOpFieldPattern(names ...string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:^|[^a-z])(?:` + strings.Join(names, "|") + `)[(\[]([^()\[\]\s,]+)[)\]]`)
+}
+
+func slowOpField(pattern *regexp.Regexp, s string) string {
+	if match := pattern.FindStringSubmatch(s); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// slowOp is an operation with its cost, parsed from a log line or the slow operations of a client.
+type slowOp struct {
+	Time      time.Time     `json:"time"`
+	Host      string        `json:"host"`
+	Op        string        `json:"op"`
+	Volume    string        `json:"volume,omitempty"`
+	Partition string        `json:"partition,omitempty"`
+	Disk      string        `json:"disk,omitempty"`
+	Cost      time.Duration `json:"cost"`
+	Line      string        `json:"line"`
+}
+
+func (op *slowOp) key(by string) string {
+	var key string
+	switch by {
+	case SlowOpByVolume:
+		key = op.Volume
+	case SlowOpByPartition:
+		key = op.Partition
+	case SlowOpByDisk:
+		if op.Disk != "" {
+			key = op.Host + ":" + op.Disk
+		}
+	case SlowOpByOp:
+		key = op.Op
+	case SlowOpByHost:
+		key = op.Host
+	}
+	if key == "" {
+		return "-"
+	}
+	return key
+}
+
+// slowOpGroup is the aggregation of the slow operations of the same key.
+type slowOpGroup struct {
+	Key   string        `json:"key"`
+	Count int           `json:"count"`
+	Total time.Duration `json:"total"`
+	Avg   time.Duration `json:"avg"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// slowOpReport is the result of the analysis, the groups are sorted by the total cost.
+type slowOpReport struct {
+	By      string         `json:"by"`
+	Since   string         `json:"since,omitempty"`
+	Until   string         `json:"until,omitempty"`
+	Sources int            `json:"sources"`
+	Matched int            `json:"matched"`
+	Groups  []*slowOpGroup `json:"groups"`
+	Slowest []*slowOp      `json:"slowest"`
+	Errors  []string       `json:"errors,omitempty"`
+}
+
+// slowOpAnalyzer collects the slow operations in the time window from the log files and the clients.
+type slowOpAnalyzer struct {
+	hosts       []string
+	since       string
+	until       string
+	min         time.Duration
+	by          string
+	top         int
+	concurrency int
+
+	from, to time.Time
+	mu       sync.Mutex
+	ops      []*slowOp
+	errors   []string
+}
+
+func newSlowOpCmd() *cobra.Command {
+	var analyzer = &slowOpAnalyzer{}
+	var cmd = &cobra.Command{
+		Use:   cmdSlowOpUse,
+		Short: cmdSlowOpShort,
+		Long:  cmdSlowOpLong,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			var report, err = analyzer.run(args)
+			if err != nil {
+				errout("Error: %v\n", err)
+			}
+			output(report, func() {
+				stdout("%s", formatSlowOpReport(report))
+			})
+		},
+	}
+	cmd.Flags().StringSliceVar(&analyzer.hosts, CliFlagHosts, nil,
+		"Specify the {HOST}:{PROF PORT} of the clients to query the slow operations of")
+	cmd.Flags().StringVar(&analyzer.since, CliFlagSince, slowOpDefaultSince.String(),
+		"Start of the time window, a duration before now or a time of \"2006-01-02 15:04:05\", empty for no start")
+	cmd.Flags().StringVar(&analyzer.until, CliFlagUntil, "",
+		"End of the time window, a time of \"2006-01-02 15:04:05\", now by default")
+	cmd.Flags().DurationVar(&analyzer.min, CliFlagMinCost, 0, "Skip the operations faster than it")
+	cmd.Flags().StringVar(&analyzer.by, CliFlagGroupBy, SlowOpByPartition,
+		fmt.Sprintf("Group the operations by [%v | %v | %v | %v | %v]",
+			SlowOpByVolume, SlowOpByPartition, SlowOpByDisk, SlowOpByOp, SlowOpByHost))
+	cmd.Flags().IntVar(&analyzer.top, CliFlagTop, slowOpDefaultTop, "Number of the groups and the slowest operations listed")
+	cmd.Flags().IntVar(&analyzer.concurrency, CliFlagConcurrency, defaultDoctorConcurrency,
+		"Number of the log files and the clients read concurrently")
+	return cmd
+}
+
+func parseSlowOpTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.ParseInLocation(slowOpTimeFormat, value, time.Local)
+}
+
+func (a *slowOpAnalyzer) run(files []string) (report *slowOpReport, err error) {
+	switch a.by {
+	case SlowOpByVolume, SlowOpByPartition, SlowOpByDisk, SlowOpByOp, SlowOpByHost:
+	default:
+		return nil, fmt.Errorf("invalid group key %v", a.by)
+	}
+	if len(files) == 0 && len(a.hosts) == 0 {
+		return nil, fmt.Errorf("neither log files nor --%v specified", CliFlagHosts)
+	}
+	if a.concurrency <= 0 {
+		a.concurrency = 1
+	}
+	var now = time.Now()
+	if a.since != "" {
+		if a.from, err = parseSlowOpTime(a.since, now); err != nil {
+			return nil, fmt.Errorf("invalid --%v %v", CliFlagSince, a.since)
+		}
+	}
+	if a.until != "" {
+		if a.to, err = parseSlowOpTime(a.until, now); err != nil {
+			return nil, fmt.Errorf("invalid --%v %v", CliFlagUntil, a.until)
+		}
+	}
+
+	var (
+		wg    sync.WaitGroup
+		limit = make(chan struct{}, a.concurrency)
+	)
+	var collect = func(source string, read func(source string) error) {
+		defer wg.Done()
+		limit <- struct{}{}
+		defer func() { <-limit }()
+		if err := read(source); err != nil {
+			a.mu.Lock()
+			a.errors = append(a.errors, fmt.Sprintf("%v: %v", source, err))
+			a.mu.Unlock()
+		}
+	}
+	for _, file := range files {
+		wg.Add(1)
+		go collect(file, a.readLogFile)
+	}
+	for _, host := range a.hosts {
+		wg.Add(1)
+		go collect(host, a.queryClient)
+	}
+	wg.Wait()
+
+	report = &slowOpReport{
+		By:      a.by,
+		Sources: len(files) + len(a.hosts),
+		Matched: len(a.ops),
+		Groups:  a.aggregate(),
+		Slowest: make([]*slowOp, 0),
+		Errors:  a.errors,
+	}
+	sort.Slice(a.ops, func(i, j int) bool { return a.ops[i].Cost > a.ops[j].Cost })
+	for i := 0; i < len(a.ops) && i < a.top; i++ {
+		report.Slowest = append(report.Slowest, a.ops[i])
+	}
+	sort.Strings(report.Errors)
+	if !a.from.IsZero() {
+		report.Since = a.from.Format(slowOpTimeFormat)
+	}
+	if !a.to.IsZero() {
+		report.Until = a.to.Format(slowOpTimeFormat)
+	}
+	return report, nil
+}
+
+// add keeps the operations in the time window and not faster than the minimal cost.
+func (a *slowOpAnalyzer) add(ops []*slowOp) {
+	var kept = make([]*slowOp, 0, len(ops))
+	for _, op := range ops {
+		if op.Cost < a.min || (!a.from.IsZero() && op.Time.Before(a.from)) || (!a.to.IsZero() && op.Time.After(a.to)) {
+			continue
+		}
+		kept = append(kept, op)
+	}
+	a.mu.Lock()
+	a.ops = append(a.ops, kept...)
+	a.mu.Unlock()
+}
+
+func (a *slowOpAnalyzer) readLogFile(file string) (err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(f); err != nil {
+			return
+		}
+		defer gr.Close()
+		r = gr
+	}
+	var ops []*slowOp
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), slowOpMaxLineSize)
+	for scanner.Scan() {
+		if op := parseSlowOpLogLine(scanner.Text()); op != nil {
+			op.Host = file
+			ops = append(ops, op)
+		}
+	}
+	a.add(ops)
+	return scanner.Err()
+}
+
+func (a *slowOpAnalyzer) queryClient(host string) (err error) {
+	var client = &http.Client{Timeout: slowOpClientTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://%v%v", host, slowOpClientPath))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status(%v) body(%v)", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var ops []*slowOp
+	var volume string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "volume: ") {
+			volume = strings.TrimPrefix(line, "volume: ")
+			continue
+		}
+		if op := parseSlowOpClientLine(line); op != nil {
+			op.Host, op.Volume = host, volume
+			ops = append(ops, op)
+		}
+	}
+	a.add(ops)
+	return
+}
+
+// parseSlowOpLogLine parses the log line of the text or the JSON format, and returns nil if it has no cost.
+func parseSlowOpLogLine(line string) *slowOp {
+	var op = &slowOp{Line: line}
+	var msg = line
+	if strings.HasPrefix(line, "{") {
+		var entry struct {
+			Time string `json:"time"`
+			Msg  string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil
+		}
+		op.Time, _ = time.Parse(slowOpJSONTimeFormat, entry.Time)
+		msg = entry.Msg
+	} else if len(line) > len(slowOpLogTimeFormat) {
+		op.Time, _ = time.ParseInLocation(slowOpLogTimeFormat, line[:len(slowOpLogTimeFormat)], time.Local)
+	}
+	var cost = slowOpCostPattern.FindStringSubmatch(msg)
+	if cost == nil {
+		return nil
+	}
+	if d, err := time.ParseDuration(cost[1]); err == nil {
+		op.Cost = d
+	} else if ms, err := strconv.ParseInt(cost[1], 10, 64); err == nil {
+		op.Cost = time.Duration(ms) * time.Millisecond
+	} else {
+		return nil
+	}
+	op.Op = slowOpField(slowOpActionPattern, msg)
+	if typ := slowOpField(slowOpTypePattern, msg); typ != "" {
+		if op.Op != "" {
+			op.Op += "/"
+		}
+		op.Op += typ
+	}
+	op.Volume = slowOpField(slowOpVolumePattern, msg)
+	op.Partition = slowOpField(slowOpPartitionPattern, msg)
+	op.Disk = slowOpField(slowOpDiskPattern, msg)
+	return op
+}
+
+// parseSlowOpClientLine parses the slow operation listed by the client, such as
+// "2006-01-02 15:04:05.000 read ino(1) total(12ms) meta(1ms) ...", and returns nil for the other lines.
+func parseSlowOpClientLine(line string) *slowOp {
+	if len(line) <= len(slowOpOpTimeFormat) {
+		return nil
+	}
+	t, err := time.ParseInLocation(slowOpOpTimeFormat, line[:len(slowOpOpTimeFormat)], time.Local)
+	if err != nil {
+		return nil
+	}
+	var fields = strings.Fields(line[len(slowOpOpTimeFormat):])
+	cost, err := time.ParseDuration(slowOpField(slowOpTotalPattern, line))
+	if len(fields) == 0 || err != nil {
+		return nil
+	}
+	return &slowOp{Time: t, Op: fields[0], Cost: cost, Line: line}
+}
+
+// aggregate groups the operations by the key, sorted by the total cost, and returns the top ones.
+func (a *slowOpAnalyzer) aggregate() []*slowOpGroup {
+	var costs = make(map[string][]time.Duration)
+	for _, op := range a.ops {
+		var key = op.key(a.by)
+		costs[key] = append(costs[key], op.Cost)
+	}
+	var groups = make([]*slowOpGroup, 0, len(costs))
+	for key, list := range costs {
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+		var group = &slowOpGroup{Key: key, Count: len(list), Max: list[len(list)-1]}
+		for _, cost := range list {
+			group.Total += cost
+		}
+		group.Avg = group.Total / time.Duration(len(list))
+		group.P99 = list[(len(list)*99+99)/100-1]
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Total != groups[j].Total {
+			return groups[i].Total > groups[j].Total
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	if len(groups) > a.top {
+		groups = groups[:a.top]
+	}
+	return groups
+}
+
+var (
+	slowOpGroupTablePattern = "%-32v    %-8v    %-12v    %-12v    %-12v    %-12v\n"
+	slowOpGroupTableHeader  = fmt.Sprintf(slowOpGroupTablePattern, "KEY", "COUNT", "TOTAL", "AVG", "P99", "MAX")
+	slowOpTablePattern      = "%-19v    %-12v    %-20v    %-16v    %-10v    %v\n"
+	slowOpTableHeader       = fmt.Sprintf(slowOpTablePattern, "TIME", "COST", "OP", "VOLUME", "PARTITION", "HOST")
+)
+
+func formatSlowOpDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+func formatSlowOpReport(report *slowOpReport) string {
+	var sb = strings.Builder{}
+	var since, until = report.Since, report.Until
+	if since == "" {
+		since = "-"
+	}
+	if until == "" {
+		until = "now"
+	}
+	sb.WriteString(fmt.Sprintf("[Slow Operations] %v operations from %v sources, window %v ~ %v\n", report.Matched, report.Sources, since, until))
+	sb.WriteString(fmt.Sprintf("\nBy %v:\n", report.By))
+	sb.WriteString(slowOpGroupTableHeader)
+	for _, group := range report.Groups {
+		sb.WriteString(fmt.Sprintf(slowOpGroupTablePattern, group.Key, group.Count, formatSlowOpDuration(group.Total),
+			formatSlowOpDuration(group.Avg), formatSlowOpDuration(group.P99), formatSlowOpDuration(group.Max)))
+	}
+	sb.WriteString("\nSlowest:\n")
+	sb.WriteString(slowOpTableHeader)
+	for _, op := range report.Slowest {
+		var t = "-"
+		if !op.Time.IsZero() {
+			t = op.Time.Format(slowOpTimeFormat)
+		}
+		sb.WriteString(fmt.Sprintf(slowOpTablePattern, t, formatSlowOpDuration(op.Cost), op.key(SlowOpByOp),
+			op.key(SlowOpByVolume), op.key(SlowOpByPartition), op.Host))
+	}
+	if len(report.Errors) > 0 {
+		sb.WriteString("\nErrors:\n")
+		for _, e := range report.Errors {
+			sb.WriteString(fmt.Sprintf("  %v\n", e))
+		}
+	}
+	return sb.String()
+}
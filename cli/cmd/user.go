@@ -91,13 +91,13 @@ func newUserCreateCmd(client *master.MasterClient) *cobra.Command {
 					displaySecretKey = optSecretKey
 				}
 				var displayUserType = userType.String()
-				fmt.Printf("Create a new ChubaoFS cluster user\n")
-				stdout("  User ID   : %v\n", userID)
-				stdout("  Password  : %v\n", displayPassword)
-				stdout("  Access Key: %v\n", displayAccessKey)
-				stdout("  Secret Key: %v\n", displaySecretKey)
-				stdout("  Type      : %v\n", displayUserType)
-				stdout("\nConfirm (yes/no)[yes]: ")
+				prompt("Create a new ChubaoFS cluster user\n")
+				prompt("  User ID   : %v\n", userID)
+				prompt("  Password  : %v\n", displayPassword)
+				prompt("  Access Key: %v\n", displayAccessKey)
+				prompt("  Secret Key: %v\n", displaySecretKey)
+				prompt("  Type      : %v\n", displayUserType)
+				prompt("\nConfirm (yes/no)[yes]: ")
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
 				if userConfirm != "yes" && len(userConfirm) != 0 {
@@ -120,8 +120,10 @@ func newUserCreateCmd(client *master.MasterClient) *cobra.Command {
 			}
 
 			// display operation result
-			stdout("Create user success:\n")
-			printUserInfo(userInfo)
+			output(userInfo, func() {
+				stdout("Create user success:\n")
+				printUserInfo(userInfo)
+			})
 			return
 		},
 	}
@@ -179,12 +181,12 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 				if optUserType != "" {
 					displayUserType = optUserType
 				}
-				fmt.Printf("Update ChubaoFS cluster user\n")
-				stdout("  User ID   : %v\n", userID)
-				stdout("  Access Key: %v\n", displayAccessKey)
-				stdout("  Secret Key: %v\n", displaySecretKey)
-				stdout("  Type      : %v\n", displayUserType)
-				stdout("\nConfirm (yes/no)[yes]: ")
+				prompt("Update ChubaoFS cluster user\n")
+				prompt("  User ID   : %v\n", userID)
+				prompt("  Access Key: %v\n", displayAccessKey)
+				prompt("  Secret Key: %v\n", displaySecretKey)
+				prompt("  Type      : %v\n", displayUserType)
+				prompt("\nConfirm (yes/no)[yes]: ")
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
 				if userConfirm != "yes" && len(userConfirm) != 0 {
@@ -207,8 +209,10 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 				return
 			}
 
-			stdout("Update user success:\n")
-			printUserInfo(userInfo)
+			output(userInfo, func() {
+				stdout("Update user success:\n")
+				printUserInfo(userInfo)
+			})
 			return
 		},
 	}
@@ -240,7 +244,7 @@ func newUserDeleteCmd(client *master.MasterClient) *cobra.Command {
 				}
 			}()
 			if !optYes {
-				stdout("Delete user [%v] (yes/no)[no]:", userID)
+				prompt("Delete user [%v] (yes/no)[no]:", userID)
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
 				if userConfirm != "yes" {
@@ -253,7 +257,7 @@ func newUserDeleteCmd(client *master.MasterClient) *cobra.Command {
 				err = fmt.Errorf("Delete user failed:\n%v\n", err)
 				return
 			}
-			stdout("Delete user success.\n")
+			outputMsg("Delete user success.\n")
 			return
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -291,7 +295,9 @@ func newUserInfoCmd(client *master.MasterClient) *cobra.Command {
 				err = fmt.Errorf("Get user info failed: %v\n", err)
 				return
 			}
-			printUserInfo(userInfo)
+			output(userInfo, func() {
+				printUserInfo(userInfo)
+			})
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -342,14 +348,14 @@ func newUserPermCmd(client *master.MasterClient) *cobra.Command {
 				err = fmt.Errorf("Permission must be on of ro, rw, none ")
 				return
 			}
-			stdout("Setup volume permission\n")
-			stdout("  User ID   : %v\n", userID)
-			stdout("  Volume    : %v\n", volume)
-			stdout("  Subdir    : %v\n", subdir)
-			stdout("  Permission: %v\n", perm.ReadableString())
+			prompt("Setup volume permission\n")
+			prompt("  User ID   : %v\n", userID)
+			prompt("  Volume    : %v\n", volume)
+			prompt("  Subdir    : %v\n", subdir)
+			prompt("  Permission: %v\n", perm.ReadableString())
 
 			// ask user for confirm
-			stdout("\nConfirm (yes/no)[yes]: ")
+			prompt("\nConfirm (yes/no)[yes]: ")
 			var userConfirm string
 			_, _ = fmt.Scanln(&userConfirm)
 			if userConfirm != "yes" && len(userConfirm) != 0 {
@@ -371,7 +377,9 @@ func newUserPermCmd(client *master.MasterClient) *cobra.Command {
 			if err != nil {
 				return
 			}
-			printUserInfo(userInfo)
+			output(userInfo, func() {
+				printUserInfo(userInfo)
+			})
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -405,10 +413,12 @@ func newUserListCmd(client *master.MasterClient) *cobra.Command {
 			if users, err = client.UserAPI().ListUsers(optKeyword); err != nil {
 				return
 			}
-			stdout("%v\n", userInfoTableHeader)
-			for _, user := range users {
-				stdout("%v\n", formatUserInfoTableRow(user))
-			}
+			output(users, func() {
+				stdout("%v\n", userInfoTableHeader)
+				for _, user := range users {
+					stdout("%v\n", formatUserInfoTableRow(user))
+				}
+			})
 		},
 	}
 	cmd.Flags().StringVar(&optKeyword, "keyword", "", "Specify keyword of user name to filter")
@@ -0,0 +1,131 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	sdk "github.com/cubefs/cubefs/sdk/master"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdVersionUse        = CliResourceVersion + " [COMMAND]"
+	cmdVersionShort      = "Check the versions of the cluster"
+	cmdVersionCheckShort = "Check the versions of the nodes against the compatibility matrix"
+	cmdVersionCheckLong  = `Check the versions of the leader master, the meta nodes and the data nodes against the compatibility
+matrix, and list the combinations of the versions which do not work together. The versions of the
+different majors never work together, and the versions of the same major work together if their
+minors differ by one at most and no rule of the matrix forbids them.
+
+The command fails if any combination is incompatible, so that it can guard the steps of an upgrade.`
+)
+
+func newVersionCmd(client *sdk.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdVersionUse,
+		Short: cmdVersionShort,
+		Args:  cobra.MinimumNArgs(0),
+	}
+	cmd.AddCommand(
+		newVersionCheckCmd(client),
+	)
+	return cmd
+}
+
+func newVersionCheckCmd(client *sdk.MasterClient) *cobra.Command {
+	var optAll bool
+	var cmd = &cobra.Command{
+		Use:   CliOpCheck,
+		Short: cmdVersionCheckShort,
+		Long:  cmdVersionCheckLong,
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var view *proto.VersionCheckView
+			if view, err = client.AdminAPI().CheckVersion(); err != nil {
+				return
+			}
+			output(view, func() {
+				stdout("%s", formatVersionCheckView(view, optAll))
+			})
+			if len(view.Conflicts) > 0 {
+				err = fmt.Errorf("%v incompatible combinations of versions found", len(view.Conflicts))
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&optAll, CliFlagAll, false, "List all the nodes, not only the incompatible ones and the ones of unknown versions")
+	return cmd
+}
+
+var (
+	versionTablePattern = "%-10v    %-24v    %-24v    %-10v    %v\n"
+	versionTableHeader  = fmt.Sprintf(versionTablePattern, "ROLE", "ADDRESS", "VERSION", "STATUS", "REASON")
+)
+
+func formatVersionCheckView(view *proto.VersionCheckView, all bool) string {
+	var sb = strings.Builder{}
+	sb.WriteString(fmt.Sprintf("Master version : %v\n", formatVersion(view.MasterVersion)))
+	var versions = make([]string, 0, len(view.Versions))
+	for version := range view.Versions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	sb.WriteString("Versions       :\n")
+	for _, version := range versions {
+		sb.WriteString(fmt.Sprintf("  %-24v %v nodes\n", formatVersion(version), view.Versions[version]))
+	}
+	if len(view.Conflicts) > 0 {
+		sb.WriteString("Conflicts      :\n")
+		for _, conflict := range view.Conflicts {
+			sb.WriteString(fmt.Sprintf("  %v <-> %v: %v\n", formatVersion(conflict.Version), formatVersion(conflict.PeerVersion), conflict.Reason))
+		}
+	}
+	var nodes = make([]*proto.NodeVersion, 0, len(view.Nodes))
+	for _, node := range view.Nodes {
+		if all || !node.Compatible || node.Reason != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		sb.WriteString(fmt.Sprintf("\nAll %v nodes are compatible.\n", len(view.Nodes)))
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("\n%s", versionTableHeader))
+	for _, node := range nodes {
+		var status = "ok"
+		if !node.Compatible {
+			status = "conflict"
+		} else if node.Reason != "" {
+			status = "unknown"
+		}
+		sb.WriteString(fmt.Sprintf(versionTablePattern, node.Role, node.Addr, formatVersion(node.Version), status, node.Reason))
+	}
+	return sb.String()
+}
+
+func formatVersion(version string) string {
+	if version == "" {
+		return "<unknown>"
+	}
+	return version
+}
@@ -49,6 +49,8 @@ func newVolCmd(client *master.MasterClient) *cobra.Command {
 		newVolDeleteCmd(client),
 		newVolTransferCmd(client),
 		newVolAddDPCmd(client),
+		newVolSnapshotCmd(client),
+		newVolDuCmd(client),
 	)
 	return cmd
 }
@@ -74,10 +76,12 @@ func newVolListCmd(client *master.MasterClient) *cobra.Command {
 			if vols, err = client.AdminAPI().ListVols(optKeyword); err != nil {
 				return
 			}
-			stdout("%v\n", volumeInfoTableHeader)
-			for _, vol := range vols {
-				stdout("%v\n", formatVolInfoTableRow(vol))
-			}
+			output(vols, func() {
+				stdout("%v\n", volumeInfoTableHeader)
+				for _, vol := range vols {
+					stdout("%v\n", formatVolInfoTableRow(vol))
+				}
+			})
 		},
 	}
 	cmd.Flags().StringVar(&optKeyword, "keyword", "", "Specify keyword of volume name to filter")
@@ -85,15 +89,18 @@ func newVolListCmd(client *master.MasterClient) *cobra.Command {
 }
 
 const (
-	cmdVolCreateUse             = "create [VOLUME NAME] [USER ID]"
-	cmdVolCreateShort           = "Create a new volume"
-	cmdVolDefaultMPCount        = 3
-	cmdVolDefaultDPSize         = 120
-	cmdVolDefaultCapacity       = 10 // 100GB
-	cmdVolDefaultReplicas       = 3
-	cmdVolDefaultFollowerReader = true
-	cmdVolDefaultZoneName       = ""
-	cmdVolDefaultCrossZone      = false
+	cmdVolCreateUse              = "create [VOLUME NAME] [USER ID]"
+	cmdVolCreateShort            = "Create a new volume"
+	cmdVolDefaultMPCount         = 3
+	cmdVolDefaultDPSize          = 120
+	cmdVolDefaultCapacity        = 10 // 100GB
+	cmdVolDefaultReplicas        = 3
+	cmdVolDefaultFollowerReader  = true
+	cmdVolDefaultZoneName        = ""
+	cmdVolDefaultCrossZone       = false
+	cmdVolDefaultCaseInsensitive = false
+	cmdVolDefaultEncrypted       = false
+	cmdVolDefaultChecksum        = "crc32"
 )
 
 func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
@@ -105,6 +112,9 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 	var optYes bool
 	var optCrossZone bool
 	var optZoneName string
+	var optCaseInsensitive bool
+	var optEncrypted bool
+	var optChecksum string
 	var cmd = &cobra.Command{
 		Use:   cmdVolCreateUse,
 		Short: cmdVolCreateShort,
@@ -120,17 +130,20 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 			}()
 			// ask user for confirm
 			if !optYes {
-				stdout("Create a new volume:\n")
-				stdout("  Name                : %v\n", volumeName)
-				stdout("  Owner               : %v\n", userID)
-				stdout("  Data partition size : %v GB\n", optDPSize)
-				stdout("  Meta partition count: %v\n", optMPCount)
-				stdout("  Capacity            : %v GB\n", optCapacity)
-				stdout("  Replicas            : %v\n", optReplicas)
-				stdout("  Allow follower read : %v\n", formatEnabledDisabled(optFollowerRead))
-				stdout("  ZoneName            : %v\n", optZoneName)
-				stdout("  CrossZone            : %v\n", optCrossZone)
-				stdout("\nConfirm (yes/no)[yes]: ")
+				prompt("Create a new volume:\n")
+				prompt("  Name                : %v\n", volumeName)
+				prompt("  Owner               : %v\n", userID)
+				prompt("  Data partition size : %v GB\n", optDPSize)
+				prompt("  Meta partition count: %v\n", optMPCount)
+				prompt("  Capacity            : %v GB\n", optCapacity)
+				prompt("  Replicas            : %v\n", optReplicas)
+				prompt("  Allow follower read : %v\n", formatEnabledDisabled(optFollowerRead))
+				prompt("  ZoneName            : %v\n", optZoneName)
+				prompt("  CrossZone            : %v\n", optCrossZone)
+				prompt("  Case insensitive    : %v\n", formatEnabledDisabled(optCaseInsensitive))
+				prompt("  Encrypted           : %v\n", formatEnabledDisabled(optEncrypted))
+				prompt("  Checksum            : %v\n", optChecksum)
+				prompt("\nConfirm (yes/no)[yes]: ")
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
 				if userConfirm != "yes" && len(userConfirm) != 0 {
@@ -141,12 +154,12 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 
 			err = client.AdminAPI().CreateVolume(
 				volumeName, userID, optMPCount, optDPSize,
-				optCapacity, optReplicas, optFollowerRead, optZoneName, optCrossZone)
+				optCapacity, optReplicas, optFollowerRead, optZoneName, optCrossZone, optCaseInsensitive, optEncrypted, optChecksum)
 			if err != nil {
 				err = fmt.Errorf("Create volume failed case:\n%v\n", err)
 				return
 			}
-			stdout("Create volume success.\n")
+			outputMsg("Create volume success.\n")
 			return
 		},
 	}
@@ -158,6 +171,9 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().StringVar(&optZoneName, CliFlagZoneName, cmdVolDefaultZoneName, "Specify volume zone name")
 	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
 	cmd.Flags().BoolVar(&optCrossZone, CliFlagCrossZone, cmdVolDefaultCrossZone, "Disable cross zone")
+	cmd.Flags().BoolVar(&optCaseInsensitive, CliFlagCaseInsensitive, cmdVolDefaultCaseInsensitive, "Make file name lookups case-insensitive (case-preserving)")
+	cmd.Flags().BoolVar(&optEncrypted, CliFlagEncrypted, cmdVolDefaultEncrypted, "Encrypt the data of the volume on the data nodes")
+	cmd.Flags().StringVar(&optChecksum, CliFlagChecksum, cmdVolDefaultChecksum, "Specify the checksum of the data, crc32 or crc32c")
 
 	return cmd
 }
@@ -172,6 +188,8 @@ func newVolSetCmd(client *master.MasterClient) *cobra.Command {
 	var optFollowerRead string
 	var optAuthenticate string
 	var optZoneName string
+	var optCompression string
+	var optFlashCache string
 	var optYes bool
 	var confirmString = strings.Builder{}
 	var vv *proto.SimpleVolView
@@ -240,17 +258,33 @@ func newVolSetCmd(client *master.MasterClient) *cobra.Command {
 			if vv.CrossZone == true && "" != optZoneName {
 				err = fmt.Errorf("Can not set zone name of the volume that cross zone\n")
 			}
+			if optCompression != "" {
+				isChange = true
+				confirmString.WriteString(fmt.Sprintf("  Compression         : %v -> %v\n", formatCompression(vv.Compression), optCompression))
+			} else {
+				confirmString.WriteString(fmt.Sprintf("  Compression         : %v\n", formatCompression(vv.Compression)))
+			}
+			var flashCache bool
+			if optFlashCache != "" {
+				isChange = true
+				if flashCache, err = strconv.ParseBool(optFlashCache); err != nil {
+					return
+				}
+				confirmString.WriteString(fmt.Sprintf("  Flash cache         : %v -> %v\n", formatEnabledDisabled(vv.FlashCache), formatEnabledDisabled(flashCache)))
+			} else {
+				confirmString.WriteString(fmt.Sprintf("  Flash cache         : %v\n", formatEnabledDisabled(vv.FlashCache)))
+			}
 			if err != nil {
 				return
 			}
 			if !isChange {
-				stdout("No changes has been set.\n")
+				outputMsg("No changes has been set.\n")
 				return
 			}
 			// ask user for confirm
 			if !optYes {
-				stdout(confirmString.String())
-				stdout("\nConfirm (yes/no)[yes]: ")
+				prompt("%s", confirmString.String())
+				prompt("\nConfirm (yes/no)[yes]: ")
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
 				if userConfirm != "yes" && len(userConfirm) != 0 {
@@ -263,7 +297,17 @@ func newVolSetCmd(client *master.MasterClient) *cobra.Command {
 			if err != nil {
 				return
 			}
-			stdout("Volume configuration has been set successfully.\n")
+			if optCompression != "" {
+				if err = client.AdminAPI().SetVolCompression(vv.Name, optCompression, calcAuthKey(vv.Owner)); err != nil {
+					return
+				}
+			}
+			if optFlashCache != "" {
+				if err = client.AdminAPI().SetVolFlashCache(vv.Name, flashCache, calcAuthKey(vv.Owner)); err != nil {
+					return
+				}
+			}
+			outputMsg("Volume configuration has been set successfully.\n")
 			return
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -278,6 +322,8 @@ func newVolSetCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().StringVar(&optFollowerRead, CliFlagEnableFollowerRead, "", "Enable read form replica follower")
 	cmd.Flags().StringVar(&optAuthenticate, CliFlagAuthenticate, "", "Enable authenticate")
 	cmd.Flags().StringVar(&optZoneName, CliFlagZoneName, "", "Specify volume zone name")
+	cmd.Flags().StringVar(&optCompression, CliFlagCompression, "", "Specify data compression [lz4 | flate | none]")
+	cmd.Flags().StringVar(&optFlashCache, CliFlagFlashCache, "", "Enable reading the data through the flash nodes")
 	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
 	return cmd
 }
@@ -287,10 +333,18 @@ const (
 	cmdVolInfoShort = "Show volume information"
 )
 
+// volInfoOutput is the information of a volume, with the partitions if they are requested.
+type volInfoOutput struct {
+	Summary        *proto.SimpleVolView           `json:"summary"`
+	MetaPartitions []*proto.MetaPartitionView     `json:"metaPartitions,omitempty"`
+	DataPartitions []*proto.DataPartitionResponse `json:"dataPartitions,omitempty"`
+}
+
 func newVolInfoCmd(client *master.MasterClient) *cobra.Command {
 	var (
 		optMetaDetail bool
 		optDataDetail bool
+		optWatch      watchOption
 	)
 
 	var cmd = &cobra.Command{
@@ -300,53 +354,59 @@ func newVolInfoCmd(client *master.MasterClient) *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			var err error
 			var volumeName = args[0]
-			var svv *proto.SimpleVolView
 			defer func() {
 				if err != nil {
 					errout("Error: %v", err)
 				}
 			}()
-			if svv, err = client.AdminAPI().GetVolumeSimpleInfo(volumeName); err != nil {
-				err = fmt.Errorf("Get volume info failed:\n%v\n", err)
-				return
-			}
-			// print summary info
-			stdout("Summary:\n%s\n", formatSimpleVolView(svv))
-
-			// print metadata detail
-			if optMetaDetail {
-				var views []*proto.MetaPartitionView
-				if views, err = client.ClientAPI().GetMetaPartitions(volumeName); err != nil {
-					err = fmt.Errorf("Get volume metadata detail information failed:\n%v\n", err)
-					return
+			err = optWatch.run(cmd, func() (err error) {
+				var svv *proto.SimpleVolView
+				if svv, err = client.AdminAPI().GetVolumeSimpleInfo(volumeName); err != nil {
+					return fmt.Errorf("Get volume info failed:\n%v\n", err)
 				}
-				stdout("Meta partitions:\n")
-				stdout("%v\n", metaPartitionTableHeader)
-				sort.SliceStable(views, func(i, j int) bool {
-					return views[i].PartitionID < views[j].PartitionID
-				})
-				for _, view := range views {
-					stdout("%v\n", formatMetaPartitionTableRow(view))
+				var data = &volInfoOutput{Summary: svv}
+				if optMetaDetail {
+					if data.MetaPartitions, err = client.ClientAPI().GetMetaPartitions(volumeName); err != nil {
+						return fmt.Errorf("Get volume metadata detail information failed:\n%v\n", err)
+					}
+					sort.SliceStable(data.MetaPartitions, func(i, j int) bool {
+						return data.MetaPartitions[i].PartitionID < data.MetaPartitions[j].PartitionID
+					})
 				}
-			}
-
-			// print data detail
-			if optDataDetail {
-				var view *proto.DataPartitionsView
-				if view, err = client.ClientAPI().GetDataPartitions(volumeName); err != nil {
-					err = fmt.Errorf("Get volume data detail information failed:\n%v\n", err)
-					return
+				if optDataDetail {
+					var view *proto.DataPartitionsView
+					if view, err = client.ClientAPI().GetDataPartitions(volumeName); err != nil {
+						return fmt.Errorf("Get volume data detail information failed:\n%v\n", err)
+					}
+					sort.SliceStable(view.DataPartitions, func(i, j int) bool {
+						return view.DataPartitions[i].PartitionID < view.DataPartitions[j].PartitionID
+					})
+					data.DataPartitions = view.DataPartitions
 				}
-				stdout("Data partitions:\n")
-				stdout("%v\n", dataPartitionTableHeader)
-				sort.SliceStable(view.DataPartitions, func(i, j int) bool {
-					return view.DataPartitions[i].PartitionID < view.DataPartitions[j].PartitionID
+				output(data, func() {
+					// print summary info
+					stdout("Summary:\n%s\n", formatSimpleVolView(svv))
+
+					// print metadata detail
+					if optMetaDetail {
+						stdout("Meta partitions:\n")
+						stdout("%v\n", metaPartitionTableHeader)
+						for _, view := range data.MetaPartitions {
+							stdout("%v\n", formatMetaPartitionTableRow(view))
+						}
+					}
+
+					// print data detail
+					if optDataDetail {
+						stdout("Data partitions:\n")
+						stdout("%v\n", dataPartitionTableHeader)
+						for _, dp := range data.DataPartitions {
+							stdout("%v\n", formatDataPartitionTableRow(dp))
+						}
+					}
 				})
-				for _, dp := range view.DataPartitions {
-					stdout("%v\n", formatDataPartitionTableRow(dp))
-				}
-			}
-			return
+				return
+			})
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -357,6 +417,7 @@ func newVolInfoCmd(client *master.MasterClient) *cobra.Command {
 	}
 	cmd.Flags().BoolVarP(&optMetaDetail, "meta-partition", "m", false, "Display meta partition detail information")
 	cmd.Flags().BoolVarP(&optDataDetail, "data-partition", "d", false, "Display data partition detail information")
+	optWatch.addFlags(cmd)
 	return cmd
 }
 
@@ -383,7 +444,7 @@ func newVolDeleteCmd(client *master.MasterClient) *cobra.Command {
 			}()
 			// ask user for confirm
 			if !optYes {
-				stdout("Delete volume [%v] (yes/no)[no]:", volumeName)
+				prompt("Delete volume [%v] (yes/no)[no]:", volumeName)
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
 				if userConfirm != "yes" {
@@ -402,7 +463,7 @@ func newVolDeleteCmd(client *master.MasterClient) *cobra.Command {
 				err = fmt.Errorf("Delete volume failed:\n%v\n", err)
 				return
 			}
-			stdout("Delete volume success.\n")
+			outputMsg("Delete volume success.\n")
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) != 0 {
@@ -441,7 +502,7 @@ func newVolTransferCmd(client *master.MasterClient) *cobra.Command {
 
 			// ask user for confirm
 			if !optYes {
-				stdout("Transfer volume [%v] to user [%v] (yes/no)[no]:", volume, userID)
+				prompt("Transfer volume [%v] to user [%v] (yes/no)[no]:", volume, userID)
 				var confirm string
 				_, _ = fmt.Scanln(&confirm)
 				if confirm != "yes" {
@@ -472,6 +533,7 @@ func newVolTransferCmd(client *master.MasterClient) *cobra.Command {
 			if _, err = client.UserAPI().TransferVol(&param); err != nil {
 				return
 			}
+			outputMsg("Transfer volume %v to user %v success.\n", volume, userInfo.UserID)
 		},
 	}
 	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
@@ -509,6 +571,7 @@ func newVolAddDPCmd(client *master.MasterClient) *cobra.Command {
 			if err = client.AdminAPI().CreateDataPartition(volume, int(count)); err != nil {
 				return
 			}
+			outputMsg("Add %v data partitions to volume %v success.\n", count, volume)
 			return
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -558,6 +621,7 @@ func newVolSetCapacityCmd(use, short string, r clientHandler) *cobra.Command {
 			if err = volume.excuteHttp(); err != nil {
 				return
 			}
+			outputMsg("Set capacity of volume %v to %v GB success.\n", name, volume.capacity)
 			return
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
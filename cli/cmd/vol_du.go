@@ -0,0 +1,251 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdVolDuUse   = CliOpDu + " [VOLUME]"
+	cmdVolDuShort = "Show the usage of the directories of a volume"
+	cmdVolDuLong  = `Show the size, the files and the subdirectories of every directory at the depth below the path,
+summed up from the directory summaries maintained by the clients mounted with enableSummary. Only the
+directories are walked, so it is fast even if the volume holds billions of files, but the files written
+by the clients without enableSummary are not counted.`
+
+	volDuSortSize  = "size"
+	volDuSortFiles = "files"
+	volDuSortName  = "name"
+
+	defaultVolDuDepth       = 1
+	defaultVolDuConcurrency = 16
+)
+
+// volDirUsage is the usage of a directory and all its subdirectories.
+type volDirUsage struct {
+	Path    string `json:"path"`
+	Files   int64  `json:"files"`
+	Subdirs int64  `json:"subdirs"`
+	Bytes   int64  `json:"bytes"`
+}
+
+func (u *volDirUsage) add(info proto.SummaryInfo) {
+	u.Files += info.Files
+	u.Subdirs += info.Subdirs
+	u.Bytes += info.Fbytes
+}
+
+// volDuReport is the usage of the directories at the depth below the path, and the total of the path,
+// which also counts the files of the directories above the depth.
+type volDuReport struct {
+	Volume string         `json:"volume"`
+	Path   string         `json:"path"`
+	Depth  int            `json:"depth"`
+	Total  *volDirUsage   `json:"total"`
+	Dirs   []*volDirUsage `json:"dirs"`
+}
+
+type volDuDir struct {
+	ino  uint64
+	path string
+}
+
+func newVolDuCmd(client *master.MasterClient) *cobra.Command {
+	var (
+		optPath        string
+		optDepth       int
+		optSort        string
+		optTop         int
+		optConcurrency int
+	)
+	var cmd = &cobra.Command{
+		Use:   cmdVolDuUse,
+		Short: cmdVolDuShort,
+		Long:  cmdVolDuLong,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var volName = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if optDepth < 0 {
+				err = fmt.Errorf("Invalid depth %v\n", optDepth)
+				return
+			}
+			if optSort != volDuSortSize && optSort != volDuSortFiles && optSort != volDuSortName {
+				err = fmt.Errorf("Invalid sort %v, expect %v, %v or %v\n", optSort, volDuSortSize, volDuSortFiles, volDuSortName)
+				return
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = meta.NewMetaWrapper(&meta.MetaConfig{
+				Volume:        volName,
+				Masters:       client.Nodes(),
+				EnableSummary: true,
+			}); err != nil {
+				err = fmt.Errorf("Get usage failed:\n%v\n", err)
+				return
+			}
+			defer mw.Close()
+			var report *volDuReport
+			if report, err = volDu(mw, volName, optPath, optDepth, int32(optConcurrency)); err != nil {
+				err = fmt.Errorf("Get usage failed: %v\n", err)
+				return
+			}
+			sortVolDirUsages(report.Dirs, optSort)
+			if optTop > 0 && len(report.Dirs) > optTop {
+				report.Dirs = report.Dirs[:optTop]
+			}
+			output(report, func() {
+				stdout("%v\n", volDirUsageTableHeader)
+				for _, usage := range report.Dirs {
+					stdout("%v\n", formatVolDirUsageTableRow(usage))
+				}
+				stdout("%v\n", formatVolDirUsageTableRow(report.Total))
+			})
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().StringVar(&optPath, CliFlagDirPath, "/", "Specify the directory whose subdirectories are reported")
+	cmd.Flags().IntVar(&optDepth, CliFlagDepth, defaultVolDuDepth, "Depth of the directories reported below the path, 0 reports the path itself")
+	cmd.Flags().StringVar(&optSort, CliFlagSortBy, volDuSortSize,
+		fmt.Sprintf("Sort the directories by [%v | %v | %v]", volDuSortSize, volDuSortFiles, volDuSortName))
+	cmd.Flags().IntVar(&optTop, CliFlagTop, 0, "Number of the directories listed, 0 lists all")
+	cmd.Flags().IntVar(&optConcurrency, CliFlagConcurrency, defaultVolDuConcurrency, "Number of the directories walked concurrently")
+	return cmd
+}
+
+// volDu walks the directories down to the depth below the path, and sums up the summaries of every directory at
+// the depth with its subdirectories. The summaries of the directories above the depth are only added to the total.
+func volDu(mw *meta.MetaWrapper, volName, dirPath string, depth int, concurrency int32) (report *volDuReport, err error) {
+	dirPath = path.Clean("/" + dirPath)
+	var rootIno uint64
+	if rootIno, err = mw.LookupPath(dirPath); err != nil {
+		return nil, fmt.Errorf("lookup path %v: %v", dirPath, err)
+	}
+	var (
+		level = []volDuDir{{ino: rootIno, path: dirPath}}
+		above []uint64
+	)
+	for i := 0; i < depth; i++ {
+		var next []volDuDir
+		for _, dir := range level {
+			above = append(above, dir.ino)
+			var children []proto.Dentry
+			if children, err = mw.ReadDirOnly_ll(dir.ino); err != nil {
+				return nil, fmt.Errorf("read dir %v: %v", dir.path, err)
+			}
+			for _, child := range children {
+				next = append(next, volDuDir{ino: child.Inode, path: path.Join(dir.path, child.Name)})
+			}
+		}
+		level = next
+	}
+
+	report = &volDuReport{
+		Volume: volName,
+		Path:   dirPath,
+		Depth:  depth,
+		Total:  &volDirUsage{Path: "TOTAL"},
+		Dirs:   make([]*volDirUsage, 0, len(level)),
+	}
+	for _, dir := range level {
+		var info proto.SummaryInfo
+		if info, err = mw.GetSummary_ll(dir.ino, dir.path, concurrency); err != nil {
+			return nil, fmt.Errorf("get summary of %v: %v", dir.path, err)
+		}
+		var usage = &volDirUsage{Path: dir.path}
+		usage.add(info)
+		report.Dirs = append(report.Dirs, usage)
+		report.Total.add(info)
+	}
+	// the files and the directories directly under the directories above the depth are only in their own summaries
+	for start := 0; start < len(above); start += meta.BatchSize {
+		var end = start + meta.BatchSize
+		if end > len(above) {
+			end = len(above)
+		}
+		var keys = make([]string, end-start)
+		for i := range keys {
+			keys[i] = proto.SummaryKey
+		}
+		var xattrs []*proto.XAttrInfo
+		if xattrs, err = mw.BatchGetXAttr(above[start:end], keys); err != nil {
+			return nil, fmt.Errorf("get summaries: %v", err)
+		}
+		for _, xattr := range xattrs {
+			report.Total.add(parseSummary(xattr.XAttrs[proto.SummaryKey]))
+		}
+	}
+	return report, nil
+}
+
+// parseSummary parses the summary of a directory, which is "files,subdirs,bytes" of its children.
+func parseSummary(value string) (info proto.SummaryInfo) {
+	var fields = strings.Split(value, ",")
+	if len(fields) != 3 {
+		return
+	}
+	info.Files, _ = strconv.ParseInt(fields[0], 10, 64)
+	info.Subdirs, _ = strconv.ParseInt(fields[1], 10, 64)
+	info.Fbytes, _ = strconv.ParseInt(fields[2], 10, 64)
+	return
+}
+
+func sortVolDirUsages(usages []*volDirUsage, by string) {
+	sort.SliceStable(usages, func(i, j int) bool {
+		switch by {
+		case volDuSortFiles:
+			if usages[i].Files != usages[j].Files {
+				return usages[i].Files > usages[j].Files
+			}
+		case volDuSortSize:
+			if usages[i].Bytes != usages[j].Bytes {
+				return usages[i].Bytes > usages[j].Bytes
+			}
+		}
+		return usages[i].Path < usages[j].Path
+	})
+}
+
+var (
+	volDirUsageTablePattern = "%-12v    %-12v    %-12v    %v"
+	volDirUsageTableHeader  = fmt.Sprintf(volDirUsageTablePattern, "SIZE", "FILES", "SUBDIRS", "PATH")
+)
+
+func formatVolDirUsageTableRow(usage *volDirUsage) string {
+	var size = "-"
+	if usage.Bytes >= 0 {
+		size = formatSize(uint64(usage.Bytes))
+	}
+	return fmt.Sprintf(volDirUsageTablePattern, size, usage.Files, usage.Subdirs, usage.Path)
+}
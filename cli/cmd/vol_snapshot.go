@@ -0,0 +1,380 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdVolSnapshotUse   = CliResourceSnapshot + " [COMMAND]"
+	cmdVolSnapshotShort = "Manage the read-only snapshots of a volume"
+)
+
+func newVolSnapshotCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotUse,
+		Short: cmdVolSnapshotShort,
+		Args:  cobra.MinimumNArgs(0),
+	}
+	cmd.AddCommand(
+		newVolSnapshotCreateCmd(client),
+		newVolSnapshotListCmd(client),
+		newVolSnapshotDeleteCmd(client),
+		newVolSnapshotRestoreCmd(client),
+	)
+	return cmd
+}
+
+// volAuthKey returns the auth key of the volume calculated from its owner.
+func volAuthKey(client *master.MasterClient, volName string) (string, error) {
+	vv, err := client.AdminAPI().GetVolumeSimpleInfo(volName)
+	if err != nil {
+		return "", err
+	}
+	return calcAuthKey(vv.Owner), nil
+}
+
+// findVolSnapshot returns the snapshot of the volume taken by the master, or nil if it is a subtree
+// snapshot taken by the clients.
+func findVolSnapshot(client *master.MasterClient, volName string, id uint64) *proto.VolSnapshotInfo {
+	snaps, err := client.AdminAPI().ListVolSnapshots(volName)
+	if err != nil {
+		return nil
+	}
+	for _, snap := range snaps {
+		if snap.ID == id {
+			return snap
+		}
+	}
+	return nil
+}
+
+// newSnapshotMetaWrapper connects to the meta partitions of the volume, which serve the snapshots.
+func newSnapshotMetaWrapper(client *master.MasterClient, volName string) (*meta.MetaWrapper, error) {
+	return meta.NewMetaWrapper(&meta.MetaConfig{
+		Volume:  volName,
+		Masters: client.Nodes(),
+	})
+}
+
+const (
+	cmdVolSnapshotCreateUse   = CliOpCreate + " [VOLUME] [NAME]"
+	cmdVolSnapshotCreateShort = "Create a snapshot of a directory of the volume"
+)
+
+func newVolSnapshotCreateCmd(client *master.MasterClient) *cobra.Command {
+	var optPath string
+	var optConsistent bool
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotCreateUse,
+		Short: cmdVolSnapshotCreateShort,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var volName, name = args[0], args[1]
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if optConsistent {
+				// the whole volume is snapshotted by the master while its writes are fenced
+				if optPath != "/" {
+					err = fmt.Errorf("Create snapshot failed: a consistent snapshot is of the whole volume\n")
+					return
+				}
+				var authKey string
+				if authKey, err = volAuthKey(client, volName); err != nil {
+					err = fmt.Errorf("Create snapshot failed:\n%v\n", err)
+					return
+				}
+				var snap *proto.VolSnapshotInfo
+				if snap, err = client.AdminAPI().CreateVolSnapshot(volName, name, authKey); err != nil {
+					err = fmt.Errorf("Create snapshot failed:\n%v\n", err)
+					return
+				}
+				output(snap, func() {
+					stdout("Create snapshot success, id: %v, writes fenced for %vms\n", snap.ID, snap.FenceMs)
+				})
+				return
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = newSnapshotMetaWrapper(client, volName); err != nil {
+				err = fmt.Errorf("Create snapshot failed:\n%v\n", err)
+				return
+			}
+			defer mw.Close()
+			var rootIno uint64
+			if rootIno, err = mw.LookupPath(optPath); err != nil {
+				err = fmt.Errorf("Create snapshot failed: lookup path %v: %v\n", optPath, err)
+				return
+			}
+			var info *proto.SnapshotInfo
+			if info, err = mw.CreateSnapshot_ll(name, rootIno); err != nil {
+				err = fmt.Errorf("Create snapshot failed:\n%v\n", err)
+				return
+			}
+			output(info, func() {
+				stdout("Create snapshot success, id: %v\n", info.ID)
+			})
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().StringVar(&optPath, CliFlagSnapshotPath, "/", "Specify the directory to snapshot")
+	cmd.Flags().BoolVar(&optConsistent, CliFlagConsistent, false, "Snapshot the whole volume consistently by the master, fencing the writes meanwhile")
+	return cmd
+}
+
+const (
+	cmdVolSnapshotListUse   = CliOpList + " [VOLUME]"
+	cmdVolSnapshotListShort = "List the snapshots of the volume"
+)
+
+func newVolSnapshotListCmd(client *master.MasterClient) *cobra.Command {
+	var optCatalog bool
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotListUse,
+		Short: cmdVolSnapshotListShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var volName = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			if optCatalog {
+				var snaps []*proto.VolSnapshotInfo
+				if snaps, err = client.AdminAPI().ListVolSnapshots(volName); err != nil {
+					err = fmt.Errorf("List snapshots failed:\n%v\n", err)
+					return
+				}
+				output(snaps, func() {
+					stdout("%v\n", volSnapshotTableHeader)
+					for _, snap := range snaps {
+						stdout("%v\n", formatVolSnapshotTableRow(snap))
+					}
+				})
+				return
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = newSnapshotMetaWrapper(client, volName); err != nil {
+				err = fmt.Errorf("List snapshots failed:\n%v\n", err)
+				return
+			}
+			defer mw.Close()
+			var snapshots []*proto.SnapshotInfo
+			if snapshots, err = mw.ListSnapshots_ll(); err != nil {
+				err = fmt.Errorf("List snapshots failed:\n%v\n", err)
+				return
+			}
+			output(snapshots, func() {
+				stdout("%v\n", snapshotTableHeader)
+				for _, info := range snapshots {
+					stdout("%v\n", formatSnapshotTableRow(info))
+				}
+			})
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().BoolVar(&optCatalog, CliFlagCatalog, false, "List the consistent snapshots of the whole volume kept by the master")
+	return cmd
+}
+
+const (
+	cmdVolSnapshotDeleteUse   = CliOpDelete + " [VOLUME] [SNAPSHOT ID]"
+	cmdVolSnapshotDeleteShort = "Delete a snapshot of the volume"
+)
+
+func newVolSnapshotDeleteCmd(client *master.MasterClient) *cobra.Command {
+	var optYes bool
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotDeleteUse,
+		Short: cmdVolSnapshotDeleteShort,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var volName = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			var snapshotID uint64
+			if snapshotID, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+				return
+			}
+			// ask user for confirm
+			if !optYes {
+				prompt("Delete snapshot [%v] of volume [%v] (yes/no)[no]:", snapshotID, volName)
+				var userConfirm string
+				_, _ = fmt.Scanln(&userConfirm)
+				if userConfirm != "yes" {
+					err = fmt.Errorf("Abort by user.\n")
+					return
+				}
+			}
+			if snap := findVolSnapshot(client, volName, snapshotID); snap != nil {
+				var authKey string
+				if authKey, err = volAuthKey(client, volName); err != nil {
+					err = fmt.Errorf("Delete snapshot failed:\n%v\n", err)
+					return
+				}
+				if err = client.AdminAPI().DeleteVolSnapshot(snapshotID, authKey); err != nil {
+					err = fmt.Errorf("Delete snapshot failed:\n%v\n", err)
+					return
+				}
+				outputMsg("Delete snapshot success.\n")
+				return
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = newSnapshotMetaWrapper(client, volName); err != nil {
+				err = fmt.Errorf("Delete snapshot failed:\n%v\n", err)
+				return
+			}
+			defer mw.Close()
+			if err = mw.DeleteSnapshot_ll(snapshotID); err != nil {
+				err = fmt.Errorf("Delete snapshot failed:\n%v\n", err)
+				return
+			}
+			outputMsg("Delete snapshot success.\n")
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
+	return cmd
+}
+
+const (
+	cmdVolSnapshotRestoreUse   = CliOpRestore + " [VOLUME] [SNAPSHOT ID] [PATH]"
+	cmdVolSnapshotRestoreShort = "Restore a file from a snapshot, or the whole volume to a consistent snapshot"
+)
+
+func newVolSnapshotRestoreCmd(client *master.MasterClient) *cobra.Command {
+	var optYes bool
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotRestoreUse,
+		Short: cmdVolSnapshotRestoreShort,
+		Args:  cobra.RangeArgs(2, 3),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var volName = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v", err)
+				}
+			}()
+			var snapshotID uint64
+			if snapshotID, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+				return
+			}
+			if len(args) == 3 {
+				var mw *meta.MetaWrapper
+				if mw, err = newSnapshotMetaWrapper(client, volName); err != nil {
+					err = fmt.Errorf("Restore file failed:\n%v\n", err)
+					return
+				}
+				defer mw.Close()
+				var info *proto.InodeInfo
+				if info, err = mw.RestoreSnapshotFile_ll(snapshotID, args[2]); err != nil {
+					err = fmt.Errorf("Restore file failed:\n%v\n", err)
+					return
+				}
+				output(info, func() {
+					stdout("Restore file success, inode: %v, size: %v\n", info.Inode, info.Size)
+				})
+				return
+			}
+			// ask user for confirm
+			if !optYes {
+				prompt("Roll back volume [%v] to snapshot [%v], the changes after it are lost (yes/no)[no]:", volName, snapshotID)
+				var userConfirm string
+				_, _ = fmt.Scanln(&userConfirm)
+				if userConfirm != "yes" {
+					err = fmt.Errorf("Abort by user.\n")
+					return
+				}
+			}
+			var authKey string
+			if authKey, err = volAuthKey(client, volName); err != nil {
+				err = fmt.Errorf("Restore volume failed:\n%v\n", err)
+				return
+			}
+			var snap *proto.VolSnapshotInfo
+			if snap, err = client.AdminAPI().RestoreVolSnapshot(snapshotID, authKey); err != nil {
+				err = fmt.Errorf("Restore volume failed:\n%v\n", err)
+				return
+			}
+			output(snap, func() {
+				stdout("Restore volume success, the clients should remount it.\n")
+			})
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
+	return cmd
+}
+
+var (
+	snapshotTablePattern = "%-20v    %-20v    %-20v    %-6v    %-10v    %-10v    %-20v"
+	snapshotTableHeader  = fmt.Sprintf(snapshotTablePattern, "ID", "NAME", "CREATE TIME", "SEALED", "INODES", "DENTRIES", "ROOT")
+)
+
+func formatSnapshotTableRow(info *proto.SnapshotInfo) string {
+	return fmt.Sprintf(snapshotTablePattern, info.ID, info.Name, formatTime(info.CreateTime),
+		formatYesNo(info.Sealed), info.Inodes, info.Dentries, info.RootIno)
+}
+
+var (
+	volSnapshotTablePattern = "%-20v    %-20v    %-10v    %-20v    %-10v    %-8v    %-20v"
+	volSnapshotTableHeader  = fmt.Sprintf(volSnapshotTablePattern, "ID", "NAME", "STATUS", "CREATE TIME", "FENCED(MS)", "MPS", "RESTORE TIME")
+)
+
+func formatVolSnapshotTableRow(snap *proto.VolSnapshotInfo) string {
+	restoreTime := "-"
+	if snap.RestoreTime > 0 {
+		restoreTime = formatTime(snap.RestoreTime)
+	}
+	return fmt.Sprintf(volSnapshotTablePattern, snap.ID, snap.Name, snap.Status, formatTime(snap.CreateTime),
+		snap.FenceMs, len(snap.MetaPartitions), restoreTime)
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	CliFlagWatch         = "watch"
+	CliFlagWatchInterval = "interval"
+
+	defaultWatchInterval = 2 * time.Second
+	minWatchInterval     = 100 * time.Millisecond
+)
+
+// watchOption is the option of the commands which refresh their output until interrupted.
+type watchOption struct {
+	enabled  bool
+	interval time.Duration
+}
+
+func (o *watchOption) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.enabled, CliFlagWatch, false, "Refresh the output in place until interrupted")
+	cmd.Flags().DurationVar(&o.interval, CliFlagWatchInterval, defaultWatchInterval, "Interval to refresh the output with --watch")
+}
+
+// run calls show once, or every interval until interrupted if the watch is enabled. The tables are redrawn in place
+// under a header of the command and the time, and the JSON or YAML documents are printed one after another. The
+// errors of show are printed and the watch goes on, so that it survives the transient failures of the master.
+func (o *watchOption) run(cmd *cobra.Command, show func() error) error {
+	if !o.enabled {
+		return show()
+	}
+	if o.interval < minWatchInterval {
+		return fmt.Errorf("interval should not be less than %v", minWatchInterval)
+	}
+	var sigC = make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigC)
+	var ticker = time.NewTicker(o.interval)
+	defer ticker.Stop()
+	for {
+		switch optOutput {
+		case OutputTable:
+			stdout("\x1b[H\x1b[2J")
+			stdout("Every %v: %v    %v\n\n", o.interval, cmd.CommandPath(), time.Now().Format("2006-01-02 15:04:05"))
+		case OutputYAML:
+			stdout("---\n")
+		}
+		if err := show(); err != nil {
+			stdout("Error: %v\n", err)
+		}
+		select {
+		case <-sigC:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
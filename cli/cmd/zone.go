@@ -60,11 +60,13 @@ func newZoneListCmd(client *sdk.MasterClient) *cobra.Command {
 			if zones, err = client.AdminAPI().ListZones(); err != nil {
 				return
 			}
-			zoneTablePattern := "%-8v    %-10v\n"
-			stdout(zoneTablePattern, "ZONE", "STATUS")
-			for _, zone := range zones {
-				stdout(zoneTablePattern, zone.Name, zone.Status)
-			}
+			output(zones, func() {
+				zoneTablePattern := "%-8v    %-10v\n"
+				stdout(zoneTablePattern, "ZONE", "STATUS")
+				for _, zone := range zones {
+					stdout(zoneTablePattern, zone.Name, zone.Status)
+				}
+			})
 			return
 		},
 	}
@@ -102,7 +104,9 @@ func newZoneInfoCmd(client *sdk.MasterClient) *cobra.Command {
 				err = fmt.Errorf("Zone[%v] not exists in cluster\n ", zoneName)
 				return
 			}
-			stdout(formatZoneView(zoneView))
+			output(zoneView, func() {
+				stdout(formatZoneView(zoneView))
+			})
 			return
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
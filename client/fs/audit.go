@@ -0,0 +1,180 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The audit records are written by a background goroutine, and dropped if it falls behind,
+// so that the operations are never blocked by the audit log.
+const (
+	auditQueueSize     = 65536
+	auditFlushInterval = time.Second
+	auditBatchSize     = 1024
+	auditFileMaxSize   = 1 << 30 // rotated to path.old beyond it
+	auditPostTimeout   = 5 * time.Second
+)
+
+// auditRecord is a line of the audit log in json.
+type auditRecord struct {
+	Time      string `json:"time"`
+	Volume    string `json:"vol"`
+	Op        string `json:"op"`
+	Parent    uint64 `json:"parent,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Ino       uint64 `json:"ino,omitempty"`
+	NewParent uint64 `json:"newParent,omitempty"`
+	NewName   string `json:"newName,omitempty"`
+	Flags     string `json:"flags,omitempty"`
+	Uid       uint32 `json:"uid"`
+	Gid       uint32 `json:"gid"`
+	Pid       uint32 `json:"pid"`
+	Result    string `json:"result"`
+}
+
+// auditLog writes the records of the file operations to a local file, or posts them
+// in batches of json lines to a collector, or both.
+type auditLog struct {
+	volume    string
+	path      string
+	collector string
+	file      *os.File
+	size      int64
+	client    *http.Client
+	recordC   chan *auditRecord
+	dropped   uint64
+}
+
+func newAuditLog(volume, path, collector string) (a *auditLog, err error) {
+	a = &auditLog{
+		volume:    volume,
+		path:      path,
+		collector: collector,
+		client:    &http.Client{Timeout: auditPostTimeout},
+		recordC:   make(chan *auditRecord, auditQueueSize),
+	}
+	if path != "" {
+		if err = a.openFile(); err != nil {
+			return nil, err
+		}
+	}
+	go a.run()
+	return a, nil
+}
+
+func (a *auditLog) openFile() (err error) {
+	if a.file, err = os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+		return
+	}
+	info, err := a.file.Stat()
+	if err != nil {
+		a.file.Close()
+		return
+	}
+	a.size = info.Size()
+	return
+}
+
+// log records an operation of the request with its result, nothing is done if the audit log is disabled.
+func (a *auditLog) log(h *fuse.Header, rec *auditRecord, err error) {
+	if a == nil {
+		return
+	}
+	rec.Time = time.Now().Format("2006-01-02 15:04:05.000")
+	rec.Volume = a.volume
+	rec.Uid, rec.Gid, rec.Pid = h.Uid, h.Gid, h.Pid
+	rec.Result = "ok"
+	if err != nil {
+		rec.Result = syscall.Errno(ParseError(err)).Error()
+	}
+	select {
+	case a.recordC <- rec:
+	default:
+		if atomic.AddUint64(&a.dropped, 1)%auditBatchSize == 1 {
+			log.LogWarnf("auditLog: queue full, %v records dropped", atomic.LoadUint64(&a.dropped))
+		}
+	}
+}
+
+func (a *auditLog) run() {
+	var buf bytes.Buffer
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+	count := 0
+	for {
+		select {
+		case rec := <-a.recordC:
+			data, _ := json.Marshal(rec)
+			buf.Write(data)
+			buf.WriteByte('\n')
+			if count++; count < auditBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if count == 0 {
+				continue
+			}
+		}
+		a.flush(buf.Bytes())
+		buf.Reset()
+		count = 0
+	}
+}
+
+func (a *auditLog) flush(data []byte) {
+	if a.file != nil {
+		if a.size+int64(len(data)) > auditFileMaxSize {
+			a.rotate()
+		}
+		n, err := a.file.Write(data)
+		a.size += int64(n)
+		if err != nil {
+			log.LogWarnf("auditLog: write to %v failed: %v", a.path, err)
+		}
+	}
+	if a.collector != "" {
+		resp, err := a.client.Post(a.collector, "application/x-ndjson", bytes.NewReader(data))
+		if err != nil {
+			log.LogWarnf("auditLog: post to %v failed: %v", a.collector, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.LogWarnf("auditLog: post to %v failed: status %v", a.collector, resp.Status)
+		}
+	}
+}
+
+func (a *auditLog) rotate() {
+	a.file.Close()
+	if err := os.Rename(a.path, a.path+".old"); err != nil {
+		log.LogWarnf("auditLog: rotate %v failed: %v", a.path, err)
+	}
+	if err := a.openFile(); err != nil {
+		log.LogErrorf("auditLog: reopen %v failed: %v", a.path, err)
+		a.file = nil
+	}
+}
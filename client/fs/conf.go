@@ -0,0 +1,206 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// confItem is a tunable of the client which can be changed without remounting.
+// The names are the same as the mount options.
+type confItem struct {
+	name string
+	get  func(s *Super) string
+	set  func(s *Super, val string) (string, error)
+}
+
+var confItems = []confItem{
+	{
+		name: "logLevel",
+		get:  func(s *Super) string { return log.GetLevel() },
+		set: func(s *Super, val string) (string, error) {
+			if err := log.SetLevel(val); err != nil {
+				return "", err
+			}
+			return log.GetLevel(), nil
+		},
+	},
+	{
+		name: "readAheadMax",
+		get:  func(s *Super) string { return s.ec.GetReadAheadMax() },
+		set: func(s *Super, val string) (string, error) {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return "", err
+			}
+			return s.ec.SetReadAheadMax(n), nil
+		},
+	},
+	{
+		name: "icacheTimeout",
+		get:  func(s *Super) string { return s.ic.Expiration().String() },
+		set: func(s *Super, val string) (string, error) {
+			d, err := parseConfSeconds(val)
+			if err != nil {
+				return "", err
+			}
+			s.ic.SetExpiration(d)
+			return d.String(), nil
+		},
+	},
+	{
+		name: "lookupValid",
+		get:  func(s *Super) string { return LookupValidDuration.String() },
+		set: func(s *Super, val string) (string, error) {
+			d, err := parseConfSeconds(val)
+			if err != nil {
+				return "", err
+			}
+			LookupValidDuration = d
+			return d.String(), nil
+		},
+	},
+	{
+		name: "attrValid",
+		get:  func(s *Super) string { return AttrValidDuration.String() },
+		set: func(s *Super, val string) (string, error) {
+			d, err := parseConfSeconds(val)
+			if err != nil {
+				return "", err
+			}
+			AttrValidDuration = d
+			return d.String(), nil
+		},
+	},
+	{
+		name: "writeBackDirtyLimit",
+		get: func(s *Super) string {
+			limit, _, err := s.ec.GetWriteBack()
+			if err != nil {
+				return "disabled"
+			}
+			return fmt.Sprintf("%v", limit)
+		},
+		set: func(s *Super, val string) (string, error) {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return "", err
+			}
+			n, err = s.ec.SetWriteBackDirtyLimit(n)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%v", n), nil
+		},
+	},
+	{
+		name: "writeBackFlushInterval",
+		get: func(s *Super) string {
+			_, interval, err := s.ec.GetWriteBack()
+			if err != nil {
+				return "disabled"
+			}
+			return interval.String()
+		},
+		set: func(s *Super, val string) (string, error) {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return "", err
+			}
+			interval, err := s.ec.SetWriteBackFlushInterval(n)
+			if err != nil {
+				return "", err
+			}
+			return interval.String(), nil
+		},
+	},
+	{
+		name: "memoryLimit",
+		get:  func(s *Super) string { return fmt.Sprintf("%v", s.getMemoryLimit()) },
+		set: func(s *Super, val string) (string, error) {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return "", err
+			}
+			s.setMemoryLimit(n)
+			return fmt.Sprintf("%v", s.getMemoryLimit()), nil
+		},
+	},
+	{
+		name: "slowOpThreshold",
+		get:  func(s *Super) string { return s.slowOps.getThreshold().String() },
+		set: func(s *Super, val string) (string, error) {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return "", err
+			}
+			if n < 0 {
+				return "", fmt.Errorf("negative milliseconds %v", n)
+			}
+			d := time.Duration(n) * time.Millisecond
+			s.slowOps.setThreshold(d)
+			return d.String(), nil
+		},
+	},
+}
+
+func parseConfSeconds(val string) (time.Duration, error) {
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative seconds %v", n)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// GetConf writes the tunables of the client which can be changed by SetConf, and the rate limits.
+func (s *Super) GetConf(w http.ResponseWriter, r *http.Request) {
+	for _, item := range confItems {
+		w.Write([]byte(fmt.Sprintf("%v: %v\n", item.name, item.get(s))))
+	}
+	w.Write([]byte(s.ec.GetRate()))
+}
+
+// SetConf changes the tunables of the client given in the form without remounting.
+// The rate limits are accepted as well, see SetRate.
+func (s *Super) SetConf(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	for _, item := range confItems {
+		val := r.FormValue(item.name)
+		if val == "" {
+			continue
+		}
+		msg, err := item.set(s, val)
+		if err != nil {
+			w.Write([]byte(fmt.Sprintf("Set %v failed: %v\n", item.name, err)))
+			continue
+		}
+		log.LogInfof("SetConf: %v(%v)", item.name, msg)
+		w.Write([]byte(fmt.Sprintf("Set %v to %v successfully\n", item.name, msg)))
+	}
+
+	s.SetRate(w, r)
+}
@@ -28,12 +28,23 @@ type DentryCache struct {
 
 // NewDentryCache returns a new dentry cache.
 func NewDentryCache() *DentryCache {
+	return newDentryCacheWithExpiration(DentryValidDuration)
+}
+
+func newDentryCacheWithExpiration(exp time.Duration) *DentryCache {
 	return &DentryCache{
 		cache:      make(map[string]uint64),
-		expiration: time.Now().Add(DentryValidDuration),
+		expiration: time.Now().Add(exp),
 	}
 }
 
+// Expired returns true if the items in the cache are not valid anymore.
+func (dc *DentryCache) Expired() bool {
+	dc.Lock()
+	defer dc.Unlock()
+	return dc.expiration.Before(time.Now())
+}
+
 // Put puts an item into the cache.
 func (dc *DentryCache) Put(name string, ino uint64) {
 	if dc == nil {
@@ -42,7 +53,9 @@ func (dc *DentryCache) Put(name string, ino uint64) {
 	dc.Lock()
 	defer dc.Unlock()
 	dc.cache[name] = ino
-	dc.expiration = time.Now().Add(DentryValidDuration)
+	if exp := time.Now().Add(DentryValidDuration); exp.After(dc.expiration) {
+		dc.expiration = exp
+	}
 }
 
 // Get gets the item from the cache based on the given key.
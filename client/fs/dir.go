@@ -19,6 +19,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -116,9 +117,12 @@ func NewDir(s *Super, i *proto.InodeInfo) fs.Node {
 }
 
 // Attr set the attributes of a directory.
-func (d *Dir) Attr(ctx context.Context, a *fuse.Attr) error {
+func (d *Dir) Attr(ctx context.Context, a *fuse.Attr) (err error) {
 	ino := d.info.Inode
-	info, err := d.super.InodeGet(ino)
+	tr := d.super.newOpTrace("attr", ino)
+	defer func() { tr.end(err) }()
+
+	info, err := d.super.inodeGet(tr, ino)
 	if err != nil {
 		log.LogErrorf("Attr: ino(%v) err(%v)", ino, err)
 		return ParseError(err)
@@ -139,11 +143,16 @@ func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.Cr
 
 	var err error
 	metric := exporter.NewTPCnt("filecreate")
+	tr := d.super.newOpTrace("create", d.info.Inode)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
+		tr.end(err)
+		d.super.audit.log(&req.Header, &auditRecord{Op: "create", Parent: d.info.Inode, Name: req.Name, Flags: req.Flags.String()}, err)
 	}()
 
+	endMeta := tr.timeMeta()
 	info, err := d.super.mw.Create_ll(d.info.Inode, req.Name, proto.Mode(req.Mode.Perm()), req.Uid, req.Gid, nil)
+	endMeta()
 	if err != nil {
 		log.LogErrorf("Create: parent(%v) req(%v) err(%v)", d.info.Inode, req, err)
 		return nil, nil, ParseError(err)
@@ -169,6 +178,42 @@ func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.Cr
 	return child, child, nil
 }
 
+// Tmpfile handles the request of open with O_TMPFILE. The file is created without a dentry,
+// and deleted when it is evicted unless it is linked into a directory by linkat before.
+func (d *Dir) Tmpfile(ctx context.Context, req *fuse.TmpfileRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	start := time.Now()
+
+	var err error
+	metric := exporter.NewTPCnt("tmpfile")
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
+	}()
+
+	info, err := d.super.mw.TmpfileCreate_ll(proto.Mode(req.Mode.Perm()), req.Uid, req.Gid)
+	if err != nil {
+		log.LogErrorf("Tmpfile: parent(%v) req(%v) err(%v)", d.info.Inode, req, err)
+		return nil, nil, ParseError(err)
+	}
+
+	d.super.ic.Put(info)
+	child := NewFile(d.super, info, d.info.Inode)
+	d.super.ec.OpenStream(info.Inode)
+	d.super.orphan.Put(info.Inode)
+
+	d.super.fslock.Lock()
+	d.super.nodeCache[info.Inode] = child
+	d.super.fslock.Unlock()
+
+	if d.super.keepCache {
+		resp.Flags |= fuse.OpenKeepCache
+	}
+	resp.EntryValid = LookupValidDuration
+
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE Tmpfile: parent(%v) req(%v) resp(%v) ino(%v) (%v)ns", d.info.Inode, req, resp, info.Inode, elapsed.Nanoseconds())
+	return child, child, nil
+}
+
 // Forget is called when the evict is invoked from the kernel.
 func (d *Dir) Forget() {
 	ino := d.info.Inode
@@ -189,11 +234,16 @@ func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error
 
 	var err error
 	metric := exporter.NewTPCnt("mkdir")
+	tr := d.super.newOpTrace("mkdir", d.info.Inode)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
+		tr.end(err)
+		d.super.audit.log(&req.Header, &auditRecord{Op: "mkdir", Parent: d.info.Inode, Name: req.Name}, err)
 	}()
 
+	endMeta := tr.timeMeta()
 	info, err := d.super.mw.Create_ll(d.info.Inode, req.Name, proto.Mode(os.ModeDir|req.Mode.Perm()), req.Uid, req.Gid, nil)
+	endMeta()
 	if err != nil {
 		log.LogErrorf("Mkdir: parent(%v) req(%v) err(%v)", d.info.Inode, req, err)
 		return nil, ParseError(err)
@@ -220,11 +270,20 @@ func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
 
 	var err error
 	metric := exporter.NewTPCnt("remove")
+	tr := d.super.newOpTrace("remove", d.info.Inode)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
+		tr.end(err)
+		op := "unlink"
+		if req.Dir {
+			op = "rmdir"
+		}
+		d.super.audit.log(&req.Header, &auditRecord{Op: op, Parent: d.info.Inode, Name: req.Name}, err)
 	}()
 
+	endMeta := tr.timeMeta()
 	info, err := d.super.mw.Delete_ll(d.info.Inode, req.Name, req.Dir)
+	endMeta()
 	if err != nil {
 		log.LogErrorf("Remove: parent(%v) name(%v) err(%v)", d.info.Inode, req.Name, err)
 		return ParseError(err)
@@ -254,10 +313,25 @@ func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 	)
 
 	log.LogDebugf("TRACE Lookup: parent(%v) req(%v)", d.info.Inode, req)
+	tr := d.super.newOpTrace("lookup", d.info.Inode)
+	defer func() { tr.end(err) }()
 
+	if dcache := d.super.takeWarmDentries(d.info.Inode); dcache != nil {
+		d.dcache = dcache
+	}
 	ino, ok := d.dcache.Get(req.Name)
+	if d.dcache != nil {
+		tr.cache(ok)
+	}
+	if ok {
+		atomic.AddUint64(&d.super.counters.dcacheHits, 1)
+	} else if d.dcache != nil {
+		atomic.AddUint64(&d.super.counters.dcacheMisses, 1)
+	}
 	if !ok {
+		endMeta := tr.timeMeta()
 		ino, _, err = d.super.mw.Lookup_ll(d.info.Inode, req.Name)
+		endMeta()
 		if err != nil {
 			if err != syscall.ENOENT {
 				log.LogErrorf("Lookup: parent(%v) name(%v) err(%v)", d.info.Inode, req.Name, err)
@@ -266,7 +340,7 @@ func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 		}
 	}
 
-	info, err := d.super.InodeGet(ino)
+	info, err := d.super.inodeGet(tr, ino)
 	if err != nil {
 		log.LogErrorf("Lookup: parent(%v) name(%v) ino(%v) err(%v)", d.info.Inode, req.Name, ino, err)
 		dummyInodeInfo := &proto.InodeInfo{Inode: ino}
@@ -295,8 +369,12 @@ func (d *Dir) ReadDir(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Rea
 	var err error
 	var limit uint64 = DefaultReaddirLimit
 	start := time.Now()
+	tr := d.super.newOpTrace("readdir", d.info.Inode)
+	defer func() { tr.end(err) }()
 
 	dirCtx := d.dctx.GetCopy(req.Handle)
+	endMeta := tr.timeMeta()
+	defer endMeta()
 	children, err := d.super.mw.ReadDirLimit_ll(d.info.Inode, dirCtx.Name, limit)
 	if err != nil {
 		log.LogErrorf("readdirlimit: Readdir: ino(%v) err(%v)", d.info.Inode, err)
@@ -354,10 +432,14 @@ func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 
 	var err error
 	metric := exporter.NewTPCnt("readdir")
+	tr := d.super.newOpTrace("readdirall", d.info.Inode)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
+		tr.end(err)
 	}()
 
+	endMeta := tr.timeMeta()
+	defer endMeta()
 	children, err := d.super.mw.ReadDir_ll(d.info.Inode)
 	if err != nil {
 		log.LogErrorf("Readdir: ino(%v) err(%v)", d.info.Inode, err)
@@ -406,11 +488,17 @@ func (d *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Nod
 
 	var err error
 	metric := exporter.NewTPCnt("rename")
+	tr := d.super.newOpTrace("rename", d.info.Inode)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
+		tr.end(err)
+		d.super.audit.log(&req.Header, &auditRecord{Op: "rename", Parent: d.info.Inode, Name: req.OldName,
+			NewParent: dstDir.info.Inode, NewName: req.NewName}, err)
 	}()
 
+	endMeta := tr.timeMeta()
 	err = d.super.mw.Rename_ll(d.info.Inode, req.OldName, dstDir.info.Inode, req.NewName)
+	endMeta()
 	if err != nil {
 		log.LogErrorf("Rename: parent(%v) req(%v) err(%v)", d.info.Inode, req, err)
 		return ParseError(err)
@@ -540,6 +628,8 @@ func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.
 	}
 
 	d.super.ic.Put(info)
+	// a file of O_TMPFILE is not deleted any more once it is linked
+	d.super.orphan.Evict(info.Inode)
 
 	d.super.fslock.Lock()
 	newFile, ok := d.super.nodeCache[info.Inode]
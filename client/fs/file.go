@@ -15,8 +15,14 @@
 package fs
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"bazil.org/fuse"
@@ -48,6 +54,9 @@ var (
 	_ fs.HandleReader      = (*File)(nil)
 	_ fs.HandleWriter      = (*File)(nil)
 	_ fs.HandleFlusher     = (*File)(nil)
+	_ fs.HandleFallocater  = (*File)(nil)
+	_ fs.HandleLocker      = (*File)(nil)
+	_ fs.HandleIoctler     = (*File)(nil)
 	_ fs.NodeFsyncer       = (*File)(nil)
 	_ fs.NodeSetattrer     = (*File)(nil)
 	_ fs.NodeReadlinker    = (*File)(nil)
@@ -63,9 +72,12 @@ func NewFile(s *Super, i *proto.InodeInfo, parentIno uint64) fs.Node {
 }
 
 // Attr sets the attributes of a file.
-func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
+func (f *File) Attr(ctx context.Context, a *fuse.Attr) (err error) {
 	ino := f.info.Inode
-	info, err := f.super.InodeGet(ino)
+	tr := f.super.newOpTrace("attr", ino)
+	defer func() { tr.end(err) }()
+
+	info, err := f.super.inodeGet(tr, ino)
 	if err != nil {
 		log.LogErrorf("Attr: ino(%v) err(%v)", ino, err)
 		if err == fuse.ENOENT {
@@ -121,10 +133,32 @@ func (f *File) Forget() {
 func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (handle fs.Handle, err error) {
 	ino := f.info.Inode
 	start := time.Now()
+	tr := f.super.newOpTrace("open", ino)
+	defer func() {
+		tr.end(err)
+		if req != nil {
+			f.super.audit.log(&req.Header, &auditRecord{Op: "open", Ino: ino, Flags: req.Flags.String()}, err)
+		}
+	}()
+
+	// req is nil if the file is opened by the restore of the fuse context
+	if req != nil && !req.Flags.IsReadOnly() {
+		info, err := f.super.inodeGet(tr, ino)
+		if err != nil {
+			log.LogErrorf("Open: ino(%v) req(%v) err(%v)", ino, req, err)
+			return nil, ParseError(err)
+		}
+		if info.IsImmutable() || (info.IsAppendOnly() && req.Flags&fuse.OpenAppend == 0) {
+			log.LogWarnf("Open: ino(%v) flags(%v) denied by inode flags(%v)", ino, req.Flags, info.Flags)
+			return nil, fuse.EPERM
+		}
+	}
 
+	endData := tr.timeData(ino)
 	f.super.ec.OpenStream(ino)
 
 	f.super.ec.RefreshExtentsCache(ino)
+	endData()
 
 	if f.super.keepCache && resp != nil {
 		resp.Flags |= fuse.OpenKeepCache
@@ -142,6 +176,13 @@ func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error
 
 	start := time.Now()
 
+	if req != nil && req.ReleaseFlags&fuse.ReleaseFlockUnlock != 0 {
+		unlock := &proto.FileLock{End: math.MaxUint64, Type: proto.FileLockUnlock, Owner: req.LockOwner, Flock: true}
+		if err = f.super.mw.SetLock_ll(ino, unlock); err != nil {
+			log.LogErrorf("Release: flock unlock failed, ino(%v) req(%v) err(%v)", ino, req, err)
+		}
+	}
+
 	//log.LogDebugf("TRACE Release close stream: ino(%v) req(%v)", ino, req)
 
 	err = f.super.ec.CloseStream(ino)
@@ -163,11 +204,15 @@ func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 	start := time.Now()
 
 	metric := exporter.NewTPCnt("fileread")
+	tr := f.super.newOpTrace("read", f.info.Inode)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: f.super.volname})
+		tr.end(err)
 	}()
 
+	endData := tr.timeData(f.info.Inode)
 	size, err := f.super.ec.Read(f.info.Inode, resp.Data[fuse.OutHeaderSize:], int(req.Offset), req.Size)
+	endData()
 	if err != nil && err != io.EOF {
 		msg := fmt.Sprintf("Read: ino(%v) req(%v) err(%v) size(%v)", f.info.Inode, req, err, size)
 		f.super.handleError("Read", msg)
@@ -200,7 +245,12 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 
 	log.LogDebugf("TRACE Write enter: ino(%v) offset(%v) len(%v) filesize(%v) flags(%v) fileflags(%v) req(%v)", ino, req.Offset, reqlen, filesize, req.Flags, req.FileFlags, req)
 
-	if req.Offset > int64(filesize) && reqlen == 1 && req.Data[0] == 0 {
+	// The dirty pages written back by the kernel, e.g. of the shared writable mmaps or in the
+	// writecache mode, are written at their offsets regardless of the flags of the handle.
+	// The kernel waits for them and sends fsync for msync and fsync.
+	pageWriteBack := req.Flags&fuse.WriteCache != 0
+
+	if !pageWriteBack && req.Offset > int64(filesize) && reqlen == 1 && req.Data[0] == 0 {
 		// workaround: posix_fallocate would write 1 byte if fallocate is not supported.
 		err = f.super.ec.Truncate(f.super.mw, f.parentIno, ino, int(req.Offset)+reqlen)
 		if err == nil {
@@ -211,6 +261,16 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 		return
 	}
 
+	// handles opened before the file is set immutable or append-only are checked
+	// against the cached inode, the meta nodes reject the new extents and the data
+	// nodes the overwrites of the extents sealed with the flags anyway
+	if info := f.super.ic.Get(ino); info != nil {
+		if info.IsImmutable() || (info.IsAppendOnly() && uint64(req.Offset) < info.Size) {
+			log.LogWarnf("Write: ino(%v) offset(%v) len(%v) denied by inode flags(%v)", ino, req.Offset, reqlen, info.Flags)
+			return fuse.EPERM
+		}
+	}
+
 	defer func() {
 		f.super.ic.Delete(ino)
 	}()
@@ -218,24 +278,34 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 	var waitForFlush bool
 	var flags int
 
-	if isDirectIOEnabled(req.FileFlags) || (req.FileFlags&fuse.OpenSync != 0) {
+	if !pageWriteBack && (isDirectIOEnabled(req.FileFlags) || (req.FileFlags&fuse.OpenSync != 0)) {
 		waitForFlush = true
 		if f.super.enSyncWrite {
 			flags |= proto.FlagsSyncWrite
 		}
 	}
 
-	if req.FileFlags&fuse.OpenAppend != 0 {
+	if !pageWriteBack && req.FileFlags&fuse.OpenAppend != 0 {
 		flags |= proto.FlagsAppend
 	}
 
 	start := time.Now()
 
 	metric := exporter.NewTPCnt("filewrite")
+	tr := f.super.newOpTrace("write", ino)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: f.super.volname})
+		tr.end(err)
 	}()
 
+	endData := tr.timeData(ino)
+	defer endData()
+	if f.super.ec.GetStreamer(ino) == nil {
+		// the pages may be written back after the handles of the file are released
+		log.LogWarnf("Write: stream is not opened, ino(%v) offset(%v) len(%v) flags(%v)", ino, req.Offset, reqlen, req.Flags)
+		f.super.ec.OpenStream(ino)
+		defer f.super.ec.CloseStream(ino)
+	}
 	f.super.ec.GetStreamer(ino).SetParentInode(f.parentIno)
 	size, err := f.super.ec.Write(ino, int(req.Offset), req.Data, flags)
 	if err != nil {
@@ -272,11 +342,15 @@ func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 	start := time.Now()
 
 	metric := exporter.NewTPCnt("filesync")
+	tr := f.super.newOpTrace("flush", f.info.Inode)
 	defer func() {
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: f.super.volname})
+		tr.end(err)
 	}()
 
+	endData := tr.timeData(f.info.Inode)
 	err = f.super.ec.Flush(f.info.Inode)
+	endData()
 	if err != nil {
 		msg := fmt.Sprintf("Flush: ino(%v) err(%v)", f.info.Inode, err)
 		f.super.handleError("Flush", msg)
@@ -292,7 +366,12 @@ func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) (err error) {
 	log.LogDebugf("TRACE Fsync enter: ino(%v)", f.info.Inode)
 	start := time.Now()
+	tr := f.super.newOpTrace("fsync", f.info.Inode)
+	defer func() { tr.end(err) }()
+
+	endData := tr.timeData(f.info.Inode)
 	err = f.super.ec.Flush(f.info.Inode)
+	endData()
 	if err != nil {
 		msg := fmt.Sprintf("Fsync: ino(%v) err(%v)", f.info.Inode, err)
 		f.super.handleError("Fsync", msg)
@@ -304,11 +383,244 @@ func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) (err error) {
 	return nil
 }
 
+// The modes of fallocate(2).
+const (
+	fallocKeepSize  = 0x01
+	fallocPunchHole = 0x02
+)
+
+// Fallocate handles the fallocate request. Preallocating the space (mode 0) and punching
+// holes are supported.
+func (f *File) Fallocate(ctx context.Context, req *fuse.FallocateRequest) (err error) {
+	ino := f.info.Inode
+	log.LogDebugf("TRACE Fallocate enter: ino(%v) offset(%v) len(%v) mode(%v)", ino, req.Offset, req.Length, req.Mode)
+	start := time.Now()
+	if req.Mode == 0 {
+		if err = f.super.ec.Preallocate(ino, int(req.Offset), int(req.Length)); err != nil {
+			log.LogErrorf("Fallocate: preallocate ino(%v) offset(%v) len(%v) err(%v)", ino, req.Offset, req.Length, err)
+			return ParseError(err)
+		}
+		f.super.ic.Delete(ino)
+		elapsed := time.Since(start)
+		log.LogDebugf("TRACE Fallocate: preallocate ino(%v) offset(%v) len(%v) (%v)ns", ino, req.Offset, req.Length, elapsed.Nanoseconds())
+		return nil
+	}
+	if req.Mode != fallocPunchHole|fallocKeepSize {
+		return fuse.ENOTSUP
+	}
+	if err = f.super.ec.PunchHole(ino, int(req.Offset), int(req.Length)); err != nil {
+		msg := fmt.Sprintf("Fallocate: punch hole ino(%v) offset(%v) len(%v) err(%v)", ino, req.Offset, req.Length, err)
+		f.super.handleError("Fallocate", msg)
+		return ParseError(err)
+	}
+	f.super.ic.Delete(ino)
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE Fallocate: ino(%v) offset(%v) len(%v) (%v)ns", ino, req.Offset, req.Length, elapsed.Nanoseconds())
+	return nil
+}
+
+// The ioctls of chattr(1) and lsattr(1) and the flags supported by them.
+const (
+	fsIocGetFlags   = 0x80086601
+	fsIocSetFlags   = 0x40086602
+	fsIocGetFlags32 = 0x80046601
+	fsIocSetFlags32 = 0x40046602
+
+	fsImmutableFl = 0x00000010
+	fsAppendFl    = 0x00000020
+
+	capLinuxImmutable = 9
+)
+
+// Ioctl handles FS_IOC_GETFLAGS and FS_IOC_SETFLAGS, so that the immutable and
+// append-only flags of the files can be listed and set by lsattr and chattr.
+func (f *File) Ioctl(ctx context.Context, req *fuse.IoctlRequest, resp *fuse.IoctlResponse) (err error) {
+	ino := f.info.Inode
+	log.LogDebugf("TRACE Ioctl enter: ino(%v) cmd(%x) req(%v)", ino, req.Cmd, req)
+
+	switch req.Cmd {
+	case fsIocGetFlags, fsIocGetFlags32:
+		info, err := f.super.InodeGet(ino)
+		if err != nil {
+			return err
+		}
+		resp.Data = make([]byte, req.OutSize)
+		if len(resp.Data) < 4 {
+			return fuse.Errno(syscall.EINVAL)
+		}
+		binary.LittleEndian.PutUint32(resp.Data, inodeFlagsToFsFlags(info.Flags))
+		return nil
+	case fsIocSetFlags, fsIocSetFlags32:
+	default:
+		return fuse.ENOTTY
+	}
+
+	if len(req.Data) < 4 {
+		return fuse.Errno(syscall.EINVAL)
+	}
+	fsFlags := binary.LittleEndian.Uint32(req.Data)
+	if fsFlags&^(fsImmutableFl|fsAppendFl) != 0 {
+		log.LogWarnf("Ioctl: ino(%v) unsupported flags(%x)", ino, fsFlags)
+		return fuse.ENOTSUP
+	}
+	info, err := f.super.InodeGet(ino)
+	if err != nil {
+		return err
+	}
+	flags := fsFlagsToInodeFlags(fsFlags)
+	if flags == info.Flags&proto.InodeUserFlagsMask {
+		return nil
+	}
+	if req.Uid != 0 && !hasCapability(req.Pid, capLinuxImmutable) {
+		log.LogWarnf("Ioctl: ino(%v) uid(%v) pid(%v) not permitted to set flags(%x)", ino, req.Uid, req.Pid, fsFlags)
+		return fuse.EPERM
+	}
+	if err = f.super.mw.SetFlags_ll(ino, flags); err != nil {
+		log.LogErrorf("Ioctl: set flags ino(%v) flags(%v) err(%v)", ino, flags, err)
+		return ParseError(err)
+	}
+	f.super.ic.Delete(ino)
+	log.LogDebugf("TRACE Ioctl: ino(%v) flags(%v)", ino, flags)
+	return nil
+}
+
+func inodeFlagsToFsFlags(flags uint32) (fsFlags uint32) {
+	if flags&proto.FlagImmutable != 0 {
+		fsFlags |= fsImmutableFl
+	}
+	if flags&proto.FlagAppendOnly != 0 {
+		fsFlags |= fsAppendFl
+	}
+	return
+}
+
+func fsFlagsToInodeFlags(fsFlags uint32) (flags uint32) {
+	if fsFlags&fsImmutableFl != 0 {
+		flags |= proto.FlagImmutable
+	}
+	if fsFlags&fsAppendFl != 0 {
+		flags |= proto.FlagAppendOnly
+	}
+	return
+}
+
+// hasCapability returns if the effective capabilities of the process include cap.
+func hasCapability(pid uint32, cap uint) bool {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		return err == nil && caps&(1<<cap) != 0
+	}
+	return false
+}
+
+// The intervals to retry a blocking lock request.
+const (
+	lockRetryMinInterval = 10 * time.Millisecond
+	lockRetryMaxInterval = time.Second
+)
+
+func newFileLock(lk fuse.FileLock, owner uint64, flags fuse.LockFlags) (*proto.FileLock, error) {
+	lock := &proto.FileLock{
+		Start: lk.Start,
+		End:   lk.End,
+		Pid:   lk.Pid,
+		Owner: owner,
+		Flock: flags&fuse.LockFlock != 0,
+	}
+	switch lk.Type {
+	case syscall.F_RDLCK:
+		lock.Type = proto.FileLockRead
+	case syscall.F_WRLCK:
+		lock.Type = proto.FileLockWrite
+	case syscall.F_UNLCK:
+		lock.Type = proto.FileLockUnlock
+	default:
+		return nil, fuse.Errno(syscall.EINVAL)
+	}
+	return lock, nil
+}
+
+// Lock handles the setlk and setlkw requests. The locks are held by the meta nodes,
+// a blocking request polls them until the lock is acquired or the request is interrupted.
+func (f *File) Lock(ctx context.Context, req *fuse.LockRequest) (err error) {
+	ino := f.info.Inode
+	log.LogDebugf("TRACE Lock enter: ino(%v) req(%v)", ino, req)
+	lock, err := newFileLock(req.Lock, req.LockOwner, req.LockFlags)
+	if err != nil {
+		return
+	}
+	start := time.Now()
+	wait := lockRetryMinInterval
+	for {
+		err = f.super.mw.SetLock_ll(ino, lock)
+		if err != syscall.EAGAIN || !req.Wait {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fuse.EINTR
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > lockRetryMaxInterval {
+			wait = lockRetryMaxInterval
+		}
+	}
+	if err != nil {
+		log.LogDebugf("Lock: ino(%v) req(%v) err(%v)", ino, req, err)
+		return ParseError(err)
+	}
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE Lock: ino(%v) req(%v) (%v)ns", ino, req, elapsed.Nanoseconds())
+	return nil
+}
+
+// QueryLock handles the getlk request.
+func (f *File) QueryLock(ctx context.Context, req *fuse.QueryLockRequest, resp *fuse.QueryLockResponse) (err error) {
+	ino := f.info.Inode
+	log.LogDebugf("TRACE QueryLock enter: ino(%v) req(%v)", ino, req)
+	lock, err := newFileLock(req.Lock, req.LockOwner, req.LockFlags)
+	if err != nil {
+		return
+	}
+	conflict, err := f.super.mw.GetLock_ll(ino, lock)
+	if err != nil {
+		log.LogErrorf("QueryLock: ino(%v) req(%v) err(%v)", ino, req, err)
+		return ParseError(err)
+	}
+	resp.Lock = fuse.FileLock{Type: syscall.F_UNLCK}
+	if conflict.Type == proto.FileLockUnlock {
+		return nil
+	}
+	resp.Lock.Start = conflict.Start
+	resp.Lock.End = conflict.End
+	resp.Lock.Type = syscall.F_RDLCK
+	if conflict.Type == proto.FileLockWrite {
+		resp.Lock.Type = syscall.F_WRLCK
+	}
+	if conflict.Session == lock.Session {
+		// the pid of a process on another host means nothing here
+		resp.Lock.Pid = conflict.Pid
+	}
+	return nil
+}
+
 // Setattr handles the setattr request.
-func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) (err error) {
 	ino := f.info.Inode
 	start := time.Now()
+	tr := f.super.newOpTrace("setattr", ino)
+	defer func() { tr.end(err) }()
+
 	if req.Valid.Size() {
+		endData := tr.timeData(ino)
+		defer endData()
 		if err := f.super.ec.Flush(ino); err != nil {
 			log.LogErrorf("Setattr: truncate wait for flush ino(%v) size(%v) err(%v)", ino, req.Size, err)
 			return ParseError(err)
@@ -321,7 +633,7 @@ func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse
 		f.super.ec.RefreshExtentsCache(ino)
 	}
 
-	info, err := f.super.InodeGet(ino)
+	info, err := f.super.inodeGet(tr, ino)
 	if err != nil {
 		log.LogErrorf("Setattr: InodeGet failed, ino(%v) err(%v)", ino, err)
 		return ParseError(err)
@@ -334,8 +646,10 @@ func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse
 	}
 
 	if valid := setattr(info, req); valid != 0 {
+		endMeta := tr.timeMeta()
 		err = f.super.mw.Setattr(ino, valid, info.Mode, info.Uid, info.Gid, info.AccessTime.Unix(),
 			info.ModifyTime.Unix())
+		endMeta()
 		if err != nil {
 			f.super.ic.Delete(ino)
 			return ParseError(err)
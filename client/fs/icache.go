@@ -55,6 +55,11 @@ func NewInodeCache(exp time.Duration, maxElements int) *InodeCache {
 
 // Put puts the given inode info into the inode cache.
 func (ic *InodeCache) Put(info *proto.InodeInfo) {
+	ic.PutWithExpiration(info, ic.Expiration())
+}
+
+// PutWithExpiration puts the given inode info into the inode cache, valid for the given duration.
+func (ic *InodeCache) PutWithExpiration(info *proto.InodeInfo, exp time.Duration) {
 	ic.Lock()
 	old, ok := ic.cache[info.Inode]
 	if ok {
@@ -66,7 +71,7 @@ func (ic *InodeCache) Put(info *proto.InodeInfo) {
 		ic.evict(true)
 	}
 
-	inodeSetExpiration(info, ic.expiration)
+	inodeSetExpiration(info, exp)
 	element := ic.lruList.PushFront(info)
 	ic.cache[info.Inode] = element
 	ic.Unlock()
@@ -103,6 +108,41 @@ func (ic *InodeCache) Delete(ino uint64) {
 	ic.Unlock()
 }
 
+// Len returns the number of the inodes in the cache.
+func (ic *InodeCache) Len() int {
+	ic.RLock()
+	defer ic.RUnlock()
+	return ic.lruList.Len()
+}
+
+// SetMaxElements sets the maximum number of the inodes in the cache, and evicts the least
+// recently used ones beyond it.
+func (ic *InodeCache) SetMaxElements(maxElements int) {
+	ic.Lock()
+	defer ic.Unlock()
+	ic.maxElements = maxElements
+	for ic.lruList.Len() > maxElements {
+		element := ic.lruList.Back()
+		info := element.Value.(*proto.InodeInfo)
+		ic.lruList.Remove(element)
+		delete(ic.cache, info.Inode)
+	}
+}
+
+// Expiration returns the expiration duration of the inodes put into the cache.
+func (ic *InodeCache) Expiration() time.Duration {
+	ic.RLock()
+	defer ic.RUnlock()
+	return ic.expiration
+}
+
+// SetExpiration sets the expiration duration of the inodes put into the cache afterwards.
+func (ic *InodeCache) SetExpiration(exp time.Duration) {
+	ic.Lock()
+	ic.expiration = exp
+	ic.Unlock()
+}
+
 // Foreground eviction cares more about the speed.
 // Background eviction evicts all expired items from the cache.
 // The caller should grab the WRITE lock of the inode cache.
@@ -15,6 +15,7 @@
 package fs
 
 import (
+	"sync/atomic"
 	"time"
 
 	"bazil.org/fuse"
@@ -28,12 +29,22 @@ const (
 )
 
 func (s *Super) InodeGet(ino uint64) (*proto.InodeInfo, error) {
+	return s.inodeGet(nil, ino)
+}
+
+// inodeGet gets the inode info for the traced operation.
+func (s *Super) inodeGet(tr *opTrace, ino uint64) (*proto.InodeInfo, error) {
 	info := s.ic.Get(ino)
+	tr.cache(info != nil)
 	if info != nil {
+		atomic.AddUint64(&s.counters.icacheHits, 1)
 		return info, nil
 	}
+	atomic.AddUint64(&s.counters.icacheMisses, 1)
 
+	endMeta := tr.timeMeta()
 	info, err := s.mw.InodeGet_ll(ino)
+	endMeta()
 	if err != nil || info == nil {
 		log.LogErrorf("InodeGet: ino(%v) err(%v) info(%v)", ino, err, info)
 		if err != nil {
@@ -0,0 +1,102 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// With a memory limit, the inode cache, the extent caches and the write buffers of the client
+// are kept in the budget together. The write buffers take half of it at most. When the total
+// exceeds it, the buffered writes are flushed and the streamers of the closed files are released
+// with their extent caches, and the inode cache, which is the cheapest to refill, takes what is
+// left by the others.
+const (
+	memoryCheckInterval  = time.Second
+	inodeCacheEntrySize  = 512 // estimated bytes of an inode in the cache
+	minInodeCacheEntries = 1024
+)
+
+type memoryUsage struct {
+	inodeCache  int64
+	extentCache int64
+	writeBuffer int64
+}
+
+func (m memoryUsage) total() int64 {
+	return m.inodeCache + m.extentCache + m.writeBuffer
+}
+
+func (s *Super) memoryUsage() memoryUsage {
+	m := memoryUsage{inodeCache: int64(s.ic.Len()) * inodeCacheEntrySize}
+	m.extentCache, m.writeBuffer = s.ec.MemoryUsage()
+	return m
+}
+
+func (s *Super) getMemoryLimit() int64 {
+	return atomic.LoadInt64(&s.memoryLimit)
+}
+
+// setMemoryLimit sets the memory budget in bytes, 0 or less is unlimited.
+func (s *Super) setMemoryLimit(limit int64) {
+	if limit < 0 {
+		limit = 0
+	}
+	atomic.StoreInt64(&s.memoryLimit, limit)
+}
+
+func (s *Super) enforceMemoryLimit() {
+	t := time.NewTicker(memoryCheckInterval)
+	defer t.Stop()
+	var pressure, limited bool
+	for range t.C {
+		limit := s.getMemoryLimit()
+		if limit <= 0 {
+			if limited {
+				s.ec.SetMemoryPressure(false)
+				s.ec.SetWriteBufferLimit(0)
+				s.ic.SetMaxElements(MaxInodeCache)
+				pressure, limited = false, false
+			}
+			continue
+		}
+		limited = true
+		s.ec.SetWriteBufferLimit(limit / 2)
+
+		m := s.memoryUsage()
+		over := m.total() > limit
+		if over && !pressure {
+			log.LogWarnf("enforceMemoryLimit: limit(%v) exceeded, inodeCache(%v) extentCache(%v) writeBuffer(%v)",
+				limit, m.inodeCache, m.extentCache, m.writeBuffer)
+		} else if !over && pressure {
+			log.LogInfof("enforceMemoryLimit: back in limit(%v), inodeCache(%v) extentCache(%v) writeBuffer(%v)",
+				limit, m.inodeCache, m.extentCache, m.writeBuffer)
+		}
+		pressure = over
+		s.ec.SetMemoryPressure(over)
+
+		maxInodes := (limit - m.extentCache - m.writeBuffer) / inodeCacheEntrySize
+		if maxInodes < minInodeCacheEntries {
+			maxInodes = minInodeCacheEntries
+		}
+		if maxInodes > MaxInodeCache {
+			maxInodes = MaxInodeCache
+		}
+		s.ic.SetMaxElements(int(maxInodes))
+	}
+}
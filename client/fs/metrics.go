@@ -0,0 +1,105 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The latencies of the operations are exported as they are served, the counters of
+// the client health are sampled and exported periodically.
+const metricsReportInterval = 10 * time.Second
+
+// The operations are reported to the master for the objectives of the volume.
+const sloReportInterval = 15 * time.Second
+
+type clientCounters struct {
+	icacheHits   uint64
+	icacheMisses uint64
+	dcacheHits   uint64
+	dcacheMisses uint64
+}
+
+// counterReporter exports the increase of a counter since the last report.
+type counterReporter struct {
+	name string
+	last uint64
+}
+
+func (r *counterReporter) report(val uint64, labels map[string]string) {
+	if val > r.last {
+		exporter.NewCounter(r.name).AddWithLabels(int64(val-r.last), labels)
+	}
+	r.last = val
+}
+
+func (s *Super) reportMetrics() {
+	labels := map[string]string{exporter.Vol: s.volname}
+	reporters := make(map[string]*counterReporter)
+	report := func(name string, val uint64) {
+		r, ok := reporters[name]
+		if !ok {
+			r = &counterReporter{name: name}
+			reporters[name] = r
+		}
+		r.report(val, labels)
+	}
+
+	t := time.NewTicker(metricsReportInterval)
+	defer t.Stop()
+	for range t.C {
+		ms := s.mw.Stats()
+		ds := s.ec.Stats()
+		exporter.NewGauge("meta_inflight").SetWithLabels(float64(ms.Inflight), labels)
+		exporter.NewGauge("data_inflight").SetWithLabels(float64(ds.Inflight), labels)
+		report("meta_retry", ms.Retries)
+		report("data_retry", ds.Retries)
+		report("meta_partition_error", ms.PartitionErrors)
+		report("data_partition_error", ds.PartitionErrors)
+		report("master_error", ms.MasterErrors+ds.MasterErrors)
+		report("icache_hit", atomic.LoadUint64(&s.counters.icacheHits))
+		report("icache_miss", atomic.LoadUint64(&s.counters.icacheMisses))
+		report("dcache_hit", atomic.LoadUint64(&s.counters.dcacheHits))
+		report("dcache_miss", atomic.LoadUint64(&s.counters.dcacheMisses))
+		report("readcache_hit", ds.ReadCacheHits)
+		report("readcache_miss", ds.ReadCacheMisses)
+		report("readahead_hit", ds.ReadAheadHits)
+		report("readahead_miss", ds.ReadAheadMisses)
+		report("flashcache_hit", ds.FlashHits)
+		report("flashcache_miss", ds.FlashMisses)
+		m := s.memoryUsage()
+		exporter.NewGauge("memory_inode_cache").SetWithLabels(float64(m.inodeCache), labels)
+		exporter.NewGauge("memory_extent_cache").SetWithLabels(float64(m.extentCache), labels)
+		exporter.NewGauge("memory_write_buffer").SetWithLabels(float64(m.writeBuffer), labels)
+	}
+}
+
+func (s *Super) reportSLO() {
+	t := time.NewTicker(sloReportInterval)
+	defer t.Stop()
+	for range t.C {
+		reports := s.slo.Take()
+		if len(reports) == 0 {
+			continue
+		}
+		if err := s.mw.ReportSLO(reports); err != nil {
+			log.LogWarnf("reportSLO: volume(%v) err(%v)", s.volname, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+)
+
+// The operations slower than the threshold are kept with the breakdown of their latencies,
+// and listed by /debug/ops from the newest, e.g. /debug/ops?op=read&min=100ms.
+const (
+	DefaultSlowOpThreshold = 10 * time.Millisecond
+	maxSlowOps             = 256
+)
+
+// opTrace breaks down the latency of an operation.
+type opTrace struct {
+	s           *Super
+	op          string
+	ino         uint64
+	start       time.Time
+	total       time.Duration
+	meta        time.Duration // in the requests to the meta nodes
+	data        time.Duration // in the data path, including the queue
+	queue       time.Duration // waiting in the request queue of the file, approximate if it is written concurrently
+	cacheHits   int
+	cacheMisses int
+	err         error
+}
+
+func (s *Super) newOpTrace(op string, ino uint64) *opTrace {
+	return &opTrace{s: s, op: op, ino: ino, start: time.Now()}
+}
+
+// timeMeta starts a call to the meta nodes, the returned function ends it.
+func (tr *opTrace) timeMeta() func() {
+	if tr == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		tr.meta += time.Since(start)
+	}
+}
+
+// timeData starts a call to the data path of the inode, the returned function ends it.
+func (tr *opTrace) timeData(ino uint64) func() {
+	if tr == nil {
+		return func() {}
+	}
+	start, queued := time.Now(), tr.s.ec.QueueWait(ino)
+	return func() {
+		tr.data += time.Since(start)
+		if wait := tr.s.ec.QueueWait(ino) - queued; wait > 0 {
+			tr.queue += wait
+		}
+	}
+}
+
+func (tr *opTrace) cache(hit bool) {
+	if tr == nil {
+		return
+	}
+	if hit {
+		tr.cacheHits++
+	} else {
+		tr.cacheMisses++
+	}
+}
+
+// end ends the operation, and keeps it if it is slow.
+func (tr *opTrace) end(err error) {
+	tr.total = time.Since(tr.start)
+	tr.err = err
+	if tr.total >= tr.s.slowOps.getThreshold() {
+		tr.s.slowOps.add(tr)
+	}
+	tr.s.slo.Record(tr.s.volname, tr.total, isSLOError(err))
+}
+
+// isSLOError returns if the operation fails by the service, rather than by the arguments or the states
+// of the files.
+func isSLOError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch ParseError(err) {
+	case fuse.EIO, fuse.Errno(syscall.EAGAIN), fuse.Errno(syscall.ETIMEDOUT), fuse.Errno(syscall.ENOSPC):
+		return true
+	}
+	return false
+}
+
+func (tr *opTrace) String() string {
+	other := tr.total - tr.meta - tr.data
+	if other < 0 {
+		other = 0
+	}
+	errStr := ""
+	if tr.err != nil {
+		errStr = tr.err.Error()
+	}
+	return fmt.Sprintf("%v %v ino(%v) total(%v) meta(%v) data(%v) queue(%v) other(%v) cache(hit %v miss %v) err(%v)",
+		tr.start.Format("2006-01-02 15:04:05.000"), tr.op, tr.ino, tr.total, tr.meta, tr.data, tr.queue, other,
+		tr.cacheHits, tr.cacheMisses, errStr)
+}
+
+// slowOpLog keeps the latest slow operations.
+type slowOpLog struct {
+	sync.Mutex
+	ops       []*opTrace
+	next      int
+	threshold int64 // atomic
+}
+
+func (l *slowOpLog) init() {
+	l.ops = make([]*opTrace, 0, maxSlowOps)
+	l.threshold = int64(DefaultSlowOpThreshold)
+}
+
+func (l *slowOpLog) getThreshold() time.Duration {
+	return time.Duration(atomic.LoadInt64(&l.threshold))
+}
+
+func (l *slowOpLog) setThreshold(d time.Duration) {
+	atomic.StoreInt64(&l.threshold, int64(d))
+}
+
+func (l *slowOpLog) add(tr *opTrace) {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.ops) < maxSlowOps {
+		l.ops = append(l.ops, tr)
+		return
+	}
+	l.ops[l.next] = tr
+	l.next = (l.next + 1) % maxSlowOps
+}
+
+// list returns the operations from the newest.
+func (l *slowOpLog) list() []*opTrace {
+	l.Lock()
+	defer l.Unlock()
+	result := make([]*opTrace, 0, len(l.ops))
+	for i := 1; i <= len(l.ops); i++ {
+		result = append(result, l.ops[(l.next-i+len(l.ops))%len(l.ops)])
+	}
+	return result
+}
+
+// GetSlowOps lists the slow operations, filtered by the name and the minimal latency.
+func (s *Super) GetSlowOps(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.Write([]byte(err.Error()))
+		return
+	}
+	var min time.Duration
+	if val := r.FormValue("min"); val != "" {
+		var err error
+		if min, err = time.ParseDuration(val); err != nil {
+			w.Write([]byte(fmt.Sprintf("invalid min %v: %v\n", val, err)))
+			return
+		}
+	}
+	op := strings.ToLower(r.FormValue("op"))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("threshold: %v\n", s.slowOps.getThreshold()))
+	sb.WriteString(fmt.Sprintf("volume: %v\n", s.volname))
+	for _, tr := range s.slowOps.list() {
+		if tr.total < min || (op != "" && tr.op != op) {
+			continue
+		}
+		sb.WriteString(tr.String())
+		sb.WriteString("\n")
+	}
+	w.Write([]byte(sb.String()))
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/stream"
 	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
 	"github.com/cubefs/cubefs/util/ump"
@@ -60,6 +61,15 @@ type Super struct {
 	state     fs.FSStatType
 	sockaddr  string
 	suspendCh chan interface{}
+
+	counters clientCounters
+	slowOps  slowOpLog
+	slo      *proto.SLORecorder
+	audit    *auditLog // nil if the audit log is disabled
+
+	memoryLimit int64 // atomic, bytes of the caches and the write buffers at most, 0 is unlimited
+
+	warmDentries sync.Map // parent inode => *DentryCache, filled by the warm-up until looked up
 }
 
 // Functions that Super needs to implement
@@ -80,6 +90,16 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 		TicketMess:    opt.TicketMess,
 		ValidateOwner: opt.Authenticate || opt.AccessKey == "",
 		EnableSummary: opt.EnableSummary && opt.EnableXattr, // enable both summary and xattr
+		SnapshotID:    opt.SnapshotID,
+		SubDir:        opt.SubDir,
+		RetryPolicy: util.RetryPolicy{
+			Timeout:      time.Duration(opt.MetaTimeout) * time.Millisecond,
+			MaxRetries:   int(opt.MetaMaxRetries),
+			RetryTimeout: time.Duration(opt.MetaRetryTimeout) * time.Millisecond,
+			Backoff:      time.Duration(opt.RetryBackoff) * time.Millisecond,
+			MaxBackoff:   time.Duration(opt.RetryMaxBackoff) * time.Millisecond,
+			Jitter:       float64(opt.RetryJitter) / 100,
+		},
 	}
 	s.mw, err = meta.NewMetaWrapper(metaConfig)
 	if err != nil {
@@ -114,24 +134,65 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 		s.sc = NewSummaryCache(DefaultSummaryExpiration, MaxSummaryCache)
 	}
 
+	if opt.AuditLog != "" || opt.AuditCollector != "" {
+		if s.audit, err = newAuditLog(opt.Volname, opt.AuditLog, opt.AuditCollector); err != nil {
+			return nil, errors.Trace(err, "newAuditLog failed!")
+		}
+	}
+
+	var encryptKey []byte
+	if opt.EncryptKeyFile != "" {
+		if encryptKey, err = stream.LoadEncryptKey(opt.EncryptKeyFile); err != nil {
+			return nil, errors.Trace(err, "LoadEncryptKey failed!")
+		}
+	}
+
 	var extentConfig = &stream.ExtentConfig{
 		Volume:            opt.Volname,
 		Masters:           masters,
 		FollowerRead:      opt.FollowerRead,
 		NearRead:          opt.NearRead,
+		ZoneName:          opt.ZoneName,
+		Rack:              opt.Rack,
 		ReadRate:          opt.ReadRate,
 		WriteRate:         opt.WriteRate,
 		OnAppendExtentKey: s.mw.AppendExtentKey,
 		OnGetExtents:      s.mw.GetExtents,
 		OnTruncate:        s.mw.Truncate,
+		OnPunchHole:       s.mw.PunchHole,
 		OnEvictIcache:     s.ic.Delete,
+		OnWriteInline:     s.mw.WriteInline,
+		InlineSize:        int(opt.InlineSize),
+		ReadCachePath:     opt.ReadCachePath,
+		ReadCacheSize:     opt.ReadCacheSize,
+
+		WriteBack:             opt.WriteBack,
+		WriteBackDirtyLimit:   opt.WriteBackDirtyLimit,
+		WriteBackFlushSeconds: opt.WriteBackFlushSecs,
+		WriteBackCloseFlush:   opt.FsyncOnClose,
+		ReadBandwidth:         opt.ReadBandwidth,
+		WriteBandwidth:        opt.WriteBandwidth,
+		ReadAheadMax:          opt.ReadAheadMax,
+		EncryptKey:            encryptKey,
+		VerifyReadChecksum:    opt.VerifyReadChecksum,
+		ClusterRateLimit:      opt.ClusterRateLimit,
+		RetryPolicy: util.RetryPolicy{
+			Timeout:      time.Duration(opt.DataTimeout) * time.Millisecond,
+			MaxRetries:   int(opt.DataMaxRetries),
+			RetryTimeout: time.Duration(opt.DataRetryTimeout) * time.Millisecond,
+			Backoff:      time.Duration(opt.RetryBackoff) * time.Millisecond,
+			MaxBackoff:   time.Duration(opt.RetryMaxBackoff) * time.Millisecond,
+			Jitter:       float64(opt.RetryJitter) / 100,
+			HedgeDelay:   time.Duration(opt.HedgeReadDelay) * time.Millisecond,
+		},
 	}
 	s.ec, err = stream.NewExtentClient(extentConfig)
 	if err != nil {
 		return nil, errors.Trace(err, "NewExtentClient failed!")
 	}
 
-	if s.rootIno, err = s.mw.GetRootIno(opt.SubDir); err != nil {
+	// the metadata wrapper is rooted at the subdir already
+	if s.rootIno, err = s.mw.GetRootIno(""); err != nil {
 		return nil, err
 	}
 	s.suspendCh = make(chan interface{})
@@ -140,6 +201,13 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 		atomic.StoreUint32((*uint32)(&s.state), uint32(fs.FSStatRestore))
 	}
 
+	s.slowOps.init()
+	s.slo = proto.NewSLORecorder()
+	s.setMemoryLimit(opt.MemoryLimit)
+	go s.enforceMemoryLimit()
+	go s.reportMetrics()
+	go s.reportSLO()
+
 	log.LogInfof("NewSuper: cluster(%v) volname(%v) icacheExpiration(%v) LookupValidDuration(%v) AttrValidDuration(%v) state(%v)",
 		s.cluster, s.volname, inodeExpiration, LookupValidDuration, AttrValidDuration, s.state)
 	return s, nil
@@ -227,6 +295,26 @@ func (s *Super) SetRate(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(fmt.Sprintf("Set write rate to %v successfully\n", msg)))
 		}
 	}
+
+	if bandwidth := r.FormValue("readBandwidth"); bandwidth != "" {
+		val, err := strconv.Atoi(bandwidth)
+		if err != nil {
+			w.Write([]byte("Set read bandwidth failed\n"))
+		} else {
+			msg := s.ec.SetReadBandwidth(val)
+			w.Write([]byte(fmt.Sprintf("Set read bandwidth to %v successfully\n", msg)))
+		}
+	}
+
+	if bandwidth := r.FormValue("writeBandwidth"); bandwidth != "" {
+		val, err := strconv.Atoi(bandwidth)
+		if err != nil {
+			w.Write([]byte("Set write bandwidth failed\n"))
+		} else {
+			msg := s.ec.SetWriteBandwidth(val)
+			w.Write([]byte(fmt.Sprintf("Set write bandwidth to %v successfully\n", msg)))
+		}
+	}
 }
 
 func (s *Super) exporterKey(act string) string {
@@ -0,0 +1,278 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The warm-up walks a directory tree before a job starts, e.g. /warmup?path=/dataset&data=true,
+// and keeps the inodes and the dentries in the caches of the client for the ttl, so that the
+// lookups and the stats of the job are served locally. With data, the files are also read into
+// the read cache on the local disk.
+const (
+	DefaultWarmupTTL      = 10 * time.Minute
+	DefaultWarmupParallel = 8
+	DefaultWarmupMaxInode = 1000000
+	warmupReadSize        = 1 << 20
+)
+
+type warmup struct {
+	s         *Super
+	ttl       time.Duration
+	data      bool
+	parallel  int
+	maxInodes int64
+
+	dirs      int64
+	files     int64
+	bytes     int64
+	errors    int64
+	truncated int32
+}
+
+// takeWarmDentries returns the dentries of the directory filled by the warm-up if they are still valid,
+// they are taken by the first lookup and kept in the directory afterwards.
+func (s *Super) takeWarmDentries(ino uint64) *DentryCache {
+	val, ok := s.warmDentries.Load(ino)
+	if !ok {
+		return nil
+	}
+	s.warmDentries.Delete(ino)
+	if dcache := val.(*DentryCache); !dcache.Expired() {
+		return dcache
+	}
+	return nil
+}
+
+func (s *Super) clearExpiredWarmDentries() {
+	s.warmDentries.Range(func(key, val interface{}) bool {
+		if val.(*DentryCache).Expired() {
+			s.warmDentries.Delete(key)
+		}
+		return true
+	})
+}
+
+// Warmup walks the directory tree of the path relative to the mount point, and replies with
+// the number of the directories and the files warmed up when it is done.
+func (s *Super) Warmup(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.Write([]byte(err.Error()))
+		return
+	}
+	wu := &warmup{s: s, ttl: DefaultWarmupTTL, parallel: DefaultWarmupParallel, maxInodes: DefaultWarmupMaxInode}
+	wu.data = r.FormValue("data") == "true"
+	if wu.data && !s.ec.ReadCacheEnabled() {
+		w.Write([]byte("data is not warmed up without the read cache, see readCachePath\n"))
+		return
+	}
+	if val := r.FormValue("ttl"); val != "" {
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || n <= 0 {
+			w.Write([]byte(fmt.Sprintf("invalid ttl %v\n", val)))
+			return
+		}
+		wu.ttl = time.Duration(n) * time.Second
+	}
+	if val := r.FormValue("parallel"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			w.Write([]byte(fmt.Sprintf("invalid parallel %v\n", val)))
+			return
+		}
+		wu.parallel = n
+	}
+	if val := r.FormValue("max"); val != "" {
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || n <= 0 {
+			w.Write([]byte(fmt.Sprintf("invalid max %v\n", val)))
+			return
+		}
+		wu.maxInodes = n
+	}
+
+	start := time.Now()
+	ino, err := s.resolvePath(r.FormValue("path"))
+	if err != nil {
+		w.Write([]byte(fmt.Sprintf("resolve path %v: %v\n", r.FormValue("path"), err)))
+		return
+	}
+	s.clearExpiredWarmDentries()
+	wu.run(ino)
+
+	msg := fmt.Sprintf("dirs: %v\nfiles: %v\nbytes: %v\nerrors: %v\nelapsed: %v\n",
+		wu.dirs, wu.files, wu.bytes, wu.errors, time.Since(start))
+	if atomic.LoadInt32(&wu.truncated) != 0 {
+		msg += fmt.Sprintf("truncated at %v inodes\n", wu.maxInodes)
+	}
+	log.LogInfof("Warmup: path(%v) %v", r.FormValue("path"), strings.Replace(msg, "\n", " ", -1))
+	w.Write([]byte(msg))
+}
+
+// resolvePath returns the inode of the path relative to the mount point.
+func (s *Super) resolvePath(path string) (ino uint64, err error) {
+	ino = s.rootIno
+	for _, name := range strings.Split(path, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+		if ino, _, err = s.mw.Lookup_ll(ino, name); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// run warms up the tree level by level, the directories of a level are read in parallel.
+func (wu *warmup) run(root uint64) {
+	info, err := wu.s.InodeGet(root)
+	if err != nil {
+		atomic.AddInt64(&wu.errors, 1)
+		return
+	}
+	if !proto.IsDir(info.Mode) {
+		wu.s.ic.PutWithExpiration(info, wu.ttl)
+		wu.files++
+		if wu.data {
+			wu.readFile(info)
+		}
+		return
+	}
+
+	level := []uint64{root}
+	inodes := int64(1)
+	for len(level) > 0 {
+		var (
+			next  []uint64
+			files []*proto.InodeInfo
+			lock  sync.Mutex
+		)
+		wu.parallelDo(len(level), func(i int) {
+			subdirs, regulars := wu.readDir(level[i])
+			lock.Lock()
+			next = append(next, subdirs...)
+			files = append(files, regulars...)
+			lock.Unlock()
+		})
+		if wu.data {
+			wu.parallelDo(len(files), func(i int) {
+				wu.readFile(files[i])
+			})
+		}
+		if inodes += int64(len(next) + len(files)); inodes >= wu.maxInodes {
+			atomic.StoreInt32(&wu.truncated, 1)
+			return
+		}
+		level = next
+	}
+}
+
+func (wu *warmup) parallelDo(n int, fn func(i int)) {
+	var (
+		wg    sync.WaitGroup
+		index int64 = -1
+	)
+	for w := 0; w < wu.parallel && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&index, 1))
+				if i >= n {
+					return
+				}
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// readDir caches the dentries and the inodes of the children of the directory,
+// and returns its subdirectories and regular files.
+func (wu *warmup) readDir(ino uint64) (subdirs []uint64, files []*proto.InodeInfo) {
+	s := wu.s
+	children, err := s.mw.ReadDir_ll(ino)
+	if err != nil {
+		log.LogWarnf("Warmup: readdir ino(%v) err(%v)", ino, err)
+		atomic.AddInt64(&wu.errors, 1)
+		return
+	}
+	atomic.AddInt64(&wu.dirs, 1)
+
+	inodes := make([]uint64, 0, len(children))
+	var dcache *DentryCache
+	if !s.disableDcache {
+		dcache = newDentryCacheWithExpiration(wu.ttl)
+	}
+	for _, child := range children {
+		dcache.Put(child.Name, child.Inode)
+		inodes = append(inodes, child.Inode)
+	}
+	if dcache != nil {
+		s.warmDentries.Store(ino, dcache)
+	}
+
+	for _, info := range s.mw.BatchInodeGet(inodes) {
+		s.ic.PutWithExpiration(info, wu.ttl)
+		if proto.IsDir(info.Mode) {
+			subdirs = append(subdirs, info.Inode)
+		} else if proto.IsRegular(info.Mode) {
+			atomic.AddInt64(&wu.files, 1)
+			files = append(files, info)
+		}
+	}
+	return
+}
+
+// readFile reads the file through the read cache.
+func (wu *warmup) readFile(info *proto.InodeInfo) {
+	ec := wu.s.ec
+	if err := ec.OpenStream(info.Inode); err != nil {
+		atomic.AddInt64(&wu.errors, 1)
+		return
+	}
+	defer ec.CloseStream(info.Inode)
+
+	buf := make([]byte, warmupReadSize)
+	for offset := 0; offset < int(info.Size); {
+		size := warmupReadSize
+		if rest := int(info.Size) - offset; rest < size {
+			size = rest
+		}
+		n, err := ec.Read(info.Inode, buf, offset, size)
+		atomic.AddInt64(&wu.bytes, int64(n))
+		if err != nil && err != io.EOF {
+			log.LogWarnf("Warmup: read ino(%v) offset(%v) size(%v) err(%v)", info.Inode, offset, size, err)
+			atomic.AddInt64(&wu.errors, 1)
+			return
+		}
+		if n <= 0 {
+			return
+		}
+		offset += n
+	}
+}
@@ -27,13 +27,11 @@ import (
 	syslog "log"
 	"net"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"path"
 	"path/filepath"
 	"runtime"
-	"runtime/debug"
 	"strings"
 	"syscall"
 	"time"
@@ -48,9 +46,13 @@ import (
 	cfs "github.com/cubefs/cubefs/client/fs"
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/diag"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tlsutil"
+	"github.com/cubefs/cubefs/util/tracing"
 	"github.com/cubefs/cubefs/util/ump"
 	"github.com/jacobsa/daemonize"
 )
@@ -69,12 +71,15 @@ const (
 	ModuleName            = "fuseclient"
 	ConfigKeyExporterPort = "exporterKey"
 
-	ControlCommandSetRate      = "/rate/set"
-	ControlCommandGetRate      = "/rate/get"
-	ControlCommandFreeOSMemory = "/debug/freeosmemory"
-	ControlCommandSuspend      = "/suspend"
-	ControlCommandResume       = "/resume"
-	Role                       = "Client"
+	ControlCommandSetRate = "/rate/set"
+	ControlCommandGetRate = "/rate/get"
+	ControlCommandSetConf = "/conf/set"
+	ControlCommandGetConf = "/conf/get"
+	ControlCommandSlowOps = "/debug/ops"
+	ControlCommandWarmup  = "/warmup"
+	ControlCommandSuspend = "/suspend"
+	ControlCommandResume  = "/resume"
+	Role                  = "Client"
 
 	DefaultIP            = "127.0.0.1"
 	DynamicUDSNameFormat = "/tmp/ChubaoFS-fdstore-%v.sock"
@@ -270,7 +275,13 @@ func main() {
 	 * Must notify the parent process through SignalOutcome anyway.
 	 */
 
-	cfg, _ := config.LoadConfigFile(*configFile)
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		err = errors.NewErrorf("load config failed: %v\n", err)
+		fmt.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
 	opt, err := parseMountOption(cfg)
 	if err != nil {
 		err = errors.NewErrorf("parse mount opt failed: %v\n", err)
@@ -286,7 +297,20 @@ func main() {
 	}
 
 	level := parseLogLevel(opt.Loglvl)
-	_, err = log.InitLog(opt.Logpath, opt.Volname, level, nil)
+	if err = log.SetFormat(opt.LogFormat); err != nil {
+		err = errors.NewErrorf("Init log format fail: %v\n", err)
+		fmt.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	logRotate, err := log.ParseLogRotate(cfg)
+	if err != nil {
+		err = errors.NewErrorf("Init log rotate fail: %v\n", err)
+		fmt.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	_, err = log.InitLog(opt.Logpath, opt.Volname, level, logRotate)
 	if err != nil {
 		err = errors.NewErrorf("Init log dir fail: %v\n", err)
 		fmt.Println(err)
@@ -295,6 +319,23 @@ func main() {
 	}
 	defer log.LogFlush()
 
+	if err = tlsutil.Init(cfg); err != nil {
+		err = errors.NewErrorf("Init TLS fail: %v\n", err)
+		fmt.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+
+	if err = tracing.Init(ModuleName, cfg); err != nil {
+		err = errors.NewErrorf("Init tracing fail: %v\n", err)
+		fmt.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	defer tracing.Stop()
+	reqid.Init(cfg)
+	diag.Init(cfg)
+
 	outputFilePath := path.Join(opt.Logpath, opt.Volname, LoggerOutput)
 	outputFile, err := os.OpenFile(outputFilePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
 	if err != nil {
@@ -522,11 +563,16 @@ func mount(opt *proto.MountOptions) (fsConn *fuse.Conn, super *cfs.Super, err er
 
 	http.HandleFunc(ControlCommandSetRate, super.SetRate)
 	http.HandleFunc(ControlCommandGetRate, super.GetRate)
+	http.HandleFunc(ControlCommandSetConf, super.SetConf)
+	http.HandleFunc(ControlCommandGetConf, super.GetConf)
 	http.HandleFunc(log.SetLogLevelPath, log.SetLogLevel)
-	http.HandleFunc(ControlCommandFreeOSMemory, freeOSMemory)
+	http.HandleFunc(log.FlushLogPath, log.FlushLog)
+	http.HandleFunc(ControlCommandSlowOps, super.GetSlowOps)
+	http.HandleFunc(ControlCommandWarmup, super.Warmup)
 	http.HandleFunc(log.GetLogPath, log.GetLog)
 	http.HandleFunc(ControlCommandSuspend, super.SetSuspend)
 	http.HandleFunc(ControlCommandResume, super.SetResume)
+	diag.Register(http.DefaultServeMux)
 
 	statusCh := make(chan error)
 	go waitListenAndServe(statusCh, ":"+opt.Profport, nil)
@@ -561,6 +607,10 @@ func mount(opt *proto.MountOptions) (fsConn *fuse.Conn, super *cfs.Super, err er
 		options = append(options, fuse.PosixACL())
 	}
 
+	if opt.LockMode == proto.LockModeMeta {
+		options = append(options, fuse.LockingPOSIX(), fuse.LockingFlock())
+	}
+
 	if opt.EnableUnixPermission {
 		options = append(options, fuse.DefaultPermissions())
 	}
@@ -579,6 +629,24 @@ func registerInterceptedSignal(mnt string) {
 	}()
 }
 
+// loadConfig loads the config file and checks it against the mount options, which may also be given
+// only by the command line without the config file.
+func loadConfig(fileName string) (*config.Config, error) {
+	if fileName == "" {
+		return config.LoadConfigString("{}"), nil
+	}
+	cfg, err := config.LoadConfigFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	schema := config.MergeSchemas(proto.MountOptionsSchema(GlobalMountOptions), log.ConfigSchema,
+		tlsutil.ConfigSchema, tracing.ConfigSchema, reqid.ConfigSchema, diag.ConfigSchema, exporter.ConfigSchema)
+	if err = cfg.Validate(schema); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	var err error
 	opt := new(proto.MountOptions)
@@ -600,6 +668,7 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	}
 	opt.Logpath = path.Join(logPath, LoggerPrefix)
 	opt.Loglvl = GlobalMountOptions[proto.LogLevel].GetString()
+	opt.LogFormat = GlobalMountOptions[proto.LogFormat].GetString()
 	opt.Profport = GlobalMountOptions[proto.ProfPort].GetString()
 	opt.IcacheTimeout = GlobalMountOptions[proto.IcacheTimeout].GetInt64()
 	opt.LookupValid = GlobalMountOptions[proto.LookupValid].GetInt64()
@@ -632,9 +701,46 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	opt.MaxCPUs = GlobalMountOptions[proto.MaxCPUs].GetInt64()
 	opt.EnableXattr = GlobalMountOptions[proto.EnableXattr].GetBool()
 	opt.NearRead = GlobalMountOptions[proto.NearRead].GetBool()
+	opt.ZoneName = GlobalMountOptions[proto.ZoneName].GetString()
+	opt.Rack = GlobalMountOptions[proto.Rack].GetString()
 	opt.EnablePosixACL = GlobalMountOptions[proto.EnablePosixACL].GetBool()
 	opt.EnableSummary = GlobalMountOptions[proto.EnableSummary].GetBool()
 	opt.EnableUnixPermission = GlobalMountOptions[proto.EnableUnixPermission].GetBool()
+	opt.SnapshotID = uint64(GlobalMountOptions[proto.Snapshot].GetInt64())
+	opt.InlineSize = GlobalMountOptions[proto.InlineSize].GetInt64()
+	opt.ReadCachePath = GlobalMountOptions[proto.ReadCachePath].GetString()
+	opt.ReadCacheSize = GlobalMountOptions[proto.ReadCacheSize].GetInt64()
+	opt.WriteBack = GlobalMountOptions[proto.WriteBack].GetBool()
+	opt.WriteBackDirtyLimit = GlobalMountOptions[proto.WriteBackDirtyLimit].GetInt64()
+	opt.WriteBackFlushSecs = GlobalMountOptions[proto.WriteBackFlushInterval].GetInt64()
+	opt.ReadBandwidth = GlobalMountOptions[proto.ReadBandwidth].GetInt64()
+	opt.WriteBandwidth = GlobalMountOptions[proto.WriteBandwidth].GetInt64()
+	opt.LockMode = GlobalMountOptions[proto.LockMode].GetString()
+	opt.ReadAheadMax = GlobalMountOptions[proto.ReadAheadMax].GetInt64()
+	opt.EncryptKeyFile = GlobalMountOptions[proto.EncryptKeyFile].GetString()
+	opt.MetaTimeout = GlobalMountOptions[proto.MetaTimeout].GetInt64()
+	opt.MetaMaxRetries = GlobalMountOptions[proto.MetaMaxRetries].GetInt64()
+	opt.MetaRetryTimeout = GlobalMountOptions[proto.MetaRetryTimeout].GetInt64()
+	opt.DataTimeout = GlobalMountOptions[proto.DataTimeout].GetInt64()
+	opt.DataMaxRetries = GlobalMountOptions[proto.DataMaxRetries].GetInt64()
+	opt.DataRetryTimeout = GlobalMountOptions[proto.DataRetryTimeout].GetInt64()
+	opt.RetryBackoff = GlobalMountOptions[proto.RetryBackoff].GetInt64()
+	opt.RetryMaxBackoff = GlobalMountOptions[proto.RetryMaxBackoff].GetInt64()
+	opt.RetryJitter = GlobalMountOptions[proto.RetryJitter].GetInt64()
+	opt.HedgeReadDelay = GlobalMountOptions[proto.HedgeReadDelay].GetInt64()
+	opt.AuditLog = GlobalMountOptions[proto.AuditLog].GetString()
+	opt.AuditCollector = GlobalMountOptions[proto.AuditCollector].GetString()
+	opt.MemoryLimit = GlobalMountOptions[proto.MemoryLimit].GetInt64()
+	opt.VerifyReadChecksum = GlobalMountOptions[proto.VerifyReadChecksum].GetBool()
+	opt.ClusterRateLimit = GlobalMountOptions[proto.ClusterRateLimit].GetBool()
+	if opt.SnapshotID != 0 {
+		// snapshots can never be modified
+		opt.Rdonly = true
+	}
+
+	if opt.LockMode != proto.LockModeLocal && opt.LockMode != proto.LockModeMeta {
+		return nil, errors.New(fmt.Sprintf("invalid config file: lockMode(%v) is neither %v nor %v", opt.LockMode, proto.LockModeLocal, proto.LockModeMeta))
+	}
 
 	if opt.MountPoint == "" || opt.Volname == "" || opt.Owner == "" || opt.Master == "" {
 		return nil, errors.New(fmt.Sprintf("invalid config file: lack of mandatory fields, mountPoint(%v), volName(%v), owner(%v), masterAddr(%v)", opt.MountPoint, opt.Volname, opt.Owner, opt.Master))
@@ -701,7 +807,3 @@ func changeRlimit(val uint64) {
 		syslog.Printf("Successfully set rlimit to %v \n", val)
 	}
 }
-
-func freeOSMemory(w http.ResponseWriter, r *http.Request) {
-	debug.FreeOSMemory()
-}
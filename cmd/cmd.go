@@ -19,7 +19,6 @@ import (
 	"fmt"
 	syslog "log"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"path"
@@ -42,10 +41,18 @@ import (
 	"github.com/cubefs/cubefs/authnode"
 	"github.com/cubefs/cubefs/cmd/common"
 	"github.com/cubefs/cubefs/datanode"
+	"github.com/cubefs/cubefs/flashnode"
 	"github.com/cubefs/cubefs/master"
 	"github.com/cubefs/cubefs/metanode"
+	"github.com/cubefs/cubefs/nfsnode"
 	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/diag"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/ipfilter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tlsutil"
+	"github.com/cubefs/cubefs/util/tracing"
 	"github.com/cubefs/cubefs/util/ump"
 )
 
@@ -53,6 +60,7 @@ const (
 	ConfigKeyRole       = "role"
 	ConfigKeyLogDir     = "logDir"
 	ConfigKeyLogLevel   = "logLevel"
+	ConfigKeyLogFormat  = "logFormat"
 	ConfigKeyProfPort   = "prof"
 	ConfigKeyWarnLogDir = "warnLogDir"
 )
@@ -64,6 +72,8 @@ const (
 	RoleAuth    = "authnode"
 	RoleObject  = "objectnode"
 	RoleConsole = "console"
+	RoleNfs     = "nfsnode"
+	RoleFlash   = "flashnode"
 )
 
 const (
@@ -73,12 +83,45 @@ const (
 	ModuleAuth    = "authNode"
 	ModuleObject  = "objectNode"
 	ModuleConsole = "console"
+	ModuleNfs     = "nfsNode"
+	ModuleFlash   = "flashNode"
 )
 
 const (
 	LoggerOutput = "output.log"
 )
 
+// commonSchema declares the configuration keys shared by all the roles.
+var commonSchema = config.MergeSchemas(config.Schema{
+	ConfigKeyRole:       config.TypeString,
+	ConfigKeyLogDir:     config.TypeString,
+	ConfigKeyLogLevel:   config.TypeString,
+	ConfigKeyLogFormat:  config.TypeString,
+	ConfigKeyProfPort:   config.TypeString,
+	ConfigKeyWarnLogDir: config.TypeString,
+}, log.ConfigSchema, tlsutil.ConfigSchema, ipfilter.ConfigSchema, diag.ConfigSchema, tracing.ConfigSchema, reqid.ConfigSchema, exporter.ConfigSchema)
+
+var roleSchemas = map[string]config.Schema{
+	RoleMaster:  master.ConfigSchema,
+	RoleMeta:    metanode.ConfigSchema,
+	RoleData:    datanode.ConfigSchema,
+	RoleAuth:    authnode.ConfigSchema,
+	RoleObject:  objectnode.ConfigSchema,
+	RoleConsole: console.ConfigSchema,
+	RoleNfs:     nfsnode.ConfigSchema,
+	RoleFlash:   flashnode.ConfigSchema,
+}
+
+// validateConfig checks the config against the schema of its role, the unknown role is reported later
+// when the server is created.
+func validateConfig(cfg *config.Config) error {
+	schema, ok := roleSchemas[cfg.GetString(ConfigKeyRole)]
+	if !ok {
+		return nil
+	}
+	return cfg.Validate(config.MergeSchemas(commonSchema, schema))
+}
+
 var (
 	configFile       = flag.String("c", "", "config file path")
 	configVersion    = flag.Bool("v", false, "show version")
@@ -137,6 +180,11 @@ func main() {
 		daemonize.SignalOutcome(err)
 		os.Exit(1)
 	}
+	if err = validateConfig(cfg); err != nil {
+		fmt.Printf("Fatal: %v: %v\n", *configFile, err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
 
 	if !*configForeground {
 		if err := startDaemon(); err != nil {
@@ -154,6 +202,7 @@ func main() {
 	role := cfg.GetString(ConfigKeyRole)
 	logDir := cfg.GetString(ConfigKeyLogDir)
 	logLevel := cfg.GetString(ConfigKeyLogLevel)
+	logFormat := cfg.GetString(ConfigKeyLogFormat)
 	profPort := cfg.GetString(ConfigKeyProfPort)
 	umpDatadir := cfg.GetString(ConfigKeyWarnLogDir)
 
@@ -181,6 +230,12 @@ func main() {
 	case RoleConsole:
 		server = console.NewServer()
 		module = ModuleConsole
+	case RoleNfs:
+		server = nfsnode.NewServer()
+		module = ModuleNfs
+	case RoleFlash:
+		server = flashnode.NewServer()
+		module = ModuleFlash
 	default:
 		err = errors.NewErrorf("Fatal: role mismatch: %s", role)
 		fmt.Println(err)
@@ -205,7 +260,20 @@ func main() {
 		level = log.ErrorLevel
 	}
 
-	_, err = log.InitLog(logDir, module, level, nil)
+	if err = log.SetFormat(logFormat); err != nil {
+		err = errors.NewErrorf("Fatal: failed to init log - %v", err)
+		fmt.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	logRotate, err := log.ParseLogRotate(cfg)
+	if err != nil {
+		err = errors.NewErrorf("Fatal: failed to init log - %v", err)
+		fmt.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	_, err = log.InitLog(logDir, module, level, logRotate)
 	if err != nil {
 		err = errors.NewErrorf("Fatal: failed to init log - %v", err)
 		fmt.Println(err)
@@ -256,10 +324,39 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = tlsutil.Init(cfg); err != nil {
+		log.LogFlush()
+		err = errors.NewErrorf("Fatal: failed to init TLS - %v", err)
+		syslog.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+
+	if err = ipfilter.Init(cfg); err != nil {
+		log.LogFlush()
+		err = errors.NewErrorf("Fatal: failed to init HTTP access lists - %v", err)
+		syslog.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+
+	if err = tracing.Init(module, cfg); err != nil {
+		log.LogFlush()
+		err = errors.NewErrorf("Fatal: failed to init tracing - %v", err)
+		syslog.Println(err)
+		daemonize.SignalOutcome(err)
+		os.Exit(1)
+	}
+	defer tracing.Stop()
+	reqid.Init(cfg)
+
 	if profPort != "" {
+		diag.Init(cfg)
 		go func() {
 			http.HandleFunc(log.SetLogLevelPath, log.SetLogLevel)
-			e := http.ListenAndServe(fmt.Sprintf(":%v", profPort), nil)
+			http.HandleFunc(log.FlushLogPath, log.FlushLog)
+			diag.Register(http.DefaultServeMux)
+			e := tlsutil.ListenAndServe(&http.Server{Addr: fmt.Sprintf(":%v", profPort), Handler: ipfilter.Handler(http.DefaultServeMux)})
 			if e != nil {
 				log.LogFlush()
 				err = errors.NewErrorf("cannot listen pprof %v err %v", profPort, err)
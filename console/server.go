@@ -19,6 +19,17 @@ import (
 	"github.com/samsarahq/thunder/graphql/introspection"
 )
 
+// ConfigSchema declares the configuration keys of the console.
+var ConfigSchema = config.Schema{
+	proto.ListenPort:       config.TypeString,
+	proto.MasterAddr:       config.TypeStringSlice,
+	proto.ObjectNodeDomain: config.TypeString,
+	"monitor_addr":         config.TypeString,
+	"monitor_app":          config.TypeString,
+	"monitor_cluster":      config.TypeString,
+	"dashboard_addr":       config.TypeString,
+}
+
 type ConsoleNode struct {
 	listen           string
 	masters          []string
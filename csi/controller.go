@@ -0,0 +1,379 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package csi implements the controller RPCs of the CSI driver which expand the volumes and take, list,
+// delete and restore their snapshots through the master, so that the Kubernetes PVCs can be resized and
+// snapshotted natively. The requests and the responses mirror the messages of the CSI spec field by field,
+// and the errors carry the gRPC status codes, so the gRPC service of the driver converts them as they are.
+//
+// The volume ID is the name of the volume, and the snapshot ID is the name of the volume and the ID of the
+// snapshot kept by the master, joined by a slash. The owner of the volume authorizes the changes, which is
+// taken from the "owner" secret of the request, or from the master if the secret is not given.
+package csi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util"
+)
+
+// Code is the gRPC status code of an error.
+type Code uint32
+
+const (
+	CodeInvalidArgument Code = 3
+	CodeNotFound        Code = 5
+	CodeAlreadyExists   Code = 6
+	CodeAborted         Code = 10
+	CodeOutOfRange      Code = 11
+	CodeInternal        Code = 13
+)
+
+const (
+	SecretOwner = "owner"
+)
+
+// The controller capabilities of the CSI spec supported.
+const (
+	CapExpandVolume         = "EXPAND_VOLUME"
+	CapCreateDeleteSnapshot = "CREATE_DELETE_SNAPSHOT"
+	CapListSnapshots        = "LIST_SNAPSHOTS"
+)
+
+// Error is an error with the gRPC status code returned by the driver.
+type Error struct {
+	Code Code
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("code(%v) %v", e.Code, e.Msg)
+}
+
+func newError(code Code, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Msg: fmt.Sprintf(format, a...)}
+}
+
+// masterError converts the error of the master.
+func masterError(action string, err error) *Error {
+	switch err {
+	case proto.ErrVolNotExists:
+		return newError(CodeNotFound, "%v: %v", action, err)
+	case proto.ErrParamError, proto.ErrVolAuthKeyNotMatch:
+		return newError(CodeInvalidArgument, "%v: %v", action, err)
+	}
+	return newError(CodeInternal, "%v: %v", action, err)
+}
+
+type CapacityRange struct {
+	RequiredBytes int64
+	LimitBytes    int64
+}
+
+type ControllerExpandVolumeRequest struct {
+	VolumeId      string
+	CapacityRange *CapacityRange
+	Secrets       map[string]string
+}
+
+type ControllerExpandVolumeResponse struct {
+	CapacityBytes         int64
+	NodeExpansionRequired bool
+}
+
+type Snapshot struct {
+	SizeBytes      int64
+	SnapshotId     string
+	SourceVolumeId string
+	CreationTime   int64 // in seconds
+	ReadyToUse     bool
+}
+
+type CreateSnapshotRequest struct {
+	SourceVolumeId string
+	Name           string
+	Secrets        map[string]string
+	Parameters     map[string]string
+}
+
+type CreateSnapshotResponse struct {
+	Snapshot *Snapshot
+}
+
+type DeleteSnapshotRequest struct {
+	SnapshotId string
+	Secrets    map[string]string
+}
+
+type DeleteSnapshotResponse struct{}
+
+type ListSnapshotsRequest struct {
+	MaxEntries     int32
+	StartingToken  string
+	SourceVolumeId string
+	SnapshotId     string
+	Secrets        map[string]string
+}
+
+type ListSnapshotsResponse struct {
+	Entries   []*Snapshot
+	NextToken string
+}
+
+// RestoreSnapshotRequest rolls the volume back to its snapshot. It backs CreateVolume with a snapshot
+// content source naming the volume of the snapshot, as the master restores the snapshots in place.
+type RestoreSnapshotRequest struct {
+	SnapshotId string
+	VolumeId   string
+	Secrets    map[string]string
+}
+
+type RestoreSnapshotResponse struct {
+	Snapshot *Snapshot
+}
+
+// ControllerServer serves the controller RPCs through the masters of the cluster.
+type ControllerServer struct {
+	mc *master.MasterClient
+}
+
+func NewControllerServer(mc *master.MasterClient) *ControllerServer {
+	return &ControllerServer{mc: mc}
+}
+
+// Capabilities returns the controller capabilities supported.
+func (cs *ControllerServer) Capabilities() []string {
+	return []string{CapExpandVolume, CapCreateDeleteSnapshot, CapListSnapshots}
+}
+
+// authKey returns the key the master authorizes the owner of the volume by.
+func (cs *ControllerServer) authKey(volName string, secrets map[string]string) (key string, err error) {
+	owner := secrets[SecretOwner]
+	if owner == "" {
+		var view *proto.SimpleVolView
+		if view, err = cs.mc.AdminAPI().GetVolumeSimpleInfo(volName); err != nil {
+			return "", masterError("get volume", err)
+		}
+		owner = view.Owner
+	}
+	sum := md5.Sum([]byte(owner))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ControllerExpandVolume expands the volume to the required bytes rounded up to GB, the capacity of the
+// volume. The volume is not shrunk, and the clients see the new capacity without expanding the nodes.
+func (cs *ControllerServer) ControllerExpandVolume(req *ControllerExpandVolumeRequest) (resp *ControllerExpandVolumeResponse, err error) {
+	if req.VolumeId == "" {
+		return nil, newError(CodeInvalidArgument, "volume ID missing")
+	}
+	if req.CapacityRange == nil || req.CapacityRange.RequiredBytes <= 0 {
+		return nil, newError(CodeInvalidArgument, "required bytes missing")
+	}
+	capacity := (uint64(req.CapacityRange.RequiredBytes) + util.GB - 1) / util.GB
+	if limit := req.CapacityRange.LimitBytes; limit > 0 && capacity*util.GB > uint64(limit) {
+		return nil, newError(CodeOutOfRange, "capacity(%vGB) exceeds the limit bytes(%v)", capacity, limit)
+	}
+	view, err := cs.mc.AdminAPI().GetVolumeSimpleInfo(req.VolumeId)
+	if err != nil {
+		return nil, masterError("get volume", err)
+	}
+	if capacity > view.Capacity {
+		var key string
+		if key, err = cs.authKey(req.VolumeId, req.Secrets); err != nil {
+			return
+		}
+		if err = cs.mc.AdminAPI().VolExpand(req.VolumeId, capacity, key); err != nil {
+			return nil, masterError("expand volume", err)
+		}
+	} else {
+		capacity = view.Capacity
+	}
+	return &ControllerExpandVolumeResponse{CapacityBytes: int64(capacity * util.GB)}, nil
+}
+
+func snapshotID(snap *proto.VolSnapshotInfo) string {
+	return snap.VolName + "/" + strconv.FormatUint(snap.ID, 10)
+}
+
+// parseSnapshotID returns the volume and the ID of the snapshot.
+func parseSnapshotID(snapshotID string) (volName string, id uint64, err error) {
+	i := strings.LastIndex(snapshotID, "/")
+	if i <= 0 {
+		return "", 0, newError(CodeInvalidArgument, "invalid snapshot ID(%v)", snapshotID)
+	}
+	if id, err = strconv.ParseUint(snapshotID[i+1:], 10, 64); err != nil {
+		return "", 0, newError(CodeInvalidArgument, "invalid snapshot ID(%v)", snapshotID)
+	}
+	return snapshotID[:i], id, nil
+}
+
+func toSnapshot(snap *proto.VolSnapshotInfo) *Snapshot {
+	return &Snapshot{
+		SnapshotId:     snapshotID(snap),
+		SourceVolumeId: snap.VolName,
+		CreationTime:   snap.CreateTime,
+		ReadyToUse:     snap.Status == proto.VolSnapshotAvailable,
+	}
+}
+
+// getSnapshot returns the snapshot of the volume by its ID, nil if it does not exist.
+func (cs *ControllerServer) getSnapshot(volName string, id uint64) (*proto.VolSnapshotInfo, error) {
+	snaps, err := cs.mc.AdminAPI().ListVolSnapshots(volName)
+	if err != nil {
+		return nil, masterError("list snapshots", err)
+	}
+	for _, snap := range snaps {
+		if snap.ID == id {
+			return snap, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateSnapshot takes the snapshot of the volume by the master. The snapshot of the same name is returned
+// if it is taken already, which fails if it is of another volume.
+func (cs *ControllerServer) CreateSnapshot(req *CreateSnapshotRequest) (resp *CreateSnapshotResponse, err error) {
+	if req.Name == "" || req.SourceVolumeId == "" {
+		return nil, newError(CodeInvalidArgument, "snapshot name or source volume ID missing")
+	}
+	snaps, err := cs.mc.AdminAPI().ListVolSnapshots("")
+	if err != nil {
+		return nil, masterError("list snapshots", err)
+	}
+	for _, snap := range snaps {
+		if snap.Name != req.Name {
+			continue
+		}
+		if snap.VolName != req.SourceVolumeId {
+			return nil, newError(CodeAlreadyExists, "snapshot(%v) exists of volume(%v)", req.Name, snap.VolName)
+		}
+		if snap.Status == proto.VolSnapshotFailed {
+			return nil, newError(CodeInternal, "snapshot(%v) failed, delete it and take again", snapshotID(snap))
+		}
+		return &CreateSnapshotResponse{Snapshot: toSnapshot(snap)}, nil
+	}
+	key, err := cs.authKey(req.SourceVolumeId, req.Secrets)
+	if err != nil {
+		return
+	}
+	snap, err := cs.mc.AdminAPI().CreateVolSnapshot(req.SourceVolumeId, req.Name, key)
+	if err != nil {
+		return nil, masterError("create snapshot", err)
+	}
+	return &CreateSnapshotResponse{Snapshot: toSnapshot(snap)}, nil
+}
+
+// DeleteSnapshot deletes the snapshot, the snapshot not existing is deleted already.
+func (cs *ControllerServer) DeleteSnapshot(req *DeleteSnapshotRequest) (resp *DeleteSnapshotResponse, err error) {
+	volName, id, err := parseSnapshotID(req.SnapshotId)
+	if err != nil {
+		return
+	}
+	snap, err := cs.getSnapshot(volName, id)
+	if err != nil {
+		return
+	}
+	if snap == nil {
+		return &DeleteSnapshotResponse{}, nil
+	}
+	key, err := cs.authKey(volName, req.Secrets)
+	if err != nil {
+		return
+	}
+	if err = cs.mc.AdminAPI().DeleteVolSnapshot(id, key); err != nil {
+		return nil, masterError("delete snapshot", err)
+	}
+	return &DeleteSnapshotResponse{}, nil
+}
+
+// ListSnapshots lists the snapshots by their IDs, of the volume or of the ID if given. The starting
+// token is the index of the next entry.
+func (cs *ControllerServer) ListSnapshots(req *ListSnapshotsRequest) (resp *ListSnapshotsResponse, err error) {
+	var (
+		volName string
+		id      uint64
+	)
+	if req.SnapshotId != "" {
+		if volName, id, err = parseSnapshotID(req.SnapshotId); err != nil {
+			// a snapshot ID not of the driver matches no snapshot
+			return &ListSnapshotsResponse{}, nil
+		}
+		if req.SourceVolumeId != "" && req.SourceVolumeId != volName {
+			return &ListSnapshotsResponse{}, nil
+		}
+	} else {
+		volName = req.SourceVolumeId
+	}
+	snaps, err := cs.mc.AdminAPI().ListVolSnapshots(volName)
+	if err != nil && err != proto.ErrVolNotExists {
+		return nil, masterError("list snapshots", err)
+	}
+	entries := make([]*Snapshot, 0, len(snaps))
+	for _, snap := range snaps {
+		if id == 0 || snap.ID == id {
+			entries = append(entries, toSnapshot(snap))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].SnapshotId < entries[j].SnapshotId })
+
+	start := 0
+	if req.StartingToken != "" {
+		if start, err = strconv.Atoi(req.StartingToken); err != nil || start < 0 || start > len(entries) {
+			return nil, newError(CodeAborted, "invalid starting token(%v)", req.StartingToken)
+		}
+	}
+	resp = &ListSnapshotsResponse{Entries: entries[start:]}
+	if req.MaxEntries > 0 && len(resp.Entries) > int(req.MaxEntries) {
+		resp.Entries = resp.Entries[:req.MaxEntries]
+		resp.NextToken = strconv.Itoa(start + int(req.MaxEntries))
+	}
+	return resp, nil
+}
+
+// RestoreSnapshot rolls the volume back to its snapshot, after which the clients should remount it.
+func (cs *ControllerServer) RestoreSnapshot(req *RestoreSnapshotRequest) (resp *RestoreSnapshotResponse, err error) {
+	volName, id, err := parseSnapshotID(req.SnapshotId)
+	if err != nil {
+		return
+	}
+	if req.VolumeId != volName {
+		return nil, newError(CodeInvalidArgument, "snapshot(%v) is restored to its volume only, not volume(%v)",
+			req.SnapshotId, req.VolumeId)
+	}
+	snap, err := cs.getSnapshot(volName, id)
+	if err != nil {
+		return
+	}
+	if snap == nil {
+		return nil, newError(CodeNotFound, "snapshot(%v) not found", req.SnapshotId)
+	}
+	if snap.Status != proto.VolSnapshotAvailable {
+		return nil, newError(CodeInvalidArgument, "snapshot(%v) is %v", req.SnapshotId, snap.Status)
+	}
+	key, err := cs.authKey(volName, req.Secrets)
+	if err != nil {
+		return
+	}
+	if snap, err = cs.mc.AdminAPI().RestoreVolSnapshot(id, key); err != nil {
+		return nil, masterError("restore snapshot", err)
+	}
+	return &RestoreSnapshotResponse{Snapshot: toSnapshot(snap)}, nil
+}
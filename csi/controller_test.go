@@ -0,0 +1,244 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package csi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util"
+)
+
+const (
+	testVol   = "csiVol"
+	testOwner = "cfs"
+)
+
+// fakeMaster serves the volume and the snapshot APIs of the master for the test volume.
+type fakeMaster struct {
+	sync.Mutex
+	capacity uint64
+	snaps    []*proto.VolSnapshotInfo
+	restored uint64
+}
+
+func (m *fakeMaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+	var (
+		data interface{}
+		err  error
+	)
+	r.ParseForm()
+	sum := md5.Sum([]byte(testOwner))
+	if key := r.FormValue("authKey"); key != "" && key != hex.EncodeToString(sum[:]) {
+		err = proto.ErrVolAuthKeyNotMatch
+	} else if name := r.FormValue("name"); name != "" && name != testVol {
+		err = proto.ErrVolNotExists
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err == nil {
+		switch r.URL.Path {
+		case proto.AdminGetVol:
+			data = &proto.SimpleVolView{Name: testVol, Owner: testOwner, Capacity: m.capacity}
+		case proto.AdminVolExpand:
+			capacity, _ := strconv.ParseUint(r.FormValue("capacity"), 10, 64)
+			if capacity <= m.capacity {
+				err = proto.ErrParamError
+			} else {
+				m.capacity = capacity
+			}
+		case proto.AdminCreateVolSnapshot:
+			snap := &proto.VolSnapshotInfo{ID: uint64(len(m.snaps) + 1), Name: r.FormValue("snapshot"),
+				VolName: testVol, Status: proto.VolSnapshotAvailable, CreateTime: 1}
+			m.snaps = append(m.snaps, snap)
+			data = snap
+		case proto.AdminListVolSnapshots:
+			data = m.snaps
+		case proto.AdminDeleteVolSnapshot:
+			for i, snap := range m.snaps {
+				if snap.ID == id {
+					m.snaps = append(m.snaps[:i], m.snaps[i+1:]...)
+					break
+				}
+			}
+		case proto.AdminRestoreVolSnapshot:
+			m.restored = id
+			for _, snap := range m.snaps {
+				if snap.ID == id {
+					data = snap
+				}
+			}
+		}
+	}
+	reply := &proto.HTTPReply{Code: proto.ErrCodeSuccess, Data: data}
+	if err != nil {
+		reply = &proto.HTTPReply{Code: proto.Err2CodeMap[err], Msg: err.Error()}
+	}
+	body, _ := json.Marshal(reply)
+	w.Write(body)
+}
+
+func newTestServer() (cs *ControllerServer, m *fakeMaster, close func()) {
+	m = &fakeMaster{capacity: 10}
+	server := httptest.NewServer(m)
+	mc := master.NewMasterClient([]string{strings.TrimPrefix(server.URL, "http://")}, false)
+	return NewControllerServer(mc), m, server.Close
+}
+
+func errCode(err error) Code {
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return 0
+}
+
+func TestControllerExpandVolume(t *testing.T) {
+	cs, m, close := newTestServer()
+	defer close()
+	cases := []struct {
+		name     string
+		volume   string
+		required int64
+		limit    int64
+		code     Code
+		capacity uint64
+	}{
+		{"expand", testVol, 20 * util.GB, 0, 0, 20},
+		{"round up", testVol, 20*util.GB + 1, 0, 0, 21},
+		{"already expanded", testVol, 15 * util.GB, 0, 0, 21},
+		{"limit exceeded", testVol, 30*util.GB + 1, 30 * util.GB, CodeOutOfRange, 21},
+		{"required missing", testVol, 0, 0, CodeInvalidArgument, 21},
+		{"volume not found", "otherVol", 30 * util.GB, 0, CodeNotFound, 21},
+	}
+	for _, c := range cases {
+		resp, err := cs.ControllerExpandVolume(&ControllerExpandVolumeRequest{VolumeId: c.volume,
+			CapacityRange: &CapacityRange{RequiredBytes: c.required, LimitBytes: c.limit}})
+		if errCode(err) != c.code {
+			t.Errorf("%v: err %v, expected code %v", c.name, err, c.code)
+		}
+		if err == nil && resp.CapacityBytes != int64(c.capacity*util.GB) {
+			t.Errorf("%v: capacity bytes %v, expected %vGB", c.name, resp.CapacityBytes, c.capacity)
+		}
+		if m.capacity != c.capacity {
+			t.Errorf("%v: volume capacity %vGB, expected %vGB", c.name, m.capacity, c.capacity)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	cs, m, close := newTestServer()
+	defer close()
+
+	resp, err := cs.CreateSnapshot(&CreateSnapshotRequest{SourceVolumeId: testVol, Name: "snap1"})
+	if err != nil {
+		t.Fatalf("create snapshot err %v", err)
+	}
+	if snap := resp.Snapshot; snap.SnapshotId != testVol+"/1" || !snap.ReadyToUse {
+		t.Fatalf("snapshot %v, expected ready %v/1", snap, testVol)
+	}
+	cases := []struct {
+		name   string
+		source string
+		snap   string
+		code   Code
+		id     string
+	}{
+		{"create again", testVol, "snap1", 0, testVol + "/1"},
+		{"create another", testVol, "snap2", 0, testVol + "/2"},
+		{"name of another volume", "otherVol", "snap1", CodeAlreadyExists, ""},
+		{"name missing", testVol, "", CodeInvalidArgument, ""},
+	}
+	for _, c := range cases {
+		resp, err = cs.CreateSnapshot(&CreateSnapshotRequest{SourceVolumeId: c.source, Name: c.snap})
+		if errCode(err) != c.code {
+			t.Errorf("%v: err %v, expected code %v", c.name, err, c.code)
+		}
+		if err == nil && resp.Snapshot.SnapshotId != c.id {
+			t.Errorf("%v: snapshot %v, expected %v", c.name, resp.Snapshot.SnapshotId, c.id)
+		}
+	}
+
+	listCases := []struct {
+		name    string
+		request *ListSnapshotsRequest
+		ids     string
+		next    string
+	}{
+		{"all", &ListSnapshotsRequest{}, "csiVol/1 csiVol/2", ""},
+		{"by volume", &ListSnapshotsRequest{SourceVolumeId: testVol}, "csiVol/1 csiVol/2", ""},
+		{"by ID", &ListSnapshotsRequest{SnapshotId: testVol + "/2"}, "csiVol/2", ""},
+		{"foreign ID", &ListSnapshotsRequest{SnapshotId: "snap"}, "", ""},
+		{"first page", &ListSnapshotsRequest{MaxEntries: 1}, "csiVol/1", "1"},
+		{"next page", &ListSnapshotsRequest{MaxEntries: 1, StartingToken: "1"}, "csiVol/2", ""},
+	}
+	for _, c := range listCases {
+		resp, err := cs.ListSnapshots(c.request)
+		if err != nil {
+			t.Fatalf("%v: list snapshots err %v", c.name, err)
+		}
+		ids := make([]string, 0, len(resp.Entries))
+		for _, snap := range resp.Entries {
+			ids = append(ids, snap.SnapshotId)
+		}
+		if strings.Join(ids, " ") != c.ids || resp.NextToken != c.next {
+			t.Errorf("%v: snapshots %v next %v, expected %v next %v", c.name, ids, resp.NextToken, c.ids, c.next)
+		}
+	}
+
+	restoreCases := []struct {
+		name   string
+		snap   string
+		volume string
+		code   Code
+	}{
+		{"restore", testVol + "/1", testVol, 0},
+		{"restore to another volume", testVol + "/1", "otherVol", CodeInvalidArgument},
+		{"snapshot not found", testVol + "/9", testVol, CodeNotFound},
+		{"invalid ID", "snap", testVol, CodeInvalidArgument},
+	}
+	for _, c := range restoreCases {
+		m.restored = 0
+		_, err := cs.RestoreSnapshot(&RestoreSnapshotRequest{SnapshotId: c.snap, VolumeId: c.volume})
+		if errCode(err) != c.code {
+			t.Errorf("%v: err %v, expected code %v", c.name, err, c.code)
+		}
+		if (m.restored == 1) != (c.code == 0) {
+			t.Errorf("%v: restored snapshot %v", c.name, m.restored)
+		}
+	}
+
+	for _, id := range []string{testVol + "/1", testVol + "/1"} {
+		if _, err = cs.DeleteSnapshot(&DeleteSnapshotRequest{SnapshotId: id}); err != nil {
+			t.Fatalf("delete snapshot %v err %v", id, err)
+		}
+	}
+	if len(m.snaps) != 1 || m.snaps[0].ID != 2 {
+		t.Errorf("snapshots %v after the delete, expected snapshot 2", m.snaps)
+	}
+	if _, err = cs.CreateSnapshot(&CreateSnapshotRequest{SourceVolumeId: testVol, Name: "snap3",
+		Secrets: map[string]string{SecretOwner: "other"}}); errCode(err) != CodeInvalidArgument {
+		t.Errorf("create snapshot by another owner err %v, expected code %v", err, CodeInvalidArgument)
+	}
+}
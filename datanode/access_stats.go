@@ -0,0 +1,164 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	ConfigKeyHotExtentCount = "hotExtentCount" // int, number of the hot extents reported to the master, a negative value disables the report
+
+	DefaultHotExtentCount = 100
+	AccessStatsWindow     = 60 // seconds
+)
+
+// accessStats counts the client reads and writes of a data partition and its extents. The
+// counts of the current window are moved to the last window when it ends, the last window
+// is the one reported to the master.
+type accessStats struct {
+	sync.Mutex
+	current proto.AccessStats
+	extents map[uint64]*proto.AccessStats
+	last    proto.AccessStats
+	written int64 // the time of the last write since the data node started
+}
+
+func newAccessStats() *accessStats {
+	return &accessStats{extents: make(map[uint64]*proto.AccessStats)}
+}
+
+func (a *accessStats) extent(extentID uint64) *proto.AccessStats {
+	stats, ok := a.extents[extentID]
+	if !ok {
+		stats = &proto.AccessStats{}
+		a.extents[extentID] = stats
+	}
+	return stats
+}
+
+func (a *accessStats) recordRead(extentID uint64, size uint32) {
+	a.Lock()
+	defer a.Unlock()
+	a.current.ReadOps++
+	a.current.ReadBytes += uint64(size)
+	stats := a.extent(extentID)
+	stats.ReadOps++
+	stats.ReadBytes += uint64(size)
+}
+
+func (a *accessStats) recordWrite(extentID uint64, size uint32) {
+	a.Lock()
+	defer a.Unlock()
+	a.current.WriteOps++
+	a.current.WriteBytes += uint64(size)
+	a.written = time.Now().Unix()
+	stats := a.extent(extentID)
+	stats.WriteOps++
+	stats.WriteBytes += uint64(size)
+}
+
+// roll ends the current window, and returns the accesses of the extents within it.
+func (a *accessStats) roll() (extents map[uint64]*proto.AccessStats) {
+	a.Lock()
+	defer a.Unlock()
+	a.last = a.current
+	a.current = proto.AccessStats{}
+	extents = a.extents
+	a.extents = make(map[uint64]*proto.AccessStats)
+	return
+}
+
+func (a *accessStats) lastWrite() int64 {
+	a.Lock()
+	defer a.Unlock()
+	return a.written
+}
+
+func (a *accessStats) lastWindow() proto.AccessStats {
+	a.Lock()
+	defer a.Unlock()
+	return a.last
+}
+
+// accessTracker rolls the access statistics of the data partitions every window, and keeps
+// the extents accessed the most within the last window.
+type accessTracker struct {
+	sync.Mutex
+	count      int
+	hotExtents []*proto.HotExtentReport
+}
+
+func (s *DataNode) startAccessTracker(cfg *config.Config) {
+	count := int(cfg.GetInt64(ConfigKeyHotExtentCount))
+	if count < 0 {
+		log.LogInfof("action[startAccessTracker] hot extent report is disabled")
+		count = 0
+	} else if count == 0 {
+		count = DefaultHotExtentCount
+	}
+	s.accessTracker = &accessTracker{count: count}
+	go s.trackAccess()
+}
+
+func (s *DataNode) trackAccess() {
+	ticker := time.NewTicker(AccessStatsWindow * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopC:
+			return
+		case <-ticker.C:
+			s.rollAccessStats()
+		}
+	}
+}
+
+func (s *DataNode) rollAccessStats() {
+	hotExtents := make([]*proto.HotExtentReport, 0)
+	s.space.RangePartitions(func(dp *DataPartition) bool {
+		for extentID, stats := range dp.accessStats.roll() {
+			hotExtents = append(hotExtents, &proto.HotExtentReport{PartitionID: dp.partitionID, ExtentID: extentID, AccessStats: *stats})
+		}
+		return true
+	})
+	sort.Slice(hotExtents, func(i, j int) bool {
+		return hotExtents[i].Ops() > hotExtents[j].Ops()
+	})
+	t := s.accessTracker
+	if len(hotExtents) > t.count {
+		hotExtents = hotExtents[:t.count]
+	}
+	t.Lock()
+	t.hotExtents = hotExtents
+	t.Unlock()
+}
+
+func (t *accessTracker) getHotExtents() (hotExtents []*proto.HotExtentReport) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	hotExtents = make([]*proto.HotExtentReport, len(t.hotExtents))
+	copy(hotExtents, t.hotExtents)
+	return
+}
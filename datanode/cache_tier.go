@@ -0,0 +1,147 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	ConfigKeyCacheDisks       = "cacheDisks"       // slice of "PATH:CAPACITY", the SSDs used as the cache tier of the HDDs
+	ConfigKeyCacheMode        = "cacheMode"        // string, writethrough or writeback
+	ConfigKeyCachePromoteHits = "cachePromoteHits" // int, reads of a block before it is promoted into the cache
+
+	DefaultCachePromoteHits = 2
+)
+
+// cacheTier holds the SSD caches shared by the partitions on the HDDs of the data node.
+type cacheTier struct {
+	caches []*storage.BlockCache
+	next   uint32
+}
+
+// initCacheTier opens the caches configured, it must be called before the partitions are
+// loaded so that the journals of the write-back caches can be replayed.
+func (s *DataNode) initCacheTier(cfg *config.Config) (err error) {
+	cacheDisks := cfg.GetSlice(ConfigKeyCacheDisks)
+	if len(cacheDisks) == 0 {
+		return
+	}
+	mode := cfg.GetString(ConfigKeyCacheMode)
+	if mode == "" {
+		mode = storage.CacheModeWriteThrough
+	}
+	promoteHits := int(cfg.GetInt64(ConfigKeyCachePromoteHits))
+	if promoteHits <= 0 {
+		promoteHits = DefaultCachePromoteHits
+	}
+	tier := new(cacheTier)
+	for _, d := range cacheDisks {
+		// format "PATH:CAPACITY"
+		arr := strings.Split(d.(string), ":")
+		if len(arr) != 2 {
+			return fmt.Errorf("invalid cache disk configuration(%v), example: PATH:CAPACITY", d)
+		}
+		capacity, err := strconv.ParseInt(arr[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cache disk capacity(%v): %v", arr[1], err)
+		}
+		cache, err := storage.NewBlockCache(arr[0], capacity, mode, promoteHits)
+		if err != nil {
+			return fmt.Errorf("open cache disk(%v): %v", arr[0], err)
+		}
+		tier.caches = append(tier.caches, cache)
+	}
+	s.cacheTier = tier
+	return
+}
+
+// assign returns the cache of a disk, the SSDs are not cached.
+func (t *cacheTier) assign(diskPath string) *storage.BlockCache {
+	if t == nil || !isRotationalDisk(diskPath) {
+		return nil
+	}
+	return t.caches[int(atomic.AddUint32(&t.next, 1)-1)%len(t.caches)]
+}
+
+// replay applies the writes of the store journaled by any of the caches, as the caches may
+// have been assigned to other disks before the restart.
+func (t *cacheTier) replay(store *storage.ExtentStore) {
+	if t == nil {
+		return
+	}
+	for _, cache := range t.caches {
+		cache.Replay(store)
+	}
+}
+
+func (t *cacheTier) finishReplay() {
+	if t == nil {
+		return
+	}
+	for _, cache := range t.caches {
+		cache.FinishReplay()
+	}
+}
+
+func (t *cacheTier) close() {
+	if t == nil {
+		return
+	}
+	for _, cache := range t.caches {
+		cache.Close()
+	}
+}
+
+// isRotationalDisk checks if the device of the path is a hard disk, the path is taken as
+// on a hard disk if the device is unknown.
+func isRotationalDisk(path string) bool {
+	device, err := mountDevice(path)
+	if err != nil {
+		log.LogWarnf("action[isRotationalDisk] path(%v) err(%v)", path, err)
+		return true
+	}
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	name := filepath.Base(device)
+	for _, queue := range []string{"/sys/class/block/" + name + "/queue", "/sys/class/block/" + name + "/../queue"} {
+		data, err := os.ReadFile(queue + "/rotational")
+		if err == nil {
+			return strings.TrimSpace(string(data)) != "0"
+		}
+	}
+	return true
+}
+
+func (s *DataNode) getCacheStats(w http.ResponseWriter, r *http.Request) {
+	stats := make([]*storage.BlockCacheStats, 0)
+	if s.cacheTier != nil {
+		for _, cache := range s.cacheTier.caches {
+			stats = append(stats, cache.Stats())
+		}
+	}
+	s.buildSuccessResp(w, stats)
+}
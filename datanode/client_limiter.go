@@ -0,0 +1,231 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// clientLimiter limits the connections and the requests in flight of each client host on the data node,
+// and sheds the clients which read the responses too slowly, so that a misbehaving host cannot exhaust
+// the goroutines and the buffers of the data node. The other data nodes and the masters are not limited.
+type clientLimiter struct {
+	sync.Mutex
+	maxConns      int           // connections per client host, 0 if not limited
+	maxInflight   int           // requests in flight per client host, 0 if not limited
+	slowThreshold time.Duration // time a response write may block before it counts as slow, 0 if not detected
+	clients       map[string]*clientState
+
+	peersOf     func() map[string]bool
+	peers       map[string]bool
+	peersUpdate time.Time
+}
+
+type clientState struct {
+	conns     int
+	inflight  int
+	shedUntil time.Time // the new connections are refused until then after the client has been shed
+}
+
+func newClientLimiter(maxConns, maxInflight int, slowThreshold time.Duration, peersOf func() map[string]bool) *clientLimiter {
+	return &clientLimiter{
+		maxConns:      maxConns,
+		maxInflight:   maxInflight,
+		slowThreshold: slowThreshold,
+		clients:       make(map[string]*clientState),
+		peersOf:       peersOf,
+	}
+}
+
+func (l *clientLimiter) enabled() bool {
+	return l != nil && (l.maxConns > 0 || l.maxInflight > 0 || l.slowThreshold > 0)
+}
+
+// isPeer returns true if the host is another data node or a master. Must be called with the lock held.
+func (l *clientLimiter) isPeer(ip string) bool {
+	if l.peersOf == nil {
+		return false
+	}
+	if time.Since(l.peersUpdate) > ClientPeerRefreshInterval {
+		l.peers = l.peersOf()
+		l.peersUpdate = time.Now()
+	}
+	return l.peers[ip]
+}
+
+func (l *clientLimiter) state(ip string) *clientState {
+	st := l.clients[ip]
+	if st == nil {
+		st = new(clientState)
+		l.clients[ip] = st
+	}
+	return st
+}
+
+// release drops the state of the client once nothing refers to it. Must be called with the lock held.
+func (l *clientLimiter) release(ip string, st *clientState) {
+	if st.conns <= 0 && st.inflight <= 0 && time.Now().After(st.shedUntil) {
+		delete(l.clients, ip)
+	}
+}
+
+// admit counts a new connection of the client, it returns the connection the packets are served on,
+// or false if the connection has to be refused.
+func (l *clientLimiter) admit(conn net.Conn) (c net.Conn, ok bool) {
+	if !l.enabled() {
+		return conn, true
+	}
+	ip := hostOfConn(conn)
+	l.Lock()
+	defer l.Unlock()
+	if l.isPeer(ip) {
+		return conn, true
+	}
+	st := l.state(ip)
+	if time.Now().Before(st.shedUntil) {
+		log.LogWarnf("action[clientLimiter.admit] refuse connection from shed client(%v) until(%v)", ip, st.shedUntil)
+		return nil, false
+	}
+	if l.maxConns > 0 && st.conns >= l.maxConns {
+		log.LogWarnf("action[clientLimiter.admit] refuse connection from client(%v) conns(%v) limit(%v)", ip, st.conns, l.maxConns)
+		l.release(ip, st)
+		return nil, false
+	}
+	st.conns++
+	return &clientConn{Conn: conn, limiter: l, ip: ip}, true
+}
+
+// wrap returns the stream of an admitted connection limited with the connection.
+func (l *clientLimiter) wrap(stream net.Conn, conn net.Conn) net.Conn {
+	if c, ok := conn.(*clientConn); ok {
+		return &clientConn{Conn: stream, limiter: l, ip: c.ip, stream: true}
+	}
+	return stream
+}
+
+// shed refuses the new connections of the client for a while.
+func (l *clientLimiter) shed(ip string) {
+	l.Lock()
+	l.state(ip).shedUntil = time.Now().Add(ClientShedTime)
+	l.Unlock()
+}
+
+// clientConn is a connection of a limited client. It counts the requests in flight of the connection,
+// and is closed when the writes of the responses keep blocking longer than the slow threshold.
+type clientConn struct {
+	net.Conn
+	limiter *clientLimiter
+	ip      string
+	stream  bool // a stream of a multiplexed connection, only the connection is counted
+
+	mu         sync.Mutex
+	inflight   int
+	slowWrites int
+	closed     bool
+}
+
+// AcquireRequest implements repl.RequestLimiter.
+func (c *clientConn) AcquireRequest() bool {
+	l := c.limiter
+	l.Lock()
+	defer l.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	st := l.state(c.ip)
+	if l.maxInflight > 0 && st.inflight >= l.maxInflight {
+		log.LogWarnf("action[clientConn.AcquireRequest] reject request from client(%v) inflight(%v) limit(%v)",
+			c.ip, st.inflight, l.maxInflight)
+		return false
+	}
+	st.inflight++
+	c.inflight++
+	return true
+}
+
+// ReleaseRequest implements repl.RequestLimiter.
+func (c *clientConn) ReleaseRequest() {
+	l := c.limiter
+	l.Lock()
+	defer l.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.inflight == 0 {
+		return
+	}
+	c.inflight--
+	st := l.state(c.ip)
+	st.inflight--
+	l.release(c.ip, st)
+}
+
+func (c *clientConn) Write(b []byte) (n int, err error) {
+	start := time.Now()
+	n, err = c.Conn.Write(b)
+	threshold := c.limiter.slowThreshold
+	if threshold <= 0 {
+		return
+	}
+	c.mu.Lock()
+	if time.Since(start) <= threshold {
+		c.slowWrites = 0
+		c.mu.Unlock()
+		return
+	}
+	c.slowWrites++
+	shed := c.slowWrites >= ClientMaxSlowWrites && !c.closed
+	c.mu.Unlock()
+	if shed {
+		log.LogWarnf("action[clientConn.Write] shed slow client(%v) local(%v) blocked(%v)",
+			c.ip, c.LocalAddr(), time.Since(start))
+		c.limiter.shed(c.ip)
+		c.Close()
+	}
+	return
+}
+
+// Close closes the connection and gives back the requests still in flight and the connection itself.
+func (c *clientConn) Close() error {
+	l := c.limiter
+	l.Lock()
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		st := l.state(c.ip)
+		st.inflight -= c.inflight
+		c.inflight = 0
+		if !c.stream {
+			st.conns--
+		}
+		l.release(c.ip, st)
+	}
+	c.mu.Unlock()
+	l.Unlock()
+	return c.Conn.Close()
+}
+
+func hostOfConn(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
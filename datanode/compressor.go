@@ -0,0 +1,156 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ConfigKeyCompressRate     = "compressRate"     // int, bytes read per second, a negative value disables the compressor
+	ConfigKeyCompressInterval = "compressInterval" // int, seconds between two rounds
+	ConfigKeyCompressColdTime = "compressColdTime" // int, seconds an extent is not modified before being compressed
+
+	DefaultCompressRate     = 20 * util.MB
+	DefaultCompressInterval = 60 * 60
+	DefaultCompressColdTime = 24 * 60 * 60
+)
+
+// compressor compresses the cold extents of the volumes whose compression is set on the
+// master, the compressions are carried by the heartbeats of the master.
+type compressor struct {
+	sync.RWMutex
+	limiter         *rate.Limiter
+	interval        time.Duration
+	coldTime        int64
+	volCompressions map[string]string
+}
+
+func (s *DataNode) startCompressor(cfg *config.Config) {
+	compressRate := cfg.GetInt64(ConfigKeyCompressRate)
+	if compressRate < 0 {
+		log.LogInfof("action[startCompressor] compressor is disabled")
+		return
+	}
+	if compressRate == 0 {
+		compressRate = DefaultCompressRate
+	}
+	interval := cfg.GetInt64(ConfigKeyCompressInterval)
+	if interval <= 0 {
+		interval = DefaultCompressInterval
+	}
+	coldTime := cfg.GetInt64(ConfigKeyCompressColdTime)
+	if coldTime <= storage.UpdateCrcInterval {
+		coldTime = DefaultCompressColdTime
+	}
+	s.compressor = &compressor{
+		limiter:         rate.NewLimiter(rate.Limit(compressRate), util.BlockSize),
+		interval:        time.Duration(interval) * time.Second,
+		coldTime:        coldTime,
+		volCompressions: make(map[string]string),
+	}
+	log.LogInfof("action[startCompressor] rate(%v) interval(%v) coldTime(%v)", compressRate, s.compressor.interval, coldTime)
+	go s.compress()
+}
+
+func (s *DataNode) compress() {
+	for {
+		select {
+		case <-s.stopC:
+			return
+		case <-time.After(s.compressor.interval):
+		}
+		partitions := make([]*DataPartition, 0)
+		s.space.RangePartitions(func(dp *DataPartition) bool {
+			partitions = append(partitions, dp)
+			return true
+		})
+		for _, dp := range partitions {
+			select {
+			case <-s.stopC:
+				return
+			default:
+			}
+			if compression := s.compressor.getVolCompression(dp.volumeID); compression != "" {
+				s.compressPartition(dp, compression)
+			}
+			blocks, savedBytes := dp.ExtentStore().CompressStats()
+			labels := map[string]string{exporter.Vol: dp.volumeID, exporter.Disk: dp.disk.Path}
+			s.metrics.MetricCompressedBlock.SetWithLabels(float64(blocks), labels)
+			s.metrics.MetricCompressSavedBytes.SetWithLabels(float64(savedBytes), labels)
+		}
+	}
+}
+
+func (s *DataNode) compressPartition(dp *DataPartition, compression string) {
+	if dp.ECStatus() != proto.ECStatusNone {
+		// the extents are erasure coded
+		return
+	}
+	store := dp.ExtentStore()
+	extents, _, err := store.GetAllWatermarks(storage.NormalExtentFilter())
+	if err != nil {
+		log.LogWarnf("action[compressPartition] partition(%v) err(%v)", dp.partitionID, err)
+		return
+	}
+	for _, ei := range extents {
+		if time.Now().Unix()-ei.ModifyTime <= s.compressor.coldTime {
+			continue
+		}
+		compressed, err := store.CompressExtent(ei.FileID, compression, s.compressor.wait)
+		if err != nil {
+			dp.checkIsDiskError(err)
+			log.LogWarnf("action[compressPartition] partition(%v) extent(%v) err(%v)", dp.partitionID, ei.FileID, err)
+			continue
+		}
+		if compressed > 0 {
+			log.LogDebugf("action[compressPartition] partition(%v) extent(%v) compressed blocks(%v)",
+				dp.partitionID, ei.FileID, compressed)
+		}
+	}
+}
+
+func (c *compressor) wait(n int) {
+	c.limiter.WaitN(context.Background(), n)
+}
+
+func (c *compressor) getVolCompression(volName string) string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.volCompressions[volName]
+}
+
+// setVolCompressions replaces the compressions of the volumes with the ones from the master.
+func (c *compressor) setVolCompressions(volCompressions map[string]string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.volCompressions = make(map[string]string, len(volCompressions))
+	for name, compression := range volCompressions {
+		c.volCompressions[name] = compression
+	}
+}
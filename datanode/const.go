@@ -47,7 +47,11 @@ const (
 	ActionStreamRead                    = "ActionStreamRead"
 	ActionCreateExtent                  = "ActionCreateExtent:"
 	ActionMarkDelete                    = "ActionMarkDelete:"
+	ActionPunchHole                     = "ActionPunchHole:"
+	ActionPreallocExtent                = "ActionPreallocExtent:"
 	ActionGetAllExtentWatermarks        = "ActionGetAllExtentWatermarks:"
+	ActionGetExtentCrcs                 = "ActionGetExtentCrcs:"
+	ActionReadECShard                   = "ActionReadECShard:"
 	ActionWrite                         = "ActionWrite:"
 	ActionRepair                        = "ActionRepair:"
 	ActionDecommissionPartition         = "ActionDecommissionPartition"
@@ -57,6 +61,12 @@ const (
 
 	ActionCreateDataPartition        = "ActionCreateDataPartition"
 	ActionLoadDataPartition          = "ActionLoadDataPartition"
+	ActionCheckDataPartition         = "ActionCheckDataPartition"
+	ActionBackupDataPartition        = "ActionBackupDataPartition"
+	ActionRestoreDataPartition       = "ActionRestoreDataPartition"
+	ActionConvertDataPartitionToEC   = "ActionConvertDataPartitionToEC"
+	ActionFenceWrites                = "ActionFenceWrites"
+	ActionSealExtents                = "ActionSealExtents"
 	ActionDeleteDataPartition        = "ActionDeleteDataPartition"
 	ActionStreamReadTinyDeleteRecord = "ActionStreamReadTinyDeleteRecord"
 	ActionSyncTinyDeleteRecord       = "ActionSyncTinyDeleteRecord"
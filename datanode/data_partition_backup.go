@@ -0,0 +1,375 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The backup is triggered by the master on the leader of a data partition. The leader takes
+// the watermarks of the extents, uploads every extent up to its watermark as an object under
+// the path of the backup, and uploads the manifest of the extents last, so a backup without a
+// manifest is incomplete. The data is read through the extent store, so it is uploaded
+// decrypted and uncompressed. The restore is triggered on every replica of a data partition,
+// which downloads the extents missing locally or shorter than in the manifest.
+
+const (
+	backupManifestName  = "manifest.json"
+	backupExtentsDir    = "extents"
+	backupManifestMagic = "CFSDPBACKUP1"
+)
+
+// backupManifest defines the extents of a backup of a data partition.
+type backupManifest struct {
+	Magic        string
+	VolName      string
+	PartitionID  uint64
+	ChecksumType uint8
+	CreateTime   int64
+	Extents      []*backupExtent
+}
+
+// backupExtent defines an extent of a backup, the crc is the IEEE CRC32 of its data.
+type backupExtent struct {
+	ExtentID uint64
+	Size     uint64
+	Crc      uint32
+}
+
+func newBackupClient(store *proto.BackupStore) (client *s3.S3, err error) {
+	if store == nil || store.Endpoint == "" || store.Bucket == "" {
+		return nil, fmt.Errorf("the backup store is not configured")
+	}
+	sess, err := session.NewSession()
+	if err != nil {
+		return
+	}
+	config := aws.NewConfig()
+	config.Endpoint = aws.String(store.Endpoint)
+	config.Region = aws.String(store.Region)
+	if store.Region == "" {
+		config.Region = aws.String("default")
+	}
+	config.Credentials = credentials.NewStaticCredentials(store.AccessKey, store.SecretKey, "")
+	config.S3ForcePathStyle = aws.Bool(true)
+	return s3.New(sess, config), nil
+}
+
+func backupExtentKey(path string, extentID uint64) string {
+	return path + "/" + backupExtentsDir + "/" + strconv.FormatUint(extentID, 10)
+}
+
+// extentReader reads an extent up to the given size as an io.ReadSeeker. The CRC of the data
+// is computed while it is read sequentially for the first time, as the S3 client may read
+// the body again after seeking back to the start.
+type extentReader struct {
+	dp       *DataPartition
+	extentID uint64
+	size     int64
+	offset   int64
+	hashed   int64
+	hash     hash.Hash32
+}
+
+func newExtentReader(dp *DataPartition, extentID uint64, size int64) *extentReader {
+	return &extentReader{dp: dp, extentID: extentID, size: size, hash: crc32.NewIEEE()}
+}
+
+func (r *extentReader) Read(p []byte) (n int, err error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	n = len(p)
+	if n > util.BlockSize {
+		n = util.BlockSize
+	}
+	if remain := r.size - r.offset; int64(n) > remain {
+		n = int(remain)
+	}
+	if _, err = r.dp.readExtent(r.extentID, r.offset, int64(n), p[:n], true); err != nil {
+		return 0, err
+	}
+	r.dp.disk.WaitIO(n, true)
+	if r.offset == r.hashed {
+		r.hash.Write(p[:n])
+		r.hashed += int64(n)
+	}
+	r.offset += int64(n)
+	return
+}
+
+func (r *extentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %v", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %v", offset)
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// crc returns the CRC of the whole data, reading the part not read yet.
+func (r *extentReader) crc() (crc uint32, err error) {
+	if r.hashed < r.size {
+		if _, err = r.Seek(r.hashed, io.SeekStart); err != nil {
+			return
+		}
+		if _, err = io.Copy(ioutil.Discard, r); err != nil {
+			return
+		}
+	}
+	return r.hash.Sum32(), nil
+}
+
+// Backup uploads the extents of the data partition to the objects under the path.
+func (dp *DataPartition) Backup(request *proto.BackupDataPartitionRequest) (response *proto.BackupDataPartitionResponse) {
+	response = &proto.BackupDataPartitionResponse{
+		PartitionId: dp.partitionID,
+		Path:        request.Path,
+		StartTime:   time.Now().Unix(),
+	}
+	defer func() {
+		response.EndTime = time.Now().Unix()
+	}()
+	if err := dp.backup(request, response); err != nil {
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		log.LogErrorf("action[Backup] partition(%v) path(%v) err(%v)", dp.partitionID, request.Path, err)
+		return
+	}
+	response.Status = proto.TaskSucceeds
+	log.LogInfof("action[Backup] partition(%v) path(%v) extents(%v) bytes(%v) cost(%vs)",
+		dp.partitionID, request.Path, response.Extents, response.Bytes, time.Now().Unix()-response.StartTime)
+	return
+}
+
+func (dp *DataPartition) backup(request *proto.BackupDataPartitionRequest, response *proto.BackupDataPartitionResponse) (err error) {
+	if _, isLeader := dp.IsRaftLeader(); !isLeader {
+		return fmt.Errorf("not the leader of the partition")
+	}
+	client, err := newBackupClient(request.Store)
+	if err != nil {
+		return
+	}
+	store := dp.ExtentStore()
+	extents, _, err := store.GetAllWatermarks(nil)
+	if err != nil {
+		return
+	}
+	sort.Slice(extents, func(i, j int) bool { return extents[i].FileID < extents[j].FileID })
+	manifest := &backupManifest{
+		Magic:        backupManifestMagic,
+		VolName:      dp.volumeID,
+		PartitionID:  dp.partitionID,
+		ChecksumType: store.ChecksumType(),
+		CreateTime:   time.Now().Unix(),
+		Extents:      make([]*backupExtent, 0, len(extents)),
+	}
+	for _, ei := range extents {
+		if ei.Size == 0 || store.IsDeletedNormalExtent(ei.FileID) {
+			continue
+		}
+		reader := newExtentReader(dp, ei.FileID, int64(ei.Size))
+		_, err = client.PutObject(&s3.PutObjectInput{
+			Bucket:        aws.String(request.Store.Bucket),
+			Key:           aws.String(backupExtentKey(request.Path, ei.FileID)),
+			Body:          reader,
+			ContentLength: aws.Int64(int64(ei.Size)),
+		})
+		if err != nil {
+			return fmt.Errorf("upload extent(%v): %v", ei.FileID, err)
+		}
+		extent := &backupExtent{ExtentID: ei.FileID, Size: ei.Size}
+		if extent.Crc, err = reader.crc(); err != nil {
+			return fmt.Errorf("read extent(%v): %v", ei.FileID, err)
+		}
+		manifest.Extents = append(manifest.Extents, extent)
+		response.Extents++
+		response.Bytes += ei.Size
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return
+	}
+	_, err = client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(request.Store.Bucket),
+		Key:    aws.String(request.Path + "/" + backupManifestName),
+		Body:   bytes.NewReader(data),
+	})
+	return
+}
+
+// Restore downloads the extents of the backup under the path which are missing locally or
+// shorter than in the backup.
+func (dp *DataPartition) Restore(request *proto.RestoreDataPartitionRequest) (response *proto.RestoreDataPartitionResponse) {
+	response = &proto.RestoreDataPartitionResponse{
+		PartitionId: dp.partitionID,
+		Path:        request.Path,
+		StartTime:   time.Now().Unix(),
+	}
+	defer func() {
+		response.EndTime = time.Now().Unix()
+	}()
+	if err := dp.restore(request, response); err != nil {
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		log.LogErrorf("action[Restore] partition(%v) path(%v) err(%v)", dp.partitionID, request.Path, err)
+		return
+	}
+	response.Status = proto.TaskSucceeds
+	log.LogInfof("action[Restore] partition(%v) path(%v) extents(%v) bytes(%v) cost(%vs)",
+		dp.partitionID, request.Path, response.Extents, response.Bytes, time.Now().Unix()-response.StartTime)
+	return
+}
+
+func (dp *DataPartition) restore(request *proto.RestoreDataPartitionRequest, response *proto.RestoreDataPartitionResponse) (err error) {
+	if dp.ECStatus() != proto.ECStatusNone {
+		return proto.ErrDataPartitionEC
+	}
+	client, err := newBackupClient(request.Store)
+	if err != nil {
+		return
+	}
+	output, err := client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(request.Store.Bucket),
+		Key:    aws.String(request.Path + "/" + backupManifestName),
+	})
+	if err != nil {
+		return fmt.Errorf("download manifest: %v", err)
+	}
+	manifest := &backupManifest{}
+	err = json.NewDecoder(output.Body).Decode(manifest)
+	output.Body.Close()
+	if err != nil {
+		return fmt.Errorf("decode manifest: %v", err)
+	}
+	if manifest.Magic != backupManifestMagic {
+		return fmt.Errorf("invalid manifest magic(%v)", manifest.Magic)
+	}
+	for _, extent := range manifest.Extents {
+		var restored uint64
+		if restored, err = dp.restoreExtent(client, request, extent); err != nil {
+			return fmt.Errorf("restore extent(%v): %v", extent.ExtentID, err)
+		}
+		if restored > 0 {
+			response.Extents++
+			response.Bytes += restored
+		}
+	}
+	return
+}
+
+// restoreExtent writes the data of the extent from the local size to the size in the backup,
+// and returns the size written. The whole object is read to verify its CRC.
+func (dp *DataPartition) restoreExtent(client *s3.S3, request *proto.RestoreDataPartitionRequest, extent *backupExtent) (restored uint64, err error) {
+	store := dp.ExtentStore()
+	if store.IsDeletedNormalExtent(extent.ExtentID) {
+		return
+	}
+	if !store.HasExtent(extent.ExtentID) {
+		if err = store.Create(extent.ExtentID); err != nil && err != storage.ExtentExistsError {
+			return
+		}
+	}
+	ei, err := store.Watermark(extent.ExtentID)
+	if err != nil {
+		return
+	}
+	localSize := ei.Size
+	if localSize >= extent.Size {
+		return
+	}
+	isTiny := storage.IsTinyExtent(extent.ExtentID)
+	output, err := client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(request.Store.Bucket),
+		Key:    aws.String(backupExtentKey(request.Path, extent.ExtentID)),
+	})
+	if err != nil {
+		return
+	}
+	defer output.Body.Close()
+	hash := crc32.NewIEEE()
+	data := make([]byte, util.BlockSize)
+	var offset uint64
+	for offset < extent.Size {
+		size := uint64(util.BlockSize)
+		if remain := extent.Size - offset; size > remain {
+			size = remain
+		}
+		if _, err = io.ReadFull(output.Body, data[:size]); err != nil {
+			return
+		}
+		hash.Write(data[:size])
+		if end := offset + size; end > localSize {
+			// the data is appended from the local size, which may be inside the block
+			start := offset
+			if start < localSize {
+				start = localSize
+			}
+			buf := data[start-offset : size]
+			if isTiny {
+				err = store.TinyExtentRecover(extent.ExtentID, int64(start), int64(len(buf)), buf, 0, false)
+			} else {
+				err = store.Write(extent.ExtentID, int64(start), int64(len(buf)), buf, 0, storage.AppendWriteType, BufferWrite)
+			}
+			if err != nil {
+				return
+			}
+			dp.disk.WaitIO(len(buf), true)
+			restored += end - start
+		}
+		offset += size
+	}
+	if crc := hash.Sum32(); crc != extent.Crc {
+		err = fmt.Errorf("crc mismatch, expected(%v) actual(%v)", extent.Crc, crc)
+	}
+	return
+}
+
+// isBackingUp returns false if the data partition starts to be backed up or restored, and
+// true if it is already being backed up or restored.
+func (dp *DataPartition) isBackingUp() bool {
+	return !atomic.CompareAndSwapInt32(&dp.backingUp, 0, 1)
+}
+
+func (dp *DataPartition) finishBackingUp() {
+	atomic.StoreInt32(&dp.backingUp, 0)
+}
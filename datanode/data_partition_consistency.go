@@ -0,0 +1,408 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The consistency check is triggered by the master on the leader of a data partition. The
+// leader collects the size and the CRC of the whole data of every normal extent from all the
+// replicas, reports the extents which differ, and repairs the replicas differing from the
+// majority if asked to. The extents modified recently are not compared, as their replicas
+// may still be catching up with the writes.
+
+// extentCrcRequest defines the request of the CRCs of the extents of a replica.
+type extentCrcRequest struct {
+	ExtentIDs []uint64 // all the normal extents if empty
+	BlockCrcs bool     // return the CRCs of the blocks as well
+}
+
+// extentCrcInfo defines the CRCs of an extent on a replica.
+type extentCrcInfo struct {
+	ExtentID   uint64
+	Size       uint64
+	Crc        uint32 // the CRC of the CRCs of the blocks
+	ModifyTime int64
+	BlockCrcs  []uint32 `json:",omitempty"`
+}
+
+// BlockRepairInfo defines a block to be rewritten with the data of the source replica.
+type BlockRepairInfo struct {
+	ExtentID uint64
+	BlockNo  int
+	Crc      uint32
+	Source   string
+}
+
+// computeExtentCrcs reads the extents of the partition to compute their CRCs, the reads are
+// throttled as the repair IO of the disk.
+func (dp *DataPartition) computeExtentCrcs(request *extentCrcRequest) (infos []*extentCrcInfo, err error) {
+	store := dp.ExtentStore()
+	extents, _, err := store.GetAllWatermarks(storage.NormalExtentFilter())
+	if err != nil {
+		return
+	}
+	wanted := make(map[uint64]bool, len(request.ExtentIDs))
+	for _, extentID := range request.ExtentIDs {
+		wanted[extentID] = true
+	}
+	data := make([]byte, util.BlockSize)
+	infos = make([]*extentCrcInfo, 0, len(extents))
+	for _, ei := range extents {
+		if len(wanted) > 0 && !wanted[ei.FileID] {
+			continue
+		}
+		info := &extentCrcInfo{ExtentID: ei.FileID, Size: ei.Size, ModifyTime: ei.ModifyTime}
+		blockCrcs := make([]uint32, 0, (ei.Size+util.BlockSize-1)/util.BlockSize)
+		for offset := int64(0); offset < int64(ei.Size); offset += util.BlockSize {
+			size := util.Min(util.BlockSize, int(int64(ei.Size)-offset))
+			limiterWaitN(dp.disk.repairIOLimiter, size)
+			crc, readErr := store.Read(ei.FileID, offset, int64(size), data, true)
+			if readErr == storage.ExtentNotFoundError {
+				blockCrcs = nil
+				break
+			}
+			if readErr != nil {
+				// the block unreadable differs from the replicas, so that it gets repaired
+				log.LogWarnf("action[computeExtentCrcs] partition(%v) extent(%v) offset(%v) err(%v)",
+					dp.partitionID, ei.FileID, offset, readErr)
+				crc = 0
+			}
+			blockCrcs = append(blockCrcs, crc)
+		}
+		if blockCrcs == nil && ei.Size > 0 {
+			continue
+		}
+		buf := make([]byte, 4*len(blockCrcs))
+		for i, crc := range blockCrcs {
+			binary.BigEndian.PutUint32(buf[4*i:], crc)
+		}
+		info.Crc = crc32.ChecksumIEEE(buf)
+		if request.BlockCrcs {
+			info.BlockCrcs = blockCrcs
+		}
+		infos = append(infos, info)
+	}
+	return
+}
+
+func (dp *DataPartition) getExtentCrcs(addr string, request *extentCrcRequest) (infos []*extentCrcInfo, err error) {
+	if addr == dp.dataNode.localServerAddr {
+		return dp.computeExtentCrcs(request)
+	}
+	p := repl.NewPacketToGetExtentCrcs(dp.partitionID)
+	if p.Data, err = json.Marshal(request); err != nil {
+		return
+	}
+	p.Size = uint32(len(p.Data))
+	var conn net.Conn
+	if conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(addr)); err != nil {
+		return
+	}
+	defer func() {
+		gConnPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	reply := new(repl.Packet)
+	if err = reply.ReadFromConn(conn, proto.NoReadDeadlineTime); err != nil {
+		return
+	}
+	if reply.ResultCode != proto.OpOk {
+		return nil, fmt.Errorf("replica(%v) error(%v)", addr, string(reply.Data[:reply.Size]))
+	}
+	err = json.Unmarshal(reply.Data[:reply.Size], &infos)
+	return
+}
+
+// CheckConsistency compares the extents of all the replicas of the partition, it must be
+// called on the leader.
+func (dp *DataPartition) CheckConsistency(repair bool) (response *proto.CheckDataPartitionResponse) {
+	response = &proto.CheckDataPartitionResponse{
+		PartitionId: dp.partitionID,
+		Repair:      repair,
+		StartTime:   time.Now().Unix(),
+		Diffs:       make([]*proto.ExtentDiff, 0),
+	}
+	defer func() {
+		response.EndTime = time.Now().Unix()
+	}()
+	if _, isLeader := dp.IsRaftLeader(); !isLeader {
+		response.Status = proto.TaskFailed
+		response.Result = "not the leader of the partition"
+		return
+	}
+	hosts := dp.getReplicaCopy()
+	response.Hosts = hosts
+
+	replicaInfos, err := dp.collectExtentCrcs(hosts, &extentCrcRequest{})
+	if err != nil {
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		return
+	}
+	extentIDs := make([]uint64, 0)
+	for _, infos := range replicaInfos {
+		for extentID := range infos {
+			extentIDs = append(extentIDs, extentID)
+		}
+	}
+	extentIDs = uniqueExtentIDs(extentIDs)
+
+	store := dp.ExtentStore()
+	now := time.Now().Unix()
+	for _, extentID := range extentIDs {
+		if store.IsDeletedNormalExtent(extentID) || isModifiedRecently(replicaInfos, extentID, now) {
+			response.SkippedExtents++
+			continue
+		}
+		response.CheckedExtents++
+		if diff := compareExtentReplicas(hosts, replicaInfos, extentID); diff != nil {
+			response.Diffs = append(response.Diffs, diff)
+		}
+	}
+	if repair && len(response.Diffs) > 0 {
+		dp.repairFromMajority(hosts, replicaInfos, response.Diffs)
+	}
+	response.Status = proto.TaskSucceeds
+	log.LogInfof("action[CheckConsistency] partition(%v) checked(%v) skipped(%v) diffs(%v) repair(%v)",
+		dp.partitionID, response.CheckedExtents, response.SkippedExtents, len(response.Diffs), repair)
+	return
+}
+
+// collectExtentCrcs returns the extents of each replica, in the order of the hosts.
+func (dp *DataPartition) collectExtentCrcs(hosts []string, request *extentCrcRequest) (replicaInfos []map[uint64]*extentCrcInfo, err error) {
+	replicaInfos = make([]map[uint64]*extentCrcInfo, len(hosts))
+	errs := make([]error, len(hosts))
+	wg := new(sync.WaitGroup)
+	for i, addr := range hosts {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			infos, err := dp.getExtentCrcs(addr, request)
+			if err != nil {
+				errs[i] = fmt.Errorf("get extent crcs from replica(%v): %v", addr, err)
+				return
+			}
+			replicaInfos[i] = make(map[uint64]*extentCrcInfo, len(infos))
+			for _, info := range infos {
+				replicaInfos[i][info.ExtentID] = info
+			}
+		}(i, addr)
+	}
+	wg.Wait()
+	for _, err = range errs {
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func uniqueExtentIDs(extentIDs []uint64) []uint64 {
+	sort.Slice(extentIDs, func(i, j int) bool { return extentIDs[i] < extentIDs[j] })
+	unique := extentIDs[:0]
+	for i, extentID := range extentIDs {
+		if i == 0 || extentID != extentIDs[i-1] {
+			unique = append(unique, extentID)
+		}
+	}
+	return unique
+}
+
+func isModifiedRecently(replicaInfos []map[uint64]*extentCrcInfo, extentID uint64, now int64) bool {
+	for _, infos := range replicaInfos {
+		if info := infos[extentID]; info != nil && now-info.ModifyTime <= storage.UpdateCrcInterval {
+			return true
+		}
+	}
+	return false
+}
+
+// compareExtentReplicas returns the difference of the replicas of the extent, nil if they
+// are the same.
+func compareExtentReplicas(hosts []string, replicaInfos []map[uint64]*extentCrcInfo, extentID uint64) (diff *proto.ExtentDiff) {
+	type version struct {
+		exist bool
+		size  uint64
+		crc   uint32
+	}
+	counts := make(map[version]int)
+	versions := make([]version, len(hosts))
+	states := make([]*proto.ExtentReplicaState, len(hosts))
+	for i, addr := range hosts {
+		state := &proto.ExtentReplicaState{Addr: addr}
+		if info := replicaInfos[i][extentID]; info != nil {
+			state.Exist, state.Size, state.Crc = true, info.Size, info.Crc
+		}
+		versions[i] = version{exist: state.Exist, size: state.Size, crc: state.Crc}
+		states[i] = state
+		counts[versions[i]]++
+	}
+	if len(counts) == 1 {
+		return nil
+	}
+	diff = &proto.ExtentDiff{ExtentID: extentID, Replicas: states, Reason: proto.ExtentDiffCrc}
+	for i := range hosts {
+		if !versions[i].exist {
+			diff.Reason = proto.ExtentDiffMissing
+			break
+		}
+		if versions[i].size != versions[0].size {
+			diff.Reason = proto.ExtentDiffSize
+		}
+	}
+	for i, v := range versions {
+		if v.exist && counts[v]*2 > len(hosts) {
+			diff.Majority = hosts[i]
+			break
+		}
+	}
+	for i, v := range versions {
+		if diff.Majority == "" || v != versions[indexOfHost(hosts, diff.Majority)] {
+			diff.BadHosts = append(diff.BadHosts, hosts[i])
+		}
+	}
+	return
+}
+
+func indexOfHost(hosts []string, addr string) int {
+	for i, host := range hosts {
+		if host == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+// repairFromMajority asks the replicas differing from the majority to repair their extents
+// from a replica of the majority. The extents larger than the majority are not repaired, as
+// the extents are never truncated.
+func (dp *DataPartition) repairFromMajority(hosts []string, replicaInfos []map[uint64]*extentCrcInfo, diffs []*proto.ExtentDiff) {
+	tasks := make([]*DataPartitionRepairTask, len(hosts))
+	for i, addr := range hosts {
+		tasks[i] = NewDataPartitionRepairTask(nil, 0, "", dp.dataNode.localServerAddr)
+		tasks[i].addr = addr
+	}
+	for _, diff := range diffs {
+		if diff.Majority == "" {
+			continue
+		}
+		source := indexOfHost(hosts, diff.Majority)
+		sourceInfo := replicaInfos[source][diff.ExtentID]
+		diff.Repaired = true
+		for _, addr := range diff.BadHosts {
+			i := indexOfHost(hosts, addr)
+			info := replicaInfos[i][diff.ExtentID]
+			if info != nil && info.Size > sourceInfo.Size {
+				diff.Repaired = false
+				continue
+			}
+			if info == nil {
+				tasks[i].ExtentsToBeCreated = append(tasks[i].ExtentsToBeCreated, &storage.ExtentInfo{FileID: diff.ExtentID})
+			} else {
+				blocks, err := dp.getBadBlocks(addr, diff.Majority, diff.ExtentID, info.Size == sourceInfo.Size)
+				if err != nil {
+					log.LogWarnf("action[repairFromMajority] partition(%v) extent(%v) replica(%v) err(%v)",
+						dp.partitionID, diff.ExtentID, addr, err)
+					diff.Repaired = false
+					continue
+				}
+				tasks[i].BlocksToBeRepaired = append(tasks[i].BlocksToBeRepaired, blocks...)
+			}
+			if info == nil || info.Size < sourceInfo.Size {
+				tasks[i].ExtentsToBeRepaired = append(tasks[i].ExtentsToBeRepaired,
+					&storage.ExtentInfo{FileID: diff.ExtentID, Size: sourceInfo.Size, Source: diff.Majority})
+			}
+		}
+	}
+	// the repair of the leader itself is done locally, the followers are notified
+	local := indexOfHost(hosts, dp.dataNode.localServerAddr)
+	if local < 0 {
+		return
+	}
+	tasks[0], tasks[local] = tasks[local], tasks[0]
+	for i, task := range tasks {
+		if len(task.ExtentsToBeCreated) == 0 && len(task.BlocksToBeRepaired) == 0 && len(task.ExtentsToBeRepaired) == 0 {
+			tasks[i] = nil
+		}
+	}
+	if tasks[0] != nil {
+		dp.DoExtentStoreRepair(tasks[0])
+	}
+	dp.NotifyExtentRepair(tasks)
+}
+
+// getBadBlocks compares the blocks of an extent on a replica with the source, the last block
+// is compared only if the replicas have the same size.
+func (dp *DataPartition) getBadBlocks(addr, source string, extentID uint64, sameSize bool) (blocks []*BlockRepairInfo, err error) {
+	request := &extentCrcRequest{ExtentIDs: []uint64{extentID}, BlockCrcs: true}
+	replicaInfos, err := dp.collectExtentCrcs([]string{addr, source}, request)
+	if err != nil {
+		return
+	}
+	bad, good := replicaInfos[0][extentID], replicaInfos[1][extentID]
+	if bad == nil || good == nil {
+		return nil, fmt.Errorf("extent not found")
+	}
+	blockCnt := int(util.Min(int(bad.Size), int(good.Size)) / util.BlockSize)
+	if sameSize {
+		blockCnt = len(good.BlockCrcs)
+	}
+	for blockNo := 0; blockNo < blockCnt && blockNo < len(bad.BlockCrcs) && blockNo < len(good.BlockCrcs); blockNo++ {
+		if bad.BlockCrcs[blockNo] != good.BlockCrcs[blockNo] {
+			blocks = append(blocks, &BlockRepairInfo{ExtentID: extentID, BlockNo: blockNo, Crc: good.BlockCrcs[blockNo], Source: source})
+		}
+	}
+	return
+}
+
+// repairBlock rewrites a block with the data of the source replica.
+func (dp *DataPartition) repairBlock(block *BlockRepairInfo) (err error) {
+	store := dp.ExtentStore()
+	ei, err := store.Watermark(block.ExtentID)
+	if err != nil {
+		return
+	}
+	offset := int64(block.BlockNo) * util.BlockSize
+	size := util.Min(util.BlockSize, int(int64(ei.Size)-offset))
+	if size <= 0 {
+		return fmt.Errorf("block(%v) beyond extent size(%v)", block.BlockNo, ei.Size)
+	}
+	limiterWaitN(dp.disk.repairIOLimiter, size)
+	data, err := dp.readRemoteBlock(block.Source, block.ExtentID, offset, size)
+	if err != nil {
+		return
+	}
+	if actualCrc := proto.Checksum(dp.extentStore.ChecksumType(), data); actualCrc != block.Crc {
+		return fmt.Errorf("source(%v) crc mismatch expectCrc(%v) actualCrc(%v)", block.Source, block.Crc, actualCrc)
+	}
+	return store.Write(block.ExtentID, offset, int64(size), data, block.Crc, storage.RandomWriteType, true)
+}
@@ -0,0 +1,549 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The normal extents of an erasure coded data partition are split into stripes of two units.
+// For every extent, a replica keeps the first units of the stripes, another one keeps the
+// second units, and the last one keeps their parity, the shards being rotated by the extent
+// id over the hosts of the partition. Every replica encodes its shards from its own extents,
+// which are the same on all the replicas as the partition has not been written for a while,
+// and releases the extents once the master has compared the digests of all the replicas.
+// A unit on an unreachable replica is reconstructed from the other two. The tiny extents
+// are kept replicated.
+
+const (
+	ECDirName      = "ec"
+	ECMetaFileName = "EC_META"
+	ECDataShards   = 2
+	ECShards       = ECDataShards + 1
+	ECUnitSize     = util.BlockSize
+)
+
+// ecMeta defines the shards of the erasure coded extents of a data partition.
+type ecMeta struct {
+	Status  uint8
+	Hosts   []string // the hosts of the partition when it is encoded
+	Extents map[uint64]*ecExtent
+}
+
+// ecExtent defines an erasure coded extent, the crc is the IEEE CRC32 of its data.
+type ecExtent struct {
+	Size uint64
+	Crc  uint32
+}
+
+// ecShardHost returns the host keeping the shard of the extent.
+func ecShardHost(hosts []string, extentID uint64, shard int) string {
+	return hosts[(uint64(shard)+extentID)%ECShards]
+}
+
+// ecHostShard returns the shard of the extent kept by the host at the index.
+func ecHostShard(index int, extentID uint64) int {
+	return int((uint64(index) + ECShards - extentID%ECShards) % ECShards)
+}
+
+func (dp *DataPartition) ecPath(name string) string {
+	return path.Join(dp.path, ECDirName, name)
+}
+
+func (dp *DataPartition) ecShardPath(extentID uint64) string {
+	return dp.ecPath(strconv.FormatUint(extentID, 10))
+}
+
+func (dp *DataPartition) loadECMeta() (err error) {
+	data, err := ioutil.ReadFile(dp.ecPath(ECMetaFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	meta := &ecMeta{}
+	if err = json.Unmarshal(data, meta); err != nil {
+		return
+	}
+	dp.ec = meta
+	atomic.StoreInt64(&dp.ecEncoded, int64(len(meta.Extents)))
+	atomic.StoreInt64(&dp.ecTotal, int64(len(meta.Extents)))
+	return
+}
+
+// persistECMeta must be called with the lock of the erasure coding held.
+func (dp *DataPartition) persistECMeta(meta *ecMeta) (err error) {
+	if err = os.MkdirAll(path.Join(dp.path, ECDirName), 0755); err != nil {
+		return
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	tmpPath := dp.ecPath(ECMetaFileName + ".tmp")
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return
+	}
+	return os.Rename(tmpPath, dp.ecPath(ECMetaFileName))
+}
+
+// ECStatus returns the status of the erasure coding of the data partition.
+func (dp *DataPartition) ECStatus() uint8 {
+	dp.ecLock.RLock()
+	defer dp.ecLock.RUnlock()
+	if dp.ec == nil {
+		return proto.ECStatusNone
+	}
+	return dp.ec.Status
+}
+
+func (dp *DataPartition) ecProgress() proto.ECProgress {
+	return proto.ECProgress{
+		ECEncodedExtents: int(atomic.LoadInt64(&dp.ecEncoded)),
+		ECTotalExtents:   int(atomic.LoadInt64(&dp.ecTotal)),
+	}
+}
+
+// lastWriteTime returns the time of the last write of the data partition, the modification
+// time of the extents being used for the writes before the data node started.
+func (dp *DataPartition) lastWriteTime() int64 {
+	if lastWrite := dp.accessStats.lastWrite(); lastWrite > 0 {
+		return lastWrite
+	}
+	if writeTime := atomic.LoadInt64(&dp.extentWriteTime); writeTime > 0 {
+		return writeTime
+	}
+	extents, _, err := dp.ExtentStore().GetAllWatermarks(nil)
+	if err != nil {
+		return 0
+	}
+	writeTime := int64(1)
+	for _, ei := range extents {
+		if ei.ModifyTime > writeTime {
+			writeTime = ei.ModifyTime
+		}
+	}
+	atomic.StoreInt64(&dp.extentWriteTime, writeTime)
+	return writeTime
+}
+
+// ecExtent returns the extent if it is read from its shards.
+func (dp *DataPartition) ecExtent(extentID uint64) (hosts []string, extent *ecExtent) {
+	dp.ecLock.RLock()
+	defer dp.ecLock.RUnlock()
+	if dp.ec == nil || dp.ec.Status != proto.ECStatusConverted {
+		return
+	}
+	return dp.ec.Hosts, dp.ec.Extents[extentID]
+}
+
+// readExtent reads the extent from its shards if it is erasure coded, and from the extent
+// store otherwise.
+func (dp *DataPartition) readExtent(extentID uint64, offset, size int64, data []byte, isRepairRead bool) (crc uint32, err error) {
+	store := dp.ExtentStore()
+	hosts, extent := dp.ecExtent(extentID)
+	if extent == nil {
+		return store.Read(extentID, offset, size, data, isRepairRead)
+	}
+	if offset+size > int64(extent.Size) {
+		return 0, storage.NewParameterMismatchErr(fmt.Sprintf("offset=%v size=%v extent size=%v", offset, size, extent.Size))
+	}
+	if err = readECData(dp.ecShardReader(hosts, extentID), offset, data[:size]); err != nil {
+		return
+	}
+	crc = proto.Checksum(store.ChecksumType(), data[:size])
+	return
+}
+
+// ecShardReader reads a part of a shard of an erasure coded extent.
+type ecShardReader func(shard int, offset int64, buf []byte) error
+
+func (dp *DataPartition) ecShardReader(hosts []string, extentID uint64) ecShardReader {
+	return func(shard int, offset int64, buf []byte) (err error) {
+		addr := ecShardHost(hosts, extentID, shard)
+		if err = dp.readECShard(addr, extentID, offset, buf); err != nil {
+			log.LogWarnf("action[readECShard] partition(%v) extent(%v) shard(%v) on (%v) err(%v)",
+				dp.partitionID, extentID, shard, addr, err)
+		}
+		return
+	}
+}
+
+// readECData reads the data of an erasure coded extent at the offset from its shards.
+func readECData(read ecShardReader, offset int64, data []byte) (err error) {
+	size := int64(len(data))
+	for done := int64(0); done < size; {
+		pos := offset + done
+		unit := pos / ECUnitSize
+		n := util.Min(int(ECUnitSize-pos%ECUnitSize), int(size-done))
+		shardOffset := unit/ECDataShards*ECUnitSize + pos%ECUnitSize
+		if err = readECUnit(read, int(unit%ECDataShards), shardOffset, data[done:done+int64(n)]); err != nil {
+			return
+		}
+		done += int64(n)
+	}
+	return
+}
+
+// readECUnit reads a part of a data shard, which is reconstructed from the other data shard
+// and the parity shard if it cannot be read.
+func readECUnit(read ecShardReader, shard int, offset int64, buf []byte) (err error) {
+	if err = read(shard, offset, buf); err == nil {
+		return
+	}
+	if err = read(ECDataShards-1-shard, offset, buf); err != nil {
+		return
+	}
+	parity := make([]byte, len(buf))
+	if err = read(ECDataShards, offset, parity); err != nil {
+		return
+	}
+	for i := range buf {
+		buf[i] ^= parity[i]
+	}
+	return
+}
+
+func (dp *DataPartition) readECShard(addr string, extentID uint64, offset int64, buf []byte) (err error) {
+	if addr == dp.dataNode.localServerAddr {
+		return dp.readLocalECShard(extentID, offset, buf)
+	}
+	p := repl.NewPacketToReadECShard(dp.partitionID, extentID, offset, uint32(len(buf)))
+	var conn net.Conn
+	if conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(addr)); err != nil {
+		return
+	}
+	defer func() {
+		gConnPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	reply := new(repl.Packet)
+	if err = reply.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		return
+	}
+	if reply.ResultCode != proto.OpOk {
+		return fmt.Errorf("replica(%v) error(%v)", addr, string(reply.Data[:reply.Size]))
+	}
+	if int(reply.Size) != len(buf) {
+		return fmt.Errorf("replica(%v) returns size(%v), expected(%v)", addr, reply.Size, len(buf))
+	}
+	copy(buf, reply.Data[:reply.Size])
+	return
+}
+
+func (dp *DataPartition) readLocalECShard(extentID uint64, offset int64, buf []byte) (err error) {
+	f, err := os.Open(dp.ecShardPath(extentID))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = f.ReadAt(buf, offset)
+	return
+}
+
+// ConvertToEC runs a phase of the conversion of the data partition to erasure coding.
+func (dp *DataPartition) ConvertToEC(request *proto.ConvertDataPartitionToECRequest) (response *proto.ConvertDataPartitionToECResponse) {
+	response = &proto.ConvertDataPartitionToECResponse{PartitionId: dp.partitionID, Phase: request.Phase}
+	var err error
+	switch request.Phase {
+	case proto.ECPhaseEncode:
+		err = dp.encodeEC(request.Hosts, response)
+	case proto.ECPhaseCommit:
+		err = dp.commitEC(response)
+	case proto.ECPhaseAbort:
+		err = dp.abortEC()
+	default:
+		err = fmt.Errorf("unknown phase %v", request.Phase)
+	}
+	if err != nil {
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		log.LogErrorf("action[ConvertToEC] partition(%v) phase(%v) err(%v)", dp.partitionID, request.Phase, err)
+		return
+	}
+	response.Status = proto.TaskSucceeds
+	log.LogInfof("action[ConvertToEC] partition(%v) phase(%v) extents(%v) bytes(%v) digest(%v)",
+		dp.partitionID, request.Phase, response.Extents, response.Bytes, response.Digest)
+	return
+}
+
+func (dp *DataPartition) encodeEC(hosts []string, response *proto.ConvertDataPartitionToECResponse) (err error) {
+	if len(hosts) != ECShards {
+		return fmt.Errorf("%v hosts are required, got %v", ECShards, len(hosts))
+	}
+	index := -1
+	for i, host := range hosts {
+		if host == dp.dataNode.localServerAddr {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("local address %v is not in the hosts %v", dp.dataNode.localServerAddr, hosts)
+	}
+	store := dp.ExtentStore()
+	if store.IsEncrypted() {
+		return fmt.Errorf("the encrypted partitions cannot be erasure coded")
+	}
+	dp.ecLock.Lock()
+	if dp.ec != nil && dp.ec.Status == proto.ECStatusConverted {
+		meta := dp.ec
+		dp.ecLock.Unlock()
+		dp.fillECResponse(meta, response)
+		return
+	}
+	// the writes are rejected from now on
+	meta := &ecMeta{Status: proto.ECStatusEncoding, Hosts: hosts, Extents: make(map[uint64]*ecExtent)}
+	if err = dp.persistECMeta(meta); err != nil {
+		dp.ecLock.Unlock()
+		return
+	}
+	dp.ec = meta
+	dp.ecLock.Unlock()
+
+	extents, _, err := store.GetAllWatermarks(storage.NormalExtentFilter())
+	if err != nil {
+		return
+	}
+	atomic.StoreInt64(&dp.ecEncoded, 0)
+	atomic.StoreInt64(&dp.ecTotal, int64(len(extents)))
+	encoded := make(map[uint64]*ecExtent, len(extents))
+	for _, ei := range extents {
+		if ei.Size > 0 && !store.IsDeletedNormalExtent(ei.FileID) {
+			var extent *ecExtent
+			if extent, err = dp.encodeECShard(ei.FileID, ei.Size, ecHostShard(index, ei.FileID)); err != nil {
+				return fmt.Errorf("encode extent(%v): %v", ei.FileID, err)
+			}
+			encoded[ei.FileID] = extent
+		}
+		atomic.AddInt64(&dp.ecEncoded, 1)
+	}
+	dp.ecLock.Lock()
+	meta.Extents = encoded
+	err = dp.persistECMeta(meta)
+	dp.ecLock.Unlock()
+	if err != nil {
+		return
+	}
+	dp.fillECResponse(meta, response)
+	return
+}
+
+// fillECResponse sets the digest of the extents encoded, which is the same on the replicas
+// encoding the same extents.
+func (dp *DataPartition) fillECResponse(meta *ecMeta, response *proto.ConvertDataPartitionToECResponse) {
+	dp.ecLock.RLock()
+	defer dp.ecLock.RUnlock()
+	extentIDs := make([]uint64, 0, len(meta.Extents))
+	for extentID := range meta.Extents {
+		extentIDs = append(extentIDs, extentID)
+	}
+	sort.Slice(extentIDs, func(i, j int) bool { return extentIDs[i] < extentIDs[j] })
+	hash := crc32.NewIEEE()
+	buf := make([]byte, 20)
+	for _, extentID := range extentIDs {
+		extent := meta.Extents[extentID]
+		binary.BigEndian.PutUint64(buf[0:8], extentID)
+		binary.BigEndian.PutUint64(buf[8:16], extent.Size)
+		binary.BigEndian.PutUint32(buf[16:20], extent.Crc)
+		hash.Write(buf)
+		response.Bytes += extent.Size
+	}
+	response.Extents = len(extentIDs)
+	response.Digest = hash.Sum32()
+}
+
+// encodeECShard writes the shard of the extent kept by the replica.
+func (dp *DataPartition) encodeECShard(extentID, size uint64, shard int) (extent *ecExtent, err error) {
+	store := dp.ExtentStore()
+	tmpPath := dp.ecShardPath(extentID) + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+	crc, err := encodeECStripes(size, shard, func(offset int64, buf []byte) (err error) {
+		if _, err = store.Read(extentID, offset, int64(len(buf)), buf, true); err == nil {
+			dp.disk.WaitIO(len(buf), true)
+		}
+		return
+	}, f)
+	if err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	if err = os.Rename(tmpPath, dp.ecShardPath(extentID)); err != nil {
+		return
+	}
+	return &ecExtent{Size: size, Crc: crc}, nil
+}
+
+// encodeECStripes writes the shard of the data of the size read by read to w, every stripe of
+// the shard taking a whole unit. It returns the IEEE CRC32 of the data.
+func encodeECStripes(size uint64, shard int, read func(offset int64, buf []byte) error, w io.Writer) (crc uint32, err error) {
+	units := [ECShards][]byte{}
+	for i := range units {
+		units[i] = make([]byte, ECUnitSize)
+	}
+	hash := crc32.NewIEEE()
+	for offset := uint64(0); offset < size; offset += ECDataShards * ECUnitSize {
+		for i := 0; i < ECDataShards; i++ {
+			unit := units[i]
+			for j := range unit {
+				unit[j] = 0
+			}
+			start := offset + uint64(i)*ECUnitSize
+			if start >= size {
+				continue
+			}
+			n := util.Min(ECUnitSize, int(size-start))
+			if err = read(int64(start), unit[:n]); err != nil {
+				return
+			}
+			hash.Write(unit[:n])
+		}
+		if shard == ECDataShards {
+			parity := units[ECDataShards]
+			for j := range parity {
+				parity[j] = units[0][j] ^ units[1][j]
+			}
+		}
+		if _, err = w.Write(units[shard]); err != nil {
+			return
+		}
+	}
+	return hash.Sum32(), nil
+}
+
+// commitEC reads the extents from their shards, and releases the space of the extents.
+func (dp *DataPartition) commitEC(response *proto.ConvertDataPartitionToECResponse) (err error) {
+	store := dp.ExtentStore()
+	dp.ecLock.Lock()
+	meta := dp.ec
+	if meta == nil {
+		dp.ecLock.Unlock()
+		return fmt.Errorf("the partition is not encoded")
+	}
+	if meta.Status != proto.ECStatusConverted {
+		for extentID, extent := range meta.Extents {
+			// the extent has been written by a write started before the encoding, it is kept
+			// replicated locally, the other replicas still read its shard
+			if ei, err := store.Watermark(extentID); err == nil && ei.Size != extent.Size {
+				log.LogWarnf("action[commitEC] partition(%v) extent(%v) size(%v) encoded size(%v)",
+					dp.partitionID, extentID, ei.Size, extent.Size)
+				delete(meta.Extents, extentID)
+			}
+		}
+		meta.Status = proto.ECStatusConverted
+		if err = dp.persistECMeta(meta); err != nil {
+			meta.Status = proto.ECStatusEncoding
+			dp.ecLock.Unlock()
+			return
+		}
+	}
+	extents := make(map[uint64]uint64, len(meta.Extents))
+	for extentID, extent := range meta.Extents {
+		extents[extentID] = extent.Size
+	}
+	dp.ecLock.Unlock()
+	for extentID, size := range extents {
+		punched, punchErr := store.PunchHole(extentID, 0, int64(size))
+		if punchErr != nil {
+			log.LogWarnf("action[commitEC] partition(%v) extent(%v) err(%v)", dp.partitionID, extentID, punchErr)
+			continue
+		}
+		response.Extents++
+		response.Bytes += uint64(punched)
+	}
+	return
+}
+
+// abortEC removes the shards of the partition, which can be written again.
+func (dp *DataPartition) abortEC() (err error) {
+	dp.ecLock.Lock()
+	defer dp.ecLock.Unlock()
+	if dp.ec != nil && dp.ec.Status == proto.ECStatusConverted {
+		return fmt.Errorf("the partition has been converted")
+	}
+	if err = os.RemoveAll(path.Join(dp.path, ECDirName)); err != nil {
+		return
+	}
+	dp.ec = nil
+	atomic.StoreInt64(&dp.ecEncoded, 0)
+	atomic.StoreInt64(&dp.ecTotal, 0)
+	return
+}
+
+// isECRunning returns false if a phase of the erasure coding starts to run, and true if a
+// phase is already running.
+func (dp *DataPartition) isECRunning() bool {
+	return !atomic.CompareAndSwapInt32(&dp.ecRunning, 0, 1)
+}
+
+func (dp *DataPartition) finishECRunning() {
+	atomic.StoreInt32(&dp.ecRunning, 0)
+}
+
+// deleteECExtent removes the shard of a deleted extent.
+func (dp *DataPartition) deleteECExtent(extentID uint64) {
+	dp.ecLock.Lock()
+	defer dp.ecLock.Unlock()
+	if dp.ec == nil {
+		return
+	}
+	if _, ok := dp.ec.Extents[extentID]; ok {
+		delete(dp.ec.Extents, extentID)
+		if err := dp.persistECMeta(dp.ec); err != nil {
+			log.LogWarnf("action[deleteECExtent] partition(%v) extent(%v) err(%v)", dp.partitionID, extentID, err)
+		}
+	}
+	if err := os.Remove(dp.ecShardPath(extentID)); err != nil && !os.IsNotExist(err) {
+		log.LogWarnf("action[deleteECExtent] partition(%v) extent(%v) err(%v)", dp.partitionID, extentID, err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func TestECShardPlacement(t *testing.T) {
+	hosts := []string{"a", "b", "c"}
+	for extentID := uint64(1024); extentID < 1030; extentID++ {
+		seen := make(map[int]bool)
+		for index, host := range hosts {
+			shard := ecHostShard(index, extentID)
+			if seen[shard] {
+				t.Errorf("extent(%v): shard %v kept by two hosts", extentID, shard)
+			}
+			seen[shard] = true
+			if got := ecShardHost(hosts, extentID, shard); got != host {
+				t.Errorf("extent(%v) shard(%v): host %v, expected %v", extentID, shard, got, host)
+			}
+		}
+	}
+}
+
+// encodeTestShards encodes all the shards of the data.
+func encodeTestShards(t *testing.T, data []byte) (shards [ECShards][]byte) {
+	for shard := 0; shard < ECShards; shard++ {
+		buf := new(bytes.Buffer)
+		crc, err := encodeECStripes(uint64(len(data)), shard, func(offset int64, b []byte) error {
+			copy(b, data[offset:])
+			return nil
+		}, buf)
+		if err != nil {
+			t.Fatalf("encode shard %v of %v bytes: %v", shard, len(data), err)
+		}
+		if crc != crc32.ChecksumIEEE(data) {
+			t.Fatalf("encode shard %v of %v bytes: crc %v", shard, len(data), crc)
+		}
+		stripes := (len(data) + ECDataShards*ECUnitSize - 1) / (ECDataShards * ECUnitSize)
+		if buf.Len() != stripes*ECUnitSize {
+			t.Fatalf("shard %v of %v bytes takes %v bytes, expected %v", shard, len(data), buf.Len(), stripes*ECUnitSize)
+		}
+		shards[shard] = buf.Bytes()
+	}
+	return
+}
+
+var errTestShardLost = errors.New("shard lost")
+
+// testShardReader reads the shards in memory, except the lost ones.
+func testShardReader(shards [ECShards][]byte, lost ...int) ecShardReader {
+	return func(shard int, offset int64, buf []byte) error {
+		for _, l := range lost {
+			if shard == l {
+				return errTestShardLost
+			}
+		}
+		copy(buf, shards[shard][offset:])
+		return nil
+	}
+}
+
+func TestECEncodeAndReconstruct(t *testing.T) {
+	sizes := []int{1, ECUnitSize - 1, ECUnitSize, ECUnitSize + 1, 2 * ECUnitSize, 5*ECUnitSize + 123}
+	r := rand.New(rand.NewSource(1))
+	for _, size := range sizes {
+		data := make([]byte, size)
+		r.Read(data)
+		shards := encodeTestShards(t, data)
+		ranges := []struct {
+			offset, size int
+		}{
+			{0, size},
+			{size / 3, size / 2},
+			{size - 1, 1},
+			{ECUnitSize - 1, 2},
+			{2*ECUnitSize - 10, ECUnitSize},
+		}
+		for _, lost := range []int{-1, 0, 1, ECDataShards} {
+			read := testShardReader(shards, lost)
+			for _, rg := range ranges {
+				if rg.size <= 0 || rg.offset+rg.size > size {
+					continue
+				}
+				buf := make([]byte, rg.size)
+				if err := readECData(read, int64(rg.offset), buf); err != nil {
+					t.Errorf("size(%v) lost shard(%v) read [%v, +%v): %v", size, lost, rg.offset, rg.size, err)
+					continue
+				}
+				if !bytes.Equal(buf, data[rg.offset:rg.offset+rg.size]) {
+					t.Errorf("size(%v) lost shard(%v) read [%v, +%v): data mismatch", size, lost, rg.offset, rg.size)
+				}
+			}
+		}
+	}
+}
+
+func TestECReadTwoShardsLost(t *testing.T) {
+	data := make([]byte, 3*ECUnitSize)
+	rand.New(rand.NewSource(2)).Read(data)
+	shards := encodeTestShards(t, data)
+	cases := [][]int{{0, 1}, {0, ECDataShards}, {1, ECDataShards}}
+	for _, lost := range cases {
+		buf := make([]byte, len(data))
+		if err := readECData(testShardReader(shards, lost...), 0, buf); err != errTestShardLost {
+			t.Errorf("lost shards %v: err(%v), expected %v", lost, err, errTestShardLost)
+		}
+	}
+}
@@ -23,7 +23,6 @@ import (
 
 	"encoding/binary"
 	"fmt"
-	"hash/crc32"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/repl"
@@ -40,6 +39,7 @@ type DataPartitionRepairTask struct {
 	extents                        map[uint64]*storage.ExtentInfo
 	ExtentsToBeCreated             []*storage.ExtentInfo
 	ExtentsToBeRepaired            []*storage.ExtentInfo
+	BlocksToBeRepaired             []*BlockRepairInfo // blocks differing from the majority, see CheckConsistency
 	LeaderTinyDeleteRecordFileSize int64
 	LeaderAddr                     string
 }
@@ -194,8 +194,8 @@ func (dp *DataPartition) getRemoteExtentInfo(extentType uint8, tinyExtents []uin
 		}
 		p.Size = uint32(len(p.Data))
 	}
-	var conn *net.TCPConn
-	conn, err = gConnPool.GetConnect(target) // get remote connection
+	var conn net.Conn
+	conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(target)) // get remote connection
 	if err != nil {
 		err = errors.Trace(err, "getRemoteExtentInfo DataPartition(%v) get host(%v) connect", dp.partitionID, target)
 		return
@@ -399,14 +399,14 @@ func (dp *DataPartition) buildExtentRepairTasks(repairTasks []*DataPartitionRepa
 
 func (dp *DataPartition) notifyFollower(wg *sync.WaitGroup, index int, members []*DataPartitionRepairTask) (err error) {
 	p := repl.NewPacketToNotifyExtentRepair(dp.partitionID) // notify all the followers to repair
-	var conn *net.TCPConn
+	var conn net.Conn
 	//target := dp.getReplicaAddr(index)
 	//fix repair case panic,may be dp's replicas is change
 	target := members[index].addr
 
 	p.Data, _ = json.Marshal(members[index])
 	p.Size = uint32(len(p.Data))
-	conn, err = gConnPool.GetConnect(target)
+	conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(target))
 	defer func() {
 		wg.Done()
 		if err == nil {
@@ -504,6 +504,7 @@ func (dp *DataPartition) streamRepairExtent(remoteExtentInfo *storage.ExtentInfo
 	} else {
 		request = repl.NewExtentRepairReadPacket(dp.partitionID, remoteExtentInfo.FileID, int(localExtentInfo.Size), int(sizeDiff))
 	}
+	request.ChecksumType = store.ChecksumType()
 	var conn net.Conn
 	conn, err = dp.getRepairConn(remoteExtentInfo.Source)
 	if err != nil {
@@ -556,14 +557,15 @@ func (dp *DataPartition) streamRepairExtent(remoteExtentInfo *storage.ExtentInfo
 		log.LogInfof(fmt.Sprintf("action[streamRepairExtent] fix(%v_%v) start fix from (%v)"+
 			" remoteSize(%v)localSize(%v) reply(%v).", dp.partitionID, localExtentInfo.FileID, remoteExtentInfo.String(),
 			remoteExtentInfo.Size, currFixOffset, reply.GetUniqueLogId()))
-		actualCrc := crc32.ChecksumIEEE(reply.Data[:reply.Size])
-		if reply.CRC != crc32.ChecksumIEEE(reply.Data[:reply.Size]) {
+		actualCrc := reply.Checksum(reply.Data[:reply.Size])
+		if reply.CRC != actualCrc {
 			err = fmt.Errorf("streamRepairExtent crc mismatch expectCrc(%v) actualCrc(%v) extent(%v_%v) start fix from (%v)"+
 				" remoteSize(%v) localSize(%v) request(%v) reply(%v) ", reply.CRC, actualCrc, dp.partitionID, remoteExtentInfo.String(),
 				remoteExtentInfo.Source, remoteExtentInfo.Size, currFixOffset, request.GetUniqueLogId(), reply.GetUniqueLogId())
 			return errors.Trace(err, "streamRepairExtent receive data error")
 		}
 		isEmptyResponse := false
+		dp.disk.WaitIO(int(reply.Size), true)
 		// Write it to local extent file
 		if storage.IsTinyExtent(uint64(localExtentInfo.FileID)) {
 			currRecoverySize := uint64(reply.Size)
@@ -605,6 +607,71 @@ func (dp *DataPartition) streamRepairExtent(remoteExtentInfo *storage.ExtentInfo
 
 }
 
+// repairCorruptBlock rewrites a block which does not match its CRC with the data of
+// a replica, if the data of the replica matches the CRC.
+func (dp *DataPartition) repairCorruptBlock(extentID uint64, block *storage.BlockCrc) (err error) {
+	store := dp.ExtentStore()
+	ei, err := store.Watermark(extentID)
+	if err != nil {
+		return
+	}
+	offset := int64(block.BlockNo) * util.BlockSize
+	size := util.Min(util.BlockSize, int(int64(ei.Size)-offset))
+	if size <= 0 {
+		return fmt.Errorf("block(%v) beyond extent size(%v)", block.BlockNo, ei.Size)
+	}
+	err = fmt.Errorf("no replica to repair from")
+	for _, addr := range dp.getReplicaCopy() {
+		if addr == dp.dataNode.localServerAddr {
+			continue
+		}
+		var data []byte
+		if data, err = dp.readRemoteBlock(addr, extentID, offset, size); err != nil {
+			continue
+		}
+		if actualCrc := proto.Checksum(store.ChecksumType(), data); actualCrc != block.Crc {
+			err = fmt.Errorf("replica(%v) crc mismatch expectCrc(%v) actualCrc(%v)", addr, block.Crc, actualCrc)
+			continue
+		}
+		return store.Write(extentID, offset, int64(size), data, block.Crc, storage.RandomWriteType, true)
+	}
+	return
+}
+
+// readRemoteBlock reads the data of an extent from a replica.
+func (dp *DataPartition) readRemoteBlock(addr string, extentID uint64, offset int64, size int) (data []byte, err error) {
+	request := repl.NewExtentRepairReadPacket(dp.partitionID, extentID, int(offset), size)
+	request.ChecksumType = dp.extentStore.ChecksumType()
+	conn, err := dp.getRepairConn(addr)
+	if err != nil {
+		return
+	}
+	defer func() {
+		dp.putRepairConn(conn, err != nil)
+	}()
+	if err = request.WriteToConn(conn); err != nil {
+		return
+	}
+	data = make([]byte, 0, size)
+	for len(data) < size {
+		reply := repl.NewPacket()
+		if err = reply.ReadFromConn(conn, 60); err != nil {
+			return
+		}
+		if reply.ResultCode != proto.OpOk {
+			err = fmt.Errorf("read from replica(%v) error(%v)", addr, string(reply.Data[:intMin(len(reply.Data), int(reply.Size))]))
+			return
+		}
+		if reply.ReqID != request.ReqID || reply.ExtentOffset != offset+int64(len(data)) ||
+			reply.CRC != reply.Checksum(reply.Data[:reply.Size]) {
+			err = fmt.Errorf("invalid reply(%v) from replica(%v)", reply.GetUniqueLogId(), addr)
+			return
+		}
+		data = append(data, reply.Data[:reply.Size]...)
+	}
+	return
+}
+
 func intMin(a, b int) int {
 	if a < b {
 		return a
@@ -28,8 +28,10 @@ import (
 	"os"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -52,7 +54,7 @@ type Disk struct {
 	Unallocated uint64
 	Allocated   uint64
 
-	MaxErrCnt       int // maximum number of errors
+	MaxErrCnt       int // maximum number of errors within DiskErrWindow
 	Status          int // disk status such as READONLY
 	ReservedSpace   uint64
 	DiskRdonlySpace uint64
@@ -62,6 +64,24 @@ type Disk struct {
 	syncTinyDeleteRecordFromLeaderOnEveryDisk chan bool
 	space                                     *SpaceManager
 	dataNode                                  *DataNode
+	stopC                                     chan bool
+
+	// the client io and the repair io are throttled separately, so that the repairs
+	// after a disk failure do not hurt the latency of the client requests.
+	clientIOLimiter *rate.Limiter
+	repairIOLimiter *rate.Limiter
+
+	smart *proto.DiskSmartInfo // the latest SMART attributes, nil if not collected
+
+	cache *storage.BlockCache // the SSD cache of the disk, nil if not cached
+
+	rotational bool  // whether the disk is a hard disk
+	released   int64 // bytes released by the hole punches since the last trim
+
+	errCnt       int       // IO errors since errStartTime
+	errStartTime time.Time // start of the window the errors are counted in
+
+	verifyWrite int32 // the writes are read back and verified before they are acknowledged
 }
 
 const (
@@ -81,6 +101,13 @@ func NewDisk(path string, reservedSpace, diskRdonlySpace uint64, maxErrCnt int,
 	d.dataNode = space.dataNode
 	d.partitionMap = make(map[uint64]*DataPartition)
 	d.syncTinyDeleteRecordFromLeaderOnEveryDisk = make(chan bool, SyncTinyDeleteRecordFromLeaderOnEveryDisk)
+	d.stopC = make(chan bool, 0)
+	d.clientIOLimiter = rate.NewLimiter(rate.Inf, defaultDiskIOLimitBurst)
+	d.repairIOLimiter = rate.NewLimiter(rate.Inf, defaultDiskIOLimitBurst)
+	setLimiter(d.clientIOLimiter, atomic.LoadUint64(&space.diskClientIOLimit))
+	setLimiter(d.repairIOLimiter, atomic.LoadUint64(&space.diskRepairIOLimit))
+	d.cache = d.dataNode.cacheTier.assign(path)
+	d.rotational = isRotationalDisk(path)
 	d.computeUsage()
 	d.updateSpaceInfo()
 	d.startScheduleToUpdateSpaceInfo()
@@ -175,9 +202,11 @@ func (d *Disk) startScheduleToUpdateSpaceInfo() {
 	go func() {
 		updateSpaceInfoTicker := time.NewTicker(5 * time.Second)
 		checkStatusTickser := time.NewTicker(time.Minute * 2)
+		trimTicker := time.NewTicker(time.Minute)
 		defer func() {
 			updateSpaceInfoTicker.Stop()
 			checkStatusTickser.Stop()
+			trimTicker.Stop()
 		}()
 		for {
 			select {
@@ -186,11 +215,36 @@ func (d *Disk) startScheduleToUpdateSpaceInfo() {
 				d.updateSpaceInfo()
 			case <-checkStatusTickser.C:
 				d.checkDiskStatus()
+			case <-trimTicker.C:
+				d.trimReleasedSpace()
+			case <-d.stopC:
+				return
 			}
 		}
 	}()
 }
 
+// addReleasedSpace records the space released by a hole punch, to be trimmed later.
+func (d *Disk) addReleasedSpace(n int64) {
+	atomic.AddInt64(&d.released, n)
+}
+
+// trimReleasedSpace discards the free blocks of an SSD once the space released by the
+// hole punches exceeds the threshold, so that the device can reclaim them.
+func (d *Disk) trimReleasedSpace() {
+	threshold := d.dataNode.trimThreshold
+	if d.rotational || threshold < 0 || atomic.LoadInt64(&d.released) < threshold {
+		return
+	}
+	released := atomic.SwapInt64(&d.released, 0)
+	trimmed, err := storage.Trim(d.Path, TrimMinLength)
+	if err != nil {
+		log.LogWarnf("action[trimReleasedSpace] disk(%v) released(%v) err(%v)", d.Path, released, err)
+		return
+	}
+	log.LogInfof("action[trimReleasedSpace] disk(%v) released(%v) trimmed(%v)", d.Path, released, trimmed)
+}
+
 func (d *Disk) doBackendTask() {
 	for {
 		partitions := make([]*DataPartition, 0)
@@ -202,8 +256,41 @@ func (d *Disk) doBackendTask() {
 		for _, dp := range partitions {
 			dp.extentStore.BackendTask()
 		}
-		time.Sleep(time.Minute)
+		select {
+		case <-d.stopC:
+			return
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+// WaitIO waits until size bytes of io are allowed on the disk.
+func (d *Disk) WaitIO(size int, isRepair bool) {
+	if isRepair {
+		limiterWaitN(d.repairIOLimiter, size)
+		return
 	}
+	limiterWaitN(d.clientIOLimiter, size)
+}
+
+func (d *Disk) setSmart(info *proto.DiskSmartInfo) {
+	d.Lock()
+	d.smart = info
+	d.Unlock()
+}
+
+func (d *Disk) getSmart() (info *proto.DiskSmartInfo) {
+	d.RLock()
+	defer d.RUnlock()
+	return d.smart
+}
+
+// Stop stops the background tasks of the disk.
+func (d *Disk) Stop() {
+	defer func() {
+		recover()
+	}()
+	close(d.stopC)
 }
 
 const (
@@ -234,24 +321,51 @@ func (d *Disk) checkDiskStatus() {
 	}
 }
 
+// triggerDiskError counts the IO errors of the disk, and isolates the disk once MaxErrCnt
+// errors happen within DiskErrWindow, so that a transient error does not take it down.
 func (d *Disk) triggerDiskError(err error) {
-	if err == nil {
+	if err == nil || !IsDiskErr(err.Error()) {
 		return
 	}
-	if IsDiskErr(err.Error()) {
-		mesg := fmt.Sprintf("disk path %v error on %v", d.Path, LocalIP)
-		exporter.Warning(mesg)
-		log.LogErrorf(mesg)
-		d.ForceExitRaftStore()
+	d.Lock()
+	now := time.Now()
+	if now.Sub(d.errStartTime) > DiskErrWindow {
+		d.errStartTime = now
+		d.errCnt = 0
+	}
+	d.errCnt++
+	errCnt := d.errCnt
+	isolate := errCnt >= d.MaxErrCnt && d.Status != proto.Unavailable
+	if isolate {
 		d.Status = proto.Unavailable
 	}
-	return
+	d.Unlock()
+	log.LogErrorf("action[triggerDiskError] disk path %v error(%v) on %v, errors(%v/%v)", d.Path, err, LocalIP, errCnt, d.MaxErrCnt)
+	if isolate {
+		d.isolate()
+	}
+}
+
+// isolate stops the partitions of the bad disk and reports the disk to the master, which
+// repairs its partitions on the other data nodes. The other disks keep being served.
+func (d *Disk) isolate() {
+	mesg := fmt.Sprintf("disk path %v is bad on %v, stop its partitions", d.Path, LocalIP)
+	exporter.Warning(mesg)
+	log.LogErrorf(mesg)
+	d.ForceExitRaftStore()
+	go func() {
+		// the bad disks are reported by the heartbeats too
+		if err := MasterClient.NodeAPI().ReportDataNodeBadDisk(d.dataNode.localServerAddr, d.Path); err != nil {
+			log.LogWarnf("action[isolate] disk(%v) report to master err(%v)", d.Path, err)
+		}
+	}()
 }
 
 func (d *Disk) updateSpaceInfo() (err error) {
 	var statsInfo syscall.Statfs_t
 	if err = syscall.Statfs(d.Path, &statsInfo); err != nil {
 		d.incReadErrCnt()
+		d.triggerDiskError(err)
 	}
 	if d.Status == proto.Unavailable {
 		mesg := fmt.Sprintf("disk path %v error on %v", d.Path, LocalIP)
@@ -298,6 +412,9 @@ func (d *Disk) ForceExitRaftStore() {
 	partitionList := d.DataPartitionList()
 	for _, partitionID := range partitionList {
 		partition := d.GetDataPartition(partitionID)
+		if partition == nil {
+			continue
+		}
 		partition.partitionStatus = proto.Unavailable
 		partition.stopRaft()
 	}
@@ -369,7 +486,7 @@ func (d *Disk) RestorePartition(visitor PartitionVisitor) {
 		return
 	}
 
-	var wg sync.WaitGroup
+	filenames := make(map[uint64]string)
 	for _, fileInfo := range fileInfoList {
 		filename := fileInfo.Name()
 		if !d.isPartitionDir(filename) {
@@ -392,15 +509,24 @@ func (d *Disk) RestorePartition(visitor PartitionVisitor) {
 			os.Rename(oldName, newName)
 			continue
 		}
+		filenames[partitionID] = filename
+	}
+	atomic.AddInt64(&d.space.partitionsToLoad, int64(len(filenames)))
 
+	var wg sync.WaitGroup
+	for partitionID, filename := range filenames {
 		wg.Add(1)
-
 		go func(partitionID uint64, filename string) {
 			var (
 				dp  *DataPartition
 				err error
 			)
-			defer wg.Done()
+			d.space.loadLimitC <- struct{}{}
+			defer func() {
+				<-d.space.loadLimitC
+				atomic.AddInt64(&d.space.partitionsLoaded, 1)
+				wg.Done()
+			}()
 			if dp, err = LoadDataPartition(path.Join(d.Path, filename), d); err != nil {
 				mesg := fmt.Sprintf("action[RestorePartition] new partition(%v) err(%v) ",
 					partitionID, err.Error())
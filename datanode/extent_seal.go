@@ -0,0 +1,184 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	ExtentSealsFileName = "SEALS"
+)
+
+// extentSeals keeps the ranges of the extents held by the immutable and append-only inodes, sealed by
+// the meta nodes when the flags are set, which the clients can not overwrite even through the handles
+// opened before. The appends beyond them are refused by the meta nodes instead.
+type extentSeals struct {
+	sync.RWMutex
+	ranges map[uint64][]sealedRange // sorted and merged ranges by the extent id
+}
+
+type sealedRange struct {
+	Offset uint64
+	Size   uint64
+}
+
+func (r sealedRange) end() uint64 {
+	return r.Offset + r.Size
+}
+
+func newExtentSeals() *extentSeals {
+	return &extentSeals{ranges: make(map[uint64][]sealedRange)}
+}
+
+// seal adds the range to the sealed ones of the extent, merging the ranges adjacent or overlapping.
+func (s *extentSeals) seal(extentID, offset, size uint64) {
+	if size == 0 {
+		return
+	}
+	merged := sealedRange{Offset: offset, Size: size}
+	ranges := make([]sealedRange, 0, len(s.ranges[extentID])+1)
+	for _, r := range s.ranges[extentID] {
+		if r.end() < merged.Offset || r.Offset > merged.end() {
+			ranges = append(ranges, r)
+			continue
+		}
+		end := merged.end()
+		if r.end() > end {
+			end = r.end()
+		}
+		if r.Offset < merged.Offset {
+			merged.Offset = r.Offset
+		}
+		merged.Size = end - merged.Offset
+	}
+	ranges = append(ranges, merged)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Offset < ranges[j].Offset })
+	s.ranges[extentID] = ranges
+}
+
+// unseal removes the range from the sealed ones of the extent.
+func (s *extentSeals) unseal(extentID, offset, size uint64) {
+	end := offset + size
+	ranges := make([]sealedRange, 0, len(s.ranges[extentID])+1)
+	for _, r := range s.ranges[extentID] {
+		if r.end() <= offset || r.Offset >= end {
+			ranges = append(ranges, r)
+			continue
+		}
+		if r.Offset < offset {
+			ranges = append(ranges, sealedRange{Offset: r.Offset, Size: offset - r.Offset})
+		}
+		if r.end() > end {
+			ranges = append(ranges, sealedRange{Offset: end, Size: r.end() - end})
+		}
+	}
+	if len(ranges) == 0 {
+		delete(s.ranges, extentID)
+		return
+	}
+	s.ranges[extentID] = ranges
+}
+
+// isSealed returns if the range of the extent overlaps a sealed one.
+func (s *extentSeals) isSealed(extentID, offset, size uint64) bool {
+	if s == nil {
+		return false
+	}
+	s.RLock()
+	defer s.RUnlock()
+	ranges := s.ranges[extentID]
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].end() > offset })
+	return i < len(ranges) && ranges[i].Offset < offset+size
+}
+
+func (dp *DataPartition) loadExtentSeals() (err error) {
+	data, err := ioutil.ReadFile(path.Join(dp.path, ExtentSealsFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	return json.Unmarshal(data, &dp.seals.ranges)
+}
+
+// sealExtents seals or unseals the ranges of the extents, and persists them.
+func (dp *DataPartition) sealExtents(request *proto.SealExtentsRequest) (err error) {
+	dp.seals.Lock()
+	defer dp.seals.Unlock()
+	for _, ek := range request.Extents {
+		if request.Unseal {
+			dp.seals.unseal(ek.ExtentId, ek.ExtentOffset, uint64(ek.Size))
+		} else {
+			dp.seals.seal(ek.ExtentId, ek.ExtentOffset, uint64(ek.Size))
+		}
+	}
+	data, err := json.Marshal(dp.seals.ranges)
+	if err != nil {
+		return
+	}
+	tmpPath := path.Join(dp.path, ExtentSealsFileName+".tmp")
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return
+	}
+	return os.Rename(tmpPath, path.Join(dp.path, ExtentSealsFileName))
+}
+
+func (s *DataNode) handlePacketToSealExtents(p *repl.Packet) {
+	partition := p.Object.(*DataPartition)
+	request := &proto.SealExtentsRequest{}
+	if err := json.Unmarshal(p.Data, request); err != nil {
+		p.PackErrorBody(ActionSealExtents, err.Error())
+		return
+	}
+	if err := partition.sealExtents(request); err != nil {
+		log.LogErrorf("action[handlePacketToSealExtents] partition(%v) unseal(%v) err(%v)",
+			partition.partitionID, request.Unseal, err)
+		p.PackErrorBody(ActionSealExtents, err.Error())
+		return
+	}
+	p.PacketOkReply()
+}
+
+// checkExtentSeal refuses the writes from the clients overlapping the sealed ranges of the extents. The
+// new tiny extent writes are not assigned an extent yet and only append.
+func (s *DataNode) checkExtentSeal(p *repl.Packet) (err error) {
+	if !(p.IsLeaderPacket() && p.IsWriteOperation()) && !p.IsRandomWrite() {
+		return
+	}
+	dp := p.Object.(*DataPartition)
+	if p.ExtentID != 0 && dp.seals.isSealed(p.ExtentID, uint64(p.ExtentOffset), uint64(p.Size)) {
+		err = proto.ErrExtentSealed
+	}
+	return
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+)
+
+func TestExtentSealRanges(t *testing.T) {
+	cases := []struct {
+		name   string
+		unseal bool
+		offset uint64
+		size   uint64
+		expect string
+	}{
+		{"seal", false, 100, 100, "[{100 100}]"},
+		{"seal apart", false, 300, 100, "[{100 100} {300 100}]"},
+		{"seal adjacent", false, 200, 50, "[{100 150} {300 100}]"},
+		{"seal overlapping both", false, 240, 70, "[{100 300}]"},
+		{"unseal middle", true, 150, 100, "[{100 50} {250 150}]"},
+		{"unseal head", true, 0, 120, "[{120 30} {250 150}]"},
+		{"unseal all", true, 0, 1000, "[]"},
+	}
+	seals := newExtentSeals()
+	for _, c := range cases {
+		if c.unseal {
+			seals.unseal(1, c.offset, c.size)
+		} else {
+			seals.seal(1, c.offset, c.size)
+		}
+		if ranges := fmt.Sprint(seals.ranges[1]); ranges != c.expect {
+			t.Errorf("%v: ranges %v, expected %v", c.name, ranges, c.expect)
+		}
+	}
+}
+
+func TestCheckExtentSeal(t *testing.T) {
+	cases := []struct {
+		name      string
+		opcode    uint8
+		followers uint8
+		extentID  uint64
+		offset    int64
+		size      uint32
+		sealed    bool
+	}{
+		{"overwrite", proto.OpRandomWrite, 0, 1, 150, 10, true},
+		{"sync overwrite ending in the seal", proto.OpSyncRandomWrite, 0, 1, 90, 20, true},
+		{"overwrite before the seal", proto.OpRandomWrite, 0, 1, 0, 100, false},
+		{"append after the seal", proto.OpWrite, 2, 1, 200, 10, false},
+		{"append into the seal", proto.OpWrite, 2, 1, 190, 20, true},
+		{"forwarded append", proto.OpWrite, 0, 1, 190, 20, false},
+		{"other extent", proto.OpRandomWrite, 0, 2, 150, 10, false},
+		{"new tiny extent", proto.OpWrite, 2, 0, 150, 10, false},
+		{"read", proto.OpStreamRead, 0, 1, 150, 10, false},
+	}
+	s := &DataNode{}
+	dp := &DataPartition{seals: newExtentSeals()}
+	dp.seals.seal(1, 100, 100)
+	for _, c := range cases {
+		p := repl.NewPacket()
+		p.Opcode = c.opcode
+		p.RemainingFollowers = c.followers
+		p.ExtentID = c.extentID
+		p.ExtentOffset = c.offset
+		p.Size = c.size
+		p.Object = dp
+		if err := s.checkExtentSeal(p); (err == proto.ErrExtentSealed) != c.sealed {
+			t.Errorf("%v: err %v, expected sealed(%v)", c.name, err, c.sealed)
+		}
+	}
+}
+
+func TestSealExtentsPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cases := []struct {
+		name   string
+		unseal bool
+		expect string
+	}{
+		{"sealed", false, "map[1:[{0 100}] 2:[{4096 10}]]"},
+		{"unsealed", true, "map[]"},
+	}
+	s := &DataNode{}
+	for _, c := range cases {
+		dp := &DataPartition{path: dir, seals: newExtentSeals()}
+		if err = dp.loadExtentSeals(); err != nil {
+			t.Fatalf("%v: load seals err %v", c.name, err)
+		}
+		request := &proto.SealExtentsRequest{Unseal: c.unseal, Extents: []proto.ExtentKey{
+			{PartitionId: 1, ExtentId: 1, ExtentOffset: 0, Size: 100},
+			{PartitionId: 1, ExtentId: 2, ExtentOffset: 4096, Size: 10},
+		}}
+		p := repl.NewPacket()
+		p.Opcode = proto.OpSealExtents
+		p.Data, _ = json.Marshal(request)
+		p.Size = uint32(len(p.Data))
+		p.Object = dp
+		s.handlePacketToSealExtents(p)
+		if p.ResultCode != proto.OpOk {
+			t.Fatalf("%v: seal extents result %v", c.name, p.GetResultMsg())
+		}
+		loaded := &DataPartition{path: dir, seals: newExtentSeals()}
+		if err = loaded.loadExtentSeals(); err != nil {
+			t.Fatalf("%v: load seals err %v", c.name, err)
+		}
+		if ranges := fmt.Sprint(loaded.seals.ranges); ranges != c.expect {
+			t.Errorf("%v: ranges %v, expected %v", c.name, ranges, c.expect)
+		}
+	}
+}
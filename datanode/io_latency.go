@@ -0,0 +1,70 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// ioLatency observes the latency of the reads, writes and fsyncs of the extents of a data
+// partition. The latency is exported as a histogram labeled by the partition, and an IO
+// slower than the slow IO threshold of the data node is logged along with its disk and
+// extent, so that a failing disk or a hot partition stands out.
+type ioLatency struct {
+	dp            *DataPartition
+	latencyLabels map[string]map[string]string // by IO type
+	slowLabels    map[string]map[string]string // by IO type
+}
+
+func newIOLatency(dp *DataPartition) *ioLatency {
+	l := &ioLatency{
+		dp:            dp,
+		latencyLabels: make(map[string]map[string]string),
+		slowLabels:    make(map[string]map[string]string),
+	}
+	for _, ioType := range []string{storage.IOTypeRead, storage.IOTypeWrite, storage.IOTypeSync} {
+		labels := GetIoMetricLabels(dp, ioType)
+		l.slowLabels[ioType] = labels
+		latencyLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			latencyLabels[k] = v
+		}
+		latencyLabels[exporter.PartId] = fmt.Sprintf("%d", dp.partitionID)
+		l.latencyLabels[ioType] = latencyLabels
+	}
+	return l
+}
+
+func (l *ioLatency) observe(ioType string, extentID uint64, size int64, start time.Time, err error) {
+	exporter.NewTPSince(MetricIOLatencyName, start).SetWithLabels(l.latencyLabels[ioType])
+	threshold := l.dp.dataNode.slowIOThreshold
+	if threshold <= 0 {
+		return
+	}
+	cost := time.Since(start)
+	if cost < threshold {
+		return
+	}
+	if metrics := l.dp.dataNode.metrics; metrics != nil {
+		metrics.MetricSlowIO.AddWithLabels(1, l.slowLabels[ioType])
+	}
+	log.LogWarnf("action[slowIO] type(%v) disk(%v) vol(%v) partition(%v) extent(%v) size(%v) cost(%v) err(%v)",
+		ioType, l.dp.disk.Path, l.dp.volumeID, l.dp.partitionID, extentID, size, cost, err)
+}
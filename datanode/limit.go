@@ -4,9 +4,14 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/cubefs/cubefs/util"
 	"golang.org/x/time/rate"
 )
 
+const (
+	defaultDiskIOLimitBurst = 4 * util.MB
+)
+
 var (
 	deleteLimiteRater      = rate.NewLimiter(rate.Inf, defaultMarkDeleteLimitBurst)
 	MaxExtentRepairLimit   = 20000
@@ -80,3 +85,13 @@ func setLimiter(limiter *rate.Limiter, limitValue uint64) {
 	}
 	limiter.SetLimit(l)
 }
+
+// limiterWaitN waits for n tokens, by pieces no larger than the burst of the limiter.
+func limiterWaitN(limiter *rate.Limiter, n int) {
+	ctx := context.Background()
+	for n > 0 {
+		m := util.Min(n, limiter.Burst())
+		limiter.WaitN(ctx, m)
+		n -= m
+	}
+}
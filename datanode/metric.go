@@ -23,15 +23,41 @@ import (
 const (
 	MetricPartitionIOName      = "dataPartitionIO"
 	MetricPartitionIOBytesName = "dataPartitionIOBytes"
+	MetricDeleteExtentName     = "dataPartitionDeleteExtent"
+	MetricDeleteRejectName     = "dataPartitionDeleteReject"
+	MetricCorruptBlockName     = "dataPartitionCorruptBlock"
+	MetricCompressedBlockName  = "dataPartitionCompressedBlock"
+	MetricCompressSavedName    = "dataPartitionCompressSavedBytes"
+	MetricPackedExtentName     = "dataPartitionPackedExtent"
+	MetricPackFileName         = "dataPartitionPackFile"
+	MetricIOLatencyName        = "dataPartitionIOLatency"
+	MetricSlowIOName           = "dataPartitionSlowIO"
 )
 
 type DataNodeMetrics struct {
-	MetricIOBytes *exporter.Counter
+	MetricIOBytes      *exporter.Counter
+	MetricDeleteExtent *exporter.Counter // extents deleted on request of the meta nodes
+	MetricDeleteReject *exporter.Counter // extent deletions rejected by the delete limiter, to be retried later
+	MetricCorruptBlock *exporter.Counter // blocks found corrupt by the scrubber
+	MetricSlowIO       *exporter.Counter // data IO slower than the slow IO threshold
+
+	MetricCompressedBlock    *exporter.Gauge // blocks stored compressed
+	MetricCompressSavedBytes *exporter.Gauge // disk space saved by the compression
+	MetricPackedExtent       *exporter.Gauge // extents stored in the pack files
+	MetricPackFile           *exporter.Gauge // pack files holding the packed extents
 }
 
 func (d *DataNode) registerMetrics() {
 	d.metrics = &DataNodeMetrics{}
 	d.metrics.MetricIOBytes = exporter.NewCounter(MetricPartitionIOBytesName)
+	d.metrics.MetricDeleteExtent = exporter.NewCounter(MetricDeleteExtentName)
+	d.metrics.MetricDeleteReject = exporter.NewCounter(MetricDeleteRejectName)
+	d.metrics.MetricCorruptBlock = exporter.NewCounter(MetricCorruptBlockName)
+	d.metrics.MetricSlowIO = exporter.NewCounter(MetricSlowIOName)
+	d.metrics.MetricCompressedBlock = exporter.NewGauge(MetricCompressedBlockName)
+	d.metrics.MetricCompressSavedBytes = exporter.NewGauge(MetricCompressSavedName)
+	d.metrics.MetricPackedExtent = exporter.NewGauge(MetricPackedExtentName)
+	d.metrics.MetricPackFile = exporter.NewGauge(MetricPackFileName)
 }
 
 func GetIoMetricLabels(partition *DataPartition, tp string) map[string]string {
@@ -1,8 +1,10 @@
 package datanode
 
 import (
+	"sync/atomic"
 	"time"
 
+	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
 	"golang.org/x/time/rate"
 )
@@ -15,6 +17,11 @@ const (
 
 var (
 	nodeInfoStopC = make(chan struct{}, 0)
+	// set once the master pushes the repair limits by the heartbeats, which take
+	// precedence over the ones of the cluster info
+	repairLimitPushed int32
+	// the repair limits pushed last, applied again when their time windows change
+	pushedRepairLimit atomic.Value
 )
 
 func (m *DataNode) startUpdateNodeInfo() {
@@ -42,8 +49,36 @@ func (m *DataNode) updateNodeInfo() {
 		return
 	}
 	setLimiter(deleteLimiteRater, clusterInfo.DataNodeDeleteLimitRate)
-	setDoExtentRepair(int(clusterInfo.DataNodeAutoRepairLimitRate))
+	if limit, ok := pushedRepairLimit.Load().(*proto.DataNodeRepairLimit); ok {
+		m.applyRepairLimit(limit)
+	}
+	if atomic.LoadInt32(&repairLimitPushed) == 0 {
+		setDoExtentRepair(int(clusterInfo.DataNodeAutoRepairLimitRate))
+		m.space.SetDiskIOLimits(clusterInfo.DataNodeDiskClientIOLimitRate, clusterInfo.DataNodeDiskRepairIOLimitRate)
+	} else {
+		m.space.SetDiskClientIOLimit(clusterInfo.DataNodeDiskClientIOLimitRate)
+	}
 	log.LogInfof("updateNodeInfo from master:"+
-		"deleteLimite(%v),autoRepairLimit(%v)", clusterInfo.DataNodeDeleteLimitRate,
-		clusterInfo.DataNodeAutoRepairLimitRate)
+		"deleteLimite(%v),autoRepairLimit(%v),diskClientIOLimit(%v),diskRepairIOLimit(%v)", clusterInfo.DataNodeDeleteLimitRate,
+		clusterInfo.DataNodeAutoRepairLimitRate, clusterInfo.DataNodeDiskClientIOLimitRate, clusterInfo.DataNodeDiskRepairIOLimitRate)
+}
+
+// setRepairLimit applies the repair limits pushed by the master.
+func (m *DataNode) setRepairLimit(limit *proto.DataNodeRepairLimit) {
+	if limit == nil {
+		return
+	}
+	atomic.StoreInt32(&repairLimitPushed, 1)
+	pushedRepairLimit.Store(limit)
+	m.applyRepairLimit(limit)
+}
+
+// applyRepairLimit applies the repair limits in effect at the moment, the ones of the time
+// window the local time is in if any.
+func (m *DataNode) applyRepairLimit(limit *proto.DataNodeRepairLimit) {
+	extentRepairLimit, diskRepairIOLimitRate := limit.LimitsAt(time.Now())
+	setDoExtentRepair(int(extentRepairLimit))
+	m.space.SetDiskRepairIOLimit(diskRepairIOLimitRate)
+	log.LogDebugf("setRepairLimit from master: autoRepairLimit(%v),diskRepairIOLimit(%v),windows(%v)",
+		extentRepairLimit, diskRepairIOLimitRate, len(limit.Windows))
 }
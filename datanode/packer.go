@@ -0,0 +1,148 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"context"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ConfigKeyPackRate          = "packRate"          // int, bytes read per second, a negative value disables the packer
+	ConfigKeyPackInterval      = "packInterval"      // int, seconds between two rounds
+	ConfigKeyPackColdTime      = "packColdTime"      // int, seconds an extent is not modified before being packed
+	ConfigKeyPackMaxExtentSize = "packMaxExtentSize" // int, bytes, the larger extents are not packed
+
+	DefaultPackRate          = 20 * util.MB
+	DefaultPackInterval      = 60 * 60
+	DefaultPackColdTime      = 24 * 60 * 60
+	DefaultPackMaxExtentSize = 1 * util.MB
+)
+
+// packer moves the small cold extents of the partitions into the pack files, so that
+// the small writes do not use up the file descriptors and the inodes of the disks.
+type packer struct {
+	limiter       *rate.Limiter
+	interval      time.Duration
+	coldTime      int64
+	maxExtentSize uint64
+}
+
+func (s *DataNode) startPacker(cfg *config.Config) {
+	packRate := cfg.GetInt64(ConfigKeyPackRate)
+	if packRate < 0 {
+		log.LogInfof("action[startPacker] packer is disabled")
+		return
+	}
+	if packRate == 0 {
+		packRate = DefaultPackRate
+	}
+	interval := cfg.GetInt64(ConfigKeyPackInterval)
+	if interval <= 0 {
+		interval = DefaultPackInterval
+	}
+	coldTime := cfg.GetInt64(ConfigKeyPackColdTime)
+	if coldTime <= storage.UpdateCrcInterval {
+		coldTime = DefaultPackColdTime
+	}
+	maxExtentSize := cfg.GetInt64(ConfigKeyPackMaxExtentSize)
+	if maxExtentSize <= 0 {
+		maxExtentSize = DefaultPackMaxExtentSize
+	}
+	burst := util.BlockSize
+	if int(maxExtentSize) > burst {
+		burst = int(maxExtentSize)
+	}
+	s.packer = &packer{
+		limiter:       rate.NewLimiter(rate.Limit(packRate), burst),
+		interval:      time.Duration(interval) * time.Second,
+		coldTime:      coldTime,
+		maxExtentSize: uint64(maxExtentSize),
+	}
+	log.LogInfof("action[startPacker] rate(%v) interval(%v) coldTime(%v) maxExtentSize(%v)",
+		packRate, s.packer.interval, coldTime, maxExtentSize)
+	go s.pack()
+}
+
+func (s *DataNode) pack() {
+	for {
+		select {
+		case <-s.stopC:
+			return
+		case <-time.After(s.packer.interval):
+		}
+		partitions := make([]*DataPartition, 0)
+		s.space.RangePartitions(func(dp *DataPartition) bool {
+			partitions = append(partitions, dp)
+			return true
+		})
+		for _, dp := range partitions {
+			select {
+			case <-s.stopC:
+				return
+			default:
+			}
+			s.packPartition(dp)
+			extents, packs := dp.ExtentStore().PackStats()
+			labels := map[string]string{exporter.Vol: dp.volumeID, exporter.Disk: dp.disk.Path}
+			s.metrics.MetricPackedExtent.SetWithLabels(float64(extents), labels)
+			s.metrics.MetricPackFile.SetWithLabels(float64(packs), labels)
+		}
+	}
+}
+
+func (s *DataNode) packPartition(dp *DataPartition) {
+	if dp.ECStatus() != proto.ECStatusNone {
+		// the extents are erasure coded
+		return
+	}
+	store := dp.ExtentStore()
+	extents, _, err := store.GetAllWatermarks(storage.NormalExtentFilter())
+	if err != nil {
+		log.LogWarnf("action[packPartition] partition(%v) err(%v)", dp.partitionID, err)
+		return
+	}
+	var packed int
+	for _, ei := range extents {
+		if ei.Size > s.packer.maxExtentSize || time.Now().Unix()-ei.ModifyTime <= s.packer.coldTime ||
+			store.IsPackedExtent(ei.FileID) {
+			continue
+		}
+		ok, err := store.PackExtent(ei.FileID, s.packer.wait)
+		if err != nil {
+			dp.checkIsDiskError(err)
+			log.LogWarnf("action[packPartition] partition(%v) extent(%v) err(%v)", dp.partitionID, ei.FileID, err)
+			continue
+		}
+		if ok {
+			packed++
+		}
+	}
+	if packed > 0 {
+		log.LogDebugf("action[packPartition] partition(%v) packed extents(%v)", dp.partitionID, packed)
+	}
+}
+
+func (p *packer) wait(n int) {
+	p.limiter.WaitN(context.Background(), n)
+}
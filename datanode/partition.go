@@ -65,6 +65,8 @@ type DataPartitionMetadata struct {
 	Hosts                   []string
 	DataPartitionCreateType int
 	LastTruncateID          uint64
+	Encrypted               bool
+	ChecksumType            uint8
 }
 
 type sortedPeers []proto.Peer
@@ -127,6 +129,17 @@ type DataPartition struct {
 	DataPartitionCreateType       int
 	isLoadingDataPartition        bool
 	persistMetaMutex              sync.RWMutex
+	isChecking                    int32 // the consistency check of the replicas is running
+	backingUp                     int32 // the partition is being backed up or restored
+	verifyWrite                   int32 // the writes are read back and verified before they are acknowledged
+	accessStats                   *accessStats
+	ecLock                        sync.RWMutex
+	ec                            *ecMeta // the erasure coding of the partition, nil if it is replicated
+	ecRunning                     int32   // a phase of the erasure coding is running
+	ecEncoded                     int64
+	ecTotal                       int64
+	extentWriteTime               int64 // the last modification time of the extents when the partition is loaded
+	seals                         *extentSeals
 }
 
 func CreateDataPartition(dpCfg *dataPartitionCfg, disk *Disk, request *proto.CreateDataPartitionRequest) (dp *DataPartition, err error) {
@@ -211,6 +224,8 @@ func LoadDataPartition(partitionDir string, disk *Disk) (dp *DataPartition, err
 		PartitionID:   meta.PartitionID,
 		Peers:         meta.Peers,
 		Hosts:         meta.Hosts,
+		Encrypted:     meta.Encrypted,
+		ChecksumType:  meta.ChecksumType,
 		RaftStore:     disk.space.GetRaftStore(),
 		NodeID:        disk.space.GetNodeID(),
 		ClusterID:     disk.space.GetClusterID(),
@@ -261,12 +276,37 @@ func newDataPartition(dpCfg *dataPartitionCfg, disk *Disk) (dp *DataPartition, e
 		partitionStatus: proto.ReadWrite,
 		config:          dpCfg,
 		raftStatus:      RaftStatusStopped,
+		accessStats:     newAccessStats(),
+		seals:           newExtentSeals(),
 	}
 	partition.replicasInit()
 	partition.extentStore, err = storage.NewExtentStore(partition.path, dpCfg.PartitionID, dpCfg.PartitionSize)
 	if err != nil {
 		return
 	}
+	partition.extentStore.SetChecksumType(dpCfg.ChecksumType)
+	partition.extentStore.SetIOObserver(newIOLatency(partition).observe)
+	if dpCfg.Encrypted {
+		// the IO of the partition fails until the master sends the key of the volume
+		partition.extentStore.SetEncrypted()
+		if key := disk.space.getVolEncryptKey(dpCfg.VolName); key != nil {
+			if err = partition.extentStore.SetEncryptKey(key); err != nil {
+				return
+			}
+		}
+	}
+	disk.dataNode.cacheTier.replay(partition.extentStore)
+	if disk.cache != nil && !dpCfg.Encrypted {
+		partition.extentStore.SetBlockCache(disk.cache)
+	}
+	disk.dataNode.replayWriteBuffer(partition.extentStore, dpCfg.Encrypted)
+
+	if err = partition.loadECMeta(); err != nil {
+		return
+	}
+	if err = partition.loadExtentSeals(); err != nil {
+		return
+	}
 
 	disk.AttachDataPartition(partition)
 	dp = partition
@@ -477,6 +517,8 @@ func (dp *DataPartition) PersistMetadata() (err error) {
 		DataPartitionCreateType: dp.DataPartitionCreateType,
 		CreateTime:              time.Now().Format(TimeLayout),
 		LastTruncateID:          dp.lastTruncateID,
+		Encrypted:               dp.config.Encrypted,
+		ChecksumType:            dp.config.ChecksumType,
 	}
 	if metaData, err = json.Marshal(md); err != nil {
 		return
@@ -585,12 +627,10 @@ func (dp *DataPartition) checkIsDiskError(err error) (diskError bool) {
 		mesg := fmt.Sprintf("checkIsDiskError disk path %v error on %v", dp.Path(), LocalIP)
 		exporter.Warning(mesg)
 		log.LogErrorf(mesg)
-		dp.stopRaft()
 		dp.disk.incReadErrCnt()
 		dp.disk.incWriteErrCnt()
-		dp.disk.Status = proto.Unavailable
+		dp.disk.triggerDiskError(err)
 		dp.statusUpdate()
-		dp.disk.ForceExitRaftStore()
 		diskError = true
 	}
 	return
@@ -727,6 +767,16 @@ func (dp *DataPartition) DoExtentStoreRepair(repairTask *DataPartitionRepairTask
 			continue
 		}
 	}
+	for _, block := range repairTask.BlocksToBeRepaired {
+		if !AutoRepairStatus {
+			log.LogWarnf("AutoRepairStatus is False,so cannot repair block(%v) of extent(%v)", block.BlockNo, block.ExtentID)
+			continue
+		}
+		if err := dp.repairBlock(block); err != nil {
+			log.LogWarnf("action[DoExtentStoreRepair] partition(%v) extent(%v) block(%v) err(%v)",
+				dp.partitionID, block.ExtentID, block.BlockNo, err)
+		}
+	}
 
 	var (
 		wg           *sync.WaitGroup
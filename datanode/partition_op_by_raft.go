@@ -27,6 +27,7 @@ import (
 	"github.com/tiglabs/raft"
 	"net"
 	"strings"
+	"time"
 )
 
 type RaftCmdItem struct {
@@ -237,9 +238,22 @@ func (dp *DataPartition) ApplyRandomWrite(command []byte, raftApplyID uint64) (r
 	log.LogDebugf("[ApplyRandomWrite] ApplyID(%v) Partition(%v)_Extent(%v)_ExtentOffset(%v)_Size(%v)",
 		raftApplyID, dp.partitionID, opItem.extentID, opItem.offset, opItem.size)
 
+	// the key of an encrypted partition comes with the heartbeats of the master after a restart
+	for !dp.ExtentStore().EncryptKeyLoaded() {
+		log.LogWarnf("[ApplyRandomWrite] ApplyID(%v) Partition(%v) waits for the encrypt key", raftApplyID, dp.partitionID)
+		time.Sleep(time.Second)
+	}
+
 	for i := 0; i < 20; i++ {
-		err = dp.ExtentStore().Write(opItem.extentID, opItem.offset, opItem.size, opItem.data, opItem.crc, storage.RandomWriteType, opItem.opcode == proto.OpSyncRandomWrite)
+		verify := dp.VerifiesWrite()
+		err = dp.ExtentStore().Write(opItem.extentID, opItem.offset, opItem.size, opItem.data, opItem.crc, storage.RandomWriteType, opItem.opcode == proto.OpSyncRandomWrite || verify)
+		if err == nil && verify {
+			// the data is written again if it does not match
+			store := dp.ExtentStore()
+			err = dp.checkWrite(opItem.extentID, opItem.offset, opItem.size, store.ChecksumType(), proto.Checksum(store.ChecksumType(), opItem.data[:opItem.size]))
+		}
 		if err == nil {
+			dp.accessStats.recordWrite(opItem.extentID, uint32(opItem.size))
 			break
 		}
 		if IsDiskErr(err.Error()) {
@@ -257,7 +271,7 @@ func (dp *DataPartition) ApplyRandomWrite(command []byte, raftApplyID uint64) (r
 
 // RandomWriteSubmit submits the proposal to raft.
 func (dp *DataPartition) RandomWriteSubmit(pkg *repl.Packet) (err error) {
-	val, err := MarshalRandWriteRaftLog(pkg.Opcode, pkg.ExtentID, pkg.ExtentOffset, int64(pkg.Size), pkg.Data, pkg.CRC)
+	val, err := MarshalRandWriteRaftLog(pkg.Opcode, pkg.ExtentID, pkg.ExtentOffset, int64(pkg.Size), pkg.Data, storeCrc(pkg, dp.extentStore))
 	if err != nil {
 		return
 	}
@@ -43,6 +43,8 @@ type dataPartitionCfg struct {
 	PartitionSize int                 `json:"partition_size"`
 	Peers         []proto.Peer        `json:"peers"`
 	Hosts         []string            `json:"hosts"`
+	Encrypted     bool                `json:"encrypted"`
+	ChecksumType  uint8               `json:"checksum_type"`
 	NodeID        uint64              `json:"-"`
 	RaftStore     raftstore.RaftStore `json:"-"`
 }
@@ -102,11 +104,12 @@ func (dp *DataPartition) StartRaft() (err error) {
 	log.LogDebugf("start partition(%v) raft peers: %s path: %s",
 		dp.partitionID, peers, dp.path)
 	pc := &raftstore.PartitionConfig{
-		ID:      uint64(dp.partitionID),
-		Applied: dp.appliedID,
-		Peers:   peers,
-		SM:      dp,
-		WalPath: dp.path,
+		ID:       uint64(dp.partitionID),
+		Applied:  dp.appliedID,
+		Peers:    peers,
+		SM:       dp,
+		WalPath:  dp.path,
+		WalGroup: dp.disk.Path,
 	}
 
 	dp.raftPartition, err = dp.config.RaftStore.CreatePartition(pc)
@@ -129,6 +132,15 @@ func (dp *DataPartition) stopRaft() {
 	return
 }
 
+// SetSnapshotRateLimit limits the rate of the raft snapshot transfer of the data partition.
+func (dp *DataPartition) SetSnapshotRateLimit(bytesPerSec int64) error {
+	if dp.raftStopped() || dp.raftPartition == nil {
+		return fmt.Errorf("raft of partition(%v) is not running", dp.partitionID)
+	}
+	dp.raftPartition.SetSnapshotRateLimit(bytesPerSec)
+	return nil
+}
+
 func (dp *DataPartition) CanRemoveRaftMember(peer proto.Peer) error {
 	downReplicas := dp.config.RaftStore.RaftServer().GetDownReplicas(dp.partitionID)
 	hasExsit := false
@@ -448,9 +460,26 @@ func (s *DataNode) parseRaftConfig(cfg *config.Config) (err error) {
 	s.raftHeartbeat = cfg.GetString(ConfigKeyRaftHeartbeat)
 	s.raftReplica = cfg.GetString(ConfigKeyRaftReplica)
 	s.raftRecvBufSize = int(cfg.GetInt(CfgRaftRecvBufSize))
+	s.raftPreVote = cfg.GetBool(CfgRaftPreVote)
+	s.raftSnapLimit = cfg.GetInt64(CfgRaftSnapRateLimit)
+	s.raftWalRepair = cfg.GetBool(CfgRaftWalRepair)
+	s.raftPropBatch = int(cfg.GetInt(CfgRaftPropBatch))
+	s.raftTLSCertFile = cfg.GetString(CfgRaftTLSCertFile)
+	s.raftTLSKeyFile = cfg.GetString(CfgRaftTLSKeyFile)
+	s.raftTLSCAFile = cfg.GetString(CfgRaftTLSCAFile)
+	s.raftWalDirs = make(map[string]string)
+	for _, d := range cfg.GetSlice(CfgRaftWalDirs) {
+		// format "DISK_PATH:WAL_DIR"
+		arr := strings.Split(d.(string), ":")
+		if len(arr) != 2 || arr[0] == "" || arr[1] == "" {
+			return fmt.Errorf("bad raftWalDirs config(%v), example: DISK_PATH:WAL_DIR", d)
+		}
+		s.raftWalDirs[arr[0]] = arr[1]
+	}
 	log.LogDebugf("[parseRaftConfig] load raftDir(%v).", s.raftDir)
 	log.LogDebugf("[parseRaftConfig] load raftHearbeat(%v).", s.raftHeartbeat)
 	log.LogDebugf("[parseRaftConfig] load raftReplica(%v).", s.raftReplica)
+	log.LogDebugf("[parseRaftConfig] load raftWalDirs(%v).", s.raftWalDirs)
 	return
 }
 
@@ -489,15 +518,30 @@ func (s *DataNode) startRaftServer(cfg *config.Config) (err error) {
 		return
 	}
 
+	raftIP := LocalIP
+	if s.replicaAddr() != "" {
+		// listen on all the interfaces, the peers which have not learned the replication
+		// address of this node yet still send the raft messages to the service address
+		raftIP = ""
+	}
 	raftConf := &raftstore.Config{
 		NodeID:            s.nodeID,
 		RaftPath:          s.raftDir,
-		IPAddr:            LocalIP,
+		IPAddr:            raftIP,
+		ReplicaIPOf:       gReplicaAddrs.IP,
 		HeartbeatPort:     heartbeatPort,
 		ReplicaPort:       replicatePort,
 		NumOfLogsToRetain: DefaultRaftLogsToRetain,
 		TickInterval:      s.tickInterval,
 		RecvBufSize:       s.raftRecvBufSize,
+		PreVote:           s.raftPreVote,
+		SnapshotRateLimit: s.raftSnapLimit,
+		WalRepair:         s.raftWalRepair,
+		WalDirs:           s.raftWalDirs,
+		ProposalBatchSize: s.raftPropBatch,
+		TLSCertFile:       s.raftTLSCertFile,
+		TLSKeyFile:        s.raftTLSKeyFile,
+		TLSCAFile:         s.raftTLSCAFile,
 	}
 	s.raftStore, err = raftstore.NewRaftStore(raftConf)
 	if err != nil {
@@ -582,13 +626,13 @@ func (dp *DataPartition) findMaxAppliedID(allAppliedIDs []uint64) (maxAppliedID
 // Get the partition size from the leader.
 func (dp *DataPartition) getLeaderPartitionSize(maxExtentID uint64) (size uint64, err error) {
 	var (
-		conn *net.TCPConn
+		conn net.Conn
 	)
 
 	p := NewPacketToGetPartitionSize(dp.partitionID)
 	p.ExtentID = maxExtentID
 	target := dp.getReplicaAddr(0)
-	conn, err = gConnPool.GetConnect(target) //get remote connect
+	conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(target)) //get remote connect
 	if err != nil {
 		err = errors.Trace(err, " partition(%v) get host(%v) connect", dp.partitionID, target)
 		return
@@ -620,13 +664,13 @@ func (dp *DataPartition) getLeaderPartitionSize(maxExtentID uint64) (size uint64
 // Get the MaxExtentID partition  from the leader.
 func (dp *DataPartition) getLeaderMaxExtentIDAndPartitionSize() (maxExtentID, PartitionSize uint64, err error) {
 	var (
-		conn *net.TCPConn
+		conn net.Conn
 	)
 
 	p := NewPacketToGetMaxExtentIDAndPartitionSIze(dp.partitionID)
 
 	target := dp.getReplicaAddr(0)
-	conn, err = gConnPool.GetConnect(target) //get remote connect
+	conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(target)) //get remote connect
 	if err != nil {
 		err = errors.Trace(err, " partition(%v) get host(%v) connect", dp.partitionID, target)
 		return
@@ -669,8 +713,8 @@ func (dp *DataPartition) broadcastMinAppliedID(minAppliedID uint64) (err error)
 			continue
 		}
 		target := dp.getReplicaAddr(i)
-		var conn *net.TCPConn
-		conn, err = gConnPool.GetConnect(target)
+		var conn net.Conn
+		conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(target))
 		if err != nil {
 			return
 		}
@@ -724,7 +768,7 @@ func (dp *DataPartition) getAllReplicaAppliedID() (allAppliedID []uint64, replyN
 
 // Get target members' applied id
 func (dp *DataPartition) getRemoteAppliedID(target string, p *repl.Packet) (appliedID uint64, err error) {
-	var conn *net.TCPConn
+	var conn net.Conn
 	start := time.Now().UnixNano()
 	defer func() {
 		if err != nil {
@@ -733,7 +777,7 @@ func (dp *DataPartition) getRemoteAppliedID(target string, p *repl.Packet) (appl
 		}
 	}()
 
-	conn, err = gConnPool.GetConnect(target)
+	conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(target))
 	if err != nil {
 		return
 	}
@@ -0,0 +1,159 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ConfigKeyScrubRate     = "scrubRate"     // int, bytes read per second, a negative value disables the scrubber
+	ConfigKeyScrubInterval = "scrubInterval" // int, seconds between two rounds
+
+	DefaultScrubRate        = 10 * util.MB
+	DefaultScrubInterval    = 24 * 60 * 60
+	MaxCorruptExtentReports = 128
+)
+
+// scrubber continuously reads the extents to detect the blocks silently corrupted on
+// the disks, and repairs them from the replicas.
+type scrubber struct {
+	sync.Mutex
+	limiter  *rate.Limiter
+	interval time.Duration
+	reports  []*proto.CorruptExtentReport // the latest corrupt blocks, reported to the master
+}
+
+func (s *DataNode) startScrubber(cfg *config.Config) {
+	scrubRate := cfg.GetInt64(ConfigKeyScrubRate)
+	if scrubRate < 0 {
+		log.LogInfof("action[startScrubber] scrubber is disabled")
+		return
+	}
+	if scrubRate == 0 {
+		scrubRate = DefaultScrubRate
+	}
+	interval := cfg.GetInt64(ConfigKeyScrubInterval)
+	if interval <= 0 {
+		interval = DefaultScrubInterval
+	}
+	s.scrubber = &scrubber{
+		limiter:  rate.NewLimiter(rate.Limit(scrubRate), util.BlockSize),
+		interval: time.Duration(interval) * time.Second,
+	}
+	log.LogInfof("action[startScrubber] rate(%v) interval(%v)", scrubRate, s.scrubber.interval)
+	go s.scrub()
+}
+
+func (s *DataNode) scrub() {
+	for {
+		partitions := make([]*DataPartition, 0)
+		s.space.RangePartitions(func(dp *DataPartition) bool {
+			partitions = append(partitions, dp)
+			return true
+		})
+		for _, dp := range partitions {
+			select {
+			case <-s.stopC:
+				return
+			default:
+			}
+			s.scrubPartition(dp)
+		}
+		select {
+		case <-s.stopC:
+			return
+		case <-time.After(s.scrubber.interval):
+		}
+	}
+}
+
+// scrubPartition verifies the extents which have not been modified for a while, as
+// the CRCs of the blocks being written are not up to date.
+func (s *DataNode) scrubPartition(dp *DataPartition) {
+	if dp.ECStatus() != proto.ECStatusNone {
+		// the extents are erasure coded
+		return
+	}
+	store := dp.ExtentStore()
+	extents, _, err := store.GetAllWatermarks(storage.NormalExtentFilter())
+	if err != nil {
+		log.LogWarnf("action[scrubPartition] partition(%v) err(%v)", dp.partitionID, err)
+		return
+	}
+	for _, ei := range extents {
+		if time.Now().Unix()-ei.ModifyTime <= storage.UpdateCrcInterval {
+			continue
+		}
+		badBlocks, err := store.VerifyExtent(ei.FileID, s.scrubber.wait)
+		if err != nil {
+			dp.checkIsDiskError(err)
+			log.LogWarnf("action[scrubPartition] partition(%v) extent(%v) err(%v)", dp.partitionID, ei.FileID, err)
+			continue
+		}
+		for _, block := range badBlocks {
+			report := &proto.CorruptExtentReport{
+				PartitionID: dp.partitionID,
+				ExtentID:    ei.FileID,
+				BlockNo:     block.BlockNo,
+				DetectTime:  time.Now().Unix(),
+			}
+			if err = dp.repairCorruptBlock(ei.FileID, block); err != nil {
+				log.LogErrorf("action[scrubPartition] partition(%v) extent(%v) block(%v) is corrupt and cannot be repaired, err(%v)",
+					dp.partitionID, ei.FileID, block.BlockNo, err)
+			} else {
+				report.Repaired = true
+				log.LogWarnf("action[scrubPartition] partition(%v) extent(%v) block(%v) is corrupt and repaired",
+					dp.partitionID, ei.FileID, block.BlockNo)
+			}
+			s.metrics.MetricCorruptBlock.AddWithLabels(1, map[string]string{exporter.Vol: dp.volumeID})
+			s.scrubber.addReport(report)
+		}
+	}
+}
+
+func (sc *scrubber) wait(n int) {
+	sc.limiter.WaitN(context.Background(), n)
+}
+
+func (sc *scrubber) addReport(report *proto.CorruptExtentReport) {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.reports = append(sc.reports, report)
+	if len(sc.reports) > MaxCorruptExtentReports {
+		sc.reports = sc.reports[len(sc.reports)-MaxCorruptExtentReports:]
+	}
+}
+
+func (sc *scrubber) getReports() (reports []*proto.CorruptExtentReport) {
+	if sc == nil {
+		return
+	}
+	sc.Lock()
+	defer sc.Unlock()
+	reports = make([]*proto.CorruptExtentReport, len(sc.reports))
+	copy(reports, sc.reports)
+	return
+}
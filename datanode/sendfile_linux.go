@@ -0,0 +1,70 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// sendFileSupported tells whether the repair data can be sent with sendFile.
+const sendFileSupported = true
+
+// sendFile sends size bytes of the file at the given offset to the connection with the
+// sendfile system call, so that the data goes from the page cache to the socket directly.
+func sendFile(conn *net.TCPConn, file *os.File, offset int64, size int) (err error) {
+	dst, err := conn.SyscallConn()
+	if err != nil {
+		return
+	}
+	src, err := file.SyscallConn()
+	if err != nil {
+		return
+	}
+	var sendErr error
+	ctrlErr := src.Control(func(srcFd uintptr) {
+		err = dst.Write(func(dstFd uintptr) bool {
+			for size > 0 {
+				n, serr := syscall.Sendfile(int(dstFd), int(srcFd), &offset, size)
+				if n > 0 {
+					size -= n
+				}
+				switch {
+				case serr == syscall.EAGAIN:
+					// wait until the socket is writable again
+					return false
+				case serr == syscall.EINTR:
+					continue
+				case serr != nil:
+					sendErr = serr
+					return true
+				case n == 0:
+					sendErr = io.ErrUnexpectedEOF
+					return true
+				}
+			}
+			return true
+		})
+	})
+	if err == nil {
+		err = sendErr
+	}
+	if err == nil {
+		err = ctrlErr
+	}
+	return
+}
@@ -0,0 +1,31 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build !linux
+
+package datanode
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// sendFileSupported tells whether the repair data can be sent with sendFile.
+const sendFileSupported = false
+
+// sendFile is only available on linux.
+func sendFile(conn *net.TCPConn, file *os.File, offset int64, size int) error {
+	return syscall.ENOSYS
+}
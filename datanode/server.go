@@ -35,10 +35,12 @@ import (
 	"github.com/cubefs/cubefs/raftstore"
 	"github.com/cubefs/cubefs/repl"
 	masterSDK "github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/storage"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tlsutil"
 
 	"smux"
 )
@@ -48,9 +50,11 @@ var (
 	ErrNoSpaceToCreatePartition    = errors.New("No disk space to create a data partition")
 	ErrNewSpaceManagerFailed       = errors.New("Creater new space manager failed")
 	ErrGetMasterDatanodeInfoFailed = errors.New("Failed to get datanode info from master")
+	ErrDiskNotEmpty                = errors.New("Disk still has data partitions")
 
 	LocalIP, serverPort string
 	gConnPool           = util.NewConnectPool()
+	gReplicaAddrs       = util.NewReplicaAddrs() // replication addresses of the other data nodes
 	MasterClient        = masterSDK.NewMasterClient(nil, false)
 )
 
@@ -58,8 +62,19 @@ const (
 	DefaultZoneName         = proto.DefaultZoneName
 	DefaultRaftDir          = "raft"
 	DefaultRaftLogsToRetain = 10 // Count of raft logs per data partition
-	DefaultDiskMaxErr       = 1
-	DefaultDiskRetainMin    = 5 * util.GB // GB
+	DefaultDiskMaxErr       = 3
+	DiskErrWindow           = 10 * time.Minute // the IO errors of a disk are counted within the window
+	DefaultDiskRetainMin    = 5 * util.GB      // GB
+	DefaultTrimThreshold    = 1 * util.GB
+	DefaultSlowIOThreshold  = 500 // milliseconds
+	TrimMinLength           = 1 * util.MB
+
+	DefaultPartitionLoadWorkers = 32
+	PartitionLoadReportInterval = 10 * time.Second
+
+	ClientMaxSlowWrites       = 3                // consecutive slow response writes after which a client is shed
+	ClientShedTime            = 30 * time.Second // new connections of a shed client are refused for the time
+	ClientPeerRefreshInterval = time.Minute      // interval to refresh the hosts exempted from the client limits
 )
 
 const (
@@ -67,16 +82,26 @@ const (
 )
 
 const (
-	ConfigKeyLocalIP       = "localIP"         // string
-	ConfigKeyPort          = "port"            // int
-	ConfigKeyMasterAddr    = "masterAddr"      // array
-	ConfigKeyZone          = "zoneName"        // string
-	ConfigKeyDisks         = "disks"           // array
-	ConfigKeyRaftDir       = "raftDir"         // string
-	ConfigKeyRaftHeartbeat = "raftHeartbeat"   // string
-	ConfigKeyRaftReplica   = "raftReplica"     // string
-	CfgTickInterval        = "tickInterval"    // int
-	CfgRaftRecvBufSize     = "raftRecvBufSize" // int
+	ConfigKeyLocalIP       = "localIP"               // string
+	ConfigKeyReplicaIP     = "replicaIP"             // string
+	ConfigKeyPort          = "port"                  // int
+	ConfigKeyMasterAddr    = "masterAddr"            // array
+	ConfigKeyZone          = "zoneName"              // string
+	ConfigKeyRack          = "rack"                  // string
+	ConfigKeyDisks         = "disks"                 // array
+	ConfigKeyRaftDir       = "raftDir"               // string
+	ConfigKeyRaftHeartbeat = "raftHeartbeat"         // string
+	ConfigKeyRaftReplica   = "raftReplica"           // string
+	CfgTickInterval        = "tickInterval"          // int
+	CfgRaftRecvBufSize     = "raftRecvBufSize"       // int
+	CfgRaftPreVote         = "raftPreVote"           // bool
+	CfgRaftSnapRateLimit   = "raftSnapshotRateLimit" // int, unit is byte per second
+	CfgRaftWalRepair       = "raftWalRepair"         // bool
+	CfgRaftWalDirs         = "raftWalDirs"           // array, DISK_PATH:WAL_DIR
+	CfgRaftPropBatch       = "raftProposalBatch"     // int
+	CfgRaftTLSCertFile     = "raftTLSCertFile"       // string
+	CfgRaftTLSKeyFile      = "raftTLSKeyFile"        // string
+	CfgRaftTLSCAFile       = "raftTLSCAFile"         // string
 
 	/*
 	 * Metrics Degrade Level
@@ -89,6 +114,20 @@ const (
 	CfgMetricsDegrade = "metricsDegrade" // int
 
 	CfgDiskRdonlySpace = "diskRdonlySpace" // int
+
+	ConfigKeyIOEngine        = "ioEngine"        // string, "sync" or "io_uring"
+	ConfigKeyIOUringEntries  = "ioUringEntries"  // int
+	ConfigKeyDirectIO        = "directIO"        // bool, bypass the page cache for the extent data
+	ConfigKeyTrimThreshold   = "trimThreshold"   // int, bytes released on an SSD before it is trimmed, negative to disable
+	ConfigKeyZeroCopyRepair  = "zeroCopyRepair"  // bool, send the repair data with sendfile, true by default
+	ConfigKeySlowIOThreshold = "slowIOThreshold" // int, milliseconds after which a data IO is logged as slow, negative to disable
+
+	ConfigKeyPartitionLoadWorkers = "partitionLoadWorkers" // int, partitions loaded at the same time on startup
+	ConfigKeyDiskMaxErr           = "diskMaxErr"           // int, IO errors of a disk within DiskErrWindow before it is isolated
+
+	ConfigKeyMaxConnsPerClient    = "maxConnsPerClient"    // int, connections per client host, 0 if not limited
+	ConfigKeyMaxInflightPerClient = "maxInflightPerClient" // int, requests in flight per client host, 0 if not limited
+	ConfigKeySlowClientThreshold  = "slowClientThreshold"  // int, milliseconds a response write may block before the client counts as slow, 0 to disable
 	// smux Config
 	ConfigKeyEnableSmuxClient  = "enableSmuxConnPool" //bool
 	ConfigKeySmuxPortShift     = "smuxPortShift"      //int
@@ -97,14 +136,77 @@ const (
 	ConfigKeySmuxMaxBuffer     = "smuxMaxBuffer"      //int
 )
 
+// ConfigSchema declares the configuration keys of the data node.
+var ConfigSchema = config.Schema{
+	ConfigKeyLocalIP:              config.TypeString,
+	ConfigKeyReplicaIP:            config.TypeString,
+	proto.ListenPort:              config.TypeString,
+	ConfigKeyMasterAddr:           config.TypeStringSlice,
+	ConfigKeyZone:                 config.TypeString,
+	ConfigKeyRack:                 config.TypeString,
+	ConfigKeyDisks:                config.TypeStringSlice,
+	ConfigKeyRaftDir:              config.TypeString,
+	ConfigKeyRaftHeartbeat:        config.TypeString,
+	ConfigKeyRaftReplica:          config.TypeString,
+	CfgTickInterval:               config.TypeFloat,
+	CfgRaftRecvBufSize:            config.TypeInt,
+	CfgRaftPreVote:                config.TypeBool,
+	CfgRaftSnapRateLimit:          config.TypeInt,
+	CfgRaftWalRepair:              config.TypeBool,
+	CfgRaftWalDirs:                config.TypeStringSlice,
+	CfgRaftPropBatch:              config.TypeInt,
+	CfgRaftTLSCertFile:            config.TypeString,
+	CfgRaftTLSKeyFile:             config.TypeString,
+	CfgRaftTLSCAFile:              config.TypeString,
+	CfgMetricsDegrade:             config.TypeInt,
+	CfgDiskRdonlySpace:            config.TypeInt,
+	ConfigKeyIOEngine:             config.TypeString,
+	ConfigKeyIOUringEntries:       config.TypeInt,
+	ConfigKeyDirectIO:             config.TypeBool,
+	ConfigKeyTrimThreshold:        config.TypeInt,
+	ConfigKeyZeroCopyRepair:       config.TypeBool,
+	ConfigKeySlowIOThreshold:      config.TypeInt,
+	ConfigKeyPartitionLoadWorkers: config.TypeInt,
+	ConfigKeyDiskMaxErr:           config.TypeInt,
+	ConfigKeyMaxConnsPerClient:    config.TypeInt,
+	ConfigKeyMaxInflightPerClient: config.TypeInt,
+	ConfigKeySlowClientThreshold:  config.TypeInt,
+	ConfigKeyEnableSmuxClient:     config.TypeBool,
+	ConfigKeySmuxPortShift:        config.TypeInt,
+	ConfigKeySmuxMaxConn:          config.TypeInt,
+	ConfigKeySmuxStreamPerConn:    config.TypeInt,
+	ConfigKeySmuxMaxBuffer:        config.TypeInt,
+	ConfigKeyHotExtentCount:       config.TypeInt,
+	ConfigKeyCacheDisks:           config.TypeStringSlice,
+	ConfigKeyCacheMode:            config.TypeString,
+	ConfigKeyCachePromoteHits:     config.TypeInt,
+	ConfigKeyCompressRate:         config.TypeInt,
+	ConfigKeyCompressInterval:     config.TypeInt,
+	ConfigKeyCompressColdTime:     config.TypeInt,
+	ConfigKeyPackRate:             config.TypeInt,
+	ConfigKeyPackInterval:         config.TypeInt,
+	ConfigKeyPackColdTime:         config.TypeInt,
+	ConfigKeyPackMaxExtentSize:    config.TypeInt,
+	ConfigKeyScrubRate:            config.TypeInt,
+	ConfigKeyScrubInterval:        config.TypeInt,
+	ConfigKeySmartInterval:        config.TypeInt,
+	ConfigKeySmartctlPath:         config.TypeString,
+	ConfigKeyPmemPath:             config.TypeString,
+	ConfigKeyPmemCapacity:         config.TypeInt,
+	ConfigKeyPmemMaxWriteSize:     config.TypeInt,
+}
+
 // DataNode defines the structure of a data node.
 type DataNode struct {
 	space           *SpaceManager
 	port            string
 	zoneName        string
+	rack            string
+	locationHint    []byte // zone and rack attached to the follower read replies
 	clusterID       string
 	localIP         string
 	localServerAddr string
+	replicaIP       string // ip of the dedicated network for the replication and repair traffic
 	nodeID          uint64
 	raftDir         string
 	raftHeartbeat   string
@@ -112,6 +214,14 @@ type DataNode struct {
 	raftStore       raftstore.RaftStore
 	tickInterval    int
 	raftRecvBufSize int
+	raftPreVote     bool
+	raftSnapLimit   int64
+	raftWalRepair   bool
+	raftWalDirs     map[string]string // disk path -> WAL directory of the partitions on the disk
+	raftPropBatch   int
+	raftTLSCertFile string
+	raftTLSKeyFile  string
+	raftTLSCAFile   string
 
 	tcpListener net.Listener
 	stopC       chan bool
@@ -126,9 +236,26 @@ type DataNode struct {
 	getRepairConnFunc func(target string) (net.Conn, error)
 	putRepairConnFunc func(conn net.Conn, forceClose bool)
 
-	metrics        *DataNodeMetrics
-	metricsDegrade int64
-	metricsCnt     uint64
+	metrics         *DataNodeMetrics
+	metricsDegrade  int64
+	scrubber        *scrubber
+	compressor      *compressor
+	writeLimiter    *writeLimiter
+	writeFences     *writeFences
+	clientLimiter   *clientLimiter
+	accessTracker   *accessTracker
+	reportTracker   *proto.ReportTracker
+	sloRecorder     *proto.SLORecorder
+	packer          *packer
+	cacheTier       *cacheTier
+	writeBuffer     *storage.WriteBuffer
+	trimThreshold   int64
+	zeroCopyRepair  bool
+	diskMaxErr      int
+	slowIOThreshold time.Duration // 0 if the slow IO are not logged
+
+	diskRdonlySpace uint64
+	metricsCnt      uint64
 
 	control common.Control
 }
@@ -176,19 +303,42 @@ func doStart(server common.Server, cfg *config.Config) (err error) {
 	}
 	//connection pool must be created before initSpaceManager
 	s.initConnPool()
+	repl.SetReplicaAddrs(gReplicaAddrs)
 
 	// init limit
 	initRepairLimit()
+	s.writeLimiter = newWriteLimiter()
+	s.writeFences = newWriteFences()
+	s.reportTracker = proto.NewReportTracker(proto.DefaultFullReportInterval)
+	s.sloRecorder = proto.NewSLORecorder()
+	if err = s.initClientLimiter(cfg); err != nil {
+		return
+	}
 
 	// start the raft server
 	if err = s.startRaftServer(cfg); err != nil {
 		return
 	}
 
+	// the io engine must be selected before the extent stores are loaded
+	if err = s.initIOEngine(cfg); err != nil {
+		return
+	}
+
+	// the journals of the caches are replayed when the partitions are loaded
+	if err = s.initCacheTier(cfg); err != nil {
+		return
+	}
+	if err = s.initWriteBuffer(cfg); err != nil {
+		return
+	}
+
 	// create space manager (disk, partition, etc.)
 	if err = s.startSpaceManager(cfg); err != nil {
 		return
 	}
+	s.cacheTier.finishReplay()
+	s.finishWriteBufferReplay()
 
 	// check local partition compare with master ,if lack,then not start
 	if err = s.checkLocalPartitionMatchWithMaster(); err != nil {
@@ -211,6 +361,16 @@ func doStart(server common.Server, cfg *config.Config) (err error) {
 
 	go s.startUpdateNodeInfo()
 
+	s.startScrubber(cfg)
+
+	s.startCompressor(cfg)
+
+	s.startPacker(cfg)
+
+	s.startSmartCollector(cfg)
+
+	s.startAccessTracker(cfg)
+
 	return
 }
 
@@ -221,6 +381,11 @@ func doShutdown(server common.Server) {
 	}
 	close(s.stopC)
 	s.space.Stop()
+	if err := storage.CloseIOEngine(); err != nil {
+		log.LogErrorf("action[doShutdown] close io engine: %v", err)
+	}
+	s.cacheTier.close()
+	s.closeWriteBuffer()
 	s.stopUpdateNodeInfo()
 	s.stopTCPService()
 	s.stopRaftServer()
@@ -234,6 +399,10 @@ func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
 		regexpPort *regexp.Regexp
 	)
 	LocalIP = cfg.GetString(ConfigKeyLocalIP)
+	s.replicaIP = cfg.GetString(ConfigKeyReplicaIP)
+	if s.replicaIP != "" && !util.IsIPV4(s.replicaIP) {
+		return fmt.Errorf("Err:replicaIP(%v) is not a valid ip", s.replicaIP)
+	}
 	port = cfg.GetString(proto.ListenPort)
 	serverPort = port
 	if regexpPort, err = regexp.Compile("^(\\d)+$"); err != nil {
@@ -253,11 +422,86 @@ func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
 	if s.zoneName == "" {
 		s.zoneName = DefaultZoneName
 	}
+	s.rack = cfg.GetString(ConfigKeyRack)
+	s.diskMaxErr = int(cfg.GetInt64(ConfigKeyDiskMaxErr))
+	if s.diskMaxErr <= 0 {
+		s.diskMaxErr = DefaultDiskMaxErr
+	}
+	s.locationHint = proto.MarshalLocationHint(s.zoneName, s.rack)
 	s.metricsDegrade = cfg.GetInt(CfgMetricsDegrade)
+	s.trimThreshold = cfg.GetInt64(ConfigKeyTrimThreshold)
+	if s.trimThreshold == 0 {
+		s.trimThreshold = DefaultTrimThreshold
+	}
+	s.zeroCopyRepair = cfg.GetBoolWithDefault(ConfigKeyZeroCopyRepair, true) && sendFileSupported
+	slowIOThreshold := cfg.GetInt64(ConfigKeySlowIOThreshold)
+	if slowIOThreshold == 0 {
+		slowIOThreshold = DefaultSlowIOThreshold
+	}
+	if slowIOThreshold > 0 {
+		s.slowIOThreshold = time.Duration(slowIOThreshold) * time.Millisecond
+	}
 
 	log.LogDebugf("action[parseConfig] load masterAddrs(%v).", MasterClient.Nodes())
 	log.LogDebugf("action[parseConfig] load port(%v).", s.port)
+	log.LogDebugf("action[parseConfig] load replicaIP(%v).", s.replicaIP)
 	log.LogDebugf("action[parseConfig] load zoneName(%v).", s.zoneName)
+	log.LogDebugf("action[parseConfig] load rack(%v).", s.rack)
+	log.LogDebugf("action[parseConfig] load zeroCopyRepair(%v).", s.zeroCopyRepair)
+	log.LogDebugf("action[parseConfig] load slowIOThreshold(%v).", s.slowIOThreshold)
+	return
+}
+
+func (s *DataNode) initClientLimiter(cfg *config.Config) (err error) {
+	maxConns := cfg.GetInt64(ConfigKeyMaxConnsPerClient)
+	maxInflight := cfg.GetInt64(ConfigKeyMaxInflightPerClient)
+	slowThreshold := cfg.GetInt64(ConfigKeySlowClientThreshold)
+	if maxConns < 0 || maxInflight < 0 || slowThreshold < 0 {
+		return fmt.Errorf("Err:invalid client limits maxConns(%v) maxInflight(%v) slowThreshold(%v)",
+			maxConns, maxInflight, slowThreshold)
+	}
+	s.clientLimiter = newClientLimiter(int(maxConns), int(maxInflight), time.Duration(slowThreshold)*time.Millisecond, s.peerHosts)
+	log.LogInfof("action[initClientLimiter] maxConnsPerClient(%v) maxInflightPerClient(%v) slowClientThreshold(%vms)",
+		maxConns, maxInflight, slowThreshold)
+	return
+}
+
+// peerHosts returns the hosts of the replicas of the partitions on the data node and of the masters.
+func (s *DataNode) peerHosts() map[string]bool {
+	hosts := make(map[string]bool)
+	addHost := func(addr string) {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			hosts[host] = true
+		}
+	}
+	for _, addr := range MasterClient.Nodes() {
+		addHost(addr)
+	}
+	if s.space == nil {
+		return hosts
+	}
+	s.space.RangePartitions(func(dp *DataPartition) bool {
+		for _, addr := range dp.getReplicaCopy() {
+			addHost(addr)
+			addHost(gReplicaAddrs.Addr(addr))
+		}
+		return true
+	})
+	return hosts
+}
+
+func (s *DataNode) initIOEngine(cfg *config.Config) (err error) {
+	engine := cfg.GetString(ConfigKeyIOEngine)
+	entries := cfg.GetInt64(ConfigKeyIOUringEntries)
+	if entries < 0 {
+		return fmt.Errorf("Err:invalid %v(%v)", ConfigKeyIOUringEntries, entries)
+	}
+	if err = storage.SetIOEngine(engine, uint32(entries)); err != nil {
+		return
+	}
+	directIO := cfg.GetBool(ConfigKeyDirectIO)
+	storage.SetDirectIO(directIO)
+	log.LogInfof("action[initIOEngine] io engine(%v) entries(%v) directIO(%v)", engine, entries, directIO)
 	return
 }
 
@@ -277,8 +521,26 @@ func (s *DataNode) startSpaceManager(cfg *config.Config) (err error) {
 		diskRdonlySpace = DefaultDiskRetainMin
 	}
 
+	s.diskRdonlySpace = diskRdonlySpace
+
 	log.LogInfof("startSpaceManager preReserveSpace %d", diskRdonlySpace)
 
+	loadWorkers := cfg.GetInt64(ConfigKeyPartitionLoadWorkers)
+	if loadWorkers <= 0 {
+		loadWorkers = DefaultPartitionLoadWorkers
+	}
+	s.space.loadLimitC = make(chan struct{}, loadWorkers)
+	loadDoneC := make(chan struct{})
+	go s.reportPartitionLoadProgress(loadDoneC)
+	defer func() {
+		close(loadDoneC)
+		loaded, total := s.space.loadProgress()
+		log.LogInfof("action[startSpaceManager] loaded partitions(%v/%v)", loaded, total)
+		if err := MasterClient.NodeAPI().ReportDataNodeLoadProgress(s.localServerAddr, loaded, total); err != nil {
+			log.LogWarnf("action[startSpaceManager] report load progress err(%v)", err)
+		}
+	}()
+
 	var wg sync.WaitGroup
 	for _, d := range cfg.GetSlice(ConfigKeyDisks) {
 		log.LogDebugf("action[startSpaceManager] load disk raw config(%v).", d)
@@ -308,13 +570,32 @@ func (s *DataNode) startSpaceManager(cfg *config.Config) (err error) {
 		wg.Add(1)
 		go func(wg *sync.WaitGroup, path string, reservedSpace uint64) {
 			defer wg.Done()
-			s.space.LoadDisk(path, reservedSpace, diskRdonlySpace, DefaultDiskMaxErr)
+			s.space.LoadDisk(path, reservedSpace, diskRdonlySpace, s.diskMaxErr)
 		}(&wg, path, reservedSpace)
 	}
 	wg.Wait()
 	return nil
 }
 
+// reportPartitionLoadProgress reports the partitions loaded to the master until the
+// loading is done, so that the master does not take the replicas as missing meanwhile.
+func (s *DataNode) reportPartitionLoadProgress(doneC <-chan struct{}) {
+	ticker := time.NewTicker(PartitionLoadReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-doneC:
+			return
+		case <-ticker.C:
+		}
+		loaded, total := s.space.loadProgress()
+		log.LogInfof("action[reportPartitionLoadProgress] loaded partitions(%v/%v)", loaded, total)
+		if err := MasterClient.NodeAPI().ReportDataNodeLoadProgress(s.localServerAddr, loaded, total); err != nil {
+			log.LogWarnf("action[reportPartitionLoadProgress] err(%v)", err)
+		}
+	}
+}
+
 // registers the data node on the master to report the information such as IsIPV4 address.
 // The startup of a data node will be blocked until the registration succeeds.
 func (s *DataNode) register(cfg *config.Config) {
@@ -350,7 +631,7 @@ func (s *DataNode) register(cfg *config.Config) {
 
 			// register this data node on the master
 			var nodeID uint64
-			if nodeID, err = MasterClient.NodeAPI().AddDataNode(fmt.Sprintf("%s:%v", LocalIP, s.port), s.zoneName); err != nil {
+			if nodeID, err = MasterClient.NodeAPI().AddDataNode(fmt.Sprintf("%s:%v", LocalIP, s.port), s.zoneName, s.replicaAddr()); err != nil {
 				log.LogErrorf("action[registerToMaster] cannot register this node to master[%v] err(%v).",
 					masterAddr, err)
 				timer.Reset(2 * time.Second)
@@ -367,6 +648,15 @@ func (s *DataNode) register(cfg *config.Config) {
 	}
 }
 
+// replicaAddr returns the address the other data nodes send the replication and repair traffic to,
+// empty if it goes to the service address.
+func (s *DataNode) replicaAddr() string {
+	if s.replicaIP == "" || s.replicaIP == LocalIP {
+		return ""
+	}
+	return fmt.Sprintf("%s:%v", s.replicaIP, s.port)
+}
+
 type DataNodeInfo struct {
 	Addr                      string
 	PersistenceDataPartitions []uint64
@@ -424,12 +714,18 @@ func (s *DataNode) registerHandler() {
 	http.HandleFunc("/getSmuxPoolStat", s.getSmuxPoolStat())
 	http.HandleFunc("/setMetricsDegrade", s.setMetricsDegrade)
 	http.HandleFunc("/getMetricsDegrade", s.getMetricsDegrade)
+	http.HandleFunc("/attachDisk", s.attachDisk)
+	http.HandleFunc("/detachDisk", s.detachDisk)
+	http.HandleFunc("/cacheStats", s.getCacheStats)
+	http.HandleFunc("/writeBufferStats", s.getWriteBufferStats)
+	http.HandleFunc("/setVerifyWrite", s.setVerifyWrite)
+	http.HandleFunc("/setSnapshotRateLimit", s.setSnapshotRateLimit)
 }
 
 func (s *DataNode) startTCPService() (err error) {
 	log.LogInfo("Start: startTCPService")
 	addr := fmt.Sprintf(":%v", s.port)
-	l, err := net.Listen(NetworkProtocol, addr)
+	l, err := tlsutil.Listen(addr)
 	log.LogDebugf("action[startTCPService] listen %v address(%v).", NetworkProtocol, addr)
 	if err != nil {
 		log.LogError("failed to listen, err:", err)
@@ -462,9 +758,16 @@ func (s *DataNode) stopTCPService() (err error) {
 func (s *DataNode) serveConn(conn net.Conn) {
 	space := s.space
 	space.Stats().AddConnection()
-	c, _ := conn.(*net.TCPConn)
-	c.SetKeepAlive(true)
-	c.SetNoDelay(true)
+	if c := tlsutil.TCPConn(conn); c != nil {
+		c.SetKeepAlive(true)
+		c.SetNoDelay(true)
+	}
+	raw := conn
+	conn, ok := s.clientLimiter.admit(conn)
+	if !ok {
+		raw.Close()
+		return
+	}
 	packetProcessor := repl.NewReplProtocol(conn, s.Prepare, s.OperatePacket, s.Post)
 	packetProcessor.ServerConn()
 }
@@ -476,7 +779,7 @@ func (s *DataNode) startSmuxService(cfg *config.Config) (err error) {
 	log.LogInfof("SmuxListenAddr: (%v)", addr)
 
 	// server
-	l, err := net.Listen(NetworkProtocol, addr)
+	l, err := tlsutil.Listen(addr)
 	log.LogDebugf("action[startSmuxService] listen %v address(%v).", NetworkProtocol, addr)
 	if err != nil {
 		log.LogError("failed to listen smux addr, err:", err)
@@ -508,14 +811,21 @@ func (s *DataNode) stopSmuxService() (err error) {
 func (s *DataNode) serveSmuxConn(conn net.Conn) {
 	space := s.space
 	space.Stats().AddConnection()
-	c, _ := conn.(*net.TCPConn)
-	c.SetKeepAlive(true)
-	c.SetNoDelay(true)
+	if c := tlsutil.TCPConn(conn); c != nil {
+		c.SetKeepAlive(true)
+		c.SetNoDelay(true)
+	}
+	raw := conn
+	conn, ok := s.clientLimiter.admit(conn)
+	if !ok {
+		raw.Close()
+		return
+	}
 	var sess *smux.Session
 	var err error
 	sess, err = smux.Server(conn, s.smuxServerConfig)
 	if err != nil {
-		log.LogErrorf("action[serveSmuxConn] failed to serve smux connection, addr(%v), err(%v)", c.RemoteAddr(), err)
+		log.LogErrorf("action[serveSmuxConn] failed to serve smux connection, addr(%v), err(%v)", raw.RemoteAddr(), err)
 		return
 	}
 	defer sess.Close()
@@ -529,12 +839,12 @@ func (s *DataNode) serveSmuxConn(conn net.Conn) {
 			}
 			break
 		}
-		go s.serveSmuxStream(stream)
+		go s.serveSmuxStream(s.clientLimiter.wrap(stream, conn))
 	}
 	return
 }
 
-func (s *DataNode) serveSmuxStream(stream *smux.Stream) {
+func (s *DataNode) serveSmuxStream(stream net.Conn) {
 	packetProcessor := repl.NewReplProtocol(stream, s.Prepare, s.OperatePacket, s.Post)
 	packetProcessor.ServerConn()
 }
@@ -616,7 +926,7 @@ func (s *DataNode) initConnPool() {
 		log.LogInfof("Start: init smux conn pool")
 		s.smuxConnPool = util.NewSmuxConnectPool(s.smuxConnPoolConfig)
 		s.getRepairConnFunc = func(target string) (net.Conn, error) {
-			addr := util.ShiftAddrPort(target, s.smuxPortShift)
+			addr := util.ShiftAddrPort(gReplicaAddrs.Addr(target), s.smuxPortShift)
 			log.LogDebugf("[dataNode.getRepairConnFunc] get smux conn, addr(%v)", addr)
 			return s.smuxConnPool.GetConnect(addr)
 		}
@@ -627,11 +937,11 @@ func (s *DataNode) initConnPool() {
 	} else {
 		s.getRepairConnFunc = func(target string) (conn net.Conn, err error) {
 			log.LogDebugf("[dataNode.getRepairConnFunc] get tcp conn, addr(%v)", target)
-			return gConnPool.GetConnect(target)
+			return gConnPool.GetConnect(gReplicaAddrs.Addr(target))
 		}
 		s.putRepairConnFunc = func(conn net.Conn, forceClose bool) {
 			log.LogDebugf("[dataNode.putRepairConnFunc] put tcp conn, addr(%v), forceClose(%v)", conn.RemoteAddr().String(), forceClose)
-			gConnPool.PutConnect(conn.(*net.TCPConn), forceClose)
+			gConnPool.PutConnect(conn, forceClose)
 			return
 		}
 	}
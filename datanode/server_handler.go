@@ -23,6 +23,7 @@ import (
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util/log"
 	"github.com/tiglabs/raft"
 )
 
@@ -44,6 +45,7 @@ func (s *DataNode) getDiskAPI(w http.ResponseWriter, r *http.Request) {
 			RestSize    uint64 `json:"restSize"`
 			DiskRdoSize uint64 `json:"diskRdoSize"`
 			Partitions  int    `json:"partitions"`
+			VerifyWrite bool   `json:"verifyWrite"`
 		}{
 			Path:        diskItem.Path,
 			Total:       diskItem.Total,
@@ -55,6 +57,7 @@ func (s *DataNode) getDiskAPI(w http.ResponseWriter, r *http.Request) {
 			RestSize:    diskItem.ReservedSpace,
 			DiskRdoSize: diskItem.DiskRdonlySpace,
 			Partitions:  diskItem.PartitionCount(),
+			VerifyWrite: diskItem.VerifiesWrite(),
 		}
 		disks = append(disks, disk)
 	}
@@ -186,6 +189,7 @@ func (s *DataNode) getPartitionAPI(w http.ResponseWriter, r *http.Request) {
 		Replicas             []string              `json:"replicas"`
 		TinyDeleteRecordSize int64                 `json:"tinyDeleteRecordSize"`
 		RaftStatus           *raft.Status          `json:"raftStatus"`
+		VerifyWrite          bool                  `json:"verifyWrite"`
 	}{
 		VolName:              partition.volumeID,
 		ID:                   partition.partitionID,
@@ -198,6 +202,7 @@ func (s *DataNode) getPartitionAPI(w http.ResponseWriter, r *http.Request) {
 		Replicas:             partition.Replicas(),
 		TinyDeleteRecordSize: tinyDeleteRecordSize,
 		RaftStatus:           partition.raftPartition.Status(),
+		VerifyWrite:          partition.VerifiesWrite(),
 	}
 	s.buildSuccessResp(w, result)
 }
@@ -361,6 +366,150 @@ func (s *DataNode) getMetricsDegrade(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(fmt.Sprintf("%v\n", atomic.LoadInt64(&s.metricsDegrade))))
 }
 
+func (s *DataNode) attachDisk(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramPath          = "path"
+		paramReservedSpace = "reservedSpace"
+	)
+	if err := r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	path := r.FormValue(paramPath)
+	if path == "" {
+		err := fmt.Errorf("param %v is empty", paramPath)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var reservedSpace uint64
+	if value := r.FormValue(paramReservedSpace); value != "" {
+		var err error
+		if reservedSpace, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = fmt.Errorf("parse param %v fail: %v", paramReservedSpace, err)
+			s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if reservedSpace < DefaultDiskRetainMin {
+		reservedSpace = DefaultDiskRetainMin
+	}
+	if err := s.space.AttachDisk(path, reservedSpace, s.diskRdonlySpace); err != nil {
+		s.buildFailureResp(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.buildSuccessResp(w, fmt.Sprintf("disk(%v) attached", path))
+}
+
+func (s *DataNode) detachDisk(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramPath = "path"
+	)
+	if err := r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	path := r.FormValue(paramPath)
+	if path == "" {
+		err := fmt.Errorf("param %v is empty", paramPath)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.space.DetachDisk(path); err != nil {
+		s.buildFailureResp(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.buildSuccessResp(w, fmt.Sprintf("disk(%v) detached", path))
+}
+
+func (s *DataNode) setVerifyWrite(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramDisk      = "disk"
+		paramPartition = "partition"
+		paramVerify    = "verify"
+	)
+	if err := r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	verify, err := strconv.ParseBool(r.FormValue(paramVerify))
+	if err != nil {
+		err = fmt.Errorf("parse param %v fail: %v", paramVerify, err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if path := r.FormValue(paramDisk); path != "" {
+		disk, err := s.space.GetDisk(path)
+		if err != nil {
+			s.buildFailureResp(w, http.StatusNotFound, err.Error())
+			return
+		}
+		disk.SetVerifyWrite(verify)
+		log.LogWarnf("action[setVerifyWrite] disk(%v) verify(%v)", path, verify)
+		s.buildSuccessResp(w, fmt.Sprintf("disk(%v) verify write(%v)", path, verify))
+		return
+	}
+	partitionID, err := strconv.ParseUint(r.FormValue(paramPartition), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("param %v or %v is required", paramDisk, paramPartition)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	partition := s.space.Partition(partitionID)
+	if partition == nil {
+		s.buildFailureResp(w, http.StatusNotFound, fmt.Sprintf("partition(%v) not exist", partitionID))
+		return
+	}
+	partition.SetVerifyWrite(verify)
+	log.LogWarnf("action[setVerifyWrite] partition(%v) verify(%v)", partitionID, verify)
+	s.buildSuccessResp(w, fmt.Sprintf("partition(%v) verify write(%v)", partitionID, verify))
+}
+
+// setSnapshotRateLimit limits the rate of the raft snapshot transfer of the node, or of the
+// partition if it is given, in bytes per second. Non-positive rate removes the limit.
+func (s *DataNode) setSnapshotRateLimit(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramPartition = "partition"
+		paramRate      = "rate"
+	)
+	if err := r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	bytesPerSec, err := strconv.ParseInt(r.FormValue(paramRate), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("parse param %v fail: %v", paramRate, err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.FormValue(paramPartition) == "" {
+		s.raftStore.SetSnapshotRateLimit(bytesPerSec)
+		log.LogWarnf("action[setSnapshotRateLimit] rate(%v)", bytesPerSec)
+		s.buildSuccessResp(w, fmt.Sprintf("snapshot rate limit(%v)", bytesPerSec))
+		return
+	}
+	partitionID, err := strconv.ParseUint(r.FormValue(paramPartition), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("parse param %v fail: %v", paramPartition, err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	partition := s.space.Partition(partitionID)
+	if partition == nil {
+		s.buildFailureResp(w, http.StatusNotFound, fmt.Sprintf("partition(%v) not exist", partitionID))
+		return
+	}
+	if err = partition.SetSnapshotRateLimit(bytesPerSec); err != nil {
+		s.buildFailureResp(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.LogWarnf("action[setSnapshotRateLimit] partition(%v) rate(%v)", partitionID, bytesPerSec)
+	s.buildSuccessResp(w, fmt.Sprintf("partition(%v) snapshot rate limit(%v)", partitionID, bytesPerSec))
+}
+
 func (s *DataNode) buildSuccessResp(w http.ResponseWriter, data interface{}) {
 	s.buildJSONResp(w, http.StatusOK, data, "")
 }
@@ -0,0 +1,192 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	ConfigKeySmartInterval = "smartInterval" // int, seconds between two collections, a negative value disables it
+	ConfigKeySmartctlPath  = "smartctlPath"  // string
+
+	DefaultSmartInterval = 60 * 60
+	DefaultSmartctlPath  = "smartctl"
+	smartctlTimeout      = 30 * time.Second
+	procMountsPath       = "/proc/mounts"
+)
+
+// ATA SMART attribute IDs
+const (
+	smartAttrReallocatedSectors   = 5
+	smartAttrPendingSectors       = 197
+	smartAttrUncorrectableSectors = 198
+)
+
+// smartctlOutput is the part of the json output of smartctl in use.
+type smartctlOutput struct {
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			ID  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NvmeSmartHealthInformationLog *struct {
+		MediaErrors    uint64 `json:"media_errors"`
+		PercentageUsed uint64 `json:"percentage_used"`
+	} `json:"nvme_smart_health_information_log"`
+	Temperature struct {
+		Current int64 `json:"current"`
+	} `json:"temperature"`
+}
+
+func (s *DataNode) startSmartCollector(cfg *config.Config) {
+	interval := cfg.GetInt64(ConfigKeySmartInterval)
+	if interval < 0 {
+		log.LogInfof("action[startSmartCollector] smart collector is disabled")
+		return
+	}
+	if interval == 0 {
+		interval = DefaultSmartInterval
+	}
+	smartctl := cfg.GetString(ConfigKeySmartctlPath)
+	if smartctl == "" {
+		smartctl = DefaultSmartctlPath
+	}
+	if _, err := exec.LookPath(smartctl); err != nil {
+		log.LogWarnf("action[startSmartCollector] smartctl(%v) not found, smart collector is disabled, err(%v)", smartctl, err)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		s.collectDiskSmarts(smartctl)
+		for {
+			select {
+			case <-s.stopC:
+				return
+			case <-ticker.C:
+				s.collectDiskSmarts(smartctl)
+			}
+		}
+	}()
+}
+
+func (s *DataNode) collectDiskSmarts(smartctl string) {
+	for _, d := range s.space.GetDisks() {
+		info, err := collectDiskSmart(smartctl, d.Path)
+		if err != nil {
+			log.LogWarnf("action[collectDiskSmarts] disk(%v) err(%v)", d.Path, err)
+			continue
+		}
+		d.setSmart(info)
+		log.LogDebugf("action[collectDiskSmarts] disk(%v) smart(%+v)", d.Path, info)
+	}
+}
+
+func collectDiskSmart(smartctl, path string) (info *proto.DiskSmartInfo, err error) {
+	device, err := mountDevice(path)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), smartctlTimeout)
+	defer cancel()
+	// the exit status of smartctl is a bit mask which is not zero for an unhealthy disk,
+	// so the output is parsed whenever there is one.
+	data, execErr := exec.CommandContext(ctx, smartctl, "-H", "-A", "-j", device).Output()
+	if len(data) == 0 {
+		return nil, fmt.Errorf("smartctl device(%v) err(%v)", device, execErr)
+	}
+	out := new(smartctlOutput)
+	if err = json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("parse smartctl output of device(%v) err(%v)", device, err)
+	}
+	if out.SmartStatus == nil {
+		return nil, fmt.Errorf("device(%v) has no smart status, err(%v)", device, execErr)
+	}
+	info = &proto.DiskSmartInfo{
+		Path:        path,
+		Device:      device,
+		Passed:      out.SmartStatus.Passed,
+		Temperature: out.Temperature.Current,
+		UpdateTime:  time.Now().Unix(),
+	}
+	for _, attr := range out.AtaSmartAttributes.Table {
+		switch attr.ID {
+		case smartAttrReallocatedSectors:
+			info.ReallocatedSectors = attr.Raw.Value
+		case smartAttrPendingSectors:
+			info.PendingSectors = attr.Raw.Value
+		case smartAttrUncorrectableSectors:
+			info.UncorrectableSectors = attr.Raw.Value
+		}
+	}
+	if nvme := out.NvmeSmartHealthInformationLog; nvme != nil {
+		info.MediaErrors = nvme.MediaErrors
+		info.PercentageUsed = nvme.PercentageUsed
+	}
+	return
+}
+
+// mountDevice returns the device mounted on the longest mount point containing the path.
+func mountDevice(path string) (device string, err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	fp, err := os.Open(procMountsPath)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	var mountPoint string
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		mp := fields[1]
+		if path != mp && !strings.HasPrefix(path, strings.TrimSuffix(mp, "/")+"/") {
+			continue
+		}
+		if len(mp) > len(mountPoint) {
+			mountPoint, device = mp, fields[0]
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if device == "" {
+		err = fmt.Errorf("no device mounted for path(%v)", path)
+	}
+	return
+}
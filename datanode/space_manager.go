@@ -15,8 +15,10 @@
 package datanode
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"math"
@@ -42,6 +44,13 @@ type SpaceManager struct {
 	diskList             []string
 	dataNode             *DataNode
 	createPartitionMutex sync.RWMutex
+	diskClientIOLimit    uint64
+	diskRepairIOLimit    uint64
+	encryptKeyMutex      sync.RWMutex
+	volEncryptKeys       map[string][]byte // data keys of the encrypted volumes, kept in memory only
+	loadLimitC           chan struct{}     // bounds the partitions loaded at the same time
+	partitionsToLoad     int64
+	partitionsLoaded     int64
 }
 
 // NewSpaceManager creates a new space manager.
@@ -51,6 +60,7 @@ func NewSpaceManager(dataNode *DataNode) *SpaceManager {
 	space.disks = make(map[string]*Disk)
 	space.diskList = make([]string, 0)
 	space.partitions = make(map[uint64]*DataPartition)
+	space.volEncryptKeys = make(map[string][]byte)
 	space.stats = NewStats(dataNode.zoneName)
 	space.stopC = make(chan bool, 0)
 	space.dataNode = dataNode
@@ -180,6 +190,11 @@ func (manager *SpaceManager) LoadDisk(path string, reservedSpace, diskRdonlySpac
 	return
 }
 
+// loadProgress returns the partitions loaded and the partitions found on the disks.
+func (manager *SpaceManager) loadProgress() (loaded, total int) {
+	return int(atomic.LoadInt64(&manager.partitionsLoaded)), int(atomic.LoadInt64(&manager.partitionsToLoad))
+}
+
 func (manager *SpaceManager) GetDisk(path string) (d *Disk, err error) {
 	manager.diskMutex.RLock()
 	defer manager.diskMutex.RUnlock()
@@ -199,6 +214,106 @@ func (manager *SpaceManager) putDisk(d *Disk) {
 	manager.diskMutex.Unlock()
 }
 
+// SetDiskIOLimits sets the rates in bytes per second of the client io and the repair
+// io of every disk, 0 for no limit.
+// SetVolEncryptKeys sets the data keys of the encrypted volumes from the master, and hands
+// them to the partitions of the volumes.
+func (manager *SpaceManager) SetVolEncryptKeys(keys map[string][]byte) {
+	manager.encryptKeyMutex.Lock()
+	changed := make(map[string][]byte)
+	for volName, key := range keys {
+		if !bytes.Equal(manager.volEncryptKeys[volName], key) {
+			manager.volEncryptKeys[volName] = key
+			changed[volName] = key
+		}
+	}
+	manager.encryptKeyMutex.Unlock()
+	if len(changed) == 0 {
+		return
+	}
+	manager.RangePartitions(func(dp *DataPartition) bool {
+		if key, ok := changed[dp.volumeID]; ok && dp.config.Encrypted {
+			if err := dp.extentStore.SetEncryptKey(key); err != nil {
+				log.LogErrorf("action[SetVolEncryptKeys] partition(%v) err(%v)", dp.partitionID, err)
+			}
+		}
+		return true
+	})
+}
+
+func (manager *SpaceManager) getVolEncryptKey(volName string) []byte {
+	manager.encryptKeyMutex.RLock()
+	defer manager.encryptKeyMutex.RUnlock()
+	return manager.volEncryptKeys[volName]
+}
+
+func (manager *SpaceManager) SetDiskIOLimits(clientLimit, repairLimit uint64) {
+	manager.SetDiskClientIOLimit(clientLimit)
+	manager.SetDiskRepairIOLimit(repairLimit)
+}
+
+func (manager *SpaceManager) SetDiskClientIOLimit(clientLimit uint64) {
+	atomic.StoreUint64(&manager.diskClientIOLimit, clientLimit)
+	for _, d := range manager.GetDisks() {
+		setLimiter(d.clientIOLimiter, clientLimit)
+	}
+}
+
+func (manager *SpaceManager) SetDiskRepairIOLimit(repairLimit uint64) {
+	atomic.StoreUint64(&manager.diskRepairIOLimit, repairLimit)
+	for _, d := range manager.GetDisks() {
+		setLimiter(d.repairIOLimiter, repairLimit)
+	}
+}
+
+// AttachDisk loads a disk while the data node is running. The new capacity is
+// reported to the master by the next heartbeat.
+func (manager *SpaceManager) AttachDisk(path string, reservedSpace, diskRdonlySpace uint64) (err error) {
+	var fileInfo os.FileInfo
+	if fileInfo, err = os.Stat(path); err != nil {
+		return
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("disk path(%v) is not dir", path)
+	}
+	if _, err = manager.GetDisk(path); err == nil {
+		return fmt.Errorf("disk(%v) already exists", path)
+	}
+	if err = manager.LoadDisk(path, reservedSpace, diskRdonlySpace, manager.dataNode.diskMaxErr); err != nil {
+		return
+	}
+	manager.updateMetrics()
+	log.LogInfof("action[AttachDisk] disk(%v) reservedSpace(%v) attached", path, reservedSpace)
+	return
+}
+
+// DetachDisk removes a disk which holds no data partition while the data node is running.
+func (manager *SpaceManager) DetachDisk(path string) (err error) {
+	// hold the partition lock so that no partition is created on the disk meanwhile
+	manager.partitionMutex.Lock()
+	defer manager.partitionMutex.Unlock()
+	var disk *Disk
+	if disk, err = manager.GetDisk(path); err != nil {
+		return
+	}
+	if disk.PartitionCount() != 0 {
+		return ErrDiskNotEmpty
+	}
+	manager.diskMutex.Lock()
+	delete(manager.disks, path)
+	for i, p := range manager.diskList {
+		if p == path {
+			manager.diskList = append(manager.diskList[:i], manager.diskList[i+1:]...)
+			break
+		}
+	}
+	manager.diskMutex.Unlock()
+	disk.Stop()
+	manager.updateMetrics()
+	log.LogInfof("action[DetachDisk] disk(%v) detached", path)
+	return
+}
+
 func (manager *SpaceManager) updateMetrics() {
 	manager.diskMutex.RLock()
 	var (
@@ -300,6 +415,8 @@ func (manager *SpaceManager) CreatePartition(request *proto.CreateDataPartitionR
 		NodeID:        manager.nodeID,
 		ClusterID:     manager.clusterID,
 		PartitionSize: request.PartitionSize,
+		Encrypted:     request.Encrypted,
+		ChecksumType:  request.ChecksumType,
 	}
 	dp = manager.partitions[dpCfg.PartitionID]
 	if dp != nil {
@@ -354,6 +471,8 @@ func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatRespo
 	stat.Unlock()
 
 	response.ZoneName = s.zoneName
+	response.Rack = s.rack
+	response.Crc32cSupported = true
 	response.PartitionReports = make([]*proto.PartitionReport, 0)
 	space := s.space
 	space.RangePartitions(func(partition *DataPartition) bool {
@@ -368,6 +487,10 @@ func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatRespo
 			IsLeader:        isLeader,
 			ExtentCount:     partition.GetExtentCount(),
 			NeedCompare:     true,
+			AccessStats:     partition.accessStats.lastWindow(),
+			LastWriteTime:   partition.lastWriteTime(),
+			ECStatus:        partition.ECStatus(),
+			ECProgress:      partition.ecProgress(),
 		}
 		log.LogDebugf("action[Heartbeats] dpid(%v), status(%v) total(%v) used(%v) leader(%v) isLeader(%v).", vr.PartitionID, vr.PartitionStatus, vr.Total, vr.Used, leaderAddr, vr.IsLeader)
 		response.PartitionReports = append(response.PartitionReports, vr)
@@ -379,5 +502,33 @@ func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatRespo
 		if d.Status == proto.Unavailable {
 			response.BadDisks = append(response.BadDisks, d.Path)
 		}
+		if smart := d.getSmart(); smart != nil {
+			response.DiskSmarts = append(response.DiskSmarts, smart)
+		}
+	}
+	response.CorruptExtents = s.scrubber.getReports()
+	response.HotExtents = s.accessTracker.getHotExtents()
+	response.AccessStatsWindow = AccessStatsWindow
+	response.Version = proto.BuildVersion()
+	response.LocalTime = time.Now().UnixNano()
+}
+
+// trimPartitionReports leaves the partition reports changed since the report acked by the master.
+func (s *DataNode) trimPartitionReports(response *proto.DataNodeHeartbeatResponse, ackedSeq uint64) {
+	reports := make(map[uint64]interface{}, len(response.PartitionReports))
+	for _, vr := range response.PartitionReports {
+		reports[vr.PartitionID] = *vr
+	}
+	delta := s.reportTracker.Report(ackedSeq, reports)
+	response.ReportSeq, response.BaseReportSeq, response.RemovedPartitions = delta.Seq, delta.Base, delta.Removed
+	if delta.Base == 0 {
+		return
+	}
+	changed := response.PartitionReports[:0]
+	for _, vr := range response.PartitionReports {
+		if delta.Changed(vr.PartitionID) {
+			changed = append(changed, vr)
+		}
 	}
+	response.PartitionReports = changed
 }
@@ -20,7 +20,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"hash/crc32"
@@ -56,6 +58,19 @@ func (s *DataNode) getPacketTpLabels(p *repl.Packet) map[string]string {
 	return labels
 }
 
+// recordSLO counts the operations of the clients by the volumes for the SLOs of the volumes.
+func (s *DataNode) recordSLO(p *repl.Packet, start int64) {
+	switch p.Opcode {
+	case proto.OpWrite, proto.OpSyncWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite,
+		proto.OpStreamRead, proto.OpStreamFollowerRead:
+	default:
+		return
+	}
+	if part, ok := p.Object.(*DataPartition); ok {
+		s.sloRecorder.Record(part.volumeID, time.Duration(time.Now().UnixNano()-start), p.IsErrPacket())
+	}
+}
+
 func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 	var (
 		tpLabels map[string]string
@@ -84,7 +99,7 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 			case proto.OpStreamRead, proto.OpRead, proto.OpExtentRepairRead, proto.OpStreamFollowerRead:
 			case proto.OpReadTinyDeleteRecord:
 				log.LogRead(logContent)
-			case proto.OpWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite, proto.OpSyncWrite, proto.OpMarkDelete:
+			case proto.OpWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite, proto.OpSyncWrite, proto.OpMarkDelete, proto.OpPunchHole, proto.OpPreallocExtent:
 				log.LogWrite(logContent)
 			default:
 				log.LogInfo(logContent)
@@ -94,6 +109,7 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 		if !shallDegrade {
 			tpObject.SetWithLabels(err, tpLabels)
 		}
+		s.recordSLO(p, start)
 	}()
 	switch p.Opcode {
 	case proto.OpCreateExtent:
@@ -112,6 +128,12 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 		s.handleMarkDeletePacket(p, c)
 	case proto.OpBatchDeleteExtent:
 		s.handleBatchMarkDeletePacket(p, c)
+	case proto.OpPunchHole:
+		s.handlePunchHolePacket(p)
+	case proto.OpPreallocExtent:
+		s.handlePreallocExtentPacket(p)
+	case proto.OpSealExtents:
+		s.handlePacketToSealExtents(p)
 	case proto.OpRandomWrite, proto.OpSyncRandomWrite:
 		s.handleRandomWritePacket(p)
 	case proto.OpNotifyReplicasToRepair:
@@ -144,6 +166,20 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 		s.handlePacketToReadTinyDeleteRecordFile(p, c)
 	case proto.OpBroadcastMinAppliedID:
 		s.handleBroadcastMinAppliedID(p)
+	case proto.OpGetExtentCrcs:
+		s.handlePacketToGetExtentCrcs(p)
+	case proto.OpCheckDataPartition:
+		s.handlePacketToCheckDataPartition(p)
+	case proto.OpBackupDataPartition:
+		s.handlePacketToBackupDataPartition(p)
+	case proto.OpRestoreDataPartition:
+		s.handlePacketToRestoreDataPartition(p)
+	case proto.OpConvertDataPartitionToEC:
+		s.handlePacketToConvertDataPartitionToEC(p)
+	case proto.OpDataNodeFenceWrites:
+		s.handlePacketToFenceWrites(p)
+	case proto.OpReadECShard:
+		s.handlePacketToReadECShard(p)
 	default:
 		p.PackErrorBody(repl.ErrorUnknownOp.Error(), repl.ErrorUnknownOp.Error()+strconv.Itoa(int(p.Opcode)))
 	}
@@ -236,13 +272,22 @@ func (s *DataNode) handleHeartbeatPacket(p *repl.Packet) {
 	go func() {
 		request := &proto.HeartBeatRequest{}
 		response := &proto.DataNodeHeartbeatResponse{}
-		s.buildHeartBeatResponse(response)
 
 		if task.OpCode == proto.OpDataNodeHeartbeat {
 			marshaled, _ := json.Marshal(task.Request)
 			_ = json.Unmarshal(marshaled, request)
+			s.compressor.setVolCompressions(request.VolCompressions)
+			s.space.SetVolEncryptKeys(request.VolEncryptKeys)
+			s.writeLimiter.setVolWriteLimits(request.VolWriteLimits)
+			s.setRepairLimit(request.RepairLimit)
+			gReplicaAddrs.Update(request.ReplicaAddrs)
+			MasterClient.NodeAPI().SetTaskCodec(request.TaskCodec)
+			s.buildHeartBeatResponse(response)
+			s.trimPartitionReports(response, request.ReportSeq)
+			response.SLOReports = s.sloRecorder.Take()
 			response.Status = proto.TaskSucceeds
 		} else {
+			s.buildHeartBeatResponse(response)
 			response.Status = proto.TaskFailed
 			err = fmt.Errorf("illegal opcode")
 			response.Result = err.Error()
@@ -343,6 +388,141 @@ func (s *DataNode) asyncLoadDataPartition(task *proto.AdminTask) {
 	}
 }
 
+func (s *DataNode) handlePacketToCheckDataPartition(p *repl.Packet) {
+	task := &proto.AdminTask{}
+	if err := json.Unmarshal(p.Data, task); err != nil {
+		p.PackErrorBody(ActionCheckDataPartition, err.Error())
+		return
+	}
+	p.PacketOkReply()
+	go s.asyncCheckDataPartition(task)
+}
+
+func (s *DataNode) asyncCheckDataPartition(task *proto.AdminTask) {
+	request := &proto.CheckDataPartitionRequest{}
+	bytes, _ := json.Marshal(task.Request)
+	json.Unmarshal(bytes, request)
+	response := &proto.CheckDataPartitionResponse{PartitionId: request.PartitionId}
+	if dp := s.space.Partition(request.PartitionId); dp == nil {
+		response.Status = proto.TaskFailed
+		response.Result = fmt.Sprintf("DataPartition(%v) not found", request.PartitionId)
+	} else {
+		// the master sends the task again until it is answered
+		if !atomic.CompareAndSwapInt32(&dp.isChecking, 0, 1) {
+			log.LogInfof("action[asyncCheckDataPartition] partition(%v) is being checked", request.PartitionId)
+			return
+		}
+		response = dp.CheckConsistency(request.Repair)
+		atomic.StoreInt32(&dp.isChecking, 0)
+	}
+	task.Response = response
+	if err := MasterClient.NodeAPI().ResponseDataNodeTask(task); err != nil {
+		err = errors.Trace(err, "check DataPartition failed,PartitionID(%v)", request.PartitionId)
+		log.LogError(errors.Stack(err))
+	}
+}
+
+func (s *DataNode) handlePacketToBackupDataPartition(p *repl.Packet) {
+	task := &proto.AdminTask{}
+	if err := json.Unmarshal(p.Data, task); err != nil {
+		p.PackErrorBody(ActionBackupDataPartition, err.Error())
+		return
+	}
+	p.PacketOkReply()
+	go s.asyncBackupDataPartition(task)
+}
+
+func (s *DataNode) asyncBackupDataPartition(task *proto.AdminTask) {
+	request := &proto.BackupDataPartitionRequest{}
+	bytes, _ := json.Marshal(task.Request)
+	json.Unmarshal(bytes, request)
+	response := &proto.BackupDataPartitionResponse{PartitionId: request.PartitionId, Path: request.Path}
+	if dp := s.space.Partition(request.PartitionId); dp == nil {
+		response.Status = proto.TaskFailed
+		response.Result = fmt.Sprintf("DataPartition(%v) not found", request.PartitionId)
+	} else {
+		// the master sends the task again until it is answered
+		if dp.isBackingUp() {
+			log.LogInfof("action[asyncBackupDataPartition] partition(%v) is being backed up or restored", request.PartitionId)
+			return
+		}
+		response = dp.Backup(request)
+		dp.finishBackingUp()
+	}
+	task.Response = response
+	if err := MasterClient.NodeAPI().ResponseDataNodeTask(task); err != nil {
+		err = errors.Trace(err, "backup DataPartition failed,PartitionID(%v)", request.PartitionId)
+		log.LogError(errors.Stack(err))
+	}
+}
+
+func (s *DataNode) handlePacketToRestoreDataPartition(p *repl.Packet) {
+	task := &proto.AdminTask{}
+	if err := json.Unmarshal(p.Data, task); err != nil {
+		p.PackErrorBody(ActionRestoreDataPartition, err.Error())
+		return
+	}
+	p.PacketOkReply()
+	go s.asyncRestoreDataPartition(task)
+}
+
+func (s *DataNode) asyncRestoreDataPartition(task *proto.AdminTask) {
+	request := &proto.RestoreDataPartitionRequest{}
+	bytes, _ := json.Marshal(task.Request)
+	json.Unmarshal(bytes, request)
+	response := &proto.RestoreDataPartitionResponse{PartitionId: request.PartitionId, Path: request.Path}
+	if dp := s.space.Partition(request.PartitionId); dp == nil {
+		response.Status = proto.TaskFailed
+		response.Result = fmt.Sprintf("DataPartition(%v) not found", request.PartitionId)
+	} else {
+		if dp.isBackingUp() {
+			log.LogInfof("action[asyncRestoreDataPartition] partition(%v) is being backed up or restored", request.PartitionId)
+			return
+		}
+		response = dp.Restore(request)
+		dp.finishBackingUp()
+	}
+	task.Response = response
+	if err := MasterClient.NodeAPI().ResponseDataNodeTask(task); err != nil {
+		err = errors.Trace(err, "restore DataPartition failed,PartitionID(%v)", request.PartitionId)
+		log.LogError(errors.Stack(err))
+	}
+}
+
+func (s *DataNode) handlePacketToConvertDataPartitionToEC(p *repl.Packet) {
+	task := &proto.AdminTask{}
+	if err := json.Unmarshal(p.Data, task); err != nil {
+		p.PackErrorBody(ActionConvertDataPartitionToEC, err.Error())
+		return
+	}
+	p.PacketOkReply()
+	go s.asyncConvertDataPartitionToEC(task)
+}
+
+func (s *DataNode) asyncConvertDataPartitionToEC(task *proto.AdminTask) {
+	request := &proto.ConvertDataPartitionToECRequest{}
+	bytes, _ := json.Marshal(task.Request)
+	json.Unmarshal(bytes, request)
+	response := &proto.ConvertDataPartitionToECResponse{PartitionId: request.PartitionId, Phase: request.Phase}
+	if dp := s.space.Partition(request.PartitionId); dp == nil {
+		response.Status = proto.TaskFailed
+		response.Result = fmt.Sprintf("DataPartition(%v) not found", request.PartitionId)
+	} else {
+		// the master sends the task again until it is answered
+		if dp.isECRunning() {
+			log.LogInfof("action[asyncConvertDataPartitionToEC] partition(%v) is being converted", request.PartitionId)
+			return
+		}
+		response = dp.ConvertToEC(request)
+		dp.finishECRunning()
+	}
+	task.Response = response
+	if err := MasterClient.NodeAPI().ResponseDataNodeTask(task); err != nil {
+		err = errors.Trace(err, "convert DataPartition to EC failed,PartitionID(%v)", request.PartitionId)
+		log.LogError(errors.Stack(err))
+	}
+}
+
 // Handle OpMarkDelete packet.
 func (s *DataNode) handleMarkDeletePacket(p *repl.Packet, c net.Conn) {
 	var (
@@ -368,6 +548,10 @@ func (s *DataNode) handleMarkDeletePacket(p *repl.Packet, c net.Conn) {
 		log.LogInfof("handleMarkDeletePacket Delete PartitionID(%v)_Extent(%v)",
 			p.PartitionID, p.ExtentID)
 		partition.ExtentStore().MarkDelete(p.ExtentID, 0, 0)
+		partition.deleteECExtent(p.ExtentID)
+	}
+	if err == nil {
+		s.metrics.MetricDeleteExtent.AddWithLabels(1, map[string]string{exporter.Vol: partition.volumeID})
 	}
 
 	return
@@ -391,20 +575,83 @@ func (s *DataNode) handleBatchMarkDeletePacket(p *repl.Packet, c net.Conn) {
 	err = json.Unmarshal(p.Data, &exts)
 	store := partition.ExtentStore()
 	if err == nil {
+		var deleted, rejected int64
 		for _, ext := range exts {
 			if deleteLimiteRater.Allow() {
 				log.LogInfof(fmt.Sprintf("recive DeleteExtent (%v) from (%v)", ext, c.RemoteAddr().String()))
 				store.MarkDelete(ext.ExtentId, int64(ext.ExtentOffset), int64(ext.Size))
+				if !storage.IsTinyExtent(ext.ExtentId) {
+					partition.deleteECExtent(ext.ExtentId)
+				}
+				deleted++
 			} else {
 				log.LogInfof("delete limiter reach(%v), remote (%v) try again.", deleteLimiteRater.Limit(), c.RemoteAddr().String())
 				err = storage.TryAgainError
+				rejected++
 			}
 		}
+		labels := map[string]string{exporter.Vol: partition.volumeID}
+		s.metrics.MetricDeleteExtent.AddWithLabels(deleted, labels)
+		s.metrics.MetricDeleteReject.AddWithLabels(rejected, labels)
 	}
 
 	return
 }
 
+// Handle OpPunchHole packet.
+func (s *DataNode) handlePunchHolePacket(p *repl.Packet) {
+	var (
+		err error
+	)
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionPunchHole, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+	partition := p.Object.(*DataPartition)
+	ext := new(proto.ExtentKey)
+	if err = json.Unmarshal(p.Data, ext); err != nil {
+		return
+	}
+	log.LogInfof("handlePunchHolePacket PartitionID(%v)_Extent(%v)_Offset(%v)_Size(%v)",
+		p.PartitionID, p.ExtentID, ext.ExtentOffset, ext.Size)
+	released, err := partition.ExtentStore().PunchHole(p.ExtentID, int64(ext.ExtentOffset), int64(ext.Size))
+	if err != nil {
+		return
+	}
+	partition.disk.addReleasedSpace(released)
+	return
+}
+
+// Handle OpPreallocExtent packet.
+func (s *DataNode) handlePreallocExtentPacket(p *repl.Packet) {
+	var (
+		err error
+	)
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionPreallocExtent, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+	partition := p.Object.(*DataPartition)
+	ext := new(proto.ExtentKey)
+	if err = json.Unmarshal(p.Data, ext); err != nil {
+		return
+	}
+	if int64(partition.Available()) < int64(ext.Size) {
+		err = storage.NoSpaceError
+		return
+	}
+	log.LogInfof("handlePreallocExtentPacket PartitionID(%v)_Extent(%v)_Offset(%v)_Size(%v)",
+		p.PartitionID, p.ExtentID, ext.ExtentOffset, ext.Size)
+	err = partition.ExtentStore().Preallocate(p.ExtentID, int64(ext.ExtentOffset), int64(ext.Size))
+	return
+}
+
 // Handle OpWrite packet.
 func (s *DataNode) handleWritePacket(p *repl.Packet) {
 	var (
@@ -433,15 +680,24 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 		return
 	}
 	store := partition.ExtentStore()
+	partition.disk.WaitIO(int(p.Size), false)
+	verify := partition.VerifiesWrite()
+	isSync := p.IsSyncWrite() || verify
 	if p.ExtentType == proto.TinyExtentType {
 		if !shallDegrade {
 			partitionIOMetric = exporter.NewTPCnt(MetricPartitionIOName)
 		}
-		err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, p.CRC, storage.AppendWriteType, p.IsSyncWrite())
+		err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, p.CRC, storage.AppendWriteType, isSync)
 		if !shallDegrade {
 			s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 			partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
 		}
+		if err == nil && verify {
+			err = partition.checkWrite(p.ExtentID, p.ExtentOffset, int64(p.Size), p.ChecksumType, p.CRC)
+		}
+		if err == nil {
+			partition.accessStats.recordWrite(p.ExtentID, p.Size)
+		}
 		s.incDiskErrCnt(p.PartitionID, err, WriteFlag)
 		return
 	}
@@ -450,7 +706,7 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 		if !shallDegrade {
 			partitionIOMetric = exporter.NewTPCnt(MetricPartitionIOName)
 		}
-		err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, p.CRC, storage.AppendWriteType, p.IsSyncWrite())
+		err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, storeCrc(p, store), storage.AppendWriteType, isSync)
 		if !shallDegrade {
 			s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 			partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
@@ -465,11 +721,11 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 			}
 			currSize := util.Min(int(size), util.BlockSize)
 			data := p.Data[offset : offset+currSize]
-			crc := crc32.ChecksumIEEE(data)
+			crc := proto.Checksum(store.ChecksumType(), data)
 			if !shallDegrade {
 				partitionIOMetric = exporter.NewTPCnt(MetricPartitionIOName)
 			}
-			err = store.Write(p.ExtentID, p.ExtentOffset+int64(offset), int64(currSize), data, crc, storage.AppendWriteType, p.IsSyncWrite())
+			err = store.Write(p.ExtentID, p.ExtentOffset+int64(offset), int64(currSize), data, crc, storage.AppendWriteType, isSync)
 			if !shallDegrade {
 				s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 				partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
@@ -482,6 +738,12 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 			offset += currSize
 		}
 	}
+	if err == nil && verify {
+		err = partition.checkWrite(p.ExtentID, p.ExtentOffset, int64(p.Size), p.ChecksumType, p.CRC)
+	}
+	if err == nil {
+		partition.accessStats.recordWrite(p.ExtentID, p.Size)
+	}
 	s.incDiskErrCnt(p.PartitionID, err, WriteFlag)
 	return
 }
@@ -511,6 +773,7 @@ func (s *DataNode) handleRandomWritePacket(p *repl.Packet) {
 		metricPartitionIOLabels = GetIoMetricLabels(partition, "randwrite")
 		partitionIOMetric = exporter.NewTPCnt(MetricPartitionIOName)
 	}
+	partition.disk.WaitIO(int(p.Size), false)
 	err = partition.RandomWriteSubmit(p)
 	if !shallDegrade {
 		s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
@@ -586,9 +849,16 @@ func (s *DataNode) extentRepairReadPacket(p *repl.Packet, connect net.Conn, isRe
 		}
 	}()
 	partition := p.Object.(*DataPartition)
+	if partition.disk.Status == proto.Unavailable {
+		err = storage.BrokenDiskError
+		return
+	}
 	needReplySize := p.Size
 	offset := p.ExtentOffset
 	store := partition.ExtentStore()
+	if !isRepairRead {
+		partition.accessStats.recordRead(p.ExtentID, needReplySize)
+	}
 	shallDegrade := p.ShallDegrade()
 	if !shallDegrade {
 		metricPartitionIOLabels = GetIoMetricLabels(partition, "read")
@@ -600,42 +870,69 @@ func (s *DataNode) extentRepairReadPacket(p *repl.Packet, connect net.Conn, isRe
 		err = nil
 		reply := repl.NewStreamReadResponsePacket(p.ReqID, p.PartitionID, p.ExtentID)
 		reply.StartT = p.StartT
+		reply.ChecksumType = p.ChecksumType
 		currReadSize := uint32(util.Min(int(needReplySize), util.ReadBlockSize))
-		if currReadSize == util.ReadBlockSize {
-			reply.Data, _ = proto.Buffers.Get(util.ReadBlockSize)
-		} else {
-			reply.Data = make([]byte, currReadSize)
-		}
 		if !shallDegrade {
 			partitionIOMetric = exporter.NewTPCnt(MetricPartitionIOName)
 			tpObject = exporter.NewTPCnt(fmt.Sprintf("Repair_%s", p.GetOpMsg()))
 		}
 		reply.ExtentOffset = offset
+		reply.Size = uint32(currReadSize)
+		reply.ResultCode = proto.OpOk
+		reply.Opcode = p.Opcode
+		if p.Opcode == proto.OpStreamFollowerRead {
+			// let the clients learn the location of the replica for their nearest reads
+			reply.Arg = s.locationHint
+			reply.ArgLen = uint32(len(reply.Arg))
+		}
 		p.Size = uint32(currReadSize)
 		p.ExtentOffset = offset
-		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead)
+		partition.disk.WaitIO(int(currReadSize), isRepairRead)
+		var sent bool
+		_, ecExtent := partition.ecExtent(p.ExtentID)
+		if ecExtent == nil {
+			// the erasure coded extents are read from their shards
+			sent, err = s.writeRepairReplyZeroCopy(reply, store, connect)
+		}
+		if !sent && err == nil {
+			if currReadSize == util.ReadBlockSize {
+				reply.Data, _ = proto.Buffers.Get(util.ReadBlockSize)
+			} else {
+				reply.Data = make([]byte, currReadSize)
+			}
+			if reply.CRC, err = partition.readExtent(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead); err == nil {
+				if p.VerifyChecksum && ecExtent == nil {
+					// the client retries on another replica if the data is corrupt on the disk
+					if err = store.VerifyRead(reply.ExtentID, offset, int64(currReadSize), reply.Data); err == proto.ErrBlockCrcMismatch {
+						s.metrics.MetricCorruptBlock.AddWithLabels(1, map[string]string{exporter.Vol: partition.volumeID})
+					}
+				}
+				setReplyChecksum(reply, store)
+			}
+		}
 		if !shallDegrade {
 			s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 			partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
 			tpObject.Set(err)
 		}
-		partition.checkIsDiskError(err)
+		if !sent {
+			partition.checkIsDiskError(err)
+		}
 		p.CRC = reply.CRC
 		if err != nil {
 			return
 		}
-		reply.Size = uint32(currReadSize)
-		reply.ResultCode = proto.OpOk
-		reply.Opcode = p.Opcode
 		p.ResultCode = proto.OpOk
-		if err = reply.WriteToConn(connect); err != nil {
-			return
+		if !sent {
+			if err = reply.WriteToConn(connect); err != nil {
+				return
+			}
+			if currReadSize == util.ReadBlockSize {
+				proto.Buffers.Put(reply.Data)
+			}
 		}
 		needReplySize -= currReadSize
 		offset += int64(currReadSize)
-		if currReadSize == util.ReadBlockSize {
-			proto.Buffers.Put(reply.Data)
-		}
 		logContent := fmt.Sprintf("action[operatePacket] %v.",
 			reply.LogMessage(reply.GetOpMsg(), connect.RemoteAddr().String(), reply.StartT, err))
 		log.LogReadf(logContent)
@@ -645,6 +942,51 @@ func (s *DataNode) extentRepairReadPacket(p *repl.Packet, connect net.Conn, isRe
 	return
 }
 
+// writeRepairReplyZeroCopy writes the reply of a full block of a normal extent to the
+// connection with the data sent from the extent file by sendfile, instead of being read
+// into a buffer first. It returns false if the block has to be read.
+func (s *DataNode) writeRepairReplyZeroCopy(reply *repl.Packet, store *storage.ExtentStore, connect net.Conn) (sent bool, err error) {
+	tcpConn, ok := connect.(*net.TCPConn)
+	if !s.zeroCopyRepair || !ok || reply.ChecksumType != store.ChecksumType() {
+		return
+	}
+	return store.ReadZeroCopy(reply.ExtentID, reply.ExtentOffset, int64(reply.Size), func(file *os.File, crc uint32) (err error) {
+		reply.CRC = crc
+		header, err := proto.Buffers.Get(util.PacketHeaderSize)
+		if err != nil {
+			header = make([]byte, util.PacketHeaderSize)
+		}
+		defer proto.Buffers.Put(header)
+		reply.MarshalHeader(header)
+		connect.SetWriteDeadline(time.Now().Add(proto.WriteDeadlineTime * time.Second))
+		if _, err = connect.Write(header); err != nil {
+			return
+		}
+		if _, err = connect.Write(reply.Arg[:int(reply.ArgLen)]); err != nil {
+			return
+		}
+		return sendFile(tcpConn, file, reply.ExtentOffset, int(reply.Size))
+	})
+}
+
+// storeCrc returns the crc of the data of the packet to be kept by the store as the crc of
+// the block, or 0 if the packet is not checksummed as the store, and the store computes
+// the crc of the block later.
+func storeCrc(p *repl.Packet, store *storage.ExtentStore) uint32 {
+	if p.ChecksumType != store.ChecksumType() {
+		return 0
+	}
+	return p.CRC
+}
+
+// setReplyChecksum sets the crc of the read reply with the checksum type of the request,
+// which is not the one of the store if the request comes from a client not knowing it.
+func setReplyChecksum(reply *repl.Packet, store *storage.ExtentStore) {
+	if reply.ChecksumType != store.ChecksumType() {
+		reply.CRC = reply.Checksum(reply.Data[:reply.Size])
+	}
+}
+
 func (s *DataNode) handlePacketToGetAllWatermarks(p *repl.Packet) {
 	var (
 		buf       []byte
@@ -671,6 +1013,49 @@ func (s *DataNode) handlePacketToGetAllWatermarks(p *repl.Packet) {
 	return
 }
 
+func (s *DataNode) handlePacketToGetExtentCrcs(p *repl.Packet) {
+	var (
+		buf   []byte
+		infos []*extentCrcInfo
+		err   error
+	)
+	partition := p.Object.(*DataPartition)
+	request := &extentCrcRequest{}
+	if err = json.Unmarshal(p.Data[:p.Size], request); err == nil {
+		infos, err = partition.computeExtentCrcs(request)
+	}
+	if err == nil {
+		buf, err = json.Marshal(infos)
+	}
+	if err != nil {
+		p.PackErrorBody(ActionGetExtentCrcs, err.Error())
+		return
+	}
+	p.PacketOkWithBody(buf)
+}
+
+func (s *DataNode) handlePacketToReadECShard(p *repl.Packet) {
+	var (
+		buf []byte
+		err error
+	)
+	partition := p.Object.(*DataPartition)
+	if p.Size != 4 {
+		err = fmt.Errorf("invalid request size %v", p.Size)
+	} else if size := binary.BigEndian.Uint32(p.Data[:4]); size > ECUnitSize {
+		err = fmt.Errorf("read size %v exceeds the unit size %v", size, ECUnitSize)
+	} else {
+		buf = make([]byte, size)
+		partition.disk.WaitIO(int(size), true)
+		err = partition.readLocalECShard(p.ExtentID, p.ExtentOffset, buf)
+	}
+	if err != nil {
+		p.PackErrorBody(ActionReadECShard, err.Error())
+		return
+	}
+	p.PacketOkWithBody(buf)
+}
+
 func (s *DataNode) writeEmptyPacketOnTinyExtentRepairRead(reply *repl.Packet, newOffset, currentOffset int64, connect net.Conn) (replySize int64, err error) {
 	replySize = newOffset - currentOffset
 	reply.Data = make([]byte, 0)
@@ -713,6 +1098,10 @@ func (s *DataNode) tinyExtentRepairRead(request *repl.Packet, connect net.Conn)
 	}
 
 	partition := request.Object.(*DataPartition)
+	if partition.disk.Status == proto.Unavailable {
+		err = storage.BrokenDiskError
+		return
+	}
 	store := partition.ExtentStore()
 	tinyExtentFinfoSize, err = store.TinyExtentGetFinfoSize(request.ExtentID)
 	if err != nil {
@@ -733,6 +1122,7 @@ func (s *DataNode) tinyExtentRepairRead(request *repl.Packet, connect net.Conn)
 			break
 		}
 		reply := repl.NewTinyExtentStreamReadResponsePacket(request.ReqID, request.PartitionID, request.ExtentID)
+		reply.ChecksumType = request.ChecksumType
 		reply.ArgLen = TinyExtentRepairReadResponseArgLen
 		reply.Arg = make([]byte, TinyExtentRepairReadResponseArgLen)
 		s.attachAvaliSizeOnTinyExtentRepairRead(reply, avaliReplySize)
@@ -759,7 +1149,10 @@ func (s *DataNode) tinyExtentRepairRead(request *repl.Packet, connect net.Conn)
 			reply.Data = make([]byte, currReadSize)
 		}
 		reply.ExtentOffset = offset
-		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, false)
+		partition.disk.WaitIO(int(currReadSize), true)
+		if reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, false); err == nil {
+			setReplyChecksum(reply, store)
+		}
 		if err != nil {
 			return
 		}
@@ -1096,7 +1489,7 @@ func (s *DataNode) handlePacketToDataPartitionTryToLeaderrr(p *repl.Packet) {
 
 func (s *DataNode) forwardToRaftLeader(dp *DataPartition, p *repl.Packet) (ok bool, err error) {
 	var (
-		conn       *net.TCPConn
+		conn       net.Conn
 		leaderAddr string
 	)
 
@@ -1111,7 +1504,7 @@ func (s *DataNode) forwardToRaftLeader(dp *DataPartition, p *repl.Packet) (ok bo
 	}
 
 	// forward the packet to the leader if local one is not the leader
-	conn, err = gConnPool.GetConnect(leaderAddr)
+	conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(leaderAddr))
 	if err != nil {
 		return
 	}
@@ -17,7 +17,6 @@ package datanode
 import (
 	"encoding/json"
 	"fmt"
-	"hash/crc32"
 	"sync/atomic"
 
 	"github.com/cubefs/cubefs/proto"
@@ -52,6 +51,15 @@ func (s *DataNode) Prepare(p *repl.Packet) (err error) {
 	if err = s.checkPartition(p); err != nil {
 		return
 	}
+	if err = s.checkWriteFence(p); err != nil {
+		return
+	}
+	if err = s.checkExtentSeal(p); err != nil {
+		return
+	}
+	if err = s.checkWriteLimit(p); err != nil {
+		return
+	}
 
 	// For certain packet, we meed to add some additional extent information.
 	if err = s.addExtentInfo(p); err != nil {
@@ -72,7 +80,7 @@ func (s *DataNode) checkCrc(p *repl.Packet) (err error) {
 	if !p.IsWriteOperation() {
 		return
 	}
-	crc := crc32.ChecksumIEEE(p.Data[:p.Size])
+	crc := p.Checksum(p.Data[:p.Size])
 	if crc != p.CRC {
 		return storage.CrcMismatchError
 	}
@@ -87,7 +95,13 @@ func (s *DataNode) checkPartition(p *repl.Packet) (err error) {
 		return
 	}
 	p.Object = dp
-	if p.IsWriteOperation() || p.IsCreateExtentOperation() {
+	if p.IsWriteOperation() || p.IsCreateExtentOperation() || p.IsRandomWrite() || p.IsPreallocExtentOperation() {
+		if dp.ECStatus() != proto.ECStatusNone {
+			err = proto.ErrDataPartitionEC
+			return
+		}
+	}
+	if p.IsWriteOperation() || p.IsCreateExtentOperation() || p.IsPreallocExtentOperation() {
 		if dp.Available() <= 0 {
 			err = storage.NoSpaceError
 			return
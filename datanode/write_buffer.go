@@ -0,0 +1,85 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/config"
+)
+
+const (
+	ConfigKeyPmemPath         = "pmemPath"         // string, directory on a persistent memory device mounted with dax, used as the write buffer
+	ConfigKeyPmemCapacity     = "pmemCapacity"     // int, bytes of the write buffer
+	ConfigKeyPmemMaxWriteSize = "pmemMaxWriteSize" // int, the synchronous writes up to the size are buffered
+
+	DefaultPmemCapacity     = util.GB
+	DefaultPmemMaxWriteSize = util.BlockSize
+)
+
+// initWriteBuffer opens the write buffer configured, it must be called before the partitions are
+// loaded so that the buffered writes can be replayed.
+func (s *DataNode) initWriteBuffer(cfg *config.Config) (err error) {
+	dir := cfg.GetString(ConfigKeyPmemPath)
+	if dir == "" {
+		return
+	}
+	capacity := cfg.GetInt64(ConfigKeyPmemCapacity)
+	if capacity == 0 {
+		capacity = DefaultPmemCapacity
+	}
+	maxWriteSize := cfg.GetInt64(ConfigKeyPmemMaxWriteSize)
+	if maxWriteSize == 0 {
+		maxWriteSize = DefaultPmemMaxWriteSize
+	}
+	if s.writeBuffer, err = storage.NewWriteBuffer(dir, capacity, maxWriteSize); err != nil {
+		return fmt.Errorf("open write buffer(%v): %v", dir, err)
+	}
+	return
+}
+
+// replayWriteBuffer applies the buffered writes of the store and makes it use the buffer.
+func (s *DataNode) replayWriteBuffer(store *storage.ExtentStore, encrypted bool) {
+	if s.writeBuffer == nil {
+		return
+	}
+	s.writeBuffer.Replay(store)
+	if !encrypted {
+		store.SetWriteBuffer(s.writeBuffer)
+	}
+}
+
+func (s *DataNode) finishWriteBufferReplay() {
+	if s.writeBuffer != nil {
+		s.writeBuffer.FinishReplay()
+	}
+}
+
+func (s *DataNode) closeWriteBuffer() {
+	if s.writeBuffer != nil {
+		s.writeBuffer.Close()
+	}
+}
+
+func (s *DataNode) getWriteBufferStats(w http.ResponseWriter, r *http.Request) {
+	if s.writeBuffer == nil {
+		s.buildSuccessResp(w, nil)
+		return
+	}
+	s.buildSuccessResp(w, s.writeBuffer.Stats())
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+)
+
+// writeFences keeps the deadlines of the write fences of the volumes set by the master while the
+// snapshots of the volumes are taken or restored, so that the data is not overwritten meanwhile.
+type writeFences struct {
+	sync.RWMutex
+	deadlines map[string]time.Time
+}
+
+func newWriteFences() *writeFences {
+	return &writeFences{deadlines: make(map[string]time.Time)}
+}
+
+// set fences the writes of the volume for ttl, or lifts the fence if ttl is 0.
+func (f *writeFences) set(volName string, ttl time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	if ttl <= 0 {
+		delete(f.deadlines, volName)
+		return
+	}
+	f.deadlines[volName] = time.Now().Add(ttl)
+}
+
+func (f *writeFences) isFenced(volName string) bool {
+	if f == nil {
+		return false
+	}
+	f.RLock()
+	defer f.RUnlock()
+	deadline, ok := f.deadlines[volName]
+	return ok && time.Now().Before(deadline)
+}
+
+func (s *DataNode) handlePacketToFenceWrites(p *repl.Packet) {
+	task := &proto.AdminTask{}
+	if err := json.Unmarshal(p.Data, task); err != nil {
+		p.PackErrorBody(ActionFenceWrites, err.Error())
+		return
+	}
+	request := &proto.FenceWritesRequest{}
+	bytes, _ := json.Marshal(task.Request)
+	if err := json.Unmarshal(bytes, request); err != nil {
+		p.PackErrorBody(ActionFenceWrites, err.Error())
+		return
+	}
+	s.writeFences.set(request.VolName, time.Duration(request.TTL)*time.Second)
+	p.PacketOkReply()
+}
+
+// checkWriteFence rejects the writes from the clients to the volumes fenced, which the clients retry.
+// The writes forwarded by the leaders have been admitted already.
+func (s *DataNode) checkWriteFence(p *repl.Packet) (err error) {
+	if !(p.IsLeaderPacket() && p.IsWriteOperation()) && !p.IsRandomWrite() {
+		return
+	}
+	dp := p.Object.(*DataPartition)
+	if s.writeFences.isFenced(dp.volumeID) {
+		err = proto.ErrVolWritesFenced
+	}
+	return
+}
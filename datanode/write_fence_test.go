@@ -0,0 +1,82 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+)
+
+func TestCheckWriteFence(t *testing.T) {
+	cases := []struct {
+		name      string
+		opcode    uint8
+		followers uint8
+		vol       string
+		fenced    bool
+	}{
+		{"append", proto.OpWrite, 2, "vol", true},
+		{"sync append", proto.OpSyncWrite, 2, "vol", true},
+		{"overwrite", proto.OpRandomWrite, 0, "vol", true},
+		{"sync overwrite", proto.OpSyncRandomWrite, 0, "vol", true},
+		{"forwarded append", proto.OpWrite, 0, "vol", false},
+		{"read", proto.OpStreamRead, 0, "vol", false},
+		{"create extent", proto.OpCreateExtent, 2, "vol", false},
+		{"other vol", proto.OpRandomWrite, 0, "other", false},
+	}
+	s := &DataNode{writeFences: newWriteFences()}
+	s.writeFences.set("vol", time.Minute)
+	for _, c := range cases {
+		p := repl.NewPacket()
+		p.Opcode = c.opcode
+		p.RemainingFollowers = c.followers
+		p.Object = &DataPartition{volumeID: c.vol}
+		if err := s.checkWriteFence(p); (err == proto.ErrVolWritesFenced) != c.fenced {
+			t.Errorf("%v: err %v, expected fenced(%v)", c.name, err, c.fenced)
+		}
+	}
+}
+
+func TestWriteFenceLifted(t *testing.T) {
+	cases := []struct {
+		name   string
+		ttl    int64
+		fenced bool
+	}{
+		{"fenced", 30, true},
+		{"lifted", 0, false},
+		{"expired", -1, false},
+	}
+	for _, c := range cases {
+		s := &DataNode{writeFences: newWriteFences()}
+		s.writeFences.set("vol", time.Minute)
+		task := proto.NewAdminTask(proto.OpDataNodeFenceWrites, "", &proto.FenceWritesRequest{VolName: "vol", TTL: c.ttl})
+		p := repl.NewPacket()
+		p.Opcode = proto.OpDataNodeFenceWrites
+		p.Data, _ = json.Marshal(task)
+		p.Size = uint32(len(p.Data))
+		s.handlePacketToFenceWrites(p)
+		if p.ResultCode != proto.OpOk {
+			t.Fatalf("%v: fence writes result %v", c.name, p.GetResultMsg())
+		}
+		if fenced := s.writeFences.isFenced("vol"); fenced != c.fenced {
+			t.Errorf("%v: fenced(%v), expected %v", c.name, fenced, c.fenced)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+	"github.com/cubefs/cubefs/util"
+	"golang.org/x/time/rate"
+)
+
+// writeLimiter limits the write bytes per second of the volumes on the data node,
+// the writes exceeding the limit are rejected so that the clients back off.
+type writeLimiter struct {
+	sync.RWMutex
+	limiters map[string]*rate.Limiter
+}
+
+func newWriteLimiter() *writeLimiter {
+	return &writeLimiter{limiters: make(map[string]*rate.Limiter)}
+}
+
+// setVolWriteLimits replaces the write limits of the volumes with the ones from the master.
+func (l *writeLimiter) setVolWriteLimits(volWriteLimits map[string]uint64) {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	limiters := make(map[string]*rate.Limiter, len(volWriteLimits))
+	for name, limit := range volWriteLimits {
+		if limit == 0 {
+			continue
+		}
+		burst := util.Max(int(limit), util.BlockSize)
+		if limiter, ok := l.limiters[name]; ok && limiter.Burst() == burst {
+			limiter.SetLimit(rate.Limit(limit))
+			limiters[name] = limiter
+			continue
+		}
+		limiters[name] = rate.NewLimiter(rate.Limit(limit), burst)
+	}
+	l.limiters = limiters
+}
+
+// allow returns false if the volume has used up its write limit.
+func (l *writeLimiter) allow(volName string, n int) bool {
+	if l == nil {
+		return true
+	}
+	l.RLock()
+	limiter := l.limiters[volName]
+	l.RUnlock()
+	if limiter == nil {
+		return true
+	}
+	return limiter.AllowN(time.Now(), n)
+}
+
+// checkWriteLimit rejects the writes from the clients to the volumes over their write limits.
+// The writes forwarded by the leaders have been admitted already.
+func (s *DataNode) checkWriteLimit(p *repl.Packet) (err error) {
+	if !(p.IsLeaderPacket() && p.IsWriteOperation()) && !p.IsRandomWrite() {
+		return
+	}
+	dp := p.Object.(*DataPartition)
+	if !s.writeLimiter.allow(dp.volumeID, int(p.Size)) {
+		err = proto.ErrVolWriteThrottled
+	}
+	return
+}
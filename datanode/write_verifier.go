@@ -0,0 +1,81 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The writes on a disk or a data partition in the verify-after-write mode are synced, read
+// back and compared with their CRC before they are acknowledged, so that a disk showing early
+// signs of errors can be kept online while it is watched. The mode is set through the HTTP API
+// of the data node and is not persisted.
+
+// SetVerifyWrite sets whether the writes on the disk are verified after they are written.
+func (d *Disk) SetVerifyWrite(verify bool) {
+	var value int32
+	if verify {
+		value = 1
+	}
+	atomic.StoreInt32(&d.verifyWrite, value)
+}
+
+// VerifiesWrite returns whether the writes on the disk are verified after they are written.
+func (d *Disk) VerifiesWrite() bool {
+	return atomic.LoadInt32(&d.verifyWrite) == 1
+}
+
+// SetVerifyWrite sets whether the writes on the data partition are verified after they are written.
+func (dp *DataPartition) SetVerifyWrite(verify bool) {
+	var value int32
+	if verify {
+		value = 1
+	}
+	atomic.StoreInt32(&dp.verifyWrite, value)
+}
+
+// VerifiesWrite returns whether the writes on the data partition are verified after they are
+// written, either because of the partition or because of its disk.
+func (dp *DataPartition) VerifiesWrite() bool {
+	return atomic.LoadInt32(&dp.verifyWrite) == 1 || (dp.disk != nil && dp.disk.VerifiesWrite())
+}
+
+// checkWrite reads back the data just written to the extent and compares its checksum with
+// the expected one, the mismatches are counted as write errors of the disk.
+func (dp *DataPartition) checkWrite(extentID uint64, offset, size int64, checksumType uint8, crc uint32) (err error) {
+	data := make([]byte, size)
+	for read := int64(0); read < size; {
+		n := util.Min(int(size-read), util.BlockSize)
+		if _, err = dp.ExtentStore().Read(extentID, offset+read, int64(n), data[read:read+int64(n)], false); err != nil {
+			return
+		}
+		read += int64(n)
+	}
+	if actual := proto.Checksum(checksumType, data); actual != crc {
+		dp.disk.incWriteErrCnt()
+		err = fmt.Errorf("verify write failed: partition(%v) extent(%v) offset(%v) size(%v) crc expected(%v) actual(%v)",
+			dp.partitionID, extentID, offset, size, crc, actual)
+		msg := fmt.Sprintf("disk path %v on %v: %v", dp.Path(), LocalIP, err)
+		exporter.Warning(msg)
+		log.LogError(msg)
+	}
+	return
+}
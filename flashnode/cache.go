@@ -0,0 +1,166 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package flashnode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+type fillKey struct {
+	partitionID uint64
+	extentID    uint64
+	blockNo     uint32
+}
+
+// fillTask reads a block missed from the data nodes of the partition.
+type fillTask struct {
+	fillKey
+	size  int
+	hosts []string
+}
+
+// handleCacheRead replies the range of the block cached, or OpNotExistErr if the block is missed,
+// which is read from the data nodes in the background for the next reads.
+func (node *FlashNode) handleCacheRead(p *proto.Packet) {
+	var arg proto.FlashCacheReadArg
+	if err := json.Unmarshal(p.Arg[:p.ArgLen], &arg); err != nil {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	offset, size := int(p.ExtentOffset), int(p.Size)
+	blockStart := offset / util.BlockSize * util.BlockSize
+	if offset < 0 || size <= 0 || offset+size-blockStart > arg.FillSize || arg.FillSize > util.BlockSize || len(arg.Hosts) == 0 {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(fmt.Sprintf("invalid range offset(%v) size(%v) fill(%v) hosts(%v)",
+			offset, size, arg.FillSize, arg.Hosts)))
+		return
+	}
+	key := fillKey{partitionID: p.PartitionID, extentID: p.ExtentID, blockNo: uint32(offset / util.BlockSize)}
+	block := make([]byte, util.BlockSize)
+	if !node.cache.Get(key.partitionID, key.extentID, key.blockNo, block, offset+size-blockStart) {
+		node.fill(&fillTask{fillKey: key, size: arg.FillSize, hosts: arg.Hosts})
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	p.Data = block[offset-blockStart : offset+size-blockStart]
+	p.CRC = p.Checksum(p.Data)
+	p.ResultCode = proto.OpOk
+	p.ArgLen = 0
+}
+
+// fill queues the block to read from the data nodes, unless it is queued already. The block is
+// dropped if the queue is full, and queued again by the next read missed.
+func (node *FlashNode) fill(task *fillTask) {
+	if _, loaded := node.filling.LoadOrStore(task.fillKey, struct{}{}); loaded {
+		return
+	}
+	select {
+	case node.fillC <- task:
+	default:
+		node.filling.Delete(task.fillKey)
+		log.LogDebugf("fill: queue is full, drop block(%v)", task.fillKey)
+	}
+}
+
+func (node *FlashNode) fillWorker() {
+	defer node.wg.Done()
+	for {
+		select {
+		case <-node.stopC:
+			return
+		case task := <-node.fillC:
+			var (
+				data []byte
+				err  error
+			)
+			for _, addr := range task.hosts {
+				if data, err = node.readBlock(task, addr); err == nil {
+					break
+				}
+				log.LogWarnf("fillWorker: block(%v) size(%v) addr(%v) err(%v)", task.fillKey, task.size, addr, err)
+			}
+			if err == nil {
+				node.cache.Put(task.partitionID, task.extentID, task.blockNo, data)
+			}
+			node.filling.Delete(task.fillKey)
+		}
+	}
+}
+
+// readBlock reads the block from a data node as the follower reads of the clients, whose replies
+// are checked by the CRCs.
+func (node *FlashNode) readBlock(task *fillTask, addr string) (data []byte, err error) {
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpStreamFollowerRead
+	p.ExtentType = proto.NormalExtentType
+	p.PartitionID = task.partitionID
+	p.ExtentID = task.extentID
+	p.ExtentOffset = int64(task.blockNo) * util.BlockSize
+	p.Size = uint32(task.size)
+	conn, err := node.connPool.GetConnect(addr)
+	if err != nil {
+		return
+	}
+	defer func() {
+		node.connPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	data = make([]byte, 0, task.size)
+	for len(data) < task.size {
+		reply := proto.NewPacket()
+		if err = reply.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+			return
+		}
+		if reply.ResultCode != proto.OpOk {
+			return nil, fmt.Errorf("result(%v) msg(%s)", reply.GetResultMsg(), reply.Data)
+		}
+		if reply.ReqID != p.ReqID || reply.Size == 0 || reply.CRC != p.Checksum(reply.Data) {
+			return nil, fmt.Errorf("inconsistent reply(%v) to request(%v)", reply, p)
+		}
+		data = append(data, reply.Data...)
+	}
+	if len(data) != task.size {
+		return nil, fmt.Errorf("read(%v) more than the block size(%v)", len(data), task.size)
+	}
+	return
+}
+
+// handleHeartbeat replies the usage and the counters of the cache to the heartbeat of the master.
+func (node *FlashNode) handleHeartbeat(p *proto.Packet) {
+	task := &proto.AdminTask{}
+	if err := json.Unmarshal(p.Data, task); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp := &proto.FlashNodeHeartbeatResponse{
+		ZoneName: node.zoneName,
+		Version:  proto.BuildVersion(),
+		Status:   proto.TaskSucceeds,
+	}
+	resp.Total, resp.Used = node.cache.Usage()
+	resp.Hits, resp.Misses = node.cache.Stats()
+	data, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(data)
+}
@@ -0,0 +1,297 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package flashnode
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/cmd/common"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tlsutil"
+)
+
+// Configuration items that act on the FlashNode.
+const (
+	// The port serving the reads of the clients and the heartbeats of the master.
+	configListen = proto.ListenPort
+
+	// The addresses of the masters of the cluster.
+	configMasterAddr = proto.MasterAddr
+
+	// The IP registered on the master, which is told by the master if it is not set.
+	configLocalIP = "localIP"
+
+	configZoneName = "zoneName"
+
+	// The directory on the SSD keeping the blocks cached, and its bytes used at most.
+	configCacheDir      = "cacheDir"
+	configCacheCapacity = "cacheCapacity"
+
+	// The seconds a block is cached at most, since the flash node does not see the
+	// overwrites of the data it caches.
+	configCacheExpiry = "cacheExpiry"
+
+	// The number of the blocks missed read from the data nodes at the same time.
+	configFillWorkers = "fillWorkers"
+)
+
+// ConfigSchema declares the configuration items of the FlashNode.
+var ConfigSchema = config.Schema{
+	configListen:        config.TypeString,
+	configMasterAddr:    config.TypeStringSlice,
+	configLocalIP:       config.TypeString,
+	configZoneName:      config.TypeString,
+	configCacheDir:      config.TypeString,
+	configCacheCapacity: config.TypeInt,
+	configCacheExpiry:   config.TypeInt,
+	configFillWorkers:   config.TypeInt,
+}
+
+const (
+	ModuleName = "flashNode"
+
+	defaultListen      = "17510"
+	defaultCacheExpiry = 3600
+	defaultFillWorkers = 16
+	fillQueueSize      = 1024
+	cacheDirName       = "flash" // the sub directory of cacheDir keeping the cache file
+)
+
+var (
+	regexpListen = regexp.MustCompile("^(\\d)+$")
+)
+
+// FlashNode caches the blocks of the extents read by the clients on the SSD, close to the
+// computing nodes. A client reads a block from the flash node selected by the hash of the block
+// among the ones the master tells, and from the data nodes if the block is missed, which the
+// flash node reads from the data nodes and caches in the background.
+type FlashNode struct {
+	port        int
+	localIP     string
+	localAddr   string
+	zoneName    string
+	masters     []string
+	clusterID   string
+	cacheDir    string
+	capacity    int64
+	expiry      time.Duration
+	fillWorkers int
+
+	mc       *master.MasterClient
+	cache    *stream.ReadCache
+	connPool *util.ConnectPool // connections to the data nodes
+	fillC    chan *fillTask
+	filling  sync.Map // fillKey -> struct{}, the blocks queued or being read
+
+	listener net.Listener
+	stopC    chan struct{}
+	wg       sync.WaitGroup
+
+	control common.Control
+}
+
+func NewServer() *FlashNode {
+	return &FlashNode{}
+}
+
+func (node *FlashNode) Start(cfg *config.Config) (err error) {
+	return node.control.Start(node, cfg, handleStart)
+}
+
+func (node *FlashNode) Shutdown() {
+	node.control.Shutdown(node, handleShutdown)
+}
+
+func (node *FlashNode) Sync() {
+	node.control.Sync()
+}
+
+func (node *FlashNode) loadConfig(cfg *config.Config) (err error) {
+	listen := cfg.GetString(configListen)
+	if len(listen) == 0 {
+		listen = defaultListen
+	}
+	if !regexpListen.MatchString(listen) {
+		return errors.New("invalid listen configuration")
+	}
+	node.port, _ = strconv.Atoi(listen)
+
+	if node.masters = cfg.GetStringSlice(configMasterAddr); len(node.masters) == 0 {
+		return config.NewIllegalConfigError(configMasterAddr)
+	}
+	node.localIP = cfg.GetString(configLocalIP)
+	if node.zoneName = cfg.GetString(configZoneName); node.zoneName == "" {
+		node.zoneName = proto.DefaultZoneName
+	}
+	if node.cacheDir = cfg.GetString(configCacheDir); node.cacheDir == "" {
+		return config.NewIllegalConfigError(configCacheDir)
+	}
+	if node.capacity = cfg.GetInt64(configCacheCapacity); node.capacity <= 0 {
+		return config.NewIllegalConfigError(configCacheCapacity)
+	}
+	expiry := cfg.GetInt64(configCacheExpiry)
+	if expiry <= 0 {
+		expiry = defaultCacheExpiry
+	}
+	node.expiry = time.Duration(expiry) * time.Second
+	if node.fillWorkers = int(cfg.GetInt64(configFillWorkers)); node.fillWorkers <= 0 {
+		node.fillWorkers = defaultFillWorkers
+	}
+	return
+}
+
+func handleStart(s common.Server, cfg *config.Config) (err error) {
+	node, ok := s.(*FlashNode)
+	if !ok {
+		return errors.New("Invalid Node Type!")
+	}
+	if err = node.loadConfig(cfg); err != nil {
+		return
+	}
+	node.stopC = make(chan struct{})
+	node.fillC = make(chan *fillTask, fillQueueSize)
+	node.connPool = util.NewConnectPool()
+	if node.cache, err = stream.NewReadCache(node.cacheDir, cacheDirName, node.capacity); err != nil {
+		return
+	}
+	node.cache.SetExpiry(node.expiry)
+	node.mc = master.NewMasterClient(node.masters, false)
+	defer func() {
+		if err != nil {
+			node.mc.Stop()
+			node.cache.Close()
+		}
+	}()
+
+	if node.listener, err = tlsutil.Listen(":" + strconv.Itoa(node.port)); err != nil {
+		return
+	}
+	go node.serve(node.listener)
+	if err = node.register(); err != nil {
+		node.listener.Close()
+		return
+	}
+	for i := 0; i < node.fillWorkers; i++ {
+		node.wg.Add(1)
+		go node.fillWorker()
+	}
+
+	exporter.Init(ModuleName, cfg)
+	exporter.RegistConsul(node.clusterID, ModuleName, cfg)
+	log.LogInfof("flash node start success, addr(%v) cacheDir(%v) capacity(%v) expiry(%v)",
+		node.localAddr, node.cacheDir, node.capacity, node.expiry)
+	return
+}
+
+func handleShutdown(s common.Server) {
+	node, ok := s.(*FlashNode)
+	if !ok {
+		return
+	}
+	node.listener.Close()
+	close(node.stopC)
+	node.wg.Wait()
+	node.mc.Stop()
+	node.connPool.Close()
+	node.cache.Close()
+}
+
+// register adds the flash node to the master, it retries until the master replies or the
+// flash node is stopped.
+func (node *FlashNode) register() (err error) {
+	for {
+		if err = node.tryRegister(); err == nil {
+			return
+		}
+		log.LogErrorf("register: masters(%v) err(%v), retry later", node.masters, err)
+		select {
+		case <-node.stopC:
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (node *FlashNode) tryRegister() (err error) {
+	ci, err := node.mc.AdminAPI().GetClusterInfo()
+	if err != nil {
+		return
+	}
+	node.clusterID = ci.Cluster
+	localIP := node.localIP
+	if localIP == "" {
+		localIP = ci.Ip
+	}
+	if !util.IsIPV4(localIP) {
+		return fmt.Errorf("invalid local ip(%v)", localIP)
+	}
+	node.localAddr = fmt.Sprintf("%s:%v", localIP, node.port)
+	id, err := node.mc.NodeAPI().AddFlashNode(node.localAddr, node.zoneName)
+	if err != nil {
+		return
+	}
+	log.LogInfof("register: flash node(%v) id(%v) zone(%v) cluster(%v)", node.localAddr, id, node.zoneName, node.clusterID)
+	return
+}
+
+func (node *FlashNode) serve(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			log.LogWarnf("serve: accept on(%v) err(%v)", l.Addr(), err)
+			return
+		}
+		go node.serveConn(c)
+	}
+}
+
+func (node *FlashNode) serveConn(c net.Conn) {
+	defer c.Close()
+	if tc := tlsutil.TCPConn(c); tc != nil {
+		tc.SetKeepAlive(true)
+		tc.SetNoDelay(true)
+	}
+	for {
+		p := proto.NewPacket()
+		if err := p.ReadFromConn(c, proto.NoReadDeadlineTime); err != nil {
+			log.LogDebugf("serveConn: remote(%v) err(%v)", c.RemoteAddr(), err)
+			return
+		}
+		switch p.Opcode {
+		case proto.OpFlashCacheRead:
+			node.handleCacheRead(p)
+		case proto.OpFlashNodeHeartbeat:
+			node.handleHeartbeat(p)
+		default:
+			p.PacketErrorWithBody(proto.OpErr, []byte(fmt.Sprintf("unknown opcode(%v)", p.Opcode)))
+		}
+		if err := p.WriteToConn(c); err != nil {
+			log.LogWarnf("serveConn: remote(%v) reply(%v) err(%v)", c.RemoteAddr(), p, err)
+			return
+		}
+	}
+}
@@ -44,6 +44,7 @@ func newCheckCmd() *cobra.Command {
 		newCheckInodeCmd(),
 		newCheckDentryCmd(),
 		newCheckBothCmd(),
+		newCheckExtentCmd(),
 	)
 
 	return c
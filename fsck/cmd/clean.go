@@ -45,6 +45,7 @@ func newCleanCmd() *cobra.Command {
 		newCleanInodeCmd(),
 		newCleanDentryCmd(),
 		newEvictInodeCmd(),
+		newCleanExtentCmd(),
 	)
 
 	return c
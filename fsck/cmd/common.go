@@ -24,6 +24,7 @@ var (
 	InodesFile string
 	DensFile   string
 	MetaPort   string
+	DataPort   string
 )
 
 var (
@@ -32,6 +33,7 @@ var (
 	inodeUpdateDumpFileName    string = "inode.dump.update"
 	obsoleteInodeDumpFileName  string = "inode.dump.obsolete"
 	obsoleteDentryDumpFileName string = "dentry.dump.obsolete"
+	extentPlanFileName         string = "extent.plan"
 )
 
 type Inode struct {
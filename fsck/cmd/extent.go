@@ -0,0 +1,527 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+)
+
+// actions of the extent repair plan
+const (
+	// ActionRepairReplica repairs the replicas of a data partition from the intact ones by the master
+	ActionRepairReplica = "repairReplica"
+	// ActionDeleteExtent deletes an extent referenced by no inode on all the replicas
+	ActionDeleteExtent = "deleteExtent"
+	// ActionLostData reports the inodes whose data is lost on all the replicas, which cannot be repaired
+	ActionLostData = "lostData"
+)
+
+// RepairAction is an action of the extent repair plan.
+type RepairAction struct {
+	Action      string   `json:"action"`
+	PartitionID uint64   `json:"partitionID"`
+	ExtentID    uint64   `json:"extentID,omitempty"`
+	Hosts       []string `json:"hosts,omitempty"`
+	Inodes      []uint64 `json:"inodes,omitempty"`
+	Reason      string   `json:"reason"`
+	API         string   `json:"api,omitempty"`
+}
+
+func (a *RepairAction) String() string {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// extentRef is the range of an extent referenced by the inodes.
+type extentRef struct {
+	end        uint64
+	inodes     []uint64
+	modifyTime int64
+}
+
+// extentReplica is the extent reported by a replica of the data partition.
+type extentReplica struct {
+	host string
+	info *storage.ExtentInfo
+}
+
+func newCheckExtentCmd() *cobra.Command {
+	var grace time.Duration
+	var c = &cobra.Command{
+		Use:   "extent",
+		Short: "check the extents referenced by the meta partitions against the data nodes",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := CheckExtent(grace); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	c.Flags().DurationVar(&grace, "grace", 24*time.Hour, "skip the extents and the inodes modified within the grace period")
+	return c
+}
+
+func newCleanExtentCmd() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "extent",
+		Short: "apply the extent repair plan made by check extent",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := CleanExtent(); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	return c
+}
+
+// CheckExtent scans the extents of the volume on the data nodes and the extents referenced by the inodes,
+// and writes the repair plan of the dangling extents, the missing extents and the short extents.
+func CheckExtent(grace time.Duration) (err error) {
+	if MasterAddr == "" || VolName == "" || MetaPort == "" || DataPort == "" {
+		return fmt.Errorf("Lack of mandatory args: master(%v) vol(%v) mport(%v) dport(%v)", MasterAddr, VolName, MetaPort, DataPort)
+	}
+	dirPath := fmt.Sprintf("_export_%s", VolName)
+	if err = os.MkdirAll(dirPath, 0755); err != nil {
+		return
+	}
+	deadline := time.Now().Add(-grace).Unix()
+
+	/*
+	 * Scan the data nodes before the meta nodes, so that the extents written during
+	 * the scan are referenced by the inodes rather than reported as dangling.
+	 */
+	dps, err := getDataPartitions(MasterAddr, VolName)
+	if err != nil {
+		return
+	}
+	replicas, err := exportExtents(dps)
+	if err != nil {
+		return
+	}
+	refs, err := exportExtentRefs(deadline)
+	if err != nil {
+		return
+	}
+
+	plan := makeRepairPlan(dps, replicas, refs, deadline)
+	fp, err := os.Create(fmt.Sprintf("%s/%s", dirPath, extentPlanFileName))
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	counts := make(map[string]int)
+	for _, action := range plan {
+		if _, err = fp.WriteString(action.String() + "\n"); err != nil {
+			return
+		}
+		counts[action.Action]++
+	}
+	fmt.Printf("Data partitions: %v\nReferenced extents: %v\nReplicas to repair: %v\nDangling extents: %v\nLost data: %v\nRepair plan: %v/%v\n",
+		len(dps), countExtentRefs(refs), counts[ActionRepairReplica], counts[ActionDeleteExtent], counts[ActionLostData], dirPath, extentPlanFileName)
+	return
+}
+
+func getDataPartitions(addr, name string) ([]*proto.DataPartitionResponse, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s%s?name=%s", addr, proto.ClientDataPartitions, name))
+	if err != nil {
+		return nil, fmt.Errorf("Get data partitions failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Invalid status code: %v", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Get data partitions read all body failed: %v", err)
+	}
+
+	body := &struct {
+		Code int32           `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err = json.Unmarshal(data, body); err != nil {
+		return nil, fmt.Errorf("Unmarshal data partitions body failed: %v", err)
+	}
+
+	view := &proto.DataPartitionsView{}
+	if err = json.Unmarshal(body.Data, view); err != nil {
+		return nil, fmt.Errorf("Unmarshal data partitions view failed: %v", err)
+	}
+	return view.DataPartitions, nil
+}
+
+// exportExtents gets the extents of every replica of the data partitions from the prof port of the data nodes.
+func exportExtents(dps []*proto.DataPartitionResponse) (replicas map[uint64]map[uint64][]*extentReplica, err error) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []string
+	)
+	replicas = make(map[uint64]map[uint64][]*extentReplica)
+	for _, dp := range dps {
+		extents := make(map[uint64][]*extentReplica)
+		replicas[dp.PartitionID] = extents
+		for _, host := range dp.Hosts {
+			wg.Add(1)
+			go func(pid uint64, host string) {
+				defer wg.Done()
+				infos, e := getPartitionExtents(host, pid)
+				mu.Lock()
+				defer mu.Unlock()
+				if e != nil {
+					errs = append(errs, e.Error())
+					return
+				}
+				for _, info := range infos {
+					extents[info.FileID] = append(extents[info.FileID], &extentReplica{host: host, info: info})
+				}
+			}(dp.PartitionID, host)
+		}
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		// a replica failed to export would be reported as missing all its extents
+		return nil, fmt.Errorf("Export extents failed:\n%v", strings.Join(errs, "\n"))
+	}
+	return
+}
+
+func getPartitionExtents(host string, pid uint64) ([]*storage.ExtentInfo, error) {
+	cmdline := fmt.Sprintf("http://%s:%s/partition?id=%d", strings.Split(host, ":")[0], DataPort, pid)
+	resp, err := http.Get(cmdline)
+	if err != nil {
+		return nil, fmt.Errorf("Get request failed: %v %v", cmdline, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Invalid status code: %v %v", cmdline, resp.StatusCode)
+	}
+
+	body := &struct {
+		Code int32 `json:"code"`
+		Msg  string
+		Data struct {
+			Extents []*storage.ExtentInfo `json:"extents"`
+		} `json:"data"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return nil, fmt.Errorf("Decode extents failed: %v %v", cmdline, err)
+	}
+	return body.Data.Extents, nil
+}
+
+// exportExtentRefs gets the extents referenced by the regular inodes from the prof port of the meta nodes.
+// The extents of the inodes modified after the deadline are also referenced, but not checked.
+func exportExtentRefs(deadline int64) (refs map[uint64]map[uint64]*extentRef, err error) {
+	mps, err := getMetaPartitions(MasterAddr, VolName)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []string
+	)
+	refs = make(map[uint64]map[uint64]*extentRef)
+	addRef := func(inode *Inode, ek *proto.ExtentKey) {
+		mu.Lock()
+		defer mu.Unlock()
+		extents, ok := refs[ek.PartitionId]
+		if !ok {
+			extents = make(map[uint64]*extentRef)
+			refs[ek.PartitionId] = extents
+		}
+		ref, ok := extents[ek.ExtentId]
+		if !ok {
+			ref = &extentRef{}
+			extents[ek.ExtentId] = ref
+		}
+		if end := ek.ExtentOffset + uint64(ek.Size); end > ref.end {
+			ref.end = end
+		}
+		if inode.ModifyTime > ref.modifyTime {
+			ref.modifyTime = inode.ModifyTime
+		}
+		ref.inodes = append(ref.inodes, inode.Inode)
+	}
+
+	for _, mp := range mps {
+		wg.Add(1)
+		go func(mp *proto.MetaPartitionView) {
+			defer wg.Done()
+			if e := exportPartitionExtentRefs(mp, addRef); e != nil {
+				mu.Lock()
+				errs = append(errs, e.Error())
+				mu.Unlock()
+			}
+		}(mp)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		// the extents of a meta partition failed to export would be reported as dangling
+		return nil, fmt.Errorf("Export extent references failed:\n%v", strings.Join(errs, "\n"))
+	}
+	return
+}
+
+func exportPartitionExtentRefs(mp *proto.MetaPartitionView, addRef func(inode *Inode, ek *proto.ExtentKey)) error {
+	host := strings.Split(mp.LeaderAddr, ":")[0]
+	cmdline := fmt.Sprintf("http://%s:%s/getAllInodes?pid=%d", host, MetaPort, mp.PartitionID)
+	resp, err := http.Get(cmdline)
+	if err != nil {
+		return fmt.Errorf("Get request failed: %v %v", cmdline, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Invalid status code: %v %v", cmdline, resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		inode := &Inode{}
+		if err = dec.Decode(inode); err != nil {
+			return fmt.Errorf("Decode inode failed: %v %v", cmdline, err)
+		}
+		if !proto.IsRegular(inode.Type) {
+			continue
+		}
+		extents, err := getInodeExtents(host, mp.PartitionID, inode.Inode)
+		if err != nil {
+			return err
+		}
+		for i := range extents {
+			addRef(inode, &extents[i])
+		}
+	}
+	return nil
+}
+
+func getInodeExtents(host string, pid, ino uint64) ([]proto.ExtentKey, error) {
+	cmdline := fmt.Sprintf("http://%s:%s/getExtentsByInode?pid=%d&ino=%d", host, MetaPort, pid, ino)
+	resp, err := http.Get(cmdline)
+	if err != nil {
+		return nil, fmt.Errorf("Get request failed: %v %v", cmdline, err)
+	}
+	defer resp.Body.Close()
+
+	body := &struct {
+		Code int                       `json:"code"`
+		Msg  string                    `json:"msg"`
+		Data *proto.GetExtentsResponse `json:"data"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return nil, fmt.Errorf("Decode extents failed: %v %v", cmdline, err)
+	}
+	// the extents are replied with StatusSeeOther by the meta node
+	if body.Code != http.StatusSeeOther {
+		return nil, fmt.Errorf("Get extents failed: %v code(%v) msg(%v)", cmdline, body.Code, body.Msg)
+	}
+	if body.Data == nil {
+		return nil, nil
+	}
+	return body.Data.Extents, nil
+}
+
+// makeRepairPlan compares the referenced extents with the replicas. The replicas of a data partition are repaired if
+// some of them miss or are shorter than a referenced extent, the inodes are reported as lost if all of them do,
+// and the normal extents referenced by no inode are deleted. The extents modified after the deadline are skipped.
+func makeRepairPlan(dps []*proto.DataPartitionResponse, replicas map[uint64]map[uint64][]*extentReplica,
+	refs map[uint64]map[uint64]*extentRef, deadline int64) (plan []*RepairAction) {
+	for _, dp := range dps {
+		var (
+			extents = replicas[dp.PartitionID]
+			broken  []string
+		)
+		for extentID, ref := range refs[dp.PartitionID] {
+			if ref.modifyTime > deadline {
+				continue
+			}
+			var intact int
+			for _, replica := range extents[extentID] {
+				if !replica.info.IsDeleted && replica.info.Size >= ref.end {
+					intact++
+				}
+			}
+			switch {
+			case intact == 0:
+				plan = append(plan, &RepairAction{
+					Action:      ActionLostData,
+					PartitionID: dp.PartitionID,
+					ExtentID:    extentID,
+					Inodes:      ref.inodes,
+					Reason:      fmt.Sprintf("no replica holds the referenced range [0, %v)", ref.end),
+				})
+			case intact < len(dp.Hosts):
+				broken = append(broken, fmt.Sprintf("extent(%v) intact on %v/%v replicas", extentID, intact, len(dp.Hosts)))
+			}
+		}
+		for extentID, ers := range extents {
+			if _, ok := refs[dp.PartitionID][extentID]; ok || storage.IsTinyExtent(extentID) {
+				continue
+			}
+			var live, recent bool
+			for _, replica := range ers {
+				live = live || !replica.info.IsDeleted
+				recent = recent || replica.info.ModifyTime > deadline
+			}
+			if !live || recent {
+				continue
+			}
+			plan = append(plan, &RepairAction{
+				Action:      ActionDeleteExtent,
+				PartitionID: dp.PartitionID,
+				ExtentID:    extentID,
+				Hosts:       dp.Hosts,
+				Reason:      "referenced by no inode",
+				API:         "OpMarkDelete to " + dp.Hosts[0],
+			})
+		}
+		if len(broken) > 0 {
+			sort.Strings(broken)
+			plan = append(plan, &RepairAction{
+				Action:      ActionRepairReplica,
+				PartitionID: dp.PartitionID,
+				Reason:      strings.Join(broken, ", "),
+				API:         fmt.Sprintf("%v?id=%v&repair=true", proto.AdminCheckDataPartition, dp.PartitionID),
+			})
+		}
+	}
+	sort.SliceStable(plan, func(i, j int) bool {
+		if plan[i].PartitionID != plan[j].PartitionID {
+			return plan[i].PartitionID < plan[j].PartitionID
+		}
+		return plan[i].ExtentID < plan[j].ExtentID
+	})
+	return
+}
+
+func countExtentRefs(refs map[uint64]map[uint64]*extentRef) (count int) {
+	for _, extents := range refs {
+		count += len(extents)
+	}
+	return
+}
+
+// CleanExtent applies the extent repair plan. The replicas are repaired by the master, and the dangling extents
+// are deleted through the leader of the data partition as the meta nodes do. The lost data is only reported.
+func CleanExtent() (err error) {
+	if MasterAddr == "" || VolName == "" {
+		return fmt.Errorf("Lack of parameters: master(%v) vol(%v)", MasterAddr, VolName)
+	}
+	fp, err := os.Open(fmt.Sprintf("_export_%s/%s", VolName, extentPlanFileName))
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+
+	var applied, failed int
+	dec := json.NewDecoder(fp)
+	for dec.More() {
+		action := &RepairAction{}
+		if err = dec.Decode(action); err != nil {
+			return
+		}
+		var e error
+		switch action.Action {
+		case ActionRepairReplica:
+			e = repairReplica(action)
+		case ActionDeleteExtent:
+			e = deleteExtent(action)
+		default:
+			fmt.Printf("Skip: %v\n", action)
+			continue
+		}
+		if e != nil {
+			failed++
+			fmt.Printf("Failed: %v: %v\n", action, e)
+			continue
+		}
+		applied++
+		fmt.Printf("Done: %v\n", action)
+	}
+	fmt.Printf("Applied: %v\nFailed: %v\n", applied, failed)
+	return
+}
+
+func repairReplica(action *RepairAction) error {
+	cmdline := fmt.Sprintf("http://%s%s?id=%d&repair=true", MasterAddr, proto.AdminCheckDataPartition, action.PartitionID)
+	resp, err := http.Get(cmdline)
+	if err != nil {
+		return fmt.Errorf("Get request failed: %v %v", cmdline, err)
+	}
+	defer resp.Body.Close()
+
+	body := &proto.HTTPReply{}
+	if err = json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return fmt.Errorf("Decode reply failed: %v %v", cmdline, err)
+	}
+	if body.Code != proto.ErrCodeSuccess {
+		return fmt.Errorf("Repair failed: %v", body.Msg)
+	}
+	return nil
+}
+
+func deleteExtent(action *RepairAction) error {
+	if len(action.Hosts) == 0 || storage.IsTinyExtent(action.ExtentID) {
+		return fmt.Errorf("Invalid action")
+	}
+	conn, err := net.DialTimeout("tcp", action.Hosts[0], time.Duration(proto.ReadDeadlineTime)*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	p := proto.NewPacket()
+	p.Opcode = proto.OpMarkDelete
+	p.ExtentType = proto.NormalExtentType
+	p.PartitionID = action.PartitionID
+	p.ExtentID = action.ExtentID
+	p.ReqID = proto.GenerateRequestID()
+	p.RemainingFollowers = uint8(len(action.Hosts) - 1)
+	p.Arg = []byte(strings.Join(action.Hosts[1:], proto.AddrSplit) + proto.AddrSplit)
+	p.ArgLen = uint32(len(p.Arg))
+	if err = p.WriteToConn(conn); err != nil {
+		return err
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		return err
+	}
+	if p.ResultCode != proto.OpOk {
+		return fmt.Errorf("%v", p.GetResultMsg())
+	}
+	return nil
+}
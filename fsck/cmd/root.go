@@ -47,6 +47,7 @@ func NewRootCmd() *cobra.Command {
 	c.PersistentFlags().StringVarP(&InodesFile, "inode-list", "i", "", "inode list file")
 	c.PersistentFlags().StringVarP(&DensFile, "dentry-list", "d", "", "dentry list file")
 	c.PersistentFlags().StringVarP(&MetaPort, "mport", "", "", "prof port of metanode")
+	c.PersistentFlags().StringVarP(&DataPort, "dport", "", "", "prof port of datanode")
 	c.Flags().BoolVarP(&optShowVersion, "version", "v", false, "Show version information")
 	return c
 }
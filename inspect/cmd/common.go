@@ -0,0 +1,141 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	raftproto "github.com/tiglabs/raft/proto"
+)
+
+// decoders of the log entries and the values
+const (
+	DecoderRaw    = "raw"
+	DecoderMaster = "master"
+	DecoderMeta   = "meta"
+)
+
+var (
+	Decoder     string
+	MaxValueLen int
+)
+
+// masterCmd is the log entry of the master, as the RaftCmd of the master package.
+type masterCmd struct {
+	Op uint32 `json:"op"`
+	K  string `json:"k"`
+	V  []byte `json:"v"`
+}
+
+// metaItem is the log entry of the meta partition, as the MetaItem of the metanode package.
+type metaItem struct {
+	Op uint32 `json:"op"`
+	K  []byte `json:"k"`
+	V  []byte `json:"v"`
+}
+
+func checkDecoder() error {
+	switch Decoder {
+	case DecoderRaw, DecoderMaster, DecoderMeta:
+		return nil
+	}
+	return fmt.Errorf("Invalid decoder: %v", Decoder)
+}
+
+// formatValue returns the value as the text if it is printable, or in hex otherwise, truncated to MaxValueLen.
+func formatValue(value []byte) string {
+	var suffix string
+	if MaxValueLen > 0 && len(value) > MaxValueLen {
+		suffix = fmt.Sprintf("...(%d bytes)", len(value))
+		value = value[:MaxValueLen]
+	}
+	if isPrintable(value) {
+		return string(value) + suffix
+	}
+	return "0x" + hex.EncodeToString(value) + suffix
+}
+
+func isPrintable(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+	for _, r := range string(value) {
+		if !unicode.IsPrint(r) && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatEntryData returns the data of the log entry decoded by the decoder.
+func formatEntryData(typ raftproto.EntryType, data []byte) string {
+	if len(data) == 0 {
+		return "(empty)"
+	}
+	if typ == raftproto.EntryConfChange {
+		return formatConfChange(data)
+	}
+	switch Decoder {
+	case DecoderMaster:
+		cmd := &masterCmd{}
+		if err := json.Unmarshal(data, cmd); err != nil {
+			return fmt.Sprintf("(undecodable: %v) %v", err, formatValue(data))
+		}
+		return fmt.Sprintf("op(%v) key(%v) value(%v)", cmd.Op, cmd.K, formatValue(cmd.V))
+	case DecoderMeta:
+		item := &metaItem{}
+		if err := json.Unmarshal(data, item); err != nil {
+			return fmt.Sprintf("(undecodable: %v) %v", err, formatValue(data))
+		}
+		return fmt.Sprintf("op(%v) key(%v) value(%v)", item.Op, formatValue(item.K), formatValue(item.V))
+	}
+	return formatValue(data)
+}
+
+// confChangeMinSize is the size of the conf change type and the peer.
+const confChangeMinSize = 12
+
+func formatConfChange(data []byte) (s string) {
+	if len(data) < confChangeMinSize {
+		return fmt.Sprintf("(undecodable conf change) %v", formatValue(data))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s = fmt.Sprintf("(undecodable conf change: %v) %v", r, formatValue(data))
+		}
+	}()
+	cc := &raftproto.ConfChange{}
+	cc.Decode(data)
+	s = fmt.Sprintf("%v peer(%v)", cc.Type, formatPeer(cc.Peer))
+	for _, change := range cc.Changes {
+		s += fmt.Sprintf(" [%v peer(%v)]", change.Type, formatPeer(change.Peer))
+	}
+	if len(cc.Context) > 0 {
+		s += fmt.Sprintf(" context(%v)", formatValue(cc.Context))
+	}
+	return
+}
+
+func formatPeer(peer raftproto.Peer) string {
+	s := fmt.Sprintf("nodeID:%v priority:%v", peer.ID, peer.Priority)
+	if peer.Type == raftproto.PeerWitness {
+		s += " witness"
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/raftstore"
+)
+
+func newRocksDBCmd() *cobra.Command {
+	var prefix string
+	var keysOnly bool
+	var c = &cobra.Command{
+		Use:   "rocksdb [STORE DIR]",
+		Short: "dump the keys and the values of the RocksDB store, such as the store of the master",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := DumpRocksDB(args[0], prefix, keysOnly); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	c.Flags().StringVarP(&prefix, "prefix", "p", "", "dump the keys having the prefix only, such as #vol#")
+	c.Flags().BoolVarP(&keysOnly, "keys-only", "k", false, "dump the keys without the values")
+	return c
+}
+
+// DumpRocksDB prints the keys having the prefix in the RocksDB store in order, and their values unless keysOnly.
+// The store is opened read-only, so it is safe to dump the store of the stopped master.
+func DumpRocksDB(dir, prefix string, keysOnly bool) (err error) {
+	store, err := raftstore.OpenRocksDBStoreReadOnly(dir)
+	if err != nil {
+		return
+	}
+	defer store.Close()
+
+	var count int
+	err = store.Range([]byte(prefix), func(key, value []byte) bool {
+		count++
+		if keysOnly {
+			fmt.Printf("%v\n", formatValue(key))
+		} else {
+			fmt.Printf("%v = %v\n", formatValue(key), formatValue(value))
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("Iterate store failed: %v", err)
+	}
+	fmt.Printf("Total: %v keys\n", count)
+	return
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func NewRootCmd() *cobra.Command {
+	var optShowVersion bool
+	var c = &cobra.Command{
+		Use:   path.Base(os.Args[0]),
+		Short: "ChubaoFS offline raft WAL, snapshot and RocksDB inspection tool",
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if optShowVersion {
+				_, _ = fmt.Fprint(os.Stdout, proto.DumpVersion("INSPECT"))
+				return
+			}
+		},
+	}
+
+	c.AddCommand(
+		newWalCmd(),
+		newSnapshotCmd(),
+		newRocksDBCmd(),
+	)
+
+	c.PersistentFlags().StringVarP(&Decoder, "decode", "d", DecoderRaw, "decoder of the log entries and the values, one of raw, master and meta")
+	c.PersistentFlags().IntVarP(&MaxValueLen, "max-value-len", "", 256, "bytes of each value printed at most, 0 is unlimited")
+	c.Flags().BoolVarP(&optShowVersion, "version", "v", false, "Show version information")
+	return c
+}
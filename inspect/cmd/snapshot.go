@@ -0,0 +1,158 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tiglabs/raft/storage/wal"
+
+	"github.com/cubefs/cubefs/raftstore"
+)
+
+// The files of the snapshot metadata, as they are named by the master, the metanode and the datanode.
+const (
+	walMetaFile         = "META"
+	rocksDBCurrentFile  = "CURRENT"
+	masterAppliedKey    = "applied"
+	metaPartitionMeta   = "meta"
+	metaPartitionApply  = "apply"
+	metaSnapshotDir     = "snapshot"
+	metaSnapshotSign    = ".sign"
+	dataPartitionApply  = "APPLY"
+	dataPartitionWalDir = "wal_"
+)
+
+// metaSnapshotFiles are the files of the meta partition snapshot in the order of their CRCs in the sign file.
+var metaSnapshotFiles = []string{"inode", "dentry", "extend", "multipart", "object_version", "subtree_snapshot"}
+
+func newSnapshotCmd() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "snapshot [DIR]",
+		Short: "show the snapshot metadata of the WAL, the master store, the meta partition or the data partition in the dir",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := ShowSnapshot(args[0]); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	return c
+}
+
+// ShowSnapshot prints the snapshot metadata found in the dir, which are the truncation of the WAL, the applied
+// index of the master store, the metadata, the applied index and the snapshot files of the meta partition, and
+// the metadata, the applied index and the WAL truncations of the data partition.
+func ShowSnapshot(dir string) (err error) {
+	var found bool
+	if data, e := ioutil.ReadFile(path.Join(dir, walMetaFile)); e == nil {
+		found = true
+		if bytes.HasPrefix(data, []byte("{")) {
+			fmt.Printf("Data partition metadata: %s\n", bytes.TrimSpace(data))
+		} else if err = showWalMeta(dir); err != nil {
+			return
+		}
+	}
+	if _, e := os.Stat(path.Join(dir, rocksDBCurrentFile)); e == nil {
+		found = true
+		if err = showMasterApplied(dir); err != nil {
+			return
+		}
+	}
+	if data, e := ioutil.ReadFile(path.Join(dir, metaPartitionMeta)); e == nil {
+		found = true
+		fmt.Printf("Meta partition metadata: %s\n", bytes.TrimSpace(data))
+	}
+	if data, e := ioutil.ReadFile(path.Join(dir, metaPartitionApply)); e == nil {
+		found = true
+		fmt.Printf("Meta partition applied (applyID|cursor): %s\n", bytes.TrimSpace(data))
+	}
+	if _, e := os.Stat(path.Join(dir, metaSnapshotDir)); e == nil {
+		found = true
+		if err = showMetaSnapshot(path.Join(dir, metaSnapshotDir)); err != nil {
+			return
+		}
+	}
+	if data, e := ioutil.ReadFile(path.Join(dir, dataPartitionApply)); e == nil {
+		found = true
+		fmt.Printf("Data partition applied: %s\n", bytes.TrimSpace(data))
+	}
+	infos, _ := ioutil.ReadDir(dir)
+	for _, info := range infos {
+		if info.IsDir() && strings.HasPrefix(info.Name(), dataPartitionWalDir) {
+			found = true
+			if err = showWalMeta(path.Join(dir, info.Name())); err != nil {
+				return
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("No snapshot metadata found in %v", dir)
+	}
+	return
+}
+
+func showWalMeta(dir string) error {
+	hs, truncIndex, truncTerm, err := wal.ReadMeta(dir)
+	if err != nil {
+		return fmt.Errorf("Read META of %v failed: %v", dir, err)
+	}
+	fmt.Printf("WAL %v: truncated index(%v) term(%v), commit(%v) term(%v)\n", dir, truncIndex, truncTerm, hs.Commit, hs.Term)
+	return nil
+}
+
+func showMasterApplied(dir string) error {
+	store, err := raftstore.OpenRocksDBStoreReadOnly(dir)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	value, err := store.Get(masterAppliedKey)
+	if err != nil {
+		return fmt.Errorf("Get applied index failed: %v", err)
+	}
+	fmt.Printf("Master store applied: %s\n", value.([]byte))
+	return nil
+}
+
+func showMetaSnapshot(dir string) error {
+	fmt.Printf("Meta partition snapshot %v:\n", dir)
+	var crcs []string
+	if data, err := ioutil.ReadFile(path.Join(dir, metaSnapshotSign)); err == nil {
+		crcs = strings.Fields(string(data))
+	}
+	for i, name := range metaSnapshotFiles {
+		var crc = "-"
+		if i < len(crcs) {
+			crc = crcs[i]
+		}
+		info, err := os.Stat(path.Join(dir, name))
+		if err != nil {
+			fmt.Printf("  %v: missing, crc(%v)\n", name, crc)
+			continue
+		}
+		fmt.Printf("  %v: size(%v) modified(%v) crc(%v)\n", name, info.Size(), info.ModTime().Format("2006-01-02 15:04:05"), crc)
+	}
+	if data, err := ioutil.ReadFile(path.Join(dir, metaPartitionApply)); err == nil {
+		fmt.Printf("  applied (applyID|cursor): %s\n", bytes.TrimSpace(data))
+	}
+	return nil
+}
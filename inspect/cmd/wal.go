@@ -0,0 +1,105 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"path"
+
+	"github.com/spf13/cobra"
+	raftproto "github.com/tiglabs/raft/proto"
+	"github.com/tiglabs/raft/storage/wal"
+)
+
+func newWalCmd() *cobra.Command {
+	var from, to uint64
+	var c = &cobra.Command{
+		Use:   "wal [WAL DIR]",
+		Short: "dump the hard state and the log entries of the raft WAL",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := DumpWal(args[0], from, to); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	c.Flags().Uint64VarP(&from, "from", "", 0, "first index of the log entries dumped")
+	c.Flags().Uint64VarP(&to, "to", "", math.MaxUint64, "last index of the log entries dumped")
+	return c
+}
+
+// DumpWal prints the hard state, the truncation and the log files of the WAL in the dir, and the log entries
+// between from and to. It stops at the first corrupt record, and reports the gaps of the indexes on the way.
+func DumpWal(dir string, from, to uint64) (err error) {
+	if err = checkDecoder(); err != nil {
+		return
+	}
+	hs, truncIndex, truncTerm, err := wal.ReadMeta(dir)
+	if err != nil {
+		return fmt.Errorf("Read META failed: %v", err)
+	}
+	files, err := wal.ListLogFiles(dir)
+	if err != nil {
+		return fmt.Errorf("List log files failed: %v", err)
+	}
+	fmt.Printf("WAL: %v\n", dir)
+	fmt.Printf("HardState: term(%v) commit(%v) vote(%v)\n", hs.Term, hs.Commit, hs.Vote)
+	fmt.Printf("Truncated: index(%v) term(%v)\n", truncIndex, truncTerm)
+	fmt.Printf("Log files:\n")
+	for _, file := range files {
+		fmt.Printf("  %v seq(%v) firstIndex(%v)\n", file.Name, file.Seq, file.FirstIndex)
+	}
+
+	fmt.Printf("Entries:\n")
+	var count int
+	var last uint64
+	for i, file := range files {
+		if i+1 < len(files) && files[i+1].FirstIndex <= from {
+			continue
+		}
+		if file.FirstIndex > to {
+			break
+		}
+		var tornOffset int64
+		tornOffset, err = wal.ReadLogFile(path.Join(dir, file.Name), func(ent *raftproto.Entry, offset int64) bool {
+			if last != 0 && ent.Index != last+1 {
+				fmt.Printf("  (index gap: %v follows %v at %v:%v)\n", ent.Index, last, file.Name, offset)
+			}
+			last = ent.Index
+			if ent.Index < from {
+				return true
+			}
+			if ent.Index > to {
+				return false
+			}
+			count++
+			fmt.Printf("  index(%v) term(%v) %v size(%v): %v\n", ent.Index, ent.Term, ent.Type, len(ent.Data),
+				formatEntryData(ent.Type, ent.Data))
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("Read log file %v failed: %v", file.Name, err)
+		}
+		if tornOffset >= 0 {
+			fmt.Printf("  (torn tail at %v:%v)\n", file.Name, tornOffset)
+		}
+		if last > to {
+			break
+		}
+	}
+	fmt.Printf("Total: %v entries\n", count)
+	return
+}
@@ -0,0 +1,140 @@
+package io.chubao.fs;
+
+import java.io.EOFException;
+import java.io.IOException;
+import java.io.InputStream;
+
+/*
+ * CfsInputStream reads a file sequentially, and also at any position. The methods seek, getPos,
+ * seekToNewSource, read and readFully at a position are the same as the ones of Seekable and
+ * PositionedReadable of Hadoop, so that the input stream of a Hadoop FileSystem can delegate to them.
+ */
+public class CfsInputStream extends InputStream {
+    private final CfsMount mnt;
+    private final String path;
+    private final int fd;
+    private long pos;
+    private boolean closed;
+
+    public CfsInputStream(CfsMount mnt, String path) throws IOException {
+        this.mnt = mnt;
+        this.path = path;
+        this.fd = mnt.open(path, CfsMount.O_RDONLY, 0);
+    }
+
+    @Override
+    public synchronized int read() throws IOException {
+        byte[] b = new byte[1];
+        int n = read(b, 0, 1);
+        if (n <= 0) {
+            return -1;
+        }
+        return b[0] & 0xff;
+    }
+
+    @Override
+    public synchronized int read(byte[] b, int off, int len) throws IOException {
+        int n = read(pos, b, off, len);
+        if (n > 0) {
+            pos += n;
+        }
+        return n;
+    }
+
+    /*
+     * read reads at the position without changing the position of the stream,
+     * it returns -1 at the end of the file.
+     */
+    public int read(long position, byte[] b, int off, int len) throws IOException {
+        checkOpen();
+        if (off < 0 || len < 0 || len > b.length - off) {
+            throw new IndexOutOfBoundsException();
+        }
+        if (len == 0) {
+            return 0;
+        }
+        byte[] buf = off == 0 ? b : new byte[len];
+        long n = mnt.read(fd, buf, len, position);
+        if (n < 0) {
+            throw new IOException("read failed : " + path + " code : " + n);
+        }
+        if (n == 0) {
+            return -1;
+        }
+        if (buf != b) {
+            System.arraycopy(buf, 0, b, off, (int) n);
+        }
+        return (int) n;
+    }
+
+    public void readFully(long position, byte[] b, int off, int len) throws IOException {
+        int total = 0;
+        while (total < len) {
+            int n = read(position + total, b, off + total, len - total);
+            if (n < 0) {
+                throw new EOFException("reach the end of " + path + " at " + (position + total));
+            }
+            total += n;
+        }
+    }
+
+    public void readFully(long position, byte[] b) throws IOException {
+        readFully(position, b, 0, b.length);
+    }
+
+    public synchronized void seek(long position) throws IOException {
+        checkOpen();
+        if (position < 0) {
+            throw new EOFException("seek to a negative position " + position);
+        }
+        pos = position;
+    }
+
+    public synchronized long getPos() {
+        return pos;
+    }
+
+    /*
+     * seekToNewSource always returns false since the replicas are chosen by the client itself.
+     */
+    public boolean seekToNewSource(long targetPos) {
+        return false;
+    }
+
+    @Override
+    public synchronized long skip(long n) throws IOException {
+        if (n <= 0) {
+            return 0;
+        }
+        long size = size();
+        long skipped = Math.min(n, Math.max(size - pos, 0));
+        pos += skipped;
+        return skipped;
+    }
+
+    @Override
+    public synchronized int available() throws IOException {
+        return (int) Math.min(Math.max(size() - pos, 0), Integer.MAX_VALUE);
+    }
+
+    private long size() throws IOException {
+        checkOpen();
+        CfsLibrary.StatInfo stat = new CfsLibrary.StatInfo();
+        mnt.getAttr(path, stat);
+        return stat.size;
+    }
+
+    private void checkOpen() throws IOException {
+        if (closed) {
+            throw new IOException("stream is closed : " + path);
+        }
+    }
+
+    @Override
+    public synchronized void close() {
+        if (!closed) {
+            closed = true;
+            mnt.close(fd);
+        }
+    }
+}
@@ -8,6 +8,9 @@ import io.chubao.fs.CfsLibrary.DirentArray;
 
 import java.io.FileNotFoundException;
 import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.List;
 
 public class CfsMount {
     // Open flags
@@ -21,6 +24,7 @@ public class CfsMount {
     public static final int O_DIRECT = 040000;
 
     // Mode
+    public static final int S_IFMT = 0170000;
     public static final int S_IFDIR = 0040000;
     public static final int S_IFREG = 0100000;
     public static final int S_IFLNK = 0120000;
@@ -42,6 +46,9 @@ public class CfsMount {
     //success single
     public static final int SUCCESS = 0;
 
+    // dirents read from a directory at a time
+    private static final int READDIR_BATCH = 1024;
+
     private CfsLibrary libcfs;
     private long cid; // client id allocated by libcfs library
 
@@ -98,6 +105,29 @@ public class CfsMount {
         libcfs.cfs_close(this.cid, fd);
     }
 
+    public int flush(int fd) throws IOException {
+        int result = libcfs.cfs_flush(this.cid, fd);
+        if (result != SUCCESS) {
+            throw new IOException("flush failed : fd " + fd + " code : " + result);
+        }
+        return result;
+    }
+
+    /*
+     * openInputStream opens the file for reading from the beginning.
+     */
+    public CfsInputStream openInputStream(String path) throws IOException {
+        return new CfsInputStream(this, path);
+    }
+
+    /*
+     * openOutputStream creates the file if it does not exist, and writes it from the beginning
+     * after truncating it, or from the end if append is true.
+     */
+    public CfsOutputStream openOutputStream(String path, boolean append) throws IOException {
+        return new CfsOutputStream(this, path, append);
+    }
+
     public long write(int fd, byte[] buf, long size, long offset) {
         return libcfs.cfs_write(this.cid, fd, buf, size, offset);
     }
@@ -128,6 +158,76 @@ public class CfsMount {
         return (int) arrSize;
     }
 
+    /*
+     * list returns the names of the entries in the directory, except "." and "..".
+     */
+    public List<String> list(String path) throws IOException {
+        int fd = open(path, O_RDONLY, 0);
+        try {
+            List<String> names = new ArrayList<String>();
+            Dirent[] dents = (Dirent[]) (new Dirent()).toArray(READDIR_BATCH);
+            while (true) {
+                int n = readdir(fd, dents, dents.length);
+                if (n < 0) {
+                    throw new IOException("readdir failed : " + path + " code : " + n);
+                }
+                if (n == 0) {
+                    break;
+                }
+                for (int i = 0; i < n; i++) {
+                    String name = direntName(dents[i]);
+                    if (!name.equals(".") && !name.equals("..")) {
+                        names.add(name);
+                    }
+                }
+            }
+            return names;
+        } finally {
+            close(fd);
+        }
+    }
+
+    private static String direntName(Dirent dent) {
+        int len = 0;
+        while (len < dent.name.length && dent.name[len] != 0) {
+            len++;
+        }
+        return new String(dent.name, 0, len, StandardCharsets.UTF_8);
+    }
+
+    public boolean exists(String path) {
+        try {
+            getAttr(path, new CfsLibrary.StatInfo());
+            return true;
+        } catch (FileNotFoundException e) {
+            return false;
+        }
+    }
+
+    public boolean isDirectory(String path) throws FileNotFoundException {
+        CfsLibrary.StatInfo stat = new CfsLibrary.StatInfo();
+        getAttr(path, stat);
+        return (stat.mode & S_IFMT) == S_IFDIR;
+    }
+
+    /*
+     * delete removes the file or the empty directory, and the entries in the directory as well
+     * if recursive is true.
+     */
+    public void delete(String path, boolean recursive) throws IOException {
+        if (!isDirectory(path)) {
+            unlink(path);
+            return;
+        }
+        if (recursive) {
+            String dir = path.endsWith("/") ? path : path + "/";
+            for (String name : list(path)) {
+                delete(dir + name, true);
+            }
+        }
+        rmdir(path);
+    }
+
     public int mkdirs(String path, int mode) throws IOException {
         int result = libcfs.cfs_mkdirs(this.cid, path, mode);
         if (result != SUCCESS) {
@@ -0,0 +1,100 @@
+package io.chubao.fs;
+
+import java.io.IOException;
+import java.io.OutputStream;
+
+/*
+ * CfsOutputStream writes a file sequentially. The data is buffered by libcfs until the stream is
+ * flushed or closed. The methods hflush and hsync are the same as the ones of Syncable of Hadoop,
+ * so that the output stream of a Hadoop FileSystem can delegate to them.
+ */
+public class CfsOutputStream extends OutputStream {
+    private final CfsMount mnt;
+    private final String path;
+    private final int fd;
+    private long pos;
+    private boolean closed;
+
+    public CfsOutputStream(CfsMount mnt, String path, boolean append) throws IOException {
+        this.mnt = mnt;
+        this.path = path;
+        int flags = CfsMount.O_WRONLY | CfsMount.O_CREAT;
+        if (append) {
+            flags |= CfsMount.O_APPEND;
+        } else {
+            flags |= CfsMount.O_TRUNC;
+        }
+        this.fd = mnt.open(path, flags, 0644);
+        if (append) {
+            CfsLibrary.StatInfo stat = new CfsLibrary.StatInfo();
+            mnt.getAttr(path, stat);
+            this.pos = stat.size;
+        }
+    }
+
+    @Override
+    public synchronized void write(int b) throws IOException {
+        write(new byte[] { (byte) b }, 0, 1);
+    }
+
+    @Override
+    public synchronized void write(byte[] b, int off, int len) throws IOException {
+        checkOpen();
+        if (off < 0 || len < 0 || len > b.length - off) {
+            throw new IndexOutOfBoundsException();
+        }
+        if (len == 0) {
+            return;
+        }
+        byte[] buf = b;
+        if (off != 0) {
+            buf = new byte[len];
+            System.arraycopy(b, off, buf, 0, len);
+        }
+        long n = mnt.write(fd, buf, len, pos);
+        if (n < 0) {
+            throw new IOException("write failed : " + path + " code : " + n);
+        }
+        pos += n;
+    }
+
+    public synchronized long getPos() {
+        return pos;
+    }
+
+    /*
+     * flush writes the data buffered to the data nodes.
+     */
+    @Override
+    public synchronized void flush() throws IOException {
+        checkOpen();
+        mnt.flush(fd);
+    }
+
+    public void hflush() throws IOException {
+        flush();
+    }
+
+    public void hsync() throws IOException {
+        flush();
+    }
+
+    private void checkOpen() throws IOException {
+        if (closed) {
+            throw new IOException("stream is closed : " + path);
+        }
+    }
+
+    @Override
+    public synchronized void close() throws IOException {
+        if (closed) {
+            return;
+        }
+        closed = true;
+        try {
+            mnt.flush(fd);
+        } finally {
+            mnt.close(fd);
+        }
+    }
+}
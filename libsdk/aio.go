@@ -0,0 +1,180 @@
+// Copyright 2020 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+/*
+
+#include <stdint.h>
+#include <sys/types.h>
+
+struct cfs_aio_event {
+    uint64_t user_data;
+    int64_t  result;
+};
+
+typedef void (*cfs_aio_cb)(uint64_t user_data, int64_t result);
+
+static inline void cfs_aio_call(cfs_aio_cb cb, uint64_t user_data, int64_t result) {
+    cb(user_data, result);
+}
+
+*/
+import "C"
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// The asynchronous IO functions return as soon as the request is submitted, and the request is
+// served by the library in the background. The result of a request, i.e. the bytes read or written,
+// or a negative status on failure, is either passed to the callback given at submission, which is
+// called in a thread of the library and must not block, or queued if the callback is NULL, to be
+// reaped by cfs_aio_getevents. The buffer of a request must be kept until it completes.
+//
+// A flush covers the writes completed before it is submitted. The requests of a client in flight
+// at most are limited, and the submission fails with EAGAIN beyond the limit. All the requests must
+// be completed before the client is closed.
+
+const maxAioInflight = 4096
+
+type aioEvent struct {
+	userData uint64
+	result   int64
+}
+
+//export cfs_aio_read
+func cfs_aio_read(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t, cb C.cfs_aio_cb, user_data C.uint64_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	if status := checkReadable(f); status != statusOK {
+		return status
+	}
+	return c.submitAio(cb, user_data, func() int64 {
+		return int64(c.pread(f, buf, size, off))
+	})
+}
+
+//export cfs_aio_write
+func cfs_aio_write(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t, cb C.cfs_aio_cb, user_data C.uint64_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	if status := checkWritable(f); status != statusOK {
+		return status
+	}
+	return c.submitAio(cb, user_data, func() int64 {
+		return int64(c.pwrite(f, buf, size, off))
+	})
+}
+
+//export cfs_aio_flush
+func cfs_aio_flush(id C.int64_t, fd C.int, cb C.cfs_aio_cb, user_data C.uint64_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	return c.submitAio(cb, user_data, func() int64 {
+		if err := c.flush(f); err != nil {
+			return int64(statusEIO)
+		}
+		return int64(statusOK)
+	})
+}
+
+/*
+ * cfs_aio_getevents reaps the results of the requests submitted without a callback. It waits
+ * until at least min_nr results are reaped or the timeout expires, a negative timeout waits
+ * forever, and returns the number of the results filled in the events.
+ */
+
+//export cfs_aio_getevents
+func cfs_aio_getevents(id C.int64_t, events []C.struct_cfs_aio_event, min_nr C.int, timeout_ms C.int64_t) (n C.int) {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	var timeout <-chan time.Time
+	if timeout_ms >= 0 {
+		timer := time.NewTimer(time.Duration(timeout_ms) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for int(n) < len(events) {
+		var ev aioEvent
+		if n < min_nr {
+			select {
+			case ev = <-c.aioEvents:
+			case <-timeout:
+				return
+			}
+		} else {
+			select {
+			case ev = <-c.aioEvents:
+			default:
+				return
+			}
+		}
+		events[n].user_data = C.uint64_t(ev.userData)
+		events[n].result = C.int64_t(ev.result)
+		atomic.AddInt64(&c.aioInflight, -1)
+		n++
+	}
+	return
+}
+
+// submitAio serves the request in the background, and passes its result to the callback,
+// or queues it if the callback is nil.
+func (c *client) submitAio(cb C.cfs_aio_cb, userData C.uint64_t, serve func() int64) C.int {
+	if atomic.AddInt64(&c.aioInflight, 1) > maxAioInflight {
+		atomic.AddInt64(&c.aioInflight, -1)
+		return statusEAGAIN
+	}
+	go func() {
+		result := serve()
+		if cb != nil {
+			C.cfs_aio_call(cb, userData, C.int64_t(result))
+			atomic.AddInt64(&c.aioInflight, -1)
+			return
+		}
+		// never blocks since the events queued are limited by the requests in flight
+		c.aioEvents <- aioEvent{userData: uint64(userData), result: result}
+	}()
+	return statusOK
+}
@@ -23,6 +23,7 @@ package main
 #include <sys/stat.h>
 #include <dirent.h>
 #include <fcntl.h>
+#include <sys/xattr.h>
 
 struct cfs_stat_info {
     uint64_t ino;
@@ -53,6 +54,19 @@ struct cfs_summary_info {
     int64_t fbytes;
 };
 
+struct cfs_statfs_info {
+    uint64_t total;
+    uint64_t used;
+    uint64_t files;
+};
+
+// valid bits of cfs_setattr
+#define CFS_ATTR_MODE  0x01
+#define CFS_ATTR_UID   0x02
+#define CFS_ATTR_GID   0x04
+#define CFS_ATTR_MTIME 0x08
+#define CFS_ATTR_ATIME 0x10
+
 */
 import "C"
 
@@ -62,10 +76,12 @@ import (
 	"os"
 	gopath "path"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/willf/bitset"
@@ -73,6 +89,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/stream"
 	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/log"
 )
 
@@ -95,6 +112,7 @@ var (
 	statusEMFILE  = errorToStatus(syscall.EMFILE)
 	statusENOTDIR = errorToStatus(syscall.ENOTDIR)
 	statusEISDIR  = errorToStatus(syscall.EISDIR)
+	statusEAGAIN  = errorToStatus(syscall.EAGAIN)
 )
 
 func init() {
@@ -127,6 +145,8 @@ func newClient() *client {
 		fdset: bitset.New(maxFdNum),
 		cwd:   "/",
 		sc:    fs.NewSummaryCache(fs.DefaultSummaryExpiration, fs.MaxSummaryCache),
+
+		aioEvents: make(chan aioEvent, maxAioInflight),
 	}
 
 	gClientManager.mu.Lock()
@@ -170,12 +190,19 @@ type client struct {
 	id int64
 
 	// mount config
-	volName       string
-	masterAddr    string
-	followerRead  bool
-	logDir        string
-	logLevel      string
-	enableSummary bool
+	volName        string
+	masterAddr     string
+	followerRead   bool
+	logDir         string
+	logLevel       string
+	enableSummary  bool
+	subDir         string // the paths are resolved from the directory of the volume
+	encryptKeyFile string // file of the key to encrypt the file contents
+	verifyRead     bool   // the data read is checked against the block CRCs of the data nodes
+	metaRetry      util.RetryPolicy
+	dataRetry      util.RetryPolicy
+	uid            uint32 // owner of the files created
+	gid            uint32
 
 	// runtime context
 	cwd    string // current working directory
@@ -183,6 +210,10 @@ type client struct {
 	fdset  *bitset.BitSet
 	fdlock sync.RWMutex
 
+	// asynchronous IO
+	aioInflight int64 // submitted and not reaped yet, or whose callbacks have not returned
+	aioEvents   chan aioEvent
+
 	// server info
 	mw *meta.MetaWrapper
 	ec *stream.ExtentClient
@@ -226,12 +257,63 @@ func cfs_set_client(id C.int64_t, key, val *C.char) C.int {
 		} else {
 			c.enableSummary = false
 		}
+	case "subDir":
+		c.subDir = v
+	case "encryptKeyFile":
+		c.encryptKeyFile = v
+	case "verifyReadChecksum":
+		c.verifyRead = v == "true"
+	case "metaTimeout", "metaMaxRetries", "metaRetryTimeout", "dataTimeout", "dataMaxRetries", "dataRetryTimeout",
+		"retryBackoff", "retryMaxBackoff", "retryJitter", "hedgeReadDelay":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return statusEINVAL
+		}
+		c.setRetryOption(k, n)
+	case "uid", "gid":
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return statusEINVAL
+		}
+		if k == "uid" {
+			c.uid = uint32(n)
+		} else {
+			c.gid = uint32(n)
+		}
 	default:
 		return statusEINVAL
 	}
 	return statusOK
 }
 
+// setRetryOption sets the retry policies by the same keys as the mount options of the fuse client.
+func (c *client) setRetryOption(key string, n int64) {
+	ms := time.Duration(n) * time.Millisecond
+	switch key {
+	case "metaTimeout":
+		c.metaRetry.Timeout = ms
+	case "metaMaxRetries":
+		c.metaRetry.MaxRetries = int(n)
+	case "metaRetryTimeout":
+		c.metaRetry.RetryTimeout = ms
+	case "dataTimeout":
+		c.dataRetry.Timeout = ms
+	case "dataMaxRetries":
+		c.dataRetry.MaxRetries = int(n)
+	case "dataRetryTimeout":
+		c.dataRetry.RetryTimeout = ms
+	case "retryBackoff":
+		c.metaRetry.Backoff, c.dataRetry.Backoff = ms, ms
+	case "retryMaxBackoff":
+		c.metaRetry.MaxBackoff, c.dataRetry.MaxBackoff = ms, ms
+	case "retryJitter":
+		c.metaRetry.Jitter = float64(n) / 100
+		c.dataRetry.Jitter = c.metaRetry.Jitter
+	case "hedgeReadDelay":
+		c.dataRetry.HedgeDelay = ms
+	}
+}
+
 //export cfs_start_client
 func cfs_start_client(id C.int64_t) C.int {
 	c, exist := getClient(int64(id))
@@ -298,8 +380,37 @@ func cfs_getattr(id C.int64_t, path *C.char, stat *C.struct_cfs_stat_info) C.int
 	if err != nil {
 		return errorToStatus(err)
 	}
+	fillStat(info, stat)
+	return statusOK
+}
+
+//export cfs_fgetattr
+func cfs_fgetattr(id C.int64_t, fd C.int, stat *C.struct_cfs_stat_info) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
 
-	// fill up the stat
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	info, err := c.mw.InodeGet_ll(f.ino)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	if proto.IsRegular(info.Mode) {
+		// the writes not flushed yet
+		if size, _, valid := c.ec.FileSize(f.ino); valid && uint64(size) > info.Size {
+			info.Size = uint64(size)
+		}
+	}
+	fillStat(info, stat)
+	return statusOK
+}
+
+func fillStat(info *proto.InodeInfo, stat *C.struct_cfs_stat_info) {
 	stat.ino = C.uint64_t(info.Inode)
 	stat.size = C.uint64_t(info.Size)
 	stat.nlink = C.uint32_t(info.Nlink)
@@ -335,7 +446,43 @@ func cfs_getattr(id C.int64_t, path *C.char, stat *C.struct_cfs_stat_info) C.int
 	t = info.CreateTime.UnixNano()
 	stat.ctime = C.uint64_t(t / 1e9)
 	stat.ctime_nsec = C.uint32_t(t % 1e9)
+}
 
+//export cfs_readlink
+func cfs_readlink(id C.int64_t, path *C.char, buf *C.char, size C.size_t) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.ssize_t(statusEINVAL)
+	}
+
+	info, err := c.lookupPath(c.absPath(C.GoString(path)))
+	if err != nil {
+		return C.ssize_t(errorToStatus(err))
+	}
+	if !proto.IsSymlink(info.Mode) {
+		return C.ssize_t(statusEINVAL)
+	}
+	n := len(info.Target)
+	if n > int(size) {
+		n = int(size)
+	}
+	if n > 0 {
+		C.memcpy(unsafe.Pointer(buf), unsafe.Pointer(&info.Target[0]), C.size_t(n))
+	}
+	return C.ssize_t(n)
+}
+
+//export cfs_statfs
+func cfs_statfs(id C.int64_t, stat *C.struct_cfs_statfs_info) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	total, used, files := c.mw.Statfs()
+	stat.total = C.uint64_t(total)
+	stat.used = C.uint64_t(used)
+	stat.files = C.uint64_t(files)
 	return statusOK
 }
 
@@ -391,7 +538,7 @@ func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
 		parentIno = dirInfo.Inode
 		newInfo, err := c.create(dirInfo.Inode, name, fuseMode)
 		if err != nil {
-			if err != syscall.EEXIST {
+			if err != syscall.EEXIST || fuseFlags&uint32(C.O_EXCL) != 0 {
 				return errorToStatus(err)
 			}
 			newInfo, err = c.lookupPath(absPath)
@@ -470,6 +617,30 @@ func cfs_close(id C.int64_t, fd C.int) {
 	}
 }
 
+//export cfs_ftruncate
+func cfs_ftruncate(id C.int64_t, fd C.int, size C.off_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	if status := checkWritable(f); status != statusOK {
+		return status
+	}
+	if err := c.flush(f); err != nil {
+		return statusEIO
+	}
+	if err := c.truncate(f, int(size)); err != nil {
+		return errorToStatus(err)
+	}
+	return statusOK
+}
+
 //export cfs_write
 func cfs_write(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
 	c, exist := getClient(int64(id))
@@ -482,11 +653,48 @@ func cfs_write(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.
 		return C.ssize_t(statusEBADFD)
 	}
 
+	if status := checkWritable(f); status != statusOK {
+		return C.ssize_t(status)
+	}
+	return c.pwrite(f, buf, size, off)
+}
+
+//export cfs_read
+func cfs_read(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.ssize_t(statusEINVAL)
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.ssize_t(statusEBADFD)
+	}
+
+	if status := checkReadable(f); status != statusOK {
+		return C.ssize_t(status)
+	}
+	return c.pread(f, buf, size, off)
+}
+
+func checkWritable(f *file) C.int {
 	accFlags := f.flags & uint32(C.O_ACCMODE)
 	if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
-		return C.ssize_t(statusEACCES)
+		return statusEACCES
+	}
+	return statusOK
+}
+
+func checkReadable(f *file) C.int {
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags == uint32(C.O_WRONLY) {
+		return statusEACCES
 	}
+	return statusOK
+}
 
+// pwrite writes the buffer of the caller at the offset, and flushes it if the file is opened synchronous.
+func (c *client) pwrite(f *file, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
 	var buffer []byte
 
 	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
@@ -518,23 +726,8 @@ func cfs_write(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.
 	return C.ssize_t(n)
 }
 
-//export cfs_read
-func cfs_read(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
-	c, exist := getClient(int64(id))
-	if !exist {
-		return C.ssize_t(statusEINVAL)
-	}
-
-	f := c.getFile(uint(fd))
-	if f == nil {
-		return C.ssize_t(statusEBADFD)
-	}
-
-	accFlags := f.flags & uint32(C.O_ACCMODE)
-	if accFlags == uint32(C.O_WRONLY) {
-		return C.ssize_t(statusEACCES)
-	}
-
+// pread reads at the offset into the buffer of the caller.
+func (c *client) pread(f *file, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
 	var buffer []byte
 
 	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
@@ -620,7 +813,7 @@ func cfs_mkdirs(id C.int64_t, path *C.char, mode C.mode_t) C.int {
 		return statusEEXIST
 	}
 
-	pino := proto.RootIno
+	pino := c.mw.RootIno()
 	dirs := strings.Split(dirpath, "/")
 	for _, dir := range dirs {
 		if dir == "/" || dir == "" {
@@ -739,6 +932,116 @@ func cfs_fchmod(id C.int64_t, fd C.int, mode C.mode_t) C.int {
 	return statusOK
 }
 
+// The extended attributes with empty values are taken as missing.
+
+//export cfs_fgetxattr
+func cfs_fgetxattr(id C.int64_t, fd C.int, name *C.char, value unsafe.Pointer, size C.size_t) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.ssize_t(statusEINVAL)
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.ssize_t(statusEBADFD)
+	}
+
+	key := C.GoString(name)
+	info, err := c.mw.XAttrGet_ll(f.ino, key)
+	if err != nil {
+		return C.ssize_t(errorToStatus(err))
+	}
+	val := info.Get(key)
+	if len(val) == 0 {
+		return C.ssize_t(errorToStatus(syscall.ENODATA))
+	}
+	if size == 0 {
+		return C.ssize_t(len(val))
+	}
+	if int(size) < len(val) {
+		return C.ssize_t(errorToStatus(syscall.ERANGE))
+	}
+	C.memcpy(value, unsafe.Pointer(&val[0]), C.size_t(len(val)))
+	return C.ssize_t(len(val))
+}
+
+//export cfs_flistxattr
+func cfs_flistxattr(id C.int64_t, fd C.int, list *C.char, size C.size_t) C.ssize_t {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return C.ssize_t(statusEINVAL)
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return C.ssize_t(statusEBADFD)
+	}
+
+	keys, err := c.mw.XAttrsList_ll(f.ino)
+	if err != nil {
+		return C.ssize_t(errorToStatus(err))
+	}
+	var names []byte
+	for _, key := range keys {
+		names = append(names, key...)
+		names = append(names, 0)
+	}
+	if size == 0 || len(names) == 0 {
+		return C.ssize_t(len(names))
+	}
+	if int(size) < len(names) {
+		return C.ssize_t(errorToStatus(syscall.ERANGE))
+	}
+	C.memcpy(unsafe.Pointer(list), unsafe.Pointer(&names[0]), C.size_t(len(names)))
+	return C.ssize_t(len(names))
+}
+
+//export cfs_fsetxattr
+func cfs_fsetxattr(id C.int64_t, fd C.int, name *C.char, value unsafe.Pointer, size C.size_t, flags C.int) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	key := C.GoString(name)
+	if flags&(C.XATTR_CREATE|C.XATTR_REPLACE) != 0 {
+		info, err := c.mw.XAttrGet_ll(f.ino, key)
+		if err != nil {
+			return errorToStatus(err)
+		}
+		exists := len(info.Get(key)) != 0
+		if exists && flags&C.XATTR_CREATE != 0 {
+			return statusEEXIST
+		}
+		if !exists && flags&C.XATTR_REPLACE != 0 {
+			return errorToStatus(syscall.ENODATA)
+		}
+	}
+	err := c.mw.XAttrSet_ll(f.ino, []byte(key), C.GoBytes(value, C.int(size)))
+	return errorToStatus(err)
+}
+
+//export cfs_fremovexattr
+func cfs_fremovexattr(id C.int64_t, fd C.int, name *C.char) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+
+	err := c.mw.XAttrDel_ll(f.ino, C.GoString(name))
+	return errorToStatus(err)
+}
+
 //export cfs_getsummary
 func cfs_getsummary(id C.int64_t, path *C.char, summary *C.struct_cfs_summary_info, useCache *C.char, goroutine_num C.int) C.int {
 	c, exist := getClient(int64(id))
@@ -790,7 +1093,7 @@ func cfs_refreshsummary(id C.int64_t, path *C.char, goroutine_num C.int) C.int {
 	info, err := c.lookupPath(c.absPath(C.GoString(path)))
 	var ino uint64
 	if err != nil {
-		ino = proto.RootIno
+		ino = c.mw.RootIno()
 	} else {
 		ino = info.Inode
 	}
@@ -825,18 +1128,30 @@ func (c *client) start() (err error) {
 		Masters:       masters,
 		ValidateOwner: false,
 		EnableSummary: c.enableSummary,
+		SubDir:        c.subDir,
+		RetryPolicy:   c.metaRetry,
 	}); err != nil {
 		return
 	}
 
+	var encryptKey []byte
+	if c.encryptKeyFile != "" {
+		if encryptKey, err = stream.LoadEncryptKey(c.encryptKeyFile); err != nil {
+			return
+		}
+	}
+
 	var ec *stream.ExtentClient
 	if ec, err = stream.NewExtentClient(&stream.ExtentConfig{
-		Volume:            c.volName,
-		Masters:           masters,
-		FollowerRead:      c.followerRead,
-		OnAppendExtentKey: mw.AppendExtentKey,
-		OnGetExtents:      mw.GetExtents,
-		OnTruncate:        mw.Truncate,
+		Volume:             c.volName,
+		Masters:            masters,
+		FollowerRead:       c.followerRead,
+		OnAppendExtentKey:  mw.AppendExtentKey,
+		OnGetExtents:       mw.GetExtents,
+		OnTruncate:         mw.Truncate,
+		EncryptKey:         encryptKey,
+		VerifyReadChecksum: c.verifyRead,
+		RetryPolicy:        c.dataRetry,
 	}); err != nil {
 		return
 	}
@@ -903,13 +1218,13 @@ func (c *client) setattr(info *proto.InodeInfo, valid uint32, mode, uid, gid uin
 
 func (c *client) create(pino uint64, name string, mode uint32) (info *proto.InodeInfo, err error) {
 	fuseMode := mode & 0777
-	return c.mw.Create_ll(pino, name, fuseMode, 0, 0, nil)
+	return c.mw.Create_ll(pino, name, fuseMode, c.uid, c.gid, nil)
 }
 
 func (c *client) mkdir(pino uint64, name string, mode uint32) (info *proto.InodeInfo, err error) {
 	fuseMode := mode & 0777
 	fuseMode |= uint32(os.ModeDir)
-	return c.mw.Create_ll(pino, name, fuseMode, 0, 0, nil)
+	return c.mw.Create_ll(pino, name, fuseMode, c.uid, c.gid, nil)
 }
 
 func (c *client) openStream(f *file) {
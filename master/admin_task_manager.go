@@ -26,6 +26,7 @@ import (
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tlsutil"
 )
 
 //const
@@ -116,21 +117,14 @@ func (sender *AdminTaskManager) doSendTasks() {
 	sender.sendTasks(tasks)
 }
 
-func (sender *AdminTaskManager) getConn() (conn *net.TCPConn, err error) {
+func (sender *AdminTaskManager) getConn() (conn net.Conn, err error) {
 	if useConnPool {
 		return sender.connPool.GetConnect(sender.targetAddr)
 	}
-	var connect net.Conn
-	connect, err = net.Dial("tcp", sender.targetAddr)
-	if err == nil {
-		conn = connect.(*net.TCPConn)
-		conn.SetKeepAlive(true)
-		conn.SetNoDelay(true)
-	}
-	return
+	return tlsutil.DialTimeout(sender.targetAddr, connectTimeout*time.Second)
 }
 
-func (sender *AdminTaskManager) putConn(conn *net.TCPConn, forceClose bool) {
+func (sender *AdminTaskManager) putConn(conn net.Conn, forceClose bool) {
 	if useConnPool {
 		sender.connPool.PutConnect(conn, forceClose)
 	}
@@ -226,6 +220,34 @@ func (sender *AdminTaskManager) syncSendAdminTask(task *proto.AdminTask) (packet
 	return packet, nil
 }
 
+// syncSendPacket sends the packet of the client protocol and waits for the reply, which is returned
+// with the error if the result is not ok.
+func (sender *AdminTaskManager) syncSendPacket(packet *proto.Packet) (reply *proto.Packet, err error) {
+	log.LogInfof("action[syncSendPacket],op %s, partition %v, reqId %d", packet.GetOpMsg(), packet.PartitionID, packet.GetReqID())
+	conn, err := sender.getConn()
+	if err != nil {
+		return nil, errors.Trace(err, "action[syncSendPacket get conn failed,reqID:%v]", packet.ReqID)
+	}
+	defer func() {
+		if err == nil {
+			sender.putConn(conn, false)
+		} else {
+			sender.putConn(conn, true)
+		}
+	}()
+	if err = packet.WriteToConn(conn); err != nil {
+		return nil, errors.Trace(err, "action[syncSendPacket],WriteToConn failed,reqID[%v]", packet.ReqID)
+	}
+	if err = packet.ReadFromConn(conn, proto.SyncSendTaskDeadlineTime); err != nil {
+		return nil, errors.Trace(err, "action[syncSendPacket],ReadFromConn failed,reqID[%v]", packet.ReqID)
+	}
+	if packet.ResultCode != proto.OpOk {
+		err = fmt.Errorf("result code[%v],msg[%v]", packet.ResultCode, string(packet.Data))
+		log.LogErrorf("action[syncSendPacket],reqID[%v],err[%v],", packet.ReqID, err)
+	}
+	return packet, err
+}
+
 // DelTask deletes the to-be-deleted tasks.
 func (sender *AdminTaskManager) DelTask(t *proto.AdminTask) {
 	sender.Lock()
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
 	"regexp"
 	"strconv"
 	"sync/atomic"
@@ -244,12 +245,16 @@ func (m *Server) getIPAddr(w http.ResponseWriter, r *http.Request) {
 	deleteSleepMs := atomic.LoadUint64(&m.cluster.cfg.MetaNodeDeleteWorkerSleepMs)
 	autoRepairRate := atomic.LoadUint64(&m.cluster.cfg.DataNodeAutoRepairLimitRate)
 	cInfo := &proto.ClusterInfo{
-		Cluster:                     m.cluster.Name,
-		MetaNodeDeleteBatchCount:    batchCount,
-		MetaNodeDeleteWorkerSleepMs: deleteSleepMs,
-		DataNodeDeleteLimitRate:     limitRate,
-		DataNodeAutoRepairLimitRate: autoRepairRate,
-		Ip:                          strings.Split(r.RemoteAddr, ":")[0],
+		Cluster:                       m.cluster.Name,
+		MetaNodeDeleteBatchCount:      batchCount,
+		MetaNodeDeleteWorkerSleepMs:   deleteSleepMs,
+		DataNodeDeleteLimitRate:       limitRate,
+		DataNodeAutoRepairLimitRate:   autoRepairRate,
+		DataNodeDiskClientIOLimitRate: atomic.LoadUint64(&m.cluster.cfg.DataNodeDiskClientIOLimitRate),
+		DataNodeDiskRepairIOLimitRate: atomic.LoadUint64(&m.cluster.cfg.DataNodeDiskRepairIOLimitRate),
+		Ip:                            strings.Split(r.RemoteAddr, ":")[0],
+		LeaderAddr:                    m.leaderInfo.addr,
+		BlobStoreAddrs:                m.cluster.cfg.BlobStoreAddrs,
 	}
 	sendOkReply(w, r, newSuccessHTTPReply(cInfo))
 }
@@ -363,6 +368,187 @@ func (m *Server) loadDataPartition(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
+// Compare the replicas of the data partition, and repair them from the majority if asked to.
+func (m *Server) checkDataPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		repair      bool
+		err         error
+	)
+	if partitionID, repair, err = parseRequestToCheckDataPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	if err = m.cluster.checkDataPartition(dp, repair); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg := fmt.Sprintf("partitionID :%v  check data partition started, repair[%v]", partitionID, repair)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+// Get the result of the latest consistency check of the data partition.
+func (m *Server) getDataPartitionCheck(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		err         error
+	)
+	if partitionID, err = parseRequestToLoadDataPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	dp.RLock()
+	result := dp.checkResult
+	dp.RUnlock()
+	if result == nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Sprintf("data partition[%v] has not been checked", partitionID)})
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(result))
+}
+
+// Upload the extents of the data partition to the backup store from its leader.
+func (m *Server) backupDataPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		err         error
+	)
+	if partitionID, err = parseRequestToLoadDataPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	path := m.cluster.backupPath(dp, time.Now())
+	if err = m.cluster.backupDataPartition(dp, path); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg := fmt.Sprintf("partitionID :%v  backup data partition to [%v] started", partitionID, path)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+// Get the result of the latest backup of the data partition.
+func (m *Server) getDataPartitionBackup(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		err         error
+	)
+	if partitionID, err = parseRequestToLoadDataPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	dp.RLock()
+	result := dp.backupResult
+	dp.RUnlock()
+	if result == nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Sprintf("data partition[%v] has not been backed up", partitionID)})
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(result))
+}
+
+// Restore the replicas of the data partition from the backup under the given path.
+func (m *Server) restoreDataPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		path        string
+		err         error
+	)
+	if partitionID, path, err = parseRequestToRestoreDataPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	if err = m.cluster.restoreDataPartition(dp, path); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg := fmt.Sprintf("partitionID :%v  restore data partition from [%v] started", partitionID, path)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+// Convert the data partition to erasure coding, regardless of the time of its last write.
+func (m *Server) convertDataPartitionToEC(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		err         error
+	)
+	if partitionID, err = parseRequestToLoadDataPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	if err = m.cluster.convertDataPartitionToEC(dp); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg := fmt.Sprintf("partitionID :%v  conversion of data partition to erasure coding started", partitionID)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+// Get the progress of the conversion of the data partition to erasure coding.
+func (m *Server) getDataPartitionECStatus(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		err         error
+	)
+	if partitionID, err = parseRequestToLoadDataPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(dp.getECStatus()))
+}
+
+// Get the data partitions accessed the most within the last windows of the access statistics
+// of the data nodes.
+func (m *Server) getHotDataPartitions(w http.ResponseWriter, r *http.Request) {
+	count := defaultHotDataPartitionCount
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if value := r.FormValue(countKey); value != "" {
+		var err error
+		if count, err = strconv.Atoi(value); err != nil || count <= 0 {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: unmatchedKey(countKey).Error()})
+			return
+		}
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.getHotDataPartitions(count)))
+}
+
 func (m *Server) addDataReplica(w http.ResponseWriter, r *http.Request) {
 	var (
 		msg         string
@@ -481,6 +667,60 @@ func (m *Server) deleteMetaReplica(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
+func (m *Server) addMetaReplicaLearner(w http.ResponseWriter, r *http.Request) {
+	var (
+		msg         string
+		addr        string
+		mp          *MetaPartition
+		partitionID uint64
+		err         error
+	)
+
+	if partitionID, addr, err = parseRequestToAddMetaReplica(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if mp, err = m.cluster.getMetaPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrMetaPartitionNotExists))
+		return
+	}
+
+	if err = m.cluster.addMetaReplicaLearner(mp, addr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg = fmt.Sprintf("meta partitionID :%v  add learner [%v] successfully", partitionID, addr)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+func (m *Server) promoteMetaReplicaLearner(w http.ResponseWriter, r *http.Request) {
+	var (
+		msg         string
+		addr        string
+		mp          *MetaPartition
+		partitionID uint64
+		err         error
+	)
+
+	if partitionID, addr, err = extractMetaPartitionIDAndAddr(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if mp, err = m.cluster.getMetaPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrMetaPartitionNotExists))
+		return
+	}
+
+	if err = m.cluster.promoteMetaReplicaLearner(mp, addr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg = fmt.Sprintf("meta partitionID :%v  promote learner [%v] successfully", partitionID, addr)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
 // Decommission a data partition. This usually happens when disk error has been reported.
 // This function needs to be called manually by the admin.
 func (m *Server) decommissionDataPartition(w http.ResponseWriter, r *http.Request) {
@@ -587,6 +827,12 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 		description    string
 		dpSelectorName string
 		dpSelectorParm string
+		compression    string
+		writeLimit     uint64
+		objQuotaBytes  uint64
+		objQuotaCount  uint64
+		slo            *proto.VolSLO
+		flashCache     bool
 		vol            *Vol
 	)
 
@@ -609,7 +855,23 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if followerRead, authenticate, err = parseBoolFieldToUpdateVol(r, vol); err != nil {
+	if followerRead, authenticate, flashCache, err = parseBoolFieldToUpdateVol(r, vol); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if compression, err = parseCompressionToUpdateVol(r, vol); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if writeLimit, err = parseWriteLimitToUpdateVol(r, vol); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if objQuotaBytes, objQuotaCount, err = parseObjectQuotaToUpdateVol(r, vol); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if slo, err = parseSLOToUpdateVol(r, vol); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
@@ -623,6 +885,12 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 	newArgs.authenticate = authenticate
 	newArgs.dpSelectorName = dpSelectorName
 	newArgs.dpSelectorParm = dpSelectorParm
+	newArgs.compression = compression
+	newArgs.writeLimit = writeLimit
+	newArgs.objQuotaBytes = objQuotaBytes
+	newArgs.objQuotaCount = objQuotaCount
+	newArgs.slo = slo
+	newArgs.flashCache = flashCache
 
 	if err = m.cluster.updateVol(name, authKey, newArgs); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
@@ -715,6 +983,9 @@ func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
 		authenticate    bool
 		crossZone       bool
 		defaultPriority bool
+		caseInsensitive bool
+		encrypted       bool
+		checksumType    uint8
 		zoneName        string
 		description     string
 	)
@@ -722,7 +993,7 @@ func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
 	if name, owner, zoneName, description,
 		mpCount, dpReplicaNum, size,
 		capacity, followerRead,
-		authenticate, crossZone, defaultPriority,
+		authenticate, crossZone, defaultPriority, caseInsensitive, encrypted, checksumType,
 		err = parseRequestToCreateVol(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
@@ -735,7 +1006,7 @@ func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
 	if vol, err = m.cluster.createVol(name, owner, zoneName, description,
 		mpCount, dpReplicaNum, size, capacity,
 		followerRead, authenticate, crossZone,
-		defaultPriority); err != nil {
+		defaultPriority, caseInsensitive, encrypted, checksumType); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
@@ -803,6 +1074,15 @@ func newSimpleView(vol *Vol) *proto.SimpleVolView {
 		DpSelectorName:     vol.dpSelectorName,
 		DpSelectorParm:     vol.dpSelectorParm,
 		DefaultZonePrior:   vol.defaultPriority,
+		CaseInsensitive:    vol.caseInsensitive,
+		Compression:        vol.compression,
+		WriteLimit:         vol.writeLimit,
+		ObjQuotaBytes:      vol.objQuotaBytes,
+		ObjQuotaCount:      vol.objQuotaCount,
+		SLO:                vol.slo,
+		Encrypted:          vol.encrypted,
+		Checksum:           proto.ChecksumTypeName(vol.checksumType),
+		FlashCache:         vol.flashCache,
 	}
 }
 
@@ -824,13 +1104,14 @@ func checkIp(addr string) bool {
 
 func (m *Server) addDataNode(w http.ResponseWriter, r *http.Request) {
 	var (
-		nodeAddr  string
-		zoneName  string
-		id        uint64
-		err       error
-		nodesetId uint64
+		nodeAddr    string
+		zoneName    string
+		replicaAddr string
+		id          uint64
+		err         error
+		nodesetId   uint64
 	)
-	if nodeAddr, zoneName, err = parseRequestForAddNode(r); err != nil {
+	if nodeAddr, zoneName, replicaAddr, err = parseRequestForAddNode(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
@@ -850,6 +1131,10 @@ func (m *Server) addDataNode(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
+	if err = m.cluster.setDataNodeReplicaAddr(nodeAddr, replicaAddr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
 	sendOkReply(w, r, newSuccessHTTPReply(id))
 }
 
@@ -877,7 +1162,9 @@ func (m *Server) getDataNode(w http.ResponseWriter, r *http.Request) {
 		AvailableSpace:            dataNode.AvailableSpace,
 		ID:                        dataNode.ID,
 		ZoneName:                  dataNode.ZoneName,
+		Rack:                      dataNode.Rack,
 		Addr:                      dataNode.Addr,
+		ReplicaAddr:               dataNode.ReplicaAddr,
 		ReportTime:                dataNode.ReportTime,
 		IsActive:                  dataNode.isActive,
 		IsWriteAble:               dataNode.isWriteAble(),
@@ -889,73 +1176,231 @@ func (m *Server) getDataNode(w http.ResponseWriter, r *http.Request) {
 		NodeSetID:                 dataNode.NodeSetID,
 		PersistenceDataPartitions: dataNode.PersistenceDataPartitions,
 		BadDisks:                  dataNode.BadDisks,
+		CorruptExtents:            dataNode.CorruptExtents,
+		DiskSmarts:                dataNode.DiskSmarts,
+		HotExtents:                dataNode.HotExtents,
+		AccessStatsWindow:         dataNode.AccessStatsWindow,
 		RdOnly:                    dataNode.RdOnly,
+		PartitionsLoaded:          dataNode.PartitionsLoaded,
+		PartitionsToLoad:          dataNode.PartitionsToLoad,
+		RepairLimit:               dataNode.RepairLimit,
+		Version:                   dataNode.Version,
+		ClockSkew:                 dataNode.ClockSkew,
 	}
 
 	sendOkReply(w, r, newSuccessHTTPReply(dataNodeInfo))
 }
 
-// Decommission a data node. This will decommission all the data partition on that node.
-func (m *Server) decommissionDataNode(w http.ResponseWriter, r *http.Request) {
+// Report the progress of a data node loading its partitions when it starts, the replicas
+// on the data node are not taken as missing while the loading goes on.
+func (m *Server) reportDataNodeLoadProgress(w http.ResponseWriter, r *http.Request) {
 	var (
-		rstMsg      string
-		offLineAddr string
-		limit       int
-		err         error
+		nodeAddr      string
+		loaded, total int
+		dataNode      *DataNode
+		err           error
 	)
-
-	if offLineAddr, limit, err = parseDecomNodeReq(r); err != nil {
+	if nodeAddr, loaded, total, err = parseRequestForLoadProgress(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-
-	if _, err = m.cluster.dataNode(offLineAddr); err != nil {
+	if dataNode, err = m.cluster.dataNode(nodeAddr); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataNodeNotExists))
 		return
 	}
+	dataNode.updateLoadProgress(loaded, total)
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("data node [%v] loaded partitions [%v/%v]", nodeAddr, loaded, total)))
+}
 
-	if err = m.cluster.migrateDataNode(offLineAddr, "", limit); err != nil {
-		sendErrReply(w, r, newErrHTTPReply(err))
+// Report a disk isolated by a data node because of its IO errors, the data partitions on
+// the disk are repaired on the other data nodes.
+func (m *Server) reportDataNodeBadDisk(w http.ResponseWriter, r *http.Request) {
+	var (
+		nodeAddr, diskPath string
+		dataNode           *DataNode
+		err                error
+	)
+	if nodeAddr, diskPath, err = parseRequestForBadDisk(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-
-	rstMsg = fmt.Sprintf("decommission data node [%v] limit %d successfully", offLineAddr, limit)
-	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+	if dataNode, err = m.cluster.dataNode(nodeAddr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataNodeNotExists))
+		return
+	}
+	m.cluster.handleBadDisk(dataNode, diskPath)
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("data node [%v] bad disk [%v] reported", nodeAddr, diskPath)))
 }
 
-func (m *Server) migrateDataNodeHandler(w http.ResponseWriter, r *http.Request) {
-	srcAddr, targetAddr, limit, err := parseMigrateNodeParam(r)
+// Set the limits of the repairs of a data node, or the ones of the cluster if no address
+// is given. The limits are pushed to the data nodes by the heartbeats.
+func (m *Server) setDataNodeRepairLimit(w http.ResponseWriter, r *http.Request) {
+	nodeAddr, params, windows, clearLimit, err := parseRequestForRepairLimit(r)
 	if err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-
-	if limit > defaultMigrateDpCnt {
-		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError,
-			Msg: fmt.Sprintf("limit %d can't be bigger than %d", limit, defaultMigrateDpCnt)})
+	if nodeAddr != "" {
+		if err = m.cluster.setDataNodeRepairLimit(nodeAddr, params, windows, clearLimit); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set repair limit of data node [%v] successfully", nodeAddr)))
 		return
 	}
-
-	srcNode, err := m.cluster.dataNode(srcAddr)
-	if err != nil {
-		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeDataNodeNotExists, Msg: err.Error()})
-		return
+	if val, ok := params[nodeAutoRepairRateKey]; ok {
+		if err = m.cluster.setDataNodeAutoRepairLimitRate(val); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+	}
+	if val, ok := params[nodeDiskRepairIORateKey]; ok {
+		if err = m.cluster.setDataNodeDiskRepairIOLimitRate(val); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+	}
+	if windows != nil {
+		if len(windows) == 0 {
+			windows = nil
+		}
+		if err = m.cluster.setDataNodeRepairWindows(windows); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
 	}
+	sendOkReply(w, r, newSuccessHTTPReply("set repair limit of the cluster successfully"))
+}
 
-	targetNode, err := m.cluster.dataNode(targetAddr)
-	if err != nil {
-		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeDataNodeNotExists, Msg: err.Error()})
-		return
+func formatRepairWindows(windows []*proto.RepairLimitWindow) string {
+	items := make([]string, 0, len(windows))
+	for _, w := range windows {
+		items = append(items, w.String())
 	}
+	return strings.Join(items, commaSplit)
+}
 
-	if srcNode.NodeSetID != targetNode.NodeSetID {
-		err = fmt.Errorf("src %s and target %s must exist in the same nodeSet when migrate", srcAddr, targetAddr)
-		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+// parseRequestForRepairLimit returns the windows of the repair schedule, nil if not given, and
+// empty if the schedule is removed.
+func parseRequestForRepairLimit(r *http.Request) (nodeAddr string, params map[string]uint64, windows []*proto.RepairLimitWindow, clearLimit bool, err error) {
+	if err = r.ParseForm(); err != nil {
 		return
 	}
-
-	if !targetNode.isWriteAble() {
-		err = fmt.Errorf("[%s] is not writable, can't used as target addr for migrate", targetAddr)
+	nodeAddr = r.FormValue(addrKey)
+	params = make(map[string]uint64)
+	for _, key := range []string{nodeAutoRepairRateKey, nodeDiskRepairIORateKey} {
+		if value := r.FormValue(key); value != "" {
+			var val uint64
+			if val, err = strconv.ParseUint(value, 10, 64); err != nil {
+				err = unmatchedKey(key)
+				return
+			}
+			params[key] = val
+		}
+	}
+	if value := r.FormValue(repairScheduleKey); value == "none" {
+		windows = make([]*proto.RepairLimitWindow, 0)
+	} else if value != "" {
+		if windows, err = proto.ParseRepairLimitWindows(value); err != nil {
+			return
+		}
+	}
+	if value := r.FormValue(clearKey); value != "" {
+		if clearLimit, err = strconv.ParseBool(value); err != nil {
+			err = unmatchedKey(clearKey)
+			return
+		}
+	}
+	if clearLimit && nodeAddr == "" {
+		err = keyNotFound(addrKey)
+		return
+	}
+	if !clearLimit && len(params) == 0 && windows == nil {
+		err = keyNotFound(nodeAutoRepairRateKey)
+	}
+	return
+}
+
+func parseRequestForLoadProgress(r *http.Request) (nodeAddr string, loaded, total int, err error) {
+	if nodeAddr, err = parseAndExtractNodeAddr(r); err != nil {
+		return
+	}
+	if loaded, err = parseUintParam(r, loadedKey); err != nil {
+		return
+	}
+	total, err = parseUintParam(r, totalKey)
+	return
+}
+
+func parseRequestForBadDisk(r *http.Request) (nodeAddr, diskPath string, err error) {
+	if nodeAddr, err = parseAndExtractNodeAddr(r); err != nil {
+		return
+	}
+	diskPath, err = extractDiskPath(r)
+	return
+}
+
+// Decommission a data node. This will decommission all the data partition on that node.
+func (m *Server) decommissionDataNode(w http.ResponseWriter, r *http.Request) {
+	var (
+		rstMsg      string
+		offLineAddr string
+		limit       int
+		err         error
+	)
+
+	if offLineAddr, limit, err = parseDecomNodeReq(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if _, err = m.cluster.dataNode(offLineAddr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataNodeNotExists))
+		return
+	}
+
+	if err = m.cluster.migrateDataNode(offLineAddr, "", limit); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+
+	rstMsg = fmt.Sprintf("decommission data node [%v] limit %d successfully", offLineAddr, limit)
+	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+}
+
+func (m *Server) migrateDataNodeHandler(w http.ResponseWriter, r *http.Request) {
+	srcAddr, targetAddr, limit, err := parseMigrateNodeParam(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if limit > defaultMigrateDpCnt {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError,
+			Msg: fmt.Sprintf("limit %d can't be bigger than %d", limit, defaultMigrateDpCnt)})
+		return
+	}
+
+	srcNode, err := m.cluster.dataNode(srcAddr)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeDataNodeNotExists, Msg: err.Error()})
+		return
+	}
+
+	targetNode, err := m.cluster.dataNode(targetAddr)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeDataNodeNotExists, Msg: err.Error()})
+		return
+	}
+
+	if srcNode.NodeSetID != targetNode.NodeSetID {
+		err = fmt.Errorf("src %s and target %s must exist in the same nodeSet when migrate", srcAddr, targetAddr)
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+
+	if !targetNode.isWriteAble() {
+		err = fmt.Errorf("[%s] is not writable, can't used as target addr for migrate", targetAddr)
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
@@ -1005,6 +1450,24 @@ func (m *Server) setNodeInfoHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if val, ok := params[nodeDiskClientIORateKey]; ok {
+		if v, ok := val.(uint64); ok {
+			if err = m.cluster.setDataNodeDiskClientIOLimitRate(v); err != nil {
+				sendErrReply(w, r, newErrHTTPReply(err))
+				return
+			}
+		}
+	}
+
+	if val, ok := params[nodeDiskRepairIORateKey]; ok {
+		if v, ok := val.(uint64); ok {
+			if err = m.cluster.setDataNodeDiskRepairIOLimitRate(v); err != nil {
+				sendErrReply(w, r, newErrHTTPReply(err))
+				return
+			}
+		}
+	}
+
 	if val, ok := params[nodeDeleteWorkerSleepMs]; ok {
 		if v, ok := val.(uint64); ok {
 			if err = m.cluster.setMetaNodeDeleteWorkerSleepMs(v); err != nil {
@@ -1454,123 +1917,648 @@ func (m *Server) updateNodeSetIdHandler(w http.ResponseWriter, r *http.Request)
 	if err = r.ParseForm(); err != nil {
 		return
 	}
-	if nodeAddr, err = extractNodeAddr(r); err != nil {
+	if nodeAddr, err = extractNodeAddr(r); err != nil {
+		return
+	}
+	if id, err = extractNodeID(r); err != nil {
+		return
+	}
+	if value = r.FormValue(nodeTypeKey); value == "" {
+		err = fmt.Errorf("need param nodeType")
+		return
+	}
+
+	if nodeType, err = strconv.ParseUint(value, 10, 64); err != nil {
+		return
+	}
+
+	if err = m.updateNodesetId(zoneName, id, nodeType, nodeAddr); err != nil {
+		return
+	}
+
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("update node setid successfully")))
+}
+
+// get metanode some interval params
+func (m *Server) getNodeSetGrpInfoHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if err = r.ParseForm(); err != nil {
+		sendOkReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	var value string
+	var id uint64
+	if value = r.FormValue(idKey); value != "" {
+		id, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		}
+	}
+	log.LogInfof("action[getNodeSetGrpInfoHandler] id [%v]", id)
+	var info *proto.SimpleNodeSetGrpInfo
+	if info, err = m.buildNodeSetGrpInfoByID(id); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(info))
+}
+func (m *Server) getIsDomainOn(w http.ResponseWriter, r *http.Request) {
+	type SimpleDomainInfo struct {
+		DomainOn bool
+	}
+	nsglStat := new(SimpleDomainInfo)
+	nsglStat.DomainOn = m.cluster.FaultDomain
+	sendOkReply(w, r, newSuccessHTTPReply(nsglStat))
+}
+
+// get metanode some interval params
+func (m *Server) getAllNodeSetGrpInfoHandler(w http.ResponseWriter, r *http.Request) {
+	var i int
+	nsgm := m.cluster.nodeSetGrpManager
+	nsglStat := new(proto.SimpleNodeSetGrpInfoList)
+	nsglStat.DomainOn = m.cluster.FaultDomain
+	nsglStat.NeedDomain = m.cluster.needFaultDomain
+	nsglStat.DataRatioLimit = nsgm.dataRatioLimit
+	nsglStat.ZoneExcludeRatioLimit = nsgm.excludeZoneUseRatio
+	nsglStat.Status = nsgm.status
+	nsglStat.ExcludeZones = nsgm.c.t.domainExcludeZones
+	for i = 0; i < len(nsgm.nodeSetGrpMap); i++ {
+		log.LogInfof("action[getAllNodeSetGrpInfoHandler] index [%v],id [%v],Print inner nodeset now!", i, nsgm.nodeSetGrpMap[i].ID)
+		nsglStat.SimpleNodeSetGrpInfo = append(nsglStat.SimpleNodeSetGrpInfo, m.buildNodeSetGrpInfo(i))
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(nsglStat))
+}
+
+// get metanode some interval params
+func (m *Server) getNodeInfoHandler(w http.ResponseWriter, r *http.Request) {
+	resp := make(map[string]string)
+	resp[nodeDeleteBatchCountKey] = fmt.Sprintf("%v", m.cluster.cfg.MetaNodeDeleteBatchCount)
+	resp[nodeMarkDeleteRateKey] = fmt.Sprintf("%v", m.cluster.cfg.DataNodeDeleteLimitRate)
+	resp[nodeDeleteWorkerSleepMs] = fmt.Sprintf("%v", m.cluster.cfg.MetaNodeDeleteWorkerSleepMs)
+	resp[nodeAutoRepairRateKey] = fmt.Sprintf("%v", m.cluster.cfg.DataNodeAutoRepairLimitRate)
+	resp[nodeDiskClientIORateKey] = fmt.Sprintf("%v", m.cluster.cfg.DataNodeDiskClientIOLimitRate)
+	resp[nodeDiskRepairIORateKey] = fmt.Sprintf("%v", m.cluster.cfg.DataNodeDiskRepairIOLimitRate)
+	resp[repairScheduleKey] = formatRepairWindows(m.cluster.cfg.repairWindows())
+
+	sendOkReply(w, r, newSuccessHTTPReply(resp))
+}
+
+func (m *Server) diagnoseMetaPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		err               error
+		rstMsg            *proto.MetaPartitionDiagnosis
+		inactiveNodes     []string
+		corruptMps        []*MetaPartition
+		lackReplicaMps    []*MetaPartition
+		corruptMpIDs      []uint64
+		lackReplicaMpIDs  []uint64
+		badMetaPartitions []badPartitionView
+	)
+	corruptMpIDs = make([]uint64, 0)
+	lackReplicaMpIDs = make([]uint64, 0)
+	if inactiveNodes, corruptMps, err = m.cluster.checkCorruptMetaPartitions(); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+	}
+
+	if lackReplicaMps, err = m.cluster.checkLackReplicaMetaPartitions(); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+	}
+	for _, mp := range corruptMps {
+		corruptMpIDs = append(corruptMpIDs, mp.PartitionID)
+	}
+	for _, mp := range lackReplicaMps {
+		lackReplicaMpIDs = append(lackReplicaMpIDs, mp.PartitionID)
+	}
+	badMetaPartitions = m.cluster.getBadMetaPartitionsView()
+	rstMsg = &proto.MetaPartitionDiagnosis{
+		InactiveMetaNodes:           inactiveNodes,
+		CorruptMetaPartitionIDs:     corruptMpIDs,
+		LackReplicaMetaPartitionIDs: lackReplicaMpIDs,
+		BadMetaPartitionIDs:         badMetaPartitions,
+	}
+	log.LogInfof("diagnose metaPartition[%v] inactiveNodes:[%v], corruptMpIDs:[%v], lackReplicaMpIDs:[%v]", m.cluster.Name, inactiveNodes, corruptMpIDs, lackReplicaMpIDs)
+	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+}
+
+// List the disks marked for drain because of their SMART attributes.
+func (m *Server) rotateEncryptKey(w http.ResponseWriter, r *http.Request) {
+	rotated, err := m.cluster.rotateEncryptKey()
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("rotate encrypt key successfully, re-wrapped the keys of %v volumes", rotated)))
+}
+
+// Add a version of the master service key, by which the authnode encrypts the tickets after the rotation.
+// The request is posted by the admin with a ticket of the authnode, whose session key encrypts the new key.
+func (m *Server) addServiceKey(w http.ResponseWriter, r *http.Request) {
+	req, ticket, ok := m.parseServiceKeyReq(w, r, proto.MsgMasterAddServiceKeyReq)
+	if !ok {
+		return
+	}
+	if req.Key == "" {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: keyNotFound(serviceKeyKey).Error()})
+		return
+	}
+	key, err := cryptoutil.DecodeMessage(req.Key, ticket.SessionKey.Key)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: unmatchedKey(serviceKeyKey).Error()})
+		return
+	}
+	if err = m.cluster.addServiceKey(req.KeyID, key); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	log.LogWarnf("action[addServiceKey] master service key(%v) added by client[%v]", req.KeyID, req.APIReq.ClientID)
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("add master service key(%v) successfully", req.KeyID)))
+}
+
+// Retire a version of the master service key once the tickets encrypted with it have expired.
+// The request is posted by the admin with a ticket of the authnode.
+func (m *Server) retireServiceKey(w http.ResponseWriter, r *http.Request) {
+	req, _, ok := m.parseServiceKeyReq(w, r, proto.MsgMasterRetireServiceKeyReq)
+	if !ok {
+		return
+	}
+	if err := m.cluster.retireServiceKey(req.KeyID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	log.LogWarnf("action[retireServiceKey] master service key(%v) retired by client[%v]", req.KeyID, req.APIReq.ClientID)
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("retire master service key(%v) successfully", req.KeyID)))
+}
+
+// parseServiceKeyReq parses the request posted in the body and checks the ticket of the admin, an error is
+// replied if it fails.
+func (m *Server) parseServiceKeyReq(w http.ResponseWriter, r *http.Request, msg proto.MsgType) (req *proto.MasterServiceKeyReq, ticket cryptoutil.Ticket, ok bool) {
+	var (
+		plaintext []byte
+		err       error
+	)
+	if err = r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	// the body only, so that the key is not left in the logs of the urls
+	message := r.PostFormValue(proto.ClientMessage)
+	if message == "" {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: keyNotFound(proto.ClientMessage).Error()})
+		return
+	}
+	req = &proto.MasterServiceKeyReq{}
+	if plaintext, err = cryptoutil.Base64Decode(message); err == nil {
+		err = json.Unmarshal(plaintext, req)
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if ticket, err = checkAdminTicket(&req.APIReq, m.cluster.serviceKeys, msg); err != nil {
+		if err == proto.ErrExpiredTicket {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeInvalidTicket, Msg: err.Error()})
+		return
+	}
+	return req, ticket, true
+}
+
+func (m *Server) listServiceKeys(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.serviceKeys.list()))
+}
+
+// Report the versions of the nodes and the incompatible combinations of them.
+func (m *Server) checkVersion(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.versionCheckView()))
+}
+
+// Set the rate per second of a limit of the cluster, which is shared by the nodes requesting the key.
+func (m *Server) setRateLimit(w http.ResponseWriter, r *http.Request) {
+	var (
+		key  string
+		rate float64
+		err  error
+	)
+	if key, err = parseRateLimitKey(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	value := r.FormValue(rateKey)
+	if value == "" {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: keyNotFound(rateKey).Error()})
+		return
+	}
+	if rate, err = strconv.ParseFloat(value, 64); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: unmatchedKey(rateKey).Error()})
+		return
+	}
+	if err = m.cluster.setRateLimit(key, rate); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set rate limit [%v] to [%v] successfully", key, rate)))
+}
+
+func (m *Server) deleteRateLimit(w http.ResponseWriter, r *http.Request) {
+	key, err := parseRateLimitKey(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.deleteRateLimit(key); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("delete rate limit [%v] successfully", key)))
+}
+
+func (m *Server) listRateLimits(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.rateLimits.list()))
+}
+
+func (m *Server) getQuota(w http.ResponseWriter, r *http.Request) {
+	kind, name, dirPath, err := parseQuotaKindAndName(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	quota, err := m.cluster.getQuota(kind, name, dirPath)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(quota))
+}
+
+func (m *Server) listQuotas(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	quotas, err := m.cluster.listQuotas(r.FormValue(quotaKindKey))
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(quotas))
+}
+
+// Set the limits of the quota of a volume, an owner or a directory, the limits not given are kept. The bytes
+// of a volume are its capacity, and the volume is updated with its auth key as /vol/update. The quota of a
+// directory is set with the auth key of its volume.
+func (m *Server) setQuota(w http.ResponseWriter, r *http.Request) {
+	var (
+		kind      string
+		name      string
+		dirPath   string
+		maxBytes  uint64
+		maxInodes uint64
+		err       error
+	)
+	if kind, name, dirPath, err = parseQuotaKindAndName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	switch kind {
+	case proto.QuotaKindVol:
+		var (
+			vol     *Vol
+			authKey string
+		)
+		if vol, err = m.cluster.getVol(name); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeVolNotExists, Msg: err.Error()})
+			return
+		}
+		if authKey, err = extractAuthKey(r); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		if maxBytes, maxInodes, err = parseQuotaLimits(r, vol.capacity()*util.GB, vol.maxInodes); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		if maxBytes == 0 || maxBytes%util.GB != 0 {
+			err = fmt.Errorf("%v of a volume should be a positive multiple of 1GB", maxBytesKey)
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		newArgs := getVolVarargs(vol)
+		newArgs.capacity = maxBytes / util.GB
+		newArgs.maxInodes = maxInodes
+		err = m.cluster.updateVol(name, authKey, newArgs)
+	case proto.QuotaKindOwner:
+		var quota = &proto.QuotaInfo{}
+		if owner := m.cluster.quotas.getOwner(name); owner != nil {
+			quota = owner
+		}
+		if maxBytes, maxInodes, err = parseQuotaLimits(r, quota.MaxBytes, quota.MaxInodes); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		err = m.cluster.setOwnerQuota(name, maxBytes, maxInodes)
+	case proto.QuotaKindDir:
+		var (
+			vol     *Vol
+			authKey string
+		)
+		if vol, err = m.cluster.getVol(name); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeVolNotExists, Msg: err.Error()})
+			return
+		}
+		if authKey, err = extractAuthKey(r); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		if !matchKey(vol.Owner, authKey) {
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrVolAuthKeyNotMatch))
+			return
+		}
+		var quota = &proto.QuotaInfo{}
+		if dir := m.cluster.quotas.getDir(name, dirPath); dir != nil {
+			quota = dir
+		}
+		if maxBytes, maxInodes, err = parseQuotaLimits(r, quota.MaxBytes, quota.MaxInodes); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		err = m.cluster.setDirQuota(name, dirPath, maxBytes, maxInodes)
+		name = name + ":" + dirPath
+	}
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set %v quota [%v] to bytes[%v] inodes[%v] successfully",
+		kind, name, maxBytes, maxInodes)))
+}
+
+// Take a consistent snapshot of the whole volume, the writes of the volume are fenced while it is taken.
+func (m *Server) createVolSnapshot(w http.ResponseWriter, r *http.Request) {
+	name, authKey, err := parseVolNameAndAuthKey(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	vol, err := m.cluster.getVol(name)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeVolNotExists, Msg: err.Error()})
+		return
+	}
+	if !matchKey(vol.Owner, authKey) {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolAuthKeyNotMatch))
+		return
+	}
+	snap, err := m.cluster.createVolSnapshot(vol, r.FormValue(snapshotKey))
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(snap))
+}
+
+func (m *Server) deleteVolSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := parseVolSnapshotAndAuthKey(m.cluster, r)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	if err = m.cluster.deleteVolSnapshot(snap.ID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("delete snapshot [%v] of vol [%v] successfully", snap.ID, snap.VolName)))
+}
+
+// List the snapshots of the volume, or of all the volumes if the name is not given.
+func (m *Server) listVolSnapshots(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.volSnapshots.list(r.FormValue(nameKey))))
+}
+
+// Roll the namespace of the volume back to the snapshot, the clients should remount the volume after it.
+func (m *Server) restoreVolSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := parseVolSnapshotAndAuthKey(m.cluster, r)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	if snap, err = m.cluster.restoreVolSnapshot(snap.ID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(snap))
+}
+
+// parseVolSnapshotAndAuthKey returns the snapshot of the id, whose volume matches the auth key.
+func parseVolSnapshotAndAuthKey(c *Cluster, r *http.Request) (snap *proto.VolSnapshotInfo, err error) {
+	var (
+		value   string
+		id      uint64
+		authKey string
+		vol     *Vol
+	)
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	if value = r.FormValue(idKey); value == "" {
+		return nil, keyNotFound(idKey)
+	}
+	if id, err = strconv.ParseUint(value, 10, 64); err != nil {
+		return nil, unmatchedKey(idKey)
+	}
+	if authKey, err = extractAuthKey(r); err != nil {
+		return
+	}
+	if snap = c.volSnapshots.get(id); snap == nil {
+		return nil, fmt.Errorf("vol snapshot[%v] not found", id)
+	}
+	if vol, err = c.getVol(snap.VolName); err != nil {
+		return nil, proto.ErrVolNotExists
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return nil, proto.ErrVolAuthKeyNotMatch
+	}
+	return
+}
+
+// Allocate the budgets of the limits to the node by the demands it reports.
+func (m *Server) allocateRateLimits(w http.ResponseWriter, r *http.Request) {
+	var (
+		body []byte
+		err  error
+	)
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	req := &proto.RateLimitAllocateRequest{}
+	if err = json.Unmarshal(body, req); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if req.Node == "" {
+		req.Node = r.RemoteAddr
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.rateLimits.allocate(req, time.Now())))
+}
+
+// Get the states of the objectives of the volume, or the volumes with the objectives if no name is given.
+func (m *Server) getSLOStatus(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	statuses, err := m.cluster.getSLOStatuses(r.FormValue(nameKey), time.Now())
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeVolNotExists, Msg: err.Error()})
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(statuses))
+}
+
+// Add the operations of the volumes reported by a client to the samples of the objectives.
+func (m *Server) reportSLO(w http.ResponseWriter, r *http.Request) {
+	var (
+		body []byte
+		err  error
+	)
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	req := &proto.SLOReportRequest{}
+	if err = json.Unmarshal(body, req); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	m.cluster.slos.report(req.Reports, time.Now())
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("received %v reports", len(req.Reports))))
+}
+
+func (m *Server) addFlashNode(w http.ResponseWriter, r *http.Request) {
+	var (
+		nodeAddr string
+		zoneName string
+		id       uint64
+		err      error
+	)
+	if nodeAddr, zoneName, _, err = parseRequestForAddNode(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if !checkIp(nodeAddr) {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Errorf("addr not legal").Error()})
+		return
+	}
+	if id, err = m.cluster.addFlashNode(nodeAddr, zoneName); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(id))
+}
+
+func (m *Server) getFlashNode(w http.ResponseWriter, r *http.Request) {
+	nodeAddr, err := parseAndExtractNodeAddr(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	flashNode, err := m.cluster.flashNode(nodeAddr)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
-	if id, err = extractNodeID(r); err != nil {
+	sendOkReply(w, r, newSuccessHTTPReply(flashNode.view()))
+}
+
+func (m *Server) listFlashNodes(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.listFlashNodes()))
+}
+
+func (m *Server) decommissionFlashNode(w http.ResponseWriter, r *http.Request) {
+	nodeAddr, err := parseAndExtractNodeAddr(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-	if value = r.FormValue(nodeTypeKey); value == "" {
-		err = fmt.Errorf("need param nodeType")
+	if err = m.cluster.decommissionFlashNode(nodeAddr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("decommission flash node [%v] successfully", nodeAddr)))
+}
 
-	if nodeType, err = strconv.ParseUint(value, 10, 64); err != nil {
+// Get the flash nodes caching the data read by the clients of the volume.
+func (m *Server) getFlashNodesOfVol(w http.ResponseWriter, r *http.Request) {
+	name, err := parseAndExtractName(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-
-	if err = m.updateNodesetId(zoneName, id, nodeType, nodeAddr); err != nil {
+	view, err := m.cluster.getFlashCacheView(name)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
 		return
 	}
-
-	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("update node setid successfully")))
+	sendOkReply(w, r, newSuccessHTTPReply(view))
 }
 
-// get metanode some interval params
-func (m *Server) getNodeSetGrpInfoHandler(w http.ResponseWriter, r *http.Request) {
-	var err error
+// parseQuotaKindAndName returns the kind and the name of the quota, and the clean absolute path of a directory.
+func parseQuotaKindAndName(r *http.Request) (kind, name, dirPath string, err error) {
 	if err = r.ParseForm(); err != nil {
-		sendOkReply(w, r, newErrHTTPReply(err))
 		return
 	}
-	var value string
-	var id uint64
-	if value = r.FormValue(idKey); value != "" {
-		id, err = strconv.ParseUint(value, 10, 64)
-		if err != nil {
-			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
-		}
+	if kind = r.FormValue(quotaKindKey); kind != proto.QuotaKindVol && kind != proto.QuotaKindOwner && kind != proto.QuotaKindDir {
+		err = unmatchedKey(quotaKindKey)
+		return
 	}
-	log.LogInfof("action[getNodeSetGrpInfoHandler] id [%v]", id)
-	var info *proto.SimpleNodeSetGrpInfo
-	if info, err = m.buildNodeSetGrpInfoByID(id); err != nil {
-		sendErrReply(w, r, newErrHTTPReply(err))
+	if name = r.FormValue(nameKey); name == "" {
+		err = keyNotFound(nameKey)
+		return
 	}
-	sendOkReply(w, r, newSuccessHTTPReply(info))
-}
-func (m *Server) getIsDomainOn(w http.ResponseWriter, r *http.Request) {
-	type SimpleDomainInfo struct {
-		DomainOn bool
+	if kind == proto.QuotaKindDir {
+		if dirPath = r.FormValue(pathKey); dirPath == "" {
+			err = keyNotFound(pathKey)
+			return
+		}
+		dirPath = path.Clean("/" + dirPath)
 	}
-	nsglStat := new(SimpleDomainInfo)
-	nsglStat.DomainOn = m.cluster.FaultDomain
-	sendOkReply(w, r, newSuccessHTTPReply(nsglStat))
+	return
 }
 
-// get metanode some interval params
-func (m *Server) getAllNodeSetGrpInfoHandler(w http.ResponseWriter, r *http.Request) {
-	var i int
-	nsgm := m.cluster.nodeSetGrpManager
-	nsglStat := new(proto.SimpleNodeSetGrpInfoList)
-	nsglStat.DomainOn = m.cluster.FaultDomain
-	nsglStat.NeedDomain = m.cluster.needFaultDomain
-	nsglStat.DataRatioLimit = nsgm.dataRatioLimit
-	nsglStat.ZoneExcludeRatioLimit = nsgm.excludeZoneUseRatio
-	nsglStat.Status = nsgm.status
-	nsglStat.ExcludeZones = nsgm.c.t.domainExcludeZones
-	for i = 0; i < len(nsgm.nodeSetGrpMap); i++ {
-		log.LogInfof("action[getAllNodeSetGrpInfoHandler] index [%v],id [%v],Print inner nodeset now!", i, nsgm.nodeSetGrpMap[i].ID)
-		nsglStat.SimpleNodeSetGrpInfo = append(nsglStat.SimpleNodeSetGrpInfo, m.buildNodeSetGrpInfo(i))
+func parseQuotaLimits(r *http.Request, oldMaxBytes, oldMaxInodes uint64) (maxBytes, maxInodes uint64, err error) {
+	maxBytes, maxInodes = oldMaxBytes, oldMaxInodes
+	if value := r.FormValue(maxBytesKey); value != "" {
+		if maxBytes, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = unmatchedKey(maxBytesKey)
+			return
+		}
 	}
-	sendOkReply(w, r, newSuccessHTTPReply(nsglStat))
-}
-
-// get metanode some interval params
-func (m *Server) getNodeInfoHandler(w http.ResponseWriter, r *http.Request) {
-	resp := make(map[string]string)
-	resp[nodeDeleteBatchCountKey] = fmt.Sprintf("%v", m.cluster.cfg.MetaNodeDeleteBatchCount)
-	resp[nodeMarkDeleteRateKey] = fmt.Sprintf("%v", m.cluster.cfg.DataNodeDeleteLimitRate)
-	resp[nodeDeleteWorkerSleepMs] = fmt.Sprintf("%v", m.cluster.cfg.MetaNodeDeleteWorkerSleepMs)
-	resp[nodeAutoRepairRateKey] = fmt.Sprintf("%v", m.cluster.cfg.DataNodeAutoRepairLimitRate)
-
-	sendOkReply(w, r, newSuccessHTTPReply(resp))
-}
-
-func (m *Server) diagnoseMetaPartition(w http.ResponseWriter, r *http.Request) {
-	var (
-		err               error
-		rstMsg            *proto.MetaPartitionDiagnosis
-		inactiveNodes     []string
-		corruptMps        []*MetaPartition
-		lackReplicaMps    []*MetaPartition
-		corruptMpIDs      []uint64
-		lackReplicaMpIDs  []uint64
-		badMetaPartitions []badPartitionView
-	)
-	corruptMpIDs = make([]uint64, 0)
-	lackReplicaMpIDs = make([]uint64, 0)
-	if inactiveNodes, corruptMps, err = m.cluster.checkCorruptMetaPartitions(); err != nil {
-		sendErrReply(w, r, newErrHTTPReply(err))
+	if value := r.FormValue(maxInodesKey); value != "" {
+		if maxInodes, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = unmatchedKey(maxInodesKey)
+			return
+		}
 	}
+	return
+}
 
-	if lackReplicaMps, err = m.cluster.checkLackReplicaMetaPartitions(); err != nil {
-		sendErrReply(w, r, newErrHTTPReply(err))
-	}
-	for _, mp := range corruptMps {
-		corruptMpIDs = append(corruptMpIDs, mp.PartitionID)
-	}
-	for _, mp := range lackReplicaMps {
-		lackReplicaMpIDs = append(lackReplicaMpIDs, mp.PartitionID)
+func parseRateLimitKey(r *http.Request) (key string, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
 	}
-	badMetaPartitions = m.cluster.getBadMetaPartitionsView()
-	rstMsg = &proto.MetaPartitionDiagnosis{
-		InactiveMetaNodes:           inactiveNodes,
-		CorruptMetaPartitionIDs:     corruptMpIDs,
-		LackReplicaMetaPartitionIDs: lackReplicaMpIDs,
-		BadMetaPartitionIDs:         badMetaPartitions,
+	if key = r.FormValue(rateLimitKeyKey); key == "" {
+		err = keyNotFound(rateLimitKeyKey)
 	}
-	log.LogInfof("diagnose metaPartition[%v] inactiveNodes:[%v], corruptMpIDs:[%v], lackReplicaMpIDs:[%v]", m.cluster.Name, inactiveNodes, corruptMpIDs, lackReplicaMpIDs)
-	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+	return
+}
+
+func (m *Server) getDegradedDisks(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.getDegradedDisks()))
 }
 
 // Decommission a disk. This will decommission all the data partitions on this disk.
@@ -1633,13 +2621,14 @@ func (m *Server) handleDataNodeTaskResponse(w http.ResponseWriter, r *http.Reque
 
 func (m *Server) addMetaNode(w http.ResponseWriter, r *http.Request) {
 	var (
-		nodeAddr  string
-		zoneName  string
-		id        uint64
-		err       error
-		nodesetId uint64
+		nodeAddr    string
+		zoneName    string
+		replicaAddr string
+		id          uint64
+		err         error
+		nodesetId   uint64
 	)
-	if nodeAddr, zoneName, err = parseRequestForAddNode(r); err != nil {
+	if nodeAddr, zoneName, replicaAddr, err = parseRequestForAddNode(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
@@ -1659,6 +2648,10 @@ func (m *Server) addMetaNode(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
+	if err = m.cluster.setMetaNodeReplicaAddr(nodeAddr, replicaAddr); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
 	sendOkReply(w, r, newSuccessHTTPReply(id))
 }
 
@@ -1721,6 +2714,7 @@ func (m *Server) getMetaNode(w http.ResponseWriter, r *http.Request) {
 	metaNodeInfo = &proto.MetaNodeInfo{
 		ID:                        metaNode.ID,
 		Addr:                      metaNode.Addr,
+		ReplicaAddr:               metaNode.ReplicaAddr,
 		IsActive:                  metaNode.IsActive,
 		IsWriteAble:               metaNode.isWritable(),
 		ZoneName:                  metaNode.ZoneName,
@@ -1736,6 +2730,8 @@ func (m *Server) getMetaNode(w http.ResponseWriter, r *http.Request) {
 		NodeSetID:                 metaNode.NodeSetID,
 		PersistenceMetaPartitions: metaNode.PersistenceMetaPartitions,
 		RdOnly:                    metaNode.RdOnly,
+		Version:                   metaNode.Version,
+		ClockSkew:                 metaNode.ClockSkew,
 	}
 	sendOkReply(w, r, newSuccessHTTPReply(metaNodeInfo))
 }
@@ -1787,6 +2783,19 @@ func (m *Server) loadMetaPartition(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
+func (m *Server) balanceMetaPartitionLeader(w http.ResponseWriter, r *http.Request) {
+	if err := m.cluster.startToBalanceMetaPartitionLeaders(); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg := proto.AdminBalanceMetaPartitionLeader + " start to balance the meta partition leaders"
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+func (m *Server) getMetaLeaderBalanceStatus(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.metaLeaderBalanceStatus()))
+}
+
 func parseMigrateNodeParam(r *http.Request) (srcAddr, targetAddr string, limit int, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -1990,7 +2999,7 @@ func parseRequestForUpdateMetaNode(r *http.Request) (nodeAddr string, id uint64,
 	return
 }
 
-func parseRequestForAddNode(r *http.Request) (nodeAddr, zoneName string, err error) {
+func parseRequestForAddNode(r *http.Request) (nodeAddr, zoneName, replicaAddr string, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
 	}
@@ -2000,6 +3009,13 @@ func parseRequestForAddNode(r *http.Request) (nodeAddr, zoneName string, err err
 	if zoneName = r.FormValue(zoneNameKey); zoneName == "" {
 		zoneName = DefaultZoneName
 	}
+	if replicaAddr = r.FormValue(replicaAddrKey); replicaAddr != "" && !checkIp(replicaAddr) {
+		err = fmt.Errorf("replication addr[%v] not legal", replicaAddr)
+		return
+	}
+	if replicaAddr == nodeAddr {
+		replicaAddr = ""
+	}
 	return
 }
 
@@ -2049,6 +3065,9 @@ func parseRequestToGetTaskResponse(r *http.Request) (tr *proto.AdminTask, err er
 	if body, err = ioutil.ReadAll(r.Body); err != nil {
 		return
 	}
+	if r.Header.Get("Content-Type") == proto.ProtobufContentType {
+		return proto.UnmarshalTaskPB(body)
+	}
 	tr = &proto.AdminTask{}
 	decoder := json.NewDecoder(bytes.NewBuffer([]byte(body)))
 	decoder.UseNumber()
@@ -2167,7 +3186,82 @@ func parseDefaultInfoToUpdateVol(r *http.Request, vol *Vol) (zoneName string, ca
 	return
 }
 
-func parseBoolFieldToUpdateVol(r *http.Request, vol *Vol) (followerRead, authenticate bool, err error) {
+func parseCompressionToUpdateVol(r *http.Request, vol *Vol) (compression string, err error) {
+	if compression = r.FormValue(compressionKey); compression == "" {
+		return vol.compression, nil
+	}
+	if compression == proto.CompressionNone {
+		return "", nil
+	}
+	if !proto.IsValidCompression(compression) {
+		err = unmatchedKey(compressionKey)
+	}
+	return
+}
+
+func parseWriteLimitToUpdateVol(r *http.Request, vol *Vol) (writeLimit uint64, err error) {
+	value := r.FormValue(writeLimitKey)
+	if value == "" {
+		return vol.writeLimit, nil
+	}
+	if writeLimit, err = strconv.ParseUint(value, 10, 64); err != nil {
+		err = unmatchedKey(writeLimitKey)
+	}
+	return
+}
+
+func parseObjectQuotaToUpdateVol(r *http.Request, vol *Vol) (quotaBytes, quotaCount uint64, err error) {
+	quotaBytes, quotaCount = vol.objQuotaBytes, vol.objQuotaCount
+	if value := r.FormValue(objectQuotaBytesKey); value != "" {
+		if quotaBytes, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = unmatchedKey(objectQuotaBytesKey)
+			return
+		}
+	}
+	if value := r.FormValue(objectQuotaCountKey); value != "" {
+		if quotaCount, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = unmatchedKey(objectQuotaCountKey)
+			return
+		}
+	}
+	return
+}
+
+// parseSLOToUpdateVol returns the objectives of the volume updated by the request, nil if all of them
+// are cleared by 0.
+func parseSLOToUpdateVol(r *http.Request, vol *Vol) (slo *proto.VolSLO, err error) {
+	slo = &proto.VolSLO{}
+	if vol.slo != nil {
+		*slo = *vol.slo
+	}
+	if value := r.FormValue(sloLatencyMsKey); value != "" {
+		if slo.LatencyMs, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, unmatchedKey(sloLatencyMsKey)
+		}
+	}
+	if value := r.FormValue(sloLatencyTargetKey); value != "" {
+		if slo.LatencyTarget, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, unmatchedKey(sloLatencyTargetKey)
+		}
+	}
+	if value := r.FormValue(sloErrorRateTargetKey); value != "" {
+		if slo.ErrorRateTarget, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, unmatchedKey(sloErrorRateTargetKey)
+		}
+	}
+	if slo.LatencyMs == 0 {
+		slo.LatencyTarget = 0
+	}
+	if slo.IsEmpty() {
+		return nil, nil
+	}
+	if err = slo.Validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func parseBoolFieldToUpdateVol(r *http.Request, vol *Vol) (followerRead, authenticate, flashCache bool, err error) {
 	if followerReadStr := r.FormValue(followerReadKey); followerReadStr != "" {
 		if followerRead, err = strconv.ParseBool(followerReadStr); err != nil {
 			err = unmatchedKey(followerReadKey)
@@ -2184,6 +3278,14 @@ func parseBoolFieldToUpdateVol(r *http.Request, vol *Vol) (followerRead, authent
 	} else {
 		authenticate = vol.authenticate
 	}
+	if flashCacheStr := r.FormValue(flashCacheKey); flashCacheStr != "" {
+		if flashCache, err = strconv.ParseBool(flashCacheStr); err != nil {
+			err = unmatchedKey(flashCacheKey)
+			return
+		}
+	} else {
+		flashCache = vol.flashCache
+	}
 	return
 }
 
@@ -2206,7 +3308,7 @@ func parseRequestToSetVolCapacity(r *http.Request) (name, authKey string, capaci
 func parseRequestToCreateVol(r *http.Request) (name, owner, zoneName, description string,
 	mpCount, dpReplicaNum, size,
 	capacity int, followerRead,
-	authenticate, crossZone, defaultPriority bool,
+	authenticate, crossZone, defaultPriority, caseInsensitive, encrypted bool, checksumType uint8,
 	err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -2256,6 +3358,19 @@ func parseRequestToCreateVol(r *http.Request) (name, owner, zoneName, descriptio
 	if defaultPriority, err = extractDefaulPriority(r); err != nil {
 		return
 	}
+	if caseInsensitive, err = extractCaseInsensitive(r); err != nil {
+		return
+	}
+	if value := r.FormValue(encryptedKey); value != "" {
+		if encrypted, err = strconv.ParseBool(value); err != nil {
+			err = unmatchedKey(encryptedKey)
+			return
+		}
+	}
+	if checksumType, err = proto.ParseChecksumType(r.FormValue(checksumKey)); err != nil {
+		err = unmatchedKey(checksumKey)
+		return
+	}
 
 	zoneName = r.FormValue(zoneNameKey)
 	description = r.FormValue(descriptionKey)
@@ -2300,6 +3415,29 @@ func parseRequestToLoadDataPartition(r *http.Request) (ID uint64, err error) {
 	return
 }
 
+func parseRequestToCheckDataPartition(r *http.Request) (ID uint64, repair bool, err error) {
+	if ID, err = parseRequestToLoadDataPartition(r); err != nil {
+		return
+	}
+	if value := r.FormValue(repairKey); value != "" {
+		if repair, err = strconv.ParseBool(value); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func parseRequestToRestoreDataPartition(r *http.Request) (ID uint64, path string, err error) {
+	if ID, err = parseRequestToLoadDataPartition(r); err != nil {
+		return
+	}
+	if path = strings.Trim(r.FormValue(pathKey), "/"); path == "" {
+		err = keyNotFound(pathKey)
+		return
+	}
+	return
+}
+
 func parseRequestToAddMetaReplica(r *http.Request) (ID uint64, addr string, err error) {
 	return extractMetaPartitionIDAndAddr(r)
 }
@@ -2462,6 +3600,18 @@ func extractDefaulPriority(r *http.Request) (defaultPrior bool, err error) {
 	return
 }
 
+func extractCaseInsensitive(r *http.Request) (caseInsensitive bool, err error) {
+	var value string
+	if value = r.FormValue(caseInsensitiveKey); value == "" {
+		caseInsensitive = false
+		return
+	}
+	if caseInsensitive, err = strconv.ParseBool(value); err != nil {
+		return
+	}
+	return
+}
+
 func parseAndExtractThreshold(r *http.Request) (threshold float64, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -2536,6 +3686,19 @@ func parseAndExtractSetNodeInfoParams(r *http.Request) (params map[string]interf
 		params[nodeAutoRepairRateKey] = val
 	}
 
+	for _, key := range []string{nodeDiskClientIORateKey, nodeDiskRepairIORateKey} {
+		if value = r.FormValue(key); value != "" {
+			noParams = false
+			var val = uint64(0)
+			val, err = strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				err = unmatchedKey(key)
+				return
+			}
+			params[key] = val
+		}
+	}
+
 	if value = r.FormValue(nodeDeleteWorkerSleepMs); value != "" {
 		noParams = false
 		var val = uint64(0)
@@ -2613,8 +3776,14 @@ func sendOkReply(w http.ResponseWriter, r *http.Request, httpReply *proto.HTTPRe
 
 func send(w http.ResponseWriter, r *http.Request, reply []byte) {
 	w.Header().Set("content-type", "application/json")
-	w.Header().Set("Content-Length", strconv.Itoa(len(reply)))
-	if _, err := w.Write(reply); err != nil {
+	if notModified(w, r, reply) {
+		w.WriteHeader(http.StatusNotModified)
+		log.LogInfof("URL[%v],remoteAddr[%v],response not modified", r.URL, r.RemoteAddr)
+		return
+	}
+	body := compressReply(w, r, reply)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if _, err := w.Write(body); err != nil {
 		log.LogErrorf("fail to write http reply[%s] len[%d].URL[%v],remoteAddr[%v] err:[%v]", string(reply), len(reply), r.URL, r.RemoteAddr, err)
 		return
 	}
@@ -2727,7 +3896,7 @@ func (m *Server) getVol(w http.ResponseWriter, r *http.Request) {
 		viewCache = vol.getViewCache()
 	}
 	if !param.skipOwnerValidation && vol.authenticate {
-		if jobj, ticket, ts, err = parseAndCheckTicket(r, m.cluster.MasterSecretKey, param.name); err != nil {
+		if jobj, ticket, ts, err = parseAndCheckTicket(r, m.cluster.serviceKeys, param.name); err != nil {
 			if err == proto.ErrExpiredTicket {
 				sendErrReply(w, r, newErrHTTPReply(err))
 				return
@@ -2846,6 +4015,7 @@ func (m *Server) getMetaPartition(w http.ResponseWriter, r *http.Request) {
 			IsRecover:     mp.IsRecover,
 			Hosts:         mp.Hosts,
 			Peers:         mp.Peers,
+			Learners:      mp.Learners,
 			Zones:         zones,
 			MissNodes:     mp.MissNodes,
 			OfflinePeerID: mp.OfflinePeerID,
@@ -2883,6 +4053,32 @@ func (m *Server) listVols(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(volsInfo))
 }
 
+// getQuotaUsage returns the usage of the given volume, or of all the volumes if no name is given.
+func (m *Server) getQuotaUsage(w http.ResponseWriter, r *http.Request) {
+	var (
+		err    error
+		vol    *Vol
+		usages []*proto.VolQuotaUsage
+	)
+	if err = r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	names := []string{r.FormValue(nameKey)}
+	if names[0] == "" {
+		names = m.cluster.allVolNames()
+	}
+	usages = make([]*proto.VolQuotaUsage, 0, len(names))
+	for _, name := range names {
+		if vol, err = m.cluster.getVol(name); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+			return
+		}
+		usages = append(usages, vol.quotaUsage())
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(usages))
+}
+
 func parseAndExtractPartitionInfo(r *http.Request) (partitionID uint64, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -2953,7 +4149,7 @@ func extractOwner(r *http.Request) (owner string, err error) {
 	return
 }
 
-func parseAndCheckTicket(r *http.Request, key []byte, volName string) (jobj proto.APIAccessReq, ticket cryptoutil.Ticket, ts int64, err error) {
+func parseAndCheckTicket(r *http.Request, keys *serviceKeyManager, volName string) (jobj proto.APIAccessReq, ticket cryptoutil.Ticket, ts int64, err error) {
 	var (
 		plaintext []byte
 	)
@@ -2974,7 +4170,7 @@ func parseAndCheckTicket(r *http.Request, key []byte, volName string) (jobj prot
 		return
 	}
 
-	ticket, ts, err = extractTicketMess(&jobj, key, volName)
+	ticket, ts, err = extractTicketMess(&jobj, keys, volName)
 
 	return
 }
@@ -2999,8 +4195,45 @@ func extractClientReqInfo(r *http.Request) (plaintext []byte, err error) {
 	return
 }
 
-func extractTicketMess(req *proto.APIAccessReq, key []byte, volName string) (ticket cryptoutil.Ticket, ts int64, err error) {
-	if ticket, err = proto.ExtractTicket(req.Ticket, key); err != nil {
+// extractTicketMess decrypts the ticket with the version of the master service key embedded in it.
+func extractTicketMess(req *proto.APIAccessReq, keys *serviceKeyManager, volName string) (ticket cryptoutil.Ticket, ts int64, err error) {
+	if ticket, ts, err = extractAPITicket(req, keys); err != nil {
+		return
+	}
+	if err = proto.CheckVOLAccessCaps(&ticket, volName, proto.VOLAccess, proto.MasterNode); err != nil {
+		err = fmt.Errorf("CheckVOLAccessCaps failed: %s", err.Error())
+		return
+	}
+	return
+}
+
+// checkAdminTicket checks the ticket of the request of the admin API, which must have the caps of the API.
+func checkAdminTicket(req *proto.APIAccessReq, keys *serviceKeyManager, msg proto.MsgType) (ticket cryptoutil.Ticket, err error) {
+	if req.Type != msg {
+		err = fmt.Errorf("invalid request type [%x]", req.Type)
+		return
+	}
+	if err = proto.VerifyAPIAccessReqIDs(req); err != nil {
+		return
+	}
+	ticket, _, err = extractAPITicket(req, keys)
+	return
+}
+
+// extractAPITicket decrypts the ticket and checks the verifier and the caps of the API of the request.
+func extractAPITicket(req *proto.APIAccessReq, keys *serviceKeyManager) (ticket cryptoutil.Ticket, ts int64, err error) {
+	var (
+		keyID     uint32
+		encrypted string
+		key       []byte
+	)
+	if keyID, encrypted, err = proto.DecodeTicketKeyID(req.Ticket); err != nil {
+		return
+	}
+	if key, err = keys.getKey(keyID); err != nil {
+		return
+	}
+	if ticket, err = proto.ExtractTicket(encrypted, key); err != nil {
 		err = fmt.Errorf("extractTicket failed: %s", err.Error())
 		return
 	}
@@ -3016,10 +4249,6 @@ func extractTicketMess(req *proto.APIAccessReq, key []byte, volName string) (tic
 		err = fmt.Errorf("CheckAPIAccessCaps failed: %s", err.Error())
 		return
 	}
-	if err = proto.CheckVOLAccessCaps(&ticket, volName, proto.VOLAccess, proto.MasterNode); err != nil {
-		err = fmt.Errorf("CheckVOLAccessCaps failed: %s", err.Error())
-		return
-	}
 	return
 }
 
@@ -102,7 +102,7 @@ func createDefaultMasterServerForTest() *Server {
 	testServer.cluster.checkMetaNodeHeartbeat()
 	time.Sleep(5 * time.Second)
 	testServer.cluster.scheduleToUpdateStatInfo()
-	vol, err := testServer.cluster.createVol(commonVolName, "cfs", testZone2, "", 3, 3, 3, 100, false, false, false, false)
+	vol, err := testServer.cluster.createVol(commonVolName, "cfs", testZone2, "", 3, 3, 3, 100, false, false, false, false, false, false, proto.ChecksumCrc32)
 	if err != nil {
 		panic(err)
 	}
@@ -526,6 +526,38 @@ func TestAddMetaReplica(t *testing.T) {
 	partition.RUnlock()
 }
 
+func TestAddAndPromoteMetaReplicaLearner(t *testing.T) {
+	maxPartitionID := commonVol.maxPartitionID()
+	partition := commonVol.MetaPartitions[maxPartitionID]
+	if partition == nil {
+		t.Error("no meta partition")
+		return
+	}
+	msAddr := "127.0.0.1:8010"
+	addMetaServer(msAddr, testZone2)
+	server.cluster.checkMetaNodeHeartbeat()
+	time.Sleep(2 * time.Second)
+	reqURL := fmt.Sprintf("%v%v?id=%v&addr=%v", hostAddr, proto.AdminAddMetaReplicaLearner, partition.PartitionID, msAddr)
+	process(reqURL, t)
+	partition.RLock()
+	if !contains(partition.Hosts, msAddr) || !partition.isLearner(msAddr) {
+		t.Errorf("hosts[%v] learners[%v] should contain msAddr[%v]", partition.Hosts, partition.Learners, msAddr)
+		partition.RUnlock()
+		return
+	}
+	partition.RUnlock()
+	reqURL = fmt.Sprintf("%v%v?id=%v&addr=%v", hostAddr, proto.AdminPromoteMetaReplicaLearner, partition.PartitionID, msAddr)
+	process(reqURL, t)
+	partition.RLock()
+	if partition.isLearner(msAddr) {
+		t.Errorf("learners[%v] should not contain msAddr[%v]", partition.Learners, msAddr)
+	}
+	partition.RUnlock()
+	partition.IsRecover = false
+	reqURL = fmt.Sprintf("%v%v?id=%v&addr=%v", hostAddr, proto.AdminDeleteMetaReplica, partition.PartitionID, msAddr)
+	process(reqURL, t)
+}
+
 func TestRemoveMetaReplica(t *testing.T) {
 	maxPartitionID := commonVol.maxPartitionID()
 	partition := commonVol.MetaPartitions[maxPartitionID]
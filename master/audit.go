@@ -0,0 +1,147 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/iputil"
+	"github.com/cubefs/cubefs/util/reqid"
+)
+
+const auditRedacted = "******"
+
+// the requests polled by the clients and the nodes are not audited
+var unauditedPaths = map[string]bool{
+	proto.ClientDataPartitions:       true,
+	proto.ClientVol:                  true,
+	proto.ClientMetaPartition:        true,
+	proto.ClientVolStat:              true,
+	proto.ClientMetaPartitions:       true,
+	proto.ClientRateLimits:           true,
+	proto.GetDataNodeTaskResponse:    true,
+	proto.GetMetaNodeTaskResponse:    true,
+	proto.ReportDataNodeLoadProgress: true,
+	"/metrics":                       true,
+}
+
+// adminAuditRecord is the audit record of a request served by the leader, or by a follower if it is a follower read.
+type adminAuditRecord struct {
+	Time       time.Time         `json:"time"`
+	RequestID  string            `json:"requestID"`
+	RemoteIP   string            `json:"remoteIP"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Params     map[string]string `json:"params,omitempty"`
+	StatusCode int               `json:"statusCode"`
+	Code       int32             `json:"code"` // the code of the reply, see proto.HTTPReply
+	Msg        string            `json:"msg,omitempty"`
+	LatencyMs  int64             `json:"latencyMs"`
+}
+
+// auditResponseWriter records the status and the reply of the response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	reply      []byte
+}
+
+func (w *auditResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *auditResponseWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	if w.reply == nil {
+		w.reply = p
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// serveAudited serves the request, and records it to the audit sinks if they are configured.
+func (m *Server) serveAudited(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if m.auditLogger == nil || unauditedPaths[r.URL.Path] {
+		next.ServeHTTP(w, r)
+		return
+	}
+	var start = time.Now()
+	var aw = &auditResponseWriter{ResponseWriter: w}
+	next.ServeHTTP(aw, r)
+	var rec = &adminAuditRecord{
+		Time:       start.UTC(),
+		RequestID:  reqid.FromHeader(r.Header).String(),
+		RemoteIP:   iputil.RealIP(r),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Params:     auditParams(r),
+		StatusCode: aw.statusCode,
+		LatencyMs:  int64(time.Since(start) / time.Millisecond),
+	}
+	rec.Code, rec.Msg = parseReplyCode(aw.reply)
+	m.auditLogger.Log(r.URL.Path, rec)
+}
+
+// auditParams returns the query parameters of the request, whose secrets are redacted.
+func auditParams(r *http.Request) (params map[string]string) {
+	var query = r.URL.Query()
+	if len(query) == 0 {
+		return
+	}
+	params = make(map[string]string, len(query))
+	for key, values := range query {
+		if key == volAuthKey || (key == serviceKeyKey && r.URL.Path == proto.AdminAddServiceKey) {
+			params[key] = auditRedacted
+			continue
+		}
+		params[key] = strings.Join(values, ",")
+	}
+	return
+}
+
+// parseReplyCode returns the code and the message of the reply in proto.HTTPReply, which are its leading
+// fields, without decoding the data of the reply.
+func parseReplyCode(reply []byte) (code int32, msg string) {
+	var decoder = json.NewDecoder(bytes.NewReader(reply))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		switch token {
+		case "code":
+			if err = decoder.Decode(&code); err != nil {
+				return
+			}
+		case "msg":
+			if err = decoder.Decode(&msg); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package master
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestParseReplyCode(t *testing.T) {
+	code, msg := parseReplyCode([]byte(`{"code":2,"msg":"param error","data":{"name":"vol"}}`))
+	if code != proto.ErrCodeParamError || msg != "param error" {
+		t.Errorf("unexpected reply code(%v) msg(%v)", code, msg)
+	}
+	if code, msg = parseReplyCode([]byte("not json")); code != 0 || msg != "" {
+		t.Errorf("unexpected reply code(%v) msg(%v)", code, msg)
+	}
+}
+
+func TestAuditParams(t *testing.T) {
+	r := httptest.NewRequest("GET", fmt.Sprintf("%v?name=vol&authKey=secret", proto.AdminDeleteVol), nil)
+	if params := auditParams(r); params[nameKey] != "vol" || params[volAuthKey] != auditRedacted {
+		t.Errorf("unexpected params %v", params)
+	}
+	r = httptest.NewRequest("GET", fmt.Sprintf("%v?id=1&key=secret", proto.AdminAddServiceKey), nil)
+	if params := auditParams(r); params[serviceKeyKey] != auditRedacted {
+		t.Errorf("unexpected params %v", params)
+	}
+	r = httptest.NewRequest("GET", fmt.Sprintf("%v?key=s3qps:ak&rate=1", proto.AdminSetRateLimit), nil)
+	if params := auditParams(r); params[rateLimitKeyKey] != "s3qps:ak" {
+		t.Errorf("unexpected params %v", params)
+	}
+}
@@ -16,6 +16,7 @@ package master
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,12 +34,16 @@ type Cluster struct {
 	vols                      map[string]*Vol
 	dataNodes                 sync.Map
 	metaNodes                 sync.Map
+	flashNodes                sync.Map
 	dpMutex                   sync.Mutex   // data partition mutex
 	volMutex                  sync.RWMutex // volume mutex
 	createVolMutex            sync.RWMutex // create volume mutex
 	mnMutex                   sync.RWMutex // meta node mutex
 	dnMutex                   sync.RWMutex // data node mutex
+	fnMutex                   sync.Mutex   // flash node mutex
 	badPartitionMutex         sync.RWMutex // BadDataPartitionIds and BadMetaPartitionIds operate mutex
+	badDiskMutex              sync.Mutex   // serializes the repairs of the bad disks
+	serviceKeysMutex          sync.Mutex   // serializes the changes of the master service keys
 	leaderInfo                *LeaderInfo
 	cfg                       *clusterConfig
 	retainLogs                uint64
@@ -61,6 +66,15 @@ type Cluster struct {
 	lastMasterZoneForMetaNode string
 	zoneList                  []string
 	followerReadManager       *followerReadManager
+	metaLeaderBalancer        *metaLeaderBalancer
+	degradedDisks             *degradedDiskManager
+	encryptKeys               *encryptKeyManager
+	serviceKeys               *serviceKeyManager
+	rateLimits                *rateLimitManager
+	quotas                    *quotaManager
+	volSnapshots              *volSnapshotManager
+	coldTier                  *coldTierManager
+	slos                      *sloManager
 }
 
 type followerReadManager struct {
@@ -136,6 +150,15 @@ func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition
 	c.FaultDomain = cfg.faultDomain
 	c.zoneStatInfos = make(map[string]*proto.ZoneStat)
 	c.followerReadManager = newFollowerReadManager()
+	c.metaLeaderBalancer = new(metaLeaderBalancer)
+	c.degradedDisks = newDegradedDiskManager()
+	c.encryptKeys, _ = newEncryptKeyManager("", nil)
+	c.serviceKeys = newServiceKeyManager()
+	c.rateLimits = newRateLimitManager()
+	c.quotas = newQuotaManager()
+	c.volSnapshots = newVolSnapshotManager()
+	c.coldTier = newColdTierManager()
+	c.slos = newSLOManager()
 	c.fsm = fsm
 	c.partition = partition
 	c.idAlloc = newIDAllocator(c.fsm.store, c.partition)
@@ -159,6 +182,10 @@ func (c *Cluster) scheduleTask() {
 	c.scheduleToReduceReplicaNum()
 	c.scheduleToCheckNodeSetGrpManagerStatus()
 	c.scheduleToCheckFollowerReadCache()
+	c.scheduleToBalanceMetaPartitionLeaders()
+	c.scheduleToCheckDegradedDisks()
+	c.scheduleToConvertColdDataPartitions()
+	c.scheduleToMigrateColdFiles()
 }
 
 func (c *Cluster) masterAddr() (addr string) {
@@ -203,6 +230,7 @@ func (c *Cluster) scheduleToCheckAutoDataPartitionCreation() {
 		for {
 			if c.partition != nil && c.partition.IsRaftLeader() {
 				vols := c.copyVols()
+				c.quotas.check(vols)
 				for _, vol := range vols {
 					vol.checkAutoDataPartitionCreation(c)
 				}
@@ -359,6 +387,15 @@ func (c *Cluster) scheduleToCheckHeartbeat() {
 			time.Sleep(time.Second * defaultIntervalToCheckHeartbeat)
 		}
 	}()
+
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.checkFlashNodeHeartbeat()
+			}
+			time.Sleep(time.Second * defaultIntervalToCheckHeartbeat)
+		}
+	}()
 }
 
 func (c *Cluster) checkLeaderAddr() {
@@ -368,10 +405,14 @@ func (c *Cluster) checkLeaderAddr() {
 
 func (c *Cluster) checkDataNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	volCompressions := c.getVolCompressions()
+	volEncryptKeys := c.getVolEncryptKeys()
+	volWriteLimits := c.getVolWriteLimits()
+	replicaAddrs := c.getDataNodeReplicaAddrs()
 	c.dataNodes.Range(func(addr, dataNode interface{}) bool {
 		node := dataNode.(*DataNode)
 		node.checkLiveness()
-		task := node.createHeartbeatTask(c.masterAddr())
+		task := node.createHeartbeatTask(c.masterAddr(), volCompressions, volEncryptKeys, volWriteLimits, c.repairLimitOf(node), replicaAddrs)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -380,10 +421,12 @@ func (c *Cluster) checkDataNodeHeartbeat() {
 
 func (c *Cluster) checkMetaNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	replicaAddrs := c.getMetaNodeReplicaAddrs()
+	quotaTokens := c.quotas.volTokens()
 	c.metaNodes.Range(func(addr, metaNode interface{}) bool {
 		node := metaNode.(*MetaNode)
 		node.checkHeartbeat()
-		task := node.createHeartbeatTask(c.masterAddr())
+		task := node.createHeartbeatTask(c.masterAddr(), replicaAddrs, c.cfg.BlobStoreAddrs, quotaTokens)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -673,7 +716,7 @@ func (c *Cluster) checkCorruptDataPartitions() (inactiveDataNodes []string, corr
 	corruptPartitions = make([]*DataPartition, 0)
 	c.dataNodes.Range(func(addr, node interface{}) bool {
 		dataNode := node.(*DataNode)
-		if !dataNode.isActive {
+		if !dataNode.isActive && !dataNode.isLoading() {
 			inactiveDataNodes = append(inactiveDataNodes, dataNode.Addr)
 		}
 		return true
@@ -762,6 +805,7 @@ func (c *Cluster) deleteVol(name string) {
 	c.volMutex.Lock()
 	defer c.volMutex.Unlock()
 	delete(c.vols, name)
+	c.slos.remove(name)
 	return
 }
 
@@ -873,7 +917,7 @@ func (c *Cluster) createDataPartition(volName string, zoneNum int) (dp *DataPart
 				wg.Done()
 			}()
 			var diskPath string
-			if diskPath, err = c.syncCreateDataPartitionToDataNode(host, vol.dataPartitionSize, dp, dp.Peers, dp.Hosts, proto.NormalCreateDataPartition); err != nil {
+			if diskPath, err = c.syncCreateDataPartitionToDataNode(host, vol.dataPartitionSize, dp, dp.Peers, dp.Hosts, proto.NormalCreateDataPartition, vol.encrypted, vol.checksumType); err != nil {
 				errChannel <- err
 				return
 			}
@@ -922,12 +966,17 @@ errHandler:
 	return
 }
 
-func (c *Cluster) syncCreateDataPartitionToDataNode(host string, size uint64, dp *DataPartition, peers []proto.Peer, hosts []string, createType int) (diskPath string, err error) {
-	task := dp.createTaskToCreateDataPartition(host, size, peers, hosts, createType)
+func (c *Cluster) syncCreateDataPartitionToDataNode(host string, size uint64, dp *DataPartition, peers []proto.Peer, hosts []string, createType int, encrypted bool, checksumType uint8) (diskPath string, err error) {
+	task := dp.createTaskToCreateDataPartition(host, size, peers, hosts, createType, encrypted, checksumType)
 	dataNode, err := c.dataNode(host)
 	if err != nil {
 		return
 	}
+	// the data nodes not knowing crc32c would keep the partition with crc32
+	if checksumType == proto.ChecksumCrc32c && !dataNode.Crc32cSupported {
+		err = fmt.Errorf("data node(%v) does not support the checksum %v", host, proto.ChecksumCrc32cName)
+		return
+	}
 	var resp *proto.Packet
 	if resp, err = dataNode.TaskManager.syncSendAdminTask(task); err != nil {
 		return
@@ -1406,6 +1455,11 @@ func (c *Cluster) validateDecommissionDataPartition(dp *DataPartition, offlineAd
 		return
 	}
 
+	if dp.ECStatus != proto.ECStatusNone {
+		err = fmt.Errorf("vol[%v],data partition[%v] is erasure coded,[%v] can't be decommissioned", vol.Name, dp.PartitionID, offlineAddr)
+		return
+	}
+
 	// if the partition can be offline or not
 	if err = dp.canBeOffLine(offlineAddr); err != nil {
 		return
@@ -1424,6 +1478,10 @@ func (c *Cluster) addDataReplica(dp *DataPartition, addr string) (err error) {
 			log.LogErrorf("action[addDataReplica],vol[%v],data partition[%v],err[%v]", dp.VolName, dp.PartitionID, err)
 		}
 	}()
+	if dp.ECStatus != proto.ECStatusNone {
+		err = proto.ErrDataPartitionEC
+		return
+	}
 	dataNode, err := c.dataNode(addr)
 	if err != nil {
 		return
@@ -1513,7 +1571,7 @@ func (c *Cluster) createDataReplica(dp *DataPartition, addPeer proto.Peer) (err
 	peers := make([]proto.Peer, len(dp.Peers))
 	copy(peers, dp.Peers)
 	dp.RUnlock()
-	diskPath, err := c.syncCreateDataPartitionToDataNode(addPeer.Addr, vol.dataPartitionSize, dp, peers, hosts, proto.DecommissionedCreateDataPartition)
+	diskPath, err := c.syncCreateDataPartitionToDataNode(addPeer.Addr, vol.dataPartitionSize, dp, peers, hosts, proto.DecommissionedCreateDataPartition, vol.encrypted, vol.checksumType)
 	if err != nil {
 		return
 	}
@@ -1841,6 +1899,13 @@ func (c *Cluster) updateVol(name, authKey string, newArgs *VolVarargs) (err erro
 		oldDescription    string
 		oldDpSelectorName string
 		oldDpSelectorParm string
+		oldCompression    string
+		oldWriteLimit     uint64
+		oldObjQuotaBytes  uint64
+		oldObjQuotaCount  uint64
+		oldSLO            *proto.VolSLO
+		oldFlashCache     bool
+		oldMaxInodes      uint64
 		volUsedSpace      uint64
 		newZoneName       string
 	)
@@ -1879,6 +1944,13 @@ func (c *Cluster) updateVol(name, authKey string, newArgs *VolVarargs) (err erro
 	oldDescription = vol.description
 	oldDpSelectorName = vol.dpSelectorName
 	oldDpSelectorParm = vol.dpSelectorParm
+	oldCompression = vol.compression
+	oldWriteLimit = vol.writeLimit
+	oldObjQuotaBytes = vol.objQuotaBytes
+	oldObjQuotaCount = vol.objQuotaCount
+	oldSLO = vol.slo
+	oldFlashCache = vol.flashCache
+	oldMaxInodes = vol.maxInodes
 
 	vol.zoneName = newArgs.zoneName
 	vol.Capacity = newArgs.capacity
@@ -1893,6 +1965,13 @@ func (c *Cluster) updateVol(name, authKey string, newArgs *VolVarargs) (err erro
 	}
 	vol.dpSelectorName = newArgs.dpSelectorName
 	vol.dpSelectorParm = newArgs.dpSelectorParm
+	vol.compression = newArgs.compression
+	vol.writeLimit = newArgs.writeLimit
+	vol.objQuotaBytes = newArgs.objQuotaBytes
+	vol.objQuotaCount = newArgs.objQuotaCount
+	vol.slo = newArgs.slo
+	vol.flashCache = newArgs.flashCache
+	vol.maxInodes = newArgs.maxInodes
 
 	if err = c.syncUpdateVol(vol); err != nil {
 		vol.Capacity = oldCapacity
@@ -1903,6 +1982,13 @@ func (c *Cluster) updateVol(name, authKey string, newArgs *VolVarargs) (err erro
 		vol.description = oldDescription
 		vol.dpSelectorName = oldDpSelectorName
 		vol.dpSelectorParm = oldDpSelectorParm
+		vol.compression = oldCompression
+		vol.writeLimit = oldWriteLimit
+		vol.objQuotaBytes = oldObjQuotaBytes
+		vol.objQuotaCount = oldObjQuotaCount
+		vol.slo = oldSLO
+		vol.flashCache = oldFlashCache
+		vol.maxInodes = oldMaxInodes
 
 		log.LogErrorf("action[updateVol] vol[%v] err[%v]", name, err)
 		err = proto.ErrPersistenceByRaft
@@ -1962,7 +2048,7 @@ func (c *Cluster) checkVolInfo(name string, crossZone bool, zoneName string) (ne
 // By default we create 3 meta partitions and 10 data partitions during initialization.
 func (c *Cluster) createVol(name, owner, zoneName, description string,
 	mpCount, dpReplicaNum, size, capacity int,
-	followerRead, authenticate, crossZone, defaultPriority bool) (vol *Vol, err error) {
+	followerRead, authenticate, crossZone, defaultPriority, caseInsensitive, encrypted bool, checksumType uint8) (vol *Vol, err error) {
 	var (
 		dataPartitionSize       uint64
 		readWriteDataPartitions int
@@ -1986,7 +2072,7 @@ func (c *Cluster) createVol(name, owner, zoneName, description string,
 	if vol, err = c.doCreateVol(name, owner, zoneName, description,
 		dataPartitionSize, uint64(capacity), dpReplicaNum,
 		followerRead, authenticate, crossZone,
-		defaultPriority); err != nil {
+		defaultPriority, caseInsensitive, encrypted, checksumType); err != nil {
 		goto errHandler
 	}
 	if err = vol.initMetaPartitions(c, mpCount); err != nil {
@@ -2018,7 +2104,7 @@ errHandler:
 func (c *Cluster) doCreateVol(name, owner, zoneName, description string,
 	dpSize, capacity uint64, dpReplicaNum int,
 	followerRead, authenticate, crossZone,
-	defaultPriority bool) (vol *Vol, err error) {
+	defaultPriority, caseInsensitive, encrypted bool, checksumType uint8) (vol *Vol, err error) {
 	var id uint64
 	c.createVolMutex.Lock()
 	defer c.createVolMutex.Unlock()
@@ -2034,9 +2120,15 @@ func (c *Cluster) doCreateVol(name, owner, zoneName, description string,
 	vol = newVol(id, name, owner, zoneName, dpSize,
 		capacity, uint8(dpReplicaNum), defaultReplicaNum,
 		followerRead, authenticate, crossZone,
-		defaultPriority, createTime, description)
+		defaultPriority, caseInsensitive, createTime, description)
+	vol.checksumType = checksumType
 	// refresh oss secure
 	vol.refreshOSSSecure()
+	if encrypted {
+		if err = c.initVolEncryptKey(vol); err != nil {
+			goto errHandler
+		}
+	}
 	if err = c.syncAddVol(vol); err != nil {
 		goto errHandler
 	}
@@ -2194,7 +2286,8 @@ func (c *Cluster) allDataNodes() (dataNodes []proto.NodeView) {
 	dataNodes = make([]proto.NodeView, 0)
 	c.dataNodes.Range(func(addr, node interface{}) bool {
 		dataNode := node.(*DataNode)
-		dataNodes = append(dataNodes, proto.NodeView{Addr: dataNode.Addr, Status: dataNode.isActive, ID: dataNode.ID, IsWritable: dataNode.isWriteAble()})
+		dataNodes = append(dataNodes, proto.NodeView{Addr: dataNode.Addr, Status: dataNode.isActive, ID: dataNode.ID, IsWritable: dataNode.isWriteAble(),
+			ZoneName: dataNode.ZoneName, Rack: dataNode.Rack})
 		return true
 	})
 	return
@@ -2243,6 +2336,122 @@ func (c *Cluster) allVols() (vols map[string]*Vol) {
 	return
 }
 
+// getHotDataPartitions returns the data partitions accessed the most within the last windows
+// of the access statistics of the data nodes.
+func (c *Cluster) getHotDataPartitions(count int) (hots []*proto.HotDataPartition) {
+	hots = make([]*proto.HotDataPartition, 0)
+	for _, vol := range c.allVols() {
+		for _, dp := range vol.cloneDataPartitionMap() {
+			stats := dp.accessStats()
+			if stats.Ops() == 0 {
+				continue
+			}
+			hots = append(hots, &proto.HotDataPartition{PartitionID: dp.PartitionID, VolName: dp.VolName, AccessStats: stats})
+		}
+	}
+	sort.Slice(hots, func(i, j int) bool {
+		return hots[i].Ops() > hots[j].Ops()
+	})
+	if len(hots) > count {
+		hots = hots[:count]
+	}
+	return
+}
+
+// getVolCompressions returns the compressions of the volumes which store the data compressed.
+func (c *Cluster) getVolCompressions() (compressions map[string]string) {
+	compressions = make(map[string]string)
+	for name, vol := range c.allVols() {
+		if vol.compression != "" {
+			compressions[name] = vol.compression
+		}
+	}
+	return
+}
+
+// getVolWriteLimits returns the write limits of the volumes whose writes are limited.
+func (c *Cluster) getVolWriteLimits() (limits map[string]uint64) {
+	limits = make(map[string]uint64)
+	for name, vol := range c.allVols() {
+		if vol.writeLimit != 0 {
+			limits[name] = vol.writeLimit
+		}
+	}
+	return
+}
+
+// getDataNodeReplicaAddrs returns the replication addresses of the data nodes which have a dedicated
+// replication network, indexed by their service addresses.
+func (c *Cluster) getDataNodeReplicaAddrs() (addrs map[string]string) {
+	addrs = make(map[string]string)
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		if replicaAddr := node.(*DataNode).ReplicaAddr; replicaAddr != "" {
+			addrs[addr.(string)] = replicaAddr
+		}
+		return true
+	})
+	return
+}
+
+// getMetaNodeReplicaAddrs returns the replication addresses of the meta nodes which have a dedicated
+// replication network, indexed by their service addresses.
+func (c *Cluster) getMetaNodeReplicaAddrs() (addrs map[string]string) {
+	addrs = make(map[string]string)
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		if replicaAddr := node.(*MetaNode).ReplicaAddr; replicaAddr != "" {
+			addrs[addr.(string)] = replicaAddr
+		}
+		return true
+	})
+	return
+}
+
+// setDataNodeReplicaAddr records the replication address the data node registers with.
+func (c *Cluster) setDataNodeReplicaAddr(nodeAddr, replicaAddr string) (err error) {
+	c.dnMutex.Lock()
+	defer c.dnMutex.Unlock()
+	dataNode, err := c.dataNode(nodeAddr)
+	if err != nil {
+		return
+	}
+	if dataNode.ReplicaAddr == replicaAddr {
+		return
+	}
+	oldAddr := dataNode.ReplicaAddr
+	dataNode.ReplicaAddr = replicaAddr
+	if err = c.syncUpdateDataNode(dataNode); err != nil {
+		log.LogErrorf("action[setDataNodeReplicaAddr] dataNode[%v] replicaAddr[%v] err[%v]", nodeAddr, replicaAddr, err)
+		dataNode.ReplicaAddr = oldAddr
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	log.LogInfof("action[setDataNodeReplicaAddr] dataNode[%v] replicaAddr[%v] -> [%v]", nodeAddr, oldAddr, replicaAddr)
+	return
+}
+
+// setMetaNodeReplicaAddr records the replication address the meta node registers with.
+func (c *Cluster) setMetaNodeReplicaAddr(nodeAddr, replicaAddr string) (err error) {
+	c.mnMutex.Lock()
+	defer c.mnMutex.Unlock()
+	metaNode, err := c.metaNode(nodeAddr)
+	if err != nil {
+		return
+	}
+	if metaNode.ReplicaAddr == replicaAddr {
+		return
+	}
+	oldAddr := metaNode.ReplicaAddr
+	metaNode.ReplicaAddr = replicaAddr
+	if err = c.syncUpdateMetaNode(metaNode); err != nil {
+		log.LogErrorf("action[setMetaNodeReplicaAddr] metaNode[%v] replicaAddr[%v] err[%v]", nodeAddr, replicaAddr, err)
+		metaNode.ReplicaAddr = oldAddr
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	log.LogInfof("action[setMetaNodeReplicaAddr] metaNode[%v] replicaAddr[%v] -> [%v]", nodeAddr, oldAddr, replicaAddr)
+	return
+}
+
 func (c *Cluster) getDataPartitionCount() (count int) {
 	c.volMutex.RLock()
 	defer c.volMutex.RUnlock()
@@ -2308,6 +2517,94 @@ func (c *Cluster) setDataNodeAutoRepairLimitRate(val uint64) (err error) {
 	return
 }
 
+// setDataNodeRepairLimit overrides the repair limits of the cluster on a data node, the
+// limits not given are taken from the ones in effect. The override is removed if clearLimit is set.
+func (c *Cluster) setDataNodeRepairLimit(nodeAddr string, params map[string]uint64, windows []*proto.RepairLimitWindow, clearLimit bool) (err error) {
+	c.dnMutex.Lock()
+	defer c.dnMutex.Unlock()
+	dataNode, err := c.dataNode(nodeAddr)
+	if err != nil {
+		return
+	}
+	oldLimit := dataNode.RepairLimit
+	var limit *proto.DataNodeRepairLimit
+	if !clearLimit {
+		limit = c.repairLimitOf(dataNode)
+		if val, ok := params[nodeAutoRepairRateKey]; ok {
+			limit.ExtentRepairLimit = val
+		}
+		if val, ok := params[nodeDiskRepairIORateKey]; ok {
+			limit.DiskRepairIOLimitRate = val
+		}
+		if windows != nil {
+			limit.Windows = nil
+			if len(windows) > 0 {
+				limit.Windows = windows
+			}
+		}
+	}
+	dataNode.RepairLimit = limit
+	if err = c.syncUpdateDataNode(dataNode); err != nil {
+		log.LogErrorf("action[setDataNodeRepairLimit] dataNode[%v] err[%v]", nodeAddr, err)
+		dataNode.RepairLimit = oldLimit
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	log.LogInfof("action[setDataNodeRepairLimit] dataNode[%v] limit[%+v]", nodeAddr, limit)
+	return
+}
+
+// repairLimitOf returns the repair limits in effect on a data node.
+func (c *Cluster) repairLimitOf(dataNode *DataNode) *proto.DataNodeRepairLimit {
+	if limit := dataNode.RepairLimit; limit != nil {
+		copied := *limit
+		return &copied
+	}
+	return &proto.DataNodeRepairLimit{
+		ExtentRepairLimit:     atomic.LoadUint64(&c.cfg.DataNodeAutoRepairLimitRate),
+		DiskRepairIOLimitRate: atomic.LoadUint64(&c.cfg.DataNodeDiskRepairIOLimitRate),
+		Windows:               c.cfg.repairWindows(),
+	}
+}
+
+// setDataNodeRepairWindows sets the repair limits of the cluster within time windows, nil
+// to remove the windows.
+func (c *Cluster) setDataNodeRepairWindows(windows []*proto.RepairLimitWindow) (err error) {
+	oldWindows := c.cfg.repairWindows()
+	c.cfg.setRepairWindows(windows)
+	if err = c.syncPutCluster(); err != nil {
+		log.LogErrorf("action[setDataNodeRepairWindows] err[%v]", err)
+		c.cfg.setRepairWindows(oldWindows)
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+func (c *Cluster) setDataNodeDiskClientIOLimitRate(val uint64) (err error) {
+	oldVal := atomic.LoadUint64(&c.cfg.DataNodeDiskClientIOLimitRate)
+	atomic.StoreUint64(&c.cfg.DataNodeDiskClientIOLimitRate, val)
+	if err = c.syncPutCluster(); err != nil {
+		log.LogErrorf("action[setDataNodeDiskClientIOLimitRate] err[%v]", err)
+		atomic.StoreUint64(&c.cfg.DataNodeDiskClientIOLimitRate, oldVal)
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
+func (c *Cluster) setDataNodeDiskRepairIOLimitRate(val uint64) (err error) {
+	oldVal := atomic.LoadUint64(&c.cfg.DataNodeDiskRepairIOLimitRate)
+	atomic.StoreUint64(&c.cfg.DataNodeDiskRepairIOLimitRate, val)
+	if err = c.syncPutCluster(); err != nil {
+		log.LogErrorf("action[setDataNodeDiskRepairIOLimitRate] err[%v]", err)
+		atomic.StoreUint64(&c.cfg.DataNodeDiskRepairIOLimitRate, oldVal)
+		err = proto.ErrPersistenceByRaft
+		return
+	}
+	return
+}
+
 func (c *Cluster) setMetaNodeDeleteWorkerSleepMs(val uint64) (err error) {
 	oldVal := atomic.LoadUint64(&c.cfg.MetaNodeDeleteWorkerSleepMs)
 	atomic.StoreUint64(&c.cfg.MetaNodeDeleteWorkerSleepMs, val)
@@ -84,6 +84,50 @@ func (c *Cluster) loadDataPartition(dp *DataPartition) {
 	}()
 }
 
+// checkDataPartition asks the leader of the data partition to compare its replicas, the result
+// is reported to the master asynchronously.
+func (c *Cluster) checkDataPartition(dp *DataPartition, repair bool) (err error) {
+	leaderAddr := dp.getLeaderAddrWithLock()
+	if leaderAddr == "" {
+		return proto.ErrNoLeader
+	}
+	c.addDataNodeTask(dp.createTaskToCheckDataPartition(leaderAddr, repair))
+	return
+}
+
+// backupDataPartition asks the leader of the data partition to upload its extents to the path
+// in the backup store, the result is reported to the master asynchronously.
+func (c *Cluster) backupDataPartition(dp *DataPartition, path string) (err error) {
+	if c.cfg.BackupStore == nil {
+		return fmt.Errorf("the backup store is not configured")
+	}
+	leaderAddr := dp.getLeaderAddrWithLock()
+	if leaderAddr == "" {
+		return proto.ErrNoLeader
+	}
+	c.addDataNodeTask(dp.createTaskToBackupDataPartition(leaderAddr, c.cfg.BackupStore, path))
+	return
+}
+
+// restoreDataPartition asks all the replicas of the data partition to restore the extents missing
+// or shorter than the ones of the backup under the path.
+func (c *Cluster) restoreDataPartition(dp *DataPartition, path string) (err error) {
+	if c.cfg.BackupStore == nil {
+		return fmt.Errorf("the backup store is not configured")
+	}
+	c.addDataNodeTasks(dp.createTasksToRestoreDataPartition(c.cfg.BackupStore, path))
+	return
+}
+
+// backupPath returns the path in the backup store of a backup of the data partition.
+func (c *Cluster) backupPath(dp *DataPartition, backupTime time.Time) string {
+	path := fmt.Sprintf("%v/%v/%v", dp.VolName, dp.PartitionID, backupTime.Unix())
+	if c.cfg.BackupPrefix != "" {
+		path = c.cfg.BackupPrefix + "/" + path
+	}
+	return path
+}
+
 func (c *Cluster) migrateMetaPartition(srcAddr, targetAddr string, mp *MetaPartition) (err error) {
 	var (
 		newPeers        []proto.Peer
@@ -402,7 +446,17 @@ func (c *Cluster) removeMetaPartitionRaftMember(partition *MetaPartition, remove
 		}
 		newPeers = append(newPeers, peer)
 	}
+	oldLearners := partition.Learners
+	newLearners := make([]proto.Peer, 0, len(partition.Learners))
+	for _, learner := range partition.Learners {
+		if learner.Addr == removePeer.Addr && learner.ID == removePeer.ID {
+			continue
+		}
+		newLearners = append(newLearners, learner)
+	}
+	partition.Learners = newLearners
 	if err = partition.persistToRocksDB("removeMetaPartitionRaftMember", partition.volName, newHosts, newPeers, c); err != nil {
+		partition.Learners = oldLearners
 		return
 	}
 	if mr.Addr != removePeer.Addr {
@@ -464,6 +518,110 @@ func (c *Cluster) addMetaReplica(partition *MetaPartition, addr string) (err err
 	return
 }
 
+// addMetaReplicaLearner adds a replica which replicates the raft log without voting,
+// so that it can catch up with the leader before being promoted by promoteMetaReplicaLearner.
+func (c *Cluster) addMetaReplicaLearner(partition *MetaPartition, addr string) (err error) {
+	defer func() {
+		if err != nil {
+			log.LogErrorf("action[addMetaReplicaLearner],vol[%v],meta partition[%v],err[%v]", partition.volName, partition.PartitionID, err)
+		}
+	}()
+	partition.Lock()
+	defer partition.Unlock()
+	if contains(partition.Hosts, addr) {
+		err = fmt.Errorf("vol[%v],mp[%v] has contains host[%v]", partition.volName, partition.PartitionID, addr)
+		return
+	}
+	metaNode, err := c.metaNode(addr)
+	if err != nil {
+		return
+	}
+	addLearner := proto.Peer{ID: metaNode.ID, Addr: addr}
+	mr, err := partition.getMetaReplicaLeader()
+	if err != nil {
+		return
+	}
+	t, err := partition.createTaskToAddRaftLearner(addLearner, mr.Addr)
+	if err != nil {
+		return
+	}
+	leaderMetaNode, err := c.metaNode(mr.Addr)
+	if err != nil {
+		return
+	}
+	if _, err = leaderMetaNode.Sender.syncSendAdminTask(t); err != nil {
+		return
+	}
+	oldLearners := partition.Learners
+	newLearners := make([]proto.Peer, 0, len(partition.Learners)+1)
+	newLearners = append(newLearners, partition.Learners...)
+	partition.Learners = append(newLearners, addLearner)
+	newHosts := make([]string, 0, len(partition.Hosts)+1)
+	newPeers := make([]proto.Peer, 0, len(partition.Peers)+1)
+	newHosts = append(append(newHosts, partition.Hosts...), addLearner.Addr)
+	newPeers = append(append(newPeers, partition.Peers...), addLearner)
+	if err = partition.persistToRocksDB("addMetaReplicaLearner", partition.volName, newHosts, newPeers, c); err != nil {
+		partition.Learners = oldLearners
+		return
+	}
+	if err = c.createMetaReplica(partition, addLearner); err != nil {
+		return
+	}
+	if err = partition.afterCreation(addLearner.Addr, c); err != nil {
+		return
+	}
+	return
+}
+
+// promoteMetaReplicaLearner turns a learner into a voting member once it has caught up with the leader.
+func (c *Cluster) promoteMetaReplicaLearner(partition *MetaPartition, addr string) (err error) {
+	defer func() {
+		if err != nil {
+			log.LogErrorf("action[promoteMetaReplicaLearner],vol[%v],meta partition[%v],err[%v]", partition.volName, partition.PartitionID, err)
+		}
+	}()
+	partition.Lock()
+	defer partition.Unlock()
+	var (
+		promoteLearner proto.Peer
+		newLearners    = make([]proto.Peer, 0, len(partition.Learners))
+	)
+	for _, learner := range partition.Learners {
+		if learner.Addr == addr {
+			promoteLearner = learner
+			continue
+		}
+		newLearners = append(newLearners, learner)
+	}
+	if promoteLearner.ID == 0 {
+		err = fmt.Errorf("vol[%v],mp[%v] has no learner[%v]", partition.volName, partition.PartitionID, addr)
+		return
+	}
+	mr, err := partition.getMetaReplicaLeader()
+	if err != nil {
+		return
+	}
+	t, err := partition.createTaskToPromoteRaftLearner(promoteLearner, mr.Addr)
+	if err != nil {
+		return
+	}
+	leaderMetaNode, err := c.metaNode(mr.Addr)
+	if err != nil {
+		return
+	}
+	if _, err = leaderMetaNode.Sender.syncSendAdminTask(t); err != nil {
+		return
+	}
+	oldLearners := partition.Learners
+	partition.Learners = newLearners
+	if err = c.syncUpdateMetaPartition(partition); err != nil {
+		partition.Learners = oldLearners
+		return
+	}
+	log.LogWarnf("action[promoteMetaReplicaLearner] vol[%v],mp[%v] learner[%v] promoted", partition.volName, partition.PartitionID, promoteLearner)
+	return
+}
+
 func (c *Cluster) createMetaReplica(partition *MetaPartition, addPeer proto.Peer) (err error) {
 	task, err := partition.createTaskToCreateReplica(addPeer.Addr)
 	if err != nil {
@@ -645,6 +803,9 @@ func (c *Cluster) handleMetaNodeTaskResponse(nodeAddr string, task *proto.AdminT
 	case proto.OpUpdateMetaPartition:
 		response := task.Response.(*proto.UpdateMetaPartitionResponse)
 		err = c.dealUpdateMetaPartitionResp(task.OperatorAddr, response)
+	case proto.OpMetaMigrateToCold:
+		response := task.Response.(*proto.MigrateToColdResponse)
+		err = c.dealMigrateToColdResp(task.OperatorAddr, response)
 	default:
 		err := fmt.Errorf("unknown operate code %v", task.OpCode)
 		log.LogError(err)
@@ -700,8 +861,9 @@ errHandler:
 
 func (c *Cluster) dealMetaNodeHeartbeatResp(nodeAddr string, resp *proto.MetaNodeHeartbeatResponse) (err error) {
 	var (
-		metaNode *MetaNode
-		logMsg   string
+		metaNode  *MetaNode
+		unchanged []*proto.MetaPartitionReport
+		logMsg    string
 	)
 	log.LogInfof("action[dealMetaNodeHeartbeatResp],clusterID[%v] receive nodeAddr[%v] heartbeat", c.Name, nodeAddr)
 	if resp.Status == proto.TaskFailed {
@@ -711,6 +873,7 @@ func (c *Cluster) dealMetaNodeHeartbeatResp(nodeAddr string, resp *proto.MetaNod
 		Warn(c.Name, msg)
 		return
 	}
+	c.slos.report(resp.SLOReports, time.Now())
 
 	if metaNode, err = c.metaNode(nodeAddr); err != nil {
 		goto errHandler
@@ -730,14 +893,14 @@ func (c *Cluster) dealMetaNodeHeartbeatResp(nodeAddr string, resp *proto.MetaNod
 		c.adjustMetaNode(metaNode)
 		log.LogWarnf("metaNode zone changed from [%v] to [%v]", oldZoneName, resp.ZoneName)
 	}
-	metaNode.updateMetric(resp, c.cfg.MetaNodeThreshold)
+	unchanged = metaNode.updateMetric(resp, c.cfg.MetaNodeThreshold)
 	metaNode.setNodeActive()
 
 	if err = c.t.putMetaNode(metaNode); err != nil {
 		log.LogErrorf("action[dealMetaNodeHeartbeatResp],metaNode[%v] error[%v]", metaNode.Addr, err)
 	}
 	c.updateMetaNode(metaNode, resp.MetaPartitionReports, metaNode.reachesThreshold())
-	metaNode.metaPartitionInfos = nil
+	c.refreshMetaNode(metaNode, unchanged, metaNode.reachesThreshold())
 	logMsg = fmt.Sprintf("action[dealMetaNodeHeartbeatResp],metaNode:%v,zone[%v], ReportTime:%v  success", metaNode.Addr, metaNode.ZoneName, time.Now().Unix())
 	log.LogInfof(logMsg)
 	return
@@ -813,6 +976,18 @@ func (c *Cluster) handleDataNodeTaskResponse(nodeAddr string, task *proto.AdminT
 	case proto.OpDataNodeHeartbeat:
 		response := task.Response.(*proto.DataNodeHeartbeatResponse)
 		err = c.handleDataNodeHeartbeatResp(task.OperatorAddr, response)
+	case proto.OpCheckDataPartition:
+		response := task.Response.(*proto.CheckDataPartitionResponse)
+		err = c.handleResponseToCheckDataPartition(task.OperatorAddr, response)
+	case proto.OpBackupDataPartition:
+		response := task.Response.(*proto.BackupDataPartitionResponse)
+		err = c.handleResponseToBackupDataPartition(task.OperatorAddr, response)
+	case proto.OpRestoreDataPartition:
+		response := task.Response.(*proto.RestoreDataPartitionResponse)
+		err = c.handleResponseToRestoreDataPartition(task.OperatorAddr, response)
+	case proto.OpConvertDataPartitionToEC:
+		response := task.Response.(*proto.ConvertDataPartitionToECResponse)
+		err = c.handleResponseToConvertDataPartitionToEC(task.OperatorAddr, response)
 	default:
 		err = fmt.Errorf(fmt.Sprintf("unknown operate code %v", task.OpCode))
 		goto errHandler
@@ -876,11 +1051,60 @@ func (c *Cluster) handleResponseToLoadDataPartition(nodeAddr string, resp *proto
 	return
 }
 
+func (c *Cluster) handleResponseToCheckDataPartition(nodeAddr string, resp *proto.CheckDataPartitionResponse) (err error) {
+	dp, err := c.getDataPartitionByID(resp.PartitionId)
+	if err != nil {
+		return
+	}
+	dp.Lock()
+	dp.checkResult = resp
+	dp.Unlock()
+	if resp.Status == proto.TaskFailed {
+		log.LogWarnf("action[handleResponseToCheckDataPartition] partition(%v) leader(%v) err(%v)", resp.PartitionId, nodeAddr, resp.Result)
+		return
+	}
+	if len(resp.Diffs) > 0 {
+		Warn(c.Name, fmt.Sprintf("clusterID[%v] data partition[%v] has [%v] extents differing between replicas, repair[%v]",
+			c.Name, resp.PartitionId, len(resp.Diffs), resp.Repair))
+	}
+	return
+}
+
+func (c *Cluster) handleResponseToBackupDataPartition(nodeAddr string, resp *proto.BackupDataPartitionResponse) (err error) {
+	dp, err := c.getDataPartitionByID(resp.PartitionId)
+	if err != nil {
+		return
+	}
+	dp.Lock()
+	dp.backupResult = resp
+	dp.Unlock()
+	if resp.Status == proto.TaskFailed {
+		Warn(c.Name, fmt.Sprintf("clusterID[%v] backup data partition[%v] to [%v] on [%v] failed,err[%v]",
+			c.Name, resp.PartitionId, resp.Path, nodeAddr, resp.Result))
+		return
+	}
+	log.LogInfof("action[handleResponseToBackupDataPartition] partition(%v) backed up to (%v) by (%v), extents(%v) bytes(%v)",
+		resp.PartitionId, resp.Path, nodeAddr, resp.Extents, resp.Bytes)
+	return
+}
+
+func (c *Cluster) handleResponseToRestoreDataPartition(nodeAddr string, resp *proto.RestoreDataPartitionResponse) (err error) {
+	if resp.Status == proto.TaskFailed {
+		Warn(c.Name, fmt.Sprintf("clusterID[%v] restore data partition[%v] from [%v] on [%v] failed,err[%v]",
+			c.Name, resp.PartitionId, resp.Path, nodeAddr, resp.Result))
+		return
+	}
+	log.LogInfof("action[handleResponseToRestoreDataPartition] partition(%v) restored from (%v) on (%v), extents(%v) bytes(%v)",
+		resp.PartitionId, resp.Path, nodeAddr, resp.Extents, resp.Bytes)
+	return
+}
+
 func (c *Cluster) handleDataNodeHeartbeatResp(nodeAddr string, resp *proto.DataNodeHeartbeatResponse) (err error) {
 
 	var (
-		dataNode *DataNode
-		logMsg   string
+		dataNode  *DataNode
+		unchanged []*proto.PartitionReport
+		logMsg    string
 	)
 	log.LogInfof("action[handleDataNodeHeartbeatResp] clusterID[%v] receive dataNode[%v] heartbeat, ", c.Name, nodeAddr)
 	if resp.Status != proto.TaskSucceeds {
@@ -888,6 +1112,7 @@ func (c *Cluster) handleDataNodeHeartbeatResp(nodeAddr string, resp *proto.DataN
 			c.Name, nodeAddr))
 		return
 	}
+	c.slos.report(resp.SLOReports, time.Now())
 
 	if dataNode, err = c.dataNode(nodeAddr); err != nil {
 		goto errHandler
@@ -907,11 +1132,12 @@ func (c *Cluster) handleDataNodeHeartbeatResp(nodeAddr string, resp *proto.DataN
 		log.LogWarnf("dataNode [%v] zone changed from [%v] to [%v]", dataNode.Addr, oldZoneName, resp.ZoneName)
 	}
 
-	dataNode.updateNodeMetric(resp)
+	unchanged = dataNode.updateNodeMetric(resp)
 	if err = c.t.putDataNode(dataNode); err != nil {
 		log.LogErrorf("action[handleDataNodeHeartbeatResp] dataNode[%v],zone[%v],node set[%v], err[%v]", dataNode.Addr, dataNode.ZoneName, dataNode.NodeSetID, err)
 	}
 	c.updateDataNode(dataNode, resp.PartitionReports)
+	c.refreshDataNode(dataNode, unchanged)
 	logMsg = fmt.Sprintf("action[handleDataNodeHeartbeatResp],dataNode:%v,zone[%v], ReportTime:%v  success", dataNode.Addr, dataNode.ZoneName, time.Now().Unix())
 	log.LogInfof(logMsg)
 	return
@@ -964,52 +1190,48 @@ func (c *Cluster) updateDataNode(dataNode *DataNode, dps []*proto.PartitionRepor
 		if vr == nil {
 			continue
 		}
-		if vr.VolName != "" {
-			vol, err := c.getVol(vr.VolName)
-			if err != nil {
-				continue
-			}
-			if vol.Status == markDelete {
-				continue
-			}
-			if dp, err := vol.getDataPartitionByID(vr.PartitionID); err == nil {
-				dp.updateMetric(vr, dataNode, c)
-			}
-		} else {
-			if dp, err := c.getDataPartitionByID(vr.PartitionID); err == nil {
-				dp.updateMetric(vr, dataNode, c)
-			}
+		if dp, err := c.reportedDataPartition(vr); err == nil {
+			dp.updateMetric(vr, dataNode, c)
+		}
+	}
+}
+
+// refreshDataNode keeps the replicas on the data node alive for the partitions whose reports are
+// unchanged since the last heartbeat.
+func (c *Cluster) refreshDataNode(dataNode *DataNode, dps []*proto.PartitionReport) {
+	for _, vr := range dps {
+		dp, err := c.reportedDataPartition(vr)
+		if err != nil {
+			continue
+		}
+		if !dp.refreshReplica(vr, dataNode) {
+			dp.updateMetric(vr, dataNode, c)
 		}
 	}
 }
 
+func (c *Cluster) reportedDataPartition(vr *proto.PartitionReport) (dp *DataPartition, err error) {
+	if vr.VolName == "" {
+		return c.getDataPartitionByID(vr.PartitionID)
+	}
+	var vol *Vol
+	if vol, err = c.getVol(vr.VolName); err != nil {
+		return
+	}
+	if vol.Status == markDelete {
+		return nil, proto.ErrVolNotExists
+	}
+	return vol.getDataPartitionByID(vr.PartitionID)
+}
+
 func (c *Cluster) updateMetaNode(metaNode *MetaNode, metaPartitions []*proto.MetaPartitionReport, threshold bool) {
-	var (
-		vol *Vol
-		err error
-	)
 	for _, mr := range metaPartitions {
 		if mr == nil {
 			continue
 		}
-		var mp *MetaPartition
-		if mr.VolName != "" {
-			vol, err = c.getVol(mr.VolName)
-			if err != nil {
-				continue
-			}
-			if vol.Status == markDelete {
-				continue
-			}
-			mp, err = vol.metaPartition(mr.PartitionID)
-			if err != nil {
-				continue
-			}
-		} else {
-			mp, err = c.getMetaPartitionByID(mr.PartitionID)
-			if err != nil {
-				continue
-			}
+		mp, err := c.reportedMetaPartition(mr)
+		if err != nil {
+			continue
 		}
 
 		//send latest end to replica
@@ -1021,6 +1243,38 @@ func (c *Cluster) updateMetaNode(metaNode *MetaNode, metaPartitions []*proto.Met
 	}
 }
 
+// refreshMetaNode keeps the replicas on the meta node alive for the partitions whose reports are
+// unchanged since the last heartbeat.
+func (c *Cluster) refreshMetaNode(metaNode *MetaNode, metaPartitions []*proto.MetaPartitionReport, threshold bool) {
+	for _, mr := range metaPartitions {
+		mp, err := c.reportedMetaPartition(mr)
+		if err != nil {
+			continue
+		}
+		if mr.End != mp.End {
+			mp.addUpdateMetaReplicaTask(c)
+		}
+		if !mp.refreshReplica(mr, metaNode) {
+			mp.updateMetaPartition(mr, metaNode)
+		}
+		c.updateInodeIDUpperBound(mp, mr, threshold, metaNode)
+	}
+}
+
+func (c *Cluster) reportedMetaPartition(mr *proto.MetaPartitionReport) (mp *MetaPartition, err error) {
+	if mr.VolName == "" {
+		return c.getMetaPartitionByID(mr.PartitionID)
+	}
+	var vol *Vol
+	if vol, err = c.getVol(mr.VolName); err != nil {
+		return
+	}
+	if vol.Status == markDelete {
+		return nil, proto.ErrVolNotExists
+	}
+	return vol.metaPartition(mr.PartitionID)
+}
+
 func (c *Cluster) updateInodeIDUpperBound(mp *MetaPartition, mr *proto.MetaPartitionReport, hasArriveThreshold bool, metaNode *MetaNode) (err error) {
 	if !hasArriveThreshold {
 		return
@@ -22,7 +22,7 @@ func buildPanicVol() *Vol {
 	}
 	var createTime = time.Now().Unix() // record create time of this volume
 	vol := newVol(id, commonVol.Name, commonVol.Owner, "", commonVol.dataPartitionSize, commonVol.Capacity,
-		defaultReplicaNum, defaultReplicaNum, false, false, false, false, createTime, "")
+		defaultReplicaNum, defaultReplicaNum, false, false, false, false, false, createTime, "")
 	vol.dataPartitions = nil
 	return vol
 }
@@ -0,0 +1,121 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The files neither accessed nor modified for the configured days are migrated to the blob store
+// of the cold tier in the background. The master asks the leaders of the meta partitions to migrate
+// their cold files, a leader reads the files through the data nodes, puts them as blobs, and records
+// the locations of the blobs in the inodes, whose extents are released then. The clients read the
+// data of the migrated files from the blob store.
+
+// coldTierManager keeps the meta partitions migrating their cold files.
+type coldTierManager struct {
+	sync.Mutex
+	migrating map[uint64]int64 // unix time the migration of the meta partition was requested, by partition ID
+}
+
+func newColdTierManager() *coldTierManager {
+	return &coldTierManager{migrating: make(map[uint64]int64)}
+}
+
+// tryStart records the migration of the meta partition, and returns false if the partition is
+// migrating or too many partitions are migrating. The migrations not responded in time are dropped.
+func (m *coldTierManager) tryStart(partitionID uint64) bool {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now().Unix()
+	for id, start := range m.migrating {
+		if now-start > defaultColdMigrationTimeoutSec {
+			delete(m.migrating, id)
+		}
+	}
+	if _, ok := m.migrating[partitionID]; ok || len(m.migrating) >= defaultMaxMigratingMetaPartitions {
+		return false
+	}
+	m.migrating[partitionID] = now
+	return true
+}
+
+func (m *coldTierManager) finish(partitionID uint64) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.migrating, partitionID)
+}
+
+func (c *Cluster) scheduleToMigrateColdFiles() {
+	go func() {
+		for {
+			if c.cfg.ColdTierDays > 0 && len(c.cfg.BlobStoreAddrs) > 0 && c.partition != nil && c.partition.IsRaftLeader() {
+				c.migrateColdFiles()
+			}
+			time.Sleep(time.Second * defaultIntervalToMigrateColdFiles)
+		}
+	}()
+}
+
+// migrateColdFiles asks the leaders of the meta partitions to migrate their cold files. The files
+// of the encrypted volumes are not migrated, since the holes of the files cannot be told from the
+// data encrypted by the clients once they are put in the blobs.
+func (c *Cluster) migrateColdFiles() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogWarnf("migrateColdFiles occurred panic,err[%v]", r)
+			WarnBySpecialKey(fmt.Sprintf("%v_%v_scheduling_job_panic", c.Name, ModuleName),
+				"migrateColdFiles occurred panic")
+		}
+	}()
+	coldSec := c.cfg.ColdTierDays * 24 * 3600
+	for _, vol := range c.allVols() {
+		if vol.encrypted || vol.Status == markDelete {
+			continue
+		}
+		for _, mp := range vol.cloneMetaPartitionMap() {
+			mp.RLock()
+			leader, err := mp.getMetaReplicaLeader()
+			mp.RUnlock()
+			if err != nil {
+				continue
+			}
+			if !c.coldTier.tryStart(mp.PartitionID) {
+				continue
+			}
+			request := &proto.MigrateToColdRequest{PartitionID: mp.PartitionID, VolName: vol.Name, ColdSec: coldSec}
+			task := proto.NewAdminTask(proto.OpMetaMigrateToCold, leader.Addr, request)
+			resetMetaPartitionTaskID(task, mp.PartitionID)
+			c.addMetaNodeTasks([]*proto.AdminTask{task})
+		}
+	}
+}
+
+func (c *Cluster) dealMigrateToColdResp(nodeAddr string, resp *proto.MigrateToColdResponse) (err error) {
+	c.coldTier.finish(resp.PartitionID)
+	if resp.Status == proto.TaskFailed {
+		log.LogWarnf("action[dealMigrateToColdResp] clusterID[%v] nodeAddr[%v] partition[%v] migrate to cold tier failed, err[%v]",
+			c.Name, nodeAddr, resp.PartitionID, resp.Result)
+		return
+	}
+	log.LogInfof("action[dealMigrateToColdResp] clusterID[%v] nodeAddr[%v] partition[%v] migrated[%v] bytes[%v] failed[%v]",
+		c.Name, nodeAddr, resp.PartitionID, resp.Migrated, resp.Bytes, resp.Failed)
+	return
+}
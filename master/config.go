@@ -19,7 +19,9 @@ import (
 	syslog "log"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
+	cfsProto "github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/raftstore"
 	"github.com/tiglabs/raft/proto"
 )
@@ -29,6 +31,8 @@ const (
 	colonSplit = ":"
 	commaSplit = ","
 	cfgPeers   = "peers"
+	// cfgWitnesses lists the IDs of the peers which vote and store the raft log but keep no metadata
+	cfgWitnesses = "witnesses"
 	// if the data partition has not been reported within this interval  (in terms of seconds), it will be considered as missing.
 	missingDataPartitionInterval        = "missingDataPartitionInterval"
 	dataPartitionTimeOutSec             = "dataPartitionTimeOutSec"
@@ -41,13 +45,37 @@ const (
 	faultDomain                         = "faultDomain"
 	cfgDomainBatchGrpCnt                = "faultDomainGrpBatchCnt"
 	cfgDomainBuildAsPossible            = "faultDomainBuildAsPossible"
+	// interval (in terms of seconds) of balancing the meta partition leaders across the meta nodes, a negative value disables it.
+	cfgMetaLeaderBalanceInterval = "metaLeaderBalanceInterval"
+	// whether to decommission the data partitions on the disks whose SMART attributes are degrading.
+	cfgAutoDrainDegradedDisk = "autoDrainDegradedDisk"
+	// whether to decommission the data partitions on the disks isolated by the data nodes because of their IO errors.
+	cfgAutoRepairBadDisk = "autoRepairBadDisk"
+	// file of the master keys wrapping the data keys of the encrypted volumes.
+	cfgEncryptKeyFile = "encryptKeyFile"
+	// S3-compatible storage the data partitions are backed up to, backups are disabled without an endpoint.
+	cfgBackupS3Endpoint  = "backupS3Endpoint"
+	cfgBackupS3Region    = "backupS3Region"
+	cfgBackupS3Bucket    = "backupS3Bucket"
+	cfgBackupS3AccessKey = "backupS3AccessKey"
+	cfgBackupS3SecretKey = "backupS3SecretKey"
+	// prefix of the paths of the backups in the bucket.
+	cfgBackupS3Prefix = "backupS3Prefix"
+	// days without writes after which the data partitions are converted to erasure coding, 0 disables the conversion.
+	cfgECColdDays = "ecColdDays"
+	// addresses of the blob store access service of the cold tier, separated by commas.
+	cfgBlobStoreAddr = "blobStoreAddr"
+	// days without accesses and modifications after which the files are migrated to the cold tier, 0 disables the migration.
+	cfgColdTierDays = "coldTierDays"
+	// sinks of the audit records of the admin requests, see package audit, the requests are not audited without it.
+	cfgAuditTargets = "auditTargets"
 )
 
 //default value
 const (
 	defaultTobeFreedDataPartitionCount         = 1000
-	defaultSecondsToFreeDataPartitionAfterLoad = 5 * 60                      // a data partition can only be freed after loading 5 mins
-	defaultIntervalToFreeDataPartition         = 10                          // in terms of seconds
+	defaultSecondsToFreeDataPartitionAfterLoad = 5 * 60 // a data partition can only be freed after loading 5 mins
+	defaultIntervalToFreeDataPartition         = 10     // in terms of seconds
 	defaultIntervalToCheck                     = 60
 	defaultIntervalToCheckHeartbeat            = 6
 	defaultIntervalToCheckDataPartition        = 5
@@ -70,8 +98,21 @@ const (
 	defaultDomainUsageThreshold                float64 = 0.75    // storage usage threshold on a data partition
 	defaultMaxMetaPartitionCountOnEachNode             = 10000
 	defaultReplicaNum                                  = 3
+	defaultHotDataPartitionCount                       = 100
 	defaultDiffSpaceUsage                              = 1024 * 1024 * 1024
 	defaultNodeSetGrpStep                              = 1
+	defaultMetaLeaderBalanceInterval                   = 10 * 60
+	defaultMaxMetaLeaderTransfersPerRound              = 64
+	defaultIntervalToCheckDegradedDisk                 = 60
+	defaultDegradedDiskDrainBatch                      = 5 // data partitions decommissioned at a time from a degraded disk
+	defaultSmartReallocatedSectorsThreshold            = 100
+	defaultSmartPendingSectorsThreshold                = 8
+	defaultSmartPercentageUsedThreshold                = 100
+	defaultIntervalToConvertColdDataPartitions         = 10 * 60
+	defaultMaxConvertingDataPartitions                 = 4 // data partitions converted to erasure coding at a time
+	defaultIntervalToMigrateColdFiles                  = 10 * 60
+	defaultMaxMigratingMetaPartitions                  = 8 // meta partitions migrating their cold files at a time
+	defaultColdMigrationTimeoutSec                     = 6 * 3600
 )
 
 // AddrDatabase is a map that stores the address of a given host (e.g., the leader)
@@ -94,8 +135,11 @@ type clusterConfig struct {
 	DataNodeDeleteLimitRate             uint64 //datanode delete limit rate
 	MetaNodeDeleteWorkerSleepMs         uint64 //datanode delete limit rate
 	DataNodeAutoRepairLimitRate         uint64 //datanode autorepair limit rate
+	DataNodeDiskClientIOLimitRate       uint64 //datanode client io bytes per second of each disk
+	DataNodeDiskRepairIOLimitRate       uint64 //datanode repair io bytes per second of each disk
 	peers                               []raftstore.PeerAddress
 	peerAddrs                           []string
+	witnesses                           []uint64
 	heartbeatPort                       int64
 	replicaPort                         int64
 	diffSpaceUsage                      uint64
@@ -103,6 +147,15 @@ type clusterConfig struct {
 	DomainNodeGrpBatchCnt               int
 	DomainBuildAsPossible               bool
 	DataPartitionUsageThreshold         float64
+	MetaLeaderBalanceInterval           int64 // seconds
+	AutoDrainDegradedDisk               bool
+	AutoRepairBadDisk                   bool
+	BackupStore                         *cfsProto.BackupStore // nil if the backups are disabled
+	BackupPrefix                        string
+	ECColdDays                          int64        // 0 if the conversion to erasure coding is disabled
+	BlobStoreAddrs                      []string     // addresses of the blob store of the cold tier, nil if not configured
+	ColdTierDays                        int64        // 0 if the migration to the cold tier is disabled
+	dataNodeRepairWindows               atomic.Value // []*cfsProto.RepairLimitWindow, the repair limits of the cluster within time windows
 }
 
 func newClusterConfig() (cfg *clusterConfig) {
@@ -121,6 +174,7 @@ func newClusterConfig() (cfg *clusterConfig) {
 	cfg.MetaNodeThreshold = defaultMetaPartitionMemUsageThreshold
 	cfg.metaNodeReservedMem = defaultMetaNodeReservedMem
 	cfg.diffSpaceUsage = defaultDiffSpaceUsage
+	cfg.MetaLeaderBalanceInterval = defaultMetaLeaderBalanceInterval
 	return
 }
 
@@ -138,6 +192,39 @@ func parsePeerAddr(peerAddr string) (id uint64, ip string, port uint64, err erro
 	return
 }
 
+func (cfg *clusterConfig) parseWitnesses(witnessStr string) error {
+	if witnessStr == "" {
+		return nil
+	}
+	for _, idStr := range strings.Split(witnessStr, commaSplit) {
+		id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			return err
+		}
+		var found bool
+		for _, peer := range cfg.peers {
+			if peer.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("witness %v is not in the peers", id)
+		}
+		cfg.witnesses = append(cfg.witnesses, id)
+	}
+	return nil
+}
+
+func (cfg *clusterConfig) repairWindows() []*cfsProto.RepairLimitWindow {
+	windows, _ := cfg.dataNodeRepairWindows.Load().([]*cfsProto.RepairLimitWindow)
+	return windows
+}
+
+func (cfg *clusterConfig) setRepairWindows(windows []*cfsProto.RepairLimitWindow) {
+	cfg.dataNodeRepairWindows.Store(windows)
+}
+
 func (cfg *clusterConfig) parsePeers(peerStr string) error {
 	peerArr := strings.Split(peerStr, commaSplit)
 	cfg.peerAddrs = peerArr
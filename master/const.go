@@ -42,23 +42,50 @@ const (
 	akKey                   = "ak"
 	keywordsKey             = "keywords"
 	zoneNameKey             = "zoneName"
+	replicaAddrKey          = "replicaAddr"
 	crossZoneKey            = "crossZone"
 	defaultPriority         = "defaultPriority"
+	caseInsensitiveKey      = "caseInsensitive"
 	userKey                 = "user"
 	nodeHostsKey            = "hosts"
 	nodeDeleteBatchCountKey = "batchCount"
 	nodeMarkDeleteRateKey   = "markDeleteRate"
 	nodeDeleteWorkerSleepMs = "deleteWorkerSleepMs"
 	nodeAutoRepairRateKey   = "autoRepairRate"
+	nodeDiskClientIORateKey = "diskClientIORate"
+	nodeDiskRepairIORateKey = "diskRepairIORate"
+	repairScheduleKey       = "repairSchedule"
 	descriptionKey          = "description"
 	dpSelectorNameKey       = "dpSelectorName"
 	dpSelectorParmKey       = "dpSelectorParm"
+	compressionKey          = "compression"
+	writeLimitKey           = "writeLimit"
+	objectQuotaBytesKey     = "objectQuotaBytes"
+	objectQuotaCountKey     = "objectQuotaCount"
+	sloLatencyMsKey         = "sloLatencyMs"
+	sloLatencyTargetKey     = "sloLatencyTarget"
+	sloErrorRateTargetKey   = "sloErrorRateTarget"
+	flashCacheKey           = "flashCache"
+	encryptedKey            = "encrypted"
+	checksumKey             = "checksum"
 	nodeTypeKey             = "nodeType"
 	ratio                   = "ratio"
 	rdOnlyKey               = "rdOnly"
 	srcAddrKey              = "srcAddr"
 	targetAddrKey           = "targetAddr"
 	forceKey                = "force"
+	repairKey               = "repair"
+	pathKey                 = "path"
+	loadedKey               = "loaded"
+	serviceKeyKey           = "key"
+	rateLimitKeyKey         = "key"
+	rateKey                 = "rate"
+	quotaKindKey            = "kind"
+	maxBytesKey             = "maxBytes"
+	maxInodesKey            = "maxInodes"
+	totalKey                = "total"
+	clearKey                = "clear"
+	snapshotKey             = "snapshot"
 )
 
 const (
@@ -150,6 +177,15 @@ const (
 	opSyncNodeSetGrp           uint32 = 0x1F
 	opSyncDataPartitionsView   uint32 = 0x20
 	opSyncExclueDomain         uint32 = 0x23
+	opSyncPutServiceKey        uint32 = 0x24
+	opSyncPutRateLimit         uint32 = 0x25
+	opSyncDeleteRateLimit      uint32 = 0x26
+	opSyncAddFlashNode         uint32 = 0x27
+	opSyncDeleteFlashNode      uint32 = 0x28
+	opSyncPutQuota             uint32 = 0x29
+	opSyncDeleteQuota          uint32 = 0x2A
+	opSyncPutVolSnapshot       uint32 = 0x2B
+	opSyncDeleteVolSnapshot    uint32 = 0x2C
 )
 
 const (
@@ -185,4 +221,14 @@ const (
 	volUserPrefix         = keySeparator + volUserAcronym + keySeparator
 	volWarnUsedRatio      = 0.9
 	volCachePrefix        = keySeparator + volNameAcronym + keySeparator
+	serviceKeyAcronym     = "servicekey"
+	serviceKeyPrefix      = keySeparator + serviceKeyAcronym + keySeparator
+	rateLimitAcronym      = "ratelimit"
+	rateLimitPrefix       = keySeparator + rateLimitAcronym + keySeparator
+	flashNodeAcronym      = "fn"
+	flashNodePrefix       = keySeparator + flashNodeAcronym + keySeparator
+	quotaAcronym          = "quota"
+	quotaPrefix           = keySeparator + quotaAcronym + keySeparator
+	volSnapshotAcronym    = "volsnapshot"
+	volSnapshotPrefix     = keySeparator + volSnapshotAcronym + keySeparator
 )
@@ -32,7 +32,10 @@ type DataNode struct {
 	AvailableSpace            uint64
 	ID                        uint64
 	ZoneName                  string `json:"Zone"`
+	Rack                      string
+	Crc32cSupported           bool
 	Addr                      string
+	ReplicaAddr               string // address for the replication and repair traffic, empty if it shares Addr
 	ReportTime                time.Time
 	isActive                  bool
 	sync.RWMutex              `graphql:"-"`
@@ -45,9 +48,21 @@ type DataNode struct {
 	NodeSetID                 uint64
 	PersistenceDataPartitions []uint64
 	BadDisks                  []string
+	CorruptExtents            []*proto.CorruptExtentReport
+	DiskSmarts                []*proto.DiskSmartInfo
+	HotExtents                []*proto.HotExtentReport
+	AccessStatsWindow         int64
 	ToBeOffline               bool
 	RdOnly                    bool
 	MigrateLock               sync.RWMutex
+	PartitionsLoaded          int // partitions loaded by the data node since it started
+	PartitionsToLoad          int
+	LoadReportTime            time.Time
+	RepairLimit               *proto.DataNodeRepairLimit // overrides the repair limits of the cluster, nil if not set
+	Version                   string
+	ClockSkew                 time.Duration                     `graphql:"-"` // clock of the data node ahead of the master
+	reportSeq                 uint64                            // sequence of the last partition report applied, 0 to ask for a full one
+	partitionReports          map[uint64]*proto.PartitionReport // the partition reports merged from the heartbeats
 }
 
 func newDataNode(addr, zoneName, clusterID string) (dataNode *DataNode) {
@@ -72,6 +87,21 @@ func (dataNode *DataNode) checkLiveness() {
 	return
 }
 
+func (dataNode *DataNode) updateLoadProgress(loaded, total int) {
+	dataNode.Lock()
+	defer dataNode.Unlock()
+	dataNode.PartitionsLoaded = loaded
+	dataNode.PartitionsToLoad = total
+	dataNode.LoadReportTime = time.Now()
+}
+
+// isLoading tells if the data node is still loading its partitions, as long as it
+// reports the progress in time.
+func (dataNode *DataNode) isLoading() bool {
+	return dataNode.PartitionsLoaded < dataNode.PartitionsToLoad &&
+		time.Since(dataNode.LoadReportTime) <= time.Second*time.Duration(defaultNodeTimeOutSec)
+}
+
 func (dataNode *DataNode) badPartitions(diskPath string, c *Cluster) (partitions []*DataPartition) {
 	partitions = make([]*DataPartition, 0)
 	vols := c.copyVols()
@@ -85,16 +115,28 @@ func (dataNode *DataNode) badPartitions(diskPath string, c *Cluster) (partitions
 	return
 }
 
-func (dataNode *DataNode) updateNodeMetric(resp *proto.DataNodeHeartbeatResponse) {
+// updateNodeMetric updates the data node by the heartbeat response, and returns the partition reports
+// not in the response since they are unchanged.
+func (dataNode *DataNode) updateNodeMetric(resp *proto.DataNodeHeartbeatResponse) (unchanged []*proto.PartitionReport) {
 	dataNode.Lock()
 	defer dataNode.Unlock()
 	dataNode.Total = resp.Total
 	dataNode.Used = resp.Used
 	dataNode.AvailableSpace = resp.Available
 	dataNode.ZoneName = resp.ZoneName
+	dataNode.Rack = resp.Rack
+	dataNode.Crc32cSupported = resp.Crc32cSupported
 	dataNode.DataPartitionCount = resp.CreatedPartitionCnt
-	dataNode.DataPartitionReports = resp.PartitionReports
+	unchanged = dataNode.mergePartitionReports(resp)
 	dataNode.BadDisks = resp.BadDisks
+	dataNode.CorruptExtents = resp.CorruptExtents
+	dataNode.DiskSmarts = resp.DiskSmarts
+	dataNode.HotExtents = resp.HotExtents
+	dataNode.AccessStatsWindow = resp.AccessStatsWindow
+	dataNode.Version = resp.Version
+	if resp.LocalTime > 0 {
+		dataNode.ClockSkew = time.Duration(resp.LocalTime - time.Now().UnixNano())
+	}
 	if dataNode.Total == 0 {
 		dataNode.UsageRatio = 0.0
 	} else {
@@ -102,6 +144,53 @@ func (dataNode *DataNode) updateNodeMetric(resp *proto.DataNodeHeartbeatResponse
 	}
 	dataNode.ReportTime = time.Now()
 	dataNode.isActive = true
+	return
+}
+
+// mergePartitionReports merges the partition reports of the heartbeat, either a full report or the
+// changes since the base report, into the ones of the data node. A full report is asked for by the
+// next heartbeat if the base report is not the last one applied.
+func (dataNode *DataNode) mergePartitionReports(resp *proto.DataNodeHeartbeatResponse) (unchanged []*proto.PartitionReport) {
+	if resp.BaseReportSeq == 0 || dataNode.partitionReports == nil {
+		dataNode.partitionReports = make(map[uint64]*proto.PartitionReport, len(resp.PartitionReports))
+	}
+	changed := make(map[uint64]bool, len(resp.PartitionReports))
+	for _, vr := range resp.PartitionReports {
+		if vr == nil {
+			continue
+		}
+		dataNode.partitionReports[vr.PartitionID] = vr
+		changed[vr.PartitionID] = true
+	}
+	for _, id := range resp.RemovedPartitions {
+		delete(dataNode.partitionReports, id)
+	}
+	switch {
+	case resp.BaseReportSeq == 0:
+		dataNode.reportSeq = resp.ReportSeq
+	case resp.BaseReportSeq == dataNode.reportSeq:
+		dataNode.reportSeq = resp.ReportSeq
+		for id, vr := range dataNode.partitionReports {
+			if !changed[id] {
+				unchanged = append(unchanged, vr)
+			}
+		}
+	default:
+		log.LogWarnf("action[mergePartitionReports] dataNode[%v] base report[%v] is not the applied one[%v]",
+			dataNode.Addr, resp.BaseReportSeq, dataNode.reportSeq)
+		dataNode.reportSeq = 0
+	}
+	dataNode.DataPartitionReports = make([]*proto.PartitionReport, 0, len(dataNode.partitionReports))
+	for _, vr := range dataNode.partitionReports {
+		dataNode.DataPartitionReports = append(dataNode.DataPartitionReports, vr)
+	}
+	return
+}
+
+func (dataNode *DataNode) appliedReportSeq() uint64 {
+	dataNode.RLock()
+	defer dataNode.RUnlock()
+	return dataNode.reportSeq
 }
 
 func (dataNode *DataNode) isWriteAble() (ok bool) {
@@ -165,10 +254,19 @@ func (dataNode *DataNode) clean() {
 	dataNode.TaskManager.exitCh <- struct{}{}
 }
 
-func (dataNode *DataNode) createHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (dataNode *DataNode) createHeartbeatTask(masterAddr string, volCompressions map[string]string,
+	volEncryptKeys map[string][]byte, volWriteLimits map[string]uint64, repairLimit *proto.DataNodeRepairLimit,
+	replicaAddrs map[string]string) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
-		CurrTime:   time.Now().Unix(),
-		MasterAddr: masterAddr,
+		CurrTime:        time.Now().Unix(),
+		MasterAddr:      masterAddr,
+		VolCompressions: volCompressions,
+		VolEncryptKeys:  volEncryptKeys,
+		VolWriteLimits:  volWriteLimits,
+		RepairLimit:     repairLimit,
+		ReplicaAddrs:    replicaAddrs,
+		TaskCodec:       proto.TaskCodecProtobuf,
+		ReportSeq:       dataNode.appliedReportSeq(),
 	}
 	task = proto.NewAdminTask(proto.OpDataNodeHeartbeat, dataNode.Addr, request)
 	return
@@ -33,3 +33,49 @@ func decommissionDataNode(addr string, t *testing.T) {
 	fmt.Println(reqURL)
 	process(reqURL, t)
 }
+
+func TestMergePartitionReports(t *testing.T) {
+	tracker := proto.NewReportTracker(time.Hour)
+	dataNode := newDataNode("127.0.0.1:9999", DefaultZoneName, "test")
+	heartbeat := func(reports ...proto.PartitionReport) (resp *proto.DataNodeHeartbeatResponse, unchanged []*proto.PartitionReport) {
+		current := make(map[uint64]interface{})
+		for _, vr := range reports {
+			current[vr.PartitionID] = vr
+		}
+		delta := tracker.Report(dataNode.appliedReportSeq(), current)
+		resp = &proto.DataNodeHeartbeatResponse{ReportSeq: delta.Seq, BaseReportSeq: delta.Base, RemovedPartitions: delta.Removed}
+		for i := range reports {
+			if delta.Changed(reports[i].PartitionID) {
+				resp.PartitionReports = append(resp.PartitionReports, &reports[i])
+			}
+		}
+		unchanged = dataNode.updateNodeMetric(resp)
+		return
+	}
+
+	resp, unchanged := heartbeat(proto.PartitionReport{PartitionID: 1, Used: 1}, proto.PartitionReport{PartitionID: 2, Used: 2})
+	if resp.BaseReportSeq != 0 || len(resp.PartitionReports) != 2 || len(unchanged) != 0 {
+		t.Fatalf("first report should be full: base(%v) reports(%v)", resp.BaseReportSeq, len(resp.PartitionReports))
+	}
+	resp, unchanged = heartbeat(proto.PartitionReport{PartitionID: 1, Used: 10}, proto.PartitionReport{PartitionID: 2, Used: 2},
+		proto.PartitionReport{PartitionID: 3, Used: 3})
+	if resp.BaseReportSeq == 0 || len(resp.PartitionReports) != 2 || len(unchanged) != 1 || unchanged[0].PartitionID != 2 {
+		t.Fatalf("expect a delta of partitions 1 and 3: base(%v) reports(%v) unchanged(%v)",
+			resp.BaseReportSeq, len(resp.PartitionReports), len(unchanged))
+	}
+	resp, _ = heartbeat(proto.PartitionReport{PartitionID: 1, Used: 10}, proto.PartitionReport{PartitionID: 3, Used: 3})
+	if len(resp.PartitionReports) != 0 || len(resp.RemovedPartitions) != 1 || len(dataNode.DataPartitionReports) != 2 {
+		t.Fatalf("expect partition 2 removed: reports(%v) removed(%v) merged(%v)",
+			len(resp.PartitionReports), resp.RemovedPartitions, len(dataNode.DataPartitionReports))
+	}
+	if dataNode.partitionReports[1].Used != 10 {
+		t.Fatalf("partition 1 is not updated: %v", dataNode.partitionReports[1].Used)
+	}
+
+	// a new leader asks for a full report
+	dataNode.reportSeq = 0
+	resp, _ = heartbeat(proto.PartitionReport{PartitionID: 1, Used: 10}, proto.PartitionReport{PartitionID: 3, Used: 3})
+	if resp.BaseReportSeq != 0 || len(resp.PartitionReports) != 2 {
+		t.Fatalf("expect a full report: base(%v) reports(%v)", resp.BaseReportSeq, len(resp.PartitionReports))
+	}
+}
@@ -49,7 +49,11 @@ type DataPartition struct {
 	lastWarnTime            int64
 	OfflinePeerID           uint64
 	FileInCoreMap           map[string]*FileInCore
-	FilesWithMissingReplica map[string]int64 // key: file name, value: last time when a missing replica is found
+	FilesWithMissingReplica map[string]int64                   // key: file name, value: last time when a missing replica is found
+	checkResult             *proto.CheckDataPartitionResponse  // the latest consistency check, not persisted
+	backupResult            *proto.BackupDataPartitionResponse // the latest backup, not persisted
+	ECStatus                uint8
+	ecDigests               map[string]uint32 // the digests of the replicas which have encoded the partition, not persisted
 }
 
 func newDataPartition(ID uint64, replicaNum uint8, volName string, volID uint64) (partition *DataPartition) {
@@ -127,6 +131,46 @@ func (partition *DataPartition) createTaskToTryToChangeLeader(addr string) (task
 	return
 }
 
+func (partition *DataPartition) createTaskToCheckDataPartition(addr string, repair bool) (task *proto.AdminTask) {
+	task = proto.NewAdminTask(proto.OpCheckDataPartition, addr, &proto.CheckDataPartitionRequest{PartitionId: partition.PartitionID, Repair: repair})
+	partition.resetTaskID(task)
+	return
+}
+
+func (partition *DataPartition) createTaskToBackupDataPartition(addr string, store *proto.BackupStore, path string) (task *proto.AdminTask) {
+	task = proto.NewAdminTask(proto.OpBackupDataPartition, addr, &proto.BackupDataPartitionRequest{PartitionId: partition.PartitionID, Store: store, Path: path})
+	partition.resetTaskID(task)
+	return
+}
+
+func (partition *DataPartition) createTasksToRestoreDataPartition(store *proto.BackupStore, path string) (tasks []*proto.AdminTask) {
+	partition.RLock()
+	defer partition.RUnlock()
+	tasks = make([]*proto.AdminTask, 0, len(partition.Hosts))
+	for _, addr := range partition.Hosts {
+		task := proto.NewAdminTask(proto.OpRestoreDataPartition, addr, &proto.RestoreDataPartitionRequest{PartitionId: partition.PartitionID, Store: store, Path: path})
+		partition.resetTaskID(task)
+		tasks = append(tasks, task)
+	}
+	return
+}
+
+// accessStats returns the accesses of the data partition within the last windows of its
+// replicas, the reads are summed as they are served by any replica.
+func (partition *DataPartition) accessStats() (stats proto.AccessStats) {
+	partition.RLock()
+	defer partition.RUnlock()
+	for _, replica := range partition.Replicas {
+		stats.ReadOps += replica.ReadOps
+		stats.ReadBytes += replica.ReadBytes
+		if replica.WriteOps > stats.WriteOps {
+			stats.WriteOps = replica.WriteOps
+			stats.WriteBytes = replica.WriteBytes
+		}
+	}
+	return
+}
+
 func (partition *DataPartition) createTaskToAddRaftMember(addPeer proto.Peer, leaderAddr string) (task *proto.AdminTask, err error) {
 	task = proto.NewAdminTask(proto.OpAddDataPartitionRaftMember, leaderAddr, newAddDataPartitionRaftMemberRequest(partition.PartitionID, addPeer))
 	partition.resetTaskID(task)
@@ -144,10 +188,10 @@ func (partition *DataPartition) createTaskToRemoveRaftMember(removePeer proto.Pe
 	return
 }
 
-func (partition *DataPartition) createTaskToCreateDataPartition(addr string, dataPartitionSize uint64, peers []proto.Peer, hosts []string, createType int) (task *proto.AdminTask) {
+func (partition *DataPartition) createTaskToCreateDataPartition(addr string, dataPartitionSize uint64, peers []proto.Peer, hosts []string, createType int, encrypted bool, checksumType uint8) (task *proto.AdminTask) {
 
 	task = proto.NewAdminTask(proto.OpCreateDataPartition, addr, newCreateDataPartitionRequest(
-		partition.VolName, partition.PartitionID, peers, int(dataPartitionSize), hosts, createType))
+		partition.VolName, partition.PartitionID, peers, int(dataPartitionSize), hosts, createType, encrypted, checksumType))
 	partition.resetTaskID(task)
 	return
 }
@@ -551,6 +595,10 @@ func (partition *DataPartition) updateMetric(vr *proto.PartitionReport, dataNode
 	replica.setAlive()
 	replica.IsLeader = vr.IsLeader
 	replica.NeedsToCompare = vr.NeedCompare
+	replica.AccessStats = vr.AccessStats
+	replica.LastWriteTime = vr.LastWriteTime
+	replica.ECStatus = vr.ECStatus
+	replica.ECProgress = vr.ECProgress
 	if replica.DiskPath != vr.DiskPath && vr.DiskPath != "" {
 		oldDiskPath := replica.DiskPath
 		replica.DiskPath = vr.DiskPath
@@ -566,6 +614,24 @@ func (partition *DataPartition) updateMetric(vr *proto.PartitionReport, dataNode
 	}
 }
 
+// refreshReplica keeps the replica on the data node alive by its unchanged report, and returns false
+// if the data node does not have a replica of the partition yet.
+func (partition *DataPartition) refreshReplica(vr *proto.PartitionReport, dataNode *DataNode) bool {
+	partition.Lock()
+	defer partition.Unlock()
+	replica, err := partition.getReplica(dataNode.Addr)
+	if err != nil {
+		return false
+	}
+	replica.Status = int8(vr.PartitionStatus)
+	replica.setAlive()
+	partition.checkAndRemoveMissReplica(dataNode.Addr)
+	if replica.Status == proto.ReadWrite && replica.dataNode.RdOnly {
+		replica.Status = int8(proto.ReadOnly)
+	}
+	return true
+}
+
 func (partition *DataPartition) setMaxUsed() {
 	var maxUsed uint64
 	for _, r := range partition.Replicas {
@@ -727,5 +793,6 @@ func (partition *DataPartition) ToProto(c *Cluster) *proto.DataPartitionInfo {
 		OfflinePeerID:           partition.OfflinePeerID,
 		IsRecover:               partition.isRecover,
 		FilesWithMissingReplica: partition.FilesWithMissingReplica,
+		ECStatus:                partition.ECStatus,
 	}
 }
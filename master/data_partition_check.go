@@ -57,6 +57,9 @@ func (partition *DataPartition) checkStatus(clusterName string, needLog bool, dp
 }
 
 func (partition *DataPartition) canWrite() bool {
+	if partition.ECStatus != proto.ECStatusNone {
+		return false
+	}
 	avail := partition.total - partition.used
 	if int64(avail) > 10*util.GB {
 		return true
@@ -105,6 +108,10 @@ func (partition *DataPartition) checkMissingReplicas(clusterID, leaderAddr strin
 	partition.Lock()
 	defer partition.Unlock()
 	for _, replica := range partition.Replicas {
+		// the replica is reported once the data node loads its partitions
+		if dataNode := replica.getReplicaNode(); dataNode != nil && dataNode.isLoading() {
+			continue
+		}
 		if partition.hasHost(replica.Addr) && replica.isMissing(dataPartitionMissSec) == true && partition.needToAlarmMissingDataPartition(replica.Addr, dataPartitionWarnInterval) {
 			dataNode := replica.getReplicaNode()
 			var (
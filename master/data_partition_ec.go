@@ -0,0 +1,240 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The data partitions not written for the configured days are converted to erasure coding
+// in the background. The replicas encode the shards of the extents from their own extents
+// and report a digest of the extents encoded, the conversion is committed if the digests
+// of all the replicas are the same, and aborted otherwise. The replicas release the space
+// of the extents once the conversion is committed.
+
+func (partition *DataPartition) createTasksToConvertDataPartitionToEC(phase uint8, hosts []string) (tasks []*proto.AdminTask) {
+	tasks = make([]*proto.AdminTask, 0, len(hosts))
+	for _, addr := range hosts {
+		request := &proto.ConvertDataPartitionToECRequest{PartitionId: partition.PartitionID, Hosts: partition.Hosts, Phase: phase}
+		task := proto.NewAdminTask(proto.OpConvertDataPartitionToEC, addr, request)
+		partition.resetTaskID(task)
+		task.ID = fmt.Sprintf("%v_Phase[%v]", task.ID, phase)
+		tasks = append(tasks, task)
+	}
+	return
+}
+
+// isColdForEC returns true if the data partition has not been written on any replica for the
+// given seconds, and all its replicas are alive to be encoded.
+func (partition *DataPartition) isColdForEC(coldSec int64) bool {
+	partition.RLock()
+	defer partition.RUnlock()
+	if partition.ECStatus != proto.ECStatusNone || partition.isRecover || partition.used == 0 ||
+		partition.ReplicaNum != defaultReplicaNum || len(partition.Hosts) != defaultReplicaNum {
+		return false
+	}
+	liveReplicas := partition.liveReplicas(defaultDataPartitionTimeOutSec)
+	if len(liveReplicas) != len(partition.Hosts) {
+		return false
+	}
+	now := time.Now().Unix()
+	for _, replica := range liveReplicas {
+		if replica.LastWriteTime <= 0 || now-replica.LastWriteTime < coldSec {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cluster) scheduleToConvertColdDataPartitions() {
+	go func() {
+		for {
+			if c.cfg.ECColdDays > 0 && c.partition != nil && c.partition.IsRaftLeader() {
+				c.convertColdDataPartitions()
+			}
+			time.Sleep(time.Second * defaultIntervalToConvertColdDataPartitions)
+		}
+	}()
+}
+
+// convertColdDataPartitions starts to convert the cold data partitions, and makes the
+// conversions interrupted by a change of the master leader, or the replicas not committed
+// yet, go on.
+func (c *Cluster) convertColdDataPartitions() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogWarnf("convertColdDataPartitions occurred panic,err[%v]", r)
+			WarnBySpecialKey(fmt.Sprintf("%v_%v_scheduling_job_panic", c.Name, ModuleName),
+				"convertColdDataPartitions occurred panic")
+		}
+	}()
+	coldSec := c.cfg.ECColdDays * 24 * 3600
+	converting := 0
+	candidates := make([]*DataPartition, 0)
+	for _, vol := range c.allVols() {
+		for _, dp := range vol.cloneDataPartitionMap() {
+			dp.Lock()
+			switch dp.ECStatus {
+			case proto.ECStatusEncoding:
+				converting++
+				if dp.ecDigests == nil {
+					// the digests have been lost with the former master leader
+					dp.ecDigests = make(map[string]uint32)
+					c.addDataNodeTasks(dp.createTasksToConvertDataPartitionToEC(proto.ECPhaseEncode, dp.Hosts))
+				}
+			case proto.ECStatusConverted:
+				for _, replica := range dp.Replicas {
+					if replica.ECStatus == proto.ECStatusEncoding {
+						c.addDataNodeTasks(dp.createTasksToConvertDataPartitionToEC(proto.ECPhaseCommit, []string{replica.Addr}))
+					}
+				}
+			}
+			dp.Unlock()
+			if !vol.encrypted && dp.isColdForEC(coldSec) {
+				candidates = append(candidates, dp)
+			}
+		}
+	}
+	for _, dp := range candidates {
+		if converting >= defaultMaxConvertingDataPartitions {
+			return
+		}
+		if err := c.convertDataPartitionToEC(dp); err != nil {
+			log.LogWarnf("action[convertColdDataPartitions] partition(%v) err(%v)", dp.PartitionID, err)
+			continue
+		}
+		converting++
+	}
+}
+
+// convertDataPartitionToEC stops the writes of the data partition, and asks its replicas to
+// encode it.
+func (c *Cluster) convertDataPartitionToEC(dp *DataPartition) (err error) {
+	vol, err := c.getVol(dp.VolName)
+	if err != nil {
+		return
+	}
+	if vol.encrypted {
+		return fmt.Errorf("the data partitions of the encrypted volumes cannot be erasure coded")
+	}
+	dp.Lock()
+	defer dp.Unlock()
+	if dp.ECStatus != proto.ECStatusNone {
+		return proto.ErrDataPartitionEC
+	}
+	if dp.ReplicaNum != defaultReplicaNum || len(dp.Hosts) != defaultReplicaNum {
+		return fmt.Errorf("only the data partitions of %v replicas can be erasure coded", defaultReplicaNum)
+	}
+	if len(dp.liveReplicas(defaultDataPartitionTimeOutSec)) != len(dp.Hosts) {
+		return proto.ErrNoEnoughReplica
+	}
+	dp.ECStatus = proto.ECStatusEncoding
+	if err = c.syncUpdateDataPartition(dp); err != nil {
+		dp.ECStatus = proto.ECStatusNone
+		return
+	}
+	dp.Status = proto.ReadOnly
+	dp.ecDigests = make(map[string]uint32)
+	c.addDataNodeTasks(dp.createTasksToConvertDataPartitionToEC(proto.ECPhaseEncode, dp.Hosts))
+	log.LogInfof("action[convertDataPartitionToEC] vol(%v) partition(%v) hosts(%v) starts to be encoded",
+		dp.VolName, dp.PartitionID, dp.Hosts)
+	return
+}
+
+func (c *Cluster) handleResponseToConvertDataPartitionToEC(nodeAddr string, resp *proto.ConvertDataPartitionToECResponse) (err error) {
+	dp, err := c.getDataPartitionByID(resp.PartitionId)
+	if err != nil {
+		return
+	}
+	if resp.Phase != proto.ECPhaseEncode {
+		if resp.Status == proto.TaskFailed {
+			Warn(c.Name, fmt.Sprintf("clusterID[%v] data partition[%v] phase[%v] of erasure coding on [%v] failed,err[%v]",
+				c.Name, resp.PartitionId, resp.Phase, nodeAddr, resp.Result))
+			return
+		}
+		log.LogInfof("action[handleResponseToConvertDataPartitionToEC] partition(%v) phase(%v) on (%v), extents(%v) bytes(%v)",
+			resp.PartitionId, resp.Phase, nodeAddr, resp.Extents, resp.Bytes)
+		return
+	}
+	dp.Lock()
+	defer dp.Unlock()
+	if dp.ECStatus != proto.ECStatusEncoding || dp.ecDigests == nil {
+		return
+	}
+	if resp.Status == proto.TaskFailed {
+		return c.abortDataPartitionToEC(dp, fmt.Sprintf("encoding on [%v] failed,err[%v]", nodeAddr, resp.Result))
+	}
+	dp.ecDigests[nodeAddr] = resp.Digest
+	for addr, digest := range dp.ecDigests {
+		if digest != resp.Digest {
+			return c.abortDataPartitionToEC(dp, fmt.Sprintf("digest[%v] on [%v] mismatches digest[%v] on [%v]",
+				resp.Digest, nodeAddr, digest, addr))
+		}
+	}
+	for _, host := range dp.Hosts {
+		if _, ok := dp.ecDigests[host]; !ok {
+			return
+		}
+	}
+	dp.ECStatus = proto.ECStatusConverted
+	if err = c.syncUpdateDataPartition(dp); err != nil {
+		dp.ECStatus = proto.ECStatusEncoding
+		return
+	}
+	dp.ecDigests = nil
+	c.addDataNodeTasks(dp.createTasksToConvertDataPartitionToEC(proto.ECPhaseCommit, dp.Hosts))
+	log.LogInfof("action[handleResponseToConvertDataPartitionToEC] partition(%v) converted, extents(%v) bytes(%v)",
+		dp.PartitionID, resp.Extents, resp.Bytes)
+	return
+}
+
+// abortDataPartitionToEC must be called with the lock of the data partition held.
+func (c *Cluster) abortDataPartitionToEC(dp *DataPartition, reason string) (err error) {
+	dp.ECStatus = proto.ECStatusNone
+	if err = c.syncUpdateDataPartition(dp); err != nil {
+		dp.ECStatus = proto.ECStatusEncoding
+		return
+	}
+	dp.ecDigests = nil
+	c.addDataNodeTasks(dp.createTasksToConvertDataPartitionToEC(proto.ECPhaseAbort, dp.Hosts))
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] conversion of data partition[%v] to erasure coding aborted: %v",
+		c.Name, dp.PartitionID, reason))
+	return
+}
+
+// getECStatus returns the erasure coding of the data partition on its replicas.
+func (partition *DataPartition) getECStatus() (status *proto.DataPartitionECStatus) {
+	partition.RLock()
+	defer partition.RUnlock()
+	status = &proto.DataPartitionECStatus{
+		PartitionID: partition.PartitionID,
+		VolName:     partition.VolName,
+		ECStatus:    partition.ECStatus,
+		Replicas:    make([]*proto.ECReplicaStatus, 0, len(partition.Replicas)),
+	}
+	for _, replica := range partition.Replicas {
+		status.Replicas = append(status.Replicas, &proto.ECReplicaStatus{
+			Addr:          replica.Addr,
+			ECStatus:      replica.ECStatus,
+			LastWriteTime: replica.LastWriteTime,
+			ECProgress:    replica.ECProgress,
+		})
+	}
+	return
+}
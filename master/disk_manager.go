@@ -16,8 +16,10 @@ package master
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/log"
 )
@@ -27,6 +29,7 @@ func (c *Cluster) scheduleToCheckDiskRecoveryProgress() {
 		for {
 			if c.partition != nil && c.partition.IsRaftLeader() {
 				if c.vols != nil {
+					c.checkBadDisks()
 					c.checkDiskRecoveryProgress()
 				}
 			}
@@ -106,3 +109,198 @@ func (c *Cluster) decommissionDisk(dataNode *DataNode, badDiskPath string, badPa
 	Warn(c.Name, msg)
 	return
 }
+
+// handleBadDisk records a disk isolated by a data node because of its IO errors before the
+// next heartbeat of the data node, and repairs the data partitions on the disk.
+func (c *Cluster) handleBadDisk(dataNode *DataNode, diskPath string) {
+	dataNode.Lock()
+	known := false
+	for _, path := range dataNode.BadDisks {
+		if path == diskPath {
+			known = true
+			break
+		}
+	}
+	if !known {
+		dataNode.BadDisks = append(dataNode.BadDisks, diskPath)
+	}
+	dataNode.Unlock()
+	Warn(c.Name, fmt.Sprintf("action[handleBadDisk] clusterID[%v] node[%v] disk[%v] is isolated because of its IO errors",
+		c.Name, dataNode.Addr, diskPath))
+	c.repairBadDisk(dataNode, diskPath)
+}
+
+// checkBadDisks repairs the data partitions on the bad disks reported by the heartbeats of
+// the data nodes, which are left after a report of the data node failed.
+func (c *Cluster) checkBadDisks() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogWarnf("checkBadDisks occurred panic,err[%v]", r)
+			WarnBySpecialKey(fmt.Sprintf("%v_%v_scheduling_job_panic", c.Name, ModuleName),
+				"checkBadDisks occurred panic")
+		}
+	}()
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		badDisks := dataNode.BadDisks
+		isActive := dataNode.isActive
+		dataNode.RUnlock()
+		if !isActive {
+			return true
+		}
+		for _, path := range badDisks {
+			c.repairBadDisk(dataNode, path)
+		}
+		return true
+	})
+}
+
+// repairBadDisk decommissions the data partitions on the bad disk, unless they are being
+// recovered or autoRepairBadDisk is disabled.
+func (c *Cluster) repairBadDisk(dataNode *DataNode, diskPath string) {
+	if !c.cfg.AutoRepairBadDisk {
+		return
+	}
+	c.badDiskMutex.Lock()
+	defer c.badDiskMutex.Unlock()
+	if _, recovering := c.BadDataPartitionIds.Load(fmt.Sprintf("%s:%s", dataNode.Addr, diskPath)); recovering {
+		return
+	}
+	partitions := dataNode.badPartitions(diskPath, c)
+	if len(partitions) == 0 {
+		return
+	}
+	if err := c.decommissionDisk(dataNode, diskPath, partitions); err != nil {
+		log.LogErrorf("action[repairBadDisk] node[%v] disk[%v] err[%v]", dataNode.Addr, diskPath, err)
+	}
+}
+
+// degradedDiskManager keeps the disks marked for drain because of their SMART attributes.
+type degradedDiskManager struct {
+	sync.RWMutex
+	disks map[string]*proto.DegradedDisk // key: addr:path
+}
+
+func newDegradedDiskManager() *degradedDiskManager {
+	return &degradedDiskManager{disks: make(map[string]*proto.DegradedDisk)}
+}
+
+// smartDegradeReason returns why the SMART attributes indicate a disk going to fail, or
+// an empty string if the disk looks healthy.
+func smartDegradeReason(info *proto.DiskSmartInfo) string {
+	switch {
+	case !info.Passed:
+		return "smart health self-assessment failed"
+	case info.ReallocatedSectors >= defaultSmartReallocatedSectorsThreshold:
+		return fmt.Sprintf("reallocated sectors(%v)", info.ReallocatedSectors)
+	case info.PendingSectors >= defaultSmartPendingSectorsThreshold:
+		return fmt.Sprintf("pending sectors(%v)", info.PendingSectors)
+	case info.UncorrectableSectors > 0:
+		return fmt.Sprintf("uncorrectable sectors(%v)", info.UncorrectableSectors)
+	case info.MediaErrors > 0:
+		return fmt.Sprintf("media errors(%v)", info.MediaErrors)
+	case info.PercentageUsed >= defaultSmartPercentageUsedThreshold:
+		return fmt.Sprintf("percentage used(%v)", info.PercentageUsed)
+	}
+	return ""
+}
+
+func (c *Cluster) scheduleToCheckDegradedDisks() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.checkDegradedDisks()
+			}
+			time.Sleep(time.Second * defaultIntervalToCheckDegradedDisk)
+		}
+	}()
+}
+
+// checkDegradedDisks marks the disks whose SMART attributes are degrading, and drains
+// them by batches if enabled, before they fail.
+func (c *Cluster) checkDegradedDisks() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogWarnf("checkDegradedDisks occurred panic,err[%v]", r)
+			WarnBySpecialKey(fmt.Sprintf("%v_%v_scheduling_job_panic", c.Name, ModuleName),
+				"checkDegradedDisks occurred panic")
+		}
+	}()
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		smarts := dataNode.DiskSmarts
+		dataNode.RUnlock()
+		for _, smart := range smarts {
+			reason := smartDegradeReason(smart)
+			if reason == "" {
+				// the disk has been replaced
+				c.unmarkDegradedDisk(dataNode.Addr, smart.Path)
+				continue
+			}
+			dd := c.markDegradedDisk(dataNode.Addr, smart.Path, reason)
+			if c.cfg.AutoDrainDegradedDisk {
+				c.drainDegradedDisk(dataNode, dd)
+			}
+		}
+		return true
+	})
+}
+
+func (c *Cluster) markDegradedDisk(addr, path, reason string) (dd *proto.DegradedDisk) {
+	key := fmt.Sprintf("%s:%s", addr, path)
+	c.degradedDisks.Lock()
+	defer c.degradedDisks.Unlock()
+	if dd = c.degradedDisks.disks[key]; dd != nil {
+		return
+	}
+	dd = &proto.DegradedDisk{Addr: addr, Path: path, Reason: reason, MarkTime: time.Now().Unix()}
+	c.degradedDisks.disks[key] = dd
+	Warn(c.Name, fmt.Sprintf("action[markDegradedDisk] clusterID[%v] node[%v] disk[%v] is degrading: %v",
+		c.Name, addr, path, reason))
+	return
+}
+
+func (c *Cluster) unmarkDegradedDisk(addr, path string) {
+	key := fmt.Sprintf("%s:%s", addr, path)
+	c.degradedDisks.Lock()
+	defer c.degradedDisks.Unlock()
+	if _, ok := c.degradedDisks.disks[key]; ok {
+		delete(c.degradedDisks.disks, key)
+		log.LogWarnf("action[unmarkDegradedDisk] node[%v] disk[%v] is healthy again", addr, path)
+	}
+}
+
+// drainDegradedDisk decommissions a batch of the data partitions on the disk, once the
+// previous batch has recovered.
+func (c *Cluster) drainDegradedDisk(dataNode *DataNode, dd *proto.DegradedDisk) {
+	if _, recovering := c.BadDataPartitionIds.Load(fmt.Sprintf("%s:%s", dd.Addr, dd.Path)); recovering {
+		return
+	}
+	partitions := dataNode.badPartitions(dd.Path, c)
+	if len(partitions) == 0 {
+		return
+	}
+	if len(partitions) > defaultDegradedDiskDrainBatch {
+		partitions = partitions[:defaultDegradedDiskDrainBatch]
+	}
+	if err := c.decommissionDisk(dataNode, dd.Path, partitions); err != nil {
+		log.LogErrorf("action[drainDegradedDisk] node[%v] disk[%v] err[%v]", dd.Addr, dd.Path, err)
+		return
+	}
+	c.degradedDisks.Lock()
+	dd.Drained += len(partitions)
+	c.degradedDisks.Unlock()
+}
+
+func (c *Cluster) getDegradedDisks() (disks []*proto.DegradedDisk) {
+	c.degradedDisks.RLock()
+	defer c.degradedDisks.RUnlock()
+	disks = make([]*proto.DegradedDisk, 0, len(c.degradedDisks.disks))
+	for _, dd := range c.degradedDisks.disks {
+		view := *dd
+		disks = append(disks, &view)
+	}
+	return
+}
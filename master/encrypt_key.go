@@ -0,0 +1,268 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/kms"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	encryptKeySize = 32
+	// kmsEncryptKeyID is the id of the data keys wrapped by the KMS, which is never the id of a master key
+	kmsEncryptKeyID = 0
+)
+
+// encryptKeyManager wraps the data keys of the encrypted volumes with the master keys, so that
+// the data keys persisted by the masters are useless without the master keys.
+// The master keys are read from a file of lines "<id> <hex key>" that every master holds, and
+// the key with the largest id wraps the data keys. A new master key is rotated in by appending
+// it to the file and calling the rotation API, which re-wraps the data keys without touching
+// the data encrypted with them.
+// If the KMS is configured, the data keys are wrapped by the KMS instead, and the master keys of
+// the file only unwrap the data keys wrapped before, until they are re-wrapped by the rotation.
+type encryptKeyManager struct {
+	sync.RWMutex
+	keyFile    string
+	masterKeys map[uint32][]byte
+	currentID  uint32
+	kms        kms.Provider
+}
+
+func newEncryptKeyManager(keyFile string, provider kms.Provider) (m *encryptKeyManager, err error) {
+	m = &encryptKeyManager{keyFile: keyFile, masterKeys: make(map[uint32][]byte), kms: provider}
+	if keyFile == "" {
+		return
+	}
+	err = m.load()
+	return
+}
+
+func (m *encryptKeyManager) load() (err error) {
+	if m.keyFile == "" {
+		if m.kms != nil {
+			return
+		}
+		return fmt.Errorf("no %v is configured", cfgEncryptKeyFile)
+	}
+	fp, err := os.Open(m.keyFile)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	masterKeys := make(map[uint32][]byte)
+	var currentID uint32
+	scanner := bufio.NewScanner(fp)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid line %v of %v", lineNo, m.keyFile)
+		}
+		id, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil || id == 0 {
+			return fmt.Errorf("invalid key id at line %v of %v", lineNo, m.keyFile)
+		}
+		key, err := hex.DecodeString(fields[1])
+		if err != nil || len(key) != encryptKeySize {
+			return fmt.Errorf("invalid key at line %v of %v, a key has %v bytes in hex", lineNo, m.keyFile, encryptKeySize)
+		}
+		masterKeys[uint32(id)] = key
+		if uint32(id) > currentID {
+			currentID = uint32(id)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.masterKeys = masterKeys
+	m.currentID = currentID
+	log.LogInfof("action[loadEncryptKeys] keys(%v) current key(%v)", len(masterKeys), currentID)
+	return
+}
+
+func (m *encryptKeyManager) getCurrentID() uint32 {
+	if m.kms != nil {
+		return kmsEncryptKeyID
+	}
+	m.RLock()
+	defer m.RUnlock()
+	return m.currentID
+}
+
+// wrap encrypts the data key by the KMS, or with the current master key in AES-GCM.
+func (m *encryptKeyManager) wrap(dataKey []byte) (keyID uint32, wrapped []byte, err error) {
+	if m.kms != nil {
+		_, wrapped, err = m.kms.Encrypt("", dataKey)
+		return kmsEncryptKeyID, wrapped, err
+	}
+	m.RLock()
+	keyID = m.currentID
+	masterKey := m.masterKeys[keyID]
+	m.RUnlock()
+	if masterKey == nil {
+		err = fmt.Errorf("no master key is loaded")
+		return
+	}
+	aead, err := newKeyAEAD(masterKey)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	wrapped = aead.Seal(nonce, nonce, dataKey, nil)
+	return
+}
+
+func (m *encryptKeyManager) unwrap(keyID uint32, wrapped []byte) (dataKey []byte, err error) {
+	if keyID == kmsEncryptKeyID {
+		if m.kms == nil {
+			return nil, fmt.Errorf("data key wrapped by KMS but no KMS is configured")
+		}
+		return m.kms.Decrypt("", wrapped)
+	}
+	m.RLock()
+	masterKey := m.masterKeys[keyID]
+	m.RUnlock()
+	if masterKey == nil {
+		err = fmt.Errorf("master key(%v) is not loaded", keyID)
+		return
+	}
+	aead, err := newKeyAEAD(masterKey)
+	if err != nil {
+		return
+	}
+	if len(wrapped) < aead.NonceSize() {
+		err = fmt.Errorf("invalid wrapped key")
+		return
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+}
+
+func newKeyAEAD(masterKey []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// initVolEncryptKey generates the data key of a new encrypted volume.
+func (c *Cluster) initVolEncryptKey(vol *Vol) (err error) {
+	dataKey := make([]byte, encryptKeySize)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return
+	}
+	if vol.encryptKeyID, vol.wrappedEncryptKey, err = c.encryptKeys.wrap(dataKey); err != nil {
+		return
+	}
+	vol.encrypted = true
+	vol.dataKey = dataKey
+	return
+}
+
+// getVolEncryptKeys returns the data keys of the encrypted volumes, which are carried by the
+// heartbeats to the data nodes.
+func (c *Cluster) getVolEncryptKeys() (dataKeys map[string][]byte) {
+	dataKeys = make(map[string][]byte)
+	for name, vol := range c.allVols() {
+		if !vol.encrypted {
+			continue
+		}
+		dataKey, err := vol.getDataKey(c.encryptKeys)
+		if err != nil {
+			log.LogErrorf("action[getVolEncryptKeys] vol(%v) err(%v)", name, err)
+			continue
+		}
+		dataKeys[name] = dataKey
+	}
+	return
+}
+
+// rotateEncryptKey reloads the master keys and re-wraps the data keys with the current one, or by the KMS if configured.
+func (c *Cluster) rotateEncryptKey() (rotated int, err error) {
+	if err = c.encryptKeys.load(); err != nil {
+		return
+	}
+	currentID := c.encryptKeys.getCurrentID()
+	for _, vol := range c.allVols() {
+		if !vol.encrypted || vol.encryptKeyID == currentID {
+			continue
+		}
+		if err = c.rewrapVolEncryptKey(vol); err != nil {
+			err = fmt.Errorf("rewrap the key of vol(%v): %v", vol.Name, err)
+			return
+		}
+		rotated++
+	}
+	log.LogInfof("action[rotateEncryptKey] rotated(%v) current key(%v)", rotated, currentID)
+	return
+}
+
+func (c *Cluster) rewrapVolEncryptKey(vol *Vol) (err error) {
+	dataKey, err := vol.getDataKey(c.encryptKeys)
+	if err != nil {
+		return
+	}
+	vol.volLock.Lock()
+	defer vol.volLock.Unlock()
+	oldKeyID, oldWrappedKey := vol.encryptKeyID, vol.wrappedEncryptKey
+	if vol.encryptKeyID, vol.wrappedEncryptKey, err = c.encryptKeys.wrap(dataKey); err != nil {
+		vol.encryptKeyID, vol.wrappedEncryptKey = oldKeyID, oldWrappedKey
+		return
+	}
+	if err = c.syncUpdateVol(vol); err != nil {
+		vol.encryptKeyID, vol.wrappedEncryptKey = oldKeyID, oldWrappedKey
+		err = proto.ErrPersistenceByRaft
+	}
+	return
+}
+
+// getDataKey returns the data key of the volume, unwrapping it on the first call.
+func (vol *Vol) getDataKey(keys *encryptKeyManager) (dataKey []byte, err error) {
+	vol.volLock.RLock()
+	dataKey, keyID, wrapped := vol.dataKey, vol.encryptKeyID, vol.wrappedEncryptKey
+	vol.volLock.RUnlock()
+	if dataKey != nil {
+		return
+	}
+	if dataKey, err = keys.unwrap(keyID, wrapped); err != nil {
+		return
+	}
+	vol.volLock.Lock()
+	vol.dataKey = dataKey
+	vol.volLock.Unlock()
+	return
+}
@@ -0,0 +1,278 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// FlashNode caches the blocks of the extents read by the clients of the volumes enabling the flash cache.
+// The flash nodes keep no partitions, so they are not placed in the node sets, and the master only tells
+// the clients the active ones.
+type FlashNode struct {
+	sync.RWMutex
+	ID          uint64
+	Addr        string
+	ZoneName    string
+	Total       uint64
+	Used        uint64
+	Hits        uint64
+	Misses      uint64
+	Version     string
+	ReportTime  time.Time
+	isActive    bool
+	TaskManager *AdminTaskManager
+}
+
+func newFlashNode(addr, zoneName, clusterID string) *FlashNode {
+	return &FlashNode{
+		Addr:        addr,
+		ZoneName:    zoneName,
+		TaskManager: newAdminTaskManager(addr, clusterID),
+	}
+}
+
+func (flashNode *FlashNode) clean() {
+	flashNode.TaskManager.exitCh <- struct{}{}
+}
+
+func (flashNode *FlashNode) checkLiveness() {
+	flashNode.Lock()
+	defer flashNode.Unlock()
+	if time.Since(flashNode.ReportTime) > time.Second*time.Duration(defaultNodeTimeOutSec) {
+		flashNode.isActive = false
+	}
+}
+
+func (flashNode *FlashNode) isAvailable() bool {
+	flashNode.RLock()
+	defer flashNode.RUnlock()
+	return flashNode.isActive
+}
+
+func (flashNode *FlashNode) createHeartbeatTask(masterAddr string) *proto.AdminTask {
+	request := &proto.FlashNodeHeartbeatRequest{
+		CurrTime:   time.Now().Unix(),
+		MasterAddr: masterAddr,
+	}
+	return proto.NewAdminTask(proto.OpFlashNodeHeartbeat, flashNode.Addr, request)
+}
+
+func (flashNode *FlashNode) updateMetric(resp *proto.FlashNodeHeartbeatResponse) {
+	flashNode.Lock()
+	defer flashNode.Unlock()
+	flashNode.ZoneName = resp.ZoneName
+	flashNode.Total = resp.Total
+	flashNode.Used = resp.Used
+	flashNode.Hits = resp.Hits
+	flashNode.Misses = resp.Misses
+	flashNode.Version = resp.Version
+	flashNode.ReportTime = time.Now()
+	flashNode.isActive = true
+}
+
+func (flashNode *FlashNode) view() *proto.FlashNodeView {
+	flashNode.RLock()
+	defer flashNode.RUnlock()
+	return &proto.FlashNodeView{
+		ID:         flashNode.ID,
+		Addr:       flashNode.Addr,
+		ZoneName:   flashNode.ZoneName,
+		IsActive:   flashNode.isActive,
+		Total:      flashNode.Total,
+		Used:       flashNode.Used,
+		Hits:       flashNode.Hits,
+		Misses:     flashNode.Misses,
+		Version:    flashNode.Version,
+		ReportTime: flashNode.ReportTime,
+	}
+}
+
+type flashNodeValue struct {
+	ID       uint64
+	Addr     string
+	ZoneName string
+}
+
+func newFlashNodeValue(flashNode *FlashNode) *flashNodeValue {
+	return &flashNodeValue{
+		ID:       flashNode.ID,
+		Addr:     flashNode.Addr,
+		ZoneName: flashNode.ZoneName,
+	}
+}
+
+// key=#fn#id#addr
+func (c *Cluster) syncAddFlashNode(flashNode *FlashNode) (err error) {
+	return c.syncPutFlashNodeInfo(opSyncAddFlashNode, flashNode)
+}
+
+func (c *Cluster) syncDeleteFlashNode(flashNode *FlashNode) (err error) {
+	return c.syncPutFlashNodeInfo(opSyncDeleteFlashNode, flashNode)
+}
+
+func (c *Cluster) syncPutFlashNodeInfo(opType uint32, flashNode *FlashNode) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opType
+	metadata.K = flashNodePrefix + fmt.Sprintf("%v", flashNode.ID) + keySeparator + flashNode.Addr
+	if metadata.V, err = json.Marshal(newFlashNodeValue(flashNode)); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadFlashNodes() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(flashNodePrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadFlashNodes],err:%v", err.Error())
+		return
+	}
+	for _, value := range result {
+		fnv := &flashNodeValue{}
+		if err = json.Unmarshal(value, fnv); err != nil {
+			err = fmt.Errorf("action[loadFlashNodes],value:%v,unmarshal err:%v", string(value), err)
+			return
+		}
+		flashNode := newFlashNode(fnv.Addr, fnv.ZoneName, c.Name)
+		flashNode.ID = fnv.ID
+		c.flashNodes.Store(flashNode.Addr, flashNode)
+		log.LogInfof("action[loadFlashNodes],flashNode[%v],flashNodeID[%v],zone[%v]", flashNode.Addr, flashNode.ID, flashNode.ZoneName)
+	}
+	return
+}
+
+func (c *Cluster) clearFlashNodes() {
+	c.flashNodes.Range(func(key, value interface{}) bool {
+		c.flashNodes.Delete(key)
+		value.(*FlashNode).clean()
+		return true
+	})
+}
+
+func (c *Cluster) flashNode(addr string) (flashNode *FlashNode, err error) {
+	value, ok := c.flashNodes.Load(addr)
+	if !ok {
+		return nil, fmt.Errorf("flash node[%v] not exists", addr)
+	}
+	return value.(*FlashNode), nil
+}
+
+func (c *Cluster) addFlashNode(nodeAddr, zoneName string) (id uint64, err error) {
+	c.fnMutex.Lock()
+	defer c.fnMutex.Unlock()
+	if value, ok := c.flashNodes.Load(nodeAddr); ok {
+		return value.(*FlashNode).ID, nil
+	}
+	flashNode := newFlashNode(nodeAddr, zoneName, c.Name)
+	if id, err = c.idAlloc.allocateCommonID(); err != nil {
+		goto errHandler
+	}
+	flashNode.ID = id
+	if err = c.syncAddFlashNode(flashNode); err != nil {
+		goto errHandler
+	}
+	c.flashNodes.Store(nodeAddr, flashNode)
+	log.LogInfof("action[addFlashNode],clusterID[%v] flashNodeAddr:%v,id[%v],zone[%v]", c.Name, nodeAddr, id, zoneName)
+	return
+errHandler:
+	err = fmt.Errorf("action[addFlashNode],clusterID[%v] flashNodeAddr:%v err:%v ", c.Name, nodeAddr, err.Error())
+	log.LogError(err)
+	Warn(c.Name, err.Error())
+	return
+}
+
+// decommissionFlashNode removes the flash node, the blocks it cached are cached by the other ones
+// once the clients learn the flash nodes again.
+func (c *Cluster) decommissionFlashNode(nodeAddr string) (err error) {
+	c.fnMutex.Lock()
+	defer c.fnMutex.Unlock()
+	flashNode, err := c.flashNode(nodeAddr)
+	if err != nil {
+		return
+	}
+	if err = c.syncDeleteFlashNode(flashNode); err != nil {
+		log.LogErrorf("action[decommissionFlashNode],clusterID[%v] flashNodeAddr:%v err:%v ", c.Name, nodeAddr, err)
+		return proto.ErrPersistenceByRaft
+	}
+	c.flashNodes.Delete(nodeAddr)
+	flashNode.clean()
+	log.LogWarnf("action[decommissionFlashNode],clusterID[%v] flashNodeAddr:%v", c.Name, nodeAddr)
+	return
+}
+
+// checkFlashNodeHeartbeat sends the heartbeats to the flash nodes, which reply in the same
+// connections.
+func (c *Cluster) checkFlashNodeHeartbeat() {
+	masterAddr := c.masterAddr()
+	var wg sync.WaitGroup
+	c.flashNodes.Range(func(addr, value interface{}) bool {
+		flashNode := value.(*FlashNode)
+		flashNode.checkLiveness()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			packet, err := flashNode.TaskManager.syncSendAdminTask(flashNode.createHeartbeatTask(masterAddr))
+			if err != nil {
+				log.LogWarnf("action[checkFlashNodeHeartbeat] flashNode[%v] err[%v]", flashNode.Addr, err)
+				return
+			}
+			resp := &proto.FlashNodeHeartbeatResponse{}
+			if err = json.Unmarshal(packet.Data, resp); err != nil {
+				log.LogWarnf("action[checkFlashNodeHeartbeat] flashNode[%v] unmarshal err[%v]", flashNode.Addr, err)
+				return
+			}
+			flashNode.updateMetric(resp)
+		}()
+		return true
+	})
+	wg.Wait()
+}
+
+func (c *Cluster) listFlashNodes() (views []*proto.FlashNodeView) {
+	views = make([]*proto.FlashNodeView, 0)
+	c.flashNodes.Range(func(addr, value interface{}) bool {
+		views = append(views, value.(*FlashNode).view())
+		return true
+	})
+	sort.Slice(views, func(i, j int) bool { return views[i].Addr < views[j].Addr })
+	return
+}
+
+// getFlashCacheView returns the active flash nodes to the clients of the volume.
+func (c *Cluster) getFlashCacheView(volName string) (view *proto.FlashCacheView, err error) {
+	vol, err := c.getVol(volName)
+	if err != nil {
+		return
+	}
+	view = &proto.FlashCacheView{Enabled: vol.flashCache, Nodes: make([]string, 0)}
+	if !view.Enabled {
+		return
+	}
+	c.flashNodes.Range(func(addr, value interface{}) bool {
+		if flashNode := value.(*FlashNode); flashNode.isAvailable() {
+			view.Nodes = append(view.Nodes, flashNode.Addr)
+		}
+		return true
+	})
+	sort.Strings(view.Nodes)
+	return
+}
@@ -0,0 +1,55 @@
+package master
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestFlashNode(t *testing.T) {
+	addr := "127.0.0.1:17510"
+	process(fmt.Sprintf("%v%v?addr=%v&zoneName=%v", hostAddr, proto.AddFlashNode, addr, testZone2), t)
+	flashNode, err := server.cluster.flashNode(addr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	process(fmt.Sprintf("%v%v?addr=%v", hostAddr, proto.GetFlashNode, addr), t)
+	process(fmt.Sprintf("%v%v", hostAddr, proto.ListFlashNodes), t)
+
+	vol, err := server.cluster.getVol(commonVolName)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	reqURL := fmt.Sprintf("%v%v?name=%v&authKey=%v&flashCache=true", hostAddr, proto.AdminUpdateVol, commonVolName, buildAuthKey(vol.Owner))
+	process(reqURL, t)
+	if !vol.flashCache {
+		t.Errorf("flash cache of vol[%v] not enabled", commonVolName)
+		return
+	}
+	// the flash node is told to the clients only after it replies the heartbeat
+	if view, err := server.cluster.getFlashCacheView(commonVolName); err != nil || !view.Enabled || len(view.Nodes) != 0 {
+		t.Errorf("unexpected view %v err %v", view, err)
+		return
+	}
+	flashNode.updateMetric(&proto.FlashNodeHeartbeatResponse{ZoneName: testZone2, Total: 1 << 30})
+	if view, err := server.cluster.getFlashCacheView(commonVolName); err != nil || len(view.Nodes) != 1 || view.Nodes[0] != addr {
+		t.Errorf("unexpected view %v err %v", view, err)
+		return
+	}
+	flashNode.ReportTime = time.Now().Add(-time.Second * time.Duration(defaultNodeTimeOutSec+1))
+	flashNode.checkLiveness()
+	if view, _ := server.cluster.getFlashCacheView(commonVolName); len(view.Nodes) != 0 {
+		t.Errorf("inactive flash node told to the clients %v", view)
+	}
+
+	reqURL = fmt.Sprintf("%v%v?name=%v&authKey=%v&flashCache=false", hostAddr, proto.AdminUpdateVol, commonVolName, buildAuthKey(vol.Owner))
+	process(reqURL, t)
+	process(fmt.Sprintf("%v%v?addr=%v", hostAddr, proto.DecommissionFlashNode, addr), t)
+	if _, err = server.cluster.flashNode(addr); err == nil {
+		t.Errorf("flash node[%v] not decommissioned", addr)
+	}
+}
@@ -176,6 +176,8 @@ func (s *VolumeService) createVolume(ctx context.Context, args struct {
 	Name, Owner, ZoneName, Description                     string
 	Capacity, DataPartitionSize, MpCount, DpReplicaNum     uint64
 	FollowerRead, Authenticate, CrossZone, DefaultPriority bool
+	CaseInsensitive                                        *bool
+	Encrypted                                              *bool
 }) (*Vol, error) {
 	uid, per, err := permissions(ctx, ADMIN|USER)
 	if err != nil {
@@ -192,7 +194,8 @@ func (s *VolumeService) createVolume(ctx context.Context, args struct {
 
 	vol, err := s.cluster.createVol(args.Name, args.Owner, args.ZoneName, args.Description, int(args.MpCount),
 		int(args.DpReplicaNum), int(args.DataPartitionSize), int(args.Capacity),
-		args.FollowerRead, args.Authenticate, args.CrossZone, args.DefaultPriority)
+		args.FollowerRead, args.Authenticate, args.CrossZone, args.DefaultPriority,
+		args.CaseInsensitive != nil && *args.CaseInsensitive, args.Encrypted != nil && *args.Encrypted, proto.ChecksumCrc32)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +29,11 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/ipfilter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tlsutil"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 func (m *Server) startHTTPService(modulename string, cfg *config.Config) {
@@ -41,10 +45,10 @@ func (m *Server) startHTTPService(modulename string, cfg *config.Config) {
 	exporter.InitWithRouter(modulename, cfg, router, m.port)
 	var server = &http.Server{
 		Addr:    colonSplit + m.port,
-		Handler: router,
+		Handler: ipfilter.Handler(router),
 	}
 	var serveAPI = func() {
-		if err := server.ListenAndServe(); err != nil {
+		if err := tlsutil.ListenAndServe(server); err != nil {
 			log.LogErrorf("serveAPI: serve http server failed: err(%v)", err)
 			return
 		}
@@ -74,7 +78,8 @@ func (m *Server) registerAPIMiddleware(route *mux.Router) {
 	var interceptor mux.MiddlewareFunc = func(next http.Handler) http.Handler {
 		return http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
-				log.LogDebugf("action[interceptor] request, method[%v] path[%v] query[%v]", r.Method, r.URL.Path, r.URL.Query())
+				log.LogDebugf("action[interceptor] request, requestID[%v] method[%v] path[%v] query[%v]",
+					r.Header.Get(reqid.Header), r.Method, r.URL.Path, r.URL.Query())
 				if mux.CurrentRoute(r).GetName() == proto.AdminGetIP {
 					next.ServeHTTP(w, r)
 					return
@@ -83,8 +88,9 @@ func (m *Server) registerAPIMiddleware(route *mux.Router) {
 				isFollowerRead := m.isFollowerRead(r)
 				if m.partition.IsRaftLeader() || isFollowerRead {
 					if m.metaReady || isFollowerRead {
-						log.LogDebugf("action[interceptor] request, method[%v] path[%v] query[%v]", r.Method, r.URL.Path, r.URL.Query())
-						next.ServeHTTP(w, r)
+						log.LogDebugf("action[interceptor] request, requestID[%v] method[%v] path[%v] query[%v]",
+							r.Header.Get(reqid.Header), r.Method, r.URL.Path, r.URL.Query())
+						m.serveAudited(next, w, r)
 						return
 					}
 					log.LogWarnf("action[interceptor] leader meta has not ready")
@@ -99,7 +105,7 @@ func (m *Server) registerAPIMiddleware(route *mux.Router) {
 				m.proxy(w, r)
 			})
 	}
-	route.Use(interceptor)
+	route.Use(reqid.Handler, tracing.ServerHandler, interceptor)
 }
 
 func (m *Server) registerAPIRoutes(router *mux.Router) {
@@ -146,6 +152,54 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminUpdateVol).
 		HandlerFunc(m.updateVol)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminRotateEncryptKey).
+		HandlerFunc(m.rotateEncryptKey)
+	router.NewRoute().Methods(http.MethodPost).
+		Path(proto.AdminAddServiceKey).
+		HandlerFunc(m.addServiceKey)
+	router.NewRoute().Methods(http.MethodPost).
+		Path(proto.AdminRetireServiceKey).
+		HandlerFunc(m.retireServiceKey)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminListServiceKeys).
+		HandlerFunc(m.listServiceKeys)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminCheckVersion).
+		HandlerFunc(m.checkVersion)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminSetRateLimit).
+		HandlerFunc(m.setRateLimit)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminDeleteRateLimit).
+		HandlerFunc(m.deleteRateLimit)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminListRateLimits).
+		HandlerFunc(m.listRateLimits)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminSLOStatus).
+		HandlerFunc(m.getSLOStatus)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetQuota).
+		HandlerFunc(m.getQuota)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminSetQuota).
+		HandlerFunc(m.setQuota)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminListQuotas).
+		HandlerFunc(m.listQuotas)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminCreateVolSnapshot).
+		HandlerFunc(m.createVolSnapshot)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminDeleteVolSnapshot).
+		HandlerFunc(m.deleteVolSnapshot)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminListVolSnapshots).
+		HandlerFunc(m.listVolSnapshots)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminRestoreVolSnapshot).
+		HandlerFunc(m.restoreVolSnapshot)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminVolShrink).
 		HandlerFunc(m.volShrink)
@@ -164,6 +218,9 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.AdminListVols).
 		HandlerFunc(m.listVols)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetQuotaUsage).
+		HandlerFunc(m.getQuotaUsage)
 
 	// node task response APIs
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
@@ -180,9 +237,24 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminDecommissionMetaPartition).
 		HandlerFunc(m.decommissionMetaPartition)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminBalanceMetaPartitionLeader).
+		HandlerFunc(m.balanceMetaPartitionLeader)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetMetaLeaderBalance).
+		HandlerFunc(m.getMetaLeaderBalanceStatus)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.ClientMetaPartitions).
 		HandlerFunc(m.getMetaPartitions)
+	router.NewRoute().Methods(http.MethodPost).
+		Path(proto.ClientRateLimits).
+		HandlerFunc(m.allocateRateLimits)
+	router.NewRoute().Methods(http.MethodPost).
+		Path(proto.ClientSLOReport).
+		HandlerFunc(m.reportSLO)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.ClientFlashNodes).
+		HandlerFunc(m.getFlashNodesOfVol)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.ClientMetaPartition).
 		HandlerFunc(m.getMetaPartition)
@@ -195,6 +267,12 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminDeleteMetaReplica).
 		HandlerFunc(m.deleteMetaReplica)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminAddMetaReplicaLearner).
+		HandlerFunc(m.addMetaReplicaLearner)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminPromoteMetaReplicaLearner).
+		HandlerFunc(m.promoteMetaReplicaLearner)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminDiagnoseMetaPartition).
 		HandlerFunc(m.diagnoseMetaPartition)
@@ -215,6 +293,30 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminDiagnoseDataPartition).
 		HandlerFunc(m.diagnoseDataPartition)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminCheckDataPartition).
+		HandlerFunc(m.checkDataPartition)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetDataPartitionCheck).
+		HandlerFunc(m.getDataPartitionCheck)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminBackupDataPartition).
+		HandlerFunc(m.backupDataPartition)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetDataPartitionBackup).
+		HandlerFunc(m.getDataPartitionBackup)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminRestoreDataPartition).
+		HandlerFunc(m.restoreDataPartition)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminConvertDataPartitionToEC).
+		HandlerFunc(m.convertDataPartitionToEC)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetDataPartitionECStatus).
+		HandlerFunc(m.getDataPartitionECStatus)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetHotDataPartitions).
+		HandlerFunc(m.getHotDataPartitions)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.ClientDataPartitions).
 		HandlerFunc(m.getDataPartitions)
@@ -250,6 +352,19 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 		Path(proto.AdminGetInvalidNodes).
 		HandlerFunc(m.checkInvalidIDNodes)
 
+	// flash node management APIs
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AddFlashNode).
+		HandlerFunc(m.addFlashNode)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.DecommissionFlashNode).
+		HandlerFunc(m.decommissionFlashNode)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.GetFlashNode).
+		HandlerFunc(m.getFlashNode)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.ListFlashNodes).
+		HandlerFunc(m.listFlashNodes)
 	// data node management APIs
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AddDataNode).
@@ -263,9 +378,21 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.GetDataNode).
 		HandlerFunc(m.getDataNode)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.ReportDataNodeLoadProgress).
+		HandlerFunc(m.reportDataNodeLoadProgress)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.ReportDataNodeBadDisk).
+		HandlerFunc(m.reportDataNodeBadDisk)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminSetDataNodeRepairLimit).
+		HandlerFunc(m.setDataNodeRepairLimit)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.DecommissionDisk).
 		HandlerFunc(m.decommissionDisk)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.AdminGetDegradedDisks).
+		HandlerFunc(m.getDegradedDisks)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminSetNodeInfo).
 		HandlerFunc(m.setNodeInfoHandler)
@@ -383,10 +510,13 @@ func ErrResponse(w http.ResponseWriter, err error) {
 }
 
 func (m *Server) newReverseProxy() *httputil.ReverseProxy {
-	return &httputil.ReverseProxy{Director: func(request *http.Request) {
-		request.URL.Scheme = "http"
-		request.URL.Host = m.leaderInfo.addr
-	}}
+	return &httputil.ReverseProxy{
+		Director: func(request *http.Request) {
+			request.URL.Scheme = tlsutil.HTTPScheme()
+			request.URL.Host = m.leaderInfo.addr
+		},
+		Transport: tlsutil.NewTransport(http.DefaultTransport.(*http.Transport).Clone()),
+	}
 }
 
 func (m *Server) proxy(w http.ResponseWriter, r *http.Request) {
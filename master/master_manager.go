@@ -167,6 +167,21 @@ func (m *Server) loadMetadata() {
 	if err = m.cluster.loadDataPartitions(); err != nil {
 		panic(err)
 	}
+	if err = m.cluster.loadServiceKeys(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadRateLimits(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadFlashNodes(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadQuotas(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadVolSnapshots(); err != nil {
+		panic(err)
+	}
 	log.LogInfo("action[loadMetadata] end")
 
 	log.LogInfo("action[loadUserInfo] begin")
@@ -193,7 +208,13 @@ func (m *Server) clearMetadata() {
 	m.cluster.clearTopology()
 	m.cluster.clearDataNodes()
 	m.cluster.clearMetaNodes()
+	m.cluster.clearFlashNodes()
 	m.cluster.clearVols()
+	m.cluster.serviceKeys.clear()
+	m.cluster.rateLimits.clear()
+	m.cluster.quotas.clear()
+	m.cluster.volSnapshots.clear()
+	m.cluster.slos.clear()
 	m.user.clearUserStore()
 	m.user.clearAKStore()
 	m.user.clearVolUsers()
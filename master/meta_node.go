@@ -20,12 +20,14 @@ import (
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 // MetaNode defines the structure of a meta node
 type MetaNode struct {
 	ID                        uint64
 	Addr                      string
+	ReplicaAddr               string // address for the replication traffic, empty if it shares Addr
 	IsActive                  bool
 	Sender                    *AdminTaskManager `graphql:"-"`
 	ZoneName                  string            `json:"Zone"`
@@ -45,6 +47,10 @@ type MetaNode struct {
 	PersistenceMetaPartitions []uint64
 	RdOnly                    bool
 	MigrateLock               sync.RWMutex
+	Version                   string
+	ClockSkew                 time.Duration                         `graphql:"-"` // clock of the meta node ahead of the master
+	reportSeq                 uint64                                // sequence of the last partition report applied, 0 to ask for a full one
+	partitionReports          map[uint64]*proto.MetaPartitionReport // the partition reports merged from the heartbeats
 }
 
 func newMetaNode(addr, zoneName, clusterID string) (node *MetaNode) {
@@ -112,11 +118,13 @@ func (metaNode *MetaNode) setNodeActive() {
 	metaNode.IsActive = true
 }
 
-func (metaNode *MetaNode) updateMetric(resp *proto.MetaNodeHeartbeatResponse, threshold float32) {
+// updateMetric updates the meta node by the heartbeat response, and returns the partition reports
+// not in the response since they are unchanged.
+func (metaNode *MetaNode) updateMetric(resp *proto.MetaNodeHeartbeatResponse, threshold float32) (unchanged []*proto.MetaPartitionReport) {
 	metaNode.Lock()
 	defer metaNode.Unlock()
-	metaNode.metaPartitionInfos = resp.MetaPartitionReports
-	metaNode.MetaPartitionCount = len(metaNode.metaPartitionInfos)
+	unchanged = metaNode.mergePartitionReports(resp)
+	metaNode.MetaPartitionCount = len(metaNode.partitionReports)
 	metaNode.Total = resp.Total
 	metaNode.Used = resp.Used
 	if resp.Total == 0 {
@@ -127,6 +135,57 @@ func (metaNode *MetaNode) updateMetric(resp *proto.MetaNodeHeartbeatResponse, th
 	metaNode.MaxMemAvailWeight = resp.Total - resp.Used
 	metaNode.ZoneName = resp.ZoneName
 	metaNode.Threshold = threshold
+	metaNode.Version = resp.Version
+	if resp.LocalTime > 0 {
+		metaNode.ClockSkew = time.Duration(resp.LocalTime - time.Now().UnixNano())
+	}
+	return
+}
+
+// mergePartitionReports merges the partition reports of the heartbeat, either a full report or the
+// changes since the base report, into the ones of the meta node. A full report is asked for by the
+// next heartbeat if the base report is not the last one applied.
+func (metaNode *MetaNode) mergePartitionReports(resp *proto.MetaNodeHeartbeatResponse) (unchanged []*proto.MetaPartitionReport) {
+	if resp.BaseReportSeq == 0 || metaNode.partitionReports == nil {
+		metaNode.partitionReports = make(map[uint64]*proto.MetaPartitionReport, len(resp.MetaPartitionReports))
+	}
+	changed := make(map[uint64]bool, len(resp.MetaPartitionReports))
+	for _, mr := range resp.MetaPartitionReports {
+		if mr == nil {
+			continue
+		}
+		metaNode.partitionReports[mr.PartitionID] = mr
+		changed[mr.PartitionID] = true
+	}
+	for _, id := range resp.RemovedPartitions {
+		delete(metaNode.partitionReports, id)
+	}
+	switch {
+	case resp.BaseReportSeq == 0:
+		metaNode.reportSeq = resp.ReportSeq
+	case resp.BaseReportSeq == metaNode.reportSeq:
+		metaNode.reportSeq = resp.ReportSeq
+		for id, mr := range metaNode.partitionReports {
+			if !changed[id] {
+				unchanged = append(unchanged, mr)
+			}
+		}
+	default:
+		log.LogWarnf("action[mergePartitionReports] metaNode[%v] base report[%v] is not the applied one[%v]",
+			metaNode.Addr, resp.BaseReportSeq, metaNode.reportSeq)
+		metaNode.reportSeq = 0
+	}
+	metaNode.metaPartitionInfos = make([]*proto.MetaPartitionReport, 0, len(metaNode.partitionReports))
+	for _, mr := range metaNode.partitionReports {
+		metaNode.metaPartitionInfos = append(metaNode.metaPartitionInfos, mr)
+	}
+	return
+}
+
+func (metaNode *MetaNode) appliedReportSeq() uint64 {
+	metaNode.RLock()
+	defer metaNode.RUnlock()
+	return metaNode.reportSeq
 }
 
 func (metaNode *MetaNode) reachesThreshold() bool {
@@ -136,10 +195,16 @@ func (metaNode *MetaNode) reachesThreshold() bool {
 	return float32(float64(metaNode.Used)/float64(metaNode.Total)) > metaNode.Threshold
 }
 
-func (metaNode *MetaNode) createHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (metaNode *MetaNode) createHeartbeatTask(masterAddr string, replicaAddrs map[string]string, blobStoreAddrs []string,
+	quotaTokens map[string]*proto.QuotaToken) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
-		CurrTime:   time.Now().Unix(),
-		MasterAddr: masterAddr,
+		CurrTime:       time.Now().Unix(),
+		MasterAddr:     masterAddr,
+		ReplicaAddrs:   replicaAddrs,
+		BlobStoreAddrs: blobStoreAddrs,
+		TaskCodec:      proto.TaskCodecProtobuf,
+		ReportSeq:      metaNode.appliedReportSeq(),
+		VolQuotaTokens: quotaTokens,
 	}
 	task = proto.NewAdminTask(proto.OpMetaNodeHeartbeat, metaNode.Addr, request)
 	return
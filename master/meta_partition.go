@@ -36,6 +36,8 @@ type MetaReplica struct {
 	MaxInodeID  uint64
 	InodeCount  uint64
 	DentryCount uint64
+	Size        uint64 // total size of the files
+	quotaUsages []*proto.QuotaUsage
 	ReportTime  int64
 	Status      int8 // unavailable, readOnly, readWrite
 	IsLeader    bool
@@ -44,24 +46,29 @@ type MetaReplica struct {
 
 // MetaPartition defines the structure of a meta partition
 type MetaPartition struct {
-	PartitionID   uint64
-	Start         uint64
-	End           uint64
-	MaxInodeID    uint64
-	InodeCount    uint64
-	DentryCount   uint64
-	Replicas      []*MetaReplica
-	ReplicaNum    uint8
-	Status        int8
-	IsRecover     bool
-	volID         uint64
-	volName       string
-	Hosts         []string
-	Peers         []proto.Peer
-	OfflinePeerID uint64
-	MissNodes     map[string]int64
-	LoadResponse  []*proto.MetaPartitionLoadResponse
-	offlineMutex  sync.RWMutex
+	PartitionID     uint64
+	Start           uint64
+	End             uint64
+	MaxInodeID      uint64
+	InodeCount      uint64
+	DentryCount     uint64
+	Size            uint64
+	SizeReportTime  int64
+	Replicas        []*MetaReplica
+	ReplicaNum      uint8
+	Status          int8
+	IsRecover       bool
+	volID           uint64
+	volName         string
+	Hosts           []string
+	Peers           []proto.Peer
+	Learners        []proto.Peer // non-voting members, also listed in Hosts and Peers
+	OfflinePeerID   uint64
+	MissNodes       map[string]int64
+	LoadResponse    []*proto.MetaPartitionLoadResponse
+	offlineMutex    sync.RWMutex
+	caseInsensitive bool                // inherited from the volume
+	quotaUsages     []*proto.QuotaUsage // usage of the directory quotas reported by the leader
 	sync.RWMutex
 }
 
@@ -79,6 +86,7 @@ func newMetaPartition(partitionID, start, end uint64, replicaNum uint8, volName
 	mp.Status = proto.Unavailable
 	mp.MissNodes = make(map[string]int64, 0)
 	mp.Peers = make([]proto.Peer, 0)
+	mp.Learners = make([]proto.Peer, 0)
 	mp.Hosts = make([]string, 0)
 	mp.LoadResponse = make([]*proto.MetaPartitionLoadResponse, 0)
 	return
@@ -88,6 +96,19 @@ func (mp *MetaPartition) setPeers(peers []proto.Peer) {
 	mp.Peers = peers
 }
 
+func (mp *MetaPartition) setLearners(learners []proto.Peer) {
+	mp.Learners = learners
+}
+
+func (mp *MetaPartition) isLearner(addr string) bool {
+	for _, learner := range mp.Learners {
+		if learner.Addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
 func (mp *MetaPartition) setHosts(hosts []string) {
 	mp.Hosts = hosts
 }
@@ -353,7 +374,26 @@ func (mp *MetaPartition) updateMetaPartition(mgr *proto.MetaPartitionReport, met
 	mp.setMaxInodeID()
 	mp.setInodeCount()
 	mp.setDentryCount()
+	mp.setSize(mr)
+	mp.removeMissingReplica(metaNode.Addr)
+}
+
+// refreshReplica keeps the replica on the meta node alive by its unchanged report, and returns false
+// if the meta node does not have a replica of the partition yet.
+func (mp *MetaPartition) refreshReplica(mgr *proto.MetaPartitionReport, metaNode *MetaNode) bool {
+	mp.Lock()
+	defer mp.Unlock()
+	mr, err := mp.getMetaReplica(metaNode.Addr)
+	if err != nil {
+		return false
+	}
+	mr.Status = (int8)(mgr.Status)
+	mr.setLastReportTime()
+	if mr.metaNode.RdOnly && mr.Status == proto.ReadWrite {
+		mr.Status = proto.ReadOnly
+	}
 	mp.removeMissingReplica(metaNode.Addr)
+	return true
 }
 
 func (mp *MetaPartition) canBeOffline(nodeAddr string, replicaNum int) (err error) {
@@ -510,11 +550,12 @@ func (mp *MetaPartition) buildNewMetaPartitionTasks(specifyAddrs []string, peers
 	tasks = make([]*proto.AdminTask, 0)
 	hosts := make([]string, 0)
 	req := &proto.CreateMetaPartitionRequest{
-		Start:       mp.Start,
-		End:         mp.End,
-		PartitionID: mp.PartitionID,
-		Members:     peers,
-		VolName:     volName,
+		Start:           mp.Start,
+		End:             mp.End,
+		PartitionID:     mp.PartitionID,
+		Members:         peers,
+		VolName:         volName,
+		CaseInsensitive: mp.caseInsensitive,
 	}
 	if specifyAddrs == nil {
 		hosts = mp.Hosts
@@ -547,13 +588,35 @@ func (mp *MetaPartition) createTaskToTryToChangeLeader(addr string) (task *proto
 	return
 }
 
+// transferLeader asks the leader of the partition to transfer the leadership to the voter on the meta node,
+// which takes over once it catches up with the leader.
+func (mp *MetaPartition) transferLeader(c *Cluster, leaderNode, metaNode *MetaNode) (err error) {
+	task, err := mp.createTaskToTransferLeader(leaderNode.Addr, metaNode.ID)
+	if err != nil {
+		return
+	}
+	if _, err = leaderNode.Sender.syncSendAdminTask(task); err != nil {
+		return
+	}
+	return
+}
+
+func (mp *MetaPartition) createTaskToTransferLeader(leaderAddr string, targetNodeID uint64) (task *proto.AdminTask, err error) {
+	req := &proto.MetaPartitionTransferLeaderRequest{PartitionId: mp.PartitionID, TargetNodeID: targetNodeID}
+	task = proto.NewAdminTask(proto.OpMetaPartitionTransferLeader, leaderAddr, req)
+	resetMetaPartitionTaskID(task, mp.PartitionID)
+	return
+}
+
 func (mp *MetaPartition) createTaskToCreateReplica(host string) (t *proto.AdminTask, err error) {
 	req := &proto.CreateMetaPartitionRequest{
-		Start:       mp.Start,
-		End:         mp.End,
-		PartitionID: mp.PartitionID,
-		Members:     mp.Peers,
-		VolName:     mp.volName,
+		Start:           mp.Start,
+		End:             mp.End,
+		PartitionID:     mp.PartitionID,
+		Members:         mp.Peers,
+		Learners:        mp.Learners,
+		VolName:         mp.volName,
+		CaseInsensitive: mp.caseInsensitive,
 	}
 	t = proto.NewAdminTask(proto.OpCreateMetaPartition, host, req)
 	resetMetaPartitionTaskID(t, mp.PartitionID)
@@ -567,6 +630,20 @@ func (mp *MetaPartition) createTaskToAddRaftMember(addPeer proto.Peer, leaderAdd
 	return
 }
 
+func (mp *MetaPartition) createTaskToAddRaftLearner(addLearner proto.Peer, leaderAddr string) (t *proto.AdminTask, err error) {
+	req := &proto.AddMetaPartitionRaftLearnerRequest{PartitionId: mp.PartitionID, AddLearner: addLearner}
+	t = proto.NewAdminTask(proto.OpAddMetaPartitionRaftLearner, leaderAddr, req)
+	resetMetaPartitionTaskID(t, mp.PartitionID)
+	return
+}
+
+func (mp *MetaPartition) createTaskToPromoteRaftLearner(promoteLearner proto.Peer, leaderAddr string) (t *proto.AdminTask, err error) {
+	req := &proto.PromoteMetaPartitionLearnerRequest{PartitionId: mp.PartitionID, PromoteLearner: promoteLearner}
+	t = proto.NewAdminTask(proto.OpPromoteMetaPartitionLearner, leaderAddr, req)
+	resetMetaPartitionTaskID(t, mp.PartitionID)
+	return
+}
+
 func (mp *MetaPartition) createTaskToRemoveRaftMember(removePeer proto.Peer) (t *proto.AdminTask, err error) {
 	mr, err := mp.getMetaReplicaLeader()
 	if err != nil {
@@ -640,6 +717,8 @@ func (mr *MetaReplica) updateMetric(mgr *proto.MetaPartitionReport) {
 	mr.MaxInodeID = mgr.MaxInodeID
 	mr.InodeCount = mgr.InodeCnt
 	mr.DentryCount = mgr.DentryCnt
+	mr.Size = mgr.Size
+	mr.quotaUsages = mgr.QuotaUsages
 	mr.setLastReportTime()
 
 	if mr.metaNode.RdOnly && mr.Status == proto.ReadWrite {
@@ -742,6 +821,17 @@ func (mp *MetaPartition) setDentryCount() {
 	mp.DentryCount = dentryCount
 }
 
+// setSize takes the size and the usage of the directory quotas reported by the leader, as the
+// followers may lag behind and the size decreases when files are deleted.
+func (mp *MetaPartition) setSize(mr *MetaReplica) {
+	if !mr.IsLeader {
+		return
+	}
+	mp.Size = mr.Size
+	mp.quotaUsages = mr.quotaUsages
+	mp.SizeReportTime = mr.ReportTime
+}
+
 func (mp *MetaPartition) getAllNodeSets() (nodeSets []uint64) {
 	mp.RLock()
 	defer mp.RUnlock()
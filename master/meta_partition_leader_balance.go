@@ -0,0 +1,210 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// metaLeaderBalancer evens out the number of meta partition leaders on the meta nodes,
+// since the leaders concentrate on a few nodes after the others restart.
+type metaLeaderBalancer struct {
+	sync.Mutex
+	status proto.MetaLeaderBalanceStatus
+}
+
+// metaLeaderCandidate is a meta partition whose leader may be transferred to one of the followers.
+type metaLeaderCandidate struct {
+	mp        *MetaPartition
+	leader    string
+	followers []string
+}
+
+func (b *metaLeaderBalancer) begin() bool {
+	b.Lock()
+	defer b.Unlock()
+	if b.status.Running {
+		return false
+	}
+	b.status = proto.MetaLeaderBalanceStatus{Running: true, StartTime: time.Now().Unix()}
+	return true
+}
+
+func (b *metaLeaderBalancer) end(transferred, failed int) {
+	b.Lock()
+	defer b.Unlock()
+	b.status.Running = false
+	b.status.EndTime = time.Now().Unix()
+	b.status.Transferred = transferred
+	b.status.Failed = failed
+}
+
+func (c *Cluster) scheduleToBalanceMetaPartitionLeaders() {
+	if c.cfg.MetaLeaderBalanceInterval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			time.Sleep(time.Second * time.Duration(c.cfg.MetaLeaderBalanceInterval))
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.balanceMetaPartitionLeaders()
+			}
+		}
+	}()
+}
+
+// startToBalanceMetaPartitionLeaders balances the leaders in the background.
+func (c *Cluster) startToBalanceMetaPartitionLeaders() (err error) {
+	c.metaLeaderBalancer.Lock()
+	running := c.metaLeaderBalancer.status.Running
+	c.metaLeaderBalancer.Unlock()
+	if running {
+		return fmt.Errorf("meta partition leaders are being balanced")
+	}
+	go c.balanceMetaPartitionLeaders()
+	return
+}
+
+func (c *Cluster) metaLeaderBalanceStatus() (status *proto.MetaLeaderBalanceStatus) {
+	c.metaLeaderBalancer.Lock()
+	copied := c.metaLeaderBalancer.status
+	c.metaLeaderBalancer.Unlock()
+	copied.NodeLeaders, _ = c.collectMetaPartitionLeaders()
+	return &copied
+}
+
+// balanceMetaPartitionLeaders moves the leaders from the meta nodes holding more than
+// the average to the followers on the meta nodes holding the least.
+func (c *Cluster) balanceMetaPartitionLeaders() {
+	if !c.metaLeaderBalancer.begin() {
+		return
+	}
+	var transferred, failed int
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogWarnf("balanceMetaPartitionLeaders occurred panic,err[%v]", r)
+			WarnBySpecialKey(fmt.Sprintf("%v_%v_scheduling_job_panic", c.Name, ModuleName),
+				"balanceMetaPartitionLeaders occurred panic")
+		}
+		c.metaLeaderBalancer.end(transferred, failed)
+	}()
+
+	leaders, candidates := c.collectMetaPartitionLeaders()
+	if len(leaders) < 2 {
+		return
+	}
+	var total int
+	for _, count := range leaders {
+		total += count
+	}
+	upper := int(math.Ceil(float64(total) / float64(len(leaders))))
+	for _, candidate := range candidates {
+		if transferred+failed >= defaultMaxMetaLeaderTransfersPerRound {
+			break
+		}
+		if leaders[candidate.leader] <= upper {
+			continue
+		}
+		target := ""
+		for _, addr := range candidate.followers {
+			if target == "" || leaders[addr] < leaders[target] {
+				target = addr
+			}
+		}
+		if target == "" || leaders[target] >= upper || leaders[target]+1 >= leaders[candidate.leader] {
+			continue
+		}
+		var metaNode *MetaNode
+		leaderNode, err := c.metaNode(candidate.leader)
+		if err == nil {
+			metaNode, err = c.metaNode(target)
+		}
+		if err == nil {
+			err = candidate.mp.transferLeader(c, leaderNode, metaNode)
+		}
+		if err != nil {
+			failed++
+			log.LogWarnf("action[balanceMetaPartitionLeaders] partitionID[%v] from[%v] to[%v] err[%v]",
+				candidate.mp.PartitionID, candidate.leader, target, err)
+			continue
+		}
+		transferred++
+		leaders[candidate.leader]--
+		leaders[target]++
+		log.LogInfof("action[balanceMetaPartitionLeaders] partitionID[%v] from[%v] to[%v]",
+			candidate.mp.PartitionID, candidate.leader, target)
+	}
+	log.LogInfof("action[balanceMetaPartitionLeaders] transferred[%v] failed[%v] leaders[%v]", transferred, failed, leaders)
+}
+
+// collectMetaPartitionLeaders counts the leaders on the active meta nodes, and returns the
+// partitions whose leader may be transferred, i.e. having all the voters active.
+func (c *Cluster) collectMetaPartitionLeaders() (leaders map[string]int, candidates []*metaLeaderCandidate) {
+	leaders = make(map[string]int)
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		if metaNode := node.(*MetaNode); metaNode.IsActive {
+			leaders[metaNode.Addr] = 0
+		}
+		return true
+	})
+	for _, vol := range c.allVols() {
+		vol.mpsLock.RLock()
+		mps := make([]*MetaPartition, 0, len(vol.MetaPartitions))
+		for _, mp := range vol.MetaPartitions {
+			mps = append(mps, mp)
+		}
+		vol.mpsLock.RUnlock()
+		for _, mp := range mps {
+			if candidate := mp.leaderCandidate(leaders); candidate != nil {
+				leaders[candidate.leader]++
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+	return
+}
+
+func (mp *MetaPartition) leaderCandidate(nodes map[string]int) (candidate *metaLeaderCandidate) {
+	mp.RLock()
+	defer mp.RUnlock()
+	leader, err := mp.getMetaReplicaLeader()
+	if err != nil {
+		return
+	}
+	if _, ok := nodes[leader.Addr]; !ok {
+		return
+	}
+	candidate = &metaLeaderCandidate{mp: mp, leader: leader.Addr}
+	for _, mr := range mp.Replicas {
+		if mr.Addr == leader.Addr || mp.isLearner(mr.Addr) {
+			continue
+		}
+		if !mr.isActive() {
+			// transferring the leader may leave the partition without quorum
+			candidate.followers = nil
+			break
+		}
+		if _, ok := nodes[mr.Addr]; ok {
+			candidate.followers = append(candidate.followers, mr.Addr)
+		}
+	}
+	return
+}
@@ -40,6 +40,10 @@ type clusterValue struct {
 	MetaNodeDeleteWorkerSleepMs uint64
 	DataNodeAutoRepairLimitRate uint64
 	FaultDomain                 bool
+
+	DataNodeDiskClientIOLimitRate uint64
+	DataNodeDiskRepairIOLimitRate uint64
+	DataNodeRepairWindows         []*bsProto.RepairLimitWindow `json:",omitempty"`
 }
 
 func newClusterValue(c *Cluster) (cv *clusterValue) {
@@ -52,6 +56,10 @@ func newClusterValue(c *Cluster) (cv *clusterValue) {
 		DataNodeAutoRepairLimitRate: c.cfg.DataNodeAutoRepairLimitRate,
 		DisableAutoAllocate:         c.DisableAutoAllocate,
 		FaultDomain:                 c.FaultDomain,
+
+		DataNodeDiskClientIOLimitRate: c.cfg.DataNodeDiskClientIOLimitRate,
+		DataNodeDiskRepairIOLimitRate: c.cfg.DataNodeDiskRepairIOLimitRate,
+		DataNodeRepairWindows:         c.cfg.repairWindows(),
 	}
 	return cv
 }
@@ -67,6 +75,7 @@ type metaPartitionValue struct {
 	Hosts         string
 	OfflinePeerID uint64
 	Peers         []bsProto.Peer
+	Learners      []bsProto.Peer
 	IsRecover     bool
 }
 
@@ -81,6 +90,7 @@ func newMetaPartitionValue(mp *MetaPartition) (mpv *metaPartitionValue) {
 		VolName:       mp.volName,
 		Hosts:         mp.hostsToString(),
 		Peers:         mp.Peers,
+		Learners:      mp.Learners,
 		OfflinePeerID: mp.OfflinePeerID,
 		IsRecover:     mp.IsRecover,
 	}
@@ -98,6 +108,7 @@ type dataPartitionValue struct {
 	OfflinePeerID uint64
 	Replicas      []*replicaValue
 	IsRecover     bool
+	ECStatus      uint8
 }
 
 type replicaValue struct {
@@ -117,6 +128,7 @@ func newDataPartitionValue(dp *DataPartition) (dpv *dataPartitionValue) {
 		OfflinePeerID: dp.OfflinePeerID,
 		Replicas:      make([]*replicaValue, 0),
 		IsRecover:     dp.isRecover,
+		ECStatus:      dp.ECStatus,
 	}
 	for _, replica := range dp.Replicas {
 		rv := &replicaValue{Addr: replica.Addr, DiskPath: replica.DiskPath}
@@ -146,6 +158,18 @@ type volValue struct {
 	DpSelectorName    string
 	DpSelectorParm    string
 	DefaultPriority   bool
+	CaseInsensitive   bool
+	Compression       string
+	WriteLimit        uint64
+	ObjQuotaBytes     uint64
+	ObjQuotaCount     uint64
+	SLO               *bsProto.VolSLO `json:",omitempty"`
+	FlashCache        bool
+	MaxInodes         uint64
+	ChecksumType      uint8
+	Encrypted         bool
+	EncryptKeyID      uint32
+	WrappedEncryptKey []byte
 }
 
 func (v *volValue) Bytes() (raw []byte, err error) {
@@ -175,6 +199,18 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		DpSelectorName:    vol.dpSelectorName,
 		DpSelectorParm:    vol.dpSelectorParm,
 		DefaultPriority:   vol.defaultPriority,
+		CaseInsensitive:   vol.caseInsensitive,
+		Compression:       vol.compression,
+		WriteLimit:        vol.writeLimit,
+		ObjQuotaBytes:     vol.objQuotaBytes,
+		ObjQuotaCount:     vol.objQuotaCount,
+		SLO:               vol.slo,
+		FlashCache:        vol.flashCache,
+		MaxInodes:         vol.maxInodes,
+		ChecksumType:      vol.checksumType,
+		Encrypted:         vol.encrypted,
+		EncryptKeyID:      vol.encryptKeyID,
+		WrappedEncryptKey: vol.wrappedEncryptKey,
 	}
 	return
 }
@@ -193,15 +229,21 @@ type dataNodeValue struct {
 	Addr      string
 	ZoneName  string
 	RdOnly    bool
+	// ReplicaAddr is the address for the replication traffic, empty if it shares Addr
+	ReplicaAddr string `json:",omitempty"`
+	// RepairLimit overrides the repair limits of the cluster, nil if not set
+	RepairLimit *bsProto.DataNodeRepairLimit `json:",omitempty"`
 }
 
 func newDataNodeValue(dataNode *DataNode) *dataNodeValue {
 	return &dataNodeValue{
-		ID:        dataNode.ID,
-		NodeSetID: dataNode.NodeSetID,
-		Addr:      dataNode.Addr,
-		ZoneName:  dataNode.ZoneName,
-		RdOnly:    dataNode.RdOnly,
+		ID:          dataNode.ID,
+		NodeSetID:   dataNode.NodeSetID,
+		Addr:        dataNode.Addr,
+		ZoneName:    dataNode.ZoneName,
+		RdOnly:      dataNode.RdOnly,
+		ReplicaAddr: dataNode.ReplicaAddr,
+		RepairLimit: dataNode.RepairLimit,
 	}
 }
 
@@ -211,15 +253,18 @@ type metaNodeValue struct {
 	Addr      string
 	ZoneName  string
 	RdOnly    bool
+	// ReplicaAddr is the address for the replication traffic, empty if it shares Addr
+	ReplicaAddr string `json:",omitempty"`
 }
 
 func newMetaNodeValue(metaNode *MetaNode) *metaNodeValue {
 	return &metaNodeValue{
-		ID:        metaNode.ID,
-		NodeSetID: metaNode.NodeSetID,
-		Addr:      metaNode.Addr,
-		ZoneName:  metaNode.ZoneName,
-		RdOnly:    metaNode.RdOnly,
+		ID:          metaNode.ID,
+		NodeSetID:   metaNode.NodeSetID,
+		Addr:        metaNode.Addr,
+		ZoneName:    metaNode.ZoneName,
+		RdOnly:      metaNode.RdOnly,
+		ReplicaAddr: metaNode.ReplicaAddr,
 	}
 }
 
@@ -315,6 +360,16 @@ func (m *RaftCmd) setOpType() {
 		m.Op = opSyncAddAKUser
 	case volUserAcronym:
 		m.Op = opSyncAddVolUser
+	case serviceKeyAcronym:
+		m.Op = opSyncPutServiceKey
+	case rateLimitAcronym:
+		m.Op = opSyncPutRateLimit
+	case flashNodeAcronym:
+		m.Op = opSyncAddFlashNode
+	case quotaAcronym:
+		m.Op = opSyncPutQuota
+	case volSnapshotAcronym:
+		m.Op = opSyncPutVolSnapshot
 	default:
 		log.LogWarnf("action[setOpType] unknown opCode[%v]", keyArr[1])
 	}
@@ -560,6 +615,11 @@ func (c *Cluster) updateDataNodeDeleteLimitRate(val uint64) {
 	atomic.StoreUint64(&c.cfg.DataNodeDeleteLimitRate, val)
 }
 
+func (c *Cluster) updateDataNodeDiskIOLimitRate(clientRate, repairRate uint64) {
+	atomic.StoreUint64(&c.cfg.DataNodeDiskClientIOLimitRate, clientRate)
+	atomic.StoreUint64(&c.cfg.DataNodeDiskRepairIOLimitRate, repairRate)
+}
+
 func (c *Cluster) loadClusterValue() (err error) {
 	result, err := c.fsm.store.SeekForPrefix([]byte(clusterPrefix))
 	if err != nil {
@@ -578,6 +638,8 @@ func (c *Cluster) loadClusterValue() (err error) {
 		c.updateMetaNodeDeleteWorkerSleepMs(cv.MetaNodeDeleteWorkerSleepMs)
 		c.updateDataNodeDeleteLimitRate(cv.DataNodeDeleteLimitRate)
 		c.updateDataNodeAutoRepairLimit(cv.DataNodeAutoRepairLimitRate)
+		c.updateDataNodeDiskIOLimitRate(cv.DataNodeDiskClientIOLimitRate, cv.DataNodeDiskRepairIOLimitRate)
+		c.cfg.setRepairWindows(cv.DataNodeRepairWindows)
 		log.LogInfof("action[loadClusterValue], metaNodeThreshold[%v]", cv.Threshold)
 	}
 	return
@@ -745,6 +807,8 @@ func (c *Cluster) loadDataNodes() (err error) {
 		dataNode.ID = dnv.ID
 		dataNode.NodeSetID = dnv.NodeSetID
 		dataNode.RdOnly = dnv.RdOnly
+		dataNode.ReplicaAddr = dnv.ReplicaAddr
+		dataNode.RepairLimit = dnv.RepairLimit
 		olddn, ok := c.dataNodes.Load(dataNode.Addr)
 		if ok {
 			if olddn.(*DataNode).ID <= dataNode.ID {
@@ -776,6 +840,7 @@ func (c *Cluster) loadMetaNodes() (err error) {
 		metaNode.ID = mnv.ID
 		metaNode.NodeSetID = mnv.NodeSetID
 		metaNode.RdOnly = mnv.RdOnly
+		metaNode.ReplicaAddr = mnv.ReplicaAddr
 
 		oldmn, ok := c.metaNodes.Load(metaNode.Addr)
 		if ok {
@@ -838,8 +903,12 @@ func (c *Cluster) loadMetaPartitions() (err error) {
 			}
 		}
 		mp := newMetaPartition(mpv.PartitionID, mpv.Start, mpv.End, vol.mpReplicaNum, vol.Name, mpv.VolID)
+		mp.caseInsensitive = vol.caseInsensitive
 		mp.setHosts(strings.Split(mpv.Hosts, underlineSeparator))
 		mp.setPeers(mpv.Peers)
+		if mpv.Learners != nil {
+			mp.setLearners(mpv.Learners)
+		}
 		mp.OfflinePeerID = mpv.OfflinePeerID
 		mp.IsRecover = mpv.IsRecover
 		vol.addMetaPartition(mp)
@@ -890,6 +959,7 @@ func (c *Cluster) loadDataPartitions() (err error) {
 		dp.Peers = dpv.Peers
 		dp.OfflinePeerID = dpv.OfflinePeerID
 		dp.isRecover = dpv.IsRecover
+		dp.ECStatus = dpv.ECStatus
 		for _, rv := range dpv.Replicas {
 			if !contains(dp.Hosts, rv.Addr) {
 				continue
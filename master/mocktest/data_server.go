@@ -16,6 +16,7 @@ package mocktest
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -68,7 +69,7 @@ func (mds *MockDataServer) register() {
 	var nodeID uint64
 	var retry int
 	for retry < 3 {
-		nodeID, err = mds.mc.NodeAPI().AddDataNode(mds.TcpAddr, mds.zoneName)
+		nodeID, err = mds.mc.NodeAPI().AddDataNode(mds.TcpAddr, mds.zoneName, "")
 		if err == nil {
 			break
 		}
@@ -108,6 +109,12 @@ func (mds *MockDataServer) serveConn(rc net.Conn) {
 	if err != nil {
 		return
 	}
+	// not an admin task
+	if req.Opcode == proto.OpGetAppliedId {
+		err = mds.handleGetAppliedID(conn, req)
+		fmt.Printf("data node [%v] get applied id of partition[%v],err:%v\n", mds.TcpAddr, req.PartitionID, err)
+		return
+	}
 	adminTask := &proto.AdminTask{}
 	decode := json.NewDecoder(bytes.NewBuffer(req.Data))
 	decode.UseNumber()
@@ -140,6 +147,9 @@ func (mds *MockDataServer) serveConn(rc net.Conn) {
 	case proto.OpDataPartitionTryToLeader:
 		err = mds.handleTryToLeader(conn, req, adminTask)
 		fmt.Printf("data node [%v] try to leader,id[%v],err:%v\n", mds.TcpAddr, adminTask.ID, err)
+	case proto.OpDataNodeFenceWrites:
+		err = responseAckOKToMaster(conn, req, nil)
+		fmt.Printf("data node [%v] %v,err:%v\n", mds.TcpAddr, req.GetOpMsg(), err)
 	default:
 		fmt.Printf("unknown code [%v]\n", req.Opcode)
 	}
@@ -328,3 +338,9 @@ func buildSnapshot() (files []*proto.File) {
 	files = append(files, f3)
 	return
 }
+
+func (mds *MockDataServer) handleGetAppliedID(conn net.Conn, p *proto.Packet) (err error) {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, p.PartitionID)
+	return responseAckOKToMaster(conn, p, buf)
+}
@@ -34,14 +34,16 @@ type MockMetaServer struct {
 	ZoneName   string
 	mc         *master.MasterClient
 	partitions map[uint64]*MockMetaPartition // Key: metaRangeId, Val: metaPartition
+	snapshots  map[uint64]map[uint64]bool    // the snapshots taken by the partitions, key: partitionID
 	sync.RWMutex
 }
 
 func NewMockMetaServer(addr string, zoneName string) *MockMetaServer {
 	mms := &MockMetaServer{
 		TcpAddr: addr, partitions: make(map[uint64]*MockMetaPartition, 0),
-		ZoneName: zoneName,
-		mc:       master.NewMasterClient([]string{hostAddr}, false),
+		snapshots: make(map[uint64]map[uint64]bool),
+		ZoneName:  zoneName,
+		mc:        master.NewMasterClient([]string{hostAddr}, false),
 	}
 	return mms
 }
@@ -56,7 +58,7 @@ func (mms *MockMetaServer) register() {
 	var nodeID uint64
 	var retry int
 	for retry < 3 {
-		nodeID, err = mms.mc.NodeAPI().AddMetaNode(mms.TcpAddr, mms.ZoneName)
+		nodeID, err = mms.mc.NodeAPI().AddMetaNode(mms.TcpAddr, mms.ZoneName, "")
 		if err == nil {
 			break
 		}
@@ -135,11 +137,98 @@ func (mms *MockMetaServer) serveConn(rc net.Conn) {
 	case proto.OpMetaPartitionTryToLeader:
 		err = mms.handleTryToLeader(conn, req, adminTask)
 		fmt.Printf("meta node [%v] try to leader,id[%v],err:%v\n", mms.TcpAddr, adminTask.ID, err)
+	case proto.OpAddMetaPartitionRaftLearner:
+		err = mms.handleAddMetaPartitionRaftLearner(conn, req, adminTask)
+		fmt.Printf("meta node [%v] add meta partition raft learner,id[%v],err:%v\n", mms.TcpAddr, adminTask.ID, err)
+	case proto.OpPromoteMetaPartitionLearner:
+		err = mms.handlePromoteMetaPartitionLearner(conn, req, adminTask)
+		fmt.Printf("meta node [%v] promote meta partition raft learner,id[%v],err:%v\n", mms.TcpAddr, adminTask.ID, err)
+	case proto.OpMetaPartitionTransferLeader:
+		err = mms.handleTransferLeader(conn, req, adminTask)
+		fmt.Printf("meta node [%v] transfer meta partition leader,id[%v],err:%v\n", mms.TcpAddr, adminTask.ID, err)
+	case proto.OpMetaFenceWrites:
+		err = responseAckOKToMaster(conn, req, nil)
+		fmt.Printf("meta node [%v] %v,err:%v\n", mms.TcpAddr, req.GetOpMsg(), err)
+	case proto.OpMetaDeleteSnapshot:
+		err = mms.handleDeleteSnapshot(conn, req)
+		fmt.Printf("meta node [%v] delete snapshot,err:%v\n", mms.TcpAddr, err)
+	case proto.OpMetaRestoreSnapshot:
+		err = mms.handleRestoreSnapshot(conn, req)
+		fmt.Printf("meta node [%v] restore snapshot,err:%v\n", mms.TcpAddr, err)
+	case proto.OpMetaCreateSnapshot:
+		err = mms.handleCreateSnapshot(conn, req)
+		fmt.Printf("meta node [%v] create snapshot,err:%v\n", mms.TcpAddr, err)
+	case proto.OpMetaLookup:
+		err = mms.handleLookup(conn, req)
+		fmt.Printf("meta node [%v] lookup,err:%v\n", mms.TcpAddr, err)
+	case proto.OpMetaReadDir:
+		err = mms.handleReadDir(conn, req)
+		fmt.Printf("meta node [%v] read dir,err:%v\n", mms.TcpAddr, err)
+	case proto.OpMetaSetInodeQuota:
+		err = mms.handleSetInodeQuota(conn, req)
+		fmt.Printf("meta node [%v] set inode quota,err:%v\n", mms.TcpAddr, err)
 	default:
 		fmt.Printf("unknown code [%v]\n", req.Opcode)
 	}
 }
 
+// handleCreateSnapshot replies the snapshot requested by the master as a client, not in an admin task.
+func (mms *MockMetaServer) handleCreateSnapshot(conn net.Conn, p *proto.Packet) (err error) {
+	req := &proto.CreateSnapshotRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		responseAckErrToMaster(conn, p, err)
+		return
+	}
+	info := &proto.SnapshotInfo{ID: req.SnapshotID, Name: req.Name, RootIno: req.RootIno, ApplyID: req.PartitionID}
+	data, err := json.Marshal(info)
+	if err != nil {
+		responseAckErrToMaster(conn, p, err)
+		return
+	}
+	mms.Lock()
+	if mms.snapshots[req.PartitionID] == nil {
+		mms.snapshots[req.PartitionID] = make(map[uint64]bool)
+	}
+	mms.snapshots[req.PartitionID][req.SnapshotID] = true
+	mms.Unlock()
+	return responseAckOKToMaster(conn, p, data)
+}
+
+func (mms *MockMetaServer) handleDeleteSnapshot(conn net.Conn, p *proto.Packet) (err error) {
+	req := &proto.DeleteSnapshotRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		responseAckErrToMaster(conn, p, err)
+		return
+	}
+	mms.Lock()
+	taken := mms.snapshots[req.PartitionID][req.SnapshotID]
+	delete(mms.snapshots[req.PartitionID], req.SnapshotID)
+	mms.Unlock()
+	if !taken {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte("snapshot not found"))
+		return p.WriteToConn(conn)
+	}
+	return responseAckOKToMaster(conn, p, nil)
+}
+
+// handleRestoreSnapshot refuses to restore the snapshot the partition has not taken, and to clear the
+// partition which has taken it, as the meta node does.
+func (mms *MockMetaServer) handleRestoreSnapshot(conn net.Conn, p *proto.Packet) (err error) {
+	req := &proto.RestoreSnapshotRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		responseAckErrToMaster(conn, p, err)
+		return
+	}
+	mms.RLock()
+	taken := mms.snapshots[req.PartitionID][req.SnapshotID]
+	mms.RUnlock()
+	if taken == req.Clear {
+		return responseAckErrToMaster(conn, p, fmt.Errorf("mp[%v] snapshot[%v] taken(%v) clear(%v)",
+			req.PartitionID, req.SnapshotID, taken, req.Clear))
+	}
+	return responseAckOKToMaster(conn, p, nil)
+}
+
 func (mms *MockMetaServer) handleAddMetaPartitionRaftMember(conn net.Conn, p *proto.Packet, adminTask *proto.AdminTask) (err error) {
 	responseAckOKToMaster(conn, p, nil)
 	return
@@ -150,11 +239,26 @@ func (mms *MockMetaServer) handleRemoveMetaPartitionRaftMember(conn net.Conn, p
 	return
 }
 
+func (mms *MockMetaServer) handleAddMetaPartitionRaftLearner(conn net.Conn, p *proto.Packet, adminTask *proto.AdminTask) (err error) {
+	responseAckOKToMaster(conn, p, nil)
+	return
+}
+
+func (mms *MockMetaServer) handlePromoteMetaPartitionLearner(conn net.Conn, p *proto.Packet, adminTask *proto.AdminTask) (err error) {
+	responseAckOKToMaster(conn, p, nil)
+	return
+}
+
 func (mms *MockMetaServer) handleTryToLeader(conn net.Conn, p *proto.Packet, adminTask *proto.AdminTask) (err error) {
 	responseAckOKToMaster(conn, p, nil)
 	return
 }
 
+func (mms *MockMetaServer) handleTransferLeader(conn net.Conn, p *proto.Packet, adminTask *proto.AdminTask) (err error) {
+	responseAckOKToMaster(conn, p, nil)
+	return
+}
+
 func (mms *MockMetaServer) handleCreateMetaPartition(conn net.Conn, p *proto.Packet, adminTask *proto.AdminTask) (err error) {
 	defer func() {
 		if err != nil {
@@ -223,6 +327,7 @@ func (mms *MockMetaServer) handleHeartbeats(conn net.Conn, p *proto.Packet, admi
 		}
 		mpr.Status = proto.ReadWrite
 		mpr.IsLeader = true
+		mpr.QuotaUsages = quotaUsages(partition.VolName, partition.Start, partition.End)
 		resp.MetaPartitionReports = append(resp.MetaPartitionReports, mpr)
 	}
 	mms.RUnlock()
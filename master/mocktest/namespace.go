@@ -0,0 +1,131 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mocktest
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// The namespace of every volume served by the mock meta servers is the tree /quota/file, whose inodes are
+// tagged with the directory quotas shared by all the servers.
+const (
+	QuotaDirIno  = 2
+	QuotaFileIno = 3
+	// the bytes of each inode counted by the directory quotas
+	MockInodeSize = 100
+)
+
+var mockDentries = map[uint64][]proto.Dentry{
+	proto.RootIno: {{Name: "quota", Inode: QuotaDirIno, Type: proto.Mode(os.ModeDir)}},
+	QuotaDirIno:   {{Name: "file", Inode: QuotaFileIno, Type: proto.Mode(0644)}},
+}
+
+var (
+	inodeQuotasMu sync.RWMutex
+	inodeQuotas   = make(map[string]map[uint64]map[uint64]bool) // vol -> inode -> quota IDs
+)
+
+// InodeQuotas returns the IDs of the directory quotas tagging the inode of the volume.
+func InodeQuotas(volName string, ino uint64) (quotaIDs []uint64) {
+	inodeQuotasMu.RLock()
+	defer inodeQuotasMu.RUnlock()
+	for id := range inodeQuotas[volName][ino] {
+		quotaIDs = append(quotaIDs, id)
+	}
+	sort.Slice(quotaIDs, func(i, j int) bool { return quotaIDs[i] < quotaIDs[j] })
+	return
+}
+
+// quotaUsages returns the usages of the directory quotas by the inodes of the volume in the range.
+func quotaUsages(volName string, start, end uint64) (usages []*proto.QuotaUsage) {
+	inodeQuotasMu.RLock()
+	defer inodeQuotasMu.RUnlock()
+	sums := make(map[uint64]*proto.QuotaUsage)
+	for ino, ids := range inodeQuotas[volName] {
+		if ino < start || ino > end {
+			continue
+		}
+		for id := range ids {
+			if sums[id] == nil {
+				sums[id] = &proto.QuotaUsage{QuotaID: id}
+			}
+			sums[id].UsedBytes += MockInodeSize
+			sums[id].UsedInodes++
+		}
+	}
+	for _, usage := range sums {
+		usages = append(usages, usage)
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].QuotaID < usages[j].QuotaID })
+	return
+}
+
+func (mms *MockMetaServer) handleLookup(conn net.Conn, p *proto.Packet) (err error) {
+	req := &proto.LookupRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		responseAckErrToMaster(conn, p, err)
+		return
+	}
+	for _, dentry := range mockDentries[req.ParentID] {
+		if dentry.Name == req.Name {
+			data, _ := json.Marshal(&proto.LookupResponse{Inode: dentry.Inode, Mode: dentry.Type})
+			return responseAckOKToMaster(conn, p, data)
+		}
+	}
+	p.PacketErrorWithBody(proto.OpNotExistErr, []byte("dentry not found"))
+	return p.WriteToConn(conn)
+}
+
+func (mms *MockMetaServer) handleReadDir(conn net.Conn, p *proto.Packet) (err error) {
+	req := &proto.ReadDirRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		responseAckErrToMaster(conn, p, err)
+		return
+	}
+	data, _ := json.Marshal(&proto.ReadDirResponse{Children: mockDentries[req.ParentID]})
+	return responseAckOKToMaster(conn, p, data)
+}
+
+func (mms *MockMetaServer) handleSetInodeQuota(conn net.Conn, p *proto.Packet) (err error) {
+	req := &proto.SetInodeQuotaRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		responseAckErrToMaster(conn, p, err)
+		return
+	}
+	inodeQuotasMu.Lock()
+	if inodeQuotas[req.VolName] == nil {
+		inodeQuotas[req.VolName] = make(map[uint64]map[uint64]bool)
+	}
+	for _, ino := range req.Inodes {
+		ids := inodeQuotas[req.VolName][ino]
+		if ids == nil {
+			ids = make(map[uint64]bool)
+			inodeQuotas[req.VolName][ino] = ids
+		}
+		if req.Remove {
+			delete(ids, req.QuotaID)
+		} else {
+			ids[req.QuotaID] = true
+		}
+	}
+	inodeQuotasMu.Unlock()
+	return responseAckOKToMaster(conn, p, nil)
+}
@@ -42,6 +42,8 @@ const (
 	MetricDiskError            = "disk_error"
 	MetricDataNodesInactive    = "dataNodes_inactive"
 	MetricMetaNodesInactive    = "metaNodes_inactive"
+	MetricVolSLOBurnRate       = "vol_slo_burn_rate"
+	MetricVolSLOAtRisk         = "vol_slo_at_risk"
 )
 
 type monitorMetrics struct {
@@ -62,16 +64,20 @@ type monitorMetrics struct {
 	diskError          *exporter.GaugeVec
 	dataNodesInactive  *exporter.Gauge
 	metaNodesInactive  *exporter.Gauge
+	volSLOBurnRate     *exporter.GaugeVec
+	volSLOAtRisk       *exporter.GaugeVec
 
-	volNames map[string]struct{}
-	badDisks map[string]string
+	volNames    map[string]struct{}
+	badDisks    map[string]string
+	sloVolNames map[string]struct{}
 	//volNamesMutex sync.Mutex
 }
 
 func newMonitorMetrics(c *Cluster) *monitorMetrics {
 	return &monitorMetrics{cluster: c,
-		volNames: make(map[string]struct{}),
-		badDisks: make(map[string]string),
+		volNames:    make(map[string]struct{}),
+		badDisks:    make(map[string]string),
+		sloVolNames: make(map[string]struct{}),
 	}
 }
 
@@ -92,6 +98,8 @@ func (mm *monitorMetrics) start() {
 	mm.diskError = exporter.NewGaugeVec(MetricDiskError, "", []string{"addr", "path"})
 	mm.dataNodesInactive = exporter.NewGauge(MetricDataNodesInactive)
 	mm.metaNodesInactive = exporter.NewGauge(MetricMetaNodesInactive)
+	mm.volSLOBurnRate = exporter.NewGaugeVec(MetricVolSLOBurnRate, "", []string{"volName", "objective", "window"})
+	mm.volSLOAtRisk = exporter.NewGaugeVec(MetricVolSLOAtRisk, "", []string{"volName"})
 	go mm.statMetrics()
 }
 
@@ -134,6 +142,7 @@ func (mm *monitorMetrics) doStat() {
 	mm.setDiskErrorMetric()
 	mm.setInactiveDataNodesCount()
 	mm.setInactiveMetaNodesCount()
+	mm.setSLOMetrics()
 }
 
 func (mm *monitorMetrics) setVolMetrics() {
@@ -200,6 +209,46 @@ func (mm *monitorMetrics) deleteVolMetric(volName string) {
 	mm.volMetaCount.DeleteLabelValues(volName, "dp")
 }
 
+// setSLOMetrics sets the burn rates of the objectives of the volumes, and warns if the objectives of a
+// volume become at risk or recover.
+func (mm *monitorMetrics) setSLOMetrics() {
+	deleteVolNames := mm.sloVolNames
+	mm.sloVolNames = make(map[string]struct{})
+	statuses, _ := mm.cluster.getSLOStatuses("", time.Now())
+	for _, status := range statuses {
+		mm.sloVolNames[status.VolName] = struct{}{}
+		delete(deleteVolNames, status.VolName)
+		mm.volSLOBurnRate.SetWithLabelValues(status.ShortErrorBurnRate, status.VolName, "error", "short")
+		mm.volSLOBurnRate.SetWithLabelValues(status.LongErrorBurnRate, status.VolName, "error", "long")
+		mm.volSLOBurnRate.SetWithLabelValues(status.ShortLatencyBurnRate, status.VolName, "latency", "short")
+		mm.volSLOBurnRate.SetWithLabelValues(status.LongLatencyBurnRate, status.VolName, "latency", "long")
+		var atRisk float64
+		if status.AtRisk {
+			atRisk = 1
+		}
+		mm.volSLOAtRisk.SetWithLabelValues(atRisk, status.VolName)
+		if !mm.cluster.slos.setAtRisk(status.VolName, status.AtRisk) {
+			continue
+		}
+		if status.AtRisk {
+			Warn(mm.cluster.Name, fmt.Sprintf("vol[%v] objectives are at risk: %v", status.VolName, status.Reason))
+		} else {
+			log.LogWarnf("action[setSLOMetrics] vol[%v] objectives recovered", status.VolName)
+		}
+	}
+	for volName := range deleteVolNames {
+		mm.deleteSLOMetric(volName)
+	}
+}
+
+func (mm *monitorMetrics) deleteSLOMetric(volName string) {
+	for _, objective := range []string{"error", "latency"} {
+		mm.volSLOBurnRate.DeleteLabelValues(volName, objective, "short")
+		mm.volSLOBurnRate.DeleteLabelValues(volName, objective, "long")
+	}
+	mm.volSLOAtRisk.DeleteLabelValues(volName)
+}
+
 func (mm *monitorMetrics) setDiskErrorMetric() {
 	deleteBadDisks := make(map[string]string)
 	for k, v := range mm.badDisks {
@@ -278,6 +327,10 @@ func (mm *monitorMetrics) clearDiskErrMetrics() {
 func (mm *monitorMetrics) resetAllMetrics() {
 	mm.clearVolMetrics()
 	mm.clearDiskErrMetrics()
+	for volName := range mm.sloVolNames {
+		mm.deleteSLOMetric(volName)
+	}
+	mm.sloVolNames = make(map[string]struct{})
 
 	mm.dataNodesCount.Set(0)
 	mm.metaNodesCount.Set(0)
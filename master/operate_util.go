@@ -28,7 +28,7 @@ import (
 	"time"
 )
 
-func newCreateDataPartitionRequest(volName string, ID uint64, members []proto.Peer, dataPartitionSize int, hosts []string, createType int) (req *proto.CreateDataPartitionRequest) {
+func newCreateDataPartitionRequest(volName string, ID uint64, members []proto.Peer, dataPartitionSize int, hosts []string, createType int, encrypted bool, checksumType uint8) (req *proto.CreateDataPartitionRequest) {
 	req = &proto.CreateDataPartitionRequest{
 		PartitionId:   ID,
 		PartitionSize: dataPartitionSize,
@@ -36,6 +36,8 @@ func newCreateDataPartitionRequest(volName string, ID uint64, members []proto.Pe
 		Members:       members,
 		Hosts:         hosts,
 		CreateType:    createType,
+		Encrypted:     encrypted,
+		ChecksumType:  checksumType,
 	}
 	return
 }
@@ -71,6 +73,11 @@ func newLoadDataPartitionMetricRequest(ID uint64) (req *proto.LoadDataPartitionR
 }
 
 func unmarshalTaskResponse(task *proto.AdminTask) (err error) {
+	switch task.Response.(type) {
+	case *proto.DataNodeHeartbeatResponse, *proto.MetaNodeHeartbeatResponse:
+		// decoded from the protobuf already
+		return
+	}
 	bytes, err := json.Marshal(task.Response)
 	if err != nil {
 		return
@@ -83,6 +90,14 @@ func unmarshalTaskResponse(task *proto.AdminTask) (err error) {
 		response = &proto.DeleteDataPartitionResponse{}
 	case proto.OpLoadDataPartition:
 		response = &proto.LoadDataPartitionResponse{}
+	case proto.OpCheckDataPartition:
+		response = &proto.CheckDataPartitionResponse{}
+	case proto.OpBackupDataPartition:
+		response = &proto.BackupDataPartitionResponse{}
+	case proto.OpRestoreDataPartition:
+		response = &proto.RestoreDataPartitionResponse{}
+	case proto.OpConvertDataPartitionToEC:
+		response = &proto.ConvertDataPartitionToECResponse{}
 	case proto.OpDeleteFile:
 		response = &proto.DeleteFileResponse{}
 	case proto.OpMetaNodeHeartbeat:
@@ -93,6 +108,8 @@ func unmarshalTaskResponse(task *proto.AdminTask) (err error) {
 		response = &proto.UpdateMetaPartitionResponse{}
 	case proto.OpDecommissionMetaPartition:
 		response = &proto.MetaPartitionDecommissionResponse{}
+	case proto.OpMetaMigrateToCold:
+		response = &proto.MigrateToColdResponse{}
 	default:
 		log.LogError(fmt.Sprintf("unknown operate code(%v)", task.OpCode))
 	}
@@ -0,0 +1,384 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// the seconds a quota token is enforced by the meta nodes after the last heartbeat carrying it
+const quotaTokenTTL = 10 * defaultIntervalToCheckHeartbeat
+
+// quotaManager decides whether the volumes, the owners and the directories exceed their quotas. The quotas
+// of the volumes are kept by the volumes, whose bytes are their capacities, and the quotas of the owners and
+// the directories are persisted by raft. The quotas are checked in the leader periodically: the data
+// partitions of the volumes exceeding the bytes are set read-only, and the meta nodes are pushed the tokens
+// of the volumes by the heartbeats. The usage of the directories is counted by the meta nodes, see quota_dir.go.
+type quotaManager struct {
+	sync.RWMutex
+	owners map[string]*proto.QuotaInfo  // limits of the owners
+	dirs   map[string]*proto.QuotaInfo  // vol#path -> limits of the directories
+	tokens map[string]*proto.QuotaToken // vol -> token, replaced as a whole by the check
+}
+
+func newQuotaManager() *quotaManager {
+	return &quotaManager{
+		owners: make(map[string]*proto.QuotaInfo),
+		dirs:   make(map[string]*proto.QuotaInfo),
+		tokens: make(map[string]*proto.QuotaToken),
+	}
+}
+
+func (m *quotaManager) clear() {
+	m.Lock()
+	defer m.Unlock()
+	m.owners = make(map[string]*proto.QuotaInfo)
+	m.dirs = make(map[string]*proto.QuotaInfo)
+	m.tokens = make(map[string]*proto.QuotaToken)
+}
+
+func (m *quotaManager) putOwner(quota *proto.QuotaInfo) {
+	m.Lock()
+	defer m.Unlock()
+	m.owners[quota.Name] = quota
+}
+
+func (m *quotaManager) deleteOwner(owner string) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.owners, owner)
+}
+
+func (m *quotaManager) getOwner(owner string) *proto.QuotaInfo {
+	m.RLock()
+	defer m.RUnlock()
+	return m.owners[owner]
+}
+
+func dirQuotaKey(volName, dirPath string) string {
+	return volName + keySeparator + dirPath
+}
+
+func (m *quotaManager) putDir(quota *proto.QuotaInfo) {
+	m.Lock()
+	defer m.Unlock()
+	m.dirs[dirQuotaKey(quota.Name, quota.Path)] = quota
+}
+
+func (m *quotaManager) deleteDir(volName, dirPath string) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.dirs, dirQuotaKey(volName, dirPath))
+}
+
+func (m *quotaManager) getDir(volName, dirPath string) *proto.QuotaInfo {
+	m.RLock()
+	defer m.RUnlock()
+	return m.dirs[dirQuotaKey(volName, dirPath)]
+}
+
+// listDirs returns the limits of the directories of the volume, or of all the volumes if volName is empty.
+func (m *quotaManager) listDirs(volName string) (quotas []*proto.QuotaInfo) {
+	m.RLock()
+	defer m.RUnlock()
+	for _, quota := range m.dirs {
+		if volName == "" || quota.Name == volName {
+			quotas = append(quotas, quota)
+		}
+	}
+	return
+}
+
+// volTokens returns the tokens of the last check, which are not modified.
+func (m *quotaManager) volTokens() map[string]*proto.QuotaToken {
+	m.RLock()
+	defer m.RUnlock()
+	return m.tokens
+}
+
+func (m *quotaManager) bytesExceeded(volName string) bool {
+	m.RLock()
+	defer m.RUnlock()
+	token := m.tokens[volName]
+	return token != nil && token.NoBytes
+}
+
+// check updates the tokens of the volumes by the quotas of the volumes, their owners and their directories.
+func (m *quotaManager) check(vols map[string]*Vol) {
+	volQuotas, ownerQuotas := m.quotaInfos(vols)
+	tokens := make(map[string]*proto.QuotaToken)
+	for name, quota := range volQuotas {
+		token := &proto.QuotaToken{NoBytes: quota.BytesExceeded, NoInodes: quota.InodesExceeded, TTL: quotaTokenTTL}
+		if owner := ownerQuotas[vols[name].Owner]; owner != nil {
+			token.NoBytes = token.NoBytes || owner.BytesExceeded
+			token.NoInodes = token.NoInodes || owner.InodesExceeded
+		}
+		if token.NoBytes || token.NoInodes {
+			tokens[name] = token
+		}
+	}
+	for _, quota := range m.dirQuotaInfos(vols, "") {
+		if volQuotas[quota.Name] == nil || (!quota.BytesExceeded && !quota.InodesExceeded) {
+			continue
+		}
+		token := tokens[quota.Name]
+		if token == nil {
+			token = &proto.QuotaToken{TTL: quotaTokenTTL}
+			tokens[quota.Name] = token
+		}
+		if quota.BytesExceeded {
+			token.NoBytesQuotas = append(token.NoBytesQuotas, quota.QuotaID)
+		}
+		if quota.InodesExceeded {
+			token.NoInodesQuotas = append(token.NoInodesQuotas, quota.QuotaID)
+		}
+	}
+	for _, token := range tokens {
+		sortQuotaIDs(token.NoBytesQuotas)
+		sortQuotaIDs(token.NoInodesQuotas)
+	}
+	m.Lock()
+	old := m.tokens
+	m.tokens = tokens
+	m.Unlock()
+	for name, token := range tokens {
+		if last := old[name]; last == nil || last.NoBytes != token.NoBytes || last.NoInodes != token.NoInodes ||
+			fmt.Sprint(last.NoBytesQuotas) != fmt.Sprint(token.NoBytesQuotas) ||
+			fmt.Sprint(last.NoInodesQuotas) != fmt.Sprint(token.NoInodesQuotas) {
+			log.LogWarnf("action[checkQuotas] vol[%v] exceeds the quota, bytes[%v] inodes[%v] dirQuotas bytes%v inodes%v",
+				name, token.NoBytes, token.NoInodes, token.NoBytesQuotas, token.NoInodesQuotas)
+		}
+	}
+	for name := range old {
+		if tokens[name] == nil {
+			log.LogWarnf("action[checkQuotas] vol[%v] is within the quota", name)
+		}
+	}
+}
+
+// quotaInfos returns the quotas with the usages of the volumes not deleted, and of their owners and the
+// owners having the quotas.
+func (m *quotaManager) quotaInfos(vols map[string]*Vol) (volQuotas, ownerQuotas map[string]*proto.QuotaInfo) {
+	volQuotas = make(map[string]*proto.QuotaInfo, len(vols))
+	ownerQuotas = make(map[string]*proto.QuotaInfo)
+	m.RLock()
+	for owner, quota := range m.owners {
+		ownerQuotas[owner] = &proto.QuotaInfo{Kind: proto.QuotaKindOwner, Name: owner, MaxBytes: quota.MaxBytes,
+			MaxInodes: quota.MaxInodes, Vols: make([]string, 0)}
+	}
+	m.RUnlock()
+	for name, vol := range vols {
+		if vol.status() == markDelete {
+			continue
+		}
+		quota := vol.quota()
+		volQuotas[name] = quota
+		owner := ownerQuotas[vol.Owner]
+		if owner == nil {
+			owner = &proto.QuotaInfo{Kind: proto.QuotaKindOwner, Name: vol.Owner, Vols: make([]string, 0)}
+			ownerQuotas[vol.Owner] = owner
+		}
+		owner.UsedBytes += quota.UsedBytes
+		owner.UsedInodes += quota.UsedInodes
+		owner.Vols = append(owner.Vols, name)
+	}
+	for _, owner := range ownerQuotas {
+		sort.Strings(owner.Vols)
+		setQuotaExceeded(owner)
+	}
+	return
+}
+
+// quota returns the quota of the volume with its usage.
+func (vol *Vol) quota() (quota *proto.QuotaInfo) {
+	vol.volLock.RLock()
+	quota = &proto.QuotaInfo{Kind: proto.QuotaKindVol, Name: vol.Name, MaxBytes: vol.Capacity * util.GB, MaxInodes: vol.maxInodes}
+	vol.volLock.RUnlock()
+	quota.UsedBytes = vol.totalUsedSpace()
+	vol.mpsLock.RLock()
+	for _, mp := range vol.MetaPartitions {
+		mp.RLock()
+		quota.UsedInodes += mp.InodeCount
+		mp.RUnlock()
+	}
+	vol.mpsLock.RUnlock()
+	setQuotaExceeded(quota)
+	return
+}
+
+// dirQuotaInfos returns the quotas of the directories of the volume, or of all the volumes if volName is
+// empty, with their usages reported by the meta nodes.
+func (m *quotaManager) dirQuotaInfos(vols map[string]*Vol, volName string) (quotas []*proto.QuotaInfo) {
+	usages := make(map[string]map[uint64]*proto.QuotaUsage)
+	for _, limits := range m.listDirs(volName) {
+		vol := vols[limits.Name]
+		if vol == nil {
+			continue
+		}
+		if usages[vol.Name] == nil {
+			usages[vol.Name] = vol.dirQuotaUsages()
+		}
+		quotas = append(quotas, dirQuotaInfo(limits, usages[vol.Name]))
+	}
+	return
+}
+
+func setQuotaExceeded(quota *proto.QuotaInfo) {
+	quota.BytesExceeded = quota.MaxBytes > 0 && quota.UsedBytes >= quota.MaxBytes
+	quota.InodesExceeded = quota.MaxInodes > 0 && quota.UsedInodes >= quota.MaxInodes
+}
+
+// key=#quota#owner, or #quota#dir#vol#path of a directory
+func quotaKey(quota *proto.QuotaInfo) string {
+	if quota.Kind == proto.QuotaKindDir {
+		return quotaPrefix + proto.QuotaKindDir + keySeparator + dirQuotaKey(quota.Name, quota.Path)
+	}
+	return quotaPrefix + quota.Name
+}
+
+func (c *Cluster) syncPutQuota(quota *proto.QuotaInfo) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutQuota
+	metadata.K = quotaKey(quota)
+	if metadata.V, err = json.Marshal(quota); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteQuota(quota *proto.QuotaInfo) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteQuota
+	metadata.K = quotaKey(quota)
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadQuotas() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(quotaPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadQuotas],err:%v", err.Error())
+		return
+	}
+	for _, value := range result {
+		quota := &proto.QuotaInfo{}
+		if err = json.Unmarshal(value, quota); err != nil {
+			err = fmt.Errorf("action[loadQuotas],value:%v,unmarshal err:%v", string(value), err)
+			return
+		}
+		if quota.Kind == proto.QuotaKindDir {
+			c.quotas.putDir(quota)
+		} else {
+			c.quotas.putOwner(quota)
+		}
+		log.LogInfof("action[loadQuotas],kind[%v] name[%v] path[%v] maxBytes[%v] maxInodes[%v]",
+			quota.Kind, quota.Name, quota.Path, quota.MaxBytes, quota.MaxInodes)
+	}
+	return
+}
+
+// setOwnerQuota sets the limits of all the volumes of the owner, the quota is deleted if both are 0.
+func (c *Cluster) setOwnerQuota(owner string, maxBytes, maxInodes uint64) (err error) {
+	if owner == "" || strings.Contains(owner, keySeparator) {
+		return fmt.Errorf("invalid owner [%v]", owner)
+	}
+	if maxBytes == 0 && maxInodes == 0 {
+		if c.quotas.getOwner(owner) == nil {
+			return
+		}
+		if err = c.syncDeleteQuota(&proto.QuotaInfo{Kind: proto.QuotaKindOwner, Name: owner}); err != nil {
+			return proto.ErrPersistenceByRaft
+		}
+		c.quotas.deleteOwner(owner)
+		log.LogInfof("action[setOwnerQuota] owner(%v) quota deleted", owner)
+		return
+	}
+	quota := &proto.QuotaInfo{Kind: proto.QuotaKindOwner, Name: owner, MaxBytes: maxBytes, MaxInodes: maxInodes}
+	if err = c.syncPutQuota(quota); err != nil {
+		return proto.ErrPersistenceByRaft
+	}
+	c.quotas.putOwner(quota)
+	log.LogInfof("action[setOwnerQuota] owner(%v) maxBytes(%v) maxInodes(%v)", owner, maxBytes, maxInodes)
+	return
+}
+
+// getQuota returns the quota of the volume, the owner or the directory of the volume with its current usage.
+func (c *Cluster) getQuota(kind, name, dirPath string) (quota *proto.QuotaInfo, err error) {
+	switch kind {
+	case proto.QuotaKindVol:
+		volQuotas, _ := c.quotas.quotaInfos(c.copyVols())
+		quota = volQuotas[name]
+	case proto.QuotaKindOwner:
+		_, ownerQuotas := c.quotas.quotaInfos(c.copyVols())
+		quota = ownerQuotas[name]
+	case proto.QuotaKindDir:
+		for _, dirQuota := range c.quotas.dirQuotaInfos(c.copyVols(), name) {
+			if dirQuota.Path == dirPath {
+				quota = dirQuota
+			}
+		}
+		if quota == nil {
+			return nil, fmt.Errorf("%v quota [%v:%v] not found", kind, name, dirPath)
+		}
+	default:
+		return nil, fmt.Errorf("invalid quota kind [%v]", kind)
+	}
+	if quota == nil {
+		return nil, fmt.Errorf("%v quota [%v] not found", kind, name)
+	}
+	return
+}
+
+// listQuotas returns the quotas of the kind, or of all the kinds if kind is empty. The volumes are
+// listed before the owners and the directories, and each kind is sorted by the names and the paths.
+func (c *Cluster) listQuotas(kind string) (quotas []*proto.QuotaInfo, err error) {
+	if kind != "" && kind != proto.QuotaKindVol && kind != proto.QuotaKindOwner && kind != proto.QuotaKindDir {
+		return nil, fmt.Errorf("invalid quota kind [%v]", kind)
+	}
+	vols := c.copyVols()
+	volQuotas, ownerQuotas := c.quotas.quotaInfos(vols)
+	dirQuotas := c.quotas.dirQuotaInfos(vols, "")
+	quotas = make([]*proto.QuotaInfo, 0, len(volQuotas)+len(ownerQuotas)+len(dirQuotas))
+	if kind == "" || kind == proto.QuotaKindVol {
+		for _, quota := range volQuotas {
+			quotas = append(quotas, quota)
+		}
+	}
+	if kind == "" || kind == proto.QuotaKindOwner {
+		for _, quota := range ownerQuotas {
+			quotas = append(quotas, quota)
+		}
+	}
+	if kind == "" || kind == proto.QuotaKindDir {
+		quotas = append(quotas, dirQuotas...)
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].Kind != quotas[j].Kind {
+			return quotas[i].Kind > quotas[j].Kind
+		}
+		if quotas[i].Name != quotas[j].Name {
+			return quotas[i].Name < quotas[j].Name
+		}
+		return quotas[i].Path < quotas[j].Path
+	})
+	return
+}
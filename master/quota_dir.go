@@ -0,0 +1,266 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The usage of a directory quota is counted by the meta nodes. The inodes of the tree are tagged with the ID
+// of the quota: the master tags the existing tree once when the quota is set, and the meta nodes tag the
+// inodes created below by the quotas of their parents. The leaders of the meta partitions report the bytes
+// and the inodes tagged with each quota in the heartbeats, which the master sums up. The meta nodes are
+// pushed the IDs of the quotas exceeded, and refuse to add the inodes, the dentries and the data to the
+// inodes tagged with them. The files renamed across the quotas are refused by the clients with EXDEV.
+const (
+	dirQuotaBatchSize = 1000 // inodes tagged by a request
+)
+
+// dirQuotaInfo returns the quota of the directory with its usage summed up from the meta partitions.
+func dirQuotaInfo(limits *proto.QuotaInfo, usages map[uint64]*proto.QuotaUsage) (quota *proto.QuotaInfo) {
+	quota = &proto.QuotaInfo{Kind: proto.QuotaKindDir, Name: limits.Name, Path: limits.Path, QuotaID: limits.QuotaID,
+		MaxBytes: limits.MaxBytes, MaxInodes: limits.MaxInodes}
+	if usage := usages[limits.QuotaID]; usage != nil {
+		quota.UsedBytes = usage.UsedBytes
+		quota.UsedInodes = usage.UsedInodes
+	}
+	setQuotaExceeded(quota)
+	return
+}
+
+// dirQuotaUsages sums up the usages of the directory quotas reported by the leaders of the meta partitions.
+func (vol *Vol) dirQuotaUsages() (usages map[uint64]*proto.QuotaUsage) {
+	usages = make(map[uint64]*proto.QuotaUsage)
+	vol.mpsLock.RLock()
+	defer vol.mpsLock.RUnlock()
+	for _, mp := range vol.MetaPartitions {
+		mp.RLock()
+		for _, usage := range mp.quotaUsages {
+			sum := usages[usage.QuotaID]
+			if sum == nil {
+				sum = &proto.QuotaUsage{QuotaID: usage.QuotaID}
+				usages[usage.QuotaID] = sum
+			}
+			sum.UsedBytes += usage.UsedBytes
+			sum.UsedInodes += usage.UsedInodes
+		}
+		mp.RUnlock()
+	}
+	return
+}
+
+func sortQuotaIDs(ids []uint64) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+}
+
+// setDirQuota sets the limits of the directory of the volume, the quota is deleted if both are 0. The tree
+// of the directory is tagged with a new quota when it is set for the first time, and untagged when the quota
+// is deleted. The files created in the tree while it is tagged may be missed by the quota.
+func (c *Cluster) setDirQuota(volName, dirPath string, maxBytes, maxInodes uint64) (err error) {
+	quota := c.quotas.getDir(volName, dirPath)
+	if maxBytes == 0 && maxInodes == 0 {
+		if quota == nil {
+			return
+		}
+		if err = c.syncDeleteQuota(quota); err != nil {
+			return proto.ErrPersistenceByRaft
+		}
+		c.quotas.deleteDir(volName, dirPath)
+		log.LogInfof("action[setDirQuota] vol(%v) path(%v) quota(%v) deleted", volName, dirPath, quota.QuotaID)
+		// the inodes left tagged are not counted by any quota
+		if err = c.tagDirQuota(quota, true); err != nil {
+			log.LogWarnf("action[setDirQuota] vol(%v) path(%v) untag quota(%v) err(%v)", volName, dirPath, quota.QuotaID, err)
+		}
+		return nil
+	}
+	if quota != nil {
+		updated := *quota
+		updated.MaxBytes, updated.MaxInodes = maxBytes, maxInodes
+		if err = c.syncPutQuota(&updated); err != nil {
+			return proto.ErrPersistenceByRaft
+		}
+		c.quotas.putDir(&updated)
+		log.LogInfof("action[setDirQuota] vol(%v) path(%v) quota(%v) maxBytes(%v) maxInodes(%v)",
+			volName, dirPath, quota.QuotaID, maxBytes, maxInodes)
+		return
+	}
+	quota = &proto.QuotaInfo{Kind: proto.QuotaKindDir, Name: volName, Path: dirPath, MaxBytes: maxBytes, MaxInodes: maxInodes}
+	if quota.QuotaID, err = c.idAlloc.allocateCommonID(); err != nil {
+		return
+	}
+	if err = c.tagDirQuota(quota, false); err != nil {
+		if e := c.tagDirQuota(quota, true); e != nil {
+			log.LogWarnf("action[setDirQuota] vol(%v) path(%v) untag quota(%v) err(%v)", volName, dirPath, quota.QuotaID, e)
+		}
+		return
+	}
+	if err = c.syncPutQuota(quota); err != nil {
+		return proto.ErrPersistenceByRaft
+	}
+	c.quotas.putDir(quota)
+	log.LogInfof("action[setDirQuota] vol(%v) path(%v) quota(%v) maxBytes(%v) maxInodes(%v)",
+		volName, dirPath, quota.QuotaID, maxBytes, maxInodes)
+	return
+}
+
+// deleteDirQuotas drops the quotas of the directories of the deleted volume.
+func (c *Cluster) deleteDirQuotas(volName string) {
+	for _, quota := range c.quotas.listDirs(volName) {
+		if err := c.syncDeleteQuota(quota); err != nil {
+			log.LogErrorf("action[deleteDirQuotas] vol[%v] path[%v] err[%v]", volName, quota.Path, err)
+			continue
+		}
+		c.quotas.deleteDir(volName, quota.Path)
+	}
+}
+
+// tagDirQuota tags the directory of the quota and all the inodes below it with the quota, or untags them.
+// The tree is walked level by level, and the directories are tagged before their children are read, so
+// that the children created meanwhile are tagged by the meta nodes.
+func (c *Cluster) tagDirQuota(quota *proto.QuotaInfo, remove bool) (err error) {
+	vol, err := c.getVol(quota.Name)
+	if err != nil {
+		return
+	}
+	ino, err := c.lookupDirPath(vol, quota.Path)
+	if err != nil {
+		return
+	}
+	level := []uint64{ino}
+	for len(level) > 0 {
+		if err = c.setInodeQuota(vol, level, quota.QuotaID, remove); err != nil {
+			return
+		}
+		children := make([][]proto.Dentry, len(level))
+		err = runConcurrently(len(level), func(i int) (err error) {
+			mp, err := vol.metaPartitionByInode(level[i])
+			if err != nil {
+				return
+			}
+			req := &proto.ReadDirRequest{VolName: vol.Name, PartitionID: mp.PartitionID, ParentID: level[i]}
+			resp := &proto.ReadDirResponse{}
+			if err = c.sendMetaPartitionRequest(mp, proto.OpMetaReadDir, req, resp); err != nil {
+				return fmt.Errorf("read dir %v: %v", level[i], err)
+			}
+			children[i] = resp.Children
+			return
+		})
+		if err != nil {
+			return
+		}
+		var files []uint64
+		next := make([]uint64, 0)
+		for _, dentries := range children {
+			for _, dentry := range dentries {
+				if proto.IsDir(dentry.Type) {
+					next = append(next, dentry.Inode)
+				} else {
+					files = append(files, dentry.Inode)
+				}
+			}
+		}
+		if err = c.setInodeQuota(vol, files, quota.QuotaID, remove); err != nil {
+			return
+		}
+		level = next
+	}
+	return
+}
+
+// setInodeQuota tags or untags the inodes with the quota, which are sent by batches to their meta partitions.
+func (c *Cluster) setInodeQuota(vol *Vol, inodes []uint64, quotaID uint64, remove bool) (err error) {
+	type batch struct {
+		mp     *MetaPartition
+		inodes []uint64
+	}
+	groups := make(map[uint64]*batch)
+	batches := make([]*batch, 0)
+	for _, ino := range inodes {
+		mp, err := vol.metaPartitionByInode(ino)
+		if err != nil {
+			return err
+		}
+		group := groups[mp.PartitionID]
+		if group == nil || len(group.inodes) >= dirQuotaBatchSize {
+			group = &batch{mp: mp}
+			groups[mp.PartitionID] = group
+			batches = append(batches, group)
+		}
+		group.inodes = append(group.inodes, ino)
+	}
+	return runConcurrently(len(batches), func(i int) (err error) {
+		req := &proto.SetInodeQuotaRequest{VolName: vol.Name, PartitionID: batches[i].mp.PartitionID,
+			Inodes: batches[i].inodes, QuotaID: quotaID, Remove: remove}
+		if _, err = c.syncSendMetaPartitionPacket(batches[i].mp, proto.OpMetaSetInodeQuota, req); err != nil {
+			return fmt.Errorf("set quota %v of inodes: %v", quotaID, err)
+		}
+		return
+	})
+}
+
+// lookupDirPath returns the inode of the directory of the absolute path.
+func (c *Cluster) lookupDirPath(vol *Vol, dirPath string) (ino uint64, err error) {
+	ino = proto.RootIno
+	for _, name := range strings.Split(strings.TrimPrefix(path.Clean(dirPath), "/"), "/") {
+		if name == "" {
+			continue
+		}
+		var mp *MetaPartition
+		if mp, err = vol.metaPartitionByInode(ino); err != nil {
+			return
+		}
+		req := &proto.LookupRequest{VolName: vol.Name, PartitionID: mp.PartitionID, ParentID: ino, Name: name}
+		resp := &proto.LookupResponse{}
+		if err = c.sendMetaPartitionRequest(mp, proto.OpMetaLookup, req, resp); err != nil {
+			return 0, fmt.Errorf("lookup %v of path %v: %v", name, dirPath, err)
+		}
+		if !proto.IsDir(resp.Mode) {
+			return 0, fmt.Errorf("%v of path %v is not a directory", name, dirPath)
+		}
+		ino = resp.Inode
+	}
+	return
+}
+
+// sendMetaPartitionRequest sends the request to the leader of the meta partition, and decodes the reply into resp.
+func (c *Cluster) sendMetaPartitionRequest(mp *MetaPartition, opcode uint8, req, resp interface{}) (err error) {
+	packet, err := c.syncSendMetaPartitionPacket(mp, opcode, req)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(packet.Data, resp); err != nil {
+		return fmt.Errorf("mp[%v] unmarshal reply of %v err[%v]", mp.PartitionID, packet.GetOpMsg(), err)
+	}
+	return
+}
+
+// metaPartitionByInode returns the meta partition of the volume whose range holds the inode.
+func (vol *Vol) metaPartitionByInode(ino uint64) (mp *MetaPartition, err error) {
+	vol.mpsLock.RLock()
+	defer vol.mpsLock.RUnlock()
+	for _, mp = range vol.MetaPartitions {
+		if mp.Start <= ino && ino <= mp.End {
+			return
+		}
+	}
+	return nil, fmt.Errorf("vol[%v] no meta partition of inode[%v]", vol.Name, ino)
+}
@@ -0,0 +1,189 @@
+package master
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/master/mocktest"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+)
+
+func TestQuota(t *testing.T) {
+	vol, err := server.cluster.getVol(commonVolName)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var mp *MetaPartition
+	for _, mp = range vol.MetaPartitions {
+		break
+	}
+	oldInodeCount := mp.InodeCount
+	mp.InodeCount = 10
+	defer func() {
+		mp.InodeCount = oldInodeCount
+		server.cluster.quotas.check(server.cluster.copyVols())
+	}()
+
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v&maxInodes=5&authKey=%v", hostAddr, proto.AdminSetQuota,
+		proto.QuotaKindVol, commonVolName, buildAuthKey(vol.Owner)), t)
+	if vol.maxInodes != 5 {
+		t.Errorf("max inodes of vol[%v] not set: %v", commonVolName, vol.maxInodes)
+		return
+	}
+	server.cluster.quotas.check(server.cluster.copyVols())
+	if token := server.cluster.quotas.volTokens()[commonVolName]; token == nil || !token.NoInodes || token.NoBytes {
+		t.Errorf("unexpected token %v", token)
+		return
+	}
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v&maxInodes=0&authKey=%v", hostAddr, proto.AdminSetQuota,
+		proto.QuotaKindVol, commonVolName, buildAuthKey(vol.Owner)), t)
+	server.cluster.quotas.check(server.cluster.copyVols())
+	if token := server.cluster.quotas.volTokens()[commonVolName]; token != nil {
+		t.Errorf("unexpected token %v", token)
+		return
+	}
+
+	// the inodes of all the volumes of the owner exceed its quota
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v&maxInodes=5", hostAddr, proto.AdminSetQuota, proto.QuotaKindOwner, vol.Owner), t)
+	server.cluster.quotas.check(server.cluster.copyVols())
+	if token := server.cluster.quotas.volTokens()[commonVolName]; token == nil || !token.NoInodes {
+		t.Errorf("unexpected token %v", token)
+		return
+	}
+	quota, err := server.cluster.getQuota(proto.QuotaKindOwner, vol.Owner, "")
+	if err != nil || !quota.InodesExceeded || quota.UsedInodes < 10 || len(quota.Vols) == 0 {
+		t.Errorf("unexpected quota %+v err %v", quota, err)
+		return
+	}
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v", hostAddr, proto.AdminGetQuota, proto.QuotaKindOwner, vol.Owner), t)
+	process(fmt.Sprintf("%v%v", hostAddr, proto.AdminListQuotas), t)
+
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v&maxInodes=0", hostAddr, proto.AdminSetQuota, proto.QuotaKindOwner, vol.Owner), t)
+	if server.cluster.quotas.getOwner(vol.Owner) != nil {
+		t.Errorf("quota of owner not deleted")
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	quota := &proto.QuotaInfo{MaxBytes: 100, UsedBytes: 100, UsedInodes: 1 << 20}
+	setQuotaExceeded(quota)
+	if !quota.BytesExceeded || quota.InodesExceeded {
+		t.Errorf("unexpected quota %+v", quota)
+	}
+}
+
+func TestDirQuotaTokens(t *testing.T) {
+	name := "dirQuotaTokensVol"
+	vol := newVol(1, name, name, "", util.DefaultDataPartitionSize, 100, defaultReplicaNum,
+		defaultReplicaNum, false, false, false, false, false, time.Now().Unix(), "")
+	// every meta partition reports 1 inode and 10 bytes of the quota 11, and 50 bytes of the quota 12
+	for id := uint64(1); id <= 2; id++ {
+		mp := newMetaPartition(id, 1, defaultMaxMetaPartitionInodeID, 3, name, vol.ID)
+		mp.quotaUsages = []*proto.QuotaUsage{{QuotaID: 11, UsedBytes: 10, UsedInodes: 1}, {QuotaID: 12, UsedBytes: 50}}
+		vol.addMetaPartition(mp)
+	}
+	vols := map[string]*Vol{name: vol}
+	n := uint64(2)
+	cases := []struct {
+		name       string
+		maxInodes  uint64 // of the quota 11
+		maxBytes   uint64 // of the quota 12
+		noInodes   string
+		noBytes    string
+		withoutDir bool
+	}{
+		{"within", n + 1, 50*n + 1, "[]", "[]", false},
+		{"inodes exceeded", n, 50*n + 1, "[11]", "[]", false},
+		{"bytes exceeded", n + 1, 50 * n, "[]", "[12]", false},
+		{"both exceeded", n, 50 * n, "[11]", "[12]", false},
+		{"quota deleted", n, 50 * n, "[11]", "[]", true},
+	}
+	for _, c := range cases {
+		quotas := newQuotaManager()
+		quotas.putDir(&proto.QuotaInfo{Kind: proto.QuotaKindDir, Name: name, Path: "/a", QuotaID: 11, MaxInodes: c.maxInodes})
+		if !c.withoutDir {
+			quotas.putDir(&proto.QuotaInfo{Kind: proto.QuotaKindDir, Name: name, Path: "/b", QuotaID: 12, MaxBytes: c.maxBytes})
+		}
+		quotas.check(vols)
+		token := quotas.volTokens()[name]
+		if c.noInodes == "[]" && c.noBytes == "[]" {
+			if token != nil {
+				t.Errorf("%v: unexpected token %+v", c.name, token)
+			}
+			continue
+		}
+		if token == nil || token.NoBytes || token.NoInodes || fmt.Sprint(token.NoInodesQuotas) != c.noInodes ||
+			fmt.Sprint(token.NoBytesQuotas) != c.noBytes {
+			t.Errorf("%v: unexpected token %+v", c.name, token)
+		}
+		for _, quota := range quotas.dirQuotaInfos(vols, name) {
+			if quota.QuotaID == 11 && (quota.UsedInodes != n || quota.UsedBytes != 10*n) ||
+				quota.QuotaID == 12 && (quota.UsedInodes != 0 || quota.UsedBytes != 50*n) {
+				t.Errorf("%v: unexpected usage %+v", c.name, quota)
+			}
+		}
+	}
+}
+
+func TestDirQuota(t *testing.T) {
+	volName := "dirQuotaVol"
+	createVol(volName, t)
+	// the inodes are tagged through the partition leaders reported by the heartbeats
+	server.cluster.checkMetaNodeHeartbeat()
+	time.Sleep(5 * time.Second)
+	vol, err := server.cluster.getVol(volName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = server.cluster.setDirQuota(volName, "/missing", 0, 1); err == nil {
+		t.Errorf("quota of the missing directory set")
+	}
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v&path=/quota&maxInodes=2&authKey=%v", hostAddr, proto.AdminSetQuota,
+		proto.QuotaKindDir, volName, buildAuthKey(vol.Owner)), t)
+	quota := server.cluster.quotas.getDir(volName, "/quota")
+	if quota == nil || quota.QuotaID == 0 {
+		t.Fatalf("unexpected quota %+v", quota)
+	}
+	for _, ino := range []uint64{mocktest.QuotaDirIno, mocktest.QuotaFileIno} {
+		if ids := mocktest.InodeQuotas(volName, ino); fmt.Sprint(ids) != fmt.Sprint([]uint64{quota.QuotaID}) {
+			t.Errorf("inode %v tagged with quotas %v, expect %v", ino, ids, quota.QuotaID)
+		}
+	}
+
+	// the usage of the tree is reported by the meta nodes
+	server.cluster.checkMetaNodeHeartbeat()
+	time.Sleep(5 * time.Second)
+	server.cluster.quotas.check(server.cluster.copyVols())
+	if token := server.cluster.quotas.volTokens()[volName]; token == nil ||
+		fmt.Sprint(token.NoInodesQuotas) != fmt.Sprint([]uint64{quota.QuotaID}) || len(token.NoBytesQuotas) != 0 {
+		t.Errorf("unexpected token %+v", token)
+	}
+	info, err := server.cluster.getQuota(proto.QuotaKindDir, volName, "/quota")
+	if err != nil || info.UsedInodes != 2 || info.UsedBytes != 2*mocktest.MockInodeSize || !info.InodesExceeded {
+		t.Errorf("unexpected quota %+v err %v", info, err)
+	}
+
+	// the limits raised keep the tags
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v&path=/quota&maxInodes=10&authKey=%v", hostAddr, proto.AdminSetQuota,
+		proto.QuotaKindDir, volName, buildAuthKey(vol.Owner)), t)
+	if updated := server.cluster.quotas.getDir(volName, "/quota"); updated == nil || updated.QuotaID != quota.QuotaID {
+		t.Errorf("unexpected quota %+v", updated)
+	}
+	server.cluster.quotas.check(server.cluster.copyVols())
+	if token := server.cluster.quotas.volTokens()[volName]; token != nil && len(token.NoInodesQuotas)+len(token.NoBytesQuotas) > 0 {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	// the tree is untagged once the quota is deleted
+	process(fmt.Sprintf("%v%v?kind=%v&name=%v&path=/quota&maxInodes=0&authKey=%v", hostAddr, proto.AdminSetQuota,
+		proto.QuotaKindDir, volName, buildAuthKey(vol.Owner)), t)
+	if server.cluster.quotas.getDir(volName, "/quota") != nil {
+		t.Errorf("quota of the directory not deleted")
+	}
+	if ids := mocktest.InodeQuotas(volName, mocktest.QuotaFileIno); len(ids) != 0 {
+		t.Errorf("inode %v left tagged with quotas %v", mocktest.QuotaFileIno, ids)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	// the interval in seconds of the allocations requested by the nodes
+	rateLimitAllocateInterval = 5
+	// the nodes not requesting a key for the intervals do not share its limit
+	rateLimitExpiredIntervals = 3
+)
+
+type rateLimitDemand struct {
+	rate       float64
+	updateTime time.Time
+}
+
+// rateLimitManager holds the limits of the cluster shared by the nodes, and allocates their rates to the nodes
+// by the demands reported. The limits are persisted by raft, while the demands are kept in the memory of the
+// leader, which are reported again by the nodes in an interval after the leader changes.
+type rateLimitManager struct {
+	sync.RWMutex
+	limits  map[string]*proto.RateLimitInfo
+	demands map[string]map[string]*rateLimitDemand // key -> node -> demand
+}
+
+func newRateLimitManager() *rateLimitManager {
+	return &rateLimitManager{
+		limits:  make(map[string]*proto.RateLimitInfo),
+		demands: make(map[string]map[string]*rateLimitDemand),
+	}
+}
+
+func (m *rateLimitManager) clear() {
+	m.Lock()
+	defer m.Unlock()
+	m.limits = make(map[string]*proto.RateLimitInfo)
+	m.demands = make(map[string]map[string]*rateLimitDemand)
+}
+
+func (m *rateLimitManager) put(limit *proto.RateLimitInfo) {
+	m.Lock()
+	defer m.Unlock()
+	m.limits[limit.Key] = limit
+}
+
+func (m *rateLimitManager) delete(key string) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.limits, key)
+}
+
+func (m *rateLimitManager) get(key string) *proto.RateLimitInfo {
+	m.RLock()
+	defer m.RUnlock()
+	return m.limits[key]
+}
+
+func (m *rateLimitManager) list() (limits []*proto.RateLimitInfo) {
+	m.RLock()
+	defer m.RUnlock()
+	limits = make([]*proto.RateLimitInfo, 0, len(m.limits))
+	for _, limit := range m.limits {
+		limits = append(limits, limit)
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Key < limits[j].Key })
+	return
+}
+
+// allocate records the demands of the node, and returns the budgets of the keys requested by it.
+func (m *rateLimitManager) allocate(req *proto.RateLimitAllocateRequest, now time.Time) *proto.RateLimitAllocateResponse {
+	m.Lock()
+	defer m.Unlock()
+	resp := &proto.RateLimitAllocateResponse{Interval: rateLimitAllocateInterval}
+	expired := now.Add(-rateLimitExpiredIntervals * rateLimitAllocateInterval * time.Second)
+	for _, demand := range req.Demands {
+		limit := m.limits[demand.Key]
+		if limit == nil {
+			delete(m.demands, demand.Key)
+			resp.Budgets = append(resp.Budgets, &proto.RateLimitBudget{Key: demand.Key, Rate: -1})
+			continue
+		}
+		nodes := m.demands[demand.Key]
+		if nodes == nil {
+			nodes = make(map[string]*rateLimitDemand)
+			m.demands[demand.Key] = nodes
+		}
+		nodes[req.Node] = &rateLimitDemand{rate: demand.Demand, updateTime: now}
+		rates := make(map[string]float64, len(nodes))
+		for node, d := range nodes {
+			if d.updateTime.Before(expired) {
+				delete(nodes, node)
+				continue
+			}
+			rates[node] = d.rate
+		}
+		resp.Budgets = append(resp.Budgets, &proto.RateLimitBudget{Key: demand.Key, Rate: shareRate(limit.Rate, rates)[req.Node]})
+	}
+	return resp
+}
+
+// shareRate shares the rate among the nodes by the max-min fairness of their demands, that is the nodes
+// demanding less than the fair share get their demands, and the others share the rest equally. The rate
+// left after the demands is shared by all the nodes as well, so that a node demanding more gets the rate
+// before it reports the demand.
+func shareRate(total float64, demands map[string]float64) map[string]float64 {
+	nodes := make([]string, 0, len(demands))
+	for node := range demands {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return demands[nodes[i]] < demands[nodes[j]] })
+	shares := make(map[string]float64, len(nodes))
+	remaining := total
+	for i, node := range nodes {
+		share := remaining / float64(len(nodes)-i)
+		if demand := demands[node]; demand < share {
+			share = demand
+		}
+		shares[node] = share
+		remaining -= share
+	}
+	for _, node := range nodes {
+		shares[node] += remaining / float64(len(nodes))
+	}
+	return shares
+}
+
+// key=#ratelimit#key
+func (c *Cluster) syncPutRateLimit(limit *proto.RateLimitInfo) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutRateLimit
+	metadata.K = rateLimitPrefix + limit.Key
+	if metadata.V, err = json.Marshal(limit); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteRateLimit(key string) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteRateLimit
+	metadata.K = rateLimitPrefix + key
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadRateLimits() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(rateLimitPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadRateLimits],err:%v", err.Error())
+		return
+	}
+	for _, value := range result {
+		limit := &proto.RateLimitInfo{}
+		if err = json.Unmarshal(value, limit); err != nil {
+			err = fmt.Errorf("action[loadRateLimits],value:%v,unmarshal err:%v", string(value), err)
+			return
+		}
+		c.rateLimits.put(limit)
+		log.LogInfof("action[loadRateLimits],key[%v] rate[%v]", limit.Key, limit.Rate)
+	}
+	return
+}
+
+func (c *Cluster) setRateLimit(key string, rate float64) (err error) {
+	if key == "" || strings.Contains(key, keySeparator) {
+		return fmt.Errorf("invalid rate limit key [%v]", key)
+	}
+	if rate <= 0 {
+		return fmt.Errorf("invalid rate [%v] of rate limit [%v], should be positive", rate, key)
+	}
+	limit := &proto.RateLimitInfo{Key: key, Rate: rate}
+	if err = c.syncPutRateLimit(limit); err != nil {
+		return proto.ErrPersistenceByRaft
+	}
+	c.rateLimits.put(limit)
+	log.LogInfof("action[setRateLimit] key(%v) rate(%v)", key, rate)
+	return
+}
+
+func (c *Cluster) deleteRateLimit(key string) (err error) {
+	if c.rateLimits.get(key) == nil {
+		return fmt.Errorf("rate limit [%v] not found", key)
+	}
+	if err = c.syncDeleteRateLimit(key); err != nil {
+		return proto.ErrPersistenceByRaft
+	}
+	c.rateLimits.delete(key)
+	log.LogInfof("action[deleteRateLimit] key(%v)", key)
+	return
+}
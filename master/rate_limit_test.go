@@ -0,0 +1,51 @@
+package master
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestShareRate(t *testing.T) {
+	shares := shareRate(100, map[string]float64{"a": 10, "b": 80, "c": 80})
+	if shares["a"] != 10 || shares["b"] != 45 || shares["c"] != 45 {
+		t.Errorf("unexpected shares %v", shares)
+	}
+	shares = shareRate(100, map[string]float64{"a": 10, "b": 20})
+	if shares["a"] != 45 || shares["b"] != 55 {
+		t.Errorf("unexpected shares %v", shares)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	key := "s3qps:test"
+	process(fmt.Sprintf("%v%v?key=%v&rate=100", hostAddr, proto.AdminSetRateLimit, key), t)
+	if limit := server.cluster.rateLimits.get(key); limit == nil || limit.Rate != 100 {
+		t.Errorf("rate limit [%v] not set: %v", key, limit)
+		return
+	}
+	process(fmt.Sprintf("%v%v", hostAddr, proto.AdminListRateLimits), t)
+
+	now := time.Now()
+	req := &proto.RateLimitAllocateRequest{Node: "n1", Demands: []*proto.RateLimitDemand{{Key: key, Demand: 90}, {Key: "unlimited", Demand: 1}}}
+	resp := server.cluster.rateLimits.allocate(req, now)
+	if resp.Budgets[0].Rate != 100 || resp.Budgets[1].Rate >= 0 {
+		t.Errorf("unexpected budgets %v %v", resp.Budgets[0], resp.Budgets[1])
+	}
+	req = &proto.RateLimitAllocateRequest{Node: "n2", Demands: []*proto.RateLimitDemand{{Key: key, Demand: 90}}}
+	if resp = server.cluster.rateLimits.allocate(req, now); resp.Budgets[0].Rate != 50 {
+		t.Errorf("unexpected budget %v", resp.Budgets[0])
+	}
+	// the demand of n1 expires
+	later := now.Add(rateLimitExpiredIntervals*rateLimitAllocateInterval*time.Second + time.Second)
+	if resp = server.cluster.rateLimits.allocate(req, later); resp.Budgets[0].Rate != 100 {
+		t.Errorf("unexpected budget %v", resp.Budgets[0])
+	}
+
+	process(fmt.Sprintf("%v%v?key=%v", hostAddr, proto.AdminDeleteRateLimit, key), t)
+	if server.cluster.rateLimits.get(key) != nil {
+		t.Errorf("rate limit [%v] not deleted", key)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"hash/crc64"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// replies smaller than it are not compressed
+const minCompressReplySize = 4 * 1024
+
+// read-only APIs whose replies carry the ETag, so that the pollers get 304 Not Modified if the
+// replies are not changed since the last ones
+var cacheableAPIs = map[string]bool{
+	proto.AdminGetCluster:           true,
+	proto.AdminClusterStat:          true,
+	proto.AdminGetVol:               true,
+	proto.AdminListVols:             true,
+	proto.AdminGetDataPartition:     true,
+	proto.AdminGetHotDataPartitions: true,
+	proto.AdminGetDegradedDisks:     true,
+	proto.AdminGetInvalidNodes:      true,
+	proto.ClientVol:                 true,
+	proto.ClientVolStat:             true,
+	proto.ClientDataPartitions:      true,
+	proto.ClientMetaPartition:       true,
+	proto.ClientMetaPartitions:      true,
+	proto.GetDataNode:               true,
+	proto.GetMetaNode:               true,
+	proto.GetTopologyView:           true,
+	proto.GetAllZones:               true,
+}
+
+var (
+	etagTable = crc64.MakeTable(crc64.ECMA)
+	gzipPool  = sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	}}
+)
+
+// replyETag returns the weak ETag of the reply, which is the same whether the reply is compressed or not.
+func replyETag(reply []byte) string {
+	return fmt.Sprintf(`W/"%x-%x"`, len(reply), crc64.Checksum(reply, etagTable))
+}
+
+// notModified sets the ETag of the reply to a GET of a cacheable API, and returns if the reply
+// matches the If-None-Match of the request.
+func notModified(w http.ResponseWriter, r *http.Request, reply []byte) bool {
+	if r.Method != http.MethodGet || !cacheableAPIs[r.URL.Path] {
+		return false
+	}
+	etag := replyETag(reply)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimSpace(match)
+		if match == "*" || strings.TrimPrefix(match, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// compressReply returns the reply compressed by gzip if the client accepts it and the reply is
+// large enough, otherwise the reply itself.
+func compressReply(w http.ResponseWriter, r *http.Request, reply []byte) []byte {
+	if len(reply) < minCompressReplySize || !acceptsGzip(r) {
+		return reply
+	}
+	var buf bytes.Buffer
+	zw := gzipPool.Get().(*gzip.Writer)
+	defer gzipPool.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(reply); err != nil {
+		return reply
+	}
+	if err := zw.Close(); err != nil {
+		return reply
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	return buf.Bytes()
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding = strings.TrimSpace(coding)
+		if i := strings.Index(coding, ";"); i >= 0 {
+			if q := strings.TrimSpace(coding[i+1:]); q == "q=0" || q == "q=0.0" {
+				continue
+			}
+			coding = strings.TrimSpace(coding[:i])
+		}
+		if coding == "gzip" || coding == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package master
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestSendReplyEncoding(t *testing.T) {
+	reply := bytes.Repeat([]byte(`{"PartitionID":1,"Status":2}`), 1024)
+
+	r := httptest.NewRequest(http.MethodGet, proto.ClientDataPartitions+"?name=vol", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	send(w, r, reply)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" || w.Body.Len() >= len(reply) {
+		t.Fatalf("expect a gzip reply: code(%v) encoding(%v) len(%v)", w.Code, w.Header().Get("Content-Encoding"), w.Body.Len())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(zr); !bytes.Equal(data, reply) {
+		t.Fatalf("decompressed reply mismatches")
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("no ETag")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, proto.ClientDataPartitions+"?name=vol", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	send(w, r, reply)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expect not modified: code(%v) len(%v)", w.Code, w.Body.Len())
+	}
+
+	// APIs out of the cacheable ones and the clients without gzip get the plain reply
+	r = httptest.NewRequest(http.MethodGet, proto.AdminCreateVol, nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	send(w, r, reply)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" || !bytes.Equal(w.Body.Bytes(), reply) {
+		t.Fatalf("expect a plain reply: code(%v) etag(%v)", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -22,14 +22,16 @@ import (
 	"net/http/httputil"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/raftstore"
+	"github.com/cubefs/cubefs/util/audit"
 	"github.com/cubefs/cubefs/util/config"
-	"github.com/cubefs/cubefs/util/cryptoutil"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/kms"
 	"github.com/cubefs/cubefs/util/log"
 )
 
@@ -49,9 +51,53 @@ const (
 	cfgTickInterval    = "tickInterval"
 	cfgRaftRecvBufSize = "raftRecvBufSize"
 	cfgElectionTick    = "electionTick"
+	cfgWalArchiveDir   = "walArchiveDir"
 	SecretKey          = "masterServiceKey"
 )
 
+// ConfigSchema declares the configuration keys of the master.
+var ConfigSchema = config.MergeSchemas(kms.ConfigSchema, config.Schema{
+	ClusterName:                         config.TypeString,
+	ID:                                  config.TypeString,
+	IP:                                  config.TypeString,
+	proto.ListenPort:                    config.TypeString,
+	WalDir:                              config.TypeString,
+	StoreDir:                            config.TypeString,
+	CfgRetainLogs:                       config.TypeString,
+	cfgTickInterval:                     config.TypeFloat,
+	cfgRaftRecvBufSize:                  config.TypeInt,
+	cfgElectionTick:                     config.TypeFloat,
+	cfgWalArchiveDir:                    config.TypeString,
+	SecretKey:                           config.TypeString,
+	cfgPeers:                            config.TypeString,
+	cfgWitnesses:                        config.TypeString,
+	missingDataPartitionInterval:        config.TypeString,
+	dataPartitionTimeOutSec:             config.TypeString,
+	NumberOfDataPartitionsToLoad:        config.TypeString,
+	secondsToFreeDataPartitionAfterLoad: config.TypeString,
+	nodeSetCapacity:                     config.TypeString,
+	cfgMetaNodeReservedMem:              config.TypeString,
+	heartbeatPortKey:                    config.TypeInt,
+	replicaPortKey:                      config.TypeInt,
+	faultDomain:                         config.TypeBool,
+	cfgDomainBatchGrpCnt:                config.TypeString,
+	cfgDomainBuildAsPossible:            config.TypeBool,
+	cfgMetaLeaderBalanceInterval:        config.TypeString,
+	cfgAutoDrainDegradedDisk:            config.TypeBool,
+	cfgAutoRepairBadDisk:                config.TypeBool,
+	cfgEncryptKeyFile:                   config.TypeString,
+	cfgBackupS3Endpoint:                 config.TypeString,
+	cfgBackupS3Region:                   config.TypeString,
+	cfgBackupS3Bucket:                   config.TypeString,
+	cfgBackupS3AccessKey:                config.TypeString,
+	cfgBackupS3SecretKey:                config.TypeString,
+	cfgBackupS3Prefix:                   config.TypeString,
+	cfgECColdDays:                       config.TypeInt,
+	cfgBlobStoreAddr:                    config.TypeString,
+	cfgColdTierDays:                     config.TypeInt,
+	cfgAuditTargets:                     config.TypeSlice,
+})
+
 var (
 	// regexps for data validation
 	volNameRegexp = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_.-]{1,61}[a-zA-Z0-9]$")
@@ -70,6 +116,7 @@ type Server struct {
 	walDir          string
 	storeDir        string
 	retainLogs      uint64
+	walArchiveDir   string // directory the truncated raft WAL files are archived into
 	tickInterval    int
 	raftRecvBufSize int
 	electionTick    int
@@ -85,6 +132,7 @@ type Server struct {
 	reverseProxy    *httputil.ReverseProxy
 	metaReady       bool
 	apiServer       *http.Server
+	auditLogger     *audit.Logger
 }
 
 // NewServer creates a new server
@@ -111,6 +159,13 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 		log.LogError(errors.Stack(err))
 		return
 	}
+	var auditTargets []*audit.SinkConfig
+	if auditTargets, err = audit.ParseSinkConfigs(cfg.GetSlice(cfgAuditTargets)); err != nil {
+		return fmt.Errorf("action[Start] failed %v, err: %v", proto.ErrInvalidCfg, err)
+	}
+	if m.auditLogger, err = audit.NewLogger(ModuleName, auditTargets); err != nil {
+		return fmt.Errorf("action[Start] failed %v, err: %v", proto.ErrInvalidCfg, err)
+	}
 
 	// 生成rocksDB对象
 	if m.rocksDBStore, err = raftstore.NewRocksDBStore(m.storeDir, LRUCacheSize, WriteBufferSize); err != nil {
@@ -129,10 +184,18 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 	m.initUser()
 	m.cluster.partition = m.partition
 	m.cluster.idAlloc.partition = m.partition
+	var kmsProvider kms.Provider
+	if kmsProvider, err = kms.NewProvider(cfg); err != nil {
+		return fmt.Errorf("action[Start] failed %v, err: KMS: %v", proto.ErrInvalidCfg, err)
+	}
 	MasterSecretKey := cfg.GetString(SecretKey)
-	if m.cluster.MasterSecretKey, err = cryptoutil.Base64Decode(MasterSecretKey); err != nil {
+	if m.cluster.MasterSecretKey, err = kms.DecodeKey(kmsProvider, MasterSecretKey); err != nil {
 		return fmt.Errorf("action[Start] failed %v, err: master service Key invalid = %s", proto.ErrInvalidCfg, MasterSecretKey)
 	}
+	m.cluster.serviceKeys.setConfigKey(m.cluster.MasterSecretKey)
+	if m.cluster.encryptKeys, err = newEncryptKeyManager(cfg.GetString(cfgEncryptKeyFile), kmsProvider); err != nil {
+		return fmt.Errorf("action[Start] failed %v, err: load encrypt keys: %v", proto.ErrInvalidCfg, err)
+	}
 	// 这里主要是开启一些定时任务，可以找开发咨询下有哪些定时任务，要一些主要的定时任务，讲解时大概说一下即可
 	m.cluster.scheduleTask()
 	// 启动对外提供api服务，方便进行管理和请求数据
@@ -155,6 +218,7 @@ func (m *Server) Shutdown() {
 			log.LogErrorf("action[Shutdown] failed, err: %v", err)
 		}
 	}
+	m.auditLogger.Stop()
 	m.wg.Done()
 }
 
@@ -193,6 +257,9 @@ func (m *Server) checkConfig(cfg *config.Config) (err error) {
 	if err = m.config.parsePeers(peerAddrs); err != nil {
 		return
 	}
+	if err = m.config.parseWitnesses(cfg.GetString(cfgWitnesses)); err != nil {
+		return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+	}
 	nodeSetCapacity := cfg.GetString(nodeSetCapacity)
 	if nodeSetCapacity != "" {
 		if m.config.nodeSetCapacity, err = strconv.Atoi(nodeSetCapacity); err != nil {
@@ -204,6 +271,33 @@ func (m *Server) checkConfig(cfg *config.Config) (err error) {
 	}
 
 	m.config.DomainBuildAsPossible = cfg.GetBoolWithDefault(cfgDomainBuildAsPossible, false)
+	m.config.AutoDrainDegradedDisk = cfg.GetBoolWithDefault(cfgAutoDrainDegradedDisk, false)
+	m.config.AutoRepairBadDisk = cfg.GetBoolWithDefault(cfgAutoRepairBadDisk, true)
+	if endpoint := cfg.GetString(cfgBackupS3Endpoint); endpoint != "" {
+		m.config.BackupStore = &proto.BackupStore{
+			Endpoint:  endpoint,
+			Region:    cfg.GetString(cfgBackupS3Region),
+			Bucket:    cfg.GetString(cfgBackupS3Bucket),
+			AccessKey: cfg.GetString(cfgBackupS3AccessKey),
+			SecretKey: cfg.GetString(cfgBackupS3SecretKey),
+		}
+		if m.config.BackupStore.Bucket == "" {
+			return fmt.Errorf("%v is required with %v", cfgBackupS3Bucket, cfgBackupS3Endpoint)
+		}
+		m.config.BackupPrefix = strings.Trim(cfg.GetString(cfgBackupS3Prefix), "/")
+	}
+	if m.config.ECColdDays = cfg.GetInt64(cfgECColdDays); m.config.ECColdDays < 0 {
+		return fmt.Errorf("%v,err:%v must not be negative", proto.ErrInvalidCfg, cfgECColdDays)
+	}
+	if addrs := cfg.GetString(cfgBlobStoreAddr); addrs != "" {
+		m.config.BlobStoreAddrs = strings.Split(addrs, ",")
+	}
+	if m.config.ColdTierDays = cfg.GetInt64(cfgColdTierDays); m.config.ColdTierDays < 0 {
+		return fmt.Errorf("%v,err:%v must not be negative", proto.ErrInvalidCfg, cfgColdTierDays)
+	}
+	if m.config.ColdTierDays > 0 && len(m.config.BlobStoreAddrs) == 0 {
+		return fmt.Errorf("%v is required with %v", cfgBlobStoreAddr, cfgColdTierDays)
+	}
 	m.config.DomainNodeGrpBatchCnt = defaultNodeSetGrpBatchCnt
 	domainBatchGrpCnt := cfg.GetString(cfgDomainBatchGrpCnt)
 	if domainBatchGrpCnt != "" {
@@ -265,9 +359,15 @@ func (m *Server) checkConfig(cfg *config.Config) (err error) {
 			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
 		}
 	}
+	if interval := cfg.GetString(cfgMetaLeaderBalanceInterval); interval != "" {
+		if m.config.MetaLeaderBalanceInterval, err = strconv.ParseInt(interval, 10, 64); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
 	m.tickInterval = int(cfg.GetFloat(cfgTickInterval))
 	m.raftRecvBufSize = int(cfg.GetInt(cfgRaftRecvBufSize))
 	m.electionTick = int(cfg.GetFloat(cfgElectionTick))
+	m.walArchiveDir = cfg.GetString(cfgWalArchiveDir)
 	if m.tickInterval <= 300 {
 		m.tickInterval = 500
 	}
@@ -288,6 +388,9 @@ func (m *Server) createRaftServer() (err error) {
 		ElectionTick:      m.electionTick,
 		RecvBufSize:       m.raftRecvBufSize,
 	}
+	if m.walArchiveDir != "" {
+		raftCfg.WalArchiver = raftstore.DirWalArchiver(m.walArchiveDir)
+	}
 	if m.raftStore, err = raftstore.NewRaftStore(raftCfg); err != nil {
 		return errors.Trace(err, "NewRaftStore failed! id[%v] walPath[%v]", m.id, m.walDir)
 	}
@@ -295,10 +398,12 @@ func (m *Server) createRaftServer() (err error) {
 	// 这里开始初始化metaDataFsm对象，会把rocksDB对象、raftserver对象、log日志等赋值给metadatafsm对象
 	m.initFsm()
 	partitionCfg := &raftstore.PartitionConfig{
-		ID:      GroupID,
-		Peers:   m.config.peers,
-		Applied: m.fsm.applied,
-		SM:      m.fsm,
+		ID:         GroupID,
+		Peers:      m.config.peers,
+		Witnesses:  m.config.witnesses,
+		Applied:    m.fsm.applied,
+		SM:         m.fsm,
+		WalArchive: true,
 	}
 	if m.partition, err = m.raftStore.CreatePartition(partitionCfg); err != nil {
 		return errors.Trace(err, "CreatePartition failed")
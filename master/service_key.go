@@ -0,0 +1,189 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// serviceKey is a version of the master service key, by which the authnode encrypts the tickets to the master.
+type serviceKey struct {
+	ID      uint32
+	Key     []byte `json:",omitempty"`
+	Retired bool
+}
+
+// serviceKeyView is the version of the master service key in the reply of the admin API, without the key.
+type serviceKeyView struct {
+	ID      uint32
+	Retired bool
+}
+
+// serviceKeyManager holds the versions of the master service key accepted by the master, so that the key is
+// rotated without invalidating the tickets encrypted with the previous version at once.
+// The version 0 is the masterServiceKey of the config, and the other versions are added by the admin API and
+// persisted by raft. A new version is added before the authnode is rotated to it, and the previous version is
+// retired once the tickets encrypted with it have expired.
+type serviceKeyManager struct {
+	sync.RWMutex
+	configKey []byte
+	keys      map[uint32]*serviceKey
+}
+
+func newServiceKeyManager() *serviceKeyManager {
+	return &serviceKeyManager{keys: make(map[uint32]*serviceKey)}
+}
+
+func (m *serviceKeyManager) setConfigKey(key []byte) {
+	m.Lock()
+	defer m.Unlock()
+	m.configKey = key
+}
+
+func (m *serviceKeyManager) clear() {
+	m.Lock()
+	defer m.Unlock()
+	m.keys = make(map[uint32]*serviceKey)
+}
+
+func (m *serviceKeyManager) put(sk *serviceKey) {
+	m.Lock()
+	defer m.Unlock()
+	m.keys[sk.ID] = sk
+}
+
+// getKey returns the key of the version, which fails if the version is unknown or retired.
+func (m *serviceKeyManager) getKey(id uint32) (key []byte, err error) {
+	m.RLock()
+	defer m.RUnlock()
+	sk := m.keys[id]
+	if sk != nil && sk.Retired {
+		return nil, fmt.Errorf("master service key(%v) is retired", id)
+	}
+	if id == 0 {
+		key = m.configKey
+	} else if sk != nil {
+		key = sk.Key
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("master service key(%v) is unknown", id)
+	}
+	return
+}
+
+func (m *serviceKeyManager) list() (views []*serviceKeyView) {
+	m.RLock()
+	defer m.RUnlock()
+	if _, ok := m.keys[0]; !ok && len(m.configKey) > 0 {
+		views = append(views, &serviceKeyView{ID: 0})
+	}
+	for _, sk := range m.keys {
+		views = append(views, &serviceKeyView{ID: sk.ID, Retired: sk.Retired})
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].ID < views[j].ID })
+	return
+}
+
+func (m *serviceKeyManager) activeCount() (count int) {
+	for _, view := range m.list() {
+		if !view.Retired {
+			count++
+		}
+	}
+	return
+}
+
+// key=#servicekey#id
+func (c *Cluster) syncPutServiceKey(sk *serviceKey) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutServiceKey
+	metadata.K = serviceKeyPrefix + strconv.FormatUint(uint64(sk.ID), 10)
+	if metadata.V, err = json.Marshal(sk); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadServiceKeys() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(serviceKeyPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadServiceKeys],err:%v", err.Error())
+		return
+	}
+	for _, value := range result {
+		sk := &serviceKey{}
+		if err = json.Unmarshal(value, sk); err != nil {
+			err = fmt.Errorf("action[loadServiceKeys],value:%v,unmarshal err:%v", string(value), err)
+			return
+		}
+		c.serviceKeys.put(sk)
+		log.LogInfof("action[loadServiceKeys],id[%v] retired[%v]", sk.ID, sk.Retired)
+	}
+	return
+}
+
+// addServiceKey adds a version of the master service key, which is accepted once added.
+// The versions are never reused, so that a ticket is not decrypted with a key other than the one encrypting it.
+func (c *Cluster) addServiceKey(id uint32, key []byte) (err error) {
+	c.serviceKeysMutex.Lock()
+	defer c.serviceKeysMutex.Unlock()
+	if id == 0 {
+		return fmt.Errorf("master service key(0) is the %v of the config", SecretKey)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("invalid master service key size(%v), should be 16, 24 or 32", len(key))
+	}
+	for _, view := range c.serviceKeys.list() {
+		if view.ID == id {
+			return fmt.Errorf("master service key(%v) already exists", id)
+		}
+	}
+	sk := &serviceKey{ID: id, Key: key}
+	if err = c.syncPutServiceKey(sk); err != nil {
+		return proto.ErrPersistenceByRaft
+	}
+	c.serviceKeys.put(sk)
+	log.LogInfof("action[addServiceKey] master service key(%v) added", id)
+	return
+}
+
+// retireServiceKey retires a version of the master service key, after which the tickets encrypted with it are refused.
+// The last active version is not retired, otherwise all the tickets are refused.
+func (c *Cluster) retireServiceKey(id uint32) (err error) {
+	c.serviceKeysMutex.Lock()
+	defer c.serviceKeysMutex.Unlock()
+	if _, err = c.serviceKeys.getKey(id); err != nil {
+		return
+	}
+	if c.serviceKeys.activeCount() <= 1 {
+		return fmt.Errorf("master service key(%v) is the last active one", id)
+	}
+	sk := &serviceKey{ID: id, Retired: true}
+	if err = c.syncPutServiceKey(sk); err != nil {
+		return proto.ErrPersistenceByRaft
+	}
+	c.serviceKeys.put(sk)
+	log.LogInfof("action[retireServiceKey] master service key(%v) retired", id)
+	return
+}
@@ -0,0 +1,108 @@
+package master
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/cryptoutil"
+)
+
+func TestServiceKeyManager(t *testing.T) {
+	m := newServiceKeyManager()
+	if _, err := m.getKey(0); err == nil {
+		t.Errorf("get the key of the config not set: expect an error")
+	}
+	m.setConfigKey([]byte("config-key-16byt"))
+	if key, err := m.getKey(0); err != nil || string(key) != "config-key-16byt" {
+		t.Errorf("unexpected key(%s) of the config, err(%v)", key, err)
+	}
+	m.put(&serviceKey{ID: 2, Key: []byte("version-2-16byte")})
+	m.put(&serviceKey{ID: 1, Key: []byte("version-1-16byte")})
+	if key, err := m.getKey(2); err != nil || string(key) != "version-2-16byte" {
+		t.Errorf("unexpected key(%s) of version 2, err(%v)", key, err)
+	}
+	if _, err := m.getKey(3); err == nil {
+		t.Errorf("get the key of an unknown version: expect an error")
+	}
+	if count := m.activeCount(); count != 3 {
+		t.Errorf("unexpected active count %v", count)
+	}
+
+	m.put(&serviceKey{ID: 0, Retired: true})
+	if _, err := m.getKey(0); err == nil {
+		t.Errorf("get the retired key of the config: expect an error")
+	}
+	views := m.list()
+	if len(views) != 3 || views[0].ID != 0 || !views[0].Retired || views[1].ID != 1 || views[2].ID != 2 {
+		t.Errorf("unexpected views %v", views)
+	}
+	if count := m.activeCount(); count != 2 {
+		t.Errorf("unexpected active count %v", count)
+	}
+
+	m.clear()
+	if views = m.list(); len(views) != 1 || views[0].ID != 0 || views[0].Retired {
+		t.Errorf("unexpected views after clear %v", views)
+	}
+}
+
+func TestCheckAdminTicket(t *testing.T) {
+	keys := newServiceKeyManager()
+	keys.setConfigKey([]byte("config-key-16byt"))
+	keys.put(&serviceKey{ID: 1, Key: []byte("version-1-16byte")})
+	sessionKey := []byte("session-key-16by")
+
+	newReq := func(keyID uint32, msg proto.MsgType, caps string) *proto.APIAccessReq {
+		ticket := cryptoutil.Ticket{
+			ServiceID:  proto.MasterServiceID,
+			SessionKey: cryptoutil.CryptoKey{Key: sessionKey},
+			Exp:        time.Now().Unix() + 60,
+			Caps:       []byte(caps),
+		}
+		data, err := json.Marshal(ticket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serviceKey, err := keys.getKey(keyID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, err := cryptoutil.EncodeMessage(data, serviceKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &proto.APIAccessReq{
+			Type:      msg,
+			ClientID:  "admin",
+			ServiceID: proto.MasterServiceID,
+			Ticket:    proto.EncodeTicketKeyID(keyID, encrypted),
+		}
+		if req.Verifier, _, err = cryptoutil.GenVerifier(sessionKey); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newReq(1, proto.MsgMasterAddServiceKeyReq, `{"API":["master:addservicekey:access"]}`)
+	ticket, err := checkAdminTicket(req, keys, proto.MsgMasterAddServiceKeyReq)
+	if err != nil || string(ticket.SessionKey.Key) != string(sessionKey) {
+		t.Errorf("check the ticket of the admin: %v", err)
+	}
+	if _, err = checkAdminTicket(req, keys, proto.MsgMasterRetireServiceKeyReq); err == nil {
+		t.Errorf("check the ticket for another request type: expect an error")
+	}
+	req = newReq(0, proto.MsgMasterRetireServiceKeyReq, `{"API":["master:addservicekey:access"]}`)
+	if _, err = checkAdminTicket(req, keys, proto.MsgMasterRetireServiceKeyReq); err == nil {
+		t.Errorf("check the ticket without the caps of the api: expect an error")
+	}
+	req = newReq(0, proto.MsgMasterRetireServiceKeyReq, `{"API":["*:*:*"]}`)
+	if _, err = checkAdminTicket(req, keys, proto.MsgMasterRetireServiceKeyReq); err != nil {
+		t.Errorf("check the ticket with all the caps: %v", err)
+	}
+	req.Ticket = proto.EncodeTicketKeyID(2, req.Ticket)
+	if _, err = checkAdminTicket(req, keys, proto.MsgMasterRetireServiceKeyReq); err == nil {
+		t.Errorf("check the ticket of an unknown key version: expect an error")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const (
+	// the operations of the volumes are counted in the samples of a minute
+	sloSampleInterval = time.Minute
+	// the windows of the burn rates in the samples
+	sloShortWindow = 5
+	sloLongWindow  = 60
+	// an objective is at risk if it burns its budget of a month in about two days
+	sloBurnRateThreshold = 14.4
+	// the operations within the short window at least to judge the objectives
+	sloMinOps = 100
+)
+
+type sloSample struct {
+	minute  int64 // unix minutes, 0 if not used
+	ops     uint64
+	errors  uint64
+	latency []uint64
+}
+
+type volSLOSamples struct {
+	samples [sloLongWindow]sloSample
+	atRisk  bool
+}
+
+// sloManager aggregates the operations of the volumes reported by the clients and the nodes in the samples
+// of the last hour, and judges the objectives of the volumes by their burn rates. The samples are kept in
+// the memory of the leader, which are filled again by the reports in an hour after the leader changes.
+type sloManager struct {
+	sync.RWMutex
+	vols map[string]*volSLOSamples
+}
+
+func newSLOManager() *sloManager {
+	return &sloManager{vols: make(map[string]*volSLOSamples)}
+}
+
+func (m *sloManager) clear() {
+	m.Lock()
+	defer m.Unlock()
+	m.vols = make(map[string]*volSLOSamples)
+}
+
+func (m *sloManager) remove(volName string) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.vols, volName)
+}
+
+// report adds the operations reported to the samples of the current minute.
+func (m *sloManager) report(reports []*proto.SLOReport, now time.Time) {
+	if len(reports) == 0 {
+		return
+	}
+	minute := now.Unix() / int64(sloSampleInterval/time.Second)
+	m.Lock()
+	defer m.Unlock()
+	for _, report := range reports {
+		if report == nil || report.VolName == "" || report.Ops == 0 {
+			continue
+		}
+		vs, ok := m.vols[report.VolName]
+		if !ok {
+			vs = &volSLOSamples{}
+			m.vols[report.VolName] = vs
+		}
+		sample := &vs.samples[minute%sloLongWindow]
+		if sample.minute != minute {
+			*sample = sloSample{minute: minute, latency: make([]uint64, len(proto.SLOLatencyBoundsMs)+1)}
+		}
+		sample.ops += report.Ops
+		sample.errors += report.Errors
+		for i, count := range report.Latency {
+			if i < len(sample.latency) {
+				sample.latency[i] += count
+			}
+		}
+	}
+}
+
+// sum returns the operations, the failed and the slow ones in the samples of the last minutes, the
+// operations of the current minute are excluded since they are still being reported.
+func (vs *volSLOSamples) sum(window int, now time.Time, slowBucket int) (ops, errors, slow uint64) {
+	current := now.Unix() / int64(sloSampleInterval/time.Second)
+	for i := range vs.samples {
+		sample := &vs.samples[i]
+		if sample.minute >= current || sample.minute < current-int64(window) {
+			continue
+		}
+		ops += sample.ops
+		errors += sample.errors
+		for j := slowBucket + 1; j < len(sample.latency); j++ {
+			slow += sample.latency[j]
+		}
+	}
+	return
+}
+
+func burnRate(bad, ops uint64, budget float64) float64 {
+	if ops == 0 || budget <= 0 {
+		return 0
+	}
+	return float64(bad) / float64(ops) / budget
+}
+
+// status judges the objectives of the volume by the samples of the windows.
+func (m *sloManager) status(volName string, slo *proto.VolSLO, now time.Time) (status *proto.SLOStatus) {
+	status = &proto.SLOStatus{VolName: volName, SLO: slo, UpdateTime: now.Unix()}
+	m.RLock()
+	defer m.RUnlock()
+	vs, ok := m.vols[volName]
+	if !ok || slo == nil {
+		return
+	}
+	slowBucket := proto.SLOLatencyBucket(time.Duration(slo.LatencyMs) * time.Millisecond)
+	shortOps, shortErrors, shortSlow := vs.sum(sloShortWindow, now, slowBucket)
+	status.Ops, status.Errors, status.SlowOps = vs.sum(sloLongWindow, now, slowBucket)
+	if slo.ErrorRateTarget > 0 {
+		status.ShortErrorBurnRate = burnRate(shortErrors, shortOps, slo.ErrorRateTarget)
+		status.LongErrorBurnRate = burnRate(status.Errors, status.Ops, slo.ErrorRateTarget)
+	}
+	if slo.LatencyMs > 0 {
+		status.ShortLatencyBurnRate = burnRate(shortSlow, shortOps, 1-slo.LatencyTarget)
+		status.LongLatencyBurnRate = burnRate(status.SlowOps, status.Ops, 1-slo.LatencyTarget)
+	}
+	if shortOps < sloMinOps {
+		return
+	}
+	if status.ShortErrorBurnRate >= sloBurnRateThreshold && status.LongErrorBurnRate >= sloBurnRateThreshold {
+		status.AtRisk = true
+		status.Reason = fmt.Sprintf("error rate burns budget %.1fx in 5m and %.1fx in 1h",
+			status.ShortErrorBurnRate, status.LongErrorBurnRate)
+	}
+	if status.ShortLatencyBurnRate >= sloBurnRateThreshold && status.LongLatencyBurnRate >= sloBurnRateThreshold {
+		if status.AtRisk {
+			status.Reason += ", "
+		}
+		status.AtRisk = true
+		status.Reason += fmt.Sprintf("latency over %vms burns budget %.1fx in 5m and %.1fx in 1h",
+			slo.LatencyMs, status.ShortLatencyBurnRate, status.LongLatencyBurnRate)
+	}
+	return
+}
+
+// setAtRisk records if the objectives of the volume are at risk, and returns if it is changed.
+func (m *sloManager) setAtRisk(volName string, atRisk bool) (changed bool) {
+	m.Lock()
+	defer m.Unlock()
+	vs, ok := m.vols[volName]
+	if !ok || vs.atRisk == atRisk {
+		return false
+	}
+	vs.atRisk = atRisk
+	return true
+}
+
+// getSLOStatuses judges the objectives of the volumes with the objectives, or the volume of the name.
+func (c *Cluster) getSLOStatuses(volName string, now time.Time) (statuses []*proto.SLOStatus, err error) {
+	if volName != "" {
+		var vol *Vol
+		if vol, err = c.getVol(volName); err != nil {
+			return nil, proto.ErrVolNotExists
+		}
+		return []*proto.SLOStatus{c.slos.status(vol.Name, vol.slo, now)}, nil
+	}
+	statuses = make([]*proto.SLOStatus, 0)
+	for name, vol := range c.allVols() {
+		if vol.slo != nil {
+			statuses = append(statuses, c.slos.status(name, vol.slo, now))
+		}
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package master
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestSLOStatus(t *testing.T) {
+	slo := &proto.VolSLO{LatencyMs: 10, LatencyTarget: 0.99, ErrorRateTarget: 0.001}
+	m := newSLOManager()
+	now := time.Unix(1700000000, 0)
+	// 1000 operations a minute of the last hour, 1 failed and 5 slower than 10ms
+	for i := 1; i <= sloLongWindow; i++ {
+		latency := make([]uint64, len(proto.SLOLatencyBoundsMs)+1)
+		latency[proto.SLOLatencyBucket(5*time.Millisecond)] = 995
+		latency[proto.SLOLatencyBucket(50*time.Millisecond)] = 5
+		m.report([]*proto.SLOReport{{VolName: "v", Ops: 1000, Errors: 1, Latency: latency}}, now.Add(-time.Duration(i)*time.Minute))
+	}
+	status := m.status("v", slo, now)
+	if status.Ops != 60000 || status.Errors != 60 || status.SlowOps != 300 || status.AtRisk {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status.LongErrorBurnRate < 0.99 || status.LongErrorBurnRate > 1.01 {
+		t.Errorf("unexpected error burn rate %v", status.LongErrorBurnRate)
+	}
+
+	// all operations of the last 5 minutes fail
+	for i := 1; i <= sloShortWindow; i++ {
+		m.report([]*proto.SLOReport{{VolName: "v", Ops: 1000, Errors: 1000}}, now.Add(-time.Duration(i)*time.Minute))
+	}
+	if status = m.status("v", slo, now); !status.AtRisk || status.ShortLatencyBurnRate >= sloBurnRateThreshold {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if !m.setAtRisk("v", true) || m.setAtRisk("v", true) {
+		t.Errorf("at risk not changed once")
+	}
+	// the samples expire after the long window
+	if status = m.status("v", slo, now.Add(2*time.Hour)); status.Ops != 0 || status.AtRisk {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestSetVolSLO(t *testing.T) {
+	vol, err := server.cluster.getVol(commonVolName)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	reqURL := fmt.Sprintf("%v%v?name=%v&authKey=%v&sloLatencyMs=20&sloLatencyTarget=0.99&sloErrorRateTarget=0.001",
+		hostAddr, proto.AdminUpdateVol, commonVolName, buildAuthKey(vol.Owner))
+	process(reqURL, t)
+	if vol.slo == nil || vol.slo.LatencyMs != 20 || vol.slo.ErrorRateTarget != 0.001 {
+		t.Errorf("unexpected slo %+v", vol.slo)
+		return
+	}
+	process(fmt.Sprintf("%v%v?name=%v", hostAddr, proto.AdminSLOStatus, commonVolName), t)
+	reqURL = fmt.Sprintf("%v%v?name=%v&authKey=%v&sloLatencyMs=0&sloErrorRateTarget=0",
+		hostAddr, proto.AdminUpdateVol, commonVolName, buildAuthKey(vol.Owner))
+	process(reqURL, t)
+	if vol.slo != nil {
+		t.Errorf("slo not cleared %+v", vol.slo)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const (
+	versionRoleMaster   = "master"
+	versionRoleMetaNode = "metanode"
+	versionRoleDataNode = "datanode"
+)
+
+// versionCheckView returns the versions of the leader master and the registered nodes against the compatibility matrix.
+func (c *Cluster) versionCheckView() *proto.VersionCheckView {
+	nodes := []*proto.NodeVersion{{Addr: c.masterAddr(), Role: versionRoleMaster, Version: proto.BuildVersion()}}
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		metaNode := node.(*MetaNode)
+		metaNode.RLock()
+		nodes = append(nodes, &proto.NodeVersion{Addr: metaNode.Addr, Role: versionRoleMetaNode, Version: metaNode.Version})
+		metaNode.RUnlock()
+		return true
+	})
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		nodes = append(nodes, &proto.NodeVersion{Addr: dataNode.Addr, Role: versionRoleDataNode, Version: dataNode.Version})
+		dataNode.RUnlock()
+		return true
+	})
+	return checkVersions(nodes)
+}
+
+// checkVersions checks every pair of the versions of the nodes, the first of which is the leader master. A node is
+// incompatible if its version does not work with any other version, and its version is unknown if it cannot be
+// parsed or the node has not reported it yet.
+func checkVersions(nodes []*proto.NodeVersion) (view *proto.VersionCheckView) {
+	view = &proto.VersionCheckView{Versions: make(map[string]int), Nodes: nodes}
+	if len(nodes) > 0 {
+		view.MasterVersion = nodes[0].Version
+	}
+	versions := make([]string, 0)
+	for _, node := range nodes {
+		if _, ok := view.Versions[node.Version]; !ok {
+			versions = append(versions, node.Version)
+		}
+		view.Versions[node.Version]++
+	}
+	sort.Strings(versions)
+
+	reasons := make(map[string]string)
+	incompatible := make(map[string]bool)
+	for _, version := range versions {
+		if _, _, err := proto.ParseVersionLine(version); err != nil {
+			reasons[version] = err.Error()
+		}
+	}
+	for i, version := range versions {
+		for _, peer := range versions[i+1:] {
+			compatible, reason, err := proto.CheckVersionCompatible(version, peer)
+			if err != nil || compatible {
+				continue
+			}
+			view.Conflicts = append(view.Conflicts, &proto.VersionConflict{Version: version, PeerVersion: peer, Reason: reason})
+			incompatible[version], incompatible[peer] = true, true
+			if _, ok := reasons[version]; !ok {
+				reasons[version] = fmt.Sprintf("incompatible with %v: %v", peer, reason)
+			}
+			if _, ok := reasons[peer]; !ok {
+				reasons[peer] = fmt.Sprintf("incompatible with %v: %v", version, reason)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		node.Compatible = !incompatible[node.Version]
+		node.Reason = reasons[node.Version]
+	}
+	return
+}
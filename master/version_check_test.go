@@ -0,0 +1,59 @@
+package master
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestCheckVersions(t *testing.T) {
+	nodes := []*proto.NodeVersion{
+		{Addr: "m1", Role: versionRoleMaster, Version: "v2.3.0-abc"},
+		{Addr: "mn1", Role: versionRoleMetaNode, Version: "v2.2.1-def"},
+		{Addr: "dn1", Role: versionRoleDataNode, Version: "v2.3.0-abc"},
+		{Addr: "dn2", Role: versionRoleDataNode, Version: ""},
+	}
+	view := checkVersions(nodes)
+	if len(view.Conflicts) != 0 || view.MasterVersion != "v2.3.0-abc" || view.Versions["v2.3.0-abc"] != 2 {
+		t.Errorf("unexpected view %v %v", view.Conflicts, view.Versions)
+	}
+	for _, node := range view.Nodes {
+		if !node.Compatible {
+			t.Errorf("node %v should be compatible: %v", node.Addr, node.Reason)
+		}
+	}
+	if view.Nodes[3].Reason == "" {
+		t.Errorf("unknown version should be reported")
+	}
+
+	nodes = append(nodes, &proto.NodeVersion{Addr: "mn2", Role: versionRoleMetaNode, Version: "v1.5.0"})
+	view = checkVersions(nodes)
+	if len(view.Conflicts) != 2 {
+		t.Errorf("expect 2 conflicts but %v", len(view.Conflicts))
+	}
+	// every known version is incompatible with v1.5.0
+	for _, node := range view.Nodes {
+		if node.Compatible != (node.Version == "") {
+			t.Errorf("node %v compatible(%v) reason(%v)", node.Addr, node.Compatible, node.Reason)
+		}
+	}
+}
+
+func TestVersionMatrix(t *testing.T) {
+	defer func(matrix []proto.VersionRule) { proto.VersionMatrix = matrix }(proto.VersionMatrix)
+	proto.VersionMatrix = []proto.VersionRule{{Line: "2.4", MinPeerLine: "2.4", Reason: "new packet header"}}
+	if compatible, _, err := proto.CheckVersionCompatible("v2.3.1", "2.4.0"); err != nil || compatible {
+		t.Errorf("2.3 and 2.4 should be incompatible by the matrix: err(%v)", err)
+	}
+	if compatible, _, err := proto.CheckVersionCompatible("v2.4.1", "2.4.0-rc1"); err != nil || !compatible {
+		t.Errorf("2.4 should be compatible with itself: err(%v)", err)
+	}
+	if compatible, _, err := proto.CheckVersionCompatible("v2.1.0", "v2.3.0"); err != nil || compatible {
+		t.Errorf("2.1 and 2.3 should be incompatible by the skew: err(%v)", err)
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	process(fmt.Sprintf("%v%v", hostAddr, proto.AdminCheckVersion), t)
+}
@@ -17,6 +17,7 @@ package master
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -35,6 +36,13 @@ type VolVarargs struct {
 	authenticate   bool
 	dpSelectorName string
 	dpSelectorParm string
+	compression    string
+	writeLimit     uint64
+	objQuotaBytes  uint64
+	objQuotaCount  uint64
+	slo            *proto.VolSLO
+	flashCache     bool
+	maxInodes      uint64
 }
 
 // Vol represents a set of meta partitionMap and data partitionMap
@@ -56,6 +64,7 @@ type Vol struct {
 	crossZone          bool
 	domainOn           bool
 	defaultPriority    bool // old default zone first
+	caseInsensitive    bool
 	zoneName           string
 	MetaPartitions     map[uint64]*MetaPartition `graphql:"-"`
 	mpsLock            sync.RWMutex
@@ -68,13 +77,25 @@ type Vol struct {
 	description        string
 	dpSelectorName     string
 	dpSelectorParm     string
+	compression        string        // compression of the data stored by the data nodes, empty if not compressed
+	writeLimit         uint64        // write bytes per second of the volume on each data node, 0 for no limit
+	objQuotaBytes      uint64        // bytes of the objects allowed to be stored by the object nodes, 0 for no limit
+	objQuotaCount      uint64        // count of the objects allowed to be stored by the object nodes, 0 for no limit
+	slo                *proto.VolSLO // latency and error rate objectives of the volume, nil if not set
+	flashCache         bool          // the data read is cached by the flash nodes
+	maxInodes          uint64        // inodes allowed to be created in the volume, 0 for no limit
+	checksumType       uint8         // checksum type of the data, chosen when the volume is created
+	encrypted          bool
+	encryptKeyID       uint32 // id of the master key wrapping the data key
+	wrappedEncryptKey  []byte
+	dataKey            []byte // unwrapped data key, never persisted
 	volLock            sync.RWMutex
 }
 
 func newVol(id uint64, name, owner, zoneName string,
 	dpSize, capacity uint64, dpReplicaNum,
 	mpReplicaNum uint8, followerRead, authenticate,
-	crossZone bool, defaultPriority bool,
+	crossZone bool, defaultPriority bool, caseInsensitive bool,
 	createTime int64, description string) (vol *Vol) {
 	vol = &Vol{ID: id, Name: name, MetaPartitions: make(map[uint64]*MetaPartition, 0)}
 	vol.dataPartitions = newDataPartitionMap(name)
@@ -105,6 +126,7 @@ func newVol(id uint64, name, owner, zoneName string,
 	vol.createTime = createTime
 	vol.description = description
 	vol.defaultPriority = defaultPriority
+	vol.caseInsensitive = caseInsensitive
 	return
 }
 
@@ -122,6 +144,7 @@ func newVolFromVolValue(vv *volValue) (vol *Vol) {
 		vv.Authenticate,
 		vv.CrossZone,
 		vv.DefaultPriority,
+		vv.CaseInsensitive,
 		vv.CreateTime,
 		vv.Description)
 	// overwrite oss secure
@@ -129,6 +152,17 @@ func newVolFromVolValue(vv *volValue) (vol *Vol) {
 	vol.Status = vv.Status
 	vol.dpSelectorName = vv.DpSelectorName
 	vol.dpSelectorParm = vv.DpSelectorParm
+	vol.compression = vv.Compression
+	vol.writeLimit = vv.WriteLimit
+	vol.objQuotaBytes = vv.ObjQuotaBytes
+	vol.objQuotaCount = vv.ObjQuotaCount
+	vol.slo = vv.SLO
+	vol.flashCache = vv.FlashCache
+	vol.maxInodes = vv.MaxInodes
+	vol.checksumType = vv.ChecksumType
+	vol.encrypted = vv.Encrypted
+	vol.encryptKeyID = vv.EncryptKeyID
+	vol.wrappedEncryptKey = vv.WrappedEncryptKey
 	return vol
 }
 
@@ -392,12 +426,11 @@ func (vol *Vol) checkAutoDataPartitionCreation(c *Cluster) {
 	if vol.status() == markDelete {
 		return
 	}
-	if vol.capacity() == 0 {
+	if c.quotas.bytesExceeded(vol.Name) {
+		vol.setAllDataPartitionsToReadOnly()
 		return
 	}
-	usedSpace := vol.totalUsedSpace() / util.GB
-	if usedSpace >= vol.capacity() {
-		vol.setAllDataPartitionsToReadOnly()
+	if vol.capacity() == 0 {
 		return
 	}
 	vol.setStatus(normal)
@@ -443,6 +476,29 @@ func (vol *Vol) setAllDataPartitionsToReadOnly() {
 	vol.dataPartitions.setAllDataPartitionsToReadOnly()
 }
 
+func (vol *Vol) quotaUsage() (usage *proto.VolQuotaUsage) {
+	usage = &proto.VolQuotaUsage{
+		Name:     vol.Name,
+		Quota:    vol.Capacity * util.GB,
+		UsedSize: vol.totalUsedSpace(),
+	}
+	vol.mpsLock.RLock()
+	for _, mp := range vol.MetaPartitions {
+		mp.RLock()
+		usage.FileSize += mp.Size
+		usage.InodeCount += mp.InodeCount
+		if usage.UpdateTime == 0 || mp.SizeReportTime < usage.UpdateTime {
+			usage.UpdateTime = mp.SizeReportTime
+		}
+		mp.RUnlock()
+	}
+	vol.mpsLock.RUnlock()
+	if usage.Quota > 0 {
+		usage.UsedRatio = strconv.FormatFloat(float64(usage.FileSize)/float64(usage.Quota), 'f', 2, 32)
+	}
+	return
+}
+
 func (vol *Vol) totalUsedSpace() uint64 {
 	return vol.dataPartitions.totalUsedSpace()
 }
@@ -635,6 +691,8 @@ func (vol *Vol) deleteVolFromStore(c *Cluster) (err error) {
 	// then delete the volume
 	c.deleteVol(vol.Name)
 	c.volStatInfo.Delete(vol.Name)
+	c.deleteVolSnapshots(vol.Name)
+	c.deleteDirQuotas(vol.Name)
 	return
 }
 
@@ -788,6 +846,7 @@ func (vol *Vol) doCreateMetaPartition(c *Cluster, start, end uint64) (mp *MetaPa
 		return nil, errors.NewError(err)
 	}
 	mp = newMetaPartition(partitionID, start, end, vol.mpReplicaNum, vol.Name, vol.ID)
+	mp.caseInsensitive = vol.caseInsensitive
 	mp.setHosts(hosts)
 	mp.setPeers(peers)
 	for _, host := range hosts {
@@ -845,5 +904,12 @@ func getVolVarargs(vol *Vol) *VolVarargs {
 		authenticate:   vol.authenticate,
 		dpSelectorName: vol.dpSelectorName,
 		dpSelectorParm: vol.dpSelectorParm,
+		compression:    vol.compression,
+		writeLimit:     vol.writeLimit,
+		objQuotaBytes:  vol.objQuotaBytes,
+		objQuotaCount:  vol.objQuotaCount,
+		slo:            vol.slo,
+		flashCache:     vol.flashCache,
+		maxInodes:      vol.maxInodes,
 	}
 }
@@ -0,0 +1,585 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	// the seconds the meta nodes and the data nodes fence the writes of a volume while its snapshot is
+	// taken or restored,
+	// the fence expires by itself if the master fails to lift it
+	volSnapshotFenceTTL = 30
+	// the fence is renewed at this interval until the snapshot is taken or restored
+	volSnapshotFenceRenewInterval = volSnapshotFenceTTL / 3 * time.Second
+	// the partitions are requested by at most this number of goroutines at the same time
+	volSnapshotConcurrency = 32
+)
+
+// volSnapshotManager keeps the catalog of the snapshots of the volumes, which is persisted by raft.
+// A snapshot of a volume is made of the subtree snapshots of the root taken by all its meta partitions
+// while the meta nodes and the data nodes fence the writes of the volume, so that it is consistent as
+// if the volume crashed.
+// The entries of the catalog are replaced as a whole and never modified.
+type volSnapshotManager struct {
+	sync.RWMutex
+	snapshots map[uint64]*proto.VolSnapshotInfo
+	running   map[string]bool // the volumes having a snapshot being created or restored
+}
+
+func newVolSnapshotManager() *volSnapshotManager {
+	return &volSnapshotManager{
+		snapshots: make(map[uint64]*proto.VolSnapshotInfo),
+		running:   make(map[string]bool),
+	}
+}
+
+func (m *volSnapshotManager) clear() {
+	m.Lock()
+	defer m.Unlock()
+	m.snapshots = make(map[uint64]*proto.VolSnapshotInfo)
+	m.running = make(map[string]bool)
+}
+
+func (m *volSnapshotManager) put(snap *proto.VolSnapshotInfo) {
+	m.Lock()
+	defer m.Unlock()
+	m.snapshots[snap.ID] = snap
+}
+
+func (m *volSnapshotManager) delete(id uint64) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.snapshots, id)
+}
+
+func (m *volSnapshotManager) get(id uint64) *proto.VolSnapshotInfo {
+	m.RLock()
+	defer m.RUnlock()
+	return m.snapshots[id]
+}
+
+// list returns the snapshots of the volume, or of all the volumes if volName is empty, sorted by the IDs.
+func (m *volSnapshotManager) list(volName string) (snaps []*proto.VolSnapshotInfo) {
+	m.RLock()
+	snaps = make([]*proto.VolSnapshotInfo, 0, len(m.snapshots))
+	for _, snap := range m.snapshots {
+		if volName == "" || snap.VolName == volName {
+			snaps = append(snaps, snap)
+		}
+	}
+	m.RUnlock()
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].ID < snaps[j].ID
+	})
+	return
+}
+
+// begin marks a snapshot of the volume being created or restored, which are not run at the same time.
+func (m *volSnapshotManager) begin(volName string) bool {
+	m.Lock()
+	defer m.Unlock()
+	if m.running[volName] {
+		return false
+	}
+	m.running[volName] = true
+	return true
+}
+
+func (m *volSnapshotManager) end(volName string) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.running, volName)
+}
+
+// key=#volsnapshot#vol#id
+func volSnapshotKey(volName string, id uint64) string {
+	return fmt.Sprintf("%v%v%v%v", volSnapshotPrefix, volName, keySeparator, id)
+}
+
+func (c *Cluster) syncPutVolSnapshot(snap *proto.VolSnapshotInfo) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutVolSnapshot
+	metadata.K = volSnapshotKey(snap.VolName, snap.ID)
+	if metadata.V, err = json.Marshal(snap); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteVolSnapshot(snap *proto.VolSnapshotInfo) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteVolSnapshot
+	metadata.K = volSnapshotKey(snap.VolName, snap.ID)
+	return c.submit(metadata)
+}
+
+// loadVolSnapshots loads the catalog, the snapshots left being created by the last leader are failed.
+func (c *Cluster) loadVolSnapshots() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(volSnapshotPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadVolSnapshots],err:%v", err.Error())
+		return
+	}
+	for _, value := range result {
+		snap := &proto.VolSnapshotInfo{}
+		if err = json.Unmarshal(value, snap); err != nil {
+			err = fmt.Errorf("action[loadVolSnapshots],value:%v,unmarshal err:%v", string(value), err)
+			return
+		}
+		if snap.Status == proto.VolSnapshotCreating {
+			snap.Status = proto.VolSnapshotFailed
+		}
+		c.volSnapshots.put(snap)
+		log.LogInfof("action[loadVolSnapshots],vol[%v] snapshot[%v] status[%v]", snap.VolName, snap.ID, snap.Status)
+	}
+	return
+}
+
+// createVolSnapshot takes a snapshot of the volume. The writes of the volume are fenced by all its meta
+// nodes and data nodes, then each meta partition takes a snapshot of its namespace and the applied IDs of the data
+// partitions are recorded. The partial snapshots are deleted if any partition fails.
+func (c *Cluster) createVolSnapshot(vol *Vol, name string) (snap *proto.VolSnapshotInfo, err error) {
+	if !c.volSnapshots.begin(vol.Name) {
+		return nil, fmt.Errorf("vol[%v] has a snapshot being created or restored", vol.Name)
+	}
+	defer c.volSnapshots.end(vol.Name)
+
+	now := time.Now()
+	snap = &proto.VolSnapshotInfo{
+		ID:         uint64(now.UnixNano()),
+		Name:       name,
+		VolName:    vol.Name,
+		Status:     proto.VolSnapshotCreating,
+		CreateTime: now.Unix(),
+	}
+	if err = c.syncPutVolSnapshot(snap); err != nil {
+		return nil, proto.ErrPersistenceByRaft
+	}
+	c.volSnapshots.put(snap)
+
+	created := *snap
+	if err = c.takeVolSnapshot(vol, &created); err != nil {
+		log.LogErrorf("action[createVolSnapshot] vol[%v] snapshot[%v] failed, err[%v]", vol.Name, snap.ID, err)
+		if e := c.dropVolSnapshot(vol, snap); e != nil {
+			log.LogErrorf("action[createVolSnapshot] vol[%v] snapshot[%v] drop failed, err[%v]", vol.Name, snap.ID, e)
+			// left to be deleted by the administrator
+			created.Status = proto.VolSnapshotFailed
+			if e = c.syncPutVolSnapshot(&created); e == nil {
+				c.volSnapshots.put(&created)
+			}
+		}
+		return nil, err
+	}
+	created.Status = proto.VolSnapshotAvailable
+	if err = c.syncPutVolSnapshot(&created); err != nil {
+		return nil, proto.ErrPersistenceByRaft
+	}
+	c.volSnapshots.put(&created)
+	log.LogInfof("action[createVolSnapshot] vol[%v] snapshot[%v] name[%v] mps[%v] dps[%v] fenced[%vms]",
+		vol.Name, created.ID, created.Name, len(created.MetaPartitions), len(created.DataPartitions), created.FenceMs)
+	return &created, nil
+}
+
+func (c *Cluster) takeVolSnapshot(vol *Vol, snap *proto.VolSnapshotInfo) (err error) {
+	mps := sortedMetaPartitions(vol)
+	dps := vol.cloneDataPartitionMap()
+	fence, err := c.holdVolWrites(vol.Name, mps, dps)
+	if err != nil {
+		return
+	}
+	defer func() {
+		fence.lift()
+		snap.FenceMs = int64(time.Since(fence.start) / time.Millisecond)
+	}()
+
+	snap.MetaPartitions = make([]*proto.VolSnapshotPartition, len(mps))
+	err = runConcurrently(len(mps), func(i int) (err error) {
+		req := &proto.CreateSnapshotRequest{
+			VolName:     vol.Name,
+			PartitionID: mps[i].PartitionID,
+			SnapshotID:  snap.ID,
+			Name:        snap.Name,
+			RootIno:     proto.RootIno,
+		}
+		packet, err := c.syncSendMetaPartitionPacket(mps[i], proto.OpMetaCreateSnapshot, req)
+		if err != nil {
+			return
+		}
+		info := &proto.SnapshotInfo{}
+		if err = json.Unmarshal(packet.Data, info); err != nil {
+			return fmt.Errorf("mp[%v] unmarshal snapshot err[%v]", mps[i].PartitionID, err)
+		}
+		snap.MetaPartitions[i] = &proto.VolSnapshotPartition{PartitionID: mps[i].PartitionID, ApplyID: info.ApplyID}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	// the data is not copied, the extents of the snapshot are kept by the meta partitions
+	snap.DataPartitions = make([]*proto.VolSnapshotPartition, 0, len(dps))
+	for _, dp := range dps {
+		snap.DataPartitions = append(snap.DataPartitions, &proto.VolSnapshotPartition{PartitionID: dp.PartitionID})
+	}
+	sort.Slice(snap.DataPartitions, func(i, j int) bool {
+		return snap.DataPartitions[i].PartitionID < snap.DataPartitions[j].PartitionID
+	})
+	err = runConcurrently(len(snap.DataPartitions), func(i int) (err error) {
+		dp := dps[snap.DataPartitions[i].PartitionID]
+		snap.DataPartitions[i].ApplyID, err = c.getDataPartitionAppliedID(dp)
+		return
+	})
+	if err != nil {
+		return
+	}
+	return fence.check()
+}
+
+// dropVolSnapshot deletes the snapshots taken by the meta partitions of the volume and the catalog entry.
+func (c *Cluster) dropVolSnapshot(vol *Vol, snap *proto.VolSnapshotInfo) (err error) {
+	mps := sortedMetaPartitions(vol)
+	err = runConcurrently(len(mps), func(i int) (err error) {
+		req := &proto.DeleteSnapshotRequest{
+			VolName:     vol.Name,
+			PartitionID: mps[i].PartitionID,
+			SnapshotID:  snap.ID,
+		}
+		packet, err := c.syncSendMetaPartitionPacket(mps[i], proto.OpMetaDeleteSnapshot, req)
+		if err != nil && packet != nil && packet.ResultCode == proto.OpNotExistErr {
+			err = nil
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	if err = c.syncDeleteVolSnapshot(snap); err != nil {
+		return proto.ErrPersistenceByRaft
+	}
+	c.volSnapshots.delete(snap.ID)
+	return
+}
+
+// deleteVolSnapshot deletes the snapshot of the volume, which releases the extents kept for it.
+func (c *Cluster) deleteVolSnapshot(id uint64) (err error) {
+	snap := c.volSnapshots.get(id)
+	if snap == nil {
+		return fmt.Errorf("vol snapshot[%v] not found", id)
+	}
+	vol, err := c.getVol(snap.VolName)
+	if err != nil {
+		return
+	}
+	if !c.volSnapshots.begin(vol.Name) {
+		return fmt.Errorf("vol[%v] has a snapshot being created or restored", vol.Name)
+	}
+	defer c.volSnapshots.end(vol.Name)
+	if err = c.dropVolSnapshot(vol, snap); err != nil {
+		return
+	}
+	log.LogInfof("action[deleteVolSnapshot] vol[%v] snapshot[%v] deleted", vol.Name, id)
+	return
+}
+
+// restoreVolSnapshot rolls the namespace of the volume back to the snapshot while the writes are fenced.
+// The clients of the volume are expected to remount, as their caches are not invalidated.
+func (c *Cluster) restoreVolSnapshot(id uint64) (snap *proto.VolSnapshotInfo, err error) {
+	if snap = c.volSnapshots.get(id); snap == nil {
+		return nil, fmt.Errorf("vol snapshot[%v] not found", id)
+	}
+	if snap.Status != proto.VolSnapshotAvailable {
+		return nil, fmt.Errorf("vol snapshot[%v] is %v", id, snap.Status)
+	}
+	vol, err := c.getVol(snap.VolName)
+	if err != nil {
+		return
+	}
+	if !c.volSnapshots.begin(vol.Name) {
+		return nil, fmt.Errorf("vol[%v] has a snapshot being created or restored", vol.Name)
+	}
+	defer c.volSnapshots.end(vol.Name)
+
+	snapped := make(map[uint64]bool, len(snap.MetaPartitions))
+	for _, p := range snap.MetaPartitions {
+		if _, err = vol.metaPartition(p.PartitionID); err != nil {
+			return nil, fmt.Errorf("mp[%v] of vol snapshot[%v] err[%v]", p.PartitionID, id, err)
+		}
+		snapped[p.PartitionID] = true
+	}
+	// the partitions created after the snapshot are cleared, so that their inodes and extents are released
+	mps := sortedMetaPartitions(vol)
+	fence, err := c.holdVolWrites(vol.Name, mps, vol.cloneDataPartitionMap())
+	if err != nil {
+		return
+	}
+	defer fence.lift()
+	err = runConcurrently(len(mps), func(i int) (err error) {
+		req := &proto.RestoreSnapshotRequest{
+			VolName:     vol.Name,
+			PartitionID: mps[i].PartitionID,
+			SnapshotID:  id,
+			Clear:       !snapped[mps[i].PartitionID],
+		}
+		_, err = c.syncSendMetaPartitionPacket(mps[i], proto.OpMetaRestoreSnapshot, req)
+		return
+	})
+	if err == nil {
+		// the namespace may mix the writes accepted after the fence expired, restore it again
+		err = fence.check()
+	}
+	if err != nil {
+		log.LogErrorf("action[restoreVolSnapshot] vol[%v] snapshot[%v] failed, err[%v]", vol.Name, id, err)
+		return nil, err
+	}
+	restored := *snap
+	restored.RestoreTime = time.Now().Unix()
+	if err = c.syncPutVolSnapshot(&restored); err != nil {
+		return nil, proto.ErrPersistenceByRaft
+	}
+	c.volSnapshots.put(&restored)
+	log.LogWarnf("action[restoreVolSnapshot] vol[%v] restored to snapshot[%v] name[%v]", vol.Name, id, snap.Name)
+	return &restored, nil
+}
+
+// deleteVolSnapshots drops the catalog entries of the deleted volume, whose partitions hold the snapshots.
+func (c *Cluster) deleteVolSnapshots(volName string) {
+	for _, snap := range c.volSnapshots.list(volName) {
+		if err := c.syncDeleteVolSnapshot(snap); err != nil {
+			log.LogErrorf("action[deleteVolSnapshots] vol[%v] snapshot[%v] err[%v]", volName, snap.ID, err)
+			continue
+		}
+		c.volSnapshots.delete(snap.ID)
+	}
+}
+
+// volWriteFence keeps the writes of a volume fenced by renewing the fence before it expires.
+type volWriteFence struct {
+	c         *Cluster
+	volName   string
+	mps       []*MetaPartition
+	dps       map[uint64]*DataPartition
+	start     time.Time
+	renewedAt int64 // the unix nano time the fence was last set by all the meta nodes and data nodes
+	stopC     chan struct{}
+	wg        sync.WaitGroup
+}
+
+// holdVolWrites fences the writes of the volume on the meta nodes and the data nodes of the partitions
+// until lift is called.
+func (c *Cluster) holdVolWrites(volName string, mps []*MetaPartition, dps map[uint64]*DataPartition) (fence *volWriteFence, err error) {
+	fence = &volWriteFence{c: c, volName: volName, mps: mps, dps: dps, start: time.Now(), stopC: make(chan struct{})}
+	if err = c.fenceVolWrites(volName, mps, dps, volSnapshotFenceTTL); err != nil {
+		c.fenceVolWrites(volName, mps, dps, 0)
+		return nil, err
+	}
+	fence.renewedAt = fence.start.UnixNano()
+	fence.wg.Add(1)
+	go fence.renew()
+	return
+}
+
+func (fence *volWriteFence) renew() {
+	defer fence.wg.Done()
+	ticker := time.NewTicker(volSnapshotFenceRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fence.stopC:
+			return
+		case <-ticker.C:
+			// the TTL counts from the time the nodes receive the request, which is after now
+			now := time.Now()
+			if err := fence.c.fenceVolWrites(fence.volName, fence.mps, fence.dps, volSnapshotFenceTTL); err != nil {
+				log.LogWarnf("action[renewVolWriteFence] vol[%v] err[%v]", fence.volName, err)
+				continue
+			}
+			atomic.StoreInt64(&fence.renewedAt, now.UnixNano())
+		}
+	}
+}
+
+// check returns an error if the fence of any node may have expired, so that the writes of the
+// volume may have been accepted since then.
+func (fence *volWriteFence) check() error {
+	renewedAt := time.Unix(0, atomic.LoadInt64(&fence.renewedAt))
+	if time.Since(renewedAt) >= volSnapshotFenceTTL*time.Second {
+		return fmt.Errorf("the write fence of vol[%v] expired, it was last renewed at %v",
+			fence.volName, renewedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// lift stops renewing the fence and lifts it.
+func (fence *volWriteFence) lift() {
+	close(fence.stopC)
+	fence.wg.Wait()
+	fence.c.fenceVolWrites(fence.volName, fence.mps, fence.dps, 0)
+}
+
+// fenceVolWrites makes the meta nodes and the data nodes of the partitions refuse the writes of the
+// volume for ttl seconds, or lifts the fence if ttl is 0.
+func (c *Cluster) fenceVolWrites(volName string, mps []*MetaPartition, dps map[uint64]*DataPartition, ttl int64) (err error) {
+	metaHosts := make(map[string]bool)
+	for _, mp := range mps {
+		mp.RLock()
+		for _, host := range mp.Hosts {
+			metaHosts[host] = true
+		}
+		mp.RUnlock()
+	}
+	dataHosts := make(map[string]bool)
+	for _, dp := range dps {
+		dp.RLock()
+		for _, host := range dp.Hosts {
+			dataHosts[host] = true
+		}
+		dp.RUnlock()
+	}
+	req := &proto.FenceWritesRequest{VolName: volName, TTL: ttl}
+	metaAddrs := sortedHosts(metaHosts)
+	err = runConcurrently(len(metaAddrs), func(i int) (err error) {
+		metaNode, err := c.metaNode(metaAddrs[i])
+		if err != nil {
+			return
+		}
+		task := proto.NewAdminTask(proto.OpMetaFenceWrites, metaAddrs[i], req)
+		if _, err = metaNode.Sender.syncSendAdminTask(task); err != nil {
+			log.LogErrorf("action[fenceVolWrites] vol[%v] metaNode[%v] ttl[%v] err[%v]", volName, metaAddrs[i], ttl, err)
+		}
+		return
+	})
+	// the data nodes are fenced or lifted even if some meta node fails
+	dataAddrs := sortedHosts(dataHosts)
+	dataErr := runConcurrently(len(dataAddrs), func(i int) (err error) {
+		dataNode, err := c.dataNode(dataAddrs[i])
+		if err != nil {
+			return
+		}
+		task := proto.NewAdminTask(proto.OpDataNodeFenceWrites, dataAddrs[i], req)
+		if _, err = dataNode.TaskManager.syncSendAdminTask(task); err != nil {
+			log.LogErrorf("action[fenceVolWrites] vol[%v] dataNode[%v] ttl[%v] err[%v]", volName, dataAddrs[i], ttl, err)
+		}
+		return
+	})
+	if err == nil {
+		err = dataErr
+	}
+	return
+}
+
+// syncSendMetaPartitionPacket sends the request of the client protocol to the leader of the meta partition.
+func (c *Cluster) syncSendMetaPartitionPacket(mp *MetaPartition, opcode uint8, req interface{}) (packet *proto.Packet, err error) {
+	mp.RLock()
+	mr, err := mp.getMetaReplicaLeader()
+	mp.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("mp[%v] err[%v]", mp.PartitionID, err)
+	}
+	metaNode, err := c.metaNode(mr.Addr)
+	if err != nil {
+		return
+	}
+	packet = proto.NewPacket()
+	packet.Opcode = opcode
+	packet.ReqID = proto.GenerateRequestID()
+	packet.PartitionID = mp.PartitionID
+	if packet.Data, err = json.Marshal(req); err != nil {
+		return
+	}
+	packet.Size = uint32(len(packet.Data))
+	if packet, err = metaNode.Sender.syncSendPacket(packet); err != nil {
+		err = fmt.Errorf("mp[%v] leader[%v] err[%v]", mp.PartitionID, mr.Addr, err)
+	}
+	return
+}
+
+func (c *Cluster) getDataPartitionAppliedID(dp *DataPartition) (appliedID uint64, err error) {
+	addr := dp.getLeaderAddrWithLock()
+	if addr == "" {
+		return 0, fmt.Errorf("dp[%v] err[%v]", dp.PartitionID, proto.ErrNoLeader)
+	}
+	dataNode, err := c.dataNode(addr)
+	if err != nil {
+		return
+	}
+	packet := proto.NewPacket()
+	packet.Opcode = proto.OpGetAppliedId
+	packet.ReqID = proto.GenerateRequestID()
+	packet.PartitionID = dp.PartitionID
+	if packet, err = dataNode.TaskManager.syncSendPacket(packet); err != nil {
+		return 0, fmt.Errorf("dp[%v] leader[%v] err[%v]", dp.PartitionID, addr, err)
+	}
+	if len(packet.Data) < 8 {
+		return 0, fmt.Errorf("dp[%v] leader[%v] invalid applied id", dp.PartitionID, addr)
+	}
+	return binary.BigEndian.Uint64(packet.Data), nil
+}
+
+func sortedMetaPartitions(vol *Vol) (mps []*MetaPartition) {
+	for _, mp := range vol.cloneMetaPartitionMap() {
+		mps = append(mps, mp)
+	}
+	sort.Slice(mps, func(i, j int) bool {
+		return mps[i].PartitionID < mps[j].PartitionID
+	})
+	return
+}
+
+func sortedHosts(hosts map[string]bool) (addrs []string) {
+	addrs = make([]string, 0, len(hosts))
+	for host := range hosts {
+		addrs = append(addrs, host)
+	}
+	sort.Strings(addrs)
+	return
+}
+
+// runConcurrently calls fn for the indexes up to n by at most volSnapshotConcurrency goroutines, and
+// returns one of the errors if any.
+func runConcurrently(n int, fn func(i int) error) (err error) {
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, volSnapshotConcurrency)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if e := fn(i); e != nil {
+				mu.Lock()
+				err = e
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return
+}
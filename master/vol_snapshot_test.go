@@ -0,0 +1,92 @@
+package master
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestVolSnapshot(t *testing.T) {
+	volName := "snapshotVol"
+	createVol(volName, t)
+	// the snapshots are requested from the partition leaders reported by the heartbeats
+	server.cluster.checkDataNodeHeartbeat()
+	server.cluster.checkMetaNodeHeartbeat()
+	time.Sleep(5 * time.Second)
+	vol, err := server.cluster.getVol(volName)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	authKey := buildAuthKey(vol.Owner)
+	process(fmt.Sprintf("%v%v?name=%v&snapshot=daily&authKey=%v", hostAddr, proto.AdminCreateVolSnapshot,
+		volName, authKey), t)
+	snaps := server.cluster.volSnapshots.list(volName)
+	if len(snaps) != 1 {
+		t.Errorf("snapshots of vol[%v]: %v", volName, snaps)
+		return
+	}
+	snap := snaps[0]
+	if snap.Status != proto.VolSnapshotAvailable || snap.Name != "daily" ||
+		len(snap.MetaPartitions) != len(vol.cloneMetaPartitionMap()) || len(snap.DataPartitions) != len(vol.cloneDataPartitionMap()) {
+		t.Errorf("unexpected snapshot %+v", snap)
+		return
+	}
+	for _, p := range snap.MetaPartitions {
+		// the mock meta nodes take the snapshots at the partition IDs
+		if p.ApplyID != p.PartitionID {
+			t.Errorf("unexpected apply id of mp[%v]: %v", p.PartitionID, p.ApplyID)
+		}
+	}
+	process(fmt.Sprintf("%v%v?name=%v", hostAddr, proto.AdminListVolSnapshots, volName), t)
+
+	// the meta partition created after the snapshot is cleared by the restore, the mock meta nodes
+	// refuse to restore the snapshot they have not taken
+	if err = server.cluster.updateInodeIDRange(volName, 0); err != nil {
+		t.Error(err)
+		return
+	}
+	server.cluster.checkMetaNodeHeartbeat()
+	time.Sleep(5 * time.Second)
+	if len(vol.cloneMetaPartitionMap()) != len(snap.MetaPartitions)+1 {
+		t.Errorf("meta partitions of vol[%v]: %v, expected one more than the snapshot", volName, len(vol.cloneMetaPartitionMap()))
+		return
+	}
+	process(fmt.Sprintf("%v%v?id=%v&authKey=%v", hostAddr, proto.AdminRestoreVolSnapshot, snap.ID, authKey), t)
+	if snap = server.cluster.volSnapshots.get(snap.ID); snap == nil || snap.RestoreTime == 0 {
+		t.Errorf("snapshot not restored: %+v", snap)
+		return
+	}
+	if _, err = server.cluster.restoreVolSnapshot(snap.ID + 1); err == nil {
+		t.Errorf("restore unknown snapshot: expect an error")
+	}
+
+	process(fmt.Sprintf("%v%v?id=%v&authKey=%v", hostAddr, proto.AdminDeleteVolSnapshot, snap.ID, authKey), t)
+	if server.cluster.volSnapshots.get(snap.ID) != nil {
+		t.Errorf("snapshot[%v] not deleted", snap.ID)
+	}
+}
+
+func TestVolSnapshotRunning(t *testing.T) {
+	m := newVolSnapshotManager()
+	if !m.begin("vol") || m.begin("vol") {
+		t.Errorf("a snapshot of the vol is running twice")
+	}
+	m.end("vol")
+	if !m.begin("vol") {
+		t.Errorf("the running snapshot of the vol is not ended")
+	}
+}
+
+func TestVolWriteFenceExpired(t *testing.T) {
+	fence := &volWriteFence{volName: "vol", renewedAt: time.Now().UnixNano()}
+	if err := fence.check(); err != nil {
+		t.Errorf("the fence just renewed expired: %v", err)
+	}
+	fence.renewedAt = time.Now().Add(-volSnapshotFenceTTL * time.Second).UnixNano()
+	if err := fence.check(); err == nil {
+		t.Errorf("the fence not renewed for its ttl: expect an error")
+	}
+}
@@ -61,6 +61,7 @@ func (m *MetaNode) registerAPIHandler() (err error) {
 	http.HandleFunc("/getParams", m.getParamsHandler)
 	http.HandleFunc("/getSmuxStat", m.getSmuxStatHandler)
 	http.HandleFunc("/getRaftStatus", m.getRaftStatusHandler)
+	http.HandleFunc("/setSnapshotRateLimit", m.setSnapshotRateLimitHandler)
 	return
 }
 
@@ -253,6 +254,53 @@ func (m *MetaNode) getRaftStatusHandler(w http.ResponseWriter, r *http.Request)
 	resp.Data = raftStatus
 }
 
+// setSnapshotRateLimitHandler limits the rate of the raft snapshot transfer of the node, or of the
+// partition if it is given, in bytes per second. Non-positive rate removes the limit.
+func (m *MetaNode) setSnapshotRateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramRate        = "rate"
+		paramPartitionID = "pid"
+	)
+
+	resp := NewAPIResponse(http.StatusOK, http.StatusText(http.StatusOK))
+	defer func() {
+		data, _ := resp.Marshal()
+		if _, err := w.Write(data); err != nil {
+			log.LogErrorf("[setSnapshotRateLimitHandler] response %s", err)
+		}
+	}()
+
+	bytesPerSec, err := strconv.ParseInt(r.FormValue(paramRate), 10, 64)
+	if err != nil {
+		resp.Code = http.StatusBadRequest
+		resp.Msg = fmt.Sprintf("parse param %v fail: %v", paramRate, err)
+		return
+	}
+	if r.FormValue(paramPartitionID) == "" {
+		m.raftStore.SetSnapshotRateLimit(bytesPerSec)
+		log.LogWarnf("[setSnapshotRateLimitHandler] rate(%v)", bytesPerSec)
+		return
+	}
+	pid, err := strconv.ParseUint(r.FormValue(paramPartitionID), 10, 64)
+	if err != nil {
+		resp.Code = http.StatusBadRequest
+		resp.Msg = fmt.Sprintf("parse param %v fail: %v", paramPartitionID, err)
+		return
+	}
+	mp, err := m.metadataManager.GetPartition(pid)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+	if err = mp.SetSnapshotRateLimit(bytesPerSec); err != nil {
+		resp.Code = http.StatusInternalServerError
+		resp.Msg = err.Error()
+		return
+	}
+	log.LogWarnf("[setSnapshotRateLimitHandler] partition(%v) rate(%v)", pid, bytesPerSec)
+}
+
 func (m *MetaNode) getExtentsByInodeHandler(w http.ResponseWriter,
 	r *http.Request) {
 	r.ParseForm()
@@ -0,0 +1,105 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// the arguments larger than it are not recorded
+const auditMaxArgsSize = 4096
+
+// auditedOps are the operations modifying the metadata which are audited, the value tells whether the
+// arguments of the request are recorded, which are not for the contents of the files.
+var auditedOps = map[uint8]bool{
+	proto.OpMetaCreateInode:       true,
+	proto.OpMetaUnlinkInode:       true,
+	proto.OpMetaBatchUnlinkInode:  true,
+	proto.OpMetaLinkInode:         true,
+	proto.OpMetaEvictInode:        true,
+	proto.OpMetaBatchEvictInode:   true,
+	proto.OpMetaDeleteInode:       true,
+	proto.OpMetaBatchDeleteInode:  true,
+	proto.OpMetaCreateDentry:      true,
+	proto.OpMetaDeleteDentry:      true,
+	proto.OpMetaBatchDeleteDentry: true,
+	proto.OpMetaUpdateDentry:      true,
+	proto.OpMetaSetattr:           true,
+	proto.OpMetaTruncate:          true,
+	proto.OpMetaPunchHole:         true,
+	proto.OpMetaSetXAttr:          true,
+	proto.OpMetaRemoveXAttr:       true,
+	proto.OpMetaWriteInline:       false,
+	proto.OpMetaCreateSnapshot:    true,
+	proto.OpMetaSealSnapshot:      true,
+	proto.OpMetaDeleteSnapshot:    true,
+	proto.OpMetaRestoreSnapshot:   true,
+	proto.OpMetaSetInodeQuota:     true,
+	proto.OpMetaFenceWrites:       true,
+	proto.OpCreateMetaPartition:   true,
+	proto.OpDeleteMetaPartition:   true,
+}
+
+// opAuditRecord is the audit record of an operation served by the meta node.
+type opAuditRecord struct {
+	Time        time.Time       `json:"time"`
+	Op          string          `json:"op"`
+	PartitionID uint64          `json:"partitionID"`
+	Vol         string          `json:"vol,omitempty"`
+	ReqID       int64           `json:"reqID"`
+	RequestID   string          `json:"requestID,omitempty"` // the ID of the user operation, see reqid
+	Remote      string          `json:"remote"`
+	Args        json.RawMessage `json:"args,omitempty"`
+	Result      string          `json:"result"`
+	LatencyMs   int64           `json:"latencyMs"`
+}
+
+// newOpAuditRecord returns the audit record of the request if its operation is audited, which is taken
+// before the request is handled, as the data of the packet is replaced by the response.
+func (m *metadataManager) newOpAuditRecord(p *Packet, remoteAddr, vol string) *opAuditRecord {
+	if m.metaNode == nil || m.metaNode.auditLogger == nil {
+		return nil
+	}
+	recordArgs, audited := auditedOps[p.Opcode]
+	if !audited {
+		return nil
+	}
+	var rec = &opAuditRecord{
+		Time:        time.Now().UTC(),
+		Op:          p.GetOpMsg(),
+		PartitionID: p.PartitionID,
+		Vol:         vol,
+		ReqID:       p.ReqID,
+		RequestID:   p.RequestID.String(),
+		Remote:      remoteAddr,
+	}
+	if size := int(p.Size); recordArgs && size <= auditMaxArgsSize && size <= len(p.Data) && json.Valid(p.Data[:size]) {
+		rec.Args = append(json.RawMessage(nil), p.Data[:size]...)
+	}
+	return rec
+}
+
+// logOpAudit records the result of the request to the audit sinks.
+func (m *metadataManager) logOpAudit(rec *opAuditRecord, p *Packet) {
+	if rec == nil {
+		return
+	}
+	rec.Result = p.GetResultMsg()
+	rec.LatencyMs = int64(time.Since(rec.Time) / time.Millisecond)
+	m.metaNode.auditLogger.Log(rec.Vol, rec)
+}
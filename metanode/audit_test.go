@@ -0,0 +1,68 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/audit"
+	"github.com/cubefs/cubefs/util/reqid"
+)
+
+func TestOpAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "audit.log")
+	logger, err := audit.NewLogger("metanode", []*audit.SinkConfig{{Type: audit.SinkFile, Path: file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &metadataManager{metaNode: &MetaNode{auditLogger: logger}}
+
+	args := []byte(`{"vol":"ltptest","pid":1,"mode":420}`)
+	p := &Packet{}
+	p.Opcode, p.PartitionID, p.ReqID = proto.OpMetaCreateInode, 1, 100
+	p.Data, p.Size = args, uint32(len(args))
+	p.RequestID = reqid.New()
+	rec := m.newOpAuditRecord(p, "127.0.0.1:1234", "ltptest")
+	// the data of the packet is replaced by the response
+	p.PacketOkWithBody([]byte(`{"info":{}}`))
+	m.logOpAudit(rec, p)
+
+	read := &Packet{}
+	read.Opcode = proto.OpMetaInodeGet
+	if m.newOpAuditRecord(read, "127.0.0.1:1234", "ltptest") != nil {
+		t.Errorf("the reads should not be audited")
+	}
+	logger.Stop()
+
+	data, _ := ioutil.ReadFile(file)
+	rec = &opAuditRecord{}
+	if err = json.Unmarshal(data, rec); err != nil {
+		t.Fatalf("unmarshal audit record fail: data(%s) err(%v)", data, err)
+	}
+	if rec.Op != "OpMetaCreateInode" || rec.Vol != "ltptest" || rec.ReqID != 100 || rec.Result != "Ok" ||
+		string(rec.Args) != string(args) || rec.RequestID != p.RequestID.String() {
+		t.Errorf("unexpected audit record: %s", data)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/errors"
 )
 
@@ -79,6 +80,10 @@ type (
 	UpdatePartitionResp = proto.UpdateMetaPartitionResponse
 	// Client -> MetaNode
 	ExtentsTruncateReq = proto.TruncateRequest
+	// Client -> MetaNode
+	ExtentsPunchHoleReq = proto.PunchHoleRequest
+	// Client -> MetaNode
+	WriteInlineReq = proto.WriteInlineRequest
 
 	// Client -> MetaNode
 	EvictInodeReq = proto.EvictInodeRequest
@@ -125,6 +130,26 @@ const (
 	opFSMExtentsAddWithCheck
 
 	opFSMUpdateSummaryInfo
+
+	opFSMCreateSnapshot
+	opFSMSealSnapshot
+	opFSMDeleteSnapshot
+	opSubtreeSnapshotState
+
+	opFSMExtentPunchHole
+
+	opFSMWriteInline
+
+	opFSMPutObjectVersion
+	opFSMRemoveObjectVersion
+
+	opFSMReplaceMultipartPart
+
+	opFSMMigrateToCold
+
+	opFSMRestoreSnapshot
+
+	opFSMSetInodeQuota
 )
 
 var (
@@ -146,6 +171,7 @@ const (
 // Configuration keys
 const (
 	cfgLocalIP           = "localIP"
+	cfgReplicaIP         = "replicaIP"
 	cfgListen            = "listen"
 	cfgMetadataDir       = "metadataDir"
 	cfgRaftDir           = "raftDir"
@@ -157,14 +183,55 @@ const (
 	cfgZoneName          = "zoneName"
 	cfgTickInterval      = "tickInterval"
 	cfgRaftRecvBufSize   = "raftRecvBufSize"
-	cfgSmuxPortShift     = "smuxPortShift"     //int
-	cfgSmuxMaxConn       = "smuxMaxConn"       //int
-	cfgSmuxStreamPerConn = "smuxStreamPerConn" //int
-	cfgSmuxMaxBuffer     = "smuxMaxBuffer"     //int
+	cfgRaftPreVote       = "raftPreVote"
+	cfgRaftWalFileSize   = "raftWalFileSize"       // int, unit is MB
+	cfgRaftWalCompress   = "raftWalCompress"       // bool
+	cfgRaftWalRepair     = "raftWalRepair"         // bool
+	cfgRaftPropBatch     = "raftProposalBatch"     // int
+	cfgRaftSnapRateLimit = "raftSnapshotRateLimit" // int, unit is byte per second
+	cfgRaftTLSCertFile   = "raftTLSCertFile"       // string
+	cfgRaftTLSKeyFile    = "raftTLSKeyFile"        // string
+	cfgRaftTLSCAFile     = "raftTLSCAFile"         // string
+	cfgSmuxPortShift     = "smuxPortShift"         //int
+	cfgSmuxMaxConn       = "smuxMaxConn"           //int
+	cfgSmuxStreamPerConn = "smuxStreamPerConn"     //int
+	cfgSmuxMaxBuffer     = "smuxMaxBuffer"         //int
+	cfgAuditTargets      = "auditTargets"          // object slice, sinks of the audit records of the operations, see package audit
 
 	metaNodeDeleteBatchCountKey = "batchCount"
 )
 
+// ConfigSchema declares the configuration keys of the meta node.
+var ConfigSchema = config.Schema{
+	cfgLocalIP:           config.TypeString,
+	cfgReplicaIP:         config.TypeString,
+	cfgListen:            config.TypeString,
+	cfgMetadataDir:       config.TypeString,
+	cfgRaftDir:           config.TypeString,
+	proto.MasterAddr:     config.TypeStringSlice,
+	cfgRaftHeartbeatPort: config.TypeString,
+	cfgRaftReplicaPort:   config.TypeString,
+	cfgDeleteBatchCount:  config.TypeInt,
+	cfgTotalMem:          config.TypeString,
+	cfgZoneName:          config.TypeString,
+	cfgTickInterval:      config.TypeFloat,
+	cfgRaftRecvBufSize:   config.TypeInt,
+	cfgRaftPreVote:       config.TypeBool,
+	cfgRaftWalFileSize:   config.TypeInt,
+	cfgRaftWalCompress:   config.TypeBool,
+	cfgRaftWalRepair:     config.TypeBool,
+	cfgRaftPropBatch:     config.TypeInt,
+	cfgRaftSnapRateLimit: config.TypeInt,
+	cfgRaftTLSCertFile:   config.TypeString,
+	cfgRaftTLSKeyFile:    config.TypeString,
+	cfgRaftTLSCAFile:     config.TypeString,
+	cfgSmuxPortShift:     config.TypeInt,
+	cfgSmuxMaxConn:       config.TypeInt,
+	cfgSmuxStreamPerConn: config.TypeInt,
+	cfgSmuxMaxBuffer:     config.TypeInt,
+	cfgAuditTargets:      config.TypeSlice,
+}
+
 const (
 	// interval of persisting in-memory data
 	intervalToPersistData = time.Minute * 5
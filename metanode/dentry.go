@@ -17,6 +17,7 @@ package metanode
 import (
 	"bytes"
 	"encoding/binary"
+	"strings"
 )
 
 // Dentry wraps necessary properties of the `dentry` information in file system.
@@ -207,3 +208,37 @@ func (d *Dentry) UnmarshalValue(val []byte) (err error) {
 	err = binary.Read(buff, binary.BigEndian, &d.Type)
 	return
 }
+
+// FoldedDentry indexes a dentry of a case-insensitive meta partition by its
+// case-folded name, so that a lookup with any case resolves to the name that
+// was preserved on creation.
+type FoldedDentry struct {
+	ParentId uint64 // FileID value of the parent inode.
+	Key      string // Case-folded name used for ordering.
+	Name     string // Name of the dentry as stored in the dentry tree.
+}
+
+func newFoldedDentry(parentID uint64, name string) *FoldedDentry {
+	return &FoldedDentry{
+		ParentId: parentID,
+		Key:      foldDentryName(name),
+		Name:     name,
+	}
+}
+
+// foldDentryName normalizes the name for case-insensitive comparison.
+func foldDentryName(name string) string {
+	return strings.ToUpper(name)
+}
+
+// Less tests whether the current folded dentry is less than the given one.
+func (f *FoldedDentry) Less(than BtreeItem) (less bool) {
+	other, ok := than.(*FoldedDentry)
+	less = ok && ((f.ParentId < other.ParentId) || ((f.ParentId == other.ParentId) && (f.Key < other.Key)))
+	return
+}
+
+func (f *FoldedDentry) Copy() BtreeItem {
+	newFolded := *f
+	return &newFolded
+}
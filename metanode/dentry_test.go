@@ -0,0 +1,43 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestCaseInsensitiveDentry(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, CaseInsensitive: true}, nil).(*metaPartition)
+	if status := mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "ReadMe.md", Inode: 10}, true); status != proto.OpOk {
+		t.Fatalf("create dentry: status(%v)", status)
+	}
+	if status := mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "README.MD", Inode: 11}, true); status != proto.OpExistErr {
+		t.Fatalf("create conflicting dentry: expect OpExistErr, got status(%v)", status)
+	}
+
+	d, status := mp.getDentry(&Dentry{ParentId: 1, Name: "readme.md"})
+	if status != proto.OpOk || d.Inode != 10 || d.Name != "ReadMe.md" {
+		t.Fatalf("lookup: status(%v) dentry(%v)", status, d)
+	}
+
+	if resp := mp.fsmDeleteDentry(&Dentry{ParentId: 1, Name: "README.md"}, false); resp.Status != proto.OpOk {
+		t.Fatalf("delete dentry: status(%v)", resp.Status)
+	}
+	if mp.dentryTree.Len() != 0 || mp.dentryFoldTree.Len() != 0 {
+		t.Fatalf("trees not empty after delete: dentries(%v) folded(%v)", mp.dentryTree.Len(), mp.dentryFoldTree.Len())
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// The deadlines of the write fences of the volumes set by the master.
+var (
+	writeFencesMu sync.RWMutex
+	writeFences   = make(map[string]time.Time)
+)
+
+// fencedOps are the operations of the clients modifying the metadata, which are refused while the
+// writes of the volume are fenced. The operations of the snapshots and between the meta nodes are not.
+var fencedOps = map[uint8]bool{
+	proto.OpMetaCreateInode:        true,
+	proto.OpMetaUnlinkInode:        true,
+	proto.OpMetaBatchUnlinkInode:   true,
+	proto.OpMetaLinkInode:          true,
+	proto.OpMetaEvictInode:         true,
+	proto.OpMetaBatchEvictInode:    true,
+	proto.OpMetaDeleteInode:        true,
+	proto.OpMetaBatchDeleteInode:   true,
+	proto.OpMetaCreateDentry:       true,
+	proto.OpMetaDeleteDentry:       true,
+	proto.OpMetaBatchDeleteDentry:  true,
+	proto.OpMetaUpdateDentry:       true,
+	proto.OpMetaSetattr:            true,
+	proto.OpMetaTruncate:           true,
+	proto.OpMetaPunchHole:          true,
+	proto.OpMetaExtentsAdd:         true,
+	proto.OpMetaExtentAddWithCheck: true,
+	proto.OpMetaBatchExtentsAdd:    true,
+	proto.OpMetaWriteInline:        true,
+	proto.OpMetaSetXAttr:           true,
+	proto.OpMetaRemoveXAttr:        true,
+	proto.OpCreateMultipart:        true,
+	proto.OpAddMultipartPart:       true,
+	proto.OpRemoveMultipart:        true,
+	proto.OpPutObjectVersion:       true,
+	proto.OpRemoveObjectVersion:    true,
+}
+
+// setWriteFence fences the writes of the volume for ttl, or lifts the fence if ttl is 0.
+func setWriteFence(volName string, ttl time.Duration) {
+	writeFencesMu.Lock()
+	defer writeFencesMu.Unlock()
+	if ttl <= 0 {
+		delete(writeFences, volName)
+		return
+	}
+	writeFences[volName] = time.Now().Add(ttl)
+}
+
+func isWriteFenced(volName string) bool {
+	writeFencesMu.RLock()
+	defer writeFencesMu.RUnlock()
+	deadline, ok := writeFences[volName]
+	return ok && time.Now().Before(deadline)
+}
+
+// checkWriteFence replies OpAgain to the write of the volume fenced, which the client retries later.
+func (m *metadataManager) checkWriteFence(conn net.Conn, p *Packet, volName string) (fenced bool) {
+	if !fencedOps[p.Opcode] || volName == "" || !isWriteFenced(volName) {
+		return false
+	}
+	p.PacketErrorWithBody(proto.OpAgain, []byte("writes of the volume are fenced"))
+	m.respondToClient(conn, p)
+	return true
+}
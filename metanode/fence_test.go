@@ -0,0 +1,33 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteFence(t *testing.T) {
+	setWriteFence("fenced", time.Minute)
+	setWriteFence("expired", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if !isWriteFenced("fenced") || isWriteFenced("expired") || isWriteFenced("other") {
+		t.Fatalf("unexpected fences: %v", writeFences)
+	}
+	setWriteFence("fenced", 0)
+	if isWriteFenced("fenced") {
+		t.Fatalf("fence not lifted")
+	}
+}
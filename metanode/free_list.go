@@ -17,8 +17,14 @@ package metanode
 import (
 	"container/list"
 	"sync"
+	"time"
 )
 
+type freeListItem struct {
+	ino      uint64
+	pushTime time.Time
+}
+
 type freeList struct {
 	sync.Mutex
 	list  *list.List
@@ -41,7 +47,7 @@ func (fl *freeList) Pop() (ino uint64) {
 		return
 	}
 	val := fl.list.Remove(item)
-	ino = val.(uint64)
+	ino = val.(*freeListItem).ino
 	delete(fl.index, ino)
 	return
 }
@@ -51,7 +57,7 @@ func (fl *freeList) Push(ino uint64) {
 	fl.Lock()
 	defer fl.Unlock()
 	if _, ok := fl.index[ino]; !ok {
-		item := fl.list.PushBack(ino)
+		item := fl.list.PushBack(&freeListItem{ino: ino, pushTime: time.Now()})
 		fl.index[ino] = item
 	}
 }
@@ -70,3 +76,14 @@ func (fl *freeList) Len() int {
 	defer fl.Unlock()
 	return len(fl.index)
 }
+
+// Lag returns how long the first item has been waiting on the list.
+func (fl *freeList) Lag() time.Duration {
+	fl.Lock()
+	defer fl.Unlock()
+	item := fl.list.Front()
+	if item == nil {
+		return 0
+	}
+	return time.Since(item.Value.(*freeListItem).pushTime)
+}
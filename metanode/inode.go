@@ -28,6 +28,11 @@ import (
 
 const (
 	DeleteMarkFlag = 1 << 0
+	ImmutableFlag  = int32(proto.FlagImmutable)
+	AppendOnlyFlag = int32(proto.FlagAppendOnly)
+	InlineDataFlag = 1 << 3 // the data of the file is stored in InlineData instead of the extents
+	ColdDataFlag   = 1 << 4 // the data of the file is stored in the blob store of the cold tier, see Cold
+	QuotaFlag      = 1 << 5 // the inode is counted by the directory quotas of QuotaIDs
 )
 
 // Inode wraps necessary properties of `Inode` information in the file system.
@@ -43,6 +48,9 @@ const (
 //  +-------+------+------+-----+----+----+----+--------+------------------+
 //  | bytes |  4   |  8   |  8  | 8  | 8  | 8  |   4    |      ExtLen      |
 //  +-------+------+------+-----+----+----+----+--------+------------------+
+// The count and the IDs of the directory quotas follow the reserved space if QuotaFlag is set.
+// The inline data takes the place of the marshaled extents if InlineDataFlag is set.
+// The length and the JSON of the cold location precede the marshaled extents if ColdDataFlag is set.
 // Marshal entity:
 //  +-------+-----------+--------------+-----------+--------------+
 //  | item  | KeyLength | MarshaledKey | ValLength | MarshaledVal |
@@ -65,7 +73,13 @@ type Inode struct {
 	Flag       int32
 	Reserved   uint64 // reserved space
 	//Extents    *ExtentsTree
-	Extents *SortedExtents
+	Extents    *SortedExtents
+	InlineData []byte // data of the small files stored in the inode
+	// location of the data migrated to the cold tier, overlaid by the extents written after the
+	// migration. It is replaced as a whole and never modified in place.
+	Cold *proto.ColdLocation
+	// sorted IDs of the directory quotas counting the inode, replaced as a whole and never modified in place
+	QuotaIDs []uint64
 }
 
 type InodeBatch []*Inode
@@ -91,6 +105,13 @@ func (i *Inode) String() string {
 	buff.WriteString(fmt.Sprintf("Flag[%d]", i.Flag))
 	buff.WriteString(fmt.Sprintf("Reserved[%d]", i.Reserved))
 	buff.WriteString(fmt.Sprintf("Extents[%s]", i.Extents))
+	buff.WriteString(fmt.Sprintf("Inline[%d]", len(i.InlineData)))
+	if i.Cold != nil {
+		buff.WriteString(fmt.Sprintf("Cold[%d/%d]", i.Cold.Size, len(i.Cold.Blobs)))
+	}
+	if len(i.QuotaIDs) > 0 {
+		buff.WriteString(fmt.Sprintf("Quotas%v", i.QuotaIDs))
+	}
 	buff.WriteString("}")
 	return buff.String()
 }
@@ -141,6 +162,12 @@ func (i *Inode) Copy() BtreeItem {
 	newIno.Flag = i.Flag
 	newIno.Reserved = i.Reserved
 	newIno.Extents = i.Extents.Clone()
+	if i.Flag&InlineDataFlag != 0 {
+		newIno.InlineData = make([]byte, len(i.InlineData))
+		copy(newIno.InlineData, i.InlineData)
+	}
+	newIno.Cold = i.Cold
+	newIno.QuotaIDs = i.QuotaIDs
 	i.RUnlock()
 	return newIno
 }
@@ -314,6 +341,36 @@ func (i *Inode) MarshalValue() (val []byte) {
 	if err = binary.Write(buff, binary.BigEndian, &i.Reserved); err != nil {
 		panic(err)
 	}
+	if i.Flag&QuotaFlag != 0 {
+		quotaCount := uint32(len(i.QuotaIDs))
+		if err = binary.Write(buff, binary.BigEndian, &quotaCount); err != nil {
+			panic(err)
+		}
+		if err = binary.Write(buff, binary.BigEndian, i.QuotaIDs); err != nil {
+			panic(err)
+		}
+	}
+	if i.Flag&InlineDataFlag != 0 {
+		if _, err = buff.Write(i.InlineData); err != nil {
+			panic(err)
+		}
+		val = buff.Bytes()
+		i.RUnlock()
+		return
+	}
+	if i.Flag&ColdDataFlag != 0 {
+		var cold []byte
+		if cold, err = json.Marshal(i.Cold); err != nil {
+			panic(err)
+		}
+		coldSize := uint32(len(cold))
+		if err = binary.Write(buff, binary.BigEndian, &coldSize); err != nil {
+			panic(err)
+		}
+		if _, err = buff.Write(cold); err != nil {
+			panic(err)
+		}
+	}
 	// marshal ExtentsKey
 	extData, err := i.Extents.MarshalBinary()
 	if err != nil {
@@ -376,6 +433,35 @@ func (i *Inode) UnmarshalValue(val []byte) (err error) {
 	if err = binary.Read(buff, binary.BigEndian, &i.Reserved); err != nil {
 		return
 	}
+	if i.Flag&QuotaFlag != 0 {
+		quotaCount := uint32(0)
+		if err = binary.Read(buff, binary.BigEndian, &quotaCount); err != nil {
+			return
+		}
+		i.QuotaIDs = make([]uint64, quotaCount)
+		if err = binary.Read(buff, binary.BigEndian, i.QuotaIDs); err != nil {
+			return
+		}
+	}
+	if i.Flag&InlineDataFlag != 0 {
+		i.InlineData = make([]byte, buff.Len())
+		copy(i.InlineData, buff.Bytes())
+		return
+	}
+	if i.Flag&ColdDataFlag != 0 {
+		coldSize := uint32(0)
+		if err = binary.Read(buff, binary.BigEndian, &coldSize); err != nil {
+			return
+		}
+		cold := make([]byte, coldSize)
+		if _, err = io.ReadFull(buff, cold); err != nil {
+			return
+		}
+		i.Cold = new(proto.ColdLocation)
+		if err = json.Unmarshal(cold, i.Cold); err != nil {
+			return
+		}
+	}
 	if buff.Len() == 0 {
 		return
 	}
@@ -392,6 +478,7 @@ func (i *Inode) UnmarshalValue(val []byte) (err error) {
 // AppendExtents append the extent to the btree.
 func (i *Inode) AppendExtents(eks []proto.ExtentKey, ct int64) (delExtents []proto.ExtentKey) {
 	i.Lock()
+	i.clearInlineData()
 	for _, ek := range eks {
 		delItems := i.Extents.Append(ek)
 		size := i.Extents.Size()
@@ -413,6 +500,7 @@ func (i *Inode) AppendExtentWithCheck(ek proto.ExtentKey, ct int64, discardExten
 	if status != proto.OpOk {
 		return
 	}
+	i.clearInlineData()
 	size := i.Extents.Size()
 	if i.Size < size {
 		i.Size = size
@@ -424,7 +512,16 @@ func (i *Inode) AppendExtentWithCheck(ek proto.ExtentKey, ct int64, discardExten
 
 func (i *Inode) ExtentsTruncate(length uint64, ct int64) (delExtents []proto.ExtentKey) {
 	i.Lock()
-	delExtents = i.Extents.Truncate(length)
+	holes := i.Extents.TruncateHole(length)
+	delExtents = append(i.Extents.Truncate(length), holes...)
+	if i.Flag&InlineDataFlag != 0 && uint64(len(i.InlineData)) > length {
+		i.InlineData = i.InlineData[:length]
+	}
+	if i.Cold != nil && i.Cold.Size > length {
+		cold := *i.Cold
+		cold.Size = length
+		i.Cold = &cold
+	}
 	i.Size = length
 	i.ModifyTime = ct
 	i.Generation++
@@ -432,6 +529,103 @@ func (i *Inode) ExtentsTruncate(length uint64, ct int64) (delExtents []proto.Ext
 	return
 }
 
+// ExtentsPunchHole removes the given range from the extents, the size of the inode is kept.
+func (i *Inode) ExtentsPunchHole(offset, size uint64, ct int64) (holes []proto.ExtentKey) {
+	i.Lock()
+	holes = i.Extents.PunchHole(offset, size)
+	if i.Flag&InlineDataFlag != 0 && offset < uint64(len(i.InlineData)) {
+		end := offset + size
+		if end > uint64(len(i.InlineData)) {
+			end = uint64(len(i.InlineData))
+		}
+		data := make([]byte, len(i.InlineData))
+		copy(data, i.InlineData)
+		for j := offset; j < end; j++ {
+			data[j] = 0
+		}
+		i.InlineData = data
+	}
+	i.ModifyTime = ct
+	i.Generation++
+	i.Unlock()
+	return
+}
+
+// WriteInline replaces the content of the file with the given data, which is stored inline in the inode.
+func (i *Inode) WriteInline(data []byte, ct int64) {
+	i.Lock()
+	i.InlineData = data
+	i.Flag |= InlineDataFlag
+	i.Size = uint64(len(data))
+	i.Generation++
+	i.ModifyTime = ct
+	i.Unlock()
+}
+
+// MigrateToCold replaces the extents of the inode with the location of its data in the cold tier,
+// and returns the extents to delete. The inode is left as it is if it has been modified since
+// the generation at which the data was read, or accessed or modified since coldBefore.
+func (i *Inode) MigrateToCold(gen uint64, coldBefore int64, cold *proto.ColdLocation) (delExtents []proto.ExtentKey, ok bool) {
+	i.Lock()
+	defer i.Unlock()
+	if i.Generation != gen || i.Flag&(InlineDataFlag|ColdDataFlag) != 0 ||
+		i.AccessTime >= coldBefore || i.ModifyTime >= coldBefore {
+		return
+	}
+	i.Extents.Range(func(ek proto.ExtentKey) bool {
+		delExtents = append(delExtents, ek)
+		return true
+	})
+	i.Extents = NewSortedExtents()
+	i.Cold = cold
+	i.Flag |= ColdDataFlag
+	i.Generation++
+	return delExtents, true
+}
+
+// ColdLocation returns the location of the data of the file in the cold tier, nil if it is not migrated.
+func (i *Inode) ColdLocation() (cold *proto.ColdLocation) {
+	i.RLock()
+	cold = i.Cold
+	i.RUnlock()
+	return
+}
+
+// GetQuotaIDs returns the IDs of the directory quotas counting the inode.
+func (i *Inode) GetQuotaIDs() (quotaIDs []uint64) {
+	i.RLock()
+	quotaIDs = i.QuotaIDs
+	i.RUnlock()
+	return
+}
+
+// SetQuotaIDs replaces the directory quotas counting the inode.
+func (i *Inode) SetQuotaIDs(quotaIDs []uint64) {
+	i.Lock()
+	i.QuotaIDs = quotaIDs
+	if len(quotaIDs) > 0 {
+		i.Flag |= QuotaFlag
+	} else {
+		i.Flag &^= QuotaFlag
+	}
+	i.Unlock()
+}
+
+// HasInlineData returns if the data of the file is stored inline.
+func (i *Inode) HasInlineData() (ok bool) {
+	i.RLock()
+	ok = i.Flag&InlineDataFlag != 0
+	i.RUnlock()
+	return
+}
+
+// clearInlineData drops the inline data once the data of the file has been moved to the extents.
+// The caller must hold the lock of the inode.
+func (i *Inode) clearInlineData() {
+	i.InlineData = nil
+	i.Flag &^= InlineDataFlag
+}
+
 // IncNLink increases the nLink value by one.
 func (i *Inode) IncNLink() {
 	i.Lock()
@@ -500,9 +694,59 @@ func (i *Inode) ShouldDelayDelete() (ok bool) {
 	return
 }
 
+// IsImmutable returns if the inode has been set immutable.
+func (i *Inode) IsImmutable() (ok bool) {
+	i.RLock()
+	ok = i.Flag&ImmutableFlag == ImmutableFlag
+	i.RUnlock()
+	return
+}
+
+// IsAppendOnly returns if the inode can only be appended to.
+func (i *Inode) IsAppendOnly() (ok bool) {
+	i.RLock()
+	ok = i.Flag&AppendOnlyFlag == AppendOnlyFlag
+	i.RUnlock()
+	return
+}
+
+// IsProtected returns if the inode is either immutable or append-only,
+// in which case it can be neither unlinked nor truncated.
+func (i *Inode) IsProtected() (ok bool) {
+	i.RLock()
+	ok = i.Flag&(ImmutableFlag|AppendOnlyFlag) != 0
+	i.RUnlock()
+	return
+}
+
+// CanAppendExtent checks whether the extent key only appends data to an append-only inode.
+// The key is accepted if it starts beyond the current size, or if it extends the last
+// extent key of the inode in place.
+func (i *Inode) CanAppendExtent(ek proto.ExtentKey) (ok bool) {
+	i.RLock()
+	defer i.RUnlock()
+	if ek.FileOffset >= i.Size {
+		return true
+	}
+	i.Extents.Range(func(cur proto.ExtentKey) bool {
+		if cur.FileOffset == ek.FileOffset && cur.PartitionId == ek.PartitionId &&
+			cur.ExtentId == ek.ExtentId && cur.ExtentOffset == ek.ExtentOffset &&
+			ek.Size >= cur.Size && cur.FileOffset+uint64(cur.Size) >= i.Size {
+			ok = true
+			return false
+		}
+		return true
+	})
+	return
+}
+
 // SetAttr sets the attributes of the inode.
 func (i *Inode) SetAttr(req *SetattrRequest) {
 	i.Lock()
+	if req.Valid&proto.AttrFlags != 0 {
+		userFlags := int32(proto.InodeUserFlagsMask)
+		i.Flag = (i.Flag &^ userFlags) | (int32(req.Flags) & userFlags)
+	}
 	if req.Valid&proto.AttrMode != 0 {
 		i.Type = req.Mode
 	}
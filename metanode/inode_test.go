@@ -0,0 +1,213 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestInodeFlags(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}, nil).(*metaPartition)
+	ino := NewInode(10, proto.Mode(0644))
+	ino.Size = 100
+	ino.Extents.Append(proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: 100})
+	if status := mp.fsmCreateInode(ino); status != proto.OpOk {
+		t.Fatalf("create inode: status(%v)", status)
+	}
+
+	req := &SetattrRequest{Inode: 10, Valid: proto.AttrFlags, Flags: proto.FlagAppendOnly}
+	if status := mp.fsmSetAttr(req); status != proto.OpOk {
+		t.Fatalf("set append-only: status(%v)", status)
+	}
+	if ino.CanAppendExtent(proto.ExtentKey{FileOffset: 50, PartitionId: 1, ExtentId: 2, Size: 10}) {
+		t.Fatalf("append-only inode accepts overwrite")
+	}
+	if !ino.CanAppendExtent(proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: 120}) {
+		t.Fatalf("append-only inode rejects extending the last extent")
+	}
+	if !ino.CanAppendExtent(proto.ExtentKey{FileOffset: 100, PartitionId: 1, ExtentId: 2, Size: 10}) {
+		t.Fatalf("append-only inode rejects append")
+	}
+
+	req = &SetattrRequest{Inode: 10, Valid: proto.AttrFlags, Flags: proto.FlagImmutable}
+	if status := mp.fsmSetAttr(req); status != proto.OpOk {
+		t.Fatalf("set immutable: status(%v)", status)
+	}
+	if status := mp.fsmSetAttr(&SetattrRequest{Inode: 10, Valid: proto.AttrMode, Mode: 0600}); status != proto.OpNotPerm {
+		t.Fatalf("chmod immutable inode: expect OpNotPerm, got status(%v)", status)
+	}
+	if resp := mp.fsmUnlinkInode(NewInode(10, 0)); resp.Status != proto.OpNotPerm {
+		t.Fatalf("unlink immutable inode: expect OpNotPerm, got status(%v)", resp.Status)
+	}
+
+	if status := mp.fsmSetAttr(&SetattrRequest{Inode: 10, Valid: proto.AttrFlags}); status != proto.OpOk {
+		t.Fatalf("clear flags: status(%v)", status)
+	}
+	if ino.IsProtected() {
+		t.Fatalf("flags not cleared: flag(%v)", ino.Flag)
+	}
+}
+
+func TestInodeInlineData(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}, nil).(*metaPartition)
+	ino := NewInode(20, proto.Mode(0644))
+	if status := mp.fsmCreateInode(ino); status != proto.OpOk {
+		t.Fatalf("create inode: status(%v)", status)
+	}
+
+	data := []byte("tiny file")
+	if status := mp.fsmWriteInline(&inodeWriteInline{Inode: 20, Data: data}); status != proto.OpOk {
+		t.Fatalf("write inline: status(%v)", status)
+	}
+	if !ino.HasInlineData() || ino.Size != uint64(len(data)) {
+		t.Fatalf("write inline: flag(%v) size(%v)", ino.Flag, ino.Size)
+	}
+
+	val, err := ino.Marshal()
+	if err != nil {
+		t.Fatalf("marshal: err(%v)", err)
+	}
+	ino2 := NewInode(0, 0)
+	if err = ino2.Unmarshal(val); err != nil {
+		t.Fatalf("unmarshal: err(%v)", err)
+	}
+	if !bytes.Equal(ino2.InlineData, data) || ino2.Extents.Len() != 0 {
+		t.Fatalf("unmarshal: inline(%s) extents(%v)", ino2.InlineData, ino2.Extents)
+	}
+
+	ino.ExtentsTruncate(4, 0)
+	if !bytes.Equal(ino.InlineData, data[:4]) {
+		t.Fatalf("truncate: inline(%s)", ino.InlineData)
+	}
+
+	ino.AppendExtents([]proto.ExtentKey{{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: 100}}, 0)
+	if ino.HasInlineData() || ino.InlineData != nil {
+		t.Fatalf("append extents: inline data not cleared, flag(%v)", ino.Flag)
+	}
+	if status := mp.fsmWriteInline(&inodeWriteInline{Inode: 20, Data: data}); status != proto.OpArgMismatchErr {
+		t.Fatalf("write inline with extents: expect OpArgMismatchErr, got status(%v)", status)
+	}
+}
+
+func TestInodeColdData(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}, nil).(*metaPartition)
+	ino := NewInode(30, proto.Mode(0644))
+	if status := mp.fsmCreateInode(ino); status != proto.OpOk {
+		t.Fatalf("create inode: status(%v)", status)
+	}
+	ino.AppendExtents([]proto.ExtentKey{{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: 100}}, 0)
+	gen := ino.Generation
+	coldBefore := Now.GetCurrentTime().Unix() + 1
+	cold := &proto.ColdLocation{Size: 100, Blobs: []proto.ColdBlob{{Offset: 0, Size: 100, Location: []byte(`{"blob":1}`)}}}
+
+	if status := mp.fsmMigrateToCold(&inodeMigrateToCold{Inode: 30, Generation: gen - 1, ColdBefore: coldBefore, Cold: cold}); status != proto.OpAgain {
+		t.Fatalf("migrate stale generation: expect OpAgain, got status(%v)", status)
+	}
+	if status := mp.fsmMigrateToCold(&inodeMigrateToCold{Inode: 30, Generation: gen, ColdBefore: ino.AccessTime, Cold: cold}); status != proto.OpAgain {
+		t.Fatalf("migrate accessed inode: expect OpAgain, got status(%v)", status)
+	}
+	if status := mp.fsmMigrateToCold(&inodeMigrateToCold{Inode: 30, Generation: gen, ColdBefore: coldBefore, Cold: cold}); status != proto.OpOk {
+		t.Fatalf("migrate: status(%v)", status)
+	}
+	if ino.ColdLocation() == nil || ino.Extents.Len() != 0 || ino.Size != 100 || ino.Generation != gen+1 {
+		t.Fatalf("migrate: cold(%v) extents(%v) size(%v) gen(%v)", ino.Cold, ino.Extents, ino.Size, ino.Generation)
+	}
+	if eks := <-mp.extDelCh; len(eks) != 1 || eks[0].ExtentId != 1 {
+		t.Fatalf("migrate: extents to delete(%v)", eks)
+	}
+
+	ino.AppendExtents([]proto.ExtentKey{{FileOffset: 200, PartitionId: 1, ExtentId: 2, Size: 10}}, 0)
+	val, err := ino.Marshal()
+	if err != nil {
+		t.Fatalf("marshal: err(%v)", err)
+	}
+	ino2 := NewInode(0, 0)
+	if err = ino2.Unmarshal(val); err != nil {
+		t.Fatalf("unmarshal: err(%v)", err)
+	}
+	if ino2.Cold == nil || ino2.Cold.Size != 100 || len(ino2.Cold.Blobs) != 1 ||
+		!bytes.Equal(ino2.Cold.Blobs[0].Location, cold.Blobs[0].Location) || ino2.Extents.Len() != 1 {
+		t.Fatalf("unmarshal: cold(%v) extents(%v)", ino2.Cold, ino2.Extents)
+	}
+
+	if status := mp.fsmWriteInline(&inodeWriteInline{Inode: 30, Data: []byte("tiny")}); status != proto.OpArgMismatchErr {
+		t.Fatalf("write inline to cold inode: expect OpArgMismatchErr, got status(%v)", status)
+	}
+	if status := mp.fsmExtentsPunchHole(&extentsPunchHole{Inode: 30, Offset: 10, Size: 10}); status != proto.OpArgMismatchErr {
+		t.Fatalf("punch hole in cold data: expect OpArgMismatchErr, got status(%v)", status)
+	}
+	ino.ExtentsTruncate(40, 0)
+	if ino.Cold.Size != 40 || cold.Size != 100 {
+		t.Fatalf("truncate: cold size(%v) original cold size(%v)", ino.Cold.Size, cold.Size)
+	}
+}
+
+func TestSetFlagsAuth(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "flags"}, nil).(*metaPartition)
+	mp.fsmCreateInode(NewInode(40, proto.Mode(0644)))
+	getVolOwnerBak := getVolOwner
+	defer func() {
+		getVolOwner = getVolOwnerBak
+		volOwnersMu.Lock()
+		delete(volOwners, "flags")
+		volOwnersMu.Unlock()
+	}()
+	var lookups int
+	cases := []struct {
+		name    string
+		owner   string
+		lookup  error
+		authKey string
+		expect  uint8
+	}{
+		{"owner unknown", "", fmt.Errorf("master unreachable"), "", proto.OpAgain},
+		{"without auth key", "cfs", nil, "", proto.OpNotPerm},
+		{"auth key of another owner", "cfs", nil, "0123456789abcdef0123456789abcdef", proto.OpNotPerm},
+	}
+	for _, c := range cases {
+		volOwnersMu.Lock()
+		delete(volOwners, "flags")
+		volOwnersMu.Unlock()
+		getVolOwner = func(volName string) (string, error) {
+			lookups++
+			return c.owner, c.lookup
+		}
+		data, _ := json.Marshal(&SetattrRequest{Inode: 40, Valid: proto.AttrFlags, Flags: proto.FlagImmutable, AuthKey: c.authKey})
+		p := &Packet{}
+		mp.SetAttr(data, p)
+		if p.ResultCode != c.expect {
+			t.Errorf("%v: expect status(%v), got status(%v)", c.name, c.expect, p.ResultCode)
+		}
+	}
+	if mp.inodeTree.Get(NewInode(40, 0)).(*Inode).IsImmutable() {
+		t.Errorf("flags set without the auth key of the owner")
+	}
+
+	// the auth key of the owner is cached
+	lookups = 0
+	for i := 0; i < 2; i++ {
+		if authKey, err := volAuthKey("flags"); err != nil || authKey != "7b2f1bf38b87d32470c4557c7ff02e75" {
+			t.Errorf("unexpected auth key(%v) err(%v)", authKey, err)
+		}
+	}
+	if lookups != 0 {
+		t.Errorf("owner looked up %v times, expect cached", lookups)
+	}
+}
@@ -19,13 +19,13 @@ import (
 	"fmt"
 	syslog "log"
 	"net"
-	_ "net/http/pprof"
 	"os"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/cubefs/cubefs/cmd/common"
 	"github.com/cubefs/cubefs/proto"
@@ -34,6 +34,7 @@ import (
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 const partitionPrefix = "partition_"
@@ -67,6 +68,8 @@ type metadataManager struct {
 	partitions         map[uint64]MetaPartition // Key: metaRangeId, Val: metaPartition
 	metaNode           *MetaNode
 	flDeleteBatchCount atomic.Value
+	reportTracker      *proto.ReportTracker
+	sloRecorder        *proto.SLORecorder
 }
 
 func (m *metadataManager) getPacketLabels(p *Packet) (labels map[string]string) {
@@ -76,7 +79,7 @@ func (m *metadataManager) getPacketLabels(p *Packet) (labels map[string]string)
 	labels[exporter.PartId] = ""
 	labels[exporter.Vol] = ""
 
-	if p.Opcode == proto.OpMetaNodeHeartbeat || p.Opcode == proto.OpCreateMetaPartition {
+	if p.Opcode == proto.OpMetaNodeHeartbeat || p.Opcode == proto.OpCreateMetaPartition || p.Opcode == proto.OpMetaFenceWrites {
 		// no partition info
 		return
 	}
@@ -96,17 +99,61 @@ func (m *metadataManager) getPacketLabels(p *Packet) (labels map[string]string)
 }
 
 // HandleMetadataOperation handles the metadata operations.
+// recordSLO counts the operations of the clients by the volumes for the SLOs of the volumes, the
+// operations rejected by the arguments or the states of the files are not counted as failed.
+func (m *metadataManager) recordSLO(p *Packet, volName string, start time.Time, err error) {
+	if (p.Opcode >= proto.OpCreateMetaPartition && p.Opcode <= proto.OpMetaFenceWrites) ||
+		p.Opcode == proto.OpMetaFreeInodesOnRaftFollower || p.Opcode >= proto.OpMetaBatchDeleteInode {
+		return
+	}
+	// the writes refused by the fence of a snapshot are retried by the clients
+	if fencedOps[p.Opcode] && p.ResultCode == proto.OpAgain && isWriteFenced(volName) {
+		return
+	}
+	var failed = err != nil
+	switch p.ResultCode {
+	case proto.OpErr, proto.OpAgain, proto.OpDiskErr, proto.OpIntraGroupNetErr:
+		failed = true
+	}
+	m.sloRecorder.Record(volName, time.Since(start), failed)
+}
+
 func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remoteAddr string) (err error) {
 	log.LogInfof("HandleMetadataOperation input info op (%s), remote %s", p.String(), remoteAddr)
 
 	metric := exporter.NewTPCnt(p.GetOpMsg())
 	labels := m.getPacketLabels(p)
+	auditRec := m.newOpAuditRecord(p, remoteAddr, labels[exporter.Vol])
+	// the request forwarded to the leader carries the span of this node
+	span := tracing.StartServerSpan(p.GetOpMsg(), p.TraceContext)
+	if span != nil {
+		span.SetAttribute("partition", p.PartitionID)
+		span.SetAttribute("reqID", p.ReqID)
+		if p.RequestID.IsValid() {
+			span.SetAttribute("requestID", p.RequestID.String())
+		}
+		p.TraceContext = span.Context()
+	}
+	start := time.Now()
 	defer func() {
 		metric.SetWithLabels(err, labels)
+		m.logOpAudit(auditRec, p)
+		m.recordSLO(p, labels[exporter.Vol], start, err)
+		if span != nil {
+			spanErr := err
+			if spanErr == nil && p.ResultCode != proto.OpOk {
+				spanErr = errors.New(p.GetResultMsg())
+			}
+			span.End(spanErr)
+		}
 	}()
 
 	log.LogDebugf("HandleMetadataOperation input info op (%s), remote %s", p.GetOpMsg(), remoteAddr)
 
+	if m.checkWriteFence(conn, p, labels[exporter.Vol]) {
+		return
+	}
+
 	switch p.Opcode {
 	case proto.OpMetaCreateInode:
 		err = m.opCreateInode(conn, p, remoteAddr)
@@ -154,6 +201,10 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opMetaExtentsDel(conn, p, remoteAddr)
 	case proto.OpMetaTruncate:
 		err = m.opMetaExtentsTruncate(conn, p, remoteAddr)
+	case proto.OpMetaPunchHole:
+		err = m.opMetaExtentsPunchHole(conn, p, remoteAddr)
+	case proto.OpMetaWriteInline:
+		err = m.opMetaWriteInline(conn, p, remoteAddr)
 	case proto.OpMetaLookup:
 		err = m.opMetaLookup(conn, p, remoteAddr)
 	case proto.OpDeleteMetaPartition:
@@ -170,8 +221,20 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opRemoveMetaPartitionRaftMember(conn, p, remoteAddr)
 	case proto.OpMetaPartitionTryToLeader:
 		err = m.opMetaPartitionTryToLeader(conn, p, remoteAddr)
+	case proto.OpAddMetaPartitionRaftLearner:
+		err = m.opAddMetaPartitionRaftLearner(conn, p, remoteAddr)
+	case proto.OpPromoteMetaPartitionLearner:
+		err = m.opPromoteMetaPartitionLearner(conn, p, remoteAddr)
+	case proto.OpMetaPartitionTransferLeader:
+		err = m.opMetaPartitionTransferLeader(conn, p, remoteAddr)
+	case proto.OpMetaMigrateToCold:
+		err = m.opMetaMigrateToCold(conn, p, remoteAddr)
+	case proto.OpMetaFenceWrites:
+		err = m.opMetaFenceWrites(conn, p, remoteAddr)
 	case proto.OpMetaBatchInodeGet:
 		err = m.opMetaBatchInodeGet(conn, p, remoteAddr)
+	case proto.OpMetaBatchStat:
+		err = m.opMetaBatchStat(conn, p, remoteAddr)
 	case proto.OpMetaDeleteInode:
 		err = m.opMetaDeleteInode(conn, p, remoteAddr)
 	case proto.OpMetaBatchDeleteInode:
@@ -202,6 +265,36 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opAppendMultipart(conn, p, remoteAddr)
 	case proto.OpGetMultipart:
 		err = m.opGetMultipart(conn, p, remoteAddr)
+	// operations for object versions
+	case proto.OpPutObjectVersion:
+		err = m.opPutObjectVersion(conn, p, remoteAddr)
+	case proto.OpGetObjectVersion:
+		err = m.opGetObjectVersion(conn, p, remoteAddr)
+	case proto.OpRemoveObjectVersion:
+		err = m.opRemoveObjectVersion(conn, p, remoteAddr)
+	case proto.OpListObjectVersions:
+		err = m.opListObjectVersions(conn, p, remoteAddr)
+	// operations for subtree snapshots
+	case proto.OpMetaCreateSnapshot:
+		err = m.opCreateSnapshot(conn, p, remoteAddr)
+	case proto.OpMetaSealSnapshot:
+		err = m.opSealSnapshot(conn, p, remoteAddr)
+	case proto.OpMetaDeleteSnapshot:
+		err = m.opDeleteSnapshot(conn, p, remoteAddr)
+	case proto.OpMetaListSnapshots:
+		err = m.opListSnapshots(conn, p, remoteAddr)
+	case proto.OpMetaRestoreSnapshot:
+		err = m.opRestoreSnapshot(conn, p, remoteAddr)
+	// operations for file locks
+	case proto.OpMetaSetLock:
+		err = m.opSetLock(conn, p, remoteAddr)
+	case proto.OpMetaGetLock:
+		err = m.opGetLock(conn, p, remoteAddr)
+	case proto.OpMetaRenewLocks:
+		err = m.opRenewLocks(conn, p, remoteAddr)
+	// operations for directory quotas
+	case proto.OpMetaSetInodeQuota:
+		err = m.opSetInodeQuota(conn, p, remoteAddr)
 	default:
 		err = fmt.Errorf("%s unknown Opcode: %d, reqId: %d", remoteAddr,
 			p.Opcode, p.GetReqID())
@@ -417,16 +510,19 @@ func (m *metadataManager) createPartition(request *proto.CreateMetaPartitionRequ
 	log.LogInfof("start create meta Partition, partition %s", partitionId)
 
 	mpc := &MetaPartitionConfig{
-		PartitionId: request.PartitionID,
-		VolName:     request.VolName,
-		Start:       request.Start,
-		End:         request.End,
-		Cursor:      request.Start,
-		Peers:       request.Members,
-		RaftStore:   m.raftStore,
-		NodeId:      m.nodeId,
-		RootDir:     path.Join(m.rootDir, partitionPrefix+partitionId),
-		ConnPool:    m.connPool,
+		PartitionId:     request.PartitionID,
+		VolName:         request.VolName,
+		Start:           request.Start,
+		End:             request.End,
+		Cursor:          request.Start,
+		Peers:           request.Members,
+		Learners:        request.Learners,
+		Witnesses:       request.Witnesses,
+		RaftStore:       m.raftStore,
+		CaseInsensitive: request.CaseInsensitive,
+		NodeId:          m.nodeId,
+		RootDir:         path.Join(m.rootDir, partitionPrefix+partitionId),
+		ConnPool:        m.connPool,
 	}
 	mpc.AfterStop = func() {
 		m.detachPartition(request.PartitionID)
@@ -495,12 +591,14 @@ func (m *metadataManager) MarshalJSON() (data []byte, err error) {
 // NewMetadataManager returns a new metadata manager.
 func NewMetadataManager(conf MetadataManagerConfig, metaNode *MetaNode) MetadataManager {
 	return &metadataManager{
-		nodeId:     conf.NodeID,
-		zoneName:   conf.ZoneName,
-		rootDir:    conf.RootDir,
-		raftStore:  conf.RaftStore,
-		partitions: make(map[uint64]MetaPartition),
-		metaNode:   metaNode,
+		nodeId:        conf.NodeID,
+		zoneName:      conf.ZoneName,
+		rootDir:       conf.RootDir,
+		raftStore:     conf.RaftStore,
+		partitions:    make(map[uint64]MetaPartition),
+		metaNode:      metaNode,
+		reportTracker: proto.NewReportTracker(proto.DefaultFullReportInterval),
+		sloRecorder:   proto.NewSLORecorder(),
 	}
 }
 
@@ -57,6 +57,10 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 			resp.Result = err.Error()
 			goto end
 		}
+		replicaAddrs.Update(req.ReplicaAddrs)
+		setBlobStoreAddrs(req.BlobStoreAddrs)
+		setQuotaTokens(req.VolQuotaTokens)
+		masterClient.NodeAPI().SetTaskCodec(req.TaskCodec)
 
 		// collect memory info
 		resp.Total = configTotalMem
@@ -76,12 +80,16 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 				VolName:     mConf.VolName,
 				InodeCnt:    uint64(partition.GetInodeTree().Len()),
 				DentryCnt:   uint64(partition.GetDentryTree().Len()),
+				Size:        partition.GetSize(),
 			}
 			addr, isLeader := partition.IsLeader()
 			if addr == "" {
 				mpr.Status = proto.Unavailable
 			}
 			mpr.IsLeader = isLeader
+			if isLeader {
+				mpr.QuotaUsages = partition.GetQuotaUsages()
+			}
 			if mConf.Cursor >= mConf.End {
 				mpr.Status = proto.ReadOnly
 			}
@@ -91,7 +99,11 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 			resp.MetaPartitionReports = append(resp.MetaPartitionReports, mpr)
 			return true
 		})
+		m.trimPartitionReports(resp, req.ReportSeq)
+		resp.SLOReports = m.sloRecorder.Take()
 		resp.ZoneName = m.zoneName
+		resp.Version = proto.BuildVersion()
+		resp.LocalTime = time.Now().UnixNano()
 		resp.Status = proto.TaskSucceeds
 	end:
 		adminTask.Request = nil
@@ -105,6 +117,26 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 	return
 }
 
+// trimPartitionReports leaves the partition reports changed since the report acked by the master.
+func (m *metadataManager) trimPartitionReports(resp *proto.MetaNodeHeartbeatResponse, ackedSeq uint64) {
+	reports := make(map[uint64]interface{}, len(resp.MetaPartitionReports))
+	for _, mpr := range resp.MetaPartitionReports {
+		reports[mpr.PartitionID] = *mpr
+	}
+	delta := m.reportTracker.Report(ackedSeq, reports)
+	resp.ReportSeq, resp.BaseReportSeq, resp.RemovedPartitions = delta.Seq, delta.Base, delta.Removed
+	if delta.Base == 0 {
+		return
+	}
+	changed := resp.MetaPartitionReports[:0]
+	for _, mpr := range resp.MetaPartitionReports {
+		if delta.Changed(mpr.PartitionID) {
+			changed = append(changed, mpr)
+		}
+	}
+	resp.MetaPartitionReports = changed
+}
+
 func (m *metadataManager) opCreateMetaPartition(conn net.Conn, p *Packet,
 	remoteAddr string) (err error) {
 	defer func() {
@@ -713,6 +745,58 @@ func (m *metadataManager) opMetaExtentsTruncate(conn net.Conn, p *Packet,
 	return
 }
 
+func (m *metadataManager) opMetaExtentsPunchHole(conn net.Conn, p *Packet,
+	remoteAddr string) (err error) {
+	req := &ExtentsPunchHoleReq{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	mp.ExtentsPunchHole(req, p)
+	m.respondToClient(conn, p)
+	log.LogDebugf("%s [OpMetaPunchHole] req: %d - %v, resp body: %v, "+
+		"resp body: %s", remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaWriteInline(conn net.Conn, p *Packet,
+	remoteAddr string) (err error) {
+	req := &WriteInlineReq{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	mp.WriteInline(req, p)
+	m.respondToClient(conn, p)
+	log.LogDebugf("%s [OpMetaWriteInline] req: %d - ino(%v) size(%v), resp: %v",
+		remoteAddr, p.GetReqID(), req.Inode, len(req.Data), p.GetResultMsg())
+	return
+}
+
 // Delete a meta partition.
 func (m *metadataManager) opDeleteMetaPartition(conn net.Conn,
 	p *Packet, remoteAddr string) (err error) {
@@ -882,6 +966,138 @@ func (m *metadataManager) opDecommissionMetaPartition(conn net.Conn,
 	return
 }
 
+func (m *metadataManager) opAddMetaPartitionRaftLearner(conn net.Conn,
+	p *Packet, remoteAddr string) (err error) {
+	var reqData []byte
+	req := &proto.AddMetaPartitionRaftLearnerRequest{}
+	adminTask := &proto.AdminTask{
+		Request: req,
+	}
+
+	defer func() {
+		if err != nil {
+			log.LogInfof("pkt %s remote %s add raft learner failed, req %v, err %s", p.String(), remoteAddr, adminTask, err.Error())
+			return
+		}
+
+		log.LogInfof("pkt %s, remote %s add raft learner success, req %v", p.String(), remoteAddr, adminTask)
+	}()
+
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpTryOtherAddr, ([]byte)(proto.ErrMetaPartitionNotExists.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+
+	if mp.IsExsitPeer(req.AddLearner) {
+		p.PacketOkReply()
+		m.respondToClient(conn, p)
+		return
+	}
+
+	if !m.serveProxy(conn, mp, p) {
+		return nil
+	}
+	if req.AddLearner.ID == 0 {
+		err = errors.NewErrorf("[opAddMetaPartitionRaftLearner]: partitionID= %d, "+
+			"unavali AddLearnerID %v", req.PartitionId, req.AddLearner.ID)
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	reqData, err = json.Marshal(req)
+	if err != nil {
+		err = errors.NewErrorf("[opAddMetaPartitionRaftLearner]: partitionID= %d, "+
+			"Marshal %s", req.PartitionId, err)
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	_, err = mp.ChangeMember(raftProto.ConfAddNode,
+		raftProto.Peer{ID: req.AddLearner.ID, Type: raftProto.PeerLearner}, reqData)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	p.PacketOkReply()
+	m.respondToClient(conn, p)
+	return
+}
+
+func (m *metadataManager) opPromoteMetaPartitionLearner(conn net.Conn,
+	p *Packet, remoteAddr string) (err error) {
+	var reqData []byte
+	req := &proto.PromoteMetaPartitionLearnerRequest{}
+	adminTask := &proto.AdminTask{
+		Request: req,
+	}
+
+	defer func() {
+		if err != nil {
+			log.LogInfof("pkt %s remote %s promote raft learner failed, req %v, err %s", p.String(), remoteAddr, adminTask, err.Error())
+			return
+		}
+
+		log.LogInfof("pkt %s, remote %s promote raft learner success, req %v", p.String(), remoteAddr, adminTask)
+	}()
+
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpTryOtherAddr, ([]byte)(proto.ErrMetaPartitionNotExists.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+
+	if mp.IsExsitPeer(req.PromoteLearner) && !mp.IsLearner(req.PromoteLearner) {
+		p.PacketOkReply()
+		m.respondToClient(conn, p)
+		return
+	}
+
+	if !m.serveProxy(conn, mp, p) {
+		return nil
+	}
+	if err = mp.CanPromoteLearner(req.PromoteLearner); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	reqData, err = json.Marshal(req)
+	if err != nil {
+		err = errors.NewErrorf("[opPromoteMetaPartitionLearner]: partitionID= %d, "+
+			"Marshal %s", req.PartitionId, err)
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	_, err = mp.ChangeMember(raftProto.ConfUpdateNode,
+		raftProto.Peer{ID: req.PromoteLearner.ID, Type: raftProto.PeerNormal}, reqData)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	p.PacketOkReply()
+	m.respondToClient(conn, p)
+	return
+}
+
 func (m *metadataManager) opAddMetaPartitionRaftMember(conn net.Conn,
 	p *Packet, remoteAddr string) (err error) {
 	var reqData []byte
@@ -1055,34 +1271,16 @@ func (m *metadataManager) opMetaBatchInodeGet(conn net.Conn, p *Packet,
 	return
 }
 
-func (m *metadataManager) opMetaPartitionTryToLeader(conn net.Conn, p *Packet,
-	remoteAddr string) (err error) {
-	mp, err := m.getPartition(p.PartitionID)
-	if err != nil {
-		goto errDeal
-	}
-	if err = mp.TryToLeader(p.PartitionID); err != nil {
-		goto errDeal
-	}
-	p.PacketOkReply()
-	m.respondToClient(conn, p)
-	return
-errDeal:
-	p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
-	m.respondToClient(conn, p)
-	return
-}
-
-func (m *metadataManager) opMetaDeleteInode(conn net.Conn, p *Packet,
+func (m *metadataManager) opMetaBatchStat(conn net.Conn, p *Packet,
 	remoteAddr string) (err error) {
-	req := &proto.DeleteInodeRequest{}
+	req := &proto.BatchStatRequest{}
 	if err = json.Unmarshal(p.Data, req); err != nil {
 		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
 		m.respondToClient(conn, p)
 		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
 		return
 	}
-	mp, err := m.getPartition(req.PartitionId)
+	mp, err := m.getPartition(req.PartitionID)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
 		m.respondToClient(conn, p)
@@ -1092,62 +1290,208 @@ func (m *metadataManager) opMetaDeleteInode(conn net.Conn, p *Packet,
 	if !m.serveProxy(conn, mp, p) {
 		return
 	}
-	err = mp.DeleteInode(req, p)
-	_ = m.respondToClient(conn, p)
-	log.LogDebugf("%s [opMetaDeleteInode] req: %d - %v, resp: %v, body: %s",
-		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	err = mp.BatchStat(req, p)
+	m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaBatchStat] req: %d - %v, resp: %v, "+
+		"body: %s", remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
 }
 
-func (m *metadataManager) opMetaBatchDeleteInode(conn net.Conn, p *Packet,
+func (m *metadataManager) opMetaPartitionTryToLeader(conn net.Conn, p *Packet,
 	remoteAddr string) (err error) {
-	var req *proto.DeleteInodeBatchRequest
-	if err = json.Unmarshal(p.Data, &req); err != nil {
-		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
-		m.respondToClient(conn, p)
-		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
-		return
-	}
-
-	mp, err := m.getPartition(req.PartitionId)
+	mp, err := m.getPartition(p.PartitionID)
 	if err != nil {
-		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
-		m.respondToClient(conn, p)
-		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
-		return
+		goto errDeal
 	}
-	if !m.serveProxy(conn, mp, p) {
-		return
+	if err = mp.TryToLeader(p.PartitionID); err != nil {
+		goto errDeal
 	}
-	err = mp.DeleteInodeBatch(req, p)
-	log.LogDebugf("%s [opMetaDeleteInode] req: %d - %v, resp: %v, body: %s",
-		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
-
-	_ = m.respondToClient(conn, p)
-
+	p.PacketOkReply()
+	m.respondToClient(conn, p)
+	return
+errDeal:
+	p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+	m.respondToClient(conn, p)
 	return
 }
 
-func (m *metadataManager) opMetaSetXAttr(conn net.Conn, p *Packet, remoteAddr string) (err error) {
-	req := &proto.SetXAttrRequest{}
-	if err = json.Unmarshal(p.Data, req); err != nil {
+func (m *metadataManager) opMetaPartitionTransferLeader(conn net.Conn,
+	p *Packet, remoteAddr string) (err error) {
+	req := &proto.MetaPartitionTransferLeaderRequest{}
+	adminTask := &proto.AdminTask{
+		Request: req,
+	}
+
+	defer func() {
+		if err != nil {
+			log.LogInfof("pkt %s remote %s transfer raft leader failed, req %v, err %s", p.String(), remoteAddr, adminTask, err.Error())
+			return
+		}
+
+		log.LogInfof("pkt %s, remote %s transfer raft leader success, req %v", p.String(), remoteAddr, adminTask)
+	}()
+
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
 		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
 		m.respondToClient(conn, p)
-		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
-		return
+		return err
 	}
 	mp, err := m.getPartition(req.PartitionId)
 	if err != nil {
-		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		p.PacketErrorWithBody(proto.OpTryOtherAddr, ([]byte)(proto.ErrMetaPartitionNotExists.Error()))
 		m.respondToClient(conn, p)
-		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
-		return
+		return err
 	}
+
 	if !m.serveProxy(conn, mp, p) {
-		return
+		return nil
 	}
-	err = mp.SetXAttr(req, p)
-	_ = m.respondToClient(conn, p)
+	if err = mp.TransferLeader(req.TargetNodeID); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return err
+	}
+	p.PacketOkReply()
+	m.respondToClient(conn, p)
+	return
+}
+
+// opMetaMigrateToCold migrates the cold files of the partition to the blob store. The task is
+// acked at once and the result is sent to the master when the migration ends.
+func (m *metadataManager) opMetaMigrateToCold(conn net.Conn, p *Packet,
+	remoteAddr string) (err error) {
+	data := p.Data
+	m.responseAckOKToMaster(conn, p)
+
+	var (
+		req       = &proto.MigrateToColdRequest{}
+		resp      = &proto.MigrateToColdResponse{}
+		adminTask = &proto.AdminTask{
+			Request: req,
+		}
+	)
+	go func() {
+		start := time.Now()
+		decode := json.NewDecoder(bytes.NewBuffer(data))
+		decode.UseNumber()
+		if err := decode.Decode(adminTask); err != nil {
+			resp.Status = proto.TaskFailed
+			resp.Result = err.Error()
+		} else if mp, err := m.getPartition(req.PartitionID); err != nil {
+			resp.PartitionID = req.PartitionID
+			resp.Status = proto.TaskFailed
+			resp.Result = err.Error()
+		} else {
+			resp = mp.MigrateToCold(req)
+		}
+		adminTask.Request = nil
+		adminTask.Response = resp
+		m.respondToMaster(adminTask)
+		log.LogInfof("%s pkt %s, migrate to cold req:%v resp:%v, cost %s",
+			remoteAddr, p.String(), req, resp, time.Since(start).String())
+	}()
+	return
+}
+
+// opMetaFenceWrites fences the writes of the clients to the volume on this node, the master
+// does so on all the nodes of the volume while taking a consistent snapshot of it.
+func (m *metadataManager) opMetaFenceWrites(conn net.Conn, p *Packet,
+	remoteAddr string) (err error) {
+	req := &proto.FenceWritesRequest{}
+	adminTask := &proto.AdminTask{
+		Request: req,
+	}
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	setWriteFence(req.VolName, time.Duration(req.TTL)*time.Second)
+	p.PacketOkReply()
+	m.respondToClient(conn, p)
+	log.LogInfof("%s pkt %s, fence writes of vol(%v) ttl(%vs)", remoteAddr, p.String(), req.VolName, req.TTL)
+	return
+}
+
+func (m *metadataManager) opMetaDeleteInode(conn net.Conn, p *Packet,
+	remoteAddr string) (err error) {
+	req := &proto.DeleteInodeRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.DeleteInode(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaDeleteInode] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaBatchDeleteInode(conn net.Conn, p *Packet,
+	remoteAddr string) (err error) {
+	var req *proto.DeleteInodeBatchRequest
+	if err = json.Unmarshal(p.Data, &req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.DeleteInodeBatch(req, p)
+	log.LogDebugf("%s [opMetaDeleteInode] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+
+	_ = m.respondToClient(conn, p)
+
+	return
+}
+
+func (m *metadataManager) opMetaSetXAttr(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.SetXAttrRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.SetXAttr(req, p)
+	_ = m.respondToClient(conn, p)
 	log.LogDebugf("%s [opMetaSetXAttr] req: %d - %v, resp: %v, body: %s",
 		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
 	return
@@ -1416,3 +1760,320 @@ func (m *metadataManager) opListMultipart(conn net.Conn, p *Packet, remote strin
 	_ = m.respondToClient(conn, p)
 	return
 }
+
+func (m *metadataManager) opPutObjectVersion(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.PutObjectVersionRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.PutObjectVersion(req, p)
+	_ = m.respondToClient(conn, p)
+	return
+}
+
+func (m *metadataManager) opGetObjectVersion(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.GetObjectVersionRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.GetObjectVersion(req, p)
+	_ = m.respondToClient(conn, p)
+	return
+}
+
+func (m *metadataManager) opRemoveObjectVersion(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.RemoveObjectVersionRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.RemoveObjectVersion(req, p)
+	_ = m.respondToClient(conn, p)
+	return
+}
+
+func (m *metadataManager) opListObjectVersions(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.ListObjectVersionsRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.ListObjectVersions(req, p)
+	_ = m.respondToClient(conn, p)
+	return
+}
+
+func (m *metadataManager) opCreateSnapshot(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.CreateSnapshotRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.CreateSnapshot(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opCreateSnapshot] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opSealSnapshot(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.SealSnapshotRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.SealSnapshot(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opSealSnapshot] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opDeleteSnapshot(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.DeleteSnapshotRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.DeleteSnapshot(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opDeleteSnapshot] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opListSnapshots(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.ListSnapshotsRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.ListSnapshots(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opListSnapshots] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opRestoreSnapshot(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.RestoreSnapshotRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.RestoreSnapshot(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opRestoreSnapshot] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opSetLock(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.SetLockRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.SetLock(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opSetLock] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opGetLock(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.GetLockRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.GetLock(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opGetLock] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opRenewLocks(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.RenewLocksRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.RenewLocks(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opRenewLocks] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opSetInodeQuota(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.SetInodeQuotaRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	err = mp.SetInodeQuota(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opSetInodeQuota] req: %d - %v, resp: %v, body: %s",
+		remote, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
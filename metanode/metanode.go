@@ -30,6 +30,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/raftstore"
 	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/audit"
 	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
@@ -44,6 +45,7 @@ var (
 	smuxPortShift  int
 	smuxPool       *util.SmuxConnectPool
 	smuxPoolCfg    = util.DefaultSmuxConnPoolConfig()
+	replicaAddrs   = util.NewReplicaAddrs() // replication addresses of the other meta nodes
 )
 
 // The MetaNode manages the dentry and inode information of the meta partitions on a meta node.
@@ -55,6 +57,7 @@ type MetaNode struct {
 	raftDir           string // root dir of the raftStore log
 	metadataManager   MetadataManager
 	localAddr         string
+	replicaIP         string // ip of the dedicated network for the raft traffic
 	clusterId         string
 	raftStore         raftstore.RaftStore
 	raftHeartbeatPort string
@@ -65,6 +68,16 @@ type MetaNode struct {
 	metrics           *MetaNodeMetrics
 	tickInterval      int
 	raftRecvBufSize   int
+	raftPreVote       bool
+	raftWalFileSize   int
+	raftWalCompress   bool
+	raftWalRepair     bool
+	raftPropBatch     int
+	raftSnapRateLimit int64
+	raftTLSCertFile   string
+	raftTLSKeyFile    string
+	raftTLSCAFile     string
+	auditLogger       *audit.Logger // nil if the operations are not audited
 
 	control common.Control
 }
@@ -169,6 +182,7 @@ func doShutdown(s common.Server) {
 	m.stopSmuxServer()
 	m.stopMetaManager()
 	m.stopRaftServer()
+	m.auditLogger.Stop()
 }
 
 // Sync blocks the invoker's goroutine until the meta node shuts down.
@@ -182,6 +196,10 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 		return
 	}
 	m.localAddr = cfg.GetString(cfgLocalIP)
+	m.replicaIP = cfg.GetString(cfgReplicaIP)
+	if m.replicaIP != "" && !util.IsIPV4(m.replicaIP) {
+		return fmt.Errorf("invalid replicaIP %v", m.replicaIP)
+	}
 	m.listen = cfg.GetString(proto.ListenPort)
 	serverPort = m.listen
 	m.metadataDir = cfg.GetString(cfgMetadataDir)
@@ -190,6 +208,15 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	m.raftReplicatePort = cfg.GetString(cfgRaftReplicaPort)
 	m.tickInterval = int(cfg.GetFloat(cfgTickInterval))
 	m.raftRecvBufSize = int(cfg.GetInt(cfgRaftRecvBufSize))
+	m.raftPreVote = cfg.GetBool(cfgRaftPreVote)
+	m.raftWalFileSize = int(cfg.GetInt(cfgRaftWalFileSize)) * util.MB
+	m.raftWalCompress = cfg.GetBool(cfgRaftWalCompress)
+	m.raftWalRepair = cfg.GetBool(cfgRaftWalRepair)
+	m.raftPropBatch = int(cfg.GetInt(cfgRaftPropBatch))
+	m.raftSnapRateLimit = cfg.GetInt64(cfgRaftSnapRateLimit)
+	m.raftTLSCertFile = cfg.GetString(cfgRaftTLSCertFile)
+	m.raftTLSKeyFile = cfg.GetString(cfgRaftTLSKeyFile)
+	m.raftTLSCAFile = cfg.GetString(cfgRaftTLSCAFile)
 	m.zoneName = cfg.GetString(cfgZoneName)
 	configTotalMem, _ = strconv.ParseUint(cfg.GetString(cfgTotalMem), 10, 64)
 
@@ -235,6 +262,7 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	}
 
 	log.LogInfof("[parseConfig] load localAddr[%v].", m.localAddr)
+	log.LogInfof("[parseConfig] load replicaIP[%v].", m.replicaIP)
 	log.LogInfof("[parseConfig] load listen[%v].", m.listen)
 	log.LogInfof("[parseConfig] load metadataDir[%v].", m.metadataDir)
 	log.LogInfof("[parseConfig] load raftDir[%v].", m.raftDir)
@@ -249,6 +277,15 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 		smuxPool = util.NewSmuxConnectPool(smuxPoolCfg)
 	}
 
+	var auditTargets []*audit.SinkConfig
+	if auditTargets, err = audit.ParseSinkConfigs(cfg.GetSlice(cfgAuditTargets)); err != nil {
+		return
+	}
+	if m.auditLogger, err = audit.NewLogger(cfg.GetString("role"), auditTargets); err != nil {
+		return
+	}
+	log.LogInfof("[parseConfig] load auditTargets[%v].", len(auditTargets))
+
 	addrs := cfg.GetSlice(proto.MasterAddr)
 	masters := make([]string, 0, len(addrs))
 	for _, addr := range addrs {
@@ -363,7 +400,7 @@ func (m *MetaNode) register() (err error) {
 			step++
 		}
 		var nodeID uint64
-		if nodeID, err = masterClient.NodeAPI().AddMetaNode(nodeAddress, m.zoneName); err != nil {
+		if nodeID, err = masterClient.NodeAPI().AddMetaNode(nodeAddress, m.zoneName, m.replicaAddr()); err != nil {
 			log.LogErrorf("register: register to master fail: address(%v) err(%s)", nodeAddress, err)
 			time.Sleep(3 * time.Second)
 			continue
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 //metrics
@@ -47,6 +48,23 @@ func (m *MetaNode) upatePartitionMetrics(mp *metaPartition) {
 	if it != nil {
 		exporter.NewGauge("mpInodeCount").SetWithLabels(float64(it.Len()), labels)
 	}
+	if _, isLeader := mp.IsLeader(); !isLeader {
+		return
+	}
+	// Only the leader deletes, so the backlog of deletions is reported by the leader.
+	exporter.NewGauge("mpFreeListInodeCount").SetWithLabels(float64(mp.freeList.Len()), labels)
+	exporter.NewGauge("mpFreeListLagSeconds").SetWithLabels(mp.freeList.Lag().Seconds(), labels)
+	pending, err := mp.pendingDelExtents()
+	if err != nil {
+		log.LogWarnf("upatePartitionMetrics: partitionID(%v) count pending extents: %v", mp.config.PartitionId, err)
+		return
+	}
+	var lag time.Duration
+	if pending > 0 {
+		lag = mp.delExtentsLag()
+	}
+	exporter.NewGauge("mpDelExtentPendingCount").SetWithLabels(float64(pending), labels)
+	exporter.NewGauge("mpDelExtentLagSeconds").SetWithLabels(lag.Seconds(), labels)
 }
 
 func (m *MetaNode) collectPartitionMetrics() {
@@ -146,6 +146,21 @@ func (m *Parts) LoadOrStore(part *Part) (actual *Part, stored bool) {
 	return
 }
 
+// Replace stores the part and returns the part of the same id replaced by it.
+func (m *Parts) Replace(part *Part) (replaced *Part) {
+	i := sort.Search(len(*m), func(i int) bool {
+		return (*m)[i].ID >= part.ID
+	})
+	if i < len(*m) && (*m)[i].ID == part.ID {
+		replaced = (*m)[i]
+		(*m)[i] = part
+		return
+	}
+	*m = append(*m, part)
+	m.sort()
+	return
+}
+
 // Deprecated
 func (m *Parts) Insert(part *Part, replace bool) (success bool) {
 	i := sort.Search(len(*m), func(i int) bool {
@@ -334,6 +349,15 @@ func (m *Multipart) LoadOrStorePart(part *Part) (actual *Part, stored bool) {
 	return
 }
 
+func (m *Multipart) ReplacePart(part *Part) (replaced *Part) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.parts == nil {
+		m.parts = PartsFromBytes(nil)
+	}
+	return m.parts.Replace(part)
+}
+
 // Deprecated
 func (m *Multipart) InsertPart(part *Part, replace bool) (success bool) {
 	m.mu.Lock()
@@ -121,6 +121,33 @@ func TestMUParts_Modify(t *testing.T) {
 	}
 }
 
+func TestMUParts_Replace(t *testing.T) {
+	var parts = PartsFromBytes(nil)
+	for _, id := range []uint16{3, 1, 2} {
+		if replaced := parts.Replace(&Part{ID: id, MD5: "origin", Inode: uint64(id)}); replaced != nil {
+			t.Fatalf("part id[%v] replaced unexpectedly", id)
+		}
+	}
+	if parts.Len() != 3 {
+		t.Fatalf("parts length mismatch: expect 3 actual %v", parts.Len())
+	}
+	replaced := parts.Replace(&Part{ID: 2, MD5: "new", Inode: 20})
+	if replaced == nil || replaced.MD5 != "origin" || replaced.Inode != 2 {
+		t.Fatalf("replaced part mismatch: %v", replaced)
+	}
+	if parts.Len() != 3 {
+		t.Fatalf("parts length mismatch: expect 3 actual %v", parts.Len())
+	}
+	if part, found := parts.Search(2); !found || part.MD5 != "new" || part.Inode != 20 {
+		t.Fatalf("part id[2] not replaced: %v", part)
+	}
+	for i, part := range parts {
+		if part.ID != uint16(i+1) {
+			t.Fatalf("parts not sorted: index %v id %v", i, part.ID)
+		}
+	}
+}
+
 func TestMUSession_Bytes(t *testing.T) {
 	var err error
 	var random = rand.New(rand.NewSource(time.Now().UnixNano()))
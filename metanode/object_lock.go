@@ -0,0 +1,129 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"strconv"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// The object lock of an inode is kept in its extended attributes by the object nodes.
+// Since whether a retention period has expired depends on the clock, the locks are
+// checked by the leader before the requests are submitted, so that all the replicas
+// apply the same log.
+
+type objectLock struct {
+	mode        string
+	retainUntil int64
+	legalHold   bool
+}
+
+// retained returns if the retention period of the lock has not expired yet.
+func (l *objectLock) retained(now int64) bool {
+	return l.mode != "" && l.retainUntil > now
+}
+
+// locked returns if the inode can be neither removed nor modified.
+func (l *objectLock) locked(now int64) bool {
+	return l.legalHold || l.retained(now)
+}
+
+func (mp *metaPartition) getObjectLock(ino uint64) (lock *objectLock) {
+	lock = &objectLock{}
+	item := mp.extendTree.Get(NewExtend(ino))
+	if item == nil {
+		return
+	}
+	extend := item.(*Extend)
+	if value, exist := extend.Get([]byte(proto.XAttrKeyObjectLockMode)); exist {
+		lock.mode = string(value)
+	}
+	if value, exist := extend.Get([]byte(proto.XAttrKeyObjectLockRetainUntil)); exist {
+		lock.retainUntil, _ = strconv.ParseInt(string(value), 10, 64)
+	}
+	if value, exist := extend.Get([]byte(proto.XAttrKeyObjectLegalHold)); exist {
+		lock.legalHold = string(value) == proto.ObjectLegalHoldOn
+	}
+	return
+}
+
+// isObjectLocked returns if the inode is under a retention period or a legal hold.
+func (mp *metaPartition) isObjectLocked(ino uint64) bool {
+	return mp.getObjectLock(ino).locked(Now.GetCurrentTime().Unix())
+}
+
+// checkObjectLockUnlink refuses to drop the last link of a locked inode. The other links
+// are held by the noncurrent versions of the object, which can be released freely.
+func (mp *metaPartition) checkObjectLockUnlink(ino uint64) uint8 {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return proto.OpOk
+	}
+	if inode := item.(*Inode); !proto.IsRegular(inode.Type) || inode.GetNLink() > 1 {
+		return proto.OpOk
+	}
+	if mp.isObjectLocked(ino) {
+		return proto.OpNotPerm
+	}
+	return proto.OpOk
+}
+
+// checkObjectLockWrite refuses to change the data of a locked inode.
+func (mp *metaPartition) checkObjectLockWrite(ino uint64) uint8 {
+	if mp.isObjectLocked(ino) {
+		return proto.OpNotPerm
+	}
+	return proto.OpOk
+}
+
+// checkObjectLockXAttr refuses to weaken the compliance retention of an inode before it
+// expires: the mode can not be changed and the retention period can only be extended.
+// The governance retention and the legal hold are left to the permission checks of the
+// object nodes.
+func (mp *metaPartition) checkObjectLockXAttr(ino uint64, key string, value []byte, remove bool) uint8 {
+	if key != proto.XAttrKeyObjectLockMode && key != proto.XAttrKeyObjectLockRetainUntil {
+		return proto.OpOk
+	}
+	lock := mp.getObjectLock(ino)
+	if lock.mode != proto.ObjectLockModeCompliance || !lock.retained(Now.GetCurrentTime().Unix()) {
+		return proto.OpOk
+	}
+	if remove {
+		return proto.OpNotPerm
+	}
+	switch key {
+	case proto.XAttrKeyObjectLockMode:
+		if string(value) != proto.ObjectLockModeCompliance {
+			return proto.OpNotPerm
+		}
+	case proto.XAttrKeyObjectLockRetainUntil:
+		retainUntil, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil || retainUntil < lock.retainUntil {
+			return proto.OpNotPerm
+		}
+	}
+	return proto.OpOk
+}
+
+// checkObjectLockDentry refuses to remove the dentry holding the last link of a locked
+// inode, if the inode belongs to this partition.
+func (mp *metaPartition) checkObjectLockDentry(parentID uint64, name string) uint8 {
+	dentry, status := mp.getDentry(&Dentry{ParentId: parentID, Name: name})
+	if status != proto.OpOk {
+		return proto.OpOk
+	}
+	return mp.checkObjectLockUnlink(dentry.Inode)
+}
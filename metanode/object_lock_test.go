@@ -0,0 +1,104 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func setTestObjectLock(t *testing.T, mp *metaPartition, ino uint64, key, value string) {
+	extend := NewExtend(ino)
+	extend.Put([]byte(key), []byte(value))
+	if err := mp.fsmSetXAttr(extend); err != nil {
+		t.Fatalf("set xattr(%v) fail: err(%v)", key, err)
+	}
+}
+
+func TestObjectLockRetention(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}, nil).(*metaPartition)
+	if status := mp.fsmCreateInode(NewInode(10, proto.Mode(0644))); status != proto.OpOk {
+		t.Fatalf("create inode: status(%v)", status)
+	}
+	if status := mp.checkObjectLockUnlink(10); status != proto.OpOk {
+		t.Fatalf("unlink unlocked inode: status(%v)", status)
+	}
+
+	retainUntil := time.Now().Add(time.Hour).Unix()
+	setTestObjectLock(t, mp, 10, proto.XAttrKeyObjectLockMode, proto.ObjectLockModeCompliance)
+	setTestObjectLock(t, mp, 10, proto.XAttrKeyObjectLockRetainUntil, strconv.FormatInt(retainUntil, 10))
+	if status := mp.checkObjectLockUnlink(10); status != proto.OpNotPerm {
+		t.Fatalf("unlink retained inode: expect OpNotPerm, got status(%v)", status)
+	}
+	if status := mp.checkObjectLockWrite(10); status != proto.OpNotPerm {
+		t.Fatalf("write retained inode: expect OpNotPerm, got status(%v)", status)
+	}
+
+	// the noncurrent versions hold the other links of the inode
+	mp.fsmCreateLinkInode(NewInode(10, 0))
+	if status := mp.checkObjectLockUnlink(10); status != proto.OpOk {
+		t.Fatalf("unlink linked retained inode: status(%v)", status)
+	}
+
+	// the compliance retention can only be extended
+	shorter := strconv.FormatInt(retainUntil-60, 10)
+	longer := strconv.FormatInt(retainUntil+60, 10)
+	if status := mp.checkObjectLockXAttr(10, proto.XAttrKeyObjectLockRetainUntil, []byte(shorter), false); status != proto.OpNotPerm {
+		t.Fatalf("shorten compliance retention: expect OpNotPerm, got status(%v)", status)
+	}
+	if status := mp.checkObjectLockXAttr(10, proto.XAttrKeyObjectLockRetainUntil, []byte(longer), false); status != proto.OpOk {
+		t.Fatalf("extend compliance retention: status(%v)", status)
+	}
+	if status := mp.checkObjectLockXAttr(10, proto.XAttrKeyObjectLockMode, []byte(proto.ObjectLockModeGovernance), false); status != proto.OpNotPerm {
+		t.Fatalf("change compliance mode: expect OpNotPerm, got status(%v)", status)
+	}
+	if status := mp.checkObjectLockXAttr(10, proto.XAttrKeyObjectLockMode, nil, true); status != proto.OpNotPerm {
+		t.Fatalf("remove compliance mode: expect OpNotPerm, got status(%v)", status)
+	}
+
+	// the governance retention is left to the object nodes
+	setTestObjectLock(t, mp, 10, proto.XAttrKeyObjectLockMode, proto.ObjectLockModeGovernance)
+	if status := mp.checkObjectLockXAttr(10, proto.XAttrKeyObjectLockRetainUntil, nil, true); status != proto.OpOk {
+		t.Fatalf("remove governance retention: status(%v)", status)
+	}
+
+	// an expired retention does not lock the inode
+	setTestObjectLock(t, mp, 10, proto.XAttrKeyObjectLockRetainUntil, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	if status := mp.checkObjectLockWrite(10); status != proto.OpOk {
+		t.Fatalf("write expired retained inode: status(%v)", status)
+	}
+}
+
+func TestObjectLockLegalHold(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}, nil).(*metaPartition)
+	if status := mp.fsmCreateInode(NewInode(10, proto.Mode(0644))); status != proto.OpOk {
+		t.Fatalf("create inode: status(%v)", status)
+	}
+	if status := mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "f", Inode: 10, Type: proto.Mode(0644)}, true); status != proto.OpOk {
+		t.Fatalf("create dentry: status(%v)", status)
+	}
+
+	setTestObjectLock(t, mp, 10, proto.XAttrKeyObjectLegalHold, proto.ObjectLegalHoldOn)
+	if status := mp.checkObjectLockDentry(1, "f"); status != proto.OpNotPerm {
+		t.Fatalf("delete dentry of held inode: expect OpNotPerm, got status(%v)", status)
+	}
+	setTestObjectLock(t, mp, 10, proto.XAttrKeyObjectLegalHold, proto.ObjectLegalHoldOff)
+	if status := mp.checkObjectLockDentry(1, "f"); status != proto.OpOk {
+		t.Fatalf("delete dentry of released inode: status(%v)", status)
+	}
+}
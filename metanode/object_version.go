@@ -0,0 +1,129 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/cubefs/cubefs/util/btree"
+)
+
+// ObjectVersion defines a noncurrent version of an object, or a delete marker, which are
+// kept for the buckets with versioning enabled. The versions of an object are ordered
+// by the version id, which sorts the newer versions first.
+type ObjectVersion struct {
+	path         string
+	versionId    string
+	inode        uint64
+	deleteMarker bool
+	createTime   time.Time
+}
+
+func (v *ObjectVersion) Less(than btree.Item) bool {
+	tv, is := than.(*ObjectVersion)
+	return is && ((v.path < tv.path) || ((v.path == tv.path) && (v.versionId < tv.versionId)))
+}
+
+func (v *ObjectVersion) Copy() btree.Item {
+	return &ObjectVersion{
+		path:         v.path,
+		versionId:    v.versionId,
+		inode:        v.inode,
+		deleteMarker: v.deleteMarker,
+		createTime:   v.createTime,
+	}
+}
+
+func (v *ObjectVersion) Bytes() ([]byte, error) {
+	var n int
+	var buffer = bytes.NewBuffer(nil)
+	var err error
+	tmp := make([]byte, binary.MaxVarintLen64)
+	var marshalStr = func(src string) error {
+		n = binary.PutUvarint(tmp, uint64(len(src)))
+		if _, err = buffer.Write(tmp[:n]); err != nil {
+			return err
+		}
+		if _, err = buffer.WriteString(src); err != nil {
+			return err
+		}
+		return nil
+	}
+	// marshal path
+	if err = marshalStr(v.path); err != nil {
+		return nil, err
+	}
+	// marshal version id
+	if err = marshalStr(v.versionId); err != nil {
+		return nil, err
+	}
+	// marshal inode
+	n = binary.PutUvarint(tmp, v.inode)
+	if _, err = buffer.Write(tmp[:n]); err != nil {
+		return nil, err
+	}
+	// marshal delete marker
+	var marker byte
+	if v.deleteMarker {
+		marker = 1
+	}
+	if err = buffer.WriteByte(marker); err != nil {
+		return nil, err
+	}
+	// marshal create time
+	n = binary.PutVarint(tmp, v.createTime.UnixNano())
+	if _, err = buffer.Write(tmp[:n]); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func ObjectVersionFromBytes(raw []byte) *ObjectVersion {
+	var unmarshalStr = func(data []byte) (string, int) {
+		var n int
+		var lengthU64 uint64
+		lengthU64, n = binary.Uvarint(data)
+		return string(data[n : n+int(lengthU64)]), n + int(lengthU64)
+	}
+	var offset, n int
+	// decode path
+	var path string
+	path, n = unmarshalStr(raw)
+	offset += n
+	// decode version id
+	var versionId string
+	versionId, n = unmarshalStr(raw[offset:])
+	offset += n
+	// decode inode
+	var inode uint64
+	inode, n = binary.Uvarint(raw[offset:])
+	offset += n
+	// decode delete marker
+	var deleteMarker = raw[offset] == 1
+	offset++
+	// decode create time
+	var createTimeI64 int64
+	createTimeI64, _ = binary.Varint(raw[offset:])
+
+	return &ObjectVersion{
+		path:         path,
+		versionId:    versionId,
+		inode:        inode,
+		deleteMarker: deleteMarker,
+		createTime:   time.Unix(0, createTimeI64),
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/util"
+)
+
+func TestObjectVersion_Bytes(t *testing.T) {
+	var err error
+	version1 := &ObjectVersion{
+		path:         "a/b/c.txt",
+		versionId:    util.CreateObjectVersionID(1),
+		inode:        12345,
+		deleteMarker: true,
+		createTime:   time.Now().Local(),
+	}
+	var versionBytes []byte
+	if versionBytes, err = version1.Bytes(); err != nil {
+		t.Fatalf("get bytes of version fail cause: %v", err)
+	}
+	version2 := ObjectVersionFromBytes(versionBytes)
+	if !reflect.DeepEqual(version1, version2) {
+		t.Fatalf("result mismatch:\n\tversion1:%v\n\tversion2:%v", version1, version2)
+	}
+	t.Logf("encoded length: %v", len(versionBytes))
+}
+
+func TestObjectVersion_Less(t *testing.T) {
+	older := &ObjectVersion{path: "a", versionId: util.CreateObjectVersionID(1)}
+	time.Sleep(time.Millisecond)
+	newer := &ObjectVersion{path: "a", versionId: util.CreateObjectVersionID(1)}
+	if !newer.Less(older) || older.Less(newer) {
+		t.Fatalf("newer version should sort first: older(%v) newer(%v)", older.versionId, newer.versionId)
+	}
+	other := &ObjectVersion{path: "b", versionId: util.CreateObjectVersionID(1)}
+	if !older.Less(other) {
+		t.Fatalf("versions should be ordered by path first: older(%v) other(%v)", older.path, other.path)
+	}
+}
@@ -45,6 +45,25 @@ func NewPacketToDeleteExtent(dp *DataPartition, ext *proto.ExtentKey) *Packet {
 	return p
 }
 
+// NewPacketToPunchHole returns a new packet to deallocate a range of a normal extent.
+func NewPacketToPunchHole(dp *DataPartition, ext *proto.ExtentKey) *Packet {
+	p := new(Packet)
+	p.Magic = proto.ProtoMagic
+	p.Opcode = proto.OpPunchHole
+	p.ExtentType = proto.NormalExtentType
+	p.PartitionID = uint64(dp.PartitionID)
+	p.Data, _ = json.Marshal(ext)
+	p.Size = uint32(len(p.Data))
+	p.ExtentID = ext.ExtentId
+	p.ExtentOffset = int64(ext.ExtentOffset)
+	p.ReqID = proto.GenerateRequestID()
+	p.RemainingFollowers = uint8(len(dp.Hosts) - 1)
+	p.Arg = ([]byte)(dp.GetAllAddrs())
+	p.ArgLen = uint32(len(p.Arg))
+
+	return p
+}
+
 // NewPacketToBatchDeleteExtent returns a new packet to batch delete the extent.
 func NewPacketToBatchDeleteExtent(dp *DataPartition, exts []*proto.ExtentKey) *Packet {
 	p := new(Packet)
@@ -62,6 +81,23 @@ func NewPacketToBatchDeleteExtent(dp *DataPartition, exts []*proto.ExtentKey) *P
 	return p
 }
 
+// NewPacketToSealExtents returns a new packet to seal or unseal the extents on the replicas of the data partition.
+func NewPacketToSealExtents(dp *DataPartition, exts []proto.ExtentKey, unseal bool) *Packet {
+	p := new(Packet)
+	p.Magic = proto.ProtoMagic
+	p.Opcode = proto.OpSealExtents
+	p.ExtentType = proto.NormalExtentType
+	p.PartitionID = uint64(dp.PartitionID)
+	p.Data, _ = json.Marshal(&proto.SealExtentsRequest{Extents: exts, Unseal: unseal})
+	p.Size = uint32(len(p.Data))
+	p.ReqID = proto.GenerateRequestID()
+	p.RemainingFollowers = uint8(len(dp.Hosts) - 1)
+	p.Arg = ([]byte)(dp.GetAllAddrs())
+	p.ArgLen = uint32(len(p.Arg))
+
+	return p
+}
+
 // NewPacketToDeleteExtent returns a new packet to delete the extent.
 func NewPacketToFreeInodeOnRaftFollower(partitionID uint64, freeInodes []byte) *Packet {
 	p := new(Packet)
@@ -63,20 +63,24 @@ func (sp sortedPeers) Swap(i, j int) {
 // MetaPartitionConfig is used to create a meta partition.
 type MetaPartitionConfig struct {
 	// Identity for raftStore group. RaftStore nodes in the same raftStore group must have the same groupID.
-	PartitionId uint64              `json:"partition_id"`
-	VolName     string              `json:"vol_name"`
-	Start       uint64              `json:"start"` // Minimal Inode ID of this range. (Required during initialization)
-	End         uint64              `json:"end"`   // Maximal Inode ID of this range. (Required during initialization)
-	Peers       []proto.Peer        `json:"peers"` // Peers information of the raftStore
-	Cursor      uint64              `json:"-"`     // Cursor ID of the inode that have been assigned
-	NodeId      uint64              `json:"-"`
-	RootDir     string              `json:"-"`
-	BeforeStart func()              `json:"-"`
-	AfterStart  func()              `json:"-"`
-	BeforeStop  func()              `json:"-"`
-	AfterStop   func()              `json:"-"`
-	RaftStore   raftstore.RaftStore `json:"-"`
-	ConnPool    *util.ConnectPool   `json:"-"`
+	PartitionId uint64       `json:"partition_id"`
+	VolName     string       `json:"vol_name"`
+	Start       uint64       `json:"start"`     // Minimal Inode ID of this range. (Required during initialization)
+	End         uint64       `json:"end"`       // Maximal Inode ID of this range. (Required during initialization)
+	Peers       []proto.Peer `json:"peers"`     // Peers information of the raftStore
+	Learners    []proto.Peer `json:"learners"`  // Peers which replicate the log but do not vote
+	Witnesses   []proto.Peer `json:"witnesses"` // Peers which vote but keep no metadata
+	// CaseInsensitive makes dentry lookups ignore the case of names while preserving it on creation.
+	CaseInsensitive bool                `json:"case_insensitive"`
+	Cursor          uint64              `json:"-"` // Cursor ID of the inode that have been assigned
+	NodeId          uint64              `json:"-"`
+	RootDir         string              `json:"-"`
+	BeforeStart     func()              `json:"-"`
+	AfterStart      func()              `json:"-"`
+	BeforeStop      func()              `json:"-"`
+	AfterStop       func()              `json:"-"`
+	RaftStore       raftstore.RaftStore `json:"-"`
+	ConnPool        *util.ConnectPool   `json:"-"`
 }
 
 func (c *MetaPartitionConfig) checkMeta() (err error) {
@@ -113,11 +117,13 @@ type OpInode interface {
 	UnlinkInodeBatch(req *BatchUnlinkInoReq, p *Packet) (err error)
 	InodeGet(req *InodeGetReq, p *Packet) (err error)
 	InodeGetBatch(req *InodeGetReqBatch, p *Packet) (err error)
+	BatchStat(req *proto.BatchStatRequest, p *Packet) (err error)
 	CreateInodeLink(req *LinkInodeReq, p *Packet) (err error)
 	EvictInode(req *EvictInodeReq, p *Packet) (err error)
 	EvictInodeBatch(req *BatchEvictInodeReq, p *Packet) (err error)
 	SetAttr(reqData []byte, p *Packet) (err error)
 	GetInodeTree() *BTree
+	GetSize() uint64
 	DeleteInode(req *proto.DeleteInodeRequest, p *Packet) (err error)
 	DeleteInodeBatch(req *proto.DeleteInodeBatchRequest, p *Packet) (err error)
 }
@@ -150,9 +156,33 @@ type OpExtent interface {
 	ExtentAppendWithCheck(req *proto.AppendExtentKeyWithCheckRequest, p *Packet) (err error)
 	ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
 	ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (err error)
+	ExtentsPunchHole(req *ExtentsPunchHoleReq, p *Packet) (err error)
+	WriteInline(req *WriteInlineReq, p *Packet) (err error)
 	BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error)
 }
 
+// OpSnapshot defines the interface for the subtree snapshot operations.
+type OpSnapshot interface {
+	CreateSnapshot(req *proto.CreateSnapshotRequest, p *Packet) (err error)
+	SealSnapshot(req *proto.SealSnapshotRequest, p *Packet) (err error)
+	DeleteSnapshot(req *proto.DeleteSnapshotRequest, p *Packet) (err error)
+	ListSnapshots(req *proto.ListSnapshotsRequest, p *Packet) (err error)
+	RestoreSnapshot(req *proto.RestoreSnapshotRequest, p *Packet) (err error)
+}
+
+// OpLock defines the interface for the file lock operations.
+type OpLock interface {
+	SetLock(req *proto.SetLockRequest, p *Packet) (err error)
+	GetLock(req *proto.GetLockRequest, p *Packet) (err error)
+	RenewLocks(req *proto.RenewLocksRequest, p *Packet) (err error)
+}
+
+// OpQuota defines the interface for the directory quota operations.
+type OpQuota interface {
+	SetInodeQuota(req *proto.SetInodeQuotaRequest, p *Packet) (err error)
+	GetQuotaUsages() []*proto.QuotaUsage
+}
+
 type OpMultipart interface {
 	GetMultipart(req *proto.GetMultipartRequest, p *Packet) (err error)
 	CreateMultipart(req *proto.CreateMultipartRequest, p *Packet) (err error)
@@ -161,6 +191,14 @@ type OpMultipart interface {
 	ListMultipart(req *proto.ListMultipartRequest, p *Packet) (err error)
 }
 
+// OpObjectVersion defines the interface for the object version operations.
+type OpObjectVersion interface {
+	PutObjectVersion(req *proto.PutObjectVersionRequest, p *Packet) (err error)
+	GetObjectVersion(req *proto.GetObjectVersionRequest, p *Packet) (err error)
+	RemoveObjectVersion(req *proto.RemoveObjectVersionRequest, p *Packet) (err error)
+	ListObjectVersions(req *proto.ListObjectVersionsRequest, p *Packet) (err error)
+}
+
 // OpMeta defines the interface for the metadata operations.
 type OpMeta interface {
 	OpInode
@@ -169,6 +207,10 @@ type OpMeta interface {
 	OpPartition
 	OpExtend
 	OpMultipart
+	OpObjectVersion
+	OpSnapshot
+	OpLock
+	OpQuota
 }
 
 // OpPartition defines the interface for the partition operations.
@@ -184,8 +226,13 @@ type OpPartition interface {
 	DeleteRaft() error
 	IsExsitPeer(peer proto.Peer) bool
 	TryToLeader(groupID uint64) error
+	TransferLeader(nodeID uint64) error
 	CanRemoveRaftMember(peer proto.Peer) error
+	CanPromoteLearner(peer proto.Peer) error
+	SetSnapshotRateLimit(bytesPerSec int64) error
+	IsLearner(peer proto.Peer) bool
 	IsEquareCreateMetaPartitionRequst(request *proto.CreateMetaPartitionRequest) (err error)
+	MigrateToCold(req *proto.MigrateToColdRequest) (resp *proto.MigrateToColdResponse)
 }
 
 // MetaPartition defines the interface for the meta partition operations.
@@ -206,12 +253,14 @@ type MetaPartition interface {
 //  +-----+             +-------+
 type metaPartition struct {
 	config                 *MetaPartitionConfig
-	size                   uint64 // For partition all file size
+	size                   uint64 // For partition all file size, updated by the deltas of inode sizes
 	applyID                uint64 // Inode/Dentry max applyID, this index will be update after restoring from the dumped data.
 	dentryTree             *BTree
+	dentryFoldTree         *BTree // case-folded dentry names, only maintained on case-insensitive partitions
 	inodeTree              *BTree // btree for inodes
 	extendTree             *BTree // btree for inode extend (XAttr) management
 	multipartTree          *BTree // collection for multipart management
+	versionTree            *BTree // noncurrent object versions and delete markers
 	raftPartition          raftstore.Partition
 	stopC                  chan bool
 	storeChan              chan *storeMsg
@@ -224,6 +273,15 @@ type metaPartition struct {
 	manager                *metadataManager
 	isLoadingMetaPartition bool
 	summaryLock            sync.Mutex
+	snapshotMu             sync.RWMutex
+	snapshots              map[uint64]*subtreeSnapshot           // subtree snapshots by ID
+	snapshotExtents        map[snapshotExtentKey]int             // number of snapshots referencing each extent
+	heldExtents            map[snapshotExtentKey]proto.ExtentKey // extents released by the live tree but kept for snapshots
+	locks                  *lockTable                            // file locks, only held by the leader
+	delExtentsCaughtUp     int64                                 // unix time when all the extents to delete were last deleted
+	coldMigrating          int32                                 // 1 while the files are migrated to the cold tier
+	quotaUsageMu           sync.Mutex
+	quotaUsages            map[uint64]*proto.QuotaUsage // usage of the directory quotas by the inodes tagged
 }
 
 func (mp *metaPartition) ForceSetMetaPartitionToLoadding() {
@@ -332,13 +390,23 @@ func (mp *metaPartition) startRaft() (err error) {
 		}
 		peers = append(peers, rp)
 	}
-	log.LogDebugf("start partition id=%d raft peers: %s",
-		mp.config.PartitionId, peers)
+	learners := make([]uint64, 0, len(mp.config.Learners))
+	for _, learner := range mp.config.Learners {
+		learners = append(learners, learner.ID)
+	}
+	witnesses := make([]uint64, 0, len(mp.config.Witnesses))
+	for _, witness := range mp.config.Witnesses {
+		witnesses = append(witnesses, witness.ID)
+	}
+	log.LogDebugf("start partition id=%d raft peers: %s learners: %v witnesses: %v",
+		mp.config.PartitionId, peers, learners, witnesses)
 	pc := &raftstore.PartitionConfig{
-		ID:      mp.config.PartitionId,
-		Applied: mp.applyID,
-		Peers:   peers,
-		SM:      mp,
+		ID:        mp.config.PartitionId,
+		Applied:   mp.applyID,
+		Peers:     peers,
+		Learners:  learners,
+		Witnesses: witnesses,
+		SM:        mp,
 	}
 	mp.raftPartition, err = mp.config.RaftStore.CreatePartition(pc)
 	if err == nil {
@@ -381,18 +449,24 @@ func (mp *metaPartition) getRaftPort() (heartbeat, replica int, err error) {
 // NewMetaPartition creates a new meta partition with the specified configuration.
 func NewMetaPartition(conf *MetaPartitionConfig, manager *metadataManager) MetaPartition {
 	mp := &metaPartition{
-		config:        conf,
-		dentryTree:    NewBtree(),
-		inodeTree:     NewBtree(),
-		extendTree:    NewBtree(),
-		multipartTree: NewBtree(),
-		stopC:         make(chan bool),
-		storeChan:     make(chan *storeMsg, 100),
-		freeList:      newFreeList(),
-		extDelCh:      make(chan []proto.ExtentKey, 10000),
-		extReset:      make(chan struct{}),
-		vol:           NewVol(),
-		manager:       manager,
+		config:          conf,
+		dentryTree:      NewBtree(),
+		dentryFoldTree:  NewBtree(),
+		inodeTree:       NewBtree(),
+		extendTree:      NewBtree(),
+		multipartTree:   NewBtree(),
+		versionTree:     NewBtree(),
+		stopC:           make(chan bool),
+		storeChan:       make(chan *storeMsg, 100),
+		freeList:        newFreeList(),
+		extDelCh:        make(chan []proto.ExtentKey, 10000),
+		extReset:        make(chan struct{}),
+		vol:             NewVol(),
+		manager:         manager,
+		snapshots:       make(map[uint64]*subtreeSnapshot),
+		snapshotExtents: make(map[snapshotExtentKey]int),
+		heldExtents:     make(map[snapshotExtentKey]proto.ExtentKey),
+		locks:           newLockTable(),
 	}
 	return mp
 }
@@ -452,6 +526,12 @@ func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
 	if err = mp.loadMultipart(snapshotPath); err != nil {
 		return
 	}
+	if err = mp.loadObjectVersion(snapshotPath); err != nil {
+		return
+	}
+	if err = mp.loadSubtreeSnapshots(snapshotPath); err != nil {
+		return
+	}
 	err = mp.loadApplyID(snapshotPath)
 	return
 }
@@ -473,6 +553,12 @@ func (mp *metaPartition) load() (err error) {
 	if err = mp.loadMultipart(snapshotPath); err != nil {
 		return
 	}
+	if err = mp.loadObjectVersion(snapshotPath); err != nil {
+		return
+	}
+	if err = mp.loadSubtreeSnapshots(snapshotPath); err != nil {
+		return
+	}
 	err = mp.loadApplyID(snapshotPath)
 	return
 }
@@ -500,6 +586,8 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 		mp.storeDentry,
 		mp.storeExtend,
 		mp.storeMultipart,
+		mp.storeObjectVersion,
+		mp.storeSubtreeSnapshots,
 	}
 	for _, storeFunc := range storeFuncs {
 		var crc uint32
@@ -621,10 +709,24 @@ func (mp *metaPartition) IsExsitPeer(peer proto.Peer) bool {
 	return false
 }
 
+// IsLearner returns true if the peer is a non-voting member of the partition.
+func (mp *metaPartition) IsLearner(peer proto.Peer) bool {
+	for _, learner := range mp.config.Learners {
+		if learner.ID == peer.ID {
+			return true
+		}
+	}
+	return false
+}
+
 func (mp *metaPartition) TryToLeader(groupID uint64) error {
 	return mp.raftPartition.TryToLeader(groupID)
 }
 
+func (mp *metaPartition) TransferLeader(nodeID uint64) error {
+	return mp.raftPartition.TransferLeader(nodeID)
+}
+
 // ResponseLoadMetaPartition loads the snapshot signature. TODO remove? no usage?
 func (mp *metaPartition) ResponseLoadMetaPartition(p *Packet) (err error) {
 	resp := &proto.MetaPartitionLoadResponse{
@@ -660,6 +762,7 @@ func (mp *metaPartition) MarshalJSON() ([]byte, error) {
 func (mp *metaPartition) Reset() (err error) {
 	mp.inodeTree.Reset()
 	mp.dentryTree.Reset()
+	mp.dentryFoldTree.Reset()
 	mp.config.Cursor = 0
 	mp.applyID = 0
 
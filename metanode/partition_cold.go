@@ -0,0 +1,278 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/blobstore"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	coldBlobSize            = 16 * 1024 * 1024 // bytes of a file put in a blob at most
+	defaultColdMigrateLimit = 1000             // files migrated by a task at most
+	coldMigrateReadMBps     = 64               // MB/s read from the data nodes by a task at most
+)
+
+// inodeMigrateToCold is the raft entry recording the location of the data of an inode in the cold tier.
+// The entry is rejected if the inode has been modified or accessed since the data was read.
+type inodeMigrateToCold struct {
+	Inode      uint64              `json:"ino"`
+	Generation uint64              `json:"gen"`
+	ColdBefore int64               `json:"before"` // the access and modify times of the inode must be before it
+	Cold       *proto.ColdLocation `json:"cold"`
+}
+
+// the client of the blob store of the cold tier, whose addresses are sent by the master in the heartbeats
+var (
+	blobStoreMu     sync.RWMutex
+	blobStoreClient *blobstore.Client
+)
+
+// setBlobStoreAddrs replaces the client of the blob store if the addresses are changed.
+func setBlobStoreAddrs(addrs []string) {
+	blobStoreMu.Lock()
+	defer blobStoreMu.Unlock()
+	if len(addrs) == 0 {
+		blobStoreClient = nil
+		return
+	}
+	if blobStoreClient == nil || strings.Join(blobStoreClient.Addrs(), ",") != strings.Join(addrs, ",") {
+		blobStoreClient = blobstore.NewClient(addrs)
+	}
+}
+
+// getBlobStore returns the client of the blob store, nil if the cold tier is not configured.
+func getBlobStore() *blobstore.Client {
+	blobStoreMu.RLock()
+	defer blobStoreMu.RUnlock()
+	return blobStoreClient
+}
+
+// MigrateToCold moves the data of the cold files of the partition to the blob store. It runs on
+// the leader only, reads the files through the data nodes, puts them as blobs and replaces their
+// extents with the locations of the blobs by the raft.
+func (mp *metaPartition) MigrateToCold(req *proto.MigrateToColdRequest) (resp *proto.MigrateToColdResponse) {
+	resp = &proto.MigrateToColdResponse{PartitionID: mp.config.PartitionId, Status: proto.TaskSucceeds}
+	var fail = func(err error) *proto.MigrateToColdResponse {
+		resp.Status = proto.TaskFailed
+		resp.Result = err.Error()
+		return resp
+	}
+	if _, ok := mp.IsLeader(); !ok {
+		return fail(ErrNotALeader)
+	}
+	store := getBlobStore()
+	if store == nil {
+		return fail(fmt.Errorf("blob store of the cold tier is not configured"))
+	}
+	if !atomic.CompareAndSwapInt32(&mp.coldMigrating, 0, 1) {
+		return fail(fmt.Errorf("migration to the cold tier is in progress"))
+	}
+	defer atomic.StoreInt32(&mp.coldMigrating, 0)
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultColdMigrateLimit
+	}
+	coldBefore := Now.GetCurrentTime().Unix() - req.ColdSec
+	candidates := make([]uint64, 0)
+	mp.inodeTree.Ascend(func(item BtreeItem) bool {
+		if ino := item.(*Inode); ino.isColdCandidate(coldBefore) {
+			candidates = append(candidates, ino.Inode)
+		}
+		return len(candidates) < limit
+	})
+	if len(candidates) == 0 {
+		return
+	}
+
+	ec, err := stream.NewExtentClient(&stream.ExtentConfig{
+		Volume:        mp.config.VolName,
+		Masters:       masterClient.Nodes(),
+		OnGetExtents:  mp.localExtents,
+		OnTruncate:    func(inode, size uint64) error { return syscall.EROFS },
+		ReadBandwidth: coldMigrateReadMBps,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	defer ec.Close()
+	for _, ino := range candidates {
+		if _, ok := mp.IsLeader(); !ok {
+			return fail(ErrNotALeader)
+		}
+		var size uint64
+		if size, err = mp.migrateInodeToCold(ec, store, ino, coldBefore); err != nil {
+			log.LogWarnf("MigrateToCold: partitionID(%v) ino(%v) err(%v)", mp.config.PartitionId, ino, err)
+			resp.Failed++
+			continue
+		}
+		resp.Migrated++
+		resp.Bytes += size
+	}
+	log.LogInfof("MigrateToCold: partitionID(%v) candidates(%v) migrated(%v) bytes(%v) failed(%v)",
+		mp.config.PartitionId, len(candidates), resp.Migrated, resp.Bytes, resp.Failed)
+	return
+}
+
+// isColdCandidate returns if the data of the inode can be migrated to the cold tier, which is a
+// regular file stored in the extents and neither accessed nor modified since coldBefore.
+func (i *Inode) isColdCandidate(coldBefore int64) (ok bool) {
+	i.RLock()
+	ok = proto.IsRegular(i.Type) && i.NLink > 0 && i.Size > 0 &&
+		i.Flag&(DeleteMarkFlag|InlineDataFlag|ColdDataFlag) == 0 && i.Extents.Len() > 0 &&
+		i.AccessTime < coldBefore && i.ModifyTime < coldBefore
+	i.RUnlock()
+	return
+}
+
+// migrateInodeToCold puts the data of the inode as blobs and records their locations. The blobs
+// are deleted if the inode cannot be migrated.
+func (mp *metaPartition) migrateInodeToCold(ec *stream.ExtentClient, store *blobstore.Client, ino uint64, coldBefore int64) (size uint64, err error) {
+	item := mp.inodeTree.CopyGet(NewInode(ino, 0))
+	if item == nil {
+		return 0, syscall.ENOENT
+	}
+	var gen uint64
+	item.(*Inode).DoReadFunc(func() {
+		gen, size = item.(*Inode).Generation, item.(*Inode).Size
+	})
+
+	if err = ec.OpenStream(ino); err != nil {
+		return
+	}
+	defer func() {
+		_ = ec.CloseStream(ino)
+		_ = ec.EvictStream(ino)
+	}()
+	cold := &proto.ColdLocation{Size: size}
+	defer func() {
+		if err != nil {
+			mp.deleteColdBlobs(store, cold)
+		}
+	}()
+	buf := make([]byte, coldBlobSize)
+	for offset := uint64(0); offset < size; offset += coldBlobSize {
+		n := size - offset
+		if n > coldBlobSize {
+			n = coldBlobSize
+		}
+		var read int
+		if read, err = ec.Read(ino, buf[:n], int(offset), int(n)); err != nil {
+			return
+		}
+		if uint64(read) != n {
+			return 0, fmt.Errorf("read offset(%v) size(%v) but read(%v)", offset, n, read)
+		}
+		var location json.RawMessage
+		if location, err = store.Put(buf[:n]); err != nil {
+			return
+		}
+		cold.Blobs = append(cold.Blobs, proto.ColdBlob{Offset: offset, Size: n, Location: location})
+	}
+
+	val, err := json.Marshal(&inodeMigrateToCold{Inode: ino, Generation: gen, ColdBefore: coldBefore, Cold: cold})
+	if err != nil {
+		return
+	}
+	resp, err := mp.submit(opFSMMigrateToCold, val)
+	if err != nil {
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk {
+		return 0, fmt.Errorf("inode is modified during the migration, status(%v)", status)
+	}
+	return
+}
+
+// localExtents returns the extents of the inode in the partition for the extent client reading the
+// files to migrate.
+func (mp *metaPartition) localExtents(inode uint64) (gen, size uint64, eks []proto.ExtentKey, inline []byte, cold *proto.ColdLocation, err error) {
+	item := mp.inodeTree.Get(NewInode(inode, 0))
+	if item == nil {
+		err = syscall.ENOENT
+		return
+	}
+	ino := item.(*Inode)
+	ino.DoReadFunc(func() {
+		gen, size, inline, cold = ino.Generation, ino.Size, ino.InlineData, ino.Cold
+		ino.Extents.Range(func(ek proto.ExtentKey) bool {
+			eks = append(eks, ek)
+			return true
+		})
+	})
+	return
+}
+
+// deleteColdBlobs deletes the blobs of the cold location.
+func (mp *metaPartition) deleteColdBlobs(store *blobstore.Client, cold *proto.ColdLocation) (err error) {
+	if cold == nil || len(cold.Blobs) == 0 {
+		return
+	}
+	locations := make([]json.RawMessage, 0, len(cold.Blobs))
+	for _, blob := range cold.Blobs {
+		locations = append(locations, blob.Location)
+	}
+	if err = store.Delete(locations); err != nil {
+		log.LogWarnf("deleteColdBlobs: partitionID(%v) blobs(%v) err(%v)", mp.config.PartitionId, len(locations), err)
+	}
+	return
+}
+
+// deleteColdInodes deletes the blobs of the cold inodes to free, and returns the inodes whose blobs
+// are deleted and the ones to retry. The blobs are kept while the partition has any snapshot, which
+// may reference them.
+func (mp *metaPartition) deleteColdInodes(inodes []*Inode) (deleted, retry []*Inode) {
+	deleted = make([]*Inode, 0, len(inodes))
+	for _, inode := range inodes {
+		cold := inode.ColdLocation()
+		if cold == nil {
+			deleted = append(deleted, inode)
+			continue
+		}
+		if mp.hasSnapshots() {
+			retry = append(retry, inode)
+			continue
+		}
+		store := getBlobStore()
+		if store == nil {
+			log.LogWarnf("deleteColdInodes: partitionID(%v) ino(%v) blob store is not configured", mp.config.PartitionId, inode.Inode)
+			retry = append(retry, inode)
+			continue
+		}
+		if err := mp.deleteColdBlobs(store, cold); err != nil {
+			retry = append(retry, inode)
+			continue
+		}
+		deleted = append(deleted, inode)
+	}
+	return
+}
+
+// hasSnapshots returns if the partition has any subtree snapshot.
+func (mp *metaPartition) hasSnapshots() bool {
+	mp.snapshotMu.RLock()
+	defer mp.snapshotMu.RUnlock()
+	return len(mp.snapshots) > 0
+}
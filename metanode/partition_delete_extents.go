@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
@@ -42,6 +43,7 @@ var extentsFileHeader = []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08}
 /// start metapartition delete extents work
 ///
 func (mp *metaPartition) startToDeleteExtents() {
+	atomic.StoreInt64(&mp.delExtentsCaughtUp, time.Now().Unix())
 	fileList := synclist.New()
 	go mp.appendDelExtentsToFile(fileList)
 	go mp.deleteExtentsFromList(fileList)
@@ -190,6 +192,7 @@ func (mp *metaPartition) deleteExtentsFromList(fileList *synclist.SyncList) {
 	LOOP:
 		element = fileList.Front()
 		if element == nil {
+			atomic.StoreInt64(&mp.delExtentsCaughtUp, time.Now().Unix())
 			continue
 		}
 		fileName = element.Value.(string)
@@ -253,6 +256,7 @@ func (mp *metaPartition) deleteExtentsFromList(fileList *synclist.SyncList) {
 		} else if err == io.EOF {
 			err = nil
 			if fileList.Len() <= 1 {
+				atomic.StoreInt64(&mp.delExtentsCaughtUp, time.Now().Unix())
 				log.LogDebugf("[deleteExtentsFromList] partitionId=%d, %s"+
 					" extents delete ok", mp.config.PartitionId, fileName)
 			} else {
@@ -327,6 +331,47 @@ func (mp *metaPartition) deleteExtentsFromList(fileList *synclist.SyncList) {
 	}
 }
 
+// pendingDelExtents counts the extents in the EXTENT_DEL_* files that are not deleted yet.
+func (mp *metaPartition) pendingDelExtents() (count uint64, err error) {
+	finfos, err := ioutil.ReadDir(mp.config.RootDir)
+	if err != nil {
+		return
+	}
+	cursorBuf := make([]byte, 8)
+	for _, info := range finfos {
+		if !strings.HasPrefix(info.Name(), prefixDelExtent) {
+			continue
+		}
+		extentKeyLen := uint64(proto.ExtentLength)
+		if strings.HasPrefix(info.Name(), prefixDelExtentV2) {
+			extentKeyLen = uint64(proto.ExtentV2Length)
+		}
+		var fp *os.File
+		if fp, err = os.Open(path.Join(mp.config.RootDir, info.Name())); err != nil {
+			return
+		}
+		_, err = fp.ReadAt(cursorBuf, 0)
+		fp.Close()
+		if err != nil {
+			return
+		}
+		cursor := binary.BigEndian.Uint64(cursorBuf)
+		if size := uint64(info.Size()); size > cursor {
+			count += (size - cursor) / extentKeyLen
+		}
+	}
+	return
+}
+
+// delExtentsLag returns how long the deletion of extents has been lagging behind.
+func (mp *metaPartition) delExtentsLag() time.Duration {
+	caughtUp := atomic.LoadInt64(&mp.delExtentsCaughtUp)
+	if caughtUp == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(caughtUp, 0))
+}
+
 func (mp *metaPartition) checkBatchDeleteExtents(allExtents map[uint64][]*proto.ExtentKey) {
 	for partitionID, deleteExtents := range allExtents {
 		needDeleteExtents := make([]proto.ExtentKey, len(deleteExtents))
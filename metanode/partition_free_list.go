@@ -151,6 +151,11 @@ func (mp *metaPartition) deleteWorker() {
 					delayDeleteInos = append(delayDeleteInos, ino)
 					continue
 				}
+				// linked again after the nlink was 0, e.g. a file of O_TMPFILE linked by linkat
+				if !inode.ShouldDelete() && inode.GetNLink() > 0 {
+					log.LogDebugf("[metaPartition] deleteWorker skip inode: %v as it is linked again", inode)
+					continue
+				}
 			}
 
 			buffSlice = append(buffSlice, ino)
@@ -238,6 +243,10 @@ func (mp *metaPartition) deleteMarkedInodes(inoSlice []uint64) {
 		}
 		inode.Extents.Range(func(ek proto.ExtentKey) bool {
 			ext := &ek
+			if mp.isSnapshotExtent(ext) {
+				// kept for a subtree snapshot, released when the snapshot is deleted.
+				return true
+			}
 			exts, ok := deleteExtentsByPartition[ext.PartitionId]
 			if !ok {
 				exts = make([]*proto.ExtentKey, 0)
@@ -250,6 +259,10 @@ func (mp *metaPartition) deleteMarkedInodes(inoSlice []uint64) {
 		allInodes = append(allInodes, inode)
 	}
 	shouldCommit, shouldRePushToFreeList = mp.batchDeleteExtentsByPartition(deleteExtentsByPartition, allInodes)
+	var coldRetry []*Inode
+	shouldCommit, coldRetry = mp.deleteColdInodes(shouldCommit)
+	shouldRePushToFreeList = append(shouldRePushToFreeList, coldRetry...)
+	shouldCommit = mp.skipRestoredInodes(shouldCommit)
 	bufSlice := make([]byte, 0, 8*len(shouldCommit))
 	for _, inode := range shouldCommit {
 		bufSlice = append(bufSlice, inode.MarshalKey()...)
@@ -287,7 +300,7 @@ func (mp *metaPartition) syncToRaftFollowersFreeInode(hasDeleteInodes []byte) (e
 }
 
 func (mp *metaPartition) notifyRaftFollowerToFreeInodes(wg *sync.WaitGroup, target string, hasDeleteInodes []byte) (err error) {
-	var conn *net.TCPConn
+	var conn net.Conn
 	conn, err = mp.config.ConnPool.GetConnect(target)
 	defer func() {
 		wg.Done()
@@ -346,7 +359,12 @@ func (mp *metaPartition) doDeleteMarkedInodes(ext *proto.ExtentKey) (err error)
 			err.Error(), ext.PartitionId, ext.ExtentId)
 		return
 	}
-	p := NewPacketToDeleteExtent(dp, ext)
+	var p *Packet
+	if ext.IsPunchHole() {
+		p = NewPacketToPunchHole(dp, ext)
+	} else {
+		p = NewPacketToDeleteExtent(dp, ext)
+	}
 	if err = p.WriteToConn(conn); err != nil {
 		err = errors.NewErrorf("write to dataNode %s, %s", p.GetUniqueLogId(),
 			err.Error())
@@ -430,3 +448,17 @@ func (mp *metaPartition) persistDeletedInodes(inos []uint64) {
 		}
 	}
 }
+
+// skipRestoredInodes filters out the inodes restored from a snapshot while their extents
+// were being deleted, the extents of the snapshot they refer to are never deleted.
+func (mp *metaPartition) skipRestoredInodes(inodes []*Inode) []*Inode {
+	remained := inodes[:0]
+	for _, inode := range inodes {
+		if item := mp.inodeTree.Get(inode); item != nil && isRestorable(item.(*Inode)) {
+			log.LogInfof("[metaPartition] deleteWorker skip inode: %v as it is restored", inode.Inode)
+			continue
+		}
+		remained = append(remained, inode)
+	}
+	return remained
+}
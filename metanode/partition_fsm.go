@@ -74,6 +74,24 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		resp = mp.fsmExtentsTruncate(ino)
+	case opFSMExtentPunchHole:
+		req := &extentsPunchHole{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmExtentsPunchHole(req)
+	case opFSMWriteInline:
+		req := &inodeWriteInline{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmWriteInline(req)
+	case opFSMMigrateToCold:
+		req := &inodeMigrateToCold{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmMigrateToCold(req)
 	case opFSMCreateLinkInode:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
@@ -98,7 +116,7 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		if err != nil {
 			return
 		}
-		err = mp.fsmSetAttr(req)
+		resp = mp.fsmSetAttr(req)
 	case opFSMCreateDentry:
 		den := &Dentry{}
 		if err = den.Unmarshal(msg.V); err != nil {
@@ -146,6 +164,8 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		dentryTree := mp.getDentryTree()
 		extendTree := mp.extendTree.GetTree()
 		multipartTree := mp.multipartTree.GetTree()
+		versionTree := mp.versionTree.GetTree()
+		snapshots, heldExtents := mp.getSnapshotState()
 		msg := &storeMsg{
 			command:       opFSMStoreTick,
 			applyIndex:    index,
@@ -153,6 +173,9 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			dentryTree:    dentryTree,
 			extendTree:    extendTree,
 			multipartTree: multipartTree,
+			versionTree:   versionTree,
+			snapshots:     snapshots,
+			heldExtents:   heldExtents,
 		}
 		mp.storeChan <- msg
 	case opFSMInternalDeleteInode:
@@ -193,12 +216,49 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		var multipart *Multipart
 		multipart = MultipartFromBytes(msg.V)
 		resp = mp.fsmAppendMultipart(multipart)
+	case opFSMReplaceMultipartPart:
+		resp = mp.fsmReplaceMultipartPart(MultipartFromBytes(msg.V))
+	case opFSMPutObjectVersion:
+		resp = mp.fsmPutObjectVersion(ObjectVersionFromBytes(msg.V))
+	case opFSMRemoveObjectVersion:
+		resp = mp.fsmRemoveObjectVersion(ObjectVersionFromBytes(msg.V))
 	case opFSMSyncCursor:
 		var cursor uint64
 		cursor = binary.BigEndian.Uint64(msg.V)
 		if cursor > mp.config.Cursor {
 			mp.config.Cursor = cursor
 		}
+	case opFSMCreateSnapshot:
+		info := &proto.SnapshotInfo{}
+		if err = json.Unmarshal(msg.V, info); err != nil {
+			return
+		}
+		info.ApplyID = index
+		resp = mp.fsmCreateSnapshot(info)
+	case opFSMSealSnapshot:
+		req := &proto.SealSnapshotRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmSealSnapshot(req)
+	case opFSMDeleteSnapshot:
+		req := &proto.DeleteSnapshotRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmDeleteSnapshot(req)
+	case opFSMRestoreSnapshot:
+		req := &proto.RestoreSnapshotRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmRestoreSnapshot(req)
+	case opFSMSetInodeQuota:
+		req := &proto.SetInodeQuotaRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmSetInodeQuota(req)
 	}
 
 	return
@@ -217,6 +277,14 @@ func (mp *metaPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 	)
 	switch confChange.Type {
 	case raftproto.ConfAddNode:
+		if confChange.Peer.IsLearner() {
+			req := &proto.AddMetaPartitionRaftLearnerRequest{}
+			if err = json.Unmarshal(confChange.Context, req); err != nil {
+				return
+			}
+			updated, err = mp.confAddLearner(req, index)
+			break
+		}
 		req := &proto.AddMetaPartitionRaftMemberRequest{}
 		if err = json.Unmarshal(confChange.Context, req); err != nil {
 			return
@@ -229,7 +297,11 @@ func (mp *metaPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 		}
 		updated, err = mp.confRemoveNode(req, index)
 	case raftproto.ConfUpdateNode:
-		//updated, err = mp.confUpdateNode(req, index)
+		req := &proto.PromoteMetaPartitionLearnerRequest{}
+		if err = json.Unmarshal(confChange.Context, req); err != nil {
+			return
+		}
+		updated, err = mp.confPromoteLearner(req, index)
 	}
 	if err != nil {
 		return
@@ -261,14 +333,24 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 		dentryTree    = NewBtree()
 		extendTree    = NewBtree()
 		multipartTree = NewBtree()
+		versionTree   = NewBtree()
+		snapshots     []*subtreeSnapshot
+		heldExtents   []proto.ExtentKey
+		size          uint64
+		quotaUsages   = make(map[uint64]*proto.QuotaUsage)
 	)
 	defer func() {
 		if err == io.EOF {
 			mp.applyID = appIndexID
 			mp.inodeTree = inodeTree
+			atomic.StoreUint64(&mp.size, size)
+			mp.resetQuotaUsages(quotaUsages)
+			mp.dentryFoldTree = mp.buildDentryFoldTree(dentryTree)
 			mp.dentryTree = dentryTree
 			mp.extendTree = extendTree
 			mp.multipartTree = multipartTree
+			mp.versionTree = versionTree
+			mp.resetSnapshotState(snapshots, heldExtents)
 			mp.config.Cursor = cursor
 			err = nil
 			// store message
@@ -279,6 +361,9 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 				dentryTree:    mp.dentryTree,
 				extendTree:    mp.extendTree,
 				multipartTree: mp.multipartTree,
+				versionTree:   mp.versionTree,
+				snapshots:     snapshots,
+				heldExtents:   heldExtents,
 			}
 			select {
 			case mp.extReset <- struct{}{}:
@@ -317,6 +402,8 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 				cursor = ino.Inode
 			}
 			inodeTree.ReplaceOrInsert(ino, true)
+			size += ino.Size
+			addQuotaUsage(quotaUsages, ino.QuotaIDs, int64(ino.Size), 1)
 			log.LogDebugf("ApplySnapshot: create inode: partitonID(%v) inode(%v).", mp.config.PartitionId, ino)
 		case opFSMCreateDentry:
 			dentry := &Dentry{}
@@ -340,6 +427,17 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 			var multipart = MultipartFromBytes(snap.V)
 			multipartTree.ReplaceOrInsert(multipart, true)
 			log.LogDebugf("ApplySnapshot: create multipart: partitionID(%v) multipart(%v)", mp.config.PartitionId, multipart)
+		case opFSMPutObjectVersion:
+			var version = ObjectVersionFromBytes(snap.V)
+			versionTree.ReplaceOrInsert(version, true)
+			log.LogDebugf("ApplySnapshot: put object version: partitionID(%v) path(%v) versionID(%v)",
+				mp.config.PartitionId, version.path, version.versionId)
+		case opSubtreeSnapshotState:
+			if snapshots, heldExtents, err = readSubtreeSnapshots(snap.V); err != nil {
+				return
+			}
+			log.LogDebugf("ApplySnapshot: subtree snapshots: partitionID(%v) snapshots(%v) heldExtents(%v)",
+				mp.config.PartitionId, len(snapshots), len(heldExtents))
 		case opExtentFileSnapshot:
 			fileName := string(snap.K)
 			fileName = path.Join(mp.config.RootDir, fileName)
@@ -368,6 +466,7 @@ func (mp *metaPartition) HandleFatalEvent(err *raft.FatalError) {
 func (mp *metaPartition) HandleLeaderChange(leader uint64) {
 	exporter.Warning(fmt.Sprintf("metaPartition(%v) changeLeader to (%v)", mp.config.PartitionId, leader))
 	if mp.config.NodeId == leader {
+		mp.locks.reset()
 		conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", serverPort), time.Second)
 		if err != nil {
 			log.LogErrorf(fmt.Sprintf("HandleLeaderChange serverPort not exsit ,error %v", err))
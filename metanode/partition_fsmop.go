@@ -106,6 +106,28 @@ func (mp *metaPartition) confAddNode(req *proto.AddMetaPartitionRaftMemberReques
 	return
 }
 
+func (mp *metaPartition) confAddLearner(req *proto.AddMetaPartitionRaftLearnerRequest, index uint64) (updated bool, err error) {
+	addReq := &proto.AddMetaPartitionRaftMemberRequest{PartitionId: req.PartitionId, AddPeer: req.AddLearner}
+	if updated, err = mp.confAddNode(addReq, index); err != nil || !updated {
+		return
+	}
+	mp.config.Learners = append(mp.config.Learners, req.AddLearner)
+	return
+}
+
+func (mp *metaPartition) confPromoteLearner(req *proto.PromoteMetaPartitionLearnerRequest, index uint64) (updated bool, err error) {
+	for i, learner := range mp.config.Learners {
+		if learner.ID == req.PromoteLearner.ID {
+			mp.config.Learners = append(mp.config.Learners[:i], mp.config.Learners[i+1:]...)
+			updated = true
+			break
+		}
+	}
+	log.LogInfof("PromoteLearner PartitionID(%v) nodeID(%v) learner(%v) updated(%v)",
+		req.PartitionId, mp.config.NodeId, req.PromoteLearner, updated)
+	return
+}
+
 func (mp *metaPartition) confRemoveNode(req *proto.RemoveMetaPartitionRaftMemberRequest, index uint64) (updated bool, err error) {
 	var canRemoveSelf bool
 	if canRemoveSelf, err = mp.canRemoveSelf(); err != nil {
@@ -128,6 +150,12 @@ func (mp *metaPartition) confRemoveNode(req *proto.RemoveMetaPartitionRaftMember
 		return
 	}
 	mp.config.Peers = append(mp.config.Peers[:peerIndex], mp.config.Peers[peerIndex+1:]...)
+	for i, learner := range mp.config.Learners {
+		if learner.ID == req.RemovePeer.ID {
+			mp.config.Learners = append(mp.config.Learners[:i], mp.config.Learners[i+1:]...)
+			break
+		}
+	}
 	if mp.config.NodeId == req.RemovePeer.ID && !mp.isLoadingMetaPartition && canRemoveSelf {
 		mp.Stop()
 		mp.DeleteRaft()
@@ -225,6 +253,24 @@ func (mp *metaPartition) CanRemoveRaftMember(peer proto.Peer) error {
 	return fmt.Errorf("downReplicas(%v) too much,so donnot offline (%v)", downReplicas, peer)
 }
 
+// CanPromoteLearner checks that the learner has caught up with the leader.
+// It must be called on the raft leader, which is the only one tracking the replication progress.
+func (mp *metaPartition) CanPromoteLearner(peer proto.Peer) error {
+	if !mp.IsLearner(peer) {
+		return fmt.Errorf("peer(%v) is not a learner of partition(%v)", peer, mp.config.PartitionId)
+	}
+	return mp.raftPartition.CanPromoteLearner(peer.ID)
+}
+
+// SetSnapshotRateLimit limits the rate of the raft snapshot transfer of the partition.
+func (mp *metaPartition) SetSnapshotRateLimit(bytesPerSec int64) error {
+	if mp.raftPartition == nil {
+		return fmt.Errorf("raft of partition(%v) is not started", mp.config.PartitionId)
+	}
+	mp.raftPartition.SetSnapshotRateLimit(bytesPerSec)
+	return nil
+}
+
 func (mp *metaPartition) IsEquareCreateMetaPartitionRequst(request *proto.CreateMetaPartitionRequest) (err error) {
 	if len(mp.config.Peers) != len(request.Members) {
 		return fmt.Errorf("Exsit unavali Partition(%v) partitionHosts(%v) requestHosts(%v)", mp.config.PartitionId, mp.config.Peers, request.Members)
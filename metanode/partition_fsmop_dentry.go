@@ -53,6 +53,13 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 			return
 		}
 	}
+	if mp.config.CaseInsensitive {
+		// a dentry differing only in case is a conflict, not a new entry
+		if name, ok := mp.resolveDentryName(dentry.ParentId, dentry.Name); ok && name != dentry.Name {
+			status = proto.OpExistErr
+			return
+		}
+	}
 	if item, ok := mp.dentryTree.ReplaceOrInsert(dentry, false); !ok {
 		//do not allow directories and files to overwrite each
 		// other when renaming
@@ -68,6 +75,9 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 
 		status = proto.OpExistErr
 	} else {
+		if mp.config.CaseInsensitive {
+			mp.dentryFoldTree.ReplaceOrInsert(newFoldedDentry(dentry.ParentId, dentry.Name), true)
+		}
 		if !forceUpdate {
 			parIno.IncNLink()
 			parIno.SetMtime()
@@ -77,9 +87,52 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 	return
 }
 
+// resolveDentryName returns the case-preserved name of the dentry whose name
+// equals the given one ignoring case.
+func (mp *metaPartition) resolveDentryName(parentID uint64, name string) (string, bool) {
+	item := mp.dentryFoldTree.Get(newFoldedDentry(parentID, name))
+	if item == nil {
+		return "", false
+	}
+	return item.(*FoldedDentry).Name, true
+}
+
+// canonicalizeDentry rewrites the name of the given dentry to the stored one
+// if the partition is case-insensitive.
+func (mp *metaPartition) canonicalizeDentry(dentry *Dentry) {
+	if !mp.config.CaseInsensitive {
+		return
+	}
+	if name, ok := mp.resolveDentryName(dentry.ParentId, dentry.Name); ok {
+		dentry.Name = name
+	}
+}
+
+// isProtectedInode checks whether the inode is immutable or append-only, which
+// can only be done if the inode belongs to this partition.
+func (mp *metaPartition) isProtectedInode(ino uint64) bool {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	return item != nil && item.(*Inode).IsProtected()
+}
+
+// buildDentryFoldTree builds the case-folded index for the given dentry tree.
+func (mp *metaPartition) buildDentryFoldTree(dentryTree *BTree) *BTree {
+	foldTree := NewBtree()
+	if !mp.config.CaseInsensitive {
+		return foldTree
+	}
+	dentryTree.Ascend(func(i BtreeItem) bool {
+		d := i.(*Dentry)
+		foldTree.ReplaceOrInsert(newFoldedDentry(d.ParentId, d.Name), true)
+		return true
+	})
+	return foldTree
+}
+
 // Query a dentry from the dentry tree with specified dentry info.
 func (mp *metaPartition) getDentry(dentry *Dentry) (*Dentry, uint8) {
 	status := proto.OpOk
+	mp.canonicalizeDentry(dentry)
 	item := mp.dentryTree.Get(dentry)
 	if item == nil {
 		status = proto.OpNotExistErr
@@ -94,6 +147,11 @@ func (mp *metaPartition) fsmDeleteDentry(dentry *Dentry, checkInode bool) (
 	resp *DentryResponse) {
 	resp = NewDentryResponse()
 	resp.Status = proto.OpOk
+	mp.canonicalizeDentry(dentry)
+	if d := mp.dentryTree.Get(dentry); d != nil && mp.isProtectedInode(d.(*Dentry).Inode) {
+		resp.Status = proto.OpNotPerm
+		return
+	}
 
 	var item interface{}
 	if checkInode {
@@ -115,6 +173,9 @@ func (mp *metaPartition) fsmDeleteDentry(dentry *Dentry, checkInode bool) (
 		resp.Status = proto.OpNotExistErr
 		return
 	} else {
+		if mp.config.CaseInsensitive {
+			mp.dentryFoldTree.Delete(newFoldedDentry(dentry.ParentId, dentry.Name))
+		}
 		mp.inodeTree.CopyFind(NewInode(dentry.ParentId, 0),
 			func(item BtreeItem) {
 				if item != nil {
@@ -143,12 +204,17 @@ func (mp *metaPartition) fsmUpdateDentry(dentry *Dentry) (
 	resp *DentryResponse) {
 	resp = NewDentryResponse()
 	resp.Status = proto.OpOk
+	mp.canonicalizeDentry(dentry)
 	mp.dentryTree.CopyFind(dentry, func(item BtreeItem) {
 		if item == nil {
 			resp.Status = proto.OpNotExistErr
 			return
 		}
 		d := item.(*Dentry)
+		if mp.isProtectedInode(d.Inode) {
+			resp.Status = proto.OpNotPerm
+			return
+		}
 		d.Inode, dentry.Inode = dentry.Inode, d.Inode
 		resp.Msg = dentry
 	})
@@ -159,7 +225,7 @@ func (mp *metaPartition) getDentryTree() *BTree {
 	return mp.dentryTree.GetTree()
 }
 
-func (mp *metaPartition) readDir(req *ReadDirReq) (resp *ReadDirResp) {
+func (mp *metaPartition) readDir(dentryTree *BTree, req *ReadDirReq) (resp *ReadDirResp) {
 	resp = &ReadDirResp{}
 	begDentry := &Dentry{
 		ParentId: req.ParentID,
@@ -167,7 +233,7 @@ func (mp *metaPartition) readDir(req *ReadDirReq) (resp *ReadDirResp) {
 	endDentry := &Dentry{
 		ParentId: req.ParentID + 1,
 	}
-	mp.dentryTree.AscendRange(begDentry, endDentry, func(i BtreeItem) bool {
+	dentryTree.AscendRange(begDentry, endDentry, func(i BtreeItem) bool {
 		d := i.(*Dentry)
 		resp.Children = append(resp.Children, proto.Dentry{
 			Inode: d.Inode,
@@ -185,7 +251,7 @@ func (mp *metaPartition) readDir(req *ReadDirReq) (resp *ReadDirResp) {
 // else if req.Marker == "" and req.Limit != 0, return dentries from pid with limit count
 // else if req.Marker != "" and req.Limit != 0, return dentries from pid:marker to pid:xxxx with limit count
 //
-func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitResp) {
+func (mp *metaPartition) readDirLimit(dentryTree *BTree, req *ReadDirLimitReq) (resp *ReadDirLimitResp) {
 	resp = &ReadDirLimitResp{}
 	startDentry := &Dentry{
 		ParentId: req.ParentID,
@@ -196,7 +262,7 @@ func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitR
 	endDentry := &Dentry{
 		ParentId: req.ParentID + 1,
 	}
-	mp.dentryTree.AscendRange(startDentry, endDentry, func(i BtreeItem) bool {
+	dentryTree.AscendRange(startDentry, endDentry, func(i BtreeItem) bool {
 		d := i.(*Dentry)
 		resp.Children = append(resp.Children, proto.Dentry{
 			Inode: d.Inode,
@@ -212,7 +278,7 @@ func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitR
 	return
 }
 
-func (mp *metaPartition) readDirOnly(req *ReadDirOnlyReq) (resp *ReadDirOnlyResp) {
+func (mp *metaPartition) readDirOnly(dentryTree *BTree, req *ReadDirOnlyReq) (resp *ReadDirOnlyResp) {
 	resp = &ReadDirOnlyResp{}
 	begDentry := &Dentry{
 		ParentId: req.ParentID,
@@ -220,7 +286,7 @@ func (mp *metaPartition) readDirOnly(req *ReadDirOnlyReq) (resp *ReadDirOnlyResp
 	endDentry := &Dentry{
 		ParentId: req.ParentID + 1,
 	}
-	mp.dentryTree.AscendRange(begDentry, endDentry, func(i BtreeItem) bool {
+	dentryTree.AscendRange(begDentry, endDentry, func(i BtreeItem) bool {
 		d := i.(*Dentry)
 		if proto.IsDir(d.Type) {
 			resp.Children = append(resp.Children, proto.Dentry{
@@ -19,6 +19,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sync/atomic"
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
@@ -39,10 +40,21 @@ func (mp *metaPartition) fsmCreateInode(ino *Inode) (status uint8) {
 	status = proto.OpOk
 	if _, ok := mp.inodeTree.ReplaceOrInsert(ino, false); !ok {
 		status = proto.OpExistErr
+		return
 	}
+	mp.accountInode(ino, 0, ino.Size, 1)
 	return
 }
 
+// updateSize applies the size change of an inode to the size of the partition.
+func (mp *metaPartition) updateSize(oldSize, newSize uint64) {
+	if newSize >= oldSize {
+		atomic.AddUint64(&mp.size, newSize-oldSize)
+		return
+	}
+	atomic.AddUint64(&mp.size, ^(oldSize - newSize - 1))
+}
+
 func (mp *metaPartition) fsmCreateLinkInode(ino *Inode) (resp *InodeResponse) {
 	resp = NewInodeResponse()
 	resp.Status = proto.OpOk
@@ -56,6 +68,10 @@ func (mp *metaPartition) fsmCreateLinkInode(ino *Inode) (resp *InodeResponse) {
 		resp.Status = proto.OpNotExistErr
 		return
 	}
+	if i.IsProtected() {
+		resp.Status = proto.OpNotPerm
+		return
+	}
 	i.IncNLink()
 	resp.Msg = i
 	return
@@ -121,11 +137,16 @@ func (mp *metaPartition) fsmUnlinkInode(ino *Inode) (resp *InodeResponse) {
 		resp.Status = proto.OpNotExistErr
 		return
 	}
+	if inode.IsProtected() {
+		resp.Status = proto.OpNotPerm
+		return
+	}
 
 	resp.Msg = inode
 
 	if inode.IsEmptyDir() {
 		mp.inodeTree.Delete(inode)
+		mp.accountInode(inode, inode.Size, 0, -1)
 	}
 
 	inode.DecNLink()
@@ -195,6 +216,10 @@ func (mp *metaPartition) internalDeleteBatch(val []byte) error {
 }
 
 func (mp *metaPartition) internalDeleteInode(ino *Inode) {
+	if item := mp.inodeTree.Get(ino); item != nil {
+		mp.holdSnapshotExtents(item.(*Inode))
+		mp.accountInode(item.(*Inode), item.(*Inode).Size, 0, -1)
+	}
 	mp.inodeTree.Delete(ino)
 	mp.freeList.Remove(ino.Inode)
 	mp.extendTree.Delete(&Extend{inode: ino.Inode}) // Also delete extend attribute.
@@ -214,9 +239,14 @@ func (mp *metaPartition) fsmAppendExtents(ino *Inode) (status uint8) {
 		return
 	}
 	eks := ino.Extents.CopyExtents()
+	if status = mp.checkExtentsWritable(ino2, eks); status != proto.OpOk {
+		return
+	}
+	oldSize := ino2.Size
 	delExtents := ino2.AppendExtents(eks, ino.ModifyTime)
+	mp.accountInode(ino2, oldSize, ino2.Size, 0)
 	log.LogInfof("fsmAppendExtents inode(%v) deleteExtents(%v)", ino2.Inode, delExtents)
-	mp.extDelCh <- delExtents
+	mp.extDelCh <- mp.retainSnapshotExtents(delExtents)
 	return
 }
 
@@ -239,12 +269,17 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode) (status uint8) {
 	if len(eks) < 1 {
 		return
 	}
+	if status = mp.checkExtentsWritable(ino2, eks[:1]); status != proto.OpOk {
+		return
+	}
 	if len(eks) > 1 {
 		discardExtentKey = eks[1:]
 	}
+	oldSize := ino2.Size
 	delExtents, status := ino2.AppendExtentWithCheck(eks[0], ino.ModifyTime, discardExtentKey)
+	mp.accountInode(ino2, oldSize, ino2.Size, 0)
 	if status == proto.OpOk && delExtents != nil && len(delExtents) > 0 {
-		mp.extDelCh <- delExtents
+		mp.extDelCh <- mp.retainSnapshotExtents(delExtents)
 	}
 	msg := fmt.Sprintf("fsmAppendExtentWithCheck inode(%v) ek(%v) server deleteExtents(%v) request discardExtents(%v) status(%v)", ino2.Inode, eks[0], delExtents, discardExtentKey, status)
 	if status != proto.OpOk {
@@ -272,12 +307,114 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 		resp.Status = proto.OpArgMismatchErr
 		return
 	}
+	if i.IsProtected() {
+		resp.Status = proto.OpNotPerm
+		return
+	}
 
+	oldSize := i.Size
 	delExtents := i.ExtentsTruncate(ino.Size, ino.ModifyTime)
+	mp.accountInode(i, oldSize, i.Size, 0)
 
 	// now we should delete the extent
 	log.LogInfof("fsmExtentsTruncate inode(%v) exts(%v)", i.Inode, delExtents)
-	mp.extDelCh <- delExtents
+	mp.extDelCh <- mp.retainSnapshotExtents(delExtents)
+	return
+}
+
+func (mp *metaPartition) fsmExtentsPunchHole(req *extentsPunchHole) (status uint8) {
+	status = proto.OpOk
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		status = proto.OpNotExistErr
+		return
+	}
+	i := item.(*Inode)
+	if i.ShouldDelete() {
+		status = proto.OpNotExistErr
+		return
+	}
+	if proto.IsDir(i.Type) {
+		status = proto.OpArgMismatchErr
+		return
+	}
+	// the data in the blobs of the cold tier cannot be deallocated by ranges
+	if cold := i.ColdLocation(); cold != nil && req.Offset < cold.Size {
+		status = proto.OpArgMismatchErr
+		return
+	}
+	if i.IsProtected() {
+		status = proto.OpNotPerm
+		return
+	}
+
+	holes := i.ExtentsPunchHole(req.Offset, req.Size, req.ModifyTime)
+	log.LogInfof("fsmExtentsPunchHole inode(%v) offset(%v) size(%v) holes(%v)", i.Inode, req.Offset, req.Size, holes)
+	mp.extDelCh <- mp.retainSnapshotExtents(holes)
+	return
+}
+
+// fsmWriteInline stores the data inline in the inode. The inode is rejected with
+// OpArgMismatchErr once its data has been moved to the extents or the cold tier.
+func (mp *metaPartition) fsmWriteInline(req *inodeWriteInline) (status uint8) {
+	status = proto.OpOk
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		status = proto.OpNotExistErr
+		return
+	}
+	i := item.(*Inode)
+	if i.ShouldDelete() {
+		status = proto.OpNotExistErr
+		return
+	}
+	if !proto.IsRegular(i.Type) || i.Extents.Len() > 0 || i.ColdLocation() != nil {
+		status = proto.OpArgMismatchErr
+		return
+	}
+	if i.IsImmutable() {
+		status = proto.OpNotPerm
+		return
+	}
+	if i.IsAppendOnly() {
+		var appended bool
+		i.DoReadFunc(func() {
+			appended = bytes.HasPrefix(req.Data, i.InlineData) && uint64(len(req.Data)) >= i.Size
+		})
+		if !appended {
+			status = proto.OpNotPerm
+			return
+		}
+	}
+	oldSize := i.Size
+	i.WriteInline(req.Data, req.ModifyTime)
+	mp.accountInode(i, oldSize, i.Size, 0)
+	return
+}
+
+// fsmMigrateToCold records the location of the data of the inode in the cold tier and releases
+// its extents. OpAgain is returned if the inode has been modified or accessed since its data was
+// read, in which case the blobs put by the leader are deleted by it.
+func (mp *metaPartition) fsmMigrateToCold(req *inodeMigrateToCold) (status uint8) {
+	status = proto.OpOk
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		status = proto.OpNotExistErr
+		return
+	}
+	i := item.(*Inode)
+	if i.ShouldDelete() {
+		status = proto.OpNotExistErr
+		return
+	}
+	delExtents, ok := i.MigrateToCold(req.Generation, req.ColdBefore, req.Cold)
+	if !ok {
+		status = proto.OpAgain
+		return
+	}
+	log.LogInfof("fsmMigrateToCold inode(%v) gen(%v) size(%v) blobs(%v) delExtents(%v)",
+		i.Inode, req.Generation, req.Cold.Size, len(req.Cold.Blobs), len(delExtents))
+	mp.extDelCh <- mp.retainSnapshotExtents(delExtents)
 	return
 }
 
@@ -327,7 +464,29 @@ func (mp *metaPartition) checkAndInsertFreeList(ino *Inode) {
 	}
 }
 
-func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
+// checkExtentsWritable checks the immutable and append-only flags of the inode
+// before the extent keys are applied.
+func (mp *metaPartition) checkExtentsWritable(ino *Inode, eks []proto.ExtentKey) (status uint8) {
+	status = proto.OpOk
+	if ino.IsImmutable() {
+		status = proto.OpNotPerm
+		return
+	}
+	if !ino.IsAppendOnly() {
+		return
+	}
+	for _, ek := range eks {
+		if !ino.CanAppendExtent(ek) {
+			log.LogWarnf("checkExtentsWritable: append-only inode(%v) rejects ek(%v)", ino.Inode, ek)
+			status = proto.OpNotPerm
+			return
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (status uint8) {
+	status = proto.OpOk
 	ino := NewInode(req.Inode, req.Mode)
 	item := mp.inodeTree.CopyGet(ino)
 	if item == nil {
@@ -337,6 +496,11 @@ func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
 	if ino.ShouldDelete() {
 		return
 	}
+	// nothing but the flags can be changed on an immutable inode
+	if ino.IsImmutable() && req.Valid&^proto.AttrFlags != 0 {
+		status = proto.OpNotPerm
+		return
+	}
 	ino.SetAttr(req)
 	return
 }
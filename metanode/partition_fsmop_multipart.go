@@ -32,6 +32,32 @@ func (mp *metaPartition) fsmRemoveMultipart(multipart *Multipart) (status uint8)
 	return proto.OpOk
 }
 
+type MultipartPartResponse struct {
+	Status   uint8
+	Replaced *Part
+}
+
+// fsmReplaceMultipartPart stores the parts of the multipart, and returns the part replaced by them.
+func (mp *metaPartition) fsmReplaceMultipartPart(multipart *Multipart) (resp *MultipartPartResponse) {
+	resp = &MultipartPartResponse{Status: proto.OpOk}
+	storedItem := mp.multipartTree.CopyGet(multipart)
+	if storedItem == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	storedMultipart, is := storedItem.(*Multipart)
+	if !is {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	for _, part := range multipart.Parts() {
+		if replaced := storedMultipart.ReplacePart(part); replaced != nil && !replaced.Equal(part) {
+			resp.Replaced = replaced
+		}
+	}
+	return
+}
+
 func (mp *metaPartition) fsmAppendMultipart(multipart *Multipart) (status uint8) {
 	storedItem := mp.multipartTree.CopyGet(multipart)
 	if storedItem == nil {
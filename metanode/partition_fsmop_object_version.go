@@ -0,0 +1,30 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import "github.com/cubefs/cubefs/proto"
+
+func (mp *metaPartition) fsmPutObjectVersion(version *ObjectVersion) (status uint8) {
+	mp.versionTree.ReplaceOrInsert(version, true)
+	return proto.OpOk
+}
+
+func (mp *metaPartition) fsmRemoveObjectVersion(version *ObjectVersion) (status uint8) {
+	deletedItem := mp.versionTree.Delete(version)
+	if deletedItem == nil {
+		return proto.OpNotExistErr
+	}
+	return proto.OpOk
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sort"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// fsmSetInodeQuota tags or untags the inodes with the directory quota, whose usage is moved along.
+// The inodes missing or already tagged as requested are skipped.
+func (mp *metaPartition) fsmSetInodeQuota(req *proto.SetInodeQuotaRequest) (status uint8) {
+	for _, id := range req.Inodes {
+		item := mp.inodeTree.CopyGet(NewInode(id, 0))
+		if item == nil {
+			continue
+		}
+		ino := item.(*Inode)
+		quotaIDs, changed := setQuotaID(ino.GetQuotaIDs(), req.QuotaID, req.Remove)
+		if !changed {
+			continue
+		}
+		size := ino.Size
+		mp.accountInode(ino, size, 0, -1)
+		ino.SetQuotaIDs(quotaIDs)
+		mp.accountInode(ino, 0, size, 1)
+	}
+	return proto.OpOk
+}
+
+// setQuotaID returns a copy of the sorted IDs with the ID added or removed, and if it is changed.
+func setQuotaID(quotaIDs []uint64, id uint64, remove bool) (result []uint64, changed bool) {
+	i := sort.Search(len(quotaIDs), func(i int) bool { return quotaIDs[i] >= id })
+	found := i < len(quotaIDs) && quotaIDs[i] == id
+	if found != remove {
+		return quotaIDs, false
+	}
+	result = make([]uint64, 0, len(quotaIDs)+1)
+	result = append(result, quotaIDs[:i]...)
+	if !remove {
+		result = append(result, id)
+		return append(result, quotaIDs[i:]...), true
+	}
+	return append(result, quotaIDs[i+1:]...), true
+}
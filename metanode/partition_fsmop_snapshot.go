@@ -0,0 +1,391 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// fsmCreateSnapshot freezes the current namespace of the partition. The trees are
+// cloned copy-on-write, so this does not copy any inode or dentry.
+func (mp *metaPartition) fsmCreateSnapshot(info *proto.SnapshotInfo) (status uint8) {
+	mp.snapshotMu.Lock()
+	defer mp.snapshotMu.Unlock()
+	if _, ok := mp.snapshots[info.ID]; ok {
+		return proto.OpExistErr
+	}
+	s := &subtreeSnapshot{
+		ID:         info.ID,
+		Name:       info.Name,
+		RootIno:    info.RootIno,
+		CreateTime: info.CreateTime,
+		ApplyID:    info.ApplyID,
+		inodeTree:  mp.inodeTree.GetTree(),
+		dentryTree: mp.dentryTree.GetTree(),
+	}
+	mp.snapshots[s.ID] = s
+	mp.addSnapshotExtentRefs(s)
+	log.LogInfof("fsmCreateSnapshot: partitionID(%v) snapshot(%v) name(%v) root(%v) inodes(%v) dentries(%v)",
+		mp.config.PartitionId, s.ID, s.Name, s.RootIno, s.inodeTree.Len(), s.dentryTree.Len())
+	return proto.OpOk
+}
+
+// fsmSealSnapshot drops everything but the given inodes and their children from
+// the snapshot, so that it only keeps the subtree it was taken for.
+func (mp *metaPartition) fsmSealSnapshot(req *proto.SealSnapshotRequest) (status uint8) {
+	mp.snapshotMu.Lock()
+	defer mp.snapshotMu.Unlock()
+	s, ok := mp.snapshots[req.SnapshotID]
+	if !ok {
+		return proto.OpNotExistErr
+	}
+	if s.Sealed {
+		return proto.OpOk
+	}
+	reachable := make(map[uint64]struct{}, len(req.Inodes))
+	for _, ino := range req.Inodes {
+		reachable[ino] = struct{}{}
+	}
+	inodeTree := NewBtree()
+	s.inodeTree.Ascend(func(i BtreeItem) bool {
+		if _, ok := reachable[i.(*Inode).Inode]; ok {
+			inodeTree.ReplaceOrInsert(i, true)
+		}
+		return true
+	})
+	dentryTree := NewBtree()
+	s.dentryTree.Ascend(func(i BtreeItem) bool {
+		if _, ok := reachable[i.(*Dentry).ParentId]; ok {
+			dentryTree.ReplaceOrInsert(i, true)
+		}
+		return true
+	})
+
+	mp.removeSnapshotExtentRefs(s)
+	s.inodeTree = inodeTree
+	s.dentryTree = dentryTree
+	s.Sealed = true
+	mp.addSnapshotExtentRefs(s)
+	mp.releaseHeldExtents()
+	log.LogInfof("fsmSealSnapshot: partitionID(%v) snapshot(%v) inodes(%v) dentries(%v)",
+		mp.config.PartitionId, s.ID, inodeTree.Len(), dentryTree.Len())
+	return proto.OpOk
+}
+
+// fsmDeleteSnapshot removes the snapshot and frees the extents that were only kept for it.
+func (mp *metaPartition) fsmDeleteSnapshot(req *proto.DeleteSnapshotRequest) (status uint8) {
+	mp.snapshotMu.Lock()
+	defer mp.snapshotMu.Unlock()
+	s, ok := mp.snapshots[req.SnapshotID]
+	if !ok {
+		return proto.OpNotExistErr
+	}
+	delete(mp.snapshots, s.ID)
+	mp.removeSnapshotExtentRefs(s)
+	mp.releaseHeldExtents()
+	log.LogInfof("fsmDeleteSnapshot: partitionID(%v) snapshot(%v)", mp.config.PartitionId, s.ID)
+	return proto.OpOk
+}
+
+// fsmRestoreSnapshot rolls the namespace of the partition back to the snapshot, or only
+// the given files if any. The extents of the replaced inodes which the restored ones do
+// not refer to are deleted. The partition created after the snapshot is rolled back to
+// an empty namespace.
+func (mp *metaPartition) fsmRestoreSnapshot(req *proto.RestoreSnapshotRequest) (status uint8) {
+	if req.Clear {
+		// the partition holding the snapshot is restored to it rather than cleared
+		if _, ok := mp.getSnapshot(req.SnapshotID); ok {
+			return proto.OpArgMismatchErr
+		}
+		mp.restoreSnapshotNamespace(&subtreeSnapshot{ID: req.SnapshotID, inodeTree: NewBtree(), dentryTree: NewBtree()})
+		log.LogInfof("fsmRestoreSnapshot: partitionID(%v) cleared for snapshot(%v)", mp.config.PartitionId, req.SnapshotID)
+		return proto.OpOk
+	}
+	s, ok := mp.getSnapshot(req.SnapshotID)
+	if !ok {
+		return proto.OpNotExistErr
+	}
+	if len(req.Inodes) == 0 {
+		mp.restoreSnapshotNamespace(s)
+		log.LogInfof("fsmRestoreSnapshot: partitionID(%v) snapshot(%v) inodes(%v) dentries(%v)",
+			mp.config.PartitionId, s.ID, s.inodeTree.Len(), s.dentryTree.Len())
+		return proto.OpOk
+	}
+
+	inodes := make([]*Inode, 0, len(req.Inodes))
+	for _, ino := range req.Inodes {
+		item := s.inodeTree.Get(NewInode(ino, 0))
+		if item == nil || !isRestorable(item.(*Inode)) {
+			return proto.OpNotExistErr
+		}
+		if proto.IsDir(item.(*Inode).Type) {
+			return proto.OpArgMismatchErr
+		}
+		inodes = append(inodes, item.(*Inode))
+	}
+	for _, ino := range inodes {
+		restored := ino.Copy().(*Inode)
+		// the file keeps its links if it is still alive, or is linked again by the client
+		restored.NLink = 1
+		if item := mp.inodeTree.Get(ino); item != nil && isRestorable(item.(*Inode)) {
+			restored.NLink = item.(*Inode).GetNLink()
+		}
+		mp.restoreInode(restored)
+	}
+	log.LogInfof("fsmRestoreSnapshot: partitionID(%v) snapshot(%v) files(%v)",
+		mp.config.PartitionId, s.ID, req.Inodes)
+	return proto.OpOk
+}
+
+// restoreSnapshotNamespace replaces the inodes and dentries of the partition by the ones of the snapshot.
+func (mp *metaPartition) restoreSnapshotNamespace(s *subtreeSnapshot) {
+	var (
+		dropped  []*Inode
+		restored []*Inode
+	)
+	mp.inodeTree.Ascend(func(i BtreeItem) bool {
+		if item := s.inodeTree.Get(i); item == nil || !isRestorable(item.(*Inode)) {
+			dropped = append(dropped, i.(*Inode))
+		}
+		return true
+	})
+	s.inodeTree.Ascend(func(i BtreeItem) bool {
+		ino := i.(*Inode)
+		if isRestorable(ino) && mp.inodeTree.Get(ino) != i {
+			restored = append(restored, ino)
+		}
+		return true
+	})
+	for _, ino := range dropped {
+		if proto.IsDir(ino.Type) {
+			mp.inodeTree.Delete(ino)
+			mp.freeList.Remove(ino.Inode)
+			mp.accountInode(ino, ino.Size, 0, -1)
+			continue
+		}
+		if item := mp.inodeTree.CopyGet(ino); item != nil && !item.(*Inode).ShouldDelete() {
+			item.(*Inode).SetDeleteMark()
+			mp.freeList.Push(ino.Inode)
+		}
+	}
+	for _, ino := range restored {
+		mp.restoreInode(ino.Copy().(*Inode))
+	}
+
+	var (
+		deleted  []*Dentry
+		replaced []*Dentry
+	)
+	mp.dentryTree.Ascend(func(i BtreeItem) bool {
+		if s.dentryTree.Get(i) == nil {
+			deleted = append(deleted, i.(*Dentry))
+		}
+		return true
+	})
+	s.dentryTree.Ascend(func(i BtreeItem) bool {
+		d := i.(*Dentry)
+		if item := mp.dentryTree.Get(d); item == nil || item.(*Dentry).Inode != d.Inode || item.(*Dentry).Type != d.Type {
+			replaced = append(replaced, d)
+		}
+		return true
+	})
+	for _, d := range deleted {
+		mp.dentryTree.Delete(d)
+		if mp.config.CaseInsensitive {
+			mp.dentryFoldTree.Delete(newFoldedDentry(d.ParentId, d.Name))
+		}
+	}
+	for _, d := range replaced {
+		mp.dentryTree.ReplaceOrInsert(d.Copy(), true)
+		if mp.config.CaseInsensitive {
+			mp.dentryFoldTree.ReplaceOrInsert(newFoldedDentry(d.ParentId, d.Name), true)
+		}
+	}
+}
+
+// restoreInode puts the inode restored from a snapshot into the live tree. The extents
+// of the replaced inode which the restored one does not refer to are deleted, and the
+// extents held for the snapshot which are restored are not held anymore.
+func (mp *metaPartition) restoreInode(ino *Inode) {
+	var freed []proto.ExtentKey
+	if item := mp.inodeTree.Get(ino); item != nil {
+		live := item.(*Inode)
+		kept := make(map[snapshotExtentKey]struct{}, ino.Extents.Len())
+		ino.Extents.Range(func(ek proto.ExtentKey) bool {
+			kept[newSnapshotExtentKey(&ek)] = struct{}{}
+			return true
+		})
+		live.Extents.Range(func(ek proto.ExtentKey) bool {
+			if _, ok := kept[newSnapshotExtentKey(&ek)]; !ok {
+				freed = append(freed, ek)
+			}
+			return true
+		})
+		mp.accountInode(live, live.Size, 0, -1)
+	}
+	mp.accountInode(ino, 0, ino.Size, 1)
+	mp.inodeTree.ReplaceOrInsert(ino, true)
+	mp.freeList.Remove(ino.Inode)
+	mp.unholdSnapshotExtents(ino)
+	if len(freed) > 0 {
+		mp.extDelCh <- mp.retainSnapshotExtents(freed)
+	}
+}
+
+// isRestorable returns if the inode is alive in the namespace it belongs to.
+func isRestorable(ino *Inode) bool {
+	return !ino.ShouldDelete() && (proto.IsDir(ino.Type) || ino.GetNLink() > 0)
+}
+
+func (mp *metaPartition) addSnapshotExtentRefs(s *subtreeSnapshot) {
+	s.rangeExtents(func(ek *proto.ExtentKey) {
+		mp.snapshotExtents[newSnapshotExtentKey(ek)]++
+	})
+}
+
+func (mp *metaPartition) removeSnapshotExtentRefs(s *subtreeSnapshot) {
+	s.rangeExtents(func(ek *proto.ExtentKey) {
+		key := newSnapshotExtentKey(ek)
+		if mp.snapshotExtents[key] <= 1 {
+			delete(mp.snapshotExtents, key)
+			return
+		}
+		mp.snapshotExtents[key]--
+	})
+}
+
+// releaseHeldExtents deletes the held extents that no snapshot refers to anymore.
+func (mp *metaPartition) releaseHeldExtents() {
+	var eks []proto.ExtentKey
+	for key, ek := range mp.heldExtents {
+		if _, ok := mp.snapshotExtents[key]; ok {
+			continue
+		}
+		eks = append(eks, ek)
+		delete(mp.heldExtents, key)
+	}
+	if len(eks) > 0 {
+		log.LogInfof("releaseHeldExtents: partitionID(%v) extents(%v)", mp.config.PartitionId, eks)
+		mp.extDelCh <- eks
+	}
+}
+
+// retainSnapshotExtents filters the extents released by the live tree. The ones
+// still referenced by a snapshot are held back, the others are returned to be deleted.
+func (mp *metaPartition) retainSnapshotExtents(eks []proto.ExtentKey) []proto.ExtentKey {
+	mp.snapshotMu.Lock()
+	defer mp.snapshotMu.Unlock()
+	if len(mp.snapshotExtents) == 0 {
+		return eks
+	}
+	free := make([]proto.ExtentKey, 0, len(eks))
+	for _, ek := range eks {
+		key := newSnapshotExtentKey(&ek)
+		if _, ok := mp.snapshotExtents[key]; ok {
+			// a hole punched in an extent of a snapshot is never released
+			if !ek.IsPunchHole() {
+				mp.heldExtents[key] = ek
+			}
+			continue
+		}
+		free = append(free, ek)
+	}
+	return free
+}
+
+// holdSnapshotExtents records the extents of an inode removed by the free list
+// which are still referenced by a snapshot. The leader skips them when it deletes
+// the extents of the inode, see isSnapshotExtent.
+func (mp *metaPartition) holdSnapshotExtents(ino *Inode) {
+	mp.snapshotMu.Lock()
+	defer mp.snapshotMu.Unlock()
+	if len(mp.snapshotExtents) == 0 {
+		return
+	}
+	ino.Extents.Range(func(ek proto.ExtentKey) bool {
+		key := newSnapshotExtentKey(&ek)
+		if _, ok := mp.snapshotExtents[key]; ok {
+			mp.heldExtents[key] = ek
+		}
+		return true
+	})
+}
+
+// unholdSnapshotExtents stops holding the extents of the inode restored from a snapshot,
+// as the live tree refers to them again.
+func (mp *metaPartition) unholdSnapshotExtents(ino *Inode) {
+	mp.snapshotMu.Lock()
+	defer mp.snapshotMu.Unlock()
+	if len(mp.heldExtents) == 0 {
+		return
+	}
+	ino.Extents.Range(func(ek proto.ExtentKey) bool {
+		delete(mp.heldExtents, newSnapshotExtentKey(&ek))
+		return true
+	})
+}
+
+func (mp *metaPartition) isSnapshotExtent(ek *proto.ExtentKey) bool {
+	mp.snapshotMu.RLock()
+	defer mp.snapshotMu.RUnlock()
+	_, ok := mp.snapshotExtents[newSnapshotExtentKey(ek)]
+	return ok
+}
+
+// getSnapshot returns a copy of the snapshot, which is safe to read without holding the lock.
+func (mp *metaPartition) getSnapshot(id uint64) (s *subtreeSnapshot, ok bool) {
+	mp.snapshotMu.RLock()
+	defer mp.snapshotMu.RUnlock()
+	var stored *subtreeSnapshot
+	if stored, ok = mp.snapshots[id]; !ok {
+		return
+	}
+	copied := *stored
+	return &copied, true
+}
+
+// getSnapshotState returns copies of all the snapshots and the extents held for them.
+func (mp *metaPartition) getSnapshotState() (snapshots []*subtreeSnapshot, held []proto.ExtentKey) {
+	mp.snapshotMu.RLock()
+	defer mp.snapshotMu.RUnlock()
+	snapshots = make([]*subtreeSnapshot, 0, len(mp.snapshots))
+	for _, s := range mp.snapshots {
+		copied := *s
+		snapshots = append(snapshots, &copied)
+	}
+	held = make([]proto.ExtentKey, 0, len(mp.heldExtents))
+	for _, ek := range mp.heldExtents {
+		held = append(held, ek)
+	}
+	return
+}
+
+// resetSnapshotState replaces the snapshots of the partition, it is used when
+// loading the partition or applying a raft snapshot.
+func (mp *metaPartition) resetSnapshotState(snapshots []*subtreeSnapshot, held []proto.ExtentKey) {
+	mp.snapshotMu.Lock()
+	defer mp.snapshotMu.Unlock()
+	mp.snapshots = make(map[uint64]*subtreeSnapshot, len(snapshots))
+	mp.snapshotExtents = make(map[snapshotExtentKey]int)
+	mp.heldExtents = make(map[snapshotExtentKey]proto.ExtentKey, len(held))
+	for _, s := range snapshots {
+		mp.snapshots[s.ID] = s
+		mp.addSnapshotExtentRefs(s)
+	}
+	for _, ek := range held {
+		mp.heldExtents[newSnapshotExtentKey(&ek)] = ek
+	}
+}
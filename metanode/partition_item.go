@@ -26,6 +26,8 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+
+	"github.com/cubefs/cubefs/proto"
 )
 
 // MetaItem defines the structure of the metadata operations.
@@ -116,6 +118,11 @@ func NewMetaItem(op uint32, key, value []byte) *MetaItem {
 	}
 }
 
+type subtreeSnapshotState struct {
+	snapshots   []*subtreeSnapshot
+	heldExtents []proto.ExtentKey
+}
+
 type fileData struct {
 	filename string
 	data     []byte
@@ -129,6 +136,9 @@ type MetaItemIterator struct {
 	dentryTree    *BTree
 	extendTree    *BTree
 	multipartTree *BTree
+	versionTree   *BTree
+	snapshots     []*subtreeSnapshot
+	heldExtents   []proto.ExtentKey
 
 	filenames []string
 
@@ -148,6 +158,8 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	si.dentryTree = mp.dentryTree.GetTree()
 	si.extendTree = mp.extendTree.GetTree()
 	si.multipartTree = mp.multipartTree.GetTree()
+	si.versionTree = mp.versionTree.GetTree()
+	si.snapshots, si.heldExtents = mp.getSnapshotState()
 	si.dataCh = make(chan interface{})
 	si.errorCh = make(chan error, 1)
 	si.closeCh = make(chan struct{})
@@ -228,6 +240,19 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 		if checkClose() {
 			return
 		}
+		// process object versions
+		iter.versionTree.Ascend(func(i BtreeItem) bool {
+			return produceItem(i)
+		})
+		if checkClose() {
+			return
+		}
+		// process subtree snapshots
+		if len(iter.snapshots) > 0 || len(iter.heldExtents) > 0 {
+			if !produceItem(&subtreeSnapshotState{snapshots: iter.snapshots, heldExtents: iter.heldExtents}) {
+				return
+			}
+		}
 		// process extent del files
 		var err error
 		var raw []byte
@@ -309,6 +334,22 @@ func (si *MetaItemIterator) Next() (data []byte, err error) {
 			return
 		}
 		snap = NewMetaItem(opFSMCreateMultipart, nil, raw)
+	case *ObjectVersion:
+		var raw []byte
+		if raw, err = typedItem.Bytes(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMPutObjectVersion, nil, raw)
+	case *subtreeSnapshotState:
+		var raw []byte
+		if raw, err = marshalSubtreeSnapshots(typedItem.snapshots, typedItem.heldExtents); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opSubtreeSnapshotState, nil, raw)
 	case *fileData:
 		snap = NewMetaItem(opExtentFileSnapshot, []byte(typedItem.filename), typedItem.data)
 	default:
@@ -28,6 +28,10 @@ func (mp *metaPartition) CreateDentry(req *CreateDentryReq, p *Packet) (err erro
 		p.PacketErrorWithBody(proto.OpExistErr, []byte(err.Error()))
 		return
 	}
+	if status := mp.checkQuotaDentry(req.ParentID); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 
 	dentry := &Dentry{
 		ParentId: req.ParentID,
@@ -50,6 +54,10 @@ func (mp *metaPartition) CreateDentry(req *CreateDentryReq, p *Packet) (err erro
 
 // DeleteDentry deletes a dentry.
 func (mp *metaPartition) DeleteDentry(req *DeleteDentryReq, p *Packet) (err error) {
+	if status := mp.checkObjectLockDentry(req.ParentID, req.Name); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 	dentry := &Dentry{
 		ParentId: req.ParentID,
 		Name:     req.Name,
@@ -84,6 +92,10 @@ func (mp *metaPartition) DeleteDentryBatch(req *BatchDeleteDentryReq, p *Packet)
 	db := make(DentryBatch, 0, len(req.Dens))
 
 	for _, d := range req.Dens {
+		if status := mp.checkObjectLockDentry(req.ParentID, d.Name); status != proto.OpOk {
+			p.PacketErrorWithBody(status, nil)
+			return
+		}
 		db = append(db, &Dentry{
 			ParentId: req.ParentID,
 			Name:     d.Name,
@@ -149,6 +161,10 @@ func (mp *metaPartition) UpdateDentry(req *UpdateDentryReq, p *Packet) (err erro
 		p.PacketErrorWithBody(proto.OpExistErr, []byte(err.Error()))
 		return
 	}
+	if status := mp.checkQuotaDentry(req.ParentID); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 
 	dentry := &Dentry{
 		ParentId: req.ParentID,
@@ -179,7 +195,12 @@ func (mp *metaPartition) UpdateDentry(req *UpdateDentryReq, p *Packet) (err erro
 }
 
 func (mp *metaPartition) ReadDirOnly(req *ReadDirOnlyReq, p *Packet) (err error) {
-	resp := mp.readDirOnly(req)
+	dentryTree, status := mp.dentryTreeAt(req.SnapshotID)
+	if status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	resp := mp.readDirOnly(dentryTree, req)
 	reply, err := json.Marshal(resp)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -191,7 +212,12 @@ func (mp *metaPartition) ReadDirOnly(req *ReadDirOnlyReq, p *Packet) (err error)
 
 // ReadDir reads the directory based on the given request.
 func (mp *metaPartition) ReadDir(req *ReadDirReq, p *Packet) (err error) {
-	resp := mp.readDir(req)
+	dentryTree, status := mp.dentryTreeAt(req.SnapshotID)
+	if status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	resp := mp.readDir(dentryTree, req)
 	reply, err := json.Marshal(resp)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -202,7 +228,12 @@ func (mp *metaPartition) ReadDir(req *ReadDirReq, p *Packet) (err error) {
 }
 
 func (mp *metaPartition) ReadDirLimit(req *ReadDirLimitReq, p *Packet) (err error) {
-	resp := mp.readDirLimit(req)
+	dentryTree, status := mp.dentryTreeAt(req.SnapshotID)
+	if status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	resp := mp.readDirLimit(dentryTree, req)
 	reply, err := json.Marshal(resp)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -218,7 +249,7 @@ func (mp *metaPartition) Lookup(req *LookupReq, p *Packet) (err error) {
 		ParentId: req.ParentID,
 		Name:     req.Name,
 	}
-	dentry, status := mp.getDentry(dentry)
+	dentry, status := mp.getDentryAt(req.SnapshotID, dentry)
 	var reply []byte
 	if status == proto.OpOk {
 		resp := &LookupResp{
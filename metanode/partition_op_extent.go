@@ -23,6 +23,14 @@ import (
 
 // ExtentAppend appends an extent.
 func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Packet) (err error) {
+	if status := mp.checkObjectLockWrite(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	if status := mp.checkQuotaBytes(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 	ino := NewInode(req.Inode, 0)
 	ext := req.Extent
 	ino.Extents.Append(ext)
@@ -36,6 +44,9 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
 	}
+	if resp.(uint8) == proto.OpOk {
+		mp.sealAppendedExtents(req.Inode, []proto.ExtentKey{req.Extent})
+	}
 	p.PacketErrorWithBody(resp.(uint8), nil)
 	return
 }
@@ -43,6 +54,14 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 // ExtentAppendWithCheck appends an extent with discard extents check.
 // Format: one valid extent key followed by non or several discard keys.
 func (mp *metaPartition) ExtentAppendWithCheck(req *proto.AppendExtentKeyWithCheckRequest, p *Packet) (err error) {
+	if status := mp.checkObjectLockWrite(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	if status := mp.checkQuotaBytes(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 	ino := NewInode(req.Inode, 0)
 	ext := req.Extent
 	ino.Extents.Append(ext)
@@ -61,6 +80,9 @@ func (mp *metaPartition) ExtentAppendWithCheck(req *proto.AppendExtentKeyWithChe
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
 	}
+	if resp.(uint8) == proto.OpOk {
+		mp.sealAppendedExtents(req.Inode, []proto.ExtentKey{req.Extent})
+	}
 	p.PacketErrorWithBody(resp.(uint8), nil)
 	return
 }
@@ -68,7 +90,7 @@ func (mp *metaPartition) ExtentAppendWithCheck(req *proto.AppendExtentKeyWithChe
 // ExtentsList returns the list of extents.
 func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error) {
 	ino := NewInode(req.Inode, 0)
-	retMsg := mp.getInode(ino)
+	retMsg := mp.getInodeAt(req.SnapshotID, ino)
 	ino = retMsg.Msg
 	var (
 		reply  []byte
@@ -83,6 +105,10 @@ func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (e
 				resp.Extents = append(resp.Extents, ek)
 				return true
 			})
+			if ino.Flag&InlineDataFlag != 0 {
+				resp.InlineData = ino.InlineData
+			}
+			resp.Cold = ino.Cold
 		})
 		reply, err = json.Marshal(resp)
 		if err != nil {
@@ -96,6 +122,10 @@ func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (e
 
 // ExtentsTruncate truncates an extent.
 func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (err error) {
+	if status := mp.checkObjectLockWrite(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 	ino := NewInode(req.Inode, proto.Mode(os.ModePerm))
 	ino.Size = req.Size
 	val, err := ino.Marshal()
@@ -113,7 +143,87 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (er
 	return
 }
 
+// extentsPunchHole is the raft log of a hole punched in an inode.
+type extentsPunchHole struct {
+	Inode      uint64 `json:"ino"`
+	Offset     uint64 `json:"off"`
+	Size       uint64 `json:"sz"`
+	ModifyTime int64  `json:"mt"`
+}
+
+// ExtentsPunchHole deallocates a range of an inode.
+func (mp *metaPartition) ExtentsPunchHole(req *ExtentsPunchHoleReq, p *Packet) (err error) {
+	if status := mp.checkObjectLockWrite(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	val, err := json.Marshal(&extentsPunchHole{
+		Inode:      req.Inode,
+		Offset:     req.Offset,
+		Size:       req.Size,
+		ModifyTime: Now.GetCurrentTime().Unix(),
+	})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMExtentPunchHole, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
+
+// inodeWriteInline is the raft log of the data stored inline in an inode.
+type inodeWriteInline struct {
+	Inode      uint64 `json:"ino"`
+	Data       []byte `json:"data"`
+	ModifyTime int64  `json:"mt"`
+}
+
+// WriteInline stores the whole content of a small file inline in its inode.
+func (mp *metaPartition) WriteInline(req *WriteInlineReq, p *Packet) (err error) {
+	if status := mp.checkObjectLockWrite(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	if status := mp.checkQuotaBytes(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	if len(req.Data) > proto.MaxInlineDataSize {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, nil)
+		return
+	}
+	val, err := json.Marshal(&inodeWriteInline{
+		Inode:      req.Inode,
+		Data:       req.Data,
+		ModifyTime: Now.GetCurrentTime().Unix(),
+	})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMWriteInline, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
+
 func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error) {
+	if status := mp.checkObjectLockWrite(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	if status := mp.checkQuotaBytes(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 	ino := NewInode(req.Inode, 0)
 	extents := req.Extents
 	for _, extent := range extents {
@@ -129,6 +239,9 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
 	}
+	if resp.(uint8) == proto.OpOk {
+		mp.sealAppendedExtents(req.Inode, req.Extents)
+	}
 	p.PacketErrorWithBody(resp.(uint8), nil)
 	return
 }
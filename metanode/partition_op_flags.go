@@ -0,0 +1,155 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The immutable and append-only flags of the inodes are set and cleared by the owners of the volumes
+// only, whose auth keys are checked against the owners fetched from the master. The ranges of the extents
+// of the inodes are sealed on the data nodes along, so that the handles opened before the flags are set
+// can not overwrite the data either.
+
+const (
+	volOwnerTTL = time.Minute
+)
+
+type volOwner struct {
+	authKey string
+	expire  time.Time
+}
+
+var (
+	volOwnersMu sync.Mutex
+	volOwners   = make(map[string]*volOwner)
+
+	// getVolOwner returns the owner of the volume from the master.
+	getVolOwner = func(volName string) (owner string, err error) {
+		view, err := masterClient.AdminAPI().GetVolumeSimpleInfo(volName)
+		if err != nil {
+			return
+		}
+		return view.Owner, nil
+	}
+)
+
+// volAuthKey returns the md5 of the owner of the volume, cached for volOwnerTTL.
+func volAuthKey(volName string) (authKey string, err error) {
+	volOwnersMu.Lock()
+	owner := volOwners[volName]
+	volOwnersMu.Unlock()
+	if owner != nil && time.Now().Before(owner.expire) {
+		return owner.authKey, nil
+	}
+	name, err := getVolOwner(volName)
+	if err != nil {
+		return
+	}
+	sum := md5.Sum([]byte(name))
+	authKey = hex.EncodeToString(sum[:])
+	volOwnersMu.Lock()
+	volOwners[volName] = &volOwner{authKey: authKey, expire: time.Now().Add(volOwnerTTL)}
+	volOwnersMu.Unlock()
+	return
+}
+
+// checkFlagsAuth returns OpNotPerm if the auth key is not the one of the owner of the volume.
+func (mp *metaPartition) checkFlagsAuth(authKey string) (status uint8, err error) {
+	expect, err := volAuthKey(mp.config.VolName)
+	if err != nil {
+		return proto.OpAgain, err
+	}
+	if authKey != expect {
+		return proto.OpNotPerm, errors.NewErrorf("vol(%v) auth key mismatch", mp.config.VolName)
+	}
+	return proto.OpOk, nil
+}
+
+// sealInodeExtents seals or unseals the extents of the inode on the data nodes.
+func (mp *metaPartition) sealInodeExtents(ino uint64, unseal bool) (err error) {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return
+	}
+	return mp.sealExtents(item.(*Inode).Extents.CopyExtents(), unseal)
+}
+
+// sealAppendedExtents seals the extents appended to the append-only inode, by which the data appended
+// can not be overwritten either. The append is kept if the seal fails.
+func (mp *metaPartition) sealAppendedExtents(ino uint64, eks []proto.ExtentKey) {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil || !item.(*Inode).IsAppendOnly() {
+		return
+	}
+	if err := mp.sealExtents(eks, false); err != nil {
+		log.LogWarnf("sealAppendedExtents: mp(%v) ino(%v) eks(%v) err(%v)", mp.config.PartitionId, ino, eks, err)
+	}
+}
+
+func (mp *metaPartition) sealExtents(eks []proto.ExtentKey, unseal bool) (err error) {
+	partitionExtents := make(map[uint64][]proto.ExtentKey)
+	for _, ek := range eks {
+		partitionExtents[ek.PartitionId] = append(partitionExtents[ek.PartitionId], ek)
+	}
+	for partitionID, exts := range partitionExtents {
+		if err = mp.doSealExtentsByPartition(partitionID, exts, unseal); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) doSealExtentsByPartition(partitionID uint64, exts []proto.ExtentKey, unseal bool) (err error) {
+	dp := mp.vol.GetPartition(partitionID)
+	if dp == nil {
+		err = errors.NewErrorf("unknown dataPartitionID=%d in vol", partitionID)
+		return
+	}
+	addr := util.ShiftAddrPort(dp.Hosts[0], smuxPortShift)
+	conn, err := smuxPool.GetConnect(addr)
+	defer func() {
+		if err != nil {
+			smuxPool.PutConnect(conn, ForceClosedConnect)
+		} else {
+			smuxPool.PutConnect(conn, NoClosedConnect)
+		}
+	}()
+	if err != nil {
+		err = errors.NewErrorf("get conn from pool %s, extents partitionId=%d", err.Error(), partitionID)
+		return
+	}
+	p := NewPacketToSealExtents(dp, exts, unseal)
+	if err = p.WriteToConn(conn); err != nil {
+		err = errors.NewErrorf("write to dataNode %s, %s", p.GetUniqueLogId(), err.Error())
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		err = errors.NewErrorf("read response from dataNode %s, %s", p.GetUniqueLogId(), err.Error())
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = errors.NewErrorf("[sealExtents] %s response: %s", p.GetUniqueLogId(), p.GetResultMsg())
+	}
+	return
+}
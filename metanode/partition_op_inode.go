@@ -17,9 +17,11 @@ package metanode
 import (
 	"encoding/binary"
 	"encoding/json"
+	"sync/atomic"
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 func replyInfo(info *proto.InodeInfo, ino *Inode) bool {
@@ -42,11 +44,17 @@ func replyInfo(info *proto.InodeInfo, ino *Inode) bool {
 	info.CreateTime = time.Unix(ino.CreateTime, 0)
 	info.AccessTime = time.Unix(ino.AccessTime, 0)
 	info.ModifyTime = time.Unix(ino.ModifyTime, 0)
+	info.Flags = uint32(ino.Flag) & proto.InodeUserFlagsMask
+	info.QuotaIDs = ino.QuotaIDs
 	return true
 }
 
 // CreateInode returns a new inode.
 func (mp *metaPartition) CreateInode(req *CreateInoReq, p *Packet) (err error) {
+	if status := mp.checkQuotaInodes(req.QuotaIDs); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 	inoID, err := mp.nextInodeID()
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpInodeFullErr, []byte(err.Error()))
@@ -56,6 +64,9 @@ func (mp *metaPartition) CreateInode(req *CreateInoReq, p *Packet) (err error) {
 	ino.Uid = req.Uid
 	ino.Gid = req.Gid
 	ino.LinkTarget = req.Target
+	if len(req.QuotaIDs) > 0 {
+		ino.SetQuotaIDs(sortQuotaIDs(req.QuotaIDs))
+	}
 	val, err := ino.Marshal()
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -89,6 +100,10 @@ func (mp *metaPartition) CreateInode(req *CreateInoReq, p *Packet) (err error) {
 
 // DeleteInode deletes an inode.
 func (mp *metaPartition) UnlinkInode(req *UnlinkInoReq, p *Packet) (err error) {
+	if status := mp.checkObjectLockUnlink(req.Inode); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
 	ino := NewInode(req.Inode, 0)
 	val, err := ino.Marshal()
 	if err != nil {
@@ -124,6 +139,13 @@ func (mp *metaPartition) UnlinkInodeBatch(req *BatchUnlinkInoReq, p *Packet) (er
 		return nil
 	}
 
+	for _, id := range req.Inodes {
+		if status := mp.checkObjectLockUnlink(id); status != proto.OpOk {
+			p.PacketErrorWithBody(status, nil)
+			return
+		}
+	}
+
 	var inodes InodeBatch
 
 	for _, id := range req.Inodes {
@@ -171,7 +193,7 @@ func (mp *metaPartition) UnlinkInodeBatch(req *BatchUnlinkInoReq, p *Packet) (er
 // InodeGet executes the inodeGet command from the client.
 func (mp *metaPartition) InodeGet(req *InodeGetReq, p *Packet) (err error) {
 	ino := NewInode(req.Inode, 0)
-	retMsg := mp.getInode(ino)
+	retMsg := mp.getInodeAt(req.SnapshotID, ino)
 	ino = retMsg.Msg
 	var (
 		reply  []byte
@@ -200,7 +222,7 @@ func (mp *metaPartition) InodeGetBatch(req *InodeGetReqBatch, p *Packet) (err er
 	ino := NewInode(0, 0)
 	for _, inoId := range req.Inodes {
 		ino.Inode = inoId
-		retMsg := mp.getInode(ino)
+		retMsg := mp.getInodeAt(req.SnapshotID, ino)
 		if retMsg.Status == proto.OpOk {
 			inoInfo := &proto.InodeInfo{}
 			if replyInfo(inoInfo, retMsg.Msg) {
@@ -217,6 +239,49 @@ func (mp *metaPartition) InodeGetBatch(req *InodeGetReqBatch, p *Packet) (err er
 	return
 }
 
+// BatchStat resolves a batch of inodes and dentries to their attributes. A dentry
+// item is looked up first, and the attributes are filled in only if its inode is
+// stored in this partition as well.
+func (mp *metaPartition) BatchStat(req *proto.BatchStatRequest, p *Packet) (err error) {
+	resp := &proto.BatchStatResponse{
+		Results: make([]*proto.BatchStatResult, 0, len(req.Items)),
+	}
+	for _, item := range req.Items {
+		result := &proto.BatchStatResult{Status: proto.OpOk, Inode: item.Inode}
+		resp.Results = append(resp.Results, result)
+		if item.Name != "" {
+			dentry, status := mp.getDentryAt(req.SnapshotID, &Dentry{ParentId: item.ParentID, Name: item.Name})
+			if status != proto.OpOk {
+				result.Status = status
+				continue
+			}
+			result.Inode = dentry.Inode
+			result.Mode = dentry.Type
+		}
+		if result.Inode < mp.config.Start || result.Inode > mp.config.End {
+			if item.Name == "" {
+				result.Status = proto.OpNotExistErr
+			}
+			continue
+		}
+		retMsg := mp.getInodeAt(req.SnapshotID, NewInode(result.Inode, 0))
+		info := &proto.InodeInfo{}
+		if retMsg.Status != proto.OpOk || !replyInfo(info, retMsg.Msg) {
+			result.Status = proto.OpNotExistErr
+			continue
+		}
+		result.Mode = info.Mode
+		result.Info = info
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(data)
+	return
+}
+
 // CreateInodeLink creates an inode link (e.g., soft link).
 func (mp *metaPartition) CreateInodeLink(req *LinkInodeReq, p *Packet) (err error) {
 	ino := NewInode(req.Inode, 0)
@@ -306,12 +371,37 @@ func (mp *metaPartition) EvictInodeBatch(req *BatchEvictInodeReq, p *Packet) (er
 
 // SetAttr set the inode attributes.
 func (mp *metaPartition) SetAttr(reqData []byte, p *Packet) (err error) {
-	_, err = mp.submit(opFSMSetAttr, reqData)
+	req := &SetattrRequest{}
+	if err = json.Unmarshal(reqData, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	// the flags are changed by the owner of the volume only, and the extents of the inode are sealed
+	// before it is protected, or unsealed before it is not
+	setFlags := req.Valid&proto.AttrFlags != 0
+	unseal := req.Flags&proto.InodeUserFlagsMask == 0
+	if setFlags {
+		var status uint8
+		if status, err = mp.checkFlagsAuth(req.AuthKey); status != proto.OpOk {
+			p.PacketErrorWithBody(status, []byte(err.Error()))
+			return
+		}
+		if err = mp.sealInodeExtents(req.Inode, unseal); err != nil {
+			p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+			return
+		}
+	}
+	resp, err := mp.submit(opFSMSetAttr, reqData)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
-		return
+	} else {
+		p.PacketErrorWithBody(resp.(uint8), nil)
+	}
+	if setFlags && !unseal && p.ResultCode != proto.OpOk {
+		if e := mp.sealInodeExtents(req.Inode, true); e != nil {
+			log.LogWarnf("SetAttr: mp(%v) ino(%v) unseal extents err(%v)", mp.config.PartitionId, req.Inode, e)
+		}
 	}
-	p.PacketOkReply()
 	return
 }
 
@@ -320,6 +410,11 @@ func (mp *metaPartition) GetInodeTree() *BTree {
 	return mp.inodeTree.GetTree()
 }
 
+// GetSize returns the total size of the files in the partition.
+func (mp *metaPartition) GetSize() uint64 {
+	return atomic.LoadUint64(&mp.size)
+}
+
 func (mp *metaPartition) DeleteInode(req *proto.DeleteInodeRequest, p *Packet) (err error) {
 	var bytes = make([]byte, 8)
 	binary.BigEndian.PutUint64(bytes, req.Inode)
@@ -0,0 +1,179 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// lockTable holds the file locks of a partition. The locks live in the memory of
+// the leader only. The clients set them again when they renew them on a new leader,
+// which refuses the other locks for a grace period meanwhile.
+type lockTable struct {
+	sync.Mutex
+	inodes     map[uint64][]*proto.FileLock
+	sessions   map[uint64]time.Time // last time each client set or renewed its locks
+	graceUntil time.Time
+}
+
+func newLockTable() *lockTable {
+	return &lockTable{
+		inodes:   make(map[uint64][]*proto.FileLock),
+		sessions: make(map[uint64]time.Time),
+	}
+}
+
+// reset drops all the locks when the partition becomes the leader.
+func (t *lockTable) reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.inodes = make(map[uint64][]*proto.FileLock)
+	t.sessions = make(map[uint64]time.Time)
+	t.graceUntil = time.Now().Add(2 * proto.FileLockRenewInterval)
+}
+
+// expireLocked drops the locks of the clients which did not renew them in time.
+func (t *lockTable) expireLocked(now time.Time) {
+	for session, last := range t.sessions {
+		if now.Sub(last) < proto.FileLockTimeout {
+			continue
+		}
+		delete(t.sessions, session)
+		for ino, locks := range t.inodes {
+			kept := locks[:0]
+			for _, l := range locks {
+				if l.Session != session {
+					kept = append(kept, l)
+				}
+			}
+			t.setInodeLocked(ino, kept)
+		}
+		log.LogWarnf("lockTable: locks of session(%v) expired", session)
+	}
+}
+
+func (t *lockTable) setInodeLocked(ino uint64, locks []*proto.FileLock) {
+	if len(locks) == 0 {
+		delete(t.inodes, ino)
+		return
+	}
+	t.inodes[ino] = locks
+}
+
+func (t *lockTable) conflictLocked(ino uint64, lock *proto.FileLock) *proto.FileLock {
+	for _, l := range t.inodes[ino] {
+		if lock.Conflicts(l) {
+			return l
+		}
+	}
+	return nil
+}
+
+// set acquires or releases the lock, it returns the lock conflicting with it if any.
+func (t *lockTable) set(ino uint64, lock *proto.FileLock) (conflict *proto.FileLock, grace bool) {
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	t.expireLocked(now)
+	if lock.Type != proto.FileLockUnlock {
+		if now.Before(t.graceUntil) {
+			return nil, true
+		}
+		if conflict = t.conflictLocked(ino, lock); conflict != nil {
+			return
+		}
+	}
+	t.sessions[lock.Session] = now
+	t.setInodeLocked(ino, proto.ApplyFileLock(t.inodes[ino], lock))
+	return
+}
+
+// get returns a lock conflicting with the given one if any.
+func (t *lockTable) get(ino uint64, lock *proto.FileLock) *proto.FileLock {
+	t.Lock()
+	defer t.Unlock()
+	t.expireLocked(time.Now())
+	return t.conflictLocked(ino, lock)
+}
+
+// renew keeps the locks of the session alive, and sets them again if the
+// session is unknown.
+func (t *lockTable) renew(session uint64, inodes []*proto.InodeLocks) {
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	t.expireLocked(now)
+	if _, ok := t.sessions[session]; !ok {
+		for _, il := range inodes {
+			for _, lock := range il.Locks {
+				if lock.Session != session || lock.Type == proto.FileLockUnlock {
+					continue
+				}
+				if conflict := t.conflictLocked(il.Inode, lock); conflict != nil {
+					log.LogWarnf("lockTable: reclaim ino(%v) lock(%v) conflicts with lock(%v)", il.Inode, lock, conflict)
+					continue
+				}
+				t.setInodeLocked(il.Inode, proto.ApplyFileLock(t.inodes[il.Inode], lock))
+			}
+		}
+	}
+	t.sessions[session] = now
+}
+
+// SetLock acquires or releases a file lock.
+func (mp *metaPartition) SetLock(req *proto.SetLockRequest, p *Packet) (err error) {
+	if req.Lock.Type != proto.FileLockUnlock && !mp.hasInode(NewInode(req.Inode, 0)) {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte("inode not exist"))
+		return
+	}
+	conflict, grace := mp.locks.set(req.Inode, &req.Lock)
+	if grace {
+		p.PacketErrorWithBody(proto.OpExistErr, []byte("locks are being reclaimed"))
+		return
+	}
+	if conflict != nil {
+		p.PacketErrorWithBody(proto.OpExistErr, []byte(conflict.String()))
+		return
+	}
+	p.PacketOkReply()
+	return
+}
+
+// GetLock looks for a file lock conflicting with the requested one.
+func (mp *metaPartition) GetLock(req *proto.GetLockRequest, p *Packet) (err error) {
+	resp := &proto.GetLockResponse{Lock: proto.FileLock{Type: proto.FileLockUnlock}}
+	if conflict := mp.locks.get(req.Inode, &req.Lock); conflict != nil {
+		resp.Lock = *conflict
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// RenewLocks keeps the file locks of a client alive.
+func (mp *metaPartition) RenewLocks(req *proto.RenewLocksRequest, p *Packet) (err error) {
+	mp.locks.renew(req.Session, req.Inodes)
+	p.PacketOkReply()
+	return
+}
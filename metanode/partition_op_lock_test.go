@@ -0,0 +1,73 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestLockTable(t *testing.T) {
+	table := newLockTable()
+	write := &proto.FileLock{Start: 0, End: 99, Type: proto.FileLockWrite, Owner: 1, Session: 1}
+	if conflict, _ := table.set(10, write); conflict != nil {
+		t.Fatalf("set write lock: conflict(%v)", conflict)
+	}
+	read := &proto.FileLock{Start: 50, End: 149, Type: proto.FileLockRead, Owner: 2, Session: 2}
+	if conflict, _ := table.set(10, read); conflict == nil {
+		t.Fatalf("read lock should conflict with the write lock")
+	}
+	if conflict := table.get(10, read); conflict == nil || conflict.Session != 1 {
+		t.Fatalf("get lock: conflict(%v)", conflict)
+	}
+	// the flock locks never conflict with the POSIX ones
+	flock := &proto.FileLock{End: math.MaxUint64, Type: proto.FileLockWrite, Owner: 3, Session: 2, Flock: true}
+	if conflict, _ := table.set(10, flock); conflict != nil {
+		t.Fatalf("set flock: conflict(%v)", conflict)
+	}
+
+	// unlocking the middle of the range splits the lock
+	unlock := &proto.FileLock{Start: 50, End: 149, Type: proto.FileLockUnlock, Owner: 1, Session: 1}
+	if conflict, _ := table.set(10, unlock); conflict != nil {
+		t.Fatalf("unlock: conflict(%v)", conflict)
+	}
+	if conflict, _ := table.set(10, read); conflict != nil {
+		t.Fatalf("read lock after unlock: conflict(%v)", conflict)
+	}
+	head := &proto.FileLock{Start: 0, End: 49, Type: proto.FileLockRead, Owner: 2, Session: 2}
+	if conflict := table.get(10, head); conflict == nil || conflict.End != 49 {
+		t.Fatalf("the head of the write lock should be kept: conflict(%v)", conflict)
+	}
+
+	// the locks of the sessions not renewed in time are dropped
+	table.sessions[1] = time.Now().Add(-proto.FileLockTimeout)
+	if conflict := table.get(10, head); conflict != nil {
+		t.Fatalf("expired lock still held: conflict(%v)", conflict)
+	}
+
+	// a new leader refuses new locks until the clients set theirs again
+	table.reset()
+	if conflict, grace := table.set(10, write); conflict != nil || !grace {
+		t.Fatalf("set lock in the grace period: conflict(%v) grace(%v)", conflict, grace)
+	}
+	table.renew(2, []*proto.InodeLocks{{Inode: 10, Locks: []*proto.FileLock{read}}})
+	table.graceUntil = time.Time{}
+	if conflict, _ := table.set(10, write); conflict == nil || conflict.Session != 2 {
+		t.Fatalf("reclaimed lock should conflict: conflict(%v)", conflict)
+	}
+}
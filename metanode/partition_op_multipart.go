@@ -81,6 +81,9 @@ func (mp *metaPartition) AppendMultipart(req *proto.AddMultipartPartRequest, p *
 			},
 		},
 	}
+	if req.Replace {
+		return mp.replaceMultipartPart(multipart, p)
+	}
 	var resp interface{}
 	if resp, err = mp.putMultipart(opFSMAppendMultipart, multipart); err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -95,6 +98,38 @@ func (mp *metaPartition) AppendMultipart(req *proto.AddMultipartPartRequest, p *
 	return
 }
 
+// replaceMultipartPart stores the part of the multipart replacing the uploaded part of the same id,
+// and replies the replaced part whose inode should be released by the client.
+func (mp *metaPartition) replaceMultipartPart(multipart *Multipart, p *Packet) (err error) {
+	var resp interface{}
+	if resp, err = mp.putMultipart(opFSMReplaceMultipartPart, multipart); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	partResp := resp.(*MultipartPartResponse)
+	if partResp.Status != proto.OpOk {
+		p.PacketErrorWithBody(partResp.Status, nil)
+		return
+	}
+	reply := &proto.AddMultipartPartResponse{}
+	if replaced := partResp.Replaced; replaced != nil {
+		reply.Replaced = &proto.MultipartPartInfo{
+			ID:         replaced.ID,
+			Inode:      replaced.Inode,
+			MD5:        replaced.MD5,
+			Size:       replaced.Size,
+			UploadTime: replaced.UploadTime,
+		}
+	}
+	var data []byte
+	if data, err = json.Marshal(reply); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(data)
+	return
+}
+
 func (mp *metaPartition) RemoveMultipart(req *proto.RemoveMultipartRequest, p *Packet) (err error) {
 	multipart := &Multipart{
 		id:  req.MultipartId,
@@ -0,0 +1,140 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func (mp *metaPartition) PutObjectVersion(req *proto.PutObjectVersionRequest, p *Packet) (err error) {
+	if req.Version == nil {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, nil)
+		return
+	}
+	version := &ObjectVersion{
+		path:         req.Version.Path,
+		versionId:    req.Version.VersionId,
+		inode:        req.Version.Inode,
+		deleteMarker: req.Version.DeleteMarker,
+		createTime:   req.Version.CreateTime,
+	}
+	var resp interface{}
+	if resp, err = mp.putObjectVersion(opFSMPutObjectVersion, version); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	status := resp.(uint8)
+	if status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	p.PacketOkReply()
+	return
+}
+
+func (mp *metaPartition) GetObjectVersion(req *proto.GetObjectVersionRequest, p *Packet) (err error) {
+	item := mp.versionTree.Get(&ObjectVersion{path: req.Path, versionId: req.VersionId})
+	if item == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	resp := &proto.GetObjectVersionResponse{
+		Version: item.(*ObjectVersion).info(),
+	}
+	var reply []byte
+	if reply, err = json.Marshal(resp); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+func (mp *metaPartition) RemoveObjectVersion(req *proto.RemoveObjectVersionRequest, p *Packet) (err error) {
+	version := &ObjectVersion{
+		path:      req.Path,
+		versionId: req.VersionId,
+	}
+	var resp interface{}
+	if resp, err = mp.putObjectVersion(opFSMRemoveObjectVersion, version); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	status := resp.(uint8)
+	if status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	p.PacketOkReply()
+	return
+}
+
+// ListObjectVersions lists the versions of the paths with the given prefix, starting
+// after the version marker of the path of the marker.
+func (mp *metaPartition) ListObjectVersions(req *proto.ListObjectVersionsRequest, p *Packet) (err error) {
+	max := int(req.Max)
+	var versions = make([]*proto.ObjectVersionInfo, 0)
+	var walkTreeFunc = func(i BtreeItem) bool {
+		version := i.(*ObjectVersion)
+		if !strings.HasPrefix(version.path, req.Prefix) {
+			// the paths with the prefix are contiguous in the tree
+			return false
+		}
+		if version.path == req.Marker && (req.VersionIdMarker == "" || version.versionId <= req.VersionIdMarker) {
+			return true
+		}
+		versions = append(versions, version.info())
+		return !(max > 0 && len(versions) >= max)
+	}
+	var start = &ObjectVersion{path: req.Prefix}
+	if req.Marker > req.Prefix {
+		start = &ObjectVersion{path: req.Marker, versionId: req.VersionIdMarker}
+	}
+	mp.versionTree.AscendGreaterOrEqual(start, walkTreeFunc)
+
+	resp := &proto.ListObjectVersionsResponse{
+		Versions: versions,
+	}
+	var reply []byte
+	if reply, err = json.Marshal(resp); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+func (v *ObjectVersion) info() *proto.ObjectVersionInfo {
+	return &proto.ObjectVersionInfo{
+		Path:         v.path,
+		VersionId:    v.versionId,
+		Inode:        v.inode,
+		DeleteMarker: v.deleteMarker,
+		CreateTime:   v.createTime,
+	}
+}
+
+// putObjectVersion replicate specified object version operation to raft.
+func (mp *metaPartition) putObjectVersion(op uint32, version *ObjectVersion) (resp interface{}, err error) {
+	var encoded []byte
+	if encoded, err = version.Bytes(); err != nil {
+		return
+	}
+	resp, err = mp.submit(op, encoded)
+	return
+}
@@ -0,0 +1,193 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// CreateSnapshot freezes the namespace of the partition under the requested snapshot ID.
+func (mp *metaPartition) CreateSnapshot(req *proto.CreateSnapshotRequest, p *Packet) (err error) {
+	if req.SnapshotID == 0 || req.RootIno == 0 {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte("invalid snapshot id or root inode"))
+		return
+	}
+	info := &proto.SnapshotInfo{
+		ID:         req.SnapshotID,
+		Name:       req.Name,
+		RootIno:    req.RootIno,
+		CreateTime: time.Now().Unix(),
+	}
+	val, err := json.Marshal(info)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMCreateSnapshot, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.ResultCode = resp.(uint8)
+	if p.ResultCode != proto.OpOk {
+		return
+	}
+	// reply the snapshot with the raft index it is taken at
+	if s, ok := mp.getSnapshot(req.SnapshotID); ok {
+		if reply, e := json.Marshal(s.info()); e == nil {
+			p.PacketOkWithBody(reply)
+		}
+	}
+	return
+}
+
+// SealSnapshot scopes the snapshot to the inodes reachable from its root.
+func (mp *metaPartition) SealSnapshot(req *proto.SealSnapshotRequest, p *Packet) (err error) {
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMSealSnapshot, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.ResultCode = resp.(uint8)
+	return
+}
+
+// DeleteSnapshot deletes the snapshot.
+func (mp *metaPartition) DeleteSnapshot(req *proto.DeleteSnapshotRequest, p *Packet) (err error) {
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMDeleteSnapshot, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.ResultCode = resp.(uint8)
+	return
+}
+
+// RestoreSnapshot rolls the partition back to the snapshot, or only the requested files.
+func (mp *metaPartition) RestoreSnapshot(req *proto.RestoreSnapshotRequest, p *Packet) (err error) {
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMRestoreSnapshot, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.ResultCode = resp.(uint8)
+	return
+}
+
+// ListSnapshots lists the snapshots held by the partition.
+func (mp *metaPartition) ListSnapshots(req *proto.ListSnapshotsRequest, p *Packet) (err error) {
+	snapshots, _ := mp.getSnapshotState()
+	resp := &proto.ListSnapshotsResponse{
+		Snapshots: make([]*proto.SnapshotInfo, 0, len(snapshots)),
+	}
+	for _, s := range snapshots {
+		resp.Snapshots = append(resp.Snapshots, s.info())
+	}
+	sort.Slice(resp.Snapshots, func(i, j int) bool {
+		return resp.Snapshots[i].ID < resp.Snapshots[j].ID
+	})
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// dentryTreeAt returns the dentry tree of the given snapshot, or the live dentry
+// tree if snapshotID is zero.
+func (mp *metaPartition) dentryTreeAt(snapshotID uint64) (tree *BTree, status uint8) {
+	if snapshotID == 0 {
+		return mp.dentryTree, proto.OpOk
+	}
+	s, ok := mp.getSnapshot(snapshotID)
+	if !ok {
+		return nil, proto.OpNotExistErr
+	}
+	return s.dentryTree, proto.OpOk
+}
+
+// getDentryAt looks up the dentry in the given snapshot, or in the live tree if
+// snapshotID is zero.
+func (mp *metaPartition) getDentryAt(snapshotID uint64, dentry *Dentry) (*Dentry, uint8) {
+	if snapshotID == 0 {
+		return mp.getDentry(dentry)
+	}
+	tree, status := mp.dentryTreeAt(snapshotID)
+	if status != proto.OpOk {
+		return nil, status
+	}
+	if item := tree.Get(dentry); item != nil {
+		return item.(*Dentry), proto.OpOk
+	}
+	if !mp.config.CaseInsensitive {
+		return nil, proto.OpNotExistErr
+	}
+	// Snapshots carry no case-folded index, scan the children instead.
+	var found *Dentry
+	tree.AscendRange(&Dentry{ParentId: dentry.ParentId}, &Dentry{ParentId: dentry.ParentId + 1}, func(i BtreeItem) bool {
+		if d := i.(*Dentry); strings.EqualFold(d.Name, dentry.Name) {
+			found = d
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return nil, proto.OpNotExistErr
+	}
+	return found, proto.OpOk
+}
+
+// getInodeAt gets the inode from the given snapshot, or from the live tree if
+// snapshotID is zero.
+func (mp *metaPartition) getInodeAt(snapshotID uint64, ino *Inode) (resp *InodeResponse) {
+	if snapshotID == 0 {
+		return mp.getInode(ino)
+	}
+	resp = NewInodeResponse()
+	resp.Status = proto.OpNotExistErr
+	s, ok := mp.getSnapshot(snapshotID)
+	if !ok {
+		return
+	}
+	item := s.inodeTree.Get(ino)
+	if item == nil || item.(*Inode).ShouldDelete() {
+		return
+	}
+	resp.Status = proto.OpOk
+	resp.Msg = item.(*Inode)
+	return
+}
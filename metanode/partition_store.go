@@ -42,6 +42,8 @@ const (
 	dentryFile      = "dentry"
 	extendFile      = "extend"
 	multipartFile   = "multipart"
+	versionFile     = "object_version"
+	subtreeSnapFile = "subtree_snapshot"
 	applyIDFile     = "apply"
 	SnapshotSign    = ".sign"
 	metadataFile    = "meta"
@@ -77,6 +79,9 @@ func (mp *metaPartition) loadMetadata() (err error) {
 	mp.config.Start = mConf.Start
 	mp.config.End = mConf.End
 	mp.config.Peers = mConf.Peers
+	mp.config.Learners = mConf.Learners
+	mp.config.Witnesses = mConf.Witnesses
+	mp.config.CaseInsensitive = mConf.CaseInsensitive
 	mp.config.Cursor = mp.config.Start
 
 	log.LogInfof("loadMetadata: load complete: partitionID(%v) volume(%v) range(%v,%v) cursor(%v)",
@@ -295,6 +300,66 @@ func (mp *metaPartition) loadMultipart(rootDir string) error {
 	return nil
 }
 
+func (mp *metaPartition) loadObjectVersion(rootDir string) error {
+	var err error
+	filename := path.Join(rootDir, versionFile)
+	if _, err = os.Stat(filename); err != nil {
+		return nil
+	}
+	fp, err := os.OpenFile(filename, os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	var mem mmap.MMap
+	if mem, err = mmap.Map(fp, mmap.RDONLY, 0); err != nil {
+		return err
+	}
+	defer func() {
+		_ = mem.Unmap()
+	}()
+	var offset, n int
+	// read number of versions
+	var numVersions uint64
+	numVersions, n = binary.Uvarint(mem)
+	offset += n
+	for i := uint64(0); i < numVersions; i++ {
+		// read length
+		var numBytes uint64
+		numBytes, n = binary.Uvarint(mem[offset:])
+		offset += n
+		var version = ObjectVersionFromBytes(mem[offset : offset+int(numBytes)])
+		mp.fsmPutObjectVersion(version)
+		offset += int(numBytes)
+	}
+	log.LogInfof("loadObjectVersion: load complete: partitionID(%v) numVersions(%v) filename(%v)",
+		mp.config.PartitionId, numVersions, filename)
+	return nil
+}
+
+func (mp *metaPartition) loadSubtreeSnapshots(rootDir string) (err error) {
+	filename := path.Join(rootDir, subtreeSnapFile)
+	if _, err = os.Stat(filename); err != nil {
+		return nil
+	}
+	var data []byte
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		return
+	}
+	snapshots, heldExtents, err := readSubtreeSnapshots(data)
+	if err != nil {
+		err = errors.NewErrorf("[loadSubtreeSnapshots] partitionID(%v) filename(%v): %v",
+			mp.config.PartitionId, filename, err.Error())
+		return
+	}
+	mp.resetSnapshotState(snapshots, heldExtents)
+	log.LogInfof("loadSubtreeSnapshots: load complete: partitionID(%v) numSnapshots(%v) numHeldExtents(%v) filename(%v)",
+		mp.config.PartitionId, len(snapshots), len(heldExtents), filename)
+	return nil
+}
+
 func (mp *metaPartition) loadApplyID(rootDir string) (err error) {
 	filename := path.Join(rootDir, applyIDFile)
 	if _, err = os.Stat(filename); err != nil {
@@ -604,3 +669,98 @@ func (mp *metaPartition) storeMultipart(rootDir string, sm *storeMsg) (crc uint3
 		mp.config.PartitionId, mp.config.VolName, multipartTree.Len(), crc)
 	return
 }
+
+func (mp *metaPartition) storeObjectVersion(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	var versionTree = sm.versionTree
+	var fp = path.Join(rootDir, versionFile)
+	var f *os.File
+	f, err = os.OpenFile(fp, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+	var writer = bufio.NewWriterSize(f, 4*1024*1024)
+	var crc32 = crc32.NewIEEE()
+	var varintTmp = make([]byte, binary.MaxVarintLen64)
+	var n int
+	// write number of versions
+	n = binary.PutUvarint(varintTmp, uint64(versionTree.Len()))
+	if _, err = writer.Write(varintTmp[:n]); err != nil {
+		return
+	}
+	if _, err = crc32.Write(varintTmp[:n]); err != nil {
+		return
+	}
+	versionTree.Ascend(func(i BtreeItem) bool {
+		v := i.(*ObjectVersion)
+		var raw []byte
+		if raw, err = v.Bytes(); err != nil {
+			return false
+		}
+		// write length
+		n = binary.PutUvarint(varintTmp, uint64(len(raw)))
+		if _, err = writer.Write(varintTmp[:n]); err != nil {
+			return false
+		}
+		if _, err = crc32.Write(varintTmp[:n]); err != nil {
+			return false
+		}
+		// write raw
+		if _, err = writer.Write(raw); err != nil {
+			return false
+		}
+		if _, err = crc32.Write(raw); err != nil {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return
+	}
+
+	if err = writer.Flush(); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	crc = crc32.Sum32()
+	log.LogInfof("storeObjectVersion: store complete: partitoinID(%v) volume(%v) numVersions(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, versionTree.Len(), crc)
+	return
+}
+
+func (mp *metaPartition) storeSubtreeSnapshots(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	var fp = path.Join(rootDir, subtreeSnapFile)
+	var f *os.File
+	f, err = os.OpenFile(fp, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+	var writer = bufio.NewWriterSize(f, 4*1024*1024)
+	var crc32 = crc32.NewIEEE()
+	if err = writeSubtreeSnapshots(io.MultiWriter(writer, crc32), sm.snapshots, sm.heldExtents); err != nil {
+		return
+	}
+	if err = writer.Flush(); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	crc = crc32.Sum32()
+	log.LogInfof("storeSubtreeSnapshots: store complete: partitoinID(%v) volume(%v) numSnapshots(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, len(sm.snapshots), crc)
+	return
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/cubefs/cubefs/cmd/common"
+	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
@@ -31,6 +32,9 @@ type storeMsg struct {
 	dentryTree    *BTree
 	extendTree    *BTree
 	multipartTree *BTree
+	versionTree   *BTree
+	snapshots     []*subtreeSnapshot
+	heldExtents   []proto.ExtentKey
 }
 
 func (mp *metaPartition) startSchedule(curIndex uint64) {
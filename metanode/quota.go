@@ -0,0 +1,172 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// The quota tokens of the volumes pushed by the master in the last heartbeat.
+var (
+	quotaTokensMu   sync.RWMutex
+	quotaTokens     map[string]*proto.QuotaToken
+	quotaTokensTime time.Time
+)
+
+// setQuotaTokens replaces the quota tokens by the ones of the heartbeat.
+func setQuotaTokens(tokens map[string]*proto.QuotaToken) {
+	quotaTokensMu.Lock()
+	defer quotaTokensMu.Unlock()
+	quotaTokens = tokens
+	quotaTokensTime = time.Now()
+}
+
+// getQuotaToken returns the token of the volume, nil if the volume is within its quotas or the token
+// is expired.
+func getQuotaToken(volName string) *proto.QuotaToken {
+	quotaTokensMu.RLock()
+	defer quotaTokensMu.RUnlock()
+	token := quotaTokens[volName]
+	if token == nil || time.Since(quotaTokensTime) > time.Duration(token.TTL)*time.Second {
+		return nil
+	}
+	return token
+}
+
+// checkQuotaInodes refuses to create the inodes of the volume exceeding the inodes of its quotas, and the
+// inodes counted by the directory quotas exceeding their inodes.
+func (mp *metaPartition) checkQuotaInodes(quotaIDs []uint64) uint8 {
+	if token := getQuotaToken(mp.config.VolName); token != nil &&
+		(token.NoInodes || containsAnyID(token.NoInodesQuotas, quotaIDs)) {
+		return proto.OpQuotaExceededErr
+	}
+	return proto.OpOk
+}
+
+// checkQuotaBytes refuses to add the data to the files of the volume exceeding the bytes of its quotas, and
+// to the files counted by the directory quotas exceeding their bytes. The data is not removed, and the files
+// are truncated and deleted as usual.
+func (mp *metaPartition) checkQuotaBytes(ino uint64) uint8 {
+	token := getQuotaToken(mp.config.VolName)
+	if token == nil {
+		return proto.OpOk
+	}
+	if token.NoBytes || containsAnyID(token.NoBytesQuotas, mp.inodeQuotaIDs(ino)) {
+		return proto.OpQuotaExceededErr
+	}
+	return proto.OpOk
+}
+
+// checkQuotaDentry refuses to create or replace the dentries in the directories counted by the directory
+// quotas exceeded, which keeps the trees from getting new files and the files moved in.
+func (mp *metaPartition) checkQuotaDentry(parentID uint64) uint8 {
+	token := getQuotaToken(mp.config.VolName)
+	if token == nil || len(token.NoInodesQuotas)+len(token.NoBytesQuotas) == 0 {
+		return proto.OpOk
+	}
+	quotaIDs := mp.inodeQuotaIDs(parentID)
+	if containsAnyID(token.NoInodesQuotas, quotaIDs) || containsAnyID(token.NoBytesQuotas, quotaIDs) {
+		return proto.OpQuotaExceededErr
+	}
+	return proto.OpOk
+}
+
+// inodeQuotaIDs returns the directory quotas counting the inode of the partition.
+func (mp *metaPartition) inodeQuotaIDs(ino uint64) []uint64 {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return nil
+	}
+	return item.(*Inode).GetQuotaIDs()
+}
+
+// containsAnyID returns if any of the IDs is in the sorted IDs.
+func containsAnyID(sorted []uint64, ids []uint64) bool {
+	for _, id := range ids {
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= id })
+		if i < len(sorted) && sorted[i] == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sortQuotaIDs returns the IDs of the quotas from a request sorted and deduplicated.
+func sortQuotaIDs(quotaIDs []uint64) (sorted []uint64) {
+	sorted = make([]uint64, 0, len(quotaIDs))
+	for _, id := range quotaIDs {
+		sorted, _ = setQuotaID(sorted, id, false)
+	}
+	return
+}
+
+// accountInode applies the size change of the inode and the inodes added or removed to the size of the
+// partition and to the usages of the directory quotas counting the inode.
+func (mp *metaPartition) accountInode(ino *Inode, oldSize, newSize uint64, inodes int64) {
+	mp.updateSize(oldSize, newSize)
+	quotaIDs := ino.GetQuotaIDs()
+	if len(quotaIDs) == 0 {
+		return
+	}
+	mp.quotaUsageMu.Lock()
+	if mp.quotaUsages == nil {
+		mp.quotaUsages = make(map[uint64]*proto.QuotaUsage)
+	}
+	addQuotaUsage(mp.quotaUsages, quotaIDs, int64(newSize)-int64(oldSize), inodes)
+	mp.quotaUsageMu.Unlock()
+}
+
+// addQuotaUsage adds the bytes and the inodes to the usages of the quotas, the usages dropping to zero are removed.
+func addQuotaUsage(usages map[uint64]*proto.QuotaUsage, quotaIDs []uint64, bytes, inodes int64) {
+	for _, id := range quotaIDs {
+		usage := usages[id]
+		if usage == nil {
+			usage = &proto.QuotaUsage{QuotaID: id}
+			usages[id] = usage
+		}
+		usage.UsedBytes += uint64(bytes)
+		usage.UsedInodes += uint64(inodes)
+		if usage.UsedBytes == 0 && usage.UsedInodes == 0 {
+			delete(usages, id)
+		}
+	}
+}
+
+// resetQuotaUsages replaces the usages of the directory quotas, e.g. by the ones of a raft snapshot.
+func (mp *metaPartition) resetQuotaUsages(usages map[uint64]*proto.QuotaUsage) {
+	mp.quotaUsageMu.Lock()
+	mp.quotaUsages = usages
+	mp.quotaUsageMu.Unlock()
+}
+
+// GetQuotaUsages returns the usages of the directory quotas by the inodes of the partition, sorted by the IDs.
+func (mp *metaPartition) GetQuotaUsages() (usages []*proto.QuotaUsage) {
+	mp.quotaUsageMu.Lock()
+	defer mp.quotaUsageMu.Unlock()
+	if len(mp.quotaUsages) == 0 {
+		return nil
+	}
+	usages = make([]*proto.QuotaUsage, 0, len(mp.quotaUsages))
+	for _, usage := range mp.quotaUsages {
+		copied := *usage
+		usages = append(usages, &copied)
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].QuotaID < usages[j].QuotaID })
+	return
+}
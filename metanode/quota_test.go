@@ -0,0 +1,143 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestQuotaTokens(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "quota"}, nil).(*metaPartition)
+	defer setQuotaTokens(nil)
+	if mp.checkQuotaInodes(nil) != proto.OpOk || mp.checkQuotaBytes(1) != proto.OpOk {
+		t.Fatalf("volume without token refused")
+	}
+
+	setQuotaTokens(map[string]*proto.QuotaToken{"quota": {NoInodes: true, TTL: 60}})
+	if status := mp.checkQuotaInodes(nil); status != proto.OpQuotaExceededErr {
+		t.Fatalf("create inode: expect OpQuotaExceededErr, got status(%v)", status)
+	}
+	if status := mp.checkQuotaBytes(1); status != proto.OpOk {
+		t.Fatalf("append extent: status(%v)", status)
+	}
+	p := &Packet{}
+	if err := mp.CreateInode(&CreateInoReq{Mode: proto.Mode(0644)}, p); err != nil || p.ResultCode != proto.OpQuotaExceededErr {
+		t.Fatalf("create inode: expect OpQuotaExceededErr, got status(%v) err(%v)", p.ResultCode, err)
+	}
+
+	// the tokens of a heartbeat replace the ones before
+	setQuotaTokens(map[string]*proto.QuotaToken{"quota": {NoBytes: true, TTL: 60}})
+	if mp.checkQuotaInodes(nil) != proto.OpOk || mp.checkQuotaBytes(1) != proto.OpQuotaExceededErr {
+		t.Fatalf("unexpected checks of token(%v)", getQuotaToken("quota"))
+	}
+
+	// the token is dropped once expired
+	setQuotaTokens(map[string]*proto.QuotaToken{"quota": {NoBytes: true, TTL: -1}})
+	if status := mp.checkQuotaBytes(1); status != proto.OpOk {
+		t.Fatalf("expired token: status(%v)", status)
+	}
+}
+
+func TestDirQuotaTokens(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "quota"}, nil).(*metaPartition)
+	defer setQuotaTokens(nil)
+	dir := NewInode(2, proto.Mode(os.ModeDir|0755))
+	dir.SetQuotaIDs([]uint64{11})
+	mp.fsmCreateInode(dir)
+	file := NewInode(3, proto.Mode(0644))
+	file.SetQuotaIDs([]uint64{11, 12})
+	mp.fsmCreateInode(file)
+	mp.fsmCreateInode(NewInode(4, proto.Mode(0644)))
+
+	setQuotaTokens(map[string]*proto.QuotaToken{"quota": {NoInodesQuotas: []uint64{11}, NoBytesQuotas: []uint64{12}, TTL: 60}})
+	cases := []struct {
+		name   string
+		check  func() uint8
+		expect uint8
+	}{
+		{"inode of the quota exceeded", func() uint8 { return mp.checkQuotaInodes([]uint64{5, 11}) }, proto.OpQuotaExceededErr},
+		{"inode of the quota within", func() uint8 { return mp.checkQuotaInodes([]uint64{12}) }, proto.OpOk},
+		{"inode without quota", func() uint8 { return mp.checkQuotaInodes(nil) }, proto.OpOk},
+		{"dentry in the tree", func() uint8 { return mp.checkQuotaDentry(2) }, proto.OpQuotaExceededErr},
+		{"dentry out of the tree", func() uint8 { return mp.checkQuotaDentry(4) }, proto.OpOk},
+		{"data of the quota exceeded", func() uint8 { return mp.checkQuotaBytes(3) }, proto.OpQuotaExceededErr},
+		{"data of the quota within", func() uint8 { return mp.checkQuotaBytes(2) }, proto.OpOk},
+		{"data without quota", func() uint8 { return mp.checkQuotaBytes(4) }, proto.OpOk},
+	}
+	for _, c := range cases {
+		if status := c.check(); status != c.expect {
+			t.Errorf("%v: expect status(%v), got status(%v)", c.name, c.expect, status)
+		}
+	}
+
+	p := &Packet{}
+	if err := mp.CreateDentry(&CreateDentryReq{ParentID: 2, Name: "f", Inode: 100, Mode: proto.Mode(0644)}, p); err != nil ||
+		p.ResultCode != proto.OpQuotaExceededErr {
+		t.Fatalf("create dentry: expect OpQuotaExceededErr, got status(%v) err(%v)", p.ResultCode, err)
+	}
+	p = &Packet{}
+	if err := mp.CreateInode(&CreateInoReq{Mode: proto.Mode(0644), QuotaIDs: []uint64{11}}, p); err != nil ||
+		p.ResultCode != proto.OpQuotaExceededErr {
+		t.Fatalf("create inode: expect OpQuotaExceededErr, got status(%v) err(%v)", p.ResultCode, err)
+	}
+}
+
+func TestQuotaUsages(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "quota"}, nil).(*metaPartition)
+	mp.fsmCreateInode(NewInode(2, proto.Mode(os.ModeDir|0755)))
+	file := NewInode(3, proto.Mode(0644))
+	file.Size = 100
+	mp.fsmCreateInode(file)
+	mp.fsmCreateInode(NewInode(4, proto.Mode(0644)))
+	expectUsages := func(step, expect string) {
+		usages := make([]proto.QuotaUsage, 0)
+		for _, usage := range mp.GetQuotaUsages() {
+			usages = append(usages, *usage)
+		}
+		if fmt.Sprintf("%+v", usages) != expect {
+			t.Fatalf("%v: expect usages %v, got %v", step, expect, usages)
+		}
+	}
+	expectUsages("untagged", "[]")
+
+	// the missing inodes and the ones tagged already are skipped
+	mp.fsmSetInodeQuota(&proto.SetInodeQuotaRequest{Inodes: []uint64{2, 3, 9}, QuotaID: 11})
+	mp.fsmSetInodeQuota(&proto.SetInodeQuotaRequest{Inodes: []uint64{3}, QuotaID: 11})
+	mp.fsmSetInodeQuota(&proto.SetInodeQuotaRequest{Inodes: []uint64{3, 4}, QuotaID: 12})
+	expectUsages("tagged", "[{QuotaID:11 UsedBytes:100 UsedInodes:2} {QuotaID:12 UsedBytes:100 UsedInodes:2}]")
+	if ids := fmt.Sprint(mp.inodeQuotaIDs(3)); ids != "[11 12]" {
+		t.Fatalf("tagged: unexpected quotas %v", ids)
+	}
+
+	val, err := file.Marshal()
+	if err != nil {
+		t.Fatalf("marshal: err(%v)", err)
+	}
+	ino := NewInode(0, 0)
+	if err = ino.Unmarshal(val); err != nil || fmt.Sprint(ino.GetQuotaIDs()) != "[11 12]" {
+		t.Fatalf("unmarshal: quotas(%v) err(%v)", ino.GetQuotaIDs(), err)
+	}
+
+	mp.fsmExtentsTruncate(&Inode{Inode: 3, Size: 40})
+	mp.internalDeleteInode(NewInode(4, 0))
+	expectUsages("truncated and deleted", "[{QuotaID:11 UsedBytes:40 UsedInodes:2} {QuotaID:12 UsedBytes:40 UsedInodes:1}]")
+
+	mp.fsmSetInodeQuota(&proto.SetInodeQuotaRequest{Inodes: []uint64{2, 3}, QuotaID: 11, Remove: true})
+	expectUsages("untagged", "[{QuotaID:12 UsedBytes:40 UsedInodes:1}]")
+}
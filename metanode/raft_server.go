@@ -38,15 +38,31 @@ func (m *MetaNode) startRaftServer() (err error) {
 	heartbeatPort, _ := strconv.Atoi(m.raftHeartbeatPort)
 	replicaPort, _ := strconv.Atoi(m.raftReplicatePort)
 
+	raftIP := m.localAddr
+	if m.replicaAddr() != "" {
+		// listen on all the interfaces, the peers which have not learned the replication
+		// address of this node yet still send the raft messages to the service address
+		raftIP = ""
+	}
 	raftConf := &raftstore.Config{
 		NodeID:            m.nodeId,
 		RaftPath:          m.raftDir,
-		IPAddr:            m.localAddr,
+		IPAddr:            raftIP,
+		ReplicaIPOf:       replicaAddrs.IP,
 		HeartbeatPort:     heartbeatPort,
 		ReplicaPort:       replicaPort,
 		TickInterval:      m.tickInterval,
 		RecvBufSize:       m.raftRecvBufSize,
+		PreVote:           m.raftPreVote,
+		WalFileSize:       m.raftWalFileSize,
+		WalCompression:    m.raftWalCompress,
+		WalRepair:         m.raftWalRepair,
+		ProposalBatchSize: m.raftPropBatch,
+		SnapshotRateLimit: m.raftSnapRateLimit,
 		NumOfLogsToRetain: raftstore.DefaultNumOfLogsToRetain * 2,
+		TLSCertFile:       m.raftTLSCertFile,
+		TLSKeyFile:        m.raftTLSKeyFile,
+		TLSCAFile:         m.raftTLSCAFile,
 	}
 	m.raftStore, err = raftstore.NewRaftStore(raftConf)
 	if err != nil {
@@ -55,6 +71,15 @@ func (m *MetaNode) startRaftServer() (err error) {
 	return
 }
 
+// replicaAddr returns the address the other meta nodes send the raft traffic to,
+// empty if it goes to the service address.
+func (m *MetaNode) replicaAddr() string {
+	if m.replicaIP == "" || m.replicaIP == m.localAddr {
+		return ""
+	}
+	return m.replicaIP + ":" + m.listen
+}
+
 func (m *MetaNode) stopRaftServer() {
 	if m.raftStore != nil {
 		m.raftStore.Stop()
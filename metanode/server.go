@@ -22,13 +22,14 @@ import (
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tlsutil"
 )
 
 // StartTcpService binds and listens to the specified port.
 func (m *MetaNode) startServer() (err error) {
 	// initialize and start the server.
 	m.httpStopC = make(chan uint8)
-	ln, err := net.Listen("tcp", ":"+m.listen)
+	ln, err := tlsutil.Listen(":" + m.listen)
 	if err != nil {
 		return
 	}
@@ -65,9 +66,10 @@ func (m *MetaNode) stopServer() {
 // Read data from the specified tcp connection until the connection is closed by the remote or the tcp service is down.
 func (m *MetaNode) serveConn(conn net.Conn, stopC chan uint8) {
 	defer conn.Close()
-	c := conn.(*net.TCPConn)
-	c.SetKeepAlive(true)
-	c.SetNoDelay(true)
+	if c := tlsutil.TCPConn(conn); c != nil {
+		c.SetKeepAlive(true)
+		c.SetNoDelay(true)
+	}
 	remoteAddr := conn.RemoteAddr().String()
 	for {
 		select {
@@ -99,7 +101,7 @@ func (m *MetaNode) startSmuxServer() (err error) {
 	// initialize and start the server.
 	m.smuxStopC = make(chan uint8)
 	addr := util.ShiftAddrPort(":"+m.listen, smuxPortShift)
-	ln, err := net.Listen("tcp", addr)
+	ln, err := tlsutil.Listen(addr)
 	if err != nil {
 		return
 	}
@@ -140,9 +142,10 @@ func (m *MetaNode) stopSmuxServer() {
 
 func (m *MetaNode) serveSmuxConn(conn net.Conn, stopC chan uint8) {
 	defer conn.Close()
-	c := conn.(*net.TCPConn)
-	c.SetKeepAlive(true)
-	c.SetNoDelay(true)
+	if c := tlsutil.TCPConn(conn); c != nil {
+		c.SetKeepAlive(true)
+		c.SetNoDelay(true)
+	}
 	remoteAddr := conn.RemoteAddr().String()
 
 	var sess *smux.Session
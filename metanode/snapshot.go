@@ -0,0 +1,260 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+)
+
+// subtreeSnapshot is a frozen, read-only view of the namespace of a meta partition.
+// When created, its trees are copy-on-write clones of the live trees, so unchanged
+// inodes and dentries are shared with the partition. Sealing the snapshot prunes the
+// trees down to the inodes reachable from RootIno.
+type subtreeSnapshot struct {
+	ID         uint64
+	Name       string
+	RootIno    uint64
+	CreateTime int64
+	Sealed     bool
+	ApplyID    uint64 // the raft index the snapshot is taken at
+	inodeTree  *BTree
+	dentryTree *BTree
+}
+
+type subtreeSnapshotHeader struct {
+	ID         uint64 `json:"id"`
+	Name       string `json:"name"`
+	RootIno    uint64 `json:"root"`
+	CreateTime int64  `json:"ctime"`
+	Sealed     bool   `json:"sealed"`
+	ApplyID    uint64 `json:"apply,omitempty"`
+}
+
+func (s *subtreeSnapshot) info() *proto.SnapshotInfo {
+	return &proto.SnapshotInfo{
+		ID:         s.ID,
+		Name:       s.Name,
+		RootIno:    s.RootIno,
+		CreateTime: s.CreateTime,
+		Sealed:     s.Sealed,
+		ApplyID:    s.ApplyID,
+		Inodes:     uint64(s.inodeTree.Len()),
+		Dentries:   uint64(s.dentryTree.Len()),
+	}
+}
+
+// rangeExtents calls fn for every extent key referenced by the snapshot.
+func (s *subtreeSnapshot) rangeExtents(fn func(ek *proto.ExtentKey)) {
+	s.inodeTree.Ascend(func(i BtreeItem) bool {
+		i.(*Inode).Extents.Range(func(ek proto.ExtentKey) bool {
+			fn(&ek)
+			return true
+		})
+		return true
+	})
+}
+
+// snapshotExtentKey identifies the data a snapshot keeps alive. Normal extents are
+// deleted as a whole, while tiny extents are deleted by range.
+type snapshotExtentKey struct {
+	PartitionId  uint64
+	ExtentId     uint64
+	ExtentOffset uint64
+}
+
+func newSnapshotExtentKey(ek *proto.ExtentKey) snapshotExtentKey {
+	key := snapshotExtentKey{
+		PartitionId: ek.PartitionId,
+		ExtentId:    ek.ExtentId,
+	}
+	if storage.IsTinyExtent(ek.ExtentId) {
+		key.ExtentOffset = ek.ExtentOffset
+	}
+	return key
+}
+
+// writeSubtreeSnapshots encodes the snapshots and the extents held for them.
+// Frame structure:
+//  +-----+-----------+-------------+-----+---------+------+
+//  | Num | Snapshot1 | Snapshot... | Len | Held    | ...  |
+//  +-----+-----------+-------------+-----+---------+------+
+//  Snapshot: | Len | Header | NumInodes | Len | Inode | ... | NumDentries | Len | Dentry | ... |
+// All numbers and lengths are uvarints, the header and the held extents are JSON.
+func writeSubtreeSnapshots(w io.Writer, snapshots []*subtreeSnapshot, held []proto.ExtentKey) (err error) {
+	var varintTmp = make([]byte, binary.MaxVarintLen64)
+	var writeUvarint = func(v uint64) error {
+		n := binary.PutUvarint(varintTmp, v)
+		_, err := w.Write(varintTmp[:n])
+		return err
+	}
+	var writeBytes = func(raw []byte) error {
+		if err := writeUvarint(uint64(len(raw))); err != nil {
+			return err
+		}
+		_, err := w.Write(raw)
+		return err
+	}
+	var writeTree = func(tree *BTree) (err error) {
+		if err = writeUvarint(uint64(tree.Len())); err != nil {
+			return
+		}
+		tree.Ascend(func(i BtreeItem) bool {
+			var raw []byte
+			switch item := i.(type) {
+			case *Inode:
+				raw, err = item.Marshal()
+			case *Dentry:
+				raw, err = item.Marshal()
+			}
+			if err != nil {
+				return false
+			}
+			err = writeBytes(raw)
+			return err == nil
+		})
+		return
+	}
+
+	if err = writeUvarint(uint64(len(snapshots))); err != nil {
+		return
+	}
+	for _, s := range snapshots {
+		var header []byte
+		if header, err = json.Marshal(&subtreeSnapshotHeader{
+			ID:         s.ID,
+			Name:       s.Name,
+			RootIno:    s.RootIno,
+			CreateTime: s.CreateTime,
+			Sealed:     s.Sealed,
+			ApplyID:    s.ApplyID,
+		}); err != nil {
+			return
+		}
+		if err = writeBytes(header); err != nil {
+			return
+		}
+		if err = writeTree(s.inodeTree); err != nil {
+			return
+		}
+		if err = writeTree(s.dentryTree); err != nil {
+			return
+		}
+	}
+	var raw []byte
+	if raw, err = json.Marshal(held); err != nil {
+		return
+	}
+	err = writeBytes(raw)
+	return
+}
+
+// readSubtreeSnapshots decodes the data written by writeSubtreeSnapshots.
+func readSubtreeSnapshots(data []byte) (snapshots []*subtreeSnapshot, held []proto.ExtentKey, err error) {
+	var offset int
+	var readUvarint = func() (uint64, error) {
+		v, n := binary.Uvarint(data[offset:])
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid subtree snapshot data at offset %v", offset)
+		}
+		offset += n
+		return v, nil
+	}
+	var readBytes = func() ([]byte, error) {
+		size, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)-offset) < size {
+			return nil, fmt.Errorf("subtree snapshot data too short at offset %v", offset)
+		}
+		raw := data[offset : offset+int(size)]
+		offset += int(size)
+		return raw, nil
+	}
+
+	var numSnapshots uint64
+	if numSnapshots, err = readUvarint(); err != nil {
+		return
+	}
+	for i := uint64(0); i < numSnapshots; i++ {
+		var raw []byte
+		if raw, err = readBytes(); err != nil {
+			return
+		}
+		header := &subtreeSnapshotHeader{}
+		if err = json.Unmarshal(raw, header); err != nil {
+			return
+		}
+		s := &subtreeSnapshot{
+			ID:         header.ID,
+			Name:       header.Name,
+			RootIno:    header.RootIno,
+			CreateTime: header.CreateTime,
+			Sealed:     header.Sealed,
+			ApplyID:    header.ApplyID,
+			inodeTree:  NewBtree(),
+			dentryTree: NewBtree(),
+		}
+		var num uint64
+		if num, err = readUvarint(); err != nil {
+			return
+		}
+		for j := uint64(0); j < num; j++ {
+			if raw, err = readBytes(); err != nil {
+				return
+			}
+			ino := NewInode(0, 0)
+			if err = ino.Unmarshal(raw); err != nil {
+				return
+			}
+			s.inodeTree.ReplaceOrInsert(ino, true)
+		}
+		if num, err = readUvarint(); err != nil {
+			return
+		}
+		for j := uint64(0); j < num; j++ {
+			if raw, err = readBytes(); err != nil {
+				return
+			}
+			dentry := &Dentry{}
+			if err = dentry.Unmarshal(raw); err != nil {
+				return
+			}
+			s.dentryTree.ReplaceOrInsert(dentry, true)
+		}
+		snapshots = append(snapshots, s)
+	}
+	var raw []byte
+	if raw, err = readBytes(); err != nil {
+		return
+	}
+	err = json.Unmarshal(raw, &held)
+	return
+}
+
+func marshalSubtreeSnapshots(snapshots []*subtreeSnapshot, held []proto.ExtentKey) ([]byte, error) {
+	buff := bytes.NewBuffer(make([]byte, 0))
+	if err := writeSubtreeSnapshots(buff, snapshots, held); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
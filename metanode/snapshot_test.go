@@ -0,0 +1,207 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestSubtreeSnapshot(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}, nil).(*metaPartition)
+	ek := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 100}
+	mp.fsmCreateInode(NewInode(1, proto.Mode(os.ModeDir|0755)))
+	mp.fsmCreateInode(NewInode(2, proto.Mode(os.ModeDir|0755)))
+	file := NewInode(3, proto.Mode(0644))
+	file.Size = 100
+	file.Extents.Append(ek)
+	mp.fsmCreateInode(file)
+	mp.fsmCreateInode(NewInode(4, proto.Mode(0644)))
+	mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "dir", Inode: 2}, false)
+	mp.fsmCreateDentry(&Dentry{ParentId: 2, Name: "file", Inode: 3}, false)
+	mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "other", Inode: 4}, false)
+
+	if status := mp.fsmCreateSnapshot(&proto.SnapshotInfo{ID: 7, Name: "s", RootIno: 2}); status != proto.OpOk {
+		t.Fatalf("create snapshot: status(%v)", status)
+	}
+	if status := mp.fsmCreateSnapshot(&proto.SnapshotInfo{ID: 7, RootIno: 2}); status != proto.OpExistErr {
+		t.Fatalf("create duplicated snapshot: expect OpExistErr, got status(%v)", status)
+	}
+
+	// Changes to the live tree must not be visible in the snapshot.
+	truncated := NewInode(3, 0)
+	truncated.Size = 0
+	if resp := mp.fsmExtentsTruncate(truncated); resp.Status != proto.OpOk {
+		t.Fatalf("truncate: status(%v)", resp.Status)
+	}
+	if eks := <-mp.extDelCh; len(eks) != 0 {
+		t.Fatalf("extents referenced by the snapshot are deleted: %v", eks)
+	}
+	mp.fsmDeleteDentry(&Dentry{ParentId: 2, Name: "file"}, false)
+
+	if _, status := mp.getDentryAt(0, &Dentry{ParentId: 2, Name: "file"}); status != proto.OpNotExistErr {
+		t.Fatalf("live lookup: expect OpNotExistErr, got status(%v)", status)
+	}
+	d, status := mp.getDentryAt(7, &Dentry{ParentId: 2, Name: "file"})
+	if status != proto.OpOk || d.Inode != 3 {
+		t.Fatalf("snapshot lookup: status(%v) dentry(%v)", status, d)
+	}
+	resp := mp.getInodeAt(7, NewInode(3, 0))
+	if resp.Status != proto.OpOk || resp.Msg.Size != 100 || resp.Msg.Extents.Size() != 100 {
+		t.Fatalf("snapshot inode: status(%v) inode(%v)", resp.Status, resp.Msg)
+	}
+
+	// Sealing keeps the subtree only.
+	if status = mp.fsmSealSnapshot(&proto.SealSnapshotRequest{SnapshotID: 7, Inodes: []uint64{2, 3}}); status != proto.OpOk {
+		t.Fatalf("seal snapshot: status(%v)", status)
+	}
+	s, _ := mp.getSnapshot(7)
+	if s.inodeTree.Len() != 2 || s.dentryTree.Len() != 1 || !s.Sealed {
+		t.Fatalf("sealed snapshot: inodes(%v) dentries(%v) sealed(%v)", s.inodeTree.Len(), s.dentryTree.Len(), s.Sealed)
+	}
+
+	// The snapshots survive an encode and decode round trip.
+	snapshots, held := mp.getSnapshotState()
+	raw, err := marshalSubtreeSnapshots(snapshots, held)
+	if err != nil {
+		t.Fatalf("marshal snapshots: %v", err)
+	}
+	decoded, decodedHeld, err := readSubtreeSnapshots(raw)
+	if err != nil {
+		t.Fatalf("unmarshal snapshots: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Name != "s" || decoded[0].inodeTree.Len() != 2 || len(decodedHeld) != 1 {
+		t.Fatalf("decoded snapshots mismatch: snapshots(%v) held(%v)", decoded, decodedHeld)
+	}
+
+	// Deleting the snapshot releases the extents it kept alive.
+	if status = mp.fsmDeleteSnapshot(&proto.DeleteSnapshotRequest{SnapshotID: 7}); status != proto.OpOk {
+		t.Fatalf("delete snapshot: status(%v)", status)
+	}
+	if eks := <-mp.extDelCh; len(eks) != 1 || eks[0].ExtentId != ek.ExtentId {
+		t.Fatalf("held extents not released: %v", eks)
+	}
+	if _, status = mp.dentryTreeAt(7); status != proto.OpNotExistErr {
+		t.Fatalf("deleted snapshot still readable: status(%v)", status)
+	}
+}
+
+func TestRestoreSubtreeSnapshot(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1}, nil).(*metaPartition)
+	ek := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 100}
+	mp.fsmCreateInode(NewInode(1, proto.Mode(os.ModeDir|0755)))
+	mp.fsmCreateInode(NewInode(2, proto.Mode(os.ModeDir|0755)))
+	file := NewInode(3, proto.Mode(0644))
+	file.Size = 100
+	file.Extents.Append(ek)
+	mp.fsmCreateInode(file)
+	mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "dir", Inode: 2}, false)
+	mp.fsmCreateDentry(&Dentry{ParentId: 2, Name: "file", Inode: 3}, false)
+	if status := mp.fsmCreateSnapshot(&proto.SnapshotInfo{ID: 7, RootIno: 1, ApplyID: 10}); status != proto.OpOk {
+		t.Fatalf("create snapshot: status(%v)", status)
+	}
+
+	// The file is truncated and removed, and another one is created.
+	truncated := NewInode(3, 0)
+	truncated.Size = 0
+	mp.fsmExtentsTruncate(truncated)
+	<-mp.extDelCh
+	mp.fsmDeleteDentry(&Dentry{ParentId: 2, Name: "file"}, false)
+	mp.fsmUnlinkInode(NewInode(3, 0))
+	mp.fsmCreateInode(NewInode(4, proto.Mode(0644)))
+	mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "new", Inode: 4}, false)
+
+	if status := mp.fsmRestoreSnapshot(&proto.RestoreSnapshotRequest{SnapshotID: 7, Inodes: []uint64{2}}); status != proto.OpArgMismatchErr {
+		t.Fatalf("restore dir: expect OpArgMismatchErr, got status(%v)", status)
+	}
+	if status := mp.fsmRestoreSnapshot(&proto.RestoreSnapshotRequest{SnapshotID: 7, Inodes: []uint64{9}}); status != proto.OpNotExistErr {
+		t.Fatalf("restore unknown inode: expect OpNotExistErr, got status(%v)", status)
+	}
+
+	// Restoring the file brings back its data, but not its dentry.
+	if status := mp.fsmRestoreSnapshot(&proto.RestoreSnapshotRequest{SnapshotID: 7, Inodes: []uint64{3}}); status != proto.OpOk {
+		t.Fatalf("restore file: status(%v)", status)
+	}
+	resp := mp.getInode(NewInode(3, 0))
+	if resp.Status != proto.OpOk || resp.Msg.Size != 100 || resp.Msg.GetNLink() != 1 || mp.GetSize() != 100 {
+		t.Fatalf("restored file: status(%v) inode(%v) size(%v)", resp.Status, resp.Msg, mp.GetSize())
+	}
+	if _, held := mp.getSnapshotState(); len(held) != 0 {
+		t.Fatalf("extents of the restored file still held: %v", held)
+	}
+	if _, status := mp.getDentry(&Dentry{ParentId: 2, Name: "file"}); status != proto.OpNotExistErr {
+		t.Fatalf("dentry restored with the file: status(%v)", status)
+	}
+
+	// Restoring the namespace drops what is created after the snapshot.
+	if status := mp.fsmRestoreSnapshot(&proto.RestoreSnapshotRequest{SnapshotID: 7}); status != proto.OpOk {
+		t.Fatalf("restore snapshot: status(%v)", status)
+	}
+	if d, status := mp.getDentry(&Dentry{ParentId: 2, Name: "file"}); status != proto.OpOk || d.Inode != 3 {
+		t.Fatalf("dentry not restored: status(%v) dentry(%v)", status, d)
+	}
+	if _, status := mp.getDentry(&Dentry{ParentId: 1, Name: "new"}); status != proto.OpNotExistErr {
+		t.Fatalf("dentry created after the snapshot: status(%v)", status)
+	}
+	if item := mp.inodeTree.Get(NewInode(4, 0)); item == nil || !item.(*Inode).ShouldDelete() {
+		t.Fatalf("inode created after the snapshot not deleted: %v", item)
+	}
+	if s, _ := mp.getSnapshot(7); s.info().ApplyID != 10 {
+		t.Fatalf("apply id of the snapshot: %v", s.info().ApplyID)
+	}
+}
+
+func TestRestoreClearsNewPartition(t *testing.T) {
+	cases := []struct {
+		name string
+		// taken is whether the partition has taken the snapshot, which is not cleared
+		taken  bool
+		status uint8
+	}{
+		{"created after the snapshot", false, proto.OpOk},
+		{"holding the snapshot", true, proto.OpArgMismatchErr},
+	}
+	for _, c := range cases {
+		mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 2}, nil).(*metaPartition)
+		if c.taken {
+			mp.fsmCreateSnapshot(&proto.SnapshotInfo{ID: 7, RootIno: 1})
+		}
+		file := NewInode(1025, proto.Mode(0644))
+		file.Size = 100
+		file.Extents.Append(proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1026, Size: 100})
+		mp.fsmCreateInode(NewInode(1024, proto.Mode(os.ModeDir|0755)))
+		mp.fsmCreateInode(file)
+		mp.fsmCreateDentry(&Dentry{ParentId: 1024, Name: "file", Inode: 1025}, false)
+
+		if status := mp.fsmRestoreSnapshot(&proto.RestoreSnapshotRequest{SnapshotID: 7, Clear: true}); status != c.status {
+			t.Fatalf("%v: clear partition: status(%v), expected %v", c.name, status, c.status)
+		}
+		if c.status != proto.OpOk {
+			if mp.inodeTree.Len() != 2 || mp.dentryTree.Len() != 1 {
+				t.Errorf("%v: inodes(%v) dentries(%v) left after the refused clear", c.name, mp.inodeTree.Len(), mp.dentryTree.Len())
+			}
+			continue
+		}
+		// the directory is dropped, the file is left to the free list which deletes its extents
+		if mp.dentryTree.Len() != 0 || mp.inodeTree.Get(NewInode(1024, 0)) != nil {
+			t.Errorf("%v: dentries(%v) dir(%v) left after the clear", c.name, mp.dentryTree.Len(), mp.inodeTree.Get(NewInode(1024, 0)))
+		}
+		if item := mp.inodeTree.Get(NewInode(1025, 0)); item == nil || !item.(*Inode).ShouldDelete() || mp.freeList.Pop() != 1025 {
+			t.Errorf("%v: file not freed: %v", c.name, item)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
 )
 
 type SortedExtents struct {
@@ -229,6 +230,82 @@ func (se *SortedExtents) Truncate(offset uint64) (deleteExtents []proto.ExtentKe
 	return
 }
 
+// TruncateHole returns the range released from the key crossing the offset when the
+// extents are truncated at the offset, as a key to punch a hole in its extent.
+func (se *SortedExtents) TruncateHole(offset uint64) (holes []proto.ExtentKey) {
+	se.RLock()
+	defer se.RUnlock()
+
+	for _, ek := range se.eks {
+		ekEnd := ek.FileOffset + uint64(ek.Size)
+		if ek.FileOffset < offset && ekEnd > offset {
+			if hole, ok := releasedRange(ek, offset, ekEnd); ok {
+				holes = append(holes, hole)
+			}
+			break
+		}
+	}
+	return
+}
+
+// PunchHole removes the range [offset, offset+size) of the file from the extent keys,
+// the keys crossing the boundaries of the range are split. It returns the keys of the
+// ranges released from the extents.
+func (se *SortedExtents) PunchHole(offset, size uint64) (holes []proto.ExtentKey) {
+	end := offset + size
+
+	se.Lock()
+	defer se.Unlock()
+
+	eks := make([]proto.ExtentKey, 0, len(se.eks)+1)
+	for _, ek := range se.eks {
+		ekEnd := ek.FileOffset + uint64(ek.Size)
+		if ekEnd <= offset || ek.FileOffset >= end {
+			eks = append(eks, ek)
+			continue
+		}
+		start, stop := ek.FileOffset, ekEnd
+		if start < offset {
+			start = offset
+			left := ek
+			left.Size = uint32(start - ek.FileOffset)
+			eks = append(eks, left)
+		}
+		if stop > end {
+			stop = end
+		}
+		if hole, ok := releasedRange(ek, start, stop); ok {
+			holes = append(holes, hole)
+		}
+		if stop < ekEnd {
+			right := ek
+			right.FileOffset = stop
+			right.ExtentOffset = ek.ExtentOffset + stop - ek.FileOffset
+			right.Size = uint32(ekEnd - stop)
+			eks = append(eks, right)
+		}
+	}
+	se.eks = eks
+	return
+}
+
+// releasedRange returns the key of the range [start, end) of the file released from ek.
+// A tiny extent is deleted by range already, so its key is returned as it is if it is
+// released entirely. Otherwise the key refers to the range of the normal extent to punch.
+func releasedRange(ek proto.ExtentKey, start, end uint64) (hole proto.ExtentKey, ok bool) {
+	if storage.IsTinyExtent(ek.ExtentId) {
+		return ek, start == ek.FileOffset && end == ek.FileOffset+uint64(ek.Size)
+	}
+	hole = proto.ExtentKey{
+		FileOffset:   proto.PunchHoleFileOffset,
+		PartitionId:  ek.PartitionId,
+		ExtentId:     ek.ExtentId,
+		ExtentOffset: ek.ExtentOffset + start - ek.FileOffset,
+		Size:         uint32(end - start),
+	}
+	return hole, true
+}
+
 func (se *SortedExtents) Len() int {
 	se.RLock()
 	defer se.RUnlock()
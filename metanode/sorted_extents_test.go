@@ -94,3 +94,64 @@ func TestTruncate01(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestPunchHole01(t *testing.T) {
+	se := NewSortedExtents()
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 1025, ExtentOffset: 100}, nil)
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 1026}, nil)
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 1027}, nil)
+	holes := se.PunchHole(500, 2000)
+	t.Logf("\nholes: %v\neks: %v", holes, se.eks)
+	if len(holes) != 3 || !holes[0].IsPunchHole() ||
+		holes[0].ExtentId != 1025 || holes[0].ExtentOffset != 600 || holes[0].Size != 500 ||
+		holes[1].ExtentId != 1026 || holes[1].ExtentOffset != 0 || holes[1].Size != 1000 ||
+		holes[2].ExtentId != 1027 || holes[2].ExtentOffset != 0 || holes[2].Size != 500 {
+		t.Fail()
+	}
+	if len(se.eks) != 2 || se.eks[0].Size != 500 ||
+		se.eks[1].FileOffset != 2500 || se.eks[1].ExtentOffset != 500 || se.eks[1].Size != 500 ||
+		se.Size() != 3000 {
+		t.Fail()
+	}
+}
+
+// The hole is punched in the middle of a key
+func TestPunchHole02(t *testing.T) {
+	se := NewSortedExtents()
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 0, Size: 3000, ExtentId: 1025}, nil)
+	holes := se.PunchHole(1000, 1000)
+	t.Logf("\nholes: %v\neks: %v", holes, se.eks)
+	if len(holes) != 1 || holes[0].ExtentOffset != 1000 || holes[0].Size != 1000 ||
+		len(se.eks) != 2 || se.eks[0].Size != 1000 ||
+		se.eks[1].FileOffset != 2000 || se.eks[1].ExtentOffset != 2000 || se.eks[1].Size != 1000 {
+		t.Fail()
+	}
+}
+
+// Only the tiny extent keys released entirely are deleted
+func TestPunchHole03(t *testing.T) {
+	se := NewSortedExtents()
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 1, ExtentOffset: 4096}, nil)
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 2}, nil)
+	holes := se.PunchHole(500, 1500)
+	t.Logf("\nholes: %v\neks: %v", holes, se.eks)
+	if len(holes) != 1 || holes[0].IsPunchHole() || holes[0].ExtentId != 2 ||
+		len(se.eks) != 1 || se.eks[0].ExtentId != 1 || se.eks[0].Size != 500 {
+		t.Fail()
+	}
+}
+
+func TestTruncateHole01(t *testing.T) {
+	se := NewSortedExtents()
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 1025}, nil)
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 1026}, nil)
+	holes := se.TruncateHole(500)
+	t.Logf("\nholes: %v\neks: %v", holes, se.eks)
+	if len(holes) != 1 || !holes[0].IsPunchHole() || holes[0].ExtentId != 1025 ||
+		holes[0].ExtentOffset != 500 || holes[0].Size != 500 {
+		t.Fail()
+	}
+	if holes = se.TruncateHole(1500); len(holes) != 0 {
+		t.Fail()
+	}
+}
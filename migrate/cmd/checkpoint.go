@@ -0,0 +1,104 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// checkpointRecord records a file copied, which is copied again on resume if the source has changed since.
+type checkpointRecord struct {
+	Path       string `json:"path"`
+	Size       uint64 `json:"size"`
+	ModifyTime int64  `json:"mtime"`
+}
+
+// checkpoint is the log of the files copied, one JSON record per line, which is appended as the files are
+// copied, so that the migration resumes from the files not copied yet after it is interrupted.
+type checkpoint struct {
+	mu   sync.Mutex
+	fp   *os.File
+	done map[string]*checkpointRecord
+}
+
+// openCheckpoint loads the records of the checkpoint file, or truncates it if restart is set.
+// A torn record written when the migration was interrupted is dropped with the records after it.
+func openCheckpoint(name string, restart bool) (c *checkpoint, err error) {
+	flag := os.O_CREATE | os.O_RDWR | os.O_APPEND
+	if restart {
+		flag |= os.O_TRUNC
+	}
+	c = &checkpoint{done: make(map[string]*checkpointRecord)}
+	if c.fp, err = os.OpenFile(name, flag, 0644); err != nil {
+		return nil, err
+	}
+	var offset int64
+	scanner := bufio.NewScanner(c.fp)
+	for scanner.Scan() {
+		record := &checkpointRecord{}
+		if json.Unmarshal(scanner.Bytes(), record) != nil {
+			break
+		}
+		c.done[record.Path] = record
+		offset += int64(len(scanner.Bytes())) + 1
+	}
+	// drop the torn record, so that the records appended are not hidden behind it
+	var fi os.FileInfo
+	if fi, err = c.fp.Stat(); err == nil && offset < fi.Size() {
+		err = c.fp.Truncate(offset)
+	} else if err == nil && offset > fi.Size() {
+		// the last record misses its line break
+		_, err = c.fp.Write([]byte("\n"))
+	}
+	if err != nil {
+		_ = c.fp.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// isDone returns whether the file has been copied and not changed since.
+func (c *checkpoint) isDone(path string, info *proto.InodeInfo) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, ok := c.done[path]
+	return ok && record.Size == info.Size && record.ModifyTime == info.ModifyTime.Unix()
+}
+
+func (c *checkpoint) add(path string, info *proto.InodeInfo) error {
+	record := &checkpointRecord{Path: path, Size: info.Size, ModifyTime: info.ModifyTime.Unix()}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done[path] = record
+	_, err = c.fp.Write(append(data, '\n'))
+	return err
+}
+
+func (c *checkpoint) close() error {
+	if err := c.fp.Sync(); err != nil {
+		_ = c.fp.Close()
+		return err
+	}
+	return c.fp.Close()
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+var (
+	SrcMaster string
+	SrcVol    string
+	SrcPath   string
+	DstMaster string
+	DstVol    string
+	DstPath   string
+	Workers   int
+	Bandwidth int64
+)
+
+const (
+	readDirLimit   = 1024
+	copyBufferSize = 1 << 20
+	reportInterval = 10 * time.Second
+)
+
+// checkArgs checks the common args, and makes the destination cluster the source one if it is not specified.
+func checkArgs() error {
+	if SrcMaster == "" || SrcVol == "" || DstVol == "" {
+		return fmt.Errorf("Lack of mandatory args: src-master(%v) src-vol(%v) dst-vol(%v)", SrcMaster, SrcVol, DstVol)
+	}
+	if DstMaster == "" {
+		DstMaster = SrcMaster
+	}
+	if Workers <= 0 {
+		Workers = 1
+	}
+	return nil
+}
+
+func initLog(module string) error {
+	if _, err := log.InitLog("migratelog", module, log.InfoLevel, nil); err != nil {
+		return fmt.Errorf("Init log failed: %v", err)
+	}
+	return nil
+}
+
+// volume is the metadata and the data clients of a volume.
+type volume struct {
+	name string
+	mw   *meta.MetaWrapper
+	ec   *stream.ExtentClient
+}
+
+// openVolume opens the volume of the cluster, whose reads and writes are limited by the bandwidth in MB/s.
+func openVolume(masterAddr, name string, bandwidth int64) (v *volume, err error) {
+	masters := strings.Split(masterAddr, meta.HostsSeparator)
+	v = &volume{name: name}
+	if v.mw, err = meta.NewMetaWrapper(&meta.MetaConfig{
+		Volume:  name,
+		Masters: masters,
+	}); err != nil {
+		return nil, fmt.Errorf("NewMetaWrapper failed: vol(%v) err(%v)", name, err)
+	}
+	if v.ec, err = stream.NewExtentClient(&stream.ExtentConfig{
+		Volume:            name,
+		Masters:           masters,
+		OnAppendExtentKey: v.mw.AppendExtentKey,
+		OnGetExtents:      v.mw.GetExtents,
+		OnTruncate:        v.mw.Truncate,
+		OnPunchHole:       v.mw.PunchHole,
+		ReadBandwidth:     bandwidth,
+		WriteBandwidth:    bandwidth,
+	}); err != nil {
+		_ = v.mw.Close()
+		return nil, fmt.Errorf("NewExtentClient failed: vol(%v) err(%v)", name, err)
+	}
+	return v, nil
+}
+
+func (v *volume) close() {
+	_ = v.ec.Close()
+	_ = v.mw.Close()
+}
+
+// lookupPath returns the inode of the directory.
+func (v *volume) lookupPath(dir string) (ino uint64, err error) {
+	if ino, err = v.mw.LookupPath(dir); err != nil {
+		return 0, fmt.Errorf("Lookup path failed: vol(%v) path(%v) err(%v)", v.name, dir, err)
+	}
+	return
+}
+
+// mkdirAll creates the directory and its parents if they do not exist, and returns its inode.
+func (v *volume) mkdirAll(dir string) (ino uint64, err error) {
+	ino = proto.RootIno
+	for _, name := range strings.Split(dir, "/") {
+		if name == "" {
+			continue
+		}
+		if ino, err = v.mkdir(ino, name, proto.Mode(os.ModeDir|0755), 0, 0); err != nil {
+			return 0, fmt.Errorf("Mkdir failed: vol(%v) path(%v) err(%v)", v.name, dir, err)
+		}
+	}
+	return
+}
+
+// mkdir creates the directory in the parent, or returns the existing one.
+func (v *volume) mkdir(parent uint64, name string, mode, uid, gid uint32) (uint64, error) {
+	ino, existMode, err := v.mw.Lookup_ll(parent, name)
+	if err == nil {
+		if !proto.IsDir(existMode) {
+			return 0, syscall.ENOTDIR
+		}
+		return ino, nil
+	}
+	if err != syscall.ENOENT {
+		return 0, err
+	}
+	info, err := v.mw.Create_ll(parent, name, mode, uid, gid, nil)
+	if err == syscall.EEXIST {
+		return v.mkdir(parent, name, mode, uid, gid)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Inode, nil
+}
+
+// remove removes the file from the parent and evicts its inode.
+func (v *volume) remove(parent uint64, name string) error {
+	info, err := v.mw.Delete_ll(parent, name, false)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		_ = v.ec.EvictStream(info.Inode)
+		_ = v.mw.Evict(info.Inode)
+	}
+	return nil
+}
+
+// readDir calls fn with the children of the directory and their inodes, a page at a time.
+func (v *volume) readDir(ino uint64, fn func(dentries []proto.Dentry, infos map[uint64]*proto.InodeInfo) error) error {
+	var from string
+	for {
+		dentries, err := v.mw.ReadDirLimit_ll(ino, from, readDirLimit)
+		if err != nil {
+			return err
+		}
+		last := len(dentries) < readDirLimit
+		// the first one is the last one of the previous page
+		if from != "" && len(dentries) > 0 {
+			dentries = dentries[1:]
+		}
+		if len(dentries) == 0 {
+			return nil
+		}
+		inodes := make([]uint64, 0, len(dentries))
+		for _, den := range dentries {
+			inodes = append(inodes, den.Inode)
+		}
+		infos := make(map[uint64]*proto.InodeInfo, len(dentries))
+		for _, info := range v.mw.BatchInodeGet(inodes) {
+			infos[info.Inode] = info
+		}
+		if err = fn(dentries, infos); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		from = dentries[len(dentries)-1].Name
+	}
+}
+
+// stats is the progress of the migration or the verification.
+type stats struct {
+	files   int64
+	skipped int64
+	failed  int64
+	bytes   int64
+}
+
+func (s *stats) String() string {
+	return fmt.Sprintf("files(%v) skipped(%v) failed(%v) bytes(%v)",
+		atomic.LoadInt64(&s.files), atomic.LoadInt64(&s.skipped), atomic.LoadInt64(&s.failed), atomic.LoadInt64(&s.bytes))
+}
+
+// report prints the progress every interval until stopC is closed.
+func (s *stats) report(action string, stopC chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(reportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopC:
+			return
+		case <-ticker.C:
+			elapsed := time.Since(start)
+			fmt.Printf("%v: %v elapsed(%v) throughput(%.2f MB/s)\n", action, s, elapsed.Round(time.Second),
+				float64(atomic.LoadInt64(&s.bytes))/elapsed.Seconds()/(1<<20))
+		}
+	}
+}
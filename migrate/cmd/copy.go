@@ -0,0 +1,280 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+func newCopyCmd() *cobra.Command {
+	var (
+		checkpointFile string
+		restart        bool
+		skipVerify     bool
+	)
+	var c = &cobra.Command{
+		Use:   "copy",
+		Short: "copy the source directory to the destination, and resume from the checkpoint",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := Copy(checkpointFile, restart, skipVerify); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	c.Flags().StringVarP(&checkpointFile, "checkpoint", "c", "", "checkpoint file, _migrate_<src-vol>_<dst-vol>.ckpt by default")
+	c.Flags().BoolVarP(&restart, "restart", "", false, "copy all the files again instead of resuming from the checkpoint")
+	c.Flags().BoolVarP(&skipVerify, "skip-verify", "", false, "skip the verification after the copy")
+	return c
+}
+
+// copyTask is a regular file to copy into the destination directory.
+type copyTask struct {
+	path      string
+	src       *proto.InodeInfo
+	dstParent uint64
+	name      string
+}
+
+// dirAttr is the attributes of the source directory, set to the destination one after its files are copied.
+type dirAttr struct {
+	ino  uint64
+	info *proto.InodeInfo
+}
+
+type copier struct {
+	src   *volume
+	dst   *volume
+	ckpt  *checkpoint
+	stats stats
+	tasks chan *copyTask
+	dirs  []*dirAttr
+}
+
+// Copy copies the source directory to the destination by the workers. The directories and the symbolic links
+// are created as the tree is walked, and the regular files are copied by the workers, which are recorded by the
+// checkpoint and skipped when the copy is run again unless they have changed. The files failed are copied again
+// by the next run. The destination is verified against the source at last unless skipVerify is set.
+func Copy(checkpointFile string, restart, skipVerify bool) (err error) {
+	defer log.LogFlush()
+	if err = checkArgs(); err != nil {
+		return
+	}
+	if err = initLog("copy"); err != nil {
+		return
+	}
+	if checkpointFile == "" {
+		checkpointFile = fmt.Sprintf("_migrate_%s_%s.ckpt", SrcVol, DstVol)
+	}
+
+	c := &copier{tasks: make(chan *copyTask, Workers*2)}
+	if c.src, err = openVolume(SrcMaster, SrcVol, Bandwidth); err != nil {
+		return
+	}
+	defer c.src.close()
+	if c.dst, err = openVolume(DstMaster, DstVol, Bandwidth); err != nil {
+		return
+	}
+	defer c.dst.close()
+	if c.ckpt, err = openCheckpoint(checkpointFile, restart); err != nil {
+		return fmt.Errorf("Open checkpoint failed: %v", err)
+	}
+	defer c.ckpt.close()
+
+	srcIno, err := c.src.lookupPath(SrcPath)
+	if err != nil {
+		return
+	}
+	dstIno, err := c.dst.mkdirAll(DstPath)
+	if err != nil {
+		return
+	}
+
+	stopC := make(chan struct{})
+	go c.stats.report("copy", stopC)
+	var wg sync.WaitGroup
+	for i := 0; i < Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, copyBufferSize)
+			for task := range c.tasks {
+				if e := c.copyFile(task, buf); e != nil {
+					atomic.AddInt64(&c.stats.failed, 1)
+					log.LogErrorf("copy file failed: path(%v) err(%v)", task.path, e)
+					fmt.Printf("Failed: %v: %v\n", task.path, e)
+				}
+			}
+		}()
+	}
+	err = c.walk(srcIno, dstIno, "/")
+	close(c.tasks)
+	wg.Wait()
+	close(stopC)
+	if err != nil {
+		return fmt.Errorf("Walk source failed: %v", err)
+	}
+
+	// the directories are set from the deepest, since the files created change the modify time of their parents
+	for i := len(c.dirs) - 1; i >= 0; i-- {
+		d := c.dirs[i]
+		if e := c.setAttr(d.ino, d.info); e != nil {
+			log.LogWarnf("set directory attributes failed: ino(%v) err(%v)", d.ino, e)
+		}
+	}
+	fmt.Printf("Copy done: %v\n", &c.stats)
+	if failed := atomic.LoadInt64(&c.stats.failed); failed > 0 {
+		return fmt.Errorf("%v files failed to copy, run copy again to retry them", failed)
+	}
+	if skipVerify {
+		return nil
+	}
+	return verify(c.src, c.dst, srcIno, dstIno, false)
+}
+
+// walk creates the directories and the symbolic links of the source directory in the destination one,
+// and sends the regular files to the workers.
+func (c *copier) walk(srcIno, dstIno uint64, dir string) error {
+	return c.src.readDir(srcIno, func(dentries []proto.Dentry, infos map[uint64]*proto.InodeInfo) error {
+		for _, den := range dentries {
+			info, ok := infos[den.Inode]
+			if !ok {
+				// removed since listed
+				continue
+			}
+			p := path.Join(dir, den.Name)
+			switch {
+			case proto.IsDir(info.Mode):
+				ino, err := c.dst.mkdir(dstIno, den.Name, info.Mode, info.Uid, info.Gid)
+				if err != nil {
+					return fmt.Errorf("mkdir %v: %v", p, err)
+				}
+				c.dirs = append(c.dirs, &dirAttr{ino: ino, info: info})
+				if err = c.walk(den.Inode, ino, p); err != nil {
+					return err
+				}
+			case proto.IsSymlink(info.Mode):
+				if err := c.symlink(dstIno, den.Name, info); err != nil {
+					atomic.AddInt64(&c.stats.failed, 1)
+					log.LogErrorf("create symlink failed: path(%v) err(%v)", p, err)
+				}
+			case proto.IsRegular(info.Mode):
+				if c.ckpt.isDone(p, info) && c.copied(dstIno, den.Name, info) {
+					atomic.AddInt64(&c.stats.skipped, 1)
+					continue
+				}
+				c.tasks <- &copyTask{path: p, src: info, dstParent: dstIno, name: den.Name}
+			default:
+				// the special files are not migrated
+				atomic.AddInt64(&c.stats.skipped, 1)
+				log.LogWarnf("skip special file: path(%v) mode(%v)", p, proto.OsMode(info.Mode))
+			}
+		}
+		return nil
+	})
+}
+
+// copied returns whether the destination file of the one recorded by the checkpoint is still there.
+func (c *copier) copied(dstParent uint64, name string, info *proto.InodeInfo) bool {
+	ino, _, err := c.dst.mw.Lookup_ll(dstParent, name)
+	if err != nil {
+		return false
+	}
+	dstInfo, err := c.dst.mw.InodeGet_ll(ino)
+	return err == nil && dstInfo.Size == info.Size
+}
+
+func (c *copier) symlink(dstParent uint64, name string, info *proto.InodeInfo) error {
+	if _, _, err := c.dst.mw.Lookup_ll(dstParent, name); err == nil {
+		if err = c.dst.remove(dstParent, name); err != nil {
+			return err
+		}
+	} else if err != syscall.ENOENT {
+		return err
+	}
+	_, err := c.dst.mw.Create_ll(dstParent, name, info.Mode, info.Uid, info.Gid, info.Target)
+	return err
+}
+
+// copyFile copies the file to the destination, which replaces the file left by the previous run.
+func (c *copier) copyFile(task *copyTask, buf []byte) (err error) {
+	if _, _, err = c.dst.mw.Lookup_ll(task.dstParent, task.name); err == nil {
+		if err = c.dst.remove(task.dstParent, task.name); err != nil {
+			return
+		}
+	} else if err != syscall.ENOENT {
+		return
+	}
+	dstInfo, err := c.dst.mw.Create_ll(task.dstParent, task.name, task.src.Mode, task.src.Uid, task.src.Gid, nil)
+	if err != nil {
+		return
+	}
+	srcIno, dstIno := task.src.Inode, dstInfo.Inode
+
+	if err = c.src.ec.OpenStream(srcIno); err != nil {
+		return
+	}
+	defer func() {
+		_ = c.src.ec.CloseStream(srcIno)
+		_ = c.src.ec.EvictStream(srcIno)
+	}()
+	if err = c.dst.ec.OpenStream(dstIno); err != nil {
+		return
+	}
+	defer func() {
+		_ = c.dst.ec.CloseStream(dstIno)
+		_ = c.dst.ec.EvictStream(dstIno)
+	}()
+
+	var offset, n int
+	for total := int(task.src.Size); offset < total; offset += n {
+		size := len(buf)
+		if rest := total - offset; rest < size {
+			size = rest
+		}
+		if n, err = c.src.ec.Read(srcIno, buf, offset, size); err != nil && err != io.EOF {
+			return
+		}
+		if n == 0 {
+			return fmt.Errorf("source truncated at %v of %v", offset, total)
+		}
+		if _, err = c.dst.ec.Write(dstIno, offset, buf[:n], 0); err != nil {
+			return
+		}
+		atomic.AddInt64(&c.stats.bytes, int64(n))
+	}
+	if err = c.dst.ec.Flush(dstIno); err != nil {
+		return
+	}
+	if err = c.setAttr(dstIno, task.src); err != nil {
+		return
+	}
+	atomic.AddInt64(&c.stats.files, 1)
+	return c.ckpt.add(task.path, task.src)
+}
+
+func (c *copier) setAttr(ino uint64, info *proto.InodeInfo) error {
+	valid := proto.AttrMode | proto.AttrUid | proto.AttrGid | proto.AttrModifyTime | proto.AttrAccessTime
+	return c.dst.mw.Setattr(ino, valid, info.Mode, info.Uid, info.Gid, info.AccessTime.Unix(), info.ModifyTime.Unix())
+}
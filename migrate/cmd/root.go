@@ -0,0 +1,56 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func NewRootCmd() *cobra.Command {
+	var optShowVersion bool
+	var c = &cobra.Command{
+		Use:   path.Base(os.Args[0]),
+		Short: "ChubaoFS data migration tool",
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if optShowVersion {
+				_, _ = fmt.Fprint(os.Stdout, proto.DumpVersion("MIGRATE"))
+				return
+			}
+		},
+	}
+
+	c.AddCommand(
+		newCopyCmd(),
+		newVerifyCmd(),
+	)
+
+	c.PersistentFlags().StringVarP(&SrcMaster, "src-master", "", "", "master addresses of the source cluster")
+	c.PersistentFlags().StringVarP(&SrcVol, "src-vol", "", "", "source volume name")
+	c.PersistentFlags().StringVarP(&SrcPath, "src-path", "", "/", "source directory")
+	c.PersistentFlags().StringVarP(&DstMaster, "dst-master", "", "", "master addresses of the destination cluster, the source cluster by default")
+	c.PersistentFlags().StringVarP(&DstVol, "dst-vol", "", "", "destination volume name")
+	c.PersistentFlags().StringVarP(&DstPath, "dst-path", "", "/", "destination directory, created if not exists")
+	c.PersistentFlags().IntVarP(&Workers, "workers", "w", 8, "number of the files copied or verified concurrently")
+	c.PersistentFlags().Int64VarP(&Bandwidth, "bandwidth", "b", 0, "MB/s read from the source and written to the destination at most, 0 is unlimited")
+	c.Flags().BoolVarP(&optShowVersion, "version", "v", false, "Show version information")
+	return c
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var sizeOnly bool
+	var c = &cobra.Command{
+		Use:   "verify",
+		Short: "verify the destination directory against the source",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := Verify(sizeOnly); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	c.Flags().BoolVarP(&sizeOnly, "size-only", "", false, "compare the sizes of the files instead of their contents")
+	return c
+}
+
+// Verify verifies the destination directory against the source.
+func Verify(sizeOnly bool) (err error) {
+	defer log.LogFlush()
+	if err = checkArgs(); err != nil {
+		return
+	}
+	if err = initLog("verify"); err != nil {
+		return
+	}
+	src, err := openVolume(SrcMaster, SrcVol, Bandwidth)
+	if err != nil {
+		return
+	}
+	defer src.close()
+	dst, err := openVolume(DstMaster, DstVol, Bandwidth)
+	if err != nil {
+		return
+	}
+	defer dst.close()
+	srcIno, err := src.lookupPath(SrcPath)
+	if err != nil {
+		return
+	}
+	dstIno, err := dst.lookupPath(DstPath)
+	if err != nil {
+		return
+	}
+	return verify(src, dst, srcIno, dstIno, sizeOnly)
+}
+
+// verifyTask is a regular file to compare with the destination one.
+type verifyTask struct {
+	path string
+	src  *proto.InodeInfo
+	dst  uint64
+}
+
+type verifier struct {
+	src      *volume
+	dst      *volume
+	sizeOnly bool
+	stats    stats
+	tasks    chan *verifyTask
+}
+
+// verify checks that every entry of the source directory exists in the destination with the same type,
+// the symbolic links have the same targets, and the regular files have the same sizes and contents.
+// The entries only in the destination are not reported.
+func verify(src, dst *volume, srcIno, dstIno uint64, sizeOnly bool) error {
+	v := &verifier{src: src, dst: dst, sizeOnly: sizeOnly, tasks: make(chan *verifyTask, Workers*2)}
+	stopC := make(chan struct{})
+	go v.stats.report("verify", stopC)
+	var wg sync.WaitGroup
+	for i := 0; i < Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, copyBufferSize)
+			for task := range v.tasks {
+				if e := v.verifyFile(task, buf); e != nil {
+					v.mismatch(task.path, e)
+				}
+			}
+		}()
+	}
+	err := v.walk(srcIno, dstIno, "/")
+	close(v.tasks)
+	wg.Wait()
+	close(stopC)
+	if err != nil {
+		return fmt.Errorf("Walk source failed: %v", err)
+	}
+	fmt.Printf("Verify done: %v\n", &v.stats)
+	if failed := atomic.LoadInt64(&v.stats.failed); failed > 0 {
+		return fmt.Errorf("%v entries mismatch, run copy again to repair them, or with --restart if their sizes match", failed)
+	}
+	return nil
+}
+
+func (v *verifier) mismatch(p string, err error) {
+	atomic.AddInt64(&v.stats.failed, 1)
+	log.LogErrorf("verify mismatch: path(%v) err(%v)", p, err)
+	fmt.Printf("Mismatch: %v: %v\n", p, err)
+}
+
+func (v *verifier) walk(srcIno, dstIno uint64, dir string) error {
+	return v.src.readDir(srcIno, func(dentries []proto.Dentry, infos map[uint64]*proto.InodeInfo) error {
+		for _, den := range dentries {
+			info, ok := infos[den.Inode]
+			if !ok {
+				continue
+			}
+			p := path.Join(dir, den.Name)
+			ino, mode, err := v.dst.mw.Lookup_ll(dstIno, den.Name)
+			if err == syscall.ENOENT {
+				v.mismatch(p, fmt.Errorf("missing"))
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("lookup %v: %v", p, err)
+			}
+			if proto.OsModeType(mode) != proto.OsModeType(info.Mode) {
+				v.mismatch(p, fmt.Errorf("type %v, expect %v", proto.OsModeType(mode), proto.OsModeType(info.Mode)))
+				continue
+			}
+			switch {
+			case proto.IsDir(info.Mode):
+				if err = v.walk(den.Inode, ino, p); err != nil {
+					return err
+				}
+			case proto.IsSymlink(info.Mode):
+				dstInfo, err := v.dst.mw.InodeGet_ll(ino)
+				if err != nil {
+					return fmt.Errorf("get inode %v: %v", p, err)
+				}
+				if !bytes.Equal(dstInfo.Target, info.Target) {
+					v.mismatch(p, fmt.Errorf("target %s, expect %s", dstInfo.Target, info.Target))
+					continue
+				}
+				atomic.AddInt64(&v.stats.files, 1)
+			case proto.IsRegular(info.Mode):
+				v.tasks <- &verifyTask{path: p, src: info, dst: ino}
+			default:
+				atomic.AddInt64(&v.stats.skipped, 1)
+			}
+		}
+		return nil
+	})
+}
+
+func (v *verifier) verifyFile(task *verifyTask, buf []byte) error {
+	dstInfo, err := v.dst.mw.InodeGet_ll(task.dst)
+	if err != nil {
+		return err
+	}
+	if dstInfo.Size != task.src.Size {
+		return fmt.Errorf("size %v, expect %v", dstInfo.Size, task.src.Size)
+	}
+	if v.sizeOnly {
+		atomic.AddInt64(&v.stats.files, 1)
+		return nil
+	}
+	srcSum, err := checksum(v.src, task.src.Inode, task.src.Size, buf)
+	if err != nil {
+		return fmt.Errorf("read source: %v", err)
+	}
+	dstSum, err := checksum(v.dst, task.dst, dstInfo.Size, buf)
+	if err != nil {
+		return fmt.Errorf("read destination: %v", err)
+	}
+	if !bytes.Equal(srcSum, dstSum) {
+		return fmt.Errorf("md5 %x, expect %x", dstSum, srcSum)
+	}
+	atomic.AddInt64(&v.stats.files, 1)
+	atomic.AddInt64(&v.stats.bytes, int64(task.src.Size))
+	return nil
+}
+
+// checksum returns the MD5 of the first size bytes of the file.
+func checksum(vol *volume, ino, size uint64, buf []byte) (sum []byte, err error) {
+	if err = vol.ec.OpenStream(ino); err != nil {
+		return
+	}
+	defer func() {
+		_ = vol.ec.CloseStream(ino)
+		_ = vol.ec.EvictStream(ino)
+	}()
+	var (
+		h      hash.Hash = md5.New()
+		offset int
+		n      int
+	)
+	for total := int(size); offset < total; offset += n {
+		readSize := len(buf)
+		if rest := total - offset; rest < readSize {
+			readSize = rest
+		}
+		if n, err = vol.ec.Read(ino, buf, offset, readSize); err != nil && err != io.EOF {
+			return
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("truncated at %v of %v", offset, total)
+		}
+		h.Write(buf[:n])
+	}
+	return h.Sum(nil), nil
+}
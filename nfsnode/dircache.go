@@ -0,0 +1,123 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	dirListingExpiration = time.Minute
+	maxDirListings       = 4096
+)
+
+// dirListing is a snapshot of the entries of a directory. The cookies of
+// READDIR are the positions in the listing, and the verifier identifies the
+// listing, so that a client reading a large directory in many requests
+// sees the same entries in the same order.
+type dirListing struct {
+	verf    uint64
+	dir     uint64
+	vol     *volume
+	entries []proto.Dentry
+	expire  time.Time
+}
+
+type dirCache struct {
+	sync.Mutex
+	nextVerf uint64
+	listings map[uint64]*dirListing
+}
+
+func newDirCache() *dirCache {
+	return &dirCache{
+		nextVerf: uint64(time.Now().UnixNano()),
+		listings: make(map[uint64]*dirListing),
+	}
+}
+
+// get returns the listing of the directory for the cookie and the verifier
+// of a READDIR. A new listing is read from the beginning of the directory,
+// or if the listing of the verifier has expired.
+func (c *dirCache) get(vol *volume, dir, cookie, verf uint64) (*dirListing, uint32) {
+	now := time.Now()
+	c.Lock()
+	l := c.listings[verf]
+	if l != nil && cookie != 0 && l.dir == dir && l.vol == vol && now.Before(l.expire) {
+		l.expire = now.Add(dirListingExpiration)
+		c.Unlock()
+		return l, nfs3OK
+	}
+	c.Unlock()
+
+	dentries, err := vol.mw.ReadDir_ll(dir)
+	if err != nil {
+		log.LogErrorf("readdir: ino(%v) err(%v)", dir, err)
+		return nil, errorStatus(err)
+	}
+	l = &dirListing{
+		dir:     dir,
+		vol:     vol,
+		entries: make([]proto.Dentry, 0, len(dentries)+2),
+		expire:  now.Add(dirListingExpiration),
+	}
+	l.entries = append(l.entries, proto.Dentry{Name: ".", Inode: dir}, proto.Dentry{Name: "..", Inode: dir})
+	l.entries = append(l.entries, dentries...)
+	if cookie > uint64(len(l.entries)) {
+		return nil, nfs3ErrBadCookie
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if len(c.listings) >= maxDirListings {
+		for v, old := range c.listings {
+			if now.After(old.expire) || len(c.listings) >= maxDirListings {
+				delete(c.listings, v)
+			}
+		}
+	}
+	c.nextVerf++
+	l.verf = c.nextVerf
+	c.listings[l.verf] = l
+	return l, nfs3OK
+}
+
+// inodes gets the inodes of the entries from the cookie, as many as may
+// fit in a READDIRPLUS reply of the given size.
+func (l *dirListing) inodes(vol *volume, cookie uint64, count uint32) map[uint64]*proto.InodeInfo {
+	// an entry takes 150 bytes at least with the attributes and the handle
+	end := cookie + uint64(count)/150 + 1
+	if end > uint64(len(l.entries)) {
+		end = uint64(len(l.entries))
+	}
+	inos := make([]uint64, 0, end-cookie)
+	for _, entry := range l.entries[cookie:end] {
+		if entry.Name != "." && entry.Name != ".." {
+			inos = append(inos, entry.Inode)
+		}
+	}
+	infos := make(map[uint64]*proto.InodeInfo, len(inos))
+	for _, info := range vol.mw.BatchInodeGet(inos) {
+		if size, ok := vol.fileSize(info.Inode); ok && size > info.Size {
+			info.Size = size
+		}
+		infos[info.Inode] = info
+	}
+	return infos
+}
@@ -0,0 +1,297 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net"
+	gopath "path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The squash options of an export.
+const (
+	squashNone = "none"
+	squashRoot = "root"
+	squashAll  = "all"
+)
+
+const (
+	nobody = 65534
+
+	fileHandleSize = 16
+
+	streamIdleTimeout = 30 * time.Second
+)
+
+// exportConfig is an item of the exports configuration.
+// Example:
+//		{
+//			"path": "/ltptest",
+//			"volume": "ltptest",
+//			"owner": "ltptest",
+//			"subdir": "/data",
+//			"clients": ["10.196.0.0/16", "192.168.1.10"],
+//			"readOnly": false,
+//			"squash": "root",
+//			"anonUid": 65534,
+//			"anonGid": 65534
+//		}
+type exportConfig struct {
+	Path     string   `json:"path"`
+	Volume   string   `json:"volume"`
+	Owner    string   `json:"owner"`
+	SubDir   string   `json:"subdir"`
+	Clients  []string `json:"clients"`
+	ReadOnly bool     `json:"readOnly"`
+	Squash   string   `json:"squash"`
+	AnonUid  *uint32  `json:"anonUid"`
+	AnonGid  *uint32  `json:"anonGid"`
+}
+
+type export struct {
+	path     string
+	id       uint64
+	clients  []*net.IPNet
+	readOnly bool
+	squash   string
+	anonUid  uint32
+	anonGid  uint32
+	rootIno  uint64
+	vol      *volume
+}
+
+// volume is a volume opened by the exports, shared by the exports of the
+// same volume.
+type volume struct {
+	name string
+	mw   *meta.MetaWrapper
+	ec   *stream.ExtentClient
+
+	sync.Mutex
+	streams map[uint64]time.Time // opened inodes and their last access time
+}
+
+func parseExports(items []interface{}) (configs []*exportConfig, err error) {
+	paths := make(map[string]bool)
+	for _, item := range items {
+		var data []byte
+		if data, err = json.Marshal(item); err != nil {
+			return
+		}
+		ec := &exportConfig{}
+		if err = json.Unmarshal(data, ec); err != nil {
+			return nil, fmt.Errorf("invalid export %s: %v", data, err)
+		}
+		if ec.Volume == "" {
+			return nil, fmt.Errorf("invalid export %s: no volume", data)
+		}
+		if ec.Path == "" {
+			ec.Path = "/" + ec.Volume
+		}
+		ec.Path = gopath.Clean("/" + ec.Path)
+		if paths[ec.Path] {
+			return nil, fmt.Errorf("duplicate export path %v", ec.Path)
+		}
+		paths[ec.Path] = true
+		switch ec.Squash {
+		case "":
+			ec.Squash = squashRoot
+		case squashNone, squashRoot, squashAll:
+		default:
+			return nil, fmt.Errorf("invalid squash %v of export %v", ec.Squash, ec.Path)
+		}
+		configs = append(configs, ec)
+	}
+	return
+}
+
+func parseClients(clients []string) (nets []*net.IPNet, err error) {
+	for _, client := range clients {
+		if !strings.Contains(client, "/") {
+			if strings.Contains(client, ":") {
+				client += "/128"
+			} else {
+				client += "/32"
+			}
+		}
+		var ipnet *net.IPNet
+		if _, ipnet, err = net.ParseCIDR(client); err != nil {
+			return
+		}
+		nets = append(nets, ipnet)
+	}
+	return
+}
+
+func (node *NfsNode) openVolume(ec *exportConfig) (vol *volume, err error) {
+	if vol = node.volumes[ec.Volume]; vol != nil {
+		return
+	}
+	vol = &volume{name: ec.Volume, streams: make(map[uint64]time.Time)}
+	if vol.mw, err = meta.NewMetaWrapper(&meta.MetaConfig{
+		Volume:        ec.Volume,
+		Owner:         ec.Owner,
+		Masters:       node.masters,
+		ValidateOwner: ec.Owner != "",
+	}); err != nil {
+		return nil, err
+	}
+	if vol.ec, err = stream.NewExtentClient(&stream.ExtentConfig{
+		Volume:            ec.Volume,
+		Masters:           node.masters,
+		OnAppendExtentKey: vol.mw.AppendExtentKey,
+		OnGetExtents:      vol.mw.GetExtents,
+		OnTruncate:        vol.mw.Truncate,
+	}); err != nil {
+		vol.mw.Close()
+		return nil, err
+	}
+	node.volumes[ec.Volume] = vol
+	return
+}
+
+func (node *NfsNode) newExport(ec *exportConfig) (exp *export, err error) {
+	exp = &export{
+		path:     ec.Path,
+		readOnly: ec.ReadOnly,
+		squash:   ec.Squash,
+		anonUid:  nobody,
+		anonGid:  nobody,
+	}
+	h := fnv.New64a()
+	h.Write([]byte(ec.Path))
+	exp.id = h.Sum64()
+	if ec.AnonUid != nil {
+		exp.anonUid = *ec.AnonUid
+	}
+	if ec.AnonGid != nil {
+		exp.anonGid = *ec.AnonGid
+	}
+	if exp.clients, err = parseClients(ec.Clients); err != nil {
+		return nil, fmt.Errorf("invalid clients of export %v: %v", ec.Path, err)
+	}
+	if exp.vol, err = node.openVolume(ec); err != nil {
+		return nil, fmt.Errorf("open volume %v of export %v: %v", ec.Volume, ec.Path, err)
+	}
+	if exp.rootIno, err = exp.vol.mw.GetRootIno(ec.SubDir); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// allowed tells if the given client can access the export.
+func (exp *export) allowed(ip net.IP) bool {
+	if len(exp.clients) == 0 {
+		return true
+	}
+	for _, ipnet := range exp.clients {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// squashCred maps the credential of a call by the squash option.
+func (exp *export) squashCred(cred rpcCred) rpcCred {
+	if cred.flavor != authSys || exp.squash == squashAll || (exp.squash == squashRoot && cred.uid == 0) {
+		return rpcCred{flavor: cred.flavor, uid: exp.anonUid, gid: exp.anonGid}
+	}
+	if exp.squash == squashRoot && cred.gid == 0 {
+		cred.gid = exp.anonGid
+	}
+	return cred
+}
+
+func (exp *export) fileHandle(ino uint64) []byte {
+	fh := make([]byte, fileHandleSize)
+	binary.BigEndian.PutUint64(fh, exp.id)
+	binary.BigEndian.PutUint64(fh[8:], ino)
+	return fh
+}
+
+// openStream opens the stream of the inode for reading or writing. The
+// streams are closed after idle for streamIdleTimeout, as NFS has no close.
+func (vol *volume) openStream(ino uint64) error {
+	vol.Lock()
+	defer vol.Unlock()
+	if _, ok := vol.streams[ino]; !ok {
+		if err := vol.ec.OpenStream(ino); err != nil {
+			return err
+		}
+	}
+	vol.streams[ino] = time.Now()
+	return nil
+}
+
+// fileSize returns the size of the inode in the stream, which is newer than
+// the size in the meta nodes until the buffered writes are flushed.
+func (vol *volume) fileSize(ino uint64) (size uint64, ok bool) {
+	vol.Lock()
+	_, ok = vol.streams[ino]
+	vol.Unlock()
+	if !ok {
+		return
+	}
+	s, _, valid := vol.ec.FileSize(ino)
+	return uint64(s), valid
+}
+
+// evictStream drops the stream of a removed inode.
+func (vol *volume) evictStream(ino uint64) {
+	vol.Lock()
+	_, ok := vol.streams[ino]
+	delete(vol.streams, ino)
+	vol.Unlock()
+	if ok {
+		vol.ec.CloseStream(ino)
+		vol.ec.EvictStream(ino)
+	}
+}
+
+func (vol *volume) closeIdleStreams(force bool) {
+	var idle []uint64
+	now := time.Now()
+	vol.Lock()
+	for ino, last := range vol.streams {
+		if force || now.Sub(last) > streamIdleTimeout {
+			idle = append(idle, ino)
+			delete(vol.streams, ino)
+		}
+	}
+	vol.Unlock()
+	for _, ino := range idle {
+		if err := vol.ec.CloseStream(ino); err != nil {
+			log.LogWarnf("closeIdleStreams: vol(%v) ino(%v) err(%v)", vol.name, ino, err)
+		}
+		vol.ec.EvictStream(ino)
+	}
+}
+
+func (vol *volume) close() {
+	vol.closeIdleStreams(true)
+	vol.ec.Close()
+	vol.mw.Close()
+}
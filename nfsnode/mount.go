@@ -0,0 +1,198 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	gopath "path"
+	"sort"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// MOUNT version 3 (RFC 1813, appendix I).
+const (
+	mountProgram = 100005
+	mountVersion = 3
+
+	mountProcNull    = 0
+	mountProcMnt     = 1
+	mountProcDump    = 2
+	mountProcUmnt    = 3
+	mountProcUmntall = 4
+	mountProcExport  = 5
+
+	mnt3OK        = 0
+	mnt3ErrNoent  = 2
+	mnt3ErrAcces  = 13
+	mnt3ErrNotdir = 20
+
+	maxDirPathLen = 1024
+)
+
+var mountProcNames = map[uint32]string{
+	mountProcNull:    "mount_null",
+	mountProcMnt:     "mount_mnt",
+	mountProcDump:    "mount_dump",
+	mountProcUmnt:    "mount_umnt",
+	mountProcUmntall: "mount_umntall",
+	mountProcExport:  "mount_export",
+}
+
+type mountV3 struct {
+	node *NfsNode
+}
+
+func (p *mountV3) versions() (low, high uint32) {
+	return mountVersion, mountVersion
+}
+
+func (p *mountV3) procName(proc uint32) string {
+	return mountProcNames[proc]
+}
+
+func (p *mountV3) serve(call *rpcCallMsg, res *xdrEncoder) uint32 {
+	switch call.proc {
+	case mountProcMnt:
+		dirpath := call.args.string(maxDirPathLen)
+		if call.args.err != nil {
+			return acceptGarbageArgs
+		}
+		p.mnt(call, dirpath, res)
+	case mountProcDump:
+		// the mounts are not tracked, as the NFS calls are stateless
+		res.bool(false)
+	case mountProcUmnt:
+		call.args.string(maxDirPathLen)
+		if call.args.err != nil {
+			return acceptGarbageArgs
+		}
+	case mountProcExport:
+		p.exports(res)
+	}
+	return acceptSuccess
+}
+
+// mnt returns the file handle of an export, or of a directory in an export
+// if the path is below the path of an export.
+func (p *mountV3) mnt(call *rpcCallMsg, dirpath string, res *xdrEncoder) {
+	dirpath = gopath.Clean("/" + dirpath)
+	var exp *export
+	for _, e := range p.node.exports {
+		if (dirpath == e.path || strings.HasPrefix(dirpath, e.path+"/")) && (exp == nil || len(e.path) > len(exp.path)) {
+			exp = e
+		}
+	}
+	if exp == nil {
+		log.LogWarnf("mnt: no export of path(%v) client(%v)", dirpath, call.conn.RemoteAddr())
+		res.uint32(mnt3ErrNoent)
+		return
+	}
+	if !exp.allowed(call.conn.remote) {
+		log.LogWarnf("mnt: client(%v) is not allowed to mount export(%v)", call.conn.RemoteAddr(), exp.path)
+		res.uint32(mnt3ErrAcces)
+		return
+	}
+	ino := exp.rootIno
+	for _, name := range strings.Split(strings.TrimPrefix(dirpath, exp.path), "/") {
+		if name == "" {
+			continue
+		}
+		child, mode, err := exp.vol.mw.Lookup_ll(ino, name)
+		if err != nil {
+			res.uint32(errorStatus(err))
+			return
+		}
+		if !proto.IsDir(mode) {
+			res.uint32(mnt3ErrNotdir)
+			return
+		}
+		ino = child
+	}
+	log.LogInfof("mnt: client(%v) mounted path(%v) of export(%v)", call.conn.RemoteAddr(), dirpath, exp.path)
+	res.uint32(mnt3OK)
+	res.opaque(exp.fileHandle(ino))
+	res.uint32(1)
+	res.uint32(authSys)
+}
+
+func (p *mountV3) exports(res *xdrEncoder) {
+	paths := make([]string, 0, len(p.node.exports))
+	for path := range p.node.exports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		exp := p.node.exports[path]
+		res.bool(true)
+		res.string(path)
+		for _, client := range exp.clients {
+			res.bool(true)
+			res.string(client.String())
+		}
+		res.bool(false)
+	}
+	res.bool(false)
+}
+
+// A minimal portmapper (RFC 1833, version 2) telling the port of the
+// programs above, for the clients which cannot be given the ports.
+const (
+	portmapProgram = 100000
+	portmapVersion = 2
+
+	portmapProcNull    = 0
+	portmapProcGetport = 3
+
+	ipProtoTCP = 6
+)
+
+var portmapProcNames = map[uint32]string{
+	portmapProcNull:    "portmap_null",
+	portmapProcGetport: "portmap_getport",
+}
+
+type portmapV2 struct {
+	node *NfsNode
+}
+
+func (p *portmapV2) versions() (low, high uint32) {
+	return portmapVersion, portmapVersion
+}
+
+func (p *portmapV2) procName(proc uint32) string {
+	return portmapProcNames[proc]
+}
+
+func (p *portmapV2) serve(call *rpcCallMsg, res *xdrEncoder) uint32 {
+	if call.proc != portmapProcGetport {
+		return acceptSuccess
+	}
+	d := call.args
+	prog := d.uint32()
+	vers := d.uint32()
+	prot := d.uint32()
+	d.uint32()
+	if d.err != nil {
+		return acceptGarbageArgs
+	}
+	var port uint32
+	if prot == ipProtoTCP && ((prog == nfsProgram && vers == nfsVersion) || (prog == mountProgram && vers == mountVersion)) {
+		port = uint32(p.node.port)
+	}
+	res.uint32(port)
+	return acceptSuccess
+}
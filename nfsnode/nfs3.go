@@ -0,0 +1,1186 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// NFS version 3 (RFC 1813).
+const (
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	nfsProcNull        = 0
+	nfsProcGetattr     = 1
+	nfsProcSetattr     = 2
+	nfsProcLookup      = 3
+	nfsProcAccess      = 4
+	nfsProcReadlink    = 5
+	nfsProcRead        = 6
+	nfsProcWrite       = 7
+	nfsProcCreate      = 8
+	nfsProcMkdir       = 9
+	nfsProcSymlink     = 10
+	nfsProcMknod       = 11
+	nfsProcRemove      = 12
+	nfsProcRmdir       = 13
+	nfsProcRename      = 14
+	nfsProcLink        = 15
+	nfsProcReaddir     = 16
+	nfsProcReaddirplus = 17
+	nfsProcFsstat      = 18
+	nfsProcFsinfo      = 19
+	nfsProcPathconf    = 20
+	nfsProcCommit      = 21
+)
+
+// The status of the results. Most of them are the same as the errnos.
+const (
+	nfs3OK             = 0
+	nfs3ErrPerm        = 1
+	nfs3ErrNoent       = 2
+	nfs3ErrIO          = 5
+	nfs3ErrAcces       = 13
+	nfs3ErrExist       = 17
+	nfs3ErrXdev        = 18
+	nfs3ErrNotdir      = 20
+	nfs3ErrIsdir       = 21
+	nfs3ErrInval       = 22
+	nfs3ErrFbig        = 27
+	nfs3ErrNospc       = 28
+	nfs3ErrRofs        = 30
+	nfs3ErrMlink       = 31
+	nfs3ErrNametoolong = 63
+	nfs3ErrNotempty    = 66
+	nfs3ErrDquot       = 69
+	nfs3ErrStale       = 70
+	nfs3ErrBadhandle   = 10001
+	nfs3ErrBadCookie   = 10003
+	nfs3ErrNotsupp     = 10004
+	nfs3ErrToosmall    = 10005
+	nfs3ErrServerfault = 10006
+)
+
+const (
+	nf3Reg  = 1
+	nf3Dir  = 2
+	nf3Blk  = 3
+	nf3Chr  = 4
+	nf3Lnk  = 5
+	nf3Sock = 6
+	nf3Fifo = 7
+
+	access3Read    = 0x01
+	access3Lookup  = 0x02
+	access3Modify  = 0x04
+	access3Extend  = 0x08
+	access3Delete  = 0x10
+	access3Execute = 0x20
+
+	unstable = 0
+	fileSync = 2
+
+	createUnchecked = 0
+	createGuarded   = 1
+	createExclusive = 2
+
+	timeDontChange  = 0
+	timeSetToServer = 1
+	timeSetToClient = 2
+
+	fsf3Link        = 0x01
+	fsf3Symlink     = 0x02
+	fsf3Homogeneous = 0x08
+	fsf3CanSetTime  = 0x10
+
+	maxFileHandleSize = 64
+	maxNameLen        = 255
+	maxPathLen        = 4096
+	maxIOSize         = 1 << 20
+	dirPrefSize       = 64 * 1024
+)
+
+const (
+	permRead  = 4
+	permWrite = 2
+	permExec  = 1
+)
+
+var nfsProcNames = map[uint32]string{
+	nfsProcNull:        "nfs_null",
+	nfsProcGetattr:     "nfs_getattr",
+	nfsProcSetattr:     "nfs_setattr",
+	nfsProcLookup:      "nfs_lookup",
+	nfsProcAccess:      "nfs_access",
+	nfsProcReadlink:    "nfs_readlink",
+	nfsProcRead:        "nfs_read",
+	nfsProcWrite:       "nfs_write",
+	nfsProcCreate:      "nfs_create",
+	nfsProcMkdir:       "nfs_mkdir",
+	nfsProcSymlink:     "nfs_symlink",
+	nfsProcMknod:       "nfs_mknod",
+	nfsProcRemove:      "nfs_remove",
+	nfsProcRmdir:       "nfs_rmdir",
+	nfsProcRename:      "nfs_rename",
+	nfsProcLink:        "nfs_link",
+	nfsProcReaddir:     "nfs_readdir",
+	nfsProcReaddirplus: "nfs_readdirplus",
+	nfsProcFsstat:      "nfs_fsstat",
+	nfsProcFsinfo:      "nfs_fsinfo",
+	nfsProcPathconf:    "nfs_pathconf",
+	nfsProcCommit:      "nfs_commit",
+}
+
+// nfsOp is the context of an NFS call on a file handle of an export.
+type nfsOp struct {
+	node *NfsNode
+	call *rpcCallMsg
+	res  *xdrEncoder
+	exp  *export
+	vol  *volume
+	cred rpcCred
+}
+
+type nfsV3 struct {
+	node *NfsNode
+}
+
+func (p *nfsV3) versions() (low, high uint32) {
+	return nfsVersion, nfsVersion
+}
+
+func (p *nfsV3) procName(proc uint32) string {
+	return nfsProcNames[proc]
+}
+
+func (p *nfsV3) serve(call *rpcCallMsg, res *xdrEncoder) uint32 {
+	op := &nfsOp{node: p.node, call: call, res: res}
+	switch call.proc {
+	case nfsProcNull:
+		return acceptSuccess
+	case nfsProcGetattr:
+		op.getattr()
+	case nfsProcSetattr:
+		op.setattr()
+	case nfsProcLookup:
+		op.lookup()
+	case nfsProcAccess:
+		op.access()
+	case nfsProcReadlink:
+		op.readlink()
+	case nfsProcRead:
+		op.read()
+	case nfsProcWrite:
+		op.write()
+	case nfsProcCreate:
+		op.create(nfsProcCreate)
+	case nfsProcMkdir:
+		op.create(nfsProcMkdir)
+	case nfsProcSymlink:
+		op.create(nfsProcSymlink)
+	case nfsProcMknod:
+		op.mknod()
+	case nfsProcRemove:
+		op.remove(false)
+	case nfsProcRmdir:
+		op.remove(true)
+	case nfsProcRename:
+		op.rename()
+	case nfsProcLink:
+		op.link()
+	case nfsProcReaddir:
+		op.readdir(false)
+	case nfsProcReaddirplus:
+		op.readdir(true)
+	case nfsProcFsstat:
+		op.fsstat()
+	case nfsProcFsinfo:
+		op.fsinfo()
+	case nfsProcPathconf:
+		op.pathconf()
+	case nfsProcCommit:
+		op.commit()
+	}
+	if call.args.err != nil {
+		return acceptGarbageArgs
+	}
+	return acceptSuccess
+}
+
+// handle decodes a file handle, and binds the op to its export.
+func (op *nfsOp) handle() (ino uint64, status uint32) {
+	fh := op.call.args.opaque(maxFileHandleSize)
+	if op.call.args.err != nil {
+		return 0, nfs3ErrServerfault
+	}
+	if len(fh) != fileHandleSize {
+		return 0, nfs3ErrBadhandle
+	}
+	exp := op.node.exportByID(binary.BigEndian.Uint64(fh))
+	if exp == nil {
+		return 0, nfs3ErrStale
+	}
+	if !exp.allowed(op.call.conn.remote) {
+		return 0, nfs3ErrAcces
+	}
+	if op.exp != nil && op.exp != exp {
+		return 0, nfs3ErrXdev
+	}
+	op.exp, op.vol = exp, exp.vol
+	op.cred = exp.squashCred(op.call.cred)
+	return binary.BigEndian.Uint64(fh[8:]), nfs3OK
+}
+
+func (op *nfsOp) inodeGet(ino uint64) (info *proto.InodeInfo, status uint32) {
+	info, err := op.vol.mw.InodeGet_ll(ino)
+	if err != nil {
+		if err == syscall.ENOENT {
+			return nil, nfs3ErrStale
+		}
+		return nil, errorStatus(err)
+	}
+	if size, ok := op.vol.fileSize(ino); ok && size > info.Size {
+		info.Size = size
+	}
+	return info, nfs3OK
+}
+
+func (op *nfsOp) writable() uint32 {
+	if op.exp.readOnly {
+		return nfs3ErrRofs
+	}
+	return nfs3OK
+}
+
+func (op *nfsOp) fattr(info *proto.InodeInfo) {
+	res := op.res
+	mode := proto.OsMode(info.Mode)
+	var ftype uint32
+	switch {
+	case mode.IsDir():
+		ftype = nf3Dir
+	case mode&os.ModeSymlink != 0:
+		ftype = nf3Lnk
+	case mode&os.ModeNamedPipe != 0:
+		ftype = nf3Fifo
+	case mode&os.ModeSocket != 0:
+		ftype = nf3Sock
+	case mode&os.ModeCharDevice != 0:
+		ftype = nf3Chr
+	case mode&os.ModeDevice != 0:
+		ftype = nf3Blk
+	default:
+		ftype = nf3Reg
+	}
+	res.uint32(ftype)
+	res.uint32(unixMode(mode))
+	res.uint32(info.Nlink)
+	res.uint32(info.Uid)
+	res.uint32(info.Gid)
+	res.uint64(info.Size)
+	res.uint64((info.Size + 4095) &^ 4095)
+	res.uint32(0)
+	res.uint32(0)
+	res.uint64(op.exp.id)
+	res.uint64(info.Inode)
+	nfsTime(res, info.AccessTime)
+	nfsTime(res, info.ModifyTime)
+	nfsTime(res, info.CreateTime)
+}
+
+func (op *nfsOp) postOpAttr(info *proto.InodeInfo) {
+	op.res.bool(info != nil)
+	if info != nil {
+		op.fattr(info)
+	}
+}
+
+// postOpAttrIno looks up and encodes the attributes of the inode, or
+// nothing if they cannot be got.
+func (op *nfsOp) postOpAttrIno(ino uint64) {
+	if op.vol == nil {
+		op.res.bool(false)
+		return
+	}
+	info, _ := op.inodeGet(ino)
+	op.postOpAttr(info)
+}
+
+// wccData encodes the attributes of a directory before and after the op.
+func (op *nfsOp) wccData(before *proto.InodeInfo, ino uint64) {
+	op.res.bool(before != nil)
+	if before != nil {
+		op.res.uint64(before.Size)
+		nfsTime(op.res, before.ModifyTime)
+		nfsTime(op.res, before.CreateTime)
+	}
+	op.postOpAttrIno(ino)
+}
+
+func (op *nfsOp) getattr() {
+	ino, status := op.handle()
+	var info *proto.InodeInfo
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+	}
+	op.res.uint32(status)
+	if status == nfs3OK {
+		op.fattr(info)
+	}
+}
+
+type sattr3 struct {
+	setMode, setUid, setGid, setSize bool
+	mode, uid, gid                   uint32
+	size                             uint64
+	atime, mtime                     uint32
+	atimeVal, mtimeVal               time.Time
+}
+
+func decodeSattr(d *xdrDecoder) (s *sattr3) {
+	s = &sattr3{}
+	if s.setMode = d.bool(); s.setMode {
+		s.mode = d.uint32()
+	}
+	if s.setUid = d.bool(); s.setUid {
+		s.uid = d.uint32()
+	}
+	if s.setGid = d.bool(); s.setGid {
+		s.gid = d.uint32()
+	}
+	if s.setSize = d.bool(); s.setSize {
+		s.size = d.uint64()
+	}
+	if s.atime = d.uint32(); s.atime == timeSetToClient {
+		s.atimeVal = time.Unix(int64(d.uint32()), int64(d.uint32()))
+	}
+	if s.mtime = d.uint32(); s.mtime == timeSetToClient {
+		s.mtimeVal = time.Unix(int64(d.uint32()), int64(d.uint32()))
+	}
+	return
+}
+
+func (op *nfsOp) setattr() {
+	d := op.call.args
+	ino, status := op.handle()
+	sa := decodeSattr(d)
+	if d.bool() {
+		d.uint32()
+		d.uint32()
+	}
+	if d.err != nil {
+		return
+	}
+	var before *proto.InodeInfo
+	if status == nfs3OK {
+		status = op.writable()
+	}
+	if status == nfs3OK {
+		before, status = op.inodeGet(ino)
+	}
+	if status == nfs3OK {
+		status = op.doSetattr(before, sa)
+	}
+	op.res.uint32(status)
+	op.wccData(before, ino)
+}
+
+func (op *nfsOp) doSetattr(info *proto.InodeInfo, sa *sattr3) uint32 {
+	ino := info.Inode
+	cred := op.cred
+	owner := cred.uid == 0 || cred.uid == info.Uid
+	if sa.setSize {
+		if proto.IsDir(info.Mode) {
+			return nfs3ErrIsdir
+		}
+		if !owner && !permitted(info, cred, permWrite) {
+			return nfs3ErrAcces
+		}
+		if err := op.vol.openStream(ino); err != nil {
+			return errorStatus(err)
+		}
+		if err := op.vol.ec.Flush(ino); err != nil {
+			return errorStatus(err)
+		}
+		if err := op.vol.ec.Truncate(op.vol.mw, 0, ino, int(sa.size)); err != nil {
+			log.LogErrorf("setattr: truncate ino(%v) size(%v) err(%v)", ino, sa.size, err)
+			return errorStatus(err)
+		}
+		op.vol.ec.RefreshExtentsCache(ino)
+	}
+
+	var valid uint32
+	if sa.setMode {
+		if !owner {
+			return nfs3ErrPerm
+		}
+		info.Mode = proto.Mode(proto.OsMode(info.Mode)&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | osMode(sa.mode))
+		valid |= proto.AttrMode
+	}
+	if sa.setUid && sa.uid != info.Uid {
+		if cred.uid != 0 {
+			return nfs3ErrPerm
+		}
+		info.Uid = sa.uid
+		valid |= proto.AttrUid
+	}
+	if sa.setGid && sa.gid != info.Gid {
+		if cred.uid != 0 && !(cred.uid == info.Uid && inGroup(cred, sa.gid)) {
+			return nfs3ErrPerm
+		}
+		info.Gid = sa.gid
+		valid |= proto.AttrGid
+	}
+	now := time.Now()
+	for _, t := range []struct {
+		how   uint32
+		value time.Time
+		dst   *time.Time
+		valid uint32
+	}{
+		{sa.atime, sa.atimeVal, &info.AccessTime, proto.AttrAccessTime},
+		{sa.mtime, sa.mtimeVal, &info.ModifyTime, proto.AttrModifyTime},
+	} {
+		switch t.how {
+		case timeSetToServer:
+			if !owner && !permitted(info, cred, permWrite) {
+				return nfs3ErrAcces
+			}
+			*t.dst = now
+		case timeSetToClient:
+			if !owner {
+				return nfs3ErrPerm
+			}
+			*t.dst = t.value
+		default:
+			continue
+		}
+		valid |= t.valid
+	}
+	if valid == 0 {
+		return nfs3OK
+	}
+	if err := op.vol.mw.Setattr(ino, valid, info.Mode, info.Uid, info.Gid, info.AccessTime.Unix(), info.ModifyTime.Unix()); err != nil {
+		log.LogErrorf("setattr: ino(%v) valid(%v) err(%v)", ino, valid, err)
+		return errorStatus(err)
+	}
+	return nfs3OK
+}
+
+// dirop decodes the directory handle and the name of an op in a directory.
+func (op *nfsOp) dirop() (dir uint64, name string, status uint32) {
+	dir, status = op.handle()
+	name = op.call.args.string(maxPathLen)
+	if status != nfs3OK {
+		return
+	}
+	if len(name) > maxNameLen {
+		status = nfs3ErrNametoolong
+	} else if name == "" {
+		status = nfs3ErrInval
+	}
+	return
+}
+
+// lookupName looks up a name in a directory, with "." and "..". The
+// parent of a directory is not known, ".." stays at the root of the export.
+func (op *nfsOp) lookupName(dir uint64, name string) (uint64, uint32) {
+	switch name {
+	case ".":
+		return dir, nfs3OK
+	case "..":
+		if dir == op.exp.rootIno {
+			return dir, nfs3OK
+		}
+		return 0, nfs3ErrNotsupp
+	}
+	ino, _, err := op.vol.mw.Lookup_ll(dir, name)
+	if err != nil {
+		return 0, errorStatus(err)
+	}
+	return ino, nfs3OK
+}
+
+func (op *nfsOp) lookup() {
+	dir, name, status := op.dirop()
+	if op.call.args.err != nil {
+		return
+	}
+	var dirInfo, info *proto.InodeInfo
+	var ino uint64
+	if status == nfs3OK {
+		dirInfo, status = op.inodeGet(dir)
+	}
+	if status == nfs3OK {
+		if !proto.IsDir(dirInfo.Mode) {
+			status = nfs3ErrNotdir
+		} else if !permitted(dirInfo, op.cred, permExec) {
+			status = nfs3ErrAcces
+		}
+	}
+	if status == nfs3OK {
+		ino, status = op.lookupName(dir, name)
+	}
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+		if status == nfs3ErrStale {
+			status = nfs3ErrNoent
+		}
+	}
+	op.res.uint32(status)
+	if status == nfs3OK {
+		op.res.opaque(op.exp.fileHandle(ino))
+		op.postOpAttr(info)
+	}
+	op.postOpAttr(dirInfo)
+}
+
+func (op *nfsOp) access() {
+	ino, status := op.handle()
+	want := op.call.args.uint32()
+	if op.call.args.err != nil {
+		return
+	}
+	var info *proto.InodeInfo
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+	}
+	op.res.uint32(status)
+	op.postOpAttr(info)
+	if status != nfs3OK {
+		return
+	}
+	var granted uint32
+	if permitted(info, op.cred, permRead) {
+		granted |= access3Read
+	}
+	if !op.exp.readOnly && permitted(info, op.cred, permWrite) {
+		granted |= access3Modify | access3Extend
+		if proto.IsDir(info.Mode) {
+			granted |= access3Delete
+		}
+	}
+	if permitted(info, op.cred, permExec) {
+		if proto.IsDir(info.Mode) {
+			granted |= access3Lookup
+		} else {
+			granted |= access3Execute
+		}
+	}
+	op.res.uint32(want & granted)
+}
+
+func (op *nfsOp) readlink() {
+	ino, status := op.handle()
+	var info *proto.InodeInfo
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+	}
+	if status == nfs3OK && !proto.IsSymlink(info.Mode) {
+		status = nfs3ErrInval
+	}
+	op.res.uint32(status)
+	op.postOpAttr(info)
+	if status == nfs3OK {
+		op.res.string(string(info.Target))
+	}
+}
+
+func (op *nfsOp) read() {
+	d := op.call.args
+	ino, status := op.handle()
+	offset := d.uint64()
+	count := d.uint32()
+	if d.err != nil {
+		return
+	}
+	if count > maxIOSize {
+		count = maxIOSize
+	}
+	var info *proto.InodeInfo
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+	}
+	if status == nfs3OK {
+		if proto.IsDir(info.Mode) {
+			status = nfs3ErrIsdir
+		} else if !proto.IsRegular(info.Mode) {
+			status = nfs3ErrInval
+		} else if op.cred.uid != info.Uid && !permitted(info, op.cred, permRead) && !permitted(info, op.cred, permExec) {
+			status = nfs3ErrAcces
+		}
+	}
+	var data []byte
+	if status == nfs3OK && offset < info.Size {
+		if uint64(count) > info.Size-offset {
+			count = uint32(info.Size - offset)
+		}
+		data = make([]byte, count)
+		if err := op.vol.openStream(ino); err != nil {
+			status = errorStatus(err)
+		} else {
+			n, err := op.vol.ec.Read(ino, data, int(offset), int(count))
+			if err != nil && err != io.EOF {
+				log.LogErrorf("read: ino(%v) offset(%v) count(%v) err(%v)", ino, offset, count, err)
+				status = errorStatus(err)
+			}
+			data = data[:n]
+		}
+	}
+	op.res.uint32(status)
+	op.postOpAttr(info)
+	if status == nfs3OK {
+		op.res.uint32(uint32(len(data)))
+		op.res.bool(offset+uint64(len(data)) >= info.Size)
+		op.res.opaque(data)
+	}
+}
+
+func (op *nfsOp) write() {
+	d := op.call.args
+	ino, status := op.handle()
+	offset := d.uint64()
+	d.uint32()
+	stable := d.uint32()
+	data := d.opaque(maxIOSize)
+	if d.err != nil {
+		return
+	}
+	var before *proto.InodeInfo
+	if status == nfs3OK {
+		status = op.writable()
+	}
+	if status == nfs3OK {
+		before, status = op.inodeGet(ino)
+	}
+	if status == nfs3OK {
+		if proto.IsDir(before.Mode) {
+			status = nfs3ErrIsdir
+		} else if !proto.IsRegular(before.Mode) {
+			status = nfs3ErrInval
+		} else if op.cred.uid != before.Uid && !permitted(before, op.cred, permWrite) {
+			status = nfs3ErrAcces
+		}
+	}
+	if status == nfs3OK {
+		status = op.doWrite(ino, offset, data, stable != unstable)
+	}
+	op.res.uint32(status)
+	op.wccData(before, ino)
+	if status == nfs3OK {
+		op.res.uint32(uint32(len(data)))
+		if stable != unstable {
+			op.res.uint32(fileSync)
+		} else {
+			op.res.uint32(unstable)
+		}
+		op.res.fixed(op.node.writeVerf[:])
+	}
+}
+
+func (op *nfsOp) doWrite(ino, offset uint64, data []byte, sync bool) uint32 {
+	if err := op.vol.openStream(ino); err != nil {
+		return errorStatus(err)
+	}
+	if _, err := op.vol.ec.Write(ino, int(offset), data, 0); err != nil {
+		log.LogErrorf("write: ino(%v) offset(%v) size(%v) err(%v)", ino, offset, len(data), err)
+		return errorStatus(err)
+	}
+	if sync {
+		if err := op.vol.ec.Flush(ino); err != nil {
+			log.LogErrorf("write: flush ino(%v) err(%v)", ino, err)
+			return errorStatus(err)
+		}
+	}
+	return nfs3OK
+}
+
+func (op *nfsOp) commit() {
+	d := op.call.args
+	ino, status := op.handle()
+	d.uint64()
+	d.uint32()
+	if d.err != nil {
+		return
+	}
+	var before *proto.InodeInfo
+	if status == nfs3OK {
+		before, status = op.inodeGet(ino)
+	}
+	if status == nfs3OK && proto.IsRegular(before.Mode) {
+		if err := op.vol.openStream(ino); err != nil {
+			status = errorStatus(err)
+		} else if err = op.vol.ec.Flush(ino); err != nil {
+			log.LogErrorf("commit: flush ino(%v) err(%v)", ino, err)
+			status = errorStatus(err)
+		}
+	}
+	op.res.uint32(status)
+	op.wccData(before, ino)
+	if status == nfs3OK {
+		op.res.fixed(op.node.writeVerf[:])
+	}
+}
+
+// checkDir checks that the inode is a directory in which the caller can
+// create or remove entries.
+func (op *nfsOp) checkDir(dir uint64) (info *proto.InodeInfo, status uint32) {
+	if status = op.writable(); status != nfs3OK {
+		return
+	}
+	if info, status = op.inodeGet(dir); status != nfs3OK {
+		return
+	}
+	if !proto.IsDir(info.Mode) {
+		return info, nfs3ErrNotdir
+	}
+	if !permitted(info, op.cred, permWrite|permExec) {
+		return info, nfs3ErrAcces
+	}
+	return
+}
+
+func (op *nfsOp) create(proc uint32) {
+	d := op.call.args
+	dir, name, status := op.dirop()
+	var (
+		sa     *sattr3
+		how    uint32
+		target string
+	)
+	switch proc {
+	case nfsProcCreate:
+		if how = d.uint32(); how == createExclusive {
+			d.fixed(8)
+			sa = &sattr3{}
+		} else {
+			sa = decodeSattr(d)
+		}
+	case nfsProcMkdir:
+		sa = decodeSattr(d)
+	case nfsProcSymlink:
+		sa = decodeSattr(d)
+		target = d.string(maxPathLen)
+	}
+	if d.err != nil {
+		return
+	}
+
+	var before, info *proto.InodeInfo
+	if status == nfs3OK {
+		before, status = op.checkDir(dir)
+	}
+	if status == nfs3OK {
+		mode := os.FileMode(0644)
+		if sa.setMode {
+			mode = osMode(sa.mode)
+		}
+		var err error
+		switch proc {
+		case nfsProcCreate:
+			info, err = op.vol.mw.Create_ll(dir, name, proto.Mode(mode), op.cred.uid, op.cred.gid, nil)
+			if err == syscall.EEXIST && how == createUnchecked {
+				if ino, _, lerr := op.vol.mw.Lookup_ll(dir, name); lerr == nil {
+					if info, status = op.inodeGet(ino); status == nfs3OK && sa.setSize {
+						sa.setMode = false
+						status = op.doSetattr(info, sa)
+					}
+					err = nil
+				}
+			}
+		case nfsProcMkdir:
+			if !sa.setMode {
+				mode = 0755
+			}
+			info, err = op.vol.mw.Create_ll(dir, name, proto.Mode(os.ModeDir|mode), op.cred.uid, op.cred.gid, nil)
+		case nfsProcSymlink:
+			info, err = op.vol.mw.Create_ll(dir, name, proto.Mode(os.ModeSymlink|os.ModePerm), op.cred.uid, op.cred.gid, []byte(target))
+		}
+		if err != nil {
+			log.LogErrorf("create: parent(%v) name(%v) err(%v)", dir, name, err)
+			status = errorStatus(err)
+		}
+	}
+	op.res.uint32(status)
+	if status == nfs3OK {
+		op.res.bool(true)
+		op.res.opaque(op.exp.fileHandle(info.Inode))
+		op.postOpAttr(info)
+	}
+	op.wccData(before, dir)
+}
+
+func (op *nfsOp) mknod() {
+	dir, _, status := op.dirop()
+	if op.call.args.err != nil {
+		return
+	}
+	if status == nfs3OK {
+		status = nfs3ErrNotsupp
+	}
+	op.res.uint32(status)
+	op.wccData(nil, dir)
+}
+
+func (op *nfsOp) remove(isDir bool) {
+	dir, name, status := op.dirop()
+	if op.call.args.err != nil {
+		return
+	}
+	var before *proto.InodeInfo
+	if status == nfs3OK {
+		before, status = op.checkDir(dir)
+	}
+	if status == nfs3OK && (name == "." || name == "..") {
+		status = nfs3ErrInval
+	}
+	if status == nfs3OK {
+		status = op.checkSticky(before, dir, name)
+	}
+	if status == nfs3OK {
+		info, err := op.vol.mw.Delete_ll(dir, name, isDir)
+		if err != nil {
+			status = errorStatus(err)
+		} else if info != nil && info.Nlink == 0 && !proto.IsDir(info.Mode) {
+			op.vol.evictStream(info.Inode)
+			if err = op.vol.mw.Evict(info.Inode); err != nil {
+				log.LogWarnf("remove: evict ino(%v) err(%v)", info.Inode, err)
+			}
+		}
+	}
+	op.res.uint32(status)
+	op.wccData(before, dir)
+}
+
+// checkSticky checks that the caller can remove or rename the entry of a
+// directory with the sticky bit.
+func (op *nfsOp) checkSticky(dirInfo *proto.InodeInfo, dir uint64, name string) uint32 {
+	if proto.OsMode(dirInfo.Mode)&os.ModeSticky == 0 || op.cred.uid == 0 || op.cred.uid == dirInfo.Uid {
+		return nfs3OK
+	}
+	ino, _, err := op.vol.mw.Lookup_ll(dir, name)
+	if err != nil {
+		return errorStatus(err)
+	}
+	info, status := op.inodeGet(ino)
+	if status != nfs3OK {
+		return status
+	}
+	if info.Uid != op.cred.uid {
+		return nfs3ErrAcces
+	}
+	return nfs3OK
+}
+
+func (op *nfsOp) rename() {
+	fromDir, fromName, status := op.dirop()
+	toDir, toName, toStatus := op.dirop()
+	if op.call.args.err != nil {
+		return
+	}
+	if status == nfs3OK {
+		status = toStatus
+	}
+	var fromBefore, toBefore *proto.InodeInfo
+	if status == nfs3OK {
+		fromBefore, status = op.checkDir(fromDir)
+	}
+	if status == nfs3OK {
+		toBefore, status = op.checkDir(toDir)
+	}
+	if status == nfs3OK {
+		for _, name := range []string{fromName, toName} {
+			if name == "." || name == ".." {
+				status = nfs3ErrInval
+			}
+		}
+	}
+	if status == nfs3OK {
+		status = op.checkSticky(fromBefore, fromDir, fromName)
+	}
+	if status == nfs3OK {
+		if err := op.vol.mw.Rename_ll(fromDir, fromName, toDir, toName); err != nil {
+			log.LogErrorf("rename: src(%v/%v) dst(%v/%v) err(%v)", fromDir, fromName, toDir, toName, err)
+			status = errorStatus(err)
+		}
+	}
+	op.res.uint32(status)
+	op.wccData(fromBefore, fromDir)
+	op.wccData(toBefore, toDir)
+}
+
+func (op *nfsOp) link() {
+	ino, status := op.handle()
+	dir, name, dirStatus := op.dirop()
+	if op.call.args.err != nil {
+		return
+	}
+	if status == nfs3OK {
+		status = dirStatus
+	}
+	var before *proto.InodeInfo
+	if status == nfs3OK {
+		before, status = op.checkDir(dir)
+	}
+	if status == nfs3OK {
+		if _, err := op.vol.mw.Link(dir, name, ino); err != nil {
+			status = errorStatus(err)
+		}
+	}
+	op.res.uint32(status)
+	op.postOpAttrIno(ino)
+	op.wccData(before, dir)
+}
+
+func (op *nfsOp) readdir(plus bool) {
+	d := op.call.args
+	dir, status := op.handle()
+	cookie := d.uint64()
+	verf := d.fixed(8)
+	count := d.uint32()
+	if plus {
+		d.uint32()
+		count = d.uint32()
+	}
+	if d.err != nil {
+		return
+	}
+	var dirInfo *proto.InodeInfo
+	if status == nfs3OK {
+		dirInfo, status = op.inodeGet(dir)
+	}
+	if status == nfs3OK {
+		if !proto.IsDir(dirInfo.Mode) {
+			status = nfs3ErrNotdir
+		} else if !permitted(dirInfo, op.cred, permRead) {
+			status = nfs3ErrAcces
+		}
+	}
+	var listing *dirListing
+	if status == nfs3OK {
+		listing, status = op.node.dirCache.get(op.vol, dir, cookie, binary.BigEndian.Uint64(verf))
+	}
+	statusPos := len(op.res.buf)
+	op.res.uint32(status)
+	start := len(op.res.buf)
+	op.postOpAttr(dirInfo)
+	if status != nfs3OK {
+		return
+	}
+	var verfBuf [8]byte
+	binary.BigEndian.PutUint64(verfBuf[:], listing.verf)
+	op.res.fixed(verfBuf[:])
+
+	var infos map[uint64]*proto.InodeInfo
+	if plus {
+		infos = listing.inodes(op.vol, cookie, count)
+	}
+	eof := true
+	for i := cookie; i < uint64(len(listing.entries)); i++ {
+		entry := listing.entries[i]
+		mark := len(op.res.buf)
+		op.res.bool(true)
+		op.res.uint64(entry.Inode)
+		op.res.string(entry.Name)
+		op.res.uint64(i + 1)
+		if plus {
+			info := infos[entry.Inode]
+			if entry.Name == "." {
+				info = dirInfo
+			}
+			op.postOpAttr(info)
+			op.res.bool(entry.Name != "..")
+			if entry.Name != ".." {
+				op.res.opaque(op.exp.fileHandle(entry.Inode))
+			}
+		}
+		// the entries and the end of the list must fit in count
+		if len(op.res.buf)-start+8 > int(count) {
+			if i == cookie {
+				op.res.buf = op.res.buf[:statusPos]
+				op.res.uint32(nfs3ErrToosmall)
+				op.postOpAttr(dirInfo)
+				return
+			}
+			op.res.buf = op.res.buf[:mark]
+			eof = false
+			break
+		}
+	}
+	op.res.bool(false)
+	op.res.bool(eof)
+}
+
+func (op *nfsOp) fsstat() {
+	ino, status := op.handle()
+	var info *proto.InodeInfo
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+	}
+	op.res.uint32(status)
+	op.postOpAttr(info)
+	if status != nfs3OK {
+		return
+	}
+	total, used, inodes := op.vol.mw.Statfs()
+	free := uint64(0)
+	if total > used {
+		free = total - used
+	}
+	const maxInodes = 1 << 50
+	op.res.uint64(total)
+	op.res.uint64(free)
+	op.res.uint64(free)
+	op.res.uint64(maxInodes)
+	op.res.uint64(maxInodes - inodes)
+	op.res.uint64(maxInodes - inodes)
+	op.res.uint32(0)
+}
+
+func (op *nfsOp) fsinfo() {
+	ino, status := op.handle()
+	var info *proto.InodeInfo
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+	}
+	op.res.uint32(status)
+	op.postOpAttr(info)
+	if status != nfs3OK {
+		return
+	}
+	op.res.uint32(maxIOSize)
+	op.res.uint32(maxIOSize)
+	op.res.uint32(4096)
+	op.res.uint32(maxIOSize)
+	op.res.uint32(maxIOSize)
+	op.res.uint32(4096)
+	op.res.uint32(dirPrefSize)
+	op.res.uint64(1<<63 - 1)
+	op.res.uint32(1)
+	op.res.uint32(0)
+	op.res.uint32(fsf3Link | fsf3Symlink | fsf3Homogeneous | fsf3CanSetTime)
+}
+
+func (op *nfsOp) pathconf() {
+	ino, status := op.handle()
+	var info *proto.InodeInfo
+	if status == nfs3OK {
+		info, status = op.inodeGet(ino)
+	}
+	op.res.uint32(status)
+	op.postOpAttr(info)
+	if status != nfs3OK {
+		return
+	}
+	op.res.uint32(1<<32 - 1)
+	op.res.uint32(maxNameLen)
+	op.res.bool(true)
+	op.res.bool(true)
+	op.res.bool(false)
+	op.res.bool(true)
+}
+
+func nfsTime(e *xdrEncoder, t time.Time) {
+	e.uint32(uint32(t.Unix()))
+	e.uint32(uint32(t.Nanosecond()))
+}
+
+// unixMode converts the permission and special bits of a mode to the unix
+// mode bits.
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= syscall.S_ISUID
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= syscall.S_ISGID
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= syscall.S_ISVTX
+	}
+	return m
+}
+
+func osMode(m uint32) os.FileMode {
+	mode := os.FileMode(m) & os.ModePerm
+	if m&syscall.S_ISUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&syscall.S_ISGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&syscall.S_ISVTX != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+func inGroup(cred rpcCred, gid uint32) bool {
+	if cred.gid == gid {
+		return true
+	}
+	for _, g := range cred.gids {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// permitted checks the permission bits of the inode for the caller. The
+// root can do anything but executing a file without any execute bit.
+func permitted(info *proto.InodeInfo, cred rpcCred, want uint32) bool {
+	perm := uint32(proto.OsMode(info.Mode).Perm())
+	if cred.uid == 0 {
+		if want&permExec != 0 && !proto.IsDir(info.Mode) && perm&0111 == 0 {
+			return false
+		}
+		return true
+	}
+	switch {
+	case cred.uid == info.Uid:
+		perm >>= 6
+	case inGroup(cred, info.Gid):
+		perm >>= 3
+	}
+	return perm&want == want
+}
+
+func errorStatus(err error) uint32 {
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return nfs3ErrIO
+	}
+	switch errno {
+	case syscall.EPERM, syscall.ENOENT, syscall.EIO, syscall.EACCES, syscall.EEXIST, syscall.EXDEV,
+		syscall.ENOTDIR, syscall.EISDIR, syscall.EINVAL, syscall.EFBIG, syscall.ENOSPC, syscall.EROFS,
+		syscall.EMLINK, syscall.ENAMETOOLONG, syscall.ENOTEMPTY, syscall.EDQUOT:
+		return uint32(errno)
+	case syscall.ENOTSUP:
+		return nfs3ErrNotsupp
+	case syscall.EBADF:
+		return nfs3ErrStale
+	default:
+		return nfs3ErrIO
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// ONC RPC (RFC 5531) over TCP.
+const (
+	rpcCall  = 0
+	rpcReply = 1
+
+	rpcVersion = 2
+
+	msgAccepted = 0
+	msgDenied   = 1
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+	acceptSystemErr    = 5
+
+	rejectRpcMismatch = 0
+	rejectAuthError   = 1
+
+	authNone = 0
+	authSys  = 1
+
+	authBadCred = 1
+
+	lastFragment   = 1 << 31
+	maxRecordSize  = 4 << 20
+	maxCallsPerCon = 64
+)
+
+var errRecordTooLarge = errors.New("rpc: record too large")
+
+// rpcCred is the AUTH_SYS credential of a call. A call with AUTH_NONE
+// carries the anonymous user.
+type rpcCred struct {
+	flavor uint32
+	uid    uint32
+	gid    uint32
+	gids   []uint32
+}
+
+type rpcCallMsg struct {
+	xid  uint32
+	prog uint32
+	vers uint32
+	proc uint32
+	cred rpcCred
+	args *xdrDecoder
+	conn *rpcConn
+}
+
+// rpcProgram serves the procedures of a version of an RPC program. The
+// results are appended to res, and garbage args is returned if the args of
+// the call cannot be decoded.
+type rpcProgram interface {
+	versions() (low, high uint32)
+	procName(proc uint32) string
+	serve(call *rpcCallMsg, res *xdrEncoder) (acceptStat uint32)
+}
+
+type rpcConn struct {
+	net.Conn
+	node   *NfsNode
+	wlock  sync.Mutex
+	remote net.IP
+}
+
+func (node *NfsNode) serveConn(c net.Conn) {
+	conn := &rpcConn{Conn: c, node: node}
+	if addr, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		conn.remote = addr.IP
+	}
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, maxCallsPerCon)
+	reader := bufio.NewReaderSize(c, 64*1024)
+	for {
+		record, err := readRecord(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.LogWarnf("serveConn: read record from(%v) err(%v)", c.RemoteAddr(), err)
+			}
+			break
+		}
+		limit <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+			conn.handleRecord(record)
+		}()
+	}
+	wg.Wait()
+}
+
+func readRecord(r io.Reader) (record []byte, err error) {
+	var header [4]byte
+	for {
+		if _, err = io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		h := binary.BigEndian.Uint32(header[:])
+		size := int(h &^ lastFragment)
+		if len(record)+size > maxRecordSize {
+			return nil, errRecordTooLarge
+		}
+		fragment := make([]byte, size)
+		if _, err = io.ReadFull(r, fragment); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		record = append(record, fragment...)
+		if h&lastFragment != 0 {
+			return
+		}
+	}
+}
+
+func (conn *rpcConn) handleRecord(record []byte) {
+	d := newXdrDecoder(record)
+	call := &rpcCallMsg{conn: conn, args: d}
+	call.xid = d.uint32()
+	msgType := d.uint32()
+	version := d.uint32()
+	call.prog = d.uint32()
+	call.vers = d.uint32()
+	call.proc = d.uint32()
+	call.cred.flavor = d.uint32()
+	cred := newXdrDecoder(d.opaque(400))
+	d.uint32()
+	d.opaque(400)
+	if d.err != nil || msgType != rpcCall {
+		log.LogWarnf("handleRecord: bad call from(%v) err(%v)", conn.RemoteAddr(), d.err)
+		return
+	}
+
+	res := &xdrEncoder{}
+	res.uint32(call.xid)
+	res.uint32(rpcReply)
+	if version != rpcVersion {
+		res.uint32(msgDenied)
+		res.uint32(rejectRpcMismatch)
+		res.uint32(rpcVersion)
+		res.uint32(rpcVersion)
+		conn.writeRecord(res.buf)
+		return
+	}
+	switch call.cred.flavor {
+	case authSys:
+		cred.uint32()
+		cred.string(255)
+		call.cred.uid = cred.uint32()
+		call.cred.gid = cred.uint32()
+		n := cred.uint32()
+		if n > 16 {
+			cred.err = errors.New("rpc: too many gids")
+		}
+		for i := uint32(0); i < n && cred.err == nil; i++ {
+			call.cred.gids = append(call.cred.gids, cred.uint32())
+		}
+	default:
+		call.cred.uid, call.cred.gid = nobody, nobody
+	}
+	if cred.err != nil {
+		res.uint32(msgDenied)
+		res.uint32(rejectAuthError)
+		res.uint32(authBadCred)
+		conn.writeRecord(res.buf)
+		return
+	}
+
+	res.uint32(msgAccepted)
+	res.uint32(authNone)
+	res.uint32(0)
+	header := len(res.buf)
+	res.uint32(acceptSuccess)
+
+	prog, ok := conn.node.programs[call.prog]
+	if !ok {
+		res.buf[header+3] = acceptProgUnavail
+		conn.writeRecord(res.buf)
+		return
+	}
+	if low, high := prog.versions(); call.vers < low || call.vers > high {
+		res.buf[header+3] = acceptProgMismatch
+		res.uint32(low)
+		res.uint32(high)
+		conn.writeRecord(res.buf)
+		return
+	}
+	name := prog.procName(call.proc)
+	if name == "" {
+		res.buf[header+3] = acceptProcUnavail
+		conn.writeRecord(res.buf)
+		return
+	}
+
+	metric := exporter.NewTPCnt(name)
+	stat := prog.serve(call, res)
+	metric.Set(nil)
+	if stat != acceptSuccess {
+		res.buf = res.buf[:header]
+		res.uint32(stat)
+	}
+	conn.writeRecord(res.buf)
+}
+
+func (conn *rpcConn) writeRecord(record []byte) {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(record))|lastFragment)
+	conn.wlock.Lock()
+	defer conn.wlock.Unlock()
+	if _, err := conn.Write(append(header[:], record...)); err != nil {
+		log.LogWarnf("writeRecord: write to(%v) err(%v)", conn.RemoteAddr(), err)
+	}
+}
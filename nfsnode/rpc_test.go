@@ -0,0 +1,120 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+func rpcRoundTrip(t *testing.T, node *NfsNode, prog, vers, proc uint32, args []byte) *xdrDecoder {
+	client, server := net.Pipe()
+	defer client.Close()
+	go node.serveConn(server)
+
+	call := &xdrEncoder{}
+	call.uint32(0)
+	call.uint32(1)
+	call.uint32(rpcCall)
+	call.uint32(rpcVersion)
+	call.uint32(prog)
+	call.uint32(vers)
+	call.uint32(proc)
+	call.uint32(authSys)
+	cred := &xdrEncoder{}
+	cred.uint32(0)
+	cred.string("client")
+	cred.uint32(1000)
+	cred.uint32(1000)
+	cred.uint32(0)
+	call.opaque(cred.buf)
+	call.uint32(authNone)
+	call.uint32(0)
+	call.fixed(args)
+	size := uint32(len(call.buf)-4) | lastFragment
+	call.buf[0], call.buf[1], call.buf[2], call.buf[3] = byte(size>>24), byte(size>>16), byte(size>>8), byte(size)
+	if _, err := client.Write(call.buf); err != nil {
+		t.Fatal(err)
+	}
+	record, err := readRecord(bufio.NewReader(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newXdrDecoder(record)
+	if xid, msgType := d.uint32(), d.uint32(); xid != 1 || msgType != rpcReply {
+		t.Fatalf("bad reply header: xid(%v) type(%v)", xid, msgType)
+	}
+	return d
+}
+
+func TestRPCAccepted(t *testing.T) {
+	node := &NfsNode{port: 2049}
+	node.programs = map[uint32]rpcProgram{
+		nfsProgram:     &nfsV3{node: node},
+		portmapProgram: &portmapV2{node: node},
+	}
+
+	d := rpcRoundTrip(t, node, nfsProgram, nfsVersion, nfsProcNull, nil)
+	if stat, _, _, accept := d.uint32(), d.uint32(), d.opaque(400), d.uint32(); stat != msgAccepted || accept != acceptSuccess {
+		t.Fatalf("null: stat(%v) accept(%v)", stat, accept)
+	}
+
+	args := &xdrEncoder{}
+	args.uint32(nfsProgram)
+	args.uint32(nfsVersion)
+	args.uint32(ipProtoTCP)
+	args.uint32(0)
+	d = rpcRoundTrip(t, node, portmapProgram, portmapVersion, portmapProcGetport, args.buf)
+	d.uint32()
+	d.uint32()
+	d.opaque(400)
+	if accept, port := d.uint32(), d.uint32(); accept != acceptSuccess || port != 2049 {
+		t.Fatalf("getport: accept(%v) port(%v)", accept, port)
+	}
+
+	d = rpcRoundTrip(t, node, nfsProgram, 4, nfsProcNull, nil)
+	d.uint32()
+	d.uint32()
+	d.opaque(400)
+	if accept, low, high := d.uint32(), d.uint32(), d.uint32(); accept != acceptProgMismatch || low != 3 || high != 3 {
+		t.Fatalf("version mismatch: accept(%v) low(%v) high(%v)", accept, low, high)
+	}
+
+	d = rpcRoundTrip(t, node, mountProgram, mountVersion, mountProcNull, nil)
+	d.uint32()
+	d.uint32()
+	d.opaque(400)
+	if accept := d.uint32(); accept != acceptProgUnavail {
+		t.Fatalf("program unavailable: accept(%v)", accept)
+	}
+}
+
+func TestXdrOpaque(t *testing.T) {
+	e := &xdrEncoder{}
+	e.string("abcde")
+	e.uint64(1 << 40)
+	if len(e.buf) != 4+8+8 {
+		t.Fatalf("encoded length %v", len(e.buf))
+	}
+	d := newXdrDecoder(e.buf)
+	if s, v := d.string(8), d.uint64(); s != "abcde" || v != 1<<40 || d.err != nil {
+		t.Fatalf("decoded %v %v err(%v)", s, v, d.err)
+	}
+	d = newXdrDecoder(e.buf)
+	if d.string(4); d.err == nil {
+		t.Fatal("expected too long opaque")
+	}
+}
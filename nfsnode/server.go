@@ -0,0 +1,259 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/cmd/common"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Configuration items that act on the NfsNode.
+const (
+	// The port serving NFS, MOUNT and the portmapper over TCP.
+	configListen = proto.ListenPort
+
+	// The addresses of the masters of the cluster.
+	configMasterAddr = proto.MasterAddr
+
+	// The port of an extra portmapper, usually 111, for the clients which
+	// cannot be told the ports by the mount options. It must not be used by
+	// the rpcbind of the host.
+	configPortmapListen = "portmapListen"
+
+	// The exported volumes, see exportConfig.
+	configExports = "exports"
+)
+
+// ConfigSchema declares the configuration items of the NfsNode.
+var ConfigSchema = config.Schema{
+	configListen:        config.TypeString,
+	configMasterAddr:    config.TypeStringSlice,
+	configPortmapListen: config.TypeString,
+	configExports:       config.TypeSlice,
+}
+
+const (
+	defaultListen = "2049"
+)
+
+var (
+	regexpListen = regexp.MustCompile("^(\\d)+$")
+)
+
+// NfsNode exports the volumes over NFSv3 with the SDK, for the clients
+// which cannot mount the volumes with FUSE.
+type NfsNode struct {
+	port          int
+	portmapListen string
+	masters       []string
+
+	volumes   map[string]*volume
+	exports   map[string]*export
+	exportIDs map[uint64]*export
+	dirCache  *dirCache
+	programs  map[uint32]rpcProgram
+	writeVerf [8]byte
+
+	listeners []net.Listener
+	stopC     chan struct{}
+	wg        sync.WaitGroup
+
+	control common.Control
+}
+
+func NewServer() *NfsNode {
+	return &NfsNode{}
+}
+
+func (node *NfsNode) Start(cfg *config.Config) (err error) {
+	return node.control.Start(node, cfg, handleStart)
+}
+
+func (node *NfsNode) Shutdown() {
+	node.control.Shutdown(node, handleShutdown)
+}
+
+func (node *NfsNode) Sync() {
+	node.control.Sync()
+}
+
+func (node *NfsNode) loadConfig(cfg *config.Config) (err error) {
+	listen := cfg.GetString(configListen)
+	if len(listen) == 0 {
+		listen = defaultListen
+	}
+	if !regexpListen.MatchString(listen) {
+		return errors.New("invalid listen configuration")
+	}
+	node.port, _ = strconv.Atoi(listen)
+
+	portmapListen := cfg.GetString(configPortmapListen)
+	if portmapListen != "" && !regexpListen.MatchString(portmapListen) {
+		return errors.New("invalid portmapListen configuration")
+	}
+	node.portmapListen = portmapListen
+
+	if node.masters = cfg.GetStringSlice(configMasterAddr); len(node.masters) == 0 {
+		return config.NewIllegalConfigError(configMasterAddr)
+	}
+
+	configs, err := parseExports(cfg.GetSlice(configExports))
+	if err != nil {
+		return
+	}
+	if len(configs) == 0 {
+		return config.NewIllegalConfigError(configExports)
+	}
+	for _, ec := range configs {
+		var exp *export
+		if exp, err = node.newExport(ec); err != nil {
+			return
+		}
+		if other := node.exportIDs[exp.id]; other != nil {
+			return errors.New("conflicting export paths " + other.path + " and " + exp.path)
+		}
+		node.exports[exp.path] = exp
+		node.exportIDs[exp.id] = exp
+		log.LogInfof("loadConfig: export path(%v) volume(%v) subdir(%v) clients(%v) readOnly(%v) squash(%v)",
+			exp.path, ec.Volume, ec.SubDir, ec.Clients, exp.readOnly, exp.squash)
+	}
+	return
+}
+
+func handleStart(s common.Server, cfg *config.Config) (err error) {
+	node, ok := s.(*NfsNode)
+	if !ok {
+		return errors.New("Invalid Node Type!")
+	}
+	node.volumes = make(map[string]*volume)
+	node.exports = make(map[string]*export)
+	node.exportIDs = make(map[uint64]*export)
+	node.dirCache = newDirCache()
+	node.stopC = make(chan struct{})
+	binary.BigEndian.PutUint64(node.writeVerf[:], uint64(time.Now().UnixNano()))
+	node.programs = map[uint32]rpcProgram{
+		nfsProgram:     &nfsV3{node: node},
+		mountProgram:   &mountV3{node: node},
+		portmapProgram: &portmapV2{node: node},
+	}
+	defer func() {
+		if err != nil {
+			node.closeVolumes()
+		}
+	}()
+
+	if err = node.loadConfig(cfg); err != nil {
+		return
+	}
+	mc := master.NewMasterClient(node.masters, false)
+	ci, err := mc.AdminAPI().GetClusterInfo()
+	mc.Stop()
+	if err != nil {
+		return
+	}
+
+	if err = node.listen(":" + strconv.Itoa(node.port)); err != nil {
+		return
+	}
+	if node.portmapListen != "" {
+		if err = node.listen(":" + node.portmapListen); err != nil {
+			node.closeListeners()
+			return
+		}
+	}
+	node.wg.Add(1)
+	go node.closeIdleStreams()
+
+	exporter.Init(cfg.GetString("role"), cfg)
+	exporter.RegistConsul(ci.Cluster, cfg.GetString("role"), cfg)
+	log.LogInfof("nfs subsystem start success, port(%v)", node.port)
+	return
+}
+
+func handleShutdown(s common.Server) {
+	node, ok := s.(*NfsNode)
+	if !ok {
+		return
+	}
+	node.closeListeners()
+	close(node.stopC)
+	node.wg.Wait()
+	node.closeVolumes()
+}
+
+func (node *NfsNode) listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.LogErrorf("listen: addr(%v) err(%v)", addr, err)
+		return err
+	}
+	node.listeners = append(node.listeners, l)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				log.LogWarnf("listen: accept on(%v) err(%v)", addr, err)
+				return
+			}
+			go node.serveConn(c)
+		}
+	}()
+	return nil
+}
+
+func (node *NfsNode) closeListeners() {
+	for _, l := range node.listeners {
+		l.Close()
+	}
+	node.listeners = nil
+}
+
+func (node *NfsNode) closeIdleStreams() {
+	defer node.wg.Done()
+	ticker := time.NewTicker(streamIdleTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-node.stopC:
+			return
+		case <-ticker.C:
+			for _, vol := range node.volumes {
+				vol.closeIdleStreams(false)
+			}
+		}
+	}
+}
+
+func (node *NfsNode) closeVolumes() {
+	for _, vol := range node.volumes {
+		vol.close()
+	}
+}
+
+func (node *NfsNode) exportByID(id uint64) *export {
+	return node.exportIDs[id]
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package nfsnode
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errXdrShort = errors.New("xdr: short buffer")
+
+// xdrDecoder decodes the XDR (RFC 4506) items of a message in order. The
+// first error sticks, and the items decoded after it are zero.
+type xdrDecoder struct {
+	buf []byte
+	err error
+}
+
+func newXdrDecoder(buf []byte) *xdrDecoder {
+	return &xdrDecoder{buf: buf}
+}
+
+func (d *xdrDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = errXdrShort
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *xdrDecoder) uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *xdrDecoder) uint64() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (d *xdrDecoder) bool() bool {
+	return d.uint32() != 0
+}
+
+// fixed decodes fixed-length opaque data.
+func (d *xdrDecoder) fixed(n int) []byte {
+	b := d.next(n)
+	d.next(pad(n))
+	return b
+}
+
+// opaque decodes variable-length opaque data of at most max bytes.
+func (d *xdrDecoder) opaque(max int) []byte {
+	n := d.uint32()
+	if d.err == nil && n > uint32(max) {
+		d.err = errors.New("xdr: opaque too long")
+	}
+	return d.fixed(int(n))
+}
+
+func (d *xdrDecoder) string(max int) string {
+	return string(d.opaque(max))
+}
+
+// xdrEncoder appends XDR items to a buffer.
+type xdrEncoder struct {
+	buf []byte
+}
+
+func (e *xdrEncoder) uint32(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *xdrEncoder) uint64(v uint64) {
+	e.uint32(uint32(v >> 32))
+	e.uint32(uint32(v))
+}
+
+func (e *xdrEncoder) bool(v bool) {
+	if v {
+		e.uint32(1)
+	} else {
+		e.uint32(0)
+	}
+}
+
+func (e *xdrEncoder) fixed(b []byte) {
+	e.buf = append(e.buf, b...)
+	for i := 0; i < pad(len(b)); i++ {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *xdrEncoder) opaque(b []byte) {
+	e.uint32(uint32(len(b)))
+	e.fixed(b)
+}
+
+func (e *xdrEncoder) string(s string) {
+	e.opaque([]byte(s))
+}
+
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
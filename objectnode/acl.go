@@ -73,7 +73,9 @@ var (
 	aclObjectPermissionActions = map[Permission]proto.Actions{
 		ReadPermission: {
 			proto.OSSGetObjectAction,
+			proto.OSSHeadObjectAction,
 			proto.OSSGetObjectTorrentAction,
+			proto.OSSSelectObjectContentAction,
 		},
 		WritePermission: {},
 		ReadACPPermission: {
@@ -83,7 +85,9 @@ var (
 			proto.OSSPutObjectAclAction},
 		FullControlPermission: {
 			proto.OSSGetObjectAction,
+			proto.OSSHeadObjectAction,
 			proto.OSSGetObjectTorrentAction,
+			proto.OSSSelectObjectContentAction,
 			proto.OSSGetObjectAclAction,
 			proto.OSSPutObjectAclAction,
 		},
@@ -248,6 +252,63 @@ func (acp *AccessControlPolicy) SetBucketStandardACL(param *RequestParam, acl st
 	}
 }
 
+// newBucketStandardACL returns the ACL of the bucket granted by the canned ACL, or nil if the canned
+// ACL is unknown.
+func newBucketStandardACL(param *RequestParam, vol *Volume, acl string) *AccessControlPolicy {
+	if _, ok := aclPermissions[StandardACL(acl)]; !ok {
+		return nil
+	}
+	var acp = &AccessControlPolicy{
+		Owner: Owner{Id: vol.Owner(), DisplayName: vol.Owner()},
+	}
+	acp.SetBucketStandardACL(param, acl)
+	return acp
+}
+
+// newObjectStandardACL returns the ACL of the object granted by the canned ACL, or nil if the canned
+// ACL does not apply to objects. The requester owns the object, or the bucket owner does if the
+// request is anonymous.
+func newObjectStandardACL(param *RequestParam, bucketOwner string, acl string) *AccessControlPolicy {
+	var rolePermissionsMap, ok = aclPermissions[StandardACL(acl)][objectResource]
+	if !ok {
+		return nil
+	}
+	var owner = param.accessKey
+	if owner == "" {
+		owner = bucketOwner
+	}
+	var acp = &AccessControlPolicy{
+		Owner: Owner{Id: owner, DisplayName: owner},
+	}
+	for role, permissions := range rolePermissionsMap {
+		grantee := Grantee{}
+		switch role {
+		case objectOwnerRole:
+			grantee.Id, grantee.DisplayName = owner, owner
+		case bucketOwnerRole:
+			grantee.Id, grantee.DisplayName = bucketOwner, bucketOwner
+		default:
+			grantee.URI = aclRoleURIMap[role]
+		}
+		for _, p := range permissions {
+			acp.Acl.Grants = append(acp.Acl.Grants, Grant{Grantee: grantee, Permission: p})
+		}
+	}
+	return acp
+}
+
+// parseObjectStandardACL returns the ACL of the object to be written by the canned ACL given by the
+// x-amz-acl header or the acl field of the POST form, which is nil if no canned ACL is given.
+func parseObjectStandardACL(param *RequestParam, vol *Volume, acl string) (acp *AccessControlPolicy, errorCode *ErrorCode) {
+	if acl == "" {
+		return nil, nil
+	}
+	if acp = newObjectStandardACL(param, vol.Owner(), acl); acp == nil {
+		return nil, InvalidArgument
+	}
+	return acp, nil
+}
+
 func (acp *AccessControlPolicy) SetBucketGrantACL(param *RequestParam, permission Permission) {
 	grantee := Grantee{
 		Id:          param.accessKey,
@@ -306,10 +367,23 @@ func (g Grant) Validate() bool {
 	return true
 }
 
+// IsAllowed returns if the grant permits the action of the requester. The grants to the group of all
+// users apply to any requester including the anonymous users. The permissions of the bucket apply to
+// its objects as well, besides the ACLs of the objects themselves.
 func (g *Grant) IsAllowed(param *RequestParam) bool {
-	if param.accessKey != g.Grantee.Id {
+	if !g.Grantee.isAllUsers() && !g.Grantee.isRequester(param) {
 		return false
 	}
-	actions := aclBucketPermissionActions[g.Permission]
-	return IsIntersectionActions(actions, param.Action())
+	return aclBucketPermissionActions[g.Permission].Contains(param.Action()) ||
+		aclObjectPermissionActions[g.Permission].Contains(param.Action())
+}
+
+func (g *Grantee) isAllUsers() bool {
+	return g.URI == aclRoleURIMap[allUsersRole]
+}
+
+// isRequester returns if the grantee is the requester, which is identified by either the access key
+// or the user id of the access key.
+func (g *Grantee) isRequester(param *RequestParam) bool {
+	return g.Id != "" && (g.Id == param.accessKey || g.Id == param.userID)
 }
@@ -21,6 +21,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"syscall"
 
 	"github.com/cubefs/cubefs/util/log"
 )
@@ -96,7 +98,9 @@ func (o *ObjectNode) putBucketACLHandler(w http.ResponseWriter, r *http.Request)
 		err error
 		ec  *ErrorCode
 	)
-	defer o.errorResponse(w, r, err, ec)
+	defer func() {
+		o.errorResponse(w, r, err, ec)
+	}()
 
 	log.LogInfof("Put bucket acl")
 
@@ -112,26 +116,26 @@ func (o *ObjectNode) putBucketACLHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var bytes []byte
-	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
-		return
-	}
-
 	var acp *AccessControlPolicy
-	if acp, err = ParseACL(bytes, param.Bucket()); err != nil {
-		return
-	}
-	if acp == nil {
-		return
-	}
-
 	//add standard acl request header
 	// https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/acl-overview.html
-	if standardAcls, found := r.Header["x-amz-acl"]; found {
-		acp.SetBucketStandardACL(param, standardAcls[0])
+	// The canned ACL replaces the ACL of the bucket, and the request has no body.
+	if standardAcl := r.Header.Get(HeaderNameXAmzACL); standardAcl != "" {
+		if acp = newBucketStandardACL(param, vol, standardAcl); acp == nil {
+			ec = InvalidArgument
+			return
+		}
 	} else {
+		var bytes []byte
+		if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+			return
+		}
+		if acp, err = ParseACL(bytes, param.Bucket()); err != nil {
+			ec = MalformedXML
+			return
+		}
 		for grant, permission := range aclGrantKeyPermissionMap {
-			if _, found2 := r.Header[grant]; found2 {
+			if r.Header.Get(grant) != "" {
 				acp.SetBucketGrantACL(param, permission)
 			}
 		}
@@ -149,14 +153,151 @@ func (o *ObjectNode) putBucketACLHandler(w http.ResponseWriter, r *http.Request)
 	return
 }
 
+// Get object acl
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectAcl.html
 func (o *ObjectNode) getObjectACLHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO: implement get object acl handler
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = InvalidKey
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		errorCode = NoSuchBucket
+		return
+	}
+
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	var acl *AccessControlPolicy
+	var inodeVersionId string
+	if acl, inodeVersionId, err = vol.GetObjectACL(param.Object(), versionId); err != nil {
+		if err == syscall.ENOENT {
+			errorCode = NoSuchKey
+			if versionId != "" {
+				errorCode = NoSuchVersion
+			}
+			return
+		}
+		log.LogErrorf("getObjectACLHandler: volume get ACL fail: requestID(%v) volume(%v) object(%v) versionId(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), param.Object(), versionId, err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	// The object without ACL of its own is fully controlled by the bucket owner.
+	if acl == nil {
+		acl = &AccessControlPolicy{
+			Owner: Owner{Id: vol.Owner(), DisplayName: vol.Owner()},
+		}
+		acl.Acl.Grants = append(acl.Acl.Grants, Grant{
+			Grantee:    Grantee{Id: vol.Owner(), DisplayName: vol.Owner()},
+			Permission: FullControlPermission,
+		})
+	}
+	for i := range acl.Acl.Grants {
+		var grantee = &acl.Acl.Grants[i].Grantee
+		grantee.Xmlxsi, grantee.XsiType = XMLNS, XSI_TYPE
+		if grantee.URI != "" {
+			grantee.XsiType = "Group"
+		}
+	}
 
+	var encoded []byte
+	if encoded, err = MarshalXMLEntity(acl); err != nil {
+		log.LogErrorf("getObjectACLHandler: encode output fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(encoded))}
+	if len(inodeVersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(inodeVersionId)}
+	}
+	if _, err = w.Write(encoded); err != nil {
+		log.LogErrorf("getObjectACLHandler: write response fail: requestID(%v) err(%v)", GetRequestID(r), err)
+	}
 	return
 }
 
+// Put object acl
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectAcl.html
 func (o *ObjectNode) putObjectACLHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO: implement get object acl handler
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = InvalidKey
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		errorCode = NoSuchBucket
+		return
+	}
+
+	// The canned ACL replaces the ACL of the object, or else the ACL is given by the request body.
+	var acp *AccessControlPolicy
+	if standardAcl := r.Header.Get(HeaderNameXAmzACL); standardAcl != "" {
+		if acp = newObjectStandardACL(param, vol.Owner(), standardAcl); acp == nil {
+			errorCode = InvalidArgument
+			return
+		}
+	} else {
+		var bytes []byte
+		if bytes, err = ioutil.ReadAll(r.Body); err != nil {
+			log.LogErrorf("putObjectACLHandler: read request body fail: requestID(%v) err(%v)", GetRequestID(r), err)
+			errorCode = InvalidArgument
+			return
+		}
+		if acp, err = ParseACL(bytes, param.Bucket()); err != nil || len(acp.Acl.Grants) > maxGrantCount {
+			log.LogWarnf("putObjectACLHandler: decode request body fail: requestID(%v) err(%v)", GetRequestID(r), err)
+			errorCode = MalformedXML
+			return
+		}
+		if acp.Owner.Id == "" {
+			acp.Owner = Owner{Id: param.AccessKey(), DisplayName: param.AccessKey()}
+		}
+	}
+
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	var inodeVersionId string
+	if inodeVersionId, err = vol.PutObjectACL(param.Object(), versionId, acp); err != nil {
+		log.LogErrorf("putObjectACLHandler: volume put ACL fail: requestID(%v) volume(%v) object(%v) versionId(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), param.Object(), versionId, err)
+		if err == syscall.ENOENT {
+			errorCode = NoSuchKey
+			if versionId != "" {
+				errorCode = NoSuchVersion
+			}
+			return
+		}
+		errorCode = InternalErrorCode(err)
+		return
+	}
 
+	if len(inodeVersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(inodeVersionId)}
+	}
 	return
 }
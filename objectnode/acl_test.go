@@ -13,3 +13,105 @@
 // permissions and limitations under the License.
 
 package objectnode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestBucketStandardACLAllowed(t *testing.T) {
+	var cases = []struct {
+		acl       string
+		accessKey string
+		action    proto.Action
+		expect    bool
+	}{
+		{PublicReadACL, "", proto.OSSGetObjectAction, true},
+		{PublicReadACL, "", proto.OSSHeadObjectAction, true},
+		{PublicReadACL, "", proto.OSSListObjectsAction, true},
+		{PublicReadACL, "", proto.OSSPutObjectAction, false},
+		{PublicReadACL, "", proto.OSSPutBucketAclAction, false},
+		{PublicReadACL, "other", proto.OSSGetObjectAction, true},
+		{PublicReadACL, "owner", proto.OSSPutObjectAction, true},
+		{PubliceReadWriteACL, "", proto.OSSPutObjectAction, true},
+		{string(PrivateACL), "", proto.OSSGetObjectAction, false},
+		{string(PrivateACL), "other", proto.OSSGetObjectAction, false},
+		{string(PrivateACL), "owner", proto.OSSGetObjectAction, true},
+	}
+	for i, c := range cases {
+		var acp = &AccessControlPolicy{}
+		acp.SetBucketStandardACL(&RequestParam{accessKey: "owner"}, c.acl)
+		var param = &RequestParam{accessKey: c.accessKey, action: c.action}
+		if allowed := acp.IsAllowed(param, false); allowed != c.expect {
+			t.Fatalf("case %v: allowed mismatch: acl(%v) accessKey(%v) action(%v) expect(%v) actual(%v)",
+				i, c.acl, c.accessKey, c.action, c.expect, allowed)
+		}
+	}
+}
+
+func TestGrantNotAllowedForAnonymous(t *testing.T) {
+	var grant = Grant{Grantee: Grantee{}, Permission: FullControlPermission}
+	if grant.IsAllowed(&RequestParam{action: proto.OSSGetObjectAction}) {
+		t.Fatalf("grant to no user should not allow anonymous request")
+	}
+}
+
+func TestObjectStandardACLAllowed(t *testing.T) {
+	var cases = []struct {
+		acl       string
+		accessKey string
+		userID    string
+		action    proto.Action
+		expect    bool
+	}{
+		{string(PrivateACL), "writer", "", proto.OSSGetObjectAction, true},
+		{string(PrivateACL), "", "", proto.OSSGetObjectAction, false},
+		{string(PrivateACL), "other", "", proto.OSSGetObjectAction, false},
+		{string(PrivateACL), "other", "bucketOwner", proto.OSSGetObjectAction, false},
+		{PublicReadACL, "", "", proto.OSSGetObjectAction, true},
+		{PublicReadACL, "", "", proto.OSSHeadObjectAction, true},
+		{PublicReadACL, "", "", proto.OSSPutObjectAclAction, false},
+		{PublicReadACL, "other", "", proto.OSSGetObjectAction, true},
+		{BucketOwnerFullControlACL, "other", "bucketOwner", proto.OSSGetObjectAction, true},
+		{BucketOwnerFullControlACL, "other", "bucketOwner", proto.OSSPutObjectAclAction, true},
+		{BucketOwnerFullControlACL, "other", "", proto.OSSGetObjectAction, false},
+		{BucketOwnerFullControlACL, "", "", proto.OSSGetObjectAction, false},
+	}
+	for i, c := range cases {
+		var acp = newObjectStandardACL(&RequestParam{accessKey: "writer"}, "bucketOwner", c.acl)
+		if acp == nil {
+			t.Fatalf("case %v: canned ACL should apply to objects: acl(%v)", i, c.acl)
+		}
+		var param = &RequestParam{accessKey: c.accessKey, userID: c.userID, action: c.action}
+		if allowed := acp.IsAllowed(param, false); allowed != c.expect {
+			t.Fatalf("case %v: allowed mismatch: acl(%v) accessKey(%v) userID(%v) action(%v) expect(%v) actual(%v)",
+				i, c.acl, c.accessKey, c.userID, c.action, c.expect, allowed)
+		}
+	}
+
+	if acp := newObjectStandardACL(&RequestParam{}, "bucketOwner", LogDeliveryWriteACL); acp != nil {
+		t.Fatalf("bucket only canned ACL should not apply to objects")
+	}
+	if acp := newObjectStandardACL(&RequestParam{}, "bucketOwner", string(PrivateACL)); acp.Owner.Id != "bucketOwner" {
+		t.Fatalf("object of anonymous request should be owned by bucket owner: owner(%v)", acp.Owner.Id)
+	}
+}
+
+func TestObjectACLMarshal(t *testing.T) {
+	var acp = newObjectStandardACL(&RequestParam{accessKey: "writer"}, "bucketOwner", PublicReadACL)
+	var data, err = acp.Marshal()
+	if err != nil {
+		t.Fatalf("marshal ACL fail: err(%v)", err)
+	}
+	var parsed *AccessControlPolicy
+	if parsed, err = ParseACL(data, "bucket"); err != nil {
+		t.Fatalf("parse ACL fail: err(%v)", err)
+	}
+	if parsed.Owner.Id != "writer" || len(parsed.Acl.Grants) != len(acp.Acl.Grants) {
+		t.Fatalf("parsed ACL mismatch: actual(%v)", parsed)
+	}
+	if !parsed.IsAllowed(&RequestParam{action: proto.OSSGetObjectAction}, false) {
+		t.Fatalf("parsed ACL should allow anonymous read")
+	}
+}
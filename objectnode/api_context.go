@@ -28,6 +28,8 @@ const (
 	ContextKeyRequestAction = "ctx_request_action"
 	ContextKeyStatusCode    = "status_code"
 	ContextKeyErrorMessage  = "error_message"
+	ContextKeyErrorCode     = "error_code"
+	ContextKeyPostAccessKey = "ctx_post_access_key"
 )
 
 func SetRequestID(r *http.Request, requestID string) {
@@ -65,3 +67,20 @@ func SetResponseErrorMessage(r *http.Request, message string) {
 func getResponseErrorMessage(r *http.Request) string {
 	return mux.Vars(r)[ContextKeyErrorMessage]
 }
+
+func SetResponseErrorCode(r *http.Request, code string) {
+	mux.Vars(r)[ContextKeyErrorCode] = code
+}
+
+func getResponseErrorCode(r *http.Request) string {
+	return mux.Vars(r)[ContextKeyErrorCode]
+}
+
+// SetPostAccessKey sets the access key of the user signing the policy of the POST form.
+func SetPostAccessKey(r *http.Request, accessKey string) {
+	mux.Vars(r)[ContextKeyPostAccessKey] = accessKey
+}
+
+func getPostAccessKey(r *http.Request) string {
+	return mux.Vars(r)[ContextKeyPostAccessKey]
+}
@@ -35,6 +35,7 @@ type RequestParam struct {
 	conditionVars map[string][]string
 	vars          map[string]string
 	accessKey     string
+	userID        string // the user of the access key, set by the policy check
 	r             *http.Request
 }
 
@@ -50,9 +50,14 @@ func (o *ObjectNode) createBucketHandler(w http.ResponseWriter, r *http.Request)
 		_ = DuplicatedBucket.ServeResponse(w, r)
 		return
 	}
+	var standardAcl = r.Header.Get(HeaderNameXAmzACL)
+	if _, ok := aclPermissions[StandardACL(standardAcl)]; standardAcl != "" && !ok {
+		_ = InvalidArgument.ServeResponse(w, r)
+		return
+	}
 	auth := parseRequestAuthInfo(r)
 	var userInfo *proto.UserInfo
-	if userInfo, err = o.getUserInfoByAccessKey(auth.accessKey); err != nil {
+	if userInfo, err = o.getRequestUserInfo(r, auth.accessKey); err != nil {
 		log.LogErrorf("get user info from master error: accessKey(%v), err(%v)", auth.accessKey, err)
 		_ = InternalErrorCode(err).ServeResponse(w, r)
 		return
@@ -62,6 +67,32 @@ func (o *ObjectNode) createBucketHandler(w http.ResponseWriter, r *http.Request)
 		_ = InternalErrorCode(err).ServeResponse(w, r)
 		return
 	}
+	if strings.ToLower(r.Header.Get(HeaderNameXAmzBucketObjectLockEnabled)) == "true" {
+		var vol *Volume
+		if vol, err = o.vm.Volume(bucket); err == nil {
+			err = enableBucketObjectLock(vol)
+		}
+		if err != nil {
+			log.LogErrorf("create bucket[%v] enable object lock failed: accessKey(%v), err(%v)", bucket, auth.accessKey, err)
+			_ = InternalErrorCode(err).ServeResponse(w, r)
+			return
+		}
+	}
+	if standardAcl != "" {
+		var vol *Volume
+		var data []byte
+		if vol, err = o.vm.Volume(bucket); err == nil {
+			data, err = newBucketStandardACL(ParseRequestParam(r), vol, standardAcl).Marshal()
+		}
+		if err == nil {
+			_, err = storeBucketACL(data, vol)
+		}
+		if err != nil {
+			log.LogErrorf("create bucket[%v] put acl failed: accessKey(%v), err(%v)", bucket, auth.accessKey, err)
+			_ = InternalErrorCode(err).ServeResponse(w, r)
+			return
+		}
+	}
 	//todo parse body
 	w.Header()[HeaderNameLocation] = []string{o.region}
 	return
@@ -84,7 +115,7 @@ func (o *ObjectNode) deleteBucketHandler(w http.ResponseWriter, r *http.Request)
 	}
 	auth := parseRequestAuthInfo(r)
 	var userInfo *proto.UserInfo
-	if userInfo, err = o.getUserInfoByAccessKey(auth.accessKey); err != nil {
+	if userInfo, err = o.getRequestUserInfo(r, auth.accessKey); err != nil {
 		log.LogErrorf("get user info from master error: accessKey(%v), err(%v)", auth.accessKey, err)
 		_ = InternalErrorCode(err).ServeResponse(w, r)
 		return
@@ -123,7 +154,7 @@ func (o *ObjectNode) listBucketsHandler(w http.ResponseWriter, r *http.Request)
 	var err error
 	auth := parseRequestAuthInfo(r)
 	var userInfo *proto.UserInfo
-	if userInfo, err = o.getUserInfoByAccessKey(auth.accessKey); err != nil {
+	if userInfo, err = o.getRequestUserInfo(r, auth.accessKey); err != nil {
 		log.LogErrorf("get user info from master error: accessKey(%v), err(%v)", auth.accessKey, err)
 		_ = InternalErrorCode(err).ServeResponse(w, r)
 		return
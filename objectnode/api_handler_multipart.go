@@ -81,6 +81,12 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 	// Checking user-defined metadata
 	var metadata = ParseUserDefinedMetadata(r.Header)
 
+	// the multipart uploads are not encrypted
+	if r.Header.Get(HeaderNameXAmzServerSideEncryption) != "" || r.Header.Get(HeaderNameXAmzSSECustomerAlgorithm) != "" {
+		errorCode = NotImplemented
+		return
+	}
+
 	// Check 'x-amz-tagging' header
 	var tagging *Tagging
 	if xAmxTagging := r.Header.Get(HeaderNameXAmzTagging); xAmxTagging != "" {
@@ -89,6 +95,15 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 			return
 		}
 	}
+	// the object lock is kept by the multipart upload until it is completed
+	var lockOpt *ObjectLockOption
+	if lockOpt, errorCode = parseObjectLockOption(r, vol); errorCode != nil {
+		return
+	}
+	var acl *AccessControlPolicy
+	if acl, errorCode = parseObjectStandardACL(param, vol, r.Header.Get(HeaderNameXAmzACL)); errorCode != nil {
+		return
+	}
 	var opt = &PutFileOption{
 		MIMEType:     contentType,
 		Disposition:  contentDisposition,
@@ -96,6 +111,8 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 		Metadata:     metadata,
 		CacheControl: cacheControl,
 		Expires:      expires,
+		ObjectLock:   lockOpt,
+		ACL:          acl,
 	}
 
 	var uploadID string
@@ -185,13 +202,46 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Checking bucket quota, the parts are counted in the usage of the bucket once written
+	if size := requestContentLength(r); !vol.quota.allow(size) {
+		log.LogWarnf("uploadPartHandler: bucket quota exceeded: requestID(%v) volume(%v) path(%v) size(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), size)
+		errorCode = QuotaExceeded
+		return
+	}
+
+	// Checking Content-MD5 and checksum, which are verified while the part is written
+	var checksum *checksumReader
+	if checksum, errorCode = newChecksumReader(r); errorCode != nil {
+		return
+	}
+	var body io.Reader = r.Body
+	if checksum != nil {
+		body = checksum
+	}
+
 	// handle exception
 	var fsFileInfo *FSFileInfo
-	fsFileInfo, err = vol.WritePart(param.Object(), uploadId, uint16(partNumberInt), r.Body)
+	fsFileInfo, err = vol.WritePart(param.Object(), uploadId, uint16(partNumberInt), body)
 	if err == syscall.ENOENT {
 		errorCode = NoSuchUpload
 		return
 	}
+	if errorCode = checksumErrorCode(err); errorCode != nil {
+		log.LogWarnf("uploadPartHandler: verify data fail: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) remote(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, getRequestIP(r), err)
+		return
+	}
+	if err == errInvalidChunkSignature {
+		log.LogWarnf("uploadPartHandler: invalid chunk signature: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) remote(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, getRequestIP(r))
+		errorCode = SignatureDoesNotMatch
+		return
+	}
+	if err == errMalformedChunk {
+		errorCode = IncompleteBody
+		return
+	}
 	if err == io.ErrUnexpectedEOF {
 		log.LogWarnf("uploadPartHandler: write part fail cause unexpected EOF: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) remote(%v) err(%v)",
 			GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, getRequestIP(r), err)
@@ -206,15 +256,215 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	vol.quota.consume(uint64(fsFileInfo.Size))
 	log.LogDebugf("uploadPartHandler: write part success: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) fsFileInfo(%v)",
 		GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, fsFileInfo)
 
 	// write header to response
 	w.Header()[HeaderNameContentLength] = []string{"0"}
-	w.Header()[HeaderNameETag] = []string{fsFileInfo.ETag}
+	w.Header()[HeaderNameETag] = []string{wrapUnescapedQuot(fsFileInfo.ETag)}
+	setChecksumResponseHeader(w, checksum.objectChecksum())
 	return
 }
 
+// Upload part copy
+// Uploads a part by copying data from an existing object as data source.
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_UploadPartCopy.html .
+func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+			return
+		}
+	}()
+
+	var param = ParseRequestParam(r)
+
+	// get upload id and part number
+	uploadId := param.GetVar(ParamUploadId)
+	partNumber := param.GetVar(ParamPartNumber)
+	if uploadId == "" || partNumber == "" {
+		log.LogErrorf("uploadPartCopyHandler: illegal uploadID or partNumber, requestID(%v)", GetRequestID(r))
+		errorCode = InvalidArgument
+		return
+	}
+	var partNumberInt uint64
+	if partNumberInt, err = strconv.ParseUint(partNumber, 10, 64); err != nil || partNumberInt < 1 || partNumberInt > MaxPartNumber {
+		log.LogErrorf("uploadPartCopyHandler: parse part number fail, requestID(%v) raw(%v) err(%v)",
+			GetRequestID(r), partNumber, err)
+		errorCode = InvalidArgument
+		return
+	}
+
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = InvalidKey
+		return
+	}
+
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("uploadPartCopyHandler: load volume fail: requestID(%v) err(%v)",
+			GetRequestID(r), err)
+		errorCode = NoSuchBucket
+		return
+	}
+
+	sourceBucket, sourceObject, sourceVersionId := parseCopySourceVersion(r)
+	if sourceBucket == "" || sourceObject == "" {
+		log.LogErrorf("uploadPartCopyHandler: illegal copy source, requestID(%v) source(%v)",
+			GetRequestID(r), r.Header.Get(HeaderNameXAmzCopySource))
+		errorCode = InvalidArgument
+		return
+	}
+
+	// check permission, must have read permission to source object
+	var userInfo *proto.UserInfo
+	if userInfo, err = o.getRequestUserInfo(r, param.AccessKey()); err != nil {
+		log.LogErrorf("uploadPartCopyHandler: get user info from master error: requestID(%v), accessKey(%v), err(%v)",
+			GetRequestID(r), param.AccessKey(), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	if !userInfo.Policy.IsAuthorized(sourceBucket, strings.TrimRight(sourceObject, "/"), proto.OSSGetObjectAction) {
+		log.LogErrorf("uploadPartCopyHandler: no permission to read source, requestID(%v), source bucket(%v), source file(%v)",
+			GetRequestID(r), sourceBucket, sourceObject)
+		errorCode = AccessDenied
+		return
+	}
+	var sourceParam = *param
+	sourceParam.bucket, sourceParam.object = sourceBucket, sourceObject
+	sourceParam.resource = sourceBucket + "/" + strings.TrimPrefix(sourceObject, "/")
+	sourceParam.action = proto.OSSGetObjectAction
+	if allowed, _ := o.sessionPolicyAllows(r, &sourceParam); !allowed {
+		log.LogErrorf("uploadPartCopyHandler: session policy not allowed to read source, requestID(%v), source bucket(%v), source file(%v)",
+			GetRequestID(r), sourceBucket, sourceObject)
+		errorCode = AccessDenied
+		return
+	}
+
+	var sourceVol *Volume
+	if sourceVol, err = o.getVol(sourceBucket); err != nil {
+		log.LogErrorf("uploadPartCopyHandler: load source volume fail: vol(%v) requestID(%v) err(%v)",
+			sourceBucket, GetRequestID(r), err)
+		errorCode = NoSuchBucket
+		return
+	}
+
+	// get source object meta
+	var fileInfo *FSFileInfo
+	if fileInfo, err = sourceVol.ObjectVersionMeta(sourceObject, sourceVersionId); err != nil {
+		if err == syscall.ENOENT {
+			if sourceVersionId != "" {
+				errorCode = NoSuchVersion
+			} else {
+				errorCode = NoSuchKey
+			}
+			return
+		}
+		log.LogErrorf("uploadPartCopyHandler: volume get file info fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	if fileInfo.DeleteMarker {
+		if sourceVersionId != "" {
+			errorCode = InvalidArgument
+		} else {
+			errorCode = NoSuchKey
+		}
+		return
+	}
+
+	// response 412
+	if errorCode = checkCopySourcePreconditions(r, fileInfo); errorCode != nil {
+		return
+	}
+
+	var offset, size uint64
+	if offset, size, errorCode = parseCopySourceRange(r.Header.Get(HeaderNameXAmzCopySourceRange), uint64(fileInfo.Size)); errorCode != nil {
+		log.LogErrorf("uploadPartCopyHandler: illegal copy source range: requestID(%v) range(%v) size(%v)",
+			GetRequestID(r), r.Header.Get(HeaderNameXAmzCopySourceRange), fileInfo.Size)
+		return
+	}
+	if size > MaxCopyObjectSize {
+		errorCode = CopySourceSizeTooLarge
+		return
+	}
+
+	var fsFileInfo *FSFileInfo
+	fsFileInfo, err = vol.CopyPart(sourceVol, sourceObject, fileInfo.Inode, offset, size, param.Object(), uploadId, uint16(partNumberInt))
+	if err == syscall.ENOENT {
+		errorCode = NoSuchUpload
+		return
+	}
+	if err != nil {
+		log.LogErrorf("uploadPartCopyHandler: copy part fail: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) source(%v/%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, sourceBucket, sourceObject, err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	log.LogDebugf("uploadPartCopyHandler: copy part success: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) fsFileInfo(%v)",
+		GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, fsFileInfo)
+
+	copyResult := CopyPartResult{
+		ETag:         wrapUnescapedQuot(fsFileInfo.ETag),
+		LastModified: formatTimeISO(fsFileInfo.ModifyTime),
+	}
+
+	var bytes []byte
+	if bytes, err = MarshalXMLEntity(copyResult); err != nil {
+		log.LogErrorf("uploadPartCopyHandler: marshal result fail, requestID(%v) err(%v)",
+			GetRequestID(r), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+
+	// set response header
+	if fileInfo.VersionId != "" {
+		w.Header()[HeaderNameXAmzCopySourceVersionId] = []string{fileInfo.VersionId}
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(bytes))}
+	if _, err = w.Write(bytes); err != nil {
+		log.LogErrorf("uploadPartCopyHandler: write response body fail, requestID(%v) err(%v)",
+			GetRequestID(r), err)
+	}
+	return
+}
+
+// parseCopySourceRange parses the value of "x-amz-copy-source-range", which must be
+// in the form of "bytes=first-last", into the offset and the size to copy.
+// The whole source is returned if no range is given.
+func parseCopySourceRange(value string, sourceSize uint64) (offset, size uint64, errorCode *ErrorCode) {
+	if value == "" {
+		return 0, sourceSize, nil
+	}
+	if !strings.HasPrefix(value, "bytes=") {
+		return 0, 0, InvalidArgument
+	}
+	var bounds = strings.SplitN(strings.TrimPrefix(value, "bytes="), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, InvalidArgument
+	}
+	first, err1 := strconv.ParseUint(bounds[0], 10, 64)
+	last, err2 := strconv.ParseUint(bounds[1], 10, 64)
+	if err1 != nil || err2 != nil || first > last {
+		return 0, 0, InvalidArgument
+	}
+	if last >= sourceSize {
+		return 0, 0, InvalidRange
+	}
+	return first, last - first + 1, nil
+}
+
 // List parts
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListParts.html
 func (o *ObjectNode) listPartsHandler(w http.ResponseWriter, r *http.Request) {
@@ -310,15 +560,16 @@ func (o *ObjectNode) listPartsHandler(w http.ResponseWriter, r *http.Request) {
 	parts := NewParts(fsParts)
 
 	listPartsResult := ListPartsResult{
-		Bucket:       param.Bucket(),
-		Key:          param.Object(),
-		UploadId:     uploadId,
-		StorageClass: StorageClassStandard,
-		NextMarker:   int(nextMarker),
-		MaxParts:     int(maxPartsInt),
-		IsTruncated:  isTruncated,
-		Parts:        parts,
-		Owner:        bucketOwner,
+		Bucket:           param.Bucket(),
+		Key:              param.Object(),
+		UploadId:         uploadId,
+		StorageClass:     StorageClassStandard,
+		PartNumberMarker: int(partNoMarkerInt),
+		NextMarker:       int(nextMarker),
+		MaxParts:         int(maxPartsInt),
+		IsTruncated:      isTruncated,
+		Parts:            parts,
+		Owner:            bucketOwner,
 	}
 
 	var bytes []byte
@@ -449,7 +700,7 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 		if strings.Contains(eTag, "\"") {
 			eTag = strings.ReplaceAll(eTag, "\"", "")
 		}
-		if multipartUploadRequest.Parts[index].ETag != eTag {
+		if strings.Trim(multipartUploadRequest.Parts[index].ETag, "\"") != eTag {
 			log.LogErrorf("CompleteMultipart: upload part ETag not equal received part ETag: volume(%v) multipartID(%v) path(%v) err(%v)",
 				vol.name, uploadId, param.object, err)
 			errorCode = InvalidPart
@@ -457,6 +708,19 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 		}
 	}
 
+	// Checking preconditions of overwriting
+	if errorCode = checkWritePreconditions(r, vol, param.Object()); errorCode != nil {
+		return
+	}
+
+	// Checking bucket quota, the bytes of the parts are already counted in the usage of the bucket
+	if !vol.quota.allow(0) {
+		log.LogWarnf("completeMultipartUploadHandler: bucket quota exceeded: requestID(%v) volume(%v) path(%v)",
+			GetRequestID(r), vol.Name(), param.Object())
+		errorCode = QuotaExceeded
+		return
+	}
+
 	fsFileInfo, err := vol.CompleteMultipart(param.Object(), uploadId, multipartInfo)
 	if err == syscall.ENOENT {
 		errorCode = NoSuchUpload
@@ -475,6 +739,20 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 	log.LogDebugf("completeMultipartUploadHandler: complete multipart, requestID(%v) uploadID(%v) path(%v)",
 		GetRequestID(r), uploadId, param.Object())
 
+	// Place the object lock after the object is completed
+	if lockOpt := objectLockFromXAttrs(multipartInfo.Extend); lockOpt != nil {
+		if err = vol.setObjectLock(fsFileInfo.Inode, lockOpt); err != nil {
+			log.LogErrorf("completeMultipartUploadHandler: place object lock fail: requestID(%v) volume(%v) path(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), err)
+			errorCode = InternalErrorCode(err)
+			return
+		}
+		lockOpt.fillFileInfo(fsFileInfo)
+	}
+
+	o.notifyObjectEvent(r, vol, EventObjectCreatedCompleteMultipartUpload, newNotificationObject(param.Object(), fsFileInfo))
+	o.replicateObject(r, vol, param.Object(), fsFileInfo)
+
 	// write response
 	completeResult := CompleteMultipartResult{
 		Bucket: param.Bucket(),
@@ -493,6 +771,9 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 	// set response header
 	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
 	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(bytes))}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fsFileInfo.VersionId)}
+	}
 	if _, err = w.Write(bytes); err != nil {
 		log.LogErrorf("completeMultipartUploadHandler: write response body fail, requestID(%v) err(%v)", GetRequestID(r), err)
 		return
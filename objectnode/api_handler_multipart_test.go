@@ -0,0 +1,67 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseCopySourceRange(t *testing.T) {
+	var cases = []struct {
+		value  string
+		size   uint64
+		offset uint64
+		length uint64
+		code   *ErrorCode
+	}{
+		{"", 100, 0, 100, nil},
+		{"bytes=0-99", 100, 0, 100, nil},
+		{"bytes=10-19", 100, 10, 10, nil},
+		{"bytes=10-100", 100, 0, 0, InvalidRange},
+		{"bytes=20-10", 100, 0, 0, InvalidArgument},
+		{"bytes=10-", 100, 0, 0, InvalidArgument},
+		{"10-19", 100, 0, 0, InvalidArgument},
+	}
+	for _, c := range cases {
+		offset, length, code := parseCopySourceRange(c.value, c.size)
+		if code != c.code || offset != c.offset || length != c.length {
+			t.Fatalf("range(%v) size(%v): expect (%v, %v, %v) actual (%v, %v, %v)",
+				c.value, c.size, c.offset, c.length, c.code, offset, length, code)
+		}
+	}
+}
+
+func TestParseCopySourceVersion(t *testing.T) {
+	var cases = []struct {
+		source    string
+		bucket    string
+		object    string
+		versionId string
+	}{
+		{"/bucket/a/b.txt", "bucket", "a/b.txt", ""},
+		{"bucket/a%20b.txt", "bucket", "a b.txt", ""},
+		{"bucket/a.txt?versionId=v1", "bucket", "a.txt", "v1"},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest(http.MethodPut, "/bucket/target?partNumber=1&uploadId=u", nil)
+		r.Header.Set(HeaderNameXAmzCopySource, c.source)
+		bucket, object, versionId := parseCopySourceVersion(r)
+		if bucket != c.bucket || object != c.object || versionId != c.versionId {
+			t.Fatalf("source(%v): expect (%v, %v, %v) actual (%v, %v, %v)",
+				c.source, c.bucket, c.object, c.versionId, bucket, object, versionId)
+		}
+	}
+}
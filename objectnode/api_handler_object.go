@@ -15,21 +15,22 @@
 package objectnode
 
 import (
-	"encoding/base64"
-	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
 )
 
 var (
@@ -150,76 +151,36 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	responseContentDisposition := r.URL.Query().Get(ParamResponseContentDisposition)
 
 	// get object meta
+	var versionId = r.URL.Query().Get(ParamVersionId)
 	var fileInfo *FSFileInfo
-	fileInfo, err = vol.ObjectMeta(param.Object())
+	fileInfo, err = vol.ObjectVersionMeta(param.Object(), versionId)
 	if err == syscall.ENOENT {
 		errorCode = NoSuchKey
+		if versionId != "" {
+			errorCode = NoSuchVersion
+		}
 		return
 	}
 	if err != nil {
-		log.LogErrorf("getObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) err(%v)",
-			GetRequestID(r), vol.Name(), param.Object(), err)
+		log.LogErrorf("getObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
 		errorCode = InternalErrorCode(err)
 		return
 	}
-
-	// parse request header
-	match := r.Header.Get(HeaderNameIfMatch)
-	noneMatch := r.Header.Get(HeaderNameIfNoneMatch)
-	modified := r.Header.Get(HeaderNameIfModifiedSince)
-	unmodified := r.Header.Get(HeaderNameIfUnmodifiedSince)
-
-	// Checking precondition: If-Match
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html#API_GetObject_RequestSyntax
-	if match != "" {
-		if matchEag := strings.Trim(match, "\""); matchEag != fileInfo.ETag {
-			log.LogDebugf("getObjectHandler: object eTag(%s) not match If-Match header value(%s), requestId(%v)",
-				fileInfo.ETag, matchEag, GetRequestID(r))
-			errorCode = PreconditionFailed
-			return
-		}
-	}
-	// Checking precondition: If-Modified-Since
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html#API_GetObject_RequestSyntax
-	if modified != "" {
-		fileModTime := fileInfo.ModifyTime
-		modifiedTime, err := parseTimeRFC1123(modified)
-		if err != nil {
-			log.LogErrorf("getObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", GetRequestID(r), err)
-			errorCode = InvalidArgument
-			return
-		}
-		if !fileModTime.After(modifiedTime) {
-			log.LogInfof("getObjectHandler: file modified time not after than specified time: requestID(%v)", GetRequestID(r))
-			errorCode = NotModified
-			return
-		}
-	}
-	// Checking precondition: If-None-Match
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html#API_GetObject_RequestSyntax
-	if noneMatch != "" {
-		if noneMatchEtag := strings.Trim(noneMatch, "\""); noneMatchEtag == fileInfo.ETag {
-			log.LogErrorf("getObjectHandler: object eTag(%s) match If-None-Match header value(%s), requestId(%v)",
-				fileInfo.ETag, noneMatchEtag, GetRequestID(r))
-			errorCode = NotModified
-			return
-		}
+	if fileInfo.DeleteMarker {
+		w.Header()[HeaderNameXAmzDeleteMarker] = []string{"true"}
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fileInfo.VersionId)}
+		errorCode = MethodNotAllowed
+		return
 	}
-	// Checking precondition: If-Unmodified-Since
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html#API_GetObject_RequestSyntax
-	if unmodified != "" && match == "" {
-		fileModTime := fileInfo.ModifyTime
-		modifiedTime, err := parseTimeRFC1123(unmodified)
-		if err != nil {
-			log.LogErrorf("getObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", GetRequestID(r), err)
-			errorCode = InvalidArgument
-			return
-		}
-		if fileModTime.After(modifiedTime) {
-			log.LogInfof("getObjectHandler: file modified time after than specified time: requestID(%v)", GetRequestID(r))
-			errorCode = PreconditionFailed
-			return
+
+	// Checking preconditions
+	if errorCode = checkReadPreconditions(r, fileInfo); errorCode != nil {
+		if errorCode == NotModified {
+			w.Header()[HeaderNameETag] = []string{wrapUnescapedQuot(fileInfo.ETag)}
+			w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
 		}
+		return
 	}
 
 	// validate and fix range
@@ -237,24 +198,33 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusPartialContent)
 	}
 
-	// get object tagging size
-	var xattrInfo *proto.XAttrInfo
-	if xattrInfo, err = vol.GetXAttr(param.object, XAttrKeyOSSTagging); err != nil && err != syscall.ENOENT {
-		log.LogErrorf("getObjectHandler: Volume get XAttr fail: requestID(%v) err(%v)", GetRequestID(r), err)
+	// get object tagging size of the version
+	var tagging *Tagging
+	if tagging, err = vol.loadInodeTagging(fileInfo.Inode); err != nil && err != syscall.ENOENT {
+		log.LogErrorf("getObjectHandler: Volume get tagging fail: requestID(%v) err(%v)", GetRequestID(r), err)
 		errorCode = InternalErrorCode(err)
 		return
 	}
-	if xattrInfo != nil {
-		ossTaggingData := xattrInfo.Get(XAttrKeyOSSTagging)
-		output, _ := ParseTagging(string(ossTaggingData))
-		if output != nil && len(output.TagSet) > 0 {
-			w.Header()[HeaderNameXAmzTaggingCount] = []string{strconv.Itoa(len(output.TagSet))}
-		}
+	if tagging != nil && len(tagging.TagSet) > 0 {
+		w.Header()[HeaderNameXAmzTaggingCount] = []string{strconv.Itoa(len(tagging.TagSet))}
 	}
 
 	// set response header for GetObject
 	w.Header()[HeaderNameAcceptRange] = []string{HeaderValueAcceptRange}
 	w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
+	if len(fileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fileInfo.VersionId)}
+	}
+	if len(fileInfo.StorageClass) > 0 {
+		w.Header()[HeaderNameXAmzStorageClass] = []string{fileInfo.StorageClass}
+	}
+	setSSEResponseHeader(w, fileInfo)
+	setObjectLockResponseHeader(w, fileInfo)
+	setReplicationResponseHeader(w, fileInfo)
+	// the checksum is of the whole object
+	if !isRangeRead && strings.EqualFold(r.Header.Get(HeaderNameXAmzChecksumMode), ChecksumModeEnabled) {
+		setChecksumResponseHeader(w, fileInfo.Checksum)
+	}
 	if len(responseContentType) > 0 {
 		w.Header()[HeaderNameContentType] = []string{responseContentType}
 	} else if len(fileInfo.MIMEType) > 0 {
@@ -331,7 +301,7 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	if isRangeRead || len(partNumber) > 0 {
 		size = rangeUpper - rangeLower + 1
 	}
-	err = vol.ReadFile(param.Object(), w, offset, size)
+	err = vol.ReadInodeWithRequestID(reqid.Parse(GetRequestID(r)), param.Object(), fileInfo.Inode, w, offset, size)
 	if err == syscall.ENOENT {
 		errorCode = NoSuchKey
 		return
@@ -380,81 +350,53 @@ func (o *ObjectNode) headObjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get object meta
+	var versionId = r.URL.Query().Get(ParamVersionId)
 	var fileInfo *FSFileInfo
-	fileInfo, err = vol.ObjectMeta(param.Object())
+	fileInfo, err = vol.ObjectVersionMeta(param.Object(), versionId)
 	if err == syscall.ENOENT {
 		errorCode = NoSuchKey
+		if versionId != "" {
+			errorCode = NoSuchVersion
+		}
 		return
 	}
 	if err != nil {
-		log.LogErrorf("headObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v)err(%v)",
-			GetRequestID(r), vol.Name(), param.Object(), err)
+		log.LogErrorf("headObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
 		errorCode = InternalErrorCode(err)
 		return
 	}
-
-	// parse request header
-	match := r.Header.Get(HeaderNameIfMatch)
-	noneMatch := r.Header.Get(HeaderNameIfNoneMatch)
-	modified := r.Header.Get(HeaderNameIfModifiedSince)
-	unmodified := r.Header.Get(HeaderNameIfUnmodifiedSince)
-
-	// Checking precondition: If-Match
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html#API_HeadObject_RequestSyntax
-	if match != "" {
-		if matchEag := strings.Trim(match, "\""); matchEag != fileInfo.ETag {
-			log.LogDebugf("headObjectHandler: object eTag(%s) not match If-Match header value(%s), requestId(%v)",
-				fileInfo.ETag, matchEag, GetRequestID(r))
-			errorCode = PreconditionFailed
-			return
-		}
-	}
-	// Checking precondition: If-Modified-Since
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html#API_HeadObject_RequestSyntax
-	if modified != "" {
-		fileModTime := fileInfo.ModifyTime
-		modifiedTime, err := parseTimeRFC1123(modified)
-		if err != nil {
-			log.LogDebugf("headObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", GetRequestID(r), err)
-			errorCode = InvalidArgument
-			return
-		}
-		if !fileModTime.After(modifiedTime) {
-			log.LogDebugf("headObjectHandler: file modified time not after than specified time: requestID(%v)", GetRequestID(r))
-			errorCode = NotModified
-			return
-		}
-	}
-	// Checking precondition: If-None-Match
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html#API_HeadObject_RequestSyntax
-	if noneMatch != "" {
-		if noneMatchEtag := strings.Trim(noneMatch, "\""); noneMatchEtag == fileInfo.ETag {
-			log.LogDebugf("headObjectHandler: object eTag(%s) match If-None-Match header value(%s), requestId(%v)",
-				fileInfo.ETag, noneMatchEtag, GetRequestID(r))
-			errorCode = NotModified
-			return
-		}
+	if fileInfo.DeleteMarker {
+		w.Header()[HeaderNameXAmzDeleteMarker] = []string{"true"}
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fileInfo.VersionId)}
+		errorCode = MethodNotAllowed
+		return
 	}
-	// Checking precondition: If-Unmodified-Since
-	// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html#API_HeadObject_RequestSyntax
-	if unmodified != "" && match == "" {
-		fileModTime := fileInfo.ModifyTime
-		modifiedTime, err := parseTimeRFC1123(unmodified)
-		if err != nil {
-			log.LogDebugf("headObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", GetRequestID(r), err)
-			errorCode = InvalidArgument
-			return
-		}
-		if fileModTime.After(modifiedTime) {
-			log.LogDebugf("headObjectHandler: file modified time after than specified time: requestID(%v)", GetRequestID(r))
-			errorCode = PreconditionFailed
-			return
+
+	// Checking preconditions
+	if errorCode = checkReadPreconditions(r, fileInfo); errorCode != nil {
+		if errorCode == NotModified {
+			w.Header()[HeaderNameETag] = []string{wrapUnescapedQuot(fileInfo.ETag)}
+			w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
 		}
+		return
 	}
 
 	// set response header
 	w.Header()[HeaderNameAcceptRange] = []string{HeaderValueAcceptRange}
 	w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
+	if len(fileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fileInfo.VersionId)}
+	}
+	if len(fileInfo.StorageClass) > 0 {
+		w.Header()[HeaderNameXAmzStorageClass] = []string{fileInfo.StorageClass}
+	}
+	setSSEResponseHeader(w, fileInfo)
+	setObjectLockResponseHeader(w, fileInfo)
+	setReplicationResponseHeader(w, fileInfo)
+	if strings.EqualFold(r.Header.Get(HeaderNameXAmzChecksumMode), ChecksumModeEnabled) {
+		setChecksumResponseHeader(w, fileInfo.Checksum)
+	}
 	w.Header()[HeaderNameContentMD5] = []string{EmptyContentMD5String}
 	if len(fileInfo.MIMEType) > 0 {
 		w.Header()[HeaderNameContentType] = []string{fileInfo.MIMEType}
@@ -563,10 +505,12 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	var (
-		deletedObjects = make([]Deleted, 0, len(deleteReq.Objects))
-		deletedErrors  = make([]Error, 0)
-	)
+	if len(deleteReq.Objects) > MaxDeleteObjects {
+		log.LogDebugf("deleteObjectsHandler: too many objects in request: requestID(%v) objects(%v)",
+			GetRequestID(r), len(deleteReq.Objects))
+		errorCode = MalformedXML
+		return
+	}
 
 	// Sort the key values in reverse order.
 	// The purpose of this is to delete the child leaf first and then the parent node.
@@ -581,24 +525,24 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 	sort.SliceStable(deleteReq.Objects, func(i, j int) bool {
 		return deleteReq.Objects[i].Key > deleteReq.Objects[j].Key
 	})
-
-	var objectKeys = make([]string, 0, len(deleteReq.Objects))
+	var objects = make([]Object, 0, len(deleteReq.Objects))
+	var requested = make(map[Object]bool)
 	for _, object := range deleteReq.Objects {
-		objectKeys = append(objectKeys, object.Key)
-		err = vol.DeletePath(object.Key)
-		log.LogWarnf("deleteObjectsHandler: delete: requestID(%v) volume(%v) path(%v)",
-			GetRequestID(r), vol.Name(), object.Key)
-		if err != nil {
-			deletedErrors = append(deletedErrors, Error{Key: object.Key, Message: err.Error()})
-			log.LogErrorf("deleteObjectsHandler: delete object failed: requestID(%v) volume(%v) path(%v) err(%v)",
-				GetRequestID(r), vol.Name(), object.Key, err)
-		} else {
-			deletedObjects = append(deletedObjects, Deleted{Key: object.Key})
-			log.LogDebugf("deleteObjectsHandler: delete object success: requestID(%v) volume(%v) path(%v)", GetRequestID(r),
-				vol.Name(), object.Key)
+		if !requested[object] {
+			requested[object] = true
+			objects = append(objects, object)
 		}
 	}
 
+	var objectKeys = make([]string, 0, len(objects))
+	for _, object := range objects {
+		objectKeys = append(objectKeys, object.Key)
+	}
+	var deletedObjects, deletedErrors = o.deleteObjects(r, vol, objects)
+	if deleteReq.Quiet {
+		deletedObjects = nil
+	}
+
 	// Audit bulk delete behavior
 	log.LogInfof("Audit: delete multiple objects: requestID(%v) remote(%v) volume(%v) objects(%v)",
 		GetRequestID(r), getRequestIP(r), vol.Name(), strings.Join(objectKeys, ","))
@@ -628,6 +572,114 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 	return
 }
 
+// deleteObjects deletes the objects sorted by the keys concurrently, and returns the results of them
+// in the order of the objects. The objects are deleted level by level from the deepest keys, so the
+// children are deleted before their parent directories, and the versions of the same key are deleted
+// one by one.
+func (o *ObjectNode) deleteObjects(r *http.Request, vol *Volume, objects []Object) (deletedObjects []Deleted, deletedErrors []Error) {
+	var (
+		deleted  = make([]*Deleted, len(objects))
+		failures = make([]*Error, len(objects))
+	)
+	for _, level := range deleteObjectLevels(objects) {
+		var wg sync.WaitGroup
+		var limit = make(chan struct{}, DeleteObjectsConcurrency)
+		for start := 0; start < len(level); {
+			var end = start + 1
+			for end < len(level) && objects[level[end]].Key == objects[level[start]].Key {
+				end++
+			}
+			wg.Add(1)
+			limit <- struct{}{}
+			go func(indexes []int) {
+				defer func() {
+					<-limit
+					wg.Done()
+				}()
+				for _, i := range indexes {
+					deleted[i], failures[i] = o.deleteObject(r, vol, objects[i])
+				}
+			}(level[start:end])
+			start = end
+		}
+		wg.Wait()
+	}
+
+	deletedObjects = make([]Deleted, 0, len(objects))
+	deletedErrors = make([]Error, 0)
+	for i := range objects {
+		if failures[i] != nil {
+			deletedErrors = append(deletedErrors, *failures[i])
+		} else {
+			deletedObjects = append(deletedObjects, *deleted[i])
+		}
+	}
+	return
+}
+
+// deleteObjectLevels groups the indexes of the objects by the depth of their keys, from the deepest level.
+func deleteObjectLevels(objects []Object) [][]int {
+	var depths = make(map[int][]int)
+	var maxDepth int
+	for i, object := range objects {
+		var depth = strings.Count(strings.Trim(object.Key, "/"), "/")
+		depths[depth] = append(depths[depth], i)
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	var levels = make([][]int, 0, len(depths))
+	for depth := maxDepth; depth >= 0; depth-- {
+		if level, ok := depths[depth]; ok {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// deleteObject deletes the object or the version of it for DeleteObjects, and returns either the
+// deleted result or the error of the object.
+func (o *ObjectNode) deleteObject(r *http.Request, vol *Volume, object Object) (*Deleted, *Error) {
+	var err error
+	var deleted = Deleted{Key: object.Key, VersionId: object.VersionId}
+	if object.VersionId != "" {
+		if lockErrorCode := o.checkDeleteObjectVersion(r, vol, object.Key, object.VersionId); lockErrorCode != nil {
+			return nil, &Error{Key: object.Key, VersionId: object.VersionId,
+				Code: lockErrorCode.ErrorCode, Message: lockErrorCode.ErrorMessage}
+		}
+		var deleteMarker bool
+		if deleteMarker, err = vol.DeleteObjectVersion(object.Key, object.VersionId); err == nil && deleteMarker {
+			deleted.DeleteMarker = "true"
+			deleted.DeleteMarkerVersionId = object.VersionId
+		}
+	} else {
+		var markerVersionId string
+		if markerVersionId, err = vol.DeleteObject(object.Key); err == nil && len(markerVersionId) > 0 {
+			deleted.DeleteMarker = "true"
+			deleted.DeleteMarkerVersionId = displayVersionId(markerVersionId)
+		}
+	}
+	if err != nil {
+		log.LogErrorf("deleteObjectsHandler: delete object failed: requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), object.Key, object.VersionId, err)
+		var errorCode = InternalErrorCode(err)
+		if err == syscall.ENOTEMPTY {
+			errorCode = AccessDenied
+		}
+		return nil, &Error{Key: object.Key, VersionId: object.VersionId, Code: errorCode.ErrorCode, Message: errorCode.ErrorMessage}
+	}
+
+	if object.VersionId == "" && deleted.DeleteMarker == "true" {
+		o.notifyObjectEvent(r, vol, EventObjectRemovedDeleteMarkerCreated,
+			NotificationObject{Key: object.Key, VersionId: deleted.DeleteMarkerVersionId})
+	} else {
+		o.notifyObjectEvent(r, vol, EventObjectRemovedDelete, NotificationObject{Key: object.Key, VersionId: object.VersionId})
+	}
+	log.LogDebugf("deleteObjectsHandler: delete object success: requestID(%v) volume(%v) path(%v) versionId(%v)",
+		GetRequestID(r), vol.Name(), object.Key, object.VersionId)
+	return &deleted, nil
+}
+
 func parseCopySourceInfo(r *http.Request) (sourceBucket, sourceObject string) {
 	var copySource = r.Header.Get(HeaderNameXAmzCopySource)
 	if strings.HasPrefix(copySource, "/") {
@@ -646,6 +698,19 @@ func parseCopySourceInfo(r *http.Request) (sourceBucket, sourceObject string) {
 	return
 }
 
+// parseCopySourceVersion returns the source of the copy, whose key is unescaped, and the version id
+// given by its "versionId" parameter.
+func parseCopySourceVersion(r *http.Request) (sourceBucket, sourceObject, versionId string) {
+	sourceBucket, sourceObject = parseCopySourceInfo(r)
+	if i := strings.Index(sourceObject, "?"+ParamVersionId+"="); i >= 0 {
+		sourceObject, versionId = sourceObject[:i], sourceObject[i+len(ParamVersionId)+2:]
+	}
+	if unescaped, err := url.PathUnescape(sourceObject); err == nil {
+		sourceObject = unescaped
+	}
+	return
+}
+
 // Copy object
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_CopyObject.html .
 func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -699,19 +764,36 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 	if len(metadataDirective) == 0 {
 		metadataDirective = MetadataDirectiveCopy
 	}
+	// the target object is encrypted only if it is requested, whether the source object is encrypted or not
+	var sseOpt *SSEOption
+	if sseOpt, errorCode = o.parseSSEOption(r); errorCode != nil {
+		return
+	}
+	// the object lock of the source object is not copied
+	var lockOpt *ObjectLockOption
+	if lockOpt, errorCode = parseObjectLockOption(r, vol); errorCode != nil {
+		return
+	}
+	// neither is the ACL of the source object
+	var acl *AccessControlPolicy
+	if acl, errorCode = parseObjectStandardACL(param, vol, r.Header.Get(HeaderNameXAmzACL)); errorCode != nil {
+		return
+	}
 	var opt = &PutFileOption{
 		MIMEType:     contentType,
 		Disposition:  contentDisposition,
 		Metadata:     metadata,
 		CacheControl: cacheControl,
 		Expires:      expires,
+		SSE:          sseOpt,
+		ACL:          acl,
 	}
 
 	sourceBucket, sourceObject := parseCopySourceInfo(r)
 
 	// check permission, must have read permission to source bucket
 	var userInfo *proto.UserInfo
-	if userInfo, err = o.getUserInfoByAccessKey(param.AccessKey()); err != nil {
+	if userInfo, err = o.getRequestUserInfo(r, param.AccessKey()); err != nil {
 		log.LogErrorf("copyObjectHandler: get user info from master error: requestID(%v), accessKey(%v), err(%v)",
 			GetRequestID(r), param.AccessKey(), err)
 		errorCode = InternalErrorCode(err)
@@ -728,6 +810,16 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		errorCode = AccessDenied
 		return
 	}
+	var sourceParam = *param
+	sourceParam.bucket, sourceParam.object = sourceBucket, sourceObject
+	sourceParam.resource = sourceBucket + "/" + strings.TrimPrefix(sourceObject, "/")
+	sourceParam.action = proto.OSSGetObjectAction
+	if allowed, _ := o.sessionPolicyAllows(r, &sourceParam); !allowed {
+		log.LogErrorf("copyObjectHandler: session policy not allowed to read source, requestID(%v), source bucket(%v), source file(%v)",
+			GetRequestID(r), sourceBucket, sourceObject)
+		errorCode = AccessDenied
+		return
+	}
 
 	// get object meta
 	var fileInfo *FSFileInfo
@@ -742,49 +834,21 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// get header
-	copyMatch := r.Header.Get(HeaderNameXAmzCopyMatch)
-	noneMatch := r.Header.Get(HeaderNameXAmzCopyNoneMatch)
-	modified := r.Header.Get(HeaderNameXAmzCopyModified)
-	unModified := r.Header.Get(HeaderNameXAmzCopyUnModified)
-
 	// response 412
-	if modified != "" {
-		fileModTime := fileInfo.ModifyTime
-		modifiedTime, err := parseTimeRFC1123(modified)
-		if err != nil {
-			log.LogErrorf("copyObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", GetRequestID(r), err)
-			errorCode = InvalidArgument
-			return
-		}
-		if fileModTime.Before(modifiedTime) {
-			log.LogInfof("copyObjectHandler: file modified time not after than specified time: requestID(%v)", GetRequestID(r))
-			errorCode = PreconditionFailed
-			return
-		}
-	}
-	if unModified != "" {
-		fileModTime := fileInfo.ModifyTime
-		unmodifiedTime, err := parseTimeRFC1123(unModified)
-		if err != nil {
-			log.LogErrorf("copyObjectHandler: parse RFC1123 time fail: requestID(%v) err(%v)", GetRequestID(r), err)
-			errorCode = InvalidArgument
-			return
-		}
-		if fileModTime.After(unmodifiedTime) {
-			log.LogInfof("copyObjectHandler: file modified time not before than specified time: requestID(%v)", GetRequestID(r))
-			errorCode = PreconditionFailed
-			return
-		}
+	if errorCode = checkCopySourcePreconditions(r, fileInfo); errorCode != nil {
+		return
 	}
-	if copyMatch != "" && fileInfo.ETag != copyMatch {
-		log.LogInfof("copyObjectHandler: eTag mismatched with specified: requestID(%v)", GetRequestID(r))
-		errorCode = PreconditionFailed
+
+	// Checking preconditions of overwriting the target object
+	if errorCode = checkWritePreconditions(r, vol, param.Object()); errorCode != nil {
 		return
 	}
-	if noneMatch != "" && fileInfo.ETag == noneMatch {
-		log.LogInfof("copyObjectHandler: eTag same with specified: requestID(%v)", GetRequestID(r))
-		errorCode = PreconditionFailed
+
+	// Checking bucket quota
+	if !vol.quota.allow(uint64(fileInfo.Size)) {
+		log.LogWarnf("copyObjectHandler: bucket quota exceeded: requestID(%v) volume(%v) path(%v) size(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), fileInfo.Size)
+		errorCode = QuotaExceeded
 		return
 	}
 
@@ -816,9 +880,24 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		errorCode = CopySourceSizeTooLarge
 		return
 	}
+	vol.quota.consume(uint64(fsFileInfo.Size))
+
+	// Place the object lock after the object is written
+	if lockOpt != nil {
+		if err = vol.setObjectLock(fsFileInfo.Inode, lockOpt); err != nil {
+			log.LogErrorf("copyObjectHandler: place object lock fail: requestID(%v) volume(%v) path(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), err)
+			errorCode = InternalErrorCode(err)
+			return
+		}
+		lockOpt.fillFileInfo(fsFileInfo)
+	}
+
+	o.notifyObjectEvent(r, vol, EventObjectCreatedCopy, newNotificationObject(param.Object(), fsFileInfo))
+	o.replicateObject(r, vol, param.Object(), fsFileInfo)
 
 	copyResult := CopyResult{
-		ETag:         fsFileInfo.ETag,
+		ETag:         wrapUnescapedQuot(fsFileInfo.ETag),
 		LastModified: formatTimeISO(fsFileInfo.ModifyTime),
 	}
 
@@ -832,6 +911,10 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 	// set response header
 	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
 	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(bytes))}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fsFileInfo.VersionId)}
+	}
+	setSSEResponseHeader(w, fsFileInfo)
 	_, _ = w.Write(bytes)
 	return
 }
@@ -920,7 +1003,7 @@ func (o *ObjectNode) getBucketV1Handler(w http.ResponseWriter, r *http.Request)
 			LastModified: formatTimeISO(file.ModifyTime),
 			ETag:         wrapUnescapedQuot(file.ETag),
 			Size:         int(file.Size),
-			StorageClass: StorageClassStandard,
+			StorageClass: displayStorageClass(file.StorageClass),
 			Owner:        bucketOwner,
 		}
 		contents = append(contents, content)
@@ -1067,7 +1150,7 @@ func (o *ObjectNode) getBucketV2Handler(w http.ResponseWriter, r *http.Request)
 				LastModified: formatTimeISO(file.ModifyTime),
 				ETag:         wrapUnescapedQuot(file.ETag),
 				Size:         int(file.Size),
-				StorageClass: StorageClassStandard,
+				StorageClass: displayStorageClass(file.StorageClass),
 				Owner:        bucketOwner,
 			}
 			contents = append(contents, content)
@@ -1159,12 +1242,14 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 	// Checking user-defined metadata
 	var metadata = ParseUserDefinedMetadata(r.Header)
 
-	// Get request MD5, if request MD5 is not empty, compute and verify it.
-	requestMD5 := r.Header.Get(HeaderNameContentMD5)
-
-	var checkMD5 bool
-	if len(requestMD5) > 0 {
-		checkMD5 = true
+	// Checking Content-MD5 and checksum, which are verified while the data is written
+	var checksum *checksumReader
+	if checksum, errorCode = newChecksumReader(r); errorCode != nil {
+		return
+	}
+	var body io.Reader = r.Body
+	if checksum != nil {
+		body = checksum
 	}
 
 	// Get the requested content-type.
@@ -1186,6 +1271,38 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Checking server-side encryption
+	var sseOpt *SSEOption
+	if sseOpt, errorCode = o.parseSSEOption(r); errorCode != nil {
+		return
+	}
+
+	// Checking object lock
+	var lockOpt *ObjectLockOption
+	if lockOpt, errorCode = parseObjectLockOption(r, vol); errorCode != nil {
+		return
+	}
+
+	// Checking canned ACL
+	var acl *AccessControlPolicy
+	if acl, errorCode = parseObjectStandardACL(param, vol, r.Header.Get(HeaderNameXAmzACL)); errorCode != nil {
+		return
+	}
+
+	// Checking preconditions of overwriting
+	if errorCode = checkWritePreconditions(r, vol, param.Object()); errorCode != nil {
+		return
+	}
+
+	// Checking bucket quota
+	var size = requestContentLength(r)
+	if !vol.quota.allow(size) {
+		log.LogWarnf("putObjectHandler: bucket quota exceeded: requestID(%v) volume(%v) path(%v) size(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), size)
+		errorCode = QuotaExceeded
+		return
+	}
+
 	// Audit file write
 	log.LogInfof("Audit: put object: requestID(%v) remote(%v) volume(%v) path(%v) type(%v)",
 		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), contentType)
@@ -1198,12 +1315,31 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 		Metadata:     metadata,
 		CacheControl: cacheControl,
 		Expires:      expires,
+		SSE:          sseOpt,
+		Checksum:     checksum.objectChecksum(),
+		ACL:          acl,
+		RequestID:    reqid.Parse(GetRequestID(r)),
 	}
-	fsFileInfo, err = vol.PutObject(param.Object(), r.Body, opt)
+	fsFileInfo, err = vol.PutObject(param.Object(), body, opt)
 	if err == syscall.EINVAL {
 		errorCode = ObjectModeConflict
 		return
 	}
+	if errorCode = checksumErrorCode(err); errorCode != nil {
+		log.LogWarnf("putObjectHandler: verify data fail: requestID(%v) volume(%v) path(%v) remote(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), getRequestIP(r), err)
+		return
+	}
+	if err == errInvalidChunkSignature {
+		log.LogWarnf("putObjectHandler: invalid chunk signature: requestID(%v) volume(%v) path(%v) remote(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), getRequestIP(r))
+		errorCode = SignatureDoesNotMatch
+		return
+	}
+	if err == errMalformedChunk {
+		errorCode = IncompleteBody
+		return
+	}
 	if err == io.ErrUnexpectedEOF {
 		log.LogWarnf("putObjectHandler: put object fail cause unexpected EOF: requestID(%v) volume(%v) path(%v) remote(%v) err(%v)",
 			GetRequestID(r), vol.Name(), param.Object(), getRequestIP(r), err)
@@ -1219,28 +1355,30 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// validate content MD5 value
-	if strings.HasSuffix(requestMD5, "==") {
-		var decoded []byte
-		if decoded, err = base64.StdEncoding.DecodeString(requestMD5); err != nil {
-			log.LogErrorf("putObjectHandler: decode request MD5 value fail: requestID(%v) raw(%v) err(%v)",
-				GetRequestID(r), requestMD5, err)
+	vol.quota.consume(uint64(fsFileInfo.Size))
+
+	// Place the object lock after the object is written
+	if lockOpt != nil {
+		if err = vol.setObjectLock(fsFileInfo.Inode, lockOpt); err != nil {
+			log.LogErrorf("putObjectHandler: place object lock fail: requestID(%v) volume(%v) path(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), err)
 			errorCode = InternalErrorCode(err)
 			return
 		}
-		requestMD5 = hex.EncodeToString(decoded)
-	}
-	// check content MD5
-	if checkMD5 && requestMD5 != fsFileInfo.ETag {
-		log.LogErrorf("putObjectHandler: MD5 validate fail: requestID(%v) requestMD5(%v) serverMD5(%v)",
-			r.URL.EscapedPath(), requestMD5, fsFileInfo.ETag)
-		errorCode = BadDigest
-		return
+		lockOpt.fillFileInfo(fsFileInfo)
 	}
 
+	o.notifyObjectEvent(r, vol, EventObjectCreatedPut, newNotificationObject(param.Object(), fsFileInfo))
+	o.replicateObject(r, vol, param.Object(), fsFileInfo)
+
 	// set response header
 	w.Header()[HeaderNameETag] = []string{wrapUnescapedQuot(fsFileInfo.ETag)}
 	w.Header()[HeaderNameContentLength] = []string{"0"}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fsFileInfo.VersionId)}
+	}
+	setSSEResponseHeader(w, fsFileInfo)
+	setChecksumResponseHeader(w, fsFileInfo.Checksum)
 	return
 }
 
@@ -1279,17 +1417,47 @@ func (o *ObjectNode) deleteObjectHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	var versionId = r.URL.Query().Get(ParamVersionId)
+
 	// Audit deletion
-	log.LogInfof("Audit: delete object: requestID(%v) remote(%v) volume(%v) path(%v)",
-		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object())
+	log.LogInfof("Audit: delete object: requestID(%v) remote(%v) volume(%v) path(%v) versionId(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), versionId)
 
-	err = vol.DeletePath(param.Object())
-	if err != nil {
+	if versionId != "" {
+		if errorCode = o.checkDeleteObjectVersion(r, vol, param.Object(), versionId); errorCode != nil {
+			return
+		}
+		var deleteMarker bool
+		if deleteMarker, err = vol.DeleteObjectVersion(param.Object(), versionId); err != nil {
+			log.LogErrorf("deleteObjectHandler: Volume delete version fail: "+
+				"requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)", GetRequestID(r), vol.Name(), param.Object(), versionId, err)
+			errorCode = InternalErrorCode(err)
+			return
+		}
+		if deleteMarker {
+			w.Header()[HeaderNameXAmzDeleteMarker] = []string{"true"}
+		}
+		o.notifyObjectEvent(r, vol, EventObjectRemovedDelete, NotificationObject{Key: param.Object(), VersionId: versionId})
+		w.Header()[HeaderNameXAmzVersionId] = []string{versionId}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var markerVersionId string
+	if markerVersionId, err = vol.DeleteObject(param.Object()); err != nil {
 		log.LogErrorf("deleteObjectHandler: Volume delete file fail: "+
 			"requestID(%v) volume(%v) path(%v) err(%v)", GetRequestID(r), vol.Name(), param.Object(), err)
 		errorCode = InternalErrorCode(err)
 		return
 	}
+	if len(markerVersionId) > 0 {
+		w.Header()[HeaderNameXAmzDeleteMarker] = []string{"true"}
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(markerVersionId)}
+		o.notifyObjectEvent(r, vol, EventObjectRemovedDeleteMarkerCreated,
+			NotificationObject{Key: param.Object(), VersionId: displayVersionId(markerVersionId)})
+	} else {
+		o.notifyObjectEvent(r, vol, EventObjectRemovedDelete, NotificationObject{Key: param.Object()})
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 	return
@@ -1324,21 +1492,23 @@ func (o *ObjectNode) getObjectTaggingHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	var xattrInfo *proto.XAttrInfo
-	if xattrInfo, err = vol.GetXAttr(param.object, XAttrKeyOSSTagging); err != nil {
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	var output *Tagging
+	var inodeVersionId string
+	if output, inodeVersionId, err = vol.GetObjectTagging(param.Object(), versionId); err != nil {
 		if err == syscall.ENOENT {
 			errorCode = NoSuchKey
+			if versionId != "" {
+				errorCode = NoSuchVersion
+			}
 			return
 		}
-		log.LogErrorf("getObjectTaggingHandler: Volume get XAttr fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		log.LogErrorf("getObjectTaggingHandler: Volume get tagging fail: requestID(%v) volume(%v) object(%v) versionId(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), param.Object(), versionId, err)
 		errorCode = InternalErrorCode(err)
 		return
 	}
 
-	ossTaggingData := xattrInfo.Get(XAttrKeyOSSTagging)
-
-	var output, _ = ParseTagging(string(ossTaggingData))
-
 	var encoded []byte
 	if encoded, err = MarshalXMLEntity(output); err != nil {
 		log.LogErrorf("getObjectTaggingHandler: encode output fail: requestID(%v) err(%v)", GetRequestID(r), err)
@@ -1346,6 +1516,11 @@ func (o *ObjectNode) getObjectTaggingHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(encoded))}
+	if len(inodeVersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(inodeVersionId)}
+	}
 	if _, err = w.Write(encoded); err != nil {
 		log.LogErrorf("getObjectTaggingHandler: write response fail: requestID(%v) err（%v)", GetRequestID(r), err)
 	}
@@ -1394,7 +1569,7 @@ func (o *ObjectNode) putObjectTaggingHandler(w http.ResponseWriter, r *http.Requ
 	var tagging = NewTagging()
 	if err = xml.Unmarshal(requestBody, tagging); err != nil {
 		log.LogWarnf("putObjectTaggingHandler: decode request body fail: requestID(%v) err(%v)", GetRequestID(r), err)
-		errorCode = InvalidArgument
+		errorCode = MalformedXML
 		return
 	}
 	validateRes, errorCode := tagging.Validate()
@@ -1403,18 +1578,25 @@ func (o *ObjectNode) putObjectTaggingHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = vol.SetXAttr(param.object, XAttrKeyOSSTagging, []byte(tagging.Encode()), false)
-
-	if err != nil {
-		log.LogErrorf("pubObjectTaggingHandler: volume set tagging fail: requestID(%v) volume(%v) object(%v) err(%v)",
-			GetRequestID(r), param.Bucket(), param.Object(), err)
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	var inodeVersionId string
+	if inodeVersionId, err = vol.PutObjectTagging(param.Object(), versionId, tagging); err != nil {
+		log.LogErrorf("pubObjectTaggingHandler: volume set tagging fail: requestID(%v) volume(%v) object(%v) versionId(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), param.Object(), versionId, err)
 		if err == syscall.ENOENT {
 			errorCode = NoSuchKey
+			if versionId != "" {
+				errorCode = NoSuchVersion
+			}
 		} else {
 			errorCode = InternalErrorCode(err)
 		}
 		return
 	}
+
+	if len(inodeVersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(inodeVersionId)}
+	}
 	return
 }
 
@@ -1447,13 +1629,25 @@ func (o *ObjectNode) deleteObjectTaggingHandler(w http.ResponseWriter, r *http.R
 		errorCode = NoSuchBucket
 		return
 	}
-	if err = vol.DeleteXAttr(param.object, XAttrKeyOSSTagging); err != nil {
-		log.LogErrorf("deleteObjectTaggingHandler: volume delete tagging fail: requestID(%v) volume(%v) object(%v) err(%v)",
-			GetRequestID(r), param.Bucket(), param.Object(), err)
-		errorCode = InternalErrorCode(err)
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	var inodeVersionId string
+	if inodeVersionId, err = vol.DeleteObjectTagging(param.Object(), versionId); err != nil {
+		log.LogErrorf("deleteObjectTaggingHandler: volume delete tagging fail: requestID(%v) volume(%v) object(%v) versionId(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), param.Object(), versionId, err)
+		if err == syscall.ENOENT {
+			errorCode = NoSuchKey
+			if versionId != "" {
+				errorCode = NoSuchVersion
+			}
+		} else {
+			errorCode = InternalErrorCode(err)
+		}
 		return
 	}
 
+	if len(inodeVersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(inodeVersionId)}
+	}
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
@@ -1508,6 +1702,11 @@ func (o *ObjectNode) putObjectXAttrHandler(w http.ResponseWriter, r *http.Reques
 	if len(key) == 0 {
 		return
 	}
+	// the object lock can only be changed through the retention and legal hold APIs
+	if isObjectLockXAttrKey(key) {
+		errorCode = AccessDenied
+		return
+	}
 
 	if err = vol.SetXAttr(param.object, key, []byte(value), true); err != nil {
 		if err == syscall.ENOENT {
@@ -1616,6 +1815,11 @@ func (o *ObjectNode) deleteObjectXAttrHandler(w http.ResponseWriter, r *http.Req
 		errorCode = InvalidArgument
 		return
 	}
+	// the object lock can only be changed through the retention and legal hold APIs
+	if isObjectLockXAttrKey(xattrKey) {
+		errorCode = AccessDenied
+		return
+	}
 
 	if err = vol.DeleteXAttr(param.object, xattrKey); err != nil {
 		if err == syscall.ENOENT {
@@ -0,0 +1,35 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeleteObjectLevels(t *testing.T) {
+	var objects = []Object{
+		{Key: "backup/2020/0102.bak"},
+		{Key: "backup/2020/"},
+		{Key: "backup/0101.bak"},
+		{Key: "backup/"},
+		{Key: "a.txt"},
+	}
+	var levels = deleteObjectLevels(objects)
+	var expect = [][]int{{0}, {1, 2}, {3, 4}}
+	if !reflect.DeepEqual(levels, expect) {
+		t.Fatalf("levels mismatch: expect(%v) actual(%v)", expect, levels)
+	}
+}
@@ -25,8 +25,10 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
-	"github.com/google/uuid"
+	"github.com/cubefs/cubefs/util/ratelimit"
+	"github.com/cubefs/cubefs/util/reqid"
 	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -66,27 +68,12 @@ func generateWarnDetail(r *http.Request, errorInfo string) string {
 // Workflow:
 //   request → [pre-handle] → [next handler] → [post-handle] → response
 func (o *ObjectNode) traceMiddleware(next http.Handler) http.Handler {
-	var generateRequestID = func() (string, error) {
-		var uUID uuid.UUID
-		var err error
-		if uUID, err = uuid.NewRandom(); err != nil {
-			return "", err
-		}
-		return strings.ReplaceAll(uUID.String(), "-", ""), nil
-	}
 	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
 		// ===== pre-handle start =====
-		var requestID string
-		if requestID, err = generateRequestID(); err != nil {
-			log.LogErrorf("traceMiddleware: generate request ID fail, remote(%v) url(%v) err(%v)",
-				r.RemoteAddr, r.URL.String(), err)
-			_ = InternalErrorCode(err).ServeResponse(w, r)
-			// export ump warn info
-			exporter.Warning(generateWarnDetail(r, err.Error()))
-			return
-		}
+		// the request ID is carried by the requests to the data nodes, see reqid
+		var requestID = reqid.New().String()
 
 		// store request ID to context and write to header
 		SetRequestID(r, requestID)
@@ -103,6 +90,17 @@ func (o *ObjectNode) traceMiddleware(next http.Handler) http.Handler {
 			metric.Set(err)
 		}()
 
+		// record the response for the server access logs
+		var lw = &accessLogWriter{ResponseWriter: w}
+		w = lw
+
+		// count the bytes received for the audit record
+		var auditBody *auditBodyReader
+		if o.auditLogger != nil {
+			auditBody = &auditBodyReader{ReadCloser: r.Body}
+			r.Body = auditBody
+		}
+
 		// Check action is whether enabled.
 		if !action.IsNone() && !o.disabledActions.Contains(action) {
 			// next
@@ -113,11 +111,20 @@ func (o *ObjectNode) traceMiddleware(next http.Handler) http.Handler {
 			_ = AccessDenied.ServeResponse(w, r)
 		}
 
+		o.logAccess(lw, r, startTime)
+		if o.auditLogger != nil {
+			var rec = newAuditRecord(lw, r, auditBody, startTime)
+			o.auditLogger.Log(rec.Bucket, rec)
+		}
+
 		// failed request monitor
 		var statusCode = GetStatusCodeFromContext(r)
 		if IsMonitoredStatusCode(statusCode) {
 			exporter.NewTPCnt(fmt.Sprintf("failed_%v", statusCode)).Set(nil)
-			exporter.Warning(generateWarnDetail(r, getResponseErrorMessage(r)))
+			// the throttled requests are exported by the rate limiter instead of warnings
+			if statusCode != SlowDown.StatusCode {
+				exporter.Warning(generateWarnDetail(r, getResponseErrorMessage(r)))
+			}
 		}
 
 		// ===== post-handle start =====
@@ -151,11 +158,33 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
+			// the requests to the website endpoints are anonymous and checked by the website handler
+			if isWebsiteRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// the signature of the POST form is verified by the handler after the fields are read
+			if isPostObjectRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
 
 			var (
 				pass bool
 				err  error
 			)
+			// check the session token of the temporary credentials
+			if _, err = o.requestSession(r); err != nil {
+				log.LogDebugf("authMiddleware: invalid session token: requestID(%v) remote(%v) err(%v)",
+					GetRequestID(r), getRequestIP(r), err)
+				if err == errExpiredSessionToken {
+					_ = ExpiredToken.ServeResponse(w, r)
+					return
+				}
+				_ = InvalidToken.ServeResponse(w, r)
+				return
+			}
+
 			//  check auth type
 			if isHeaderUsingSignatureAlgorithmV4(r) {
 				// using signature algorithm version 4 in header
@@ -169,6 +198,13 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 			} else if isUrlUsingSignatureAlgorithmV4(r) {
 				// using signature algorithm version 4 in url parameter
 				pass, err = o.validateUrlBySignatureAlgorithmV4(r)
+			} else {
+				// The anonymous requests are not signed, which are allowed only by the bucket
+				// policy or the bucket ACL in the policy check.
+				log.LogDebugf("authMiddleware: anonymous request: requestID(%v) remote(%v) action(%v)",
+					GetRequestID(r), getRequestIP(r), currentAction.Name())
+				next.ServeHTTP(w, r)
+				return
 			}
 
 			if err != nil {
@@ -195,7 +231,7 @@ func (o *ObjectNode) policyCheckMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			action := ActionFromRouteName(mux.CurrentRoute(r).GetName())
-			if !action.IsNone() && o.signatureIgnoredActions.Contains(action) {
+			if !action.IsNone() && o.signatureIgnoredActions.Contains(action) || isWebsiteRequest(r) || isPostObjectRequest(r) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -206,14 +242,16 @@ func (o *ObjectNode) policyCheckMiddleware(next http.Handler) http.Handler {
 }
 
 // ContentMiddleware returns a middleware handler to process reader for content.
-// If the request contains the "X-amz-Decoded-Content-Length" header, it means that the data
-// in the request body is chunked. Use ChunkedReader to parse the data.
+// If the request contains the "X-amz-Decoded-Content-Length" header or a streaming content hash,
+// it means that the data in the request body is sent in aws-chunked encoding. The signed chunks
+// are decoded and verified by the reader set in the auth middleware, and the others are decoded here.
 // Workflow:
 //   request → [pre-handle] → [next handler] → response
 func (o *ObjectNode) contentMiddleware(next http.Handler) http.Handler {
 	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
-		if len(r.Header) > 0 && len(r.Header.Get(http.CanonicalHeaderKey(HeaderNameXAmzDecodeContentLength))) > 0 {
-			r.Body = NewClosableChunkedReader(r.Body)
+		if _, decoded := r.Body.(*awsChunkedReader); !decoded &&
+			(r.Header.Get(HeaderNameXAmzDecodeContentLength) != "" || isStreamingPayload(getContentHash(r.Header))) {
+			r.Body = newAWSChunkedReader(r.Body, nil, requestTrailer(r))
 			log.LogDebugf("contentMiddleware: chunk reader inited: requestID(%v)", GetRequestID(r))
 		}
 		next.ServeHTTP(w, r)
@@ -221,6 +259,49 @@ func (o *ObjectNode) contentMiddleware(next http.Handler) http.Handler {
 	return handlerFunc
 }
 
+// RateLimitMiddleware returns a middleware handler to limit the requests of the access keys.
+// The requests over the QPS limit, or arriving while the bandwidth limit has been used up, are
+// rejected with SlowDown. The uploads and the downloads of the allowed requests are throttled
+// by the bandwidth limit. The limits of the cluster shared by the ObjectNodes are applied as well
+// if the cluster limiter is enabled.
+// Workflow:
+//   request → [pre-handle] → [next handler] → response
+func (o *ObjectNode) rateLimitMiddleware(next http.Handler) http.Handler {
+	var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		var accessKey = parseRequestAuthInfo(r).accessKey
+		var limiter = o.rateLimiter.limiter(accessKey)
+		if limiter == nil && o.clusterLimiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var limitType string
+		var bandwidth *rate.Limiter
+		if limiter != nil {
+			limitType = limiter.allow()
+			bandwidth = limiter.bandwidth
+		}
+		if limitType == "" && !o.clusterLimiter.Allow(ratelimit.Key(ratelimit.ScopeS3QPS, accessKey)) {
+			limitType = rateLimitTypeClusterQPS
+		}
+		if limitType != "" {
+			log.LogDebugf("rateLimitMiddleware: request throttled: requestID(%v) accessKey(%v) limit(%v)",
+				GetRequestID(r), accessKey, limitType)
+			exportThrottledRequest(accessKey, limitType)
+			_ = SlowDown.ServeResponse(w, r)
+			return
+		}
+		if bandwidth != nil || o.clusterLimiter != nil {
+			var clusterKey = ratelimit.Key(ratelimit.ScopeS3Bandwidth, accessKey)
+			r.Body = &throttledReader{ReadCloser: r.Body, ctx: r.Context(), limiter: bandwidth,
+				cluster: o.clusterLimiter, clusterKey: clusterKey}
+			w = &throttledWriter{ResponseWriter: w, ctx: r.Context(), limiter: bandwidth,
+				cluster: o.clusterLimiter, clusterKey: clusterKey}
+		}
+		next.ServeHTTP(w, r)
+	}
+	return handlerFunc
+}
+
 // Http's Expect header is a special header. When nginx is used as the reverse proxy in the front
 // end of ObjectNode, nginx will process the Expect header information in advance, send the http
 // status code 100 to the client, and will not forward this header information to ObjectNode.
@@ -242,11 +323,16 @@ func (o *ObjectNode) expectMiddleware(next http.Handler) http.Handler {
 }
 
 // CORSMiddleware returns a middleware handler to support CORS request.
-// This handler will write following header into response:
-//   Access-Control-Allow-Origin [*]
-//   Access-Control-Allow-Headers [*]
-//   Access-Control-Allow-Methods [*]
-//   Access-Control-Max-Age [0]
+// The preflight requests, which are OPTIONS requests with the Access-Control-Request-Method
+// header and are not signed by the browsers, are responded by this handler with following
+// headers if a CORS rule of the bucket allows them, or are denied otherwise:
+//   Access-Control-Allow-Origin
+//   Access-Control-Allow-Methods
+//   Access-Control-Allow-Headers
+//   Access-Control-Expose-Headers
+//   Access-Control-Max-Age
+// The actual requests from other origins are responded with the allow origin and the expose
+// headers of the CORS rule.
 // Workflow:
 //   request → [pre-handle] → [next handler] → response
 func (o *ObjectNode) corsMiddleware(next http.Handler) http.Handler {
@@ -254,7 +340,8 @@ func (o *ObjectNode) corsMiddleware(next http.Handler) http.Handler {
 
 		var err error
 		var param = ParseRequestParam(r)
-		if param.Bucket() == "" {
+		var origin = r.Header.Get(Origin)
+		if param.Bucket() == "" || origin == "" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -263,31 +350,46 @@ func (o *ObjectNode) corsMiddleware(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
+		cors, _ := vol.metaLoader.loadCors()
+
+		var setupCORSHeader = func(rule *CORSRule, writer http.ResponseWriter) {
+			if rule.allowAnyOrigin() {
+				writer.Header()[HeaderNameAccessControlAllowOrigin] = []string{"*"}
+			} else {
+				writer.Header()[HeaderNameAccessControlAllowOrigin] = []string{origin}
+				writer.Header()[HeaderNameAccessControlAllowCredentials] = []string{"true"}
+			}
+			if len(rule.ExposeHeader) > 0 {
+				writer.Header()[HeaderNamrAccessControlExposeHeaders] = []string{strings.Join(rule.ExposeHeader, ", ")}
+			}
+			writer.Header()[HeaderNameVary] = []string{strings.Join([]string{Origin,
+				HeaderNameAccessControlRequestHeaders, HeaderNameAccessControlRequestMethod}, ", ")}
+		}
 
-		var setupCORSHeader = func(volume *Volume, writer http.ResponseWriter, request *http.Request) {
-			origin := request.Header.Get(Origin)
-			method := request.Header.Get(HeaderNameAccessControlRequestMethod)
-			headerStr := request.Header.Get(HeaderNameAccessControlRequestHeaders)
-			if origin == "" || method == "" {
+		if method := r.Header.Get(HeaderNameAccessControlRequestMethod); r.Method == http.MethodOptions && method != "" {
+			headers := parseCORSRequestHeaders(r.Header.Get(HeaderNameAccessControlRequestHeaders))
+			rule := cors.matchRule(origin, method, headers)
+			if rule == nil {
+				log.LogDebugf("corsMiddleware: preflight request not allowed: requestID(%v) volume(%v) origin(%v) method(%v) headers(%v)",
+					GetRequestID(r), vol.Name(), origin, method, headers)
+				_ = CORSForbidden.ServeResponse(w, r)
 				return
 			}
-			cors, _ := volume.metaLoader.loadCors()
-			if cors != nil {
-				headers := strings.Split(headerStr, ",")
-				for _, corsRule := range cors.CORSRule {
-					if corsRule.match(origin, method, headers) {
-						// write access control allow headers
-						writer.Header()[HeaderNameAccessControlAllowOrigin] = []string{origin}
-						writer.Header()[HeaderNameAccessControlMaxAge] = []string{strconv.Itoa(int(corsRule.MaxAgeSeconds))}
-						writer.Header()[HeaderNameAccessControlAllowMethods] = []string{strings.Join(corsRule.AllowedMethod, ",")}
-						writer.Header()[HeaderNameAccessControlAllowHeaders] = []string{strings.Join(corsRule.AllowedHeader, ",")}
-						writer.Header()[HeaderNamrAccessControlExposeHeaders] = []string{strings.Join(corsRule.ExposeHeader, ",")}
-						return
-					}
-				}
+			setupCORSHeader(rule, w)
+			w.Header()[HeaderNameAccessControlAllowMethods] = []string{strings.Join(rule.AllowedMethod, ", ")}
+			if len(headers) > 0 {
+				w.Header()[HeaderNameAccessControlAllowHeaders] = []string{strings.Join(headers, ", ")}
+			}
+			if rule.MaxAgeSeconds > 0 {
+				w.Header()[HeaderNameAccessControlMaxAge] = []string{strconv.Itoa(int(rule.MaxAgeSeconds))}
 			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if rule := cors.matchRule(origin, r.Method, nil); rule != nil {
+			setupCORSHeader(rule, w)
 		}
-		setupCORSHeader(vol, w, r)
 		next.ServeHTTP(w, r)
 		return
 	})
@@ -0,0 +1,86 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Every request of the S3 APIs served by the ObjectNode is recorded to the audit targets configured
+// by the "auditTargets" configuration, in a JSON record per request which has the same request ID
+// as the x-amz-request-id header of the response. The targets are the sinks of package audit, such
+// as the files of JSON lines, the HTTP webhooks or the topics of Kafka, and the records are keyed by
+// their buckets.
+
+// auditRecord is the audit record of a request.
+type auditRecord struct {
+	Time          time.Time `json:"time"`
+	RequestID     string    `json:"requestID"`
+	RemoteIP      string    `json:"remoteIP"`
+	AccessKey     string    `json:"accessKey,omitempty"` // empty for the anonymous requests
+	Bucket        string    `json:"bucket,omitempty"`
+	Key           string    `json:"key,omitempty"`
+	Action        string    `json:"action"`
+	Method        string    `json:"method"`
+	URI           string    `json:"uri"`
+	StatusCode    int       `json:"statusCode"`
+	ErrorCode     string    `json:"errorCode,omitempty"`
+	BytesReceived int64     `json:"bytesReceived"`
+	BytesSent     int64     `json:"bytesSent"`
+	LatencyMs     int64     `json:"latencyMs"`
+	UserAgent     string    `json:"userAgent,omitempty"`
+}
+
+// auditBodyReader counts the bytes of the request body received for the audit record.
+type auditBodyReader struct {
+	io.ReadCloser
+	bytesReceived int64
+}
+
+func (r *auditBodyReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.bytesReceived += int64(n)
+	return
+}
+
+// newAuditRecord returns the audit record of the request responded by the writer.
+func newAuditRecord(w *accessLogWriter, r *http.Request, body *auditBodyReader, startTime time.Time) *auditRecord {
+	var param = ParseRequestParam(r)
+	var rec = &auditRecord{
+		Time:       startTime.UTC(),
+		RequestID:  GetRequestID(r),
+		RemoteIP:   getRequestIP(r),
+		AccessKey:  param.AccessKey(),
+		Bucket:     param.Bucket(),
+		Key:        param.Object(),
+		Action:     param.Action().Name(),
+		Method:     r.Method,
+		URI:        r.RequestURI,
+		StatusCode: w.statusCode,
+		ErrorCode:  getResponseErrorCode(r),
+		BytesSent:  w.bytesSent,
+		LatencyMs:  int64(time.Since(startTime) / time.Millisecond),
+		UserAgent:  r.UserAgent(),
+	}
+	if rec.StatusCode == 0 {
+		rec.StatusCode = http.StatusOK
+	}
+	if body != nil {
+		rec.BytesReceived = body.bytesReceived
+	}
+	return rec
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/audit"
+	"github.com/gorilla/mux"
+)
+
+func TestParseAuditTargets(t *testing.T) {
+	var items = []interface{}{
+		map[string]interface{}{"type": "webhook", "endpoint": "http://127.0.0.1:17410/audit"},
+		map[string]interface{}{"type": "kafka", "brokers": []interface{}{"127.0.0.1:9092"}, "topic": "audit"},
+	}
+	configs, err := audit.ParseSinkConfigs(items)
+	if err != nil || len(configs) != 2 || configs[1].Topic != "audit" {
+		t.Fatalf("parse audit targets mismatch: configs(%v) err(%v)", configs, err)
+	}
+	l, err := audit.NewLogger("objectnode", configs)
+	if err != nil {
+		t.Fatalf("new audit logger fail: err(%v)", err)
+	}
+	l.Stop()
+	for i, config := range []*audit.SinkConfig{{Type: "file"}, {Type: "webhook"}, {Type: "kafka", Topic: "audit"}, {Type: "syslog"}} {
+		if _, err = audit.NewLogger("objectnode", []*audit.SinkConfig{config}); err == nil {
+			t.Fatalf("case %v: expect invalid audit target error", i)
+		}
+	}
+}
+
+func TestAuditLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("create temp dir fail: err(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	var file = path.Join(dir, "audit.log")
+	l, err := audit.NewLogger("objectnode", []*audit.SinkConfig{{Type: audit.SinkFile, Path: file}})
+	if err != nil {
+		t.Fatalf("new audit logger fail: err(%v)", err)
+	}
+
+	var r = httptest.NewRequest(http.MethodPut, "/bucket/a.txt", strings.NewReader("hello"))
+	r = mux.SetURLVars(r, map[string]string{"bucket": "bucket", "object": "a.txt"})
+	SetRequestID(r, "1234")
+	SetRequestAction(r, proto.OSSPutObjectAction)
+	var body = &auditBodyReader{ReadCloser: r.Body}
+	if _, err = ioutil.ReadAll(body); err != nil {
+		t.Fatalf("read body fail: err(%v)", err)
+	}
+	var w = &accessLogWriter{ResponseWriter: httptest.NewRecorder()}
+	_, _ = w.Write([]byte("ok"))
+	var rec = newAuditRecord(w, r, body, time.Now())
+	l.Log(rec.Bucket, rec)
+	// the records queued are delivered when the logger stops
+	l.Stop()
+
+	data, _ := ioutil.ReadFile(file)
+	rec = &auditRecord{}
+	if err = json.Unmarshal(data, rec); err != nil {
+		t.Fatalf("unmarshal audit record fail: data(%s) err(%v)", data, err)
+	}
+	if rec.RequestID != "1234" || rec.Action != "PutObject" || rec.Method != http.MethodPut || rec.StatusCode != http.StatusOK ||
+		rec.BytesReceived != 5 || rec.BytesSent != 2 || rec.URI != "/bucket/a.txt" || rec.Bucket != "bucket" || rec.Key != "a.txt" {
+		t.Fatalf("audit record mismatch: %s", data)
+	}
+}
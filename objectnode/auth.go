@@ -25,6 +25,7 @@ const (
 	SignatrueV4          = "signature_v4"
 	PresignedV2          = "presigned_v2"
 	PresignedV4          = "presigned_v4"
+	PostPolicy           = "post_policy"
 )
 
 type RequestAuthInfo struct {
@@ -58,6 +59,10 @@ func parseRequestAuthInfo(r *http.Request) *RequestAuthInfo {
 		if ai != nil {
 			auth.accessKey = ai.Credential.AccessKey
 		}
+	} else if accessKey := getPostAccessKey(r); accessKey != "" {
+		// the policy of the POST form is signed, which is verified by the handler
+		auth.authType = PostPolicy
+		auth.accessKey = accessKey
 	}
 
 	return auth
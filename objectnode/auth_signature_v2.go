@@ -181,7 +181,7 @@ func (o *ObjectNode) validateHeaderBySignatureAlgorithmV2(r *http.Request) (bool
 	var accessKey = authInfo.accessKeyId
 	var secretKey string
 	var bucket = mux.Vars(r)["bucket"]
-	if userInfo, err := o.getUserInfoByAccessKey(accessKey); err == nil {
+	if userInfo, err := o.getRequestUserInfo(r, accessKey); err == nil {
 		secretKey = userInfo.SecretKey
 	} else if (err == proto.ErrUserNotExists || err == proto.ErrAccessKeyNotExists) &&
 		len(bucket) > 0 && GetActionFromContext(r) != proto.OSSCreateBucketAction {
@@ -295,7 +295,7 @@ func (o *ObjectNode) validateUrlBySignatureAlgorithmV2(r *http.Request) (bool, e
 
 	var secretKey string
 	var bucket = mux.Vars(r)["bucket"]
-	if userInfo, err := o.getUserInfoByAccessKey(accessKey); err == nil {
+	if userInfo, err := o.getRequestUserInfo(r, accessKey); err == nil {
 		secretKey = userInfo.SecretKey
 	} else if (err == proto.ErrUserNotExists || err == proto.ErrAccessKeyNotExists) &&
 		len(bucket) > 0 && GetActionFromContext(r) != proto.OSSCreateBucketAction {
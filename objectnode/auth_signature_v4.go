@@ -18,8 +18,12 @@
 package objectnode
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sort"
@@ -46,6 +50,7 @@ const (
 	XAmzAlgorithm     = "X-Amz-Algorithm"
 	XAmzDate          = "X-Amz-Date"
 	XAmzExpires       = "X-Amz-Expires"
+	XAmzSecurityToken = "X-Amz-Security-Token"
 
 	SignatureV4Algorithm = "AWS4-HMAC-SHA256"
 	SignatureV4Request   = "aws4-request"
@@ -114,7 +119,7 @@ func (o *ObjectNode) validateHeaderBySignatureAlgorithmV4(r *http.Request) (bool
 	var accessKey = req.Credential.AccessKey
 	var secretKey string
 	var bucket = mux.Vars(r)["bucket"]
-	if userInfo, err := o.getUserInfoByAccessKey(accessKey); err == nil {
+	if userInfo, err := o.getRequestUserInfo(r, accessKey); err == nil {
 		secretKey = userInfo.SecretKey
 	} else if (err == proto.ErrUserNotExists || err == proto.ErrAccessKeyNotExists) &&
 		len(bucket) > 0 && GetActionFromContext(r) != proto.OSSCreateBucketAction {
@@ -144,6 +149,14 @@ func (o *ObjectNode) validateHeaderBySignatureAlgorithmV4(r *http.Request) (bool
 		return false, nil
 	}
 
+	// The chunks of the signed streaming payload are verified while the body is read.
+	if contentHash := getContentHash(r.Header); contentHash == StreamingSignedPayload || contentHash == StreamingSignedPayloadTrailer {
+		cred := req.Credential
+		signingKey := buildSigningKey(SCHEME, secretKey, cred.Date, cred.Region, cred.Service, TERMINATOR)
+		scope := buildScope(cred.Date, cred.Region, cred.Service, TERMINATOR)
+		r.Body = newAWSChunkedReader(r.Body, newChunkSigner(signingKey, getStartTime(r.Header), scope, req.Signature), requestTrailer(r))
+	}
+
 	return true, nil
 }
 
@@ -174,7 +187,7 @@ func (o *ObjectNode) validateUrlBySignatureAlgorithmV4(r *http.Request) (pass bo
 	var accessKey = req.Credential.AccessKey
 	var secretKey string
 	var bucket = mux.Vars(r)["bucket"]
-	if userInfo, err := o.getUserInfoByAccessKey(accessKey); err == nil {
+	if userInfo, err := o.getRequestUserInfo(r, accessKey); err == nil {
 		secretKey = userInfo.SecretKey
 	} else if (err == proto.ErrUserNotExists || err == proto.ErrAccessKeyNotExists) &&
 		len(bucket) > 0 && GetActionFromContext(r) != proto.OSSCreateBucketAction {
@@ -448,6 +461,10 @@ func createCanonicalQueryV4(req *signatureRequestV4) string {
 		if strings.Contains(key, "x-amz-server-side-") {
 			newQuery.Set(k, v[0])
 		}
+		if k == XAmzSecurityToken {
+			newQuery.Set(k, v[0])
+			continue
+		}
 		if strings.HasPrefix(key, "x-amz") {
 			continue
 		}
@@ -476,6 +493,17 @@ func getContentHash(headers http.Header) (contentHash string) {
 	return
 }
 
+// getPayloadHash returns the hash of the body of the STS request, which is kept for the handler.
+func getPayloadHash(r *http.Request) string {
+	var data []byte
+	if r.Body != nil {
+		data, _ = ioutil.ReadAll(io.LimitReader(r.Body, STSRequestLimitSize+1))
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func getEncodeQuery(r *http.Request) string {
 	return r.URL.Query().Encode()
 }
@@ -488,13 +516,17 @@ func calculateSignatureV4(r *http.Request, cred credential, secretKey string, si
 	canonicalHeaderString := buildCanonicalHeaderString(r.Host, headers, signedHeaders)
 	headerNames := getCanonicalHeaderNames(signedHeaders)
 	contentHash := getContentHash(headers)
+	if contentHash == "" && cred.Service == STSService {
+		// The STS clients do not give the hash of the payload in the header.
+		contentHash = getPayloadHash(r)
+	}
 	encodeQuery := getEncodeQuery(r)
 	canonicalURI := getCanonicalURI(r)
 	canonicalRequest := createCanonicalRequestString(
 		r.Method, canonicalURI, encodeQuery, canonicalHeaderString, headerNames, contentHash)
 
-	signingKey := buildSigningKey(SCHEME, secretKey, cred.Date, cred.Region, SERVICE, TERMINATOR)
-	scope := buildScope(cred.Date, cred.Region, SERVICE, TERMINATOR)
+	signingKey := buildSigningKey(SCHEME, secretKey, cred.Date, cred.Region, cred.Service, TERMINATOR)
+	scope := buildScope(cred.Date, cred.Region, cred.Service, TERMINATOR)
 
 	var timestamp = getStartTime(headers)
 	stringToSign := buildStringToSign(SignatureV4Algorithm, timestamp, scope, canonicalRequest)
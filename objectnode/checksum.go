@@ -0,0 +1,246 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The uploaded data of PutObject and UploadPart are verified by the Content-MD5 header and the
+// x-amz-checksum-<algorithm> header or trailer while the body is read, and the request fails
+// before the data is committed if they do not match. The checksum of the object is stored with
+// the object and returned by GetObject and HeadObject if the checksum mode is enabled.
+
+const (
+	ChecksumAlgorithmCRC32  = "CRC32"
+	ChecksumAlgorithmCRC32C = "CRC32C"
+	ChecksumAlgorithmSHA1   = "SHA1"
+	ChecksumAlgorithmSHA256 = "SHA256"
+
+	ChecksumModeEnabled = "ENABLED"
+)
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errBadDigest       = errors.New("bad content MD5")
+	errBadChecksum     = errors.New("bad checksum")
+	errMissingChecksum = errors.New("missing checksum trailer")
+)
+
+var checksumAlgorithms = []string{ChecksumAlgorithmCRC32, ChecksumAlgorithmCRC32C, ChecksumAlgorithmSHA1, ChecksumAlgorithmSHA256}
+
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case ChecksumAlgorithmCRC32:
+		return crc32.NewIEEE()
+	case ChecksumAlgorithmCRC32C:
+		return crc32.New(crc32cTable)
+	case ChecksumAlgorithmSHA1:
+		return sha1.New()
+	case ChecksumAlgorithmSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+func checksumHeaderName(algorithm string) string {
+	return HeaderNameXAmzChecksumPrefix + strings.ToLower(algorithm)
+}
+
+// ObjectChecksum is the checksum of the data of an object, which is encoded in base64.
+type ObjectChecksum struct {
+	Algorithm string
+	Value     string
+}
+
+// Encode encodes the checksum as the value of the extended attribute, such as "CRC32:NhCmhg==".
+func (c *ObjectChecksum) Encode() string {
+	return c.Algorithm + ":" + c.Value
+}
+
+// ParseObjectChecksum parses the checksum encoded by Encode, nil is returned if it is malformed.
+func ParseObjectChecksum(raw string) *ObjectChecksum {
+	var items = strings.SplitN(raw, ":", 2)
+	if len(items) != 2 || newChecksumHash(items[0]) == nil || items[1] == "" {
+		return nil
+	}
+	return &ObjectChecksum{Algorithm: items[0], Value: items[1]}
+}
+
+// checksumReader verifies the body of the request when it is read to the end.
+type checksumReader struct {
+	io.Reader
+	r          *http.Request
+	contentMD5 []byte // nil if Content-MD5 is not given
+	md5        hash.Hash
+	checksum   *ObjectChecksum // nil if no checksum is given, the value is set once verified
+	expected   string          // empty if the checksum is sent in the trailer
+	hash       hash.Hash
+	verified   bool
+}
+
+// newChecksumReader returns the reader to verify the body of the request by the Content-MD5 header
+// and the checksum header or trailer, which is nil if nothing is to be verified.
+func newChecksumReader(r *http.Request) (reader *checksumReader, errorCode *ErrorCode) {
+	reader = &checksumReader{Reader: r.Body, r: r}
+	if raw := r.Header.Get(HeaderNameContentMD5); raw != "" {
+		if reader.contentMD5 = parseContentMD5(raw); reader.contentMD5 == nil {
+			return nil, InvalidDigest
+		}
+		reader.md5 = md5.New()
+	}
+
+	var algorithm string
+	for _, alg := range checksumAlgorithms {
+		if value := r.Header.Get(checksumHeaderName(alg)); value != "" {
+			if algorithm != "" {
+				return nil, MultipleChecksums
+			}
+			algorithm, reader.expected = alg, value
+		}
+	}
+	if trailer := strings.ToLower(strings.TrimSpace(r.Header.Get(HeaderNameXAmzTrailer))); strings.HasPrefix(trailer, HeaderNameXAmzChecksumPrefix) {
+		if algorithm != "" {
+			return nil, MultipleChecksums
+		}
+		algorithm = strings.ToUpper(strings.TrimPrefix(trailer, HeaderNameXAmzChecksumPrefix))
+		if newChecksumHash(algorithm) == nil {
+			return nil, InvalidChecksum
+		}
+	}
+	if sdkAlgorithm := strings.ToUpper(r.Header.Get(HeaderNameXAmzSdkChecksumAlgorithm)); sdkAlgorithm != "" &&
+		algorithm != "" && sdkAlgorithm != algorithm {
+		return nil, InvalidChecksum
+	}
+	if algorithm != "" {
+		reader.checksum = &ObjectChecksum{Algorithm: algorithm}
+		reader.hash = newChecksumHash(algorithm)
+		if reader.expected != "" && !validChecksumValue(algorithm, reader.expected) {
+			return nil, InvalidChecksum
+		}
+	}
+
+	if reader.md5 == nil && reader.hash == nil {
+		return nil, nil
+	}
+	return reader, nil
+}
+
+// parseContentMD5 parses the Content-MD5 in base64, or in hex as the former versions accept.
+func parseContentMD5(raw string) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil && len(decoded) == md5.Size {
+		return decoded
+	}
+	if decoded, err := hex.DecodeString(raw); err == nil && len(decoded) == md5.Size {
+		return decoded
+	}
+	return nil
+}
+
+func validChecksumValue(algorithm, value string) bool {
+	var decoded, err = base64.StdEncoding.DecodeString(value)
+	return err == nil && len(decoded) == newChecksumHash(algorithm).Size()
+}
+
+func (c *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = c.Reader.Read(p)
+	if n > 0 {
+		if c.md5 != nil {
+			c.md5.Write(p[:n])
+		}
+		if c.hash != nil {
+			c.hash.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !c.verified {
+		if verifyErr := c.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+		c.verified = true
+	}
+	return
+}
+
+func (c *checksumReader) verify() error {
+	if c.md5 != nil && !bytes.Equal(c.md5.Sum(nil), c.contentMD5) {
+		return errBadDigest
+	}
+	if c.hash == nil {
+		return nil
+	}
+	var expected = c.expected
+	if expected == "" && c.r.Trailer != nil {
+		expected = c.r.Trailer.Get(checksumHeaderName(c.checksum.Algorithm))
+	}
+	if expected == "" {
+		return errMissingChecksum
+	}
+	var actual = base64.StdEncoding.EncodeToString(c.hash.Sum(nil))
+	if actual != expected {
+		return errBadChecksum
+	}
+	c.checksum.Value = actual
+	return nil
+}
+
+// objectChecksum returns the checksum of the body once it is verified, or nil if no checksum is given.
+func (c *checksumReader) objectChecksum() *ObjectChecksum {
+	if c == nil || c.checksum == nil {
+		return nil
+	}
+	return c.checksum
+}
+
+// checksumErrorCode returns the error code of the errors of verifying the body, or nil if the
+// error is not caused by the verification.
+func checksumErrorCode(err error) *ErrorCode {
+	switch err {
+	case errBadDigest:
+		return BadDigest
+	case errBadChecksum:
+		return BadChecksum
+	case errMissingChecksum:
+		return MalformedTrailer
+	}
+	return nil
+}
+
+// requestTrailer returns the trailer of the request, to which the trailers of the aws-chunked
+// payload are added.
+func requestTrailer(r *http.Request) http.Header {
+	if r.Trailer == nil {
+		r.Trailer = make(http.Header)
+	}
+	return r.Trailer
+}
+
+// setChecksumResponseHeader exposes the checksum of the object in the response headers.
+func setChecksumResponseHeader(w http.ResponseWriter, checksum *ObjectChecksum) {
+	if checksum != nil && checksum.Value != "" {
+		w.Header()[checksumHeaderName(checksum.Algorithm)] = []string{checksum.Value}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChecksumReader(t *testing.T) {
+	var cases = []struct {
+		headers   map[string]string
+		errorCode *ErrorCode
+		err       error
+		checksum  string
+	}{
+		{map[string]string{}, nil, nil, ""},
+		{map[string]string{"Content-MD5": "XUFAKrxLKna5cZ2REBfFkg=="}, nil, nil, ""},
+		{map[string]string{"Content-MD5": "5d41402abc4b2a76b9719d911017c592"}, nil, nil, ""},
+		{map[string]string{"Content-MD5": "1B2M2Y8AsgTpgAmY7PhCfg=="}, nil, errBadDigest, ""},
+		{map[string]string{"Content-MD5": "hello"}, InvalidDigest, nil, ""},
+		{map[string]string{"x-amz-checksum-crc32": "NhCmhg=="}, nil, nil, "CRC32:NhCmhg=="},
+		{map[string]string{"x-amz-checksum-crc32c": "mnG7TA=="}, nil, nil, "CRC32C:mnG7TA=="},
+		{map[string]string{"x-amz-checksum-sha1": "qvTGHdzF6KLavt4PO0gs2a6pQ00="}, nil, nil, "SHA1:qvTGHdzF6KLavt4PO0gs2a6pQ00="},
+		{map[string]string{"x-amz-checksum-sha256": "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="}, nil, nil, "SHA256:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="},
+		{map[string]string{"x-amz-checksum-crc32": "AAAAAA=="}, nil, errBadChecksum, ""},
+		{map[string]string{"x-amz-checksum-crc32": "NhCm"}, InvalidChecksum, nil, ""},
+		{map[string]string{"x-amz-checksum-crc32": "NhCmhg==", "x-amz-checksum-sha1": "qvTGHdzF6KLavt4PO0gs2a6pQ00="}, MultipleChecksums, nil, ""},
+		{map[string]string{"x-amz-checksum-crc32": "NhCmhg==", "x-amz-sdk-checksum-algorithm": "SHA1"}, InvalidChecksum, nil, ""},
+		{map[string]string{"x-amz-trailer": "x-amz-checksum-md5"}, InvalidChecksum, nil, ""},
+		{map[string]string{"x-amz-trailer": "x-amz-checksum-crc32"}, nil, errMissingChecksum, ""},
+	}
+	for i, c := range cases {
+		var r = httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("hello"))
+		for name, value := range c.headers {
+			r.Header.Set(name, value)
+		}
+		var reader, errorCode = newChecksumReader(r)
+		if errorCode != c.errorCode {
+			t.Fatalf("case %v: error code mismatch: expect(%v) actual(%v)", i, c.errorCode, errorCode)
+		}
+		if errorCode != nil || reader == nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != c.err {
+			t.Fatalf("case %v: error mismatch: expect(%v) actual(%v)", i, c.err, err)
+		}
+		if err == nil && string(data) != "hello" {
+			t.Fatalf("case %v: data mismatch: actual(%v)", i, string(data))
+		}
+		var checksum string
+		if objectChecksum := reader.objectChecksum(); objectChecksum != nil && objectChecksum.Value != "" {
+			checksum = objectChecksum.Encode()
+		}
+		if checksum != c.checksum {
+			t.Fatalf("case %v: checksum mismatch: expect(%v) actual(%v)", i, c.checksum, checksum)
+		}
+	}
+}
+
+func TestChecksumReaderTrailer(t *testing.T) {
+	var cases = []struct {
+		trailer string
+		err     error
+	}{
+		{"x-amz-checksum-crc32:NhCmhg==", nil},
+		{"x-amz-checksum-crc32:AAAAAA==", errBadChecksum},
+		{"x-amz-checksum-sha1:qvTGHdzF6KLavt4PO0gs2a6pQ00=", errMissingChecksum},
+	}
+	for i, c := range cases {
+		var r = httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("5\r\nhello\r\n0\r\n"+c.trailer+"\r\n\r\n"))
+		r.Header.Set(HeaderNameXAmzContentHash, StreamingUnsignedPayloadTrailer)
+		r.Header.Set(HeaderNameXAmzTrailer, "x-amz-checksum-crc32")
+		r.Body = newAWSChunkedReader(r.Body, nil, requestTrailer(r))
+		var reader, errorCode = newChecksumReader(r)
+		if errorCode != nil || reader == nil {
+			t.Fatalf("case %v: new checksum reader fail: errorCode(%v)", i, errorCode)
+		}
+		if _, err := ioutil.ReadAll(reader); err != c.err {
+			t.Fatalf("case %v: error mismatch: expect(%v) actual(%v)", i, c.err, err)
+		}
+	}
+}
+
+func TestParseObjectChecksum(t *testing.T) {
+	var checksum = ParseObjectChecksum("CRC32C:mnG7TA==")
+	if checksum == nil || checksum.Algorithm != ChecksumAlgorithmCRC32C || checksum.Value != "mnG7TA==" {
+		t.Fatalf("parse checksum mismatch: actual(%v)", checksum)
+	}
+	for _, raw := range []string{"", "CRC32", "MD5:XUFAKrxLKna5cZ2REBfFkg==", "SHA1:"} {
+		if checksum = ParseObjectChecksum(raw); checksum != nil {
+			t.Fatalf("malformed checksum should be rejected: raw(%v)", raw)
+		}
+	}
+}
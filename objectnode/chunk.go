@@ -15,30 +15,239 @@
 package objectnode
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
-	"net/http/httputil"
+	"net/http"
+	"strconv"
+	"strings"
 )
 
-// ClosableChunkReader wraps the chunked reader from the "httputil" package provided by Go
-// and provides a close method.
-type closableChunkedReader struct {
-	io.Reader
-	src io.ReadCloser
+const (
+	StreamingSignedPayload          = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingSignedPayloadTrailer   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	streamingPayloadPrefix    = "STREAMING-"
+	chunkSignatureAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
+	trailerSignatureAlgorithm = "AWS4-HMAC-SHA256-TRAILER"
+	chunkSignatureExtension   = "chunk-signature="
+	trailerSignatureHeader    = "x-amz-trailer-signature"
+	emptyPayloadSHA256        = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	MaxChunkSize     = 16 * 1024 * 1024 // the chunks are buffered to be verified before they are read
+	maxChunkLineSize = 4096
+	maxChunkTrailers = 16
+)
+
+var (
+	errInvalidChunkSignature = errors.New("invalid chunk signature")
+	errMalformedChunk        = errors.New("malformed chunk")
+)
+
+// isStreamingPayload returns whether the payload of the request is sent in aws-chunked encoding
+// by the hash of the content given in the x-amz-content-sha256 header.
+func isStreamingPayload(contentHash string) bool {
+	return strings.HasPrefix(contentHash, streamingPayloadPrefix)
+}
+
+// chunkSigner computes the signatures of the chunks of a streaming payload, each of which is chained
+// to the signature of the previous chunk, starting from the seed signature of the request.
+// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+type chunkSigner struct {
+	signingKey    []byte
+	timestamp     string
+	scope         string
+	prevSignature string
+}
+
+func newChunkSigner(signingKey []byte, timestamp, scope, seedSignature string) *chunkSigner {
+	return &chunkSigner{
+		signingKey:    signingKey,
+		timestamp:     timestamp,
+		scope:         scope,
+		prevSignature: seedSignature,
+	}
+}
+
+// verifyChunk verifies the signature of the chunk and chains it if it is valid.
+func (s *chunkSigner) verifyChunk(data []byte, signature string) bool {
+	sum := sha256.Sum256(data)
+	stringToSign := strings.Join([]string{
+		chunkSignatureAlgorithm,
+		s.timestamp,
+		s.scope,
+		s.prevSignature,
+		emptyPayloadSHA256,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+	return s.verify(stringToSign, signature)
+}
+
+// verifyTrailer verifies the signature of the trailing headers, which are given as "name:value\n" lines.
+func (s *chunkSigner) verifyTrailer(trailers []byte, signature string) bool {
+	stringToSign := strings.Join([]string{
+		trailerSignatureAlgorithm,
+		s.timestamp,
+		s.scope,
+		s.prevSignature,
+		calcHash(string(trailers)),
+	}, "\n")
+	return s.verify(stringToSign, signature)
+}
+
+func (s *chunkSigner) verify(stringToSign, signature string) bool {
+	if expected := hex.EncodeToString(sign(stringToSign, s.signingKey)); expected != signature {
+		return false
+	}
+	s.prevSignature = signature
+	return true
+}
+
+// awsChunkedReader decodes the payload sent in aws-chunked encoding, such as
+//   <hex size>;chunk-signature=<signature>\r\n<data>\r\n ... 0;chunk-signature=<signature>\r\n<trailers>\r\n
+// The signatures of the chunks and the trailers are verified if the signer is given, and the
+// payloads without signatures or trailers are decoded alike. The trailers are added to the
+// given header, such as the Trailer of the request, once the last chunk is read.
+type awsChunkedReader struct {
+	src     io.ReadCloser
+	r       *bufio.Reader
+	signer  *chunkSigner // nil if the chunks are not verified
+	trailer http.Header  // nil if the trailers are dropped
+	chunk   []byte       // the data of the current chunk not read yet
+	buf     []byte
+	err     error
+}
+
+func newAWSChunkedReader(src io.ReadCloser, signer *chunkSigner, trailer http.Header) *awsChunkedReader {
+	return &awsChunkedReader{
+		src:     src,
+		r:       bufio.NewReader(src),
+		signer:  signer,
+		trailer: trailer,
+	}
 }
 
-func (r *closableChunkedReader) Read(p []byte) (n int, err error) {
-	return r.Reader.Read(p)
+func (r *awsChunkedReader) Read(p []byte) (n int, err error) {
+	for len(r.chunk) == 0 && r.err == nil {
+		r.err = r.readChunk()
+	}
+	if len(r.chunk) == 0 {
+		return 0, r.err
+	}
+	n = copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
 }
 
-func (r *closableChunkedReader) Close() error {
+func (r *awsChunkedReader) Close() error {
 	return r.src.Close()
 }
 
-// NewClosableChunkedReader returns an instance of the io.ReadCloser interface
-// used to parse chunk data.
-func NewClosableChunkedReader(source io.ReadCloser) io.ReadCloser {
-	return &closableChunkedReader{
-		src:    source,
-		Reader: httputil.NewChunkedReader(source),
+func (r *awsChunkedReader) readLine() (string, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > maxChunkLineSize {
+		return "", errMalformedChunk
+	}
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (r *awsChunkedReader) readChunk() (err error) {
+	var line string
+	if line, err = r.readLine(); err != nil {
+		return
+	}
+	var sizeStr, signature = line, ""
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		sizeStr = line[:i]
+		for _, ext := range strings.Split(line[i+1:], ";") {
+			if ext = strings.TrimSpace(ext); strings.HasPrefix(ext, chunkSignatureExtension) {
+				signature = strings.TrimPrefix(ext, chunkSignatureExtension)
+			}
+		}
+	}
+	var size uint64
+	if size, err = strconv.ParseUint(strings.TrimSpace(sizeStr), 16, 64); err != nil {
+		return errMalformedChunk
+	}
+	if size > MaxChunkSize {
+		return errMalformedChunk
+	}
+	if uint64(cap(r.buf)) < size {
+		r.buf = make([]byte, size)
+	}
+	var data = r.buf[:size]
+	if _, err = io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	if r.signer != nil && !r.signer.verifyChunk(data, signature) {
+		return errInvalidChunkSignature
+	}
+	if size == 0 {
+		if err = r.readTrailers(); err != nil {
+			return
+		}
+		return io.EOF
+	}
+	// the data of chunk ends with CRLF
+	if line, err = r.readLine(); err != nil {
+		return
+	}
+	if line != "" {
+		return errMalformedChunk
+	}
+	r.chunk = data
+	return nil
+}
+
+// readTrailers reads the trailing headers after the last chunk until the empty line.
+func (r *awsChunkedReader) readTrailers() (err error) {
+	var trailers bytes.Buffer
+	var values = make(map[string]string)
+	var signature string
+	for i := 0; ; i++ {
+		var line string
+		if line, err = r.readLine(); err != nil {
+			return
+		}
+		if line == "" {
+			break
+		}
+		if i >= maxChunkTrailers {
+			return errMalformedChunk
+		}
+		var kv = strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return errMalformedChunk
+		}
+		var name, value = strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		if name == trailerSignatureHeader {
+			signature = value
+			continue
+		}
+		trailers.WriteString(name + ":" + value + "\n")
+		values[name] = value
+	}
+	if r.signer != nil && trailers.Len() > 0 && !r.signer.verifyTrailer(trailers.Bytes(), signature) {
+		return errInvalidChunkSignature
+	}
+	// the trailers are exposed only after they are verified
+	if r.trailer != nil {
+		for name, value := range values {
+			r.trailer.Set(name, value)
+		}
 	}
+	return nil
 }
@@ -0,0 +1,91 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// The example of the streaming payload given by
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+const (
+	exampleChunkSecretKey     = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	exampleChunkTimestamp     = "20130524T000000Z"
+	exampleChunkSeedSignature = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+)
+
+func exampleChunkedPayload(lastSignature string) string {
+	return "10000;chunk-signature=ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648\r\n" +
+		strings.Repeat("a", 65536) + "\r\n" +
+		"400;chunk-signature=0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497\r\n" +
+		strings.Repeat("a", 1024) + "\r\n" +
+		"0;chunk-signature=" + lastSignature + "\r\n\r\n"
+}
+
+func exampleChunkSigner() *chunkSigner {
+	signingKey := buildSigningKey(SCHEME, exampleChunkSecretKey, "20130524", "us-east-1", "s3", TERMINATOR)
+	scope := buildScope("20130524", "us-east-1", "s3", TERMINATOR)
+	return newChunkSigner(signingKey, exampleChunkTimestamp, scope, exampleChunkSeedSignature)
+}
+
+func TestAWSChunkedReaderSigned(t *testing.T) {
+	var payload = exampleChunkedPayload("b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9")
+	var reader = newAWSChunkedReader(ioutil.NopCloser(strings.NewReader(payload)), exampleChunkSigner(), nil)
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read signed chunks fail: err(%v)", err)
+	}
+	if !bytes.Equal(data, bytes.Repeat([]byte("a"), 65536+1024)) {
+		t.Fatalf("decoded data mismatch: length(%v)", len(data))
+	}
+
+	payload = exampleChunkedPayload(strings.Repeat("0", 64))
+	reader = newAWSChunkedReader(ioutil.NopCloser(strings.NewReader(payload)), exampleChunkSigner(), nil)
+	if _, err = ioutil.ReadAll(reader); err != errInvalidChunkSignature {
+		t.Fatalf("invalid chunk signature should be rejected: err(%v)", err)
+	}
+}
+
+func TestAWSChunkedReaderUnsigned(t *testing.T) {
+	var cases = []struct {
+		payload string
+		expect  string
+		err     error
+	}{
+		{"5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n", "hello world", nil},
+		{"5\r\nhello\r\n0\r\nx-amz-checksum-crc32:NhCmhg==\r\n\r\n", "hello", nil},
+		{"5;chunk-signature=abc\r\nhello\r\n0;chunk-signature=abc\r\n\r\n", "hello", nil},
+		{"5\r\nhelloX\r\n0\r\n\r\n", "", errMalformedChunk},
+		{"z\r\nhello\r\n0\r\n\r\n", "", errMalformedChunk},
+	}
+	for i, c := range cases {
+		var reader = newAWSChunkedReader(ioutil.NopCloser(strings.NewReader(c.payload)), nil, nil)
+		data, err := ioutil.ReadAll(reader)
+		if err != c.err {
+			t.Fatalf("case %v: error mismatch: expect(%v) actual(%v)", i, c.err, err)
+		}
+		if err == nil && string(data) != c.expect {
+			t.Fatalf("case %v: data mismatch: expect(%v) actual(%v)", i, c.expect, string(data))
+		}
+	}
+
+	var reader = newAWSChunkedReader(ioutil.NopCloser(strings.NewReader("5\r\nhel")), nil, nil)
+	if _, err := ioutil.ReadAll(reader); err == nil {
+		t.Fatalf("truncated chunk should fail")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// etagMatches returns whether the ETag matches any entity tag listed in the value of
+// If-Match or If-None-Match. The weak tags are compared by the opaque tags, and "*" matches any ETag.
+func etagMatches(value, etag string) bool {
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		tag = strings.TrimPrefix(tag, "W/")
+		if strings.Trim(tag, "\"") == etag && etag != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedSince returns whether the time is after the HTTP date, the time is truncated
+// to seconds as the HTTP dates are.
+func modifiedSince(t, since time.Time) bool {
+	return t.Truncate(time.Second).After(since)
+}
+
+type preconditionHeaders struct {
+	match      string
+	noneMatch  string
+	modified   string
+	unmodified string
+}
+
+// evaluate evaluates the preconditions in the order of RFC 7232 section 6. The If-Unmodified-Since
+// is ignored if If-Match is given, and the If-Modified-Since is ignored if If-None-Match is given.
+// The failed If-None-Match and If-Modified-Since result notModified.
+func (h preconditionHeaders) evaluate(etag string, modTime time.Time, notModified *ErrorCode) *ErrorCode {
+	if h.match != "" {
+		if !etagMatches(h.match, etag) {
+			return PreconditionFailed
+		}
+	} else if h.unmodified != "" {
+		unmodifiedTime, err := parseTimeRFC1123(h.unmodified)
+		if err != nil {
+			return InvalidArgument
+		}
+		if modifiedSince(modTime, unmodifiedTime) {
+			return PreconditionFailed
+		}
+	}
+	if h.noneMatch != "" {
+		if etagMatches(h.noneMatch, etag) {
+			return notModified
+		}
+	} else if h.modified != "" {
+		modifiedTime, err := parseTimeRFC1123(h.modified)
+		if err != nil {
+			return InvalidArgument
+		}
+		if !modifiedSince(modTime, modifiedTime) {
+			return notModified
+		}
+	}
+	return nil
+}
+
+// checkReadPreconditions checks If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since
+// of GetObject and HeadObject.
+// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html#API_GetObject_RequestSyntax
+func checkReadPreconditions(r *http.Request, fileInfo *FSFileInfo) *ErrorCode {
+	var headers = preconditionHeaders{
+		match:      r.Header.Get(HeaderNameIfMatch),
+		noneMatch:  r.Header.Get(HeaderNameIfNoneMatch),
+		modified:   r.Header.Get(HeaderNameIfModifiedSince),
+		unmodified: r.Header.Get(HeaderNameIfUnmodifiedSince),
+	}
+	errorCode := headers.evaluate(fileInfo.ETag, fileInfo.ModifyTime, NotModified)
+	if errorCode != nil {
+		log.LogDebugf("checkReadPreconditions: precondition not hold: requestID(%v) eTag(%v) modifyTime(%v) headers(%+v) code(%v)",
+			GetRequestID(r), fileInfo.ETag, fileInfo.ModifyTime, headers, errorCode.ErrorCode)
+	}
+	return errorCode
+}
+
+// checkCopySourcePreconditions checks the x-amz-copy-source-if-* headers of CopyObject and UploadPartCopy,
+// all the failed preconditions result PreconditionFailed.
+// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_CopyObject.html#API_CopyObject_RequestSyntax
+func checkCopySourcePreconditions(r *http.Request, fileInfo *FSFileInfo) *ErrorCode {
+	var headers = preconditionHeaders{
+		match:      r.Header.Get(HeaderNameXAmzCopyMatch),
+		noneMatch:  r.Header.Get(HeaderNameXAmzCopyNoneMatch),
+		modified:   r.Header.Get(HeaderNameXAmzCopyModified),
+		unmodified: r.Header.Get(HeaderNameXAmzCopyUnModified),
+	}
+	errorCode := headers.evaluate(fileInfo.ETag, fileInfo.ModifyTime, PreconditionFailed)
+	if errorCode != nil {
+		log.LogInfof("checkCopySourcePreconditions: precondition not hold: requestID(%v) eTag(%v) modifyTime(%v) headers(%+v) code(%v)",
+			GetRequestID(r), fileInfo.ETag, fileInfo.ModifyTime, headers, errorCode.ErrorCode)
+	}
+	return errorCode
+}
+
+// checkWritePreconditions checks If-Match and If-None-Match of the requests writing the object,
+// such as PutObject, CopyObject and CompleteMultipartUpload. If-None-Match "*" prevents the object
+// from being overwritten, and If-Match requires the current object to have a matched ETag.
+// The preconditions are checked before the object is written, so the concurrent writes of the
+// same object are not serialized by them.
+func checkWritePreconditions(r *http.Request, vol *Volume, path string) *ErrorCode {
+	match := r.Header.Get(HeaderNameIfMatch)
+	noneMatch := r.Header.Get(HeaderNameIfNoneMatch)
+	if match == "" && noneMatch == "" {
+		return nil
+	}
+	fileInfo, err := vol.ObjectMeta(path)
+	if err != nil && err != syscall.ENOENT {
+		log.LogErrorf("checkWritePreconditions: get object meta fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), path, err)
+		return InternalErrorCode(err)
+	}
+	var exist = err == nil && !fileInfo.DeleteMarker
+	if match != "" {
+		if !exist {
+			return NoSuchKey
+		}
+		if !etagMatches(match, fileInfo.ETag) {
+			log.LogDebugf("checkWritePreconditions: If-Match not hold: requestID(%v) volume(%v) path(%v) eTag(%v) match(%v)",
+				GetRequestID(r), vol.Name(), path, fileInfo.ETag, match)
+			return PreconditionFailed
+		}
+	}
+	if noneMatch != "" && exist && etagMatches(noneMatch, fileInfo.ETag) {
+		log.LogDebugf("checkWritePreconditions: If-None-Match not hold: requestID(%v) volume(%v) path(%v) eTag(%v) noneMatch(%v)",
+			GetRequestID(r), vol.Name(), path, fileInfo.ETag, noneMatch)
+		return PreconditionFailed
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETagMatches(t *testing.T) {
+	var cases = []struct {
+		value string
+		etag  string
+		match bool
+	}{
+		{`"abc"`, "abc", true},
+		{`abc`, "abc", true},
+		{`W/"abc"`, "abc", true},
+		{`"xyz", "abc"`, "abc", true},
+		{`*`, "abc", true},
+		{`"abc-2"`, "abc", false},
+		{`"xyz"`, "abc", false},
+		{`""`, "", false},
+	}
+	for i, c := range cases {
+		if match := etagMatches(c.value, c.etag); match != c.match {
+			t.Fatalf("case %v: match mismatch: expect(%v) actual(%v)", i, c.match, match)
+		}
+	}
+}
+
+func TestReadPreconditions(t *testing.T) {
+	var modTime = time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC)
+	var before = "Thu, 2 Jan 2020 03:04:04 GMT"
+	var same = "Thu, 2 Jan 2020 03:04:05 GMT"
+	var cases = []struct {
+		headers map[string]string
+		expect  *ErrorCode
+	}{
+		{map[string]string{}, nil},
+		{map[string]string{HeaderNameIfMatch: `"abc"`}, nil},
+		{map[string]string{HeaderNameIfMatch: `"xyz"`}, PreconditionFailed},
+		{map[string]string{HeaderNameIfNoneMatch: `"abc"`}, NotModified},
+		{map[string]string{HeaderNameIfNoneMatch: `"xyz"`}, nil},
+		{map[string]string{HeaderNameIfModifiedSince: same}, NotModified},
+		{map[string]string{HeaderNameIfModifiedSince: before}, nil},
+		{map[string]string{HeaderNameIfUnmodifiedSince: same}, nil},
+		{map[string]string{HeaderNameIfUnmodifiedSince: before}, PreconditionFailed},
+		{map[string]string{HeaderNameIfModifiedSince: "invalid"}, InvalidArgument},
+		// If-Unmodified-Since is ignored if If-Match holds
+		{map[string]string{HeaderNameIfMatch: `"abc"`, HeaderNameIfUnmodifiedSince: before}, nil},
+		// If-Modified-Since is ignored if If-None-Match is given
+		{map[string]string{HeaderNameIfNoneMatch: `"xyz"`, HeaderNameIfModifiedSince: same}, nil},
+		{map[string]string{HeaderNameIfMatch: `"xyz"`, HeaderNameIfNoneMatch: `"abc"`}, PreconditionFailed},
+	}
+	var info = &FSFileInfo{ETag: "abc", ModifyTime: modTime}
+	for i, c := range cases {
+		var r = httptest.NewRequest("GET", "/bucket/key", nil)
+		for name, value := range c.headers {
+			r.Header.Set(name, value)
+		}
+		if errorCode := checkReadPreconditions(r, info); errorCode != c.expect {
+			t.Fatalf("case %v: result mismatch: expect(%v) actual(%v)", i, c.expect, errorCode)
+		}
+	}
+}
+
+func TestCopySourcePreconditions(t *testing.T) {
+	var info = &FSFileInfo{ETag: "abc", ModifyTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	var cases = []struct {
+		headers map[string]string
+		expect  *ErrorCode
+	}{
+		{map[string]string{HeaderNameXAmzCopyMatch: `"abc"`, HeaderNameXAmzCopyUnModified: "Thu, 2 Jan 2020 03:04:04 GMT"}, nil},
+		{map[string]string{HeaderNameXAmzCopyNoneMatch: `"abc"`}, PreconditionFailed},
+		{map[string]string{HeaderNameXAmzCopyModified: "Thu, 2 Jan 2020 03:04:05 GMT"}, PreconditionFailed},
+		{map[string]string{HeaderNameXAmzCopyNoneMatch: `"xyz"`, HeaderNameXAmzCopyModified: "Thu, 2 Jan 2020 03:04:05 GMT"}, nil},
+	}
+	for i, c := range cases {
+		var r = httptest.NewRequest("PUT", "/bucket/key", nil)
+		for name, value := range c.headers {
+			r.Header.Set(name, value)
+		}
+		if errorCode := checkCopySourcePreconditions(r, info); errorCode != c.expect {
+			t.Fatalf("case %v: result mismatch: expect(%v) actual(%v)", i, c.expect, errorCode)
+		}
+	}
+}
+
+func TestMultipartETagValue(t *testing.T) {
+	var cases = []struct {
+		parts  []string
+		expect string
+	}{
+		{[]string{"5d41402abc4b2a76b9719d911017c592", `"7d793037a0760186574b0282f2f435e7"`}, "065947336a2f2a95ba8899f3675c3be6-2"},
+		{[]string{"5d41402abc4b2a76b9719d911017c592"}, "62109206880d38a4010a98e11243924a-1"},
+	}
+	for i, c := range cases {
+		if etag := MultipartETagValue(c.parts, time.Now()).ETag(); etag != c.expect {
+			t.Fatalf("case %v: ETag mismatch: expect(%v) actual(%v)", i, c.expect, etag)
+		}
+	}
+}
@@ -14,7 +14,11 @@
 
 package objectnode
 
-import "os"
+import (
+	"os"
+
+	"github.com/cubefs/cubefs/proto"
+)
 
 const (
 	MaxRetry = 3
@@ -37,19 +41,22 @@ const (
 	HeaderNameRange              = "Range"
 	HeaderNameExpect             = "Expect"
 	HeaderNameXForwardedExpect   = "X-Forwarded-Expect"
+	HeaderNameXForwardedProto    = "X-Forwarded-Proto"
 	HeaderNameLocation           = "Location"
 	HeaderNameCacheControl       = "Cache-Control"
 	HeaderNameExpires            = "Expires"
 
 	// Headers for CORS validation
-	Origin                                = "Origin"
-	HeaderNameAccessControlRequestMethod  = "Access-Control-Request-Method"
-	HeaderNameAccessControlRequestHeaders = "Access-Control-Request-Headers"
-	HeaderNameAccessControlAllowOrigin    = "Access-Control-Allow-Origin"
-	HeaderNameAccessControlMaxAge         = "Access-Control-Max-Age"
-	HeaderNameAccessControlAllowMethods   = "Access-Control-Allow-Methods"
-	HeaderNameAccessControlAllowHeaders   = "Access-Control-Allow-Headers"
-	HeaderNamrAccessControlExposeHeaders  = "Access-Control-Expose-Headers"
+	Origin                                  = "Origin"
+	HeaderNameAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderNameAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderNameAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderNameAccessControlMaxAge           = "Access-Control-Max-Age"
+	HeaderNameAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderNameAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderNamrAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderNameAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderNameVary                          = "Vary"
 
 	HeaderNameXAmzStartDate           = "x-amz-date"
 	HeaderNameXAmzRequestId           = "x-amz-request-id"
@@ -59,6 +66,8 @@ const (
 	HeaderNameXAmzCopyNoneMatch       = "x-amz-copy-source-if-none-match"
 	HeaderNameXAmzCopyModified        = "x-amz-copy-source-if-modified-since"
 	HeaderNameXAmzCopyUnModified      = "x-amz-copy-source-if-unmodified-since"
+	HeaderNameXAmzCopySourceRange     = "x-amz-copy-source-range"
+	HeaderNameXAmzCopySourceVersionId = "x-amz-copy-source-version-id"
 	HeaderNameXAmzDecodeContentLength = "x-amz-decoded-content-length"
 	HeaderNameXAmzTagging             = "x-amz-tagging"
 	HeaderNameXAmzMetaPrefix          = "x-amz-meta-"
@@ -66,6 +75,28 @@ const (
 	HeaderNameXAmzMetadataDirective   = "x-amz-metadata-directive"
 	HeaderNameXAmzBucketRegion        = "x-amz-bucket-region"
 	HeaderNameXAmzTaggingCount        = "x-amz-tagging-count"
+	HeaderNameXAmzVersionId           = "x-amz-version-id"
+	HeaderNameXAmzDeleteMarker        = "x-amz-delete-marker"
+	HeaderNameXAmzStorageClass        = "x-amz-storage-class"
+	HeaderNameXAmzACL                 = "x-amz-acl"
+	HeaderNameXAmzReplicationStatus   = "x-amz-replication-status"
+
+	HeaderNameXAmzChecksumPrefix       = "x-amz-checksum-"
+	HeaderNameXAmzChecksumMode         = "x-amz-checksum-mode"
+	HeaderNameXAmzSdkChecksumAlgorithm = "x-amz-sdk-checksum-algorithm"
+	HeaderNameXAmzTrailer              = "x-amz-trailer"
+
+	HeaderNameXAmzServerSideEncryption         = "x-amz-server-side-encryption"
+	HeaderNameXAmzServerSideEncryptionKMSKeyId = "x-amz-server-side-encryption-aws-kms-key-id"
+	HeaderNameXAmzSSECustomerAlgorithm         = "x-amz-server-side-encryption-customer-algorithm"
+
+	HeaderNameXAmzSecurityToken = "x-amz-security-token"
+
+	HeaderNameXAmzBucketObjectLockEnabled   = "x-amz-bucket-object-lock-enabled"
+	HeaderNameXAmzObjectLockMode            = "x-amz-object-lock-mode"
+	HeaderNameXAmzObjectLockRetainUntilDate = "x-amz-object-lock-retain-until-date"
+	HeaderNameXAmzObjectLockLegalHold       = "x-amz-object-lock-legal-hold"
+	HeaderNameXAmzBypassGovernanceRetention = "x-amz-bypass-governance-retention"
 
 	HeaderNameIfMatch           = "If-Match"
 	HeaderNameIfNoneMatch       = "If-None-Match"
@@ -78,6 +109,7 @@ const (
 	HeaderValueAcceptRange          = "bytes"
 	HeaderValueTypeStream           = "application/octet-stream"
 	HeaderValueContentTypeXML       = "application/xml"
+	HeaderValueContentTypeJSON      = "application/json"
 	HeaderValueContentTypeDirectory = "application/directory"
 )
 
@@ -98,6 +130,11 @@ const (
 	ParamStartAfter = "start-after"
 	ParamKey        = "key"
 
+	ParamInventoryId = "id"
+
+	ParamVersionId       = "versionId"
+	ParamVersionIdMarker = "version-id-marker"
+
 	ParamMaxParts       = "max-parts"
 	ParamUploadIdMarker = "upload-id-marker"
 	ParamPartNoMarker   = "part-number-marker"
@@ -112,13 +149,15 @@ const (
 )
 
 const (
-	MaxKeys    = 1000
-	MaxParts   = 1000
-	MaxUploads = 1000
+	MaxKeys       = 1000
+	MaxParts      = 1000
+	MaxUploads    = 1000
+	MaxPartNumber = 10000
 )
 
 const (
 	StorageClassStandard = "Standard"
+	StorageClassCold     = "Cold" // the data partitions of the object are erasure coded
 )
 
 // XAttr keys for ObjectNode compatible feature
@@ -132,6 +171,27 @@ const (
 	XAttrKeyOSSCORS         = "oss:cors"
 	XAttrKeyOSSCacheControl = "oss:cache"
 	XAttrKeyOSSExpires      = "oss:expires"
+	XAttrKeyOSSVersionId    = "oss:version"
+	XAttrKeyOSSVersioning   = "oss:versioning"
+	XAttrKeyOSSLifecycle    = "oss:lifecycle"
+	XAttrKeyOSSNotification = "oss:notification"
+	XAttrKeyOSSObjectLock   = "oss:object-lock"
+	XAttrKeyOSSLogging      = "oss:logging"
+	XAttrKeyOSSWebsite      = "oss:website"
+	XAttrKeyOSSReplication  = "oss:replication"
+	XAttrKeyOSSInventory    = "oss:inventory"
+	XAttrKeyOSSStorageClass = "oss:storage-class"
+	XAttrKeyOSSSSE          = "oss:sse"        // algorithm of the server-side encryption
+	XAttrKeyOSSSSEKeyId     = "oss:sse-key-id" // id of the key which wraps the data key
+	XAttrKeyOSSSSEKey       = "oss:sse-key"    // wrapped data key in base64
+
+	XAttrKeyOSSReplicationStatus = "oss:replication-status"
+	XAttrKeyOSSChecksum          = "oss:checksum" // algorithm and value of the checksum, such as "CRC32:NhCmhg=="
+	XAttrKeyOSSInventoryStatus   = "oss:inventory-status" // time of the last reports of the inventory configurations
+
+	XAttrKeyOSSLockMode        = proto.XAttrKeyObjectLockMode
+	XAttrKeyOSSLockRetainUntil = proto.XAttrKeyObjectLockRetainUntil
+	XAttrKeyOSSLegalHold       = proto.XAttrKeyObjectLegalHold
 
 	// Deprecated
 	XAttrKeyOSSETagDeprecated = "oss:tag"
@@ -163,13 +223,35 @@ const (
 	MaxCopyObjectSize = 5 * 1024 * 1024 * 1024
 )
 
+const (
+	MaxDeleteObjects = 1000
+	// DeleteObjectsConcurrency is the number of the objects deleted at the same time by a DeleteObjects request.
+	DeleteObjectsConcurrency = 16
+)
+
 const (
 	MetadataDirectiveCopy    = "COPY"
 	MetadataDirectiveReplace = "REPLACE"
 )
 
+const (
+	VersioningStatusEnabled   = "Enabled"
+	VersioningStatusSuspended = "Suspended"
+
+	// NullVersionId is the version id of the objects written while versioning is not enabled.
+	NullVersionId = "null"
+)
+
+const (
+	LifecycleStatusEnabled  = "Enabled"
+	LifecycleStatusDisabled = "Disabled"
+
+	MaxLifecycleRules = 1000
+)
+
 const (
 	TaggingCounts         = 10
 	TaggingKeyMaxLength   = 128
 	TaggingValueMaxLength = 256
+	MaxTaggingBodySize    = 64 * 1024 // bytes of the tagging in the request body at most
 )
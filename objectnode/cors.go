@@ -4,6 +4,7 @@ package objectnode
 
 import (
 	"encoding/xml"
+	"strings"
 
 	"github.com/cubefs/cubefs/util/errors"
 )
@@ -23,39 +24,86 @@ type CORSRule struct {
 	MaxAgeSeconds uint16   `xml:"MaxAgeSeconds" json:"max_age_seconds"`
 }
 
+// The allowed origins and headers may contain a wildcard, such as "http://*.example.com", and
+// the headers are matched ignoring the case.
 func (rule *CORSRule) match(origin, method string, headers []string) bool {
-	// todo if "*" are used in some text
-	if !contains(rule.AllowedOrigin, "*") && !contains(rule.AllowedOrigin, origin) {
+	if !wildcardContains(rule.AllowedOrigin, origin, false) {
 		return false
 	}
 	if !contains(rule.AllowedMethod, "*") && !contains(rule.AllowedMethod, method) {
 		return false
 	}
-	if contains(rule.AllowedHeader, "*") {
-		return true
-	}
 	for _, header := range headers {
-		if !contains(rule.AllowedHeader, header) {
+		if !wildcardContains(rule.AllowedHeader, header, true) {
 			return false
 		}
 	}
 	return true
 }
 
+// allowAnyOrigin returns true if the rule allows the requests of any origin, which are
+// responded without credentials.
+func (rule *CORSRule) allowAnyOrigin() bool {
+	return contains(rule.AllowedOrigin, "*")
+}
+
+func wildcardContains(patterns []string, value string, ignoreCase bool) bool {
+	for _, pattern := range patterns {
+		if ignoreCase && wildcardMatch(strings.ToLower(pattern), strings.ToLower(value)) ||
+			!ignoreCase && wildcardMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRule returns the first rule which allows the request, or nil if no rule allows it.
+func (corsConfig *CORSConfiguration) matchRule(origin, method string, headers []string) *CORSRule {
+	if corsConfig == nil {
+		return nil
+	}
+	for _, rule := range corsConfig.CORSRule {
+		if rule.match(origin, method, headers) {
+			return rule
+		}
+	}
+	return nil
+}
+
 func (corsConfig *CORSConfiguration) validate() bool {
-	if len(corsConfig.CORSRule) > 100 {
+	if len(corsConfig.CORSRule) == 0 || len(corsConfig.CORSRule) > 100 {
 		return false
 	}
 	for _, rule := range corsConfig.CORSRule {
+		if len(rule.AllowedOrigin) == 0 || len(rule.AllowedMethod) == 0 {
+			return false
+		}
 		for _, method := range rule.AllowedMethod {
 			if !contains(methodsRequest, method) {
 				return false
 			}
 		}
+		// At most one wildcard is allowed in each origin and header.
+		for _, value := range append(rule.AllowedOrigin, rule.AllowedHeader...) {
+			if strings.Count(value, "*") > 1 {
+				return false
+			}
+		}
 	}
 	return true
 }
 
+// parseCORSRequestHeaders returns the headers of the Access-Control-Request-Headers header.
+func parseCORSRequestHeaders(headerStr string) []string {
+	var headers = make([]string, 0)
+	for _, header := range strings.Split(headerStr, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
 func parseCorsConfig(bytes []byte) (corsConfig *CORSConfiguration, err error) {
 	corsConfig = &CORSConfiguration{}
 	if err = xml.Unmarshal(bytes, corsConfig); err != nil {
@@ -4,10 +4,10 @@ package objectnode
 
 import (
 	"encoding/json"
-	"encoding/xml"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/cubefs/cubefs/util/log"
 )
@@ -35,15 +35,19 @@ func (o *ObjectNode) getBucketCorsHandler(w http.ResponseWriter, r *http.Request
 		_ = InternalErrorCode(err).ServeResponse(w, r)
 		return
 	}
-	if cors != nil {
-		output.CORSRule = cors.CORSRule
+	if cors == nil {
+		_ = NoSuchCORSConfiguration.ServeResponse(w, r)
+		return
 	}
+	output.CORSRule = cors.CORSRule
 	var corsData []byte
-	if corsData, err = xml.Marshal(output); err != nil {
+	if corsData, err = MarshalXMLEntity(output); err != nil {
 		_ = InternalErrorCode(err).ServeResponse(w, r)
 		return
 	}
 
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(corsData))}
 	_, _ = w.Write(corsData)
 	return
 }
@@ -72,11 +76,9 @@ func (o *ObjectNode) putBucketCorsHandler(w http.ResponseWriter, r *http.Request
 
 	var corsConfig *CORSConfiguration
 	if corsConfig, err = parseCorsConfig(bytes); err != nil {
-		_ = InvalidArgument.ServeResponse(w, r)
-		return
-	}
-	if corsConfig == nil {
-		_ = InvalidArgument.ServeResponse(w, r)
+		log.LogWarnf("putBucketCorsHandler: parse cors fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = MalformedXML.ServeResponse(w, r)
 		return
 	}
 
@@ -124,6 +126,8 @@ func (o *ObjectNode) deleteBucketCorsHandler(w http.ResponseWriter, r *http.Requ
 // Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTOPTIONSobject.html
 func (o *ObjectNode) optionsObjectHandler(w http.ResponseWriter, r *http.Request) {
 	log.LogInfof("optionsObjectHandler: OPTIONS object, requestID(%v) remote(%v)", GetRequestID(r), r.RemoteAddr)
-	// Already done in methods 'corsMiddleware'.
+	// The preflight requests have been responded in 'corsMiddleware', the requests without the origin
+	// or the request method are not allowed.
+	_ = CORSForbidden.ServeResponse(w, r)
 	return
 }
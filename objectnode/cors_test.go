@@ -0,0 +1,69 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import "testing"
+
+func TestCORSMatchRule(t *testing.T) {
+	var config = `<CORSConfiguration>
+	<CORSRule>
+		<AllowedOrigin>http://*.example.com</AllowedOrigin>
+		<AllowedMethod>PUT</AllowedMethod>
+		<AllowedMethod>POST</AllowedMethod>
+		<AllowedHeader>Content-*</AllowedHeader>
+		<AllowedHeader>x-amz-date</AllowedHeader>
+		<MaxAgeSeconds>3000</MaxAgeSeconds>
+	</CORSRule>
+	<CORSRule>
+		<AllowedOrigin>*</AllowedOrigin>
+		<AllowedMethod>GET</AllowedMethod>
+	</CORSRule>
+</CORSConfiguration>`
+	cors, err := parseCorsConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("parse cors config fail: err(%v)", err)
+	}
+
+	var cases = []struct {
+		origin  string
+		method  string
+		headers string
+		rule    int
+	}{
+		{"http://www.example.com", "PUT", "content-type, X-Amz-Date", 0},
+		{"http://www.example.com", "PUT", "authorization", -1},
+		{"https://www.example.com", "PUT", "", -1},
+		{"https://www.other.com", "GET", "", 1},
+		{"https://www.other.com", "DELETE", "", -1},
+	}
+	for i, c := range cases {
+		rule := cors.matchRule(c.origin, c.method, parseCORSRequestHeaders(c.headers))
+		if c.rule < 0 && rule != nil || c.rule >= 0 && rule != cors.CORSRule[c.rule] {
+			t.Fatalf("case %v: unexpected rule matched: %v", i, rule)
+		}
+	}
+
+	var invalids = []string{
+		`<CORSConfiguration></CORSConfiguration>`,
+		`<CORSConfiguration><CORSRule><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`,
+		`<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>`,
+		`<CORSConfiguration><CORSRule><AllowedOrigin>http://*.*.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`,
+	}
+	for i, invalid := range invalids {
+		if _, err = parseCorsConfig([]byte(invalid)); err == nil {
+			t.Fatalf("invalid cors config %v accepted", i)
+		}
+	}
+}
@@ -116,6 +116,25 @@ func NewRandomUUIDETagValue(partNum int, ts time.Time) ETagValue {
 	return value
 }
 
+// MultipartETagValue returns the ETag value of the object completed from the parts, which is the MD5
+// of the concatenated binary MD5s of the parts as Amazon S3 computes, even if there is only one part.
+func MultipartETagValue(partMD5s []string, ts time.Time) ETagValue {
+	md5Hash := md5.New()
+	for _, partMD5 := range partMD5s {
+		partMD5 = strings.Trim(partMD5, "\"")
+		if decoded, err := hex.DecodeString(partMD5); err == nil {
+			md5Hash.Write(decoded)
+		} else {
+			md5Hash.Write([]byte(partMD5))
+		}
+	}
+	return ETagValue{
+		Value:   hex.EncodeToString(md5Hash.Sum(nil)),
+		PartNum: len(partMD5s),
+		TS:      ts,
+	}
+}
+
 func ParseETagValue(raw string) ETagValue {
 	value := ETagValue{}
 	if !regexpEncodedETagValue.MatchString(raw) {
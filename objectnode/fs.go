@@ -33,6 +33,25 @@ type FSFileInfo struct {
 	CacheControl string
 	Expires      string
 	Metadata     map[string]string `graphql:"-"` // User-defined metadata
+	VersionId    string
+	DeleteMarker bool
+	StorageClass string // empty for the standard storage class
+	SSEAlgorithm string // empty if the object is not encrypted
+	SSEKMSKeyId  string
+
+	ObjectLockMode        string    // empty if the object has no retention
+	ObjectLockRetainUntil time.Time // end of the retention period
+	ObjectLegalHold       string
+
+	ReplicationStatus string // empty if the object is not replicated
+
+	Checksum *ObjectChecksum // nil if the object is not uploaded with a checksum
+}
+
+// FSVersion is a version of an object, or a delete marker, in the listing of object versions.
+type FSVersion struct {
+	*FSFileInfo
+	IsLatest bool
 }
 
 type Prefixes []string
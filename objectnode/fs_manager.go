@@ -41,6 +41,7 @@ type VolumeLoader struct {
 	closeOnce  sync.Once
 	closeCh    chan struct{}
 	metaStrict bool
+	sse        *sseKeyManager // keys of the server-side encryption, nil if not configured
 }
 
 func (loader *VolumeLoader) blacklistCleanup() {
@@ -136,6 +137,7 @@ func (loader *VolumeLoader) loadVolume(volName string) (*Volume, error) {
 			Store:            loader.store,
 			OnAsyncTaskError: onAsyncTaskError,
 			MetaStrict:       loader.metaStrict,
+			SSE:              loader.sse,
 		}
 		if volume, err = NewVolume(config); err != nil {
 			if err != proto.ErrVolNotExists {
@@ -226,6 +228,13 @@ func (m *VolumeManager) init() {
 	}
 }
 
+// setSSEKeyManager sets the keys of the server-side encryption of the volumes loaded later.
+func (m *VolumeManager) setSSEKeyManager(sse *sseKeyManager) {
+	for _, loader := range m.loaders {
+		loader.sse = sse
+	}
+}
+
 func NewVolumeManager(masters []string, strict bool) *VolumeManager {
 	manager := &VolumeManager{
 		masters:    masters,
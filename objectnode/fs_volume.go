@@ -15,6 +15,7 @@
 package objectnode
 
 import (
+	"crypto/cipher"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
@@ -23,6 +24,7 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -32,9 +34,11 @@ import (
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/sdk/master"
 	"github.com/cubefs/cubefs/sdk/meta"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
 
 	"github.com/cubefs/cubefs/util"
 )
@@ -76,6 +80,10 @@ type VolumeConfig struct {
 
 	// Get OSSMeta from the MetaNode every time if it is set true.
 	MetaStrict bool
+
+	// Keys of the server-side encryption of the objects.
+	// This is a optional configuration item.
+	SSE *sseKeyManager
 }
 
 type PutFileOption struct {
@@ -85,6 +93,15 @@ type PutFileOption struct {
 	Metadata     map[string]string
 	CacheControl string
 	Expires      string
+	SSE          *SSEOption // nil if the object is not encrypted
+	// ObjectLock is kept by the multipart uploads, and is placed by the handlers after
+	// the object is written.
+	ObjectLock *ObjectLockOption
+	// Checksum is verified while the data is read, and is stored once its value is set.
+	Checksum *ObjectChecksum
+	ACL      *AccessControlPolicy // nil if the object has no ACL of its own
+	// RequestID is the ID of the S3 request carried by the writes to the data nodes, a new one if invalid.
+	RequestID reqid.ID
 }
 
 type ListFilesV1Option struct {
@@ -127,8 +144,11 @@ type Volume struct {
 	store      Store // Storage for ACP management
 	name       string
 	metaLoader ossMetaLoader
+	sse        *sseKeyManager
 	ticker     *time.Ticker
 	createTime int64
+	mc         *master.MasterClient
+	quota      *bucketQuota
 
 	closeOnce sync.Once
 	closeCh   chan struct{}
@@ -174,6 +194,54 @@ func (v *Volume) loadOSSMeta() {
 		return
 	}
 	v.metaLoader.storeCors(cors)
+
+	var versioning *VersioningConfiguration
+	if versioning, err = v.loadBucketVersioning(); err != nil {
+		return
+	}
+	v.metaLoader.storeVersioning(versioning)
+
+	var lifecycle *LifecycleConfiguration
+	if lifecycle, err = v.loadBucketLifecycle(); err != nil {
+		return
+	}
+	v.metaLoader.storeLifecycle(lifecycle)
+
+	var notification *NotificationConfiguration
+	if notification, err = v.loadBucketNotification(); err != nil {
+		return
+	}
+	v.metaLoader.storeNotification(notification)
+
+	var objectLock *ObjectLockConfiguration
+	if objectLock, err = v.loadBucketObjectLock(); err != nil {
+		return
+	}
+	v.metaLoader.storeObjectLock(objectLock)
+
+	var logging *BucketLoggingStatus
+	if logging, err = v.loadBucketLogging(); err != nil {
+		return
+	}
+	v.metaLoader.storeLogging(logging)
+
+	var website *WebsiteConfiguration
+	if website, err = v.loadBucketWebsite(); err != nil {
+		return
+	}
+	v.metaLoader.storeWebsite(website)
+
+	var replication *ReplicationConfiguration
+	if replication, err = v.loadBucketReplication(); err != nil {
+		return
+	}
+	v.metaLoader.storeReplication(replication)
+
+	var inventory *InventoryConfigurations
+	if inventory, err = v.loadBucketInventory(); err != nil {
+		return
+	}
+	v.metaLoader.storeInventory(inventory)
 }
 
 func (v *Volume) Name() string {
@@ -236,6 +304,126 @@ func (v *Volume) loadBucketCors() (configuration *CORSConfiguration, err error)
 	return configuration, nil
 }
 
+func (v *Volume) loadBucketVersioning() (configuration *VersioningConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSVersioning); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &VersioningConfiguration{}
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketLifecycle() (configuration *LifecycleConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSLifecycle); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &LifecycleConfiguration{}
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketNotification() (configuration *NotificationConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSNotification); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &NotificationConfiguration{}
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketObjectLock() (configuration *ObjectLockConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSObjectLock); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &ObjectLockConfiguration{}
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketLogging() (configuration *BucketLoggingStatus, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSLogging); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &BucketLoggingStatus{}
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketWebsite() (configuration *WebsiteConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSWebsite); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &WebsiteConfiguration{}
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketReplication() (configuration *ReplicationConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSReplication); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &ReplicationConfiguration{}
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketInventory() (configurations *InventoryConfigurations, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSInventory); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configurations = &InventoryConfigurations{}
+	if err = xml.Unmarshal(raw, configurations); err != nil {
+		return
+	}
+	return configurations, nil
+}
+
 func (v *Volume) getInodeFromPath(path string) (inode uint64, err error) {
 	if path == "/" {
 		return volumeRootInode, nil
@@ -510,11 +698,26 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 		}
 	}()
 
+	var sseKey *sseDataKey
+	var sseCipher cipher.Block
+	if opt != nil && opt.SSE != nil {
+		if sseKey, err = v.sse.generateDataKey(opt.SSE); err != nil {
+			log.LogErrorf("PutObject: generate data key fail: volume(%v) path(%v) algorithm(%v) err(%v)",
+				v.name, path, opt.SSE.Algorithm, err)
+			return
+		}
+		sseCipher = sseKey.newCipher()
+	}
+
 	var (
-		md5Hash  = md5.New()
-		md5Value string
+		md5Hash   = md5.New()
+		md5Value  string
+		requestID reqid.ID
 	)
-	if _, err = v.streamWrite(invisibleTempDataInode.Inode, reader, md5Hash); err != nil {
+	if opt != nil {
+		requestID = opt.RequestID
+	}
+	if _, err = v.streamWrite(invisibleTempDataInode.Inode, reader, md5Hash, sseCipher, requestID); err != nil {
 		return
 	}
 	// compute file md5
@@ -545,6 +748,12 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 			v.name, path, invisibleTempDataInode.Inode, XAttrKeyOSSETag, md5Value, err)
 		return nil, err
 	}
+	// Save the data key of the encrypted object
+	if sseKey != nil {
+		if err = v.storeSSEDataKey(invisibleTempDataInode.Inode, sseKey); err != nil {
+			return nil, err
+		}
+	}
 	// If MIME information is valid, use extended attributes for storage.
 	if opt != nil && opt.MIMEType != "" {
 		if err = v.mw.XAttrSet_ll(invisibleTempDataInode.Inode, []byte(XAttrKeyOSSMIME), []byte(opt.MIMEType)); err != nil {
@@ -585,6 +794,23 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 			return nil, err
 		}
 	}
+	// If the checksum of the data has been verified, use extend attributes for storage.
+	if opt != nil && opt.Checksum != nil && opt.Checksum.Value != "" {
+		var encoded = opt.Checksum.Encode()
+		if err = v.mw.XAttrSet_ll(invisibleTempDataInode.Inode, []byte(XAttrKeyOSSChecksum), []byte(encoded)); err != nil {
+			log.LogErrorf("PutObject: store checksum fail: volume(%v) path(%v) inode(%v) value(%v) err(%v)",
+				v.name, path, invisibleTempDataInode.Inode, encoded, err)
+			return nil, err
+		}
+	}
+	// If the ACL of the object has been specified, use extend attributes for storage.
+	if opt != nil && opt.ACL != nil {
+		if err = v.storeInodeACL(invisibleTempDataInode.Inode, opt.ACL); err != nil {
+			log.LogErrorf("PutObject: store ACL fail: volume(%v) path(%v) inode(%v) err(%v)",
+				v.name, path, invisibleTempDataInode.Inode, err)
+			return nil, err
+		}
+	}
 	// If user-defined metadata have been specified, use extend attributes for storage.
 	if opt != nil && len(opt.Metadata) > 0 {
 		for name, value := range opt.Metadata {
@@ -610,9 +836,15 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 		ETag:       etagValue.ETag(),
 		Inode:      finalInode.Inode,
 	}
+	if sseKey != nil {
+		sseKey.fillFileInfo(fsInfo)
+	}
+	if opt != nil && opt.Checksum != nil && opt.Checksum.Value != "" {
+		fsInfo.Checksum = opt.Checksum
+	}
 
 	// apply new inode to dentry
-	err = v.applyInodeToDEntry(parentId, lastPathItem.Name, invisibleTempDataInode.Inode)
+	fsInfo.VersionId, err = v.applyInodeToObject(path, parentId, lastPathItem.Name, invisibleTempDataInode.Inode)
 	if err != nil {
 		log.LogErrorf("PutObject: apply new inode to dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
 			parentId, lastPathItem.Name, invisibleTempDataInode.Inode, err)
@@ -741,6 +973,18 @@ func (v *Volume) InitMultipart(path string, opt *PutFileOption) (multipartID str
 		var encoded = opt.Tagging.Encode()
 		extend[XAttrKeyOSSTagging] = encoded
 	}
+	if opt != nil && opt.ObjectLock != nil {
+		for key, value := range opt.ObjectLock.xattrs() {
+			extend[key] = value
+		}
+	}
+	if opt != nil && opt.ACL != nil {
+		var data []byte
+		if data, err = opt.ACL.Marshal(); err != nil {
+			return "", err
+		}
+		extend[XAttrKeyOSSACL] = string(data)
+	}
 
 	// Iterate all the meta partition to create multipart id
 	multipartID, err = v.mw.InitMultipart_ll(path, extend)
@@ -802,7 +1046,7 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 		etag    string
 		md5Hash = md5.New()
 	)
-	if size, err = v.streamWrite(tempInodeInfo.Inode, reader, md5Hash); err != nil {
+	if size, err = v.streamWrite(tempInodeInfo.Inode, reader, md5Hash, nil, reqid.ID{}); err != nil {
 		return nil, err
 	}
 	// compute file md5
@@ -813,10 +1057,11 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 		log.LogErrorf("WritePart: data flush inode fail: volume(%v) inode(%v) err(%v)", v.name, tempInodeInfo.Inode, err)
 		return nil, err
 	}
-	// update temp file inode to meta with session
-	err = v.mw.AddMultipartPart_ll(path, multipartId, partId, size, etag, tempInodeInfo.Inode)
+	// update temp file inode to meta with session, the part uploaded again replaces the former one
+	var replaced *proto.MultipartPartInfo
+	replaced, err = v.mw.ReplaceMultipartPart_ll(path, multipartId, partId, size, etag, tempInodeInfo.Inode)
 	if err == syscall.EEXIST {
-		// Result success but cleanup data.
+		// The meta node does not replace the parts. Result success but cleanup data.
 		err = nil
 		exist = true
 	}
@@ -827,6 +1072,9 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 	}
 	log.LogDebugf("WritePart: meta add multipart part: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) size(%v) MD5(%v)",
 		v.name, path, multipartId, partId, tempInodeInfo.Inode, size, etag)
+	if replaced != nil {
+		v.releasePartInode(path, multipartId, replaced.ID, replaced.Inode)
+	}
 	// create file info
 	fInfo = &FSFileInfo{
 		Path:       fileName,
@@ -840,6 +1088,33 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 	return fInfo, nil
 }
 
+// CopyPart writes the range of the source inode, which is decrypted if it is encrypted, as the part of
+// the multipart upload.
+func (v *Volume) CopyPart(sv *Volume, sourcePath string, sourceInode, offset, size uint64,
+	path, multipartId string, partId uint16) (info *FSFileInfo, err error) {
+	defer func() {
+		log.LogInfof("Audit: CopyPart: volume(%v) path(%v) multipartID(%v) partID(%v) source volume(%v) source path(%v) offset(%v) size(%v) err(%v)",
+			v.name, path, multipartId, partId, sv.name, sourcePath, offset, size, err)
+	}()
+
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(sv.ReadInode(sourcePath, sourceInode, writer, offset, size))
+	}()
+	info, err = v.WritePart(path, multipartId, partId, reader)
+	// stop reading the source if the part is not written completely
+	_ = reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(info.Size) != size {
+		log.LogErrorf("CopyPart: copied size mismatch: volume(%v) path(%v) multipartID(%v) partID(%v) size(%v) expect(%v)",
+			v.name, path, multipartId, partId, info.Size, size)
+		return nil, io.ErrUnexpectedEOF
+	}
+	return
+}
+
 func (v *Volume) AbortMultipart(path string, multipartID string) (err error) {
 	defer func() {
 		log.LogInfof("Audit: AbortMultipart: volume(%v) path(%v) multipartID(%v) err(%v)",
@@ -855,20 +1130,7 @@ func (v *Volume) AbortMultipart(path string, multipartID string) (err error) {
 	}
 	// release part data
 	for _, part := range multipartInfo.Parts {
-		log.LogWarnf("AbortMultipart: unlink part inode: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
-			v.name, path, multipartID, part.ID, part.Inode)
-		if _, err = v.mw.InodeUnlink_ll(part.Inode); err != nil {
-			log.LogErrorf("AbortMultipart: meta inode unlink fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
-				v.name, path, multipartID, part.ID, part.Inode, err)
-		}
-		log.LogWarnf("AbortMultipart: evict part inode: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
-			v.name, path, multipartID, part.ID, part.Inode)
-		if err = v.mw.Evict(part.Inode); err != nil {
-			log.LogErrorf("AbortMultipart: meta inode evict fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
-				v.name, path, multipartID, part.ID, part.Inode, err)
-		}
-		log.LogDebugf("AbortMultipart: multipart part data released: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
-			v.name, path, multipartID, part.ID, part.Inode)
+		v.releasePartInode(path, multipartID, part.ID, part.Inode)
 	}
 
 	if err = v.mw.RemoveMultipart_ll(path, multipartID); err != nil {
@@ -881,6 +1143,24 @@ func (v *Volume) AbortMultipart(path string, multipartID string) (err error) {
 	return nil
 }
 
+// releasePartInode releases the inode and the data of the part which is no longer referenced by the multipart upload.
+func (v *Volume) releasePartInode(path, multipartID string, partID uint16, inode uint64) {
+	log.LogWarnf("releasePartInode: unlink part inode: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
+		v.name, path, multipartID, partID, inode)
+	if _, err := v.mw.InodeUnlink_ll(inode); err != nil {
+		log.LogErrorf("releasePartInode: meta inode unlink fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+			v.name, path, multipartID, partID, inode, err)
+	}
+	log.LogWarnf("releasePartInode: evict part inode: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
+		v.name, path, multipartID, partID, inode)
+	if err := v.mw.Evict(inode); err != nil {
+		log.LogErrorf("releasePartInode: meta inode evict fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+			v.name, path, multipartID, partID, inode, err)
+	}
+	log.LogDebugf("releasePartInode: multipart part data released: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
+		v.name, path, multipartID, partID, inode)
+}
+
 func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *proto.MultipartInfo) (fsFileInfo *FSFileInfo, err error) {
 	defer func() {
 		log.LogInfof("Audit: CompleteMultipart: volume(%v) path(%v) multipartID(%v) err(%v)",
@@ -916,11 +1196,19 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 	var fileOffset uint64
 	for _, part := range parts {
 		var eks []proto.ExtentKey
-		if _, _, eks, err = v.mw.GetExtents(part.Inode); err != nil {
+		var cold *proto.ColdLocation
+		if _, _, eks, _, cold, err = v.mw.GetExtents(part.Inode); err != nil {
 			log.LogErrorf("CompleteMultipart: meta get extents fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
 				v.name, path, multipartID, part.ID, part.Inode, err)
 			return
 		}
+		// the extent keys of the part cannot be merged once its data is migrated to the cold tier
+		if cold != nil {
+			err = syscall.EIO
+			log.LogErrorf("CompleteMultipart: part migrated to the cold tier: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
+				v.name, path, multipartID, part.ID, part.Inode)
+			return
+		}
 		// recompute offsets of extent keys
 		for _, ek := range eks {
 			ek.FileOffset = fileOffset
@@ -931,18 +1219,12 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 	}
 
 	// compute md5 hash
-	var md5Val string
-	if len(parts) == 1 {
-		md5Val = parts[0].MD5
-	} else {
-		var md5Hash = md5.New()
-		for _, part := range parts {
-			md5Hash.Write([]byte(part.MD5))
-		}
-		md5Val = hex.EncodeToString(md5Hash.Sum(nil))
+	var partMD5s = make([]string, 0, len(parts))
+	for _, part := range parts {
+		partMD5s = append(partMD5s, part.MD5)
 	}
-	log.LogDebugf("CompleteMultipart: merge parts: volume(%v) path(%v) multipartID(%v) numParts(%v) MD5(%v)",
-		v.name, path, multipartID, len(parts), md5Val)
+	log.LogDebugf("CompleteMultipart: merge parts: volume(%v) path(%v) multipartID(%v) numParts(%v)",
+		v.name, path, multipartID, len(parts))
 
 	if err = v.mw.AppendExtentKeys(completeInodeInfo.Inode, completeExtentKeys); err != nil {
 		log.LogErrorf("CompleteMultipart: meta append extent keys fail: volume(%v) path(%v) multipartID(%v) inode(%v) err(%v)",
@@ -968,11 +1250,7 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 		return
 	}
 
-	var etagValue = ETagValue{
-		Value:   md5Val,
-		PartNum: len(parts),
-		TS:      finalInode.ModifyTime,
-	}
+	var etagValue = MultipartETagValue(partMD5s, finalInode.ModifyTime)
 	if err = v.mw.XAttrSet_ll(finalInode.Inode, []byte(XAttrKeyOSSETag), []byte(etagValue.Encode())); err != nil {
 		log.LogErrorf("CompleteMultipart: save ETag fail: volume(%v) inode(%v) err(%v)",
 			v.name, completeInodeInfo, err)
@@ -982,6 +1260,10 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 	extend := multipartInfo.Extend
 	if len(extend) > 0 {
 		for key, value := range extend {
+			// the object lock is placed after the object is completed
+			if isObjectLockXAttrKey(key) {
+				continue
+			}
 			if err = v.mw.XAttrSet_ll(completeInodeInfo.Inode, []byte(key), []byte(value)); err != nil {
 				log.LogErrorf("CompleteMultipart: store multipart extend fail: volume(%v) path(%v) inode(%v) key(%v) value(%v) err(%v)",
 					v.name, path, completeInodeInfo.Inode, key, value, err)
@@ -990,24 +1272,28 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 		}
 	}
 
-	// remove multipart
-	err = v.mw.RemoveMultipart_ll(path, multipartID)
-	if err == syscall.ENOENT {
-		log.LogWarnf("CompleteMultipart: removing not exist multipart: volume(%v) multipartID(%v) path(%v)",
-			v.name, multipartID, path)
+	// Apply the new inode to the object before the multipart is removed, so the multipart upload is kept
+	// and can be completed again if the object node fails in the middle.
+	var versionId string
+	if versionId, err = v.applyInodeToObject(path, parentId, filename, completeInodeInfo.Inode); err != nil {
+		log.LogErrorf("CompleteMultipart: apply new inode to dentry fail: volume(%v) parent id(%v) file name(%v) inode(%v) err(%v)",
+			v.name, parentId, filename, completeInodeInfo.Inode, err)
+		return
 	}
-	if err != nil {
-		log.LogErrorf("CompleteMultipart: meta complete multipart fail: volume(%v) multipartID(%v) path(%v) err(%v)",
-			v.name, multipartID, path, err)
-		return nil, err
+
+	// remove multipart
+	if removeErr := v.mw.RemoveMultipart_ll(path, multipartID); removeErr != nil {
+		// The object is completed, and the parts sharing its data must not be released.
+		log.LogErrorf("CompleteMultipart: meta remove multipart fail: volume(%v) multipartID(%v) path(%v) err(%v)",
+			v.name, multipartID, path, removeErr)
 	}
 	// delete part inodes
 	for _, part := range parts {
 		log.LogWarnf("CompleteMultipart: destroy part inode: volume(%v) multipartID(%v) partID(%v) inode(%v)",
 			v.name, multipartID, part.ID, part.Inode)
-		if err = v.mw.InodeDelete_ll(part.Inode); err != nil {
+		if deleteErr := v.mw.InodeDelete_ll(part.Inode); deleteErr != nil {
 			log.LogErrorf("CompleteMultipart: destroy part inode fail: volume(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
-				v.name, multipartID, part.ID, part.Inode, err)
+				v.name, multipartID, part.ID, part.Inode, deleteErr)
 		}
 	}
 
@@ -1023,18 +1309,17 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 		ModifyTime: time.Now(),
 		ETag:       etagValue.ETag(),
 		Inode:      finalInode.Inode,
-	}
-
-	// apply new inode to dentry
-	err = v.applyInodeToDEntry(parentId, filename, completeInodeInfo.Inode)
-	if err != nil {
-		log.LogErrorf("CompleteMultipart: apply new inode to dentry fail, parent id (%v), file name(%v), inode(%v)",
-			parentId, filename, completeInodeInfo.Inode)
+		VersionId:  versionId,
 	}
 	return fInfo, nil
 }
 
-func (v *Volume) streamWrite(inode uint64, reader io.Reader, h hash.Hash) (size uint64, err error) {
+// streamWrite writes the data of the reader to the inode, the data is encrypted if the cipher is not nil.
+// The writes carry the request ID, or a new one if it is invalid.
+func (v *Volume) streamWrite(inode uint64, reader io.Reader, h hash.Hash, block cipher.Block, requestID reqid.ID) (size uint64, err error) {
+	if !requestID.IsValid() {
+		requestID = reqid.New()
+	}
 	var (
 		buf                   = make([]byte, 2*util.BlockSize)
 		readN, writeN, offset int
@@ -1046,16 +1331,21 @@ func (v *Volume) streamWrite(inode uint64, reader io.Reader, h hash.Hash) (size
 			return
 		}
 		if readN > 0 {
-			if writeN, err = v.ec.Write(inode, offset, buf[:readN], 0); err != nil {
-				log.LogErrorf("streamWrite: data write tmp file fail, inode(%v) offset(%v) err(%v)", inode, offset, err)
+			// copy to md5 buffer before the data is encrypted
+			copy(hashBuf, buf[:readN])
+			if block != nil {
+				sseXorKeyStream(block, buf[:readN], offset)
+			}
+			if writeN, err = v.ec.WriteWithRequestID(requestID, inode, offset, buf[:readN], 0); err != nil {
+				log.LogErrorf("streamWrite: data write tmp file fail, inode(%v) offset(%v) requestID(%v) err(%v)",
+					inode, offset, requestID, err)
 				exporter.Warning(fmt.Sprintf("write data fail: volume(%v) inode(%v) offset(%v) size(%v) err(%v)",
 					v.name, inode, offset, readN, err))
 				return
 			}
 			offset += writeN
-			// copy to md5 buffer, and then write to md5
+			// write to md5
 			size += uint64(writeN)
-			copy(hashBuf, buf[:readN])
 			if h != nil {
 				h.Write(hashBuf[:readN])
 			}
@@ -1191,6 +1481,21 @@ func (v *Volume) ReadFile(path string, writer io.Writer, offset, size uint64) er
 	if mode.IsDir() {
 		return nil
 	}
+	return v.ReadInode(path, ino, writer, offset, size)
+}
+
+// ReadInode reads the data of the inode, which is a version of the object at the path.
+func (v *Volume) ReadInode(path string, ino uint64, writer io.Writer, offset, size uint64) error {
+	return v.ReadInodeWithRequestID(reqid.ID{}, path, ino, writer, offset, size)
+}
+
+// ReadInodeWithRequestID reads the data of the inode as ReadInode, and the reads from the data nodes carry
+// the request ID, or a new one if it is invalid.
+func (v *Volume) ReadInodeWithRequestID(requestID reqid.ID, path string, ino uint64, writer io.Writer, offset, size uint64) error {
+	var err error
+	if !requestID.IsValid() {
+		requestID = reqid.New()
+	}
 
 	// read file data
 	var inoInfo *proto.InodeInfo
@@ -1198,6 +1503,11 @@ func (v *Volume) ReadFile(path string, writer io.Writer, offset, size uint64) er
 		return err
 	}
 
+	var sseCipher cipher.Block
+	if sseCipher, err = v.loadSSECipher(ino); err != nil {
+		return err
+	}
+
 	if err = v.ec.OpenStream(ino); err != nil {
 		log.LogErrorf("ReadFile: data open stream fail, Inode(%v) err(%v)", ino, err)
 		return err
@@ -1224,15 +1534,18 @@ func (v *Volume) ReadFile(path string, writer io.Writer, offset, size uint64) er
 		if uint64(readSize) > rest {
 			readSize = int(rest)
 		}
-		n, err = v.ec.Read(ino, tmp, int(offset), readSize)
+		n, err = v.ec.ReadWithRequestID(requestID, ino, tmp, int(offset), readSize)
 		if err != nil && err != io.EOF {
-			log.LogErrorf("ReadFile: data read fail: volume(%v) path(%v) inode(%v) offset(%v) size(%v) err(%v)",
-				v.name, path, ino, offset, size, err)
+			log.LogErrorf("ReadFile: data read fail: volume(%v) path(%v) inode(%v) offset(%v) size(%v) requestID(%v) err(%v)",
+				v.name, path, ino, offset, size, requestID, err)
 			exporter.Warning(fmt.Sprintf("read data fail: volume(%v) path(%v) inode(%v) offset(%v) size(%v) err(%v)",
 				v.name, path, ino, offset, readSize, err))
 			return err
 		}
 		if n > 0 {
+			if sseCipher != nil {
+				sseXorKeyStream(sseCipher, tmp[:n], int(offset))
+			}
 			if _, err = writer.Write(tmp[:n]); err != nil {
 				return err
 			}
@@ -1269,13 +1582,26 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, err error) {
 		}
 		break
 	}
+	return v.inodeMeta(path, mode, inoInfo)
+}
 
+func (v *Volume) inodeMeta(path string, mode os.FileMode, inoInfo *proto.InodeInfo) (info *FSFileInfo, err error) {
+	var inode = inoInfo.Inode
 	var (
 		etagValue    ETagValue
 		mimeType     string
 		disposition  string
 		cacheControl string
 		expires      string
+		versionId    string
+		storageClass string
+		sseAlgorithm string
+		sseKeyId     string
+		lockMode     string
+		retainUntil  time.Time
+		legalHold    string
+		replStatus   string
+		checksum     *ObjectChecksum
 	)
 
 	if mode.IsDir() {
@@ -1289,7 +1615,9 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, err error) {
 		// 2. MIME type
 		var xattrs []*proto.XAttrInfo
 		var xattrKeys = []string{XAttrKeyOSSETag, XAttrKeyOSSETagDeprecated, XAttrKeyOSSMIME, XAttrKeyOSSDISPOSITION,
-			XAttrKeyOSSCacheControl, XAttrKeyOSSExpires}
+			XAttrKeyOSSCacheControl, XAttrKeyOSSExpires, XAttrKeyOSSVersionId, XAttrKeyOSSStorageClass,
+			XAttrKeyOSSSSE, XAttrKeyOSSSSEKeyId, XAttrKeyOSSLockMode, XAttrKeyOSSLockRetainUntil, XAttrKeyOSSLegalHold,
+			XAttrKeyOSSReplicationStatus, XAttrKeyOSSChecksum}
 		if xattrs, err = v.mw.BatchGetXAttr([]uint64{inode}, xattrKeys); err != nil {
 			log.LogErrorf("ObjectMeta: meta get xattr fail, volume(%v) inode(%v) path(%v) keys(%v) err(%v)",
 				v.name, inode, path, strings.Join(xattrKeys, ","), err)
@@ -1309,6 +1637,17 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, err error) {
 			disposition = string(xattr.Get(XAttrKeyOSSDISPOSITION))
 			cacheControl = string(xattr.Get(XAttrKeyOSSCacheControl))
 			expires = string(xattr.Get(XAttrKeyOSSExpires))
+			versionId = string(xattr.Get(XAttrKeyOSSVersionId))
+			storageClass = string(xattr.Get(XAttrKeyOSSStorageClass))
+			sseAlgorithm = string(xattr.Get(XAttrKeyOSSSSE))
+			sseKeyId = string(xattr.Get(XAttrKeyOSSSSEKeyId))
+			lockMode = string(xattr.Get(XAttrKeyOSSLockMode))
+			if sec, parseErr := strconv.ParseInt(string(xattr.Get(XAttrKeyOSSLockRetainUntil)), 10, 64); parseErr == nil {
+				retainUntil = time.Unix(sec, 0)
+			}
+			legalHold = string(xattr.Get(XAttrKeyOSSLegalHold))
+			replStatus = string(xattr.Get(XAttrKeyOSSReplicationStatus))
+			checksum = ParseObjectChecksum(string(xattr.Get(XAttrKeyOSSChecksum)))
 		}
 	}
 
@@ -1344,6 +1683,19 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, err error) {
 		CacheControl: cacheControl,
 		Expires:      expires,
 		Metadata:     metadata,
+		VersionId:    versionId,
+		StorageClass: storageClass,
+		SSEAlgorithm: sseAlgorithm,
+
+		ObjectLockMode:        lockMode,
+		ObjectLockRetainUntil: retainUntil,
+		ObjectLegalHold:       legalHold,
+
+		ReplicationStatus: replStatus,
+		Checksum:          checksum,
+	}
+	if sseAlgorithm == SSEAlgorithmKMS {
+		info.SSEKMSKeyId = sseKeyId
 	}
 	return
 }
@@ -1825,7 +2177,7 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 	}
 
 	// Get MD5 information in batches, then update to fileInfos
-	keys := []string{XAttrKeyOSSETag, XAttrKeyOSSETagDeprecated}
+	keys := []string{XAttrKeyOSSETag, XAttrKeyOSSETagDeprecated, XAttrKeyOSSVersionId, XAttrKeyOSSStorageClass}
 	xattrs, err := v.mw.BatchGetXAttr(inodes, keys)
 	if err != nil {
 		log.LogErrorf("supplyListFileInfo: batch get xattr fail, inodes(%v), err(%v)", inodes, err)
@@ -1852,6 +2204,10 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 			if len(rawETag) > 0 {
 				etagValue = ParseETagValue(rawETag)
 			}
+			if versionId := xattr.Get(XAttrKeyOSSVersionId); len(versionId) > 0 {
+				fileInfo.VersionId = string(versionId)
+			}
+			fileInfo.StorageClass = string(xattr.Get(XAttrKeyOSSStorageClass))
 		}
 		if !etagValue.Valid() || etagValue.TS.Before(fileInfo.ModifyTime) {
 			// The ETag is invalid or outdated then generate a new ETag and make update.
@@ -1956,19 +2312,18 @@ func (v *Volume) ListParts(path, uploadId string, maxParts, partNumberMarker uin
 		return
 	}
 
+	// The parts are listed in the order of the part numbers after the part number marker.
 	sessionParts := multipartInfo.Parts
-	resLength := maxParts
-	isTruncated = true
-	if (uint64(len(sessionParts)) - partNumberMarker) < maxParts {
-		resLength = uint64(len(sessionParts)) - partNumberMarker
-		isTruncated = false
-		nextMarker = 0
-	} else {
-		nextMarker = partNumberMarker + resLength
-	}
-
-	sessionPartsTemp := sessionParts[partNumberMarker:resLength]
-	for _, sessionPart := range sessionPartsTemp {
+	sort.SliceStable(sessionParts, func(i, j int) bool { return sessionParts[i].ID < sessionParts[j].ID })
+	for _, sessionPart := range sessionParts {
+		if uint64(sessionPart.ID) <= partNumberMarker {
+			continue
+		}
+		if uint64(len(parts)) >= maxParts {
+			isTruncated = true
+			break
+		}
+		nextMarker = uint64(sessionPart.ID)
 		fsPart := &FSPart{
 			PartNumber:   int(sessionPart.ID),
 			LastModified: formatTimeISO(sessionPart.UploadTime),
@@ -2019,7 +2374,8 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 
 	// if source path is same with target path, just reset file metadata
 	// source path is same with target path, and metadata directive is not 'REPLACE', object node do nothing
-	if targetPath == sourcePath {
+	// unless the object is encrypted again
+	if targetPath == sourcePath && (opt == nil || opt.SSE == nil) {
 		if metaDirective != MetadataDirectiveReplace {
 			log.LogInfof("CopyFile: target path is equal with source path, object node do nothing, source path(%v) target path(%v) err(%v)",
 				sourcePath, targetPath, err)
@@ -2069,6 +2425,11 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 			log.LogInfof("CopyFile: target path is equal with source path, replace metadata, source path(%v) target path(%v) opt(%v)",
 				sourcePath, targetPath, opt)
 		}
+		if opt != nil && opt.ACL != nil {
+			if err = v.storeInodeACL(sInode, opt.ACL); err != nil {
+				return nil, err
+			}
+		}
 		return sv.ObjectMeta(sourcePath)
 	}
 
@@ -2159,6 +2520,21 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		}
 	}()
 
+	// the data is decrypted with the key of the source object and encrypted with a new key
+	var sourceCipher, targetCipher cipher.Block
+	if sourceCipher, err = sv.loadSSECipher(sInode); err != nil {
+		return
+	}
+	var sseKey *sseDataKey
+	if opt != nil && opt.SSE != nil {
+		if sseKey, err = v.sse.generateDataKey(opt.SSE); err != nil {
+			log.LogErrorf("CopyFile: generate data key fail: volume(%v) path(%v) algorithm(%v) err(%v)",
+				v.name, targetPath, opt.SSE.Algorithm, err)
+			return
+		}
+		targetCipher = sseKey.newCipher()
+	}
+
 	// write data to invisibleTempDataInode from source object
 	var (
 		fileSize    = sInodeInfo.Size
@@ -2185,6 +2561,14 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 			return
 		}
 		if readN > 0 {
+			if sourceCipher != nil {
+				sseXorKeyStream(sourceCipher, buf[:readN], readOffset)
+			}
+			// copy to md5 buffer before the data is encrypted
+			copy(hashBuf, buf[:readN])
+			if targetCipher != nil {
+				sseXorKeyStream(targetCipher, buf[:readN], writeOffset)
+			}
 			if writeN, err = v.ec.Write(tInodeInfo.Inode, writeOffset, buf[:readN], 0); err != nil {
 				log.LogErrorf("CopyFile: write target path from source fail, volume(%v) path(%v) inode(%v) target offset(%v) err(%v)",
 					v.name, targetPath, tInodeInfo.Inode, writeOffset, err)
@@ -2192,8 +2576,7 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 			}
 			readOffset += readN
 			writeOffset += writeN
-			// copy to md5 buffer, and then write to md5
-			copy(hashBuf, buf[:readN])
+			// write to md5
 			md5Hash.Write(hashBuf[:readN])
 		}
 		if err == io.EOF {
@@ -2228,6 +2611,13 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		return
 	}
 
+	// Save target file data key
+	if sseKey != nil {
+		if err = v.storeSSEDataKey(tInodeInfo.Inode, sseKey); err != nil {
+			return
+		}
+	}
+
 	// copy source file metadata to write target file metadata
 	if metaDirective != MetadataDirectiveReplace {
 		// get source file xattr keys
@@ -2252,7 +2642,8 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		// set tar xattr
 		if len(xattrs) > 0 {
 			for xk, xv := range xattrs[0].XAttrs {
-				if xk == XAttrKeyOSSETag {
+				if xk == XAttrKeyOSSETag || xk == XAttrKeyOSSReplicationStatus || xk == XAttrKeyOSSACL ||
+					isSSEXAttrKey(xk) || isObjectLockXAttrKey(xk) {
 					continue
 				}
 				if err = v.mw.XAttrSet_ll(tInodeInfo.Inode, []byte(xk), []byte(xv)); err != nil {
@@ -2304,6 +2695,13 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		}
 	}
 
+	// The ACL of the source object is not copied, and the target object has the ACL given by the request.
+	if opt != nil && opt.ACL != nil {
+		if err = v.storeInodeACL(tInodeInfo.Inode, opt.ACL); err != nil {
+			return nil, err
+		}
+	}
+
 	// create file info
 	info = &FSFileInfo{
 		Path:       targetPath,
@@ -2314,9 +2712,12 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		ETag:       md5Value,
 		Inode:      tInodeInfo.Inode,
 	}
+	if sseKey != nil {
+		sseKey.fillFileInfo(info)
+	}
 
 	// apply new inode to dentry
-	err = v.applyInodeToDEntry(tParentId, tLastName, tInodeInfo.Inode)
+	info.VersionId, err = v.applyInodeToObject(targetPath, tParentId, tLastName, tInodeInfo.Inode)
 	if err != nil {
 		log.LogErrorf("CopyFile: apply inode to new dentry fail: path(%v) parentID(%v) name(%v) inode(%v) err(%v)",
 			targetPath, tParentId, tLastName, tInodeInfo.Inode, err)
@@ -2374,7 +2775,10 @@ func NewVolume(config *VolumeConfig) (*Volume, error) {
 		ec:         extentClient,
 		name:       config.Volume,
 		store:      config.Store,
+		sse:        config.SSE,
 		createTime: metaWrapper.VolCreateTime(),
+		mc:         master.NewMasterClient(config.Masters, false),
+		quota:      new(bucketQuota),
 		closeCh:    make(chan struct{}),
 		onAsyncTaskError: func(err error) {
 			if err == syscall.ENOENT {
@@ -2388,6 +2792,7 @@ func NewVolume(config *VolumeConfig) (*Volume, error) {
 		v.metaLoader = &cacheMetaLoader{om: new(OSSMeta)}
 		go v.syncOSSMeta()
 	}
+	go v.syncQuota()
 
 	return v, nil
 }
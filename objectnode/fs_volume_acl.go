@@ -0,0 +1,77 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The ACL of an object is stored in the extended attribute of its inode like the tags, so each
+// version of the object has its own ACL. An object without ACL is accessed by the permissions of
+// the bucket only.
+
+// loadInodeACL returns the ACL of the inode, which is nil if the inode has no ACL.
+func (v *Volume) loadInodeACL(inode uint64) (acl *AccessControlPolicy, err error) {
+	var info *proto.XAttrInfo
+	if info, err = v.mw.XAttrGet_ll(inode, XAttrKeyOSSACL); err != nil {
+		log.LogErrorf("loadInodeACL: meta get xattr fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	var raw = info.Get(XAttrKeyOSSACL)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	return ParseACL(raw, v.name)
+}
+
+// storeInodeACL replaces the ACL of the inode.
+func (v *Volume) storeInodeACL(inode uint64, acl *AccessControlPolicy) (err error) {
+	var data []byte
+	if data, err = acl.Marshal(); err != nil {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(inode, []byte(XAttrKeyOSSACL), data); err != nil {
+		log.LogErrorf("storeInodeACL: meta set xattr fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	return
+}
+
+// GetObjectACL returns the ACL of the version of the object, which is nil if the object has no ACL,
+// and the version id of it.
+func (v *Volume) GetObjectACL(path, versionId string) (acl *AccessControlPolicy, inodeVersionId string, err error) {
+	var inode uint64
+	if inode, inodeVersionId, err = v.objectVersionInode(path, versionId); err != nil {
+		return
+	}
+	if acl, err = v.loadInodeACL(inode); err != nil {
+		return
+	}
+	return
+}
+
+// PutObjectACL replaces the ACL of the version of the object, and returns the version id of it.
+func (v *Volume) PutObjectACL(path, versionId string, acl *AccessControlPolicy) (inodeVersionId string, err error) {
+	var inode uint64
+	if inode, inodeVersionId, err = v.objectVersionInode(path, versionId); err != nil {
+		return
+	}
+	if err = v.storeInodeACL(inode, acl); err != nil {
+		return
+	}
+	log.LogInfof("Audit: PutObjectACL: volume(%v) path(%v) versionId(%v)", v.name, path, inodeVersionId)
+	return
+}
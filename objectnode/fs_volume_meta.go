@@ -20,9 +20,25 @@ type ossMetaLoader interface {
 	loadPolicy() (p *Policy, err error)
 	loadACL() (p *AccessControlPolicy, err error)
 	loadCors() (cors *CORSConfiguration, err error)
+	loadVersioning() (versioning *VersioningConfiguration, err error)
+	loadLifecycle() (lifecycle *LifecycleConfiguration, err error)
+	loadNotification() (notification *NotificationConfiguration, err error)
+	loadObjectLock() (objectLock *ObjectLockConfiguration, err error)
+	loadLogging() (logging *BucketLoggingStatus, err error)
+	loadWebsite() (website *WebsiteConfiguration, err error)
+	loadReplication() (replication *ReplicationConfiguration, err error)
+	loadInventory() (inventory *InventoryConfigurations, err error)
 	storePolicy(p *Policy)
 	storeACL(p *AccessControlPolicy)
 	storeCors(cors *CORSConfiguration)
+	storeVersioning(versioning *VersioningConfiguration)
+	storeLifecycle(lifecycle *LifecycleConfiguration)
+	storeNotification(notification *NotificationConfiguration)
+	storeObjectLock(objectLock *ObjectLockConfiguration)
+	storeLogging(logging *BucketLoggingStatus)
+	storeWebsite(website *WebsiteConfiguration)
+	storeReplication(replication *ReplicationConfiguration)
+	storeInventory(inventory *InventoryConfigurations)
 }
 
 type strictMetaLoader struct {
@@ -35,12 +51,28 @@ type cacheMetaLoader struct {
 
 // OSSMeta is bucket policy and ACL metadata.
 type OSSMeta struct {
-	policy     *Policy
-	acl        *AccessControlPolicy
-	corsConfig *CORSConfiguration
-	policyLock sync.RWMutex
-	aclLock    sync.RWMutex
-	corsLock   sync.RWMutex
+	policy           *Policy
+	acl              *AccessControlPolicy
+	corsConfig       *CORSConfiguration
+	versioning       *VersioningConfiguration
+	lifecycle        *LifecycleConfiguration
+	notification     *NotificationConfiguration
+	objectLock       *ObjectLockConfiguration
+	logging          *BucketLoggingStatus
+	website          *WebsiteConfiguration
+	replication      *ReplicationConfiguration
+	inventory        *InventoryConfigurations
+	policyLock       sync.RWMutex
+	aclLock          sync.RWMutex
+	corsLock         sync.RWMutex
+	versioningLock   sync.RWMutex
+	lifecycleLock    sync.RWMutex
+	notificationLock sync.RWMutex
+	objectLockLock   sync.RWMutex
+	loggingLock      sync.RWMutex
+	websiteLock      sync.RWMutex
+	replicationLock  sync.RWMutex
+	inventoryLock    sync.RWMutex
 }
 
 func (c *cacheMetaLoader) loadPolicy() (p *Policy, err error) {
@@ -85,6 +117,118 @@ func (c *cacheMetaLoader) storeCors(cors *CORSConfiguration) {
 	return
 }
 
+func (c *cacheMetaLoader) loadVersioning() (versioning *VersioningConfiguration, err error) {
+	c.om.versioningLock.RLock()
+	versioning = c.om.versioning
+	c.om.versioningLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeVersioning(versioning *VersioningConfiguration) {
+	c.om.versioningLock.Lock()
+	c.om.versioning = versioning
+	c.om.versioningLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadLifecycle() (lifecycle *LifecycleConfiguration, err error) {
+	c.om.lifecycleLock.RLock()
+	lifecycle = c.om.lifecycle
+	c.om.lifecycleLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeLifecycle(lifecycle *LifecycleConfiguration) {
+	c.om.lifecycleLock.Lock()
+	c.om.lifecycle = lifecycle
+	c.om.lifecycleLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadNotification() (notification *NotificationConfiguration, err error) {
+	c.om.notificationLock.RLock()
+	notification = c.om.notification
+	c.om.notificationLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeNotification(notification *NotificationConfiguration) {
+	c.om.notificationLock.Lock()
+	c.om.notification = notification
+	c.om.notificationLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadObjectLock() (objectLock *ObjectLockConfiguration, err error) {
+	c.om.objectLockLock.RLock()
+	objectLock = c.om.objectLock
+	c.om.objectLockLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeObjectLock(objectLock *ObjectLockConfiguration) {
+	c.om.objectLockLock.Lock()
+	c.om.objectLock = objectLock
+	c.om.objectLockLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadLogging() (logging *BucketLoggingStatus, err error) {
+	c.om.loggingLock.RLock()
+	logging = c.om.logging
+	c.om.loggingLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeLogging(logging *BucketLoggingStatus) {
+	c.om.loggingLock.Lock()
+	c.om.logging = logging
+	c.om.loggingLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadWebsite() (website *WebsiteConfiguration, err error) {
+	c.om.websiteLock.RLock()
+	website = c.om.website
+	c.om.websiteLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeWebsite(website *WebsiteConfiguration) {
+	c.om.websiteLock.Lock()
+	c.om.website = website
+	c.om.websiteLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadReplication() (replication *ReplicationConfiguration, err error) {
+	c.om.replicationLock.RLock()
+	replication = c.om.replication
+	c.om.replicationLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeReplication(replication *ReplicationConfiguration) {
+	c.om.replicationLock.Lock()
+	c.om.replication = replication
+	c.om.replicationLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadInventory() (inventory *InventoryConfigurations, err error) {
+	c.om.inventoryLock.RLock()
+	inventory = c.om.inventory
+	c.om.inventoryLock.RUnlock()
+	return
+}
+
+func (c *cacheMetaLoader) storeInventory(inventory *InventoryConfigurations) {
+	c.om.inventoryLock.Lock()
+	c.om.inventory = inventory
+	c.om.inventoryLock.Unlock()
+	return
+}
+
 func (s *strictMetaLoader) loadPolicy() (p *Policy, err error) {
 	return s.v.loadBucketPolicy()
 }
@@ -102,3 +246,51 @@ func (s *strictMetaLoader) loadCors() (cors *CORSConfiguration, err error) {
 }
 
 func (s *strictMetaLoader) storeCors(cors *CORSConfiguration) {}
+
+func (s *strictMetaLoader) loadVersioning() (versioning *VersioningConfiguration, err error) {
+	return s.v.loadBucketVersioning()
+}
+
+func (s *strictMetaLoader) storeVersioning(versioning *VersioningConfiguration) {}
+
+func (s *strictMetaLoader) loadLifecycle() (lifecycle *LifecycleConfiguration, err error) {
+	return s.v.loadBucketLifecycle()
+}
+
+func (s *strictMetaLoader) storeLifecycle(lifecycle *LifecycleConfiguration) {}
+
+func (s *strictMetaLoader) loadNotification() (notification *NotificationConfiguration, err error) {
+	return s.v.loadBucketNotification()
+}
+
+func (s *strictMetaLoader) storeNotification(notification *NotificationConfiguration) {}
+
+func (s *strictMetaLoader) loadObjectLock() (objectLock *ObjectLockConfiguration, err error) {
+	return s.v.loadBucketObjectLock()
+}
+
+func (s *strictMetaLoader) storeObjectLock(objectLock *ObjectLockConfiguration) {}
+
+func (s *strictMetaLoader) loadLogging() (logging *BucketLoggingStatus, err error) {
+	return s.v.loadBucketLogging()
+}
+
+func (s *strictMetaLoader) storeLogging(logging *BucketLoggingStatus) {}
+
+func (s *strictMetaLoader) loadWebsite() (website *WebsiteConfiguration, err error) {
+	return s.v.loadBucketWebsite()
+}
+
+func (s *strictMetaLoader) storeWebsite(website *WebsiteConfiguration) {}
+
+func (s *strictMetaLoader) loadReplication() (replication *ReplicationConfiguration, err error) {
+	return s.v.loadBucketReplication()
+}
+
+func (s *strictMetaLoader) storeReplication(replication *ReplicationConfiguration) {}
+
+func (s *strictMetaLoader) loadInventory() (inventory *InventoryConfigurations, err error) {
+	return s.v.loadBucketInventory()
+}
+
+func (s *strictMetaLoader) storeInventory(inventory *InventoryConfigurations) {}
@@ -0,0 +1,104 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The tags of an object are stored in the extended attribute of its inode, so each version of
+// the object has its own tags, and the tags are removed along with the version.
+
+// objectVersionInode returns the inode of the version of the object, or of the current version if
+// the version id is empty, and the version id of the inode.
+func (v *Volume) objectVersionInode(path, versionId string) (inode uint64, inodeVersionId string, err error) {
+	if versionId == "" {
+		var mode os.FileMode
+		if _, inode, _, mode, err = v.recursiveLookupTarget(path); err != nil {
+			return
+		}
+		if mode.IsDir() {
+			return inode, "", nil
+		}
+		if inodeVersionId, err = v.inodeVersionId(inode); err != nil {
+			return
+		}
+		return
+	}
+	var version *proto.ObjectVersionInfo
+	if version, _, err = v.lookupObjectVersion(path, versionId); err != nil {
+		return
+	}
+	if version.DeleteMarker {
+		return 0, "", syscall.ENOENT
+	}
+	return version.Inode, version.VersionId, nil
+}
+
+// loadInodeTagging returns the tags of the inode, which are empty if the inode has no tag.
+func (v *Volume) loadInodeTagging(inode uint64) (tagging *Tagging, err error) {
+	var info *proto.XAttrInfo
+	if info, err = v.mw.XAttrGet_ll(inode, XAttrKeyOSSTagging); err != nil {
+		log.LogErrorf("loadInodeTagging: meta get xattr fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	return ParseTagging(string(info.Get(XAttrKeyOSSTagging)))
+}
+
+// GetObjectTagging returns the tags of the version of the object, and the version id of it.
+func (v *Volume) GetObjectTagging(path, versionId string) (tagging *Tagging, inodeVersionId string, err error) {
+	var inode uint64
+	if inode, inodeVersionId, err = v.objectVersionInode(path, versionId); err != nil {
+		return
+	}
+	if tagging, err = v.loadInodeTagging(inode); err != nil {
+		return
+	}
+	return
+}
+
+// PutObjectTagging replaces the tags of the version of the object, and returns the version id of it.
+func (v *Volume) PutObjectTagging(path, versionId string, tagging *Tagging) (inodeVersionId string, err error) {
+	var inode uint64
+	if inode, inodeVersionId, err = v.objectVersionInode(path, versionId); err != nil {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(inode, []byte(XAttrKeyOSSTagging), []byte(tagging.Encode())); err != nil {
+		log.LogErrorf("PutObjectTagging: meta set xattr fail: volume(%v) path(%v) inode(%v) err(%v)",
+			v.name, path, inode, err)
+		return
+	}
+	log.LogInfof("Audit: PutObjectTagging: volume(%v) path(%v) versionId(%v)", v.name, path, inodeVersionId)
+	return
+}
+
+// DeleteObjectTagging removes the tags of the version of the object, and returns the version id of it.
+func (v *Volume) DeleteObjectTagging(path, versionId string) (inodeVersionId string, err error) {
+	var inode uint64
+	if inode, inodeVersionId, err = v.objectVersionInode(path, versionId); err != nil {
+		return
+	}
+	if err = v.mw.XAttrDel_ll(inode, XAttrKeyOSSTagging); err != nil {
+		log.LogErrorf("DeleteObjectTagging: meta delete xattr fail: volume(%v) path(%v) inode(%v) err(%v)",
+			v.name, path, inode, err)
+		return
+	}
+	log.LogInfof("Audit: DeleteObjectTagging: volume(%v) path(%v) versionId(%v)", v.name, path, inodeVersionId)
+	return
+}
@@ -0,0 +1,512 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"os"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The current version of an object is the file of the dentry at its path, and the version id
+// is kept in the extended attributes of its inode. The noncurrent versions and the delete
+// markers are kept by the meta partitions, and each noncurrent version holds the link of its
+// inode.
+
+type ListObjectVersionsOption struct {
+	Prefix          string
+	KeyMarker       string
+	VersionIdMarker string
+	MaxKeys         uint64
+}
+
+type ListObjectVersionsResult struct {
+	Versions            []*FSVersion
+	NextKeyMarker       string
+	NextVersionIdMarker string
+	Truncated           bool
+}
+
+func (v *Volume) versioningStatus() (status string, err error) {
+	var config *VersioningConfiguration
+	if config, err = v.metaLoader.loadVersioning(); err != nil {
+		log.LogErrorf("versioningStatus: load versioning fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	if config != nil {
+		status = config.Status
+	}
+	return
+}
+
+func (v *Volume) newVersionId(status string) (versionId string, err error) {
+	switch status {
+	case VersioningStatusEnabled:
+		return v.mw.NewObjectVersionID_ll()
+	case VersioningStatusSuspended:
+		return nullVersionId(time.Now()), nil
+	default:
+		return "", nil
+	}
+}
+
+func (v *Volume) inodeVersionId(inode uint64) (versionId string, err error) {
+	var info *proto.XAttrInfo
+	if info, err = v.mw.XAttrGet_ll(inode, XAttrKeyOSSVersionId); err != nil {
+		log.LogErrorf("inodeVersionId: meta get xattr fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	return string(info.Get(XAttrKeyOSSVersionId)), nil
+}
+
+// applyInodeToObject applies the inode as the current version of the object. If versioning
+// has been configured for the bucket, the replaced version is kept as a noncurrent version
+// instead of being released.
+func (v *Volume) applyInodeToObject(path string, parentId uint64, name string, inode uint64) (versionId string, err error) {
+	var status string
+	if status, err = v.versioningStatus(); err != nil {
+		return
+	}
+	if status == "" {
+		err = v.applyInodeToDEntry(parentId, name, inode)
+		return
+	}
+
+	if versionId, err = v.newVersionId(status); err != nil {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(inode, []byte(XAttrKeyOSSVersionId), []byte(versionId)); err != nil {
+		log.LogErrorf("applyInodeToObject: store version id fail: volume(%v) path(%v) inode(%v) versionId(%v) err(%v)",
+			v.name, path, inode, versionId, err)
+		return
+	}
+
+	var existMode uint32
+	_, existMode, err = v.mw.Lookup_ll(parentId, name)
+	if err != nil && err != syscall.ENOENT {
+		log.LogErrorf("applyInodeToObject: meta lookup fail: parentID(%v) name(%v) err(%v)", parentId, name, err)
+		return
+	}
+	if err == syscall.ENOENT {
+		if err = v.applyInodeToNewDentry(parentId, name, inode); err != nil {
+			return
+		}
+	} else {
+		if os.FileMode(existMode).IsDir() {
+			err = syscall.EINVAL
+			return
+		}
+		var oldInode uint64
+		if oldInode, err = v.mw.DentryUpdate_ll(parentId, name, inode); err != nil {
+			log.LogErrorf("applyInodeToObject: meta update dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
+				parentId, name, inode, err)
+			return
+		}
+		if err = v.archiveObjectInode(path, oldInode, status); err != nil {
+			return
+		}
+	}
+
+	if status == VersioningStatusSuspended {
+		// The new object replaces the null version of the object.
+		if err = v.removeNullVersion(path); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// archiveObjectInode keeps the inode which is no longer the current version of the object
+// as a noncurrent version. The null versions are released instead while versioning is suspended.
+func (v *Volume) archiveObjectInode(path string, inode uint64, status string) (err error) {
+	var versionId string
+	if versionId, err = v.inodeVersionId(inode); err != nil {
+		return
+	}
+	if isNullVersionId(versionId) && status == VersioningStatusSuspended {
+		v.releaseInode(inode)
+		return
+	}
+	var info *proto.InodeInfo
+	if info, err = v.mw.InodeGet_ll(inode); err != nil {
+		log.LogErrorf("archiveObjectInode: meta get inode fail: volume(%v) path(%v) inode(%v) err(%v)",
+			v.name, path, inode, err)
+		return
+	}
+	if versionId == "" {
+		versionId = nullVersionId(info.ModifyTime)
+	}
+	var version = &proto.ObjectVersionInfo{
+		Path:       path,
+		VersionId:  versionId,
+		Inode:      inode,
+		CreateTime: info.ModifyTime,
+	}
+	if err = v.mw.PutObjectVersion_ll(version); err != nil {
+		log.LogErrorf("archiveObjectInode: put version fail: volume(%v) path(%v) inode(%v) versionId(%v) err(%v)",
+			v.name, path, inode, versionId, err)
+		return
+	}
+	log.LogDebugf("archiveObjectInode: put version: volume(%v) path(%v) inode(%v) versionId(%v)",
+		v.name, path, inode, versionId)
+	return
+}
+
+func (v *Volume) releaseInode(inode uint64) {
+	log.LogWarnf("releaseInode: unlink inode: volume(%v) inode(%v)", v.name, inode)
+	if _, err := v.mw.InodeUnlink_ll(inode); err != nil {
+		log.LogWarnf("releaseInode: unlink inode fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+	}
+	log.LogWarnf("releaseInode: evict inode: volume(%v) inode(%v)", v.name, inode)
+	if err := v.mw.Evict(inode); err != nil {
+		log.LogWarnf("releaseInode: evict inode fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+	}
+}
+
+// listPathVersions returns all the noncurrent versions and the delete markers of the path,
+// the newer versions first.
+func (v *Volume) listPathVersions(path string) (versions []*proto.ObjectVersionInfo, err error) {
+	var marker, versionIdMarker string
+	for {
+		var batch []*proto.ObjectVersionInfo
+		if batch, err = v.mw.ListObjectVersions_ll(path, marker, versionIdMarker, MaxKeys); err != nil {
+			log.LogErrorf("listPathVersions: list versions fail: volume(%v) path(%v) err(%v)", v.name, path, err)
+			return
+		}
+		for _, version := range batch {
+			if version.Path != path {
+				return
+			}
+			versions = append(versions, version)
+		}
+		if len(batch) < MaxKeys {
+			return
+		}
+		marker, versionIdMarker = path, batch[len(batch)-1].VersionId
+	}
+}
+
+func (v *Volume) removeNullVersion(path string) (err error) {
+	var versions []*proto.ObjectVersionInfo
+	if versions, err = v.listPathVersions(path); err != nil {
+		return
+	}
+	for _, version := range versions {
+		if !isNullVersionId(version.VersionId) {
+			continue
+		}
+		if err = v.mw.RemoveObjectVersion_ll(path, version.VersionId); err != nil {
+			log.LogErrorf("removeNullVersion: remove version fail: volume(%v) path(%v) versionId(%v) err(%v)",
+				v.name, path, version.VersionId, err)
+			return
+		}
+		if !version.DeleteMarker {
+			v.releaseInode(version.Inode)
+		}
+	}
+	return
+}
+
+// DeleteObject deletes the current version of the object. If versioning has been configured
+// for the bucket, the current version is kept as a noncurrent version and a delete marker is
+// placed, and the version id of the delete marker is returned.
+func (v *Volume) DeleteObject(path string) (markerVersionId string, err error) {
+	defer func() {
+		// Audit behavior
+		log.LogInfof("Audit: DeleteObject: volume(%v) path(%v) markerVersionId(%v) err(%v)",
+			v.name, path, markerVersionId, err)
+	}()
+	var status string
+	if status, err = v.versioningStatus(); err != nil {
+		return
+	}
+	if status == "" {
+		err = v.DeletePath(path)
+		return
+	}
+
+	var parent, ino uint64
+	var name string
+	var mode os.FileMode
+	parent, ino, name, mode, err = v.recursiveLookupTarget(path)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	if err == nil && mode.IsDir() {
+		// Directories are not versioned.
+		err = v.DeletePath(path)
+		return
+	}
+	if err == nil {
+		// Take a link for the noncurrent version before the dentry releases its own.
+		if _, err = v.mw.InodeLink_ll(ino); err != nil {
+			return
+		}
+		if _, err = v.mw.Delete_ll(parent, name, false); err != nil {
+			_, _ = v.mw.InodeUnlink_ll(ino)
+			return
+		}
+		if err = v.archiveObjectInode(path, ino, status); err != nil {
+			return
+		}
+	}
+
+	if status == VersioningStatusSuspended {
+		// The delete marker replaces the null version of the object.
+		if err = v.removeNullVersion(path); err != nil {
+			return
+		}
+	}
+	if markerVersionId, err = v.newVersionId(status); err != nil {
+		return
+	}
+	var marker = &proto.ObjectVersionInfo{
+		Path:         path,
+		VersionId:    markerVersionId,
+		DeleteMarker: true,
+		CreateTime:   time.Now(),
+	}
+	if err = v.mw.PutObjectVersion_ll(marker); err != nil {
+		log.LogErrorf("DeleteObject: put delete marker fail: volume(%v) path(%v) versionId(%v) err(%v)",
+			v.name, path, markerVersionId, err)
+		return
+	}
+	return
+}
+
+// lookupObjectVersion finds the version of the object by the version id shown to the clients.
+func (v *Volume) lookupObjectVersion(path, versionId string) (version *proto.ObjectVersionInfo, current bool, err error) {
+	var ino uint64
+	var mode os.FileMode
+	_, ino, _, mode, err = v.recursiveLookupTarget(path)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	if err == nil && !mode.IsDir() {
+		var currentVersionId string
+		if currentVersionId, err = v.inodeVersionId(ino); err != nil {
+			return
+		}
+		if displayVersionId(currentVersionId) == versionId {
+			version = &proto.ObjectVersionInfo{
+				Path:      path,
+				VersionId: currentVersionId,
+				Inode:     ino,
+			}
+			return version, true, nil
+		}
+	}
+
+	if versionId == NullVersionId {
+		var versions []*proto.ObjectVersionInfo
+		if versions, err = v.listPathVersions(path); err != nil {
+			return
+		}
+		for _, version = range versions {
+			if isNullVersionId(version.VersionId) {
+				return version, false, nil
+			}
+		}
+		return nil, false, syscall.ENOENT
+	}
+	version, err = v.mw.GetObjectVersion_ll(path, versionId)
+	return
+}
+
+// ObjectVersionMeta returns the meta of the specified version of the object. The current
+// version is returned if the version id is empty.
+func (v *Volume) ObjectVersionMeta(path, versionId string) (info *FSFileInfo, err error) {
+	if versionId == "" {
+		return v.ObjectMeta(path)
+	}
+	var version *proto.ObjectVersionInfo
+	var current bool
+	if version, current, err = v.lookupObjectVersion(path, versionId); err != nil {
+		return
+	}
+	if current {
+		return v.ObjectMeta(path)
+	}
+	if version.DeleteMarker {
+		info = &FSFileInfo{
+			Path:         path,
+			ModifyTime:   version.CreateTime,
+			CreateTime:   version.CreateTime,
+			VersionId:    version.VersionId,
+			DeleteMarker: true,
+		}
+		return
+	}
+	var inoInfo *proto.InodeInfo
+	if inoInfo, err = v.mw.InodeGet_ll(version.Inode); err != nil {
+		log.LogErrorf("ObjectVersionMeta: get inode fail: volume(%v) path(%v) versionId(%v) inode(%v) err(%v)",
+			v.name, path, versionId, version.Inode, err)
+		return
+	}
+	if info, err = v.inodeMeta(path, os.FileMode(inoInfo.Mode), inoInfo); err != nil {
+		return
+	}
+	info.VersionId = version.VersionId
+	return
+}
+
+// DeleteObjectVersion permanently deletes the specified version of the object, and returns
+// whether the version is a delete marker. If the current version or the latest delete marker
+// is deleted, the newest remaining version becomes the current version.
+func (v *Volume) DeleteObjectVersion(path, versionId string) (deleteMarker bool, err error) {
+	defer func() {
+		// Audit behavior
+		log.LogInfof("Audit: DeleteObjectVersion: volume(%v) path(%v) versionId(%v) err(%v)",
+			v.name, path, versionId, err)
+	}()
+	var version *proto.ObjectVersionInfo
+	var current bool
+	version, current, err = v.lookupObjectVersion(path, versionId)
+	if err == syscall.ENOENT {
+		return false, nil
+	}
+	if err != nil {
+		return
+	}
+	if current {
+		if err = v.DeletePath(path); err != nil {
+			return
+		}
+	} else {
+		if err = v.mw.RemoveObjectVersion_ll(path, version.VersionId); err != nil && err != syscall.ENOENT {
+			log.LogErrorf("DeleteObjectVersion: remove version fail: volume(%v) path(%v) versionId(%v) err(%v)",
+				v.name, path, version.VersionId, err)
+			return
+		}
+		if err == nil && !version.DeleteMarker {
+			v.releaseInode(version.Inode)
+		}
+	}
+	err = v.promoteLatestVersion(path)
+	return version.DeleteMarker, err
+}
+
+// promoteLatestVersion restores the newest noncurrent version as the current version of the
+// object, if the object has no current version and the newest version is not a delete marker.
+func (v *Volume) promoteLatestVersion(path string) (err error) {
+	if _, _, _, _, err = v.recursiveLookupTarget(path); err != syscall.ENOENT {
+		return
+	}
+	var versions []*proto.ObjectVersionInfo
+	if versions, err = v.listPathVersions(path); err != nil {
+		return
+	}
+	if len(versions) == 0 || versions[0].DeleteMarker {
+		return
+	}
+	var latest = versions[0]
+	var pathItems = NewPathIterator(path).ToSlice()
+	if len(pathItems) == 0 {
+		return
+	}
+	var parentId uint64
+	if parentId, err = v.recursiveMakeDirectory(path); err != nil {
+		log.LogErrorf("promoteLatestVersion: recursive make directory fail: volume(%v) path(%v) err(%v)",
+			v.name, path, err)
+		return
+	}
+	if err = v.mw.XAttrSet_ll(latest.Inode, []byte(XAttrKeyOSSVersionId), []byte(latest.VersionId)); err != nil {
+		return
+	}
+	if err = v.applyInodeToNewDentry(parentId, pathItems[len(pathItems)-1].Name, latest.Inode); err != nil {
+		return
+	}
+	if err = v.mw.RemoveObjectVersion_ll(path, latest.VersionId); err != nil {
+		log.LogErrorf("promoteLatestVersion: remove version fail: volume(%v) path(%v) versionId(%v) err(%v)",
+			v.name, path, latest.VersionId, err)
+		return
+	}
+	log.LogDebugf("promoteLatestVersion: promote version: volume(%v) path(%v) versionId(%v) inode(%v)",
+		v.name, path, latest.VersionId, latest.Inode)
+	return
+}
+
+// ListObjectVersions lists the current versions, the noncurrent versions and the delete markers
+// of the objects with the prefix, ordered by the key and then the newer versions first.
+func (v *Volume) ListObjectVersions(opt *ListObjectVersionsOption) (result *ListObjectVersionsResult, err error) {
+	var currents []*FSFileInfo
+	if currents, _, _, err = v.listFilesV1(opt.Prefix, opt.KeyMarker, "", opt.MaxKeys+1); err != nil {
+		log.LogErrorf("ListObjectVersions: list files fail: volume(%v) prefix(%v) keyMarker(%v) err(%v)",
+			v.name, opt.Prefix, opt.KeyMarker, err)
+		return
+	}
+	var versions []*proto.ObjectVersionInfo
+	if versions, err = v.mw.ListObjectVersions_ll(opt.Prefix, opt.KeyMarker, opt.VersionIdMarker, opt.MaxKeys+1); err != nil {
+		log.LogErrorf("ListObjectVersions: list versions fail: volume(%v) prefix(%v) keyMarker(%v) versionIdMarker(%v) err(%v)",
+			v.name, opt.Prefix, opt.KeyMarker, opt.VersionIdMarker, err)
+		return
+	}
+
+	var entries = make([]*FSVersion, 0, len(currents)+len(versions))
+	for _, info := range currents {
+		// All the versions of the key marker, the current version first, are listed in the previous pages.
+		if info.Path == opt.KeyMarker {
+			continue
+		}
+		if info.VersionId == "" {
+			info.VersionId = nullVersionId(info.ModifyTime)
+		}
+		entries = append(entries, &FSVersion{FSFileInfo: info, IsLatest: true})
+	}
+	var noncurrents = make([]*FSFileInfo, 0, len(versions))
+	for _, version := range versions {
+		var info = &FSFileInfo{
+			Path:         version.Path,
+			Inode:        version.Inode,
+			ModifyTime:   version.CreateTime,
+			VersionId:    version.VersionId,
+			DeleteMarker: version.DeleteMarker,
+		}
+		if !version.DeleteMarker {
+			noncurrents = append(noncurrents, info)
+		}
+		entries = append(entries, &FSVersion{FSFileInfo: info})
+	}
+	if err = v.supplyListFileInfo(noncurrents); err != nil {
+		return
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return (entries[i].Path < entries[j].Path) ||
+			((entries[i].Path == entries[j].Path) && (entries[i].VersionId < entries[j].VersionId))
+	})
+	// The newest delete marker of an object without current version is the latest version.
+	for i, entry := range entries {
+		if entry.DeleteMarker && entry.Path != opt.KeyMarker && (i == 0 || entries[i-1].Path != entry.Path) {
+			entry.IsLatest = true
+		}
+	}
+
+	result = &ListObjectVersionsResult{}
+	if uint64(len(entries)) > opt.MaxKeys {
+		entries = entries[:opt.MaxKeys]
+		result.Truncated = true
+		if len(entries) > 0 {
+			result.NextKeyMarker = entries[len(entries)-1].Path
+			result.NextVersionIdMarker = entries[len(entries)-1].VersionId
+		}
+	}
+	result.Versions = entries
+	return
+}
@@ -0,0 +1,778 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-inventory.html
+//
+// The inventory executor scans the buckets with inventory configurations periodically, and generates
+// the reports of the enabled configurations which are due by their daily or weekly schedules. A report
+// lists the objects matching the filter in the data files of CSV (gzip compressed) or Parquet format,
+// which are delivered to the destination bucket with a manifest:
+//
+//	<prefix>/<source bucket>/<id>/data/<uuid>.csv.gz
+//	<prefix>/<source bucket>/<id>/<YYYY-MM-DDTHH-MMZ>/manifest.json
+//	<prefix>/<source bucket>/<id>/<YYYY-MM-DDTHH-MMZ>/manifest.checksum
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/google/uuid"
+)
+
+const (
+	InventoryFormatCSV     = "CSV"
+	InventoryFormatParquet = "Parquet"
+
+	InventoryFrequencyDaily  = "Daily"
+	InventoryFrequencyWeekly = "Weekly"
+
+	InventoryVersionsCurrent = "Current"
+	InventoryVersionsAll     = "All"
+
+	InventoryFieldSize                      = "Size"
+	InventoryFieldLastModifiedDate          = "LastModifiedDate"
+	InventoryFieldETag                      = "ETag"
+	InventoryFieldStorageClass              = "StorageClass"
+	InventoryFieldReplicationStatus         = "ReplicationStatus"
+	InventoryFieldEncryptionStatus          = "EncryptionStatus"
+	InventoryFieldObjectLockRetainUntilDate = "ObjectLockRetainUntilDate"
+	InventoryFieldObjectLockMode            = "ObjectLockMode"
+	InventoryFieldObjectLockLegalHoldStatus = "ObjectLockLegalHoldStatus"
+
+	MaxInventoryConfigurations = 1000
+	MaxInventoryListSize       = 100
+
+	inventoryManifestVersion = "2016-11-30"
+	inventoryFileRecords     = 100000 // the records of a data file
+	inventoryTimeFormat      = "2006-01-02T15:04:05.000Z"
+	inventoryDateFormat      = "2006-01-02T15-04Z"
+)
+
+var (
+	// the optional fields in the order of the columns of the reports
+	inventoryOptionalFields = []string{
+		InventoryFieldSize,
+		InventoryFieldLastModifiedDate,
+		InventoryFieldETag,
+		InventoryFieldStorageClass,
+		InventoryFieldReplicationStatus,
+		InventoryFieldEncryptionStatus,
+		InventoryFieldObjectLockRetainUntilDate,
+		InventoryFieldObjectLockMode,
+		InventoryFieldObjectLockLegalHoldStatus,
+	}
+
+	inventoryIdRegexp = regexp.MustCompile("^[a-zA-Z0-9._-]{1,64}$")
+)
+
+type InventoryConfiguration struct {
+	XMLName                xml.Name                 `xml:"InventoryConfiguration"`
+	Id                     string                   `xml:"Id"`
+	IsEnabled              bool                     `xml:"IsEnabled"`
+	Destination            *InventoryDestination    `xml:"Destination"`
+	Filter                 *InventoryFilter         `xml:"Filter,omitempty"`
+	IncludedObjectVersions string                   `xml:"IncludedObjectVersions"`
+	OptionalFields         *InventoryOptionalFields `xml:"OptionalFields,omitempty"`
+	Schedule               *InventorySchedule       `xml:"Schedule"`
+}
+
+type InventoryDestination struct {
+	S3BucketDestination *InventoryS3BucketDestination `xml:"S3BucketDestination"`
+}
+
+type InventoryS3BucketDestination struct {
+	AccountId string `xml:"AccountId,omitempty"`
+	Bucket    string `xml:"Bucket"`
+	Format    string `xml:"Format"`
+	Prefix    string `xml:"Prefix,omitempty"`
+}
+
+type InventoryFilter struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type InventoryOptionalFields struct {
+	Fields []string `xml:"Field"`
+}
+
+type InventorySchedule struct {
+	Frequency string `xml:"Frequency"`
+}
+
+// InventoryConfigurations are all the inventory configurations of a bucket, which are stored together.
+type InventoryConfigurations struct {
+	XMLName        xml.Name                  `xml:"InventoryConfigurations"`
+	Configurations []*InventoryConfiguration `xml:"InventoryConfiguration"`
+}
+
+type ListInventoryConfigurationsResult struct {
+	XMLName               xml.Name                  `xml:"ListInventoryConfigurationsResult"`
+	Xmlns                 string                    `xml:"xmlns,attr,omitempty"`
+	Configurations        []*InventoryConfiguration `xml:"InventoryConfiguration"`
+	IsTruncated           bool                      `xml:"IsTruncated"`
+	ContinuationToken     string                    `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string                    `xml:"NextContinuationToken,omitempty"`
+}
+
+// destinationBucket returns the name of the destination bucket of the same cluster.
+func (config *InventoryConfiguration) destinationBucket() string {
+	return strings.TrimPrefix(config.Destination.S3BucketDestination.Bucket, LocalBucketARNPrefix)
+}
+
+func (config *InventoryConfiguration) prefix() string {
+	if config.Filter != nil {
+		return config.Filter.Prefix
+	}
+	return ""
+}
+
+// period returns the interval between the reports of the schedule.
+func (config *InventoryConfiguration) period() time.Duration {
+	if config.Schedule.Frequency == InventoryFrequencyWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// fileSchema returns the columns of the reports.
+func (config *InventoryConfiguration) fileSchema() []string {
+	var schema = []string{"Bucket", "Key"}
+	if config.IncludedObjectVersions == InventoryVersionsAll {
+		schema = append(schema, "VersionId", "IsLatest", "IsDeleteMarker")
+	}
+	if config.OptionalFields == nil {
+		return schema
+	}
+	for _, field := range inventoryOptionalFields {
+		for _, f := range config.OptionalFields.Fields {
+			if f == field {
+				schema = append(schema, field)
+				break
+			}
+		}
+	}
+	return schema
+}
+
+func (config *InventoryConfiguration) validate() error {
+	if !inventoryIdRegexp.MatchString(config.Id) {
+		return fmt.Errorf("invalid inventory configuration ID: %v", config.Id)
+	}
+	if config.Destination == nil || config.Destination.S3BucketDestination == nil {
+		return errors.New("the destination of inventory configuration must be specified")
+	}
+	var dest = config.Destination.S3BucketDestination
+	if !strings.HasPrefix(dest.Bucket, LocalBucketARNPrefix) || config.destinationBucket() == "" ||
+		strings.ContainsAny(config.destinationBucket(), ":/") {
+		return fmt.Errorf("invalid destination bucket: %v", dest.Bucket)
+	}
+	if dest.Format != InventoryFormatCSV && dest.Format != InventoryFormatParquet {
+		return fmt.Errorf("unsupported inventory format: %v", dest.Format)
+	}
+	if config.IncludedObjectVersions != InventoryVersionsCurrent && config.IncludedObjectVersions != InventoryVersionsAll {
+		return fmt.Errorf("invalid included object versions: %v", config.IncludedObjectVersions)
+	}
+	if config.Schedule == nil ||
+		(config.Schedule.Frequency != InventoryFrequencyDaily && config.Schedule.Frequency != InventoryFrequencyWeekly) {
+		return errors.New("invalid inventory schedule")
+	}
+	if config.OptionalFields != nil {
+		for _, f := range config.OptionalFields.Fields {
+			var valid bool
+			for _, field := range inventoryOptionalFields {
+				if f == field {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return fmt.Errorf("invalid optional field: %v", f)
+			}
+		}
+	}
+	return nil
+}
+
+// get returns the configuration of the id, or nil if it does not exist.
+func (configs *InventoryConfigurations) get(id string) *InventoryConfiguration {
+	for _, config := range configs.Configurations {
+		if config.Id == id {
+			return config
+		}
+	}
+	return nil
+}
+
+// put adds the configuration or replaces the configuration of the same id, which are kept in order of the ids.
+func (configs *InventoryConfigurations) put(config *InventoryConfiguration) {
+	for i, c := range configs.Configurations {
+		if c.Id == config.Id {
+			configs.Configurations[i] = config
+			return
+		}
+	}
+	configs.Configurations = append(configs.Configurations, config)
+	sort.Slice(configs.Configurations, func(i, j int) bool {
+		return configs.Configurations[i].Id < configs.Configurations[j].Id
+	})
+}
+
+// delete removes the configuration of the id, which returns false if it does not exist.
+func (configs *InventoryConfigurations) delete(id string) bool {
+	for i, c := range configs.Configurations {
+		if c.Id == id {
+			configs.Configurations = append(configs.Configurations[:i], configs.Configurations[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// list returns a page of the configurations following the continuation token, which is the id of the first one.
+func (configs *InventoryConfigurations) list(token string) *ListInventoryConfigurationsResult {
+	var result = &ListInventoryConfigurationsResult{
+		Xmlns:             "http://s3.amazonaws.com/doc/2006-03-01/",
+		ContinuationToken: token,
+	}
+	for _, config := range configs.Configurations {
+		if config.Id < token {
+			continue
+		}
+		if len(result.Configurations) == MaxInventoryListSize {
+			result.IsTruncated = true
+			result.NextContinuationToken = config.Id
+			break
+		}
+		result.Configurations = append(result.Configurations, config)
+	}
+	return result
+}
+
+func storeBucketInventory(configs *InventoryConfigurations, vol *Volume) (err error) {
+	if len(configs.Configurations) == 0 {
+		return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSInventory)
+	}
+	var bytes []byte
+	if bytes, err = xml.Marshal(configs); err != nil {
+		return
+	}
+	if err = vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSInventory, bytes); err != nil {
+		return
+	}
+	return nil
+}
+
+// inventoryStatus is the time of the last report of each inventory configuration of a bucket.
+type inventoryStatus map[string]int64
+
+func (v *Volume) loadInventoryStatus() (status inventoryStatus, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSInventoryStatus); err != nil {
+		return
+	}
+	status = make(inventoryStatus)
+	if len(raw) == 0 {
+		return
+	}
+	if err = json.Unmarshal(raw, &status); err != nil {
+		return
+	}
+	return
+}
+
+func (v *Volume) storeInventoryStatus(status inventoryStatus) (err error) {
+	var raw []byte
+	if raw, err = json.Marshal(status); err != nil {
+		return
+	}
+	return v.store.Put(v.name, bucketRootPath, XAttrKeyOSSInventoryStatus, raw)
+}
+
+// inventoryFileWriter writes the records of a data file of the inventory report.
+type inventoryFileWriter interface {
+	write(record []interface{})
+	// bytes returns the data file, and its extension
+	bytes() ([]byte, string, error)
+}
+
+// inventoryCSVWriter writes the records in CSV format, all the fields are quoted.
+type inventoryCSVWriter struct {
+	buf bytes.Buffer
+	gw  *gzip.Writer
+}
+
+func newInventoryCSVWriter() *inventoryCSVWriter {
+	var w = &inventoryCSVWriter{}
+	w.gw = gzip.NewWriter(&w.buf)
+	return w
+}
+
+func (w *inventoryCSVWriter) write(record []interface{}) {
+	var line strings.Builder
+	for i, value := range record {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		var field string
+		switch v := value.(type) {
+		case string:
+			field = v
+		case int64:
+			field = strconv.FormatInt(v, 10)
+		case bool:
+			field = strconv.FormatBool(v)
+		case time.Time:
+			field = v.UTC().Format(inventoryTimeFormat)
+		}
+		line.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`)
+	}
+	line.WriteByte('\n')
+	_, _ = w.gw.Write([]byte(line.String()))
+}
+
+func (w *inventoryCSVWriter) bytes() ([]byte, string, error) {
+	if err := w.gw.Close(); err != nil {
+		return nil, "", err
+	}
+	return w.buf.Bytes(), ".csv.gz", nil
+}
+
+type inventoryParquetWriter struct {
+	*parquetWriter
+}
+
+func newInventoryParquetWriter(schema []string) *inventoryParquetWriter {
+	var w = &inventoryParquetWriter{parquetWriter: newParquetWriter()}
+	for _, field := range schema {
+		switch field {
+		case "IsLatest", "IsDeleteMarker":
+			w.addColumn(field, parquetBoolean, parquetConvertedNone)
+		case InventoryFieldSize:
+			w.addColumn(field, parquetInt64, parquetConvertedNone)
+		case InventoryFieldLastModifiedDate, InventoryFieldObjectLockRetainUntilDate:
+			w.addColumn(field, parquetInt64, parquetConvertedTimestampMillis)
+		default:
+			w.addColumn(field, parquetByteArray, parquetConvertedUTF8)
+		}
+	}
+	return w
+}
+
+func (w *inventoryParquetWriter) bytes() ([]byte, string, error) {
+	return w.parquetWriter.bytes(), ".parquet", nil
+}
+
+// inventoryRecord returns the values of the columns of the object, the fields not applied to the
+// object are nil.
+func inventoryRecord(bucket string, schema []string, info *FSFileInfo, isLatest bool) []interface{} {
+	var record = make([]interface{}, len(schema))
+	for i, field := range schema {
+		switch field {
+		case "Bucket":
+			record[i] = bucket
+		case "Key":
+			record[i] = info.Path
+		case "VersionId":
+			record[i] = info.VersionId
+		case "IsLatest":
+			record[i] = isLatest
+		case "IsDeleteMarker":
+			record[i] = info.DeleteMarker
+		}
+		if info.DeleteMarker {
+			continue
+		}
+		switch field {
+		case InventoryFieldSize:
+			record[i] = info.Size
+		case InventoryFieldLastModifiedDate:
+			record[i] = info.ModifyTime
+		case InventoryFieldETag:
+			record[i] = info.ETag
+		case InventoryFieldStorageClass:
+			record[i] = displayStorageClass(info.StorageClass)
+		case InventoryFieldReplicationStatus:
+			record[i] = info.ReplicationStatus
+		case InventoryFieldEncryptionStatus:
+			switch info.SSEAlgorithm {
+			case SSEAlgorithmAES256:
+				record[i] = "SSE-S3"
+			case SSEAlgorithmKMS:
+				record[i] = "SSE-KMS"
+			default:
+				record[i] = "NOT-SSE"
+			}
+		case InventoryFieldObjectLockRetainUntilDate:
+			if !info.ObjectLockRetainUntil.IsZero() {
+				record[i] = info.ObjectLockRetainUntil
+			}
+		case InventoryFieldObjectLockMode:
+			if info.ObjectLockMode != "" {
+				record[i] = info.ObjectLockMode
+			}
+		case InventoryFieldObjectLockLegalHoldStatus:
+			record[i] = info.ObjectLegalHold
+			if info.ObjectLegalHold == "" {
+				record[i] = LegalHoldStatusOff
+			}
+		}
+	}
+	return record
+}
+
+type inventoryManifestFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5Checksum string `json:"MD5checksum"`
+}
+
+type inventoryManifest struct {
+	SourceBucket      string                   `json:"sourceBucket"`
+	DestinationBucket string                   `json:"destinationBucket"`
+	Version           string                   `json:"version"`
+	CreationTimestamp string                   `json:"creationTimestamp"`
+	FileFormat        string                   `json:"fileFormat"`
+	FileSchema        string                   `json:"fileSchema"`
+	Files             []*inventoryManifestFile `json:"files"`
+}
+
+// The inventory executor scans the buckets with inventory configurations periodically, and delivers
+// the reports of the enabled configurations into the destination buckets once they are due.
+type inventoryExecutor struct {
+	vm       *VolumeManager
+	mc       *master.MasterClient
+	interval time.Duration
+	stopC    chan struct{}
+	stopOnce sync.Once
+}
+
+func newInventoryExecutor(vm *VolumeManager, mc *master.MasterClient, interval time.Duration) *inventoryExecutor {
+	return &inventoryExecutor{
+		vm:       vm,
+		mc:       mc,
+		interval: interval,
+		stopC:    make(chan struct{}),
+	}
+}
+
+func (e *inventoryExecutor) start() {
+	go e.run()
+}
+
+func (e *inventoryExecutor) stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopC)
+	})
+}
+
+func (e *inventoryExecutor) stopped() bool {
+	select {
+	case <-e.stopC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *inventoryExecutor) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopC:
+			return
+		case <-ticker.C:
+			e.scan()
+		}
+	}
+}
+
+func (e *inventoryExecutor) scan() {
+	vols, err := e.mc.AdminAPI().ListVols("")
+	if err != nil {
+		log.LogErrorf("inventory scan: list volumes fail: err(%v)", err)
+		return
+	}
+	for _, volInfo := range vols {
+		if e.stopped() {
+			return
+		}
+		if volInfo.Status == volStatusMarkDelete {
+			continue
+		}
+		var vol *Volume
+		if vol, err = e.vm.Volume(volInfo.Name); err != nil {
+			log.LogWarnf("inventory scan: load volume fail: volume(%v) err(%v)", volInfo.Name, err)
+			continue
+		}
+		var configs *InventoryConfigurations
+		if configs, err = vol.metaLoader.loadInventory(); err != nil {
+			log.LogWarnf("inventory scan: load inventory fail: volume(%v) err(%v)", volInfo.Name, err)
+			continue
+		}
+		if configs == nil {
+			continue
+		}
+		var status inventoryStatus
+		if status, err = vol.loadInventoryStatus(); err != nil {
+			log.LogWarnf("inventory scan: load inventory status fail: volume(%v) err(%v)", volInfo.Name, err)
+			continue
+		}
+		var now = time.Now()
+		for _, config := range configs.Configurations {
+			if !config.IsEnabled || now.Before(time.Unix(status[config.Id], 0).Add(config.period())) {
+				continue
+			}
+			// the report is marked before it is generated, so that it is generated once a period
+			status[config.Id] = now.Unix()
+			if err = vol.storeInventoryStatus(status); err != nil {
+				log.LogWarnf("inventory scan: store inventory status fail: volume(%v) id(%v) err(%v)",
+					vol.Name(), config.Id, err)
+				break
+			}
+			if err = e.generate(vol, config, now); err != nil {
+				log.LogWarnf("inventory scan: generate report fail: volume(%v) id(%v) err(%v)",
+					vol.Name(), config.Id, err)
+			}
+		}
+	}
+}
+
+// generate lists the objects of the configuration and delivers the report.
+func (e *inventoryExecutor) generate(vol *Volume, config *InventoryConfiguration, now time.Time) (err error) {
+	var dest *Volume
+	if dest, err = e.vm.Volume(config.destinationBucket()); err != nil {
+		return
+	}
+	var schema = config.fileSchema()
+	var basePath = inventoryBasePath(config, vol.Name())
+	var manifest = &inventoryManifest{
+		SourceBucket:      vol.Name(),
+		DestinationBucket: config.Destination.S3BucketDestination.Bucket,
+		Version:           inventoryManifestVersion,
+		CreationTimestamp: strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10),
+		FileFormat:        config.Destination.S3BucketDestination.Format,
+		FileSchema:        strings.Join(schema, ", "),
+		Files:             make([]*inventoryManifestFile, 0),
+	}
+
+	var writer inventoryFileWriter
+	var records int
+	var flush = func() (err error) {
+		if writer == nil {
+			return
+		}
+		var data []byte
+		var ext string
+		if data, ext, err = writer.bytes(); err != nil {
+			return
+		}
+		var key = basePath + "data/" + uuid.New().String() + ext
+		if _, err = dest.PutObject(key, bytes.NewReader(data), &PutFileOption{}); err != nil {
+			return fmt.Errorf("put data file: %v", err)
+		}
+		var sum = md5.Sum(data)
+		manifest.Files = append(manifest.Files, &inventoryManifestFile{Key: key, Size: int64(len(data)), MD5Checksum: hex.EncodeToString(sum[:])})
+		writer, records = nil, 0
+		return
+	}
+	var write = func(info *FSFileInfo, isLatest bool) (err error) {
+		if writer == nil {
+			if config.Destination.S3BucketDestination.Format == InventoryFormatParquet {
+				writer = newInventoryParquetWriter(schema)
+			} else {
+				writer = newInventoryCSVWriter()
+			}
+		}
+		writer.write(inventoryRecord(vol.Name(), schema, info, isLatest))
+		if records++; records >= inventoryFileRecords {
+			return flush()
+		}
+		return
+	}
+
+	if config.IncludedObjectVersions == InventoryVersionsAll {
+		err = e.listVersions(vol, config, schema, write)
+	} else {
+		err = e.listObjects(vol, config, schema, write)
+	}
+	if err != nil {
+		return
+	}
+	if err = flush(); err != nil {
+		return
+	}
+
+	var data []byte
+	if data, err = json.Marshal(manifest); err != nil {
+		return
+	}
+	var manifestPath = basePath + now.UTC().Format(inventoryDateFormat) + "/"
+	if _, err = dest.PutObject(manifestPath+"manifest.json", bytes.NewReader(data),
+		&PutFileOption{MIMEType: HeaderValueContentTypeJSON}); err != nil {
+		return fmt.Errorf("put manifest: %v", err)
+	}
+	var sum = md5.Sum(data)
+	if _, err = dest.PutObject(manifestPath+"manifest.checksum", strings.NewReader(hex.EncodeToString(sum[:])),
+		&PutFileOption{MIMEType: "text/plain"}); err != nil {
+		return fmt.Errorf("put manifest checksum: %v", err)
+	}
+	log.LogInfof("inventory: report delivered: volume(%v) id(%v) destination(%v) manifest(%v) files(%v)",
+		vol.Name(), config.Id, dest.Name(), manifestPath, len(manifest.Files))
+	return
+}
+
+// inventoryBasePath returns the path of the reports of the configuration in the destination bucket.
+func inventoryBasePath(config *InventoryConfiguration, bucket string) string {
+	var prefix = config.Destination.S3BucketDestination.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + bucket + "/" + config.Id + "/"
+}
+
+func (e *inventoryExecutor) listObjects(vol *Volume, config *InventoryConfiguration, schema []string,
+	write func(info *FSFileInfo, isLatest bool) error) (err error) {
+	var option = &ListFilesV1Option{
+		Prefix:  config.prefix(),
+		MaxKeys: MaxKeys,
+	}
+	for !e.stopped() {
+		var result *ListFilesV1Result
+		if result, err = vol.ListFilesV1(option); err != nil {
+			return
+		}
+		var files = make([]*FSFileInfo, 0, len(result.Files))
+		for _, file := range result.Files {
+			if !file.Mode.IsDir() {
+				files = append(files, file)
+			}
+		}
+		if err = supplyInventoryFileInfo(vol, schema, files); err != nil {
+			return
+		}
+		for _, file := range files {
+			if err = write(file, true); err != nil {
+				return
+			}
+		}
+		if !result.Truncated {
+			return
+		}
+		option.Marker = result.NextMarker
+	}
+	return errors.New("inventory executor stopped")
+}
+
+func (e *inventoryExecutor) listVersions(vol *Volume, config *InventoryConfiguration, schema []string,
+	write func(info *FSFileInfo, isLatest bool) error) (err error) {
+	var option = &ListObjectVersionsOption{
+		Prefix:  config.prefix(),
+		MaxKeys: MaxKeys,
+	}
+	for !e.stopped() {
+		var result *ListObjectVersionsResult
+		if result, err = vol.ListObjectVersions(option); err != nil {
+			return
+		}
+		var files = make([]*FSFileInfo, 0, len(result.Versions))
+		for _, version := range result.Versions {
+			if !version.DeleteMarker && !version.Mode.IsDir() {
+				files = append(files, version.FSFileInfo)
+			}
+		}
+		if err = supplyInventoryFileInfo(vol, schema, files); err != nil {
+			return
+		}
+		for _, version := range result.Versions {
+			if version.Mode.IsDir() {
+				continue
+			}
+			if err = write(version.FSFileInfo, version.IsLatest); err != nil {
+				return
+			}
+		}
+		if !result.Truncated {
+			return
+		}
+		option.KeyMarker, option.VersionIdMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+	return errors.New("inventory executor stopped")
+}
+
+// supplyInventoryFileInfo loads the attributes of the optional fields which are not given by the listings.
+func supplyInventoryFileInfo(vol *Volume, schema []string, files []*FSFileInfo) (err error) {
+	var keys []string
+	for _, field := range schema {
+		switch field {
+		case InventoryFieldReplicationStatus:
+			keys = append(keys, XAttrKeyOSSReplicationStatus)
+		case InventoryFieldEncryptionStatus:
+			keys = append(keys, XAttrKeyOSSSSE)
+		case InventoryFieldObjectLockRetainUntilDate:
+			keys = append(keys, XAttrKeyOSSLockRetainUntil)
+		case InventoryFieldObjectLockMode:
+			keys = append(keys, XAttrKeyOSSLockMode)
+		case InventoryFieldObjectLockLegalHoldStatus:
+			keys = append(keys, XAttrKeyOSSLegalHold)
+		}
+	}
+	if len(keys) == 0 || len(files) == 0 {
+		return
+	}
+	var inodes = make([]uint64, 0, len(files))
+	for _, file := range files {
+		inodes = append(inodes, file.Inode)
+	}
+	var xattrs []*proto.XAttrInfo
+	if xattrs, err = vol.mw.BatchGetXAttr(inodes, keys); err != nil {
+		log.LogErrorf("supplyInventoryFileInfo: batch get xattr fail: volume(%v) err(%v)", vol.Name(), err)
+		return
+	}
+	var xattrMap = make(map[uint64]*proto.XAttrInfo, len(xattrs))
+	for _, xattr := range xattrs {
+		xattrMap[xattr.Inode] = xattr
+	}
+	for _, file := range files {
+		var xattr = xattrMap[file.Inode]
+		if xattr == nil {
+			continue
+		}
+		file.ReplicationStatus = string(xattr.Get(XAttrKeyOSSReplicationStatus))
+		file.SSEAlgorithm = string(xattr.Get(XAttrKeyOSSSSE))
+		file.ObjectLockMode = string(xattr.Get(XAttrKeyOSSLockMode))
+		if sec, parseErr := strconv.ParseInt(string(xattr.Get(XAttrKeyOSSLockRetainUntil)), 10, 64); parseErr == nil {
+			file.ObjectLockRetainUntil = time.Unix(sec, 0)
+		}
+		file.ObjectLegalHold = string(xattr.Get(XAttrKeyOSSLegalHold))
+	}
+	return
+}
@@ -0,0 +1,215 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get bucket inventory configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketInventoryConfiguration.html
+func (o *ObjectNode) getBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var configs *InventoryConfigurations
+	if configs, err = vol.metaLoader.loadInventory(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	var config *InventoryConfiguration
+	if configs != nil {
+		config = configs.get(param.GetVar(ParamInventoryId))
+	}
+	if config == nil {
+		_ = NoSuchInventoryConfiguration.ServeResponse(w, r)
+		return
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(config); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// List bucket inventory configurations
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListBucketInventoryConfigurations.html
+func (o *ObjectNode) listBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var configs *InventoryConfigurations
+	if configs, err = vol.metaLoader.loadInventory(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if configs == nil {
+		configs = &InventoryConfigurations{}
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(configs.list(param.GetVar(ParamContToken))); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// Put bucket inventory configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketInventoryConfiguration.html
+func (o *ObjectNode) putBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var bytes []byte
+	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	var config = &InventoryConfiguration{}
+	if err = xml.Unmarshal(bytes, config); err != nil {
+		log.LogWarnf("putBucketInventoryHandler: parse inventory fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if config.Id != param.GetVar(ParamInventoryId) {
+		_ = (&ErrorCode{ErrorCode: InvalidArgument.ErrorCode, ErrorMessage: "The ID of the configuration does not match the id parameter.", StatusCode: InvalidArgument.StatusCode}).ServeResponse(w, r)
+		return
+	}
+	if err = config.validate(); err != nil {
+		log.LogWarnf("putBucketInventoryHandler: invalid inventory: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = (&ErrorCode{ErrorCode: InvalidArgument.ErrorCode, ErrorMessage: err.Error(), StatusCode: InvalidArgument.StatusCode}).ServeResponse(w, r)
+		return
+	}
+
+	// the destination bucket must be owned by the owner of the bucket
+	var dest *Volume
+	if dest, err = o.vm.Volume(config.destinationBucket()); err != nil || dest.Owner() != vol.Owner() {
+		log.LogWarnf("putBucketInventoryHandler: invalid destination bucket: requestID(%v) volume(%v) destination(%v) err(%v)",
+			GetRequestID(r), vol.Name(), config.destinationBucket(), err)
+		_ = InvalidInventoryDestination.ServeResponse(w, r)
+		return
+	}
+
+	// the configurations are copied to update, as the cached configurations are shared
+	var configs *InventoryConfigurations
+	if configs, err = vol.loadBucketInventory(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if configs == nil {
+		configs = &InventoryConfigurations{}
+	}
+	if configs.get(config.Id) == nil && len(configs.Configurations) >= MaxInventoryConfigurations {
+		_ = TooManyInventoryConfigurations.ServeResponse(w, r)
+		return
+	}
+	configs.put(config)
+	if err = storeBucketInventory(configs, vol); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	vol.metaLoader.storeInventory(configs)
+
+	// Audit inventory change
+	log.LogInfof("Audit: put bucket inventory: requestID(%v) remote(%v) volume(%v) id(%v) inventory(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), config.Id, string(bytes))
+	return
+}
+
+// Delete bucket inventory configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketInventoryConfiguration.html
+func (o *ObjectNode) deleteBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var configs *InventoryConfigurations
+	if configs, err = vol.loadBucketInventory(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	var id = param.GetVar(ParamInventoryId)
+	if configs == nil || !configs.delete(id) {
+		_ = NoSuchInventoryConfiguration.ServeResponse(w, r)
+		return
+	}
+	if err = storeBucketInventory(configs, vol); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if len(configs.Configurations) == 0 {
+		configs = nil
+	}
+	vol.metaLoader.storeInventory(configs)
+
+	// Audit inventory deletion
+	log.LogInfof("Audit: delete bucket inventory: requestID(%v) remote(%v) volume(%v) id(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), id)
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
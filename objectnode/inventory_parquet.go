@@ -0,0 +1,270 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// A lite writer of Parquet files for the inventory reports, which writes the records of a flat
+// schema of optional columns into a row group, in a data page per column in PLAIN encoding
+// without compression. The files are read by the reader for S3 Select as well.
+// File format reference: https://github.com/apache/parquet-format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+const (
+	parquetConvertedUTF8 = 0
+	parquetConvertedNone = -1
+
+	parquetCreatedBy = "cfs objectnode"
+)
+
+// thriftWriter encodes the structs in thrift compact protocol.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // the last field ids of the nested structs
+}
+
+func (t *thriftWriter) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	t.buf.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.uvarint(uint64(v<<1 ^ v>>63))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	var last = t.last[len(t.last)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.last[len(t.last)-1] = id
+}
+
+func (t *thriftWriter) beginStruct() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) i32(id int16, v int64) {
+	t.fieldHeader(id, 5)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, 6)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.fieldHeader(id, 8)
+	t.binaryValue(v)
+}
+
+func (t *thriftWriter) binaryValue(v string) {
+	t.uvarint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) list(id int16, elemType byte, size int) {
+	t.fieldHeader(id, 9)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	t.uvarint(uint64(size))
+}
+
+// structField begins a struct field, which is ended by endStruct.
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, 12)
+	t.beginStruct()
+}
+
+type parquetWriterColumn struct {
+	name          string
+	physicalType  int64
+	convertedType int64
+	defined       []bool
+	values        bytes.Buffer
+	bools         []bool
+}
+
+type parquetWriter struct {
+	columns []*parquetWriterColumn
+	rows    int
+}
+
+func newParquetWriter() *parquetWriter {
+	return &parquetWriter{}
+}
+
+// addColumn adds an optional column, the converted type is parquetConvertedNone if the column has no logical type.
+func (p *parquetWriter) addColumn(name string, physicalType, convertedType int64) {
+	p.columns = append(p.columns, &parquetWriterColumn{name: name, physicalType: physicalType, convertedType: convertedType})
+}
+
+// write appends a record, whose values are string, int64, bool, time.Time or nil for the undefined values.
+func (p *parquetWriter) write(record []interface{}) {
+	for i, column := range p.columns {
+		var value = record[i]
+		column.defined = append(column.defined, value != nil)
+		switch v := value.(type) {
+		case string:
+			var size [4]byte
+			binary.LittleEndian.PutUint32(size[:], uint32(len(v)))
+			column.values.Write(size[:])
+			column.values.WriteString(v)
+		case int64:
+			var data [8]byte
+			binary.LittleEndian.PutUint64(data[:], uint64(v))
+			column.values.Write(data[:])
+		case time.Time:
+			var data [8]byte
+			binary.LittleEndian.PutUint64(data[:], uint64(v.UnixNano()/int64(time.Millisecond)))
+			column.values.Write(data[:])
+		case bool:
+			column.bools = append(column.bools, v)
+		}
+	}
+	p.rows++
+}
+
+// encodeBitPacked encodes the bits in the bit packed groups of the hybrid of run length encoding
+// and bit packing, with the bit width 1.
+func encodeBitPacked(bits []bool) []byte {
+	var groups = (len(bits) + 7) / 8
+	var data = make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+groups)
+	data = append(data[:binary.PutUvarint(data, uint64(groups)<<1|1)], make([]byte, groups)...)
+	var offset = len(data) - groups
+	for i, bit := range bits {
+		if bit {
+			data[offset+i/8] |= 1 << uint(i%8)
+		}
+	}
+	return data
+}
+
+// pageData returns the data of the data page of the column, including the definition levels.
+func (c *parquetWriterColumn) pageData() []byte {
+	var levels = encodeBitPacked(c.defined)
+	var data bytes.Buffer
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(levels)))
+	data.Write(size[:])
+	data.Write(levels)
+	if c.physicalType == parquetBoolean {
+		// the booleans are bit packed without the header in PLAIN encoding
+		data.Write(encodeBitPacked(c.bools)[binary.PutUvarint(make([]byte, binary.MaxVarintLen64), uint64((len(c.bools)+7)/8)<<1|1):])
+	} else {
+		data.Write(c.values.Bytes())
+	}
+	return data.Bytes()
+}
+
+// bytes returns the Parquet file of the records written.
+func (p *parquetWriter) bytes() []byte {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type chunkInfo struct {
+		offset int64
+		size   int64
+	}
+	var chunks = make([]chunkInfo, len(p.columns))
+	for i, column := range p.columns {
+		var data = column.pageData()
+		var header = &thriftWriter{}
+		header.beginStruct()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int64(len(data)))
+		header.i32(3, int64(len(data)))
+		header.structField(5)
+		header.i32(1, int64(p.rows))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.endStruct()
+		header.endStruct()
+		chunks[i] = chunkInfo{offset: int64(file.Len()), size: int64(header.buf.Len() + len(data))}
+		file.Write(header.buf.Bytes())
+		file.Write(data)
+	}
+
+	var footer = &thriftWriter{}
+	footer.beginStruct()
+	footer.i32(1, 1)
+	footer.list(2, 12, len(p.columns)+1)
+	footer.beginStruct()
+	footer.binary(4, "schema")
+	footer.i32(5, int64(len(p.columns)))
+	footer.endStruct()
+	for _, column := range p.columns {
+		footer.beginStruct()
+		footer.i32(1, column.physicalType)
+		footer.i32(3, parquetOptional)
+		footer.binary(4, column.name)
+		if column.convertedType != parquetConvertedNone {
+			footer.i32(6, column.convertedType)
+		}
+		footer.endStruct()
+	}
+	footer.i64(3, int64(p.rows))
+	footer.list(4, 12, 1)
+	footer.beginStruct()
+	footer.list(1, 12, len(p.columns))
+	var totalSize int64
+	for i, column := range p.columns {
+		footer.beginStruct()
+		footer.i64(2, chunks[i].offset)
+		footer.structField(3)
+		footer.i32(1, column.physicalType)
+		footer.list(2, 5, 2)
+		footer.zigzag(parquetEncodingPlain)
+		footer.zigzag(parquetEncodingRLE)
+		footer.list(3, 8, 1)
+		footer.binaryValue(column.name)
+		footer.i32(4, parquetCodecUncompressed)
+		footer.i64(5, int64(p.rows))
+		footer.i64(6, chunks[i].size)
+		footer.i64(7, chunks[i].size)
+		footer.i64(9, chunks[i].offset)
+		footer.endStruct()
+		footer.endStruct()
+		totalSize += chunks[i].size
+	}
+	footer.i64(2, totalSize)
+	footer.i64(3, int64(p.rows))
+	footer.endStruct()
+	footer.binary(6, parquetCreatedBy)
+	footer.endStruct()
+
+	file.Write(footer.buf.Bytes())
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(footer.buf.Len()))
+	file.Write(size[:])
+	file.WriteString(parquetMagic)
+	return file.Bytes()
+}
@@ -0,0 +1,184 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInventoryConfigurationValidate(t *testing.T) {
+	var config = func(id, bucket, format, versions, frequency, fields string) string {
+		return "<InventoryConfiguration><Id>" + id + "</Id><IsEnabled>true</IsEnabled>" +
+			"<Destination><S3BucketDestination><Bucket>" + bucket + "</Bucket><Format>" + format + "</Format></S3BucketDestination></Destination>" +
+			"<IncludedObjectVersions>" + versions + "</IncludedObjectVersions>" +
+			"<OptionalFields>" + fields + "</OptionalFields>" +
+			"<Schedule><Frequency>" + frequency + "</Frequency></Schedule></InventoryConfiguration>"
+	}
+	var cases = []struct {
+		config string
+		valid  bool
+	}{
+		{config("report", "arn:aws:s3:::audit", "CSV", "Current", "Daily", "<Field>Size</Field><Field>ETag</Field>"), true},
+		{config("report-1.a_b", "arn:aws:s3:::audit", "Parquet", "All", "Weekly", ""), true},
+		{config("", "arn:aws:s3:::audit", "CSV", "Current", "Daily", ""), false},
+		{config("a/b", "arn:aws:s3:::audit", "CSV", "Current", "Daily", ""), false},
+		{config("report", "audit", "CSV", "Current", "Daily", ""), false},
+		{config("report", "arn:cfs:s3::dr:audit", "CSV", "Current", "Daily", ""), false},
+		{config("report", "arn:aws:s3:::audit", "ORC", "Current", "Daily", ""), false},
+		{config("report", "arn:aws:s3:::audit", "CSV", "Noncurrent", "Daily", ""), false},
+		{config("report", "arn:aws:s3:::audit", "CSV", "Current", "Monthly", ""), false},
+		{config("report", "arn:aws:s3:::audit", "CSV", "Current", "Daily", "<Field>Owner</Field>"), false},
+	}
+	for i, c := range cases {
+		var inventory = &InventoryConfiguration{}
+		if err := xml.Unmarshal([]byte(c.config), inventory); err != nil {
+			t.Fatalf("case %v: unmarshal inventory fail: err(%v)", i, err)
+		}
+		if err := inventory.validate(); (err == nil) != c.valid {
+			t.Fatalf("case %v: validate result mismatch: expect valid(%v) actual err(%v)", i, c.valid, err)
+		}
+	}
+}
+
+func TestInventoryFileSchema(t *testing.T) {
+	var config = &InventoryConfiguration{
+		IncludedObjectVersions: InventoryVersionsAll,
+		OptionalFields:         &InventoryOptionalFields{Fields: []string{InventoryFieldStorageClass, InventoryFieldSize}},
+	}
+	var expected = []string{"Bucket", "Key", "VersionId", "IsLatest", "IsDeleteMarker", "Size", "StorageClass"}
+	if schema := config.fileSchema(); !reflect.DeepEqual(schema, expected) {
+		t.Fatalf("schema mismatch: expect(%v) actual(%v)", expected, schema)
+	}
+}
+
+func TestInventoryConfigurationsList(t *testing.T) {
+	var configs = &InventoryConfigurations{}
+	for i := MaxInventoryListSize + 10; i > 0; i-- {
+		configs.put(&InventoryConfiguration{Id: fmt.Sprintf("%03d", i)})
+	}
+	configs.put(&InventoryConfiguration{Id: "001", IsEnabled: true})
+	if len(configs.Configurations) != MaxInventoryListSize+10 || !configs.get("001").IsEnabled {
+		t.Fatalf("put configuration mismatch: configurations(%v)", len(configs.Configurations))
+	}
+
+	var result = configs.list("")
+	if !result.IsTruncated || len(result.Configurations) != MaxInventoryListSize || result.Configurations[0].Id != "001" {
+		t.Fatalf("first page mismatch: truncated(%v) configurations(%v)", result.IsTruncated, len(result.Configurations))
+	}
+	result = configs.list(result.NextContinuationToken)
+	if result.IsTruncated || len(result.Configurations) != 10 || result.Configurations[0].Id != "101" {
+		t.Fatalf("second page mismatch: truncated(%v) configurations(%v)", result.IsTruncated, len(result.Configurations))
+	}
+
+	if !configs.delete("001") || configs.delete("001") || configs.get("001") != nil {
+		t.Fatalf("delete configuration mismatch")
+	}
+}
+
+func TestInventoryBasePath(t *testing.T) {
+	var config = &InventoryConfiguration{
+		Id:          "report",
+		Destination: &InventoryDestination{S3BucketDestination: &InventoryS3BucketDestination{Bucket: "arn:aws:s3:::audit"}},
+	}
+	if path := inventoryBasePath(config, "photos"); path != "photos/report/" {
+		t.Fatalf("base path mismatch: actual(%v)", path)
+	}
+	config.Destination.S3BucketDestination.Prefix = "inventory"
+	if path := inventoryBasePath(config, "photos"); path != "inventory/photos/report/" {
+		t.Fatalf("base path mismatch: actual(%v)", path)
+	}
+}
+
+func TestInventoryCSVWriter(t *testing.T) {
+	var schema = []string{"Bucket", "Key", "VersionId", "IsLatest", "IsDeleteMarker", "Size", "LastModifiedDate", "ETag", "StorageClass", "EncryptionStatus"}
+	var modifyTime = time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	var writer = newInventoryCSVWriter()
+	writer.write(inventoryRecord("photos", schema, &FSFileInfo{Path: `a"b.jpg`, VersionId: "v1", Size: 10, ModifyTime: modifyTime,
+		ETag: "d41d8cd98f00b204e9800998ecf8427e", SSEAlgorithm: SSEAlgorithmKMS}, true))
+	writer.write(inventoryRecord("photos", schema, &FSFileInfo{Path: "c.jpg", VersionId: "v2", DeleteMarker: true}, false))
+	var data, ext, err = writer.bytes()
+	if err != nil || ext != ".csv.gz" {
+		t.Fatalf("write csv fail: ext(%v) err(%v)", ext, err)
+	}
+	var reader *gzip.Reader
+	if reader, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+		t.Fatalf("open gzip fail: err(%v)", err)
+	}
+	var csv []byte
+	if csv, err = ioutil.ReadAll(reader); err != nil {
+		t.Fatalf("read gzip fail: err(%v)", err)
+	}
+	var expected = `"photos","a""b.jpg","v1","true","false","10","2020-03-04T05:06:07.000Z","d41d8cd98f00b204e9800998ecf8427e","Standard","SSE-KMS"` + "\n" +
+		`"photos","c.jpg","v2","false","true","","","","",""` + "\n"
+	if string(csv) != expected {
+		t.Fatalf("csv mismatch: expect(%q) actual(%q)", expected, csv)
+	}
+}
+
+func TestInventoryParquetWriter(t *testing.T) {
+	var schema = []string{"Bucket", "Key", "IsLatest", "Size", "LastModifiedDate", "StorageClass"}
+	var modifyTime = time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	var writer = newInventoryParquetWriter(schema)
+	writer.write(inventoryRecord("photos", schema, &FSFileInfo{Path: "a.jpg", Size: 10, ModifyTime: modifyTime, StorageClass: StorageClassCold}, true))
+	writer.write(inventoryRecord("photos", schema, &FSFileInfo{Path: "b.jpg", DeleteMarker: true}, false))
+	var data, ext, err = writer.bytes()
+	if err != nil || ext != ".parquet" {
+		t.Fatalf("write parquet fail: ext(%v) err(%v)", ext, err)
+	}
+
+	reader, err := newParquetRecordReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open parquet fail: err(%v)", err)
+	}
+	var expected = [][]interface{}{
+		{"photos", "a.jpg", true, int64(10), "2020-03-04T05:06:07Z", "Cold"},
+		{"photos", "b.jpg", false, nil, nil, nil},
+	}
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			if i != len(expected) {
+				t.Fatalf("records mismatch: expect(%v) actual(%v)", len(expected), i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("read record fail: err(%v)", err)
+		}
+		var fields = record.fields()
+		var values = make([]interface{}, len(fields))
+		for j, field := range fields {
+			if field.name != schema[j] {
+				t.Fatalf("column mismatch: expect(%v) actual(%v)", schema[j], field.name)
+			}
+			values[j] = field.value
+		}
+		if !reflect.DeepEqual(values, expected[i]) {
+			t.Fatalf("record %v mismatch: expect(%v) actual(%v)", i, expected[i], values)
+		}
+	}
+	if !strings.HasPrefix(string(data), parquetMagic) {
+		t.Fatalf("parquet magic mismatch")
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/object-lifecycle-mgmt.html
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/util/errors"
+)
+
+type LifecycleConfiguration struct {
+	XMLName xml.Name         `xml:"LifecycleConfiguration"`
+	Rules   []*LifecycleRule `xml:"Rule"`
+}
+
+type LifecycleRule struct {
+	ID          string                 `xml:"ID,omitempty"`
+	Status      string                 `xml:"Status"`
+	Prefix      string                 `xml:"Prefix,omitempty"` // deprecated, the prefix of the filter is used instead
+	Filter      *LifecycleFilter       `xml:"Filter,omitempty"`
+	Expiration  *LifecycleExpiration   `xml:"Expiration,omitempty"`
+	Transitions []*LifecycleTransition `xml:"Transition,omitempty"`
+}
+
+type LifecycleFilter struct {
+	Prefix string              `xml:"Prefix,omitempty"`
+	Tag    *Tag                `xml:"Tag,omitempty"`
+	And    *LifecycleFilterAnd `xml:"And,omitempty"`
+}
+
+type LifecycleFilterAnd struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []*Tag `xml:"Tag,omitempty"`
+}
+
+type LifecycleExpiration struct {
+	Days int    `xml:"Days,omitempty"`
+	Date string `xml:"Date,omitempty"`
+}
+
+type LifecycleTransition struct {
+	Days         int    `xml:"Days,omitempty"`
+	Date         string `xml:"Date,omitempty"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+func (config *LifecycleConfiguration) validate() (err error) {
+	if len(config.Rules) == 0 || len(config.Rules) > MaxLifecycleRules {
+		return errors.New("invalid number of lifecycle rules")
+	}
+	var ids = make(map[string]struct{})
+	for _, rule := range config.Rules {
+		if err = rule.validate(); err != nil {
+			return
+		}
+		if rule.ID == "" {
+			continue
+		}
+		if _, exist := ids[rule.ID]; exist {
+			return errors.NewErrorf("duplicate lifecycle rule id: %v", rule.ID)
+		}
+		ids[rule.ID] = struct{}{}
+	}
+	return
+}
+
+func (rule *LifecycleRule) validate() (err error) {
+	if len(rule.ID) > 255 {
+		return errors.New("lifecycle rule id is too long")
+	}
+	if rule.Status != LifecycleStatusEnabled && rule.Status != LifecycleStatusDisabled {
+		return errors.NewErrorf("invalid lifecycle rule status: %v", rule.Status)
+	}
+	if rule.Filter != nil {
+		if rule.Prefix != "" {
+			return errors.New("prefix and filter cannot be both specified in a lifecycle rule")
+		}
+		if err = rule.Filter.validate(); err != nil {
+			return
+		}
+	}
+	if rule.Expiration == nil && len(rule.Transitions) == 0 {
+		return errors.New("no action in lifecycle rule")
+	}
+	if rule.Expiration != nil {
+		if err = validateLifecycleTime(rule.Expiration.Days, rule.Expiration.Date); err != nil {
+			return
+		}
+	}
+	if len(rule.Transitions) > 1 {
+		return errors.New("only one transition is supported in lifecycle rule")
+	}
+	for _, transition := range rule.Transitions {
+		if err = validateLifecycleTime(transition.Days, transition.Date); err != nil {
+			return
+		}
+		if !strings.EqualFold(transition.StorageClass, StorageClassCold) {
+			return errors.NewErrorf("invalid storage class of lifecycle transition: %v", transition.StorageClass)
+		}
+		transition.StorageClass = StorageClassCold
+		if rule.Expiration != nil && rule.Expiration.Days > 0 && transition.Days >= rule.Expiration.Days {
+			return errors.New("days of lifecycle transition must be less than the days of expiration")
+		}
+	}
+	return
+}
+
+// At most one of the prefix, the tag and the conjunction can be specified in the filter, and the
+// conjunction must combine at least two conditions.
+func (filter *LifecycleFilter) validate() (err error) {
+	var conditions = 0
+	if filter.Prefix != "" {
+		conditions++
+	}
+	if filter.Tag != nil {
+		conditions++
+	}
+	if filter.And != nil {
+		conditions++
+	}
+	if conditions > 1 {
+		return errors.New("only one of prefix, tag and and can be specified in lifecycle filter")
+	}
+	var tags []Tag
+	if filter.Tag != nil {
+		tags = append(tags, *filter.Tag)
+	}
+	if filter.And != nil {
+		if len(filter.And.Tags) == 0 || len(filter.And.Tags) == 1 && filter.And.Prefix == "" {
+			return errors.New("and of lifecycle filter must combine at least two conditions")
+		}
+		for _, tag := range filter.And.Tags {
+			tags = append(tags, *tag)
+		}
+	}
+	if ok, _ := (Tagging{TagSet: tags}).Validate(); !ok {
+		return errors.New("invalid tag in lifecycle filter")
+	}
+	return
+}
+
+// The days or the date of the action must be specified, and the date must be midnight in UTC.
+func validateLifecycleTime(days int, date string) (err error) {
+	if (days > 0) == (date != "") {
+		return errors.New("either days or date of lifecycle action must be specified")
+	}
+	if days < 0 {
+		return errors.New("days of lifecycle action must be positive")
+	}
+	if date != "" {
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339, date); err != nil {
+			return
+		}
+		if !t.Equal(t.UTC().Truncate(24 * time.Hour)) {
+			return errors.New("date of lifecycle action must be midnight in UTC")
+		}
+	}
+	return
+}
+
+func (rule *LifecycleRule) prefix() string {
+	if rule.Filter != nil {
+		if rule.Filter.And != nil {
+			return rule.Filter.And.Prefix
+		}
+		return rule.Filter.Prefix
+	}
+	return rule.Prefix
+}
+
+// tags returns the tags which the objects must have to be applied the rule.
+func (rule *LifecycleRule) tags() []*Tag {
+	switch {
+	case rule.Filter == nil:
+		return nil
+	case rule.Filter.Tag != nil:
+		return []*Tag{rule.Filter.Tag}
+	case rule.Filter.And != nil:
+		return rule.Filter.And.Tags
+	}
+	return nil
+}
+
+// matchTags returns true if the object of the tagging has all the tags of the rule.
+func (rule *LifecycleRule) matchTags(tagging *Tagging) bool {
+	for _, tag := range rule.tags() {
+		if value, exist := tagging.Get(tag.Key); !exist || value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// lifecycleDue returns true if the action of the days or the date is due for the object
+// modified at the modify time. As S3 does, the object is due at the midnight in UTC after
+// the days passed since it was modified.
+func lifecycleDue(days int, date string, modifyTime, now time.Time) bool {
+	if date != "" {
+		t, err := time.Parse(time.RFC3339, date)
+		return err == nil && !now.Before(t)
+	}
+	var due = modifyTime.UTC().Add(time.Duration(days) * 24 * time.Hour)
+	if truncated := due.Truncate(24 * time.Hour); !truncated.Equal(due) {
+		due = truncated.Add(24 * time.Hour)
+	}
+	return !now.Before(due)
+}
+
+// expired returns true if the object modified at the modify time is expired by the rule.
+func (rule *LifecycleRule) expired(modifyTime, now time.Time) bool {
+	return rule.Expiration != nil && lifecycleDue(rule.Expiration.Days, rule.Expiration.Date, modifyTime, now)
+}
+
+// transition returns the storage class which the object modified at the modify time is
+// transited to by the rule, or an empty string if no transition is due.
+func (rule *LifecycleRule) transition(modifyTime, now time.Time) string {
+	for _, transition := range rule.Transitions {
+		if lifecycleDue(transition.Days, transition.Date, modifyTime, now) {
+			return transition.StorageClass
+		}
+	}
+	return ""
+}
+
+func parseLifecycleConfig(bytes []byte) (config *LifecycleConfiguration, err error) {
+	config = &LifecycleConfiguration{}
+	if err = xml.Unmarshal(bytes, config); err != nil {
+		return
+	}
+	if err = config.validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func storeBucketLifecycle(bytes []byte, vol *Volume) (err error) {
+	if err = vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSLifecycle, bytes); err != nil {
+		return
+	}
+	return nil
+}
+
+func deleteBucketLifecycle(vol *Volume) (err error) {
+	if err = vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSLifecycle); err != nil {
+		return
+	}
+	return nil
+}
+
+// displayStorageClass returns the storage class shown to the clients, the objects without
+// storage class are of the standard storage class.
+func displayStorageClass(storageClass string) string {
+	if storageClass == "" {
+		return StorageClassStandard
+	}
+	return storageClass
+}
@@ -0,0 +1,229 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	// The data partitions of the objects transited to the cold storage class are converted to
+	// erasure coding once they have not been written for the seconds.
+	lifecycleColdPartitionSec = 24 * 3600
+
+	volStatusMarkDelete uint8 = 1
+)
+
+// The lifecycle executor scans the buckets with lifecycle configuration periodically, and
+// applies the enabled rules to the objects. The expired objects are deleted as the clients
+// delete them, so delete markers are placed for the versioned buckets. The objects are transited
+// to the cold storage class once all their data partitions have been erasure coded.
+type lifecycleExecutor struct {
+	vm       *VolumeManager
+	mc       *master.MasterClient
+	interval time.Duration
+	stopC    chan struct{}
+	stopOnce sync.Once
+}
+
+func newLifecycleExecutor(vm *VolumeManager, mc *master.MasterClient, interval time.Duration) *lifecycleExecutor {
+	return &lifecycleExecutor{
+		vm:       vm,
+		mc:       mc,
+		interval: interval,
+		stopC:    make(chan struct{}),
+	}
+}
+
+func (e *lifecycleExecutor) start() {
+	go e.run()
+}
+
+func (e *lifecycleExecutor) stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopC)
+	})
+}
+
+func (e *lifecycleExecutor) stopped() bool {
+	select {
+	case <-e.stopC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *lifecycleExecutor) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopC:
+			return
+		case <-ticker.C:
+			e.scan()
+		}
+	}
+}
+
+func (e *lifecycleExecutor) scan() {
+	vols, err := e.mc.AdminAPI().ListVols("")
+	if err != nil {
+		log.LogErrorf("lifecycle scan: list volumes fail: err(%v)", err)
+		return
+	}
+	for _, volInfo := range vols {
+		if e.stopped() {
+			return
+		}
+		if volInfo.Status == volStatusMarkDelete {
+			continue
+		}
+		var vol *Volume
+		if vol, err = e.vm.Volume(volInfo.Name); err != nil {
+			log.LogWarnf("lifecycle scan: load volume fail: volume(%v) err(%v)", volInfo.Name, err)
+			continue
+		}
+		var lifecycle *LifecycleConfiguration
+		if lifecycle, err = vol.metaLoader.loadLifecycle(); err != nil {
+			log.LogWarnf("lifecycle scan: load lifecycle fail: volume(%v) err(%v)", volInfo.Name, err)
+			continue
+		}
+		if lifecycle == nil {
+			continue
+		}
+		var now = time.Now()
+		for _, rule := range lifecycle.Rules {
+			if rule.Status != LifecycleStatusEnabled {
+				continue
+			}
+			if err = e.executeRule(vol, rule, now); err != nil {
+				log.LogWarnf("lifecycle scan: execute rule fail: volume(%v) rule(%v) err(%v)",
+					vol.Name(), rule.ID, err)
+			}
+		}
+	}
+}
+
+func (e *lifecycleExecutor) executeRule(vol *Volume, rule *LifecycleRule, now time.Time) (err error) {
+	var option = &ListFilesV1Option{
+		Prefix:  rule.prefix(),
+		MaxKeys: MaxKeys,
+	}
+	for !e.stopped() {
+		var result *ListFilesV1Result
+		if result, err = vol.ListFilesV1(option); err != nil {
+			return
+		}
+		for _, file := range result.Files {
+			if file.Mode.IsDir() {
+				continue
+			}
+			if len(rule.tags()) > 0 {
+				var tagging *Tagging
+				if tagging, err = vol.loadInodeTagging(file.Inode); err != nil {
+					log.LogWarnf("lifecycle: load object tagging fail: volume(%v) path(%v) rule(%v) err(%v)",
+						vol.Name(), file.Path, rule.ID, err)
+					continue
+				}
+				if !rule.matchTags(tagging) {
+					continue
+				}
+			}
+			if rule.expired(file.ModifyTime, now) {
+				if _, err = vol.DeleteObject(file.Path); err != nil {
+					log.LogWarnf("lifecycle: expire object fail: volume(%v) path(%v) rule(%v) err(%v)",
+						vol.Name(), file.Path, rule.ID, err)
+				}
+				continue
+			}
+			if storageClass := rule.transition(file.ModifyTime, now); storageClass != "" && file.StorageClass != storageClass {
+				if err = e.transitObject(vol, file, storageClass); err != nil {
+					log.LogWarnf("lifecycle: transit object fail: volume(%v) path(%v) rule(%v) err(%v)",
+						vol.Name(), file.Path, rule.ID, err)
+				}
+			}
+		}
+		if !result.Truncated {
+			break
+		}
+		option.Marker = result.NextMarker
+	}
+	return nil
+}
+
+// transitObject requests the conversion of the cold data partitions of the object to erasure
+// coding, and sets the storage class of the object once all of them have been converted.
+// The objects are transited on the following scans if some partitions are not converted yet.
+func (e *lifecycleExecutor) transitObject(vol *Volume, file *FSFileInfo, storageClass string) (err error) {
+	var extents []proto.ExtentKey
+	if _, _, extents, _, _, err = vol.mw.GetExtents(file.Inode); err != nil {
+		return
+	}
+	var partitions = make(map[uint64]struct{})
+	for _, ek := range extents {
+		partitions[ek.PartitionId] = struct{}{}
+	}
+
+	var converted = true
+	var now = time.Now().Unix()
+	for partitionID := range partitions {
+		var status *proto.DataPartitionECStatus
+		if status, err = e.mc.AdminAPI().GetDataPartitionECStatus(partitionID); err != nil {
+			return
+		}
+		if status.ECStatus == proto.ECStatusConverted {
+			continue
+		}
+		converted = false
+		if status.ECStatus != proto.ECStatusNone || !isLifecycleColdPartition(status, now) {
+			continue
+		}
+		if err = e.mc.AdminAPI().ConvertDataPartitionToEC(partitionID); err != nil {
+			log.LogWarnf("lifecycle: convert data partition to EC fail: volume(%v) partition(%v) err(%v)",
+				vol.Name(), partitionID, err)
+			continue
+		}
+		log.LogInfof("lifecycle: convert data partition to EC: volume(%v) partition(%v) path(%v)",
+			vol.Name(), partitionID, file.Path)
+	}
+	if !converted {
+		return nil
+	}
+	if err = vol.mw.XAttrSet_ll(file.Inode, []byte(XAttrKeyOSSStorageClass), []byte(storageClass)); err != nil {
+		return
+	}
+	log.LogInfof("Audit: lifecycle transit object: volume(%v) path(%v) inode(%v) storageClass(%v)",
+		vol.Name(), file.Path, file.Inode, storageClass)
+	return
+}
+
+func isLifecycleColdPartition(status *proto.DataPartitionECStatus, now int64) bool {
+	if len(status.Replicas) == 0 {
+		return false
+	}
+	for _, replica := range status.Replicas {
+		if replica.LastWriteTime <= 0 || now-replica.LastWriteTime < lifecycleColdPartitionSec {
+			return false
+		}
+	}
+	return true
+}
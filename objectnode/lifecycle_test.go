@@ -0,0 +1,134 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLifecycleConfig(t *testing.T) {
+	var valid = `<LifecycleConfiguration>
+	<Rule>
+		<ID>logs</ID>
+		<Status>Enabled</Status>
+		<Filter><Prefix>logs/</Prefix></Filter>
+		<Transition><Days>30</Days><StorageClass>COLD</StorageClass></Transition>
+		<Expiration><Days>365</Days></Expiration>
+	</Rule>
+</LifecycleConfiguration>`
+	config, err := parseLifecycleConfig([]byte(valid))
+	if err != nil {
+		t.Fatalf("parse lifecycle config fail: err(%v)", err)
+	}
+	if len(config.Rules) != 1 || config.Rules[0].prefix() != "logs/" {
+		t.Fatalf("unexpected lifecycle rules: %v", config.Rules)
+	}
+	if config.Rules[0].Transitions[0].StorageClass != StorageClassCold {
+		t.Fatalf("storage class not normalized: %v", config.Rules[0].Transitions[0].StorageClass)
+	}
+
+	var invalids = []string{
+		// no rule
+		`<LifecycleConfiguration></LifecycleConfiguration>`,
+		// no action
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Prefix>a</Prefix></Rule></LifecycleConfiguration>`,
+		// invalid status
+		`<LifecycleConfiguration><Rule><Status>On</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		// both days and date
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Expiration><Days>1</Days><Date>2020-01-01T00:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+		// date not at midnight
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Expiration><Date>2020-01-01T08:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+		// unsupported storage class
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Transition><Days>1</Days><StorageClass>GLACIER</StorageClass></Transition></Rule></LifecycleConfiguration>`,
+		// transition after expiration
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Transition><Days>10</Days><StorageClass>Cold</StorageClass></Transition><Expiration><Days>5</Days></Expiration></Rule></LifecycleConfiguration>`,
+		// both prefix and tag in filter
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Filter><Prefix>a</Prefix><Tag><Key>k</Key><Value>v</Value></Tag></Filter><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		// and of a single tag
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Filter><And><Tag><Key>k</Key><Value>v</Value></Tag></And></Filter><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		// duplicate tag keys
+		`<LifecycleConfiguration><Rule><Status>Enabled</Status><Filter><And><Tag><Key>k</Key><Value>v</Value></Tag><Tag><Key>k</Key><Value>w</Value></Tag></And></Filter><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		// duplicate id
+		`<LifecycleConfiguration><Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule><Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>2</Days></Expiration></Rule></LifecycleConfiguration>`,
+	}
+	for i, invalid := range invalids {
+		if _, err = parseLifecycleConfig([]byte(invalid)); err == nil {
+			t.Fatalf("invalid lifecycle config %v accepted", i)
+		}
+	}
+}
+
+func TestLifecycleRuleTags(t *testing.T) {
+	var valid = `<LifecycleConfiguration>
+	<Rule>
+		<Status>Enabled</Status>
+		<Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter>
+		<Expiration><Days>1</Days></Expiration>
+	</Rule>
+	<Rule>
+		<Status>Enabled</Status>
+		<Filter><And><Prefix>logs/</Prefix><Tag><Key>k</Key><Value>v</Value></Tag><Tag><Key>t</Key><Value></Value></Tag></And></Filter>
+		<Expiration><Days>1</Days></Expiration>
+	</Rule>
+</LifecycleConfiguration>`
+	config, err := parseLifecycleConfig([]byte(valid))
+	if err != nil {
+		t.Fatalf("parse lifecycle config fail: err(%v)", err)
+	}
+	var single, and = config.Rules[0], config.Rules[1]
+	if single.prefix() != "" || and.prefix() != "logs/" {
+		t.Fatalf("unexpected prefixes: %v %v", single.prefix(), and.prefix())
+	}
+
+	var tagging = &Tagging{TagSet: []Tag{{Key: "k", Value: "v"}}}
+	if !single.matchTags(tagging) {
+		t.Fatalf("object with the tag not matched")
+	}
+	if and.matchTags(tagging) {
+		t.Fatalf("object without all the tags matched")
+	}
+	tagging.TagSet = append(tagging.TagSet, Tag{Key: "t"})
+	if !and.matchTags(tagging) {
+		t.Fatalf("object with all the tags not matched")
+	}
+	if single.matchTags(&Tagging{TagSet: []Tag{{Key: "k", Value: "w"}}}) {
+		t.Fatalf("object with another tag value matched")
+	}
+}
+
+func TestLifecycleRuleDue(t *testing.T) {
+	var rule = &LifecycleRule{
+		Status:      LifecycleStatusEnabled,
+		Expiration:  &LifecycleExpiration{Days: 2},
+		Transitions: []*LifecycleTransition{{Date: "2020-01-02T00:00:00Z", StorageClass: StorageClassCold}},
+	}
+	modifyTime := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// The object expires at the midnight after two days passed.
+	if rule.expired(modifyTime, time.Date(2020, 1, 3, 23, 59, 59, 0, time.UTC)) {
+		t.Fatalf("object expired too early")
+	}
+	if !rule.expired(modifyTime, time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("object not expired")
+	}
+
+	if storageClass := rule.transition(modifyTime, time.Date(2020, 1, 1, 23, 0, 0, 0, time.UTC)); storageClass != "" {
+		t.Fatalf("object transited too early: %v", storageClass)
+	}
+	if storageClass := rule.transition(modifyTime, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)); storageClass != StorageClassCold {
+		t.Fatalf("object not transited: %v", storageClass)
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/ServerLogs.html
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+
+	"github.com/google/uuid"
+)
+
+// The buckets with the server access logging enabled log their requests into the log objects of
+// the target buckets, a line per request in the format of the server access logs of the Amazon S3.
+// The records are buffered by the ObjectNode serving the requests, and are written to a new log
+// object "<TargetPrefix>YYYY-mm-DD-HH-MM-SS-<UniqueString>" periodically or when the buffer is
+// full, so every ObjectNode writes its own log objects. The records are delivered on a best-effort
+// basis, and the buffered records are lost if the ObjectNode crashes.
+
+const (
+	MaxLoggingTargetPrefix = 1024
+
+	accessLogMaxBufferSize = 1 << 20
+	accessLogQueueSize     = 1024
+	accessLogTimeFormat    = "02/Jan/2006:15:04:05 -0700"
+	accessLogKeyTimeFormat = "2006-01-02-15-04-05"
+
+	defaultAccessLogFlushInterval = 5 * time.Minute
+)
+
+type BucketLoggingStatus struct {
+	XMLName        xml.Name        `xml:"BucketLoggingStatus"`
+	LoggingEnabled *LoggingEnabled `xml:"LoggingEnabled,omitempty"`
+}
+
+type LoggingEnabled struct {
+	TargetBucket string `xml:"TargetBucket"`
+	TargetPrefix string `xml:"TargetPrefix"`
+}
+
+func (status *BucketLoggingStatus) enabled() bool {
+	return status != nil && status.LoggingEnabled != nil
+}
+
+func (status *BucketLoggingStatus) validate() error {
+	if status.LoggingEnabled == nil {
+		return nil
+	}
+	if status.LoggingEnabled.TargetBucket == "" {
+		return errors.New("the target bucket must be specified")
+	}
+	if len(status.LoggingEnabled.TargetPrefix) > MaxLoggingTargetPrefix {
+		return errors.New("the target prefix is too long")
+	}
+	return nil
+}
+
+func storeBucketLogging(bytes []byte, vol *Volume) (err error) {
+	if err = vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSLogging, bytes); err != nil {
+		return
+	}
+	return nil
+}
+
+func deleteBucketLogging(vol *Volume) (err error) {
+	if err = vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSLogging); err != nil {
+		return
+	}
+	return nil
+}
+
+// accessLogWriter records the status and the size of the response for the access logs.
+type accessLogWriter struct {
+	http.ResponseWriter
+	statusCode int
+	bytesSent  int64
+	firstByte  time.Time
+}
+
+func (w *accessLogWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+		w.firstByte = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessLogWriter) Write(p []byte) (n int, err error) {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err = w.ResponseWriter.Write(p)
+	w.bytesSent += int64(n)
+	return
+}
+
+// Flush is required by the event stream of SelectObjectContent.
+func (w *accessLogWriter) Flush() {
+	if flusher, is := w.ResponseWriter.(http.Flusher); is {
+		flusher.Flush()
+	}
+}
+
+// accessLogRecord is a line of the access logs, in which the absent fields are "-".
+type accessLogRecord struct {
+	BucketOwner      string
+	Bucket           string
+	Time             time.Time
+	RemoteIP         string
+	Requester        string
+	RequestID        string
+	Operation        string
+	Key              string
+	RequestURI       string
+	HTTPStatus       int
+	ErrorCode        string
+	BytesSent        int64
+	ObjectSize       int64 // -1 if the size of the object is unknown
+	TotalTime        time.Duration
+	TurnAroundTime   time.Duration
+	Referer          string
+	UserAgent        string
+	VersionId        string
+	SignatureVersion string
+	AuthType         string
+	HostHeader       string
+}
+
+func (rec *accessLogRecord) String() string {
+	var field = func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	}
+	var quoted = func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return strconv.Quote(value)
+	}
+	var number = func(value int64) string {
+		if value <= 0 {
+			return "-"
+		}
+		return strconv.FormatInt(value, 10)
+	}
+	var key = rec.Key
+	if key != "" {
+		key = (&url.URL{Path: key}).EscapedPath()
+	}
+	var objectSize = "-"
+	if rec.ObjectSize >= 0 {
+		objectSize = strconv.FormatInt(rec.ObjectSize, 10)
+	}
+	return strings.Join([]string{
+		field(rec.BucketOwner),
+		field(rec.Bucket),
+		"[" + rec.Time.Format(accessLogTimeFormat) + "]",
+		field(rec.RemoteIP),
+		field(rec.Requester),
+		field(rec.RequestID),
+		field(rec.Operation),
+		field(key),
+		quoted(rec.RequestURI),
+		strconv.Itoa(rec.HTTPStatus),
+		field(rec.ErrorCode),
+		number(rec.BytesSent),
+		objectSize,
+		strconv.FormatInt(int64(rec.TotalTime/time.Millisecond), 10),
+		strconv.FormatInt(int64(rec.TurnAroundTime/time.Millisecond), 10),
+		quoted(rec.Referer),
+		quoted(rec.UserAgent),
+		field(rec.VersionId),
+		"-", // host id
+		field(rec.SignatureVersion),
+		"-", // cipher suite
+		field(rec.AuthType),
+		field(rec.HostHeader),
+		"-", // TLS version
+	}, " ")
+}
+
+// accessLogOperation returns the operation of the request in the form of "REST.<method>.<resource>",
+// in which the resource is the sub-resource of the request, or "OBJECT" or "BUCKET".
+func accessLogOperation(r *http.Request, object string) string {
+	var resource = "BUCKET"
+	if object != "" {
+		resource = "OBJECT"
+	}
+	var query = r.URL.Query()
+	for _, subresource := range []string{
+		"acl", "cors", "delete", "lifecycle", "location", "logging", "notification", "object-lock",
+		"policy", "tagging", "versioning", "versions", "uploads", "uploadId", "legal-hold", "retention", "select",
+	} {
+		if _, exist := query[subresource]; exist {
+			resource = strings.ToUpper(strings.ReplaceAll(subresource, "-", "_"))
+			if subresource == "uploadId" {
+				resource = "UPLOAD"
+			}
+			break
+		}
+	}
+	return "REST." + r.Method + "." + resource
+}
+
+// newAccessLogRecord returns the access log record of the request responded by the writer.
+func newAccessLogRecord(w *accessLogWriter, r *http.Request, vol *Volume, startTime time.Time) *accessLogRecord {
+	var param = ParseRequestParam(r)
+	var now = time.Now()
+	var rec = &accessLogRecord{
+		BucketOwner:    vol.Owner(),
+		Bucket:         vol.Name(),
+		Time:           startTime,
+		RemoteIP:       getRequestIP(r),
+		Requester:      param.AccessKey(),
+		RequestID:      GetRequestID(r),
+		Operation:      accessLogOperation(r, param.Object()),
+		Key:            param.Object(),
+		RequestURI:     r.Method + " " + r.RequestURI + " " + r.Proto,
+		HTTPStatus:     w.statusCode,
+		ErrorCode:      getResponseErrorCode(r),
+		BytesSent:      w.bytesSent,
+		ObjectSize:     -1,
+		TotalTime:      now.Sub(startTime),
+		TurnAroundTime: now.Sub(startTime),
+		Referer:        r.Referer(),
+		UserAgent:      r.UserAgent(),
+		VersionId:      r.URL.Query().Get(ParamVersionId),
+		HostHeader:     r.Host,
+	}
+	if rec.HTTPStatus == 0 {
+		rec.HTTPStatus = http.StatusOK
+	}
+	if !w.firstByte.IsZero() {
+		rec.TurnAroundTime = w.firstByte.Sub(startTime)
+	}
+	if param.Object() != "" {
+		switch r.Method {
+		case http.MethodPut, http.MethodPost:
+			rec.ObjectSize = r.ContentLength
+		case http.MethodGet, http.MethodHead:
+			if size, err := strconv.ParseInt(w.Header().Get(HeaderNameContentLength), 10, 64); err == nil {
+				rec.ObjectSize = size
+			}
+		}
+	}
+	switch {
+	case isHeaderUsingSignatureAlgorithmV4(r):
+		rec.SignatureVersion, rec.AuthType = "SigV4", "AuthHeader"
+	case isUrlUsingSignatureAlgorithmV4(r):
+		rec.SignatureVersion, rec.AuthType = "SigV4", "QueryString"
+	case isHeaderUsingSignatureAlgorithmV2(r):
+		rec.SignatureVersion, rec.AuthType = "SigV2", "AuthHeader"
+	case isUrlUsingSignatureAlgorithmV2(r):
+		rec.SignatureVersion, rec.AuthType = "SigV2", "QueryString"
+	}
+	return rec
+}
+
+// logAccess records the request to the access logs of the bucket if the logging of it is enabled.
+func (o *ObjectNode) logAccess(w *accessLogWriter, r *http.Request, startTime time.Time) {
+	if o.accessLogger == nil {
+		return
+	}
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" || getResponseErrorCode(r) == NoSuchBucket.ErrorCode {
+		return
+	}
+	var vol, err = o.vm.Volume(param.Bucket())
+	if err != nil {
+		return
+	}
+	var logging *BucketLoggingStatus
+	if logging, err = vol.metaLoader.loadLogging(); err != nil || !logging.enabled() {
+		return
+	}
+	var rec = newAccessLogRecord(w, r, vol, startTime)
+	o.accessLogger.record(vol.Name(), *logging.LoggingEnabled, rec.String())
+}
+
+type accessLogBuffer struct {
+	target LoggingEnabled
+	data   bytes.Buffer
+}
+
+type accessLogObject struct {
+	bucket string
+	key    string
+	data   []byte
+}
+
+// accessLogger buffers the access log records of the buckets and writes them to the target buckets.
+type accessLogger struct {
+	interval time.Duration
+	put      func(bucket, key string, data []byte) error
+	buffers  map[string]*accessLogBuffer // source bucket -> buffer
+	mu       sync.Mutex
+	objects  chan *accessLogObject
+	stopC    chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newAccessLogger(interval time.Duration, put func(bucket, key string, data []byte) error) *accessLogger {
+	if interval <= 0 {
+		interval = defaultAccessLogFlushInterval
+	}
+	return &accessLogger{
+		interval: interval,
+		put:      put,
+		buffers:  make(map[string]*accessLogBuffer),
+		objects:  make(chan *accessLogObject, accessLogQueueSize),
+		stopC:    make(chan struct{}),
+	}
+}
+
+func (l *accessLogger) start() {
+	l.wg.Add(1)
+	go l.run()
+}
+
+// stop flushes the buffered records and waits for them to be written.
+func (l *accessLogger) stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopC)
+		l.wg.Wait()
+	})
+}
+
+func (l *accessLogger) record(bucket string, target LoggingEnabled, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var buffer, exist = l.buffers[bucket]
+	if exist && buffer.target != target {
+		// the records logged before the target is changed are written to the previous target
+		l.rotate(bucket, buffer)
+		exist = false
+	}
+	if !exist {
+		buffer = &accessLogBuffer{target: target}
+		l.buffers[bucket] = buffer
+	}
+	buffer.data.WriteString(line)
+	buffer.data.WriteByte('\n')
+	if buffer.data.Len() >= accessLogMaxBufferSize {
+		l.rotate(bucket, buffer)
+	}
+}
+
+// rotate takes the records out of the buffer as a log object to be written, and it must be called
+// with the lock held.
+func (l *accessLogger) rotate(bucket string, buffer *accessLogBuffer) {
+	delete(l.buffers, bucket)
+	var unique = strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", ""))[:16]
+	var object = &accessLogObject{
+		bucket: buffer.target.TargetBucket,
+		key:    buffer.target.TargetPrefix + time.Now().UTC().Format(accessLogKeyTimeFormat) + "-" + unique,
+		data:   buffer.data.Bytes(),
+	}
+	select {
+	case l.objects <- object:
+	default:
+		log.LogWarnf("accessLogger: queue is full and log object is dropped: source(%v) target(%v) key(%v)",
+			bucket, object.bucket, object.key)
+	}
+}
+
+func (l *accessLogger) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for bucket, buffer := range l.buffers {
+		l.rotate(bucket, buffer)
+	}
+}
+
+func (l *accessLogger) run() {
+	defer l.wg.Done()
+	var ticker = time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stopC:
+			l.flush()
+			for {
+				select {
+				case object := <-l.objects:
+					l.write(object)
+				default:
+					return
+				}
+			}
+		case <-ticker.C:
+			l.flush()
+		case object := <-l.objects:
+			l.write(object)
+		}
+	}
+}
+
+func (l *accessLogger) write(object *accessLogObject) {
+	if err := l.put(object.bucket, object.key, object.data); err != nil {
+		log.LogErrorf("accessLogger: write log object fail: target(%v) key(%v) size(%v) err(%v)",
+			object.bucket, object.key, len(object.data), err)
+		return
+	}
+	log.LogDebugf("accessLogger: write log object: target(%v) key(%v) size(%v)",
+		object.bucket, object.key, len(object.data))
+}
+
+// putAccessLog writes the log object to the target bucket.
+func (o *ObjectNode) putAccessLog(bucket, key string, data []byte) (err error) {
+	var vol *Volume
+	if vol, err = o.vm.Volume(bucket); err != nil {
+		return
+	}
+	if _, err = vol.PutObject(key, bytes.NewReader(data), &PutFileOption{MIMEType: "text/plain"}); err != nil {
+		return fmt.Errorf("put log object: %v", err)
+	}
+	return
+}
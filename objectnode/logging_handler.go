@@ -0,0 +1,135 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get bucket logging
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLogging.html
+func (o *ObjectNode) getBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var logging *BucketLoggingStatus
+	if logging, err = vol.metaLoader.loadLogging(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	// an empty status is responded if the logging of the bucket is disabled
+	if logging == nil {
+		logging = &BucketLoggingStatus{}
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(logging); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// Put bucket logging
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLogging.html
+func (o *ObjectNode) putBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var bytes []byte
+	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	var logging = &BucketLoggingStatus{}
+	if err = xml.Unmarshal(bytes, logging); err != nil {
+		log.LogWarnf("putBucketLoggingHandler: parse logging fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if err = logging.validate(); err != nil {
+		log.LogWarnf("putBucketLoggingHandler: invalid logging: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = (&ErrorCode{ErrorCode: InvalidArgument.ErrorCode, ErrorMessage: err.Error(), StatusCode: InvalidArgument.StatusCode}).ServeResponse(w, r)
+		return
+	}
+
+	// the empty status disables the logging of the bucket
+	if !logging.enabled() {
+		if err = deleteBucketLogging(vol); err != nil {
+			_ = InternalErrorCode(err).ServeResponse(w, r)
+			return
+		}
+		vol.metaLoader.storeLogging(nil)
+		log.LogInfof("Audit: put bucket logging: requestID(%v) remote(%v) volume(%v) disabled",
+			GetRequestID(r), getRequestIP(r), vol.Name())
+		return
+	}
+
+	// the log objects are written to the target bucket owned by the owner of the bucket
+	var target *Volume
+	if target, err = o.vm.Volume(logging.LoggingEnabled.TargetBucket); err != nil || target.Owner() != vol.Owner() {
+		log.LogWarnf("putBucketLoggingHandler: invalid target bucket: requestID(%v) volume(%v) target(%v) err(%v)",
+			GetRequestID(r), vol.Name(), logging.LoggingEnabled.TargetBucket, err)
+		_ = InvalidTargetBucketForLogging.ServeResponse(w, r)
+		return
+	}
+
+	var newBytes []byte
+	if newBytes, err = xml.Marshal(logging); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if err = storeBucketLogging(newBytes, vol); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	vol.metaLoader.storeLogging(logging)
+
+	// Audit logging change
+	log.LogInfof("Audit: put bucket logging: requestID(%v) remote(%v) volume(%v) target(%v) prefix(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), logging.LoggingEnabled.TargetBucket, logging.LoggingEnabled.TargetPrefix)
+	return
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBucketLoggingStatusValidate(t *testing.T) {
+	var cases = []struct {
+		status  string
+		enabled bool
+		valid   bool
+	}{
+		{"<BucketLoggingStatus></BucketLoggingStatus>", false, true},
+		{"<BucketLoggingStatus><LoggingEnabled><TargetBucket>logs</TargetBucket><TargetPrefix>a/</TargetPrefix></LoggingEnabled></BucketLoggingStatus>", true, true},
+		{"<BucketLoggingStatus><LoggingEnabled><TargetPrefix>a/</TargetPrefix></LoggingEnabled></BucketLoggingStatus>", true, false},
+		{"<BucketLoggingStatus><LoggingEnabled><TargetBucket>logs</TargetBucket><TargetPrefix>" + strings.Repeat("a", MaxLoggingTargetPrefix+1) + "</TargetPrefix></LoggingEnabled></BucketLoggingStatus>", true, false},
+	}
+	for i, c := range cases {
+		var status = &BucketLoggingStatus{}
+		if err := xml.Unmarshal([]byte(c.status), status); err != nil {
+			t.Fatalf("case %v: parse logging status fail: err(%v)", i, err)
+		}
+		if status.enabled() != c.enabled {
+			t.Fatalf("case %v: enabled mismatch: expect(%v) actual(%v)", i, c.enabled, status.enabled())
+		}
+		if err := status.validate(); (err == nil) != c.valid {
+			t.Fatalf("case %v: validate result mismatch: expect valid(%v) actual err(%v)", i, c.valid, err)
+		}
+	}
+}
+
+func TestAccessLogOperation(t *testing.T) {
+	var cases = []struct {
+		method string
+		url    string
+		object string
+		expect string
+	}{
+		{http.MethodGet, "/bucket/key", "key", "REST.GET.OBJECT"},
+		{http.MethodPut, "/bucket/key?tagging", "key", "REST.PUT.TAGGING"},
+		{http.MethodGet, "/bucket", "", "REST.GET.BUCKET"},
+		{http.MethodGet, "/bucket?logging", "", "REST.GET.LOGGING"},
+		{http.MethodPut, "/bucket?object-lock", "", "REST.PUT.OBJECT_LOCK"},
+		{http.MethodPut, "/bucket/key?partNumber=1&uploadId=1", "key", "REST.PUT.UPLOAD"},
+		{http.MethodPost, "/bucket/key?uploads", "key", "REST.POST.UPLOADS"},
+	}
+	for i, c := range cases {
+		var r = httptest.NewRequest(c.method, c.url, nil)
+		if operation := accessLogOperation(r, c.object); operation != c.expect {
+			t.Fatalf("case %v: operation mismatch: expect(%v) actual(%v)", i, c.expect, operation)
+		}
+	}
+}
+
+func TestAccessLogRecordString(t *testing.T) {
+	var rec = &accessLogRecord{
+		BucketOwner:    "owner",
+		Bucket:         "bucket",
+		Time:           time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		RemoteIP:       "10.0.0.1",
+		Requester:      "AK",
+		RequestID:      "id",
+		Operation:      "REST.GET.OBJECT",
+		Key:            "a b/c",
+		RequestURI:     "GET /bucket/a%20b/c HTTP/1.1",
+		HTTPStatus:     http.StatusOK,
+		BytesSent:      10,
+		ObjectSize:     10,
+		TotalTime:      15 * time.Millisecond,
+		TurnAroundTime: 5 * time.Millisecond,
+		UserAgent:      "aws-cli",
+		AuthType:       "AuthHeader",
+	}
+	var expect = `owner bucket [02/Jan/2020:03:04:05 +0000] 10.0.0.1 AK id REST.GET.OBJECT a%20b/c ` +
+		`"GET /bucket/a%20b/c HTTP/1.1" 200 - 10 10 15 5 - "aws-cli" - - - - AuthHeader - -`
+	if line := rec.String(); line != expect {
+		t.Fatalf("record mismatch:\nexpect: %v\nactual: %v", expect, line)
+	}
+}
+
+func TestAccessLogWriter(t *testing.T) {
+	var recorder = httptest.NewRecorder()
+	var w = &accessLogWriter{ResponseWriter: recorder}
+	_, _ = w.Write([]byte("hello"))
+	_, _ = w.Write([]byte("world"))
+	w.Flush()
+	if w.statusCode != http.StatusOK || w.bytesSent != 10 || w.firstByte.IsZero() {
+		t.Fatalf("writer mismatch: status(%v) bytes(%v)", w.statusCode, w.bytesSent)
+	}
+	if !recorder.Flushed {
+		t.Fatalf("writer is not flushed")
+	}
+}
+
+func TestAccessLogger(t *testing.T) {
+	var mu sync.Mutex
+	var objects = make(map[string]string)
+	var logger = newAccessLogger(time.Hour, func(bucket, key string, data []byte) error {
+		mu.Lock()
+		objects[bucket+"/"+key] = string(data)
+		mu.Unlock()
+		return nil
+	})
+	logger.start()
+	var target = LoggingEnabled{TargetBucket: "logs", TargetPrefix: "a/"}
+	logger.record("a", target, "line1")
+	logger.record("a", target, "line2")
+	// the records before the target is changed are written to the previous target
+	logger.record("b", target, "line3")
+	logger.record("b", LoggingEnabled{TargetBucket: "logs", TargetPrefix: "b/"}, "line4")
+	logger.stop()
+
+	if len(objects) != 3 {
+		t.Fatalf("log objects mismatch: %v", objects)
+	}
+	var found = make(map[string]int)
+	for key, data := range objects {
+		switch {
+		case data == "line1\nline2\n" && strings.HasPrefix(key, "logs/a/"):
+			found["a"]++
+		case data == "line3\n" && strings.HasPrefix(key, "logs/a/"):
+			found["b-old"]++
+		case data == "line4\n" && strings.HasPrefix(key, "logs/b/"):
+			found["b"]++
+		}
+		if len(key) != len("logs/a/")+len(accessLogKeyTimeFormat)+17 {
+			t.Fatalf("invalid log object key: %v", key)
+		}
+	}
+	if found["a"] != 1 || found["b-old"] != 1 || found["b"] != 1 {
+		t.Fatalf("log objects mismatch: %v", objects)
+	}
+}
@@ -0,0 +1,549 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/NotificationHowTo.html
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/kafka"
+	"github.com/cubefs/cubefs/util/log"
+
+	"github.com/google/uuid"
+)
+
+// The buckets publish the events of the objects created and removed to the notification targets,
+// which are configured on the ObjectNodes by the "notificationTargets" configuration since they are
+// the endpoints of the HTTP webhooks or the topics of Kafka. The notification configuration of a
+// bucket refers to the targets by their ARNs "arn:cfs:sqs::<id>:<type>" in the queue configurations,
+// and only the targets configured on the ObjectNode can be referred.
+//
+// The events are published after the requests succeed, in the format of the event messages of the
+// Amazon S3. They are delivered asynchronously by a queue per target, and the events are dropped
+// if the queue is full or the delivery keeps failing, so the targets may miss some events.
+
+const (
+	NotificationTargetWebhook = "webhook"
+	NotificationTargetKafka   = "kafka"
+
+	NotificationARNPrefix = "arn:cfs:sqs::"
+
+	EventObjectCreatedAll                     = "s3:ObjectCreated:*"
+	EventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	EventObjectCreatedPost                    = "s3:ObjectCreated:Post"
+	EventObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedAll                     = "s3:ObjectRemoved:*"
+	EventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+
+	NotificationFilterPrefix = "prefix"
+	NotificationFilterSuffix = "suffix"
+
+	MaxNotificationConfigurations = 100
+	MaxNotificationFilterValue    = 1024
+
+	notificationQueueSize    = 10000
+	notificationMaxRetries   = 3
+	notificationTimeout      = 10 * time.Second
+	notificationEventSource  = "cfs:s3"
+	notificationEventVersion = "2.1"
+
+	kafkaClientID = "cfs-objectnode"
+)
+
+var notificationEvents = []string{
+	EventObjectCreatedAll,
+	EventObjectCreatedPut,
+	EventObjectCreatedPost,
+	EventObjectCreatedCopy,
+	EventObjectCreatedCompleteMultipartUpload,
+	EventObjectRemovedAll,
+	EventObjectRemovedDelete,
+	EventObjectRemovedDeleteMarkerCreated,
+}
+
+type NotificationConfiguration struct {
+	XMLName             xml.Name              `xml:"NotificationConfiguration"`
+	QueueConfigurations []*QueueConfiguration `xml:"QueueConfiguration,omitempty"`
+	// The notifications to the SNS topics and the Lambda functions are not supported.
+	TopicConfigurations          []struct{} `xml:"TopicConfiguration,omitempty"`
+	CloudFunctionConfigurations  []struct{} `xml:"CloudFunctionConfiguration,omitempty"`
+	LambdaFunctionConfigurations []struct{} `xml:"LambdaFunctionConfiguration,omitempty"`
+}
+
+type QueueConfiguration struct {
+	ID     string              `xml:"Id,omitempty"`
+	Queue  string              `xml:"Queue"`
+	Events []string            `xml:"Event"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+type NotificationFilter struct {
+	Key *NotificationKeyFilter `xml:"S3Key,omitempty"`
+}
+
+type NotificationKeyFilter struct {
+	FilterRules []*NotificationFilterRule `xml:"FilterRule"`
+}
+
+type NotificationFilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// validate checks the notification configuration, whose queues must be the targets of the notifier.
+func (config *NotificationConfiguration) validate(notifier *eventNotifier) (err error) {
+	if len(config.TopicConfigurations) > 0 || len(config.CloudFunctionConfigurations) > 0 ||
+		len(config.LambdaFunctionConfigurations) > 0 {
+		return errors.New("only the queue configurations are supported")
+	}
+	if len(config.QueueConfigurations) > MaxNotificationConfigurations {
+		return errors.New("too many queue configurations")
+	}
+	var ids = make(map[string]struct{})
+	for _, queue := range config.QueueConfigurations {
+		if err = queue.validate(notifier); err != nil {
+			return
+		}
+		if queue.ID == "" {
+			queue.ID = uuid.New().String()
+		}
+		if _, exist := ids[queue.ID]; exist {
+			return errors.NewErrorf("duplicate queue configuration id: %v", queue.ID)
+		}
+		ids[queue.ID] = struct{}{}
+	}
+	return
+}
+
+func (queue *QueueConfiguration) validate(notifier *eventNotifier) (err error) {
+	if len(queue.ID) > 255 {
+		return errors.New("queue configuration id is too long")
+	}
+	if notifier == nil || !notifier.hasTarget(queue.Queue) {
+		return errors.NewErrorf("the destination ARN does not exist or is not well-formed: %v", queue.Queue)
+	}
+	if len(queue.Events) == 0 {
+		return errors.New("no event in queue configuration")
+	}
+	for _, event := range queue.Events {
+		if !isValidNotificationEvent(event) {
+			return errors.NewErrorf("unsupported event: %v", event)
+		}
+	}
+	if queue.Filter != nil && queue.Filter.Key != nil {
+		var names = make(map[string]struct{})
+		for _, rule := range queue.Filter.Key.FilterRules {
+			rule.Name = strings.ToLower(rule.Name)
+			if rule.Name != NotificationFilterPrefix && rule.Name != NotificationFilterSuffix {
+				return errors.NewErrorf("invalid filter rule name: %v", rule.Name)
+			}
+			if _, exist := names[rule.Name]; exist {
+				return errors.NewErrorf("duplicate filter rule name: %v", rule.Name)
+			}
+			names[rule.Name] = struct{}{}
+			if len(rule.Value) > MaxNotificationFilterValue {
+				return errors.New("filter rule value is too long")
+			}
+		}
+	}
+	return
+}
+
+func isValidNotificationEvent(event string) bool {
+	for _, e := range notificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEvent returns true if the event name, such as "s3:ObjectCreated:Put", is one of the events
+// of the queue configuration, which may end with a wildcard.
+func (queue *QueueConfiguration) matchEvent(eventName string) bool {
+	for _, event := range queue.Events {
+		if event == eventName {
+			return true
+		}
+		if strings.HasSuffix(event, "*") && strings.HasPrefix(eventName, event[:len(event)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (queue *QueueConfiguration) matchKey(key string) bool {
+	if queue.Filter == nil || queue.Filter.Key == nil {
+		return true
+	}
+	for _, rule := range queue.Filter.Key.FilterRules {
+		switch rule.Name {
+		case NotificationFilterPrefix:
+			if !strings.HasPrefix(key, rule.Value) {
+				return false
+			}
+		case NotificationFilterSuffix:
+			if !strings.HasSuffix(key, rule.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func storeBucketNotification(bytes []byte, vol *Volume) (err error) {
+	if err = vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSNotification, bytes); err != nil {
+		return
+	}
+	return nil
+}
+
+func deleteBucketNotification(vol *Volume) (err error) {
+	if err = vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSNotification); err != nil {
+		return
+	}
+	return nil
+}
+
+// NotificationEvent is the event message delivered to the targets.
+type NotificationEvent struct {
+	Records []*NotificationRecord `json:"Records"`
+}
+
+type NotificationRecord struct {
+	EventVersion      string                   `json:"eventVersion"`
+	EventSource       string                   `json:"eventSource"`
+	AwsRegion         string                   `json:"awsRegion"`
+	EventTime         string                   `json:"eventTime"`
+	EventName         string                   `json:"eventName"`
+	UserIdentity      NotificationIdentity     `json:"userIdentity"`
+	RequestParameters map[string]string        `json:"requestParameters"`
+	ResponseElements  map[string]string        `json:"responseElements"`
+	S3                NotificationRecordEntity `json:"s3"`
+}
+
+type NotificationIdentity struct {
+	PrincipalId string `json:"principalId"`
+}
+
+type NotificationRecordEntity struct {
+	SchemaVersion   string             `json:"s3SchemaVersion"`
+	ConfigurationId string             `json:"configurationId"`
+	Bucket          NotificationBucket `json:"bucket"`
+	Object          NotificationObject `json:"object"`
+}
+
+type NotificationBucket struct {
+	Name          string               `json:"name"`
+	OwnerIdentity NotificationIdentity `json:"ownerIdentity"`
+	ARN           string               `json:"arn"`
+}
+
+type NotificationObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionId string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// newNotificationObject returns the object of the events of the created object.
+func newNotificationObject(key string, fileInfo *FSFileInfo) NotificationObject {
+	var object = NotificationObject{Key: key, Size: fileInfo.Size, ETag: fileInfo.ETag}
+	if len(fileInfo.VersionId) > 0 {
+		object.VersionId = displayVersionId(fileInfo.VersionId)
+	}
+	return object
+}
+
+// notifyObjectEvent publishes the event of the object to the queues of the bucket whose events and
+// filter match it. The key of the object is escaped in the event as the Amazon S3 does.
+func (o *ObjectNode) notifyObjectEvent(r *http.Request, vol *Volume, eventName string, object NotificationObject) {
+	if o.notifier == nil {
+		return
+	}
+	var config, err = vol.metaLoader.loadNotification()
+	if err != nil {
+		log.LogWarnf("notifyObjectEvent: load notification fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if config == nil {
+		return
+	}
+	var now = time.Now()
+	var key = object.Key
+	object.Key = url.QueryEscape(key)
+	object.Sequencer = fmt.Sprintf("%016X", now.UnixNano())
+	for _, queue := range config.QueueConfigurations {
+		if !queue.matchEvent(eventName) || !queue.matchKey(key) {
+			continue
+		}
+		var record = &NotificationRecord{
+			EventVersion:      notificationEventVersion,
+			EventSource:       notificationEventSource,
+			AwsRegion:         o.region,
+			EventTime:         now.UTC().Format("2006-01-02T15:04:05.000Z"),
+			EventName:         strings.TrimPrefix(eventName, "s3:"),
+			UserIdentity:      NotificationIdentity{PrincipalId: ParseRequestParam(r).AccessKey()},
+			RequestParameters: map[string]string{"sourceIPAddress": getRequestIP(r)},
+			ResponseElements:  map[string]string{"x-amz-request-id": GetRequestID(r)},
+			S3: NotificationRecordEntity{
+				SchemaVersion:   "1.0",
+				ConfigurationId: queue.ID,
+				Bucket: NotificationBucket{
+					Name:          vol.Name(),
+					OwnerIdentity: NotificationIdentity{PrincipalId: vol.Owner()},
+					ARN:           "arn:aws:s3:::" + vol.Name(),
+				},
+				Object: object,
+			},
+		}
+		o.notifier.send(queue.Queue, vol.Name()+"/"+key, &NotificationEvent{Records: []*NotificationRecord{record}})
+	}
+}
+
+// notificationTargetConfig is the configuration of a notification target of the ObjectNode.
+type notificationTargetConfig struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Endpoint  string   `json:"endpoint,omitempty"`  // webhook
+	AuthToken string   `json:"authToken,omitempty"` // webhook
+	Brokers   []string `json:"brokers,omitempty"`   // kafka
+	Topic     string   `json:"topic,omitempty"`     // kafka
+}
+
+// parseNotificationTargets parses the targets of the "notificationTargets" configuration.
+func parseNotificationTargets(items []interface{}) (configs []*notificationTargetConfig, err error) {
+	if len(items) == 0 {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(items); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid notification targets: %v", err)
+	}
+	return
+}
+
+func (c *notificationTargetConfig) arn() string {
+	return NotificationARNPrefix + c.ID + ":" + c.Type
+}
+
+// notificationTarget delivers the events to an endpoint.
+type notificationTarget interface {
+	deliver(key string, data []byte) error
+	close()
+}
+
+type notificationMessage struct {
+	key  string
+	data []byte
+}
+
+type notificationQueue struct {
+	arn      string
+	target   notificationTarget
+	messages chan *notificationMessage
+}
+
+type eventNotifier struct {
+	queues   map[string]*notificationQueue // ARN -> queue
+	stopC    chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// newEventNotifier returns the notifier of the targets, which is nil if no target is configured.
+func newEventNotifier(configs []*notificationTargetConfig) (n *eventNotifier, err error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	n = &eventNotifier{
+		queues: make(map[string]*notificationQueue),
+		stopC:  make(chan struct{}),
+	}
+	for _, config := range configs {
+		if config.ID == "" || strings.Contains(config.ID, ":") {
+			return nil, fmt.Errorf("invalid notification target id: %v", config.ID)
+		}
+		var target notificationTarget
+		switch config.Type {
+		case NotificationTargetWebhook:
+			if config.Endpoint == "" {
+				return nil, fmt.Errorf("no endpoint of notification target: %v", config.ID)
+			}
+			target = newWebhookTarget(config.Endpoint, config.AuthToken)
+		case NotificationTargetKafka:
+			if len(config.Brokers) == 0 || config.Topic == "" {
+				return nil, fmt.Errorf("no brokers or topic of notification target: %v", config.ID)
+			}
+			target = newKafkaTarget(config.Brokers, config.Topic)
+		default:
+			return nil, fmt.Errorf("invalid type of notification target %v: %v", config.ID, config.Type)
+		}
+		var arn = config.arn()
+		if _, exist := n.queues[arn]; exist {
+			return nil, fmt.Errorf("duplicate notification target: %v", arn)
+		}
+		n.queues[arn] = &notificationQueue{
+			arn:      arn,
+			target:   target,
+			messages: make(chan *notificationMessage, notificationQueueSize),
+		}
+	}
+	return
+}
+
+func (n *eventNotifier) start() {
+	for _, queue := range n.queues {
+		n.wg.Add(1)
+		go n.run(queue)
+	}
+}
+
+func (n *eventNotifier) stop() {
+	n.stopOnce.Do(func() {
+		close(n.stopC)
+		n.wg.Wait()
+		for _, queue := range n.queues {
+			queue.target.close()
+		}
+	})
+}
+
+func (n *eventNotifier) hasTarget(arn string) bool {
+	_, exist := n.queues[arn]
+	return exist
+}
+
+func (n *eventNotifier) send(arn, key string, event *NotificationEvent) {
+	var queue, exist = n.queues[arn]
+	if !exist {
+		log.LogWarnf("notifier: send event to unknown target: target(%v) key(%v)", arn, key)
+		return
+	}
+	var data, err = json.Marshal(event)
+	if err != nil {
+		log.LogErrorf("notifier: marshal event fail: target(%v) key(%v) err(%v)", arn, key, err)
+		return
+	}
+	select {
+	case queue.messages <- &notificationMessage{key: key, data: data}:
+	default:
+		log.LogWarnf("notifier: queue is full and event is dropped: target(%v) key(%v)", arn, key)
+	}
+}
+
+func (n *eventNotifier) run(queue *notificationQueue) {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.stopC:
+			return
+		case message := <-queue.messages:
+			n.deliver(queue, message)
+		}
+	}
+}
+
+func (n *eventNotifier) deliver(queue *notificationQueue, message *notificationMessage) {
+	var err error
+	for i := 0; i < notificationMaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-n.stopC:
+				return
+			case <-time.After(time.Duration(i) * time.Second):
+			}
+		}
+		if err = queue.target.deliver(message.key, message.data); err == nil {
+			return
+		}
+		log.LogWarnf("notifier: deliver event fail: target(%v) key(%v) retry(%v) err(%v)",
+			queue.arn, message.key, i, err)
+	}
+	log.LogErrorf("notifier: event is dropped: target(%v) key(%v) err(%v)", queue.arn, message.key, err)
+}
+
+// webhookTarget posts the events to the HTTP endpoint in JSON, which should respond 2xx.
+type webhookTarget struct {
+	endpoint  string
+	authToken string
+	client    *http.Client
+}
+
+func newWebhookTarget(endpoint, authToken string) *webhookTarget {
+	return &webhookTarget{
+		endpoint:  endpoint,
+		authToken: authToken,
+		client:    &http.Client{Timeout: notificationTimeout},
+	}
+}
+
+func (t *webhookTarget) deliver(key string, data []byte) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(data)); err != nil {
+		return
+	}
+	req.Header.Set(HeaderNameContentType, HeaderValueContentTypeJSON)
+	if t.authToken != "" {
+		req.Header.Set(HeaderNameAuthorization, "Bearer "+t.authToken)
+	}
+	var resp *http.Response
+	if resp, err = t.client.Do(req); err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %v", resp.StatusCode)
+	}
+	return
+}
+
+func (t *webhookTarget) close() {
+	t.client.CloseIdleConnections()
+}
+
+// kafkaTarget publishes the events to the topic of Kafka, the partition of an event is chosen by its key.
+type kafkaTarget struct {
+	producer *kafka.Producer
+}
+
+func newKafkaTarget(brokers []string, topic string) *kafkaTarget {
+	return &kafkaTarget{producer: kafka.NewProducer(brokers, topic, kafkaClientID, notificationTimeout)}
+}
+
+func (t *kafkaTarget) deliver(key string, data []byte) error {
+	return t.producer.Produce(key, data)
+}
+
+func (t *kafkaTarget) close() {
+	t.producer.Close()
+}
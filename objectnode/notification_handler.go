@@ -0,0 +1,123 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get bucket notification
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+func (o *ObjectNode) getBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var notification *NotificationConfiguration
+	if notification, err = vol.metaLoader.loadNotification(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	// an empty configuration is responded if the bucket has no notification
+	if notification == nil {
+		notification = &NotificationConfiguration{}
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(notification); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// Put bucket notification
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+func (o *ObjectNode) putBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var bytes []byte
+	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	var notification = &NotificationConfiguration{}
+	if err = xml.Unmarshal(bytes, notification); err != nil {
+		log.LogWarnf("putBucketNotificationHandler: parse notification fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if err = notification.validate(o.notifier); err != nil {
+		log.LogWarnf("putBucketNotificationHandler: invalid notification: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = (&ErrorCode{ErrorCode: InvalidArgument.ErrorCode, ErrorMessage: err.Error(), StatusCode: InvalidArgument.StatusCode}).ServeResponse(w, r)
+		return
+	}
+
+	// the empty configuration disables the notification of the bucket
+	if len(notification.QueueConfigurations) == 0 {
+		if err = deleteBucketNotification(vol); err != nil {
+			_ = InternalErrorCode(err).ServeResponse(w, r)
+			return
+		}
+		vol.metaLoader.storeNotification(nil)
+	} else {
+		var newBytes []byte
+		if newBytes, err = xml.Marshal(notification); err != nil {
+			_ = InternalErrorCode(err).ServeResponse(w, r)
+			return
+		}
+		if err = storeBucketNotification(newBytes, vol); err != nil {
+			_ = InternalErrorCode(err).ServeResponse(w, r)
+			return
+		}
+		vol.metaLoader.storeNotification(notification)
+	}
+
+	// Audit notification change
+	log.LogInfof("Audit: put bucket notification: requestID(%v) remote(%v) volume(%v) queues(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), len(notification.QueueConfigurations))
+	return
+}
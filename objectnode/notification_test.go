@@ -0,0 +1,204 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestNotifier(t *testing.T, configs ...*notificationTargetConfig) *eventNotifier {
+	notifier, err := newEventNotifier(configs)
+	if err != nil {
+		t.Fatalf("new notifier fail: err(%v)", err)
+	}
+	return notifier
+}
+
+func TestNotificationConfigValidate(t *testing.T) {
+	var notifier = newTestNotifier(t,
+		&notificationTargetConfig{ID: "1", Type: NotificationTargetWebhook, Endpoint: "http://127.0.0.1/events"})
+
+	var valid = `<NotificationConfiguration>
+	<QueueConfiguration>
+		<Queue>arn:cfs:sqs::1:webhook</Queue>
+		<Event>s3:ObjectCreated:*</Event>
+		<Event>s3:ObjectRemoved:Delete</Event>
+		<Filter><S3Key>
+			<FilterRule><Name>Prefix</Name><Value>images/</Value></FilterRule>
+			<FilterRule><Name>suffix</Name><Value>.jpg</Value></FilterRule>
+		</S3Key></Filter>
+	</QueueConfiguration>
+</NotificationConfiguration>`
+	var config = &NotificationConfiguration{}
+	if err := xml.Unmarshal([]byte(valid), config); err != nil {
+		t.Fatalf("unmarshal notification config fail: err(%v)", err)
+	}
+	if err := config.validate(notifier); err != nil {
+		t.Fatalf("validate notification config fail: err(%v)", err)
+	}
+	if config.QueueConfigurations[0].ID == "" {
+		t.Fatalf("id of queue configuration not generated")
+	}
+	if name := config.QueueConfigurations[0].Filter.Key.FilterRules[0].Name; name != NotificationFilterPrefix {
+		t.Fatalf("filter rule name not normalized: %v", name)
+	}
+	if err := config.validate(nil); err == nil {
+		t.Fatalf("validate notification config without targets should fail")
+	}
+
+	var invalids = []string{
+		// unknown target
+		`<NotificationConfiguration><QueueConfiguration><Queue>arn:cfs:sqs::2:webhook</Queue><Event>s3:ObjectCreated:*</Event></QueueConfiguration></NotificationConfiguration>`,
+		// no event
+		`<NotificationConfiguration><QueueConfiguration><Queue>arn:cfs:sqs::1:webhook</Queue></QueueConfiguration></NotificationConfiguration>`,
+		// unsupported event
+		`<NotificationConfiguration><QueueConfiguration><Queue>arn:cfs:sqs::1:webhook</Queue><Event>s3:ObjectRestore:*</Event></QueueConfiguration></NotificationConfiguration>`,
+		// invalid filter rule
+		`<NotificationConfiguration><QueueConfiguration><Queue>arn:cfs:sqs::1:webhook</Queue><Event>s3:ObjectCreated:Put</Event><Filter><S3Key><FilterRule><Name>infix</Name><Value>a</Value></FilterRule></S3Key></Filter></QueueConfiguration></NotificationConfiguration>`,
+		// duplicate filter rule
+		`<NotificationConfiguration><QueueConfiguration><Queue>arn:cfs:sqs::1:webhook</Queue><Event>s3:ObjectCreated:Put</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>a</Value></FilterRule><FilterRule><Name>prefix</Name><Value>b</Value></FilterRule></S3Key></Filter></QueueConfiguration></NotificationConfiguration>`,
+		// duplicate id
+		`<NotificationConfiguration><QueueConfiguration><Id>a</Id><Queue>arn:cfs:sqs::1:webhook</Queue><Event>s3:ObjectCreated:Put</Event></QueueConfiguration><QueueConfiguration><Id>a</Id><Queue>arn:cfs:sqs::1:webhook</Queue><Event>s3:ObjectRemoved:*</Event></QueueConfiguration></NotificationConfiguration>`,
+		// topic configuration
+		`<NotificationConfiguration><TopicConfiguration><Topic>arn:aws:sns:us-east-1:1:topic</Topic><Event>s3:ObjectCreated:*</Event></TopicConfiguration></NotificationConfiguration>`,
+	}
+	for _, invalid := range invalids {
+		config = &NotificationConfiguration{}
+		if err := xml.Unmarshal([]byte(invalid), config); err != nil {
+			t.Fatalf("unmarshal notification config fail: config(%v) err(%v)", invalid, err)
+		}
+		if err := config.validate(notifier); err == nil {
+			t.Fatalf("validate invalid notification config should fail: config(%v)", invalid)
+		}
+	}
+}
+
+func TestNotificationQueueMatch(t *testing.T) {
+	var queue = &QueueConfiguration{
+		Events: []string{EventObjectCreatedAll, EventObjectRemovedDeleteMarkerCreated},
+		Filter: &NotificationFilter{Key: &NotificationKeyFilter{FilterRules: []*NotificationFilterRule{
+			{Name: NotificationFilterPrefix, Value: "images/"},
+			{Name: NotificationFilterSuffix, Value: ".jpg"},
+		}}},
+	}
+	var events = map[string]bool{
+		EventObjectCreatedPut:                     true,
+		EventObjectCreatedCompleteMultipartUpload: true,
+		EventObjectRemovedDeleteMarkerCreated:     true,
+		EventObjectRemovedDelete:                  false,
+	}
+	for event, expect := range events {
+		if queue.matchEvent(event) != expect {
+			t.Fatalf("unexpected event match: event(%v) expect(%v)", event, expect)
+		}
+	}
+	var keys = map[string]bool{
+		"images/a.jpg":     true,
+		"images/sub/b.jpg": true,
+		"images/a.png":     false,
+		"docs/a.jpg":       false,
+	}
+	for key, expect := range keys {
+		if queue.matchKey(key) != expect {
+			t.Fatalf("unexpected key match: key(%v) expect(%v)", key, expect)
+		}
+	}
+}
+
+func TestParseNotificationTargets(t *testing.T) {
+	var raw = `[{"id": "1", "type": "webhook", "endpoint": "http://127.0.0.1/events"},
+		{"id": "2", "type": "kafka", "brokers": ["127.0.0.1:9092"], "topic": "objects"}]`
+	var items []interface{}
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		t.Fatalf("unmarshal targets fail: err(%v)", err)
+	}
+	configs, err := parseNotificationTargets(items)
+	if err != nil {
+		t.Fatalf("parse targets fail: err(%v)", err)
+	}
+	var notifier = newTestNotifier(t, configs...)
+	for _, arn := range []string{"arn:cfs:sqs::1:webhook", "arn:cfs:sqs::2:kafka"} {
+		if !notifier.hasTarget(arn) {
+			t.Fatalf("target not found: %v", arn)
+		}
+	}
+
+	var invalids = [][]*notificationTargetConfig{
+		{{ID: "1", Type: "sqs"}},
+		{{ID: "1", Type: NotificationTargetWebhook}},
+		{{ID: "1", Type: NotificationTargetKafka, Brokers: []string{"127.0.0.1:9092"}}},
+		{{ID: "a:b", Type: NotificationTargetWebhook, Endpoint: "http://127.0.0.1"}},
+		{{ID: "1", Type: NotificationTargetWebhook, Endpoint: "http://127.0.0.1"}, {ID: "1", Type: NotificationTargetWebhook, Endpoint: "http://127.0.0.2"}},
+	}
+	for _, invalid := range invalids {
+		if _, err = newEventNotifier(invalid); err == nil {
+			t.Fatalf("new notifier of invalid targets should fail: targets(%v)", invalid[0])
+		}
+	}
+}
+
+func TestWebhookNotification(t *testing.T) {
+	var received = make(chan *NotificationEvent, 1)
+	var failures = 1
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderNameAuthorization) != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// the failed delivery is retried
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event = &NotificationEvent{}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, event); err != nil {
+			t.Errorf("unmarshal event fail: err(%v)", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	var notifier = newTestNotifier(t,
+		&notificationTargetConfig{ID: "1", Type: NotificationTargetWebhook, Endpoint: server.URL, AuthToken: "token"})
+	notifier.start()
+	defer notifier.stop()
+
+	var record = &NotificationRecord{
+		EventVersion: notificationEventVersion,
+		EventName:    "ObjectCreated:Put",
+		S3: NotificationRecordEntity{
+			Bucket: NotificationBucket{Name: "bucket"},
+			Object: NotificationObject{Key: "a%2Fb", Size: 3, ETag: "etag"},
+		},
+	}
+	notifier.send("arn:cfs:sqs::1:webhook", "bucket/a/b", &NotificationEvent{Records: []*NotificationRecord{record}})
+	select {
+	case event := <-received:
+		if len(event.Records) != 1 || event.Records[0].EventName != "ObjectCreated:Put" ||
+			event.Records[0].S3.Object.Key != "a%2Fb" || event.Records[0].S3.Object.Size != 3 {
+			t.Fatalf("unexpected event: %v", event.Records[0])
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("event not delivered")
+	}
+}
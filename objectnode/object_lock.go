@@ -0,0 +1,389 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/object-lock.html
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The object lock of a version is kept in the extended attributes of its inode, and the
+// meta nodes refuse to remove or to modify the inode while it is locked. The permanent
+// deletion of a version under a governance retention is allowed by clearing the retention
+// first, if the request bypasses the governance retention.
+
+const (
+	ObjectLockEnabled        = "Enabled"
+	ObjectLockModeGovernance = proto.ObjectLockModeGovernance
+	ObjectLockModeCompliance = proto.ObjectLockModeCompliance
+	LegalHoldStatusOn        = proto.ObjectLegalHoldOn
+	LegalHoldStatusOff       = proto.ObjectLegalHoldOff
+
+	MaxObjectLockRetentionDays = 36500
+)
+
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled,omitempty"`
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+type ObjectLockRule struct {
+	DefaultRetention *DefaultRetention `xml:"DefaultRetention"`
+}
+
+// DefaultRetention is the retention placed on the new objects of the bucket without an
+// explicit retention, for either days or years.
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"`
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+type ObjectRetention struct {
+	XMLName         xml.Name `xml:"Retention"`
+	Mode            string   `xml:"Mode,omitempty"`
+	RetainUntilDate string   `xml:"RetainUntilDate,omitempty"`
+}
+
+type ObjectLegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+// ObjectLockOption is the object lock placed on an object when it is written.
+type ObjectLockOption struct {
+	Mode        string
+	RetainUntil time.Time
+	LegalHold   string
+}
+
+func isObjectLockMode(mode string) bool {
+	return mode == ObjectLockModeGovernance || mode == ObjectLockModeCompliance
+}
+
+func isLegalHoldStatus(status string) bool {
+	return status == LegalHoldStatusOn || status == LegalHoldStatusOff
+}
+
+func isObjectLockXAttrKey(key string) bool {
+	return key == XAttrKeyOSSLockMode || key == XAttrKeyOSSLockRetainUntil || key == XAttrKeyOSSLegalHold
+}
+
+func (config *ObjectLockConfiguration) enabled() bool {
+	return config != nil && config.ObjectLockEnabled == ObjectLockEnabled
+}
+
+func (config *ObjectLockConfiguration) validate() error {
+	if config.ObjectLockEnabled != ObjectLockEnabled {
+		return errors.New("ObjectLockEnabled must be Enabled")
+	}
+	if config.Rule == nil {
+		return nil
+	}
+	var retention = config.Rule.DefaultRetention
+	if retention == nil {
+		return errors.New("the rule must contain a default retention")
+	}
+	if !isObjectLockMode(retention.Mode) {
+		return errors.NewErrorf("unknown object lock mode %v", retention.Mode)
+	}
+	if retention.Days < 0 || retention.Years < 0 {
+		return errors.New("the default retention period must be positive")
+	}
+	if (retention.Days > 0) == (retention.Years > 0) {
+		return errors.New("either Days or Years must be specified in the default retention")
+	}
+	if retention.Days > MaxObjectLockRetentionDays || retention.Years > MaxObjectLockRetentionDays/365 {
+		return errors.New("the default retention period is too long")
+	}
+	return nil
+}
+
+// defaultLockOption returns the default retention of the objects written at the time.
+func (config *ObjectLockConfiguration) defaultLockOption(now time.Time) *ObjectLockOption {
+	if !config.enabled() || config.Rule == nil || config.Rule.DefaultRetention == nil {
+		return nil
+	}
+	var retention = config.Rule.DefaultRetention
+	var opt = &ObjectLockOption{Mode: retention.Mode}
+	if retention.Years > 0 {
+		opt.RetainUntil = now.AddDate(retention.Years, 0, 0)
+	} else {
+		opt.RetainUntil = now.AddDate(0, 0, retention.Days)
+	}
+	return opt
+}
+
+func parseObjectLockConfig(bytes []byte) (config *ObjectLockConfiguration, err error) {
+	config = &ObjectLockConfiguration{}
+	if err = xml.Unmarshal(bytes, config); err != nil {
+		return
+	}
+	return
+}
+
+func storeBucketObjectLock(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSObjectLock, bytes)
+}
+
+// storeObjectLockConfig persists the object lock configuration of the bucket and refreshes the cache.
+func storeObjectLockConfig(vol *Volume, config *ObjectLockConfiguration) (err error) {
+	var bytes []byte
+	if bytes, err = xml.Marshal(config); err != nil {
+		return
+	}
+	if err = storeBucketObjectLock(bytes, vol); err != nil {
+		return
+	}
+	vol.metaLoader.storeObjectLock(config)
+	return
+}
+
+// enableBucketObjectLock enables the versioning and the object lock of a new bucket.
+func enableBucketObjectLock(vol *Volume) (err error) {
+	var versioning = &VersioningConfiguration{Status: VersioningStatusEnabled}
+	var bytes []byte
+	if bytes, err = xml.Marshal(versioning); err != nil {
+		return
+	}
+	if err = storeBucketVersioning(bytes, vol); err != nil {
+		return
+	}
+	vol.metaLoader.storeVersioning(versioning)
+	return storeObjectLockConfig(vol, &ObjectLockConfiguration{ObjectLockEnabled: ObjectLockEnabled})
+}
+
+func parseRetainUntilDate(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339, value)
+}
+
+func formatRetainUntilDate(t time.Time) string {
+	return t.UTC().Format(AMZTimeFormat)
+}
+
+// xattrs returns the extended attributes holding the object lock.
+func (opt *ObjectLockOption) xattrs() map[string]string {
+	var xattrs = make(map[string]string)
+	if opt.Mode != "" {
+		xattrs[XAttrKeyOSSLockMode] = opt.Mode
+		xattrs[XAttrKeyOSSLockRetainUntil] = strconv.FormatInt(opt.RetainUntil.Unix(), 10)
+	}
+	if opt.LegalHold != "" {
+		xattrs[XAttrKeyOSSLegalHold] = opt.LegalHold
+	}
+	return xattrs
+}
+
+// objectLockFromXAttrs takes the object lock out of the extended attributes of a multipart
+// upload, which is placed after the object is completed.
+func objectLockFromXAttrs(xattrs map[string]string) (opt *ObjectLockOption) {
+	for key, value := range xattrs {
+		if !isObjectLockXAttrKey(key) {
+			continue
+		}
+		if opt == nil {
+			opt = &ObjectLockOption{}
+		}
+		switch key {
+		case XAttrKeyOSSLockMode:
+			opt.Mode = value
+		case XAttrKeyOSSLockRetainUntil:
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				opt.RetainUntil = time.Unix(sec, 0)
+			}
+		case XAttrKeyOSSLegalHold:
+			opt.LegalHold = value
+		}
+	}
+	return
+}
+
+// objectRetained returns if the retention period of the object has not expired yet.
+func objectRetained(info *FSFileInfo, now time.Time) bool {
+	return info.ObjectLockMode != "" && info.ObjectLockRetainUntil.After(now)
+}
+
+// objectLocked returns if the object can not be deleted permanently.
+func objectLocked(info *FSFileInfo, now time.Time) bool {
+	return info.ObjectLegalHold == LegalHoldStatusOn || objectRetained(info, now)
+}
+
+// setObjectLock places the object lock on the inode. The lock is placed after the inode is
+// applied to the object, so that an inode failed to be written can still be released.
+func (v *Volume) setObjectLock(inode uint64, opt *ObjectLockOption) (err error) {
+	// The mode is set before the retention period, with which the meta nodes check the change.
+	var xattrs = opt.xattrs()
+	for _, key := range []string{XAttrKeyOSSLockMode, XAttrKeyOSSLockRetainUntil, XAttrKeyOSSLegalHold} {
+		var value, exist = xattrs[key]
+		if !exist {
+			continue
+		}
+		if err = v.mw.XAttrSet_ll(inode, []byte(key), []byte(value)); err != nil {
+			log.LogErrorf("setObjectLock: store object lock fail: volume(%v) inode(%v) key(%v) value(%v) err(%v)",
+				v.name, inode, key, value, err)
+			return
+		}
+	}
+	return
+}
+
+// clearObjectRetention removes the retention of the inode.
+func (v *Volume) clearObjectRetention(inode uint64) (err error) {
+	for _, key := range []string{XAttrKeyOSSLockRetainUntil, XAttrKeyOSSLockMode} {
+		if err = v.mw.XAttrDel_ll(inode, key); err != nil {
+			log.LogErrorf("clearObjectRetention: remove object retention fail: volume(%v) inode(%v) key(%v) err(%v)",
+				v.name, inode, key, err)
+			return
+		}
+	}
+	return
+}
+
+// parseObjectLockOption returns the object lock of the object to be written, which is given
+// by the request headers, or else the default retention of the bucket.
+func parseObjectLockOption(r *http.Request, vol *Volume) (opt *ObjectLockOption, errorCode *ErrorCode) {
+	var config, err = vol.metaLoader.loadObjectLock()
+	if err != nil {
+		return nil, InternalErrorCode(err)
+	}
+	var (
+		mode        = r.Header.Get(HeaderNameXAmzObjectLockMode)
+		retainUntil = r.Header.Get(HeaderNameXAmzObjectLockRetainUntilDate)
+		legalHold   = r.Header.Get(HeaderNameXAmzObjectLockLegalHold)
+	)
+	if mode == "" && retainUntil == "" && legalHold == "" {
+		return config.defaultLockOption(time.Now()), nil
+	}
+	if !config.enabled() {
+		return nil, InvalidObjectLockRequest
+	}
+	if (mode == "") != (retainUntil == "") {
+		return nil, &ErrorCode{ErrorCode: InvalidArgument.ErrorCode,
+			ErrorMessage: "x-amz-object-lock-retain-until-date and x-amz-object-lock-mode must both be supplied",
+			StatusCode:   InvalidArgument.StatusCode}
+	}
+	opt = &ObjectLockOption{Mode: mode, LegalHold: legalHold}
+	if mode != "" {
+		if !isObjectLockMode(mode) {
+			return nil, &ErrorCode{ErrorCode: InvalidArgument.ErrorCode, ErrorMessage: "Unknown wormMode directive.",
+				StatusCode: InvalidArgument.StatusCode}
+		}
+		if opt.RetainUntil, err = parseRetainUntilDate(retainUntil); err != nil || !opt.RetainUntil.After(time.Now()) {
+			return nil, &ErrorCode{ErrorCode: InvalidArgument.ErrorCode,
+				ErrorMessage: "The retain until date must be provided in ISO 8601 format and in the future",
+				StatusCode:   InvalidArgument.StatusCode}
+		}
+	} else if defaultOpt := config.defaultLockOption(time.Now()); defaultOpt != nil {
+		opt.Mode, opt.RetainUntil = defaultOpt.Mode, defaultOpt.RetainUntil
+	}
+	if legalHold != "" && !isLegalHoldStatus(legalHold) {
+		return nil, &ErrorCode{ErrorCode: InvalidArgument.ErrorCode, ErrorMessage: "Legal Hold must be either of 'ON' or 'OFF'",
+			StatusCode: InvalidArgument.StatusCode}
+	}
+	return opt, nil
+}
+
+// setObjectLockResponseHeader exposes the object lock of the object in the response headers.
+func setObjectLockResponseHeader(w http.ResponseWriter, info *FSFileInfo) {
+	if info.ObjectLockMode != "" {
+		w.Header()[HeaderNameXAmzObjectLockMode] = []string{info.ObjectLockMode}
+		w.Header()[HeaderNameXAmzObjectLockRetainUntilDate] = []string{formatRetainUntilDate(info.ObjectLockRetainUntil)}
+	}
+	if info.ObjectLegalHold != "" {
+		w.Header()[HeaderNameXAmzObjectLockLegalHold] = []string{info.ObjectLegalHold}
+	}
+}
+
+// allowBypassGovernance checks whether the request bypasses the governance retention. The
+// owner of the bucket and the administrators are allowed to, and the other users need the
+// BypassGovernanceRetention action granted by their own policy or the bucket policy.
+func (o *ObjectNode) allowBypassGovernance(r *http.Request, vol *Volume) bool {
+	if !strings.EqualFold(r.Header.Get(HeaderNameXAmzBypassGovernanceRetention), "true") {
+		return false
+	}
+	var param = ParseRequestParam(r)
+	param.action = proto.OSSBypassGovernanceRetentionAction
+	if allowed, _ := o.sessionPolicyAllows(r, param); !allowed {
+		return false
+	}
+	var userInfo, err = o.getRequestUserInfo(r, param.AccessKey())
+	if err != nil {
+		var accessKey, _ = vol.OSSSecure()
+		return (err == proto.ErrAccessKeyNotExists || err == proto.ErrUserNotExists) && accessKey == param.AccessKey()
+	}
+	if userInfo.UserType == proto.UserTypeRoot || userInfo.UserType == proto.UserTypeAdmin {
+		return true
+	}
+	param.userID = userInfo.UserID
+	if policy, _ := vol.metaLoader.loadPolicy(); policy != nil && !policy.IsEmpty() {
+		switch policy.Evaluate(param) {
+		case PolicyDeny:
+			return false
+		case PolicyAllow:
+			return true
+		}
+	}
+	return userInfo.Policy.IsOwn(vol.Name()) ||
+		userInfo.Policy.IsAuthorized(vol.Name(), strings.TrimRight(param.Object(), "/"), param.action)
+}
+
+// checkDeleteObjectVersion checks whether the version of the object can be deleted permanently.
+// The governance retention of the version is cleared if the request bypasses it.
+func (o *ObjectNode) checkDeleteObjectVersion(r *http.Request, vol *Volume, path, versionId string) *ErrorCode {
+	var info, err = vol.ObjectVersionMeta(path, versionId)
+	if err == syscall.ENOENT {
+		return nil
+	}
+	if err != nil {
+		return InternalErrorCode(err)
+	}
+	if info.DeleteMarker || !objectLocked(info, time.Now()) {
+		return nil
+	}
+	if info.ObjectLegalHold == LegalHoldStatusOn || info.ObjectLockMode != ObjectLockModeGovernance ||
+		!o.allowBypassGovernance(r, vol) {
+		log.LogWarnf("checkDeleteObjectVersion: object is locked: requestID(%v) volume(%v) path(%v) versionId(%v) "+
+			"mode(%v) retainUntil(%v) legalHold(%v)", GetRequestID(r), vol.Name(), path, versionId,
+			info.ObjectLockMode, info.ObjectLockRetainUntil, info.ObjectLegalHold)
+		return ObjectLocked
+	}
+	if err = vol.clearObjectRetention(info.Inode); err != nil {
+		return InternalErrorCode(err)
+	}
+	log.LogInfof("Audit: bypass governance retention: requestID(%v) remote(%v) volume(%v) path(%v) versionId(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), path, versionId)
+	return nil
+}
+
+func (opt *ObjectLockOption) fillFileInfo(info *FSFileInfo) {
+	if opt.Mode != "" {
+		info.ObjectLockMode = opt.Mode
+		info.ObjectLockRetainUntil = opt.RetainUntil
+	}
+	if opt.LegalHold != "" {
+		info.ObjectLegalHold = opt.LegalHold
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get bucket object lock configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
+func (o *ObjectNode) getBucketObjectLockHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var config *ObjectLockConfiguration
+	if config, err = vol.metaLoader.loadObjectLock(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if config == nil {
+		_ = ObjectLockConfigurationNotFound.ServeResponse(w, r)
+		return
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(config); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// Put bucket object lock configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLockConfiguration.html
+func (o *ObjectNode) putBucketObjectLockHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var bytes []byte
+	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	var config *ObjectLockConfiguration
+	if config, err = parseObjectLockConfig(bytes); err != nil {
+		log.LogWarnf("putBucketObjectLockHandler: parse object lock fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = MalformedXML.ServeResponse(w, r)
+		return
+	}
+	if err = config.validate(); err != nil {
+		log.LogWarnf("putBucketObjectLockHandler: invalid object lock: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = (&ErrorCode{ErrorCode: InvalidArgument.ErrorCode, ErrorMessage: err.Error(), StatusCode: InvalidArgument.StatusCode}).ServeResponse(w, r)
+		return
+	}
+
+	// the object lock depends on the versions of the objects
+	var status string
+	if status, err = vol.versioningStatus(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if status != VersioningStatusEnabled {
+		_ = InvalidBucketState.ServeResponse(w, r)
+		return
+	}
+
+	if err = storeObjectLockConfig(vol, config); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	// Audit object lock change
+	log.LogInfof("Audit: put bucket object lock: requestID(%v) remote(%v) volume(%v) defaultRetention(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), config.Rule != nil)
+	return
+}
+
+// loadObjectLockTarget loads the version of the object whose object lock is requested.
+func (o *ObjectNode) loadObjectLockTarget(r *http.Request) (vol *Volume, info *FSFileInfo, errorCode *ErrorCode) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		return nil, nil, InvalidBucketName
+	}
+	if param.Object() == "" {
+		return nil, nil, InvalidKey
+	}
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		return nil, nil, NoSuchBucket
+	}
+	var config *ObjectLockConfiguration
+	if config, err = vol.metaLoader.loadObjectLock(); err != nil {
+		return nil, nil, InternalErrorCode(err)
+	}
+	if !config.enabled() {
+		return nil, nil, InvalidObjectLockRequest
+	}
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	if info, err = vol.ObjectVersionMeta(param.Object(), versionId); err != nil {
+		if err == syscall.ENOENT && versionId != "" {
+			return nil, nil, NoSuchVersion
+		}
+		if err == syscall.ENOENT {
+			return nil, nil, NoSuchKey
+		}
+		log.LogErrorf("loadObjectLockTarget: get object meta fail: requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
+		return nil, nil, InternalErrorCode(err)
+	}
+	if info.DeleteMarker {
+		return nil, nil, MethodNotAllowed
+	}
+	return
+}
+
+// Get object retention
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectRetention.html
+func (o *ObjectNode) getObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var info *FSFileInfo
+	if _, info, errorCode = o.loadObjectLockTarget(r); errorCode != nil {
+		return
+	}
+	if info.ObjectLockMode == "" {
+		errorCode = NoSuchObjectLockConfiguration
+		return
+	}
+
+	var retention = ObjectRetention{
+		Mode:            info.ObjectLockMode,
+		RetainUntilDate: formatRetainUntilDate(info.ObjectLockRetainUntil),
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(retention); err != nil {
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// Put object retention
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectRetention.html
+func (o *ObjectNode) putObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var vol *Volume
+	var info *FSFileInfo
+	if vol, info, errorCode = o.loadObjectLockTarget(r); errorCode != nil {
+		return
+	}
+
+	var bytes []byte
+	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	var retention = &ObjectRetention{}
+	if err = xml.Unmarshal(bytes, retention); err != nil {
+		errorCode = MalformedXML
+		return
+	}
+
+	// An empty retention removes the retention of the object.
+	var remove = retention.Mode == "" && retention.RetainUntilDate == ""
+	var retainUntil time.Time
+	if !remove {
+		if !isObjectLockMode(retention.Mode) {
+			errorCode = MalformedXML
+			return
+		}
+		if retainUntil, err = parseRetainUntilDate(retention.RetainUntilDate); err != nil || !retainUntil.After(time.Now()) {
+			errorCode = &ErrorCode{ErrorCode: InvalidArgument.ErrorCode,
+				ErrorMessage: "The retain until date must be provided in ISO 8601 format and in the future",
+				StatusCode:   InvalidArgument.StatusCode}
+			return
+		}
+	}
+
+	// The compliance retention can only be extended, and the governance retention can be
+	// shortened or removed only if the request bypasses it.
+	if objectRetained(info, time.Now()) {
+		var weaken = remove || retainUntil.Unix() < info.ObjectLockRetainUntil.Unix()
+		switch {
+		case info.ObjectLockMode == ObjectLockModeCompliance && (weaken || retention.Mode != ObjectLockModeCompliance):
+			errorCode = ObjectLocked
+			return
+		case info.ObjectLockMode == ObjectLockModeGovernance && weaken && !o.allowBypassGovernance(r, vol):
+			errorCode = ObjectLocked
+			return
+		}
+	}
+
+	if remove {
+		err = vol.clearObjectRetention(info.Inode)
+	} else {
+		err = vol.setObjectLock(info.Inode, &ObjectLockOption{Mode: retention.Mode, RetainUntil: retainUntil})
+	}
+	if err == syscall.EPERM {
+		errorCode = ObjectLocked
+		return
+	}
+	if err != nil {
+		errorCode = InternalErrorCode(err)
+		return
+	}
+
+	// Audit retention change
+	log.LogInfof("Audit: put object retention: requestID(%v) remote(%v) volume(%v) path(%v) versionId(%v) mode(%v) retainUntil(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), info.Path, info.VersionId, retention.Mode, retention.RetainUntilDate)
+	return
+}
+
+// Get object legal hold
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLegalHold.html
+func (o *ObjectNode) getObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var info *FSFileInfo
+	if _, info, errorCode = o.loadObjectLockTarget(r); errorCode != nil {
+		return
+	}
+	if info.ObjectLegalHold == "" {
+		errorCode = NoSuchObjectLockConfiguration
+		return
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(ObjectLegalHold{Status: info.ObjectLegalHold}); err != nil {
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// Put object legal hold
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLegalHold.html
+func (o *ObjectNode) putObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var vol *Volume
+	var info *FSFileInfo
+	if vol, info, errorCode = o.loadObjectLockTarget(r); errorCode != nil {
+		return
+	}
+
+	var bytes []byte
+	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	var legalHold = &ObjectLegalHold{}
+	if err = xml.Unmarshal(bytes, legalHold); err != nil || !isLegalHoldStatus(legalHold.Status) {
+		errorCode = MalformedXML
+		return
+	}
+
+	if err = vol.setObjectLock(info.Inode, &ObjectLockOption{LegalHold: legalHold.Status}); err != nil {
+		errorCode = InternalErrorCode(err)
+		return
+	}
+
+	// Audit legal hold change
+	log.LogInfof("Audit: put object legal hold: requestID(%v) remote(%v) volume(%v) path(%v) versionId(%v) status(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), info.Path, info.VersionId, legalHold.Status)
+	return
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObjectLockConfigurationValidate(t *testing.T) {
+	var cases = []struct {
+		config string
+		valid  bool
+	}{
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>", true},
+		{"<ObjectLockConfiguration></ObjectLockConfiguration>", false},
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>", true},
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>COMPLIANCE</Mode><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>", true},
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>COMPLIANCE</Mode><Days>1</Days><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>", false},
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>COMPLIANCE</Mode></DefaultRetention></Rule></ObjectLockConfiguration>", false},
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>UNKNOWN</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>", false},
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>-1</Days></DefaultRetention></Rule></ObjectLockConfiguration>", false},
+		{"<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule></Rule></ObjectLockConfiguration>", false},
+	}
+	for i, c := range cases {
+		config, err := parseObjectLockConfig([]byte(c.config))
+		if err != nil {
+			t.Fatalf("case %v: parse object lock configuration fail: err(%v)", i, err)
+		}
+		if err = config.validate(); (err == nil) != c.valid {
+			t.Fatalf("case %v: validate result mismatch: expect valid(%v) actual err(%v)", i, c.valid, err)
+		}
+	}
+}
+
+func TestObjectLockDefaultRetention(t *testing.T) {
+	var now = time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)
+	var config = &ObjectLockConfiguration{
+		ObjectLockEnabled: ObjectLockEnabled,
+		Rule:              &ObjectLockRule{DefaultRetention: &DefaultRetention{Mode: ObjectLockModeGovernance, Days: 2}},
+	}
+	opt := config.defaultLockOption(now)
+	if opt == nil || opt.Mode != ObjectLockModeGovernance || !opt.RetainUntil.Equal(now.AddDate(0, 0, 2)) {
+		t.Fatalf("default retention by days mismatch: %v", opt)
+	}
+	config.Rule.DefaultRetention = &DefaultRetention{Mode: ObjectLockModeCompliance, Years: 1}
+	opt = config.defaultLockOption(now)
+	if opt == nil || opt.Mode != ObjectLockModeCompliance || !opt.RetainUntil.Equal(now.AddDate(1, 0, 0)) {
+		t.Fatalf("default retention by years mismatch: %v", opt)
+	}
+	if opt = (&ObjectLockConfiguration{ObjectLockEnabled: ObjectLockEnabled}).defaultLockOption(now); opt != nil {
+		t.Fatalf("unexpected default retention: %v", opt)
+	}
+}
+
+func TestObjectLockXAttrs(t *testing.T) {
+	var now = time.Now()
+	var opt = &ObjectLockOption{
+		Mode:        ObjectLockModeCompliance,
+		RetainUntil: time.Unix(now.Unix()+3600, 0),
+		LegalHold:   LegalHoldStatusOff,
+	}
+	var xattrs = opt.xattrs()
+	xattrs["oss:tagging"] = "k=v"
+	restored := objectLockFromXAttrs(xattrs)
+	if restored == nil || restored.Mode != opt.Mode || !restored.RetainUntil.Equal(opt.RetainUntil) || restored.LegalHold != opt.LegalHold {
+		t.Fatalf("object lock mismatch: expect(%v) actual(%v)", opt, restored)
+	}
+	if restored = objectLockFromXAttrs(map[string]string{"oss:tagging": "k=v"}); restored != nil {
+		t.Fatalf("unexpected object lock: %v", restored)
+	}
+
+	var info = &FSFileInfo{}
+	opt.fillFileInfo(info)
+	if !objectRetained(info, now) || !objectLocked(info, now) {
+		t.Fatalf("object should be locked before retain until date")
+	}
+	if objectLocked(info, opt.RetainUntil.Add(time.Second)) {
+		t.Fatalf("object should not be locked after retain until date")
+	}
+	info.ObjectLegalHold = LegalHoldStatusOn
+	if !objectLocked(info, opt.RetainUntil.Add(time.Second)) {
+		t.Fatalf("object should be locked by legal hold")
+	}
+}
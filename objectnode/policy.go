@@ -39,6 +39,14 @@ const (
 	PolicyDefaultVersion  = "2012-10-17"
 	BucketPolicyLimitSize = 20 * 1024 //Bucket policies are limited to 20KB
 	ArnSplitToken         = ":"
+	S3ResourcePrefix      = "arn:aws:s3:::"
+)
+
+// The results of the evaluation of the bucket policy for a request.
+const (
+	PolicyDefault = iota // no statement applies to the request
+	PolicyAllow
+	PolicyDeny
 )
 
 //https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
@@ -68,7 +76,7 @@ type Arn struct {
 
 func parseArn(str string) (*Arn, error) {
 	items := strings.Split(str, ArnSplitToken)
-	if len(items) < 4 {
+	if len(items) < 6 {
 		log.LogErrorf("Arn is invalid: %v", str)
 		return nil, errors.New("invalid arn")
 	}
@@ -166,32 +174,97 @@ func (p Policy) Validate(bucket string) (bool, error) {
 // check policy is allowed for request
 // https://docs.aws.amazon.com/zh_cn/IAM/latest/UserGuide/reference_policies_evaluation-logic.html
 func (p *Policy) IsAllowed(params *RequestParam, isOwner bool) bool {
+	switch p.Evaluate(params) {
+	case PolicyDeny:
+		return false
+	case PolicyAllow:
+		return true
+	}
+	return isOwner
+}
+
+// Evaluate returns the result of the statements applied to the request, an explicit deny
+// overrides any allows.
+func (p *Policy) Evaluate(params *RequestParam) int {
+	var result = PolicyDefault
 	for _, s := range p.Statements {
+		if !s.check(params) {
+			continue
+		}
 		if s.Effect == Deny {
-			if !s.IsAllowed(params) {
-				log.LogDebugf("policy deny cause of %v, %v", s, params)
-				return false
-			}
+			log.LogDebugf("policy deny cause of %v, %v", s, params)
+			return PolicyDeny
 		}
+		result = PolicyAllow
 	}
+	return result
+}
 
-	//is owner
-	if isOwner {
-		return true
+// objectACLActions are the actions which the ACLs of the objects apply to.
+var objectACLActions = aclObjectPermissionActions[FullControlPermission]
+
+// objectACLAllows returns if the ACL of the requested object grants the action to the requester.
+// The object ACL only grants the access besides the bucket policy and the bucket ACL.
+func (o *ObjectNode) objectACLAllows(r *http.Request, vol *Volume, param *RequestParam) bool {
+	if vol == nil || param.Object() == "" || !objectACLActions.Contains(param.Action()) {
+		return false
 	}
+	var acl, _, err = vol.GetObjectACL(param.Object(), r.URL.Query().Get(ParamVersionId))
+	if err != nil || acl == nil || acl.IsAclEmpty() {
+		return false
+	}
+	return acl.IsAllowed(param, false)
+}
 
-	for _, s := range p.Statements {
-		if s.Effect == Allow {
-			if s.IsAllowed(params) {
-				log.LogDebugf("policy allow cause of %v, %v", s, params)
-				return true
-			}
-		}
+// anonymousAllowed checks the anonymous request, which is allowed only if the bucket policy allows
+// it or the bucket or object ACL grants the action to all users, such as the canned ACL "public-read".
+func (o *ObjectNode) anonymousAllowed(r *http.Request, param *RequestParam) (allowed bool, ec *ErrorCode) {
+	if param.Bucket() == "" || param.action == proto.OSSCreateBucketAction {
+		return false, nil
+	}
+	var vol, err = o.getVol(param.Bucket())
+	if err == proto.ErrVolNotExists {
+		return false, NoSuchBucket
+	}
+	if err != nil {
+		return false, InternalErrorCode(err)
 	}
 
-	log.LogDebugf("policy deny cause of %v, request: %v", p, params)
+	var policy *Policy
+	if policy, err = vol.metaLoader.loadPolicy(); err != nil {
+		return false, InternalErrorCode(err)
+	}
+	if policy != nil && !policy.IsEmpty() {
+		if policy.usesObjectTags() {
+			addObjectTagConditionValues(param, vol, r)
+		}
+		switch policy.Evaluate(param) {
+		case PolicyDeny:
+			return false, nil
+		case PolicyAllow:
+			log.LogDebugf("policyCheck: anonymous request allowed by bucket policy: requestID(%v) volume(%v) action(%v)",
+				GetRequestID(r), param.Bucket(), param.Action())
+			return true, nil
+		}
+	}
 
-	return false
+	var acl *AccessControlPolicy
+	if acl, err = vol.metaLoader.loadACL(); err != nil {
+		return false, InternalErrorCode(err)
+	}
+	if acl != nil && !acl.IsAclEmpty() && acl.IsAllowed(param, false) {
+		log.LogDebugf("policyCheck: anonymous request allowed by bucket ACL: requestID(%v) volume(%v) action(%v)",
+			GetRequestID(r), param.Bucket(), param.Action())
+		return true, nil
+	}
+	if o.objectACLAllows(r, vol, param) {
+		log.LogDebugf("policyCheck: anonymous request allowed by object ACL: requestID(%v) volume(%v) object(%v) action(%v)",
+			GetRequestID(r), param.Bucket(), param.Object(), param.Action())
+		return true, nil
+	}
+	log.LogDebugf("policyCheck: anonymous request not allowed: requestID(%v) volume(%v) action(%v)",
+		GetRequestID(r), param.Bucket(), param.Action())
+	return false, nil
 }
 
 func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
@@ -214,6 +287,19 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 
 		param := ParseRequestParam(r)
 
+		// The session policy of the temporary credentials limits the requests of any user.
+		if sessionAllowed, sessionErr := o.sessionPolicyAllows(r, param); !sessionAllowed {
+			log.LogDebugf("policyCheck: session policy not allowed: requestID(%v) accessKey(%v) volume(%v) action(%v) err(%v)",
+				GetRequestID(r), param.AccessKey(), param.Bucket(), param.Action(), sessionErr)
+			allowed = false
+			return
+		}
+
+		if param.AccessKey() == "" {
+			allowed, ec = o.anonymousAllowed(r, param)
+			return
+		}
+
 		if param.Bucket() == "" {
 			log.LogDebugf("policyCheck: no bucket specified: requestID(%v)", GetRequestID(r))
 			allowed = true
@@ -241,7 +327,8 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 		}
 		var userInfo *proto.UserInfo
 		isOwner := false
-		if userInfo, err = o.getUserInfoByAccessKey(param.AccessKey()); err == nil {
+		userAuthorized := false
+		if userInfo, err = o.getRequestUserInfo(r, param.AccessKey()); err == nil {
 			// White list for admin and root user.
 			if userInfo.UserType == proto.UserTypeRoot || userInfo.UserType == proto.UserTypeAdmin {
 				log.LogDebugf("policyCheck: user is admin: requestID(%v) userID(%v) accessKey(%v) volume(%v)",
@@ -249,24 +336,26 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 				allowed = true
 				return
 			}
+			param.userID = userInfo.UserID
 			var userPolicy = userInfo.Policy
 			isOwner = userPolicy.IsOwn(param.Bucket())
 			subdir := strings.TrimRight(param.Object(), "/")
 			if subdir == "" {
 				subdir = r.URL.Query().Get(ParamPrefix)
 			}
-			if !isOwner && !userPolicy.IsAuthorized(param.Bucket(), subdir, param.Action()) {
+			userAuthorized = isOwner || userPolicy.IsAuthorized(param.Bucket(), subdir, param.Action())
+			if !userAuthorized {
 				log.LogDebugf("policyCheck: user no permission: url(%v) subdir(%v) requestID(%v) userID(%v) accessKey(%v) volume(%v) object(%v) action(%v)",
 					r.URL, subdir, GetRequestID(r), userInfo.UserID, param.AccessKey(), param.Bucket(), param.Object(), param.Action())
-				allowed = false
-				return
 			}
 		} else if (err == proto.ErrAccessKeyNotExists || err == proto.ErrUserNotExists) && volume != nil {
 			if ak, _ := volume.OSSSecure(); ak != param.AccessKey() {
 				allowed = false
 				return
 			}
+			param.userID = volume.Owner()
 			isOwner = true
+			userAuthorized = true
 		} else {
 			log.LogErrorf("policyCheck: load user policy from master fail: requestID(%v) accessKey(%v) err(%v)",
 				GetRequestID(r), param.AccessKey(), err)
@@ -296,13 +385,36 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		var policyResult = PolicyDefault
 		if vol != nil && policy != nil && !policy.IsEmpty() {
-			allowed = policy.IsAllowed(param, isOwner)
-			if !allowed {
-				log.LogWarnf("policyCheck: bucket policy not allowed: requestID(%v) userID(%v) accessKey(%v) volume(%v) action(%v)",
-					GetRequestID(r), userInfo, param.AccessKey(), param.Bucket(), param.Action())
-				return
+			if policy.usesObjectTags() {
+				addObjectTagConditionValues(param, vol, r)
 			}
+			policyResult = policy.Evaluate(param)
+		}
+		switch {
+		case policyResult == PolicyDeny:
+			log.LogWarnf("policyCheck: bucket policy not allowed: requestID(%v) userID(%v) accessKey(%v) volume(%v) action(%v)",
+				GetRequestID(r), param.userID, param.AccessKey(), param.Bucket(), param.Action())
+			allowed = false
+			return
+		case policyResult == PolicyAllow:
+			// The bucket policy grants the access to the users who are not authorized by their own
+			// policy, such as the users of other accounts.
+			allowed = true
+			log.LogDebugf("policyCheck: bucket policy allowed: requestID(%v) userID(%v) accessKey(%v) volume(%v) action(%v)",
+				GetRequestID(r), param.userID, param.AccessKey(), param.Bucket(), param.Action())
+			return
+		case o.objectACLAllows(r, vol, param):
+			// The object ACL grants the access to the object, such as to the bucket owner by the
+			// canned ACL "bucket-owner-full-control".
+			allowed = true
+			log.LogDebugf("policyCheck: object ACL allowed: requestID(%v) userID(%v) accessKey(%v) volume(%v) object(%v) action(%v)",
+				GetRequestID(r), param.userID, param.AccessKey(), param.Bucket(), param.Object(), param.Action())
+			return
+		case !userAuthorized:
+			allowed = false
+			return
 		}
 
 		if vol != nil && acl != nil && !acl.IsAclEmpty() {
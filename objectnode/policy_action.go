@@ -49,11 +49,60 @@ func ActionFromRouteName(name string) proto.Action {
 	return proto.ParseAction(name[:len(name)-33])
 }
 
+const S3ActionPrefix = "s3:"
+
+// The actions of S3 which permit several actions of the object node, the other actions of S3
+// are the actions of the object node of the same name, such as "s3:GetBucketPolicy".
+var s3ActionAliases = map[string]proto.Actions{
+	"s3:ListAllMyBuckets":            {proto.OSSListBucketsAction},
+	"s3:ListBucket":                  {proto.OSSListObjectsAction, proto.OSSHeadBucketAction},
+	"s3:ListBucketVersions":          {proto.OSSListObjectVersionsAction},
+	"s3:ListBucketMultipartUploads":  {proto.OSSListMultipartUploadsAction},
+	"s3:ListMultipartUploadParts":    {proto.OSSListPartsAction},
+	"s3:GetObject":                   {proto.OSSGetObjectAction, proto.OSSHeadObjectAction, proto.OSSSelectObjectContentAction},
+	"s3:GetObjectVersion":            {proto.OSSGetObjectAction, proto.OSSHeadObjectAction},
+	"s3:PutObject":                   {proto.OSSPutObjectAction, proto.OSSCopyObjectAction, proto.OSSCreateMultipartUploadAction, proto.OSSUploadPartAction, proto.OSSCompleteMultipartUploadAction},
+	"s3:DeleteObject":                {proto.OSSDeleteObjectAction, proto.OSSDeleteObjectsAction},
+	"s3:DeleteObjectVersion":         {proto.OSSDeleteObjectAction, proto.OSSDeleteObjectsAction},
+	"s3:GetLifecycleConfiguration":   {proto.OSSGetBucketLifecycleAction},
+	"s3:PutLifecycleConfiguration":   {proto.OSSPutBucketLifecycleAction, proto.OSSDeleteBucketLifecycleAction},
+	"s3:GetBucketCORS":               {proto.OSSGetBucketCorsAction},
+	"s3:PutBucketCORS":               {proto.OSSPutBucketCorsAction, proto.OSSDeleteBucketCorsAction},
+	"s3:GetObjectVersionTagging":     {proto.OSSGetObjectTaggingAction},
+	"s3:GetReplicationConfiguration": {proto.OSSGetBucketReplicationAction},
+	"s3:PutReplicationConfiguration": {proto.OSSPutBucketReplicationAction, proto.OSSDeleteBucketReplicationAction},
+	"s3:GetInventoryConfiguration":   {proto.OSSGetBucketInventoryAction, proto.OSSListBucketInventoryAction},
+	"s3:PutInventoryConfiguration":   {proto.OSSPutBucketInventoryAction, proto.OSSDeleteBucketInventoryAction},
+}
+
+// actionMatch returns true if the action of the policy, which may contain wildcards such as
+// "s3:Get*", permits the action of the request.
+func actionMatch(pattern string, action proto.Action) bool {
+	if pattern == "*" || pattern == action.String() {
+		return true
+	}
+	for name, actions := range s3ActionAliases {
+		if wildcardMatch(pattern, name) && actions.Contains(action) {
+			return true
+		}
+	}
+	return wildcardMatch(pattern, S3ActionPrefix+action.Name())
+}
+
+func actionsMatch(actions StringSet, action proto.Action) bool {
+	for pattern := range actions.values {
+		if actionMatch(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s Statement) checkActions(p *RequestParam) bool {
 	if s.Actions.Empty() {
 		return true
 	}
-	if s.Actions.ContainsWithAny(p.Action().String()) {
+	if actionsMatch(s.Actions, p.Action()) {
 		return true
 	}
 	return false
@@ -63,7 +112,7 @@ func (s Statement) checkNotActions(p *RequestParam) bool {
 	if s.NotActions.Empty() {
 		return true
 	}
-	if s.NotActions.ContainsWithAny(p.Action().String()) {
+	if actionsMatch(s.NotActions, p.Action()) {
 		return false
 	}
 	return true
@@ -20,11 +20,17 @@ package objectnode
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/access-policy-language-overview.html
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/cubefs/cubefs/proto"
 )
 
 //https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
@@ -106,6 +112,11 @@ const (
 	AwsUserId                              = "aws:userid"
 	AwsUserName                            = "aws:username"
 	AwsVpcSourceIp                         = "aws:VpcSourceIp"
+
+	// https://docs.aws.amazon.com/AmazonS3/latest/dev/object-tagging.html
+	S3ExistingObjectTag    = "s3:ExistingObjectTag/" // followed by the tag key
+	S3RequestObjectTag     = "s3:RequestObjectTag/"  // followed by the tag key
+	S3RequestObjectTagKeys = "s3:RequestObjectTagKeys"
 )
 
 var ConditionKeyType = map[ConditionKey]ConditionTypeSet{
@@ -133,22 +144,33 @@ var ConditionKeyType = map[ConditionKey]ConditionTypeSet{
 	AwsUserId:                 StringType,
 	AwsUserName:               StringType,
 	AwsVpcSourceIp:            IpAddressType,
+	S3RequestObjectTagKeys:    StringType,
 }
 
 var ConditionFuncMap = map[ConditionType]ConditionFunc{
-	IpAddress:             IpAddressFunc,
-	NotIpAddress:          NotIpAddressFunc,
-	StringLike:            StringLikeFunc,
-	StringNotLike:         StringNotLikeFunc,
-	StringEquals:          StringEqualsFunc,
-	StringNotEquals:       StringNotEqualsFunc,
-	Bool:                  BoolFunc,
-	DateEquals:            DateEqualsFunc,
-	DateNotEquals:         DateNotEqualsFunc,
-	DateLessThan:          DateLessThanFunc,
-	DateLessThanEquals:    DateLessThanEqualsFunc,
-	DateGreaterThan:       DateGreaterThanFunc,
-	DateGreaterThanEquals: DateGreaterThanEqualsFunc,
+	IpAddress:                IpAddressFunc,
+	NotIpAddress:             NotIpAddressFunc,
+	StringLike:               StringLikeFunc,
+	StringNotLike:            StringNotLikeFunc,
+	StringEquals:             StringEqualsFunc,
+	StringNotEquals:          StringNotEqualsFunc,
+	Bool:                     BoolFunc,
+	DateEquals:               DateEqualsFunc,
+	DateNotEquals:            DateNotEqualsFunc,
+	DateLessThan:             DateLessThanFunc,
+	DateLessThanEquals:       DateLessThanEqualsFunc,
+	DateGreaterThan:          DateGreaterThanFunc,
+	DateGreaterThanEquals:    DateGreaterThanEqualsFunc,
+	NumericEquals:            NumericEqualsFunc,
+	NumericNotEquals:         NumericNotEqualsFunc,
+	NumericLessThan:          NumericLessThanFunc,
+	NumericLessThanEquals:    NumericLessThanEqualsFunc,
+	NumericGreaterThan:       NumericGreaterThanFunc,
+	NumericGreaterThanEquals: NumericGreaterThanEqualsFunc,
+	ArnEquals:                ArnEqualsFunc,
+	ArnNotEquals:             ArnNotEqualsFunc,
+	ArnLike:                  ArnLikeFunc,
+	ArnNotLike:               ArnNotLikeFunc,
 }
 
 type ConditionFunc func(p *RequestParam, values ConditionValues) bool
@@ -184,6 +206,8 @@ func getCondtionValues(r *http.Request) map[string][]string {
 		"userid":        {accessKey},
 		"username":      {accessKey},
 		"PrincipalType": {principalType},
+		"SecureTransport": {strconv.FormatBool(r.TLS != nil ||
+			strings.EqualFold(r.Header.Get(HeaderNameXForwardedProto), "https"))},
 	}
 
 	for k, v := range r.Header {
@@ -205,72 +229,130 @@ func getCondtionValues(r *http.Request) map[string][]string {
 	return values
 }
 
-func IpAddressFunc(p *RequestParam, value ConditionValues) bool {
-	key := TrimAwsPrefixKey(AwsSourceIp)
-	canonicalKey := http.CanonicalHeaderKey(key)
-	sourceIP, ok := value[canonicalKey]
-	if !ok {
-		return false
+// conditionValues returns the values of the condition key of the request, the key is matched
+// without the prefix of the service, and the case of the key is ignored.
+func conditionValues(p *RequestParam, key string) (values []string, ok bool) {
+	key = TrimAwsPrefixKey(key)
+	if values, ok = p.conditionVars[key]; ok {
+		return
 	}
-	for ipnet := range sourceIP.values {
-		if ok, _ := isIPNetContainsIP(p.sourceIP, ipnet); ok {
-			return true
+	if values, ok = p.conditionVars[http.CanonicalHeaderKey(key)]; ok {
+		return
+	}
+	for k, v := range p.conditionVars {
+		if strings.EqualFold(k, key) {
+			return v, true
 		}
 	}
-
-	return true
+	return nil, false
 }
 
-func NotIpAddressFunc(p *RequestParam, values ConditionValues) bool {
-	return !IpAddressFunc(p, values)
-}
-
-func StringLikeFunc(reqParam *RequestParam, storeCondVals ConditionValues) bool {
-	for k, storeVals := range storeCondVals {
-		key := TrimAwsPrefixKey(k)
-		canonicalKey := http.CanonicalHeaderKey(key)
-		if reqVals, ok := reqParam.conditionVars[canonicalKey]; ok {
-			for _, rv := range reqVals {
-				for sv := range storeVals.values {
-					if match := patternMatch(rv, sv); match {
-						return true
-					}
-				}
+type conditionMatchFunc func(requestValue, conditionValue string) bool
+
+func matchAnyValue(requestValues []string, conditionValues StringSet, match conditionMatchFunc) bool {
+	for _, rv := range requestValues {
+		for cv := range conditionValues.values {
+			if match(rv, cv) {
+				return true
 			}
 		}
 	}
-
 	return false
 }
 
-func StringNotLikeFunc(p *RequestParam, values ConditionValues) bool {
-	return !StringLikeFunc(p, values)
+// matchCondition returns true if the request matches any value of each key of the condition,
+// the condition is not matched if the key does not exist in the request.
+func matchCondition(p *RequestParam, condition ConditionValues, match conditionMatchFunc) bool {
+	for key, values := range condition {
+		requestValues, ok := conditionValues(p, key)
+		if !ok || !matchAnyValue(requestValues, values, match) {
+			return false
+		}
+	}
+	return true
 }
 
-func StringEqualsFunc(reqParam *RequestParam, storeCondVals ConditionValues) bool {
-	for k, storeVals := range storeCondVals {
-		key := TrimAwsPrefixKey(k)
-		canonicalKey := http.CanonicalHeaderKey(key)
-		if reqVals, ok := reqParam.conditionVars[canonicalKey]; ok {
-			for _, rv := range reqVals {
-				if storeVals.Contains(rv) {
-					return true
-				}
-			}
-		} else if reqVals, ok := reqParam.conditionVars[key]; ok {
-			for _, rv := range reqVals {
-				if storeVals.Contains(rv) {
-					return true
-				}
-			}
+// matchNegatedCondition returns true if the request matches none of the values of each key of
+// the condition.
+func matchNegatedCondition(p *RequestParam, condition ConditionValues, match conditionMatchFunc) bool {
+	for key, values := range condition {
+		requestValues, _ := conditionValues(p, key)
+		if matchAnyValue(requestValues, values, match) {
+			return false
 		}
 	}
+	return true
+}
 
-	return false
+func ipMatch(requestValue, conditionValue string) bool {
+	ok, _ := isIPNetContainsIP(requestValue, conditionValue)
+	return ok
+}
+
+func stringEqual(requestValue, conditionValue string) bool {
+	return requestValue == conditionValue
+}
+
+func stringLike(requestValue, conditionValue string) bool {
+	return wildcardMatch(conditionValue, requestValue)
+}
+
+func boolEqual(requestValue, conditionValue string) bool {
+	rv, err1 := strconv.ParseBool(requestValue)
+	cv, err2 := strconv.ParseBool(conditionValue)
+	return err1 == nil && err2 == nil && rv == cv
+}
+
+// The dates of the conditions are in ISO 8601 format or the epoch time in seconds.
+func parseConditionTime(value string) (t time.Time, err error) {
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return
+	}
+	var epoch int64
+	if epoch, err = strconv.ParseInt(value, 10, 64); err != nil {
+		return
+	}
+	return time.Unix(epoch, 0), nil
+}
+
+func dateCompare(compare func(rv, cv time.Time) bool) conditionMatchFunc {
+	return func(requestValue, conditionValue string) bool {
+		rv, err1 := parseConditionTime(requestValue)
+		cv, err2 := parseConditionTime(conditionValue)
+		return err1 == nil && err2 == nil && compare(rv, cv)
+	}
+}
+
+func numericCompare(compare func(rv, cv float64) bool) conditionMatchFunc {
+	return func(requestValue, conditionValue string) bool {
+		rv, err1 := strconv.ParseFloat(requestValue, 64)
+		cv, err2 := strconv.ParseFloat(conditionValue, 64)
+		return err1 == nil && err2 == nil && compare(rv, cv)
+	}
+}
+
+func IpAddressFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, ipMatch)
+}
+
+func NotIpAddressFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, ipMatch)
+}
+
+func StringLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringLike)
+}
+
+func StringNotLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, stringLike)
+}
+
+func StringEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringEqual)
 }
 
 func StringNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
-	return !StringNotEqualsFunc(p, values)
+	return matchNegatedCondition(p, values, stringEqual)
 }
 
 // check statement conditions
@@ -281,7 +363,8 @@ func (s Statement) checkConditions(param *RequestParam) bool {
 	for k, v := range s.Condition {
 		f, ok := ConditionFuncMap[k]
 		if !ok {
-			continue
+			// The statement does not apply to any request with an unsupported condition.
+			return false
 		}
 		if !f(param, v) {
 			return false
@@ -291,145 +374,121 @@ func (s Statement) checkConditions(param *RequestParam) bool {
 	return true
 }
 
-func BoolFunc(p *RequestParam, policyCondtion ConditionValues) bool {
-	if len(policyCondtion) == 0 {
-		return true
-	}
-	for condKey, condVal := range policyCondtion {
-		for vals := range condVal.values {
-			val1, _ := strconv.ParseBool(vals)
-			if cond, ok := p.conditionVars[condKey]; ok {
-				for _, c := range cond {
-					val2, _ := strconv.ParseBool(c)
-					return val1 == val2
-				}
-			}
-		}
-	}
-
-	return false
+func BoolFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, boolEqual)
 }
 
-func DateEqualsFunc(p *RequestParam, policyVals ConditionValues) bool {
-	for k, pVals := range policyVals {
-		for pVal := range pVals.values {
-			pDate, err := time.Parse(AMZTimeFormat, pVal)
-			if err != nil {
-				return false
-			}
-			if reqVals, ok := p.conditionVars[k]; ok {
-				for _, reqVal := range reqVals {
-					reqDate, err := time.Parse(AMZTimeFormat, reqVal)
-					if err != nil {
-						return false
-					}
-					return pDate.Equal(reqDate)
-				}
-			}
-		}
-	}
-
-	return false
+func DateEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(func(rv, cv time.Time) bool { return rv.Equal(cv) }))
 }
 
-func DateNotEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !DateEqualsFunc(p, value)
+func DateNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, dateCompare(func(rv, cv time.Time) bool { return rv.Equal(cv) }))
 }
 
-func DateLessThanFunc(p *RequestParam, value ConditionValues) bool {
-	for k, pVals := range value {
-		for pVal := range pVals.values {
-			pDate, err := time.Parse(AMZTimeFormat, pVal)
-			if err != nil {
-				return false
-			}
-			if reqVals, ok := p.conditionVars[k]; ok {
-				for _, reqVal := range reqVals {
-					reqDate, err := time.Parse(AMZTimeFormat, reqVal)
-					if err != nil {
-						return false
-					}
-					return reqDate.Before(pDate)
-				}
-			}
-		}
-	}
-	return false
+func DateLessThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(func(rv, cv time.Time) bool { return rv.Before(cv) }))
 }
 
-func DateLessThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	for k, pVals := range value {
-		for pVal := range pVals.values {
-			pDate, err := time.Parse(AMZTimeFormat, pVal)
-			if err != nil {
-				return false
-			}
-			if reqVals, ok := p.conditionVars[k]; ok {
-				for _, reqVal := range reqVals {
-					reqDate, err := time.Parse(AMZTimeFormat, reqVal)
-					if err != nil {
-						return false
-					}
-					return reqDate.Before(pDate) || reqDate.Equal(pDate)
-				}
-			}
-		}
-	}
-	return true
+func DateLessThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(func(rv, cv time.Time) bool { return !rv.After(cv) }))
 }
 
-func DateGreaterThanFunc(p *RequestParam, value ConditionValues) bool {
-	return !DateLessThanEqualsFunc(p, value)
+func DateGreaterThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(func(rv, cv time.Time) bool { return rv.After(cv) }))
 }
 
-func DateGreaterThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !DateLessThanFunc(p, value)
+func DateGreaterThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, dateCompare(func(rv, cv time.Time) bool { return !rv.Before(cv) }))
 }
 
-func NumericEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric equal implements
-
-	return true
+func NumericEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(func(rv, cv float64) bool { return rv == cv }))
 }
 
-func NumericNotEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !NumericEqualsFunc(p, value)
+func NumericNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, numericCompare(func(rv, cv float64) bool { return rv == cv }))
 }
 
-func NumericLessThanFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric less than implements
-
-	return true
+func NumericLessThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(func(rv, cv float64) bool { return rv < cv }))
 }
 
-func NumericLessThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return NumericLessThanFunc(p, value) || NumericEqualsFunc(p, value)
+func NumericLessThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(func(rv, cv float64) bool { return rv <= cv }))
 }
 
-func NumericGreaterThanFunc(p *RequestParam, value ConditionValues) bool {
-	return !NumericLessThanEqualsFunc(p, value)
+func NumericGreaterThanFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(func(rv, cv float64) bool { return rv > cv }))
 }
 
-func NumericGreaterThanEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !NumericLessThanFunc(p, value)
+func NumericGreaterThanEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, numericCompare(func(rv, cv float64) bool { return rv >= cv }))
 }
 
-func ArnEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric equal implements
+func ArnEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringEqual)
+}
 
-	return true
+func ArnNotEqualsFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, stringEqual)
 }
 
-func ArnNotEqualsFunc(p *RequestParam, value ConditionValues) bool {
-	return !ArnEqualsFunc(p, value)
+func ArnLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchCondition(p, values, stringLike)
 }
 
-func ArnLikeFunc(p *RequestParam, value ConditionValues) bool {
-	//TODO: numeric equal implements
+func ArnNotLikeFunc(p *RequestParam, values ConditionValues) bool {
+	return matchNegatedCondition(p, values, stringLike)
+}
 
-	return true
+// usesObjectTags reports whether any condition of the policy refers to the tags of the objects,
+// which are loaded only for such policies.
+func (p *Policy) usesObjectTags() bool {
+	for _, s := range p.Statements {
+		for _, values := range s.Condition {
+			for key := range values {
+				if strings.HasPrefix(key, S3ExistingObjectTag) || strings.HasPrefix(key, S3RequestObjectTag) ||
+					key == S3RequestObjectTagKeys {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
 
-func ArnNotLikeFunc(p *RequestParam, value ConditionValues) bool {
-	return !ArnLikeFunc(p, value)
+// addObjectTagConditionValues adds the tags of the existing object and the tags given by the request
+// to the condition values of the request.
+func addObjectTagConditionValues(p *RequestParam, vol *Volume, r *http.Request) {
+	if p.Object() != "" {
+		tagging, _, err := vol.GetObjectTagging(p.Object(), r.URL.Query().Get(ParamVersionId))
+		if err == nil {
+			for _, tag := range tagging.TagSet {
+				p.conditionVars[TrimAwsPrefixKey(S3ExistingObjectTag+tag.Key)] = []string{tag.Value}
+			}
+		}
+	}
+
+	var tagging *Tagging
+	if header := r.Header.Get(HeaderNameXAmzTagging); header != "" {
+		tagging, _ = ParseTagging(header)
+	} else if p.Action() == proto.OSSPutObjectTaggingAction && r.Body != nil {
+		// the body is read again by the handler
+		data, _ := ioutil.ReadAll(io.LimitReader(r.Body, MaxTaggingBodySize))
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+		tagging = NewTagging()
+		if xml.Unmarshal(data, tagging) != nil {
+			tagging = nil
+		}
+	}
+	if tagging == nil {
+		return
+	}
+	var keys = make([]string, 0, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		p.conditionVars[TrimAwsPrefixKey(S3RequestObjectTag+tag.Key)] = []string{tag.Value}
+		keys = append(keys, tag.Key)
+	}
+	p.conditionVars[TrimAwsPrefixKey(S3RequestObjectTagKeys)] = keys
 }
@@ -15,6 +15,7 @@
 package objectnode
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -29,7 +30,9 @@ func (o *ObjectNode) getBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 		err error
 		ec  *ErrorCode
 	)
-	defer o.errorResponse(w, r, err, ec)
+	defer func() {
+		o.errorResponse(w, r, err, ec)
+	}()
 
 	var param = ParseRequestParam(r)
 	if param.Bucket() == "" {
@@ -48,6 +51,10 @@ func (o *ObjectNode) getBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 		ec = InternalErrorCode(err)
 		return
 	}
+	if policy == nil {
+		ec = NoSuchBucketPolicy
+		return
+	}
 
 	var policyData []byte
 	policyData, err = json.Marshal(policy)
@@ -56,6 +63,7 @@ func (o *ObjectNode) getBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeJSON}
 	_, _ = w.Write(policyData)
 
 	return
@@ -67,7 +75,9 @@ func (o *ObjectNode) putBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 		err error
 		ec  *ErrorCode
 	)
-	defer o.errorResponse(w, r, err, ec)
+	defer func() {
+		o.errorResponse(w, r, err, ec)
+	}()
 
 	var param = ParseRequestParam(r)
 	if param.Bucket() == "" {
@@ -87,8 +97,8 @@ func (o *ObjectNode) putBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	var bytes []byte
-	bytes, err = ioutil.ReadAll(r.Body)
+	var data []byte
+	data, err = ioutil.ReadAll(r.Body)
 	if err != nil && err != io.EOF {
 		log.LogErrorf("putBucketPolicyHandler: read request body fail: requestID(%v) err(%v)", GetRequestID(r), err)
 		ec = &ErrorCode{
@@ -100,7 +110,14 @@ func (o *ObjectNode) putBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	var policy *Policy
-	policy, err = storeBucketPolicy(bytes, vol)
+	if policy, err = ParsePolicy(bytes.NewReader(data), vol.name); err != nil || policy == nil {
+		log.LogWarnf("putBucketPolicyHandler: parse policy fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		err = nil
+		ec = MalformedPolicy
+		return
+	}
+	policy, err = storeBucketPolicy(data, vol)
 	if err != nil {
 		log.LogErrorf("putBucketPolicyHandler: store policy fail: requestID(%v) err(%v)", GetRequestID(r), err)
 		ec = InternalErrorCode(err)
@@ -110,6 +127,7 @@ func (o *ObjectNode) putBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 	log.LogInfof("putBucketPolicyHandler: put bucket policy: requestID(%v) volume(%v) policy(%v)",
 		GetRequestID(r), param.Bucket(), policy)
 
+	w.WriteHeader(http.StatusNoContent)
 	return
 }
 
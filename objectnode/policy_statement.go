@@ -19,6 +19,13 @@ package objectnode
 
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/access-policy-language-overview.html
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/example-bucket-policies.html
 //https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
 
@@ -31,6 +38,25 @@ const (
 	Deny         = "Deny"
 )
 
+const (
+	PrincipalAWS = "AWS"
+)
+
+// UnmarshalJSON supports the principal of all users, which is "*" instead of a map.
+func (p *Principal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*p = Principal{PrincipalAWS: StringSet{values: map[string]null{s: void}}}
+		return nil
+	}
+	var m map[string]StringSet
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*p = m
+	return nil
+}
+
 type Statement struct {
 	Sid          string    `json:"Sid,omitempty"`
 	Effect       Effect    `json:"Effect"`
@@ -47,8 +73,35 @@ func (s *Statement) Validate(bucket string) (bool, error) {
 }
 
 func (s *Statement) isValid(bucket string) (bool, error) {
-	//TODO:
-
+	if s.Effect != Allow && s.Effect != Deny {
+		return false, fmt.Errorf("invalid effect: %v", s.Effect)
+	}
+	if len(s.Principal) == 0 {
+		return false, errors.New("principal cannot be empty")
+	}
+	if s.Actions.Empty() == s.NotActions.Empty() {
+		return false, errors.New("either action or not action must be specified")
+	}
+	if s.Resources.Empty() == s.NotResources.Empty() {
+		return false, errors.New("either resource or not resource must be specified")
+	}
+	for _, resources := range []StringSet{s.Resources, s.NotResources} {
+		for resource := range resources.values {
+			if !strings.HasPrefix(resource, S3ResourcePrefix) {
+				return false, fmt.Errorf("invalid resource: %v", resource)
+			}
+			// The resources must be the bucket or the objects of the bucket.
+			resourceBucket := strings.SplitN(strings.TrimPrefix(resource, S3ResourcePrefix), "/", 2)[0]
+			if !wildcardMatch(resourceBucket, bucket) {
+				return false, fmt.Errorf("resource is not in bucket: %v", resource)
+			}
+		}
+	}
+	for conditionType := range s.Condition {
+		if _, ok := ConditionFuncMap[conditionType]; !ok {
+			return false, fmt.Errorf("unsupported condition: %v", conditionType)
+		}
+	}
 	return true, nil
 }
 
@@ -88,19 +141,40 @@ func (s Statement) checkPrincipal(p *RequestParam) bool {
 		return true
 	}
 	for _, principal := range s.Principal {
-		if principal.ContainsWild(p.AccessKey()) {
-			return true
+		for value := range principal.values {
+			if principalMatch(value, p) {
+				return true
+			}
 		}
 	}
 
 	return false
 }
 
+// The principal is the access key or the ID of the user, or the ARN of the user as the account,
+// such as "arn:aws:iam::<user id>:root".
+func principalMatch(principal string, p *RequestParam) bool {
+	if principal == "*" || principal == p.AccessKey() {
+		return true
+	}
+	if p.userID == "" {
+		return false
+	}
+	if principal == p.userID {
+		return true
+	}
+	if strings.HasPrefix(principal, "arn:") {
+		arn, err := parseArn(principal)
+		return err == nil && arn.service == "iam" && arn.accountId == p.userID
+	}
+	return false
+}
+
 func (s Statement) checkResources(p *RequestParam) bool {
 	if s.Resources.Empty() {
 		return true
 	}
-	if s.Resources.ContainsRegex(p.resource) {
+	if resourcesMatch(s.Resources, p.resource) {
 		return true
 	}
 	return false
@@ -110,8 +184,18 @@ func (s Statement) checkNotResources(p *RequestParam) bool {
 	if s.NotResources.Empty() {
 		return true
 	}
-	if s.NotResources.ContainsRegex(p.resource) {
+	if resourcesMatch(s.NotResources, p.resource) {
 		return false
 	}
 	return true
 }
+
+// The resource of the request is the bucket, or the object path prefixed with the bucket.
+func resourcesMatch(resources StringSet, resource string) bool {
+	for pattern := range resources.values {
+		if pattern == "*" || wildcardMatch(strings.TrimPrefix(pattern, S3ResourcePrefix), resource) {
+			return true
+		}
+	}
+	return false
+}
@@ -14,6 +14,16 @@
 
 package objectnode
 
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
 /*
 
 https://docs.aws.amazon.com/zh_cn/AmazonS3/latest/dev/example-bucket-policies.html
@@ -66,3 +76,163 @@ https://docs.aws.amazon.com/zh_cn/IAM/latest/UserGuide/reference_policies_elemen
 }
 
 */
+
+func TestPolicyEvaluate(t *testing.T) {
+	var policyJSON = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "CrossAccountRead",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::reader:root"]},
+      "Action": ["s3:GetObject", "s3:ListBucket"],
+      "Resource": ["arn:aws:s3:::examplebucket", "arn:aws:s3:::examplebucket/public/*"],
+      "Condition": {"IpAddress": {"aws:SourceIp": "192.168.0.0/16"}}
+    },
+    {
+      "Sid": "DenySecret",
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": "arn:aws:s3:::examplebucket/public/secret*"
+    }
+  ]
+}`
+	policy, err := ParsePolicy(strings.NewReader(policyJSON), "examplebucket")
+	if err != nil || policy == nil {
+		t.Fatalf("parse policy fail: err(%v)", err)
+	}
+
+	var newParam = func(userID, resource, sourceIP string, action proto.Action) *RequestParam {
+		return &RequestParam{
+			resource:      resource,
+			action:        action,
+			sourceIP:      sourceIP,
+			userID:        userID,
+			accessKey:     "ak-" + userID,
+			conditionVars: map[string][]string{"SourceIp": {sourceIP}},
+		}
+	}
+	var cases = []struct {
+		param  *RequestParam
+		result int
+	}{
+		{newParam("reader", "examplebucket/public/a.txt", "192.168.1.1", proto.OSSGetObjectAction), PolicyAllow},
+		{newParam("reader", "examplebucket/public/a.txt", "192.168.1.1", proto.OSSHeadObjectAction), PolicyAllow},
+		{newParam("reader", "examplebucket", "192.168.1.1", proto.OSSListObjectsAction), PolicyAllow},
+		{newParam("reader", "examplebucket/public/a.txt", "10.0.0.1", proto.OSSGetObjectAction), PolicyDefault},
+		{newParam("reader", "examplebucket/private/a.txt", "192.168.1.1", proto.OSSGetObjectAction), PolicyDefault},
+		{newParam("reader", "examplebucket/public/a.txt", "192.168.1.1", proto.OSSPutObjectAction), PolicyDefault},
+		{newParam("other", "examplebucket/public/a.txt", "192.168.1.1", proto.OSSGetObjectAction), PolicyDefault},
+		{newParam("reader", "examplebucket/public/secret.txt", "192.168.1.1", proto.OSSGetObjectAction), PolicyDeny},
+	}
+	for i, c := range cases {
+		if result := policy.Evaluate(c.param); result != c.result {
+			t.Fatalf("case %v: expect result %v but %v", i, c.result, result)
+		}
+	}
+}
+
+func TestPolicyObjectTagCondition(t *testing.T) {
+	var policyJSON = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::examplebucket/*",
+      "Condition": {"StringEquals": {"s3:ExistingObjectTag/class": "public"}}
+    },
+    {
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["s3:PutObject", "s3:PutObjectTagging"],
+      "Resource": "arn:aws:s3:::examplebucket/*",
+      "Condition": {"StringEquals": {"s3:RequestObjectTag/project": "x"}}
+    }
+  ]
+}`
+	policy, err := ParsePolicy(strings.NewReader(policyJSON), "examplebucket")
+	if err != nil || policy == nil {
+		t.Fatalf("parse policy fail: err(%v)", err)
+	}
+	if !policy.usesObjectTags() {
+		t.Fatalf("object tag conditions not found")
+	}
+
+	var param = &RequestParam{
+		resource:      "examplebucket/a.txt",
+		action:        proto.OSSGetObjectAction,
+		conditionVars: map[string][]string{"ExistingObjectTag/class": {"public"}},
+	}
+	if result := policy.Evaluate(param); result != PolicyAllow {
+		t.Fatalf("object with the tag not allowed: %v", result)
+	}
+	param.conditionVars["ExistingObjectTag/class"] = []string{"private"}
+	if result := policy.Evaluate(param); result != PolicyDefault {
+		t.Fatalf("object without the tag allowed: %v", result)
+	}
+
+	// tags given by the header
+	r := httptest.NewRequest(http.MethodPut, "/examplebucket/a.txt", nil)
+	r.Header.Set(HeaderNameXAmzTagging, "project=x&team=y")
+	param = &RequestParam{resource: "examplebucket/a.txt", action: proto.OSSPutObjectAction, conditionVars: map[string][]string{}}
+	addObjectTagConditionValues(param, nil, r)
+	if result := policy.Evaluate(param); result != PolicyAllow {
+		t.Fatalf("request with the tag not allowed: %v", result)
+	}
+	if keys := param.conditionVars["RequestObjectTagKeys"]; len(keys) != 2 {
+		t.Fatalf("unexpected request tag keys: %v", keys)
+	}
+
+	// tags given by the body, which can be read again
+	var body = `<Tagging><TagSet><Tag><Key>project</Key><Value>z</Value></Tag></TagSet></Tagging>`
+	r = httptest.NewRequest(http.MethodPut, "/examplebucket/a.txt?tagging", strings.NewReader(body))
+	param = &RequestParam{resource: "examplebucket/a.txt", action: proto.OSSPutObjectTaggingAction, conditionVars: map[string][]string{}}
+	addObjectTagConditionValues(param, nil, r)
+	if result := policy.Evaluate(param); result != PolicyDefault {
+		t.Fatalf("request with another tag value allowed: %v", result)
+	}
+	if data, _ := ioutil.ReadAll(r.Body); string(data) != body {
+		t.Fatalf("request body not restored: %v", string(data))
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	var invalids = []string{
+		// resource of other bucket
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::other/*"}]}`,
+		// no principal
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::examplebucket/*"}]}`,
+		// invalid effect
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Permit","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::examplebucket/*"}]}`,
+		// unsupported condition
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::examplebucket/*","Condition":{"BinaryEquals":{"k":"v"}}}]}`,
+	}
+	for i, invalid := range invalids {
+		if policy, err := ParsePolicy(strings.NewReader(invalid), "examplebucket"); err == nil && policy != nil {
+			t.Fatalf("invalid policy %v accepted", i)
+		}
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	var cases = []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"*", "", true},
+		{"bucket/*", "bucket/a/b", true},
+		{"bucket/*", "bucket", false},
+		{"bucket/a?c", "bucket/abc", true},
+		{"bucket/a*c", "bucket/abbbd", false},
+		{"s3:Get*", "s3:GetObject", true},
+	}
+	for _, c := range cases {
+		if match := wildcardMatch(c.pattern, c.key); match != c.match {
+			t.Fatalf("wildcard match pattern(%v) key(%v): expect %v but %v", c.pattern, c.key, c.match, match)
+		}
+	}
+}
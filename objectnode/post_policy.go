@@ -0,0 +1,380 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/gorilla/mux"
+)
+
+// The objects are uploaded by the HTML forms of the browsers in the POST requests of multipart/form-data,
+// of which the fields before the file give the key, the metadata of the object and the policy document
+// signed by the user. The policy document limits the fields of the form and the size of the file, and
+// the user signing it is authorized to put the object as PutObject.
+// Reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html
+
+const (
+	PostFormFieldKey                   = "key"
+	PostFormFieldFile                  = "file"
+	PostFormFieldPolicy                = "policy"
+	PostFormFieldBucket                = "bucket"
+	PostFormFieldSignature             = "signature"      // signature V2
+	PostFormFieldAccessKeyId           = "awsaccesskeyid" // signature V2
+	PostFormFieldSuccessActionRedirect = "success_action_redirect"
+	PostFormFieldRedirect              = "redirect"
+	PostFormFieldSuccessActionStatus   = "success_action_status"
+	PostFormFieldACL                   = "acl"
+	PostFormIgnoredPrefix              = "x-ignore-"
+
+	PostPolicyConditionEq                 = "eq"
+	PostPolicyConditionStartsWith         = "starts-with"
+	PostPolicyConditionContentLengthRange = "content-length-range"
+
+	postFileNameVariable = "${filename}"
+	maxPostFormSize      = 20 * 1024 // the total size of the fields before the file
+)
+
+var (
+	errMalformedPostForm     = errors.New("malformed POST form")
+	errMissingPostFile       = errors.New("no file in POST form")
+	errPostFormTooLarge      = errors.New("POST form too large")
+	errPostEntityTooSmall    = errors.New("POST file smaller than the policy allows")
+	errPostEntityTooLarge    = errors.New("POST file larger than the policy allows")
+	errInvalidPolicyDocument = errors.New("invalid policy document")
+)
+
+// the fields which are not required to be given by the conditions of the policy
+var postPolicyExemptFields = map[string]bool{
+	PostFormFieldFile:        true,
+	PostFormFieldPolicy:      true,
+	PostFormFieldSignature:   true,
+	PostFormFieldAccessKeyId: true,
+	"x-amz-signature":        true,
+}
+
+// postForm is the form of the POST request, of which the file is read after the fields.
+type postForm struct {
+	values   map[string]string // the fields before the file by the lower cased names
+	file     io.Reader
+	fileName string
+	fileType string // the content type of the file part
+}
+
+// parsePostForm reads the fields of the form until the file.
+func parsePostForm(r *http.Request) (form *postForm, err error) {
+	var reader, readerErr = r.MultipartReader()
+	if readerErr != nil {
+		return nil, errMalformedPostForm
+	}
+	form = &postForm{values: make(map[string]string)}
+	var size int
+	for {
+		var part, partErr = reader.NextPart()
+		if partErr == io.EOF {
+			return nil, errMissingPostFile
+		}
+		if partErr != nil {
+			return nil, errMalformedPostForm
+		}
+		var name = strings.ToLower(part.FormName())
+		if name == "" {
+			continue
+		}
+		if name == PostFormFieldFile {
+			form.file = part
+			form.fileName = part.FileName()
+			form.fileType = part.Header.Get(HeaderNameContentType)
+			return form, nil
+		}
+		var value []byte
+		if value, err = ioutil.ReadAll(io.LimitReader(part, int64(maxPostFormSize-size+1))); err != nil {
+			return nil, errMalformedPostForm
+		}
+		if size += len(value); size > maxPostFormSize {
+			return nil, errPostFormTooLarge
+		}
+		form.values[name] = string(value)
+	}
+}
+
+func (form *postForm) value(name string) string {
+	return form.values[name]
+}
+
+// key returns the key of the object, of which the variable ${filename} is replaced by the name of the file.
+func (form *postForm) key() string {
+	return strings.Replace(form.values[PostFormFieldKey], postFileNameVariable, form.fileName, -1)
+}
+
+// header returns the fields of the form as the headers of PutObject, such as Content-Type and x-amz-meta-*.
+func (form *postForm) header() http.Header {
+	var header = make(http.Header)
+	for name, value := range form.values {
+		header.Set(name, value)
+	}
+	if header.Get(HeaderNameContentType) == "" && form.fileType != "" {
+		header.Set(HeaderNameContentType, form.fileType)
+	}
+	return header
+}
+
+type postPolicyCondition struct {
+	operator string
+	field    string // the lower cased name of the field without "$"
+	value    string
+	min, max int64 // content-length-range
+}
+
+// postPolicy is the policy document of the POST form.
+type postPolicy struct {
+	expiration time.Time
+	conditions []*postPolicyCondition
+}
+
+// parsePostPolicy parses the policy document encoded in base64, such as
+//   {"expiration": "2007-12-01T12:00:00.000Z",
+//    "conditions": [{"bucket": "johnsmith"}, ["starts-with", "$key", "user/eric/"], ["content-length-range", 0, 1048576]]}
+func parsePostPolicy(encoded string) (policy *postPolicy, err error) {
+	var data []byte
+	if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+		return nil, errInvalidPolicyDocument
+	}
+	var document struct {
+		Expiration string        `json:"expiration"`
+		Conditions []interface{} `json:"conditions"`
+	}
+	var decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&document); err != nil {
+		return nil, errInvalidPolicyDocument
+	}
+	policy = &postPolicy{}
+	if policy.expiration, err = time.Parse(time.RFC3339Nano, document.Expiration); err != nil {
+		return nil, errInvalidPolicyDocument
+	}
+	for _, item := range document.Conditions {
+		var condition *postPolicyCondition
+		if condition, err = parsePostPolicyCondition(item); err != nil {
+			return nil, err
+		}
+		policy.conditions = append(policy.conditions, condition)
+	}
+	return
+}
+
+func parsePostPolicyCondition(item interface{}) (condition *postPolicyCondition, err error) {
+	switch typed := item.(type) {
+	case map[string]interface{}:
+		// {"field": "value"} is the exact match of the field
+		if len(typed) != 1 {
+			return nil, errInvalidPolicyDocument
+		}
+		for field, value := range typed {
+			var str, is = value.(string)
+			if !is {
+				return nil, errInvalidPolicyDocument
+			}
+			condition = &postPolicyCondition{operator: PostPolicyConditionEq, field: strings.ToLower(field), value: str}
+		}
+		return
+	case []interface{}:
+		if len(typed) != 3 {
+			return nil, errInvalidPolicyDocument
+		}
+		var operator, _ = typed[0].(string)
+		switch operator = strings.ToLower(operator); operator {
+		case PostPolicyConditionEq, PostPolicyConditionStartsWith:
+			var field, isField = typed[1].(string)
+			var value, isValue = typed[2].(string)
+			if !isField || !isValue || !strings.HasPrefix(field, "$") {
+				return nil, errInvalidPolicyDocument
+			}
+			return &postPolicyCondition{operator: operator, field: strings.ToLower(field[1:]), value: value}, nil
+		case PostPolicyConditionContentLengthRange:
+			var min, minErr = parsePostPolicyNumber(typed[1])
+			var max, maxErr = parsePostPolicyNumber(typed[2])
+			if minErr != nil || maxErr != nil || min < 0 || min > max {
+				return nil, errInvalidPolicyDocument
+			}
+			return &postPolicyCondition{operator: operator, min: min, max: max}, nil
+		}
+	}
+	return nil, errInvalidPolicyDocument
+}
+
+func parsePostPolicyNumber(value interface{}) (int64, error) {
+	if number, is := value.(json.Number); is {
+		return number.Int64()
+	}
+	return 0, errInvalidPolicyDocument
+}
+
+// check checks the fields of the form by the conditions, and returns the message of the violation.
+func (p *postPolicy) check(form *postForm, bucket string, now time.Time) string {
+	if now.After(p.expiration) {
+		return "Invalid according to Policy: Policy expired."
+	}
+	var conditioned = make(map[string]bool)
+	for _, condition := range p.conditions {
+		if condition.operator == PostPolicyConditionContentLengthRange {
+			continue
+		}
+		conditioned[condition.field] = true
+		var value = form.value(condition.field)
+		if condition.field == PostFormFieldBucket {
+			value = bucket
+		}
+		if condition.operator == PostPolicyConditionEq && value != condition.value ||
+			condition.operator == PostPolicyConditionStartsWith && !strings.HasPrefix(value, condition.value) {
+			return fmt.Sprintf("Invalid according to Policy: Policy Condition failed: [\"%v\", \"$%v\", \"%v\"]",
+				condition.operator, condition.field, condition.value)
+		}
+	}
+	for name := range form.values {
+		if !postPolicyExemptFields[name] && !strings.HasPrefix(name, PostFormIgnoredPrefix) && !conditioned[name] {
+			return fmt.Sprintf("Invalid according to Policy: Extra input fields: %v", name)
+		}
+	}
+	return ""
+}
+
+// contentLengthRange returns the range of the size of the file, which is unlimited if not given.
+func (p *postPolicy) contentLengthRange() (min, max int64) {
+	min, max = 0, -1
+	for _, condition := range p.conditions {
+		if condition.operator == PostPolicyConditionContentLengthRange {
+			min, max = condition.min, condition.max
+		}
+	}
+	return
+}
+
+// postFileReader limits the size of the file by the content-length-range of the policy.
+type postFileReader struct {
+	io.Reader
+	min, max int64 // max is -1 if unlimited
+	size     int64
+}
+
+func (r *postFileReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.size += int64(n)
+	if r.max >= 0 && r.size > r.max {
+		return n, errPostEntityTooLarge
+	}
+	if err == io.EOF && r.size < r.min {
+		return n, errPostEntityTooSmall
+	}
+	return
+}
+
+// verifyPostSignature verifies the signature of the policy document in the form, and returns the
+// access key of the user signing it. The signature is given in V4 by x-amz-algorithm, x-amz-credential
+// and x-amz-signature, or in V2 by AWSAccessKeyId and signature.
+func (o *ObjectNode) verifyPostSignature(r *http.Request, form *postForm) (accessKey string, errorCode *ErrorCode) {
+	var policy = form.value(PostFormFieldPolicy)
+	var expected, signature string
+	if algorithm := form.value(strings.ToLower(XAmzAlgorithm)); algorithm != "" {
+		if algorithm != SignatureV4Algorithm {
+			return "", InvalidArgument
+		}
+		var req = &signatureRequestV4{}
+		if err := req.parseCredential(form.value(strings.ToLower(XAmzCredential))); err != nil {
+			return "", InvalidArgument
+		}
+		var secretKey, found, err = o.postSecretKey(r, req.Credential.AccessKey)
+		if err != nil {
+			return "", InternalErrorCode(err)
+		}
+		if !found {
+			return "", InvalidAccessKeyId
+		}
+		var cred = req.Credential
+		var signingKey = buildSigningKey(SCHEME, secretKey, cred.Date, cred.Region, cred.Service, TERMINATOR)
+		accessKey, expected = cred.AccessKey, hex.EncodeToString(sign(policy, signingKey))
+		signature = form.value(strings.ToLower(XAmzSignature))
+	} else {
+		accessKey = form.value(PostFormFieldAccessKeyId)
+		var secretKey, found, err = o.postSecretKey(r, accessKey)
+		if err != nil {
+			return "", InternalErrorCode(err)
+		}
+		if !found {
+			return "", InvalidAccessKeyId
+		}
+		var hm = hmac.New(sha1.New, []byte(secretKey))
+		hm.Write([]byte(policy))
+		expected = base64.StdEncoding.EncodeToString(hm.Sum(nil))
+		signature = form.value(PostFormFieldSignature)
+	}
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", SignatureDoesNotMatch
+	}
+	return accessKey, nil
+}
+
+// postSecretKey returns the secret key of the user of the access key, or of the bucket as the
+// signatures of the headers are verified.
+func (o *ObjectNode) postSecretKey(r *http.Request, accessKey string) (secretKey string, found bool, err error) {
+	if accessKey == "" {
+		return "", false, nil
+	}
+	var userInfo *proto.UserInfo
+	if userInfo, err = o.getRequestUserInfo(r, accessKey); err == nil {
+		return userInfo.SecretKey, true, nil
+	}
+	if err != proto.ErrUserNotExists && err != proto.ErrAccessKeyNotExists {
+		return "", false, err
+	}
+	var volume *Volume
+	if volume, err = o.getVol(mux.Vars(r)["bucket"]); err != nil {
+		return "", false, err
+	}
+	if ak, sk := volume.OSSSecure(); ak == accessKey {
+		return sk, true, nil
+	}
+	return "", false, nil
+}
+
+// PostResponse is the result of the POST object given success_action_status 201.
+type PostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// isPostObjectRequest returns whether the request uploads the object by the form, of which the
+// signature and the policy are verified by the handler.
+func isPostObjectRequest(r *http.Request) bool {
+	var route = mux.CurrentRoute(r)
+	return r.Method == http.MethodPost && route != nil && ActionFromRouteName(route.GetName()) == proto.OSSPutObjectAction
+}
@@ -0,0 +1,236 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/gorilla/mux"
+)
+
+// Post object
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html
+func (o *ObjectNode) postObjectHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		errorCode = NoSuchBucket
+		return
+	}
+
+	var form *postForm
+	if form, err = parsePostForm(r); err != nil {
+		log.LogWarnf("postObjectHandler: parse form fail: requestID(%v) volume(%v) remote(%v) err(%v)",
+			GetRequestID(r), vol.Name(), getRequestIP(r), err)
+		switch err {
+		case errMissingPostFile:
+			errorCode = MissingPostFile
+		case errPostFormTooLarge:
+			errorCode = MaxPostPreDataLengthExceeded
+		default:
+			errorCode = MalformedPOSTRequest
+		}
+		return
+	}
+	var key = form.key()
+	if key == "" {
+		errorCode = MissingPostKey
+		return
+	}
+
+	// The form without the policy is anonymous, and the signed form acts as the user signing the
+	// policy, which are authorized to put the object by the policy check alike.
+	var policy *postPolicy
+	var accessKey string
+	if encoded := form.value(PostFormFieldPolicy); encoded != "" {
+		if policy, err = parsePostPolicy(encoded); err != nil {
+			errorCode = InvalidPolicyDocument
+			return
+		}
+		if token := form.value(HeaderNameXAmzSecurityToken); token != "" {
+			r.Header.Set(HeaderNameXAmzSecurityToken, token)
+		}
+		if accessKey, errorCode = o.verifyPostSignature(r, form); errorCode != nil {
+			log.LogWarnf("postObjectHandler: verify signature fail: requestID(%v) volume(%v) remote(%v) err(%v)",
+				GetRequestID(r), vol.Name(), getRequestIP(r), errorCode.ErrorCode)
+			return
+		}
+		if message := policy.check(form, vol.Name(), time.Now()); message != "" {
+			log.LogWarnf("postObjectHandler: policy check fail: requestID(%v) volume(%v) remote(%v) reason(%v)",
+				GetRequestID(r), vol.Name(), getRequestIP(r), message)
+			errorCode = &ErrorCode{ErrorCode: AccessDenied.ErrorCode, ErrorMessage: message, StatusCode: AccessDenied.StatusCode}
+			return
+		}
+	}
+	mux.Vars(r)["object"] = key
+	SetPostAccessKey(r, accessKey)
+
+	o.policyCheck(func(w http.ResponseWriter, r *http.Request) {
+		o.putPostObject(w, r, vol, form, key, policy)
+	})(w, r)
+	return
+}
+
+// putPostObject writes the file of the form as the object, once the request is authorized.
+func (o *ObjectNode) putPostObject(w http.ResponseWriter, r *http.Request, vol *Volume, form *postForm, key string, policy *postPolicy) {
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var header = form.header()
+	var opt = &PutFileOption{
+		MIMEType:     header.Get(HeaderNameContentType),
+		Disposition:  header.Get(HeaderNameContentDisposition),
+		Metadata:     ParseUserDefinedMetadata(header),
+		CacheControl: header.Get(HeaderNameCacheControl),
+		Expires:      header.Get(HeaderNameExpires),
+	}
+	if len(opt.CacheControl) > 0 && !ValidateCacheControl(opt.CacheControl) ||
+		len(opt.Expires) > 0 && !ValidateCacheExpires(opt.Expires) {
+		errorCode = InvalidCacheArgument
+		return
+	}
+
+	// The default retention of the bucket applies to the objects of the form
+	var lockOpt *ObjectLockOption
+	if lockOpt, errorCode = parseObjectLockOption(r, vol); errorCode != nil {
+		return
+	}
+	if opt.ACL, errorCode = parseObjectStandardACL(ParseRequestParam(r), vol, form.value(PostFormFieldACL)); errorCode != nil {
+		return
+	}
+
+	// Checking bucket quota by the size of the form
+	if size := requestContentLength(r); !vol.quota.allow(size) {
+		log.LogWarnf("postObjectHandler: bucket quota exceeded: requestID(%v) volume(%v) path(%v) size(%v)",
+			GetRequestID(r), vol.Name(), key, size)
+		errorCode = QuotaExceeded
+		return
+	}
+
+	var file = &postFileReader{Reader: form.file, max: -1}
+	if policy != nil {
+		file.min, file.max = policy.contentLengthRange()
+	}
+
+	// Audit file write
+	log.LogInfof("Audit: post object: requestID(%v) remote(%v) volume(%v) path(%v) type(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), key, opt.MIMEType)
+
+	var fsFileInfo *FSFileInfo
+	fsFileInfo, err = vol.PutObject(key, file, opt)
+	switch {
+	case err == syscall.EINVAL:
+		errorCode = ObjectModeConflict
+		return
+	case err == errPostEntityTooLarge:
+		errorCode = EntityTooLarge
+		return
+	case err == errPostEntityTooSmall:
+		errorCode = EntityTooSmall
+		return
+	case err == io.ErrUnexpectedEOF:
+		errorCode = MalformedPOSTRequest
+		return
+	case err != nil:
+		log.LogErrorf("postObjectHandler: put object fail: requestId(%v) volume(%v) path(%v) remote(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, getRequestIP(r), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	vol.quota.consume(uint64(fsFileInfo.Size))
+
+	if lockOpt != nil {
+		if err = vol.setObjectLock(fsFileInfo.Inode, lockOpt); err != nil {
+			log.LogErrorf("postObjectHandler: place object lock fail: requestID(%v) volume(%v) path(%v) err(%v)",
+				GetRequestID(r), vol.Name(), key, err)
+			errorCode = InternalErrorCode(err)
+			return
+		}
+		lockOpt.fillFileInfo(fsFileInfo)
+	}
+
+	o.notifyObjectEvent(r, vol, EventObjectCreatedPost, newNotificationObject(key, fsFileInfo))
+	o.replicateObject(r, vol, key, fsFileInfo)
+
+	var etag = wrapUnescapedQuot(fsFileInfo.ETag)
+	w.Header()[HeaderNameETag] = []string{etag}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{displayVersionId(fsFileInfo.VersionId)}
+	}
+
+	// The browser is redirected with the bucket, the key and the ETag of the object if the
+	// redirection is given, or else the status of the response is given by success_action_status.
+	var redirect = form.value(PostFormFieldSuccessActionRedirect)
+	if redirect == "" {
+		redirect = form.value(PostFormFieldRedirect)
+	}
+	if location, parseErr := url.Parse(redirect); redirect != "" && parseErr == nil {
+		var query = location.Query()
+		query.Set("bucket", vol.Name())
+		query.Set("key", key)
+		query.Set("etag", etag)
+		location.RawQuery = query.Encode()
+		w.Header()[HeaderNameLocation] = []string{location.String()}
+		w.WriteHeader(http.StatusSeeOther)
+		return
+	}
+	switch form.value(PostFormFieldSuccessActionStatus) {
+	case strconv.Itoa(http.StatusOK):
+		w.Header()[HeaderNameContentLength] = []string{"0"}
+		w.WriteHeader(http.StatusOK)
+	case strconv.Itoa(http.StatusCreated):
+		var scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		var location = scheme + "://" + r.Host + strings.TrimSuffix(r.URL.Path, "/") + "/" + url.PathEscape(key)
+		var data []byte
+		if data, err = MarshalXMLEntity(&PostResponse{Location: location, Bucket: vol.Name(), Key: key, ETag: etag}); err != nil {
+			errorCode = InternalErrorCode(err)
+			return
+		}
+		w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+		w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPostFormRequest(t *testing.T, fields [][2]string, file string) *http.Request {
+	var body bytes.Buffer
+	var writer = multipart.NewWriter(&body)
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			t.Fatalf("write field fail: err(%v)", err)
+		}
+	}
+	if file != "" {
+		part, err := writer.CreateFormFile("file", "photo.jpg")
+		if err != nil {
+			t.Fatalf("create file fail: err(%v)", err)
+		}
+		_, _ = part.Write([]byte(file))
+	}
+	_ = writer.Close()
+	var r = httptest.NewRequest(http.MethodPost, "/photos", &body)
+	r.Header.Set(HeaderNameContentType, writer.FormDataContentType())
+	return r
+}
+
+func TestParsePostForm(t *testing.T) {
+	var r = newPostFormRequest(t, [][2]string{
+		{"key", "user/${filename}"},
+		{"Content-Type", "image/jpeg"},
+		{"x-amz-meta-uuid", "14365123651274"},
+	}, "hello")
+	var form, err = parsePostForm(r)
+	if err != nil {
+		t.Fatalf("parse form fail: err(%v)", err)
+	}
+	if key := form.key(); key != "user/photo.jpg" {
+		t.Fatalf("key mismatch: actual(%v)", key)
+	}
+	var header = form.header()
+	if header.Get(HeaderNameContentType) != "image/jpeg" || ParseUserDefinedMetadata(header)["uuid"] != "14365123651274" {
+		t.Fatalf("header mismatch: actual(%v)", header)
+	}
+	if data, _ := ioutil.ReadAll(form.file); string(data) != "hello" {
+		t.Fatalf("file mismatch: actual(%v)", string(data))
+	}
+
+	if _, err = parsePostForm(newPostFormRequest(t, [][2]string{{"key", "a"}}, "")); err != errMissingPostFile {
+		t.Fatalf("form without file should be rejected: err(%v)", err)
+	}
+	var large = strings.Repeat("a", maxPostFormSize)
+	if _, err = parsePostForm(newPostFormRequest(t, [][2]string{{"key", "a"}, {"x-ignore-a", large}}, "hello")); err != errPostFormTooLarge {
+		t.Fatalf("large form should be rejected: err(%v)", err)
+	}
+}
+
+func TestPostPolicyCheck(t *testing.T) {
+	var document = `{"expiration": "2030-01-01T12:00:00.000Z",
+		"conditions": [{"bucket": "photos"}, ["starts-with", "$key", "user/"], {"acl": "public-read"},
+			["eq", "$Content-Type", "image/jpeg"], ["content-length-range", 1, 10]]}`
+	var policy, err = parsePostPolicy(base64.StdEncoding.EncodeToString([]byte(document)))
+	if err != nil {
+		t.Fatalf("parse policy fail: err(%v)", err)
+	}
+	if min, max := policy.contentLengthRange(); min != 1 || max != 10 {
+		t.Fatalf("content length range mismatch: min(%v) max(%v)", min, max)
+	}
+
+	var now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var values = func(extra ...string) map[string]string {
+		var values = map[string]string{"key": "user/a.jpg", "acl": "public-read", "content-type": "image/jpeg",
+			"policy": "...", "x-amz-signature": "...", "x-ignore-id": "1"}
+		for i := 0; i+1 < len(extra); i += 2 {
+			values[extra[i]] = extra[i+1]
+		}
+		return values
+	}
+	var cases = []struct {
+		values  map[string]string
+		bucket  string
+		now     time.Time
+		allowed bool
+	}{
+		{values(), "photos", now, true},
+		{values(), "photos", now.AddDate(20, 0, 0), false},
+		{values(), "videos", now, false},
+		{values("key", "admin/a.jpg"), "photos", now, false},
+		{values("acl", "private"), "photos", now, false},
+		{values("x-amz-meta-uuid", "1"), "photos", now, false},
+	}
+	for i, c := range cases {
+		var message = policy.check(&postForm{values: c.values}, c.bucket, c.now)
+		if (message == "") != c.allowed {
+			t.Fatalf("case %v: check result mismatch: expect allowed(%v) actual(%v)", i, c.allowed, message)
+		}
+	}
+
+	for _, invalid := range []string{
+		`{"conditions": []}`,
+		`{"expiration": "2030-01-01T12:00:00.000Z", "conditions": [["starts-with", "key", "user/"]]}`,
+		`{"expiration": "2030-01-01T12:00:00.000Z", "conditions": [["content-length-range", 10, 1]]}`,
+		`{"expiration": "2030-01-01T12:00:00.000Z", "conditions": [["matches", "$key", "user/"]]}`,
+		`{"expiration": "2030-01-01T12:00:00.000Z", "conditions": [{"bucket": "photos", "key": "a"}]}`,
+	} {
+		if _, err = parsePostPolicy(base64.StdEncoding.EncodeToString([]byte(invalid))); err == nil {
+			t.Fatalf("invalid policy should be rejected: policy(%v)", invalid)
+		}
+	}
+}
+
+func TestPostFileReader(t *testing.T) {
+	var cases = []struct {
+		data     string
+		min, max int64
+		err      error
+	}{
+		{"hello", 1, 10, nil},
+		{"hello", 0, -1, nil},
+		{"hello", 6, 10, errPostEntityTooSmall},
+		{"hello", 1, 4, errPostEntityTooLarge},
+	}
+	for i, c := range cases {
+		var reader = &postFileReader{Reader: strings.NewReader(c.data), min: c.min, max: c.max}
+		if _, err := ioutil.ReadAll(reader); err != c.err {
+			t.Fatalf("case %v: error mismatch: expect(%v) actual(%v)", i, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	BucketQuotaUpdateDuration = 10 * time.Second
+)
+
+// bucketQuota caches the object quota of a bucket set on the master and the usage reported by the
+// meta partitions. The usage reported lags behind the writes, so the bytes and the objects written
+// through this node since the last update are counted locally until the next update.
+type bucketQuota struct {
+	maxBytes     uint64 // 0 for no limit
+	maxCount     uint64 // 0 for no limit
+	usedBytes    uint64
+	usedCount    uint64
+	pendingBytes uint64
+	pendingCount uint64
+	mu           sync.RWMutex
+}
+
+func (q *bucketQuota) update(maxBytes, maxCount, usedBytes, usedCount uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxBytes, q.maxCount = maxBytes, maxCount
+	q.usedBytes, q.usedCount = usedBytes, usedCount
+	q.pendingBytes, q.pendingCount = 0, 0
+}
+
+// allow returns whether the bucket can store one more object of the size without exceeding the quota.
+func (q *bucketQuota) allow(size uint64) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.maxBytes > 0 && q.usedBytes+q.pendingBytes+size > q.maxBytes {
+		return false
+	}
+	if q.maxCount > 0 && q.usedCount+q.pendingCount+1 > q.maxCount {
+		return false
+	}
+	return true
+}
+
+// consume counts an object written through this node until the next update.
+func (q *bucketQuota) consume(size uint64) {
+	q.mu.Lock()
+	q.pendingBytes += size
+	q.pendingCount++
+	q.mu.Unlock()
+}
+
+func (v *Volume) syncQuota() {
+	v.loadQuota()
+	var ticker = time.NewTicker(BucketQuotaUpdateDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			v.loadQuota()
+		case <-v.closeCh:
+			return
+		}
+	}
+}
+
+func (v *Volume) loadQuota() {
+	var err error
+	var view *proto.SimpleVolView
+	if view, err = v.mc.AdminAPI().GetVolumeSimpleInfo(v.name); err != nil {
+		log.LogWarnf("loadQuota: get volume info fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	var usedBytes, usedCount uint64
+	if view.ObjQuotaBytes > 0 || view.ObjQuotaCount > 0 {
+		var usages []*proto.VolQuotaUsage
+		if usages, err = v.mc.ClientAPI().GetVolumeQuotaUsage(v.name); err != nil {
+			log.LogWarnf("loadQuota: get volume quota usage fail: volume(%v) err(%v)", v.name, err)
+			return
+		}
+		for _, usage := range usages {
+			if usage.Name == v.name {
+				usedBytes, usedCount = usage.FileSize, usage.InodeCount
+			}
+		}
+	}
+	v.quota.update(view.ObjQuotaBytes, view.ObjQuotaCount, usedBytes, usedCount)
+}
+
+// requestContentLength returns the length of the object data carried by the request,
+// 0 if it is unknown.
+func requestContentLength(r *http.Request) uint64 {
+	if decoded := r.Header.Get(HeaderNameXAmzDecodeContentLength); decoded != "" {
+		if length, err := strconv.ParseUint(decoded, 10, 64); err == nil {
+			return length
+		}
+	}
+	if r.ContentLength > 0 {
+		return uint64(r.ContentLength)
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBucketQuota(t *testing.T) {
+	var quota = new(bucketQuota)
+	if !quota.allow(1 << 40) {
+		t.Fatalf("bucket without quota should allow any object")
+	}
+
+	quota.update(100, 3, 60, 1)
+	var cases = []struct {
+		size  uint64
+		allow bool
+	}{
+		{40, true},
+		{41, false},
+		{0, true},
+	}
+	for i, c := range cases {
+		if allow := quota.allow(c.size); allow != c.allow {
+			t.Fatalf("case %v: allow mismatch: expect(%v) actual(%v)", i, c.allow, allow)
+		}
+	}
+
+	// the objects written since the last update are counted as well
+	quota.consume(10)
+	if quota.allow(31) {
+		t.Fatalf("bytes quota should be exceeded by pending bytes")
+	}
+	quota.consume(0)
+	if quota.allow(0) {
+		t.Fatalf("count quota should be exceeded by pending objects")
+	}
+
+	// the update replaces the pending usage
+	quota.update(100, 3, 70, 2)
+	if !quota.allow(30) || quota.allow(31) {
+		t.Fatalf("quota mismatch after update")
+	}
+	quota.update(0, 0, 70, 2)
+	if !quota.allow(1 << 40) {
+		t.Fatalf("removed quota should allow any object")
+	}
+}
+
+func TestRequestContentLength(t *testing.T) {
+	var r = httptest.NewRequest("PUT", "/bucket/key", strings.NewReader("hello"))
+	if length := requestContentLength(r); length != 5 {
+		t.Fatalf("content length mismatch: %v", length)
+	}
+	r.Header.Set(HeaderNameXAmzDecodeContentLength, "3")
+	if length := requestContentLength(r); length != 3 {
+		t.Fatalf("decoded content length mismatch: %v", length)
+	}
+	r = httptest.NewRequest("PUT", "/bucket/key", nil)
+	r.ContentLength = -1
+	if length := requestContentLength(r); length != 0 {
+		t.Fatalf("unknown content length mismatch: %v", length)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// the limit of the access keys without their own limits
+	rateLimitDefaultAccessKey = "*"
+
+	// the requests are rejected if the bandwidth of the access key is used up for longer
+	rateLimitMaxDelay = time.Second
+
+	rateLimitThrottledCounter = "throttled_requests"
+	rateLimitTypeQPS          = "qps"
+	rateLimitTypeBandwidth    = "bandwidth"
+	rateLimitTypeClusterQPS   = "clusterQPS"
+)
+
+// rateLimitConfig is the limit of the requests of an access key, the zero values are unlimited.
+type rateLimitConfig struct {
+	AccessKey string  `json:"accessKey"`
+	QPS       float64 `json:"qps"`
+	Bandwidth int64   `json:"bandwidth"` // bytes per second of the uploads and the downloads
+}
+
+// parseRateLimits parses the limits of the "rateLimits" configuration.
+func parseRateLimits(items []interface{}) (configs []*rateLimitConfig, err error) {
+	if len(items) == 0 {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(items); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid rate limits: %v", err)
+	}
+	return
+}
+
+type accessKeyLimiter struct {
+	qps       *rate.Limiter // nil if unlimited
+	bandwidth *rate.Limiter // nil if unlimited
+}
+
+// rateLimiter limits the QPS and the bandwidth of each access key, the requests exceeding the
+// limits are rejected with SlowDown so that the clients back off. The anonymous requests share
+// the limiter of the empty access key.
+type rateLimiter struct {
+	sync.Mutex
+	configs  map[string]*rateLimitConfig
+	limiters map[string]*accessKeyLimiter
+}
+
+func newRateLimiter(configs []*rateLimitConfig) (l *rateLimiter, err error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	l = &rateLimiter{
+		configs:  make(map[string]*rateLimitConfig),
+		limiters: make(map[string]*accessKeyLimiter),
+	}
+	for _, config := range configs {
+		if config.QPS < 0 || config.Bandwidth < 0 {
+			return nil, fmt.Errorf("invalid rate limit of access key: %v", config.AccessKey)
+		}
+		if _, exist := l.configs[config.AccessKey]; exist {
+			return nil, fmt.Errorf("duplicate rate limit of access key: %v", config.AccessKey)
+		}
+		l.configs[config.AccessKey] = config
+	}
+	return
+}
+
+// limiter returns the limiter of the access key, which is nil if the access key is unlimited.
+func (l *rateLimiter) limiter(accessKey string) *accessKeyLimiter {
+	if l == nil {
+		return nil
+	}
+	l.Lock()
+	defer l.Unlock()
+	if limiter, exist := l.limiters[accessKey]; exist {
+		return limiter
+	}
+	var config = l.configs[accessKey]
+	if config == nil {
+		config = l.configs[rateLimitDefaultAccessKey]
+	}
+	var limiter *accessKeyLimiter
+	if config != nil && (config.QPS > 0 || config.Bandwidth > 0) {
+		limiter = &accessKeyLimiter{}
+		if config.QPS > 0 {
+			var burst = int(config.QPS)
+			if burst < 1 {
+				burst = 1
+			}
+			limiter.qps = rate.NewLimiter(rate.Limit(config.QPS), burst)
+		}
+		if config.Bandwidth > 0 {
+			limiter.bandwidth = rate.NewLimiter(rate.Limit(config.Bandwidth), int(config.Bandwidth))
+		}
+	}
+	l.limiters[accessKey] = limiter
+	return limiter
+}
+
+// allow returns the type of the limit exceeded by the request, or empty if the request is allowed.
+func (l *accessKeyLimiter) allow() string {
+	if l.qps != nil && !l.qps.Allow() {
+		return rateLimitTypeQPS
+	}
+	if l.bandwidth != nil {
+		var now = time.Now()
+		var reservation = l.bandwidth.ReserveN(now, 1)
+		var delay = reservation.DelayFrom(now)
+		reservation.CancelAt(now)
+		if delay > rateLimitMaxDelay {
+			return rateLimitTypeBandwidth
+		}
+	}
+	return ""
+}
+
+// waitBandwidth waits until the bytes are allowed by the bandwidth limit.
+func waitBandwidth(ctx context.Context, limiter *rate.Limiter, n int) (err error) {
+	for n > 0 {
+		var size = n
+		if size > limiter.Burst() {
+			size = limiter.Burst()
+		}
+		if err = limiter.WaitN(ctx, size); err != nil {
+			return
+		}
+		n -= size
+	}
+	return
+}
+
+// throttledReader throttles the uploads by the bandwidth limit of the ObjectNode, and the bandwidth
+// limit of the cluster if the cluster limiter is not nil.
+type throttledReader struct {
+	io.ReadCloser
+	ctx        context.Context
+	limiter    *rate.Limiter // nil if unlimited
+	cluster    *ratelimit.Limiter
+	clusterKey string
+}
+
+func (r *throttledReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := waitThrottled(r.ctx, r.limiter, r.cluster, r.clusterKey, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return
+}
+
+// throttledWriter throttles the downloads by the bandwidth limit of the ObjectNode, and the bandwidth
+// limit of the cluster if the cluster limiter is not nil.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx        context.Context
+	limiter    *rate.Limiter // nil if unlimited
+	cluster    *ratelimit.Limiter
+	clusterKey string
+}
+
+func (w *throttledWriter) Write(p []byte) (n int, err error) {
+	if err = waitThrottled(w.ctx, w.limiter, w.cluster, w.clusterKey, len(p)); err != nil {
+		return
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func waitThrottled(ctx context.Context, limiter *rate.Limiter, cluster *ratelimit.Limiter, clusterKey string, n int) (err error) {
+	if limiter != nil {
+		if err = waitBandwidth(ctx, limiter, n); err != nil {
+			return
+		}
+	}
+	return cluster.WaitN(ctx, clusterKey, n)
+}
+
+// Flush is required by the event stream of SelectObjectContent.
+func (w *throttledWriter) Flush() {
+	if flusher, is := w.ResponseWriter.(http.Flusher); is {
+		flusher.Flush()
+	}
+}
+
+func exportThrottledRequest(accessKey, limitType string) {
+	exporter.NewCounter(rateLimitThrottledCounter).AddWithLabels(1, map[string]string{
+		"accessKey": accessKey,
+		"limit":     limitType,
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	var items = []interface{}{
+		map[string]interface{}{"accessKey": "*", "qps": 10},
+		map[string]interface{}{"accessKey": "AK", "qps": 1, "bandwidth": 1024},
+	}
+	configs, err := parseRateLimits(items)
+	if err != nil || len(configs) != 2 || configs[1].AccessKey != "AK" || configs[1].Bandwidth != 1024 {
+		t.Fatalf("parse rate limits mismatch: configs(%v) err(%v)", configs, err)
+	}
+	if _, err = newRateLimiter(append(configs, &rateLimitConfig{AccessKey: "AK"})); err == nil {
+		t.Fatalf("expect duplicate rate limit error")
+	}
+	if _, err = newRateLimiter([]*rateLimitConfig{{AccessKey: "AK", QPS: -1}}); err == nil {
+		t.Fatalf("expect invalid rate limit error")
+	}
+	if _, err = parseRateLimits([]interface{}{map[string]interface{}{"qps": "fast"}}); err == nil {
+		t.Fatalf("expect parsing error")
+	}
+}
+
+func TestRateLimiterLimiter(t *testing.T) {
+	var l *rateLimiter
+	if l.limiter("AK") != nil {
+		t.Fatalf("expect no limiter if rate limits are not configured")
+	}
+	l, err := newRateLimiter([]*rateLimitConfig{
+		{AccessKey: rateLimitDefaultAccessKey, QPS: 2},
+		{AccessKey: "AK", Bandwidth: 1024},
+		{AccessKey: "admin"},
+	})
+	if err != nil {
+		t.Fatalf("new rate limiter fail: err(%v)", err)
+	}
+	if limiter := l.limiter("admin"); limiter != nil {
+		t.Fatalf("expect access key unlimited")
+	}
+	if limiter := l.limiter("AK"); limiter == nil || limiter.qps != nil || limiter.bandwidth == nil {
+		t.Fatalf("expect bandwidth limit of access key")
+	}
+	var limiter = l.limiter("other")
+	if limiter == nil || limiter.qps == nil || limiter != l.limiter("other") || limiter == l.limiter("") {
+		t.Fatalf("expect default limiter of each access key")
+	}
+	for i := 0; i < 2; i++ {
+		if limitType := limiter.allow(); limitType != "" {
+			t.Fatalf("request %v: expect allowed but throttled by %v", i, limitType)
+		}
+	}
+	if limitType := limiter.allow(); limitType != rateLimitTypeQPS {
+		t.Fatalf("expect throttled by qps but %v", limitType)
+	}
+}
+
+func TestRateLimiterBandwidth(t *testing.T) {
+	l, err := newRateLimiter([]*rateLimitConfig{{AccessKey: "AK", Bandwidth: 1024}})
+	if err != nil {
+		t.Fatalf("new rate limiter fail: err(%v)", err)
+	}
+	var limiter = l.limiter("AK")
+	var reader = &throttledReader{ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 1536))),
+		ctx: context.Background(), limiter: limiter.bandwidth}
+	var start = time.Now()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil || len(data) != 1536 {
+		t.Fatalf("read throttled reader fail: size(%v) err(%v)", len(data), err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("expect read throttled but elapsed %v", elapsed)
+	}
+	if limitType := limiter.allow(); limitType != "" {
+		t.Fatalf("expect allowed but throttled by %v", limitType)
+	}
+	// the bandwidth is used up for the next 2 seconds
+	limiter.bandwidth.ReserveN(time.Now(), 1024)
+	limiter.bandwidth.ReserveN(time.Now(), 1024)
+	if limitType := limiter.allow(); limitType != rateLimitTypeBandwidth {
+		t.Fatalf("expect throttled by bandwidth but %v", limitType)
+	}
+}
@@ -0,0 +1,572 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/replication.html
+//
+// The new objects of a bucket are replicated asynchronously to the destination bucket of the
+// replication rule matching the key, which is a bucket of the same cluster given by the ARN
+// "arn:aws:s3:::<bucket>", or a bucket of a remote cluster given by the ARN "arn:cfs:s3::<id>:<bucket>"
+// where the id refers to a replication target configured on the ObjectNode.
+//
+// The objects to be replicated are marked PENDING after they are written, and marked COMPLETED or
+// FAILED after the replication, which is exposed by the x-amz-replication-status header of GetObject
+// and HeadObject. The replicas in the buckets of the same cluster are marked REPLICA, and are not
+// replicated again. The replications are queued in memory, so the objects are kept PENDING if the
+// ObjectNode stops before they are replicated, and the objects are marked FAILED if the queue is full.
+// The deletions are not replicated.
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	ReplicationStatusPending   = "PENDING"
+	ReplicationStatusCompleted = "COMPLETED"
+	ReplicationStatusFailed    = "FAILED"
+	ReplicationStatusReplica   = "REPLICA"
+
+	ReplicationRuleEnabled  = "Enabled"
+	ReplicationRuleDisabled = "Disabled"
+
+	LocalBucketARNPrefix  = "arn:aws:s3:::"
+	RemoteBucketARNPrefix = "arn:cfs:s3::"
+
+	MaxReplicationRules  = 1000
+	MaxReplicationRuleID = 255
+
+	replicationQueueSize    = 10000
+	replicationWorkers      = 4
+	replicationMaxRetries   = 3
+	replicationReadSize     = 1024 * 1024
+	replicationBacklogGauge = "replication_backlog"
+)
+
+type ReplicationConfiguration struct {
+	XMLName xml.Name           `xml:"ReplicationConfiguration"`
+	Role    string             `xml:"Role,omitempty"`
+	Rules   []*ReplicationRule `xml:"Rule"`
+}
+
+type ReplicationRule struct {
+	ID          string                  `xml:"ID,omitempty"`
+	Priority    int                     `xml:"Priority,omitempty"`
+	Status      string                  `xml:"Status"`
+	Prefix      string                  `xml:"Prefix,omitempty"` // deprecated by the filter
+	Filter      *ReplicationFilter      `xml:"Filter,omitempty"`
+	Destination *ReplicationDestination `xml:"Destination"`
+}
+
+type ReplicationFilter struct {
+	Prefix string `xml:"Prefix,omitempty"`
+}
+
+type ReplicationDestination struct {
+	Bucket       string `xml:"Bucket"`
+	StorageClass string `xml:"StorageClass,omitempty"`
+}
+
+func (rule *ReplicationRule) prefix() string {
+	if rule.Filter != nil {
+		return rule.Filter.Prefix
+	}
+	return rule.Prefix
+}
+
+// parseReplicationDestination returns the target id and the bucket of the destination ARN,
+// the target id is empty for the buckets of the same cluster.
+func parseReplicationDestination(arn string) (targetId, bucket string, err error) {
+	switch {
+	case strings.HasPrefix(arn, LocalBucketARNPrefix):
+		bucket = strings.TrimPrefix(arn, LocalBucketARNPrefix)
+	case strings.HasPrefix(arn, RemoteBucketARNPrefix):
+		var parts = strings.SplitN(strings.TrimPrefix(arn, RemoteBucketARNPrefix), ":", 2)
+		if len(parts) == 2 {
+			targetId, bucket = parts[0], parts[1]
+		}
+		if targetId == "" {
+			return "", "", fmt.Errorf("invalid destination bucket: %v", arn)
+		}
+	}
+	if bucket == "" || strings.ContainsAny(bucket, ":/") {
+		return "", "", fmt.Errorf("invalid destination bucket: %v", arn)
+	}
+	return
+}
+
+// validate checks the replication configuration, whose remote destinations must be the
+// targets of the replicator.
+func (config *ReplicationConfiguration) validate(replicator *bucketReplicator) error {
+	if len(config.Rules) == 0 {
+		return errors.New("no replication rule")
+	}
+	if len(config.Rules) > MaxReplicationRules {
+		return errors.New("too many replication rules")
+	}
+	var ids = make(map[string]struct{})
+	for _, rule := range config.Rules {
+		if len(rule.ID) > MaxReplicationRuleID {
+			return errors.New("the ID of replication rule is too long")
+		}
+		if rule.ID != "" {
+			if _, exist := ids[rule.ID]; exist {
+				return fmt.Errorf("duplicate replication rule ID: %v", rule.ID)
+			}
+			ids[rule.ID] = struct{}{}
+		}
+		if rule.Status != ReplicationRuleEnabled && rule.Status != ReplicationRuleDisabled {
+			return fmt.Errorf("invalid status of replication rule: %v", rule.Status)
+		}
+		if rule.Priority < 0 {
+			return fmt.Errorf("invalid priority of replication rule: %v", rule.Priority)
+		}
+		if rule.Filter != nil && rule.Prefix != "" {
+			return errors.New("Prefix cannot be specified with Filter")
+		}
+		if rule.Destination == nil {
+			return errors.New("the destination of replication rule must be specified")
+		}
+		targetId, _, err := parseReplicationDestination(rule.Destination.Bucket)
+		if err != nil {
+			return err
+		}
+		if targetId != "" && (replicator == nil || !replicator.hasTarget(targetId)) {
+			return fmt.Errorf("unknown replication target: %v", targetId)
+		}
+		if targetId == "" && rule.Destination.StorageClass != "" && rule.Destination.StorageClass != "STANDARD" {
+			return fmt.Errorf("invalid storage class of destination: %v", rule.Destination.StorageClass)
+		}
+	}
+	return nil
+}
+
+// matchRule returns the enabled rule of the highest priority whose prefix matches the key,
+// the earlier rule is matched if the priorities are same.
+func (config *ReplicationConfiguration) matchRule(key string) (matched *ReplicationRule) {
+	for _, rule := range config.Rules {
+		if rule.Status != ReplicationRuleEnabled || !strings.HasPrefix(key, rule.prefix()) {
+			continue
+		}
+		if matched == nil || rule.Priority > matched.Priority {
+			matched = rule
+		}
+	}
+	return
+}
+
+func storeBucketReplication(bytes []byte, vol *Volume) (err error) {
+	if err = vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSReplication, bytes); err != nil {
+		return
+	}
+	return nil
+}
+
+func deleteBucketReplication(vol *Volume) (err error) {
+	if err = vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSReplication); err != nil {
+		return
+	}
+	return nil
+}
+
+// setReplicationStatus marks the replication status of the inode.
+func (v *Volume) setReplicationStatus(inode uint64, status string) (err error) {
+	if err = v.mw.XAttrSet_ll(inode, []byte(XAttrKeyOSSReplicationStatus), []byte(status)); err != nil {
+		log.LogErrorf("setReplicationStatus: store replication status fail: volume(%v) inode(%v) status(%v) err(%v)",
+			v.name, inode, status, err)
+	}
+	return
+}
+
+// setReplicationResponseHeader exposes the replication status of the object in the response headers.
+func setReplicationResponseHeader(w http.ResponseWriter, info *FSFileInfo) {
+	if info.ReplicationStatus != "" {
+		w.Header()[HeaderNameXAmzReplicationStatus] = []string{info.ReplicationStatus}
+	}
+}
+
+// replicateObject queues the replication of the object written by the request if a replication
+// rule of the bucket matches it. The object is marked PENDING before it is queued.
+func (o *ObjectNode) replicateObject(r *http.Request, vol *Volume, key string, fileInfo *FSFileInfo) {
+	if o.replicator == nil {
+		return
+	}
+	var config, err = vol.metaLoader.loadReplication()
+	if err != nil {
+		log.LogWarnf("replicateObject: load replication fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if config == nil {
+		return
+	}
+	var rule = config.matchRule(key)
+	if rule == nil {
+		return
+	}
+	var targetId, bucket string
+	if targetId, bucket, err = parseReplicationDestination(rule.Destination.Bucket); err != nil {
+		log.LogWarnf("replicateObject: invalid destination: requestID(%v) volume(%v) destination(%v) err(%v)",
+			GetRequestID(r), vol.Name(), rule.Destination.Bucket, err)
+		return
+	}
+	if err = vol.setReplicationStatus(fileInfo.Inode, ReplicationStatusPending); err != nil {
+		return
+	}
+	fileInfo.ReplicationStatus = ReplicationStatusPending
+	var task = &replicationTask{
+		bucket:       vol.Name(),
+		key:          key,
+		inode:        fileInfo.Inode,
+		targetId:     targetId,
+		destBucket:   bucket,
+		storageClass: rule.Destination.StorageClass,
+	}
+	if !o.replicator.send(task) {
+		_ = vol.setReplicationStatus(fileInfo.Inode, ReplicationStatusFailed)
+		fileInfo.ReplicationStatus = ReplicationStatusFailed
+	}
+}
+
+// replicationTargetConfig is the configuration of a remote cluster of the replications.
+type replicationTargetConfig struct {
+	ID        string `json:"id"`
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region,omitempty"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// parseReplicationTargets parses the targets of the "replicationTargets" configuration.
+func parseReplicationTargets(items []interface{}) (configs []*replicationTargetConfig, err error) {
+	if len(items) == 0 {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(items); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid replication targets: %v", err)
+	}
+	return
+}
+
+func newReplicationClient(config *replicationTargetConfig) (client *s3.S3, err error) {
+	var sess *session.Session
+	if sess, err = session.NewSession(); err != nil {
+		return
+	}
+	var awsConfig = aws.NewConfig()
+	awsConfig.Endpoint = aws.String(config.Endpoint)
+	awsConfig.Region = aws.String(config.Region)
+	if config.Region == "" {
+		awsConfig.Region = aws.String("default")
+	}
+	awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, "")
+	awsConfig.S3ForcePathStyle = aws.Bool(true)
+	return s3.New(sess, awsConfig), nil
+}
+
+type replicationTask struct {
+	bucket       string
+	key          string
+	inode        uint64 // the inode of the object when it is queued
+	targetId     string // empty for the buckets of the same cluster
+	destBucket   string
+	storageClass string
+}
+
+// bucketReplicator replicates the queued objects by the workers.
+type bucketReplicator struct {
+	vm       *VolumeManager
+	clients  map[string]*s3.S3 // target id -> client
+	tasks    chan *replicationTask
+	backlog  int64
+	gauge    *exporter.Gauge
+	stopC    chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newBucketReplicator(vm *VolumeManager, configs []*replicationTargetConfig) (r *bucketReplicator, err error) {
+	r = &bucketReplicator{
+		vm:      vm,
+		clients: make(map[string]*s3.S3),
+		tasks:   make(chan *replicationTask, replicationQueueSize),
+		gauge:   exporter.NewGauge(replicationBacklogGauge),
+		stopC:   make(chan struct{}),
+	}
+	for _, config := range configs {
+		if config.ID == "" || strings.Contains(config.ID, ":") {
+			return nil, fmt.Errorf("invalid replication target id: %v", config.ID)
+		}
+		if config.Endpoint == "" {
+			return nil, fmt.Errorf("no endpoint of replication target: %v", config.ID)
+		}
+		if _, exist := r.clients[config.ID]; exist {
+			return nil, fmt.Errorf("duplicate replication target: %v", config.ID)
+		}
+		if r.clients[config.ID], err = newReplicationClient(config); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func (r *bucketReplicator) start() {
+	for i := 0; i < replicationWorkers; i++ {
+		r.wg.Add(1)
+		go r.run()
+	}
+}
+
+func (r *bucketReplicator) stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopC)
+		r.wg.Wait()
+	})
+}
+
+func (r *bucketReplicator) hasTarget(id string) bool {
+	_, exist := r.clients[id]
+	return exist
+}
+
+// send queues the task, which returns false if the queue is full.
+func (r *bucketReplicator) send(task *replicationTask) bool {
+	select {
+	case r.tasks <- task:
+		r.gauge.Set(float64(atomic.AddInt64(&r.backlog, 1)))
+		return true
+	default:
+		log.LogWarnf("replicator: queue is full and replication is dropped: volume(%v) key(%v)", task.bucket, task.key)
+		return false
+	}
+}
+
+func (r *bucketReplicator) run() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.stopC:
+			return
+		case task := <-r.tasks:
+			r.replicate(task)
+			r.gauge.Set(float64(atomic.AddInt64(&r.backlog, -1)))
+		}
+	}
+}
+
+func (r *bucketReplicator) replicate(task *replicationTask) {
+	var vol, err = r.vm.Volume(task.bucket)
+	if err != nil {
+		log.LogWarnf("replicator: load volume fail: volume(%v) key(%v) err(%v)", task.bucket, task.key, err)
+		return
+	}
+	var info *FSFileInfo
+	if info, err = vol.ObjectMeta(task.key); err != nil {
+		if err != syscall.ENOENT {
+			log.LogWarnf("replicator: get object meta fail: volume(%v) key(%v) err(%v)", task.bucket, task.key, err)
+			_ = vol.setReplicationStatus(task.inode, ReplicationStatusFailed)
+		}
+		return
+	}
+	// the object is overwritten, which is replicated by its own task
+	if info.Inode != task.inode || info.DeleteMarker || info.Mode.IsDir() {
+		return
+	}
+	for i := 0; i < replicationMaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-r.stopC:
+				return
+			case <-time.After(time.Duration(i) * time.Second):
+			}
+		}
+		if err = r.replicateObject(vol, info, task); err == nil {
+			break
+		}
+		log.LogWarnf("replicator: replicate object fail: volume(%v) key(%v) target(%v) bucket(%v) retry(%v) err(%v)",
+			task.bucket, task.key, task.targetId, task.destBucket, i, err)
+	}
+	var status = ReplicationStatusCompleted
+	if err != nil {
+		log.LogErrorf("replicator: replication failed: volume(%v) key(%v) target(%v) bucket(%v) err(%v)",
+			task.bucket, task.key, task.targetId, task.destBucket, err)
+		status = ReplicationStatusFailed
+	}
+	_ = vol.setReplicationStatus(info.Inode, status)
+}
+
+func (r *bucketReplicator) replicateObject(vol *Volume, info *FSFileInfo, task *replicationTask) (err error) {
+	var tagging *Tagging
+	if tagging, err = vol.loadInodeTagging(info.Inode); err != nil && err != syscall.ENOENT {
+		return
+	}
+	var reader = newInodeReader(vol, info)
+	if task.targetId == "" {
+		return r.replicateLocal(reader, info, tagging, task)
+	}
+	return r.replicateRemote(reader, info, tagging, task)
+}
+
+// replicateLocal writes the replica into the bucket of the same cluster, which is marked REPLICA.
+func (r *bucketReplicator) replicateLocal(reader *inodeReader, info *FSFileInfo, tagging *Tagging, task *replicationTask) (err error) {
+	var dest *Volume
+	if dest, err = r.vm.Volume(task.destBucket); err != nil {
+		return
+	}
+	var opt = &PutFileOption{
+		MIMEType:     info.MIMEType,
+		Disposition:  info.Disposition,
+		Tagging:      tagging,
+		Metadata:     info.Metadata,
+		CacheControl: info.CacheControl,
+		Expires:      info.Expires,
+	}
+	if info.SSEAlgorithm != "" {
+		opt.SSE = &SSEOption{Algorithm: info.SSEAlgorithm, KMSKeyId: info.SSEKMSKeyId}
+	}
+	var replica *FSFileInfo
+	if replica, err = dest.PutObject(task.key, reader, opt); err != nil {
+		return
+	}
+	return dest.setReplicationStatus(replica.Inode, ReplicationStatusReplica)
+}
+
+// replicateRemote puts the replica into the bucket of the remote cluster.
+func (r *bucketReplicator) replicateRemote(reader *inodeReader, info *FSFileInfo, tagging *Tagging, task *replicationTask) (err error) {
+	var client = r.clients[task.targetId]
+	if client == nil {
+		return fmt.Errorf("unknown replication target: %v", task.targetId)
+	}
+	var input = &s3.PutObjectInput{
+		Bucket:        aws.String(task.destBucket),
+		Key:           aws.String(task.key),
+		Body:          reader,
+		ContentLength: aws.Int64(info.Size),
+	}
+	if info.MIMEType != "" {
+		input.ContentType = aws.String(info.MIMEType)
+	}
+	if info.Disposition != "" {
+		input.ContentDisposition = aws.String(info.Disposition)
+	}
+	if info.CacheControl != "" {
+		input.CacheControl = aws.String(info.CacheControl)
+	}
+	if len(info.Metadata) > 0 {
+		input.Metadata = aws.StringMap(info.Metadata)
+	}
+	if tagging != nil && len(tagging.TagSet) > 0 {
+		input.Tagging = aws.String(tagging.Encode())
+	}
+	if task.storageClass != "" {
+		input.StorageClass = aws.String(task.storageClass)
+	}
+	if info.SSEAlgorithm != "" {
+		input.ServerSideEncryption = aws.String(info.SSEAlgorithm)
+		if info.SSEKMSKeyId != "" {
+			input.SSEKMSKeyId = aws.String(info.SSEKMSKeyId)
+		}
+	}
+	_, err = client.PutObject(input)
+	return
+}
+
+// inodeReader reads the data of an object as an io.ReadSeeker, which is read again by the
+// S3 client after it computes the hash of the payload.
+type inodeReader struct {
+	vol    *Volume
+	info   *FSFileInfo
+	offset int64
+	buf    []byte
+}
+
+func newInodeReader(vol *Volume, info *FSFileInfo) *inodeReader {
+	return &inodeReader{vol: vol, info: info}
+}
+
+type inodeReaderBuffer struct {
+	data []byte
+}
+
+func (b *inodeReaderBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (r *inodeReader) Read(p []byte) (n int, err error) {
+	if len(r.buf) == 0 {
+		if r.offset >= r.info.Size {
+			return 0, io.EOF
+		}
+		var size = r.info.Size - r.offset
+		if size > replicationReadSize {
+			size = replicationReadSize
+		}
+		var buffer = &inodeReaderBuffer{data: make([]byte, 0, size)}
+		if err = r.vol.ReadInode(r.info.Path, r.info.Inode, buffer, uint64(r.offset), uint64(size)); err != nil {
+			return 0, err
+		}
+		if len(buffer.data) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		r.buf = buffer.data
+	}
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *inodeReader) Seek(offset int64, whence int) (int64, error) {
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = r.offset + offset
+	case io.SeekEnd:
+		position = r.info.Size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if position < 0 {
+		return 0, errors.New("negative position")
+	}
+	if position != r.offset {
+		r.offset = position
+		r.buf = nil
+	}
+	return position, nil
+}
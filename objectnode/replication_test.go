@@ -0,0 +1,102 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestParseReplicationDestination(t *testing.T) {
+	var cases = []struct {
+		arn      string
+		targetId string
+		bucket   string
+		valid    bool
+	}{
+		{"arn:aws:s3:::backup", "", "backup", true},
+		{"arn:cfs:s3::dr:backup", "dr", "backup", true},
+		{"arn:cfs:s3:::backup", "", "", false},
+		{"arn:aws:s3:::", "", "", false},
+		{"backup", "", "", false},
+		{"arn:cfs:s3::dr:a:b", "", "", false},
+	}
+	for i, c := range cases {
+		targetId, bucket, err := parseReplicationDestination(c.arn)
+		if (err == nil) != c.valid {
+			t.Fatalf("case %v: parse result mismatch: expect valid(%v) actual err(%v)", i, c.valid, err)
+		}
+		if targetId != c.targetId || bucket != c.bucket {
+			t.Fatalf("case %v: destination mismatch: expect(%v %v) actual(%v %v)", i, c.targetId, c.bucket, targetId, bucket)
+		}
+	}
+}
+
+func TestReplicationConfigurationValidate(t *testing.T) {
+	var replicator, err = newBucketReplicator(nil, []*replicationTargetConfig{{ID: "dr", Endpoint: "http://127.0.0.1:17410"}})
+	if err != nil {
+		t.Fatalf("new replicator fail: err(%v)", err)
+	}
+	var cases = []struct {
+		config string
+		valid  bool
+	}{
+		{"<ReplicationConfiguration><Rule><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination></Rule></ReplicationConfiguration>", true},
+		{"<ReplicationConfiguration><Rule><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter><Destination><Bucket>arn:cfs:s3::dr:backup</Bucket></Destination></Rule></ReplicationConfiguration>", true},
+		{"<ReplicationConfiguration></ReplicationConfiguration>", false},
+		{"<ReplicationConfiguration><Rule><Status>On</Status><Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination></Rule></ReplicationConfiguration>", false},
+		{"<ReplicationConfiguration><Rule><Status>Enabled</Status></Rule></ReplicationConfiguration>", false},
+		{"<ReplicationConfiguration><Rule><Status>Enabled</Status><Destination><Bucket>arn:cfs:s3::unknown:backup</Bucket></Destination></Rule></ReplicationConfiguration>", false},
+		{"<ReplicationConfiguration><Rule><Status>Enabled</Status><Prefix>a</Prefix><Filter><Prefix>b</Prefix></Filter><Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination></Rule></ReplicationConfiguration>", false},
+		{"<ReplicationConfiguration><Rule><ID>1</ID><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::a</Bucket></Destination></Rule><Rule><ID>1</ID><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::b</Bucket></Destination></Rule></ReplicationConfiguration>", false},
+		{"<ReplicationConfiguration><Rule><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::backup</Bucket><StorageClass>GLACIER</StorageClass></Destination></Rule></ReplicationConfiguration>", false},
+	}
+	for i, c := range cases {
+		var config = &ReplicationConfiguration{}
+		if err = xml.Unmarshal([]byte(c.config), config); err != nil {
+			t.Fatalf("case %v: parse replication fail: err(%v)", i, err)
+		}
+		if err = config.validate(replicator); (err == nil) != c.valid {
+			t.Fatalf("case %v: validate result mismatch: expect valid(%v) actual err(%v)", i, c.valid, err)
+		}
+	}
+}
+
+func TestReplicationConfigurationMatchRule(t *testing.T) {
+	var config = &ReplicationConfiguration{Rules: []*ReplicationRule{
+		{ID: "all", Status: ReplicationRuleEnabled, Destination: &ReplicationDestination{Bucket: "arn:aws:s3:::a"}},
+		{ID: "logs", Priority: 2, Status: ReplicationRuleEnabled, Filter: &ReplicationFilter{Prefix: "logs/"},
+			Destination: &ReplicationDestination{Bucket: "arn:aws:s3:::b"}},
+		{ID: "disabled", Priority: 3, Status: ReplicationRuleDisabled, Prefix: "logs/",
+			Destination: &ReplicationDestination{Bucket: "arn:aws:s3:::c"}},
+	}}
+	var cases = []struct {
+		key    string
+		expect string
+	}{
+		{"logs/1", "logs"},
+		{"data/1", "all"},
+	}
+	for i, c := range cases {
+		var rule = config.matchRule(c.key)
+		if rule == nil || rule.ID != c.expect {
+			t.Fatalf("case %v: matched rule mismatch: expect(%v) actual(%+v)", i, c.expect, rule)
+		}
+	}
+	config.Rules[0].Status = ReplicationRuleDisabled
+	if rule := config.matchRule("data/1"); rule != nil {
+		t.Fatalf("unexpected matched rule: %+v", rule)
+	}
+}
@@ -165,7 +165,7 @@ func NewParts(fsParts []*FSPart) []*Part {
 		part := &Part{
 			PartNumber:   fsPart.PartNumber,
 			LastModified: fsPart.LastModified,
-			ETag:         fsPart.ETag,
+			ETag:         wrapUnescapedQuot(fsPart.ETag),
 			Size:         fsPart.Size,
 		}
 		parts = append(parts, part)
@@ -199,6 +199,41 @@ func NewBucketOwner(volume *Volume) *BucketOwner {
 	}
 }
 
+type Version struct {
+	XMLName      xml.Name     `xml:"Version"`
+	Key          string       `xml:"Key"`
+	VersionId    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	ETag         string       `xml:"ETag"`
+	Size         int          `xml:"Size"`
+	StorageClass string       `xml:"StorageClass"`
+	Owner        *BucketOwner `xml:"Owner,omitempty"`
+}
+
+type DeleteMarkerEntry struct {
+	XMLName      xml.Name     `xml:"DeleteMarker"`
+	Key          string       `xml:"Key"`
+	VersionId    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	Owner        *BucketOwner `xml:"Owner,omitempty"`
+}
+
+type ListVersionsResult struct {
+	XMLName             xml.Name             `xml:"ListVersionsResult"`
+	Name                string               `xml:"Name"`
+	Prefix              string               `xml:"Prefix"`
+	KeyMarker           string               `xml:"KeyMarker"`
+	VersionIdMarker     string               `xml:"VersionIdMarker"`
+	NextKeyMarker       string               `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string               `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int                  `xml:"MaxKeys"`
+	IsTruncated         bool                 `xml:"IsTruncated"`
+	Versions            []*Version           `xml:"Version"`
+	DeleteMarkers       []*DeleteMarkerEntry `xml:"DeleteMarker"`
+}
+
 type Object struct {
 	Key       string `xml:"Key"`
 	VersionId string `xml:"VersionId,omitempty"`
@@ -206,6 +241,7 @@ type Object struct {
 
 type DeleteRequest struct {
 	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet,omitempty"`
 	Objects []Object `xml:"Object"`
 }
 
@@ -215,6 +251,12 @@ type CopyResult struct {
 	ETag         string   `xml:"ETag,omitempty"`
 }
 
+type CopyPartResult struct {
+	XMLName      xml.Name `xml:"CopyPartResult"`
+	LastModified string   `xml:"LastModified,omitempty"`
+	ETag         string   `xml:"ETag,omitempty"`
+}
+
 type ListBucketResultV2 struct {
 	XMLName        xml.Name        `xml:"ListBucketResult"`
 	Name           string          `xml:"Name"`
@@ -252,18 +294,33 @@ func (t Tagging) Validate() (bool, *ErrorCode) {
 	if len(t.TagSet) > TaggingCounts {
 		return false, TagsGreaterThen10
 	}
+	var keys = make(map[string]struct{}, len(t.TagSet))
 	for _, tag := range t.TagSet {
 		log.LogDebugf("Validate: key : (%v), value : (%v)", tag.Key, tag.Value)
-		if len(tag.Key) > TaggingKeyMaxLength {
+		if len(tag.Key) == 0 || len(tag.Key) > TaggingKeyMaxLength {
 			return false, InvalidTagKey
 		}
 		if len(tag.Value) > TaggingValueMaxLength {
 			return false, InvalidTagValue
 		}
+		if _, exist := keys[tag.Key]; exist {
+			return false, DuplicateTagKey
+		}
+		keys[tag.Key] = struct{}{}
 	}
 	return true, errorCode
 }
 
+// Get returns the value of the tag of the key.
+func (t Tagging) Get(key string) (value string, exist bool) {
+	for _, tag := range t.TagSet {
+		if tag.Key == key {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
 func NewTagging() *Tagging {
 	return &Tagging{
 		XMLName: xml.Name{Local: "Tagging"},
@@ -31,6 +31,13 @@ func (code ErrorCode) ServeResponse(w http.ResponseWriter, r *http.Request) erro
 	// traceMiddleWare send exception request to prometheus via status code
 	SetResponseStatusCode(r, code)
 	SetResponseErrorMessage(r, code.ErrorMessage)
+	SetResponseErrorCode(r, code.ErrorCode)
+
+	// the response of 304 is not allowed to have a body
+	if code.StatusCode == http.StatusNotModified {
+		w.WriteHeader(code.StatusCode)
+		return nil
+	}
 
 	var err error
 	var marshaled []byte
@@ -94,7 +101,7 @@ var (
 	MaxContentLength                    = &ErrorCode{ErrorCode: "MaxContentLength", ErrorMessage: "Content-Length is bigger than 20KB.", StatusCode: http.StatusLengthRequired}
 	DuplicatedBucket                    = &ErrorCode{ErrorCode: "CreateBucketFailed", ErrorMessage: "Duplicate bucket name.", StatusCode: http.StatusBadRequest}
 	ObjectModeConflict                  = &ErrorCode{ErrorCode: "ObjectModeConflict", ErrorMessage: "Object already exists but file mode conflicts", StatusCode: http.StatusConflict}
-	NotModified                         = &ErrorCode{ErrorCode: "MaxContentLength", ErrorMessage: "Not modified.", StatusCode: http.StatusNotModified}
+	NotModified                         = &ErrorCode{ErrorCode: "NotModified", ErrorMessage: "Not modified.", StatusCode: http.StatusNotModified}
 	NoSuchUpload                        = &ErrorCode{ErrorCode: "NoSuchUpload", ErrorMessage: "The specified upload does not exist.", StatusCode: http.StatusNotFound}
 	OverMaxRecordSize                   = &ErrorCode{ErrorCode: "OverMaxRecordSize", ErrorMessage: "The length of a record in the input or result is greater than maxCharsPerRecord of 1 MB.", StatusCode: http.StatusBadRequest}
 	CopySourceSizeTooLarge              = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "The specified copy source is larger than the maximum allowable size for a copy source: 5368709120", StatusCode: http.StatusBadRequest}
@@ -104,6 +111,53 @@ var (
 	TagsGreaterThen10                   = &ErrorCode{ErrorCode: "BadRequest", ErrorMessage: "Object tags cannot be greater than 10", StatusCode: http.StatusBadRequest}
 	InvalidTagKey                       = &ErrorCode{ErrorCode: "InvalidTag", ErrorMessage: "The TagKey you have provided is invalid", StatusCode: http.StatusBadRequest}
 	InvalidTagValue                     = &ErrorCode{ErrorCode: "InvalidTag", ErrorMessage: "The TagValue you have provided is invalid", StatusCode: http.StatusBadRequest}
+	DuplicateTagKey                     = &ErrorCode{ErrorCode: "InvalidTag", ErrorMessage: "Cannot provide multiple Tags with the same key", StatusCode: http.StatusBadRequest}
+	NoSuchVersion                       = &ErrorCode{ErrorCode: "NoSuchVersion", ErrorMessage: "The specified version does not exist.", StatusCode: http.StatusNotFound}
+	MethodNotAllowed                    = &ErrorCode{ErrorCode: "MethodNotAllowed", ErrorMessage: "The specified method is not allowed against this resource.", StatusCode: http.StatusMethodNotAllowed}
+	IllegalVersioningConfiguration      = &ErrorCode{ErrorCode: "IllegalVersioningConfigurationException", ErrorMessage: "The versioning configuration specified in the request is invalid.", StatusCode: http.StatusBadRequest}
+	MalformedXML                        = &ErrorCode{ErrorCode: "MalformedXML", ErrorMessage: "The XML you provided was not well-formed or did not validate against our published schema.", StatusCode: http.StatusBadRequest}
+	NoSuchLifecycleConfiguration        = &ErrorCode{ErrorCode: "NoSuchLifecycleConfiguration", ErrorMessage: "The lifecycle configuration does not exist.", StatusCode: http.StatusNotFound}
+	NoSuchBucketPolicy                  = &ErrorCode{ErrorCode: "NoSuchBucketPolicy", ErrorMessage: "The bucket policy does not exist.", StatusCode: http.StatusNotFound}
+	NoSuchWebsiteConfiguration          = &ErrorCode{ErrorCode: "NoSuchWebsiteConfiguration", ErrorMessage: "The specified bucket does not have a website configuration.", StatusCode: http.StatusNotFound}
+	ReplicationConfigurationNotFound    = &ErrorCode{ErrorCode: "ReplicationConfigurationNotFoundError", ErrorMessage: "The replication configuration was not found.", StatusCode: http.StatusNotFound}
+	InvalidReplicationDestination       = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "Destination bucket must exist, be owned by the bucket owner and differ from the source bucket.", StatusCode: http.StatusBadRequest}
+	NoSuchInventoryConfiguration        = &ErrorCode{ErrorCode: "NoSuchConfiguration", ErrorMessage: "The specified configuration does not exist.", StatusCode: http.StatusNotFound}
+	InvalidInventoryDestination         = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "Destination bucket must exist and be owned by the bucket owner.", StatusCode: http.StatusBadRequest}
+	TooManyInventoryConfigurations      = &ErrorCode{ErrorCode: "TooManyConfigurations", ErrorMessage: "You are attempting to create a new configuration but have already reached the 1,000-configuration limit.", StatusCode: http.StatusBadRequest}
+	SlowDown                            = &ErrorCode{ErrorCode: "SlowDown", ErrorMessage: "Please reduce your request rate.", StatusCode: http.StatusServiceUnavailable}
+	CORSForbidden                       = &ErrorCode{ErrorCode: "AccessForbidden", ErrorMessage: "CORSResponse: This CORS request is not allowed.", StatusCode: http.StatusForbidden}
+	NoSuchCORSConfiguration             = &ErrorCode{ErrorCode: "NoSuchCORSConfiguration", ErrorMessage: "The CORS configuration does not exist.", StatusCode: http.StatusNotFound}
+	MalformedPolicy                     = &ErrorCode{ErrorCode: "MalformedPolicy", ErrorMessage: "Policies must be valid JSON and the first byte must be '{'.", StatusCode: http.StatusBadRequest}
+	NotImplemented                      = &ErrorCode{ErrorCode: "NotImplemented", ErrorMessage: "A header you provided implies functionality that is not implemented.", StatusCode: http.StatusNotImplemented}
+	InvalidEncryptionAlgorithm          = &ErrorCode{ErrorCode: "InvalidEncryptionAlgorithmError", ErrorMessage: "The encryption request you specified is not valid.", StatusCode: http.StatusBadRequest}
+	InvalidToken                        = &ErrorCode{ErrorCode: "InvalidToken", ErrorMessage: "The provided token is malformed or otherwise invalid.", StatusCode: http.StatusBadRequest}
+	ExpiredToken                        = &ErrorCode{ErrorCode: "ExpiredToken", ErrorMessage: "The provided token has expired.", StatusCode: http.StatusBadRequest}
+	MalformedPolicyDocument             = &ErrorCode{ErrorCode: "MalformedPolicyDocument", ErrorMessage: "The policy document is malformed.", StatusCode: http.StatusBadRequest}
+	InvalidExpressionType               = &ErrorCode{ErrorCode: "InvalidExpressionType", ErrorMessage: "The ExpressionType is invalid. Only SQL expressions are supported.", StatusCode: http.StatusBadRequest}
+	InvalidCompressionFormat            = &ErrorCode{ErrorCode: "InvalidCompressionFormat", ErrorMessage: "The file is not in a supported compression format. Only GZIP and BZIP2 are supported.", StatusCode: http.StatusBadRequest}
+	InvalidRequestParameter             = &ErrorCode{ErrorCode: "InvalidRequestParameter", ErrorMessage: "The value of a parameter in SelectRequest element is invalid.", StatusCode: http.StatusBadRequest}
+	MissingRequiredParameter            = &ErrorCode{ErrorCode: "MissingRequiredParameter", ErrorMessage: "The SelectRequest entity is missing a required parameter.", StatusCode: http.StatusBadRequest}
+	ParseSelectFailure                  = &ErrorCode{ErrorCode: "ParseSelectFailure", ErrorMessage: "The SQL expression can not be parsed.", StatusCode: http.StatusBadRequest}
+	InvalidBucketState                  = &ErrorCode{ErrorCode: "InvalidBucketState", ErrorMessage: "Object Lock configuration cannot be enabled on existing buckets without versioning enabled.", StatusCode: http.StatusConflict}
+	ObjectLockConfigurationNotFound     = &ErrorCode{ErrorCode: "ObjectLockConfigurationNotFoundError", ErrorMessage: "Object Lock configuration does not exist for this bucket.", StatusCode: http.StatusNotFound}
+	NoSuchObjectLockConfiguration       = &ErrorCode{ErrorCode: "NoSuchObjectLockConfiguration", ErrorMessage: "The specified object does not have a ObjectLock configuration.", StatusCode: http.StatusNotFound}
+	ObjectLocked                        = &ErrorCode{ErrorCode: "AccessDenied", ErrorMessage: "Access Denied because object protected by object lock.", StatusCode: http.StatusForbidden}
+	InvalidObjectLockRequest            = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "Bucket is missing Object Lock Configuration.", StatusCode: http.StatusBadRequest}
+	InvalidTargetBucketForLogging       = &ErrorCode{ErrorCode: "InvalidTargetBucketForLogging", ErrorMessage: "The target bucket for logging does not exist or is not owned by the owner of the bucket.", StatusCode: http.StatusBadRequest}
+	SignatureDoesNotMatch               = &ErrorCode{ErrorCode: "SignatureDoesNotMatch", ErrorMessage: "The request signature we calculated does not match the signature you provided.", StatusCode: http.StatusForbidden}
+	IncompleteBody                      = &ErrorCode{ErrorCode: "IncompleteBody", ErrorMessage: "You did not provide the number of bytes specified by the Content-Length HTTP header.", StatusCode: http.StatusBadRequest}
+	QuotaExceeded                       = &ErrorCode{ErrorCode: "QuotaExceeded", ErrorMessage: "The bucket has exceeded its capacity or object count quota.", StatusCode: http.StatusForbidden}
+	InvalidDigest                       = &ErrorCode{ErrorCode: "InvalidDigest", ErrorMessage: "The Content-MD5 you specified is not valid.", StatusCode: http.StatusBadRequest}
+	InvalidChecksum                     = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "The checksum algorithm or value you specified is not valid.", StatusCode: http.StatusBadRequest}
+	MultipleChecksums                   = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "Expecting a single x-amz-checksum- header. Multiple checksum types are not allowed.", StatusCode: http.StatusBadRequest}
+	BadChecksum                         = &ErrorCode{ErrorCode: "BadDigest", ErrorMessage: "The checksum you specified did not match the calculated checksum.", StatusCode: http.StatusBadRequest}
+	MalformedTrailer                    = &ErrorCode{ErrorCode: "MalformedTrailerError", ErrorMessage: "The request contained trailing data that was not well-formed or did not conform to our published schema.", StatusCode: http.StatusBadRequest}
+	InvalidAccessKeyId                  = &ErrorCode{ErrorCode: "InvalidAccessKeyId", ErrorMessage: "The AWS access key Id you provided does not exist in our records.", StatusCode: http.StatusForbidden}
+	MalformedPOSTRequest                = &ErrorCode{ErrorCode: "MalformedPOSTRequest", ErrorMessage: "The body of your POST request is not well-formed multipart/form-data.", StatusCode: http.StatusBadRequest}
+	MissingPostFile                     = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "POST requires exactly one file upload per request.", StatusCode: http.StatusBadRequest}
+	MissingPostKey                      = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "Bucket POST must contain a field named 'key'.", StatusCode: http.StatusBadRequest}
+	MaxPostPreDataLengthExceeded        = &ErrorCode{ErrorCode: "MaxPostPreDataLengthExceededError", ErrorMessage: "Your POST request fields preceding the upload file were too large.", StatusCode: http.StatusBadRequest}
+	InvalidPolicyDocument               = &ErrorCode{ErrorCode: "InvalidPolicyDocument", ErrorMessage: "The content of the form does not meet the conditions specified in the policy document.", StatusCode: http.StatusBadRequest}
 )
 
 func HttpStatusErrorCode(code int) *ErrorCode {
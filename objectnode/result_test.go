@@ -300,3 +300,14 @@ func TestRequest_CompleteMultipartUploadRequest_Unmarshal(t *testing.T) {
 	bytes, _ := json.Marshal(request)
 	fmt.Printf("request : %s\n", string(bytes))
 }
+
+func TestXmlUnmarshal_DeleteRequest(t *testing.T) {
+	var data = `<Delete><Quiet>true</Quiet><Object><Key>a</Key></Object><Object><Key>b</Key><VersionId>1</VersionId></Object></Delete>`
+	var request = DeleteRequest{}
+	if err := UnmarshalXMLEntity([]byte(data), &request); err != nil {
+		t.Fatalf("unmarshal fail: err(%v)", err)
+	}
+	if !request.Quiet || len(request.Objects) != 2 || request.Objects[1].VersionId != "1" {
+		t.Fatalf("request mismatch: actual(%v)", request)
+	}
+}
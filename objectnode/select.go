@@ -0,0 +1,828 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+const (
+	SelectExpressionTypeSQL = "SQL"
+
+	SelectCompressionNone  = "NONE"
+	SelectCompressionGzip  = "GZIP"
+	SelectCompressionBzip2 = "BZIP2"
+
+	SelectFileHeaderUse    = "USE"
+	SelectFileHeaderIgnore = "IGNORE"
+	SelectFileHeaderNone   = "NONE"
+
+	SelectJSONTypeDocument = "DOCUMENT"
+	SelectJSONTypeLines    = "LINES"
+
+	SelectQuoteFieldsAlways   = "ALWAYS"
+	SelectQuoteFieldsAsNeeded = "ASNEEDED"
+
+	SelectRequestLimitSize = 256 * 1024
+	SelectMaxRecordSize    = 1024 * 1024 // the records of CSV input are limited to 1MB
+	SelectRecordsChunkSize = 128 * 1024  // the records are returned in chunks of 128KB
+)
+
+type SelectObjectContentRequest struct {
+	XMLName             xml.Name                  `xml:"SelectObjectContentRequest"`
+	Expression          string                    `xml:"Expression"`
+	ExpressionType      string                    `xml:"ExpressionType"`
+	InputSerialization  SelectInputSerialization  `xml:"InputSerialization"`
+	OutputSerialization SelectOutputSerialization `xml:"OutputSerialization"`
+	RequestProgress     struct {
+		Enabled bool `xml:"Enabled"`
+	} `xml:"RequestProgress"`
+}
+
+type SelectInputSerialization struct {
+	CompressionType string              `xml:"CompressionType"`
+	CSV             *SelectCSVInput     `xml:"CSV"`
+	JSON            *SelectJSONInput    `xml:"JSON"`
+	Parquet         *SelectParquetInput `xml:"Parquet"`
+}
+
+type SelectCSVInput struct {
+	FileHeaderInfo             string `xml:"FileHeaderInfo"`
+	Comments                   string `xml:"Comments"`
+	QuoteEscapeCharacter       string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter            string `xml:"RecordDelimiter"`
+	FieldDelimiter             string `xml:"FieldDelimiter"`
+	QuoteCharacter             string `xml:"QuoteCharacter"`
+	AllowQuotedRecordDelimiter bool   `xml:"AllowQuotedRecordDelimiter"`
+}
+
+type SelectJSONInput struct {
+	Type string `xml:"Type"`
+}
+
+type SelectParquetInput struct{}
+
+type SelectOutputSerialization struct {
+	CSV  *SelectCSVOutput  `xml:"CSV"`
+	JSON *SelectJSONOutput `xml:"JSON"`
+}
+
+type SelectCSVOutput struct {
+	QuoteFields          string `xml:"QuoteFields"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter      string `xml:"RecordDelimiter"`
+	FieldDelimiter       string `xml:"FieldDelimiter"`
+	QuoteCharacter       string `xml:"QuoteCharacter"`
+}
+
+type SelectJSONOutput struct {
+	RecordDelimiter string `xml:"RecordDelimiter"`
+}
+
+// validate checks the request and fills the default values of the serializations.
+func (req *SelectObjectContentRequest) validate() *ErrorCode {
+	if req.ExpressionType != SelectExpressionTypeSQL {
+		return InvalidExpressionType
+	}
+	if strings.TrimSpace(req.Expression) == "" {
+		return MissingRequiredParameter
+	}
+	var input = &req.InputSerialization
+	var inputs = 0
+	for _, given := range []bool{input.CSV != nil, input.JSON != nil, input.Parquet != nil} {
+		if given {
+			inputs++
+		}
+	}
+	if inputs != 1 {
+		return InvalidRequestParameter
+	}
+	switch input.CompressionType {
+	case "":
+		input.CompressionType = SelectCompressionNone
+	case SelectCompressionNone, SelectCompressionGzip, SelectCompressionBzip2:
+	default:
+		return InvalidCompressionFormat
+	}
+	if input.Parquet != nil && input.CompressionType != SelectCompressionNone {
+		return InvalidCompressionFormat
+	}
+	if csvInput := input.CSV; csvInput != nil {
+		switch csvInput.FileHeaderInfo {
+		case "":
+			csvInput.FileHeaderInfo = SelectFileHeaderNone
+		case SelectFileHeaderUse, SelectFileHeaderIgnore, SelectFileHeaderNone:
+		default:
+			return InvalidRequestParameter
+		}
+		if !fillSelectDelimiters(&csvInput.FieldDelimiter, &csvInput.RecordDelimiter, &csvInput.QuoteCharacter, &csvInput.QuoteEscapeCharacter) ||
+			utf8.RuneCountInString(csvInput.Comments) > 1 {
+			return InvalidRequestParameter
+		}
+	}
+	if jsonInput := input.JSON; jsonInput != nil {
+		if jsonInput.Type != SelectJSONTypeDocument && jsonInput.Type != SelectJSONTypeLines {
+			return InvalidRequestParameter
+		}
+	}
+
+	var output = &req.OutputSerialization
+	if (output.CSV == nil) == (output.JSON == nil) {
+		return InvalidRequestParameter
+	}
+	if csvOutput := output.CSV; csvOutput != nil {
+		switch csvOutput.QuoteFields {
+		case "":
+			csvOutput.QuoteFields = SelectQuoteFieldsAsNeeded
+		case SelectQuoteFieldsAsNeeded, SelectQuoteFieldsAlways:
+		default:
+			return InvalidRequestParameter
+		}
+		if !fillSelectDelimiters(&csvOutput.FieldDelimiter, &csvOutput.RecordDelimiter, &csvOutput.QuoteCharacter, &csvOutput.QuoteEscapeCharacter) {
+			return InvalidRequestParameter
+		}
+	}
+	if jsonOutput := output.JSON; jsonOutput != nil {
+		if jsonOutput.RecordDelimiter == "" {
+			jsonOutput.RecordDelimiter = "\n"
+		}
+		if utf8.RuneCountInString(jsonOutput.RecordDelimiter) > 2 {
+			return InvalidRequestParameter
+		}
+	}
+	return nil
+}
+
+// fillSelectDelimiters fills the default delimiters of CSV, and reports whether they are valid.
+func fillSelectDelimiters(field, record, quote, escape *string) bool {
+	if *field == "" {
+		*field = ","
+	}
+	if *record == "" {
+		*record = "\n"
+	}
+	if *quote == "" {
+		*quote = "\""
+	}
+	if *escape == "" {
+		*escape = *quote
+	}
+	return utf8.RuneCountInString(*field) == 1 && utf8.RuneCountInString(*record) <= 2 &&
+		utf8.RuneCountInString(*quote) == 1 && utf8.RuneCountInString(*escape) == 1
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return 0
+	}
+	return r
+}
+
+// selectError is an error occurred while processing the records, which is sent as an error event.
+type selectError struct {
+	code    string
+	message string
+}
+
+func (e *selectError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newSelectError(code string, err error) *selectError {
+	return &selectError{code: code, message: err.Error()}
+}
+
+type selectField struct {
+	name  string
+	value interface{}
+}
+
+// selectObject is an object of JSON, whose fields are kept in order.
+type selectObject []selectField
+
+func (o selectObject) field(name string, quoted bool) (interface{}, bool) {
+	for _, f := range o {
+		if f.name == name {
+			return f.value, true
+		}
+	}
+	if !quoted {
+		for _, f := range o {
+			if strings.EqualFold(f.name, name) {
+				return f.value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (o selectObject) fields() []selectField {
+	return o
+}
+
+type csvRecord struct {
+	values []string
+	header []string
+}
+
+func (c *csvRecord) field(name string, quoted bool) (interface{}, bool) {
+	// the columns are referred by their positions as "_1", "_2" and so on
+	if len(name) > 1 && name[0] == '_' {
+		if position, err := strconv.Atoi(name[1:]); err == nil {
+			if position < 1 || position > len(c.values) {
+				return nil, false
+			}
+			return c.values[position-1], true
+		}
+	}
+	for i, h := range c.header {
+		if h == name && i < len(c.values) {
+			return c.values[i], true
+		}
+	}
+	if !quoted {
+		for i, h := range c.header {
+			if strings.EqualFold(h, name) && i < len(c.values) {
+				return c.values[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (c *csvRecord) fields() []selectField {
+	var fields = make([]selectField, len(c.values))
+	for i, value := range c.values {
+		fields[i].value = value
+		if i < len(c.header) && c.header[i] != "" {
+			fields[i].name = c.header[i]
+		} else {
+			fields[i].name = "_" + strconv.Itoa(i+1)
+		}
+	}
+	return fields
+}
+
+type selectRecordReader interface {
+	// Read returns the next record, or io.EOF if there are no more records.
+	Read() (selectRecord, error)
+}
+
+type csvRecordReader struct {
+	r           *bufio.Reader
+	headerInfo  string
+	fieldDelim  rune
+	recordDelim []rune
+	quote       rune
+	escape      rune
+	comment     rune
+	header      []string
+	started     bool
+}
+
+func newCSVRecordReader(r io.Reader, opt *SelectCSVInput) *csvRecordReader {
+	return &csvRecordReader{
+		r:           bufio.NewReader(r),
+		headerInfo:  opt.FileHeaderInfo,
+		fieldDelim:  firstRune(opt.FieldDelimiter),
+		recordDelim: []rune(opt.RecordDelimiter),
+		quote:       firstRune(opt.QuoteCharacter),
+		escape:      firstRune(opt.QuoteEscapeCharacter),
+		comment:     firstRune(opt.Comments),
+	}
+}
+
+func (c *csvRecordReader) Read() (selectRecord, error) {
+	for {
+		values, err := c.readRecord()
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, newSelectError("CSVParsingError", err)
+		}
+		if !c.started {
+			c.started = true
+			switch c.headerInfo {
+			case SelectFileHeaderUse:
+				c.header = values
+				continue
+			case SelectFileHeaderIgnore:
+				continue
+			}
+		}
+		return &csvRecord{values: values, header: c.header}, nil
+	}
+}
+
+// isRecordDelimiter reports whether the rune starts a record delimiter, which is consumed if so.
+func (c *csvRecordReader) isRecordDelimiter(r rune) bool {
+	if r != c.recordDelim[0] {
+		return false
+	}
+	if len(c.recordDelim) == 1 {
+		return true
+	}
+	next, _, err := c.r.ReadRune()
+	if err != nil {
+		return false
+	}
+	if next == c.recordDelim[1] {
+		return true
+	}
+	_ = c.r.UnreadRune()
+	return false
+}
+
+func (c *csvRecordReader) readRecord() ([]string, error) {
+	var (
+		values  []string
+		field   strings.Builder
+		inQuote bool
+		quoted  bool
+		size    int
+	)
+	// end finishes the record, which is skipped if it is a blank line
+	var end = func() ([]string, bool) {
+		var value = field.String()
+		if !quoted && c.recordDelim[0] == '\n' && len(c.recordDelim) == 1 {
+			value = strings.TrimSuffix(value, "\r")
+		}
+		if len(values) == 0 && value == "" && !quoted {
+			return nil, false
+		}
+		return append(values, value), true
+	}
+	for {
+		r, n, err := c.r.ReadRune()
+		if err == io.EOF {
+			if inQuote {
+				return nil, errors.New("unterminated quoted field")
+			}
+			if record, ok := end(); ok {
+				return record, nil
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size += n; size > SelectMaxRecordSize {
+			return nil, fmt.Errorf("record is larger than %v", SelectMaxRecordSize)
+		}
+		if inQuote {
+			switch {
+			case r == c.escape && c.escape != c.quote:
+				var next rune
+				if next, _, err = c.r.ReadRune(); err != nil {
+					return nil, errors.New("unterminated quoted field")
+				}
+				field.WriteRune(next)
+			case r == c.quote:
+				var next rune
+				if next, _, err = c.r.ReadRune(); err == nil {
+					if next == c.quote {
+						field.WriteRune(c.quote)
+						continue
+					}
+					_ = c.r.UnreadRune()
+				}
+				inQuote = false
+			default:
+				field.WriteRune(r)
+			}
+			continue
+		}
+		switch {
+		case c.comment != 0 && r == c.comment && len(values) == 0 && field.Len() == 0 && !quoted:
+			// skip the comment line
+			for {
+				if r, _, err = c.r.ReadRune(); err != nil || c.isRecordDelimiter(r) {
+					break
+				}
+			}
+		case r == c.quote && field.Len() == 0 && !quoted:
+			inQuote, quoted = true, true
+		case r == c.fieldDelim:
+			values = append(values, field.String())
+			field.Reset()
+			quoted = false
+		case c.isRecordDelimiter(r):
+			if record, ok := end(); ok {
+				return record, nil
+			}
+			values, quoted, size = nil, false, 0
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+}
+
+type jsonRecordReader struct {
+	dec      *json.Decoder
+	fromPath []selectPathElem
+	pending  []interface{}
+}
+
+func newJSONRecordReader(r io.Reader, fromPath []selectPathElem) *jsonRecordReader {
+	var dec = json.NewDecoder(r)
+	dec.UseNumber()
+	return &jsonRecordReader{dec: dec, fromPath: fromPath}
+}
+
+func (j *jsonRecordReader) Read() (selectRecord, error) {
+	for len(j.pending) == 0 {
+		value, err := decodeSelectJSONValue(j.dec)
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, newSelectError("JSONParsingError", err)
+		}
+		j.pending = expandSelectPath(value, j.fromPath)
+	}
+	var value = j.pending[0]
+	j.pending = j.pending[1:]
+	if object, is := value.(selectObject); is {
+		return object, nil
+	}
+	return selectObject{{name: "_1", value: value}}, nil
+}
+
+// expandSelectPath returns the values in the path of the FROM clause, in which "[*]" expands the arrays.
+func expandSelectPath(value interface{}, path []selectPathElem) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	var elem = path[0]
+	if elem.wildcard {
+		array, is := value.([]interface{})
+		if !is {
+			return expandSelectPath(value, path[1:])
+		}
+		var values []interface{}
+		for _, item := range array {
+			values = append(values, expandSelectPath(item, path[1:])...)
+		}
+		return values
+	}
+	if child := lookupSelectPath(value, elem); child != nil {
+		return expandSelectPath(child, path[1:])
+	}
+	return nil
+}
+
+func decodeSelectJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			var object = selectObject{}
+			for dec.More() {
+				var key json.Token
+				if key, err = dec.Token(); err != nil {
+					return nil, err
+				}
+				var value interface{}
+				if value, err = decodeSelectJSONValue(dec); err != nil {
+					return nil, err
+				}
+				object = append(object, selectField{name: key.(string), value: value})
+			}
+			if _, err = dec.Token(); err != nil {
+				return nil, err
+			}
+			return object, nil
+		case '[':
+			var array = []interface{}{}
+			for dec.More() {
+				var value interface{}
+				if value, err = decodeSelectJSONValue(dec); err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			}
+			if _, err = dec.Token(); err != nil {
+				return nil, err
+			}
+			return array, nil
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	}
+	return token, nil
+}
+
+func writeSelectJSONValue(w io.Writer, v interface{}) {
+	switch t := v.(type) {
+	case nil:
+		_, _ = io.WriteString(w, "null")
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			_, _ = io.WriteString(w, "null")
+			return
+		}
+		_, _ = io.WriteString(w, strconv.FormatFloat(t, 'f', -1, 64))
+	case selectObject:
+		_, _ = io.WriteString(w, "{")
+		for i, f := range t {
+			if i > 0 {
+				_, _ = io.WriteString(w, ",")
+			}
+			writeSelectJSONValue(w, f.name)
+			_, _ = io.WriteString(w, ":")
+			writeSelectJSONValue(w, f.value)
+		}
+		_, _ = io.WriteString(w, "}")
+	case []interface{}:
+		_, _ = io.WriteString(w, "[")
+		for i, item := range t {
+			if i > 0 {
+				_, _ = io.WriteString(w, ",")
+			}
+			writeSelectJSONValue(w, item)
+		}
+		_, _ = io.WriteString(w, "]")
+	default:
+		data, _ := json.Marshal(t)
+		_, _ = w.Write(data)
+	}
+}
+
+type selectRecordWriter interface {
+	write(buf *bytes.Buffer, fields []selectField)
+}
+
+type csvRecordWriter struct {
+	opt *SelectCSVOutput
+}
+
+func (c *csvRecordWriter) write(buf *bytes.Buffer, fields []selectField) {
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(c.opt.FieldDelimiter)
+		}
+		var value = formatSQLString(f.value)
+		if c.opt.QuoteFields == SelectQuoteFieldsAlways ||
+			strings.ContainsAny(value, c.opt.FieldDelimiter+c.opt.RecordDelimiter+c.opt.QuoteCharacter+"\r\n") {
+			buf.WriteString(c.opt.QuoteCharacter)
+			buf.WriteString(strings.Replace(value, c.opt.QuoteCharacter, c.opt.QuoteEscapeCharacter+c.opt.QuoteCharacter, -1))
+			buf.WriteString(c.opt.QuoteCharacter)
+			continue
+		}
+		buf.WriteString(value)
+	}
+	buf.WriteString(c.opt.RecordDelimiter)
+}
+
+type jsonRecordWriter struct {
+	opt *SelectJSONOutput
+}
+
+func (j *jsonRecordWriter) write(buf *bytes.Buffer, fields []selectField) {
+	writeSelectJSONValue(buf, selectObject(fields))
+	buf.WriteString(j.opt.RecordDelimiter)
+}
+
+// newSelectInputReader returns the reader of uncompressed data of the object.
+func newSelectInputReader(r io.Reader, compressionType string) (io.Reader, error) {
+	switch compressionType {
+	case SelectCompressionGzip:
+		return gzip.NewReader(r)
+	case SelectCompressionBzip2:
+		return bzip2.NewReader(r), nil
+	}
+	return r, nil
+}
+
+type selectCountingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *selectCountingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += int64(n)
+	return
+}
+
+type SelectStats struct {
+	XMLName        xml.Name `xml:"Stats"`
+	BytesScanned   int64    `xml:"BytesScanned"`
+	BytesProcessed int64    `xml:"BytesProcessed"`
+	BytesReturned  int64    `xml:"BytesReturned"`
+}
+
+type SelectProgress struct {
+	XMLName        xml.Name `xml:"Progress"`
+	BytesScanned   int64    `xml:"BytesScanned"`
+	BytesProcessed int64    `xml:"BytesProcessed"`
+	BytesReturned  int64    `xml:"BytesReturned"`
+}
+
+// selectEventWriter writes the messages of the event stream, which is the response of SelectObjectContent.
+type selectEventWriter struct {
+	w       io.Writer
+	encoder *eventstream.Encoder
+}
+
+func newSelectEventWriter(w io.Writer) *selectEventWriter {
+	return &selectEventWriter{w: w, encoder: eventstream.NewEncoder(w)}
+}
+
+func (e *selectEventWriter) writeMessage(headers eventstream.Headers, payload []byte) (err error) {
+	if err = e.encoder.Encode(eventstream.Message{Headers: headers, Payload: payload}); err != nil {
+		return
+	}
+	if flusher, is := e.w.(http.Flusher); is {
+		flusher.Flush()
+	}
+	return
+}
+
+func (e *selectEventWriter) writeEvent(eventType, contentType string, payload []byte) error {
+	var headers eventstream.Headers
+	headers.Set(":message-type", eventstream.StringValue("event"))
+	headers.Set(":event-type", eventstream.StringValue(eventType))
+	if contentType != "" {
+		headers.Set(":content-type", eventstream.StringValue(contentType))
+	}
+	return e.writeMessage(headers, payload)
+}
+
+func (e *selectEventWriter) writeRecords(payload []byte) error {
+	return e.writeEvent("Records", "application/octet-stream", payload)
+}
+
+func (e *selectEventWriter) writeXMLEvent(eventType string, entity interface{}) error {
+	payload, err := xml.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	return e.writeEvent(eventType, "text/xml", payload)
+}
+
+func (e *selectEventWriter) writeEnd() error {
+	return e.writeEvent("End", "", nil)
+}
+
+func (e *selectEventWriter) writeError(code, message string) error {
+	var headers eventstream.Headers
+	headers.Set(":message-type", eventstream.StringValue("error"))
+	headers.Set(":error-code", eventstream.StringValue(code))
+	headers.Set(":error-message", eventstream.StringValue(message))
+	return e.writeMessage(headers, nil)
+}
+
+// selectExecutor runs the statement over the records and writes the results as events.
+type selectExecutor struct {
+	stmt           *selectStatement
+	reader         selectRecordReader
+	writer         selectRecordWriter
+	events         *selectEventWriter
+	progress       bool
+	bytesScanned   func() int64
+	bytesProcessed func() int64
+	bytesReturned  int64
+}
+
+func (e *selectExecutor) flush(buf *bytes.Buffer) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+	e.bytesReturned += int64(buf.Len())
+	if err := e.events.writeRecords(buf.Bytes()); err != nil {
+		return err
+	}
+	buf.Reset()
+	if e.progress {
+		return e.events.writeXMLEvent("Progress", &SelectProgress{
+			BytesScanned:   e.bytesScanned(),
+			BytesProcessed: e.bytesProcessed(),
+			BytesReturned:  e.bytesReturned,
+		})
+	}
+	return nil
+}
+
+func (e *selectExecutor) project(record selectRecord, names []string) ([]selectField, error) {
+	if e.stmt.selectAll {
+		return record.fields(), nil
+	}
+	var fields = make([]selectField, len(e.stmt.columns))
+	for i, column := range e.stmt.columns {
+		value, err := column.expr.eval(record)
+		if err != nil {
+			return nil, newSelectError("EvaluatorInvalidArguments", err)
+		}
+		fields[i] = selectField{name: names[i], value: value}
+	}
+	return fields, nil
+}
+
+// run processes all the records, and returns a selectError if the request fails while processing
+// the records, or other errors if the events can not be written.
+func (e *selectExecutor) run() (err error) {
+	var names = make([]string, len(e.stmt.columns))
+	for i, column := range e.stmt.columns {
+		names[i] = column.name(i)
+	}
+	var buf bytes.Buffer
+	var count int64
+	for e.stmt.limit < 0 || count < e.stmt.limit || e.stmt.isAggregate() {
+		var record selectRecord
+		if record, err = e.reader.Read(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, is := err.(*selectError); !is {
+				err = newSelectError("InternalError", err)
+			}
+			return
+		}
+		if e.stmt.where != nil {
+			var matched *bool
+			if matched, err = evalSQLBool(e.stmt.where, record); err != nil {
+				return newSelectError("EvaluatorInvalidArguments", err)
+			}
+			if matched == nil || !*matched {
+				continue
+			}
+		}
+		if e.stmt.isAggregate() {
+			for _, agg := range e.stmt.aggregates {
+				if err = agg.accumulate(record); err != nil {
+					return newSelectError("EvaluatorInvalidArguments", err)
+				}
+			}
+			continue
+		}
+		var fields []selectField
+		if fields, err = e.project(record, names); err != nil {
+			return
+		}
+		e.writer.write(&buf, fields)
+		count++
+		if buf.Len() >= SelectRecordsChunkSize {
+			if err = e.flush(&buf); err != nil {
+				return
+			}
+		}
+	}
+	if e.stmt.isAggregate() {
+		var fields = make([]selectField, len(e.stmt.aggregates))
+		for i, agg := range e.stmt.aggregates {
+			fields[i] = selectField{name: names[i], value: agg.result()}
+		}
+		e.writer.write(&buf, fields)
+	}
+	if err = e.flush(&buf); err != nil {
+		return
+	}
+	if err = e.events.writeXMLEvent("Stats", &SelectStats{
+		BytesScanned:   e.bytesScanned(),
+		BytesProcessed: e.bytesProcessed(),
+		BytesReturned:  e.bytesReturned,
+	}); err != nil {
+		return
+	}
+	return e.events.writeEnd()
+}
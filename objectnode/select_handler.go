@@ -0,0 +1,205 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"syscall"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// volumeReaderAt reads the data of an object at random offsets, which is required by Parquet files.
+type volumeReaderAt struct {
+	vol   *Volume
+	path  string
+	inode uint64
+	size  int64
+	n     int64
+}
+
+func (v *volumeReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 || off+int64(len(p)) > v.size {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var buf = bytes.NewBuffer(p[:0])
+	if err = v.vol.ReadInode(v.path, v.inode, buf, uint64(off), uint64(len(p))); err != nil {
+		return 0, err
+	}
+	if n = copy(p, buf.Bytes()); n < len(p) {
+		return n, io.ErrUnexpectedEOF
+	}
+	v.n += int64(n)
+	return
+}
+
+// Select object content
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+func (o *ObjectNode) selectObjectContentHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+			return
+		}
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = InvalidKey
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("selectObjectContentHandler: load volume fail: requestID(%v) err(%v)",
+			GetRequestID(r), err)
+		errorCode = NoSuchBucket
+		return
+	}
+
+	// parse request
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, SelectRequestLimitSize+1)); err != nil {
+		log.LogErrorf("selectObjectContentHandler: read request body fail: requestID(%v) err(%v)",
+			GetRequestID(r), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	if len(body) > SelectRequestLimitSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var request = &SelectObjectContentRequest{}
+	if err = xml.Unmarshal(body, request); err != nil {
+		log.LogWarnf("selectObjectContentHandler: unmarshal request fail: requestID(%v) err(%v)",
+			GetRequestID(r), err)
+		errorCode = MalformedXML
+		return
+	}
+	if errorCode = request.validate(); errorCode != nil {
+		return
+	}
+	var stmt *selectStatement
+	if stmt, err = parseSelectStatement(request.Expression); err != nil {
+		log.LogWarnf("selectObjectContentHandler: parse expression fail: requestID(%v) expression(%v) err(%v)",
+			GetRequestID(r), request.Expression, err)
+		errorCode = &ErrorCode{ErrorCode: ParseSelectFailure.ErrorCode, ErrorMessage: err.Error(), StatusCode: ParseSelectFailure.StatusCode}
+		return
+	}
+
+	// get object meta
+	var fileInfo *FSFileInfo
+	if fileInfo, err = vol.ObjectVersionMeta(param.Object(), ""); err != nil {
+		if err == syscall.ENOENT {
+			errorCode = NoSuchKey
+			return
+		}
+		log.LogErrorf("selectObjectContentHandler: get file meta fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	if fileInfo.DeleteMarker || fileInfo.Mode.IsDir() {
+		errorCode = NoSuchKey
+		return
+	}
+
+	// open the records of object
+	var input = &request.InputSerialization
+	var executor = &selectExecutor{
+		stmt:     stmt,
+		progress: request.RequestProgress.Enabled,
+	}
+	if input.Parquet != nil {
+		var readerAt = &volumeReaderAt{vol: vol, path: param.Object(), inode: fileInfo.Inode, size: fileInfo.Size}
+		if executor.reader, err = newParquetRecordReader(readerAt, fileInfo.Size); err != nil {
+			log.LogWarnf("selectObjectContentHandler: open parquet file fail: requestID(%v) volume(%v) path(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), err)
+			if selectErr, is := err.(*selectError); is {
+				errorCode = &ErrorCode{ErrorCode: selectErr.code, ErrorMessage: selectErr.message, StatusCode: http.StatusBadRequest}
+			} else {
+				errorCode = InternalErrorCode(err)
+			}
+			return
+		}
+		executor.bytesScanned = func() int64 { return readerAt.n }
+		executor.bytesProcessed = executor.bytesScanned
+	} else {
+		var reader, writer = io.Pipe()
+		defer func() {
+			_ = reader.Close()
+		}()
+		go func() {
+			var readErr error
+			if fileInfo.Size > 0 {
+				readErr = vol.ReadInode(param.Object(), fileInfo.Inode, writer, 0, uint64(fileInfo.Size))
+			}
+			_ = writer.CloseWithError(readErr)
+		}()
+		var scanned = &selectCountingReader{r: reader}
+		var decompressed io.Reader
+		if decompressed, err = newSelectInputReader(scanned, input.CompressionType); err != nil {
+			log.LogWarnf("selectObjectContentHandler: open compressed object fail: requestID(%v) volume(%v) path(%v) compression(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), input.CompressionType, err)
+			errorCode = InvalidCompressionFormat
+			return
+		}
+		var processed = &selectCountingReader{r: decompressed}
+		if input.CSV != nil {
+			executor.reader = newCSVRecordReader(processed, input.CSV)
+		} else {
+			executor.reader = newJSONRecordReader(processed, stmt.fromPath)
+		}
+		executor.bytesScanned = func() int64 { return scanned.n }
+		executor.bytesProcessed = func() int64 { return processed.n }
+	}
+	if output := request.OutputSerialization; output.CSV != nil {
+		executor.writer = &csvRecordWriter{opt: output.CSV}
+	} else {
+		executor.writer = &jsonRecordWriter{opt: output.JSON}
+	}
+
+	// the results are streamed as events
+	w.WriteHeader(http.StatusOK)
+	executor.events = newSelectEventWriter(w)
+	if err = executor.run(); err != nil {
+		if selectErr, is := err.(*selectError); is {
+			log.LogWarnf("selectObjectContentHandler: process records fail: requestID(%v) volume(%v) path(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), err)
+			_ = executor.events.writeError(selectErr.code, selectErr.message)
+			return
+		}
+		log.LogErrorf("selectObjectContentHandler: write events fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
+	log.LogDebugf("selectObjectContentHandler: select object content: requestID(%v) volume(%v) path(%v) returned(%v)",
+		GetRequestID(r), vol.Name(), param.Object(), executor.bytesReturned)
+}
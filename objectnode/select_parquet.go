@@ -0,0 +1,761 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// A lite reader of Parquet files for S3 Select, which reads files of flat schemas
+// (no nested or repeated columns) stored in PLAIN or dictionary encodings, in pages
+// which are uncompressed or compressed by SNAPPY or GZIP.
+// File format reference: https://github.com/apache/parquet-format
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+)
+
+const (
+	parquetMagic            = "PAR1"
+	parquetMaxFooterSize    = 16 * 1024 * 1024
+	parquetMaxColumnSize    = 256 * 1024 * 1024
+	parquetMaxThriftDepth   = 32
+	parquetJulianDayOfEpoch = 2440588
+)
+
+// physical types
+const (
+	parquetBoolean = iota
+	parquetInt32
+	parquetInt64
+	parquetInt96
+	parquetFloat
+	parquetDouble
+	parquetByteArray
+	parquetFixedLenByteArray
+)
+
+// converted types
+const (
+	parquetConvertedDecimal         = 5
+	parquetConvertedDate            = 6
+	parquetConvertedTimestampMillis = 9
+	parquetConvertedTimestampMicros = 10
+)
+
+// repetition types
+const (
+	parquetRequired = iota
+	parquetOptional
+	parquetRepeated
+)
+
+// page types
+const (
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+)
+
+// encodings
+const (
+	parquetEncodingPlain          = 0
+	parquetEncodingPlainDictonary = 2
+	parquetEncodingRLE            = 3
+	parquetEncodingRLEDictionary  = 8
+)
+
+// compression codecs
+const (
+	parquetCodecUncompressed = 0
+	parquetCodecSnappy       = 1
+	parquetCodecGzip         = 2
+)
+
+var errParquetCorrupted = errors.New("parquet file is corrupted")
+
+// thriftStruct is a struct decoded from thrift compact protocol, keyed by the field ids.
+type thriftStruct map[int16]interface{}
+
+func (s thriftStruct) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStruct) has(id int16) bool {
+	_, has := s[id]
+	return has
+}
+
+func (s thriftStruct) string(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s thriftStruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+func (s thriftStruct) structure(id int16) thriftStruct {
+	v, _ := s[id].(thriftStruct)
+	return v
+}
+
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (t *thriftReader) byte() (byte, error) {
+	if t.pos >= len(t.data) {
+		return 0, errParquetCorrupted
+	}
+	t.pos++
+	return t.data[t.pos-1], nil
+}
+
+func (t *thriftReader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(t.data)-t.pos) {
+		return nil, errParquetCorrupted
+	}
+	t.pos += int(n)
+	return t.data[t.pos-int(n) : t.pos], nil
+}
+
+func (t *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(t.data[t.pos:])
+	if n <= 0 {
+		return 0, errParquetCorrupted
+	}
+	t.pos += n
+	return v, nil
+}
+
+func (t *thriftReader) zigzag() (int64, error) {
+	v, err := t.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (t *thriftReader) readStruct(depth int) (thriftStruct, error) {
+	if depth > parquetMaxThriftDepth {
+		return nil, errParquetCorrupted
+	}
+	var s = thriftStruct{}
+	var last int16
+	for {
+		b, err := t.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return s, nil
+		}
+		var typ, delta = b & 0x0f, b >> 4
+		var id = last + int16(delta)
+		if delta == 0 {
+			var z int64
+			if z, err = t.zigzag(); err != nil {
+				return nil, err
+			}
+			id = int16(z)
+		}
+		last = id
+		if typ == 1 || typ == 2 {
+			s[id] = typ == 1
+			continue
+		}
+		if s[id], err = t.readValue(typ, depth); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *thriftReader) readValue(typ byte, depth int) (interface{}, error) {
+	switch typ {
+	case 1, 2: // boolean in collections
+		b, err := t.byte()
+		return b == 1, err
+	case 3:
+		b, err := t.byte()
+		return int64(int8(b)), err
+	case 4, 5, 6:
+		return t.zigzag()
+	case 7:
+		data, err := t.bytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case 8:
+		n, err := t.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		return t.bytes(n)
+	case 9, 10:
+		header, err := t.byte()
+		if err != nil {
+			return nil, err
+		}
+		var size, elemType = uint64(header >> 4), header & 0x0f
+		if size == 15 {
+			if size, err = t.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		if size > uint64(len(t.data)-t.pos) {
+			return nil, errParquetCorrupted
+		}
+		var list = make([]interface{}, size)
+		for i := range list {
+			if list[i], err = t.readValue(elemType, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case 11:
+		size, err := t.uvarint()
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		var types byte
+		if types, err = t.byte(); err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, err = t.readValue(types>>4, depth+1); err != nil {
+				return nil, err
+			}
+			if _, err = t.readValue(types&0x0f, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case 12:
+		return t.readStruct(depth + 1)
+	}
+	return nil, errParquetCorrupted
+}
+
+type parquetColumn struct {
+	name          string
+	physicalType  int64
+	typeLength    int64
+	optional      bool
+	convertedType int64
+	scale         int64
+}
+
+// convert converts a decoded physical value into the value of the logical type.
+func (c *parquetColumn) convert(v interface{}) interface{} {
+	switch c.convertedType {
+	case parquetConvertedDecimal:
+		if i, is := v.(int64); is {
+			return float64(i) / math.Pow10(int(c.scale))
+		}
+	case parquetConvertedDate:
+		if i, is := v.(int64); is {
+			return time.Unix(i*86400, 0).UTC().Format("2006-01-02")
+		}
+	case parquetConvertedTimestampMillis:
+		if i, is := v.(int64); is {
+			return time.Unix(0, i*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano)
+		}
+	case parquetConvertedTimestampMicros:
+		if i, is := v.(int64); is {
+			return time.Unix(0, i*int64(time.Microsecond)).UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return v
+}
+
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+	codec  int64
+}
+
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetColumnChunk
+}
+
+type parquetRecordReader struct {
+	r         io.ReaderAt
+	columns   []*parquetColumn
+	rowGroups []parquetRowGroup
+	group     int
+	row       int64
+	values    [][]interface{}
+}
+
+func newParquetRecordReader(r io.ReaderAt, size int64) (reader *parquetRecordReader, err error) {
+	defer func() {
+		if err != nil {
+			err = newSelectError("ParquetParsingError", err)
+		}
+	}()
+	if size < int64(2*len(parquetMagic)+4) {
+		return nil, errParquetCorrupted
+	}
+	var tail = make([]byte, 8)
+	if _, err = r.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+	if string(tail[4:]) != parquetMagic {
+		return nil, errors.New("not a parquet file")
+	}
+	var footerSize = int64(binary.LittleEndian.Uint32(tail))
+	if footerSize > parquetMaxFooterSize || footerSize > size-12 {
+		return nil, errParquetCorrupted
+	}
+	var footer = make([]byte, footerSize)
+	if _, err = r.ReadAt(footer, size-8-footerSize); err != nil {
+		return nil, err
+	}
+	var meta thriftStruct
+	if meta, err = (&thriftReader{data: footer}).readStruct(0); err != nil {
+		return nil, err
+	}
+
+	reader = &parquetRecordReader{r: r}
+	// the schema is flattened in depth-first order, whose first element is the root
+	var schema = meta.list(2)
+	if len(schema) == 0 {
+		return nil, errParquetCorrupted
+	}
+	root, _ := schema[0].(thriftStruct)
+	if root == nil || root.int(5) != int64(len(schema)-1) {
+		return nil, errors.New("nested schema is not supported")
+	}
+	for _, item := range schema[1:] {
+		element, _ := item.(thriftStruct)
+		if element == nil || element.int(5) > 0 || !element.has(1) {
+			return nil, errors.New("nested schema is not supported")
+		}
+		if element.int(3) == parquetRepeated {
+			return nil, errors.New("repeated column is not supported")
+		}
+		reader.columns = append(reader.columns, &parquetColumn{
+			name:          element.string(4),
+			physicalType:  element.int(1),
+			typeLength:    element.int(2),
+			optional:      element.int(3) == parquetOptional,
+			convertedType: element.int(6),
+			scale:         element.int(7),
+		})
+	}
+	for _, item := range meta.list(4) {
+		group, _ := item.(thriftStruct)
+		if group == nil || len(group.list(1)) != len(reader.columns) {
+			return nil, errParquetCorrupted
+		}
+		var rowGroup = parquetRowGroup{rows: group.int(3)}
+		for _, c := range group.list(1) {
+			chunk, _ := c.(thriftStruct)
+			if chunk == nil || chunk.string(1) != "" || chunk.structure(3) == nil {
+				return nil, errors.New("column chunks in external files are not supported")
+			}
+			var columnMeta = chunk.structure(3)
+			var offset = columnMeta.int(9)
+			if dictOffset := columnMeta.int(11); columnMeta.has(11) && dictOffset > 0 && dictOffset < offset {
+				offset = dictOffset
+			}
+			var columnSize = columnMeta.int(7)
+			if offset < 0 || columnSize < 0 || columnSize > parquetMaxColumnSize || offset+columnSize > size {
+				return nil, errParquetCorrupted
+			}
+			rowGroup.chunks = append(rowGroup.chunks, parquetColumnChunk{
+				offset: offset,
+				size:   columnSize,
+				codec:  columnMeta.int(4),
+			})
+		}
+		reader.rowGroups = append(reader.rowGroups, rowGroup)
+	}
+	return reader, nil
+}
+
+func (p *parquetRecordReader) Read() (selectRecord, error) {
+	for p.values == nil || p.row >= p.rowGroups[p.group-1].rows {
+		if p.group >= len(p.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := p.loadRowGroup(&p.rowGroups[p.group]); err != nil {
+			return nil, newSelectError("ParquetParsingError", err)
+		}
+		p.group++
+		p.row = 0
+	}
+	var record = make(selectObject, len(p.columns))
+	for i, column := range p.columns {
+		record[i] = selectField{name: column.name, value: p.values[i][p.row]}
+	}
+	p.row++
+	return record, nil
+}
+
+func (p *parquetRecordReader) loadRowGroup(group *parquetRowGroup) (err error) {
+	p.values = make([][]interface{}, len(p.columns))
+	for i, column := range p.columns {
+		var chunk = group.chunks[i]
+		var data = make([]byte, chunk.size)
+		if _, err = p.r.ReadAt(data, chunk.offset); err != nil {
+			return
+		}
+		if p.values[i], err = decodeParquetColumnChunk(column, chunk.codec, data); err != nil {
+			return fmt.Errorf("column %v: %v", column.name, err)
+		}
+		if int64(len(p.values[i])) < group.rows {
+			return errParquetCorrupted
+		}
+	}
+	return
+}
+
+func decodeParquetColumnChunk(column *parquetColumn, codec int64, data []byte) (values []interface{}, err error) {
+	var dictionary []interface{}
+	var t = &thriftReader{data: data}
+	for t.pos < len(data) {
+		var header thriftStruct
+		if header, err = t.readStruct(0); err != nil {
+			return
+		}
+		var page []byte
+		if page, err = t.bytes(uint64(header.int(3))); err != nil {
+			return
+		}
+		switch header.int(1) {
+		case parquetDictionaryPage:
+			if page, err = decompressParquetPage(codec, page, header.int(2)); err != nil {
+				return
+			}
+			var dictHeader = header.structure(7)
+			if dictionary, _, err = decodeParquetPlain(column, page, int(dictHeader.int(1))); err != nil {
+				return
+			}
+		case parquetDataPage:
+			if page, err = decompressParquetPage(codec, page, header.int(2)); err != nil {
+				return
+			}
+			var dataHeader = header.structure(5)
+			var numValues = int(dataHeader.int(1))
+			var defined []uint32
+			if column.optional {
+				if dataHeader.int(3) != parquetEncodingRLE || len(page) < 4 {
+					return nil, errors.New("unsupported definition level encoding")
+				}
+				var length = int(binary.LittleEndian.Uint32(page))
+				if length > len(page)-4 {
+					return nil, errParquetCorrupted
+				}
+				if defined, err = decodeParquetRLE(page[4:4+length], 1, numValues); err != nil {
+					return
+				}
+				page = page[4+length:]
+			}
+			var pageValues []interface{}
+			if pageValues, err = decodeParquetValues(column, int(dataHeader.int(2)), page, defined, numValues, dictionary); err != nil {
+				return
+			}
+			values = append(values, pageValues...)
+		case parquetDataPageV2:
+			var dataHeader = header.structure(8)
+			var numValues = int(dataHeader.int(1))
+			var defLength, repLength = int(dataHeader.int(5)), int(dataHeader.int(6))
+			if defLength < 0 || repLength != 0 || defLength > len(page) {
+				return nil, errParquetCorrupted
+			}
+			var defined []uint32
+			if column.optional {
+				if defined, err = decodeParquetRLE(page[:defLength], 1, numValues); err != nil {
+					return
+				}
+			}
+			page = page[defLength:]
+			if compressed, has := dataHeader[7].(bool); !has || compressed {
+				if page, err = decompressParquetPage(codec, page, header.int(2)-int64(defLength)); err != nil {
+					return
+				}
+			}
+			var pageValues []interface{}
+			if pageValues, err = decodeParquetValues(column, int(dataHeader.int(4)), page, defined, numValues, dictionary); err != nil {
+				return
+			}
+			values = append(values, pageValues...)
+		}
+	}
+	return
+}
+
+// decodeParquetValues decodes the values of a data page, in which the undefined values are nil.
+func decodeParquetValues(column *parquetColumn, encoding int, data []byte, defined []uint32, numValues int,
+	dictionary []interface{}) (values []interface{}, err error) {
+	var numDefined = numValues
+	if defined != nil {
+		numDefined = 0
+		for _, d := range defined {
+			if d > 0 {
+				numDefined++
+			}
+		}
+	}
+	var decoded []interface{}
+	switch encoding {
+	case parquetEncodingPlain:
+		if decoded, _, err = decodeParquetPlain(column, data, numDefined); err != nil {
+			return
+		}
+	case parquetEncodingPlainDictonary, parquetEncodingRLEDictionary:
+		if len(data) < 1 {
+			return nil, errParquetCorrupted
+		}
+		var indexes []uint32
+		if indexes, err = decodeParquetRLE(data[1:], int(data[0]), numDefined); err != nil {
+			return
+		}
+		decoded = make([]interface{}, len(indexes))
+		for i, index := range indexes {
+			if int(index) >= len(dictionary) {
+				return nil, errParquetCorrupted
+			}
+			decoded[i] = dictionary[index]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding %v", encoding)
+	}
+	values = make([]interface{}, numValues)
+	var next = 0
+	for i := range values {
+		if defined != nil && defined[i] == 0 {
+			continue
+		}
+		values[i] = column.convert(decoded[next])
+		next++
+	}
+	return
+}
+
+func decodeParquetPlain(column *parquetColumn, data []byte, count int) (values []interface{}, consumed int, err error) {
+	values = make([]interface{}, 0, count)
+	var fixed = func(size int) ([]byte, error) {
+		if consumed+size > len(data) {
+			return nil, errParquetCorrupted
+		}
+		consumed += size
+		return data[consumed-size : consumed], nil
+	}
+	for i := 0; i < count; i++ {
+		var b []byte
+		switch column.physicalType {
+		case parquetBoolean:
+			if i/8 >= len(data) {
+				return nil, 0, errParquetCorrupted
+			}
+			values = append(values, data[i/8]>>(uint(i)%8)&1 == 1)
+			consumed = (i + 8) / 8
+			continue
+		case parquetInt32:
+			if b, err = fixed(4); err != nil {
+				return
+			}
+			values = append(values, int64(int32(binary.LittleEndian.Uint32(b))))
+		case parquetInt64:
+			if b, err = fixed(8); err != nil {
+				return
+			}
+			values = append(values, int64(binary.LittleEndian.Uint64(b)))
+		case parquetInt96:
+			if b, err = fixed(12); err != nil {
+				return
+			}
+			var nanos = int64(binary.LittleEndian.Uint64(b))
+			var days = int64(binary.LittleEndian.Uint32(b[8:])) - parquetJulianDayOfEpoch
+			values = append(values, time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano))
+		case parquetFloat:
+			if b, err = fixed(4); err != nil {
+				return
+			}
+			values = append(values, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		case parquetDouble:
+			if b, err = fixed(8); err != nil {
+				return
+			}
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		case parquetByteArray:
+			if b, err = fixed(4); err != nil {
+				return
+			}
+			if b, err = fixed(int(binary.LittleEndian.Uint32(b))); err != nil {
+				return
+			}
+			values = append(values, string(b))
+		case parquetFixedLenByteArray:
+			if column.typeLength <= 0 {
+				return nil, 0, errParquetCorrupted
+			}
+			if b, err = fixed(int(column.typeLength)); err != nil {
+				return
+			}
+			values = append(values, string(b))
+		default:
+			return nil, 0, fmt.Errorf("unsupported type %v", column.physicalType)
+		}
+	}
+	return
+}
+
+// decodeParquetRLE decodes the values in the hybrid of run length encoding and bit packing.
+func decodeParquetRLE(data []byte, bitWidth int, count int) (values []uint32, err error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, errParquetCorrupted
+	}
+	values = make([]uint32, 0, count)
+	var pos = 0
+	for len(values) < count {
+		header, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return nil, errParquetCorrupted
+		}
+		pos += n
+		if header&1 == 0 {
+			// repeated value
+			var runLength = header >> 1
+			var width = (bitWidth + 7) / 8
+			if pos+width > len(data) {
+				return nil, errParquetCorrupted
+			}
+			if runLength > uint64(count-len(values)) {
+				runLength = uint64(count - len(values))
+			}
+			var value uint32
+			for i := 0; i < width; i++ {
+				value |= uint32(data[pos+i]) << (8 * uint(i))
+			}
+			pos += width
+			for i := uint64(0); i < runLength; i++ {
+				values = append(values, value)
+			}
+			continue
+		}
+		// bit packed groups of 8 values
+		var groups = header >> 1
+		if groups*uint64(bitWidth) > uint64(len(data)-pos) {
+			return nil, errParquetCorrupted
+		}
+		var bit = 0
+		for i := uint64(0); i < groups*8 && len(values) < count; i++ {
+			var value uint32
+			for j := 0; j < bitWidth; j++ {
+				if data[pos+bit/8]>>(uint(bit)%8)&1 == 1 {
+					value |= 1 << uint(j)
+				}
+				bit++
+			}
+			values = append(values, value)
+		}
+		pos += int(groups) * bitWidth
+	}
+	return
+}
+
+func decompressParquetPage(codec int64, data []byte, uncompressedSize int64) ([]byte, error) {
+	switch codec {
+	case parquetCodecUncompressed:
+		return data, nil
+	case parquetCodecSnappy:
+		return decodeSnappy(data)
+	case parquetCodecGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(io.LimitReader(r, uncompressedSize))
+	}
+	return nil, fmt.Errorf("unsupported compression codec %v", codec)
+}
+
+// decodeSnappy decodes a block of the snappy format.
+// Format reference: https://github.com/google/snappy/blob/master/format_description.txt
+func decodeSnappy(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > parquetMaxColumnSize {
+		return nil, errParquetCorrupted
+	}
+	var dst = make([]byte, 0, length)
+	for pos := n; pos < len(src); {
+		var tag = src[pos]
+		var size, offset int
+		switch tag & 0x03 {
+		case 0: // literal
+			size = int(tag>>2) + 1
+			pos++
+			if size > 60 {
+				var extra = size - 60
+				if pos+extra > len(src) {
+					return nil, errParquetCorrupted
+				}
+				size = 0
+				for i := 0; i < extra; i++ {
+					size |= int(src[pos+i]) << (8 * uint(i))
+				}
+				size++
+				pos += extra
+			}
+			if size <= 0 || pos+size > len(src) {
+				return nil, errParquetCorrupted
+			}
+			dst = append(dst, src[pos:pos+size]...)
+			pos += size
+			continue
+		case 1:
+			if pos+2 > len(src) {
+				return nil, errParquetCorrupted
+			}
+			size = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[pos+1])
+			pos += 2
+		case 2:
+			if pos+3 > len(src) {
+				return nil, errParquetCorrupted
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[pos+1:]))
+			pos += 3
+		case 3:
+			if pos+5 > len(src) {
+				return nil, errParquetCorrupted
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[pos+1:]))
+			pos += 5
+		}
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+size) > length {
+			return nil, errParquetCorrupted
+		}
+		for i := 0; i < size; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, errParquetCorrupted
+	}
+	return dst, nil
+}
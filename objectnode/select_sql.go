@@ -0,0 +1,1468 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// The SQL dialect of S3 Select is implemented here, for the subset of
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/s3-glacier-select-sql-reference-select.html
+//
+//   SELECT * | expr [[AS] alias], ... FROM S3Object[[*].path] [[AS] alias] [WHERE cond] [LIMIT n]
+//
+// Values of expressions are nil (NULL or MISSING), bool, int64, float64, string,
+// selectObject and []interface{}.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type sqlTokenKind int
+
+const (
+	sqlTokenEOF sqlTokenKind = iota
+	sqlTokenIdent
+	sqlTokenString
+	sqlTokenNumber
+	sqlTokenSymbol
+)
+
+type sqlToken struct {
+	kind   sqlTokenKind
+	text   string
+	quoted bool // identifier in double quotes, which is case sensitive and never a keyword
+}
+
+// is reports whether the token is the keyword or symbol.
+func (t sqlToken) is(s string) bool {
+	switch t.kind {
+	case sqlTokenIdent:
+		return !t.quoted && strings.EqualFold(t.text, s)
+	case sqlTokenSymbol:
+		return t.text == s
+	}
+	return false
+}
+
+func (t sqlToken) String() string {
+	switch t.kind {
+	case sqlTokenEOF:
+		return "end of expression"
+	case sqlTokenString:
+		return "'" + t.text + "'"
+	}
+	return t.text
+}
+
+func tokenizeSQL(expr string) (tokens []sqlToken, err error) {
+	var i = 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(expr) {
+					return nil, fmt.Errorf("unterminated quoted text at position %v", i)
+				}
+				if expr[j] == c {
+					if j+1 < len(expr) && expr[j+1] == c {
+						b.WriteByte(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(expr[j])
+				j++
+			}
+			if c == '\'' {
+				tokens = append(tokens, sqlToken{kind: sqlTokenString, text: b.String()})
+			} else {
+				tokens = append(tokens, sqlToken{kind: sqlTokenIdent, text: b.String(), quoted: true})
+			}
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			if j < len(expr) && (expr[j] == 'e' || expr[j] == 'E') {
+				k := j + 1
+				if k < len(expr) && (expr[k] == '+' || expr[k] == '-') {
+					k++
+				}
+				if k < len(expr) && expr[k] >= '0' && expr[k] <= '9' {
+					for j = k; j < len(expr) && expr[j] >= '0' && expr[j] <= '9'; j++ {
+					}
+				}
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenNumber, text: expr[i:j]})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf:
+			j := i
+			for j < len(expr) {
+				r, size := utf8.DecodeRuneInString(expr[j:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected character at position %v", i)
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenIdent, text: expr[i:j]})
+			i = j
+		default:
+			var symbol = string(c)
+			if i+1 < len(expr) {
+				switch two := expr[i : i+2]; two {
+				case "<=", ">=", "<>", "!=", "||":
+					symbol = two
+				}
+			}
+			if !strings.Contains("*,().[]=<>+-/%", symbol) && len(symbol) == 1 {
+				return nil, fmt.Errorf("unexpected character '%c' at position %v", c, i)
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenSymbol, text: symbol})
+			i += len(symbol)
+		}
+	}
+	tokens = append(tokens, sqlToken{kind: sqlTokenEOF})
+	return
+}
+
+// selectPathElem is an element of the path to a value in a record,
+// which is either a field name or an array index.
+type selectPathElem struct {
+	name     string
+	quoted   bool
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+type selectColumn struct {
+	expr  sqlExpr
+	alias string
+}
+
+// name returns the name of the projected column at the position, which is the alias,
+// the last field name of a column reference or "_N".
+func (c *selectColumn) name(position int) string {
+	if c.alias != "" {
+		return c.alias
+	}
+	if ref, is := c.expr.(*sqlColumnRef); is {
+		if last := ref.path[len(ref.path)-1]; !last.isIndex {
+			return last.name
+		}
+	}
+	return "_" + strconv.Itoa(position+1)
+}
+
+type selectStatement struct {
+	selectAll  bool
+	columns    []*selectColumn
+	tableAlias string
+	fromPath   []selectPathElem
+	where      sqlExpr
+	limit      int64 // negative if no limit
+	aggregates []*sqlAggregate
+}
+
+// isAggregate reports whether the statement projects aggregations, which produces a single record.
+func (s *selectStatement) isAggregate() bool {
+	return len(s.aggregates) > 0
+}
+
+type sqlParser struct {
+	tokens     []sqlToken
+	pos        int
+	aggregates []*sqlAggregate
+	tableAlias string
+}
+
+func (p *sqlParser) peek() sqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *sqlParser) next() sqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != sqlTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *sqlParser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expect(s string) error {
+	if !p.accept(s) {
+		return fmt.Errorf("expected %v but found %v", s, p.peek())
+	}
+	return nil
+}
+
+var sqlReservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true, "AND": true, "OR": true,
+	"NOT": true, "LIKE": true, "ESCAPE": true, "BETWEEN": true, "IN": true, "IS": true, "NULL": true,
+	"MISSING": true, "TRUE": true, "FALSE": true, "CAST": true,
+}
+
+// parseSelectStatement parses the SQL expression of a select request.
+func parseSelectStatement(expr string) (stmt *selectStatement, err error) {
+	var tokens []sqlToken
+	if tokens, err = tokenizeSQL(expr); err != nil {
+		return
+	}
+	var p = &sqlParser{tokens: tokens}
+	stmt = &selectStatement{limit: -1}
+	if err = p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+
+	// the projection is parsed after the FROM clause, which names the alias of the table
+	var projectionStart = p.pos
+	var depth = 0
+	for {
+		t := p.peek()
+		if t.kind == sqlTokenEOF {
+			return nil, errors.New("missing FROM clause")
+		}
+		if depth == 0 && t.is("FROM") {
+			break
+		}
+		if t.is("(") || t.is("[") {
+			depth++
+		} else if t.is(")") || t.is("]") {
+			depth--
+		}
+		p.next()
+	}
+	var projectionEnd = p.pos
+	p.next()
+	if t := p.next(); t.kind != sqlTokenIdent || !strings.EqualFold(t.text, "S3Object") {
+		return nil, fmt.Errorf("expected S3Object but found %v", t)
+	}
+	if p.peek().is("[") || p.peek().is(".") {
+		if stmt.fromPath, err = p.parsePath(nil); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("AS") {
+		t := p.next()
+		if t.kind != sqlTokenIdent {
+			return nil, fmt.Errorf("expected alias but found %v", t)
+		}
+		stmt.tableAlias = t.text
+	} else if t := p.peek(); t.kind == sqlTokenIdent && (t.quoted || !sqlReservedWords[strings.ToUpper(t.text)]) {
+		stmt.tableAlias = p.next().text
+	}
+	p.tableAlias = stmt.tableAlias
+	if p.accept("WHERE") {
+		if stmt.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if len(p.aggregates) > 0 {
+			return nil, errors.New("aggregate functions are not allowed in WHERE clause")
+		}
+	}
+	if p.accept("LIMIT") {
+		t := p.next()
+		if t.kind != sqlTokenNumber {
+			return nil, fmt.Errorf("expected number of LIMIT but found %v", t)
+		}
+		if stmt.limit, err = strconv.ParseInt(t.text, 10, 64); err != nil || stmt.limit < 0 {
+			return nil, fmt.Errorf("invalid LIMIT %v", t.text)
+		}
+	}
+	if t := p.peek(); t.kind != sqlTokenEOF {
+		return nil, fmt.Errorf("unexpected %v", t)
+	}
+
+	// parse projection
+	var clauseEnd = p.pos
+	p.pos = projectionStart
+	p.tokens = append(append([]sqlToken{}, tokens[:projectionEnd]...), sqlToken{kind: sqlTokenEOF})
+	if p.accept("*") {
+		stmt.selectAll = true
+	} else {
+		for {
+			var aggregates = len(p.aggregates)
+			var column = &selectColumn{}
+			if column.expr, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			if len(p.aggregates) > aggregates {
+				if _, is := column.expr.(*sqlAggregate); !is || len(p.aggregates) > aggregates+1 {
+					return nil, errors.New("aggregate functions must be projected directly")
+				}
+			}
+			if p.accept("AS") {
+				t := p.next()
+				if t.kind != sqlTokenIdent {
+					return nil, fmt.Errorf("expected alias but found %v", t)
+				}
+				column.alias = t.text
+			} else if t := p.peek(); t.kind == sqlTokenIdent && (t.quoted || !sqlReservedWords[strings.ToUpper(t.text)]) {
+				column.alias = p.next().text
+			}
+			stmt.columns = append(stmt.columns, column)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if len(p.aggregates) > 0 && len(p.aggregates) != len(stmt.columns) {
+			return nil, errors.New("aggregate and non-aggregate projections can not be mixed")
+		}
+	}
+	if t := p.peek(); t.kind != sqlTokenEOF {
+		return nil, fmt.Errorf("unexpected %v", t)
+	}
+	p.pos = clauseEnd
+	stmt.aggregates = p.aggregates
+	return
+}
+
+func (p *sqlParser) parseExpr() (sqlExpr, error) {
+	return p.parseOr()
+}
+
+func (p *sqlParser) parseOr() (sqlExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		var right sqlExpr
+		if right, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		left = &sqlLogical{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parseAnd() (sqlExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		var right sqlExpr
+		if right, err = p.parseNot(); err != nil {
+			return nil, err
+		}
+		left = &sqlLogical{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parseNot() (sqlExpr, error) {
+	if p.accept("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlNot{x: x}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *sqlParser) parsePredicate() (sqlExpr, error) {
+	x, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("IS") {
+		var not = p.accept("NOT")
+		if !p.accept("NULL") && !p.accept("MISSING") {
+			return nil, fmt.Errorf("expected NULL but found %v", p.peek())
+		}
+		return &sqlIsNull{x: x, not: not}, nil
+	}
+	var not = p.accept("NOT")
+	switch {
+	case p.accept("LIKE"):
+		var like = &sqlLike{x: x, not: not}
+		if like.pattern, err = p.parseConcat(); err != nil {
+			return nil, err
+		}
+		if p.accept("ESCAPE") {
+			if like.escape, err = p.parseConcat(); err != nil {
+				return nil, err
+			}
+		}
+		return like, nil
+	case p.accept("BETWEEN"):
+		var between = &sqlBetween{x: x, not: not}
+		if between.lower, err = p.parseConcat(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("AND"); err != nil {
+			return nil, err
+		}
+		if between.upper, err = p.parseConcat(); err != nil {
+			return nil, err
+		}
+		return between, nil
+	case p.accept("IN"):
+		var in = &sqlIn{x: x, not: not}
+		if err = p.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			var item sqlExpr
+			if item, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+	if not {
+		return nil, fmt.Errorf("expected LIKE, BETWEEN or IN but found %v", p.peek())
+	}
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			var right sqlExpr
+			if right, err = p.parseConcat(); err != nil {
+				return nil, err
+			}
+			return &sqlComparison{op: op, left: x, right: right}, nil
+		}
+	}
+	return x, nil
+}
+
+func (p *sqlParser) parseConcat() (sqlExpr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		var right sqlExpr
+		if right, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+		left = &sqlConcat{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parseAdditive() (sqlExpr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op = p.peek()
+		if !op.is("+") && !op.is("-") {
+			return left, nil
+		}
+		p.next()
+		var right sqlExpr
+		if right, err = p.parseMultiplicative(); err != nil {
+			return nil, err
+		}
+		left = &sqlArithmetic{op: op.text, left: left, right: right}
+	}
+}
+
+func (p *sqlParser) parseMultiplicative() (sqlExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op = p.peek()
+		if !op.is("*") && !op.is("/") && !op.is("%") {
+			return left, nil
+		}
+		p.next()
+		var right sqlExpr
+		if right, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		left = &sqlArithmetic{op: op.text, left: left, right: right}
+	}
+}
+
+func (p *sqlParser) parseUnary() (sqlExpr, error) {
+	if p.accept("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlArithmetic{op: "-", left: &sqlLiteral{value: int64(0)}, right: x}, nil
+	}
+	if p.accept("+") {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *sqlParser) parsePrimary() (sqlExpr, error) {
+	var t = p.next()
+	switch t.kind {
+	case sqlTokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &sqlLiteral{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %v", t.text)
+		}
+		return &sqlLiteral{value: f}, nil
+	case sqlTokenString:
+		return &sqlLiteral{value: t.text}, nil
+	case sqlTokenSymbol:
+		if t.is("(") {
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	case sqlTokenIdent:
+		switch {
+		case t.is("TRUE"):
+			return &sqlLiteral{value: true}, nil
+		case t.is("FALSE"):
+			return &sqlLiteral{value: false}, nil
+		case t.is("NULL") || t.is("MISSING"):
+			return &sqlLiteral{value: nil}, nil
+		case t.is("CAST"):
+			return p.parseCast()
+		}
+		if !t.quoted && p.peek().is("(") {
+			return p.parseFunction(strings.ToUpper(t.text))
+		}
+		if !t.quoted && sqlReservedWords[strings.ToUpper(t.text)] {
+			break
+		}
+		path, err := p.parsePath([]selectPathElem{{name: t.text, quoted: t.quoted}})
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > 1 && p.tableAlias != "" && !path[0].isIndex && strings.EqualFold(path[0].name, p.tableAlias) {
+			path = path[1:]
+		}
+		return &sqlColumnRef{path: path}, nil
+	}
+	return nil, fmt.Errorf("unexpected %v", t)
+}
+
+func (p *sqlParser) parsePath(path []selectPathElem) ([]selectPathElem, error) {
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != sqlTokenIdent {
+				return nil, fmt.Errorf("expected field name but found %v", t)
+			}
+			path = append(path, selectPathElem{name: t.text, quoted: t.quoted})
+		case p.accept("["):
+			t := p.next()
+			switch {
+			case t.is("*"):
+				path = append(path, selectPathElem{isIndex: true, wildcard: true})
+			case t.kind == sqlTokenNumber:
+				index, err := strconv.Atoi(t.text)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid array index %v", t.text)
+				}
+				path = append(path, selectPathElem{isIndex: true, index: index})
+			case t.kind == sqlTokenString:
+				path = append(path, selectPathElem{name: t.text, quoted: true})
+			default:
+				return nil, fmt.Errorf("invalid array index %v", t)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
+
+var sqlCastTypes = map[string]string{
+	"INT": "INT", "INTEGER": "INT", "BIGINT": "INT", "SMALLINT": "INT",
+	"FLOAT": "FLOAT", "DECIMAL": "FLOAT", "NUMERIC": "FLOAT", "REAL": "FLOAT", "DOUBLE": "FLOAT",
+	"STRING": "STRING", "VARCHAR": "STRING", "CHAR": "STRING",
+	"BOOL": "BOOL", "BOOLEAN": "BOOL",
+}
+
+func (p *sqlParser) parseCast() (sqlExpr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("AS"); err != nil {
+		return nil, err
+	}
+	var t = p.next()
+	typ, supported := sqlCastTypes[strings.ToUpper(t.text)]
+	if t.kind != sqlTokenIdent || !supported {
+		return nil, fmt.Errorf("unsupported type %v", t)
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &sqlCast{x: x, typ: typ}, nil
+}
+
+var sqlFunctionArities = map[string][2]int{
+	"LOWER":            {1, 1},
+	"UPPER":            {1, 1},
+	"CHAR_LENGTH":      {1, 1},
+	"CHARACTER_LENGTH": {1, 1},
+	"COALESCE":         {1, -1},
+	"NULLIF":           {2, 2},
+	"ABS":              {1, 1},
+}
+
+func (p *sqlParser) parseFunction(name string) (sqlExpr, error) {
+	var err error
+	p.next() // (
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		var aggregates = len(p.aggregates)
+		var agg = &sqlAggregate{name: name}
+		if name == "COUNT" && p.accept("*") {
+			agg.star = true
+		} else if agg.arg, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if len(p.aggregates) > aggregates {
+			return nil, errors.New("aggregate functions can not be nested")
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		p.aggregates = append(p.aggregates, agg)
+		return agg, nil
+	case "SUBSTRING":
+		var fn = &sqlFunction{name: name}
+		var x sqlExpr
+		if x, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		fn.args = append(fn.args, x)
+		if p.accept(",") || p.accept("FROM") {
+			if x, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			fn.args = append(fn.args, x)
+			if p.accept(",") || p.accept("FOR") {
+				if x, err = p.parseExpr(); err != nil {
+					return nil, err
+				}
+				fn.args = append(fn.args, x)
+			}
+		} else {
+			return nil, errors.New("SUBSTRING requires start position")
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		return fn, nil
+	case "TRIM":
+		var fn = &sqlFunction{name: name, trimMode: "BOTH"}
+		for _, mode := range []string{"BOTH", "LEADING", "TRAILING"} {
+			if p.accept(mode) {
+				fn.trimMode = mode
+			}
+		}
+		var x sqlExpr
+		if !p.peek().is("FROM") {
+			if x, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		if p.accept("FROM") {
+			fn.trimChars = x
+			if x, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		if x == nil {
+			return nil, errors.New("TRIM requires an argument")
+		}
+		fn.args = []sqlExpr{x}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		return fn, nil
+	}
+	arity, supported := sqlFunctionArities[name]
+	if !supported {
+		return nil, fmt.Errorf("unsupported function %v", name)
+	}
+	var fn = &sqlFunction{name: name}
+	if !p.peek().is(")") {
+		for {
+			var x sqlExpr
+			if x, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			fn.args = append(fn.args, x)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(fn.args) < arity[0] || arity[1] >= 0 && len(fn.args) > arity[1] {
+		return nil, fmt.Errorf("invalid number of arguments for %v", name)
+	}
+	return fn, nil
+}
+
+// selectRecord is a record read from the object.
+type selectRecord interface {
+	// field returns the value of the top level field, and false if the field is missing.
+	field(name string, quoted bool) (interface{}, bool)
+	// fields returns all the fields of the record in order, which is projected by "SELECT *".
+	fields() []selectField
+}
+
+type sqlExpr interface {
+	eval(record selectRecord) (interface{}, error)
+}
+
+type sqlLiteral struct {
+	value interface{}
+}
+
+func (e *sqlLiteral) eval(selectRecord) (interface{}, error) {
+	return e.value, nil
+}
+
+type sqlColumnRef struct {
+	path []selectPathElem
+}
+
+func (e *sqlColumnRef) eval(record selectRecord) (interface{}, error) {
+	var first = e.path[0]
+	if first.isIndex {
+		return nil, nil
+	}
+	value, found := record.field(first.name, first.quoted)
+	if !found {
+		return nil, nil
+	}
+	for _, elem := range e.path[1:] {
+		if value = lookupSelectPath(value, elem); value == nil {
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+// lookupSelectPath returns the value of the child of a JSON value, or nil if it is missing.
+func lookupSelectPath(value interface{}, elem selectPathElem) interface{} {
+	switch v := value.(type) {
+	case selectObject:
+		if elem.isIndex {
+			return nil
+		}
+		child, _ := v.field(elem.name, elem.quoted)
+		return child
+	case []interface{}:
+		if !elem.isIndex || elem.wildcard || elem.index >= len(v) {
+			return nil
+		}
+		return v[elem.index]
+	}
+	return nil
+}
+
+type sqlLogical struct {
+	or          bool
+	left, right sqlExpr
+}
+
+func (e *sqlLogical) eval(record selectRecord) (interface{}, error) {
+	l, err := evalSQLBool(e.left, record)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	if l != nil && *l == e.or {
+		return e.or, nil
+	}
+	r, err := evalSQLBool(e.right, record)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil && *r == e.or {
+		return e.or, nil
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	return !e.or, nil
+}
+
+type sqlNot struct {
+	x sqlExpr
+}
+
+func (e *sqlNot) eval(record selectRecord) (interface{}, error) {
+	b, err := evalSQLBool(e.x, record)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return !*b, nil
+}
+
+// evalSQLBool evaluates a condition, whose result is nil if it is unknown.
+func evalSQLBool(x sqlExpr, record selectRecord) (*bool, error) {
+	v, err := x.eval(record)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	var b bool
+	switch t := v.(type) {
+	case bool:
+		b = t
+	case string:
+		if b, err = strconv.ParseBool(t); err != nil {
+			return nil, fmt.Errorf("value '%v' is not a boolean", t)
+		}
+	default:
+		return nil, fmt.Errorf("value %v is not a boolean", v)
+	}
+	return &b, nil
+}
+
+type sqlIsNull struct {
+	x   sqlExpr
+	not bool
+}
+
+func (e *sqlIsNull) eval(record selectRecord) (interface{}, error) {
+	v, err := e.x.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.not, nil
+}
+
+type sqlComparison struct {
+	op          string
+	left, right sqlExpr
+}
+
+func (e *sqlComparison) eval(record selectRecord) (interface{}, error) {
+	l, err := e.left.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.right.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	c, comparable := compareSQLValues(l, r)
+	if !comparable {
+		return nil, nil
+	}
+	switch e.op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type sqlBetween struct {
+	x, lower, upper sqlExpr
+	not             bool
+}
+
+func (e *sqlBetween) eval(record selectRecord) (interface{}, error) {
+	var values [3]interface{}
+	for i, x := range []sqlExpr{e.x, e.lower, e.upper} {
+		var err error
+		if values[i], err = x.eval(record); err != nil {
+			return nil, err
+		}
+	}
+	lower, ok1 := compareSQLValues(values[0], values[1])
+	upper, ok2 := compareSQLValues(values[0], values[2])
+	if !ok1 || !ok2 {
+		return nil, nil
+	}
+	return (lower >= 0 && upper <= 0) != e.not, nil
+}
+
+type sqlIn struct {
+	x    sqlExpr
+	list []sqlExpr
+	not  bool
+}
+
+func (e *sqlIn) eval(record selectRecord) (interface{}, error) {
+	v, err := e.x.eval(record)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	var unknown bool
+	for _, item := range e.list {
+		var iv interface{}
+		if iv, err = item.eval(record); err != nil {
+			return nil, err
+		}
+		c, comparable := compareSQLValues(v, iv)
+		if !comparable {
+			unknown = true
+			continue
+		}
+		if c == 0 {
+			return !e.not, nil
+		}
+	}
+	if unknown {
+		return nil, nil
+	}
+	return e.not, nil
+}
+
+type sqlLike struct {
+	x, pattern, escape sqlExpr
+	not                bool
+}
+
+func (e *sqlLike) eval(record selectRecord) (interface{}, error) {
+	var values [3]interface{}
+	for i, x := range []sqlExpr{e.x, e.pattern, e.escape} {
+		if x == nil {
+			continue
+		}
+		var err error
+		if values[i], err = x.eval(record); err != nil {
+			return nil, err
+		}
+		if values[i] == nil {
+			return nil, nil
+		}
+	}
+	var escape rune
+	if e.escape != nil {
+		s := formatSQLString(values[2])
+		if utf8.RuneCountInString(s) != 1 {
+			return nil, fmt.Errorf("invalid escape character '%v'", s)
+		}
+		escape, _ = utf8.DecodeRuneInString(s)
+	}
+	matched := matchSQLLike([]rune(formatSQLString(values[0])), []rune(formatSQLString(values[1])), escape)
+	return matched != e.not, nil
+}
+
+// matchSQLLike matches the text with the pattern, in which '%' matches any sequence
+// and '_' matches any single character.
+func matchSQLLike(text, pattern []rune, escape rune) bool {
+	for len(pattern) > 0 {
+		var c = pattern[0]
+		switch {
+		case escape != 0 && c == escape && len(pattern) > 1:
+			if len(text) == 0 || text[0] != pattern[1] {
+				return false
+			}
+			text, pattern = text[1:], pattern[2:]
+		case c == '%':
+			for len(pattern) > 0 && pattern[0] == '%' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(text); i++ {
+				if matchSQLLike(text[i:], pattern, escape) {
+					return true
+				}
+			}
+			return false
+		case c == '_':
+			if len(text) == 0 {
+				return false
+			}
+			text, pattern = text[1:], pattern[1:]
+		default:
+			if len(text) == 0 || text[0] != c {
+				return false
+			}
+			text, pattern = text[1:], pattern[1:]
+		}
+	}
+	return len(text) == 0
+}
+
+type sqlConcat struct {
+	left, right sqlExpr
+}
+
+func (e *sqlConcat) eval(record selectRecord) (interface{}, error) {
+	l, err := e.left.eval(record)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	r, err := e.right.eval(record)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	return formatSQLString(l) + formatSQLString(r), nil
+}
+
+type sqlArithmetic struct {
+	op          string
+	left, right sqlExpr
+}
+
+func (e *sqlArithmetic) eval(record selectRecord) (interface{}, error) {
+	l, err := e.left.eval(record)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	r, err := e.right.eval(record)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	ln, ok1 := toSQLNumber(l)
+	rn, ok2 := toSQLNumber(r)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("invalid operands of %v: %v, %v", e.op, l, r)
+	}
+	li, lIsInt := ln.(int64)
+	ri, rIsInt := rn.(int64)
+	if lIsInt && rIsInt {
+		switch e.op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if e.op == "/" {
+				return li / ri, nil
+			}
+			return li % ri, nil
+		}
+	}
+	lf, rf := toSQLFloat(ln), toSQLFloat(rn)
+	switch e.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return lf / rf, nil
+	default:
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+}
+
+type sqlCast struct {
+	x   sqlExpr
+	typ string
+}
+
+func (e *sqlCast) eval(record selectRecord) (interface{}, error) {
+	v, err := e.x.eval(record)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch e.typ {
+	case "INT":
+		switch t := v.(type) {
+		case bool:
+			if t {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case float64:
+			return int64(t), nil
+		}
+		n, ok := toSQLNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("can not cast '%v' to INT", v)
+		}
+		if f, isFloat := n.(float64); isFloat {
+			return int64(f), nil
+		}
+		return n, nil
+	case "FLOAT":
+		if b, is := v.(bool); is {
+			if b {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		}
+		n, ok := toSQLNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("can not cast '%v' to FLOAT", v)
+		}
+		return toSQLFloat(n), nil
+	case "BOOL":
+		switch t := v.(type) {
+		case bool:
+			return t, nil
+		case int64:
+			return t != 0, nil
+		case float64:
+			return t != 0, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(t))
+			if err != nil {
+				return nil, fmt.Errorf("can not cast '%v' to BOOL", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("can not cast %v to BOOL", v)
+	default:
+		return formatSQLString(v), nil
+	}
+}
+
+type sqlFunction struct {
+	name      string
+	args      []sqlExpr
+	trimMode  string
+	trimChars sqlExpr
+}
+
+func (e *sqlFunction) eval(record selectRecord) (interface{}, error) {
+	var args = make([]interface{}, len(e.args))
+	for i, x := range e.args {
+		var err error
+		if args[i], err = x.eval(record); err != nil {
+			return nil, err
+		}
+	}
+	switch e.name {
+	case "COALESCE":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if c, comparable := compareSQLValues(args[0], args[1]); comparable && c == 0 {
+			return nil, nil
+		}
+		return args[0], nil
+	}
+	for _, arg := range args {
+		if arg == nil {
+			return nil, nil
+		}
+	}
+	switch e.name {
+	case "LOWER":
+		return strings.ToLower(formatSQLString(args[0])), nil
+	case "UPPER":
+		return strings.ToUpper(formatSQLString(args[0])), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return int64(utf8.RuneCountInString(formatSQLString(args[0]))), nil
+	case "ABS":
+		n, ok := toSQLNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("invalid argument of ABS: %v", args[0])
+		}
+		if i, isInt := n.(int64); isInt {
+			if i < 0 {
+				return -i, nil
+			}
+			return i, nil
+		}
+		return math.Abs(n.(float64)), nil
+	case "TRIM":
+		var cutset = " "
+		if e.trimChars != nil {
+			chars, err := e.trimChars.eval(record)
+			if err != nil || chars == nil {
+				return nil, err
+			}
+			cutset = formatSQLString(chars)
+		}
+		var s = formatSQLString(args[0])
+		switch e.trimMode {
+		case "LEADING":
+			return strings.TrimLeft(s, cutset), nil
+		case "TRAILING":
+			return strings.TrimRight(s, cutset), nil
+		}
+		return strings.Trim(s, cutset), nil
+	case "SUBSTRING":
+		var runes = []rune(formatSQLString(args[0]))
+		var bounds [2]int64
+		for i, arg := range args[1:] {
+			n, ok := toSQLNumber(arg)
+			if !ok {
+				return nil, fmt.Errorf("invalid argument of SUBSTRING: %v", arg)
+			}
+			if f, isFloat := n.(float64); isFloat {
+				n = int64(f)
+			}
+			bounds[i] = n.(int64)
+		}
+		// positions start from 1, and the ones before the string are counted in the length
+		var start = bounds[0]
+		var end = int64(len(runes)) + 1
+		if len(args) == 3 {
+			if bounds[1] < 0 {
+				return nil, errors.New("negative length of SUBSTRING")
+			}
+			if start+bounds[1] < end {
+				end = start + bounds[1]
+			}
+		}
+		if start < 1 {
+			start = 1
+		}
+		if start >= end {
+			return "", nil
+		}
+		return string(runes[start-1 : end-1]), nil
+	}
+	return nil, fmt.Errorf("unsupported function %v", e.name)
+}
+
+// sqlAggregate is an aggregate function, which accumulates the values of all the selected records.
+type sqlAggregate struct {
+	name  string
+	arg   sqlExpr
+	star  bool
+	count int64
+	value interface{}
+}
+
+func (e *sqlAggregate) eval(selectRecord) (interface{}, error) {
+	return nil, fmt.Errorf("aggregate function %v is not allowed here", e.name)
+}
+
+func (e *sqlAggregate) accumulate(record selectRecord) error {
+	if e.star {
+		e.count++
+		return nil
+	}
+	v, err := e.arg.eval(record)
+	if err != nil || v == nil {
+		return err
+	}
+	e.count++
+	switch e.name {
+	case "SUM", "AVG":
+		n, ok := toSQLNumber(v)
+		if !ok {
+			return fmt.Errorf("invalid argument of %v: %v", e.name, v)
+		}
+		if e.value == nil {
+			e.value = n
+			return nil
+		}
+		si, sIsInt := e.value.(int64)
+		ni, nIsInt := n.(int64)
+		if sIsInt && nIsInt {
+			e.value = si + ni
+		} else {
+			e.value = toSQLFloat(e.value) + toSQLFloat(n)
+		}
+	case "MIN", "MAX":
+		if n, ok := toSQLNumber(v); ok {
+			v = n
+		}
+		if e.value == nil {
+			e.value = v
+			return nil
+		}
+		c, comparable := compareSQLValues(v, e.value)
+		if !comparable {
+			return fmt.Errorf("invalid argument of %v: %v", e.name, v)
+		}
+		if e.name == "MIN" && c < 0 || e.name == "MAX" && c > 0 {
+			e.value = v
+		}
+	}
+	return nil
+}
+
+func (e *sqlAggregate) result() interface{} {
+	switch e.name {
+	case "COUNT":
+		return e.count
+	case "AVG":
+		if e.value == nil {
+			return nil
+		}
+		return toSQLFloat(e.value) / float64(e.count)
+	}
+	return e.value
+}
+
+// toSQLNumber converts the value into int64 or float64, in which strings are parsed.
+func toSQLNumber(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case int64, float64:
+		return t, true
+	case string:
+		s := strings.TrimSpace(t)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toSQLFloat(n interface{}) float64 {
+	if i, is := n.(int64); is {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+// compareSQLValues compares two values. Values of CSV records are strings, so a string is
+// compared with a number as number. Comparing NULL or values of different types is unknown.
+func compareSQLValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	_, aIsString := a.(string)
+	_, bIsString := b.(string)
+	if aIsString && bIsString {
+		return strings.Compare(a.(string), b.(string)), true
+	}
+	if ab, is := a.(bool); is {
+		bb, ok := b.(bool)
+		if !ok {
+			if s, isString := b.(string); isString {
+				var err error
+				if bb, err = strconv.ParseBool(s); err != nil {
+					return 0, false
+				}
+			} else {
+				return 0, false
+			}
+		}
+		switch {
+		case ab == bb:
+			return 0, true
+		case !ab:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	if _, is := b.(bool); is {
+		c, ok := compareSQLValues(b, a)
+		return -c, ok
+	}
+	an, ok1 := toSQLNumber(a)
+	bn, ok2 := toSQLNumber(b)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	ai, aIsInt := an.(int64)
+	bi, bIsInt := bn.(int64)
+	if aIsInt && bIsInt {
+		switch {
+		case ai < bi:
+			return -1, true
+		case ai > bi:
+			return 1, true
+		}
+		return 0, true
+	}
+	af, bf := toSQLFloat(an), toSQLFloat(bn)
+	switch {
+	case af < bf:
+		return -1, true
+	case af > bf:
+		return 1, true
+	}
+	return 0, true
+}
+
+// formatSQLString formats a value as text, in which nested values are formatted in JSON.
+func formatSQLString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	var b strings.Builder
+	writeSelectJSONValue(&b, v)
+	return b.String()
+}
@@ -0,0 +1,401 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+)
+
+// runSelectTest runs the expression over the input, and returns the records and the events.
+func runSelectTest(t *testing.T, expression string, input *SelectInputSerialization, output *SelectOutputSerialization, data string) (records string, events []eventstream.Message) {
+	var request = &SelectObjectContentRequest{
+		Expression:          expression,
+		ExpressionType:      SelectExpressionTypeSQL,
+		InputSerialization:  *input,
+		OutputSerialization: *output,
+	}
+	if errorCode := request.validate(); errorCode != nil {
+		t.Fatalf("validate request fail: %v", errorCode.ErrorCode)
+	}
+	stmt, err := parseSelectStatement(expression)
+	if err != nil {
+		t.Fatalf("parse expression(%v) fail: %v", expression, err)
+	}
+	var buf bytes.Buffer
+	var processed = &selectCountingReader{r: strings.NewReader(data)}
+	var executor = &selectExecutor{
+		stmt:           stmt,
+		events:         newSelectEventWriter(&buf),
+		bytesScanned:   func() int64 { return processed.n },
+		bytesProcessed: func() int64 { return processed.n },
+	}
+	if request.InputSerialization.CSV != nil {
+		executor.reader = newCSVRecordReader(processed, request.InputSerialization.CSV)
+	} else {
+		executor.reader = newJSONRecordReader(processed, stmt.fromPath)
+	}
+	if request.OutputSerialization.CSV != nil {
+		executor.writer = &csvRecordWriter{opt: request.OutputSerialization.CSV}
+	} else {
+		executor.writer = &jsonRecordWriter{opt: request.OutputSerialization.JSON}
+	}
+	if err = executor.run(); err != nil {
+		t.Fatalf("run expression(%v) fail: %v", expression, err)
+	}
+	for {
+		msg, err := eventstream.Decode(&buf, nil)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decode event fail: %v", err)
+		}
+		if msg.Headers.Get(":event-type").String() == "Records" {
+			records += string(msg.Payload)
+		}
+		events = append(events, msg)
+	}
+	return
+}
+
+const selectTestCSV = "name,age,city\n" +
+	"alice,30,\"New York, NY\"\n" +
+	"bob,25,Boston\r\n" +
+	"\n" +
+	"carol,35,\"Chicago \"\"IL\"\"\"\n"
+
+func TestSelectCSV(t *testing.T) {
+	var input = &SelectInputSerialization{CSV: &SelectCSVInput{FileHeaderInfo: SelectFileHeaderUse}}
+	var output = &SelectOutputSerialization{CSV: &SelectCSVOutput{}}
+	var cases = []struct {
+		expression string
+		expected   string
+	}{
+		{"SELECT * FROM S3Object", "alice,30,\"New York, NY\"\nbob,25,Boston\ncarol,35,\"Chicago \"\"IL\"\"\"\n"},
+		{"SELECT s.name FROM S3Object s WHERE s.age > 28", "alice\ncarol\n"},
+		{"SELECT name, CAST(age AS INT) + 1 FROM S3Object WHERE city LIKE 'B%'", "bob,26\n"},
+		{"SELECT _1 FROM S3Object WHERE _2 BETWEEN 25 AND 30 LIMIT 1", "alice\n"},
+		{"SELECT UPPER(name) FROM S3Object WHERE name IN ('bob', 'carol') AND NOT age = 35", "BOB\n"},
+		{"SELECT COUNT(*), SUM(age), MAX(name), AVG(age) FROM S3Object", "3,90,carol,30\n"},
+		{"SELECT SUBSTRING(name FROM 2 FOR 3), name || '@' || city FROM S3Object WHERE age < 30", "ob,bob@Boston\n"},
+		{"SELECT \"name\" FROM S3Object WHERE missing IS NULL LIMIT 2", "alice\nbob\n"},
+	}
+	for _, c := range cases {
+		records, events := runSelectTest(t, c.expression, input, output, selectTestCSV)
+		if records != c.expected {
+			t.Fatalf("expression(%v): expect records %q actual %q", c.expression, c.expected, records)
+		}
+		if len(events) < 2 {
+			t.Fatalf("expression(%v): too few events %v", c.expression, len(events))
+		}
+		if eventType := events[len(events)-1].Headers.Get(":event-type").String(); eventType != "End" {
+			t.Fatalf("expression(%v): expect End event actual %v", c.expression, eventType)
+		}
+		var stats SelectStats
+		if err := xml.Unmarshal(events[len(events)-2].Payload, &stats); err != nil {
+			t.Fatalf("expression(%v): unmarshal stats fail: %v", c.expression, err)
+		}
+		if stats.BytesReturned != int64(len(records)) || stats.BytesScanned > int64(len(selectTestCSV)) {
+			t.Fatalf("expression(%v): stats mismatch: %+v", c.expression, stats)
+		}
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	var output = &SelectOutputSerialization{JSON: &SelectJSONOutput{}}
+	var lines = `{"id": 1, "user": {"name": "alice", "tags": ["a", "b"]}, "score": 9.5}
+{"id": 2, "user": {"name": "bob", "tags": []}, "score": 7}
+{"id": 3, "user": {"name": "carol"}, "score": null}
+`
+	var cases = []struct {
+		expression string
+		data       string
+		jsonType   string
+		expected   string
+	}{
+		{"SELECT * FROM S3Object s WHERE s.id = 2", lines, SelectJSONTypeLines,
+			`{"id":2,"user":{"name":"bob","tags":[]},"score":7}` + "\n"},
+		{"SELECT s.user.name AS n, s.user.tags[1] FROM S3Object[*] s WHERE s.score >= 7", lines, SelectJSONTypeLines,
+			`{"n":"alice","_2":"b"}` + "\n" + `{"n":"bob","_2":null}` + "\n"},
+		{"SELECT COUNT(s.score) AS c, MIN(s.score) AS m FROM S3Object s", lines, SelectJSONTypeLines,
+			`{"c":2,"m":7}` + "\n"},
+		{"SELECT d.id FROM S3Object[*].items[*] d WHERE d.id <> 1", `{"items": [{"id": 1}, {"id": 2}, {"id": 3}]}`, SelectJSONTypeDocument,
+			`{"id":2}` + "\n" + `{"id":3}` + "\n"},
+	}
+	for _, c := range cases {
+		var input = &SelectInputSerialization{JSON: &SelectJSONInput{Type: c.jsonType}}
+		records, _ := runSelectTest(t, c.expression, input, output, c.data)
+		if records != c.expected {
+			t.Fatalf("expression(%v): expect records %q actual %q", c.expression, c.expected, records)
+		}
+	}
+}
+
+func TestSelectCSVOptions(t *testing.T) {
+	var input = &SelectInputSerialization{CSV: &SelectCSVInput{
+		FileHeaderInfo:  SelectFileHeaderIgnore,
+		FieldDelimiter:  "|",
+		RecordDelimiter: ";",
+		Comments:        "#",
+	}}
+	var output = &SelectOutputSerialization{CSV: &SelectCSVOutput{QuoteFields: SelectQuoteFieldsAlways, FieldDelimiter: "\t"}}
+	records, _ := runSelectTest(t, "SELECT _2, _1 FROM S3Object", input, output, "a|b;#comment;1|x;2|\"y;z\"")
+	if expected := "\"x\"\t\"1\"\n\"y;z\"\t\"2\"\n"; records != expected {
+		t.Fatalf("expect records %q actual %q", expected, records)
+	}
+}
+
+func TestSelectParseFailure(t *testing.T) {
+	var expressions = []string{
+		"",
+		"SELECT FROM S3Object",
+		"SELECT * FROM Table",
+		"SELECT * FROM S3Object WHERE",
+		"SELECT name, COUNT(*) FROM S3Object",
+		"SELECT * FROM S3Object WHERE COUNT(*) > 1",
+		"SELECT * FROM S3Object LIMIT -1",
+		"SELECT 'abc FROM S3Object",
+		"SELECT UNKNOWN(name) FROM S3Object",
+		"SELECT CAST(name AS DATE) FROM S3Object",
+	}
+	for _, expression := range expressions {
+		if _, err := parseSelectStatement(expression); err == nil {
+			t.Fatalf("expression(%v): expect parse failure", expression)
+		}
+	}
+}
+
+func TestSelectRequestValidate(t *testing.T) {
+	var valid = func() *SelectObjectContentRequest {
+		return &SelectObjectContentRequest{
+			Expression:          "SELECT * FROM S3Object",
+			ExpressionType:      SelectExpressionTypeSQL,
+			InputSerialization:  SelectInputSerialization{CSV: &SelectCSVInput{}},
+			OutputSerialization: SelectOutputSerialization{JSON: &SelectJSONOutput{}},
+		}
+	}
+	if errorCode := valid().validate(); errorCode != nil {
+		t.Fatalf("validate fail: %v", errorCode.ErrorCode)
+	}
+	var cases = []struct {
+		modify   func(req *SelectObjectContentRequest)
+		expected *ErrorCode
+	}{
+		{func(req *SelectObjectContentRequest) { req.ExpressionType = "XPATH" }, InvalidExpressionType},
+		{func(req *SelectObjectContentRequest) { req.Expression = " " }, MissingRequiredParameter},
+		{func(req *SelectObjectContentRequest) {
+			req.InputSerialization.JSON = &SelectJSONInput{Type: SelectJSONTypeLines}
+		}, InvalidRequestParameter},
+		{func(req *SelectObjectContentRequest) { req.InputSerialization.CompressionType = "ZSTD" }, InvalidCompressionFormat},
+		{func(req *SelectObjectContentRequest) { req.InputSerialization.CSV.FieldDelimiter = "||" }, InvalidRequestParameter},
+		{func(req *SelectObjectContentRequest) { req.OutputSerialization.CSV = &SelectCSVOutput{} }, InvalidRequestParameter},
+		{func(req *SelectObjectContentRequest) {
+			req.InputSerialization = SelectInputSerialization{Parquet: &SelectParquetInput{}, CompressionType: SelectCompressionGzip}
+		}, InvalidCompressionFormat},
+	}
+	for i, c := range cases {
+		var req = valid()
+		c.modify(req)
+		if errorCode := req.validate(); errorCode != c.expected {
+			t.Fatalf("case(%v): expect %v actual %v", i, c.expected, errorCode)
+		}
+	}
+}
+
+func TestMatchSQLLike(t *testing.T) {
+	var cases = []struct {
+		text, pattern string
+		escape        rune
+		matched       bool
+	}{
+		{"hello", "h%o", 0, true},
+		{"hello", "h_llo", 0, true},
+		{"hello", "h_lo", 0, false},
+		{"50%", "50!%", '!', true},
+		{"500", "50!%", '!', false},
+		{"", "%", 0, true},
+	}
+	for _, c := range cases {
+		if matched := matchSQLLike([]rune(c.text), []rune(c.pattern), c.escape); matched != c.matched {
+			t.Fatalf("text(%v) pattern(%v): expect %v actual %v", c.text, c.pattern, c.matched, matched)
+		}
+	}
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	// literal "abcd" followed by a copy of 8 bytes at offset 4
+	var src = []byte{12, 3 << 2, 'a', 'b', 'c', 'd', 1 | (8-4)<<2, 4}
+	decoded, err := decodeSnappy(src)
+	if err != nil {
+		t.Fatalf("decode snappy fail: %v", err)
+	}
+	if string(decoded) != "abcdabcdabcd" {
+		t.Fatalf("decoded mismatch: %q", decoded)
+	}
+	if _, err = decodeSnappy([]byte{12, 3 << 2, 'a', 'b', 'c', 'd', 1 | (8-4)<<2, 5}); err == nil {
+		t.Fatalf("expect corrupted data")
+	}
+}
+
+type thriftField struct {
+	id    int16
+	typ   byte
+	value interface{}
+}
+
+type thriftList struct {
+	elemType byte
+	items    []interface{}
+}
+
+func encodeThriftVarint(b *bytes.Buffer, v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], uint64(v<<1^v>>63))])
+}
+
+func encodeThriftValue(b *bytes.Buffer, typ byte, value interface{}) {
+	switch typ {
+	case 5, 6:
+		encodeThriftVarint(b, int64(value.(int)))
+	case 8:
+		var buf [binary.MaxVarintLen64]byte
+		b.Write(buf[:binary.PutUvarint(buf[:], uint64(len(value.(string))))])
+		b.WriteString(value.(string))
+	case 9:
+		list := value.(thriftList)
+		b.WriteByte(byte(len(list.items))<<4 | list.elemType)
+		for _, item := range list.items {
+			encodeThriftValue(b, list.elemType, item)
+		}
+	case 12:
+		b.Write(value.([]byte))
+	}
+}
+
+func encodeThriftStruct(fields ...thriftField) []byte {
+	var b bytes.Buffer
+	var last int16
+	for _, f := range fields {
+		b.WriteByte(byte(f.id-last)<<4 | f.typ)
+		last = f.id
+		encodeThriftValue(&b, f.typ, f.value)
+	}
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+func TestParquetRecordReader(t *testing.T) {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	// column "id": required int64 of 1, 2, 3
+	var idData = make([]byte, 24)
+	for i := 0; i < 3; i++ {
+		binary.LittleEndian.PutUint64(idData[i*8:], uint64(i+1))
+	}
+	// column "name": optional byte array of "a", null, "c", whose definition levels are bit packed
+	var nameData = []byte{2, 0, 0, 0, 3, 5, 1, 0, 0, 0, 'a', 1, 0, 0, 0, 'c'}
+
+	var chunks [][]byte
+	for _, data := range [][]byte{idData, nameData} {
+		var chunk = encodeThriftStruct(
+			thriftField{1, 5, parquetDataPage},
+			thriftField{2, 5, len(data)},
+			thriftField{3, 5, len(data)},
+			thriftField{5, 12, encodeThriftStruct(
+				thriftField{1, 5, 3},
+				thriftField{2, 5, parquetEncodingPlain},
+				thriftField{3, 5, parquetEncodingRLE},
+				thriftField{4, 5, parquetEncodingRLE},
+			)},
+		)
+		chunks = append(chunks, append(chunk, data...))
+	}
+	var columnChunks []interface{}
+	for i, chunk := range chunks {
+		var physicalType = []int{parquetInt64, parquetByteArray}[i]
+		var offset = file.Len()
+		file.Write(chunk)
+		columnChunks = append(columnChunks, encodeThriftStruct(
+			thriftField{2, 6, offset},
+			thriftField{3, 12, encodeThriftStruct(
+				thriftField{1, 5, physicalType},
+				thriftField{2, 9, thriftList{5, []interface{}{parquetEncodingPlain}}},
+				thriftField{3, 9, thriftList{8, []interface{}{[]string{"id", "name"}[i]}}},
+				thriftField{4, 5, parquetCodecUncompressed},
+				thriftField{5, 6, 3},
+				thriftField{6, 6, len(chunk)},
+				thriftField{7, 6, len(chunk)},
+				thriftField{9, 6, offset},
+			)},
+		))
+	}
+	var footer = encodeThriftStruct(
+		thriftField{1, 5, 1},
+		thriftField{2, 9, thriftList{12, []interface{}{
+			encodeThriftStruct(thriftField{4, 8, "schema"}, thriftField{5, 5, 2}),
+			encodeThriftStruct(thriftField{1, 5, parquetInt64}, thriftField{3, 5, parquetRequired}, thriftField{4, 8, "id"}),
+			encodeThriftStruct(thriftField{1, 5, parquetByteArray}, thriftField{3, 5, parquetOptional}, thriftField{4, 8, "name"}),
+		}}},
+		thriftField{3, 6, 3},
+		thriftField{4, 9, thriftList{12, []interface{}{
+			encodeThriftStruct(thriftField{1, 9, thriftList{12, columnChunks}}, thriftField{2, 6, 0}, thriftField{3, 6, 3}),
+		}}},
+	)
+	file.Write(footer)
+	var length = make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+	file.Write(length)
+	file.WriteString(parquetMagic)
+
+	reader, err := newParquetRecordReader(bytes.NewReader(file.Bytes()), int64(file.Len()))
+	if err != nil {
+		t.Fatalf("open parquet fail: %v", err)
+	}
+	stmt, err := parseSelectStatement("SELECT * FROM S3Object s WHERE s.id > 1")
+	if err != nil {
+		t.Fatalf("parse expression fail: %v", err)
+	}
+	var buf bytes.Buffer
+	var executor = &selectExecutor{
+		stmt:           stmt,
+		reader:         reader,
+		writer:         &jsonRecordWriter{opt: &SelectJSONOutput{RecordDelimiter: "\n"}},
+		events:         newSelectEventWriter(&buf),
+		bytesScanned:   func() int64 { return 0 },
+		bytesProcessed: func() int64 { return 0 },
+	}
+	if err = executor.run(); err != nil {
+		t.Fatalf("run select fail: %v", err)
+	}
+	msg, err := eventstream.Decode(&buf, nil)
+	if err != nil {
+		t.Fatalf("decode event fail: %v", err)
+	}
+	if expected := `{"id":2,"name":null}` + "\n" + `{"id":3,"name":"c"}` + "\n"; string(msg.Payload) != expected {
+		t.Fatalf("expect records %q actual %q", expected, msg.Payload)
+	}
+
+	if _, err = newParquetRecordReader(strings.NewReader("PAR1 not a parquet file"), 23); err == nil {
+		t.Fatalf("expect parquet parsing error")
+	}
+}
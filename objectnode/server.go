@@ -19,17 +19,22 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/kms"
 
 	"github.com/cubefs/cubefs/cmd/common"
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/audit"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/ratelimit"
 	"github.com/gorilla/mux"
 )
 
@@ -77,10 +82,164 @@ const (
 	// The configuration in the example will allow ObjectNode to automatically resolve "* .object.chubao.io".
 	configDomains = "domains"
 
+	// The character creation array configuration item is used to configure the domain names of the
+	// website endpoints, on which the buckets with the website configuration are served as static
+	// websites to the anonymous users. The website domains should differ from the domains of the
+	// object storage interface.
+	// Example:
+	//		{
+	//			"websiteDomains": [
+	//				"website.chubao.io"
+	//			]
+	//		}
+	// The configuration in the example will serve the bucket "docs" on "docs.website.chubao.io".
+	configWebsiteDomains = "websiteDomains"
+
 	disabledActions               = "disabledActions"
 	configSignatureIgnoredActions = "signatureIgnoredActions"
+
+	// Integer type configuration item, used to configure the interval in seconds of the scans of the
+	// lifecycle rules of the buckets. The lifecycle rules are not executed by the ObjectNode if it is
+	// not configured, and it should be configured on only one ObjectNode of the cluster.
+	// Example:
+	//		{
+	//			"lifecycleInterval": 3600
+	//		}
+	configLifecycleInterval = "lifecycleInterval"
+
+	// Integer type configuration item, used to configure the interval in seconds of the scans of the
+	// inventory configurations of the buckets, whose reports are generated once they are due by their
+	// schedules. The inventory reports are not generated by the ObjectNode if it is not configured, and
+	// it should be configured on only one ObjectNode of the cluster.
+	// Example:
+	//		{
+	//			"inventoryInterval": 3600
+	//		}
+	configInventoryInterval = "inventoryInterval"
+
+	// String type configuration item, used to configure the file of the master keys of the server-side
+	// encryption (SSE-S3), a line "<id> <hex key>" of 32 bytes per key. The key with the largest id wraps
+	// the data keys of the new objects. The SSE-S3 is not supported if it is not configured.
+	// Example:
+	//		{
+	//			"sseKeyFile": "/cfs/conf/sse.keys"
+	//		}
+	configSSEKeyFile = "sseKeyFile"
+
+	// String type configuration items, used to configure the endpoint of the external KMS and its key
+	// used if the request gives none (SSE-KMS). The provider of the KMS is the AWS KMS by default, or
+	// "vault" or "http", with the other "kms*" items of the package kms. The SSE-KMS is not supported
+	// if the endpoint is not configured.
+	// Example:
+	//		{
+	//			"kmsProvider": "aws",
+	//			"kmsEndpoint": "http://kms.chubao.io:8080",
+	//			"kmsKeyId": "alias/objectnode"
+	//		}
+	configKMSEndpoint = kms.CfgEndpoint
+	configKMSKeyId    = kms.CfgKeyId
+
+	// String type configuration item, used to configure the file of the keys sealing the session tokens
+	// of the temporary credentials, in the same format as the "sseKeyFile". All the ObjectNodes of the
+	// cluster should have the same keys. The temporary credentials are not supported if it is not configured.
+	// Example:
+	//		{
+	//			"stsKeyFile": "/cfs/conf/sts.keys"
+	//		}
+	configSTSKeyFile = "stsKeyFile"
+
+	// Object array configuration item, used to configure the targets of the bucket notifications, which
+	// are the HTTP webhooks or the topics of Kafka. The buckets refer to a target by its ARN
+	// "arn:cfs:sqs::<id>:<type>". The bucket notifications are not supported if it is not configured.
+	// Example:
+	//		{
+	//			"notificationTargets": [
+	//				{"id": "1", "type": "webhook", "endpoint": "http://hook.chubao.io/events"},
+	//				{"id": "2", "type": "kafka", "brokers": ["10.196.0.1:9092"], "topic": "objects"}
+	//			]
+	//		}
+	configNotificationTargets = "notificationTargets"
+
+	// Object array configuration item, used to configure the remote clusters of the bucket replications,
+	// which are the S3 endpoints with the credentials of the users owning the destination buckets. The
+	// buckets refer to a bucket of a target by the ARN "arn:cfs:s3::<id>:<bucket>". The buckets of the
+	// same cluster are replicated without it.
+	// Example:
+	//		{
+	//			"replicationTargets": [
+	//				{"id": "dr", "endpoint": "http://object.dr.chubao.io", "accessKey": "AK", "secretKey": "SK"}
+	//			]
+	//		}
+	configReplicationTargets = "replicationTargets"
+
+	// Object array configuration item, used to configure the limits of the QPS and the bandwidth in bytes
+	// per second of the requests of the access keys on the ObjectNode. The limit of the access key "*"
+	// applies to each access key without its own limit, and the limit of the empty access key applies to
+	// the anonymous requests. The requests over the limits are rejected with SlowDown (503). The requests
+	// are not limited if it is not configured.
+	// Example:
+	//		{
+	//			"rateLimits": [
+	//				{"accessKey": "*", "qps": 1000, "bandwidth": 104857600},
+	//				{"accessKey": "AK", "qps": 100}
+	//			]
+	//		}
+	configRateLimits = "rateLimits"
+
+	// Boolean type configuration item, used to enable the limits of the QPS and the bandwidth in bytes per
+	// second of the access keys shared by all the ObjectNodes of the cluster, which are set on the master
+	// with the keys "s3qps:<access key>" and "s3bandwidth:<access key>" and apply along with "rateLimits".
+	// Example:
+	//		{
+	//			"clusterRateLimit": true
+	//		}
+	configClusterRateLimit = "clusterRateLimit"
+
+	// Object array configuration item, used to configure the targets of the audit records of all the
+	// requests served by the ObjectNode, which are the files of JSON lines, the HTTP webhooks or the
+	// topics of Kafka, see package audit. The requests are not audited if it is not configured.
+	// Example:
+	//		{
+	//			"auditTargets": [
+	//				{"type": "file", "path": "/cfs/log/objectnode/audit.log"},
+	//				{"type": "kafka", "brokers": ["10.196.0.1:9092"], "topic": "audit"}
+	//			]
+	//		}
+	configAuditTargets = "auditTargets"
+
+	// Integer type configuration item, used to configure the interval in seconds of the flushes of the
+	// server access logs buffered by the ObjectNode into the log objects of the target buckets. The
+	// default is 300 seconds.
+	// Example:
+	//		{
+	//			"accessLogFlushInterval": 300
+	//		}
+	configAccessLogFlushInterval = "accessLogFlushInterval"
 )
 
+// ConfigSchema declares the configuration items of the ObjectNode.
+var ConfigSchema = config.MergeSchemas(kms.ConfigSchema, config.Schema{
+	configListen:                  config.TypeString,
+	configMasterAddr:              config.TypeStringSlice,
+	configStrict:                  config.TypeBool,
+	configDomains:                 config.TypeStringSlice,
+	configWebsiteDomains:          config.TypeStringSlice,
+	disabledActions:               config.TypeStringSlice,
+	configSignatureIgnoredActions: config.TypeStringSlice,
+	configLifecycleInterval:       config.TypeInt,
+	configInventoryInterval:       config.TypeInt,
+	configSSEKeyFile:              config.TypeString,
+	configSTSKeyFile:              config.TypeString,
+	configNotificationTargets:     config.TypeSlice,
+	configReplicationTargets:      config.TypeSlice,
+	configRateLimits:              config.TypeSlice,
+	configClusterRateLimit:        config.TypeBool,
+	configAuditTargets:            config.TypeSlice,
+	configAccessLogFlushInterval:  config.TypeInt,
+	// the addresses of the AuthNodes, which are accepted for the compatibility of the config files
+	"authNodes": config.TypeStringSlice,
+})
+
 // Default of configuration value
 const (
 	defaultListen = "80"
@@ -93,16 +252,28 @@ var (
 )
 
 type ObjectNode struct {
-	domains    []string
-	wildcards  Wildcards
-	listen     string
-	region     string
-	httpServer *http.Server
-	vm         *VolumeManager
-	mc         *master.MasterClient
-	state      uint32
-	wg         sync.WaitGroup
-	userStore  UserInfoStore
+	domains        []string
+	wildcards      Wildcards
+	websiteDomains []string
+	listen         string
+	region         string
+	httpServer     *http.Server
+	vm             *VolumeManager
+	mc             *master.MasterClient
+	state          uint32
+	wg             sync.WaitGroup
+	userStore      UserInfoStore
+	lifecycle      *lifecycleExecutor
+	inventory      *inventoryExecutor
+	sse            *sseKeyManager
+	sts            *stsManager
+	notifier       *eventNotifier
+	replicator     *bucketReplicator
+	rateLimiter    *rateLimiter
+	clusterLimiter *ratelimit.Limiter
+
+	accessLogger *accessLogger
+	auditLogger  *audit.Logger
 
 	signatureIgnoredActions proto.Actions // signature ignored actions
 	disabledActions         proto.Actions // disabled actions
@@ -145,6 +316,10 @@ func (o *ObjectNode) loadConfig(cfg *config.Config) (err error) {
 	}
 	log.LogInfof("loadConfig: setup config: %v(%v)", configDomains, domains)
 
+	// parse website domain
+	o.websiteDomains = cfg.GetStringSlice(configWebsiteDomains)
+	log.LogInfof("loadConfig: setup config: %v(%v)", configWebsiteDomains, o.websiteDomains)
+
 	// parse master config
 	masters := cfg.GetStringSlice(configMasterAddr)
 	if len(masters) == 0 {
@@ -180,6 +355,88 @@ func (o *ObjectNode) loadConfig(cfg *config.Config) (err error) {
 	o.vm = NewVolumeManager(masters, strict)
 	o.userStore = NewUserInfoStore(masters, strict)
 
+	// parse server-side encryption config
+	var sse *sseKeyManager
+	var provider kms.Provider
+	if provider, err = kms.NewProvider(cfg); err != nil {
+		return
+	}
+	if sse, err = newSSEKeyManager(cfg.GetString(configSSEKeyFile), provider); err != nil {
+		return
+	}
+	o.vm.setSSEKeyManager(sse)
+	o.sse = sse
+	log.LogInfof("loadConfig: setup config: %v(%v) %v(%v) %v(%v)", configSSEKeyFile, cfg.GetString(configSSEKeyFile),
+		configKMSEndpoint, cfg.GetString(configKMSEndpoint), configKMSKeyId, cfg.GetString(configKMSKeyId))
+
+	// parse temporary credentials config
+	if o.sts, err = newSTSManager(cfg.GetString(configSTSKeyFile)); err != nil {
+		return
+	}
+	log.LogInfof("loadConfig: setup config: %v(%v)", configSTSKeyFile, cfg.GetString(configSTSKeyFile))
+
+	// parse notification config
+	var targets []*notificationTargetConfig
+	if targets, err = parseNotificationTargets(cfg.GetSlice(configNotificationTargets)); err != nil {
+		return
+	}
+	if o.notifier, err = newEventNotifier(targets); err != nil {
+		return
+	}
+	log.LogInfof("loadConfig: setup config: %v(%v)", configNotificationTargets, len(targets))
+
+	// parse replication config
+	var replicationTargets []*replicationTargetConfig
+	if replicationTargets, err = parseReplicationTargets(cfg.GetSlice(configReplicationTargets)); err != nil {
+		return
+	}
+	if o.replicator, err = newBucketReplicator(o.vm, replicationTargets); err != nil {
+		return
+	}
+	log.LogInfof("loadConfig: setup config: %v(%v)", configReplicationTargets, len(replicationTargets))
+
+	// parse rate limit config
+	var rateLimits []*rateLimitConfig
+	if rateLimits, err = parseRateLimits(cfg.GetSlice(configRateLimits)); err != nil {
+		return
+	}
+	if o.rateLimiter, err = newRateLimiter(rateLimits); err != nil {
+		return
+	}
+	log.LogInfof("loadConfig: setup config: %v(%v)", configRateLimits, len(rateLimits))
+	if cfg.GetBool(configClusterRateLimit) {
+		hostname, _ := os.Hostname()
+		o.clusterLimiter = ratelimit.NewLimiter(fmt.Sprintf("%v:%v", hostname, o.listen), o.mc.ClientAPI())
+	}
+	log.LogInfof("loadConfig: setup config: %v(%v)", configClusterRateLimit, o.clusterLimiter != nil)
+
+	// parse audit config
+	var auditTargets []*audit.SinkConfig
+	if auditTargets, err = audit.ParseSinkConfigs(cfg.GetSlice(configAuditTargets)); err != nil {
+		return
+	}
+	if o.auditLogger, err = audit.NewLogger(cfg.GetString("role"), auditTargets); err != nil {
+		return
+	}
+	log.LogInfof("loadConfig: setup config: %v(%v)", configAuditTargets, len(auditTargets))
+
+	// parse access log config
+	var flushInterval = cfg.GetInt64(configAccessLogFlushInterval)
+	o.accessLogger = newAccessLogger(time.Duration(flushInterval)*time.Second, o.putAccessLog)
+	log.LogInfof("loadConfig: setup config: %v(%v)", configAccessLogFlushInterval, o.accessLogger.interval)
+
+	// parse lifecycle config
+	if interval := cfg.GetInt64(configLifecycleInterval); interval > 0 {
+		o.lifecycle = newLifecycleExecutor(o.vm, o.mc, time.Duration(interval)*time.Second)
+		log.LogInfof("loadConfig: setup config: %v(%v)", configLifecycleInterval, interval)
+	}
+
+	// parse inventory config
+	if interval := cfg.GetInt64(configInventoryInterval); interval > 0 {
+		o.inventory = newInventoryExecutor(o.vm, o.mc, time.Duration(interval)*time.Second)
+		log.LogInfof("loadConfig: setup config: %v(%v)", configInventoryInterval, interval)
+	}
+
 	return
 }
 
@@ -213,6 +470,18 @@ func handleStart(s common.Server, cfg *config.Config) (err error) {
 		return
 	}
 
+	if o.lifecycle != nil {
+		o.lifecycle.start()
+	}
+	if o.inventory != nil {
+		o.inventory.start()
+	}
+	if o.notifier != nil {
+		o.notifier.start()
+	}
+	o.replicator.start()
+	o.accessLogger.start()
+
 	exporter.Init(cfg.GetString("role"), cfg)
 	exporter.RegistConsul(ci.Cluster, cfg.GetString("role"), cfg)
 
@@ -225,7 +494,24 @@ func handleShutdown(s common.Server) {
 	if !ok {
 		return
 	}
+	if o.lifecycle != nil {
+		o.lifecycle.stop()
+	}
+	if o.inventory != nil {
+		o.inventory.stop()
+	}
 	o.shutdownRestAPI()
+	if o.notifier != nil {
+		o.notifier.stop()
+	}
+	if o.replicator != nil {
+		o.replicator.stop()
+	}
+	if o.accessLogger != nil {
+		o.accessLogger.stop()
+	}
+	o.auditLogger.Stop()
+	o.clusterLimiter.Stop()
 }
 
 func (o *ObjectNode) startMuxRestAPI() (err error) {
@@ -238,6 +524,7 @@ func (o *ObjectNode) startMuxRestAPI() (err error) {
 		o.authMiddleware,
 		o.policyCheckMiddleware,
 		o.contentMiddleware,
+		o.rateLimitMiddleware,
 	)
 
 	var server = &http.Server{
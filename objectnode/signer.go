@@ -25,6 +25,7 @@ import (
 )
 
 var SERVICE = "s3"
+var STSService = "sts"
 var SCHEME = "AWS4"
 var ALGORITHM = "HMAC-SHA256"
 var TERMINATOR = "aws4_request"
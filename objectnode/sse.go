@@ -0,0 +1,356 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/kms"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The objects are encrypted by the ObjectNode if the server-side encryption is requested by
+// the "x-amz-server-side-encryption" header. Each object has its own random data key, and its
+// data is encrypted with AES-256 in the CTR mode, with the counter of the block of the offset in
+// the object, so the ranges of the object can be read without the neighbouring data.
+//
+// The data key is wrapped before it is stored in the extended attributes of the object:
+//   - SSE-S3 (AES256) wraps it in AES-GCM with the master keys of the ObjectNodes, which are read
+//     from the file of the "sseKeyFile" configuration, a line "<id> <hex key>" per key. The key with
+//     the largest id wraps the new data keys, and the old keys should be kept in the file as long
+//     as the objects wrapped with them exist.
+//   - SSE-KMS (aws:kms) asks the external KMS of the "kmsEndpoint" configuration to generate and
+//     decrypt the data key, with the key id of the request or of the "kmsKeyId" configuration.
+//     The KMS is the AWS KMS, the transit secrets engine of the Vault or a simple HTTP KMS by the
+//     "kmsProvider" configuration, see the package kms.
+//
+// The multipart uploads are not encrypted, and the customer provided keys (SSE-C) are not supported.
+
+const (
+	SSEAlgorithmAES256 = "AES256"
+	SSEAlgorithmKMS    = "aws:kms"
+
+	sseDataKeySize = 32
+)
+
+// SSEOption is the server-side encryption requested for an object.
+type SSEOption struct {
+	Algorithm string
+	KMSKeyId  string // empty uses the default key of the KMS
+}
+
+// parseSSEOption returns the server-side encryption requested by the headers, or nil if it is not requested.
+func parseSSEOption(header http.Header) (opt *SSEOption, errorCode *ErrorCode) {
+	if header.Get(HeaderNameXAmzSSECustomerAlgorithm) != "" {
+		return nil, NotImplemented
+	}
+	var algorithm = header.Get(HeaderNameXAmzServerSideEncryption)
+	var keyId = header.Get(HeaderNameXAmzServerSideEncryptionKMSKeyId)
+	switch algorithm {
+	case "":
+		if keyId != "" {
+			return nil, InvalidArgument
+		}
+		return nil, nil
+	case SSEAlgorithmAES256:
+		if keyId != "" {
+			return nil, InvalidArgument
+		}
+	case SSEAlgorithmKMS:
+	default:
+		return nil, InvalidEncryptionAlgorithm
+	}
+	return &SSEOption{Algorithm: algorithm, KMSKeyId: keyId}, nil
+}
+
+// parseSSEOption returns the server-side encryption requested by the request, which must be configured.
+func (o *ObjectNode) parseSSEOption(r *http.Request) (opt *SSEOption, errorCode *ErrorCode) {
+	if opt, errorCode = parseSSEOption(r.Header); errorCode != nil || opt == nil {
+		return
+	}
+	if !o.sse.supported(opt) {
+		log.LogWarnf("parseSSEOption: server-side encryption is not configured: requestID(%v) algorithm(%v)",
+			GetRequestID(r), opt.Algorithm)
+		return nil, NotImplemented
+	}
+	return
+}
+
+// setSSEResponseHeader sets the headers of the server-side encryption of the object.
+func setSSEResponseHeader(w http.ResponseWriter, info *FSFileInfo) {
+	if len(info.SSEAlgorithm) == 0 {
+		return
+	}
+	w.Header()[HeaderNameXAmzServerSideEncryption] = []string{info.SSEAlgorithm}
+	if info.SSEAlgorithm == SSEAlgorithmKMS && len(info.SSEKMSKeyId) > 0 {
+		w.Header()[HeaderNameXAmzServerSideEncryptionKMSKeyId] = []string{info.SSEKMSKeyId}
+	}
+}
+
+// sseDataKey is the data key of an encrypted object.
+type sseDataKey struct {
+	algorithm string
+	keyId     string // id of the master key for SSE-S3, or of the KMS key for SSE-KMS
+	plaintext []byte
+	wrapped   []byte
+}
+
+func (k *sseDataKey) newCipher() cipher.Block {
+	block, err := aes.NewCipher(k.plaintext)
+	if err != nil {
+		// never happens since the data keys are always of a valid size
+		panic(err)
+	}
+	return block
+}
+
+func (k *sseDataKey) fillFileInfo(info *FSFileInfo) {
+	info.SSEAlgorithm = k.algorithm
+	if k.algorithm == SSEAlgorithmKMS {
+		info.SSEKMSKeyId = k.keyId
+	}
+}
+
+// sseKeyManager generates and unwraps the data keys of the encrypted objects.
+type sseKeyManager struct {
+	masterKeys map[uint32][]byte // nil if SSE-S3 is not configured
+	currentID  uint32
+	kms        kms.Provider // nil if SSE-KMS is not configured
+}
+
+func newSSEKeyManager(keyFile string, provider kms.Provider) (m *sseKeyManager, err error) {
+	m = &sseKeyManager{kms: provider}
+	if keyFile != "" {
+		if m.masterKeys, m.currentID, err = loadSSEMasterKeys(keyFile); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// loadSSEMasterKeys reads the master keys from the file of lines "<id> <hex key>".
+func loadSSEMasterKeys(keyFile string) (masterKeys map[uint32][]byte, currentID uint32, err error) {
+	fp, err := os.Open(keyFile)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	masterKeys = make(map[uint32][]byte)
+	scanner := bufio.NewScanner(fp)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, 0, fmt.Errorf("invalid line %v of %v", lineNo, keyFile)
+		}
+		id, parseErr := strconv.ParseUint(fields[0], 10, 32)
+		if parseErr != nil || id == 0 {
+			return nil, 0, fmt.Errorf("invalid key id at line %v of %v", lineNo, keyFile)
+		}
+		key, decodeErr := hex.DecodeString(fields[1])
+		if decodeErr != nil || len(key) != sseDataKeySize {
+			return nil, 0, fmt.Errorf("invalid key at line %v of %v, a key has %v bytes in hex", lineNo, keyFile, sseDataKeySize)
+		}
+		masterKeys[uint32(id)] = key
+		if uint32(id) > currentID {
+			currentID = uint32(id)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(masterKeys) == 0 {
+		return nil, 0, fmt.Errorf("no key in %v", keyFile)
+	}
+	log.LogInfof("loadSSEMasterKeys: keys(%v) current key(%v)", len(masterKeys), currentID)
+	return
+}
+
+// supported reports whether the encryption of the option is configured.
+func (m *sseKeyManager) supported(opt *SSEOption) bool {
+	if m == nil {
+		return false
+	}
+	switch opt.Algorithm {
+	case SSEAlgorithmAES256:
+		return m.masterKeys != nil
+	case SSEAlgorithmKMS:
+		return m.kms != nil
+	}
+	return false
+}
+
+// generateDataKey returns a new data key of an object encrypted as the option.
+func (m *sseKeyManager) generateDataKey(opt *SSEOption) (key *sseDataKey, err error) {
+	if !m.supported(opt) {
+		return nil, fmt.Errorf("server-side encryption %v is not configured", opt.Algorithm)
+	}
+	key = &sseDataKey{algorithm: opt.Algorithm}
+	if opt.Algorithm == SSEAlgorithmKMS {
+		if key.keyId, key.plaintext, key.wrapped, err = m.kms.GenerateDataKey(opt.KMSKeyId); err != nil {
+			return nil, err
+		}
+		return
+	}
+	key.plaintext = make([]byte, sseDataKeySize)
+	if _, err = io.ReadFull(rand.Reader, key.plaintext); err != nil {
+		return nil, err
+	}
+	key.keyId = strconv.FormatUint(uint64(m.currentID), 10)
+	if key.wrapped, err = wrapSSEDataKey(m.masterKeys[m.currentID], key.plaintext); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// unwrapDataKey returns the plaintext of the data key of an encrypted object.
+func (m *sseKeyManager) unwrapDataKey(algorithm, keyId string, wrapped []byte) (plaintext []byte, err error) {
+	if !m.supported(&SSEOption{Algorithm: algorithm}) {
+		return nil, fmt.Errorf("server-side encryption %v is not configured", algorithm)
+	}
+	if algorithm == SSEAlgorithmKMS {
+		if plaintext, err = m.kms.Decrypt(keyId, wrapped); err != nil {
+			return nil, err
+		}
+	} else {
+		id, parseErr := strconv.ParseUint(keyId, 10, 32)
+		masterKey := m.masterKeys[uint32(id)]
+		if parseErr != nil || masterKey == nil {
+			return nil, fmt.Errorf("master key(%v) is not loaded", keyId)
+		}
+		if plaintext, err = unwrapSSEDataKey(masterKey, wrapped); err != nil {
+			return nil, err
+		}
+	}
+	if len(plaintext) != sseDataKeySize {
+		return nil, fmt.Errorf("invalid data key size %v", len(plaintext))
+	}
+	return
+}
+
+// wrapSSEDataKey encrypts the data key with the master key in AES-GCM.
+func wrapSSEDataKey(masterKey, dataKey []byte) (wrapped []byte, err error) {
+	aead, err := newSSEKeyAEAD(masterKey)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func unwrapSSEDataKey(masterKey, wrapped []byte) (dataKey []byte, err error) {
+	aead, err := newSSEKeyAEAD(masterKey)
+	if err != nil {
+		return
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid wrapped key")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+}
+
+func newSSEKeyAEAD(masterKey []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// sseXorKeyStream encrypts or decrypts in place the data at the offset of the object.
+func sseXorKeyStream(block cipher.Block, data []byte, offset int) {
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[8:], uint64(offset/aes.BlockSize))
+	ctr := cipher.NewCTR(block, iv[:])
+	if skip := offset % aes.BlockSize; skip > 0 {
+		var pad [aes.BlockSize]byte
+		ctr.XORKeyStream(pad[:skip], pad[:skip])
+	}
+	ctr.XORKeyStream(data, data)
+}
+
+// isSSEXAttrKey reports whether the extended attribute belongs to the encryption of the object,
+// which is never copied to another object since the data is encrypted again.
+func isSSEXAttrKey(key string) bool {
+	return key == XAttrKeyOSSSSE || key == XAttrKeyOSSSSEKeyId || key == XAttrKeyOSSSSEKey
+}
+
+// storeSSEDataKey stores the data key of the encrypted object in its extended attributes.
+func (v *Volume) storeSSEDataKey(inode uint64, key *sseDataKey) (err error) {
+	var attrs = []struct {
+		key   string
+		value string
+	}{
+		{XAttrKeyOSSSSE, key.algorithm},
+		{XAttrKeyOSSSSEKeyId, key.keyId},
+		{XAttrKeyOSSSSEKey, base64.StdEncoding.EncodeToString(key.wrapped)},
+	}
+	for _, attr := range attrs {
+		if err = v.mw.XAttrSet_ll(inode, []byte(attr.key), []byte(attr.value)); err != nil {
+			log.LogErrorf("storeSSEDataKey: store xattr fail: volume(%v) inode(%v) key(%v) err(%v)",
+				v.name, inode, attr.key, err)
+			return
+		}
+	}
+	return
+}
+
+// loadSSECipher returns the cipher of the encrypted object, or nil if the object is not encrypted.
+func (v *Volume) loadSSECipher(inode uint64) (block cipher.Block, err error) {
+	var xattrs []*proto.XAttrInfo
+	var keys = []string{XAttrKeyOSSSSE, XAttrKeyOSSSSEKeyId, XAttrKeyOSSSSEKey}
+	if xattrs, err = v.mw.BatchGetXAttr([]uint64{inode}, keys); err != nil {
+		log.LogErrorf("loadSSECipher: meta get xattr fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	if len(xattrs) == 0 || xattrs[0].Inode != inode {
+		return
+	}
+	var algorithm = string(xattrs[0].Get(XAttrKeyOSSSSE))
+	if algorithm == "" {
+		return
+	}
+	var wrapped []byte
+	if wrapped, err = base64.StdEncoding.DecodeString(string(xattrs[0].Get(XAttrKeyOSSSSEKey))); err != nil {
+		log.LogErrorf("loadSSECipher: invalid wrapped key: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	var key = &sseDataKey{algorithm: algorithm, keyId: string(xattrs[0].Get(XAttrKeyOSSSSEKeyId)), wrapped: wrapped}
+	if key.plaintext, err = v.sse.unwrapDataKey(key.algorithm, key.keyId, key.wrapped); err != nil {
+		log.LogErrorf("loadSSECipher: unwrap data key fail: volume(%v) inode(%v) algorithm(%v) key(%v) err(%v)",
+			v.name, inode, key.algorithm, key.keyId, err)
+		return
+	}
+	return key.newCipher(), nil
+}
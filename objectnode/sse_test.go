@@ -0,0 +1,182 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/kms"
+)
+
+func TestParseSSEOption(t *testing.T) {
+	var cases = []struct {
+		algorithm string
+		keyId     string
+		customer  string
+		expect    *SSEOption
+		errorCode *ErrorCode
+	}{
+		{"", "", "", nil, nil},
+		{SSEAlgorithmAES256, "", "", &SSEOption{Algorithm: SSEAlgorithmAES256}, nil},
+		{SSEAlgorithmKMS, "", "", &SSEOption{Algorithm: SSEAlgorithmKMS}, nil},
+		{SSEAlgorithmKMS, "key", "", &SSEOption{Algorithm: SSEAlgorithmKMS, KMSKeyId: "key"}, nil},
+		{SSEAlgorithmAES256, "key", "", nil, InvalidArgument},
+		{"", "key", "", nil, InvalidArgument},
+		{"DES", "", "", nil, InvalidEncryptionAlgorithm},
+		{"", "", "AES256", nil, NotImplemented},
+	}
+	for i, c := range cases {
+		var header = make(http.Header)
+		if c.algorithm != "" {
+			header.Set(HeaderNameXAmzServerSideEncryption, c.algorithm)
+		}
+		if c.keyId != "" {
+			header.Set(HeaderNameXAmzServerSideEncryptionKMSKeyId, c.keyId)
+		}
+		if c.customer != "" {
+			header.Set(HeaderNameXAmzSSECustomerAlgorithm, c.customer)
+		}
+		opt, errorCode := parseSSEOption(header)
+		if errorCode != c.errorCode {
+			t.Fatalf("case %v: error code %v, expect %v", i, errorCode, c.errorCode)
+		}
+		if (opt == nil) != (c.expect == nil) || (opt != nil && *opt != *c.expect) {
+			t.Fatalf("case %v: option %v, expect %v", i, opt, c.expect)
+		}
+	}
+}
+
+func TestSSEXorKeyStream(t *testing.T) {
+	var key = &sseDataKey{plaintext: bytes.Repeat([]byte{7}, sseDataKeySize)}
+	var data = make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	var encrypted = make([]byte, len(data))
+	copy(encrypted, data)
+	sseXorKeyStream(key.newCipher(), encrypted, 0)
+	if bytes.Equal(encrypted, data) {
+		t.Fatalf("data not encrypted")
+	}
+	// any range is decrypted at its offset
+	for _, r := range [][2]int{{0, 1000}, {1, 17}, {15, 33}, {500, 1000}} {
+		var part = make([]byte, r[1]-r[0])
+		copy(part, encrypted[r[0]:r[1]])
+		sseXorKeyStream(key.newCipher(), part, r[0])
+		if !bytes.Equal(part, data[r[0]:r[1]]) {
+			t.Fatalf("range %v not decrypted", r)
+		}
+	}
+}
+
+func TestSSEKeyManagerAES256(t *testing.T) {
+	keyFile, err := ioutil.TempFile("", "sse-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(keyFile.Name())
+	_, _ = keyFile.WriteString("# master keys\n" +
+		"1 0000000000000000000000000000000000000000000000000000000000000001\n" +
+		"2 0000000000000000000000000000000000000000000000000000000000000002\n")
+	_ = keyFile.Close()
+
+	m, err := newSSEKeyManager(keyFile.Name(), nil)
+	if err != nil {
+		t.Fatalf("new key manager fail: err(%v)", err)
+	}
+	if m.supported(&SSEOption{Algorithm: SSEAlgorithmKMS}) {
+		t.Fatalf("SSE-KMS should not be supported")
+	}
+	key, err := m.generateDataKey(&SSEOption{Algorithm: SSEAlgorithmAES256})
+	if err != nil {
+		t.Fatalf("generate data key fail: err(%v)", err)
+	}
+	if key.keyId != "2" {
+		t.Fatalf("data key wrapped with master key %v, expect the latest", key.keyId)
+	}
+	plaintext, err := m.unwrapDataKey(key.algorithm, key.keyId, key.wrapped)
+	if err != nil || !bytes.Equal(plaintext, key.plaintext) {
+		t.Fatalf("unwrap data key fail: err(%v)", err)
+	}
+	if _, err = m.unwrapDataKey(key.algorithm, "1", key.wrapped); err == nil {
+		t.Fatalf("data key unwrapped with a wrong master key")
+	}
+	if _, err = m.unwrapDataKey(key.algorithm, "3", key.wrapped); err == nil {
+		t.Fatalf("data key unwrapped with an unknown master key")
+	}
+}
+
+func TestSSEKeyManagerKMS(t *testing.T) {
+	var masterKey = bytes.Repeat([]byte{9}, sseDataKeySize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GenerateDataKey":
+			var req struct{ KeyId string }
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			var plaintext = bytes.Repeat([]byte{1}, sseDataKeySize)
+			wrapped, _ := wrapSSEDataKey(masterKey, plaintext)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"KeyId": "arn:" + req.KeyId, "Plaintext": plaintext, "CiphertextBlob": wrapped})
+		case "TrentService.Decrypt":
+			var req struct {
+				KeyId          string
+				CiphertextBlob []byte
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			plaintext, err := unwrapSSEDataKey(masterKey, req.CiphertextBlob)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"__type": "InvalidCiphertextException"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"KeyId": req.KeyId, "Plaintext": plaintext})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := kms.NewProvider(config.LoadConfigString(`{"kmsEndpoint": "` + server.URL + `", "kmsKeyId": "default"}`))
+	if err != nil {
+		t.Fatalf("new KMS provider fail: err(%v)", err)
+	}
+	m, err := newSSEKeyManager("", provider)
+	if err != nil {
+		t.Fatalf("new key manager fail: err(%v)", err)
+	}
+	if m.supported(&SSEOption{Algorithm: SSEAlgorithmAES256}) {
+		t.Fatalf("SSE-S3 should not be supported")
+	}
+	key, err := m.generateDataKey(&SSEOption{Algorithm: SSEAlgorithmKMS})
+	if err != nil {
+		t.Fatalf("generate data key fail: err(%v)", err)
+	}
+	if key.keyId != "arn:default" {
+		t.Fatalf("data key generated with KMS key %v, expect the default", key.keyId)
+	}
+	plaintext, err := m.unwrapDataKey(key.algorithm, key.keyId, key.wrapped)
+	if err != nil || !bytes.Equal(plaintext, key.plaintext) {
+		t.Fatalf("unwrap data key fail: err(%v)", err)
+	}
+	if _, err = m.unwrapDataKey(key.algorithm, key.keyId, []byte("invalid")); err == nil {
+		t.Fatalf("invalid data key unwrapped")
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+
+	"github.com/gorilla/mux"
+)
+
+// The ObjectNode issues temporary credentials like the AWS Security Token Service (STS), which
+// are an access key, a secret key and a session token valid until an expiration. The requests
+// signed with the temporary credentials give the session token in the "x-amz-security-token"
+// header, or in the "X-Amz-Security-Token" query parameter of the presigned URLs.
+//
+// The session token is the session sealed in AES-GCM with the STS keys of the ObjectNodes, which
+// are read from the file of the "stsKeyFile" configuration in the same format as the master keys of
+// the server-side encryption, so any ObjectNode sharing the keys validates the session without
+// storing it. The key with the largest id seals the new sessions, and the old keys should be kept
+// in the file until the sessions sealed with them expire.
+//
+// The temporary credentials act as the user who requested them, and a session policy given to
+// AssumeRole limits them further: the request must be allowed by both the permissions of the user
+// and the session policy.
+
+const (
+	STSActionGetSessionToken = "GetSessionToken"
+	STSActionAssumeRole      = "AssumeRole"
+	STSVersion               = "2011-06-15"
+	STSNamespace             = "https://sts.amazonaws.com/doc/2011-06-15/"
+
+	STSParamAction          = "Action"
+	STSParamDurationSeconds = "DurationSeconds"
+	STSParamPolicy          = "Policy"
+	STSParamRoleArn         = "RoleArn"
+	STSParamRoleSessionName = "RoleSessionName"
+
+	STSAccessKeyPrefix = "ASIA"
+
+	// The durations of the sessions in seconds, of GetSessionToken and of AssumeRole.
+	STSMinDuration                 = 15 * 60
+	STSSessionTokenMaxDuration     = 36 * 60 * 60
+	STSSessionTokenDefaultDuration = 12 * 60 * 60
+	STSAssumeRoleMaxDuration       = 12 * 60 * 60
+	STSAssumeRoleDefaultDuration   = 60 * 60
+
+	STSSessionPolicyLimitSize = 2048 // the session policies are limited to 2048 characters
+	STSRequestLimitSize       = 16 * 1024
+
+	stsAccessKeyLength = 20
+	stsSecretKeyLength = 40
+)
+
+var (
+	errInvalidSessionToken = errors.New("invalid session token")
+	errExpiredSessionToken = errors.New("expired session token")
+)
+
+// stsSession is the session of the temporary credentials, which is sealed in the session token.
+type stsSession struct {
+	AccessKey       string `json:"ak"`
+	SecretKey       string `json:"sk"`
+	ParentAccessKey string `json:"pak"`
+	Expiration      int64  `json:"exp"` // unix time in seconds
+	Policy          string `json:"policy,omitempty"`
+}
+
+func (s *stsSession) expired() bool {
+	return time.Now().Unix() >= s.Expiration
+}
+
+// sessionPolicy returns the session policy of the session, or nil if the session has none.
+func (s *stsSession) sessionPolicy() (*Policy, error) {
+	if s.Policy == "" {
+		return nil, nil
+	}
+	return parseSessionPolicy(s.Policy)
+}
+
+type stsManager struct {
+	keys      map[uint32][]byte
+	currentID uint32
+}
+
+// newSTSManager returns the manager of the temporary credentials, which is nil if the key file
+// is not configured.
+func newSTSManager(keyFile string) (m *stsManager, err error) {
+	if keyFile == "" {
+		return nil, nil
+	}
+	m = new(stsManager)
+	if m.keys, m.currentID, err = loadSSEMasterKeys(keyFile); err != nil {
+		return nil, fmt.Errorf("load STS keys fail: %v", err)
+	}
+	return
+}
+
+// issue returns a new session of the parent access key and its session token.
+func (m *stsManager) issue(parentAccessKey string, duration time.Duration, policy string) (session *stsSession, token string, err error) {
+	session = &stsSession{
+		ParentAccessKey: parentAccessKey,
+		Expiration:      time.Now().Add(duration).Unix(),
+		Policy:          policy,
+	}
+	if session.AccessKey, err = stsRandomString(stsAccessKeyLength-len(STSAccessKeyPrefix), stsAccessKeyChars); err != nil {
+		return
+	}
+	session.AccessKey = STSAccessKeyPrefix + session.AccessKey
+	if session.SecretKey, err = stsRandomString(stsSecretKeyLength, stsSecretKeyChars); err != nil {
+		return
+	}
+	if token, err = m.seal(session); err != nil {
+		return
+	}
+	return
+}
+
+func (m *stsManager) seal(session *stsSession) (token string, err error) {
+	var data []byte
+	if data, err = json.Marshal(session); err != nil {
+		return
+	}
+	var sealed []byte
+	if sealed, err = wrapSSEDataKey(m.keys[m.currentID], data); err != nil {
+		return
+	}
+	var raw = make([]byte, 4, 4+len(sealed))
+	binary.BigEndian.PutUint32(raw, m.currentID)
+	return base64.StdEncoding.EncodeToString(append(raw, sealed...)), nil
+}
+
+// open returns the session sealed in the session token, which may be expired.
+func (m *stsManager) open(token string) (session *stsSession, err error) {
+	if m == nil {
+		return nil, errInvalidSessionToken
+	}
+	raw, err := base64.StdEncoding.DecodeString(token)
+	if err != nil || len(raw) < 4 {
+		return nil, errInvalidSessionToken
+	}
+	key := m.keys[binary.BigEndian.Uint32(raw)]
+	if key == nil {
+		return nil, errInvalidSessionToken
+	}
+	data, err := unwrapSSEDataKey(key, raw[4:])
+	if err != nil {
+		return nil, errInvalidSessionToken
+	}
+	session = new(stsSession)
+	if err = json.Unmarshal(data, session); err != nil || session.AccessKey == "" || session.ParentAccessKey == "" {
+		return nil, errInvalidSessionToken
+	}
+	return session, nil
+}
+
+const (
+	stsAccessKeyChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	stsSecretKeyChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// stsRandomString returns a random string of the chars from the cryptographic random source.
+func stsRandomString(length int, chars string) (string, error) {
+	var buf = make([]byte, length)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	for i := range buf {
+		buf[i] = chars[int(buf[i])%len(chars)]
+	}
+	return string(buf), nil
+}
+
+// parseSessionPolicy parses the session policy of AssumeRole, whose statements have no principal
+// and may give the resources of any bucket.
+func parseSessionPolicy(data string) (*Policy, error) {
+	if len(data) > STSSessionPolicyLimitSize {
+		return nil, fmt.Errorf("session policy is larger than %v", STSSessionPolicyLimitSize)
+	}
+	var policy Policy
+	d := json.NewDecoder(strings.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&policy); err != nil {
+		return nil, err
+	}
+	if ok, err := policy.isValid(); !ok {
+		return nil, err
+	}
+	for _, s := range policy.Statements {
+		if s.Effect != Allow && s.Effect != Deny {
+			return nil, fmt.Errorf("invalid effect: %v", s.Effect)
+		}
+		if len(s.Principal) != 0 {
+			return nil, errors.New("session policy cannot have principal")
+		}
+		if s.Actions.Empty() == s.NotActions.Empty() {
+			return nil, errors.New("either action or not action must be specified")
+		}
+		if s.Resources.Empty() == s.NotResources.Empty() {
+			return nil, errors.New("either resource or not resource must be specified")
+		}
+		for _, resources := range []StringSet{s.Resources, s.NotResources} {
+			for resource := range resources.values {
+				if resource != "*" && !strings.HasPrefix(resource, S3ResourcePrefix) {
+					return nil, fmt.Errorf("invalid resource: %v", resource)
+				}
+			}
+		}
+		for conditionType := range s.Condition {
+			if _, ok := ConditionFuncMap[conditionType]; !ok {
+				return nil, fmt.Errorf("unsupported condition: %v", conditionType)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// getSecurityToken returns the session token of the request signed with temporary credentials.
+func getSecurityToken(r *http.Request) string {
+	if token := r.Header.Get(HeaderNameXAmzSecurityToken); token != "" {
+		return token
+	}
+	return r.URL.Query().Get(XAmzSecurityToken)
+}
+
+// requestSession returns the unexpired session of the request, or nil if the request is not
+// signed with temporary credentials.
+func (o *ObjectNode) requestSession(r *http.Request) (session *stsSession, err error) {
+	var token = getSecurityToken(r)
+	if token == "" {
+		return nil, nil
+	}
+	if session, err = o.sts.open(token); err != nil {
+		return nil, err
+	}
+	if session.expired() {
+		return nil, errExpiredSessionToken
+	}
+	return
+}
+
+// getRequestUserInfo returns the user of the access key of the request. The temporary credentials
+// act as the user of the parent access key of their session.
+func (o *ObjectNode) getRequestUserInfo(r *http.Request, accessKey string) (userInfo *proto.UserInfo, err error) {
+	var session *stsSession
+	if session, err = o.requestSession(r); err != nil {
+		return
+	}
+	if session == nil {
+		return o.getUserInfoByAccessKey(accessKey)
+	}
+	if session.AccessKey != accessKey {
+		return nil, proto.ErrAccessKeyNotExists
+	}
+	var parent *proto.UserInfo
+	if parent, err = o.getUserInfoByAccessKey(session.ParentAccessKey); err != nil {
+		return
+	}
+	userInfo = &proto.UserInfo{
+		UserID:      parent.UserID,
+		AccessKey:   session.AccessKey,
+		SecretKey:   session.SecretKey,
+		Policy:      parent.Policy,
+		UserType:    parent.UserType,
+		CreateTime:  parent.CreateTime,
+		Description: parent.Description,
+	}
+	return
+}
+
+// sessionPolicyAllows reports whether the session policy of the temporary credentials of the request
+// allows the request, which is true if the request has no session policy.
+func (o *ObjectNode) sessionPolicyAllows(r *http.Request, param *RequestParam) (allowed bool, err error) {
+	var session *stsSession
+	if session, err = o.requestSession(r); err != nil {
+		return false, err
+	}
+	if session == nil {
+		return true, nil
+	}
+	var policy *Policy
+	if policy, err = session.sessionPolicy(); err != nil {
+		return false, err
+	}
+	if policy == nil {
+		return true, nil
+	}
+	if policy.usesObjectTags() && param.Bucket() != "" {
+		if vol, loadErr := o.getVol(param.Bucket()); loadErr == nil {
+			addObjectTagConditionValues(param, vol, r)
+		}
+	}
+	return policy.Evaluate(param) == PolicyAllow, nil
+}
+
+// readSTSRequestForm returns the parameters of the STS request, which are given in the query or in
+// the form of the body. The body is kept for the validation of the signature.
+func readSTSRequestForm(r *http.Request) (form url.Values, err error) {
+	form = make(url.Values)
+	for key, values := range r.URL.Query() {
+		form[key] = values
+	}
+	if r.Method != http.MethodPost || r.Body == nil {
+		return
+	}
+	var data []byte
+	if data, err = ioutil.ReadAll(io.LimitReader(r.Body, STSRequestLimitSize+1)); err != nil {
+		return
+	}
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+	if len(data) > STSRequestLimitSize {
+		return nil, fmt.Errorf("request body is larger than %v", STSRequestLimitSize)
+	}
+	var values url.Values
+	if values, err = url.ParseQuery(string(data)); err != nil {
+		return
+	}
+	for key, v := range values {
+		form[key] = append(form[key], v...)
+	}
+	return
+}
+
+// stsActionMatcher matches the STS requests of the action.
+func stsActionMatcher(action string) func(r *http.Request, rm *mux.RouteMatch) bool {
+	return func(r *http.Request, rm *mux.RouteMatch) bool {
+		form, err := readSTSRequestForm(r)
+		if err != nil {
+			log.LogDebugf("stsActionMatcher: read request form fail: remote(%v) err(%v)", getRequestIP(r), err)
+			return false
+		}
+		return form.Get(STSParamAction) == action
+	}
+}
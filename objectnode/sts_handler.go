@@ -0,0 +1,242 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+var regexpRoleSessionName = regexp.MustCompile("^[\\w+=,.@-]{2,64}$")
+
+type STSCredentials struct {
+	AccessKeyId     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+type STSResponseMetadata struct {
+	RequestId string `xml:"RequestId"`
+}
+
+type GetSessionTokenResponse struct {
+	XMLName          xml.Name            `xml:"GetSessionTokenResponse"`
+	Xmlns            string              `xml:"xmlns,attr"`
+	Credentials      STSCredentials      `xml:"GetSessionTokenResult>Credentials"`
+	ResponseMetadata STSResponseMetadata `xml:"ResponseMetadata"`
+}
+
+type AssumedRoleUser struct {
+	Arn           string `xml:"Arn"`
+	AssumedRoleId string `xml:"AssumedRoleId"`
+}
+
+type AssumeRoleResponse struct {
+	XMLName          xml.Name            `xml:"AssumeRoleResponse"`
+	Xmlns            string              `xml:"xmlns,attr"`
+	Credentials      STSCredentials      `xml:"AssumeRoleResult>Credentials"`
+	AssumedRoleUser  AssumedRoleUser     `xml:"AssumeRoleResult>AssumedRoleUser"`
+	ResponseMetadata STSResponseMetadata `xml:"ResponseMetadata"`
+}
+
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_GetSessionToken.html
+func (o *ObjectNode) getSessionTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var form url.Values
+	var userInfo *proto.UserInfo
+	if form, userInfo, errorCode = o.parseSTSRequest(r); errorCode != nil {
+		return
+	}
+	var duration time.Duration
+	if duration, errorCode = parseSTSDuration(form, STSSessionTokenDefaultDuration, STSSessionTokenMaxDuration); errorCode != nil {
+		return
+	}
+
+	var session *stsSession
+	var token string
+	if session, token, err = o.sts.issue(userInfo.AccessKey, duration, ""); err != nil {
+		log.LogErrorf("getSessionTokenHandler: issue session fail: requestID(%v) accessKey(%v) err(%v)",
+			GetRequestID(r), userInfo.AccessKey, err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	log.LogInfof("getSessionTokenHandler: issue session: requestID(%v) userID(%v) accessKey(%v) tempAccessKey(%v) duration(%v)",
+		GetRequestID(r), userInfo.UserID, userInfo.AccessKey, session.AccessKey, duration)
+
+	var output = GetSessionTokenResponse{
+		Xmlns:            STSNamespace,
+		Credentials:      newSTSCredentials(session, token),
+		ResponseMetadata: STSResponseMetadata{RequestId: GetRequestID(r)},
+	}
+	writeSTSResponse(w, r, &output)
+	return
+}
+
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+// The role must be of the account of the user, such as "arn:aws:iam::<user id>:role/<name>", and the
+// temporary credentials act as the user limited by the session policy.
+func (o *ObjectNode) assumeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+		}
+	}()
+
+	var form url.Values
+	var userInfo *proto.UserInfo
+	if form, userInfo, errorCode = o.parseSTSRequest(r); errorCode != nil {
+		return
+	}
+	var duration time.Duration
+	if duration, errorCode = parseSTSDuration(form, STSAssumeRoleDefaultDuration, STSAssumeRoleMaxDuration); errorCode != nil {
+		return
+	}
+
+	var roleArn = form.Get(STSParamRoleArn)
+	var arn *Arn
+	if arn, err = parseArn(roleArn); err != nil || arn.service != "iam" || !strings.HasPrefix(arn.resourceId, "role/") {
+		log.LogDebugf("assumeRoleHandler: invalid role arn: requestID(%v) roleArn(%v)", GetRequestID(r), roleArn)
+		errorCode = InvalidArgument
+		return
+	}
+	if arn.accountId != userInfo.UserID {
+		log.LogWarnf("assumeRoleHandler: role is not of the user: requestID(%v) userID(%v) roleArn(%v)",
+			GetRequestID(r), userInfo.UserID, roleArn)
+		errorCode = AccessDenied
+		return
+	}
+	var sessionName = form.Get(STSParamRoleSessionName)
+	if !regexpRoleSessionName.MatchString(sessionName) {
+		errorCode = InvalidArgument
+		return
+	}
+	var policy = form.Get(STSParamPolicy)
+	if policy != "" {
+		if _, err = parseSessionPolicy(policy); err != nil {
+			log.LogDebugf("assumeRoleHandler: invalid session policy: requestID(%v) err(%v)", GetRequestID(r), err)
+			errorCode = MalformedPolicyDocument
+			return
+		}
+	}
+
+	var session *stsSession
+	var token string
+	if session, token, err = o.sts.issue(userInfo.AccessKey, duration, policy); err != nil {
+		log.LogErrorf("assumeRoleHandler: issue session fail: requestID(%v) accessKey(%v) err(%v)",
+			GetRequestID(r), userInfo.AccessKey, err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+	log.LogInfof("assumeRoleHandler: issue session: requestID(%v) userID(%v) accessKey(%v) tempAccessKey(%v) roleArn(%v) sessionName(%v) duration(%v)",
+		GetRequestID(r), userInfo.UserID, userInfo.AccessKey, session.AccessKey, roleArn, sessionName, duration)
+
+	var roleName = strings.TrimPrefix(arn.resourceId, "role/")
+	var output = AssumeRoleResponse{
+		Xmlns:       STSNamespace,
+		Credentials: newSTSCredentials(session, token),
+		AssumedRoleUser: AssumedRoleUser{
+			Arn:           "arn:aws:sts::" + userInfo.UserID + ":assumed-role/" + roleName + "/" + sessionName,
+			AssumedRoleId: session.AccessKey + ":" + sessionName,
+		},
+		ResponseMetadata: STSResponseMetadata{RequestId: GetRequestID(r)},
+	}
+	writeSTSResponse(w, r, &output)
+	return
+}
+
+// parseSTSRequest returns the parameters of the STS request and the user who signed it with its
+// long-term credentials.
+func (o *ObjectNode) parseSTSRequest(r *http.Request) (form url.Values, userInfo *proto.UserInfo, errorCode *ErrorCode) {
+	var err error
+	if o.sts == nil {
+		log.LogWarnf("parseSTSRequest: STS is not configured: requestID(%v)", GetRequestID(r))
+		return nil, nil, NotImplemented
+	}
+	if form, err = readSTSRequestForm(r); err != nil {
+		return nil, nil, InvalidArgument
+	}
+	if version := form.Get("Version"); version != "" && version != STSVersion {
+		return nil, nil, InvalidArgument
+	}
+	// The temporary credentials cannot request new ones.
+	if getSecurityToken(r) != "" {
+		return nil, nil, AccessDenied
+	}
+	var auth = parseRequestAuthInfo(r)
+	if userInfo, err = o.getUserInfoByAccessKey(auth.accessKey); err != nil {
+		if err == proto.ErrAccessKeyNotExists || err == proto.ErrUserNotExists {
+			return nil, nil, AccessDenied
+		}
+		log.LogErrorf("parseSTSRequest: load user fail: requestID(%v) accessKey(%v) err(%v)",
+			GetRequestID(r), auth.accessKey, err)
+		return nil, nil, InternalErrorCode(err)
+	}
+	return
+}
+
+func parseSTSDuration(form url.Values, defaultSeconds, maxSeconds int) (duration time.Duration, errorCode *ErrorCode) {
+	var seconds = defaultSeconds
+	if value := form.Get(STSParamDurationSeconds); value != "" {
+		var err error
+		if seconds, err = strconv.Atoi(value); err != nil || seconds < STSMinDuration || seconds > maxSeconds {
+			return 0, InvalidArgument
+		}
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func newSTSCredentials(session *stsSession, token string) STSCredentials {
+	return STSCredentials{
+		AccessKeyId:     session.AccessKey,
+		SecretAccessKey: session.SecretKey,
+		SessionToken:    token,
+		Expiration:      time.Unix(session.Expiration, 0).UTC().Format(time.RFC3339),
+	}
+}
+
+func writeSTSResponse(w http.ResponseWriter, r *http.Request, output interface{}) {
+	response, err := MarshalXMLEntity(output)
+	if err != nil {
+		log.LogErrorf("writeSTSResponse: marshal result fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(response))}
+	_, _ = w.Write(response)
+}
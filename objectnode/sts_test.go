@@ -0,0 +1,174 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package objectnode
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func newTestSTSManager(t *testing.T, keys string) *stsManager {
+	keyFile, err := ioutil.TempFile("", "sts-keys")
+	if err != nil {
+		t.Fatalf("create key file fail: err(%v)", err)
+	}
+	defer os.Remove(keyFile.Name())
+	_, _ = keyFile.WriteString(keys)
+	_ = keyFile.Close()
+	m, err := newSTSManager(keyFile.Name())
+	if err != nil {
+		t.Fatalf("new STS manager fail: err(%v)", err)
+	}
+	return m
+}
+
+func TestSTSSessionToken(t *testing.T) {
+	var key1 = "1 " + strings.Repeat("01", 32) + "\n"
+	var key2 = "2 " + strings.Repeat("02", 32) + "\n"
+	var m = newTestSTSManager(t, key1)
+
+	session, token, err := m.issue("parentAK", time.Hour, `{"Version":"2012-10-17"}`)
+	if err != nil {
+		t.Fatalf("issue session fail: err(%v)", err)
+	}
+	if !strings.HasPrefix(session.AccessKey, STSAccessKeyPrefix) || len(session.AccessKey) != stsAccessKeyLength ||
+		len(session.SecretKey) != stsSecretKeyLength {
+		t.Fatalf("invalid credentials: accessKey(%v) secretKey(%v)", session.AccessKey, session.SecretKey)
+	}
+	opened, err := m.open(token)
+	if err != nil {
+		t.Fatalf("open token fail: err(%v)", err)
+	}
+	if *opened != *session || opened.expired() {
+		t.Fatalf("opened session %v, expect %v", opened, session)
+	}
+
+	// the sessions of the old keys are opened after the rotation of the keys
+	var rotated = newTestSTSManager(t, key1+key2)
+	if _, err = rotated.open(token); err != nil {
+		t.Fatalf("open token after rotation fail: err(%v)", err)
+	}
+	_, newToken, _ := rotated.issue("parentAK", time.Hour, "")
+	if _, err = m.open(newToken); err != errInvalidSessionToken {
+		t.Fatalf("open token of unknown key: err(%v)", err)
+	}
+
+	var tampered = []byte(token)
+	tampered[len(tampered)/2] ^= 1
+	for _, invalid := range []string{"", "invalid", string(tampered)} {
+		if _, err = m.open(invalid); err != errInvalidSessionToken {
+			t.Fatalf("open invalid token %v: err(%v)", invalid, err)
+		}
+	}
+	var none *stsManager
+	if _, err = none.open(token); err != errInvalidSessionToken {
+		t.Fatalf("open token without STS: err(%v)", err)
+	}
+
+	expired, _ := m.seal(&stsSession{AccessKey: "ak", SecretKey: "sk", ParentAccessKey: "parentAK", Expiration: time.Now().Unix() - 1})
+	var o = &ObjectNode{sts: m}
+	var r = httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	if session, err = o.requestSession(r); session != nil || err != nil {
+		t.Fatalf("request without token: session(%v) err(%v)", session, err)
+	}
+	r.Header.Set(HeaderNameXAmzSecurityToken, expired)
+	if _, err = o.requestSession(r); err != errExpiredSessionToken {
+		t.Fatalf("request of expired token: err(%v)", err)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/bucket/key?X-Amz-Security-Token="+strings.NewReplacer("+", "%2B", "/", "%2F", "=", "%3D").Replace(token), nil)
+	if session, err = o.requestSession(r); err != nil || session.AccessKey != opened.AccessKey {
+		t.Fatalf("request of presigned token: session(%v) err(%v)", session, err)
+	}
+}
+
+func TestSTSSessionPolicy(t *testing.T) {
+	var policyJSON = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": ["arn:aws:s3:::examplebucket", "arn:aws:s3:::examplebucket/*"]},
+    {"Effect": "Deny", "Action": "s3:*", "Resource": "arn:aws:s3:::examplebucket/secret/*"}
+  ]
+}`
+	policy, err := parseSessionPolicy(policyJSON)
+	if err != nil {
+		t.Fatalf("parse session policy fail: err(%v)", err)
+	}
+	var cases = []struct {
+		resource string
+		action   proto.Action
+		result   int
+	}{
+		{"examplebucket/a.txt", proto.OSSGetObjectAction, PolicyAllow},
+		{"examplebucket", proto.OSSListObjectsAction, PolicyAllow},
+		{"examplebucket/a.txt", proto.OSSPutObjectAction, PolicyDefault},
+		{"otherbucket/a.txt", proto.OSSGetObjectAction, PolicyDefault},
+		{"examplebucket/secret/a.txt", proto.OSSGetObjectAction, PolicyDeny},
+	}
+	for i, c := range cases {
+		var param = &RequestParam{resource: c.resource, action: c.action, conditionVars: map[string][]string{}}
+		if result := policy.Evaluate(param); result != c.result {
+			t.Fatalf("case %v: expect result %v but %v", i, c.result, result)
+		}
+	}
+
+	var invalids = []string{
+		`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "bucket/*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Resource": "*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [{"Effect": "Maybe", "Action": "s3:*", "Resource": "*"}]}`,
+		`{"Version": "2012-10-17", "Statement": [], "Unknown": 1}`,
+		`{"Version": "2012-10-17", "Id": "` + strings.Repeat("x", STSSessionPolicyLimitSize) + `"}`,
+	}
+	for i, invalid := range invalids {
+		if _, err = parseSessionPolicy(invalid); err == nil {
+			t.Fatalf("invalid policy %v is parsed", i)
+		}
+	}
+}
+
+func TestSTSRequestForm(t *testing.T) {
+	var body = "Action=AssumeRole&Version=2011-06-15&DurationSeconds=900"
+	var r = httptest.NewRequest(http.MethodPost, "/?Policy=p", strings.NewReader(body))
+	r.Header.Set(HeaderNameContentType, "application/x-www-form-urlencoded")
+	if !stsActionMatcher(STSActionAssumeRole)(r, nil) || stsActionMatcher(STSActionGetSessionToken)(r, nil) {
+		t.Fatalf("request is not matched by its action")
+	}
+	form, err := readSTSRequestForm(r)
+	if err != nil || form.Get(STSParamAction) != STSActionAssumeRole || form.Get(STSParamPolicy) != "p" {
+		t.Fatalf("read form fail: form(%v) err(%v)", form, err)
+	}
+	duration, errorCode := parseSTSDuration(form, STSAssumeRoleDefaultDuration, STSAssumeRoleMaxDuration)
+	if errorCode != nil || duration != 15*time.Minute {
+		t.Fatalf("duration %v, error code %v", duration, errorCode)
+	}
+	// the body is kept for the signature and the handler
+	if data, _ := ioutil.ReadAll(r.Body); string(data) != body {
+		t.Fatalf("body %v is not kept", string(data))
+	}
+
+	form.Set(STSParamDurationSeconds, "60")
+	if _, errorCode = parseSTSDuration(form, STSAssumeRoleDefaultDuration, STSAssumeRoleMaxDuration); errorCode != InvalidArgument {
+		t.Fatalf("too short duration is accepted")
+	}
+}
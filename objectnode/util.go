@@ -222,6 +222,32 @@ func patternMatch(pattern, key string) bool {
 	return matched
 }
 
+// wildcardMatch matches the key with the pattern of the policies, in which '*' matches any
+// sequence of characters and '?' matches any single character.
+func wildcardMatch(pattern, key string) bool {
+	var p, k = 0, 0
+	var starP, starK = -1, 0
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			p++
+			k++
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starK = p, k
+			p++
+		case starP >= 0:
+			starK++
+			p, k = starP+1, starK
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
 func wrapUnescapedQuot(src string) string {
 	return "\"" + src + "\""
 }
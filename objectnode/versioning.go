@@ -0,0 +1,72 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/Versioning.html
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/util/errors"
+)
+
+type VersioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+func (config *VersioningConfiguration) validate() bool {
+	return config.Status == VersioningStatusEnabled || config.Status == VersioningStatusSuspended
+}
+
+func parseVersioningConfig(bytes []byte) (config *VersioningConfiguration, err error) {
+	config = &VersioningConfiguration{}
+	if err = xml.Unmarshal(bytes, config); err != nil {
+		return
+	}
+	if ok := config.validate(); !ok {
+		return nil, errors.New("invalid versioning configuration")
+	}
+	return
+}
+
+func storeBucketVersioning(bytes []byte, vol *Volume) (err error) {
+	if err = vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSVersioning, bytes); err != nil {
+		return
+	}
+	return nil
+}
+
+// The null versions are stored with a version id made of the reversed modify time and the
+// null suffix, so that they are ordered with the other versions of the object.
+func nullVersionId(t time.Time) string {
+	return fmt.Sprintf("%016x%s", uint64(math.MaxInt64-t.UnixNano()), NullVersionId)
+}
+
+func isNullVersionId(versionId string) bool {
+	return versionId == "" || strings.HasSuffix(versionId, NullVersionId)
+}
+
+// displayVersionId returns the version id shown to the clients.
+func displayVersionId(versionId string) string {
+	if isNullVersionId(versionId) {
+		return NullVersionId
+	}
+	return versionId
+}
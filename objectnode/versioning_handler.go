@@ -0,0 +1,243 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/Versioning.html
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get bucket versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
+func (o *ObjectNode) getBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var output = VersioningConfiguration{}
+
+	var versioning *VersioningConfiguration
+	if versioning, err = vol.metaLoader.loadVersioning(); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if versioning != nil {
+		output.Status = versioning.Status
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(output); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(data))}
+	_, _ = w.Write(data)
+	return
+}
+
+// Put bucket versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
+func (o *ObjectNode) putBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		_ = NoSuchBucket.ServeResponse(w, r)
+		return
+	}
+
+	var bytes []byte
+	if bytes, err = ioutil.ReadAll(r.Body); err != nil && err != io.EOF {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+
+	var versioning *VersioningConfiguration
+	if versioning, err = parseVersioningConfig(bytes); err != nil {
+		log.LogWarnf("putBucketVersioningHandler: parse versioning fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		_ = IllegalVersioningConfiguration.ServeResponse(w, r)
+		return
+	}
+
+	// the versioning of a bucket with object lock can not be suspended
+	if versioning.Status != VersioningStatusEnabled {
+		var lockConfig *ObjectLockConfiguration
+		if lockConfig, err = vol.metaLoader.loadObjectLock(); err != nil {
+			_ = InternalErrorCode(err).ServeResponse(w, r)
+			return
+		}
+		if lockConfig.enabled() {
+			_ = (&ErrorCode{
+				ErrorCode:    InvalidBucketState.ErrorCode,
+				ErrorMessage: "An Object Lock configuration is present on this bucket, so the versioning state cannot be changed.",
+				StatusCode:   InvalidBucketState.StatusCode,
+			}).ServeResponse(w, r)
+			return
+		}
+	}
+
+	var newBytes []byte
+	if newBytes, err = xml.Marshal(versioning); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	if err = storeBucketVersioning(newBytes, vol); err != nil {
+		_ = InternalErrorCode(err).ServeResponse(w, r)
+		return
+	}
+	vol.metaLoader.storeVersioning(versioning)
+
+	// Audit versioning change
+	log.LogInfof("Audit: put bucket versioning: requestID(%v) remote(%v) volume(%v) status(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), versioning.Status)
+	return
+}
+
+// List object versions
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
+func (o *ObjectNode) listObjectVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+
+	defer func() {
+		if errorCode != nil {
+			_ = errorCode.ServeResponse(w, r)
+			return
+		}
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+
+	prefix := param.GetVar(ParamPrefix)
+	keyMarker := param.GetVar(ParamKeyMarker)
+	versionIdMarker := param.GetVar(ParamVersionIdMarker)
+	maxKeys := param.GetVar(ParamMaxKeys)
+
+	var maxKeysInt uint64
+	if maxKeys == "" {
+		maxKeysInt = MaxKeys
+	} else {
+		if maxKeysInt, err = strconv.ParseUint(maxKeys, 10, 64); err != nil {
+			log.LogErrorf("listObjectVersionsHandler: parse max keys option fail: requestID(%v) err(%v)", GetRequestID(r), err)
+			errorCode = InvalidArgument
+			return
+		}
+		if maxKeysInt > MaxKeys {
+			maxKeysInt = MaxKeys
+		}
+	}
+	if keyMarker == "" {
+		// The version id marker can only be used along with the key marker.
+		versionIdMarker = ""
+	}
+
+	var vol *Volume
+	if vol, err = o.vm.Volume(param.Bucket()); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: load volume fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		errorCode = NoSuchBucket
+		return
+	}
+
+	var option = &ListObjectVersionsOption{
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         maxKeysInt,
+	}
+	var result *ListObjectVersionsResult
+	if result, err = vol.ListObjectVersions(option); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: list versions fail: requestID(%v) volume(%v) option(%v) err(%v)",
+			GetRequestID(r), vol.Name(), option, err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+
+	var owner = NewBucketOwner(vol)
+	var output = &ListVersionsResult{
+		Name:                param.Bucket(),
+		Prefix:              prefix,
+		KeyMarker:           keyMarker,
+		VersionIdMarker:     versionIdMarker,
+		NextKeyMarker:       result.NextKeyMarker,
+		NextVersionIdMarker: result.NextVersionIdMarker,
+		MaxKeys:             int(maxKeysInt),
+		IsTruncated:         result.Truncated,
+		Versions:            make([]*Version, 0),
+		DeleteMarkers:       make([]*DeleteMarkerEntry, 0),
+	}
+	for _, version := range result.Versions {
+		if version.DeleteMarker {
+			output.DeleteMarkers = append(output.DeleteMarkers, &DeleteMarkerEntry{
+				Key:          version.Path,
+				VersionId:    displayVersionId(version.VersionId),
+				IsLatest:     version.IsLatest,
+				LastModified: formatTimeISO(version.ModifyTime),
+				Owner:        owner,
+			})
+			continue
+		}
+		output.Versions = append(output.Versions, &Version{
+			Key:          version.Path,
+			VersionId:    displayVersionId(version.VersionId),
+			IsLatest:     version.IsLatest,
+			LastModified: formatTimeISO(version.ModifyTime),
+			ETag:         wrapUnescapedQuot(version.ETag),
+			Size:         int(version.Size),
+			StorageClass: displayStorageClass(version.StorageClass),
+			Owner:        owner,
+		})
+	}
+
+	var bytes []byte
+	if bytes, err = MarshalXMLEntity(output); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: marshal xml entity fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		errorCode = InternalErrorCode(err)
+		return
+	}
+
+	// set response header
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(bytes))}
+	_, _ = w.Write(bytes)
+	return
+}
@@ -0,0 +1,370 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteHosting.html
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/gorilla/mux"
+)
+
+// The buckets with the website configuration are served as static websites on the website
+// endpoints "<bucket>.<website domain>". The requests to the website endpoints are anonymous,
+// which are allowed only if the bucket policy or the bucket ACL allows the anonymous users to
+// get the objects. The website endpoints serve the index documents for the requests of the
+// directories, the error document for the errors, and redirect the requests by the routing rules.
+
+const (
+	MaxWebsiteRoutingRules = 50
+
+	websiteRouteName = "WebsiteObject"
+)
+
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	RoutingRules          []*RoutingRule         `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type RoutingRule struct {
+	Condition *RoutingRuleCondition `xml:"Condition,omitempty"`
+	Redirect  *RoutingRuleRedirect  `xml:"Redirect"`
+}
+
+type RoutingRuleCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+type RoutingRuleRedirect struct {
+	HostName             string  `xml:"HostName,omitempty"`
+	HttpRedirectCode     string  `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string  `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       *string `xml:"ReplaceKeyWith,omitempty"`
+}
+
+func validWebsiteProtocol(protocol string) bool {
+	return protocol == "" || protocol == "http" || protocol == "https"
+}
+
+func (c *WebsiteConfiguration) validate() error {
+	if c.RedirectAllRequestsTo != nil {
+		if c.IndexDocument != nil || c.ErrorDocument != nil || len(c.RoutingRules) > 0 {
+			return errors.New("RedirectAllRequestsTo cannot be specified with other elements")
+		}
+		if c.RedirectAllRequestsTo.HostName == "" {
+			return errors.New("the host name of RedirectAllRequestsTo must be specified")
+		}
+		if !validWebsiteProtocol(c.RedirectAllRequestsTo.Protocol) {
+			return errors.New("invalid protocol of RedirectAllRequestsTo")
+		}
+		return nil
+	}
+	if c.IndexDocument == nil || c.IndexDocument.Suffix == "" {
+		return errors.New("the suffix of IndexDocument must be specified")
+	}
+	if strings.Contains(c.IndexDocument.Suffix, "/") {
+		return errors.New("the suffix of IndexDocument cannot contain slash")
+	}
+	if c.ErrorDocument != nil && c.ErrorDocument.Key == "" {
+		return errors.New("the key of ErrorDocument must be specified")
+	}
+	if len(c.RoutingRules) > MaxWebsiteRoutingRules {
+		return errors.New("too many routing rules")
+	}
+	for _, rule := range c.RoutingRules {
+		if rule.Redirect == nil {
+			return errors.New("the redirect of routing rule must be specified")
+		}
+		if rule.Redirect.ReplaceKeyPrefixWith != nil && rule.Redirect.ReplaceKeyWith != nil {
+			return errors.New("ReplaceKeyPrefixWith and ReplaceKeyWith cannot be both specified")
+		}
+		if !validWebsiteProtocol(rule.Redirect.Protocol) {
+			return errors.New("invalid protocol of routing rule")
+		}
+		if code := rule.Redirect.HttpRedirectCode; code != "" {
+			if value, err := strconv.Atoi(code); err != nil || value < 300 || value > 399 {
+				return errors.New("invalid http redirect code of routing rule")
+			}
+		}
+		if rule.Condition != nil && rule.Condition.HttpErrorCodeReturnedEquals != "" {
+			if value, err := strconv.Atoi(rule.Condition.HttpErrorCodeReturnedEquals); err != nil || value < 400 || value > 599 {
+				return errors.New("invalid http error code of routing rule condition")
+			}
+		}
+	}
+	return nil
+}
+
+// indexKey returns the key of the index document if the key is a directory.
+func (c *WebsiteConfiguration) indexKey(key string) string {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return key + c.IndexDocument.Suffix
+	}
+	return key
+}
+
+// matchRoutingRule returns the first routing rule matching the key. The rules with the error code
+// condition are matched after the request fails with the status code, and the others are matched
+// before the object is read, so statusCode is 0 if the object is not read yet.
+func (c *WebsiteConfiguration) matchRoutingRule(key string, statusCode int) *RoutingRule {
+	for _, rule := range c.RoutingRules {
+		var cond = rule.Condition
+		if cond == nil {
+			if statusCode == 0 {
+				return rule
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, cond.KeyPrefixEquals) {
+			continue
+		}
+		if cond.HttpErrorCodeReturnedEquals == "" && statusCode == 0 ||
+			cond.HttpErrorCodeReturnedEquals != "" && cond.HttpErrorCodeReturnedEquals == strconv.Itoa(statusCode) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func requestProtocol(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// redirectLocation returns the location and the status code of the redirection of the key by the rule.
+func (rule *RoutingRule) redirectLocation(r *http.Request, key string) (location string, statusCode int) {
+	var redirect = rule.Redirect
+	var host, protocol = redirect.HostName, redirect.Protocol
+	if host == "" {
+		host = r.Host
+	}
+	if protocol == "" {
+		protocol = requestProtocol(r)
+	}
+	switch {
+	case redirect.ReplaceKeyWith != nil:
+		key = *redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != nil:
+		var prefix string
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = *redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	statusCode = http.StatusMovedPermanently
+	if redirect.HttpRedirectCode != "" {
+		statusCode, _ = strconv.Atoi(redirect.HttpRedirectCode)
+	}
+	location = protocol + "://" + host + (&url.URL{Path: "/" + key}).EscapedPath()
+	return
+}
+
+func storeBucketWebsite(bytes []byte, vol *Volume) (err error) {
+	if err = vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSWebsite, bytes); err != nil {
+		return
+	}
+	return nil
+}
+
+func deleteBucketWebsite(vol *Volume) (err error) {
+	if err = vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSWebsite); err != nil {
+		return
+	}
+	return nil
+}
+
+// isWebsiteRequest returns whether the request is sent to the website endpoints, which is
+// not signed and is checked by the website handler.
+func isWebsiteRequest(r *http.Request) bool {
+	var route = mux.CurrentRoute(r)
+	return route != nil && route.GetName() == websiteRouteName
+}
+
+// serveWebsiteError responds the error in an HTML page as the website endpoints of Amazon S3.
+func serveWebsiteError(w http.ResponseWriter, r *http.Request, code *ErrorCode) {
+	SetResponseStatusCode(r, *code)
+	SetResponseErrorMessage(r, code.ErrorMessage)
+	SetResponseErrorCode(r, code.ErrorCode)
+
+	var status = fmt.Sprintf("%d %s", code.StatusCode, http.StatusText(code.StatusCode))
+	var page = "<html>\n<head><title>" + status + "</title></head>\n<body>\n<h1>" + status + "</h1>\n<ul>\n" +
+		"<li>Code: " + html.EscapeString(code.ErrorCode) + "</li>\n" +
+		"<li>Message: " + html.EscapeString(code.ErrorMessage) + "</li>\n" +
+		"<li>RequestId: " + html.EscapeString(GetRequestID(r)) + "</li>\n" +
+		"</ul>\n</body>\n</html>\n"
+	w.Header()[HeaderNameContentType] = []string{"text/html; charset=utf-8"}
+	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(page))}
+	w.WriteHeader(code.StatusCode)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write([]byte(page))
+	}
+}
+
+// websiteObjectAllowed returns whether the anonymous users are allowed to get the object of the website.
+func (o *ObjectNode) websiteObjectAllowed(r *http.Request, param *RequestParam, key string) (bool, *ErrorCode) {
+	var p = *param
+	p.object = key
+	p.resource = param.Bucket() + "/" + key
+	p.action = proto.OSSGetObjectAction
+	return o.anonymousAllowed(r, &p)
+}
+
+// Serve the objects of the bucket on the website endpoint
+// Reference: https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteEndpoints.html
+func (o *ObjectNode) websiteHandler(w http.ResponseWriter, r *http.Request) {
+	var param = ParseRequestParam(r)
+	var vol, err = o.vm.Volume(param.Bucket())
+	if err != nil {
+		serveWebsiteError(w, r, NoSuchBucket)
+		return
+	}
+	var config *WebsiteConfiguration
+	if config, err = vol.metaLoader.loadWebsite(); err != nil {
+		serveWebsiteError(w, r, InternalErrorCode(err))
+		return
+	}
+	if config == nil {
+		serveWebsiteError(w, r, NoSuchWebsiteConfiguration)
+		return
+	}
+
+	if target := config.RedirectAllRequestsTo; target != nil {
+		var protocol = target.Protocol
+		if protocol == "" {
+			protocol = requestProtocol(r)
+		}
+		http.Redirect(w, r, protocol+"://"+target.HostName+r.URL.RequestURI(), http.StatusMovedPermanently)
+		return
+	}
+
+	var key = param.Object()
+	if rule := config.matchRoutingRule(key, 0); rule != nil {
+		location, statusCode := rule.redirectLocation(r, key)
+		http.Redirect(w, r, location, statusCode)
+		return
+	}
+
+	var errorCode = o.serveWebsiteObject(w, r, param, vol, config.indexKey(key), http.StatusOK)
+	if errorCode == nil {
+		return
+	}
+	// the request of a directory without the trailing slash is redirected to the directory
+	if errorCode == NoSuchKey && key != "" && !strings.HasSuffix(key, "/") {
+		if info, err := vol.ObjectMeta(config.indexKey(key + "/")); err == nil && !info.Mode.IsDir() && !info.DeleteMarker {
+			http.Redirect(w, r, (&url.URL{Path: "/" + key + "/"}).EscapedPath(), http.StatusFound)
+			return
+		}
+	}
+	if rule := config.matchRoutingRule(key, errorCode.StatusCode); rule != nil {
+		location, statusCode := rule.redirectLocation(r, key)
+		http.Redirect(w, r, location, statusCode)
+		return
+	}
+	if config.ErrorDocument != nil && errorCode.StatusCode >= http.StatusBadRequest && errorCode.StatusCode < http.StatusInternalServerError {
+		if o.serveWebsiteObject(w, r, param, vol, config.ErrorDocument.Key, errorCode.StatusCode) == nil {
+			return
+		}
+	}
+	serveWebsiteError(w, r, errorCode)
+}
+
+// serveWebsiteObject responds the object with the status code, nothing is responded if it fails.
+func (o *ObjectNode) serveWebsiteObject(w http.ResponseWriter, r *http.Request, param *RequestParam,
+	vol *Volume, key string, statusCode int) *ErrorCode {
+
+	allowed, errorCode := o.websiteObjectAllowed(r, param, key)
+	if errorCode != nil {
+		return errorCode
+	}
+	if !allowed {
+		return AccessDenied
+	}
+
+	fileInfo, err := vol.ObjectMeta(key)
+	if err == syscall.ENOENT || err == nil && (fileInfo.Mode.IsDir() || fileInfo.DeleteMarker) {
+		return NoSuchKey
+	}
+	if err != nil {
+		log.LogErrorf("websiteHandler: get object meta fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, err)
+		return InternalErrorCode(err)
+	}
+	if statusCode == http.StatusOK {
+		if errorCode = checkReadPreconditions(r, fileInfo); errorCode == NotModified {
+			w.Header()[HeaderNameETag] = []string{wrapUnescapedQuot(fileInfo.ETag)}
+			w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
+			_ = errorCode.ServeResponse(w, r)
+			return nil
+		}
+		if errorCode != nil {
+			return errorCode
+		}
+	}
+
+	var contentType = fileInfo.MIMEType
+	if contentType == "" {
+		contentType = HeaderValueTypeStream
+	}
+	w.Header()[HeaderNameContentType] = []string{contentType}
+	w.Header()[HeaderNameContentLength] = []string{strconv.FormatInt(fileInfo.Size, 10)}
+	w.Header()[HeaderNameETag] = []string{wrapUnescapedQuot(fileInfo.ETag)}
+	w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
+	if len(fileInfo.CacheControl) > 0 {
+		w.Header()[HeaderNameCacheControl] = []string{fileInfo.CacheControl}
+	}
+	w.WriteHeader(statusCode)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	if err = vol.ReadInode(key, fileInfo.Inode, w, 0, uint64(fileInfo.Size)); err != nil {
+		log.LogErrorf("websiteHandler: read object fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func parseWebsiteConfiguration(t *testing.T, data string) *WebsiteConfiguration {
+	var config = &WebsiteConfiguration{}
+	if err := xml.Unmarshal([]byte(data), config); err != nil {
+		t.Fatalf("parse website configuration fail: err(%v)", err)
+	}
+	return config
+}
+
+func TestWebsiteConfigurationValidate(t *testing.T) {
+	var cases = []struct {
+		config string
+		valid  bool
+	}{
+		{"<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>", true},
+		{"<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><ErrorDocument><Key>404.html</Key></ErrorDocument></WebsiteConfiguration>", true},
+		{"<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>https</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>", true},
+		{"<WebsiteConfiguration></WebsiteConfiguration>", false},
+		{"<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>", false},
+		{"<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>", false},
+		{"<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>ftp</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>", false},
+		{"<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition><Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>", true},
+		{"<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition></RoutingRule></RoutingRules></WebsiteConfiguration>", false},
+		{"<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><ReplaceKeyPrefixWith>a/</ReplaceKeyPrefixWith><ReplaceKeyWith>b</ReplaceKeyWith></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>", false},
+		{"<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>", false},
+		{"<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition><HttpErrorCodeReturnedEquals>302</HttpErrorCodeReturnedEquals></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>", false},
+	}
+	for i, c := range cases {
+		var config = parseWebsiteConfiguration(t, c.config)
+		if err := config.validate(); (err == nil) != c.valid {
+			t.Fatalf("case %v: validate result mismatch: expect valid(%v) actual err(%v)", i, c.valid, err)
+		}
+	}
+}
+
+func TestWebsiteIndexKey(t *testing.T) {
+	var config = &WebsiteConfiguration{IndexDocument: &IndexDocument{Suffix: "index.html"}}
+	var cases = []struct {
+		key    string
+		expect string
+	}{
+		{"", "index.html"},
+		{"docs/", "docs/index.html"},
+		{"docs", "docs"},
+		{"docs/a.html", "docs/a.html"},
+	}
+	for i, c := range cases {
+		if key := config.indexKey(c.key); key != c.expect {
+			t.Fatalf("case %v: index key mismatch: expect(%v) actual(%v)", i, c.expect, key)
+		}
+	}
+}
+
+func TestWebsiteRoutingRules(t *testing.T) {
+	var config = parseWebsiteConfiguration(t, "<WebsiteConfiguration>"+
+		"<IndexDocument><Suffix>index.html</Suffix></IndexDocument>"+
+		"<RoutingRules>"+
+		"<RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>"+
+		"<Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule>"+
+		"<RoutingRule><Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>"+
+		"<Redirect><HostName>example.com</HostName><Protocol>https</Protocol><HttpRedirectCode>302</HttpRedirectCode><ReplaceKeyWith>404.html</ReplaceKeyWith></Redirect></RoutingRule>"+
+		"</RoutingRules></WebsiteConfiguration>")
+
+	var cases = []struct {
+		key        string
+		statusCode int
+		location   string
+		redirect   int
+	}{
+		{"docs/a.html", 0, "http://bucket.website.io/documents/a.html", http.StatusMovedPermanently},
+		{"images/a.png", 0, "", 0},
+		{"images/a.png", http.StatusNotFound, "https://example.com/404.html", http.StatusFound},
+		{"images/a.png", http.StatusForbidden, "", 0},
+	}
+	var r = httptest.NewRequest(http.MethodGet, "http://bucket.website.io/", nil)
+	for i, c := range cases {
+		var rule = config.matchRoutingRule(c.key, c.statusCode)
+		if rule == nil {
+			if c.location != "" {
+				t.Fatalf("case %v: expect rule matched", i)
+			}
+			continue
+		}
+		if c.location == "" {
+			t.Fatalf("case %v: expect no rule matched", i)
+		}
+		location, statusCode := rule.redirectLocation(r, c.key)
+		if location != c.location || statusCode != c.redirect {
+			t.Fatalf("case %v: redirect mismatch: expect(%v %v) actual(%v %v)", i, c.redirect, c.location, statusCode, location)
+		}
+	}
+}
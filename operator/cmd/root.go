@@ -0,0 +1,87 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cubefs/cubefs/operator/controller"
+	"github.com/cubefs/cubefs/operator/kube"
+	"github.com/cubefs/cubefs/proto"
+)
+
+func NewRootCmd() *cobra.Command {
+	var (
+		optShowVersion bool
+		optServer      string
+		optTokenFile   string
+		optCAFile      string
+		optNamespace   string
+		optResync      time.Duration
+	)
+	var c = &cobra.Command{
+		Use:   path.Base(os.Args[0]),
+		Short: "ChubaoFS operator to deploy the clusters and manage their volumes and users on Kubernetes",
+		Args:  cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if optShowVersion {
+				_, _ = fmt.Fprint(os.Stdout, proto.DumpVersion("OPERATOR"))
+				return nil
+			}
+			var client *kube.Client
+			if optServer == "" {
+				client, err = kube.NewInClusterClient()
+			} else {
+				var token []byte
+				if optTokenFile != "" {
+					if token, err = ioutil.ReadFile(optTokenFile); err != nil {
+						return
+					}
+				}
+				client, err = kube.NewClient(optServer, strings.TrimSpace(string(token)), optCAFile)
+			}
+			if err != nil {
+				return
+			}
+			var stop = make(chan struct{})
+			var signals = make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				log.Printf("received signal %v, exiting", <-signals)
+				close(stop)
+			}()
+			log.Printf("operator started: namespace(%v) resync(%v)", optNamespace, optResync)
+			controller.New(client, optNamespace, optResync).Run(stop)
+			return nil
+		},
+	}
+	c.Flags().BoolVarP(&optShowVersion, "version", "v", false, "Show version information")
+	c.Flags().StringVar(&optServer, "server", "", "address of the Kubernetes API server, the one of the cluster the operator runs in by default")
+	c.Flags().StringVar(&optTokenFile, "token-file", "", "file of the bearer token of the API server given by --server")
+	c.Flags().StringVar(&optCAFile, "ca-file", "", "file of the CA certificate of the API server given by --server")
+	c.Flags().StringVar(&optNamespace, "namespace", "", "namespace of the resources, all the namespaces by default")
+	c.Flags().DurationVar(&optResync, "resync", 30*time.Second, "interval the resources are reconciled in")
+	return c
+}
@@ -0,0 +1,322 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/cubefs/cubefs/operator/kube"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+)
+
+// decommission is a decommission of a node on the master.
+type decommission struct {
+	done bool
+	err  error
+}
+
+func (c *Controller) reconcileCluster(cluster *Cluster) (err error) {
+	if cluster.Metadata.DeletionTimestamp != nil {
+		// the objects of the cluster are deleted with it by their owner references
+		c.mu.Lock()
+		if mc, ok := c.masters[cluster.Metadata.Namespace+"/"+cluster.Metadata.Name]; ok {
+			mc.Stop()
+			delete(c.masters, cluster.Metadata.Namespace+"/"+cluster.Metadata.Name)
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	cluster.setDefaults()
+	var status = ClusterStatus{Phase: PhaseRunning, ObservedGeneration: cluster.Metadata.Generation}
+	var messages []string
+
+	// the masters are not scaled, since their peers are changed by raft instead of their configs
+	var masterSet *kube.StatefulSet
+	if masterSet, err = c.getStatefulSet(cluster, roleMaster); err != nil {
+		return
+	}
+	if masterSet != nil && masterSet.Spec.Replicas != nil && *masterSet.Spec.Replicas != cluster.Spec.Master.Replicas {
+		messages = append(messages, fmt.Sprintf("the replicas of the masters are kept %v, which cannot be changed after the cluster is created",
+			*masterSet.Spec.Replicas))
+		cluster.Spec.Master.Replicas = *masterSet.Spec.Replicas
+	}
+
+	var configMap object
+	if configMap, err = renderConfigMap(cluster); err != nil {
+		return
+	}
+	var ns = cluster.Metadata.Namespace
+	var applies = []struct {
+		path string
+		obj  object
+	}{
+		{kube.Path("", "v1", ns, "configmaps", cluster.Metadata.Name+"-config"), configMap},
+		{kube.Path("", "v1", ns, "services", objectName(cluster, roleMaster)), renderService(cluster, roleMaster, masterPort)},
+		{kube.Path("", "v1", ns, "services", objectName(cluster, roleMetaNode)), renderService(cluster, roleMetaNode, metaNodePort)},
+		{kube.Path("", "v1", ns, "services", objectName(cluster, roleDataNode)), renderService(cluster, roleDataNode, dataNodePort)},
+		{kube.Path("", "v1", ns, "services", objectName(cluster, roleObjectNode)), renderObjectNodeService(cluster)},
+		{kube.Path("apps", "v1", ns, "statefulsets", objectName(cluster, roleMaster)), renderMaster(cluster, cluster.Spec.Master.Replicas)},
+		{kube.Path("apps", "v1", ns, "deployments", objectName(cluster, roleObjectNode)), renderObjectNode(cluster)},
+	}
+	for _, apply := range applies {
+		if err = c.kube.Apply(apply.path, apply.obj); err != nil {
+			return fmt.Errorf("apply %v: %v", apply.path, err)
+		}
+	}
+
+	// the view is nil until the masters elect the leader
+	var mc = c.masterClient(cluster)
+	var view *proto.ClusterView
+	info, err := mc.AdminAPI().GetClusterInfo()
+	if err == nil {
+		view, err = mc.AdminAPI().GetCluster()
+	}
+	if err != nil {
+		status.Phase = PhaseCreating
+		messages = append(messages, fmt.Sprintf("master is not available: %v", err))
+	} else {
+		status.Leader = info.LeaderAddr
+	}
+
+	var scaling bool
+	for _, role := range []struct {
+		name     string
+		port     int
+		replicas int32
+		render   func(cluster *Cluster, replicas int32) object
+	}{
+		{roleMetaNode, metaNodePort, cluster.Spec.MetaNode.Replicas, renderMetaNode},
+		{roleDataNode, dataNodePort, cluster.Spec.DataNode.Replicas, renderDataNode},
+	} {
+		var replicas int32
+		var message string
+		if replicas, message, err = c.scaleReplicas(cluster, mc, view, role.name, role.port, role.replicas); err != nil {
+			return
+		}
+		if replicas != role.replicas {
+			scaling = true
+		}
+		if message != "" {
+			messages = append(messages, message)
+		}
+		var path = kube.Path("apps", "v1", ns, "statefulsets", objectName(cluster, role.name))
+		if err = c.kube.Apply(path, role.render(cluster, replicas)); err != nil {
+			return fmt.Errorf("apply %v: %v", path, err)
+		}
+	}
+
+	// the masters are upgraded before the meta nodes, and the meta nodes before the data nodes
+	var upgrading bool
+	for _, role := range []struct {
+		name   string
+		port   int
+		status *RoleStatus
+	}{
+		{roleMaster, masterPort, &status.Master},
+		{roleMetaNode, metaNodePort, &status.MetaNode},
+		{roleDataNode, dataNodePort, &status.DataNode},
+	} {
+		var done bool
+		if done, err = c.upgradeRole(cluster, role.name, role.port, view, status.Leader, role.status, !upgrading); err != nil {
+			return
+		}
+		upgrading = upgrading || !done
+	}
+	var deployment kube.Deployment
+	if err = c.kube.Get(kube.Path("apps", "v1", ns, "deployments", objectName(cluster, roleObjectNode)), nil, &deployment); err != nil {
+		return
+	}
+	status.ObjectNode = RoleStatus{
+		Replicas: deployment.Status.Replicas,
+		Ready:    deployment.Status.ReadyReplicas,
+		Updated:  deployment.Status.UpdatedReplicas,
+	}
+
+	if status.Phase == PhaseRunning && upgrading {
+		status.Phase = PhaseUpgrading
+	} else if status.Phase == PhaseRunning && scaling {
+		status.Phase = PhaseScaling
+	}
+	status.Message = strings.Join(messages, "; ")
+	return c.updateStatus(ResourceClusters, &cluster.Metadata, &status)
+}
+
+// getStatefulSet returns the stateful set of the role, nil if it does not exist.
+func (c *Controller) getStatefulSet(cluster *Cluster, role string) (*kube.StatefulSet, error) {
+	var set kube.StatefulSet
+	var path = kube.Path("apps", "v1", cluster.Metadata.Namespace, "statefulsets", objectName(cluster, role))
+	if err := c.kube.Get(path, nil, &set); err != nil {
+		if kube.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &set, nil
+}
+
+// nodeAddr returns the address the node of the pod registers on the master, which runs in the host network.
+func nodeAddr(pod *kube.Pod, port int) string {
+	return fmt.Sprintf("%v:%v", pod.Status.HostIP, port)
+}
+
+// findNode returns the node of the address in the view, nil if the master does not have it.
+func findNode(view *proto.ClusterView, role, addr string) *proto.NodeView {
+	var nodes = view.MetaNodes
+	if role == roleDataNode {
+		nodes = view.DataNodes
+	}
+	for i := range nodes {
+		if nodes[i].Addr == addr {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+// scaleReplicas returns the replicas of the meta or the data nodes to apply. They are scaled up at once,
+// while the pods above the replicas of the spec are removed one by one from the highest ordinal, each
+// after its node is decommissioned on the master, which moves its partitions to the other nodes.
+func (c *Controller) scaleReplicas(cluster *Cluster, mc *master.MasterClient, view *proto.ClusterView, role string, port int,
+	desired int32) (replicas int32, message string, err error) {
+	var set *kube.StatefulSet
+	if set, err = c.getStatefulSet(cluster, role); err != nil || set == nil || set.Spec.Replicas == nil || *set.Spec.Replicas <= desired {
+		return desired, "", err
+	}
+	var current = *set.Spec.Replicas
+	if view == nil {
+		return current, fmt.Sprintf("waiting for the master to decommission the %vs", role), nil
+	}
+	var pod kube.Pod
+	var name = fmt.Sprintf("%v-%v", objectName(cluster, role), current-1)
+	if err = c.kube.Get(kube.Path("", "v1", cluster.Metadata.Namespace, "pods", name), nil, &pod); err != nil {
+		if kube.IsNotFound(err) {
+			return current - 1, "", nil
+		}
+		return current, "", err
+	}
+	if pod.Status.HostIP == "" {
+		// the pod has never been scheduled, so it has no node on the master
+		return current - 1, "", nil
+	}
+	var addr = nodeAddr(&pod, port)
+	if findNode(view, role, addr) == nil {
+		c.mu.Lock()
+		delete(c.decommissions, addr)
+		c.mu.Unlock()
+		log.Printf("cluster %v/%v: %v %v decommissioned, scaling to %v", cluster.Metadata.Namespace, cluster.Metadata.Name, role, addr, current-1)
+		return current - 1, "", nil
+	}
+	return current, c.decommission(cluster, mc, role, addr), nil
+}
+
+// decommission decommissions the node on the master in the background, since it takes long to move
+// the partitions away, and returns the progress. It is retried if it fails.
+func (c *Controller) decommission(cluster *Cluster, mc *master.MasterClient, role, addr string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.decommissions[addr]; ok {
+		if !d.done || d.err == nil {
+			return fmt.Sprintf("decommissioning %v %v", role, addr)
+		}
+		delete(c.decommissions, addr)
+		return fmt.Sprintf("decommission %v %v failed: %v, retrying", role, addr, d.err)
+	}
+	var d = &decommission{}
+	c.decommissions[addr] = d
+	log.Printf("cluster %v/%v: decommission %v %v", cluster.Metadata.Namespace, cluster.Metadata.Name, role, addr)
+	go func() {
+		var err error
+		if role == roleMetaNode {
+			err = mc.NodeAPI().MetaNodeDecommission(addr)
+		} else {
+			err = mc.NodeAPI().DataNodeDecommission(addr)
+		}
+		if err != nil {
+			log.Printf("cluster %v/%v: decommission %v %v failed: %v", cluster.Metadata.Namespace, cluster.Metadata.Name, role, addr, err)
+		}
+		c.mu.Lock()
+		d.done, d.err = true, err
+		c.mu.Unlock()
+	}()
+	return fmt.Sprintf("decommissioning %v %v", role, addr)
+}
+
+// upgradeRole fills the status of the role, and deletes a pod not of the update revision of the stateful
+// set if proceed is set, all the pods are ready and the master reports their nodes active, so that the
+// pods are recreated by the new spec one by one. The leader of the masters is upgraded last. It returns
+// whether all the pods are of the update revision.
+func (c *Controller) upgradeRole(cluster *Cluster, role string, port int, view *proto.ClusterView, leader string,
+	status *RoleStatus, proceed bool) (done bool, err error) {
+	var set *kube.StatefulSet
+	if set, err = c.getStatefulSet(cluster, role); err != nil || set == nil {
+		return set == nil, err
+	}
+	status.Replicas = set.Status.Replicas
+	status.Ready = set.Status.ReadyReplicas
+	status.Updated = set.Status.UpdatedReplicas
+	var pods []kube.Pod
+	if pods, err = c.listPods(cluster, role); err != nil {
+		return
+	}
+	var outdated []kube.Pod
+	var ready = true
+	for _, pod := range pods {
+		if pod.Metadata.Labels[labelRevision] != set.Status.UpdateRevision {
+			outdated = append(outdated, pod)
+		}
+		if !pod.Ready() || pod.Metadata.DeletionTimestamp != nil {
+			ready = false
+		}
+		if role == roleMaster {
+			if pod.Ready() {
+				status.Active++
+			}
+		} else if view != nil {
+			if node := findNode(view, role, nodeAddr(&pod, port)); node != nil && node.Status {
+				status.Active++
+			}
+		}
+	}
+	if set.Status.ObservedGeneration < set.Metadata.Generation {
+		// the update revision is not computed for the new spec yet
+		return false, nil
+	}
+	if len(outdated) == 0 {
+		return true, nil
+	}
+	if !proceed || view == nil || !ready || set.Spec.Replicas == nil || int32(len(pods)) < *set.Spec.Replicas || status.Active < len(pods) {
+		return false, nil
+	}
+	sort.Slice(outdated, func(i, j int) bool {
+		var iLeader = strings.HasPrefix(leader, outdated[i].Metadata.Name+".")
+		var jLeader = strings.HasPrefix(leader, outdated[j].Metadata.Name+".")
+		if iLeader != jLeader {
+			return jLeader
+		}
+		// the highest ordinal first
+		var iName, jName = outdated[i].Metadata.Name, outdated[j].Metadata.Name
+		if len(iName) != len(jName) {
+			return len(iName) > len(jName)
+		}
+		return iName > jName
+	})
+	var pod = outdated[0]
+	log.Printf("cluster %v/%v: upgrade %v pod %v", cluster.Metadata.Namespace, cluster.Metadata.Name, role, pod.Metadata.Name)
+	return false, c.kube.Delete(kube.Path("", "v1", cluster.Metadata.Namespace, "pods", pod.Metadata.Name))
+}
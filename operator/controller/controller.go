@@ -0,0 +1,187 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package controller reconciles the custom resources of the operator. The clusters are deployed as the
+// stateful sets of the masters, the meta nodes and the data nodes, and the deployment of the object nodes.
+// The meta and the data nodes removed by the scaling are decommissioned on the master before their pods
+// are deleted, and the pods are upgraded one by one while the master reports the nodes active. The volumes
+// and the users are created and updated by the APIs of the master.
+//
+// The resources are reconciled by listing them in an interval instead of watching them, since the states
+// on the master change without the events of the Kubernetes API anyway.
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/operator/kube"
+	"github.com/cubefs/cubefs/sdk/master"
+)
+
+// Controller reconciles the clusters, the volumes and the users.
+type Controller struct {
+	kube      *kube.Client
+	namespace string // the namespace of the resources, all the namespaces if empty
+	resync    time.Duration
+
+	mu sync.Mutex
+	// the clients of the masters of the clusters by their namespaces and names
+	masters map[string]*masterClient
+	// the decommissions in progress by the addresses of the nodes
+	decommissions map[string]*decommission
+}
+
+// New returns the controller of the resources in the namespace, or all the namespaces if it is empty.
+func New(client *kube.Client, namespace string, resync time.Duration) *Controller {
+	return &Controller{
+		kube:          client,
+		namespace:     namespace,
+		resync:        resync,
+		masters:       make(map[string]*masterClient),
+		decommissions: make(map[string]*decommission),
+	}
+}
+
+// Run reconciles the resources in the interval until stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	var ticker = time.NewTicker(c.resync)
+	defer ticker.Stop()
+	for {
+		c.reconcileAll()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Controller) reconcileAll() {
+	var clusters clusterList
+	if err := c.kube.Get(kube.Path(Group, Version, c.namespace, ResourceClusters, ""), nil, &clusters); err != nil {
+		log.Printf("list clusters failed: %v", err)
+	}
+	for _, cluster := range clusters.Items {
+		if err := c.reconcileCluster(cluster); err != nil {
+			log.Printf("reconcile cluster %v/%v failed: %v", cluster.Metadata.Namespace, cluster.Metadata.Name, err)
+		}
+	}
+
+	var volumes volumeList
+	if err := c.kube.Get(kube.Path(Group, Version, c.namespace, ResourceVolumes, ""), nil, &volumes); err != nil {
+		log.Printf("list volumes failed: %v", err)
+	}
+	for _, volume := range volumes.Items {
+		if err := c.reconcileVolume(volume); err != nil {
+			log.Printf("reconcile volume %v/%v failed: %v", volume.Metadata.Namespace, volume.Metadata.Name, err)
+		}
+	}
+
+	var users userList
+	if err := c.kube.Get(kube.Path(Group, Version, c.namespace, ResourceUsers, ""), nil, &users); err != nil {
+		log.Printf("list users failed: %v", err)
+	}
+	for _, user := range users.Items {
+		if err := c.reconcileUser(user); err != nil {
+			log.Printf("reconcile user %v/%v failed: %v", user.Metadata.Namespace, user.Metadata.Name, err)
+		}
+	}
+}
+
+// updateStatus replaces the status of the resource.
+func (c *Controller) updateStatus(resource string, meta *kube.ObjectMeta, status interface{}) error {
+	var path = kube.Path(Group, Version, meta.Namespace, resource, meta.Name) + "/status"
+	return c.kube.MergePatch(path, map[string]interface{}{"status": status})
+}
+
+// setFinalizers replaces the finalizers of the resource, which fails if the resource has been modified
+// since it was read, so that the finalizers added by the others are not lost.
+func (c *Controller) setFinalizers(resource string, meta *kube.ObjectMeta, finalizers []string) error {
+	var path = kube.Path(Group, Version, meta.Namespace, resource, meta.Name)
+	if finalizers == nil {
+		finalizers = []string{}
+	}
+	return c.kube.MergePatch(path, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": meta.ResourceVersion,
+			"finalizers":      finalizers,
+		},
+	})
+}
+
+func (c *Controller) addFinalizer(resource string, meta *kube.ObjectMeta) error {
+	if meta.HasFinalizer(Finalizer) {
+		return nil
+	}
+	return c.setFinalizers(resource, meta, append(meta.Finalizers, Finalizer))
+}
+
+func (c *Controller) removeFinalizer(resource string, meta *kube.ObjectMeta) error {
+	var finalizers []string
+	for _, f := range meta.Finalizers {
+		if f != Finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	return c.setFinalizers(resource, meta, finalizers)
+}
+
+type masterClient struct {
+	addrs string
+	*master.MasterClient
+}
+
+// masterClient returns the client of the masters of the cluster, which is kept for the cluster since
+// it probes the masters in the background until it is stopped.
+func (c *Controller) masterClient(cluster *Cluster) *master.MasterClient {
+	var key = cluster.Metadata.Namespace + "/" + cluster.Metadata.Name
+	var addrs = masterAddrs(cluster)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mc, ok := c.masters[key]; ok {
+		if mc.addrs == strings.Join(addrs, ",") {
+			return mc.MasterClient
+		}
+		mc.Stop()
+	}
+	var mc = &masterClient{addrs: strings.Join(addrs, ","), MasterClient: master.NewMasterClient(addrs, false)}
+	c.masters[key] = mc
+	return mc.MasterClient
+}
+
+// masterClientOf returns the client of the masters of the cluster of the name in the namespace.
+func (c *Controller) masterClientOf(namespace, name string) (*master.MasterClient, error) {
+	var cluster Cluster
+	if err := c.kube.Get(kube.Path(Group, Version, namespace, ResourceClusters, name), nil, &cluster); err != nil {
+		return nil, fmt.Errorf("get cluster %v: %v", name, err)
+	}
+	cluster.setDefaults()
+	return c.masterClient(&cluster), nil
+}
+
+// listPods lists the pods of the role of the cluster.
+func (c *Controller) listPods(cluster *Cluster, role string) ([]kube.Pod, error) {
+	var pods kube.PodList
+	var selector = fmt.Sprintf("%v=%v,%v=%v", labelInstance, cluster.Metadata.Name, labelComponent, role)
+	if err := c.kube.Get(kube.Path("", "v1", cluster.Metadata.Namespace, "pods", ""),
+		url.Values{"labelSelector": {selector}}, &pods); err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
@@ -0,0 +1,394 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the roles of the pods, which are also the roles in the configs of the servers
+const (
+	roleMaster     = "master"
+	roleMetaNode   = "metanode"
+	roleDataNode   = "datanode"
+	roleObjectNode = "objectnode"
+)
+
+// the ports of the servers
+const (
+	masterPort     = 17010
+	masterProfPort = 17020
+	metaNodePort   = 17210
+	dataNodePort   = 17310
+	objectNodePort = 17410
+)
+
+// the labels of the objects of the clusters
+const (
+	labelName      = "app.kubernetes.io/name"
+	labelInstance  = "app.kubernetes.io/instance"
+	labelComponent = "app.kubernetes.io/component"
+	labelManagedBy = "app.kubernetes.io/managed-by"
+	labelRevision  = "controller-revision-hash"
+)
+
+// the paths in the containers
+const (
+	templateDir = "/cfs/template"
+	dataDir     = "/cfs/data"
+	logDir      = "/cfs/log"
+	diskDir     = "/cfs/disk"
+)
+
+// startScript renders the config of the role from the template and starts the server. The masters take
+// their IDs from the ordinals of the pods, and their addresses from the names of the pods.
+const startScript = `#!/bin/sh
+set -e
+mkdir -p /cfs/conf ` + logDir + ` ` + dataDir + `
+ID=0
+HOST=${POD_NAME}.${SERVICE_NAME}.${POD_NAMESPACE}.svc
+if [ "$ROLE" = "master" ]; then
+    ID=$((${POD_NAME##*-} + 1))
+fi
+sed -e "s/__ID__/${ID}/g" -e "s/__HOST__/${HOST}/g" ` + templateDir + `/${ROLE}.json > /cfs/conf/${ROLE}.json
+exec /cfs/bin/cfs-server -f -c /cfs/conf/${ROLE}.json
+`
+
+type object = map[string]interface{}
+
+func objectName(cluster *Cluster, role string) string {
+	return cluster.Metadata.Name + "-" + role
+}
+
+// masterAddrs returns the addresses of the masters by the DNS names of their pods.
+func masterAddrs(cluster *Cluster) []string {
+	var addrs = make([]string, 0, cluster.Spec.Master.Replicas)
+	for i := int32(0); i < cluster.Spec.Master.Replicas; i++ {
+		addrs = append(addrs, fmt.Sprintf("%v:%v", masterHost(cluster, i), masterPort))
+	}
+	return addrs
+}
+
+func masterHost(cluster *Cluster, ordinal int32) string {
+	var name = objectName(cluster, roleMaster)
+	return fmt.Sprintf("%v-%v.%v.%v.svc", name, ordinal, name, cluster.Metadata.Namespace)
+}
+
+func labels(cluster *Cluster, role string) map[string]string {
+	return map[string]string{
+		labelName:      "chubaofs",
+		labelInstance:  cluster.Metadata.Name,
+		labelComponent: role,
+	}
+}
+
+func metadata(cluster *Cluster, name, role string) object {
+	var l = labels(cluster, role)
+	if role == "" {
+		delete(l, labelComponent)
+	}
+	l[labelManagedBy] = "cfs-operator"
+	return object{
+		"name":      name,
+		"namespace": cluster.Metadata.Namespace,
+		"labels":    l,
+		"ownerReferences": []object{{
+			"apiVersion":         APIVersion,
+			"kind":               KindCluster,
+			"name":               cluster.Metadata.Name,
+			"uid":                cluster.Metadata.UID,
+			"controller":         true,
+			"blockOwnerDeletion": true,
+		}},
+	}
+}
+
+// serverConfigs returns the templates of the configs of the roles, with the keys of the spec overriding.
+func serverConfigs(cluster *Cluster) (configs map[string]string, err error) {
+	var masters = masterAddrs(cluster)
+	var peers = make([]string, 0, cluster.Spec.Master.Replicas)
+	for i := int32(0); i < cluster.Spec.Master.Replicas; i++ {
+		peers = append(peers, fmt.Sprintf("%v:%v:%v", i+1, masterHost(cluster, i), masterPort))
+	}
+	var disks = make([]string, 0, len(cluster.Spec.DataNode.Disks))
+	for i, disk := range cluster.Spec.DataNode.Disks {
+		var reserved = "0"
+		if index := strings.LastIndex(disk, ":"); index >= 0 {
+			reserved = disk[index+1:]
+		}
+		disks = append(disks, fmt.Sprintf("%v%v:%v", diskDir, i, reserved))
+	}
+	var templates = map[string]object{
+		roleMaster: {
+			"role":        roleMaster,
+			"clusterName": cluster.Metadata.Name,
+			"id":          "__ID__",
+			"ip":          "__HOST__",
+			"listen":      strconv.Itoa(masterPort),
+			"prof":        strconv.Itoa(masterProfPort),
+			"peers":       strings.Join(peers, ","),
+			"retainLogs":  "20000",
+			"logLevel":    "info",
+			"logDir":      logDir,
+			"walDir":      dataDir + "/wal",
+			"storeDir":    dataDir + "/store",
+		},
+		roleMetaNode: {
+			"role":              roleMetaNode,
+			"listen":            strconv.Itoa(metaNodePort),
+			"prof":              strconv.Itoa(metaNodePort + 10),
+			"raftHeartbeatPort": strconv.Itoa(metaNodePort + 20),
+			"raftReplicaPort":   strconv.Itoa(metaNodePort + 30),
+			"logLevel":          "info",
+			"logDir":            logDir,
+			"totalMem":          strconv.FormatUint(cluster.Spec.MetaNode.TotalMem, 10),
+			"metadataDir":       dataDir + "/meta",
+			"raftDir":           dataDir + "/raft",
+			"masterAddr":        masters,
+		},
+		roleDataNode: {
+			"role":          roleDataNode,
+			"listen":        strconv.Itoa(dataNodePort),
+			"prof":          strconv.Itoa(dataNodePort + 10),
+			"raftHeartbeat": strconv.Itoa(dataNodePort + 20),
+			"raftReplica":   strconv.Itoa(dataNodePort + 30),
+			"raftDir":       dataDir + "/raft",
+			"logLevel":      "info",
+			"logDir":        logDir,
+			"disks":         disks,
+			"masterAddr":    masters,
+		},
+		roleObjectNode: {
+			"role":       roleObjectNode,
+			"listen":     strconv.Itoa(objectNodePort),
+			"logLevel":   "info",
+			"logDir":     logDir,
+			"domains":    cluster.Spec.ObjectNode.Domains,
+			"masterAddr": masters,
+		},
+	}
+	var overrides = map[string]map[string]interface{}{
+		roleMaster:     cluster.Spec.Master.Config,
+		roleMetaNode:   cluster.Spec.MetaNode.Config,
+		roleDataNode:   cluster.Spec.DataNode.Config,
+		roleObjectNode: cluster.Spec.ObjectNode.Config,
+	}
+	configs = make(map[string]string, len(templates)+1)
+	for role, template := range templates {
+		for key, value := range overrides[role] {
+			template[key] = value
+		}
+		var data []byte
+		if data, err = json.MarshalIndent(template, "", "  "); err != nil {
+			return nil, err
+		}
+		configs[role+".json"] = string(data)
+	}
+	configs["start.sh"] = startScript
+	return configs, nil
+}
+
+func renderConfigMap(cluster *Cluster) (object, error) {
+	configs, err := serverConfigs(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return object{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata(cluster, cluster.Metadata.Name+"-config", ""),
+		"data":       configs,
+	}, nil
+}
+
+// renderService renders the headless service of the role, which publishes the addresses of the pods
+// not ready yet, so that the masters find each other to elect the leader.
+func renderService(cluster *Cluster, role string, port int) object {
+	return object{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   metadata(cluster, objectName(cluster, role), role),
+		"spec": object{
+			"clusterIP":                "None",
+			"publishNotReadyAddresses": true,
+			"selector":                 labels(cluster, role),
+			"ports":                    []object{{"name": "listen", "port": port}},
+		},
+	}
+}
+
+// renderPodTemplate renders the pod of the role. The meta and the data nodes run in the host network,
+// so that they keep the addresses registered on the master after the pods are recreated.
+func renderPodTemplate(cluster *Cluster, role string, spec *RoleSpec, port int, volumes, mounts []object) object {
+	var hostNetwork = role == roleMetaNode || role == roleDataNode
+	volumes = append(volumes, object{
+		"name":      "template",
+		"configMap": object{"name": cluster.Metadata.Name + "-config"},
+	})
+	mounts = append(mounts, object{"name": "template", "mountPath": templateDir})
+	var container = object{
+		"name":            role,
+		"image":           cluster.Spec.Image,
+		"imagePullPolicy": cluster.Spec.ImagePullPolicy,
+		"command":         []string{"/bin/sh", templateDir + "/start.sh"},
+		"env": []object{
+			{"name": "ROLE", "value": role},
+			{"name": "SERVICE_NAME", "value": objectName(cluster, role)},
+			{"name": "POD_NAME", "valueFrom": object{"fieldRef": object{"fieldPath": "metadata.name"}}},
+			{"name": "POD_NAMESPACE", "valueFrom": object{"fieldRef": object{"fieldPath": "metadata.namespace"}}},
+		},
+		"ports":        []object{{"name": "listen", "containerPort": port}},
+		"volumeMounts": mounts,
+		"readinessProbe": object{
+			"tcpSocket":           object{"port": port},
+			"initialDelaySeconds": 5,
+			"periodSeconds":       10,
+		},
+	}
+	if len(spec.Resources) > 0 {
+		container["resources"] = spec.Resources
+	}
+	var podSpec = object{
+		"containers": []object{container},
+		"volumes":    volumes,
+	}
+	if len(spec.NodeSelector) > 0 {
+		podSpec["nodeSelector"] = spec.NodeSelector
+	}
+	if hostNetwork {
+		podSpec["hostNetwork"] = true
+		podSpec["dnsPolicy"] = "ClusterFirstWithHostNet"
+	}
+	if role != roleObjectNode {
+		podSpec["affinity"] = object{
+			"podAntiAffinity": object{
+				"requiredDuringSchedulingIgnoredDuringExecution": []object{{
+					"labelSelector": object{"matchLabels": labels(cluster, role)},
+					"topologyKey":   "kubernetes.io/hostname",
+				}},
+			},
+		}
+	}
+	return object{
+		"metadata": object{"labels": labels(cluster, role)},
+		"spec":     podSpec,
+	}
+}
+
+// renderStatefulSet renders the stateful set of the role, whose pods are deleted by the operator to be
+// upgraded one by one.
+func renderStatefulSet(cluster *Cluster, role string, replicas int32, template object, claims []object) object {
+	var spec = object{
+		"serviceName":         objectName(cluster, role),
+		"replicas":            replicas,
+		"podManagementPolicy": "Parallel",
+		"updateStrategy":      object{"type": "OnDelete"},
+		"selector":            object{"matchLabels": labels(cluster, role)},
+		"template":            template,
+	}
+	if len(claims) > 0 {
+		spec["volumeClaimTemplates"] = claims
+	}
+	return object{
+		"apiVersion": "apps/v1",
+		"kind":       "StatefulSet",
+		"metadata":   metadata(cluster, objectName(cluster, role), role),
+		"spec":       spec,
+	}
+}
+
+func renderMaster(cluster *Cluster, replicas int32) object {
+	var spec = &cluster.Spec.Master
+	var claim = object{
+		"metadata": object{"name": "data"},
+		"spec": object{
+			"accessModes": []string{"ReadWriteOnce"},
+			"resources":   object{"requests": object{"storage": spec.Storage}},
+		},
+	}
+	if spec.StorageClass != "" {
+		claim["spec"].(object)["storageClassName"] = spec.StorageClass
+	}
+	var template = renderPodTemplate(cluster, roleMaster, &spec.RoleSpec, masterPort,
+		[]object{{"name": "log", "emptyDir": object{}}},
+		[]object{{"name": "data", "mountPath": dataDir}, {"name": "log", "mountPath": logDir}})
+	return renderStatefulSet(cluster, roleMaster, replicas, template, []object{claim})
+}
+
+func hostPathVolume(name, path string) object {
+	return object{"name": name, "hostPath": object{"path": path, "type": "DirectoryOrCreate"}}
+}
+
+func renderMetaNode(cluster *Cluster, replicas int32) object {
+	var hostDir = fmt.Sprintf("%v/%v/%v", cluster.Spec.DataDir, cluster.Metadata.Name, roleMetaNode)
+	var template = renderPodTemplate(cluster, roleMetaNode, &cluster.Spec.MetaNode.RoleSpec, metaNodePort,
+		[]object{hostPathVolume("data", hostDir+"/data"), hostPathVolume("log", hostDir+"/log")},
+		[]object{{"name": "data", "mountPath": dataDir}, {"name": "log", "mountPath": logDir}})
+	return renderStatefulSet(cluster, roleMetaNode, replicas, template, nil)
+}
+
+func renderDataNode(cluster *Cluster, replicas int32) object {
+	var hostDir = fmt.Sprintf("%v/%v/%v", cluster.Spec.DataDir, cluster.Metadata.Name, roleDataNode)
+	var volumes = []object{hostPathVolume("data", hostDir+"/data"), hostPathVolume("log", hostDir+"/log")}
+	var mounts = []object{{"name": "data", "mountPath": dataDir}, {"name": "log", "mountPath": logDir}}
+	for i, disk := range cluster.Spec.DataNode.Disks {
+		if index := strings.LastIndex(disk, ":"); index >= 0 {
+			disk = disk[:index]
+		}
+		var name = fmt.Sprintf("disk%v", i)
+		volumes = append(volumes, hostPathVolume(name, disk))
+		mounts = append(mounts, object{"name": name, "mountPath": fmt.Sprintf("%v%v", diskDir, i)})
+	}
+	var template = renderPodTemplate(cluster, roleDataNode, &cluster.Spec.DataNode.RoleSpec, dataNodePort, volumes, mounts)
+	return renderStatefulSet(cluster, roleDataNode, replicas, template, nil)
+}
+
+// renderObjectNode renders the deployment of the object nodes, which are stateless and upgraded by the
+// rolling update of the deployment.
+func renderObjectNode(cluster *Cluster) object {
+	var spec = &cluster.Spec.ObjectNode
+	var template = renderPodTemplate(cluster, roleObjectNode, &spec.RoleSpec, objectNodePort,
+		[]object{{"name": "log", "emptyDir": object{}}}, []object{{"name": "log", "mountPath": logDir}})
+	return object{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   metadata(cluster, objectName(cluster, roleObjectNode), roleObjectNode),
+		"spec": object{
+			"replicas": spec.Replicas,
+			"selector": object{"matchLabels": labels(cluster, roleObjectNode)},
+			"strategy": object{
+				"type":          "RollingUpdate",
+				"rollingUpdate": object{"maxUnavailable": 1, "maxSurge": 0},
+			},
+			"template": template,
+		},
+	}
+}
+
+// renderObjectNodeService renders the service the S3 clients access the object nodes by.
+func renderObjectNodeService(cluster *Cluster) object {
+	return object{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   metadata(cluster, objectName(cluster, roleObjectNode), roleObjectNode),
+		"spec": object{
+			"selector": labels(cluster, roleObjectNode),
+			"ports":    []object{{"name": "s3", "port": 80, "targetPort": objectNodePort}},
+		},
+	}
+}
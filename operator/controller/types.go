@@ -0,0 +1,249 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/cubefs/cubefs/operator/kube"
+)
+
+// the custom resources of the operator
+const (
+	Group      = "chubaofs.io"
+	Version    = "v1alpha1"
+	APIVersion = Group + "/" + Version
+
+	KindCluster = "Cluster"
+	KindVolume  = "Volume"
+	KindUser    = "User"
+
+	ResourceClusters = "clusters"
+	ResourceVolumes  = "volumes"
+	ResourceUsers    = "users"
+
+	// Finalizer keeps the volumes and the users until they are reclaimed on the cluster.
+	Finalizer = Group + "/reclaim"
+)
+
+// the reclaim policies of the volumes and the users deleted
+const (
+	ReclaimRetain = "Retain"
+	ReclaimDelete = "Delete"
+)
+
+// the phases of the resources
+const (
+	PhaseCreating  = "Creating"
+	PhaseRunning   = "Running"
+	PhaseScaling   = "Scaling"
+	PhaseUpgrading = "Upgrading"
+	PhaseReady     = "Ready"
+	PhaseFailed    = "Failed"
+)
+
+// the defaults of the clusters
+const (
+	defaultDataDir          = "/var/lib/chubaofs"
+	defaultMasterReplicas   = 3
+	defaultMasterStorage    = "10Gi"
+	defaultMetaNodeTotalMem = 4 << 30
+	defaultImagePullPolicy  = "IfNotPresent"
+)
+
+// RoleSpec is the spec of the pods of a role.
+type RoleSpec struct {
+	Replicas     int32                  `json:"replicas"`
+	NodeSelector map[string]string      `json:"nodeSelector,omitempty"`
+	Resources    json.RawMessage        `json:"resources,omitempty"`
+	Config       map[string]interface{} `json:"config,omitempty"` // the keys of the config of the role to override
+}
+
+// MasterSpec is the spec of the masters, which keep the data in the persistent volumes.
+type MasterSpec struct {
+	RoleSpec
+	StorageClass string `json:"storageClass,omitempty"`
+	Storage      string `json:"storage,omitempty"`
+}
+
+// MetaNodeSpec is the spec of the meta nodes, which run in the host network and keep the data
+// in the data directory of the hosts.
+type MetaNodeSpec struct {
+	RoleSpec
+	TotalMem uint64 `json:"totalMem,omitempty"`
+}
+
+// DataNodeSpec is the spec of the data nodes, which run in the host network and keep the data
+// in the disks of the hosts.
+type DataNodeSpec struct {
+	RoleSpec
+	Disks []string `json:"disks"` // the paths of the disks on the hosts with the reserved bytes, as PATH:RESERVED
+}
+
+// ObjectNodeSpec is the spec of the object nodes.
+type ObjectNodeSpec struct {
+	RoleSpec
+	Domains []string `json:"domains,omitempty"`
+}
+
+// ClusterSpec is the spec of a cluster.
+type ClusterSpec struct {
+	Image           string         `json:"image"`
+	ImagePullPolicy string         `json:"imagePullPolicy,omitempty"`
+	DataDir         string         `json:"dataDir,omitempty"`
+	Master          MasterSpec     `json:"master"`
+	MetaNode        MetaNodeSpec   `json:"metaNode"`
+	DataNode        DataNodeSpec   `json:"dataNode"`
+	ObjectNode      ObjectNodeSpec `json:"objectNode"`
+}
+
+// RoleStatus is the status of the pods of a role.
+type RoleStatus struct {
+	Replicas int32 `json:"replicas"`
+	Ready    int32 `json:"ready"`
+	Updated  int32 `json:"updated"`
+	Active   int   `json:"active"` // the nodes active on the master
+}
+
+// ClusterStatus is the status of a cluster.
+type ClusterStatus struct {
+	Phase              string     `json:"phase,omitempty"`
+	Message            string     `json:"message,omitempty"`
+	ObservedGeneration int64      `json:"observedGeneration,omitempty"`
+	Leader             string     `json:"leader,omitempty"`
+	Master             RoleStatus `json:"master"`
+	MetaNode           RoleStatus `json:"metaNode"`
+	DataNode           RoleStatus `json:"dataNode"`
+	ObjectNode         RoleStatus `json:"objectNode"`
+}
+
+// Cluster is a cluster deployed by the operator.
+type Cluster struct {
+	Metadata kube.ObjectMeta `json:"metadata"`
+	Spec     ClusterSpec     `json:"spec"`
+	Status   ClusterStatus   `json:"status"`
+}
+
+type clusterList struct {
+	Items []*Cluster `json:"items"`
+}
+
+func (c *Cluster) setDefaults() {
+	if c.Spec.ImagePullPolicy == "" {
+		c.Spec.ImagePullPolicy = defaultImagePullPolicy
+	}
+	if c.Spec.DataDir == "" {
+		c.Spec.DataDir = defaultDataDir
+	}
+	if c.Spec.Master.Replicas == 0 {
+		c.Spec.Master.Replicas = defaultMasterReplicas
+	}
+	if c.Spec.Master.Storage == "" {
+		c.Spec.Master.Storage = defaultMasterStorage
+	}
+	if c.Spec.MetaNode.TotalMem == 0 {
+		c.Spec.MetaNode.TotalMem = defaultMetaNodeTotalMem
+	}
+}
+
+// VolumeSpec is the spec of a volume of a cluster in the same namespace.
+type VolumeSpec struct {
+	Cluster           string `json:"cluster"`
+	Name              string `json:"name,omitempty"` // the name of the resource by default
+	Owner             string `json:"owner"`
+	Capacity          uint64 `json:"capacity"` // GB
+	MetaPartitions    int    `json:"metaPartitions,omitempty"`
+	DataPartitionSize uint64 `json:"dataPartitionSize,omitempty"` // GB
+	FollowerRead      bool   `json:"followerRead,omitempty"`
+	ZoneName          string `json:"zoneName,omitempty"`
+	CrossZone         bool   `json:"crossZone,omitempty"`
+	ReclaimPolicy     string `json:"reclaimPolicy,omitempty"`
+}
+
+// VolumeStatus is the status of a volume.
+type VolumeStatus struct {
+	Phase    string `json:"phase,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Capacity uint64 `json:"capacity,omitempty"`
+}
+
+// Volume is a volume created by the operator.
+type Volume struct {
+	Metadata kube.ObjectMeta `json:"metadata"`
+	Spec     VolumeSpec      `json:"spec"`
+	Status   VolumeStatus    `json:"status"`
+}
+
+type volumeList struct {
+	Items []*Volume `json:"items"`
+}
+
+func (v *Volume) setDefaults() {
+	if v.Spec.Name == "" {
+		v.Spec.Name = v.Metadata.Name
+	}
+	if v.Spec.MetaPartitions == 0 {
+		v.Spec.MetaPartitions = 3
+	}
+	if v.Spec.DataPartitionSize == 0 {
+		v.Spec.DataPartitionSize = 120
+	}
+	if v.Spec.ReclaimPolicy == "" {
+		v.Spec.ReclaimPolicy = ReclaimRetain
+	}
+}
+
+// UserSpec is the spec of a user of a cluster in the same namespace, whose keys are kept in the secret.
+type UserSpec struct {
+	Cluster       string `json:"cluster"`
+	ID            string `json:"id,omitempty"`   // the name of the resource by default
+	Type          string `json:"type,omitempty"` // normal or admin
+	Description   string `json:"description,omitempty"`
+	SecretName    string `json:"secretName,omitempty"` // NAME-keys by default
+	ReclaimPolicy string `json:"reclaimPolicy,omitempty"`
+}
+
+// UserStatus is the status of a user.
+type UserStatus struct {
+	Phase     string `json:"phase,omitempty"`
+	Message   string `json:"message,omitempty"`
+	AccessKey string `json:"accessKey,omitempty"`
+}
+
+// User is a user created by the operator.
+type User struct {
+	Metadata kube.ObjectMeta `json:"metadata"`
+	Spec     UserSpec        `json:"spec"`
+	Status   UserStatus      `json:"status"`
+}
+
+type userList struct {
+	Items []*User `json:"items"`
+}
+
+func (u *User) setDefaults() {
+	if u.Spec.ID == "" {
+		u.Spec.ID = u.Metadata.Name
+	}
+	if u.Spec.Type == "" {
+		u.Spec.Type = "normal"
+	}
+	if u.Spec.SecretName == "" {
+		u.Spec.SecretName = u.Metadata.Name + "-keys"
+	}
+	if u.Spec.ReclaimPolicy == "" {
+		u.Spec.ReclaimPolicy = ReclaimRetain
+	}
+}
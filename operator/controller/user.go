@@ -0,0 +1,108 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cubefs/cubefs/operator/kube"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+)
+
+// reconcileUser creates the user on the master, and keeps its keys in the secret owned by the resource.
+// The user is deleted with the resource if its reclaim policy is Delete.
+func (c *Controller) reconcileUser(user *User) (err error) {
+	user.setDefaults()
+	var meta = &user.Metadata
+	if meta.DeletionTimestamp != nil {
+		if !meta.HasFinalizer(Finalizer) {
+			return nil
+		}
+		if user.Spec.ReclaimPolicy == ReclaimDelete {
+			var mc *master.MasterClient
+			if mc, err = c.masterClientOf(meta.Namespace, user.Spec.Cluster); err != nil {
+				return
+			}
+			if err = mc.UserAPI().DeleteUser(user.Spec.ID); err != nil && err != proto.ErrUserNotExists {
+				return c.updateStatus(ResourceUsers, meta, &UserStatus{Phase: PhaseFailed, Message: fmt.Sprintf("delete user: %v", err)})
+			}
+			log.Printf("user %v/%v: user %v deleted", meta.Namespace, meta.Name, user.Spec.ID)
+		}
+		return c.removeFinalizer(ResourceUsers, meta)
+	}
+	if err = c.addFinalizer(ResourceUsers, meta); err != nil {
+		return
+	}
+
+	var status = &UserStatus{Phase: PhaseReady}
+	defer func() {
+		if err != nil {
+			status.Phase, status.Message, err = PhaseFailed, err.Error(), nil
+		}
+		err = c.updateStatus(ResourceUsers, meta, status)
+	}()
+	var userType = proto.UserTypeFromString(user.Spec.Type)
+	if userType != proto.UserTypeNormal && userType != proto.UserTypeAdmin {
+		return fmt.Errorf("invalid user type %v, expect normal or admin", user.Spec.Type)
+	}
+	var mc *master.MasterClient
+	if mc, err = c.masterClientOf(meta.Namespace, user.Spec.Cluster); err != nil {
+		return
+	}
+	var info *proto.UserInfo
+	if info, err = mc.UserAPI().GetUserInfo(user.Spec.ID); err == proto.ErrUserNotExists {
+		if info, err = mc.UserAPI().CreateUser(&proto.UserCreateParam{
+			ID:          user.Spec.ID,
+			Type:        userType,
+			Description: user.Spec.Description,
+		}); err != nil {
+			return fmt.Errorf("create user: %v", err)
+		}
+		log.Printf("user %v/%v: user %v created", meta.Namespace, meta.Name, user.Spec.ID)
+	} else if err != nil {
+		return fmt.Errorf("get user: %v", err)
+	}
+	status.AccessKey = info.AccessKey
+
+	var secret = object{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": object{
+			"name":      user.Spec.SecretName,
+			"namespace": meta.Namespace,
+			"labels":    map[string]string{labelName: "chubaofs", labelManagedBy: "cfs-operator"},
+			"ownerReferences": []object{{
+				"apiVersion":         APIVersion,
+				"kind":               KindUser,
+				"name":               meta.Name,
+				"uid":                meta.UID,
+				"controller":         true,
+				"blockOwnerDeletion": true,
+			}},
+		},
+		"type": "Opaque",
+		"stringData": map[string]string{
+			"userID":    info.UserID,
+			"accessKey": info.AccessKey,
+			"secretKey": info.SecretKey,
+		},
+	}
+	if err = c.kube.Apply(kube.Path("", "v1", meta.Namespace, "secrets", user.Spec.SecretName), secret); err != nil {
+		return fmt.Errorf("apply secret %v: %v", user.Spec.SecretName, err)
+	}
+	return nil
+}
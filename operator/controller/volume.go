@@ -0,0 +1,100 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package controller
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+)
+
+// authKey returns the key the master authorizes the owner of a volume by.
+func authKey(owner string) string {
+	var sum = md5.Sum([]byte(owner))
+	return hex.EncodeToString(sum[:])
+}
+
+// reconcileVolume creates the volume on the master, and expands or shrinks it to the capacity of the spec.
+// The volume is deleted with the resource if its reclaim policy is Delete.
+func (c *Controller) reconcileVolume(volume *Volume) (err error) {
+	volume.setDefaults()
+	var meta = &volume.Metadata
+	if meta.DeletionTimestamp != nil {
+		if !meta.HasFinalizer(Finalizer) {
+			return nil
+		}
+		if volume.Spec.ReclaimPolicy == ReclaimDelete {
+			var mc *master.MasterClient
+			if mc, err = c.masterClientOf(meta.Namespace, volume.Spec.Cluster); err != nil {
+				return
+			}
+			if err = mc.AdminAPI().DeleteVolume(volume.Spec.Name, authKey(volume.Spec.Owner)); err != nil && err != proto.ErrVolNotExists {
+				return c.updateStatus(ResourceVolumes, meta, &VolumeStatus{Phase: PhaseFailed, Message: fmt.Sprintf("delete volume: %v", err)})
+			}
+			log.Printf("volume %v/%v: volume %v deleted", meta.Namespace, meta.Name, volume.Spec.Name)
+		}
+		return c.removeFinalizer(ResourceVolumes, meta)
+	}
+	if err = c.addFinalizer(ResourceVolumes, meta); err != nil {
+		return
+	}
+
+	var status = &VolumeStatus{Phase: PhaseReady}
+	defer func() {
+		if err != nil {
+			status.Phase, status.Message, err = PhaseFailed, err.Error(), nil
+		}
+		err = c.updateStatus(ResourceVolumes, meta, status)
+	}()
+	var mc *master.MasterClient
+	if mc, err = c.masterClientOf(meta.Namespace, volume.Spec.Cluster); err != nil {
+		return
+	}
+	var spec = &volume.Spec
+	var view *proto.SimpleVolView
+	if view, err = mc.AdminAPI().GetVolumeSimpleInfo(spec.Name); err == proto.ErrVolNotExists {
+		if err = mc.AdminAPI().CreateVolume(spec.Name, spec.Owner, spec.MetaPartitions, spec.DataPartitionSize, spec.Capacity,
+			0, spec.FollowerRead, spec.ZoneName, spec.CrossZone, false, false, ""); err != nil {
+			return fmt.Errorf("create volume: %v", err)
+		}
+		log.Printf("volume %v/%v: volume %v created", meta.Namespace, meta.Name, spec.Name)
+		status.Capacity = spec.Capacity
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get volume: %v", err)
+	}
+	if view.Owner != spec.Owner {
+		return fmt.Errorf("volume %v is owned by %v instead of %v", spec.Name, view.Owner, spec.Owner)
+	}
+	status.Capacity = view.Capacity
+	if view.Capacity == spec.Capacity {
+		return nil
+	}
+	if spec.Capacity > view.Capacity {
+		err = mc.AdminAPI().VolExpand(spec.Name, spec.Capacity, authKey(spec.Owner))
+	} else {
+		err = mc.AdminAPI().VolShrink(spec.Name, spec.Capacity, authKey(spec.Owner))
+	}
+	if err != nil {
+		return fmt.Errorf("change capacity from %v to %v: %v", view.Capacity, spec.Capacity, err)
+	}
+	log.Printf("volume %v/%v: capacity of volume %v changed from %v to %v", meta.Namespace, meta.Name, spec.Name, view.Capacity, spec.Capacity)
+	status.Capacity = spec.Capacity
+	return nil
+}
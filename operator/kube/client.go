@@ -0,0 +1,202 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package kube is a minimal client of the Kubernetes API for the operator, which only needs to read, apply,
+// patch and delete the objects by their paths, without the code generated for the typed clients.
+package kube
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	requestTimeout    = 30 * time.Second
+
+	// FieldManager is the manager of the fields the operator applies.
+	FieldManager = "cfs-operator"
+)
+
+// StatusError is the error returned by the API server.
+type StatusError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kube: %v %v: %v", e.Code, e.Reason, e.Message)
+}
+
+// IsNotFound returns whether the error is returned for an object not found.
+func IsNotFound(err error) bool {
+	e, ok := err.(*StatusError)
+	return ok && e.Code == http.StatusNotFound
+}
+
+// IsConflict returns whether the error is returned for an object modified since it was read.
+func IsConflict(err error) bool {
+	e, ok := err.(*StatusError)
+	return ok && e.Code == http.StatusConflict
+}
+
+// Client calls the Kubernetes API.
+type Client struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// NewClient returns the client of the API server. The token and the CA are optional, such as
+// for the API server proxied by kubectl proxy.
+func NewClient(server, token, caFile string) (c *Client, err error) {
+	var transport = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: 8,
+	}
+	if caFile != "" {
+		var ca []byte
+		if ca, err = ioutil.ReadFile(caFile); err != nil {
+			return nil, err
+		}
+		var pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("kube: no certificate in %v", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &Client{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		client: &http.Client{Transport: transport, Timeout: requestTimeout},
+	}, nil
+}
+
+// NewInClusterClient returns the client of the API server of the cluster the pod runs in,
+// authorized by the token of the service account of the pod.
+func NewInClusterClient() (*Client, error) {
+	var host, port = os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("kube: not running in a cluster, KUBERNETES_SERVICE_HOST or KUBERNETES_SERVICE_PORT is not set")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	return NewClient("https://"+net.JoinHostPort(host, port), strings.TrimSpace(string(token)), serviceAccountDir+"/ca.crt")
+}
+
+// Path returns the path of the objects of the resource, or of the object if the name is given. The group
+// is empty for the core resources, and the namespace is empty for the ones of all the namespaces.
+func Path(group, version, namespace, resource, name string) string {
+	var sb strings.Builder
+	if group == "" {
+		sb.WriteString("/api/" + version)
+	} else {
+		sb.WriteString("/apis/" + group + "/" + version)
+	}
+	if namespace != "" {
+		sb.WriteString("/namespaces/" + namespace)
+	}
+	sb.WriteString("/" + resource)
+	if name != "" {
+		sb.WriteString("/" + name)
+	}
+	return sb.String()
+}
+
+// Get reads the object or the list of the path into out.
+func (c *Client) Get(path string, query url.Values, out interface{}) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(http.MethodGet, path, "", nil, out)
+}
+
+// Apply creates or updates the fields of the object of the path by the server-side apply, taking
+// over the fields from the other managers.
+func (c *Client) Apply(path string, obj interface{}) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var query = url.Values{"fieldManager": {FieldManager}, "force": {"true"}}
+	return c.do(http.MethodPatch, path+"?"+query.Encode(), "application/apply-patch+yaml", body, nil)
+}
+
+// MergePatch patches the object of the path by the JSON merge patch.
+func (c *Client) MergePatch(path string, patch interface{}) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPatch, path, "application/merge-patch+json", body, nil)
+}
+
+// Delete deletes the object of the path, which is not an error if it does not exist.
+func (c *Client) Delete(path string) error {
+	if err := c.do(http.MethodDelete, path, "", nil, nil); err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) do(method, path, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var status struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(data))
+		}
+		return &StatusError{Code: resp.StatusCode, Reason: status.Reason, Message: status.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kube
+
+import (
+	"time"
+)
+
+// The objects below only have the fields the operator reads, the ones it applies are built as maps.
+
+// ObjectMeta is the metadata of the objects.
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	Generation        int64             `json:"generation,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Finalizers        []string          `json:"finalizers,omitempty"`
+	DeletionTimestamp *time.Time        `json:"deletionTimestamp,omitempty"`
+}
+
+// HasFinalizer returns whether the object has the finalizer.
+func (m *ObjectMeta) HasFinalizer(finalizer string) bool {
+	for _, f := range m.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// Pod is a pod.
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		Phase      string `json:"phase"`
+		HostIP     string `json:"hostIP"`
+		PodIP      string `json:"podIP"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// Ready returns whether the pod is ready.
+func (p *Pod) Ready() bool {
+	for _, cond := range p.Status.Conditions {
+		if cond.Type == "Ready" {
+			return cond.Status == "True"
+		}
+	}
+	return false
+}
+
+// PodList is a list of the pods.
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// StatefulSet is a stateful set.
+type StatefulSet struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Replicas *int32 `json:"replicas"`
+	} `json:"spec"`
+	Status struct {
+		ObservedGeneration int64  `json:"observedGeneration"`
+		Replicas           int32  `json:"replicas"`
+		ReadyReplicas      int32  `json:"readyReplicas"`
+		UpdatedReplicas    int32  `json:"updatedReplicas"`
+		CurrentRevision    string `json:"currentRevision"`
+		UpdateRevision     string `json:"updateRevision"`
+	} `json:"status"`
+}
+
+// Deployment is a deployment.
+type Deployment struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		Replicas        int32 `json:"replicas"`
+		ReadyReplicas   int32 `json:"readyReplicas"`
+		UpdatedReplicas int32 `json:"updatedReplicas"`
+	} `json:"status"`
+}
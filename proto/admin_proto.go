@@ -23,6 +23,14 @@ const (
 	AdminCreateDataPartition       = "/dataPartition/create"
 	AdminDecommissionDataPartition = "/dataPartition/decommission"
 	AdminDiagnoseDataPartition     = "/dataPartition/diagnose"
+	AdminCheckDataPartition        = "/dataPartition/checkConsistency"
+	AdminGetDataPartitionCheck     = "/dataPartition/consistency"
+	AdminBackupDataPartition       = "/dataPartition/backup"
+	AdminGetDataPartitionBackup    = "/dataPartition/backupStatus"
+	AdminRestoreDataPartition      = "/dataPartition/restore"
+	AdminGetHotDataPartitions      = "/dataPartition/hot"
+	AdminConvertDataPartitionToEC  = "/dataPartition/convertToEC"
+	AdminGetDataPartitionECStatus  = "/dataPartition/ecStatus"
 	AdminDeleteDataReplica         = "/dataReplica/delete"
 	AdminAddDataReplica            = "/dataReplica/add"
 	AdminDeleteVol                 = "/vol/delete"
@@ -37,6 +45,7 @@ const (
 	AdminCreateMetaPartition       = "/metaPartition/create"
 	AdminSetMetaNodeThreshold      = "/threshold/set"
 	AdminListVols                  = "/vol/list"
+	AdminGetQuotaUsage             = "/vol/quotaUsage"
 	AdminSetNodeInfo               = "/admin/setNodeInfo"
 	AdminGetNodeInfo               = "/admin/getNodeInfo"
 	AdminGetAllNodeSetGrpInfo      = "/admin/getDomainInfo"
@@ -47,6 +56,22 @@ const (
 	AdminUpdateDomainDataUseRatio  = "/admin/updateDomainDataRatio"
 	AdminUpdateZoneExcludeRatio    = "/admin/updateZoneExcludeRatio"
 	AdminSetNodeRdOnly             = "/admin/setNodeRdOnly"
+	AdminRotateEncryptKey          = "/admin/rotateEncryptKey"
+	AdminAddServiceKey             = "/admin/addServiceKey"
+	AdminRetireServiceKey          = "/admin/retireServiceKey"
+	AdminListServiceKeys           = "/admin/listServiceKeys"
+	AdminCheckVersion              = "/admin/checkVersion"
+	AdminSetRateLimit              = "/admin/setRateLimit"
+	AdminDeleteRateLimit           = "/admin/deleteRateLimit"
+	AdminListRateLimits            = "/admin/listRateLimits"
+	AdminSLOStatus                 = "/admin/sloStatus"
+	AdminGetQuota                  = "/quota/get"
+	AdminSetQuota                  = "/quota/set"
+	AdminListQuotas                = "/quota/list"
+	AdminCreateVolSnapshot         = "/vol/snapshot/create"
+	AdminDeleteVolSnapshot         = "/vol/snapshot/delete"
+	AdminListVolSnapshots          = "/vol/snapshot/list"
+	AdminRestoreVolSnapshot        = "/vol/snapshot/restore"
 	//graphql master api
 	AdminClusterAPI = "/api/cluster"
 	AdminUserAPI    = "/api/user"
@@ -66,29 +91,44 @@ const (
 	ClientMetaPartition  = "/metaPartition/get"
 	ClientVolStat        = "/client/volStat"
 	ClientMetaPartitions = "/client/metaPartitions"
+	ClientRateLimits     = "/client/rateLimits"
+	ClientSLOReport      = "/client/sloReport"
+	ClientFlashNodes     = "/client/flashNodes"
 
 	//raft node APIs
 	AddRaftNode    = "/raftNode/add"
 	RemoveRaftNode = "/raftNode/remove"
 
 	// Node APIs
-	AddDataNode                    = "/dataNode/add"
-	DecommissionDataNode           = "/dataNode/decommission"
-	MigrateDataNode                = "/dataNode/migrate"
-	DecommissionDisk               = "/disk/decommission"
-	GetDataNode                    = "/dataNode/get"
-	AddMetaNode                    = "/metaNode/add"
-	DecommissionMetaNode           = "/metaNode/decommission"
-	MigrateMetaNode                = "/metaNode/migrate"
-	GetMetaNode                    = "/metaNode/get"
-	AdminUpdateMetaNode            = "/metaNode/update"
-	AdminUpdateDataNode            = "/dataNode/update"
-	AdminGetInvalidNodes           = "/invalid/nodes"
-	AdminLoadMetaPartition         = "/metaPartition/load"
-	AdminDiagnoseMetaPartition     = "/metaPartition/diagnose"
-	AdminDecommissionMetaPartition = "/metaPartition/decommission"
-	AdminAddMetaReplica            = "/metaReplica/add"
-	AdminDeleteMetaReplica         = "/metaReplica/delete"
+	AddDataNode                     = "/dataNode/add"
+	DecommissionDataNode            = "/dataNode/decommission"
+	MigrateDataNode                 = "/dataNode/migrate"
+	DecommissionDisk                = "/disk/decommission"
+	AdminGetDegradedDisks           = "/disk/degraded"
+	GetDataNode                     = "/dataNode/get"
+	ReportDataNodeLoadProgress      = "/dataNode/loadProgress"
+	ReportDataNodeBadDisk           = "/dataNode/reportBadDisk"
+	AdminSetDataNodeRepairLimit     = "/dataNode/setRepairLimit"
+	AddMetaNode                     = "/metaNode/add"
+	DecommissionMetaNode            = "/metaNode/decommission"
+	MigrateMetaNode                 = "/metaNode/migrate"
+	GetMetaNode                     = "/metaNode/get"
+	AdminUpdateMetaNode             = "/metaNode/update"
+	AdminUpdateDataNode             = "/dataNode/update"
+	AdminGetInvalidNodes            = "/invalid/nodes"
+	AdminLoadMetaPartition          = "/metaPartition/load"
+	AdminDiagnoseMetaPartition      = "/metaPartition/diagnose"
+	AdminDecommissionMetaPartition  = "/metaPartition/decommission"
+	AdminBalanceMetaPartitionLeader = "/metaPartition/balanceLeader"
+	AdminGetMetaLeaderBalance       = "/metaPartition/leaderBalanceStatus"
+	AdminAddMetaReplica             = "/metaReplica/add"
+	AdminDeleteMetaReplica          = "/metaReplica/delete"
+	AdminAddMetaReplicaLearner      = "/metaLearner/add"
+	AdminPromoteMetaReplicaLearner  = "/metaLearner/promote"
+	AddFlashNode                    = "/flashNode/add"
+	DecommissionFlashNode           = "/flashNode/decommission"
+	GetFlashNode                    = "/flashNode/get"
+	ListFlashNodes                  = "/flashNode/list"
 
 	// Operation response
 	GetMetaNodeTaskResponse = "/metaNode/response" // Method: 'POST', ContentType: 'application/json'
@@ -123,6 +163,22 @@ const (
 
 const TimeFormat = "2006-01-02 15:04:05"
 
+// The compressions of the data stored by the data nodes.
+const (
+	CompressionNone  = "none"
+	CompressionLZ4   = "lz4"
+	CompressionFlate = "flate"
+)
+
+// IsValidCompression checks if the given compression is supported, an empty one means no compression.
+func IsValidCompression(compression string) bool {
+	switch compression {
+	case "", CompressionLZ4, CompressionFlate:
+		return true
+	}
+	return false
+}
+
 // HTTPReply uniform response structure
 type HTTPReply struct {
 	Code int32       `json:"code"`
@@ -139,10 +195,16 @@ type RegisterMetaNodeResp struct {
 type ClusterInfo struct {
 	Cluster                     string
 	Ip                          string
+	LeaderAddr                  string
 	MetaNodeDeleteBatchCount    uint64
 	MetaNodeDeleteWorkerSleepMs uint64
 	DataNodeDeleteLimitRate     uint64
 	DataNodeAutoRepairLimitRate uint64
+
+	DataNodeDiskClientIOLimitRate uint64 // client io bytes per second of each disk, 0 for no limit
+	DataNodeDiskRepairIOLimitRate uint64 // repair io bytes per second of each disk, 0 for no limit
+
+	BlobStoreAddrs []string `json:",omitempty"` // addresses of the blob store access service of the cold tier
 }
 
 // CreateDataPartitionRequest defines the request to create a data partition.
@@ -155,6 +217,8 @@ type CreateDataPartitionRequest struct {
 	Members       []Peer
 	Hosts         []string
 	CreateType    int
+	Encrypted     bool
+	ChecksumType  uint8
 }
 
 // CreateDataPartitionResponse defines the response to the request of creating a data partition.
@@ -209,6 +273,25 @@ type RemoveMetaPartitionRaftMemberRequest struct {
 	RemovePeer  Peer
 }
 
+// AddMetaPartitionRaftLearnerRequest defines the request of adding a non-voting raft member to a meta partition.
+type AddMetaPartitionRaftLearnerRequest struct {
+	PartitionId uint64
+	AddLearner  Peer
+}
+
+// PromoteMetaPartitionLearnerRequest defines the request of promoting a raft learner of a meta partition to a voter.
+type PromoteMetaPartitionLearnerRequest struct {
+	PartitionId    uint64
+	PromoteLearner Peer
+}
+
+// MetaPartitionTransferLeaderRequest defines the request of transferring the raft leadership of a meta partition
+// to the voter on the target meta node, which is sent to the leader of the partition.
+type MetaPartitionTransferLeaderRequest struct {
+	PartitionId  uint64
+	TargetNodeID uint64
+}
+
 // LoadDataPartitionRequest defines the request of loading a data partition.
 type LoadDataPartitionRequest struct {
 	PartitionId uint64
@@ -225,6 +308,184 @@ type LoadDataPartitionResponse struct {
 	VolName           string
 }
 
+// CheckDataPartitionRequest defines the request of comparing the replicas of a data partition.
+type CheckDataPartitionRequest struct {
+	PartitionId uint64
+	Repair      bool // repair the replicas differing from the majority
+}
+
+const (
+	ExtentDiffMissing = "missing" // the extent is missing on some replicas
+	ExtentDiffSize    = "size"    // the replicas of the extent differ in size
+	ExtentDiffCrc     = "crc"     // the replicas of the extent differ in content
+)
+
+// ExtentReplicaState defines the state of an extent on a replica.
+type ExtentReplicaState struct {
+	Addr  string
+	Exist bool
+	Size  uint64
+	Crc   uint32
+}
+
+// ExtentDiff defines an extent whose replicas differ.
+type ExtentDiff struct {
+	ExtentID uint64
+	Reason   string
+	Replicas []*ExtentReplicaState
+	Majority string   // a replica of the majority, empty if there is no majority
+	BadHosts []string // the replicas differing from the majority
+	Repaired bool     // the repair of the bad replicas has been issued
+}
+
+// CheckDataPartitionResponse defines the result of comparing the replicas of a data partition.
+type CheckDataPartitionResponse struct {
+	PartitionId    uint64
+	Status         uint8
+	Result         string
+	Hosts          []string
+	Repair         bool
+	CheckedExtents int
+	SkippedExtents int // the extents modified recently are not compared
+	Diffs          []*ExtentDiff
+	StartTime      int64
+	EndTime        int64
+}
+
+// BackupStore defines the S3-compatible storage the data partitions are backed up to.
+type BackupStore struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// BackupDataPartitionRequest defines the request of uploading the extents of a data partition
+// to the objects under the path in the backup store.
+type BackupDataPartitionRequest struct {
+	PartitionId uint64
+	Store       *BackupStore
+	Path        string
+}
+
+// BackupDataPartitionResponse defines the result of backing up a data partition.
+type BackupDataPartitionResponse struct {
+	PartitionId uint64
+	Status      uint8
+	Result      string
+	Path        string
+	Extents     int
+	Bytes       uint64
+	StartTime   int64
+	EndTime     int64
+}
+
+// RestoreDataPartitionRequest defines the request of restoring a data partition from the backup
+// under the path in the backup store.
+type RestoreDataPartitionRequest struct {
+	PartitionId uint64
+	Store       *BackupStore
+	Path        string
+}
+
+// RestoreDataPartitionResponse defines the result of restoring a data partition.
+type RestoreDataPartitionResponse struct {
+	PartitionId uint64
+	Status      uint8
+	Result      string
+	Path        string
+	Extents     int
+	Bytes       uint64
+	StartTime   int64
+	EndTime     int64
+}
+
+// The erasure coded data partitions keep the data of their normal extents as two data shards
+// and a parity shard on their three replicas, instead of three copies.
+const (
+	ECStatusNone      uint8 = iota
+	ECStatusEncoding        // the replicas are encoding their shards, the partition is read only
+	ECStatusConverted       // the replicas read the normal extents from the shards
+)
+
+// The phases of the conversion of a data partition to erasure coding.
+const (
+	ECPhaseEncode uint8 = iota // encode the shards of the replica from its extents
+	ECPhaseCommit              // release the extents encoded
+	ECPhaseAbort               // remove the shards
+)
+
+// ECProgress defines the progress of the encoding of the shards of a replica.
+type ECProgress struct {
+	ECEncodedExtents int
+	ECTotalExtents   int
+}
+
+// ConvertDataPartitionToECRequest defines the request of a phase of the conversion of a data
+// partition to erasure coding, the hosts decide the shards of the replicas.
+type ConvertDataPartitionToECRequest struct {
+	PartitionId uint64
+	Hosts       []string
+	Phase       uint8
+}
+
+// ConvertDataPartitionToECResponse defines the result of a phase of the conversion of a data
+// partition to erasure coding. The digest of the extents encoded must be the same on all
+// the replicas.
+type ConvertDataPartitionToECResponse struct {
+	PartitionId uint64
+	Phase       uint8
+	Status      uint8
+	Result      string
+	Digest      uint32
+	Extents     int
+	Bytes       uint64
+}
+
+// MigrateToColdRequest defines the request to migrate the cold files of a meta partition to the
+// blob store. A file is cold if it is neither accessed nor modified for ColdSec seconds.
+type MigrateToColdRequest struct {
+	PartitionID uint64
+	VolName     string
+	ColdSec     int64
+	Limit       int // files migrated at most by the task
+}
+
+// MigrateToColdResponse defines the result of the migration of the cold files of a meta partition.
+type MigrateToColdResponse struct {
+	PartitionID uint64
+	Status      uint8
+	Result      string
+	Migrated    int
+	Bytes       uint64
+	Failed      int
+}
+
+// FenceWritesRequest defines the request to refuse the writes of the clients to the meta partitions and
+// the data partitions of the volume with OpAgain, which the clients retry. The fence is lifted after TTL seconds, or by the
+// request with TTL 0.
+type FenceWritesRequest struct {
+	VolName string
+	TTL     int64
+}
+
+// DataPartitionECStatus defines the progress of the conversion of a data partition to erasure coding.
+type DataPartitionECStatus struct {
+	PartitionID uint64
+	VolName     string
+	ECStatus    uint8
+	Replicas    []*ECReplicaStatus
+}
+
+// ECReplicaStatus defines the progress of the conversion of a replica to erasure coding.
+type ECReplicaStatus struct {
+	Addr          string
+	ECStatus      uint8
+	LastWriteTime int64
+	ECProgress
+}
+
 // File defines the file struct.
 type File struct {
 	Name     string
@@ -251,8 +512,30 @@ type LoadMetaPartitionMetricResponse struct {
 
 // HeartBeatRequest define the heartbeat request.
 type HeartBeatRequest struct {
-	CurrTime   int64
-	MasterAddr string
+	CurrTime        int64
+	MasterAddr      string
+	VolCompressions map[string]string // compressions of the volumes which store the data compressed
+	VolEncryptKeys  map[string][]byte // data keys of the encrypted volumes
+	VolWriteLimits  map[string]uint64 // write bytes per second of the limited volumes on each data node
+	RepairLimit     *DataNodeRepairLimit
+	// replication addresses of the nodes running the replication traffic on a dedicated network
+	ReplicaAddrs map[string]string `json:",omitempty"`
+	// addresses of the blob store access service of the cold tier
+	BlobStoreAddrs []string `json:",omitempty"`
+	// encoding of the task responses accepted by the master, TaskCodecJSON for the older masters
+	TaskCodec uint8 `json:",omitempty"`
+	// sequence of the last partition report of the node applied by the master, 0 for a full report
+	ReportSeq uint64 `json:",omitempty"`
+	// tokens of the volumes exceeding the quotas, enforced by the meta nodes
+	VolQuotaTokens map[string]*QuotaToken `json:",omitempty"`
+}
+
+// DataNodeRepairLimit defines the limits of the repairs and the replications on a data node.
+type DataNodeRepairLimit struct {
+	ExtentRepairLimit     uint64 // extents repaired at the same time, 0 for the default
+	DiskRepairIOLimitRate uint64 // repair io bytes per second of each disk, 0 for no limit
+	// limits taking the place of the ones above within the time windows
+	Windows []*RepairLimitWindow `json:",omitempty"`
 }
 
 // PartitionReport defines the partition report.
@@ -266,6 +549,32 @@ type PartitionReport struct {
 	IsLeader        bool
 	ExtentCount     int
 	NeedCompare     bool
+	AccessStats     // the accesses of the partition within the last window
+	LastWriteTime   int64
+	ECStatus        uint8
+	ECProgress
+}
+
+// AccessStats defines the client reads and writes of a data partition or an extent within
+// a window of the access statistics of a data node.
+type AccessStats struct {
+	ReadOps    uint64
+	WriteOps   uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+// Ops returns the number of the reads and the writes.
+func (s *AccessStats) Ops() uint64 {
+	return s.ReadOps + s.WriteOps
+}
+
+// HotExtentReport defines an extent among the most accessed ones of a data node within the
+// last window of the access statistics.
+type HotExtentReport struct {
+	PartitionID uint64
+	ExtentID    uint64
+	AccessStats
 }
 
 // DataNodeHeartbeatResponse defines the response to the data node heartbeat.
@@ -278,10 +587,79 @@ type DataNodeHeartbeatResponse struct {
 	CreatedPartitionCnt uint32
 	MaxCapacity         uint64 // maximum capacity to create partition
 	ZoneName            string
+	Rack                string
+	Crc32cSupported     bool // the data node keeps the partitions of the crc32c volumes
 	PartitionReports    []*PartitionReport
 	Status              uint8
 	Result              string
 	BadDisks            []string
+	CorruptExtents      []*CorruptExtentReport
+	DiskSmarts          []*DiskSmartInfo
+	HotExtents          []*HotExtentReport
+	AccessStatsWindow   int64  // seconds
+	Version             string // version of the build of the data node
+	LocalTime           int64  // unix nanoseconds of the clock of the data node when the response is built
+	// the partition reports are the ones changed since the base report if BaseReportSeq is not 0
+	ReportSeq         uint64       `json:",omitempty"`
+	BaseReportSeq     uint64       `json:",omitempty"`
+	RemovedPartitions []uint64     `json:",omitempty"`
+	SLOReports        []*SLOReport `json:",omitempty"` // operations of the volumes since the last heartbeat
+}
+
+// CorruptExtentReport defines a block of an extent whose data does not match its checksum.
+type CorruptExtentReport struct {
+	PartitionID uint64
+	ExtentID    uint64
+	BlockNo     int
+	DetectTime  int64
+	Repaired    bool
+}
+
+// DiskSmartInfo defines the SMART attributes of a data node disk.
+type DiskSmartInfo struct {
+	Path                 string
+	Device               string
+	Passed               bool // the overall health self-assessment
+	ReallocatedSectors   uint64
+	PendingSectors       uint64
+	UncorrectableSectors uint64
+	MediaErrors          uint64 // media and data integrity errors of NVMe
+	PercentageUsed       uint64 // estimated wear of NVMe
+	Temperature          int64
+	UpdateTime           int64
+}
+
+// DegradedDisk defines a disk marked for drain because of its SMART attributes.
+type DegradedDisk struct {
+	Addr     string
+	Path     string
+	Reason   string
+	MarkTime int64
+	Drained  int // number of data partitions decommissioned from the disk
+}
+
+// NodeVersion defines the version of a node, and whether it works with the versions of the other nodes.
+type NodeVersion struct {
+	Addr       string
+	Role       string
+	Version    string
+	Compatible bool
+	Reason     string // why the version is incompatible or unknown
+}
+
+// VersionConflict defines two versions running in the cluster which do not work together.
+type VersionConflict struct {
+	Version     string
+	PeerVersion string
+	Reason      string
+}
+
+// VersionCheckView defines the versions of the nodes of the cluster against the compatibility matrix.
+type VersionCheckView struct {
+	MasterVersion string
+	Versions      map[string]int // number of the nodes of each version
+	Nodes         []*NodeVersion
+	Conflicts     []*VersionConflict
 }
 
 // MetaPartitionReport defines the meta partition report.
@@ -295,6 +673,9 @@ type MetaPartitionReport struct {
 	VolName     string
 	InodeCnt    uint64
 	DentryCnt   uint64
+	Size        uint64
+	// usage of the directory quotas by the inodes of the partition, sorted by the quota IDs
+	QuotaUsages []*QuotaUsage `json:",omitempty"`
 }
 
 // MetaNodeHeartbeatResponse defines the response to the meta node heartbeat request.
@@ -305,6 +686,13 @@ type MetaNodeHeartbeatResponse struct {
 	MetaPartitionReports []*MetaPartitionReport
 	Status               uint8
 	Result               string
+	Version              string // version of the build of the meta node
+	LocalTime            int64  // unix nanoseconds of the clock of the meta node when the response is built
+	// the partition reports are the ones changed since the base report if BaseReportSeq is not 0
+	ReportSeq         uint64       `json:",omitempty"`
+	BaseReportSeq     uint64       `json:",omitempty"`
+	RemovedPartitions []uint64     `json:",omitempty"`
+	SLOReports        []*SLOReport `json:",omitempty"` // operations of the volumes since the last heartbeat
 }
 
 // DeleteFileRequest defines the request to delete a file.
@@ -492,6 +880,15 @@ type SimpleVolView struct {
 	DpSelectorName     string
 	DpSelectorParm     string
 	DefaultZonePrior   bool
+	CaseInsensitive    bool
+	Compression        string
+	WriteLimit         uint64
+	ObjQuotaBytes      uint64
+	ObjQuotaCount      uint64
+	SLO                *VolSLO `json:",omitempty"`
+	Encrypted          bool
+	Checksum           string
+	FlashCache         bool // the data read is cached by the flash nodes
 }
 type NodeSetInfo struct {
 	ID           uint64
@@ -566,3 +963,108 @@ type NodeSetView struct {
 type TopologyView struct {
 	Zones []*ZoneView
 }
+
+// RateLimitInfo is a limit of the cluster shared by the nodes, such as the QPS of an access key of the
+// ObjectNodes or the bandwidth of a volume of the clients. The rate is allocated by the master to the
+// nodes requesting the key as their budgets.
+type RateLimitInfo struct {
+	Key  string
+	Rate float64 // tokens per second of the cluster
+}
+
+// RateLimitDemand is the rate of the tokens of a key requested by a node in the last interval.
+type RateLimitDemand struct {
+	Key    string
+	Demand float64
+}
+
+// RateLimitAllocateRequest reports the demands of the keys of a node to the master.
+type RateLimitAllocateRequest struct {
+	Node    string
+	Demands []*RateLimitDemand
+}
+
+// RateLimitBudget is the rate of the tokens of a key allocated to a node, which is negative if the key is
+// not limited.
+type RateLimitBudget struct {
+	Key  string
+	Rate float64
+}
+
+// RateLimitAllocateResponse replies the budgets of the keys requested, and the interval in seconds of the
+// next request.
+type RateLimitAllocateResponse struct {
+	Budgets  []*RateLimitBudget
+	Interval int64
+}
+
+// Kinds of the quotas kept by the master.
+const (
+	QuotaKindVol   = "vol"   // the bytes and the inodes of a volume, whose bytes are the capacity of the volume
+	QuotaKindOwner = "owner" // the bytes and the inodes of all the volumes of an owner
+	QuotaKindDir   = "dir"   // the bytes and the inodes of a directory tree, counted by the meta nodes
+)
+
+// QuotaInfo is the limits and the usage of a quota. The bytes used are the space used by the data
+// partitions, and the inodes used are the ones reported by the leaders of the meta partitions. The usage
+// of a directory is the sum of the usages of its quota reported by the leaders of the meta partitions.
+type QuotaInfo struct {
+	Kind           string
+	Name           string // name of the volume or the owner, or the volume of the directory
+	Path           string `json:",omitempty"` // path of the directory
+	QuotaID        uint64 `json:",omitempty"` // ID of the quota of the directory, by which the inodes are tagged
+	MaxBytes       uint64 // 0 for no limit
+	MaxInodes      uint64 // 0 for no limit
+	UsedBytes      uint64
+	UsedInodes     uint64
+	Vols           []string `json:",omitempty"` // volumes of the owner
+	BytesExceeded  bool
+	InodesExceeded bool
+}
+
+// QuotaUsage is the bytes and the inodes of a meta partition tagged with a directory quota.
+type QuotaUsage struct {
+	QuotaID    uint64
+	UsedBytes  uint64
+	UsedInodes uint64
+}
+
+// QuotaToken is pushed by the master to the meta nodes in the heartbeats for a volume exceeding its
+// quota, the quota of its owner or the quotas of its directories. The tokens of a heartbeat replace the
+// ones before, and a token is dropped after TTL seconds if the master stops pushing it.
+type QuotaToken struct {
+	NoBytes  bool // the extents and the inline data are not added to the files
+	NoInodes bool // the inodes are not created
+	// sorted IDs of the directory quotas exceeding the bytes, whose files are not added the data
+	NoBytesQuotas []uint64 `json:",omitempty"`
+	// sorted IDs of the directory quotas exceeding the inodes, whose directories are not added the inodes
+	NoInodesQuotas []uint64 `json:",omitempty"`
+	TTL            int64
+}
+
+// Status of the snapshots of the volumes kept by the master.
+const (
+	VolSnapshotCreating  = "creating"
+	VolSnapshotAvailable = "available"
+	VolSnapshotFailed    = "failed" // the master failed over while creating it, it can only be deleted
+)
+
+// VolSnapshotInfo is a snapshot of a whole volume taken by the master, which is made of the subtree
+// snapshots of the root taken by all the meta partitions while the writes of the volume are fenced.
+type VolSnapshotInfo struct {
+	ID             uint64
+	Name           string
+	VolName        string
+	Status         string
+	CreateTime     int64
+	FenceMs        int64 // the milliseconds the writes were fenced
+	RestoreTime    int64 `json:",omitempty"` // the last time the volume was restored to it
+	MetaPartitions []*VolSnapshotPartition
+	DataPartitions []*VolSnapshotPartition
+}
+
+// VolSnapshotPartition is the raft index a partition was at when the snapshot was taken.
+type VolSnapshotPartition struct {
+	PartitionID uint64
+	ApplyID     uint64
+}
@@ -0,0 +1,553 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/golang/protobuf/proto"
+)
+
+// encodings of the admin task responses sent by the nodes to the master
+const (
+	TaskCodecJSON     uint8 = 0
+	TaskCodecProtobuf uint8 = 1
+
+	// ProtobufContentType is the content type of an admin task response encoded by MarshalTaskPB.
+	ProtobufContentType = "application/x-protobuf"
+)
+
+// The messages below are the protobuf form of the admin task and the heartbeat responses, whose
+// schema is admin_task.proto and whose field numbers must never be reused. The heartbeat responses
+// are encoded as messages since they carry the reports of all the partitions of a node, the requests
+// and the other responses are small and kept in JSON.
+
+type adminTaskPB struct {
+	ID                string               `protobuf:"bytes,1,opt,name=id,proto3"`
+	PartitionID       uint64               `protobuf:"varint,2,opt,name=partition_id,proto3"`
+	OpCode            uint32               `protobuf:"varint,3,opt,name=op_code,proto3"`
+	OperatorAddr      string               `protobuf:"bytes,4,opt,name=operator_addr,proto3"`
+	Status            int32                `protobuf:"varint,5,opt,name=status,proto3"`
+	SendTime          int64                `protobuf:"varint,6,opt,name=send_time,proto3"`
+	CreateTime        int64                `protobuf:"varint,7,opt,name=create_time,proto3"`
+	SendCount         uint32               `protobuf:"varint,8,opt,name=send_count,proto3"`
+	Request           []byte               `protobuf:"bytes,9,opt,name=request,proto3"`   // JSON
+	Response          []byte               `protobuf:"bytes,10,opt,name=response,proto3"` // JSON of the responses other than the heartbeats
+	DataNodeHeartbeat *dataNodeHeartbeatPB `protobuf:"bytes,11,opt,name=data_node_heartbeat,proto3"`
+	MetaNodeHeartbeat *metaNodeHeartbeatPB `protobuf:"bytes,12,opt,name=meta_node_heartbeat,proto3"`
+}
+
+type dataNodeHeartbeatPB struct {
+	Total               uint64               `protobuf:"varint,1,opt,name=total,proto3"`
+	Used                uint64               `protobuf:"varint,2,opt,name=used,proto3"`
+	Available           uint64               `protobuf:"varint,3,opt,name=available,proto3"`
+	TotalPartitionSize  uint64               `protobuf:"varint,4,opt,name=total_partition_size,proto3"`
+	RemainingCapacity   uint64               `protobuf:"varint,5,opt,name=remaining_capacity,proto3"`
+	CreatedPartitionCnt uint32               `protobuf:"varint,6,opt,name=created_partition_cnt,proto3"`
+	MaxCapacity         uint64               `protobuf:"varint,7,opt,name=max_capacity,proto3"`
+	ZoneName            string               `protobuf:"bytes,8,opt,name=zone_name,proto3"`
+	Rack                string               `protobuf:"bytes,9,opt,name=rack,proto3"`
+	Crc32CSupported     bool                 `protobuf:"varint,10,opt,name=crc32c_supported,proto3"`
+	PartitionReports    []*partitionReportPB `protobuf:"bytes,11,rep,name=partition_reports,proto3"`
+	Status              uint32               `protobuf:"varint,12,opt,name=status,proto3"`
+	Result              string               `protobuf:"bytes,13,opt,name=result,proto3"`
+	BadDisks            []string             `protobuf:"bytes,14,rep,name=bad_disks,proto3"`
+	CorruptExtents      []*corruptExtentPB   `protobuf:"bytes,15,rep,name=corrupt_extents,proto3"`
+	DiskSmarts          []*diskSmartPB       `protobuf:"bytes,16,rep,name=disk_smarts,proto3"`
+	HotExtents          []*hotExtentPB       `protobuf:"bytes,17,rep,name=hot_extents,proto3"`
+	AccessStatsWindow   int64                `protobuf:"varint,18,opt,name=access_stats_window,proto3"`
+	Version             string               `protobuf:"bytes,19,opt,name=version,proto3"`
+	LocalTime           int64                `protobuf:"varint,20,opt,name=local_time,proto3"`
+	ReportSeq           uint64               `protobuf:"varint,21,opt,name=report_seq,proto3"`
+	BaseReportSeq       uint64               `protobuf:"varint,22,opt,name=base_report_seq,proto3"`
+	RemovedPartitions   []uint64             `protobuf:"varint,23,rep,packed,name=removed_partitions,proto3"`
+	SLOReports          []*sloReportPB       `protobuf:"bytes,24,rep,name=slo_reports,proto3"`
+}
+
+type partitionReportPB struct {
+	VolName          string `protobuf:"bytes,1,opt,name=vol_name,proto3"`
+	PartitionID      uint64 `protobuf:"varint,2,opt,name=partition_id,proto3"`
+	PartitionStatus  int64  `protobuf:"varint,3,opt,name=partition_status,proto3"`
+	Total            uint64 `protobuf:"varint,4,opt,name=total,proto3"`
+	Used             uint64 `protobuf:"varint,5,opt,name=used,proto3"`
+	DiskPath         string `protobuf:"bytes,6,opt,name=disk_path,proto3"`
+	IsLeader         bool   `protobuf:"varint,7,opt,name=is_leader,proto3"`
+	ExtentCount      int64  `protobuf:"varint,8,opt,name=extent_count,proto3"`
+	NeedCompare      bool   `protobuf:"varint,9,opt,name=need_compare,proto3"`
+	ReadOps          uint64 `protobuf:"varint,10,opt,name=read_ops,proto3"`
+	WriteOps         uint64 `protobuf:"varint,11,opt,name=write_ops,proto3"`
+	ReadBytes        uint64 `protobuf:"varint,12,opt,name=read_bytes,proto3"`
+	WriteBytes       uint64 `protobuf:"varint,13,opt,name=write_bytes,proto3"`
+	LastWriteTime    int64  `protobuf:"varint,14,opt,name=last_write_time,proto3"`
+	ECStatus         uint32 `protobuf:"varint,15,opt,name=ec_status,proto3"`
+	ECEncodedExtents int64  `protobuf:"varint,16,opt,name=ec_encoded_extents,proto3"`
+	ECTotalExtents   int64  `protobuf:"varint,17,opt,name=ec_total_extents,proto3"`
+}
+
+type corruptExtentPB struct {
+	PartitionID uint64 `protobuf:"varint,1,opt,name=partition_id,proto3"`
+	ExtentID    uint64 `protobuf:"varint,2,opt,name=extent_id,proto3"`
+	BlockNo     int64  `protobuf:"varint,3,opt,name=block_no,proto3"`
+	DetectTime  int64  `protobuf:"varint,4,opt,name=detect_time,proto3"`
+	Repaired    bool   `protobuf:"varint,5,opt,name=repaired,proto3"`
+}
+
+type diskSmartPB struct {
+	Path                 string `protobuf:"bytes,1,opt,name=path,proto3"`
+	Device               string `protobuf:"bytes,2,opt,name=device,proto3"`
+	Passed               bool   `protobuf:"varint,3,opt,name=passed,proto3"`
+	ReallocatedSectors   uint64 `protobuf:"varint,4,opt,name=reallocated_sectors,proto3"`
+	PendingSectors       uint64 `protobuf:"varint,5,opt,name=pending_sectors,proto3"`
+	UncorrectableSectors uint64 `protobuf:"varint,6,opt,name=uncorrectable_sectors,proto3"`
+	MediaErrors          uint64 `protobuf:"varint,7,opt,name=media_errors,proto3"`
+	PercentageUsed       uint64 `protobuf:"varint,8,opt,name=percentage_used,proto3"`
+	Temperature          int64  `protobuf:"varint,9,opt,name=temperature,proto3"`
+	UpdateTime           int64  `protobuf:"varint,10,opt,name=update_time,proto3"`
+}
+
+type hotExtentPB struct {
+	PartitionID uint64 `protobuf:"varint,1,opt,name=partition_id,proto3"`
+	ExtentID    uint64 `protobuf:"varint,2,opt,name=extent_id,proto3"`
+	ReadOps     uint64 `protobuf:"varint,3,opt,name=read_ops,proto3"`
+	WriteOps    uint64 `protobuf:"varint,4,opt,name=write_ops,proto3"`
+	ReadBytes   uint64 `protobuf:"varint,5,opt,name=read_bytes,proto3"`
+	WriteBytes  uint64 `protobuf:"varint,6,opt,name=write_bytes,proto3"`
+}
+
+type metaNodeHeartbeatPB struct {
+	ZoneName             string                   `protobuf:"bytes,1,opt,name=zone_name,proto3"`
+	Total                uint64                   `protobuf:"varint,2,opt,name=total,proto3"`
+	Used                 uint64                   `protobuf:"varint,3,opt,name=used,proto3"`
+	MetaPartitionReports []*metaPartitionReportPB `protobuf:"bytes,4,rep,name=meta_partition_reports,proto3"`
+	Status               uint32                   `protobuf:"varint,5,opt,name=status,proto3"`
+	Result               string                   `protobuf:"bytes,6,opt,name=result,proto3"`
+	Version              string                   `protobuf:"bytes,7,opt,name=version,proto3"`
+	LocalTime            int64                    `protobuf:"varint,8,opt,name=local_time,proto3"`
+	ReportSeq            uint64                   `protobuf:"varint,9,opt,name=report_seq,proto3"`
+	BaseReportSeq        uint64                   `protobuf:"varint,10,opt,name=base_report_seq,proto3"`
+	RemovedPartitions    []uint64                 `protobuf:"varint,11,rep,packed,name=removed_partitions,proto3"`
+	SLOReports           []*sloReportPB           `protobuf:"bytes,12,rep,name=slo_reports,proto3"`
+}
+
+type sloReportPB struct {
+	VolName string   `protobuf:"bytes,1,opt,name=vol_name,proto3"`
+	Ops     uint64   `protobuf:"varint,2,opt,name=ops,proto3"`
+	Errors  uint64   `protobuf:"varint,3,opt,name=errors,proto3"`
+	Latency []uint64 `protobuf:"varint,4,rep,packed,name=latency,proto3"`
+}
+
+type metaPartitionReportPB struct {
+	PartitionID uint64          `protobuf:"varint,1,opt,name=partition_id,proto3"`
+	Start       uint64          `protobuf:"varint,2,opt,name=start,proto3"`
+	End         uint64          `protobuf:"varint,3,opt,name=end,proto3"`
+	Status      int64           `protobuf:"varint,4,opt,name=status,proto3"`
+	MaxInodeID  uint64          `protobuf:"varint,5,opt,name=max_inode_id,proto3"`
+	IsLeader    bool            `protobuf:"varint,6,opt,name=is_leader,proto3"`
+	VolName     string          `protobuf:"bytes,7,opt,name=vol_name,proto3"`
+	InodeCnt    uint64          `protobuf:"varint,8,opt,name=inode_cnt,proto3"`
+	DentryCnt   uint64          `protobuf:"varint,9,opt,name=dentry_cnt,proto3"`
+	Size        uint64          `protobuf:"varint,10,opt,name=size,proto3"`
+	QuotaUsages []*quotaUsagePB `protobuf:"bytes,11,rep,name=quota_usages,proto3"`
+}
+
+type quotaUsagePB struct {
+	QuotaID    uint64 `protobuf:"varint,1,opt,name=quota_id,proto3"`
+	UsedBytes  uint64 `protobuf:"varint,2,opt,name=used_bytes,proto3"`
+	UsedInodes uint64 `protobuf:"varint,3,opt,name=used_inodes,proto3"`
+}
+
+func (m *adminTaskPB) Reset()                   { *m = adminTaskPB{} }
+func (m *adminTaskPB) String() string           { return pb.CompactTextString(m) }
+func (*adminTaskPB) ProtoMessage()              {}
+func (m *dataNodeHeartbeatPB) Reset()           { *m = dataNodeHeartbeatPB{} }
+func (m *dataNodeHeartbeatPB) String() string   { return pb.CompactTextString(m) }
+func (*dataNodeHeartbeatPB) ProtoMessage()      {}
+func (m *partitionReportPB) Reset()             { *m = partitionReportPB{} }
+func (m *partitionReportPB) String() string     { return pb.CompactTextString(m) }
+func (*partitionReportPB) ProtoMessage()        {}
+func (m *corruptExtentPB) Reset()               { *m = corruptExtentPB{} }
+func (m *corruptExtentPB) String() string       { return pb.CompactTextString(m) }
+func (*corruptExtentPB) ProtoMessage()          {}
+func (m *diskSmartPB) Reset()                   { *m = diskSmartPB{} }
+func (m *diskSmartPB) String() string           { return pb.CompactTextString(m) }
+func (*diskSmartPB) ProtoMessage()              {}
+func (m *hotExtentPB) Reset()                   { *m = hotExtentPB{} }
+func (m *hotExtentPB) String() string           { return pb.CompactTextString(m) }
+func (*hotExtentPB) ProtoMessage()              {}
+func (m *metaNodeHeartbeatPB) Reset()           { *m = metaNodeHeartbeatPB{} }
+func (m *metaNodeHeartbeatPB) String() string   { return pb.CompactTextString(m) }
+func (*metaNodeHeartbeatPB) ProtoMessage()      {}
+func (m *metaPartitionReportPB) Reset()         { *m = metaPartitionReportPB{} }
+func (m *metaPartitionReportPB) String() string { return pb.CompactTextString(m) }
+func (*metaPartitionReportPB) ProtoMessage()    {}
+func (m *sloReportPB) Reset()                   { *m = sloReportPB{} }
+func (m *sloReportPB) String() string           { return pb.CompactTextString(m) }
+func (*sloReportPB) ProtoMessage()              {}
+func (m *quotaUsagePB) Reset()                  { *m = quotaUsagePB{} }
+func (m *quotaUsagePB) String() string          { return pb.CompactTextString(m) }
+func (*quotaUsagePB) ProtoMessage()             {}
+
+// MarshalTaskPB encodes the admin task in protobuf. The request and the responses other than the
+// heartbeats are embedded in JSON.
+func MarshalTaskPB(t *AdminTask) (data []byte, err error) {
+	m := &adminTaskPB{
+		ID:           t.ID,
+		PartitionID:  t.PartitionID,
+		OpCode:       uint32(t.OpCode),
+		OperatorAddr: t.OperatorAddr,
+		Status:       int32(t.Status),
+		SendTime:     t.SendTime,
+		CreateTime:   t.CreateTime,
+		SendCount:    uint32(t.SendCount),
+	}
+	if t.Request != nil {
+		if m.Request, err = json.Marshal(t.Request); err != nil {
+			return
+		}
+	}
+	switch resp := t.Response.(type) {
+	case nil:
+	case *DataNodeHeartbeatResponse:
+		m.DataNodeHeartbeat = dataNodeHeartbeatToPB(resp)
+	case *MetaNodeHeartbeatResponse:
+		m.MetaNodeHeartbeat = metaNodeHeartbeatToPB(resp)
+	default:
+		if m.Response, err = json.Marshal(resp); err != nil {
+			return
+		}
+	}
+	return pb.Marshal(m)
+}
+
+// UnmarshalTaskPB decodes the admin task encoded by MarshalTaskPB. The heartbeat response is decoded
+// into its type, the request and the other responses are left as json.RawMessage.
+func UnmarshalTaskPB(data []byte) (t *AdminTask, err error) {
+	m := &adminTaskPB{}
+	if err = pb.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("unmarshal task err(%v)", err)
+	}
+	t = &AdminTask{
+		ID:           m.ID,
+		PartitionID:  m.PartitionID,
+		OpCode:       uint8(m.OpCode),
+		OperatorAddr: m.OperatorAddr,
+		Status:       int8(m.Status),
+		SendTime:     m.SendTime,
+		CreateTime:   m.CreateTime,
+		SendCount:    uint8(m.SendCount),
+	}
+	if len(m.Request) > 0 {
+		t.Request = json.RawMessage(m.Request)
+	}
+	switch {
+	case m.DataNodeHeartbeat != nil:
+		t.Response = dataNodeHeartbeatFromPB(m.DataNodeHeartbeat)
+	case m.MetaNodeHeartbeat != nil:
+		t.Response = metaNodeHeartbeatFromPB(m.MetaNodeHeartbeat)
+	case len(m.Response) > 0:
+		t.Response = json.RawMessage(m.Response)
+	}
+	return
+}
+
+func dataNodeHeartbeatToPB(r *DataNodeHeartbeatResponse) *dataNodeHeartbeatPB {
+	m := &dataNodeHeartbeatPB{
+		Total:               r.Total,
+		Used:                r.Used,
+		Available:           r.Available,
+		TotalPartitionSize:  r.TotalPartitionSize,
+		RemainingCapacity:   r.RemainingCapacity,
+		CreatedPartitionCnt: r.CreatedPartitionCnt,
+		MaxCapacity:         r.MaxCapacity,
+		ZoneName:            r.ZoneName,
+		Rack:                r.Rack,
+		Crc32CSupported:     r.Crc32cSupported,
+		PartitionReports:    make([]*partitionReportPB, 0, len(r.PartitionReports)),
+		Status:              uint32(r.Status),
+		Result:              r.Result,
+		BadDisks:            r.BadDisks,
+		CorruptExtents:      make([]*corruptExtentPB, 0, len(r.CorruptExtents)),
+		DiskSmarts:          make([]*diskSmartPB, 0, len(r.DiskSmarts)),
+		HotExtents:          make([]*hotExtentPB, 0, len(r.HotExtents)),
+		AccessStatsWindow:   r.AccessStatsWindow,
+		Version:             r.Version,
+		LocalTime:           r.LocalTime,
+		ReportSeq:           r.ReportSeq,
+		BaseReportSeq:       r.BaseReportSeq,
+		RemovedPartitions:   r.RemovedPartitions,
+		SLOReports:          sloReportsToPB(r.SLOReports),
+	}
+	for _, p := range r.PartitionReports {
+		if p == nil {
+			continue
+		}
+		m.PartitionReports = append(m.PartitionReports, &partitionReportPB{
+			VolName:          p.VolName,
+			PartitionID:      p.PartitionID,
+			PartitionStatus:  int64(p.PartitionStatus),
+			Total:            p.Total,
+			Used:             p.Used,
+			DiskPath:         p.DiskPath,
+			IsLeader:         p.IsLeader,
+			ExtentCount:      int64(p.ExtentCount),
+			NeedCompare:      p.NeedCompare,
+			ReadOps:          p.ReadOps,
+			WriteOps:         p.WriteOps,
+			ReadBytes:        p.ReadBytes,
+			WriteBytes:       p.WriteBytes,
+			LastWriteTime:    p.LastWriteTime,
+			ECStatus:         uint32(p.ECStatus),
+			ECEncodedExtents: int64(p.ECEncodedExtents),
+			ECTotalExtents:   int64(p.ECTotalExtents),
+		})
+	}
+	for _, e := range r.CorruptExtents {
+		if e == nil {
+			continue
+		}
+		m.CorruptExtents = append(m.CorruptExtents, &corruptExtentPB{
+			PartitionID: e.PartitionID,
+			ExtentID:    e.ExtentID,
+			BlockNo:     int64(e.BlockNo),
+			DetectTime:  e.DetectTime,
+			Repaired:    e.Repaired,
+		})
+	}
+	for _, s := range r.DiskSmarts {
+		if s == nil {
+			continue
+		}
+		m.DiskSmarts = append(m.DiskSmarts, &diskSmartPB{
+			Path:                 s.Path,
+			Device:               s.Device,
+			Passed:               s.Passed,
+			ReallocatedSectors:   s.ReallocatedSectors,
+			PendingSectors:       s.PendingSectors,
+			UncorrectableSectors: s.UncorrectableSectors,
+			MediaErrors:          s.MediaErrors,
+			PercentageUsed:       s.PercentageUsed,
+			Temperature:          s.Temperature,
+			UpdateTime:           s.UpdateTime,
+		})
+	}
+	for _, h := range r.HotExtents {
+		if h == nil {
+			continue
+		}
+		m.HotExtents = append(m.HotExtents, &hotExtentPB{
+			PartitionID: h.PartitionID,
+			ExtentID:    h.ExtentID,
+			ReadOps:     h.ReadOps,
+			WriteOps:    h.WriteOps,
+			ReadBytes:   h.ReadBytes,
+			WriteBytes:  h.WriteBytes,
+		})
+	}
+	return m
+}
+
+func dataNodeHeartbeatFromPB(m *dataNodeHeartbeatPB) *DataNodeHeartbeatResponse {
+	r := &DataNodeHeartbeatResponse{
+		Total:               m.Total,
+		Used:                m.Used,
+		Available:           m.Available,
+		TotalPartitionSize:  m.TotalPartitionSize,
+		RemainingCapacity:   m.RemainingCapacity,
+		CreatedPartitionCnt: m.CreatedPartitionCnt,
+		MaxCapacity:         m.MaxCapacity,
+		ZoneName:            m.ZoneName,
+		Rack:                m.Rack,
+		Crc32cSupported:     m.Crc32CSupported,
+		PartitionReports:    make([]*PartitionReport, 0, len(m.PartitionReports)),
+		Status:              uint8(m.Status),
+		Result:              m.Result,
+		BadDisks:            m.BadDisks,
+		CorruptExtents:      make([]*CorruptExtentReport, 0, len(m.CorruptExtents)),
+		DiskSmarts:          make([]*DiskSmartInfo, 0, len(m.DiskSmarts)),
+		HotExtents:          make([]*HotExtentReport, 0, len(m.HotExtents)),
+		AccessStatsWindow:   m.AccessStatsWindow,
+		Version:             m.Version,
+		LocalTime:           m.LocalTime,
+		ReportSeq:           m.ReportSeq,
+		BaseReportSeq:       m.BaseReportSeq,
+		RemovedPartitions:   m.RemovedPartitions,
+		SLOReports:          sloReportsFromPB(m.SLOReports),
+	}
+	for _, p := range m.PartitionReports {
+		r.PartitionReports = append(r.PartitionReports, &PartitionReport{
+			VolName:         p.VolName,
+			PartitionID:     p.PartitionID,
+			PartitionStatus: int(p.PartitionStatus),
+			Total:           p.Total,
+			Used:            p.Used,
+			DiskPath:        p.DiskPath,
+			IsLeader:        p.IsLeader,
+			ExtentCount:     int(p.ExtentCount),
+			NeedCompare:     p.NeedCompare,
+			AccessStats: AccessStats{
+				ReadOps:    p.ReadOps,
+				WriteOps:   p.WriteOps,
+				ReadBytes:  p.ReadBytes,
+				WriteBytes: p.WriteBytes,
+			},
+			LastWriteTime: p.LastWriteTime,
+			ECStatus:      uint8(p.ECStatus),
+			ECProgress: ECProgress{
+				ECEncodedExtents: int(p.ECEncodedExtents),
+				ECTotalExtents:   int(p.ECTotalExtents),
+			},
+		})
+	}
+	for _, e := range m.CorruptExtents {
+		r.CorruptExtents = append(r.CorruptExtents, &CorruptExtentReport{
+			PartitionID: e.PartitionID,
+			ExtentID:    e.ExtentID,
+			BlockNo:     int(e.BlockNo),
+			DetectTime:  e.DetectTime,
+			Repaired:    e.Repaired,
+		})
+	}
+	for _, s := range m.DiskSmarts {
+		r.DiskSmarts = append(r.DiskSmarts, &DiskSmartInfo{
+			Path:                 s.Path,
+			Device:               s.Device,
+			Passed:               s.Passed,
+			ReallocatedSectors:   s.ReallocatedSectors,
+			PendingSectors:       s.PendingSectors,
+			UncorrectableSectors: s.UncorrectableSectors,
+			MediaErrors:          s.MediaErrors,
+			PercentageUsed:       s.PercentageUsed,
+			Temperature:          s.Temperature,
+			UpdateTime:           s.UpdateTime,
+		})
+	}
+	for _, h := range m.HotExtents {
+		r.HotExtents = append(r.HotExtents, &HotExtentReport{
+			PartitionID: h.PartitionID,
+			ExtentID:    h.ExtentID,
+			AccessStats: AccessStats{
+				ReadOps:    h.ReadOps,
+				WriteOps:   h.WriteOps,
+				ReadBytes:  h.ReadBytes,
+				WriteBytes: h.WriteBytes,
+			},
+		})
+	}
+	return r
+}
+
+func metaNodeHeartbeatToPB(r *MetaNodeHeartbeatResponse) *metaNodeHeartbeatPB {
+	m := &metaNodeHeartbeatPB{
+		ZoneName:             r.ZoneName,
+		Total:                r.Total,
+		Used:                 r.Used,
+		MetaPartitionReports: make([]*metaPartitionReportPB, 0, len(r.MetaPartitionReports)),
+		Status:               uint32(r.Status),
+		Result:               r.Result,
+		Version:              r.Version,
+		LocalTime:            r.LocalTime,
+		ReportSeq:            r.ReportSeq,
+		BaseReportSeq:        r.BaseReportSeq,
+		RemovedPartitions:    r.RemovedPartitions,
+		SLOReports:           sloReportsToPB(r.SLOReports),
+	}
+	for _, p := range r.MetaPartitionReports {
+		if p == nil {
+			continue
+		}
+		m.MetaPartitionReports = append(m.MetaPartitionReports, &metaPartitionReportPB{
+			PartitionID: p.PartitionID,
+			Start:       p.Start,
+			End:         p.End,
+			Status:      int64(p.Status),
+			MaxInodeID:  p.MaxInodeID,
+			IsLeader:    p.IsLeader,
+			VolName:     p.VolName,
+			InodeCnt:    p.InodeCnt,
+			DentryCnt:   p.DentryCnt,
+			Size:        p.Size,
+			QuotaUsages: quotaUsagesToPB(p.QuotaUsages),
+		})
+	}
+	return m
+}
+
+func metaNodeHeartbeatFromPB(m *metaNodeHeartbeatPB) *MetaNodeHeartbeatResponse {
+	r := &MetaNodeHeartbeatResponse{
+		ZoneName:             m.ZoneName,
+		Total:                m.Total,
+		Used:                 m.Used,
+		MetaPartitionReports: make([]*MetaPartitionReport, 0, len(m.MetaPartitionReports)),
+		Status:               uint8(m.Status),
+		Result:               m.Result,
+		Version:              m.Version,
+		LocalTime:            m.LocalTime,
+		ReportSeq:            m.ReportSeq,
+		BaseReportSeq:        m.BaseReportSeq,
+		RemovedPartitions:    m.RemovedPartitions,
+		SLOReports:           sloReportsFromPB(m.SLOReports),
+	}
+	for _, p := range m.MetaPartitionReports {
+		r.MetaPartitionReports = append(r.MetaPartitionReports, &MetaPartitionReport{
+			PartitionID: p.PartitionID,
+			Start:       p.Start,
+			End:         p.End,
+			Status:      int(p.Status),
+			MaxInodeID:  p.MaxInodeID,
+			IsLeader:    p.IsLeader,
+			VolName:     p.VolName,
+			InodeCnt:    p.InodeCnt,
+			DentryCnt:   p.DentryCnt,
+			Size:        p.Size,
+			QuotaUsages: quotaUsagesFromPB(p.QuotaUsages),
+		})
+	}
+	return r
+}
+
+func quotaUsagesToPB(usages []*QuotaUsage) (m []*quotaUsagePB) {
+	for _, u := range usages {
+		if u == nil {
+			continue
+		}
+		m = append(m, &quotaUsagePB{QuotaID: u.QuotaID, UsedBytes: u.UsedBytes, UsedInodes: u.UsedInodes})
+	}
+	return
+}
+
+func quotaUsagesFromPB(m []*quotaUsagePB) (usages []*QuotaUsage) {
+	for _, u := range m {
+		usages = append(usages, &QuotaUsage{QuotaID: u.QuotaID, UsedBytes: u.UsedBytes, UsedInodes: u.UsedInodes})
+	}
+	return
+}
+
+func sloReportsToPB(reports []*SLOReport) (m []*sloReportPB) {
+	for _, r := range reports {
+		if r == nil {
+			continue
+		}
+		m = append(m, &sloReportPB{VolName: r.VolName, Ops: r.Ops, Errors: r.Errors, Latency: r.Latency})
+	}
+	return
+}
+
+func sloReportsFromPB(m []*sloReportPB) (reports []*SLOReport) {
+	for _, r := range m {
+		reports = append(reports, &SLOReport{VolName: r.VolName, Ops: r.Ops, Errors: r.Errors, Latency: r.Latency})
+	}
+	return
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fillValue sets every field reachable from v to a distinct non-zero value, so that a field missed
+// or swapped by the protobuf conversion shows up in the JSON encoding.
+func fillValue(v reflect.Value, seq *int) {
+	next := func() int {
+		*seq++
+		return *seq%120 + 1 // fits in the uint8 fields
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillValue(v.Elem(), seq)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				fillValue(v.Field(i), seq)
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < v.Len(); i++ {
+			fillValue(v.Index(i), seq)
+		}
+	case reflect.String:
+		v.SetString(fmt.Sprintf("s%v", next()))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(next()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(next()))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(next()))
+	}
+}
+
+func TestTaskPBRoundTrip(t *testing.T) {
+	var seq int
+	dataResp := &DataNodeHeartbeatResponse{}
+	fillValue(reflect.ValueOf(dataResp).Elem(), &seq)
+	metaResp := &MetaNodeHeartbeatResponse{}
+	fillValue(reflect.ValueOf(metaResp).Elem(), &seq)
+
+	for _, resp := range []interface{}{dataResp, metaResp} {
+		task := &AdminTask{
+			ID:           "task",
+			PartitionID:  1,
+			OpCode:       OpDataNodeHeartbeat,
+			OperatorAddr: "127.0.0.1:17310",
+			Status:       TaskSucceeds,
+			SendTime:     2,
+			CreateTime:   3,
+			SendCount:    4,
+			Request:      &HeartBeatRequest{CurrTime: 5, MasterAddr: "127.0.0.1:17010"},
+			Response:     resp,
+		}
+		data, err := MarshalTaskPB(task)
+		if err != nil {
+			t.Fatalf("marshal %T: %v", resp, err)
+		}
+		decoded, err := UnmarshalTaskPB(data)
+		if err != nil {
+			t.Fatalf("unmarshal %T: %v", resp, err)
+		}
+		expect, _ := json.Marshal(task)
+		actual, _ := json.Marshal(decoded)
+		if !bytes.Equal(expect, actual) {
+			t.Errorf("%T round trip mismatch\nexpect %s\nactual %s", resp, expect, actual)
+		}
+	}
+}
+
+// TestTaskPBSchema checks the messages of admin_task_pb.go against admin_task.proto, so that the field
+// numbers are not changed by accident.
+func TestTaskPBSchema(t *testing.T) {
+	types := map[string]reflect.Type{}
+	for _, m := range []interface{}{
+		adminTaskPB{}, dataNodeHeartbeatPB{}, partitionReportPB{}, corruptExtentPB{}, diskSmartPB{},
+		hotExtentPB{}, metaNodeHeartbeatPB{}, sloReportPB{}, metaPartitionReportPB{}, quotaUsagePB{},
+	} {
+		types[reflect.TypeOf(m).Name()] = reflect.TypeOf(m)
+	}
+	schema, err := ioutil.ReadFile("admin_task.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageRe := regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	fieldRe := regexp.MustCompile(`^\s*(repeated )?(\w+) (\w+) = (\d+);`)
+	messages := messageRe.FindAllSubmatch(schema, -1)
+	if len(messages) != len(types) {
+		t.Errorf("%v messages in the schema, expect %v", len(messages), len(types))
+	}
+	for _, message := range messages {
+		name := string(message[1])
+		typ, ok := types[name]
+		if !ok {
+			t.Errorf("message %v of the schema not defined", name)
+			continue
+		}
+		tags := make(map[string]string)
+		for i := 0; i < typ.NumField(); i++ {
+			tag := typ.Field(i).Tag.Get("protobuf")
+			tags[tagName(tag)] = tag
+		}
+		fields := 0
+		for _, line := range strings.Split(string(message[2]), "\n") {
+			match := fieldRe.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			fields++
+			repeated, kind, field, number := match[1] != "", match[2], match[3], match[4]
+			tag, ok := tags[field]
+			if !ok {
+				t.Errorf("field %v.%v of the schema not defined", name, field)
+				continue
+			}
+			wire := "varint"
+			if kind == "string" || kind == "bytes" || types[kind] != nil {
+				wire = "bytes"
+			}
+			label := "opt"
+			if repeated {
+				label = "rep"
+			}
+			if parts := strings.Split(tag, ","); len(parts) < 3 || parts[0] != wire || parts[1] != number || parts[2] != label {
+				t.Errorf("field %v.%v: tag %q mismatch the schema %v %v %v", name, field, tag, label, wire, number)
+			}
+		}
+		if fields != len(tags) {
+			t.Errorf("message %v: %v fields in the schema, %v defined", name, fields, len(tags))
+		}
+	}
+}
+
+func tagName(tag string) string {
+	for _, part := range strings.Split(tag, ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return ""
+}
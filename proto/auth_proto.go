@@ -23,6 +23,8 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cubefs/cubefs/util/caps"
@@ -48,12 +50,15 @@ const (
 	OwnerVOLRsc     = "OwnerVOL"
 	NoneOwnerVOLRsc = "NoneOwnerVOL"
 	VOLAccess       = "*"
+
+	ticketKeyIDSeparator = ":"
 )
 
 // api
 const (
 	// Client APIs
-	ClientGetTicket = "/client/getticket"
+	ClientGetTicket         = "/client/getticket"
+	ClientGetIdentityTicket = "/client/getidentityticket"
 
 	// Admin APIs
 	AdminCreateKey  = "/admin/createkey"
@@ -62,6 +67,7 @@ const (
 	AdminAddCaps    = "/admin/addcaps"
 	AdminDeleteCaps = "/admin/deletecaps"
 	AdminGetCaps    = "/admin/getcaps"
+	AdminRotateKey  = "/admin/rotatekey"
 
 	//raft node APIs
 	AdminAddRaftNode    = "/admin/addraftnode"
@@ -90,6 +96,12 @@ const (
 	ObjectServiceID = "ObjectService"
 )
 
+// external identity providers
+const (
+	IdentityProviderOIDC = "oidc"
+	IdentityProviderLDAP = "ldap"
+)
+
 const (
 	MasterNode = "master"
 	MetaNode   = "metanode"
@@ -172,6 +184,12 @@ const (
 	// MsgAuthRemoveRaftNodeResp response type for authnode remove node
 	MsgAuthRemoveRaftNodeResp MsgType = MsgAuthBase + 0x58001
 
+	// MsgAuthRotateKeyReq request type for authnode rotate the key of a service
+	MsgAuthRotateKeyReq MsgType = MsgAuthBase + 0x59000
+
+	// MsgAuthRotateKeyResp response type for authnode rotate the key of a service
+	MsgAuthRotateKeyResp MsgType = MsgAuthBase + 0x59001
+
 	// MsgAuthOSAddCapsReq request type from ObjectNode to add caps
 	MsgAuthOSAddCapsReq MsgType = MsgAuthBase + 0x61000
 
@@ -198,6 +216,12 @@ const (
 
 	//Master API ClientVol
 	MsgMasterFetchVolViewReq MsgType = MsgMasterAPIAccessReq + 0x10000
+
+	// MsgMasterAddServiceKeyReq request type for master add a version of the master service key
+	MsgMasterAddServiceKeyReq MsgType = MsgMasterAPIAccessReq + 0x20000
+
+	// MsgMasterRetireServiceKeyReq request type for master retire a version of the master service key
+	MsgMasterRetireServiceKeyReq MsgType = MsgMasterAPIAccessReq + 0x30000
 )
 
 // HTTPAuthReply uniform response structure
@@ -217,11 +241,14 @@ var MsgType2ResourceMap = map[MsgType]string{
 	MsgAuthGetCapsReq:        "auth:getcaps",
 	MsgAuthAddRaftNodeReq:    "auth:addnode",
 	MsgAuthRemoveRaftNodeReq: "auth:removenode",
+	MsgAuthRotateKeyReq:      "auth:rotatekey",
 	MsgAuthOSAddCapsReq:      "auth:osaddcaps",
 	MsgAuthOSDeleteCapsReq:   "auth:osdeletecaps",
 	MsgAuthOSGetCapsReq:      "auth:osgetcaps",
 
-	MsgMasterFetchVolViewReq: "master:getvol",
+	MsgMasterFetchVolViewReq:     "master:getvol",
+	MsgMasterAddServiceKeyReq:    "master:addservicekey",
+	MsgMasterRetireServiceKeyReq: "master:retireservicekey",
 }
 
 // AuthGetTicketReq defines the message from client to authnode
@@ -244,6 +271,21 @@ type AuthGetTicketResp struct {
 	SessionKey cryptoutil.CryptoKey `json:"session_key"`
 }
 
+// AuthGetIdentityTicketReq defines the message from client to authnode to get a ticket with an external identity,
+// which is verified by the OIDC provider (with Token) or the LDAP server (with User and Password)
+// instead of the key of the client. The response is encrypted by ReplyKey generated by the client,
+// and ReplyKey is also used to generate the verifier
+type AuthGetIdentityTicketReq struct {
+	Type      MsgType `json:"type"`
+	ServiceID string  `json:"service_id"`
+	Provider  string  `json:"provider"`
+	Token     string  `json:"token"`
+	User      string  `json:"user"`
+	Password  string  `json:"password"`
+	ReplyKey  []byte  `json:"reply_key"`
+	Verifier  string  `json:"verifier"`
+}
+
 // APIAccessReq defines the request for access restful api
 // use Timestamp as verifier for MITM mitigation
 // verifier is also used to verify the server identity
@@ -271,6 +313,14 @@ type AuthAPIAccessReq struct {
 	KeyInfo keystore.KeyInfo `json:"key_info"`
 }
 
+// MasterServiceKeyReq defines the message from the admin to master to add or retire a version of the master
+// service key. Key is the new key encrypted by the session key of the ticket, only for adding.
+type MasterServiceKeyReq struct {
+	APIReq APIAccessReq `json:"api_req"`
+	KeyID  uint32       `json:"key_id"`
+	Key    string       `json:"key,omitempty"`
+}
+
 // AuthAPIAccessResp defines the response for creating an key in authnode
 type AuthAPIAccessResp struct {
 	APIResp APIAccessResp    `json:"api_resp"`
@@ -438,6 +488,29 @@ func ParseAuthOSAKResp(body []byte, key []byte) (resp AuthOSAccessKeyResp, err e
 	return
 }
 
+// EncodeTicketKeyID prefixes the ticket with the version of the service key encrypting it, so that the service
+// holding several versions of its key during the rotation picks the right one. The version 0 is not prefixed,
+// which keeps the tickets compatible with the services knowing only one key.
+func EncodeTicketKeyID(keyID uint32, ticket string) string {
+	if keyID == 0 {
+		return ticket
+	}
+	return strconv.FormatUint(uint64(keyID), 10) + ticketKeyIDSeparator + ticket
+}
+
+// DecodeTicketKeyID returns the version of the service key and the encrypted ticket.
+func DecodeTicketKeyID(str string) (keyID uint32, ticket string, err error) {
+	i := strings.Index(str, ticketKeyIDSeparator)
+	if i < 0 {
+		return 0, str, nil
+	}
+	id, err := strconv.ParseUint(str[:i], 10, 32)
+	if err != nil || id == 0 {
+		return 0, "", fmt.Errorf("invalid ticket key id [%s]", str[:i])
+	}
+	return uint32(id), str[i+1:], nil
+}
+
 func ExtractTicket(str string, key []byte) (ticket cryptoutil.Ticket, err error) {
 	var (
 		plaintext []byte
@@ -0,0 +1,65 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+// Checksum types of the data of a volume, chosen when the volume is created. The CRC of a
+// packet carrying the data of a crc32c volume is flagged in the extent type byte of its
+// header, so that the packets of the clients and the data nodes not knowing crc32c stay
+// crc32 and are still served.
+const (
+	ChecksumCrc32  uint8 = 0 // crc32 with the IEEE polynomial
+	ChecksumCrc32c uint8 = 1 // crc32 with the Castagnoli polynomial, computed by the SSE4.2 or ARMv8 CRC instructions
+
+	ChecksumCrc32Name  = "crc32"
+	ChecksumCrc32cName = "crc32c"
+
+	packetCrc32cFlag uint8 = 0x80
+	packetVerifyFlag uint8 = 0x40 // a read is checked against the block CRCs of the data node
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum returns the checksum of the data with the given checksum type.
+func Checksum(checksumType uint8, data []byte) uint32 {
+	if checksumType == ChecksumCrc32c {
+		return crc32.Checksum(data, crc32cTable)
+	}
+	return crc32.ChecksumIEEE(data)
+}
+
+// ParseChecksumType returns the checksum type of the given name, crc32 if it is empty.
+func ParseChecksumType(name string) (checksumType uint8, err error) {
+	switch name {
+	case "", ChecksumCrc32Name:
+		return ChecksumCrc32, nil
+	case ChecksumCrc32cName:
+		return ChecksumCrc32c, nil
+	default:
+		return 0, fmt.Errorf("unknown checksum %v", name)
+	}
+}
+
+// ChecksumTypeName returns the name of the checksum type.
+func ChecksumTypeName(checksumType uint8) string {
+	if checksumType == ChecksumCrc32c {
+		return ChecksumCrc32cName
+	}
+	return ChecksumCrc32Name
+}
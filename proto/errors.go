@@ -28,6 +28,8 @@ var (
 	ErrVolNotExists           = errors.New("vol not exists")
 	ErrMetaPartitionNotExists = errors.New("meta partition not exists")
 	ErrDataPartitionNotExists = errors.New("data partition not exists")
+	ErrDataPartitionEC        = errors.New("data partition is erasure coded")
+	ErrBlockCrcMismatch       = errors.New("block crc mismatch")
 	ErrDataNodeNotExists      = errors.New("data node not exists")
 	ErrMetaNodeNotExists      = errors.New("meta node not exists")
 	ErrDuplicateVol           = errors.New("duplicate vol")
@@ -82,6 +84,9 @@ var (
 	ErrInvalidSecretKey                = errors.New("invalid secret key")
 	ErrIsOwner                         = errors.New("user owns the volume")
 	ErrZoneNum                         = errors.New("zone num not qualified")
+	ErrVolWriteThrottled               = errors.New("volume write throttled")
+	ErrVolWritesFenced                 = errors.New("volume writes fenced")
+	ErrExtentSealed                    = errors.New("extent sealed")
 )
 
 // http response error code and error message definitions
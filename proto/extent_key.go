@@ -36,6 +36,11 @@ var (
 	InvalidKeyCheckSum    = errors.New("invalid extent v2 key checksum error")
 )
 
+// PunchHoleFileOffset is the file offset of the extent keys released by a hole punch.
+// Such a key refers to a range of a normal extent to be deallocated, instead of the
+// whole extent.
+const PunchHoleFileOffset = ^uint64(0)
+
 // ExtentKey defines the extent key struct.
 type ExtentKey struct {
 	FileOffset   uint64
@@ -51,6 +56,11 @@ func (k ExtentKey) String() string {
 	return fmt.Sprintf("ExtentKey{FileOffset(%v),Partition(%v),ExtentID(%v),ExtentOffset(%v),Size(%v),CRC(%v)}", k.FileOffset, k.PartitionId, k.ExtentId, k.ExtentOffset, k.Size, k.CRC)
 }
 
+// IsPunchHole returns if the key refers to a range of an extent to be deallocated.
+func (k *ExtentKey) IsPunchHole() bool {
+	return k.FileOffset == PunchHoleFileOffset
+}
+
 // Less defines the less comparator.
 func (k *ExtentKey) Less(than btree.Item) bool {
 	that := than.(*ExtentKey)
@@ -0,0 +1,96 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"time"
+)
+
+// Types of the file locks, the same values as F_RDLCK, F_WRLCK and F_UNLCK on Linux.
+const (
+	FileLockRead   uint32 = 0
+	FileLockWrite  uint32 = 1
+	FileLockUnlock uint32 = 2
+)
+
+const (
+	FileLockRenewInterval = 10 * time.Second // the clients renew their locks this often
+	FileLockTimeout       = 60 * time.Second // the locks of a client not renewed in time are dropped
+)
+
+// FileLock is a byte-range lock of an inode held by a lock owner of a client.
+// The flock(2) locks cover the whole file and never conflict with the POSIX locks.
+type FileLock struct {
+	Start   uint64 `json:"start"`
+	End     uint64 `json:"end"` // inclusive
+	Type    uint32 `json:"type"`
+	Pid     uint32 `json:"pid"`
+	Owner   uint64 `json:"owner"`   // lock owner in the client
+	Session uint64 `json:"session"` // the client holding the lock
+	Flock   bool   `json:"flock"`
+}
+
+func (l *FileLock) String() string {
+	if l == nil {
+		return ""
+	}
+	return fmt.Sprintf("FileLock{%v-%v type(%v) pid(%v) owner(%v) session(%v) flock(%v)}",
+		l.Start, l.End, l.Type, l.Pid, l.Owner, l.Session, l.Flock)
+}
+
+func (l *FileLock) sameOwner(o *FileLock) bool {
+	return l.Session == o.Session && l.Owner == o.Owner && l.Flock == o.Flock
+}
+
+func (l *FileLock) overlaps(o *FileLock) bool {
+	return l.Flock == o.Flock && l.Start <= o.End && o.Start <= l.End
+}
+
+// Conflicts returns true if the lock cannot be held together with the other one.
+func (l *FileLock) Conflicts(o *FileLock) bool {
+	if l.sameOwner(o) || !l.overlaps(o) {
+		return false
+	}
+	return l.Type == FileLockWrite || o.Type == FileLockWrite
+}
+
+// ApplyFileLock sets or releases the lock among the locks of an inode. The lock
+// replaces the overlapping ranges of the same owner, which are split if needed.
+// The caller checks the conflicts with the other owners before.
+func ApplyFileLock(locks []*FileLock, lock *FileLock) []*FileLock {
+	result := make([]*FileLock, 0, len(locks)+2)
+	for _, l := range locks {
+		if !l.sameOwner(lock) || !l.overlaps(lock) {
+			result = append(result, l)
+			continue
+		}
+		if l.Start < lock.Start {
+			head := *l
+			head.End = lock.Start - 1
+			result = append(result, &head)
+		}
+		if l.End > lock.End {
+			tail := *l
+			tail.Start = lock.End + 1
+			result = append(result, &tail)
+		}
+	}
+	if lock.Type != FileLockUnlock {
+		l := *lock
+		result = append(result, &l)
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// FlashNodeHeartbeatRequest is sent by the master to a flash node in the heartbeat task.
+type FlashNodeHeartbeatRequest struct {
+	CurrTime   int64
+	MasterAddr string
+}
+
+// FlashNodeHeartbeatResponse is replied by a flash node to the heartbeat task.
+type FlashNodeHeartbeatResponse struct {
+	ZoneName string
+	Total    uint64 // bytes of the cache
+	Used     uint64
+	Hits     uint64 // reads served by the cache since the flash node started
+	Misses   uint64
+	Version  string
+	Status   uint8
+	Result   string
+}
+
+// FlashNodeView is the information of a flash node shown by the master.
+type FlashNodeView struct {
+	ID         uint64
+	Addr       string
+	ZoneName   string
+	IsActive   bool
+	Total      uint64
+	Used       uint64
+	Hits       uint64
+	Misses     uint64
+	Version    string
+	ReportTime time.Time
+}
+
+// FlashCacheView tells a client of a volume if its reads go through the flash nodes, and the
+// active flash nodes to read from.
+type FlashCacheView struct {
+	Enabled bool
+	Nodes   []string
+}
+
+// FlashCacheReadArg is carried in the arg of an OpFlashCacheRead packet, whose partition ID,
+// extent ID, extent offset and size are the range of the extent to read. The range is within
+// a block of util.BlockSize, which is read from the data nodes up to FillSize from the start
+// of the block and cached if it is not cached.
+type FlashCacheReadArg struct {
+	Hosts    []string // hosts of the data partition
+	FillSize int      // bytes of the block in the extent
+}
+
+// SelectFlashNode returns the node of the nodes caching the block of the extent by the
+// rendezvous hashing, so that the clients read a block from the same node, and only the blocks
+// of a failed node move to the others.
+func SelectFlashNode(nodes []string, partitionID, extentID uint64, blockNo uint32) (node string) {
+	var max uint64
+	for _, addr := range nodes {
+		h := fnv.New64a()
+		h.Write([]byte(addr))
+		h.Write([]byte(strconv.FormatUint(partitionID, 10) + "/" + strconv.FormatUint(extentID, 10) +
+			"/" + strconv.FormatUint(uint64(blockNo), 10)))
+		if score := h.Sum64(); node == "" || score > max {
+			node, max = addr, score
+		}
+	}
+	return
+}
@@ -15,6 +15,7 @@
 package proto
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -74,6 +75,8 @@ type InodeInfo struct {
 	CreateTime time.Time `json:"ct"`
 	AccessTime time.Time `json:"at"`
 	Target     []byte    `json:"tgt"`
+	Flags      uint32    `json:"flags"`
+	QuotaIDs   []uint64  `json:"qids,omitempty"` // directory quotas of the trees holding the inode
 
 	expiration int64
 }
@@ -145,6 +148,8 @@ type CreateInodeRequest struct {
 	Uid         uint32 `json:"uid"`
 	Gid         uint32 `json:"gid"`
 	Target      []byte `json:"tgt"`
+	// directory quotas of the parent, which the inode is counted by
+	QuotaIDs []uint64 `json:"qids,omitempty"`
 }
 
 // CreateInodeResponse defines the response to the request of creating an inode.
@@ -263,6 +268,7 @@ type LookupRequest struct {
 	PartitionID uint64 `json:"pid"`
 	ParentID    uint64 `json:"pino"`
 	Name        string `json:"name"`
+	SnapshotID  uint64 `json:"snap,omitempty"`
 }
 
 // LookupResponse defines the response for the loopup request.
@@ -276,6 +282,7 @@ type InodeGetRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	Inode       uint64 `json:"ino"`
+	SnapshotID  uint64 `json:"snap,omitempty"`
 }
 
 // InodeGetResponse defines the response to the InodeGetRequest.
@@ -288,6 +295,7 @@ type BatchInodeGetRequest struct {
 	VolName     string   `json:"vol"`
 	PartitionID uint64   `json:"pid"`
 	Inodes      []uint64 `json:"inos"`
+	SnapshotID  uint64   `json:"snap,omitempty"`
 }
 
 // BatchInodeGetResponse defines the response to the request of getting the inode in batch.
@@ -295,17 +303,50 @@ type BatchInodeGetResponse struct {
 	Infos []*InodeInfo `json:"infos"`
 }
 
+// BatchStatItem is either an inode ID, or a dentry to look up by parent and name.
+type BatchStatItem struct {
+	Inode    uint64 `json:"ino,omitempty"`
+	ParentID uint64 `json:"pino,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// BatchStatRequest defines the request to resolve a batch of inodes and dentries
+// of a meta partition to their attributes.
+type BatchStatRequest struct {
+	VolName     string          `json:"vol"`
+	PartitionID uint64          `json:"pid"`
+	Items       []BatchStatItem `json:"items"`
+	SnapshotID  uint64          `json:"snap,omitempty"`
+}
+
+// BatchStatResult is the result of a single BatchStatItem. Info is nil if the
+// dentry points to an inode stored by another meta partition.
+type BatchStatResult struct {
+	Status uint8      `json:"status"`
+	Inode  uint64     `json:"ino"`
+	Mode   uint32     `json:"mode"`
+	Info   *InodeInfo `json:"info,omitempty"`
+}
+
+// BatchStatResponse defines the response to the BatchStatRequest, the results
+// are in the order of the request items.
+type BatchStatResponse struct {
+	Results []*BatchStatResult `json:"results"`
+}
+
 // ReadDirRequest defines the request to read dir.
 type ReadDirRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	ParentID    uint64 `json:"pino"`
+	SnapshotID  uint64 `json:"snap,omitempty"`
 }
 
 type ReadDirOnlyRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	ParentID    uint64 `json:"pino"`
+	SnapshotID  uint64 `json:"snap,omitempty"`
 }
 
 // ReadDirResponse defines the response to the request of reading dir.
@@ -324,6 +365,7 @@ type ReadDirLimitRequest struct {
 	ParentID    uint64 `json:"pino"`
 	Marker      string `json:"marker"`
 	Limit       uint64 `json:"limit"`
+	SnapshotID  uint64 `json:"snap,omitempty"`
 }
 
 type ReadDirLimitResponse struct {
@@ -351,13 +393,42 @@ type GetExtentsRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	Inode       uint64 `json:"ino"`
+	SnapshotID  uint64 `json:"snap,omitempty"`
 }
 
 // GetExtentsResponse defines the response to the request of getting extents.
 type GetExtentsResponse struct {
-	Generation uint64      `json:"gen"`
-	Size       uint64      `json:"sz"`
-	Extents    []ExtentKey `json:"eks"`
+	Generation uint64        `json:"gen"`
+	Size       uint64        `json:"sz"`
+	Extents    []ExtentKey   `json:"eks"`
+	InlineData []byte        `json:"inline,omitempty"`
+	Cold       *ColdLocation `json:"cold,omitempty"`
+}
+
+// ColdLocation defines the location of the data of a file migrated to the blob store of the cold
+// tier. The data of the file below Size is read from the blobs, except the ranges overwritten
+// by the extents written after the migration.
+type ColdLocation struct {
+	Size  uint64     `json:"sz"`
+	Blobs []ColdBlob `json:"blobs"`
+}
+
+// ColdBlob defines a blob holding the data of a file from Offset.
+type ColdBlob struct {
+	Offset   uint64          `json:"off"`
+	Size     uint64          `json:"sz"`
+	Location json.RawMessage `json:"loc"`
+}
+
+// MaxInlineDataSize is the upper limit of the data stored inline in an inode.
+const MaxInlineDataSize = 64 * 1024
+
+// WriteInlineRequest defines the request to store the whole content of a small file inline in its inode.
+type WriteInlineRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Data        []byte `json:"data"`
 }
 
 // TruncateRequest defines the request to truncate.
@@ -368,6 +439,15 @@ type TruncateRequest struct {
 	Size        uint64 `json:"sz"`
 }
 
+// PunchHoleRequest defines the request to deallocate a range of a file.
+type PunchHoleRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Offset      uint64 `json:"off"`
+	Size        uint64 `json:"sz"`
+}
+
 // SetAttrRequest defines the request to set attribute.
 type SetAttrRequest struct {
 	VolName     string `json:"vol"`
@@ -378,7 +458,9 @@ type SetAttrRequest struct {
 	Gid         uint32 `json:"gid"`
 	ModifyTime  int64  `json:"mt"`
 	AccessTime  int64  `json:"at"`
+	Flags       uint32 `json:"flags"`
 	Valid       uint32 `json:"valid"`
+	AuthKey     string `json:"authKey,omitempty"` // the md5 of the owner of the volume, required by AttrFlags
 }
 
 const (
@@ -387,8 +469,33 @@ const (
 	AttrGid
 	AttrModifyTime
 	AttrAccessTime
+	AttrFlags
 )
 
+// Inode flags which can be changed through SetAttrRequest, in the spirit of chattr(1).
+const (
+	FlagImmutable  uint32 = 1 << 1 // the file can not be modified, renamed, linked or removed
+	FlagAppendOnly uint32 = 1 << 2 // the file can only be appended to and can not be renamed or removed
+)
+
+// InodeUserFlagsMask masks the inode flags that users are allowed to change.
+const InodeUserFlagsMask = FlagImmutable | FlagAppendOnly
+
+// IsImmutable returns true if the inode has the immutable flag.
+func (info *InodeInfo) IsImmutable() bool {
+	return info.Flags&FlagImmutable != 0
+}
+
+// IsAppendOnly returns true if the inode has the append-only flag.
+func (info *InodeInfo) IsAppendOnly() bool {
+	return info.Flags&FlagAppendOnly != 0
+}
+
+// IsProtected returns true if the inode can neither be removed nor renamed.
+func (info *InodeInfo) IsProtected() bool {
+	return info.Flags&InodeUserFlagsMask != 0
+}
+
 // DeleteInodeRequest defines the request to delete an inode.
 type DeleteInodeRequest struct {
 	VolName     string `json:"vol"`
@@ -511,6 +618,11 @@ type AddMultipartPartRequest struct {
 	Path        string             `json:"path"`
 	MultipartId string             `json:"mid"`
 	Part        *MultipartPartInfo `json:"part"`
+	Replace     bool               `json:"replace,omitempty"` // replace the part of the same id
+}
+
+type AddMultipartPartResponse struct {
+	Replaced *MultipartPartInfo `json:"replaced,omitempty"`
 }
 
 type RemoveMultipartRequest struct {
@@ -534,6 +646,180 @@ type ListMultipartResponse struct {
 	Multiparts []*MultipartInfo `json:"mps"`
 }
 
+// ObjectVersionInfo defines a noncurrent version of an object, or a delete marker.
+// The current version of an object is the file of the dentry at its path.
+type ObjectVersionInfo struct {
+	Path         string    `json:"path"`
+	VersionId    string    `json:"vid"`
+	Inode        uint64    `json:"ino"`
+	DeleteMarker bool      `json:"dm"`
+	CreateTime   time.Time `json:"ct"`
+}
+
+type PutObjectVersionRequest struct {
+	VolName     string             `json:"vol"`
+	PartitionId uint64             `json:"pid"`
+	Version     *ObjectVersionInfo `json:"ver"`
+}
+
+type GetObjectVersionRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	Path        string `json:"path"`
+	VersionId   string `json:"vid"`
+}
+
+type GetObjectVersionResponse struct {
+	Version *ObjectVersionInfo `json:"ver"`
+}
+
+type RemoveObjectVersionRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	Path        string `json:"path"`
+	VersionId   string `json:"vid"`
+}
+
+// ListObjectVersionsRequest lists the versions after the version marker of the marker path.
+// All the versions of the marker path are skipped if the version marker is empty.
+type ListObjectVersionsRequest struct {
+	VolName         string `json:"vol"`
+	PartitionId     uint64 `json:"pid"`
+	Prefix          string `json:"pf"`
+	Marker          string `json:"mk"`
+	VersionIdMarker string `json:"vmk"`
+	Max             uint64 `json:"max"`
+}
+
+type ListObjectVersionsResponse struct {
+	Versions []*ObjectVersionInfo `json:"vers"`
+}
+
+// The extended attributes holding the object lock of an inode. The meta nodes refuse to
+// remove or to modify the inode while the retention period has not expired or the legal
+// hold is on.
+const (
+	XAttrKeyObjectLockMode        = "oss:lock-mode"         // GOVERNANCE or COMPLIANCE
+	XAttrKeyObjectLockRetainUntil = "oss:lock-retain-until" // unix seconds
+	XAttrKeyObjectLegalHold       = "oss:legal-hold"        // ON or OFF
+
+	ObjectLockModeGovernance = "GOVERNANCE"
+	ObjectLockModeCompliance = "COMPLIANCE"
+	ObjectLegalHoldOn        = "ON"
+	ObjectLegalHoldOff       = "OFF"
+)
+
+// SnapshotInfo describes a snapshot of a directory subtree held by a meta partition.
+type SnapshotInfo struct {
+	ID         uint64 `json:"id"`
+	Name       string `json:"name"`
+	RootIno    uint64 `json:"root"`
+	CreateTime int64  `json:"ctime"`
+	Sealed     bool   `json:"sealed"`
+	Inodes     uint64 `json:"inodes"`
+	Dentries   uint64 `json:"dentries"`
+	ApplyID    uint64 `json:"apply,omitempty"` // the raft index of the partition the snapshot is taken at
+}
+
+// CreateSnapshotRequest freezes the namespace of a meta partition under the given snapshot ID.
+type CreateSnapshotRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	SnapshotID  uint64 `json:"snap"`
+	Name        string `json:"name"`
+	RootIno     uint64 `json:"root"`
+}
+
+// SealSnapshotRequest scopes a snapshot to the inodes reachable from its root.
+// Inodes lists the reachable inodes that belong to the partition.
+type SealSnapshotRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	SnapshotID  uint64   `json:"snap"`
+	Inodes      []uint64 `json:"inos"`
+}
+
+type DeleteSnapshotRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	SnapshotID  uint64 `json:"snap"`
+}
+
+type ListSnapshotsRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+}
+
+type ListSnapshotsResponse struct {
+	Snapshots []*SnapshotInfo `json:"snaps"`
+}
+
+// RestoreSnapshotRequest brings the namespace of a meta partition back to the snapshot, or only the
+// given files if Inodes is not empty. Clear drops the whole namespace of a partition created after the
+// snapshot, which holds no snapshot to restore.
+type RestoreSnapshotRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	SnapshotID  uint64   `json:"snap"`
+	Inodes      []uint64 `json:"inos,omitempty"`
+	Clear       bool     `json:"clear,omitempty"`
+}
+
+// SetLockRequest acquires or releases a lock of an inode.
+type SetLockRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	Inode       uint64   `json:"ino"`
+	Lock        FileLock `json:"lock"`
+}
+
+// GetLockRequest looks for a lock of an inode conflicting with the given one.
+type GetLockRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	Inode       uint64   `json:"ino"`
+	Lock        FileLock `json:"lock"`
+}
+
+// GetLockResponse holds the conflicting lock, whose type is FileLockUnlock if there is none.
+type GetLockResponse struct {
+	Lock FileLock `json:"lock"`
+}
+
+// InodeLocks are the locks of an inode held by a client.
+type InodeLocks struct {
+	Inode uint64      `json:"ino"`
+	Locks []*FileLock `json:"locks"`
+}
+
+// RenewLocksRequest keeps the locks of a client alive. The meta node which does
+// not know the client, e.g. a new leader, sets the given locks again.
+type RenewLocksRequest struct {
+	VolName     string        `json:"vol"`
+	PartitionID uint64        `json:"pid"`
+	Session     uint64        `json:"session"`
+	Inodes      []*InodeLocks `json:"inodes"`
+}
+
+// SetInodeQuotaRequest tags the inodes of a meta partition with a directory quota, or untags them if Remove
+// is set, by which the usage of the quota is counted. The master tags the existing tree of a directory when
+// its quota is set, and the inodes created below are tagged by the quotas of their parents.
+type SetInodeQuotaRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	Inodes      []uint64 `json:"inos"`
+	QuotaID     uint64   `json:"qid"`
+	Remove      bool     `json:"remove,omitempty"`
+}
+
+// SealExtentsRequest seals the ranges of the extents of a data partition held by an immutable or
+// append-only inode, so that the data nodes refuse to overwrite them, or unseals them once the flags
+// are cleared. The meta node leader sends it to the data partition after the flags are changed.
+type SealExtentsRequest struct {
+	Extents []ExtentKey `json:"eks"`
+	Unseal  bool        `json:"unseal,omitempty"`
+}
+
 type UpdateSummaryInfoRequest struct {
 	VolName     string `json:"vol"`
 	PartitionId uint64 `json:"pid"`
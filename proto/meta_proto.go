@@ -39,6 +39,10 @@ type CreateMetaPartitionRequest struct {
 	End         uint64
 	PartitionID uint64
 	Members     []Peer
+	Learners    []Peer // members which do not vote, a subset of Members
+	Witnesses   []Peer // members which vote but keep no metadata, a subset of Members
+	// CaseInsensitive makes dentry lookups of the partition ignore the case of names.
+	CaseInsensitive bool
 }
 
 // CreateMetaPartitionResponse defines the response to the request of creating a meta partition.
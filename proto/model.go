@@ -15,6 +15,7 @@
 package proto
 
 import (
+	"strings"
 	"time"
 )
 
@@ -28,6 +29,7 @@ type MetaNodeInfo struct {
 	Addr                      string
 	IsActive                  bool
 	IsWriteAble               bool
+	ReplicaAddr               string // address for the replication traffic, empty if it shares Addr
 	ZoneName                  string `json:"Zone"`
 	MaxMemAvailWeight         uint64 `json:"MaxMemAvailWeight"`
 	Total                     uint64 `json:"TotalWeight"`
@@ -41,6 +43,8 @@ type MetaNodeInfo struct {
 	NodeSetID                 uint64
 	PersistenceMetaPartitions []uint64
 	RdOnly                    bool
+	Version                   string
+	ClockSkew                 time.Duration // clock of the meta node ahead of the master, measured by the heartbeats
 }
 
 // DataNode stores all the information about a data node
@@ -50,7 +54,9 @@ type DataNodeInfo struct {
 	AvailableSpace            uint64
 	ID                        uint64
 	ZoneName                  string `json:"Zone"`
+	Rack                      string
 	Addr                      string
+	ReplicaAddr               string // address for the replication and repair traffic, empty if it shares Addr
 	ReportTime                time.Time
 	IsActive                  bool
 	IsWriteAble               bool
@@ -62,7 +68,16 @@ type DataNodeInfo struct {
 	NodeSetID                 uint64
 	PersistenceDataPartitions []uint64
 	BadDisks                  []string
+	CorruptExtents            []*CorruptExtentReport
+	DiskSmarts                []*DiskSmartInfo
+	HotExtents                []*HotExtentReport
+	AccessStatsWindow         int64 // seconds
 	RdOnly                    bool
+	PartitionsLoaded          int // partitions loaded since the data node started
+	PartitionsToLoad          int
+	RepairLimit               *DataNodeRepairLimit // limits set on the data node, nil if it takes the ones of the cluster
+	Version                   string
+	ClockSkew                 time.Duration // clock of the data node ahead of the master, measured by the heartbeats
 }
 
 // MetaPartition defines the structure of a meta partition
@@ -80,6 +95,7 @@ type MetaPartitionInfo struct {
 	IsRecover     bool
 	Hosts         []string
 	Peers         []Peer
+	Learners      []Peer
 	Zones         []string
 	OfflinePeerID uint64
 	MissNodes     map[string]int64
@@ -119,6 +135,24 @@ type NodeView struct {
 	Status     bool
 	ID         uint64
 	IsWritable bool
+	ZoneName   string
+	Rack       string
+}
+
+// MarshalLocationHint encodes the zone and the rack of a data node into the hint it attaches
+// to the arg of its follower read replies.
+func MarshalLocationHint(zoneName, rack string) []byte {
+	return []byte(zoneName + "/" + rack)
+}
+
+// UnmarshalLocationHint decodes the zone and the rack from the hint of a read reply.
+func UnmarshalLocationHint(hint []byte) (zoneName, rack string, ok bool) {
+	s := string(hint)
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return
+	}
+	return s[:i], s[i+1:], true
 }
 
 type BadPartitionView struct {
@@ -160,6 +194,31 @@ type VolStatInfo struct {
 	InodeCount uint64
 }
 
+// MetaLeaderBalanceStatus defines the status of balancing the meta partition leaders across the meta nodes.
+// NodeLeaders is the number of leaders on each meta node, as reported by the last heartbeats.
+type MetaLeaderBalanceStatus struct {
+	Running     bool
+	StartTime   int64
+	EndTime     int64
+	Transferred int
+	Failed      int
+	NodeLeaders map[string]int
+}
+
+// VolQuotaUsage defines the usage of a volume against its quota.
+// FileSize is aggregated from the sizes reported by the meta partitions, which are
+// maintained by the meta nodes as the files change, and UpdateTime is the time of
+// the oldest of these reports.
+type VolQuotaUsage struct {
+	Name       string
+	Quota      uint64
+	FileSize   uint64
+	UsedSize   uint64
+	UsedRatio  string
+	InodeCount uint64
+	UpdateTime int64
+}
+
 // DataPartition represents the structure of storing the file contents.
 type DataPartitionInfo struct {
 	PartitionID             uint64
@@ -178,6 +237,7 @@ type DataPartitionInfo struct {
 	FileInCoreMap           map[string]*FileInCore
 	IsRecover               bool
 	FilesWithMissingReplica map[string]int64 // key: file name, value: last time when a missing replica is found
+	ECStatus                uint8
 }
 
 //FileInCore define file in data partition
@@ -206,6 +266,18 @@ type DataReplica struct {
 	IsLeader        bool
 	NeedsToCompare  bool
 	DiskPath        string
+	AccessStats     // the accesses of the replica within the last window of the data node
+	LastWriteTime   int64
+	ECStatus        uint8
+	ECProgress
+}
+
+// HotDataPartition defines a data partition among the most accessed ones of the cluster, the
+// reads are summed over the replicas while the writes are the ones of a replica.
+type HotDataPartition struct {
+	PartitionID uint64
+	VolName     string
+	AccessStats
 }
 
 // data partition diagnosis represents the inactive data nodes, corrupt data partitions, and data partitions lack of replicas
@@ -20,6 +20,7 @@ const (
 	LogDir
 	WarnLogDir
 	LogLevel
+	LogFormat
 	ProfPort
 	IcacheTimeout
 	LookupValid
@@ -48,6 +49,35 @@ const (
 	EnablePosixACL
 	EnableSummary
 	EnableUnixPermission
+	Snapshot
+	ZoneName
+	Rack
+	InlineSize
+	ReadCachePath
+	ReadCacheSize
+	WriteBack
+	WriteBackDirtyLimit
+	WriteBackFlushInterval
+	ReadBandwidth
+	WriteBandwidth
+	LockMode
+	ReadAheadMax
+	EncryptKeyFile
+	MetaTimeout
+	MetaMaxRetries
+	MetaRetryTimeout
+	DataTimeout
+	DataMaxRetries
+	DataRetryTimeout
+	RetryBackoff
+	RetryMaxBackoff
+	RetryJitter
+	HedgeReadDelay
+	AuditLog
+	AuditCollector
+	MemoryLimit
+	VerifyReadChecksum
+	ClusterRateLimit
 
 	MaxMountOption
 )
@@ -83,6 +113,7 @@ func InitMountOptions(opts []MountOption) {
 	opts[LogDir] = MountOption{"logDir", "Log Path", "", ""}
 	opts[WarnLogDir] = MountOption{"warnLogDir", "Warn Log Path", "", ""}
 	opts[LogLevel] = MountOption{"logLevel", "Log Level", "", ""}
+	opts[LogFormat] = MountOption{"logFormat", "Log Format, text or json", "", ""}
 	opts[ProfPort] = MountOption{"profPort", "PProf Port", "", ""}
 	opts[IcacheTimeout] = MountOption{"icacheTimeout", "Inode Cache Expiration Time", "", int64(-1)}
 	opts[LookupValid] = MountOption{"lookupValid", "Lookup Valid Duration", "", int64(-1)}
@@ -96,6 +127,8 @@ func InitMountOptions(opts []MountOption) {
 	opts[KeepCache] = MountOption{"keepcache", "Enable FUSE keepcache feature", "", false}
 	opts[FollowerRead] = MountOption{"followerRead", "Enable read from follower", "", false}
 	opts[NearRead] = MountOption{"nearRead", "Enable read from nearest node", "", true}
+	opts[ZoneName] = MountOption{"zoneName", "Zone of the client, to read from the nearest node", "", ""}
+	opts[Rack] = MountOption{"rack", "Rack of the client, to read from the nearest node", "", ""}
 
 	opts[Authenticate] = MountOption{"authenticate", "Enable Authenticate", "", false}
 	opts[ClientKey] = MountOption{"clientKey", "Client Key", "", ""}
@@ -114,12 +147,55 @@ func InitMountOptions(opts []MountOption) {
 	opts[EnablePosixACL] = MountOption{"enablePosixACL", "Enable posix ACL support", "", false}
 	opts[EnableSummary] = MountOption{"enableSummary", "Enable content summary", "", false}
 	opts[EnableUnixPermission] = MountOption{"enableUnixPermission", "Enable unix permission check(e.g: 777/755)", "", false}
+	opts[Snapshot] = MountOption{"snapshot", "Mount the subtree snapshot with the given ID as readonly", "", int64(0)}
+	opts[InlineSize] = MountOption{"inlineSize", "Store the files not larger than the size in bytes inline in the metadata, 0 disables", "", int64(0)}
+	opts[ReadCachePath] = MountOption{"readCachePath", "Directory on a local disk to cache the data read", "", ""}
+	opts[ReadCacheSize] = MountOption{"readCacheSize", "Bytes of the read cache on the local disk, 0 disables", "", int64(0)}
+	opts[WriteBack] = MountOption{"writeBack", "Buffer the writes which are not synchronous in the memory", "", false}
+	opts[WriteBackDirtyLimit] = MountOption{"writeBackDirtyLimit", "Bytes of the writes buffered at most", "", int64(0)}
+	opts[WriteBackFlushInterval] = MountOption{"writeBackFlushInterval", "Seconds the writes are buffered at most", "", int64(0)}
+	opts[ReadBandwidth] = MountOption{"readBandwidth", "Read Bandwidth Limit in MB/s", "", int64(-1)}
+	opts[WriteBandwidth] = MountOption{"writeBandwidth", "Write Bandwidth Limit in MB/s", "", int64(-1)}
+	opts[LockMode] = MountOption{"lockMode", "Where the file locks are held: local or meta", "", LockModeLocal}
+	opts[ReadAheadMax] = MountOption{"readAheadMax", "Bytes prefetched at most after the sequential reads, negative disables", "", int64(0)}
+	opts[EncryptKeyFile] = MountOption{"encryptKeyFile", "File of the key in hex to encrypt the file contents", "", ""}
+	opts[MetaTimeout] = MountOption{"metaTimeout", "Milliseconds waiting for the reply of a meta node, 0 uses the default", "", int64(0)}
+	opts[MetaMaxRetries] = MountOption{"metaMaxRetries", "Retries of a failed meta request, 0 uses the default", "", int64(0)}
+	opts[MetaRetryTimeout] = MountOption{"metaRetryTimeout", "Milliseconds a failed meta request is retried at most, 0 uses the default", "", int64(0)}
+	opts[DataTimeout] = MountOption{"dataTimeout", "Milliseconds waiting for the reply of a data node, 0 uses the default", "", int64(0)}
+	opts[DataMaxRetries] = MountOption{"dataMaxRetries", "Retries of a failed data request, 0 uses the default", "", int64(0)}
+	opts[DataRetryTimeout] = MountOption{"dataRetryTimeout", "Milliseconds a failed data request is retried at most, 0 is unlimited", "", int64(0)}
+	opts[RetryBackoff] = MountOption{"retryBackoff", "Milliseconds before the first retry, 0 uses the default", "", int64(0)}
+	opts[RetryMaxBackoff] = MountOption{"retryMaxBackoff", "Milliseconds between the retries at most, doubling from retryBackoff", "", int64(0)}
+	opts[RetryJitter] = MountOption{"retryJitter", "Percentage of the retry interval randomized", "", int64(0)}
+	opts[HedgeReadDelay] = MountOption{"hedgeReadDelay", "Milliseconds before a follower read is also sent to another replica, 0 disables", "", int64(0)}
+	opts[AuditLog] = MountOption{"auditLog", "File of the audit log of the file operations, empty disables", "", ""}
+	opts[AuditCollector] = MountOption{"auditCollector", "URL the audit records are posted to, empty disables", "", ""}
+	opts[MemoryLimit] = MountOption{"memoryLimit", "Bytes of the caches and the write buffers of the client at most, 0 is unlimited", "", int64(0)}
+	opts[VerifyReadChecksum] = MountOption{"verifyReadChecksum", "Check the data read against the block CRCs of the data nodes", "", false}
+	opts[ClusterRateLimit] = MountOption{"clusterRateLimit", "Limit the bandwidth of the volume by the rate limits shared by the clients on the master", "", false}
 
 	for i := 0; i < MaxMountOption; i++ {
 		flag.StringVar(&opts[i].cmdlineValue, opts[i].keyword, "", opts[i].description)
 	}
 }
 
+// MountOptionsSchema returns the schema of the config file of the mount options.
+func MountOptionsSchema(opts []MountOption) config.Schema {
+	schema := make(config.Schema, len(opts))
+	for i := 0; i < MaxMountOption; i++ {
+		switch opts[i].value.(type) {
+		case int64:
+			schema[opts[i].keyword] = config.TypeInt
+		case bool:
+			schema[opts[i].keyword] = config.TypeBool
+		default:
+			schema[opts[i].keyword] = config.TypeString
+		}
+	}
+	return schema
+}
+
 func ParseMountOptions(opts []MountOption, cfg *config.Config) {
 	for i := 0; i < MaxMountOption; i++ {
 		switch v := opts[i].value.(type) {
@@ -139,8 +215,8 @@ func ParseMountOptions(opts []MountOption, cfg *config.Config) {
 			if opts[i].cmdlineValue != "" {
 				opts[i].value = parseInt64(opts[i].cmdlineValue)
 			} else {
-				if value, present := cfg.CheckAndGetString(opts[i].keyword); present {
-					opts[i].value = parseInt64(value)
+				if value, present := cfg.CheckAndGetInt64(opts[i].keyword); present {
+					opts[i].value = value
 				} else {
 					opts[i].value = v
 				}
@@ -218,6 +294,7 @@ type MountOptions struct {
 	Master               string
 	Logpath              string
 	Loglvl               string
+	LogFormat            string
 	Profport             string
 	IcacheTimeout        int64
 	LookupValid          int64
@@ -241,8 +318,43 @@ type MountOptions struct {
 	MaxCPUs              int64
 	EnableXattr          bool
 	NearRead             bool
+	ZoneName             string
+	Rack                 string
 	EnablePosixACL       bool
 	EnableSummary        bool
 	EnableUnixPermission bool
 	NeedRestoreFuse      bool
+	SnapshotID           uint64
+	InlineSize           int64
+	ReadCachePath        string
+	ReadCacheSize        int64
+	WriteBack            bool
+	WriteBackDirtyLimit  int64
+	WriteBackFlushSecs   int64
+	ReadBandwidth        int64
+	WriteBandwidth       int64
+	LockMode             string
+	ReadAheadMax         int64
+	EncryptKeyFile       string
+	MetaTimeout          int64
+	MetaMaxRetries       int64
+	MetaRetryTimeout     int64
+	DataTimeout          int64
+	DataMaxRetries       int64
+	DataRetryTimeout     int64
+	RetryBackoff         int64
+	RetryMaxBackoff      int64
+	RetryJitter          int64
+	HedgeReadDelay       int64
+	AuditLog             string
+	AuditCollector       string
+	MemoryLimit          int64
+	VerifyReadChecksum   bool
+	ClusterRateLimit     bool
 }
+
+// Where the file locks of a mount are held.
+const (
+	LockModeLocal = "local" // in the kernel, the locks are only seen on the host
+	LockModeMeta  = "meta"  // in the meta nodes, the locks are seen by all the clients
+)
@@ -28,6 +28,8 @@ import (
 
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/buf"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 var (
@@ -67,6 +69,11 @@ const (
 	OpReadTinyDeleteRecord           uint8 = 0x14
 	OpTinyExtentRepairRead           uint8 = 0x15
 	OpGetMaxExtentIDAndPartitionSize uint8 = 0x16
+	OpGetExtentCrcs                  uint8 = 0x17
+	OpPunchHole                      uint8 = 0x18
+	OpReadECShard                    uint8 = 0x19
+	OpPreallocExtent                 uint8 = 0x1A
+	OpSealExtents                    uint8 = 0x1B // MetaNode to DataNode
 
 	// Operations: Client -> MetaNode.
 	OpMetaCreateInode   uint8 = 0x20
@@ -100,6 +107,8 @@ const (
 	OpMetaBatchGetXAttr      uint8 = 0x39
 	OpMetaExtentAddWithCheck uint8 = 0x3A // Append extent key with discard extents check
 	OpMetaReadDirLimit       uint8 = 0x3D
+	OpMetaBatchStat          uint8 = 0x3E
+	OpMetaPunchHole          uint8 = 0x3F
 
 	// Operations: Master -> MetaNode
 	OpCreateMetaPartition           uint8 = 0x40
@@ -111,6 +120,11 @@ const (
 	OpAddMetaPartitionRaftMember    uint8 = 0x46
 	OpRemoveMetaPartitionRaftMember uint8 = 0x47
 	OpMetaPartitionTryToLeader      uint8 = 0x48
+	OpAddMetaPartitionRaftLearner   uint8 = 0x49
+	OpPromoteMetaPartitionLearner   uint8 = 0x4A
+	OpMetaPartitionTransferLeader   uint8 = 0x4B
+	OpMetaMigrateToCold             uint8 = 0x4C
+	OpMetaFenceWrites               uint8 = 0x4D
 
 	// Operations: Master -> DataNode
 	OpCreateDataPartition           uint8 = 0x60
@@ -123,6 +137,11 @@ const (
 	OpAddDataPartitionRaftMember    uint8 = 0x67
 	OpRemoveDataPartitionRaftMember uint8 = 0x68
 	OpDataPartitionTryToLeader      uint8 = 0x69
+	OpCheckDataPartition            uint8 = 0x6A
+	OpBackupDataPartition           uint8 = 0x6B
+	OpRestoreDataPartition          uint8 = 0x6C
+	OpConvertDataPartitionToEC      uint8 = 0x6D
+	OpDataNodeFenceWrites           uint8 = 0x6E
 
 	// Operations: MultipartInfo
 	OpCreateMultipart  uint8 = 0x70
@@ -133,6 +152,34 @@ const (
 
 	OpBatchDeleteExtent uint8 = 0x75 // SDK to MetaNode
 
+	// Operations: Subtree snapshot
+	OpMetaCreateSnapshot  uint8 = 0x76
+	OpMetaSealSnapshot    uint8 = 0x77
+	OpMetaDeleteSnapshot  uint8 = 0x78
+	OpMetaListSnapshots   uint8 = 0x79
+	OpMetaRestoreSnapshot uint8 = 0x7E
+
+	// Operations: Inline data
+	OpMetaWriteInline uint8 = 0x7A
+
+	// Operations: File locks
+	OpMetaSetLock    uint8 = 0x7B
+	OpMetaGetLock    uint8 = 0x7C
+	OpMetaRenewLocks uint8 = 0x7D
+
+	// Operations: Directory quotas
+	OpMetaSetInodeQuota uint8 = 0x7F
+
+	// Operations: Object versions
+	OpPutObjectVersion    uint8 = 0x80
+	OpGetObjectVersion    uint8 = 0x81
+	OpRemoveObjectVersion uint8 = 0x82
+	OpListObjectVersions  uint8 = 0x83
+
+	// Operations: Flash cache
+	OpFlashCacheRead     uint8 = 0x84
+	OpFlashNodeHeartbeat uint8 = 0x85
+
 	//Operations: MetaNode Leader -> MetaNode Follower
 	OpMetaBatchDeleteInode  uint8 = 0x90
 	OpMetaBatchDeleteDentry uint8 = 0x91
@@ -140,6 +187,7 @@ const (
 	OpMetaBatchEvictInode   uint8 = 0x93
 
 	// Commons
+	OpWriteThrottled     uint8 = 0xF1
 	OpConflictExtentsErr uint8 = 0xF2
 	OpIntraGroupNetErr   uint8 = 0xF3
 	OpArgMismatchErr     uint8 = 0xF4
@@ -153,6 +201,7 @@ const (
 	OpTryOtherAddr       uint8 = 0xFC
 	OpNotPerm            uint8 = 0xFD
 	OpNotEmtpy           uint8 = 0xFE
+	OpQuotaExceededErr   uint8 = 0xEF
 	OpOk                 uint8 = 0xF0
 
 	OpPing                  uint8 = 0xFF
@@ -174,6 +223,14 @@ const (
 	NormalExtentType = 1
 )
 
+// packetTraceFlag flags the request carrying the trace context, which follows the header in
+// tracing.SpanContextSize bytes. Only the requests of the sampled traces carry it.
+const packetTraceFlag uint8 = 0x20
+
+// packetRequestIDFlag flags the request carrying the request ID, which follows the trace context if any
+// in reqid.Size bytes. The requests carry it only if the propagation is enabled, see reqid.Propagated.
+const packetRequestIDFlag uint8 = 0x10
+
 const (
 	NormalCreateDataPartition         = 0
 	DecommissionedCreateDataPartition = 1
@@ -194,11 +251,17 @@ type Packet struct {
 	ExtentID           uint64
 	ExtentOffset       int64
 	ReqID              int64
+	ChecksumType       uint8  // type of the CRC, flagged in the extent type byte of the header
+	VerifyChecksum     bool   // the data read is checked against the block CRCs kept by the data node, flagged as above
 	Arg                []byte // for create or append ops, the data contains the address
 	Data               []byte
+	TraceContext       tracing.SpanContext
+	RequestID          reqid.ID // the ID of the user operation of the request
 	StartT             int64
 	mesg               string
 	HasPrepare         bool
+	traced             bool // the trace context follows the header read
+	requested          bool // the request ID follows the header read
 }
 
 // NewPacket returns a new packet.
@@ -217,9 +280,18 @@ func NewPacketReqID() *Packet {
 }
 
 func (p *Packet) String() string {
+	if p.RequestID.IsValid() {
+		return fmt.Sprintf("ReqID(%v)RequestID(%v)Op(%v)PartitionID(%v)ResultCode(%v)",
+			p.ReqID, p.RequestID, p.GetOpMsg(), p.PartitionID, p.GetResultMsg())
+	}
 	return fmt.Sprintf("ReqID(%v)Op(%v)PartitionID(%v)ResultCode(%v)", p.ReqID, p.GetOpMsg(), p.PartitionID, p.GetResultMsg())
 }
 
+// Checksum returns the checksum of the data with the checksum type of the packet.
+func (p *Packet) Checksum(data []byte) uint32 {
+	return Checksum(p.ChecksumType, data)
+}
+
 // GetStoreType returns the store type.
 func (p *Packet) GetStoreType() (m string) {
 	switch p.ExtentType {
@@ -284,6 +356,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaReadDir"
 	case OpMetaReadDirLimit:
 		m = "OpMetaReadDirLimit"
+	case OpMetaBatchStat:
+		m = "OpMetaBatchStat"
 	case OpMetaInodeGet:
 		m = "OpMetaInodeGet"
 	case OpMetaBatchInodeGet:
@@ -300,6 +374,10 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaUpdateDentry"
 	case OpMetaTruncate:
 		m = "OpMetaTruncate"
+	case OpMetaPunchHole:
+		m = "OpMetaPunchHole"
+	case OpMetaWriteInline:
+		m = "OpMetaWriteInline"
 	case OpMetaLinkInode:
 		m = "OpMetaLinkInode"
 	case OpMetaEvictInode:
@@ -352,6 +430,16 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpGetMaxExtentIDAndPartitionSize"
 	case OpBroadcastMinAppliedID:
 		m = "OpBroadcastMinAppliedID"
+	case OpGetExtentCrcs:
+		m = "OpGetExtentCrcs"
+	case OpPunchHole:
+		m = "OpPunchHole"
+	case OpReadECShard:
+		m = "OpReadECShard"
+	case OpPreallocExtent:
+		m = "OpPreallocExtent"
+	case OpSealExtents:
+		m = "OpSealExtents"
 	case OpRemoveDataPartitionRaftMember:
 		m = "OpRemoveDataPartitionRaftMember"
 	case OpAddDataPartitionRaftMember:
@@ -362,8 +450,28 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpRemoveMetaPartitionRaftMember"
 	case OpMetaPartitionTryToLeader:
 		m = "OpMetaPartitionTryToLeader"
+	case OpAddMetaPartitionRaftLearner:
+		m = "OpAddMetaPartitionRaftLearner"
+	case OpPromoteMetaPartitionLearner:
+		m = "OpPromoteMetaPartitionLearner"
+	case OpMetaPartitionTransferLeader:
+		m = "OpMetaPartitionTransferLeader"
+	case OpMetaMigrateToCold:
+		m = "OpMetaMigrateToCold"
+	case OpMetaFenceWrites:
+		m = "OpMetaFenceWrites"
 	case OpDataPartitionTryToLeader:
 		m = "OpDataPartitionTryToLeader"
+	case OpCheckDataPartition:
+		m = "OpCheckDataPartition"
+	case OpBackupDataPartition:
+		m = "OpBackupDataPartition"
+	case OpRestoreDataPartition:
+		m = "OpRestoreDataPartition"
+	case OpConvertDataPartitionToEC:
+		m = "OpConvertDataPartitionToEC"
+	case OpDataNodeFenceWrites:
+		m = "OpDataNodeFenceWrites"
 	case OpMetaDeleteInode:
 		m = "OpMetaDeleteInode"
 	case OpMetaBatchDeleteInode:
@@ -392,6 +500,36 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpListMultiparts"
 	case OpBatchDeleteExtent:
 		m = "OpBatchDeleteExtent"
+	case OpMetaCreateSnapshot:
+		m = "OpMetaCreateSnapshot"
+	case OpMetaSealSnapshot:
+		m = "OpMetaSealSnapshot"
+	case OpMetaDeleteSnapshot:
+		m = "OpMetaDeleteSnapshot"
+	case OpMetaListSnapshots:
+		m = "OpMetaListSnapshots"
+	case OpMetaRestoreSnapshot:
+		m = "OpMetaRestoreSnapshot"
+	case OpMetaSetLock:
+		m = "OpMetaSetLock"
+	case OpMetaGetLock:
+		m = "OpMetaGetLock"
+	case OpMetaRenewLocks:
+		m = "OpMetaRenewLocks"
+	case OpMetaSetInodeQuota:
+		m = "OpMetaSetInodeQuota"
+	case OpPutObjectVersion:
+		m = "OpPutObjectVersion"
+	case OpGetObjectVersion:
+		m = "OpGetObjectVersion"
+	case OpRemoveObjectVersion:
+		m = "OpRemoveObjectVersion"
+	case OpListObjectVersions:
+		m = "OpListObjectVersions"
+	case OpFlashCacheRead:
+		m = "OpFlashCacheRead"
+	case OpFlashNodeHeartbeat:
+		m = "OpFlashNodeHeartbeat"
 	}
 	return
 }
@@ -403,6 +541,8 @@ func (p *Packet) GetResultMsg() (m string) {
 	}
 
 	switch p.ResultCode {
+	case OpWriteThrottled:
+		m = "WriteThrottled"
 	case OpConflictExtentsErr:
 		m = "ConflictExtentsErr"
 	case OpIntraGroupNetErr:
@@ -431,6 +571,8 @@ func (p *Packet) GetResultMsg() (m string) {
 		m = "NotPerm"
 	case OpNotEmtpy:
 		m = "DirNotEmpty"
+	case OpQuotaExceededErr:
+		m = "QuotaExceededErr"
 	default:
 		return fmt.Sprintf("Unknown ResultCode(%v)", p.ResultCode)
 	}
@@ -445,6 +587,18 @@ func (p *Packet) GetReqID() int64 {
 func (p *Packet) MarshalHeader(out []byte) {
 	out[0] = p.Magic
 	out[1] = p.ExtentType
+	if p.ChecksumType == ChecksumCrc32c {
+		out[1] |= packetCrc32cFlag
+	}
+	if p.VerifyChecksum {
+		out[1] |= packetVerifyFlag
+	}
+	if p.hasTraceContext() {
+		out[1] |= packetTraceFlag
+	}
+	if p.hasRequestID() {
+		out[1] |= packetRequestIDFlag
+	}
 	out[2] = p.Opcode
 	out[3] = p.ResultCode
 	out[4] = p.RemainingFollowers
@@ -466,7 +620,14 @@ func (p *Packet) UnmarshalHeader(in []byte) error {
 		return errors.New("Bad Magic " + strconv.Itoa(int(p.Magic)))
 	}
 
-	p.ExtentType = in[1]
+	p.ExtentType = in[1] &^ (packetCrc32cFlag | packetVerifyFlag | packetTraceFlag | packetRequestIDFlag)
+	p.VerifyChecksum = in[1]&packetVerifyFlag != 0
+	p.traced = in[1]&packetTraceFlag != 0
+	p.requested = in[1]&packetRequestIDFlag != 0
+	p.ChecksumType = ChecksumCrc32
+	if in[1]&packetCrc32cFlag != 0 {
+		p.ChecksumType = ChecksumCrc32c
+	}
 	p.Opcode = in[2]
 	p.ResultCode = in[3]
 	p.RemainingFollowers = in[4]
@@ -482,6 +643,46 @@ func (p *Packet) UnmarshalHeader(in []byte) error {
 	return nil
 }
 
+func (p *Packet) hasTraceContext() bool {
+	return p.ResultCode == OpInitResultCode && p.TraceContext.IsValid()
+}
+
+func (p *Packet) hasRequestID() bool {
+	return p.ResultCode == OpInitResultCode && p.RequestID.IsValid() && reqid.Propagated()
+}
+
+// writeHeaderExtensions writes the trace context and the request ID following the header if the header is flagged.
+func (p *Packet) writeHeaderExtensions(c net.Conn) (err error) {
+	if p.hasTraceContext() {
+		trace := make([]byte, tracing.SpanContextSize)
+		p.TraceContext.MarshalTo(trace)
+		if _, err = c.Write(trace); err != nil {
+			return
+		}
+	}
+	if p.hasRequestID() {
+		_, err = c.Write(p.RequestID[:])
+	}
+	return
+}
+
+// ReadHeaderExtensions reads the trace context and the request ID following the header if the header is flagged.
+func (p *Packet) ReadHeaderExtensions(c io.Reader) (err error) {
+	p.TraceContext = tracing.SpanContext{}
+	p.RequestID = reqid.ID{}
+	if p.traced {
+		trace := make([]byte, tracing.SpanContextSize)
+		if _, err = io.ReadFull(c, trace); err != nil {
+			return
+		}
+		p.TraceContext = tracing.UnmarshalSpanContext(trace)
+	}
+	if p.requested {
+		_, err = io.ReadFull(c, p.RequestID[:])
+	}
+	return
+}
+
 // MarshalData marshals the packet data.
 func (p *Packet) MarshalData(v interface{}) error {
 	data, err := json.Marshal(v)
@@ -507,6 +708,9 @@ func (p *Packet) WriteToNoDeadLineConn(c net.Conn) (err error) {
 
 	p.MarshalHeader(header)
 	if _, err = c.Write(header); err == nil {
+		if err = p.writeHeaderExtensions(c); err != nil {
+			return
+		}
 		if _, err = c.Write(p.Arg[:int(p.ArgLen)]); err == nil {
 			if p.Data != nil {
 				_, err = c.Write(p.Data[:p.Size])
@@ -528,6 +732,9 @@ func (p *Packet) WriteToConn(c net.Conn) (err error) {
 
 	p.MarshalHeader(header)
 	if _, err = c.Write(header); err == nil {
+		if err = p.writeHeaderExtensions(c); err != nil {
+			return
+		}
 		if _, err = c.Write(p.Arg[:int(p.ArgLen)]); err == nil {
 			if p.Data != nil && p.Size != 0 {
 				_, err = c.Write(p.Data[:p.Size])
@@ -547,8 +754,16 @@ func ReadFull(c net.Conn, buf *[]byte, readSize int) (err error) {
 
 // ReadFromConn reads the data from the given connection.
 func (p *Packet) ReadFromConn(c net.Conn, timeoutSec int) (err error) {
-	if timeoutSec != NoReadDeadlineTime {
-		c.SetReadDeadline(time.Now().Add(time.Second * time.Duration(timeoutSec)))
+	if timeoutSec == NoReadDeadlineTime {
+		return p.ReadFromConnTimeout(c, 0)
+	}
+	return p.ReadFromConnTimeout(c, time.Second*time.Duration(timeoutSec))
+}
+
+// ReadFromConnTimeout reads the data from the given connection in the timeout, 0 for no deadline.
+func (p *Packet) ReadFromConnTimeout(c net.Conn, timeout time.Duration) (err error) {
+	if timeout != 0 {
+		c.SetReadDeadline(time.Now().Add(timeout))
 	} else {
 		c.SetReadDeadline(time.Time{})
 	}
@@ -567,6 +782,9 @@ func (p *Packet) ReadFromConn(c net.Conn, timeoutSec int) (err error) {
 	if err = p.UnmarshalHeader(header); err != nil {
 		return
 	}
+	if err = p.ReadHeaderExtensions(c); err != nil {
+		return
+	}
 
 	if p.ArgLen > 0 {
 		p.Arg = make([]byte, int(p.ArgLen))
@@ -579,7 +797,8 @@ func (p *Packet) ReadFromConn(c net.Conn, timeoutSec int) (err error) {
 		return syscall.EBADMSG
 	}
 	size := p.Size
-	if (p.Opcode == OpRead || p.Opcode == OpStreamRead || p.Opcode == OpExtentRepairRead || p.Opcode == OpStreamFollowerRead) && p.ResultCode == OpInitResultCode {
+	if (p.Opcode == OpRead || p.Opcode == OpStreamRead || p.Opcode == OpExtentRepairRead || p.Opcode == OpStreamFollowerRead ||
+		p.Opcode == OpFlashCacheRead) && p.ResultCode == OpInitResultCode {
 		size = 0
 	}
 	p.Data = make([]byte, size)
@@ -634,6 +853,9 @@ func (p *Packet) AddMesgLog(m string) {
 // GetUniqueLogId returns the unique log ID.
 func (p *Packet) GetUniqueLogId() (m string) {
 	defer func() {
+		if p.RequestID.IsValid() {
+			m += fmt.Sprintf("_RequestID(%v)", p.RequestID)
+		}
 		m = m + fmt.Sprintf("_ResultMesg(%v)", p.GetResultMsg())
 	}()
 	if p.HasPrepare {
@@ -650,7 +872,7 @@ func (p *Packet) GetUniqueLogId() (m string) {
 			return m
 		}
 	} else if p.Opcode == OpReadTinyDeleteRecord || p.Opcode == OpNotifyReplicasToRepair || p.Opcode == OpDataNodeHeartbeat ||
-		p.Opcode == OpLoadDataPartition || p.Opcode == OpBatchDeleteExtent {
+		p.Opcode == OpLoadDataPartition || p.Opcode == OpBatchDeleteExtent || p.Opcode == OpSealExtents {
 		p.mesg += fmt.Sprintf("Opcode(%v)", p.GetOpMsg())
 		return
 	} else if p.Opcode == OpBroadcastMinAppliedID || p.Opcode == OpGetAppliedId {
@@ -681,7 +903,7 @@ func (p *Packet) setPacketPrefix() {
 			return
 		}
 	} else if p.Opcode == OpReadTinyDeleteRecord || p.Opcode == OpNotifyReplicasToRepair || p.Opcode == OpDataNodeHeartbeat ||
-		p.Opcode == OpLoadDataPartition || p.Opcode == OpBatchDeleteExtent {
+		p.Opcode == OpLoadDataPartition || p.Opcode == OpBatchDeleteExtent || p.Opcode == OpSealExtents {
 		p.mesg += fmt.Sprintf("Opcode(%v)", p.GetOpMsg())
 		return
 	} else if p.Opcode == OpBroadcastMinAppliedID || p.Opcode == OpGetAppliedId {
@@ -57,6 +57,9 @@ const (
 	OSSDeleteObjectsAction Action = OSSActionPrefix + "DeleteObjects"
 	OSSHeadObjectAction    Action = OSSActionPrefix + "HeadObject"
 
+	// Object select actions
+	OSSSelectObjectContentAction Action = OSSActionPrefix + "SelectObjectContent"
+
 	// Bucket actions
 	OSSCreateBucketAction Action = OSSActionPrefix + "CreateBucket"
 	OSSDeleteBucketAction Action = OSSActionPrefix + "DeleteBucket"
@@ -90,7 +93,7 @@ const (
 	OSSCreateMultipartUploadAction   Action = OSSActionPrefix + "CreateMultipartUpload"
 	OSSListMultipartUploadsAction    Action = OSSActionPrefix + "ListMultipartUploads"
 	OSSUploadPartAction              Action = OSSActionPrefix + "UploadPart"
-	OSSUploadPartCopyAction          Action = OSSActionPrefix + "UploadPartCopy" // unsupported
+	OSSUploadPartCopyAction          Action = OSSActionPrefix + "UploadPartCopy"
 	OSSListPartsAction               Action = OSSActionPrefix + "ListParts"
 	OSSCompleteMultipartUploadAction Action = OSSActionPrefix + "CompleteMultipartUpload"
 	OSSAbortMultipartUploadAction    Action = OSSActionPrefix + "AbortMultipartUpload"
@@ -115,9 +118,17 @@ const (
 	OSSDeleteBucketTaggingAction Action = OSSActionPrefix + "DeleteBucketTagging"
 
 	// Bucket lifecycle actions
-	OSSGetBucketLifecycleAction    Action = OSSActionPrefix + "GetBucketLifecycle"    // unsupported
-	OSSPutBucketLifecycleAction    Action = OSSActionPrefix + "PutBucketLifecycle"    // unsupported
-	OSSDeleteBucketLifecycleAction Action = OSSActionPrefix + "DeleteBucketLifecycle" // unsupported
+	OSSGetBucketLifecycleAction    Action = OSSActionPrefix + "GetBucketLifecycle"
+	OSSPutBucketLifecycleAction    Action = OSSActionPrefix + "PutBucketLifecycle"
+	OSSDeleteBucketLifecycleAction Action = OSSActionPrefix + "DeleteBucketLifecycle"
+
+	// Bucket notification actions
+	OSSGetBucketNotificationAction Action = OSSActionPrefix + "GetBucketNotification"
+	OSSPutBucketNotificationAction Action = OSSActionPrefix + "PutBucketNotification"
+
+	// Bucket logging actions
+	OSSGetBucketLoggingAction Action = OSSActionPrefix + "GetBucketLogging"
+	OSSPutBucketLoggingAction Action = OSSActionPrefix + "PutBucketLogging"
 
 	// Object storage version actions
 	OSSGetBucketVersioningAction Action = OSSActionPrefix + "GetBucketVersioning" // unsupported
@@ -125,12 +136,17 @@ const (
 	OSSListObjectVersionsAction  Action = OSSActionPrefix + "ListObjectVersions"  // unsupported
 
 	// Object legal hold actions
-	OSSGetObjectLegalHoldAction Action = OSSActionPrefix + "GetObjectLegalHold" // unsupported
-	OSSPutObjectLegalHoldAction Action = OSSActionPrefix + "PutObjectLegalHold" // unsupported
+	OSSGetObjectLegalHoldAction Action = OSSActionPrefix + "GetObjectLegalHold"
+	OSSPutObjectLegalHoldAction Action = OSSActionPrefix + "PutObjectLegalHold"
 
 	// Object retention actions
-	OSSGetObjectRetentionAction Action = OSSActionPrefix + "GetObjectRetention" // unsupported
-	OSSPutObjectRetentionAction Action = OSSActionPrefix + "PutObjectRetention" // unsupported
+	OSSGetObjectRetentionAction        Action = OSSActionPrefix + "GetObjectRetention"
+	OSSPutObjectRetentionAction        Action = OSSActionPrefix + "PutObjectRetention"
+	OSSBypassGovernanceRetentionAction Action = OSSActionPrefix + "BypassGovernanceRetention"
+
+	// Bucket object lock actions
+	OSSGetBucketObjectLockConfigurationAction Action = OSSActionPrefix + "GetBucketObjectLockConfiguration"
+	OSSPutBucketObjectLockConfigurationAction Action = OSSActionPrefix + "PutBucketObjectLockConfiguration"
 
 	// Bucket encryption actions
 	OSSGetBucketEncryptionAction    Action = OSSActionPrefix + "GetBucketEncryption"    // unsupported
@@ -138,9 +154,9 @@ const (
 	OSSDeleteBucketEncryptionAction Action = OSSActionPrefix + "DeleteBucketEncryption" // unsupported
 
 	// Bucket website actions
-	OSSGetBucketWebsiteAction    Action = OSSActionPrefix + "GetBucketWebsite"    // unsupported
-	OSSPutBucketWebsiteAction    Action = OSSActionPrefix + "PutBucketWebsite"    // unsupported
-	OSSDeleteBucketWebsiteAction Action = OSSActionPrefix + "DeleteBucketWebsite" // unsupported
+	OSSGetBucketWebsiteAction    Action = OSSActionPrefix + "GetBucketWebsite"
+	OSSPutBucketWebsiteAction    Action = OSSActionPrefix + "PutBucketWebsite"
+	OSSDeleteBucketWebsiteAction Action = OSSActionPrefix + "DeleteBucketWebsite"
 
 	// Object restore actions
 	OSSRestoreObjectAction Action = OSSActionPrefix + "RestoreObject" // unsupported
@@ -155,9 +171,19 @@ const (
 	OSSPutBucketRequestPaymentAction Action = OSSActionPrefix + "PutBucketRequestPayment" // unsupported
 
 	// Bucket replication actions
-	OSSGetBucketReplicationAction    Action = OSSActionPrefix + "GetBucketReplicationAction"    // unsupported
-	OSSPutBucketReplicationAction    Action = OSSActionPrefix + "PutBucketReplicationAction"    // unsupported
-	OSSDeleteBucketReplicationAction Action = OSSActionPrefix + "DeleteBucketReplicationAction" // unsupported
+	OSSGetBucketReplicationAction    Action = OSSActionPrefix + "GetBucketReplication"
+	OSSPutBucketReplicationAction    Action = OSSActionPrefix + "PutBucketReplication"
+	OSSDeleteBucketReplicationAction Action = OSSActionPrefix + "DeleteBucketReplication"
+
+	// Bucket inventory actions
+	OSSGetBucketInventoryAction    Action = OSSActionPrefix + "GetBucketInventoryConfiguration"
+	OSSListBucketInventoryAction   Action = OSSActionPrefix + "ListBucketInventoryConfigurations"
+	OSSPutBucketInventoryAction    Action = OSSActionPrefix + "PutBucketInventoryConfiguration"
+	OSSDeleteBucketInventoryAction Action = OSSActionPrefix + "DeleteBucketInventoryConfiguration"
+
+	// Temporary credentials actions
+	OSSGetSessionTokenAction Action = OSSActionPrefix + "GetSessionToken"
+	OSSAssumeRoleAction      Action = OSSActionPrefix + "AssumeRole"
 
 	// constants for POSIX file system interface
 	POSIXReadAction  Action = POSIXActionPrefix + "Read"
@@ -176,6 +202,7 @@ var (
 		OSSDeleteObjectAction,
 		OSSDeleteObjectsAction,
 		OSSHeadObjectAction,
+		OSSSelectObjectContentAction,
 		OSSCreateBucketAction,
 		OSSDeleteBucketAction,
 		OSSHeadBucketAction,
@@ -210,6 +237,10 @@ var (
 		OSSGetBucketLifecycleAction,
 		OSSPutBucketLifecycleAction,
 		OSSDeleteBucketLifecycleAction,
+		OSSGetBucketNotificationAction,
+		OSSPutBucketNotificationAction,
+		OSSGetBucketLoggingAction,
+		OSSPutBucketLoggingAction,
 		OSSGetBucketVersioningAction,
 		OSSPutBucketVersioningAction,
 		OSSListObjectVersionsAction,
@@ -217,6 +248,9 @@ var (
 		OSSPutObjectLegalHoldAction,
 		OSSGetObjectRetentionAction,
 		OSSPutObjectRetentionAction,
+		OSSBypassGovernanceRetentionAction,
+		OSSGetBucketObjectLockConfigurationAction,
+		OSSPutBucketObjectLockConfigurationAction,
 		OSSGetBucketEncryptionAction,
 		OSSPutBucketEncryptionAction,
 		OSSDeleteBucketEncryptionAction,
@@ -235,7 +269,13 @@ var (
 		OSSGetBucketReplicationAction,
 		OSSPutBucketReplicationAction,
 		OSSDeleteBucketReplicationAction,
+		OSSGetBucketInventoryAction,
+		OSSListBucketInventoryAction,
+		OSSPutBucketInventoryAction,
+		OSSDeleteBucketInventoryAction,
 		OSSOptionsObjectAction,
+		OSSGetSessionTokenAction,
+		OSSAssumeRoleAction,
 
 		// POSIX file system interface actions
 		POSIXReadAction,
@@ -389,6 +429,7 @@ var (
 			OSSGetObjectAction,
 			OSSListObjectsAction,
 			OSSHeadObjectAction,
+			OSSSelectObjectContentAction,
 			OSSHeadBucketAction,
 			OSSGetObjectTorrentAction,
 			OSSGetObjectAclAction,
@@ -412,6 +453,7 @@ var (
 			OSSDeleteObjectAction,
 			OSSDeleteObjectsAction,
 			OSSHeadObjectAction,
+			OSSSelectObjectContentAction,
 			OSSHeadBucketAction,
 			OSSGetObjectTorrentAction,
 			OSSGetObjectAclAction,
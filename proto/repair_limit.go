@@ -0,0 +1,109 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepairLimitWindow defines the limits of the repairs within a daily time window, in the
+// local time of the data nodes. The window wraps past midnight if it ends before it starts.
+type RepairLimitWindow struct {
+	Start                 string // HH:MM
+	End                   string // HH:MM
+	ExtentRepairLimit     uint64 // extents repaired at the same time, 0 for the default
+	DiskRepairIOLimitRate uint64 // repair io bytes per second of each disk, 0 for no limit
+}
+
+func (w *RepairLimitWindow) String() string {
+	return fmt.Sprintf("%v-%v/%v/%v", w.Start, w.End, w.ExtentRepairLimit, w.DiskRepairIOLimitRate)
+}
+
+// Contains returns whether the window contains the time of the day.
+func (w *RepairLimitWindow) Contains(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return start <= now && now < end
+	}
+	return now >= start || now < end
+}
+
+// LimitsAt returns the limits in effect at the time, the ones of the first window containing
+// the time, or the limits out of the windows.
+func (l *DataNodeRepairLimit) LimitsAt(t time.Time) (extentRepairLimit, diskRepairIOLimitRate uint64) {
+	for _, w := range l.Windows {
+		if w.Contains(t) {
+			return w.ExtentRepairLimit, w.DiskRepairIOLimitRate
+		}
+	}
+	return l.ExtentRepairLimit, l.DiskRepairIOLimitRate
+}
+
+// ParseRepairLimitWindows parses the windows separated by commas, each of them in the format
+// HH:MM-HH:MM/extentRepairLimit/diskRepairIOLimitRate.
+func ParseRepairLimitWindows(s string) (windows []*RepairLimitWindow, err error) {
+	windows = make([]*RepairLimitWindow, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, "/")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid window %v, expected HH:MM-HH:MM/extentRepairLimit/diskRepairIOLimitRate", item)
+		}
+		clocks := strings.Split(fields[0], "-")
+		if len(clocks) != 2 {
+			return nil, fmt.Errorf("invalid time range %v", fields[0])
+		}
+		w := &RepairLimitWindow{Start: clocks[0], End: clocks[1]}
+		for _, clock := range clocks {
+			if _, err = parseClock(clock); err != nil {
+				return nil, err
+			}
+		}
+		if w.Start == w.End {
+			return nil, fmt.Errorf("empty time range %v", fields[0])
+		}
+		if w.ExtentRepairLimit, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid extent repair limit %v", fields[1])
+		}
+		if w.DiskRepairIOLimitRate, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid disk repair io limit rate %v", fields[2])
+		}
+		windows = append(windows, w)
+	}
+	return
+}
+
+// parseClock returns the minutes of the day of a time in the format HH:MM.
+func parseClock(clock string) (minutes int, err error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %v, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
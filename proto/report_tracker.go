@@ -0,0 +1,97 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultFullReportInterval is the interval of the full partition reports of the heartbeats.
+const DefaultFullReportInterval = 5 * time.Minute
+
+// ReportDelta defines the partitions of a heartbeat to report, relative to the base report acked by the master.
+type ReportDelta struct {
+	Seq     uint64
+	Base    uint64   // 0 for a full report
+	Removed []uint64 // partitions of the base report which are removed since
+	changed map[uint64]bool
+}
+
+// Changed returns if the report of the partition is to send.
+func (d *ReportDelta) Changed(id uint64) bool {
+	return d.Base == 0 || d.changed[id]
+}
+
+// ReportTracker keeps the partition reports of a node sent in the heartbeats, so that the node sends
+// only the reports changed since the last one applied by the master, which the master acks in the
+// heartbeat requests. A full report is sent if the acked report is unknown, e.g. the master leader
+// changes or the response is lost, and at the full report interval.
+type ReportTracker struct {
+	sync.Mutex
+	fullInterval time.Duration
+	lastFull     time.Time
+	seq          uint64
+	sent         map[uint64]interface{}
+	ackedSeq     uint64
+	acked        map[uint64]interface{}
+}
+
+// NewReportTracker returns a tracker sending a full report at the interval at least. The sequences
+// start from the current time, so that the ones of a restarted node differ from the acked ones.
+func NewReportTracker(fullInterval time.Duration) *ReportTracker {
+	return &ReportTracker{
+		fullInterval: fullInterval,
+		seq:          uint64(time.Now().UnixNano()),
+	}
+}
+
+// Report records the reports of a heartbeat by the partitions, whose values are compared deeply,
+// and returns the delta to send against the report acked by the master.
+func (t *ReportTracker) Report(ackedSeq uint64, reports map[uint64]interface{}) (delta *ReportDelta) {
+	t.Lock()
+	defer t.Unlock()
+	switch {
+	case ackedSeq == 0:
+		t.acked, t.ackedSeq = nil, 0
+	case ackedSeq == t.seq && t.sent != nil:
+		t.acked, t.ackedSeq = t.sent, t.seq
+	case ackedSeq == t.ackedSeq && t.acked != nil:
+		// the master has not applied the last report yet
+	default:
+		t.acked, t.ackedSeq = nil, 0
+	}
+	t.seq++
+	t.sent = reports
+	delta = &ReportDelta{Seq: t.seq}
+	if t.acked == nil || time.Since(t.lastFull) >= t.fullInterval {
+		t.lastFull = time.Now()
+		return
+	}
+	delta.Base = t.ackedSeq
+	delta.changed = make(map[uint64]bool)
+	for id, report := range reports {
+		if old, ok := t.acked[id]; !ok || !reflect.DeepEqual(old, report) {
+			delta.changed[id] = true
+		}
+	}
+	for id := range t.acked {
+		if _, ok := reports[id]; !ok {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+	return
+}
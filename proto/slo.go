@@ -0,0 +1,155 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SLOLatencyBoundsMs are the upper bounds in milliseconds of the latency buckets of the SLO reports,
+// the last bucket counts the operations slower than all the bounds.
+var SLOLatencyBoundsMs = []int64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+// VolSLO defines the objectives of the operations of a volume served by the clients and the nodes.
+type VolSLO struct {
+	LatencyMs       int64   // latency objective, one of SLOLatencyBoundsMs, 0 if not set
+	LatencyTarget   float64 // fraction of the operations completed within LatencyMs, e.g. 0.99
+	ErrorRateTarget float64 // fraction of the operations allowed to fail, e.g. 0.001, 0 if not set
+}
+
+// Validate checks the objectives.
+func (s *VolSLO) Validate() error {
+	if s.LatencyMs != 0 {
+		if i := SLOLatencyBucket(time.Duration(s.LatencyMs) * time.Millisecond); i >= len(SLOLatencyBoundsMs) ||
+			SLOLatencyBoundsMs[i] != s.LatencyMs {
+			return fmt.Errorf("latency objective %vms is not one of %v", s.LatencyMs, SLOLatencyBoundsMs)
+		}
+		if s.LatencyTarget <= 0 || s.LatencyTarget >= 1 {
+			return fmt.Errorf("latency target %v is not in (0, 1)", s.LatencyTarget)
+		}
+	}
+	if s.ErrorRateTarget < 0 || s.ErrorRateTarget >= 1 {
+		return fmt.Errorf("error rate target %v is not in [0, 1)", s.ErrorRateTarget)
+	}
+	return nil
+}
+
+// IsEmpty returns if no objective is set.
+func (s *VolSLO) IsEmpty() bool {
+	return s.LatencyMs == 0 && s.ErrorRateTarget == 0
+}
+
+// SLOLatencyBucket returns the index of the latency bucket of SLOLatencyBoundsMs.
+func SLOLatencyBucket(latency time.Duration) int {
+	for i, bound := range SLOLatencyBoundsMs {
+		if latency <= time.Duration(bound)*time.Millisecond {
+			return i
+		}
+	}
+	return len(SLOLatencyBoundsMs)
+}
+
+// SLOReport defines the operations of a volume served by a client or a node since its last report.
+type SLOReport struct {
+	VolName string
+	Ops     uint64
+	Errors  uint64
+	Latency []uint64 // operations by the latency buckets of SLOLatencyBoundsMs
+}
+
+// SLOReportRequest reports the operations of the volumes served by a client to the master.
+type SLOReportRequest struct {
+	Node    string
+	Reports []*SLOReport
+}
+
+// SLOStatus defines the state of the objectives of a volume. A burn rate is the rate of the bad
+// operations within a window divided by the rate allowed by the objective, an objective is at risk
+// if both of its short and long window burn rates are above the threshold.
+type SLOStatus struct {
+	VolName              string
+	SLO                  *VolSLO
+	Ops                  uint64 // within the long window
+	Errors               uint64
+	SlowOps              uint64 // slower than the latency objective
+	ShortLatencyBurnRate float64
+	LongLatencyBurnRate  float64
+	ShortErrorBurnRate   float64
+	LongErrorBurnRate    float64
+	AtRisk               bool
+	Reason               string
+	UpdateTime           int64
+}
+
+type sloCounter struct {
+	ops     uint64
+	errors  uint64
+	latency []uint64
+}
+
+// SLORecorder counts the operations of the volumes served by a client or a node between the reports.
+type SLORecorder struct {
+	sync.RWMutex
+	vols map[string]*sloCounter
+}
+
+func NewSLORecorder() *SLORecorder {
+	return &SLORecorder{vols: make(map[string]*sloCounter)}
+}
+
+// Record counts an operation of the volume.
+func (r *SLORecorder) Record(volName string, latency time.Duration, failed bool) {
+	if volName == "" {
+		return
+	}
+	r.RLock()
+	c := r.vols[volName]
+	r.RUnlock()
+	if c == nil {
+		r.Lock()
+		if c = r.vols[volName]; c == nil {
+			c = &sloCounter{latency: make([]uint64, len(SLOLatencyBoundsMs)+1)}
+			r.vols[volName] = c
+		}
+		r.Unlock()
+	}
+	atomic.AddUint64(&c.ops, 1)
+	if failed {
+		atomic.AddUint64(&c.errors, 1)
+	}
+	atomic.AddUint64(&c.latency[SLOLatencyBucket(latency)], 1)
+}
+
+// Take returns the reports of the volumes with the operations since the last call, and resets the counts.
+func (r *SLORecorder) Take() (reports []*SLOReport) {
+	r.RLock()
+	defer r.RUnlock()
+	for volName, c := range r.vols {
+		ops := atomic.SwapUint64(&c.ops, 0)
+		if ops == 0 {
+			continue
+		}
+		report := &SLOReport{VolName: volName, Ops: ops, Errors: atomic.SwapUint64(&c.errors, 0),
+			Latency: make([]uint64, len(c.latency))}
+		for i := range c.latency {
+			report.Latency[i] = atomic.SwapUint64(&c.latency[i], 0)
+		}
+		reports = append(reports, report)
+	}
+	return
+}
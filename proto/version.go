@@ -2,7 +2,9 @@ package proto
 
 import (
 	"fmt"
+	"regexp"
 	"runtime"
+	"strconv"
 )
 
 var (
@@ -12,6 +14,14 @@ var (
 	BuildTime  string
 )
 
+// BuildVersion returns the version and the commit of the build, which the nodes report to the master by the heartbeats.
+func BuildVersion() string {
+	if CommitID == "" {
+		return Version
+	}
+	return fmt.Sprintf("%s-%s", Version, CommitID)
+}
+
 func DumpVersion(role string) string {
 	return fmt.Sprintf("ChubaoFS %s\n"+
 		"Version : %s\n"+
@@ -24,3 +34,70 @@ func DumpVersion(role string) string {
 		CommitID,
 		runtime.Version(), runtime.GOOS, runtime.GOARCH, BuildTime)
 }
+
+// VersionRule declares that the nodes of the version line cannot work with the nodes of the lines older than
+// MinPeerLine, as the protocol changed incompatibly in the line. The lines are of the form major.minor.
+type VersionRule struct {
+	Line        string
+	MinPeerLine string
+	Reason      string
+}
+
+// VersionMatrix is the compatibility matrix of the version lines. The versions of the different majors never work
+// together, and the versions of the same major work together if their minors differ by MaxMinorSkew at most, and
+// no rule of the newer line requires a newer peer than the older one. A rule is added here when the protocol
+// between the nodes changes incompatibly.
+var VersionMatrix = []VersionRule{}
+
+// MaxMinorSkew is the most minors by which the versions of the nodes may differ during a rolling upgrade.
+const MaxMinorSkew = 1
+
+var versionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+// ParseVersionLine returns the major and the minor of the version reported by BuildVersion.
+func ParseVersionLine(version string) (major, minor int, err error) {
+	matches := versionRegexp.FindStringSubmatch(version)
+	if matches == nil {
+		return 0, 0, fmt.Errorf("unknown version [%v]", version)
+	}
+	major, _ = strconv.Atoi(matches[1])
+	minor, _ = strconv.Atoi(matches[2])
+	return
+}
+
+// CheckVersionCompatible returns whether the nodes of the two versions work together, and the reason if they do not.
+// It returns an error if either version cannot be parsed, which is the case of the builds without a version tag.
+func CheckVersionCompatible(a, b string) (compatible bool, reason string, err error) {
+	aMajor, aMinor, err := ParseVersionLine(a)
+	if err != nil {
+		return
+	}
+	bMajor, bMinor, err := ParseVersionLine(b)
+	if err != nil {
+		return
+	}
+	if aMajor != bMajor {
+		return false, fmt.Sprintf("major versions %v and %v are incompatible", aMajor, bMajor), nil
+	}
+	if aMinor < bMinor {
+		aMinor, bMinor = bMinor, aMinor
+		a, b = b, a
+	}
+	if aMinor-bMinor > MaxMinorSkew {
+		return false, fmt.Sprintf("versions %v.%v and %v.%v differ by more than %v minor", aMajor, aMinor, bMajor, bMinor, MaxMinorSkew), nil
+	}
+	newer := fmt.Sprintf("%v.%v", aMajor, aMinor)
+	for _, rule := range VersionMatrix {
+		if rule.Line != newer {
+			continue
+		}
+		var minMajor, minMinor int
+		if minMajor, minMinor, err = ParseVersionLine(rule.MinPeerLine); err != nil {
+			return
+		}
+		if bMajor < minMajor || (bMajor == minMajor && bMinor < minMinor) {
+			return false, fmt.Sprintf("%v needs %v or newer: %v", newer, rule.MinPeerLine, rule.Reason), nil
+		}
+	}
+	return true, "", nil
+}
@@ -0,0 +1,280 @@
+# Copyright 2020 The ChubaoFS Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+# implied. See the License for the specific language governing
+# permissions and limitations under the License.
+
+"""A client of a volume which accesses the meta nodes and the data nodes directly through libcfs."""
+
+import ctypes
+import errno
+import io
+import os
+import stat as statmod
+
+from . import libcfs
+
+# dirents read from a directory at a time
+READDIR_BATCH = 1024
+
+
+def _check(ret, path=None):
+    if ret < 0:
+        if path is None:
+            raise OSError(-ret, os.strerror(-ret))
+        raise OSError(-ret, os.strerror(-ret), path)
+    return ret
+
+
+def _encode(path):
+    if isinstance(path, bytes):
+        return path
+    return os.fspath(path).encode("utf-8")
+
+
+class Client(object):
+    """Client of a volume, configured with the keys of cfs_set_client, e.g.
+
+        with Client(volName="ltptest", masterAddr="192.168.0.11:17010") as c:
+            with c.open("/a.txt", "w") as f:
+                f.write("hello")
+            print(c.listdir("/"))
+    """
+
+    def __init__(self, lib_path=None, **config):
+        self._lib = libcfs.load(lib_path)
+        self._cid = self._lib.cfs_new_client()
+        for key, val in config.items():
+            if isinstance(val, bool):
+                val = "true" if val else "false"
+            ret = self._lib.cfs_set_client(self._cid, _encode(key), _encode(str(val)))
+            if ret < 0:
+                self._lib.cfs_close_client(self._cid)
+                raise ValueError("invalid config {}={}".format(key, val))
+        ret = self._lib.cfs_start_client(self._cid)
+        if ret < 0:
+            self._lib.cfs_close_client(self._cid)
+            _check(ret)
+        self._closed = False
+
+    def close(self):
+        """Closes the client, the files opened should be closed before."""
+        if not self._closed:
+            self._closed = True
+            self._lib.cfs_close_client(self._cid)
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+    def chdir(self, path):
+        _check(self._lib.cfs_chdir(self._cid, _encode(path)), path)
+
+    def getcwd(self):
+        return self._lib.cfs_getcwd(self._cid).decode("utf-8")
+
+    def stat(self, path):
+        """Returns the os.stat_result of the path, the symbolic links are not followed."""
+        info = libcfs.StatInfo()
+        _check(self._lib.cfs_getattr(self._cid, _encode(path), ctypes.byref(info)), path)
+        return os.stat_result((
+            info.mode, info.ino, 0, info.nlink, info.uid, info.gid, info.size,
+            info.atime + info.atime_nsec / 1e9,
+            info.mtime + info.mtime_nsec / 1e9,
+            info.ctime + info.ctime_nsec / 1e9,
+        ))
+
+    def exists(self, path):
+        try:
+            self.stat(path)
+        except OSError as e:
+            if e.errno == errno.ENOENT:
+                return False
+            raise
+        return True
+
+    def isdir(self, path):
+        try:
+            return statmod.S_ISDIR(self.stat(path).st_mode)
+        except OSError as e:
+            if e.errno == errno.ENOENT:
+                return False
+            raise
+
+    def listdir(self, path="."):
+        """Returns the names of the entries in the directory, except "." and ".."."""
+        fd = _check(self._lib.cfs_open(self._cid, _encode(path), os.O_RDONLY, 0), path)
+        try:
+            names = []
+            dents = (libcfs.Dirent * READDIR_BATCH)()
+            slice_ = libcfs.GoSlice(ctypes.cast(dents, ctypes.c_void_p), READDIR_BATCH, READDIR_BATCH)
+            while True:
+                n = _check(self._lib.cfs_readdir(self._cid, fd, slice_, READDIR_BATCH), path)
+                if n == 0:
+                    break
+                for dent in dents[:n]:
+                    name = dent.name.decode("utf-8")
+                    if name not in (".", ".."):
+                        names.append(name)
+            return names
+        finally:
+            self._lib.cfs_close(self._cid, fd)
+
+    def mkdirs(self, path, mode=0o755):
+        _check(self._lib.cfs_mkdirs(self._cid, _encode(path), mode), path)
+
+    def rmdir(self, path):
+        _check(self._lib.cfs_rmdir(self._cid, _encode(path)), path)
+
+    def remove(self, path):
+        _check(self._lib.cfs_unlink(self._cid, _encode(path)), path)
+
+    unlink = remove
+
+    def rename(self, src, dst):
+        _check(self._lib.cfs_rename(self._cid, _encode(src), _encode(dst)), src)
+
+    def chmod(self, path, mode):
+        fd = _check(self._lib.cfs_open(self._cid, _encode(path), os.O_RDONLY, 0), path)
+        try:
+            _check(self._lib.cfs_fchmod(self._cid, fd, mode), path)
+        finally:
+            self._lib.cfs_close(self._cid, fd)
+
+    def open(self, path, mode="r", buffering=-1, encoding=None, errors=None, newline=None):
+        """Opens the file like the builtin open, the modes are r, w, a and x, with b, t and +."""
+        modes = set(mode)
+        if len(modes & set("rwax")) != 1 or not modes <= set("rwaxbt+") or len(mode) != len(modes):
+            raise ValueError("invalid mode: {!r}".format(mode))
+        binary = "b" in modes
+        if binary and (encoding is not None or errors is not None or newline is not None):
+            raise ValueError("binary mode doesn't take an encoding, errors or newline argument")
+
+        flags = 0
+        if "+" in modes:
+            flags |= os.O_RDWR
+        elif "r" in modes:
+            flags |= os.O_RDONLY
+        else:
+            flags |= os.O_WRONLY
+        if "w" in modes:
+            flags |= os.O_CREAT | os.O_TRUNC
+        elif "a" in modes:
+            flags |= os.O_CREAT | os.O_APPEND
+        elif "x" in modes:
+            if self.exists(path):
+                raise OSError(errno.EEXIST, os.strerror(errno.EEXIST), path)
+            flags |= os.O_CREAT
+
+        raw = File(self, path, flags, mode)
+        if buffering == 0:
+            if not binary:
+                raise ValueError("can't have unbuffered text I/O")
+            return raw
+        if buffering < 0:
+            buffering = io.DEFAULT_BUFFER_SIZE
+        if raw.readable() and raw.writable():
+            buf = io.BufferedRandom(raw, buffering)
+        elif raw.readable():
+            buf = io.BufferedReader(raw, buffering)
+        else:
+            buf = io.BufferedWriter(raw, buffering)
+        if binary:
+            return buf
+        return io.TextIOWrapper(buf, encoding or "utf-8", errors, newline)
+
+
+class File(io.RawIOBase):
+    """Raw file of a client, which reads and writes at its own position."""
+
+    def __init__(self, client, path, flags, mode):
+        super(File, self).__init__()
+        self.name = path
+        self.mode = mode
+        self._client = client
+        self._lib = client._lib
+        self._flags = flags
+        self._fd = -1
+        self._pos = 0
+        self._fd = _check(self._lib.cfs_open(client._cid, _encode(path), flags, 0o644), path)
+        if flags & os.O_APPEND:
+            self._pos = client.stat(path).st_size
+
+    def fileno(self):
+        raise io.UnsupportedOperation("the file is not a file of the operating system")
+
+    def readable(self):
+        return self._flags & os.O_ACCMODE != os.O_WRONLY
+
+    def writable(self):
+        return self._flags & os.O_ACCMODE != os.O_RDONLY
+
+    def seekable(self):
+        return True
+
+    def readinto(self, b):
+        self._checkClosed()
+        view = memoryview(b).cast("B")
+        if len(view) == 0:
+            return 0
+        buf = (ctypes.c_char * len(view)).from_buffer(view)
+        n = _check(self._lib.cfs_read(self._client._cid, self._fd, buf, len(view), self._pos), self.name)
+        self._pos += n
+        return n
+
+    def write(self, b):
+        self._checkClosed()
+        data = bytes(b)
+        if len(data) == 0:
+            return 0
+        n = _check(self._lib.cfs_write(self._client._cid, self._fd, data, len(data), self._pos), self.name)
+        self._pos += n
+        return n
+
+    def seek(self, offset, whence=io.SEEK_SET):
+        self._checkClosed()
+        if whence == io.SEEK_SET:
+            pos = offset
+        elif whence == io.SEEK_CUR:
+            pos = self._pos + offset
+        elif whence == io.SEEK_END:
+            pos = self._client.stat(self.name).st_size + offset
+        else:
+            raise ValueError("invalid whence: {}".format(whence))
+        if pos < 0:
+            raise OSError(errno.EINVAL, os.strerror(errno.EINVAL), self.name)
+        self._pos = pos
+        return pos
+
+    def tell(self):
+        return self._pos
+
+    def truncate(self, size=None):
+        raise io.UnsupportedOperation("truncate")
+
+    def flush(self):
+        """Writes the data buffered by libcfs to the data nodes."""
+        if not self.closed and self.writable():
+            _check(self._lib.cfs_flush(self._client._cid, self._fd), self.name)
+
+    def close(self):
+        if self.closed:
+            return
+        try:
+            if self._fd >= 0:
+                self.flush()
+        finally:
+            if self._fd >= 0:
+                self._lib.cfs_close(self._client._cid, self._fd)
+                self._fd = -1
+            super(File, self).close()
@@ -0,0 +1,89 @@
+# Copyright 2020 The ChubaoFS Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+# implied. See the License for the specific language governing
+# permissions and limitations under the License.
+
+"""The bindings of the functions exported by libcfs.so, see libsdk/libsdk.go."""
+
+import ctypes
+import os
+
+
+class StatInfo(ctypes.Structure):
+    # note that the field layout should be aligned with cfs_stat_info
+    _fields_ = [
+        ("ino", ctypes.c_uint64),
+        ("size", ctypes.c_uint64),
+        ("blocks", ctypes.c_uint64),
+        ("atime", ctypes.c_uint64),
+        ("mtime", ctypes.c_uint64),
+        ("ctime", ctypes.c_uint64),
+        ("atime_nsec", ctypes.c_uint32),
+        ("mtime_nsec", ctypes.c_uint32),
+        ("ctime_nsec", ctypes.c_uint32),
+        ("mode", ctypes.c_uint32),
+        ("nlink", ctypes.c_uint32),
+        ("blk_size", ctypes.c_uint32),
+        ("uid", ctypes.c_uint32),
+        ("gid", ctypes.c_uint32),
+    ]
+
+
+class Dirent(ctypes.Structure):
+    # note that the field layout should be aligned with cfs_dirent
+    _fields_ = [
+        ("ino", ctypes.c_uint64),
+        ("name", ctypes.c_char * 256),
+        ("d_type", ctypes.c_char),
+    ]
+
+
+class GoSlice(ctypes.Structure):
+    # note that the field layout should be aligned with GoSlice
+    _fields_ = [
+        ("data", ctypes.c_void_p),
+        ("len", ctypes.c_longlong),
+        ("cap", ctypes.c_longlong),
+    ]
+
+
+def load(path=None):
+    """Loads libcfs.so from the path, or CFS_LIBRARY in the environment by default."""
+    lib = ctypes.CDLL(path or os.environ.get("CFS_LIBRARY", "libcfs.so"))
+
+    c_int64, c_int, c_char_p = ctypes.c_int64, ctypes.c_int, ctypes.c_char_p
+    mode_t, size_t, off_t = ctypes.c_uint32, ctypes.c_size_t, ctypes.c_int64
+
+    def bind(name, restype, *argtypes):
+        fn = getattr(lib, name)
+        fn.restype = restype
+        fn.argtypes = list(argtypes)
+
+    bind("cfs_new_client", c_int64)
+    bind("cfs_set_client", c_int, c_int64, c_char_p, c_char_p)
+    bind("cfs_start_client", c_int, c_int64)
+    bind("cfs_close_client", None, c_int64)
+    bind("cfs_chdir", c_int, c_int64, c_char_p)
+    bind("cfs_getcwd", c_char_p, c_int64)
+    bind("cfs_getattr", c_int, c_int64, c_char_p, ctypes.POINTER(StatInfo))
+    bind("cfs_open", c_int, c_int64, c_char_p, c_int, mode_t)
+    bind("cfs_flush", c_int, c_int64, c_int)
+    bind("cfs_close", None, c_int64, c_int)
+    bind("cfs_write", ctypes.c_ssize_t, c_int64, c_int, ctypes.c_void_p, size_t, off_t)
+    bind("cfs_read", ctypes.c_ssize_t, c_int64, c_int, ctypes.c_void_p, size_t, off_t)
+    bind("cfs_readdir", c_int, c_int64, c_int, GoSlice, c_int)
+    bind("cfs_mkdirs", c_int, c_int64, c_char_p, mode_t)
+    bind("cfs_rmdir", c_int, c_int64, c_char_p)
+    bind("cfs_unlink", c_int, c_int64, c_char_p)
+    bind("cfs_rename", c_int, c_int64, c_char_p, c_char_p)
+    bind("cfs_fchmod", c_int, c_int64, c_int, mode_t)
+    return lib
@@ -0,0 +1,129 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The WAL files of the archived partitions are kept in the archive directory of the WAL by the truncation,
+// and shipped to the external storage by the archiver in the background, so the log entries truncated
+// beyond the retained logs are still available for the point-in-time recovery. The archived WAL files
+// are in the same format as the WAL, and they are named by the sequence and the first index of them.
+
+const (
+	walArchiveDirName  = "archive"
+	walArchiveInterval = 10 * time.Second
+)
+
+// WalArchiver ships the WAL file of the partition to the external storage. The file is removed once
+// shipped, or kept to be shipped again later if failed, and the files of a partition are shipped in order.
+type WalArchiver func(partitionID uint64, file string) error
+
+// DirWalArchiver returns the archiver copying the WAL files into the directory of each partition under
+// the dir, which is usually mounted from the external storage.
+func DirWalArchiver(dir string) WalArchiver {
+	return func(partitionID uint64, file string) (err error) {
+		partitionDir := path.Join(dir, strconv.FormatUint(partitionID, 10))
+		if err = os.MkdirAll(partitionDir, 0755); err != nil {
+			return
+		}
+		dst := path.Join(partitionDir, path.Base(file))
+		tmp := dst + ".tmp"
+		if err = copyFile(file, tmp); err != nil {
+			os.Remove(tmp)
+			return
+		}
+		return os.Rename(tmp, dst)
+	}
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return
+	}
+	return out.Sync()
+}
+
+// archiveWals ships the WAL files of the archived partitions periodically until the store stops.
+func (s *raftStore) archiveWals() {
+	ticker := time.NewTicker(walArchiveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopc:
+			return
+		case <-ticker.C:
+			s.archiveDirs.Range(func(key, value interface{}) bool {
+				if !s.archivePartitionWals(key.(uint64), value.(string)) {
+					s.archiveDirs.Delete(key)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// archivePartitionWals ships the WAL files in the archive directory of the partition in order,
+// and returns false if the directory has been removed with the partition.
+func (s *raftStore) archivePartitionWals(id uint64, dir string) bool {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return s.raftServer == nil || !s.raftServer.Status(id).Stopped
+	}
+	if err != nil {
+		log.LogWarnf("archivePartitionWals: read archive dir fail: partitionID(%v) dir(%v) err(%v)", id, dir, err)
+		return true
+	}
+	// the names of the WAL files are ordered by their sequences
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".log") {
+			continue
+		}
+		file := path.Join(dir, info.Name())
+		if err = s.walArchiver(id, file); err != nil {
+			log.LogWarnf("archivePartitionWals: archive WAL fail: partitionID(%v) file(%v) err(%v)", id, file, err)
+			return true
+		}
+		if err = os.Remove(file); err != nil {
+			log.LogWarnf("archivePartitionWals: remove archived WAL fail: partitionID(%v) file(%v) err(%v)", id, file, err)
+			return true
+		}
+		log.LogInfof("archivePartitionWals: WAL archived: partitionID(%v) file(%v)", id, file)
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRaftStoreArchiveWals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raftstore_archive")
+	if err != nil {
+		t.Fatalf("create temp dir fail: err(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	archiveDir := path.Join(dir, "wal", walArchiveDirName)
+	if err = os.MkdirAll(archiveDir, 0755); err != nil {
+		t.Fatalf("create archive dir fail: err(%v)", err)
+	}
+	names := []string{"0000000000000001-0000000000000001.log", "0000000000000002-0000000000000064.log"}
+	for _, name := range names {
+		if err = ioutil.WriteFile(path.Join(archiveDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("write WAL fail: err(%v)", err)
+		}
+	}
+
+	// The files failed to be shipped are kept
+	var shipped []string
+	s := &raftStore{walArchiver: func(partitionID uint64, file string) error {
+		if len(shipped) == 1 {
+			return fmt.Errorf("unavailable")
+		}
+		shipped = append(shipped, path.Base(file))
+		return nil
+	}}
+	if !s.archivePartitionWals(1, archiveDir) {
+		t.Fatalf("archive dir should be kept")
+	}
+	if len(shipped) != 1 || shipped[0] != names[0] {
+		t.Fatalf("unexpected shipped files: %v", shipped)
+	}
+	if _, err = os.Stat(path.Join(archiveDir, names[0])); !os.IsNotExist(err) {
+		t.Fatalf("shipped WAL should be removed: err(%v)", err)
+	}
+
+	s.walArchiver = DirWalArchiver(path.Join(dir, "external"))
+	s.archivePartitionWals(1, archiveDir)
+	data, err := ioutil.ReadFile(path.Join(dir, "external", "1", names[1]))
+	if err != nil || string(data) != names[1] {
+		t.Fatalf("WAL not archived: data(%s) err(%v)", data, err)
+	}
+	if infos, _ := ioutil.ReadDir(archiveDir); len(infos) != 0 {
+		t.Fatalf("archive dir should be empty: files(%v)", len(infos))
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/tiglabs/raft"
+)
+
+// The commands submitted at the same time are batched in one raft log entry, so the raft overhead of
+// the log entry, the replication and the apply is paid once for all of them. The batches are proposed
+// without waiting for the previous ones to be applied, and the raft applies the committed log entries
+// by its own worker of each partition, so the proposals, the replication and the apply are pipelined.
+
+const (
+	// maxProposalBatchBytes limits the size of the commands batched in one raft log entry.
+	maxProposalBatchBytes = 1 << 20
+	// proposalQueueSize is the number of the commands waiting to be batched in each partition.
+	proposalQueueSize = 1024
+)
+
+// proposalBatchMagic begins the raft log entry of the batch, which no command begins with.
+var proposalBatchMagic = []byte{0xcf, 0x5b, 0xa7, 0xc4, 0x0b, 0x8d, 0x3e, 0x91}
+
+type proposal struct {
+	cmd  []byte
+	resp chan *proposalResult
+}
+
+type proposalResult struct {
+	resp interface{}
+	err  error
+}
+
+// proposalBatcher batches the commands submitted to a partition.
+type proposalBatcher struct {
+	id       uint64
+	raft     *raft.RaftServer
+	maxBatch int
+	propc    chan *proposal
+	stopc    chan struct{}
+	mu       sync.RWMutex
+	stopped  bool
+}
+
+func newProposalBatcher(id uint64, raft *raft.RaftServer, maxBatch int) *proposalBatcher {
+	b := &proposalBatcher{
+		id:       id,
+		raft:     raft,
+		maxBatch: maxBatch,
+		propc:    make(chan *proposal, proposalQueueSize),
+		stopc:    make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// submit queues the command to be batched, and waits for the result of applying it.
+func (b *proposalBatcher) submit(cmd []byte) (resp interface{}, err error) {
+	pr := &proposal{cmd: cmd, resp: make(chan *proposalResult, 1)}
+	// The queued commands are either proposed or drained after stopping
+	b.mu.RLock()
+	if b.stopped {
+		b.mu.RUnlock()
+		return nil, raft.ErrStopped
+	}
+	b.propc <- pr
+	b.mu.RUnlock()
+	result := <-pr.resp
+	return result.resp, result.err
+}
+
+func (b *proposalBatcher) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.stopped {
+		b.stopped = true
+		close(b.stopc)
+	}
+}
+
+func (b *proposalBatcher) run() {
+	for {
+		select {
+		case <-b.stopc:
+			for {
+				select {
+				case pr := <-b.propc:
+					pr.resp <- &proposalResult{err: raft.ErrStopped}
+				default:
+					return
+				}
+			}
+		case pr := <-b.propc:
+			batch := []*proposal{pr}
+			size := len(pr.cmd)
+		collect:
+			for len(batch) < b.maxBatch && size < maxProposalBatchBytes {
+				select {
+				case pr = <-b.propc:
+					batch = append(batch, pr)
+					size += len(pr.cmd)
+				default:
+					break collect
+				}
+			}
+			b.propose(batch)
+		}
+	}
+}
+
+// propose submits the batch to the raft without waiting for it to be applied.
+// The single command is submitted as it is.
+func (b *proposalBatcher) propose(batch []*proposal) {
+	if len(batch) == 1 {
+		future := b.raft.Submit(b.id, batch[0].cmd)
+		go func() {
+			resp, err := future.Response()
+			batch[0].resp <- &proposalResult{resp: resp, err: err}
+		}()
+		return
+	}
+
+	cmds := make([][]byte, 0, len(batch))
+	for _, pr := range batch {
+		cmds = append(cmds, pr.cmd)
+	}
+	future := b.raft.Submit(b.id, encodeProposalBatch(cmds))
+	go func() {
+		resp, err := future.Response()
+		results, ok := resp.([]*proposalResult)
+		if err == nil && (!ok || len(results) != len(batch)) {
+			err = fmt.Errorf("mismatched results of proposal batch: partition(%v) commands(%v) results(%v)", b.id, len(batch), resp)
+		}
+		for i, pr := range batch {
+			if err != nil {
+				pr.resp <- &proposalResult{err: err}
+				continue
+			}
+			pr.resp <- results[i]
+		}
+	}()
+}
+
+func encodeProposalBatch(cmds [][]byte) []byte {
+	size := len(proposalBatchMagic) + 4
+	for _, cmd := range cmds {
+		size += 4 + len(cmd)
+	}
+	data := make([]byte, size)
+	off := copy(data, proposalBatchMagic)
+	binary.BigEndian.PutUint32(data[off:], uint32(len(cmds)))
+	off += 4
+	for _, cmd := range cmds {
+		binary.BigEndian.PutUint32(data[off:], uint32(len(cmd)))
+		off += 4
+		off += copy(data[off:], cmd)
+	}
+	return data
+}
+
+// decodeProposalBatch returns the commands of the batch, or false if the data is not a batch.
+func decodeProposalBatch(data []byte) (cmds [][]byte, ok bool, err error) {
+	if !bytes.HasPrefix(data, proposalBatchMagic) {
+		return nil, false, nil
+	}
+	data = data[len(proposalBatchMagic):]
+	if len(data) < 4 {
+		return nil, true, fmt.Errorf("truncated proposal batch header")
+	}
+	count := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	cmds = make([][]byte, 0, count)
+	for i := uint32(0); i < count; i++ {
+		if len(data) < 4 {
+			return nil, true, fmt.Errorf("truncated proposal batch command(%v)", i)
+		}
+		size := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint32(len(data)) < size {
+			return nil, true, fmt.Errorf("truncated proposal batch command(%v)", i)
+		}
+		cmds = append(cmds, data[:size])
+		data = data[size:]
+	}
+	return cmds, true, nil
+}
+
+// batchFsm applies the commands of the batches one by one by the state machine of the partition,
+// and the results of them are returned together as the result of the batch.
+// It applies the batches whether the batching is enabled on this node or not, since the leader
+// of the partition decides how the commands are proposed.
+type batchFsm struct {
+	PartitionFsm
+}
+
+func (f *batchFsm) Apply(command []byte, index uint64) (resp interface{}, err error) {
+	cmds, ok, err := decodeProposalBatch(command)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f.PartitionFsm.Apply(command, index)
+	}
+	results := make([]*proposalResult, 0, len(cmds))
+	for _, cmd := range cmds {
+		result := &proposalResult{}
+		result.resp, result.err = f.PartitionFsm.Apply(cmd, index)
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type applyRecorder struct {
+	PartitionFsm
+	applied [][]byte
+}
+
+func (r *applyRecorder) Apply(command []byte, index uint64) (resp interface{}, err error) {
+	r.applied = append(r.applied, command)
+	if bytes.Equal(command, []byte("fail")) {
+		return nil, errors.New("apply fail")
+	}
+	return fmt.Sprintf("%s@%d", command, index), nil
+}
+
+func TestBatchFsmApply(t *testing.T) {
+	recorder := &applyRecorder{}
+	fsm := &batchFsm{PartitionFsm: recorder}
+
+	resp, err := fsm.Apply([]byte("single"), 1)
+	if err != nil || resp != "single@1" {
+		t.Fatalf("apply single command mismatch: resp(%v) err(%v)", resp, err)
+	}
+
+	resp, err = fsm.Apply(encodeProposalBatch([][]byte{[]byte("a"), []byte("fail"), {}}), 2)
+	if err != nil {
+		t.Fatalf("apply batch fail: err(%v)", err)
+	}
+	results, ok := resp.([]*proposalResult)
+	if !ok || len(results) != 3 {
+		t.Fatalf("batch results mismatch: resp(%v)", resp)
+	}
+	if results[0].resp != "a@2" || results[0].err != nil || results[1].err == nil || results[2].resp != "@2" {
+		t.Fatalf("batch results mismatch: %v %v %v", results[0], results[1], results[2])
+	}
+	if len(recorder.applied) != 4 {
+		t.Fatalf("applied commands mismatch: %v", recorder.applied)
+	}
+
+	if _, err = fsm.Apply(encodeProposalBatch([][]byte{[]byte("a")})[:len(proposalBatchMagic)+6], 3); err == nil {
+		t.Fatalf("truncated batch should be rejected")
+	}
+}
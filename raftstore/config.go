@@ -29,6 +29,10 @@ const (
 	DefaultElectionTick      = 3
 )
 
+// MaxLearnerLagToPromote is the number of log entries by which a learner may lag behind the
+// commit of the leader at most to be promoted.
+const MaxLearnerLagToPromote = 1000
+
 // Config defines the configuration properties for the raft store.
 type Config struct {
 	NodeID            uint64 // Identity of raft server instance.
@@ -52,6 +56,67 @@ type Config struct {
 	// We suggest to use ElectionTick = 10 * HeartbeatTick to avoid unnecessary leader switching.
 	// The default value is 1s.
 	ElectionTick int
+
+	// PreVote enables the pre-vote phase of the elections, so a partitioned or restarted node
+	// rejoining does not bump the term and force the leader of its partitions to step down.
+	// It should be enabled only after all the nodes of the cluster support it.
+	PreVote bool
+
+	// WalFileSize is the size of each WAL segment file of the partitions, unit is byte.
+	// Smaller segments are truncated sooner after the snapshots, and larger ones open fewer files.
+	// The default value is 32MB.
+	WalFileSize int
+
+	// WalCompression enables the compression of the data of the log entries in the WAL,
+	// which trades the cpu for the disk usage and the replay time of the write-heavy partitions.
+	// The WAL written with it enabled cannot be read by the nodes not supporting it.
+	WalCompression bool
+
+	// WalRepair truncates the WAL of the partitions at the first corrupt record found by the replay,
+	// which drops the log entries after it. Without it the partition with the corrupt WAL fails to start,
+	// except the torn record at the tail left by the unclean shutdown, which is always truncated.
+	// It should be enabled only by the operator confirming the loss, and disabled after the repair.
+	WalRepair bool
+
+	// WalDirs places the WALs of the partitions of each group, named by PartitionConfig.WalGroup, in the
+	// directory of the group, e.g. on the NVMe device while the data and the snapshots stay on the HDD.
+	// The partitions of the groups not configured place the WALs in their WalPath or the RaftPath.
+	WalDirs map[string]string
+
+	// WalArchiver ships the WAL files truncated from the partitions enabling PartitionConfig.WalArchive
+	// to the external storage, so the log entries beyond NumOfLogsToRetain are kept for the point-in-time
+	// recovery. The WAL files are not archived if it is nil.
+	WalArchiver WalArchiver
+
+	// ProposalBatchSize is the max number of the commands submitted at the same time to a partition,
+	// which are batched in one raft log entry. The batches cannot be applied by the nodes not supporting it,
+	// so it should be enabled only after all the nodes of the cluster support it.
+	// The default value is 0, which means the commands are not batched.
+	ProposalBatchSize int
+
+	// ApplyQueueSize is the max number of the committed log entries of each partition waiting for the
+	// apply worker of the partition, so the replication goes on while the state machine applies the log.
+	// The default value is 2048.
+	ApplyQueueSize int
+
+	// SnapshotRateLimit limits the rate of sending and receiving the snapshots of all the partitions
+	// respectively, unit is byte per second, so seeding a node does not saturate the replica port.
+	// It can be changed by RaftStore.SetSnapshotRateLimit at runtime.
+	// The default value is 0, which means no limit.
+	SnapshotRateLimit int64
+
+	// ReplicaIPOf maps the ip of a peer to the one of its dedicated replication network,
+	// the raft messages go to the ip of the peer as is if it is nil.
+	ReplicaIPOf func(ip string) string
+
+	// TLSCertFile, TLSKeyFile and TLSCAFile enable the mutual TLS on the heartbeat and replication
+	// connections, by which each node presents its certificate and verifies the one of its peer against the CA.
+	// The certificates must be valid for the IP addresses of the nodes, and they are rotated by replacing
+	// the certificate file and the key file, which are reloaded by the next connections.
+	// All the nodes of the cluster must enable it at the same time.
+	TLSCertFile string
+	TLSKeyFile  string
+	TLSCAFile   string
 }
 
 // PeerAddress defines the set of addresses that will be used by the peers.
@@ -69,8 +134,43 @@ type PartitionConfig struct {
 	Leader  uint64
 	Term    uint64
 	Peers   []PeerAddress
-	SM      PartitionFsm
-	WalPath string
+	// Learners are the IDs of the peers which replicate the log but neither vote nor count in
+	// the quorum, each of which must be in Peers as well.
+	Learners []uint64
+	// Witnesses are the IDs of the peers which vote and store the log but apply no command,
+	// each of which must be in Peers as well. The partition on the witness drops the commands.
+	Witnesses []uint64
+	// Priorities are the leader priorities of the peers by their IDs, which are 0 if not given.
+	// The leader transfers the leadership to the caught up voter with a higher priority than its own.
+	Priorities map[uint64]uint16
+	SM         PartitionFsm
+	WalPath    string
+	// WalArchive archives the WAL files of the partition by Config.WalArchiver before truncating them.
+	WalArchive bool
+	// WalGroup is the group of the partition, whose WAL is placed in the directory of the group by Config.WalDirs.
+	WalGroup string
+}
+
+// raftPeers returns the peers of the raft group, of which the learners, the witnesses and the priorities are marked.
+func (c *PartitionConfig) raftPeers() []proto.Peer {
+	peers := make([]proto.Peer, 0, len(c.Peers))
+	for _, peerAddress := range c.Peers {
+		peer := peerAddress.Peer
+		for _, learner := range c.Learners {
+			if learner == peer.ID {
+				peer.Type = proto.PeerLearner
+				break
+			}
+		}
+		if c.isWitness(peer.ID) {
+			peer.Type = proto.PeerWitness
+		}
+		if priority, ok := c.Priorities[peer.ID]; ok {
+			peer.Priority = priority
+		}
+		peers = append(peers, peer)
+	}
+	return peers
 }
 
 func (p PeerAddress) String() string {
@@ -0,0 +1,45 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"testing"
+
+	"github.com/tiglabs/raft/proto"
+)
+
+func TestPartitionConfigRaftPeers(t *testing.T) {
+	cfg := &PartitionConfig{
+		Peers: []PeerAddress{
+			{Peer: proto.Peer{ID: 1}},
+			{Peer: proto.Peer{ID: 2}},
+			{Peer: proto.Peer{ID: 3}},
+		},
+		Learners:  []uint64{3},
+		Witnesses: []uint64{2},
+	}
+	peers := cfg.raftPeers()
+	if len(peers) != 3 {
+		t.Fatalf("peers mismatch: expect(3) actual(%v)", len(peers))
+	}
+	for _, peer := range peers {
+		if peer.IsLearner() != (peer.ID == 3) {
+			t.Fatalf("learner mismatch: peer(%v)", peer)
+		}
+		if peer.IsWitness() != (peer.ID == 2) {
+			t.Fatalf("witness mismatch: peer(%v)", peer)
+		}
+	}
+}
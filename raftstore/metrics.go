@@ -0,0 +1,79 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/tiglabs/raft"
+)
+
+// The raft metrics of the partitions, labeled by the partition id.
+const (
+	metricRaftProposal          = "raftProposal"          // latency of the proposals submitted by the leader
+	metricRaftCommitLag         = "raftCommitLag"         // log entries not committed yet
+	metricRaftApplyLag          = "raftApplyLag"          // log entries committed but not applied yet
+	metricRaftLeaderChanges     = "raftLeaderChanges"     // leader changes since the partition started
+	metricRaftDroppedMessages   = "raftDroppedMessages"   // messages dropped since the partition started
+	metricRaftSnapshotsSent     = "raftSnapshotsSent"     // snapshots sent since the partition started
+	metricRaftSnapshotsReceived = "raftSnapshotsReceived" // snapshots received since the partition started
+)
+
+const metricsCollectInterval = time.Minute
+
+func partitionMetricLabels(id uint64) map[string]string {
+	return map[string]string{"partid": strconv.FormatUint(id, 10)}
+}
+
+// collectMetrics exports the raft metrics of the partitions periodically until the store stops.
+func (s *raftStore) collectMetrics() {
+	ticker := time.NewTicker(metricsCollectInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopc:
+			return
+		case <-ticker.C:
+			s.partitions.Range(func(key, _ interface{}) bool {
+				status := s.raftServer.Status(key.(uint64))
+				if status.Stopped {
+					s.partitions.Delete(key)
+					return true
+				}
+				exportPartitionMetrics(status)
+				return true
+			})
+		}
+	}
+}
+
+func exportPartitionMetrics(status *raft.Status) {
+	labels := partitionMetricLabels(status.ID)
+	var commitLag, applyLag uint64
+	if status.Index > status.Commit {
+		commitLag = status.Index - status.Commit
+	}
+	if status.Commit > status.Applied {
+		applyLag = status.Commit - status.Applied
+	}
+	exporter.NewGauge(metricRaftCommitLag).SetWithLabels(float64(commitLag), labels)
+	exporter.NewGauge(metricRaftApplyLag).SetWithLabels(float64(applyLag), labels)
+	exporter.NewGauge(metricRaftLeaderChanges).SetWithLabels(float64(status.LeaderChanges), labels)
+	exporter.NewGauge(metricRaftDroppedMessages).SetWithLabels(float64(status.DroppedMessages), labels)
+	exporter.NewGauge(metricRaftSnapshotsSent).SetWithLabels(float64(status.SnapshotsSent), labels)
+	exporter.NewGauge(metricRaftSnapshotsReceived).SetWithLabels(float64(status.SnapshotsReceived), labels)
+}
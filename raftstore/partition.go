@@ -15,8 +15,10 @@
 package raftstore
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/tiglabs/raft"
 	"github.com/tiglabs/raft/proto"
 )
@@ -40,6 +42,30 @@ type Partition interface {
 	// ChaneMember submits member change event and information to raft log.
 	ChangeMember(changeType proto.ConfChangeType, peer proto.Peer, context []byte) (resp interface{}, err error)
 
+	// ChangeMembers submits several member changes applied at once through the joint configuration,
+	// so replacing more than one replica never passes through the intermediate configurations.
+	ChangeMembers(changes []*proto.ConfChange) (resp interface{}, err error)
+
+	// AddLearner submits the member change adding the peer as a learner, which replicates the log
+	// but neither votes nor counts in the quorum until it is promoted.
+	AddLearner(peer proto.Peer, context []byte) (resp interface{}, err error)
+
+	// AddWitness submits the member change adding the peer as a witness, which votes and stores the log
+	// but applies no command, so it never becomes the leader.
+	AddWitness(peer proto.Peer, context []byte) (resp interface{}, err error)
+
+	// PromoteLearner submits the member change turning the learner into a voter, once it has
+	// caught up with the leader.
+	PromoteLearner(peer proto.Peer, context []byte) (resp interface{}, err error)
+
+	// CanPromoteLearner checks if the learner has caught up with the leader. It must be called on
+	// the leader, which is the only one tracking the replication progress.
+	CanPromoteLearner(nodeID uint64) error
+
+	// SetSnapshotRateLimit limits the rate of the snapshot transfer of this partition besides the
+	// limit of the raft store, in bytes per second. Non-positive rate means no limit.
+	SetSnapshotRateLimit(bytesPerSec int64)
+
 	// Stop removes the raft partition from raft server and shuts down this partition.
 	Stop() error
 
@@ -66,6 +92,10 @@ type Partition interface {
 
 	TryToLeader(nodeID uint64) error
 
+	// TransferLeader transfers the leadership to the voter on the node once it catches up with the leader.
+	// It must be called on the leader, and no command is accepted until the transfer completes or times out.
+	TransferLeader(nodeID uint64) error
+
 	IsOfflinePeer() bool
 }
 
@@ -75,6 +105,7 @@ type partition struct {
 	raft    *raft.RaftServer
 	walPath string
 	config  *PartitionConfig
+	batcher *proposalBatcher
 }
 
 // ChaneMember submits member change event and information to raft log.
@@ -89,9 +120,70 @@ func (p *partition) ChangeMember(changeType proto.ConfChangeType, peer proto.Pee
 	return
 }
 
+// ChangeMembers submits several member changes applied at once through the joint configuration.
+func (p *partition) ChangeMembers(changes []*proto.ConfChange) (resp interface{}, err error) {
+	if !p.IsRaftLeader() {
+		err = raft.ErrNotLeader
+		return
+	}
+	future := p.raft.ChangeMembers(p.id, changes)
+	resp, err = future.Response()
+	return
+}
+
+// AddLearner submits the member change adding the peer as a learner.
+func (p *partition) AddLearner(peer proto.Peer, context []byte) (resp interface{}, err error) {
+	peer.Type = proto.PeerLearner
+	return p.ChangeMember(proto.ConfAddNode, peer, context)
+}
+
+// AddWitness submits the member change adding the peer as a witness.
+func (p *partition) AddWitness(peer proto.Peer, context []byte) (resp interface{}, err error) {
+	peer.Type = proto.PeerWitness
+	return p.ChangeMember(proto.ConfAddNode, peer, context)
+}
+
+// PromoteLearner submits the member change turning the learner into a voter.
+func (p *partition) PromoteLearner(peer proto.Peer, context []byte) (resp interface{}, err error) {
+	if err = p.CanPromoteLearner(peer.ID); err != nil {
+		return
+	}
+	peer.Type = proto.PeerNormal
+	return p.ChangeMember(proto.ConfUpdateNode, peer, context)
+}
+
+// CanPromoteLearner checks if the learner has caught up with the leader.
+func (p *partition) CanPromoteLearner(nodeID uint64) (err error) {
+	if !p.IsRaftLeader() {
+		return raft.ErrNotLeader
+	}
+	status := p.Status()
+	replica, ok := status.Replicas[nodeID]
+	if !ok {
+		return fmt.Errorf("no replication progress of node(%v), partition(%v) leader(%v)", nodeID, p.id, status.Leader)
+	}
+	if !replica.IsLearner {
+		return fmt.Errorf("node(%v) is not a learner of partition(%v)", nodeID, p.id)
+	}
+	if replica.Snapshoting || replica.Match+MaxLearnerLagToPromote < status.Commit {
+		return fmt.Errorf("learner(%v) has not caught up, match(%v) commit(%v) snapshoting(%v)",
+			nodeID, replica.Match, status.Commit, replica.Snapshoting)
+	}
+	return
+}
+
+// SetSnapshotRateLimit limits the rate of the snapshot transfer of this partition.
+func (p *partition) SetSnapshotRateLimit(bytesPerSec int64) {
+	p.raft.SetGroupSnapshotRateLimit(p.id, bytesPerSec)
+}
+
 // Stop removes the raft partition from raft server and shuts down this partition.
 func (p *partition) Stop() (err error) {
+	p.raft.SetGroupSnapshotRateLimit(p.id, 0)
 	err = p.raft.RemoveRaft(p.id)
+	if p.batcher != nil {
+		p.batcher.stop()
+	}
 	return
 }
 
@@ -101,6 +193,17 @@ func (p *partition) TryToLeader(nodeID uint64) (err error) {
 	return
 }
 
+// TransferLeader transfers the leadership to the voter on the node.
+func (p *partition) TransferLeader(nodeID uint64) (err error) {
+	if !p.IsRaftLeader() {
+		err = raft.ErrNotLeader
+		return
+	}
+	future := p.raft.TransferLeader(p.id, nodeID)
+	_, err = future.Response()
+	return
+}
+
 // Delete stops and deletes the partition.
 func (p *partition) Delete() (err error) {
 	if err = p.Stop(); err != nil {
@@ -131,6 +234,10 @@ func (p *partition) IsOfflinePeer() bool {
 	active := 0
 	sumPeers := 0
 	for _, peer := range status.Replicas {
+		// the learners do not count in the quorum
+		if peer.IsLearner {
+			continue
+		}
 		if peer.Active == true {
 			active++
 		}
@@ -164,8 +271,14 @@ func (p *partition) Submit(cmd []byte) (resp interface{}, err error) {
 		err = raft.ErrNotLeader
 		return
 	}
-	future := p.raft.Submit(p.id, cmd)
-	resp, err = future.Response()
+	tp := exporter.NewTP(metricRaftProposal)
+	if p.batcher != nil {
+		resp, err = p.batcher.submit(cmd)
+	} else {
+		future := p.raft.Submit(p.id, cmd)
+		resp, err = future.Response()
+	}
+	tp.SetWithLabels(partitionMetricLabels(p.id))
 	return
 }
 
@@ -176,11 +289,15 @@ func (p *partition) Truncate(index uint64) {
 	}
 }
 
-func newPartition(cfg *PartitionConfig, raft *raft.RaftServer, walPath string) Partition {
-	return &partition{
+func newPartition(cfg *PartitionConfig, raft *raft.RaftServer, walPath string, maxBatch int) Partition {
+	p := &partition{
 		id:      cfg.ID,
 		raft:    raft,
 		walPath: walPath,
 		config:  cfg,
 	}
+	if maxBatch > 1 {
+		p.batcher = newProposalBatcher(cfg.ID, raft, maxBatch)
+	}
+	return p
 }
@@ -20,11 +20,11 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/tiglabs/raft"
 	"github.com/tiglabs/raft/logger"
-	"github.com/tiglabs/raft/proto"
 	"github.com/tiglabs/raft/storage/wal"
 	raftlog "github.com/tiglabs/raft/util/log"
 )
@@ -37,6 +37,9 @@ type RaftStore interface {
 	RaftStatus(raftID uint64) (raftStatus *raft.Status)
 	NodeManager
 	RaftServer() *raft.RaftServer
+	// SetSnapshotRateLimit limits the rate of sending and receiving the snapshots of all the partitions
+	// respectively, in bytes per second. Non-positive rate means no limit.
+	SetSnapshotRateLimit(bytesPerSec int64)
 }
 
 type raftStore struct {
@@ -45,6 +48,16 @@ type raftStore struct {
 	raftConfig *raft.Config
 	raftServer *raft.RaftServer
 	raftPath   string
+	walConfig  wal.Config
+	walDirs    map[string]string
+	// walArchiver ships the archived WAL files of the partitions in archiveDirs
+	walArchiver WalArchiver
+	archiveDirs sync.Map // id -> archive directory of the WAL
+	// proposalBatch is the max number of the commands batched in one raft log entry
+	proposalBatch int
+	partitions    sync.Map // id -> struct{}, the partitions whose metrics are exported
+	stopOnce      sync.Once
+	stopc         chan struct{}
 }
 
 // RaftConfig returns the raft configuration.
@@ -68,6 +81,9 @@ func (s *raftStore) DeleteNode(nodeID uint64) {
 
 // Stop stops the raft store server.
 func (s *raftStore) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopc)
+	})
 	if s.raftServer != nil {
 		s.raftServer.Stop()
 	}
@@ -96,7 +112,7 @@ func newRaftLogger(dir string) {
 
 // NewRaftStore returns a new raft store instance.
 func NewRaftStore(cfg *Config) (mr RaftStore, err error) {
-	resolver := NewNodeResolver()
+	resolver := newReplicaNodeResolver(cfg.ReplicaIPOf)
 
 	// 设置raft的log日志存储路径
 	newRaftLogger(cfg.RaftPath)
@@ -104,6 +120,8 @@ func NewRaftStore(cfg *Config) (mr RaftStore, err error) {
 	rc := raft.DefaultConfig()
 	rc.NodeID = cfg.NodeID
 	rc.LeaseCheck = true
+	rc.PreVote = cfg.PreVote
+	rc.SnapshotRateLimit = cfg.SnapshotRateLimit
 	// 此处检查的值与前面的检查值1024不一致
 	if cfg.HeartbeatPort <= 0 {
 		cfg.HeartbeatPort = DefaultHeartbeatPort
@@ -131,17 +149,39 @@ func NewRaftStore(cfg *Config) (mr RaftStore, err error) {
 	rc.RetainLogs = cfg.NumOfLogsToRetain
 	rc.TickInterval = time.Duration(cfg.TickInterval) * time.Millisecond
 	rc.ElectionTick = cfg.ElectionTick
+	if cfg.ApplyQueueSize > 0 {
+		rc.AppBufferSize = cfg.ApplyQueueSize
+	}
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" || cfg.TLSCAFile != "" {
+		if rc.TLSConfig, err = newTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSCAFile); err != nil {
+			return
+		}
+	}
 	rs, err := raft.NewRaftServer(rc)
 	if err != nil {
 		return
 	}
-	mr = &raftStore{
-		nodeID:     cfg.NodeID,
-		resolver:   resolver,
-		raftConfig: rc,
-		raftServer: rs,
-		raftPath:   cfg.RaftPath,
+	walConfig := wal.Config{FileSize: cfg.WalFileSize, RepairCorrupt: cfg.WalRepair}
+	if cfg.WalCompression {
+		walConfig.Compression = wal.CompressionFlate
+	}
+	store := &raftStore{
+		nodeID:        cfg.NodeID,
+		resolver:      resolver,
+		raftConfig:    rc,
+		raftServer:    rs,
+		raftPath:      cfg.RaftPath,
+		walConfig:     walConfig,
+		walDirs:       cfg.WalDirs,
+		proposalBatch: cfg.ProposalBatchSize,
+		walArchiver:   cfg.WalArchiver,
+		stopc:         make(chan struct{}),
 	}
+	go store.collectMetrics()
+	if store.walArchiver != nil {
+		go store.archiveWals()
+	}
+	mr = store
 	return
 }
 
@@ -149,6 +189,35 @@ func (s *raftStore) RaftServer() *raft.RaftServer {
 	return s.raftServer
 }
 
+// SetSnapshotRateLimit limits the rate of the snapshot transfer of all the partitions.
+func (s *raftStore) SetSnapshotRateLimit(bytesPerSec int64) {
+	s.raftServer.SetSnapshotRateLimit(bytesPerSec)
+}
+
+// walPath returns the WAL path of the partition, which is in the directory of its group if configured.
+// The existing WAL is kept where it is, since it is not moved across the devices by changing the directory.
+func (s *raftStore) walPath(cfg *PartitionConfig) string {
+	var walPath string
+	if cfg.WalPath == "" {
+		walPath = path.Join(s.raftPath, strconv.FormatUint(cfg.ID, 10))
+	} else {
+		walPath = path.Join(cfg.WalPath, "wal_"+strconv.FormatUint(cfg.ID, 10))
+	}
+	dir, ok := s.walDirs[cfg.WalGroup]
+	if !ok || cfg.WalGroup == "" {
+		return walPath
+	}
+	groupPath := path.Join(dir, "wal_"+strconv.FormatUint(cfg.ID, 10))
+	if _, err := os.Stat(groupPath); os.IsNotExist(err) {
+		if _, err = os.Stat(walPath); err == nil {
+			logger.Warn("action[raftstore:walPath] partition(%v) keeps the existing WAL at %v instead of %v of group %v",
+				cfg.ID, walPath, groupPath, cfg.WalGroup)
+			return walPath
+		}
+	}
+	return groupPath
+}
+
 // CreatePartition creates a new partition in the raft store.
 func (s *raftStore) CreatePartition(cfg *PartitionConfig) (p Partition, err error) {
 	// Init WaL Storage for this partition.
@@ -156,21 +225,17 @@ func (s *raftStore) CreatePartition(cfg *PartitionConfig) (p Partition, err erro
 	// wc: WaL Configuration.
 	// wp: WaL Path.
 	// ws: WaL Storage.
-	var walPath string
-	if cfg.WalPath == "" {
-		walPath = path.Join(s.raftPath, strconv.FormatUint(cfg.ID, 10))
-	} else {
-		walPath = path.Join(cfg.WalPath, "wal_"+strconv.FormatUint(cfg.ID, 10))
+	walPath := s.walPath(cfg)
+	wc := s.walConfig
+	if cfg.WalArchive && s.walArchiver != nil {
+		wc.ArchiveDir = path.Join(walPath, walArchiveDirName)
 	}
-
-	wc := &wal.Config{}
-	ws, err := wal.NewStorage(walPath, wc)
+	ws, err := wal.NewStorage(walPath, &wc)
 	if err != nil {
 		return
 	}
-	peers := make([]proto.Peer, 0)
+	peers := cfg.raftPeers()
 	for _, peerAddress := range cfg.Peers {
-		peers = append(peers, peerAddress.Peer)
 		s.AddNodeWithPort(
 			peerAddress.ID,
 			peerAddress.Address,
@@ -179,19 +244,27 @@ func (s *raftStore) CreatePartition(cfg *PartitionConfig) (p Partition, err erro
 		)
 	}
 	logger.Info("action[raftstore:CreatePartition] raft config applied [%v] id:%d", cfg.Applied, cfg.ID)
+	var sm PartitionFsm = &batchFsm{PartitionFsm: cfg.SM}
+	if cfg.isWitness(s.nodeID) {
+		sm = &witnessFsm{PartitionFsm: cfg.SM, id: cfg.ID, raft: s.raftServer}
+	}
 	rc := &raft.RaftConfig{
 		ID:           cfg.ID,
 		Peers:        peers,
 		Leader:       cfg.Leader,
 		Term:         cfg.Term,
 		Storage:      ws,
-		StateMachine: cfg.SM,
+		StateMachine: sm,
 		Applied:      cfg.Applied,
 		Monitor:      newMonitor(),
 	}
 	if err = s.raftServer.CreateRaft(rc); err != nil {
 		return
 	}
-	p = newPartition(cfg, s.raftServer, walPath)
+	p = newPartition(cfg, s.raftServer, walPath, s.proposalBatch)
+	s.partitions.Store(cfg.ID, struct{}{})
+	if wc.ArchiveDir != "" {
+		s.archiveDirs.Store(cfg.ID, wc.ArchiveDir)
+	}
 	return
 }
@@ -0,0 +1,58 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRaftStoreWalPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raftstore_wal_path")
+	if err != nil {
+		t.Fatalf("create temp dir fail: err(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &raftStore{
+		raftPath: path.Join(dir, "raft"),
+		walDirs:  map[string]string{"/disk1": path.Join(dir, "nvme")},
+	}
+	cases := []struct {
+		cfg    *PartitionConfig
+		expect string
+	}{
+		{&PartitionConfig{ID: 1}, path.Join(dir, "raft", "1")},
+		{&PartitionConfig{ID: 2, WalPath: path.Join(dir, "dp_2")}, path.Join(dir, "dp_2", "wal_2")},
+		{&PartitionConfig{ID: 3, WalPath: path.Join(dir, "dp_3"), WalGroup: "/disk1"}, path.Join(dir, "nvme", "wal_3")},
+		{&PartitionConfig{ID: 4, WalPath: path.Join(dir, "dp_4"), WalGroup: "/disk2"}, path.Join(dir, "dp_4", "wal_4")},
+	}
+	for i, c := range cases {
+		if walPath := s.walPath(c.cfg); walPath != c.expect {
+			t.Fatalf("case %v: wal path mismatch: expect(%v) actual(%v)", i, c.expect, walPath)
+		}
+	}
+
+	// The existing WAL is not moved to the directory of the group
+	existing := path.Join(dir, "dp_5", "wal_5")
+	if err = os.MkdirAll(existing, 0755); err != nil {
+		t.Fatalf("create wal dir fail: err(%v)", err)
+	}
+	if walPath := s.walPath(&PartitionConfig{ID: 5, WalPath: path.Join(dir, "dp_5"), WalGroup: "/disk1"}); walPath != existing {
+		t.Fatalf("existing wal path mismatch: expect(%v) actual(%v)", existing, walPath)
+	}
+}
@@ -31,8 +31,9 @@ var (
 
 // This private struct defines the necessary properties for node address info.
 type nodeAddress struct {
-	Heartbeat string
-	Replicate string
+	IP        string
+	Heartbeat int
+	Replicate int
 }
 
 // NodeManager defines the necessary methods for node address management.
@@ -56,6 +57,8 @@ type nodeResolver struct {
 	// 这里使用一种较高并发的map进行存储，提供线程安全的读写
 	// 这个map里存储的是所有节点信息
 	nodeMap sync.Map
+	// maps the ip of a node to the one of its replication network, nil to use the ip as is
+	replicaIPOf func(ip string) string
 }
 
 // NodeAddress resolves NodeID as net.Addr.
@@ -71,11 +74,15 @@ func (r *nodeResolver) NodeAddress(nodeID uint64, stype raft.SocketType) (addr s
 		err = ErrIllegalAddress
 		return
 	}
+	ip := address.IP
+	if r.replicaIPOf != nil {
+		ip = r.replicaIPOf(ip)
+	}
 	switch stype {
 	case raft.HeartBeat:
-		addr = address.Heartbeat
+		addr = fmt.Sprintf("%s:%d", ip, address.Heartbeat)
 	case raft.Replicate:
-		addr = address.Replicate
+		addr = fmt.Sprintf("%s:%d", ip, address.Replicate)
 	default:
 		err = ErrUnknownSocketType
 	}
@@ -97,8 +104,9 @@ func (r *nodeResolver) AddNodeWithPort(nodeID uint64, addr string, heartbeat int
 	}
 	if len(strings.TrimSpace(addr)) != 0 {
 		r.nodeMap.Store(nodeID, &nodeAddress{
-			Heartbeat: fmt.Sprintf("%s:%d", addr, heartbeat),
-			Replicate: fmt.Sprintf("%s:%d", addr, replicate),
+			IP:        addr,
+			Heartbeat: heartbeat,
+			Replicate: replicate,
 		})
 	}
 }
@@ -112,3 +120,9 @@ func (r *nodeResolver) DeleteNode(nodeID uint64) {
 func NewNodeResolver() NodeResolver {
 	return &nodeResolver{}
 }
+
+// newReplicaNodeResolver returns a NodeResolver which sends the raft messages to the
+// replication network of the nodes.
+func newReplicaNodeResolver(replicaIPOf func(ip string) string) NodeResolver {
+	return &nodeResolver{replicaIPOf: replicaIPOf}
+}
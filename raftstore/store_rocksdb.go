@@ -43,6 +43,21 @@ func NewRocksDBStore(dir string, lruCacheSize, writeBufferSize int) (store *Rock
 	return
 }
 
+// OpenRocksDBStoreReadOnly opens the RocksDB in the dir in the read-only mode, which neither creates nor
+// modifies any file of it, for the offline inspection of the store of a stopped node.
+func OpenRocksDBStoreReadOnly(dir string) (store *RocksDBStore, err error) {
+	if _, err = os.Stat(dir); err != nil {
+		return
+	}
+	opts := gorocksdb.NewDefaultOptions()
+	db, err := gorocksdb.OpenDbForReadOnly(opts, dir, false)
+	if err != nil {
+		err = fmt.Errorf("action[openRocksDBReadOnly],err:%v", err)
+		return
+	}
+	return &RocksDBStore{dir: dir, db: db}, nil
+}
+
 // Open opens the RocksDB instance.
 func (rs *RocksDBStore) Open(lruCacheSize, writeBufferSize int) error {
 	basedTableOptions := gorocksdb.NewDefaultBlockBasedTableOptions()
@@ -213,3 +228,29 @@ func (rs *RocksDBStore) Iterator(snapshot *gorocksdb.Snapshot) *gorocksdb.Iterat
 
 	return rs.db.NewIterator(ro)
 }
+
+// Range calls fn with the key-value pairs of the keys having the prefix in order, until fn returns false.
+// The key and the value are valid only during the call.
+func (rs *RocksDBStore) Range(prefix []byte, fn func(key, value []byte) bool) error {
+	snapshot := rs.RocksDBSnapshot()
+	it := rs.Iterator(snapshot)
+	defer func() {
+		it.Close()
+		rs.ReleaseSnapshot(snapshot)
+	}()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key, value := it.Key(), it.Value()
+		next := fn(key.Data(), value.Data())
+		key.Free()
+		value.Free()
+		if !next {
+			break
+		}
+	}
+	return it.Err()
+}
+
+// Close closes the RocksDB instance.
+func (rs *RocksDBStore) Close() {
+	rs.db.Close()
+}
@@ -0,0 +1,31 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/cubefs/cubefs/util/tlsutil"
+)
+
+// newTLSConfig returns the config of the mutual TLS on the raft connections, by which each node presents
+// its certificate and verifies the one of its peer against the CA, as both the server and the client.
+func newTLSConfig(certFile, keyFile, caFile string) (config *tls.Config, err error) {
+	if config, err = tlsutil.NewConfig(certFile, keyFile, caFile); err != nil {
+		return nil, fmt.Errorf("TLS of raft: %v", err)
+	}
+	return
+}
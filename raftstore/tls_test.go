@@ -0,0 +1,171 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/tiglabs/raft/util"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key fail: err(%v)", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "raft ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create ca fail: err(%v)", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue writes the certificate of the node signed by the CA and its key to the files.
+func (ca *testCA) issue(t *testing.T, serial int64, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key fail: err(%v)", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "raft node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate fail: err(%v)", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key fail: err(%v)", err)
+	}
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+}
+
+func writePEM(t *testing.T, name, typ string, der []byte) {
+	if err := ioutil.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatalf("write %v fail: err(%v)", name, err)
+	}
+}
+
+func TestRaftStoreTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raftstore_tls")
+	if err != nil {
+		t.Fatalf("create temp dir fail: err(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	caFile, certFile, keyFile := path.Join(dir, "ca.pem"), path.Join(dir, "cert.pem"), path.Join(dir, "key.pem")
+	writePEM(t, caFile, "CERTIFICATE", ca.cert.Raw)
+	ca.issue(t, 2, certFile, keyFile)
+
+	if _, err = newTLSConfig(certFile, keyFile, ""); err == nil {
+		t.Fatalf("config without CA should fail")
+	}
+	config, err := newTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("new tls config fail: err(%v)", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail: err(%v)", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				c := tls.Server(conn, config)
+				c.Handshake()
+				c.Close()
+			}()
+		}
+	}()
+
+	// The client without certificate is refused
+	plain := &tls.Config{RootCAs: config.RootCAs}
+	if c, err := tls.Dial("tcp", ln.Addr().String(), plain); err == nil {
+		_, err = c.Read(make([]byte, 1))
+		c.Close()
+		if err == nil {
+			t.Fatalf("client without certificate should be refused")
+		}
+	}
+
+	if _, err = util.DialTLSTimeout(ln.Addr().String(), time.Second, config); err != nil {
+		t.Fatalf("mutual tls dial fail: err(%v)", err)
+	}
+	if serial := serverSerial(t, ln, config); serial != 2 {
+		t.Fatalf("unexpected certificate: serial(%v)", serial)
+	}
+
+	// The rotated certificate is used by the next connections
+	ca.issue(t, 3, certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+	if serial := serverSerial(t, ln, config); serial != 3 {
+		t.Fatalf("certificate not rotated: serial(%v)", serial)
+	}
+}
+
+func serverSerial(t *testing.T, ln net.Listener, config *tls.Config) int64 {
+	config = config.Clone()
+	config.ServerName = "127.0.0.1"
+	c, err := tls.Dial("tcp", ln.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("dial fail: err(%v)", err)
+	}
+	defer c.Close()
+	return c.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
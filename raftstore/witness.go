@@ -0,0 +1,57 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package raftstore
+
+import (
+	"github.com/tiglabs/raft"
+	"github.com/tiglabs/raft/proto"
+)
+
+// The witness of a partition votes and stores the raft log, but applies no command to the state machine,
+// so the partition deployed in two datacenters keeps the quorum by the witness in the third one,
+// which needs neither the disk space nor the memory of a full copy of the data.
+
+// witnessTruncateInterval is the number of the log entries applied by the witness between the truncations
+// of its log, which is not truncated by the snapshots of the state machine as the other replicas.
+const witnessTruncateInterval = 10000
+
+// witnessFsm is the state machine of the partition on the witness, which applies the member changes
+// and the leader changes only, and drops the commands and the data of the snapshots.
+type witnessFsm struct {
+	PartitionFsm
+	id   uint64
+	raft *raft.RaftServer
+}
+
+func (f *witnessFsm) Apply(command []byte, index uint64) (resp interface{}, err error) {
+	if index%witnessTruncateInterval == 0 {
+		f.raft.Truncate(f.id, index)
+	}
+	return
+}
+
+func (f *witnessFsm) ApplySnapshot(peers []proto.Peer, iter proto.SnapIterator) (err error) {
+	return
+}
+
+// isWitness returns true if the node is a witness of the partition.
+func (c *PartitionConfig) isWitness(nodeID uint64) bool {
+	for _, witness := range c.Witnesses {
+		if witness == nodeID {
+			return true
+		}
+	}
+	return false
+}
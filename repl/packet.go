@@ -15,6 +15,7 @@
 package repl
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
@@ -26,6 +27,7 @@ import (
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/tracing"
 	"github.com/tiglabs/raft"
 )
 
@@ -46,6 +48,8 @@ type Packet struct {
 
 	// used locally
 	shallDegrade bool
+	admitted     bool // counted in the requests in flight of the connection
+	span         *tracing.Span
 }
 
 type FollowerPacket struct {
@@ -85,8 +89,13 @@ func (p *FollowerPacket) identificationErrorResultCode(errLog string, errMsg str
 		p.ResultCode = proto.OpNotExistErr
 	} else if strings.Contains(errMsg, storage.NoSpaceError.Error()) {
 		p.ResultCode = proto.OpDiskNoSpaceErr
-	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) {
+	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) ||
+		strings.Contains(errMsg, proto.ErrVolWritesFenced.Error()) {
 		p.ResultCode = proto.OpAgain
+	} else if strings.Contains(errMsg, proto.ErrVolWriteThrottled.Error()) {
+		p.ResultCode = proto.OpWriteThrottled
+	} else if strings.Contains(errMsg, proto.ErrExtentSealed.Error()) {
+		p.ResultCode = proto.OpNotPerm
 	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) {
 		p.ResultCode = proto.OpTryOtherAddr
 	} else {
@@ -119,6 +128,7 @@ func (p *Packet) clean() {
 func copyPacket(src *Packet, dst *FollowerPacket) {
 	dst.Magic = src.Magic
 	dst.ExtentType = src.ExtentType
+	dst.ChecksumType = src.ChecksumType
 	dst.Opcode = src.Opcode
 	dst.ResultCode = src.ResultCode
 	dst.CRC = src.CRC
@@ -129,7 +139,33 @@ func copyPacket(src *Packet, dst *FollowerPacket) {
 	dst.ExtentOffset = src.ExtentOffset
 	dst.ReqID = src.ReqID
 	dst.Data = src.OrgBuffer
+	dst.TraceContext = src.span.Context()
+	dst.RequestID = src.RequestID
+}
+
+// startSpan starts the span of the request if its trace is sampled by the sender.
+func (p *Packet) startSpan() {
+	if p.span = tracing.StartServerSpan(p.GetOpMsg(), p.TraceContext); p.span != nil {
+		p.span.SetAttribute("partition", p.PartitionID)
+		p.span.SetAttribute("extent", p.ExtentID)
+		p.span.SetAttribute("size", p.Size)
+		p.span.SetAttribute("reqID", p.ReqID)
+		if p.RequestID.IsValid() {
+			p.span.SetAttribute("requestID", p.RequestID.String())
+		}
+	}
+}
 
+func (p *Packet) endSpan() {
+	if p.span == nil {
+		return
+	}
+	var err error
+	if p.IsErrPacket() {
+		err = errors.New(p.GetResultMsg())
+	}
+	p.span.End(err)
+	p.span = nil
 }
 
 func (p *Packet) BeforeTp(clusterID string) (ok bool) {
@@ -188,6 +224,35 @@ func NewPacketToGetAllWatermarks(partitionID uint64, extentType uint8) (p *Packe
 	return
 }
 
+func NewPacketToGetExtentCrcs(partitionID uint64) (p *Packet) {
+	p = new(Packet)
+	p.Opcode = proto.OpGetExtentCrcs
+	p.PartitionID = partitionID
+	p.Magic = proto.ProtoMagic
+	p.ReqID = proto.GenerateRequestID()
+	p.ExtentType = proto.NormalExtentType
+
+	return
+}
+
+// NewPacketToReadECShard returns a new packet to read the shard of an erasure coded extent,
+// the size to read is the body of the packet.
+func NewPacketToReadECShard(partitionID, extentID uint64, offset int64, size uint32) (p *Packet) {
+	p = new(Packet)
+	p.Opcode = proto.OpReadECShard
+	p.PartitionID = partitionID
+	p.ExtentID = extentID
+	p.ExtentOffset = offset
+	p.Data = make([]byte, 4)
+	binary.BigEndian.PutUint32(p.Data, size)
+	p.Size = uint32(len(p.Data))
+	p.Magic = proto.ProtoMagic
+	p.ReqID = proto.GenerateRequestID()
+	p.ExtentType = proto.NormalExtentType
+
+	return
+}
+
 func NewPacketToReadTinyDeleteRecord(partitionID uint64, offset int64) (p *Packet) {
 	p = new(Packet)
 	p.Opcode = proto.OpReadTinyDeleteRecord
@@ -300,9 +365,15 @@ func (p *Packet) identificationErrorResultCode(errLog string, errMsg string) {
 		p.ResultCode = proto.OpNotExistErr
 	} else if strings.Contains(errMsg, storage.NoSpaceError.Error()) {
 		p.ResultCode = proto.OpDiskNoSpaceErr
-	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) {
+	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) ||
+		strings.Contains(errMsg, proto.ErrVolWritesFenced.Error()) {
 		p.ResultCode = proto.OpAgain
-	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) {
+	} else if strings.Contains(errMsg, proto.ErrVolWriteThrottled.Error()) {
+		p.ResultCode = proto.OpWriteThrottled
+	} else if strings.Contains(errMsg, proto.ErrExtentSealed.Error()) {
+		p.ResultCode = proto.OpNotPerm
+	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) ||
+		strings.Contains(errMsg, proto.ErrBlockCrcMismatch.Error()) {
 		p.ResultCode = proto.OpTryOtherAddr
 	} else {
 		p.ResultCode = proto.OpIntraGroupNetErr
@@ -343,6 +414,9 @@ func (p *Packet) ReadFromConnFromCli(c net.Conn, deadlineTime time.Duration) (er
 	if err = p.UnmarshalHeader(header); err != nil {
 		return
 	}
+	if err = p.ReadHeaderExtensions(c); err != nil {
+		return
+	}
 
 	if p.ArgLen > 0 {
 		if err = proto.ReadFull(c, &p.Arg, int(p.ArgLen)); err != nil {
@@ -370,7 +444,9 @@ func (p *Packet) IsMasterCommand() bool {
 		proto.OpDecommissionDataPartition,
 		proto.OpAddDataPartitionRaftMember,
 		proto.OpRemoveDataPartitionRaftMember,
-		proto.OpDataPartitionTryToLeader:
+		proto.OpDataPartitionTryToLeader,
+		proto.OpCheckDataPartition,
+		proto.OpDataNodeFenceWrites:
 		return true
 	}
 	return false
@@ -406,6 +482,10 @@ func (p *Packet) IsMarkDeleteExtentOperation() bool {
 	return p.Opcode == proto.OpMarkDelete
 }
 
+func (p *Packet) IsPreallocExtentOperation() bool {
+	return p.Opcode == proto.OpPreallocExtent
+}
+
 func (p *Packet) IsBatchDeleteExtents() bool {
 	return p.Opcode == proto.OpBatchDeleteExtent
 }
@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/log"
 	"sync/atomic"
@@ -29,8 +30,22 @@ import (
 
 var (
 	gConnPool = util.NewConnectPool()
+	// the packets are forwarded to the replication addresses of the followers
+	gReplicaAddrs *util.ReplicaAddrs
 )
 
+// SetReplicaAddrs sets the table of the replication addresses of the followers.
+func SetReplicaAddrs(addrs *util.ReplicaAddrs) {
+	gReplicaAddrs = addrs
+}
+
+// RequestLimiter is implemented by the source connections which limit the requests in flight of the clients.
+type RequestLimiter interface {
+	// AcquireRequest returns false if the client has too many requests in flight.
+	AcquireRequest() bool
+	ReleaseRequest()
+}
+
 // ReplProtocol defines the struct of the replication protocol.
 // 1. ServerConn reads a packet from the client socket, and analyzes the addresses of the followers.
 // 2. After the preparation, the packet is send to toBeProcessedCh. If failure happens, send it to the response channel.
@@ -75,7 +90,7 @@ func NewFollowersTransport(addr string) (ft *FollowerTransport, err error) {
 	var (
 		conn net.Conn
 	)
-	if conn, err = gConnPool.GetConnect(addr); err != nil {
+	if conn, err = gConnPool.GetConnect(gReplicaAddrs.Addr(addr)); err != nil {
 		return
 	}
 	ft = new(FollowerTransport)
@@ -272,6 +287,15 @@ func (rp *ReplProtocol) readPkgAndPrepare() (err error) {
 	}
 	log.LogDebugf("action[readPkgAndPrepare] packet(%v) from remote(%v) ",
 		request.GetUniqueLogId(), rp.sourceConn.RemoteAddr().String())
+	request.startSpan()
+	if limiter, ok := rp.sourceConn.(RequestLimiter); ok {
+		if !limiter.AcquireRequest() {
+			request.PackErrorBody(ActionPreparePkt, storage.TryAgainError.Error())
+			err = rp.putResponse(request)
+			return
+		}
+		request.admitted = true
+	}
 	if err = request.resolveFollowersAddr(); err != nil {
 		err = rp.putResponse(request)
 		return
@@ -396,6 +420,11 @@ func (rp *ReplProtocol) checkLocalResultAndReciveAllFollowerResponse() {
 func (rp *ReplProtocol) writeResponse(reply *Packet) {
 	var err error
 	defer func() {
+		if reply.admitted {
+			rp.sourceConn.(RequestLimiter).ReleaseRequest()
+			reply.admitted = false
+		}
+		reply.endSpan()
 		reply.clean()
 	}()
 	if reply.IsErrPacket() {
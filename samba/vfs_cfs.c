@@ -0,0 +1,982 @@
+/*
+ * Copyright 2018 The Chubao Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+ * implied. See the License for the specific language governing
+ * permissions and limitations under the License.
+ */
+
+/*
+ * The VFS module of Samba (4.16) serving the shares from the ChubaoFS
+ * volumes through libcfs, the Go SDK built by libsdk/build.sh. Samba takes
+ * care of SMB and the authentication, the module maps the file operations
+ * of smbd to libcfs.
+ *
+ * libcfs resolves the relative paths from its working directory like a
+ * process, which follows the chdir of smbd to the path of the share.
+ */
+
+#include "includes.h"
+#include "smbd/smbd.h"
+#include "system/filesys.h"
+#include "lib/util/tevent_unix.h"
+#include "libcfs.h"
+
+#undef DBGC_CLASS
+#define DBGC_CLASS DBGC_VFS
+
+#define CFS_MODULE "cfs"
+#define CFS_BLOCK_SIZE 4096
+#define CFS_DIRENT_BATCH 64
+
+#define WRAP_RETURN(_res) \
+	do { \
+		if ((_res) < 0) { \
+			errno = -(_res); \
+			return -1; \
+		} \
+		return (_res); \
+	} while (0)
+
+struct cfs_data {
+	int64_t cid;
+};
+
+struct cfs_dir {
+	int fd;
+	char *path;
+	int n;
+	int pos;
+	struct cfs_dirent ents[CFS_DIRENT_BATCH];
+	struct dirent de;
+};
+
+static int64_t cfswrap_cid(struct vfs_handle_struct *handle)
+{
+	struct cfs_data *data = (struct cfs_data *)handle->data;
+	return data->cid;
+}
+
+static bool cfswrap_set(int64_t cid, const char *key, const char *val)
+{
+	if (val == NULL) {
+		return true;
+	}
+	if (cfs_set_client(cid, discard_const_p(char, key),
+			   discard_const_p(char, val)) < 0) {
+		DBG_ERR("[CFS] invalid %s: %s\n", key, val);
+		return false;
+	}
+	return true;
+}
+
+static int cfswrap_connect(struct vfs_handle_struct *handle,
+		       const char *service, const char *user)
+{
+	int snum = SNUM(handle->conn);
+	const struct security_unix_token *ut =
+		get_current_utok(handle->conn);
+	const char *volume;
+	const char *masters;
+	struct cfs_data *data;
+	char id[16];
+	int64_t cid;
+	int ret;
+
+	volume = lp_parm_const_string(snum, CFS_MODULE, "volume", NULL);
+	masters = lp_parm_const_string(snum, CFS_MODULE, "masterAddr", NULL);
+	if (volume == NULL || masters == NULL) {
+		DBG_ERR("[CFS] cfs:volume and cfs:masterAddr are required "
+			"by share %s\n", service);
+		errno = EINVAL;
+		return -1;
+	}
+
+	cid = cfs_new_client();
+	if (!cfswrap_set(cid, "volName", volume) ||
+	    !cfswrap_set(cid, "masterAddr", masters) ||
+	    !cfswrap_set(cid, "logDir", lp_parm_const_string(snum, CFS_MODULE,
+							 "logDir", NULL)) ||
+	    !cfswrap_set(cid, "subDir", lp_parm_const_string(snum, CFS_MODULE,
+							 "subDir", NULL)) ||
+	    !cfswrap_set(cid, "followerRead",
+		     lp_parm_bool(snum, CFS_MODULE, "followerRead", false) ?
+		     "true" : "false")) {
+		goto err;
+	}
+	/* the files are created as the user of the session */
+	if (ut != NULL) {
+		snprintf(id, sizeof(id), "%u", (unsigned int)ut->uid);
+		cfswrap_set(cid, "uid", id);
+		snprintf(id, sizeof(id), "%u", (unsigned int)ut->gid);
+		cfswrap_set(cid, "gid", id);
+	}
+
+	ret = cfs_start_client(cid);
+	if (ret < 0) {
+		DBG_ERR("[CFS] failed to start the client of volume %s: %d\n",
+			volume, ret);
+		goto err;
+	}
+
+	data = talloc_zero(handle->conn, struct cfs_data);
+	if (data == NULL) {
+		cfs_close_client(cid);
+		errno = ENOMEM;
+		return -1;
+	}
+	data->cid = cid;
+	handle->data = data;
+
+	/*
+	 * The file descriptors are the ones of libcfs, the kernel knows
+	 * nothing of them.
+	 */
+	lp_do_parameter(snum, "kernel share modes", "no");
+	lp_do_parameter(snum, "kernel oplocks", "no");
+	lp_do_parameter(snum, "smbd async dosmode", "no");
+
+	DBG_NOTICE("[CFS] connected share %s to volume %s\n", service, volume);
+	return 0;
+
+err:
+	cfs_close_client(cid);
+	errno = EINVAL;
+	return -1;
+}
+
+static void cfswrap_disconnect(struct vfs_handle_struct *handle)
+{
+	cfs_close_client(cfswrap_cid(handle));
+	TALLOC_FREE(handle->data);
+}
+
+/* Disk operations */
+
+static uint64_t cfswrap_disk_free(struct vfs_handle_struct *handle,
+			      const struct smb_filename *smb_fname,
+			      uint64_t *bsize, uint64_t *dfree,
+			      uint64_t *dsize)
+{
+	struct cfs_statfs_info st;
+	int ret;
+
+	ret = cfs_statfs(cfswrap_cid(handle), &st);
+	if (ret < 0) {
+		errno = -ret;
+		return (uint64_t)-1;
+	}
+	*bsize = CFS_BLOCK_SIZE;
+	*dsize = st.total / CFS_BLOCK_SIZE;
+	*dfree = st.total > st.used ?
+		(st.total - st.used) / CFS_BLOCK_SIZE : 0;
+	return *dfree;
+}
+
+static int cfswrap_statvfs(struct vfs_handle_struct *handle,
+		       const struct smb_filename *smb_fname,
+		       vfs_statvfs_struct *statbuf)
+{
+	struct cfs_statfs_info st;
+	int ret;
+
+	ret = cfs_statfs(cfswrap_cid(handle), &st);
+	if (ret < 0) {
+		errno = -ret;
+		return -1;
+	}
+	ZERO_STRUCTP(statbuf);
+	statbuf->OptimalTransferSize = 128 * 1024;
+	statbuf->BlockSize = CFS_BLOCK_SIZE;
+	statbuf->TotalBlocks = st.total / CFS_BLOCK_SIZE;
+	statbuf->BlocksAvail = st.total > st.used ?
+		(st.total - st.used) / CFS_BLOCK_SIZE : 0;
+	statbuf->UserBlocksAvail = statbuf->BlocksAvail;
+	statbuf->TotalFileNodes = st.files;
+	statbuf->FreeFileNodes = UINT32_MAX;
+	statbuf->FsCapabilities =
+		FILE_CASE_SENSITIVE_SEARCH | FILE_CASE_PRESERVED_NAMES;
+	return 0;
+}
+
+static uint32_t cfswrap_fs_capabilities(struct vfs_handle_struct *handle,
+				    enum timestamp_set_resolution *p_ts_res)
+{
+	/* the times are set in seconds by libcfs */
+	*p_ts_res = TIMESTAMP_SET_SECONDS;
+	return FILE_CASE_SENSITIVE_SEARCH | FILE_CASE_PRESERVED_NAMES;
+}
+
+/* Directory operations */
+
+static int cfswrap_opendir_fd(struct vfs_handle_struct *handle,
+			  struct cfs_dir *dir)
+{
+	int fd = cfs_open(cfswrap_cid(handle), dir->path, O_RDONLY, 0);
+	if (fd < 0) {
+		return fd;
+	}
+	dir->fd = fd;
+	dir->n = 0;
+	dir->pos = 0;
+	return 0;
+}
+
+static DIR *cfswrap_fdopendir(struct vfs_handle_struct *handle,
+			  files_struct *fsp,
+			  const char *mask,
+			  uint32_t attributes)
+{
+	struct cfs_dir *dir;
+	int ret;
+
+	dir = talloc_zero(NULL, struct cfs_dir);
+	if (dir == NULL) {
+		errno = ENOMEM;
+		return NULL;
+	}
+	dir->path = talloc_strdup(dir, fsp->fsp_name->base_name);
+	if (dir->path == NULL) {
+		TALLOC_FREE(dir);
+		errno = ENOMEM;
+		return NULL;
+	}
+	ret = cfswrap_opendir_fd(handle, dir);
+	if (ret < 0) {
+		TALLOC_FREE(dir);
+		errno = -ret;
+		return NULL;
+	}
+	return (DIR *)dir;
+}
+
+static struct dirent *cfswrap_readdir(struct vfs_handle_struct *handle,
+				      struct files_struct *dirfsp,
+				      DIR *dirp,
+				      SMB_STRUCT_STAT *sbuf)
+{
+	struct cfs_dir *dir = (struct cfs_dir *)dirp;
+	struct cfs_dirent *ent;
+
+	if (sbuf != NULL) {
+		SET_STAT_INVALID(*sbuf);
+	}
+	if (dir->pos == dir->n) {
+		GoSlice ents = {
+			.data = dir->ents,
+			.len = CFS_DIRENT_BATCH,
+			.cap = CFS_DIRENT_BATCH,
+		};
+		int n = cfs_readdir(cfswrap_cid(handle), dir->fd, ents,
+				    CFS_DIRENT_BATCH);
+		if (n < 0) {
+			errno = -n;
+			return NULL;
+		}
+		if (n == 0) {
+			return NULL;
+		}
+		dir->n = n;
+		dir->pos = 0;
+	}
+
+	ent = &dir->ents[dir->pos++];
+	ZERO_STRUCT(dir->de);
+	dir->de.d_ino = ent->ino;
+	dir->de.d_type = ent->d_type;
+	strlcpy(dir->de.d_name, ent->name, sizeof(dir->de.d_name));
+	return &dir->de;
+}
+
+static void cfswrap_rewinddir(struct vfs_handle_struct *handle, DIR *dirp)
+{
+	struct cfs_dir *dir = (struct cfs_dir *)dirp;
+
+	cfs_close(cfswrap_cid(handle), dir->fd);
+	if (cfswrap_opendir_fd(handle, dir) < 0) {
+		dir->fd = -1;
+	}
+}
+
+static int cfswrap_closedir(struct vfs_handle_struct *handle, DIR *dirp)
+{
+	struct cfs_dir *dir = (struct cfs_dir *)dirp;
+
+	if (dir->fd >= 0) {
+		cfs_close(cfswrap_cid(handle), dir->fd);
+	}
+	TALLOC_FREE(dir);
+	return 0;
+}
+
+static int cfswrap_mkdirat(struct vfs_handle_struct *handle,
+		       files_struct *dirfsp,
+		       const struct smb_filename *smb_fname,
+		       mode_t mode)
+{
+	struct smb_filename *full_fname;
+	struct cfs_stat_info st;
+	int ret;
+
+	full_fname = full_path_from_dirfsp_atname(talloc_tos(), dirfsp,
+						  smb_fname);
+	if (full_fname == NULL) {
+		return -1;
+	}
+	/* cfs_mkdirs makes the parents too, and succeeds if it exists */
+	ret = cfs_getattr(cfswrap_cid(handle), full_fname->base_name, &st);
+	if (ret == 0) {
+		ret = -EEXIST;
+	} else if (ret == -ENOENT) {
+		ret = cfs_mkdirs(cfswrap_cid(handle), full_fname->base_name,
+				 mode);
+	}
+	TALLOC_FREE(full_fname);
+	WRAP_RETURN(ret);
+}
+
+/* File operations */
+
+static int cfswrap_openat(struct vfs_handle_struct *handle,
+		      const struct files_struct *dirfsp,
+		      const struct smb_filename *smb_fname,
+		      files_struct *fsp,
+		      int flags,
+		      mode_t mode)
+{
+	struct smb_filename *full_fname;
+	int ret;
+
+	if (smb_fname->stream_name != NULL) {
+		errno = ENOENT;
+		return -1;
+	}
+	full_fname = full_path_from_dirfsp_atname(talloc_tos(), dirfsp,
+						  smb_fname);
+	if (full_fname == NULL) {
+		return -1;
+	}
+	ret = cfs_open(cfswrap_cid(handle), full_fname->base_name, flags, mode);
+	TALLOC_FREE(full_fname);
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_close(struct vfs_handle_struct *handle, files_struct *fsp)
+{
+	cfs_close(cfswrap_cid(handle), fsp_get_pathref_fd(fsp));
+	return 0;
+}
+
+static ssize_t cfswrap_pread(struct vfs_handle_struct *handle, files_struct *fsp,
+			 void *data, size_t n, off_t offset)
+{
+	ssize_t ret = cfs_read(cfswrap_cid(handle), fsp_get_io_fd(fsp), data, n,
+			       offset);
+	WRAP_RETURN(ret);
+}
+
+static ssize_t cfswrap_pwrite(struct vfs_handle_struct *handle, files_struct *fsp,
+			  const void *data, size_t n, off_t offset)
+{
+	ssize_t ret = cfs_write(cfswrap_cid(handle), fsp_get_io_fd(fsp),
+				discard_const(data), n, offset);
+	WRAP_RETURN(ret);
+}
+
+/*
+ * The asynchronous requests are served synchronously, the threads of
+ * vfs_default would call the kernel with the descriptors of libcfs.
+ */
+struct cfs_aio_state {
+	ssize_t ret;
+	struct vfs_aio_state vfs_aio_state;
+};
+
+static struct tevent_req *cfswrap_aio_done(struct tevent_req *req,
+				       struct tevent_context *ev,
+				       struct cfs_aio_state *state,
+				       ssize_t ret)
+{
+	if (ret < 0) {
+		tevent_req_error(req, -ret);
+		return tevent_req_post(req, ev);
+	}
+	state->ret = ret;
+	tevent_req_done(req);
+	return tevent_req_post(req, ev);
+}
+
+static struct tevent_req *cfswrap_pread_send(struct vfs_handle_struct *handle,
+					 TALLOC_CTX *mem_ctx,
+					 struct tevent_context *ev,
+					 struct files_struct *fsp,
+					 void *data,
+					 size_t n, off_t offset)
+{
+	struct tevent_req *req;
+	struct cfs_aio_state *state;
+
+	req = tevent_req_create(mem_ctx, &state, struct cfs_aio_state);
+	if (req == NULL) {
+		return NULL;
+	}
+	return cfswrap_aio_done(req, ev, state,
+			    cfs_read(cfswrap_cid(handle), fsp_get_io_fd(fsp),
+				     data, n, offset));
+}
+
+static struct tevent_req *cfswrap_pwrite_send(struct vfs_handle_struct *handle,
+					  TALLOC_CTX *mem_ctx,
+					  struct tevent_context *ev,
+					  struct files_struct *fsp,
+					  const void *data,
+					  size_t n, off_t offset)
+{
+	struct tevent_req *req;
+	struct cfs_aio_state *state;
+
+	req = tevent_req_create(mem_ctx, &state, struct cfs_aio_state);
+	if (req == NULL) {
+		return NULL;
+	}
+	return cfswrap_aio_done(req, ev, state,
+			    cfs_write(cfswrap_cid(handle), fsp_get_io_fd(fsp),
+				      discard_const(data), n, offset));
+}
+
+static ssize_t cfswrap_aio_recv(struct tevent_req *req,
+			    struct vfs_aio_state *vfs_aio_state)
+{
+	struct cfs_aio_state *state =
+		tevent_req_data(req, struct cfs_aio_state);
+
+	if (tevent_req_is_unix_error(req, &vfs_aio_state->error)) {
+		return -1;
+	}
+	*vfs_aio_state = state->vfs_aio_state;
+	return state->ret;
+}
+
+static struct tevent_req *cfswrap_fsync_send(struct vfs_handle_struct *handle,
+					 TALLOC_CTX *mem_ctx,
+					 struct tevent_context *ev,
+					 files_struct *fsp)
+{
+	struct tevent_req *req;
+	struct cfs_aio_state *state;
+
+	req = tevent_req_create(mem_ctx, &state, struct cfs_aio_state);
+	if (req == NULL) {
+		return NULL;
+	}
+	return cfswrap_aio_done(req, ev, state,
+			    cfs_flush(cfswrap_cid(handle), fsp_get_io_fd(fsp)));
+}
+
+static int cfswrap_fsync_recv(struct tevent_req *req,
+			  struct vfs_aio_state *vfs_aio_state)
+{
+	return cfswrap_aio_recv(req, vfs_aio_state);
+}
+
+static off_t cfswrap_lseek(struct vfs_handle_struct *handle, files_struct *fsp,
+		       off_t offset, int whence)
+{
+	struct cfs_stat_info st;
+	int ret;
+
+	/* the descriptors of libcfs have no position */
+	switch (whence) {
+	case SEEK_SET:
+		return offset;
+	case SEEK_END:
+		ret = cfs_fgetattr(cfswrap_cid(handle), fsp_get_io_fd(fsp), &st);
+		if (ret < 0) {
+			errno = -ret;
+			return -1;
+		}
+		return st.size + offset;
+	default:
+		errno = EINVAL;
+		return -1;
+	}
+}
+
+static ssize_t cfswrap_sendfile(struct vfs_handle_struct *handle, int tofd,
+			    files_struct *fromfsp, const DATA_BLOB *hdr,
+			    off_t offset, size_t n)
+{
+	errno = ENOTSUP;
+	return -1;
+}
+
+static ssize_t cfswrap_recvfile(struct vfs_handle_struct *handle, int fromfd,
+			    files_struct *tofsp, off_t offset, size_t n)
+{
+	errno = ENOTSUP;
+	return -1;
+}
+
+static int cfswrap_renameat(struct vfs_handle_struct *handle,
+			files_struct *srcfsp,
+			const struct smb_filename *smb_fname_src,
+			files_struct *dstfsp,
+			const struct smb_filename *smb_fname_dst)
+{
+	struct smb_filename *full_src;
+	struct smb_filename *full_dst;
+	int ret;
+
+	if (smb_fname_src->stream_name != NULL ||
+	    smb_fname_dst->stream_name != NULL) {
+		errno = ENOENT;
+		return -1;
+	}
+	full_src = full_path_from_dirfsp_atname(talloc_tos(), srcfsp,
+						smb_fname_src);
+	if (full_src == NULL) {
+		return -1;
+	}
+	full_dst = full_path_from_dirfsp_atname(talloc_tos(), dstfsp,
+						smb_fname_dst);
+	if (full_dst == NULL) {
+		TALLOC_FREE(full_src);
+		return -1;
+	}
+	ret = cfs_rename(cfswrap_cid(handle), full_src->base_name,
+			 full_dst->base_name);
+	TALLOC_FREE(full_src);
+	TALLOC_FREE(full_dst);
+	WRAP_RETURN(ret);
+}
+
+static void cfswrap_init_stat_ex(SMB_STRUCT_STAT *dst,
+			     const struct cfs_stat_info *src)
+{
+	struct stat st;
+
+	ZERO_STRUCT(st);
+	st.st_ino = src->ino;
+	st.st_mode = src->mode;
+	st.st_nlink = src->nlink;
+	st.st_uid = src->uid;
+	st.st_gid = src->gid;
+	st.st_size = src->size;
+	st.st_blksize = src->blk_size;
+	st.st_blocks = src->blocks;
+	st.st_atim.tv_sec = src->atime;
+	st.st_atim.tv_nsec = src->atime_nsec;
+	st.st_mtim.tv_sec = src->mtime;
+	st.st_mtim.tv_nsec = src->mtime_nsec;
+	st.st_ctim.tv_sec = src->ctime;
+	st.st_ctim.tv_nsec = src->ctime_nsec;
+	init_stat_ex_from_stat(dst, &st, false);
+}
+
+/*
+ * The paths are not followed if they are symbolic links, so stat is the
+ * same as lstat.
+ */
+static int cfswrap_stat(struct vfs_handle_struct *handle,
+		    struct smb_filename *smb_fname)
+{
+	struct cfs_stat_info st;
+	int ret;
+
+	if (smb_fname->stream_name != NULL) {
+		errno = ENOENT;
+		return -1;
+	}
+	ret = cfs_getattr(cfswrap_cid(handle), smb_fname->base_name, &st);
+	if (ret < 0) {
+		errno = -ret;
+		return -1;
+	}
+	cfswrap_init_stat_ex(&smb_fname->st, &st);
+	return 0;
+}
+
+static int cfswrap_fstat(struct vfs_handle_struct *handle, files_struct *fsp,
+		     SMB_STRUCT_STAT *sbuf)
+{
+	struct cfs_stat_info st;
+	int ret;
+
+	ret = cfs_fgetattr(cfswrap_cid(handle), fsp_get_pathref_fd(fsp), &st);
+	if (ret < 0) {
+		errno = -ret;
+		return -1;
+	}
+	cfswrap_init_stat_ex(sbuf, &st);
+	return 0;
+}
+
+static int cfswrap_unlinkat(struct vfs_handle_struct *handle,
+			struct files_struct *dirfsp,
+			const struct smb_filename *smb_fname,
+			int flags)
+{
+	struct smb_filename *full_fname;
+	int ret;
+
+	if (smb_fname->stream_name != NULL) {
+		errno = ENOENT;
+		return -1;
+	}
+	full_fname = full_path_from_dirfsp_atname(talloc_tos(), dirfsp,
+						  smb_fname);
+	if (full_fname == NULL) {
+		return -1;
+	}
+	if (flags & AT_REMOVEDIR) {
+		ret = cfs_rmdir(cfswrap_cid(handle), full_fname->base_name);
+	} else {
+		ret = cfs_unlink(cfswrap_cid(handle), full_fname->base_name);
+	}
+	TALLOC_FREE(full_fname);
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_fchmod(struct vfs_handle_struct *handle, files_struct *fsp,
+			 mode_t mode)
+{
+	int ret = cfs_fchmod(cfswrap_cid(handle), fsp_get_pathref_fd(fsp), mode);
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_chown_path(struct vfs_handle_struct *handle, const char *path,
+			  uid_t uid, gid_t gid)
+{
+	struct cfs_stat_info st;
+	int valid = 0;
+	int ret;
+
+	ZERO_STRUCT(st);
+	if (uid != (uid_t)-1) {
+		st.uid = uid;
+		valid |= CFS_ATTR_UID;
+	}
+	if (gid != (gid_t)-1) {
+		st.gid = gid;
+		valid |= CFS_ATTR_GID;
+	}
+	if (valid == 0) {
+		return 0;
+	}
+	ret = cfs_setattr(cfswrap_cid(handle), discard_const_p(char, path), &st,
+			  valid);
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_fchown(struct vfs_handle_struct *handle, files_struct *fsp,
+		      uid_t uid, gid_t gid)
+{
+	return cfswrap_chown_path(handle, fsp->fsp_name->base_name, uid, gid);
+}
+
+static int cfswrap_lchown(struct vfs_handle_struct *handle,
+		      const struct smb_filename *smb_fname,
+		      uid_t uid, gid_t gid)
+{
+	return cfswrap_chown_path(handle, smb_fname->base_name, uid, gid);
+}
+
+static int cfswrap_chdir(struct vfs_handle_struct *handle,
+			 const struct smb_filename *smb_fname)
+{
+	int ret = cfs_chdir(cfswrap_cid(handle),
+			    discard_const_p(char, smb_fname->base_name));
+	WRAP_RETURN(ret);
+}
+
+static struct smb_filename *cfswrap_getwd(struct vfs_handle_struct *handle,
+					  TALLOC_CTX *ctx)
+{
+	struct smb_filename *smb_fname;
+	char *cwd = cfs_getcwd(cfswrap_cid(handle));
+
+	smb_fname = synthetic_smb_fname(ctx, cwd, NULL, NULL, 0, 0);
+	free(cwd);
+	return smb_fname;
+}
+
+static int cfswrap_fntimes(struct vfs_handle_struct *handle,
+			   files_struct *fsp,
+			   struct smb_file_time *ft)
+{
+	struct cfs_stat_info st;
+	int valid = 0;
+	int ret;
+
+	ZERO_STRUCT(st);
+	if (!is_omit_timespec(&ft->atime)) {
+		st.atime = ft->atime.tv_sec;
+		valid |= CFS_ATTR_ATIME;
+	}
+	if (!is_omit_timespec(&ft->mtime)) {
+		st.mtime = ft->mtime.tv_sec;
+		valid |= CFS_ATTR_MTIME;
+	}
+	/* the creation time is kept in the DOS attributes by smbd */
+	if (valid == 0) {
+		return 0;
+	}
+	ret = cfs_setattr(cfswrap_cid(handle), fsp->fsp_name->base_name, &st,
+			  valid);
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_ftruncate(struct vfs_handle_struct *handle,
+			     files_struct *fsp, off_t len)
+{
+	int ret = cfs_ftruncate(cfswrap_cid(handle), fsp_get_io_fd(fsp), len);
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_fallocate(struct vfs_handle_struct *handle,
+			     struct files_struct *fsp,
+			     uint32_t mode, off_t offset, off_t len)
+{
+	errno = ENOTSUP;
+	return -1;
+}
+
+/* The byte range locks are kept by smbd, without POSIX locks. */
+static bool cfswrap_lock(struct vfs_handle_struct *handle, files_struct *fsp,
+			 int op, off_t offset, off_t count, int type)
+{
+	return true;
+}
+
+static bool cfswrap_getlock(struct vfs_handle_struct *handle,
+			    files_struct *fsp, off_t *poffset,
+			    off_t *pcount, int *ptype, pid_t *ppid)
+{
+	errno = ENOTSUP;
+	return false;
+}
+
+static int cfswrap_filesystem_sharemode(struct vfs_handle_struct *handle,
+					files_struct *fsp,
+					uint32_t share_access,
+					uint32_t access_mask)
+{
+	return 0;
+}
+
+static int cfswrap_linux_setlease(struct vfs_handle_struct *handle,
+				  files_struct *fsp, int leasetype)
+{
+	errno = ENOTSUP;
+	return -1;
+}
+
+static int cfswrap_fcntl(struct vfs_handle_struct *handle, files_struct *fsp,
+			 int cmd, va_list cmd_arg)
+{
+	/* only called by vfs_set_blocking() to clear O_NONBLOCK */
+	if (cmd == F_GETFL) {
+		return 0;
+	}
+	if (cmd == F_SETFL) {
+		va_list dup;
+		int opt;
+
+		va_copy(dup, cmd_arg);
+		opt = va_arg(dup, int);
+		va_end(dup);
+		if (opt == 0) {
+			return 0;
+		}
+	}
+	errno = EINVAL;
+	return -1;
+}
+
+static int cfswrap_readlinkat(struct vfs_handle_struct *handle,
+			      const struct files_struct *dirfsp,
+			      const struct smb_filename *smb_fname,
+			      char *buf, size_t bufsiz)
+{
+	struct smb_filename *full_fname;
+	ssize_t ret;
+
+	full_fname = full_path_from_dirfsp_atname(talloc_tos(), dirfsp,
+						  smb_fname);
+	if (full_fname == NULL) {
+		return -1;
+	}
+	ret = cfs_readlink(cfswrap_cid(handle), full_fname->base_name, buf,
+			   bufsiz);
+	TALLOC_FREE(full_fname);
+	WRAP_RETURN(ret);
+}
+
+static struct smb_filename *cfswrap_realpath(struct vfs_handle_struct *handle,
+					     TALLOC_CTX *ctx,
+					     const struct smb_filename *smb_fname)
+{
+	const char *path = smb_fname->base_name;
+	struct smb_filename *result;
+	char *cwd;
+	char *resolved;
+
+	if (path[0] == '/') {
+		return synthetic_smb_fname(ctx, path, NULL, NULL, 0, 0);
+	}
+	cwd = cfs_getcwd(cfswrap_cid(handle));
+	if (ISDOT(path)) {
+		resolved = talloc_strdup(ctx, cwd);
+	} else if (strcmp(cwd, "/") == 0) {
+		resolved = talloc_asprintf(ctx, "/%s", path);
+	} else {
+		resolved = talloc_asprintf(ctx, "%s/%s", cwd, path);
+	}
+	free(cwd);
+	if (resolved == NULL) {
+		errno = ENOMEM;
+		return NULL;
+	}
+	result = synthetic_smb_fname(ctx, resolved, NULL, NULL, 0, 0);
+	TALLOC_FREE(resolved);
+	return result;
+}
+
+static const char *cfswrap_connectpath(struct vfs_handle_struct *handle,
+				       const struct smb_filename *smb_fname)
+{
+	return handle->conn->connectpath;
+}
+
+/* Extended attributes, where smbd keeps the DOS attributes */
+
+static ssize_t cfswrap_fgetxattr(struct vfs_handle_struct *handle,
+				 struct files_struct *fsp,
+				 const char *name, void *value, size_t size)
+{
+	ssize_t ret = cfs_fgetxattr(cfswrap_cid(handle),
+				    fsp_get_pathref_fd(fsp),
+				    discard_const_p(char, name), value, size);
+	WRAP_RETURN(ret);
+}
+
+static ssize_t cfswrap_flistxattr(struct vfs_handle_struct *handle,
+				  struct files_struct *fsp,
+				  char *list, size_t size)
+{
+	ssize_t ret = cfs_flistxattr(cfswrap_cid(handle),
+				     fsp_get_pathref_fd(fsp), list, size);
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_fremovexattr(struct vfs_handle_struct *handle,
+				struct files_struct *fsp, const char *name)
+{
+	int ret = cfs_fremovexattr(cfswrap_cid(handle),
+				   fsp_get_pathref_fd(fsp),
+				   discard_const_p(char, name));
+	WRAP_RETURN(ret);
+}
+
+static int cfswrap_fsetxattr(struct vfs_handle_struct *handle,
+			     struct files_struct *fsp, const char *name,
+			     const void *value, size_t size, int flags)
+{
+	int ret = cfs_fsetxattr(cfswrap_cid(handle), fsp_get_pathref_fd(fsp),
+				discard_const_p(char, name),
+				discard_const(value), size, flags);
+	WRAP_RETURN(ret);
+}
+
+static struct vfs_fn_pointers cfs_fns = {
+	/* Disk operations */
+
+	.connect_fn = cfswrap_connect,
+	.disconnect_fn = cfswrap_disconnect,
+	.disk_free_fn = cfswrap_disk_free,
+	.get_quota_fn = vfs_not_implemented_get_quota,
+	.set_quota_fn = vfs_not_implemented_set_quota,
+	.statvfs_fn = cfswrap_statvfs,
+	.fs_capabilities_fn = cfswrap_fs_capabilities,
+
+	/* Directory operations */
+
+	.fdopendir_fn = cfswrap_fdopendir,
+	.readdir_fn = cfswrap_readdir,
+	.rewind_dir_fn = cfswrap_rewinddir,
+	.mkdirat_fn = cfswrap_mkdirat,
+	.closedir_fn = cfswrap_closedir,
+
+	/* File operations */
+
+	.openat_fn = cfswrap_openat,
+	.close_fn = cfswrap_close,
+	.pread_fn = cfswrap_pread,
+	.pread_send_fn = cfswrap_pread_send,
+	.pread_recv_fn = cfswrap_aio_recv,
+	.pwrite_fn = cfswrap_pwrite,
+	.pwrite_send_fn = cfswrap_pwrite_send,
+	.pwrite_recv_fn = cfswrap_aio_recv,
+	.lseek_fn = cfswrap_lseek,
+	.sendfile_fn = cfswrap_sendfile,
+	.recvfile_fn = cfswrap_recvfile,
+	.renameat_fn = cfswrap_renameat,
+	.fsync_send_fn = cfswrap_fsync_send,
+	.fsync_recv_fn = cfswrap_fsync_recv,
+	.stat_fn = cfswrap_stat,
+	.fstat_fn = cfswrap_fstat,
+	.lstat_fn = cfswrap_stat,
+	.unlinkat_fn = cfswrap_unlinkat,
+	.fchmod_fn = cfswrap_fchmod,
+	.fchown_fn = cfswrap_fchown,
+	.lchown_fn = cfswrap_lchown,
+	.chdir_fn = cfswrap_chdir,
+	.getwd_fn = cfswrap_getwd,
+	.fntimes_fn = cfswrap_fntimes,
+	.ftruncate_fn = cfswrap_ftruncate,
+	.fallocate_fn = cfswrap_fallocate,
+	.lock_fn = cfswrap_lock,
+	.filesystem_sharemode_fn = cfswrap_filesystem_sharemode,
+	.fcntl_fn = cfswrap_fcntl,
+	.linux_setlease_fn = cfswrap_linux_setlease,
+	.getlock_fn = cfswrap_getlock,
+	.symlinkat_fn = vfs_not_implemented_symlinkat,
+	.readlinkat_fn = cfswrap_readlinkat,
+	.linkat_fn = vfs_not_implemented_linkat,
+	.mknodat_fn = vfs_not_implemented_mknodat,
+	.realpath_fn = cfswrap_realpath,
+	.fchflags_fn = vfs_not_implemented_fchflags,
+	.connectpath_fn = cfswrap_connectpath,
+
+	/* EA operations. */
+	.getxattrat_send_fn = vfs_not_implemented_getxattrat_send,
+	.getxattrat_recv_fn = vfs_not_implemented_getxattrat_recv,
+	.fgetxattr_fn = cfswrap_fgetxattr,
+	.flistxattr_fn = cfswrap_flistxattr,
+	.fremovexattr_fn = cfswrap_fremovexattr,
+	.fsetxattr_fn = cfswrap_fsetxattr,
+
+	/* Posix ACL Operations, the ACLs are made from the modes */
+	.sys_acl_get_fd_fn = vfs_not_implemented_sys_acl_get_fd,
+	.sys_acl_blob_get_fd_fn = vfs_not_implemented_sys_acl_blob_get_fd,
+	.sys_acl_set_fd_fn = vfs_not_implemented_sys_acl_set_fd,
+	.sys_acl_delete_def_fd_fn = vfs_not_implemented_sys_acl_delete_def_fd,
+
+	/* aio operations */
+	.aio_force_fn = vfs_not_implemented_aio_force,
+};
+
+static_decl_vfs;
+NTSTATUS vfs_cfs_init(TALLOC_CTX *ctx)
+{
+	return smb_register_vfs(SMB_VFS_INTERFACE_VERSION,
+				CFS_MODULE, &cfs_fns);
+}
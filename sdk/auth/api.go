@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/rand"
 	"encoding/json"
 
 	"github.com/cubefs/cubefs/proto"
@@ -8,6 +9,9 @@ import (
 	"github.com/cubefs/cubefs/util/cryptoutil"
 )
 
+// replyKeySize is the size of the AES-256 key generated by the client to encrypt the response
+const replyKeySize = 32
+
 func (api *API) GetTicket(clientId string, clientKey string, serviceID string) (ticket *auth.Ticket, err error) {
 	var (
 		key      []byte
@@ -43,3 +47,44 @@ func (api *API) GetTicket(clientId string, clientKey string, serviceID string) (
 	}
 	return
 }
+
+// GetIdentityTicket gets the ticket of the service with the external identity verified by the provider,
+// which is the ID token for the OIDC provider, or the user and the password for the LDAP server.
+func (api *API) GetIdentityTicket(provider, token, user, password, serviceID string) (ticket *auth.Ticket, err error) {
+	var (
+		ts       int64
+		msgResp  proto.AuthGetTicketResp
+		respData []byte
+	)
+	message := proto.AuthGetIdentityTicketReq{
+		Type:      proto.MsgAuthTicketReq,
+		ServiceID: serviceID,
+		Provider:  provider,
+		Token:     token,
+		User:      user,
+		Password:  password,
+		ReplyKey:  make([]byte, replyKeySize),
+	}
+	if _, err = rand.Read(message.ReplyKey); err != nil {
+		return
+	}
+	if message.Verifier, ts, err = cryptoutil.GenVerifier(message.ReplyKey); err != nil {
+		return
+	}
+	if respData, err = api.ac.request("", "", message.ReplyKey, message, proto.ClientGetIdentityTicket, serviceID); err != nil {
+		return
+	}
+	if err = json.Unmarshal(respData, &msgResp); err != nil {
+		return
+	}
+	if err = proto.VerifyTicketRespComm(&msgResp, proto.MsgAuthTicketReq, msgResp.ClientID, serviceID, ts); err != nil {
+		return
+	}
+	ticket = &auth.Ticket{
+		ID:         msgResp.ClientID,
+		SessionKey: cryptoutil.Base64Encode(msgResp.SessionKey.Key),
+		ServiceID:  serviceID,
+		Ticket:     msgResp.Ticket,
+	}
+	return
+}
@@ -80,3 +80,17 @@ func (api *API) AdminGetCaps(clientID, clientKey, userID string) (res *keystore.
 	}
 	return api.ac.serveAdminRequest(clientID, clientKey, api.ac.ticket, keyInfo, proto.MsgAuthGetCapsReq, proto.AdminGetCaps)
 }
+
+func (api *API) AdminRotateKey(clientID, clientKey, serviceID string, keyVersion uint32, authKey []byte) (res *keystore.KeyInfo, err error) {
+	if api.ac.ticket == nil {
+		if api.ac.ticket, err = api.GetTicket(clientID, clientKey, proto.AuthServiceID); err != nil {
+			return
+		}
+	}
+	keyInfo := &keystore.KeyInfo{
+		ID:         serviceID,
+		AuthKey:    authKey,
+		KeyVersion: keyVersion,
+	}
+	return api.ac.serveAdminRequest(clientID, clientKey, api.ac.ticket, keyInfo, proto.MsgAuthRotateKeyReq, proto.AdminRotateKey)
+}
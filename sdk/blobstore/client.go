@@ -0,0 +1,159 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package blobstore is the client of the access service of an erasure coded blob store, which is
+// the cold tier of the file volumes. A blob is put as a whole, read by ranges and deleted by the
+// location returned by the put, which is opaque to the client.
+package blobstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	requestTimeout = 60 * time.Second
+
+	// MaxBlobSize is the upper limit of the size of a blob put by the client.
+	MaxBlobSize = 64 * 1024 * 1024
+)
+
+// paths of the access service
+const (
+	putPath    = "/put"
+	getPath    = "/get"
+	deletePath = "/delete"
+)
+
+type putResponse struct {
+	Location json.RawMessage `json:"location"`
+}
+
+type getRequest struct {
+	Location json.RawMessage `json:"location"`
+	Offset   uint64          `json:"offset"`
+	ReadSize uint64          `json:"read_size"`
+}
+
+type deleteRequest struct {
+	Locations []json.RawMessage `json:"locations"`
+}
+
+// Client sends the requests to the addresses of the access service in turn until one succeeds.
+type Client struct {
+	addrs  []string
+	next   uint32
+	client *http.Client
+}
+
+// NewClient returns the client of the access service of the addresses.
+func NewClient(addrs []string) *Client {
+	return &Client{
+		addrs:  addrs,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Addrs returns the addresses of the access service.
+func (c *Client) Addrs() []string {
+	return c.addrs
+}
+
+// Put stores the data as a blob and returns the location of the blob.
+func (c *Client) Put(data []byte) (location json.RawMessage, err error) {
+	if len(data) > MaxBlobSize {
+		return nil, fmt.Errorf("blob size(%v) exceeds the limit(%v)", len(data), MaxBlobSize)
+	}
+	var body []byte
+	if body, err = c.request(putPath+"?size="+strconv.Itoa(len(data)), data); err != nil {
+		return
+	}
+	var resp putResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal put response(%s) err(%v)", body, err)
+	}
+	if len(resp.Location) == 0 {
+		return nil, fmt.Errorf("no location in put response(%s)", body)
+	}
+	return resp.Location, nil
+}
+
+// Get reads the blob at the location from the offset into data.
+func (c *Client) Get(location json.RawMessage, offset uint64, data []byte) (err error) {
+	var req []byte
+	if req, err = json.Marshal(&getRequest{Location: location, Offset: offset, ReadSize: uint64(len(data))}); err != nil {
+		return
+	}
+	var body []byte
+	if body, err = c.request(getPath, req); err != nil {
+		return
+	}
+	if len(body) != len(data) {
+		return fmt.Errorf("get blob offset(%v) size(%v) but read(%v)", offset, len(data), len(body))
+	}
+	copy(data, body)
+	return
+}
+
+// Delete deletes the blobs at the locations.
+func (c *Client) Delete(locations []json.RawMessage) (err error) {
+	if len(locations) == 0 {
+		return
+	}
+	var req []byte
+	if req, err = json.Marshal(&deleteRequest{Locations: locations}); err != nil {
+		return
+	}
+	_, err = c.request(deletePath, req)
+	return
+}
+
+func (c *Client) request(path string, data []byte) (body []byte, err error) {
+	if len(c.addrs) == 0 {
+		return nil, fmt.Errorf("no blob store address")
+	}
+	var start = int(atomic.AddUint32(&c.next, 1))
+	for i := 0; i < len(c.addrs); i++ {
+		var addr = c.addrs[(start+i)%len(c.addrs)]
+		if body, err = c.post("http://"+addr+path, data); err == nil {
+			return
+		}
+		log.LogWarnf("blobstore: request fail: addr(%v) path(%v) err(%v)", addr, path, err)
+	}
+	return
+}
+
+func (c *Client) post(url string, data []byte) (body []byte, err error) {
+	var resp *http.Response
+	if resp, err = c.client.Post(url, "application/octet-stream", bytes.NewReader(data)); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(io.LimitReader(resp.Body, MaxBlobSize+1)); err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status(%v) body(%s)", resp.StatusCode, body)
+	}
+	return
+}
@@ -21,6 +21,8 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/btree"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 // ExtentRequest defines the struct for the request of read or write an extent.
@@ -29,6 +31,8 @@ type ExtentRequest struct {
 	Size       int
 	Data       []byte
 	ExtentKey  *proto.ExtentKey
+	trace      tracing.SpanContext
+	requestID  reqid.ID
 }
 
 // String returns the string format of the extent request.
@@ -54,6 +58,8 @@ type ExtentCache struct {
 	size    uint64 // size of the cache
 	root    *btree.BTree
 	discard *btree.BTree
+	inline  []byte              // data of the file stored inline in the inode, never modified in place
+	cold    *proto.ColdLocation // location of the data of the file in the cold tier, overlaid by the extents
 }
 
 // NewExtentCache returns a new extent cache.
@@ -67,17 +73,17 @@ func NewExtentCache(inode uint64) *ExtentCache {
 
 // Refresh refreshes the extent cache.
 func (cache *ExtentCache) Refresh(inode uint64, getExtents GetExtentsFunc) error {
-	gen, size, extents, err := getExtents(inode)
+	gen, size, extents, inline, cold, err := getExtents(inode)
 	if err != nil {
 		return err
 	}
 	//log.LogDebugf("Local ExtentCache before update: ino(%v) gen(%v) size(%v) extents(%v)", inode, cache.gen, cache.size, cache.List())
-	cache.update(gen, size, extents)
+	cache.update(gen, size, extents, inline, cold)
 	//log.LogDebugf("Local ExtentCache after update: ino(%v) gen(%v) size(%v) extents(%v)", inode, cache.gen, cache.size, cache.List())
 	return nil
 }
 
-func (cache *ExtentCache) update(gen, size uint64, eks []proto.ExtentKey) {
+func (cache *ExtentCache) update(gen, size uint64, eks []proto.ExtentKey, inline []byte, cold *proto.ColdLocation) {
 	cache.Lock()
 	defer cache.Unlock()
 
@@ -100,6 +106,8 @@ func (cache *ExtentCache) update(gen, size uint64, eks []proto.ExtentKey) {
 
 	cache.gen = gen
 	cache.size = size
+	cache.inline = inline
+	cache.cold = cold
 	cache.root.Clear(false)
 	for _, ek := range eks {
 		extent := ek
@@ -184,6 +192,13 @@ func (cache *ExtentCache) TruncDiscard(size uint64) {
 	log.LogDebugf("truncate ExtentCache discard: ino(%v) size(%v) discard(%v)", cache.inode, size, discardExtents)
 }
 
+// Len returns the number of the extent keys in the cache.
+func (cache *ExtentCache) Len() int {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.root.Len()
+}
+
 // Max returns the max extent key in the cache.
 func (cache *ExtentCache) Max() *proto.ExtentKey {
 	cache.RLock()
@@ -209,6 +224,34 @@ func (cache *ExtentCache) SetSize(size uint64, sync bool) {
 	}
 }
 
+// Inline returns the inline data of the file, which must not be modified.
+func (cache *ExtentCache) Inline() []byte {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.inline
+}
+
+// Cold returns the location of the data of the file in the cold tier, nil if it is not migrated.
+func (cache *ExtentCache) Cold() *proto.ColdLocation {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.cold
+}
+
+// SetInline replaces the inline data of the file.
+func (cache *ExtentCache) SetInline(data []byte) {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.inline = data
+}
+
+// HasExtents returns if the file has any extent in the cache.
+func (cache *ExtentCache) HasExtents() bool {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.root.Len() > 0
+}
+
 // List returns a list of the extents in the cache.
 func (cache *ExtentCache) List() []*proto.ExtentKey {
 	cache.RLock()
@@ -339,6 +382,43 @@ func (cache *ExtentCache) PrepareReadRequests(offset, size int, data []byte) []*
 	return requests
 }
 
+// Holes returns the ranges in the given range that are not covered by any extent.
+func (cache *ExtentCache) Holes(offset, size int) []*ExtentRequest {
+	holes := make([]*ExtentRequest, 0)
+	pivot := &proto.ExtentKey{FileOffset: uint64(offset)}
+	upper := &proto.ExtentKey{FileOffset: uint64(offset + size)}
+	start := offset
+	end := offset + size
+
+	cache.RLock()
+	defer cache.RUnlock()
+
+	lower := &proto.ExtentKey{}
+	cache.root.DescendLessOrEqual(pivot, func(i btree.Item) bool {
+		ek := i.(*proto.ExtentKey)
+		lower.FileOffset = ek.FileOffset
+		return false
+	})
+
+	cache.root.AscendRange(lower, upper, func(i btree.Item) bool {
+		ek := i.(*proto.ExtentKey)
+		ekStart := int(ek.FileOffset)
+		ekEnd := int(ek.FileOffset) + int(ek.Size)
+		if start < ekStart {
+			holes = append(holes, NewExtentRequest(start, ekStart-start, nil, nil))
+		}
+		if start < ekEnd {
+			start = ekEnd
+		}
+		return start < end
+	})
+
+	if start < end {
+		holes = append(holes, NewExtentRequest(start, end-start, nil, nil))
+	}
+	return holes
+}
+
 // PrepareWriteRequests TODO explain
 func (cache *ExtentCache) PrepareWriteRequests(offset, size int, data []byte) []*ExtentRequest {
 	requests := make([]*ExtentRequest, 0)
@@ -15,8 +15,12 @@
 package stream
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -25,15 +29,22 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/blobstore"
 	"github.com/cubefs/cubefs/sdk/data/wrapper"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/ratelimit"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 type AppendExtentKeyFunc func(parentInode, inode uint64, key proto.ExtentKey, discard []proto.ExtentKey) error
-type GetExtentsFunc func(inode uint64) (uint64, uint64, []proto.ExtentKey, error)
+type GetExtentsFunc func(inode uint64) (uint64, uint64, []proto.ExtentKey, []byte, *proto.ColdLocation, error)
+type WriteInlineFunc func(inode uint64, data []byte) error
 type TruncateFunc func(inode, size uint64) error
+type PunchHoleFunc func(inode, offset, size uint64) error
 type EvictIcacheFunc func(inode uint64)
 
 const (
@@ -49,12 +60,14 @@ const (
 
 var (
 	// global object pools for memory optimization
-	openRequestPool    *sync.Pool
-	writeRequestPool   *sync.Pool
-	flushRequestPool   *sync.Pool
-	releaseRequestPool *sync.Pool
-	truncRequestPool   *sync.Pool
-	evictRequestPool   *sync.Pool
+	openRequestPool     *sync.Pool
+	writeRequestPool    *sync.Pool
+	flushRequestPool    *sync.Pool
+	releaseRequestPool  *sync.Pool
+	truncRequestPool    *sync.Pool
+	punchRequestPool    *sync.Pool
+	preallocRequestPool *sync.Pool
+	evictRequestPool    *sync.Pool
 )
 
 func init() {
@@ -74,6 +87,12 @@ func init() {
 	truncRequestPool = &sync.Pool{New: func() interface{} {
 		return &TruncRequest{}
 	}}
+	punchRequestPool = &sync.Pool{New: func() interface{} {
+		return &PunchRequest{}
+	}}
+	preallocRequestPool = &sync.Pool{New: func() interface{} {
+		return &PreallocRequest{}
+	}}
 	evictRequestPool = &sync.Pool{New: func() interface{} {
 		return &EvictRequest{}
 	}}
@@ -84,12 +103,31 @@ type ExtentConfig struct {
 	Masters           []string
 	FollowerRead      bool
 	NearRead          bool
+	ZoneName          string // location of the client for the nearest reads
+	Rack              string
 	ReadRate          int64
 	WriteRate         int64
 	OnAppendExtentKey AppendExtentKeyFunc
 	OnGetExtents      GetExtentsFunc
 	OnTruncate        TruncateFunc
+	OnPunchHole       PunchHoleFunc
 	OnEvictIcache     EvictIcacheFunc
+	OnWriteInline     WriteInlineFunc
+	InlineSize        int // files not larger than the size are stored inline in the inodes, 0 disables
+	ReadCachePath     string
+	ReadCacheSize     int64 // bytes of the read cache on the local disk, 0 disables
+
+	WriteBack             bool             // buffer the writes which are not synchronous in the memory
+	WriteBackDirtyLimit   int64            // bytes buffered by the client at most
+	WriteBackFlushSeconds int64            // seconds the writes are buffered at most
+	WriteBackCloseFlush   bool             // flush the buffered writes when the file is closed
+	ReadBandwidth         int64            // MB/s read at most, 0 or less is unlimited
+	WriteBandwidth        int64            // MB/s written at most, 0 or less is unlimited
+	ReadAheadMax          int64            // bytes prefetched at most after the sequential reads, 0 uses the default, negative disables
+	EncryptKey            []byte           // the file contents are encrypted with the key if it is not nil, see LoadEncryptKey
+	RetryPolicy           util.RetryPolicy // the zero fields take DefaultRetryPolicy
+	VerifyReadChecksum    bool             // the data read is checked against the block CRCs of the data nodes, and read from another replica if corrupt
+	ClusterRateLimit      bool             // the bandwidth of the volume is limited by the rate limits shared by the clients, see package ratelimit
 }
 
 // ExtentClient defines the struct of the extent client.
@@ -100,11 +138,37 @@ type ExtentClient struct {
 	readLimiter  *rate.Limiter
 	writeLimiter *rate.Limiter
 
+	// limit the bytes per second, the limiters above limit the requests
+	readBandwidthLimiter  *rate.Limiter
+	writeBandwidthLimiter *rate.Limiter
+
+	// limit the bytes per second of the volume shared by the clients, nil if not enabled
+	clusterLimiter *ratelimit.Limiter
+	readLimitKey   string
+	writeLimitKey  string
+
 	dataWrapper     *wrapper.Wrapper
 	appendExtentKey AppendExtentKeyFunc
 	getExtents      GetExtentsFunc
 	truncate        TruncateFunc
+	punchHole       PunchHoleFunc   //May be null, must check before using
 	evictIcache     EvictIcacheFunc //May be null, must check before using
+	writeInline     WriteInlineFunc //May be null, must check before using
+	inlineSize      int
+	readCache       *ReadCache       //May be null, must check before using
+	writeBack       *writeBackConfig //May be null, must check before using
+	readAheadMax    int64            // atomic, see ExtentConfig.ReadAheadMax
+	readAheadHits   uint64           // reads served by the data prefetched entirely
+	readAheadMisses uint64
+	flashHits       uint64 // blocks read from the flash nodes
+	flashMisses     uint64
+	encryptKey      []byte            // key of the volume derived from ExtentConfig.EncryptKey, nil if not encrypted
+	blobStore       *blobstore.Client // client of the blob store of the cold tier, nil if not configured
+
+	verifyReadChecksum bool
+
+	memPressure      int32 // atomic, see SetMemoryPressure
+	writeBufferLimit int64 // atomic, see SetWriteBufferLimit
 }
 
 // NewExtentClient returns a new extent client.
@@ -128,9 +192,38 @@ retry:
 	client.appendExtentKey = config.OnAppendExtentKey
 	client.getExtents = config.OnGetExtents
 	client.truncate = config.OnTruncate
+	client.punchHole = config.OnPunchHole
 	client.evictIcache = config.OnEvictIcache
+	client.writeInline = config.OnWriteInline
+	if client.writeInline != nil && config.InlineSize > 0 {
+		client.inlineSize = config.InlineSize
+		if client.inlineSize > proto.MaxInlineDataSize {
+			client.inlineSize = proto.MaxInlineDataSize
+		}
+	}
+	if config.ReadCachePath != "" && config.ReadCacheSize > 0 {
+		if client.readCache, err = NewReadCache(config.ReadCachePath, config.Volume, config.ReadCacheSize); err != nil {
+			client.dataWrapper.Stop()
+			return nil, errors.Trace(err, "Init read cache failed!")
+		}
+	}
+	client.writeBack = newWriteBackConfig(config)
+	client.readAheadMax = config.ReadAheadMax
+	client.verifyReadChecksum = config.VerifyReadChecksum
+	if config.EncryptKey != nil {
+		if len(config.EncryptKey) != EncryptKeySize {
+			client.dataWrapper.Stop()
+			return nil, fmt.Errorf("invalid encryption key size %v", len(config.EncryptKey))
+		}
+		client.encryptKey = volumeEncryptKey(config.EncryptKey, config.Volume)
+	}
+	if addrs := client.dataWrapper.BlobStoreAddrs(); len(addrs) > 0 {
+		client.blobStore = blobstore.NewClient(addrs)
+	}
+	client.dataWrapper.SetRetryPolicy(config.RetryPolicy.Merge(DefaultRetryPolicy))
 	client.dataWrapper.InitFollowerRead(config.FollowerRead)
 	client.dataWrapper.SetNearRead(config.NearRead)
+	client.dataWrapper.SetLocation(config.ZoneName, config.Rack)
 
 	var readLimit, writeLimit rate.Limit
 	if config.ReadRate <= 0 {
@@ -146,6 +239,16 @@ retry:
 
 	client.readLimiter = rate.NewLimiter(readLimit, defaultReadLimitBurst)
 	client.writeLimiter = rate.NewLimiter(writeLimit, defaultWriteLimitBurst)
+	client.readBandwidthLimiter = rate.NewLimiter(rate.Inf, 0)
+	client.writeBandwidthLimiter = rate.NewLimiter(rate.Inf, 0)
+	setBandwidth(client.readBandwidthLimiter, int(config.ReadBandwidth))
+	setBandwidth(client.writeBandwidthLimiter, int(config.WriteBandwidth))
+	if config.ClusterRateLimit {
+		node := fmt.Sprintf("%v:%v", wrapper.LocalIP, os.Getpid())
+		client.clusterLimiter = ratelimit.NewLimiter(node, client.dataWrapper.MasterClient().ClientAPI())
+		client.readLimitKey = ratelimit.Key(ratelimit.ScopeVolReadBytes, config.Volume)
+		client.writeLimitKey = ratelimit.Key(ratelimit.ScopeVolWriteBytes, config.Volume)
+	}
 
 	return
 }
@@ -220,7 +323,16 @@ func (client *ExtentClient) SetFileSize(inode uint64, size int) {
 
 // Write writes the data.
 func (client *ExtentClient) Write(inode uint64, offset int, data []byte, flags int) (write int, err error) {
-	prefix := fmt.Sprintf("Write{ino(%v)offset(%v)size(%v)}", inode, offset, len(data))
+	return client.WriteWithRequestID(reqid.ID{}, inode, offset, data, flags)
+}
+
+// WriteWithRequestID writes the data for the user operation of the request ID, which is carried by the
+// requests to the data nodes. A new request ID is used if it is invalid.
+func (client *ExtentClient) WriteWithRequestID(requestID reqid.ID, inode uint64, offset int, data []byte, flags int) (write int, err error) {
+	if !requestID.IsValid() {
+		requestID = reqid.New()
+	}
+	prefix := fmt.Sprintf("Write{ino(%v)offset(%v)size(%v)requestID(%v)}", inode, offset, len(data), requestID)
 
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -233,7 +345,15 @@ func (client *ExtentClient) Write(inode uint64, offset int, data []byte, flags i
 		s.GetExtents()
 	})
 
-	write, err = s.IssueWriteRequest(offset, data, flags)
+	span := tracing.StartSpan("Write", tracing.SpanKindClient, tracing.SpanContext{})
+	span.SetAttribute("ino", inode)
+	span.SetAttribute("offset", offset)
+	span.SetAttribute("size", len(data))
+	s.readAhead.invalidate()
+	span.SetAttribute("requestID", requestID)
+	write, err = s.IssueWriteRequest(offset, s.encrypt(data, offset), flags, span.Context(), requestID)
+	s.readAhead.invalidate()
+	span.End(err)
 	if err != nil {
 		err = errors.Trace(err, prefix)
 		log.LogError(errors.Stack(err))
@@ -256,7 +376,9 @@ func (client *ExtentClient) Truncate(mw *meta.MetaWrapper, parentIno uint64, ino
 		info, err = mw.InodeGet_ll(inode)
 		oldSize = info.Size
 	}
+	s.readAhead.invalidate()
 	err = s.IssueTruncRequest(size)
+	s.readAhead.invalidate()
 	if err != nil {
 		err = errors.Trace(err, prefix)
 		log.LogError(errors.Stack(err))
@@ -268,6 +390,45 @@ func (client *ExtentClient) Truncate(mw *meta.MetaWrapper, parentIno uint64, ino
 	return err
 }
 
+// PunchHole deallocates the given range of a file, the size of the file is kept.
+func (client *ExtentClient) PunchHole(inode uint64, offset, size int) error {
+	prefix := fmt.Sprintf("PunchHole{ino(%v)offset(%v)size(%v)}", inode, offset, size)
+	if client.punchHole == nil {
+		return syscall.EOPNOTSUPP
+	}
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("Prefix(%v): stream is not opened yet", prefix)
+		return syscall.EBADF
+	}
+	s.readAhead.invalidate()
+	err := s.IssuePunchRequest(offset, size)
+	s.readAhead.invalidate()
+	if err != nil {
+		err = errors.Trace(err, "%v", prefix)
+		log.LogError(errors.Stack(err))
+	}
+	return err
+}
+
+// Preallocate allocates the space of the given range of a file, the holes in the range
+// read as zeros. The size of the file is extended if the range is beyond it.
+func (client *ExtentClient) Preallocate(inode uint64, offset, size int) error {
+	prefix := fmt.Sprintf("Preallocate{ino(%v)offset(%v)size(%v)}", inode, offset, size)
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("Prefix(%v): stream is not opened yet", prefix)
+		return syscall.EBADF
+	}
+	s.readAhead.invalidate()
+	err := s.IssuePreallocRequest(offset, size)
+	if err != nil {
+		// the errno is returned as is, e.g. ENOSPC
+		log.LogError(errors.Stack(errors.Trace(err, "%v", prefix)))
+	}
+	return err
+}
+
 func (client *ExtentClient) Flush(inode uint64) error {
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -278,9 +439,18 @@ func (client *ExtentClient) Flush(inode uint64) error {
 }
 
 func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int) (read int, err error) {
+	return client.ReadWithRequestID(reqid.ID{}, inode, data, offset, size)
+}
+
+// ReadWithRequestID reads the data for the user operation of the request ID, which is carried by the
+// requests to the data nodes. A new request ID is used if it is invalid.
+func (client *ExtentClient) ReadWithRequestID(requestID reqid.ID, inode uint64, data []byte, offset int, size int) (read int, err error) {
 	if size == 0 {
 		return
 	}
+	if !requestID.IsValid() {
+		requestID = reqid.New()
+	}
 
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -297,10 +467,34 @@ func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int
 		return
 	}
 
-	read, err = s.read(data, offset, size)
+	span := tracing.StartSpan("Read", tracing.SpanKindClient, tracing.SpanContext{})
+	span.SetAttribute("ino", inode)
+	span.SetAttribute("offset", offset)
+	span.SetAttribute("size", size)
+	span.SetAttribute("requestID", requestID)
+	if maxWindow := readAheadWindow(atomic.LoadInt64(&client.readAheadMax)); maxWindow > 0 {
+		read, err = s.readWithReadAhead(data, offset, size, maxWindow, span.Context(), requestID)
+	} else {
+		read, err = s.read(data, offset, size, span.Context(), requestID)
+	}
+	if err == io.EOF {
+		span.End(nil)
+	} else {
+		span.End(err)
+	}
 	return
 }
 
+// QueueWait returns the total time the write and flush requests of the inode waited in its
+// request queue, or 0 if the inode is not opened.
+func (client *ExtentClient) QueueWait(inode uint64) time.Duration {
+	s := client.GetStreamer(inode)
+	if s == nil {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&s.queueWait))
+}
+
 // GetStreamer returns the streamer.
 func (client *ExtentClient) GetStreamer(inode uint64) *Streamer {
 	client.streamerLock.Lock()
@@ -312,8 +506,44 @@ func (client *ExtentClient) GetStreamer(inode uint64) *Streamer {
 	return s
 }
 
+// Stats are the counters of the requests of a client to the data nodes and its caches.
+type Stats struct {
+	Inflight        int64
+	Retries         uint64
+	PartitionErrors uint64
+	MasterErrors    uint64
+	ReadCacheHits   uint64
+	ReadCacheMisses uint64
+	ReadAheadHits   uint64
+	ReadAheadMisses uint64
+	FlashHits       uint64
+	FlashMisses     uint64
+}
+
+// Stats returns the counters of the client. The requests to the data nodes are counted
+// for all the clients of the process.
+func (client *ExtentClient) Stats() *Stats {
+	stats := &Stats{
+		Inflight:        atomic.LoadInt64(&sendInflight),
+		Retries:         atomic.LoadUint64(&sendRetries),
+		PartitionErrors: atomic.LoadUint64(&sendPartitionErrors),
+		MasterErrors:    client.dataWrapper.MasterErrorCount(),
+		ReadAheadHits:   atomic.LoadUint64(&client.readAheadHits),
+		ReadAheadMisses: atomic.LoadUint64(&client.readAheadMisses),
+		FlashHits:       atomic.LoadUint64(&client.flashHits),
+		FlashMisses:     atomic.LoadUint64(&client.flashMisses),
+	}
+	if c := client.readCache; c != nil {
+		stats.ReadCacheHits = atomic.LoadUint64(&c.hits)
+		stats.ReadCacheMisses = atomic.LoadUint64(&c.misses)
+	}
+	return stats
+}
+
 func (client *ExtentClient) GetRate() string {
-	return fmt.Sprintf("read: %v\nwrite: %v\n", getRate(client.readLimiter), getRate(client.writeLimiter))
+	return fmt.Sprintf("read: %v\nwrite: %v\nreadBandwidth: %v\nwriteBandwidth: %v\n",
+		getRate(client.readLimiter), getRate(client.writeLimiter),
+		getBandwidth(client.readBandwidthLimiter), getBandwidth(client.writeBandwidthLimiter))
 }
 
 func getRate(lim *rate.Limiter) string {
@@ -341,6 +571,107 @@ func setRate(lim *rate.Limiter, val int) string {
 	return "unlimited"
 }
 
+func (client *ExtentClient) SetReadBandwidth(val int) string {
+	return setBandwidth(client.readBandwidthLimiter, val)
+}
+
+func (client *ExtentClient) SetWriteBandwidth(val int) string {
+	return setBandwidth(client.writeBandwidthLimiter, val)
+}
+
+func getBandwidth(lim *rate.Limiter) string {
+	if lim.Limit() == rate.Inf {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%vMB/s", int(lim.Limit())/util.MB)
+}
+
+// setBandwidth sets the limit in MB/s, the burst is the bytes of one second.
+func setBandwidth(lim *rate.Limiter, val int) string {
+	if val > 0 {
+		lim.SetBurst(val * util.MB)
+		lim.SetLimit(rate.Limit(val * util.MB))
+		return fmt.Sprintf("%vMB/s", val)
+	}
+	lim.SetLimit(rate.Inf)
+	return "unlimited"
+}
+
+// waitBandwidth blocks until the size bytes are allowed by the limiter.
+func waitBandwidth(lim *rate.Limiter, size int) {
+	if lim.Limit() == rate.Inf {
+		return
+	}
+	ctx := context.Background()
+	for size > 0 {
+		n := size
+		if burst := lim.Burst(); n > burst {
+			n = burst
+		}
+		if lim.WaitN(ctx, n) != nil {
+			// the burst was changed concurrently, try again with the new one
+			continue
+		}
+		size -= n
+	}
+}
+
+// GetReadAheadMax returns the bytes prefetched at most after the sequential reads.
+func (client *ExtentClient) GetReadAheadMax() string {
+	if max := readAheadWindow(atomic.LoadInt64(&client.readAheadMax)); max > 0 {
+		return fmt.Sprintf("%v", max)
+	}
+	return "disabled"
+}
+
+// SetReadAheadMax sets the bytes prefetched at most, 0 uses the default and negative disables.
+// The windows of the open files are shrunk at their next reads.
+func (client *ExtentClient) SetReadAheadMax(val int64) string {
+	atomic.StoreInt64(&client.readAheadMax, val)
+	return client.GetReadAheadMax()
+}
+
+// GetWriteBack returns the dirty limit in bytes and the flush interval of the write-back mode.
+func (client *ExtentClient) GetWriteBack() (dirtyLimit int64, flushInterval time.Duration, err error) {
+	wb := client.writeBack
+	if wb == nil {
+		return 0, 0, ErrWriteBackDisabled
+	}
+	return atomic.LoadInt64(&wb.dirtyLimit), wb.getFlushInterval(), nil
+}
+
+// SetWriteBackDirtyLimit sets the bytes buffered by the client at most, non-positive uses the default.
+func (client *ExtentClient) SetWriteBackDirtyLimit(val int64) (int64, error) {
+	wb := client.writeBack
+	if wb == nil {
+		return 0, ErrWriteBackDisabled
+	}
+	if val <= 0 {
+		val = DefaultWriteBackDirtyLimit
+	}
+	atomic.StoreInt64(&wb.dirtyLimit, val)
+	return val, nil
+}
+
+// SetWriteBackFlushInterval sets the seconds the writes are buffered at most, non-positive uses the default.
+func (client *ExtentClient) SetWriteBackFlushInterval(secs int64) (time.Duration, error) {
+	wb := client.writeBack
+	if wb == nil {
+		return 0, ErrWriteBackDisabled
+	}
+	if secs <= 0 {
+		secs = DefaultWriteBackFlushSeconds
+	}
+	interval := time.Duration(secs) * time.Second
+	atomic.StoreInt64((*int64)(&wb.flushInterval), int64(interval))
+	return interval, nil
+}
+
+// ReadCacheEnabled returns true if the data read is cached on the local disk.
+func (client *ExtentClient) ReadCacheEnabled() bool {
+	return client.readCache != nil
+}
+
 func (client *ExtentClient) Close() error {
 	// release streamers
 	var inodes []uint64
@@ -353,6 +684,10 @@ func (client *ExtentClient) Close() error {
 	for _, inode := range inodes {
 		_ = client.EvictStream(inode)
 	}
+	client.clusterLimiter.Stop()
 	client.dataWrapper.Stop()
+	if client.readCache != nil {
+		client.readCache.Close()
+	}
 	return nil
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tlsutil"
 )
 
 // State machines
@@ -35,6 +36,9 @@ const (
 	ExtentStatusError
 )
 
+// WriteThrottledBackoff is how long the writes back off after the data node throttled or fenced the volume.
+const WriteThrottledBackoff = 500 * time.Millisecond
+
 var (
 	gExtentHandlerID = uint64(0)
 )
@@ -74,7 +78,7 @@ type ExtentHandler struct {
 
 	// Allocated in the sender, and released in the receiver.
 	// Will not be changed.
-	conn *net.TCPConn
+	conn net.Conn
 	dp   *wrapper.DataPartition
 
 	// Issue a signal to this channel when *inflight* hits zero.
@@ -163,6 +167,8 @@ func (eh *ExtentHandler) write(data []byte, offset, size int, direct bool) (ek *
 	for total < size {
 		if eh.packet == nil {
 			eh.packet = NewWritePacket(eh.inode, offset+total, eh.storeMode)
+			eh.packet.TraceContext = eh.stream.trace
+			eh.packet.RequestID = eh.stream.requestID
 			if direct {
 				eh.packet.Opcode = proto.OpSyncWrite
 			}
@@ -236,6 +242,7 @@ func (eh *ExtentHandler) sender() {
 			// fill the packet according to the extent
 			packet.PartitionID = eh.dp.PartitionID
 			packet.ExtentType = uint8(eh.storeMode)
+			packet.ChecksumType = eh.dp.ClientWrapper.ChecksumType()
 			packet.ExtentID = uint64(eh.extID)
 			packet.ExtentOffset = int64(extOffset)
 			packet.Arg = ([]byte)(eh.dp.GetAllAddrs())
@@ -305,7 +312,7 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 	}
 
 	reply := NewReply(packet.ReqID, packet.PartitionID, packet.ExtentID)
-	err := reply.ReadFromConn(eh.conn, proto.ReadDeadlineTime)
+	err := reply.ReadFromConnTimeout(eh.conn, eh.dp.ClientWrapper.RetryPolicy().Timeout)
 	if err != nil {
 		eh.processReplyError(packet, err.Error())
 		return
@@ -313,6 +320,10 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 
 	log.LogDebugf("processReply: get reply, eh(%v) packet(%v) reply(%v)", eh, packet, reply)
 
+	if reply.ResultCode == proto.OpWriteThrottled || reply.ResultCode == proto.OpAgain {
+		time.Sleep(WriteThrottledBackoff)
+	}
+
 	if reply.ResultCode != proto.OpOk {
 		errmsg := fmt.Sprintf("reply NOK: reply(%v)", reply)
 		eh.processReplyError(packet, errmsg)
@@ -495,7 +506,7 @@ func (eh *ExtentHandler) discardPacket(packet *Packet) {
 func (eh *ExtentHandler) allocateExtent() (err error) {
 	var (
 		dp    *wrapper.DataPartition
-		conn  *net.TCPConn
+		conn  net.Conn
 		extID int
 	)
 
@@ -559,16 +570,8 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 	return err
 }
 
-func (eh *ExtentHandler) createConnection(dp *wrapper.DataPartition) (*net.TCPConn, error) {
-	conn, err := net.DialTimeout("tcp", dp.Hosts[0], time.Second)
-	if err != nil {
-		return nil, err
-	}
-	connect := conn.(*net.TCPConn)
-	// TODO unhandled error
-	connect.SetKeepAlive(true)
-	connect.SetNoDelay(true)
-	return connect, nil
+func (eh *ExtentHandler) createConnection(dp *wrapper.DataPartition) (net.Conn, error) {
+	return tlsutil.DialTimeout(dp.Hosts[0], time.Second)
 }
 
 func (eh *ExtentHandler) createExtent(dp *wrapper.DataPartition) (extID int, err error) {
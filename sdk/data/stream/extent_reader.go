@@ -21,8 +21,9 @@ import (
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
-	"hash/crc32"
 	"net"
+	"strings"
+	"time"
 )
 
 // ExtentReader defines the struct of the extent reader.
@@ -31,6 +32,8 @@ type ExtentReader struct {
 	key          *proto.ExtentKey
 	dp           *wrapper.DataPartition
 	followerRead bool
+
+	verifyChecksum bool // see ExtentConfig.VerifyReadChecksum
 }
 
 // NewExtentReader returns a new extent reader.
@@ -51,21 +54,79 @@ func (reader *ExtentReader) String() (m string) {
 
 // Read reads the extent request.
 func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error) {
+	policy := reader.dp.ClientWrapper.RetryPolicy()
+	if policy.HedgeDelay > 0 && reader.followerRead && len(reader.dp.Hosts) > 1 {
+		return reader.hedgedRead(req, policy.HedgeDelay)
+	}
+	return reader.read(req, req.Data, NewStreamConn(reader.dp, reader.followerRead))
+}
+
+type readResult struct {
+	data      []byte
+	readBytes int
+	err       error
+}
+
+// hedgedRead sends the request to another replica if the first one does not reply in the delay,
+// and takes whichever succeeds first.
+func (reader *ExtentReader) hedgedRead(req *ExtentRequest, delay time.Duration) (readBytes int, err error) {
+	results := make(chan readResult, 2)
+	attempt := func(sc *StreamConn) {
+		data := make([]byte, req.Size)
+		n, e := reader.read(req, data, sc)
+		results <- readResult{data: data, readBytes: n, err: e}
+	}
+
+	sc := NewStreamConn(reader.dp, true)
+	firstAddr := sc.currAddr
+	go attempt(sc)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				copy(req.Data, res.data[:res.readBytes])
+				return res.readBytes, nil
+			}
+			readBytes, err = res.readBytes, res.err
+		case <-timer.C:
+			for _, addr := range sortByStatus(reader.dp, false) {
+				if addr != "" && addr != firstAddr {
+					log.LogDebugf("ExtentReader hedgedRead: ino(%v) req(%v) addr(%v) not replied in (%v), try addr(%v)", reader.inode, req, firstAddr, delay, addr)
+					go attempt(&StreamConn{dp: reader.dp, currAddr: addr})
+					pending++
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+func (reader *ExtentReader) read(req *ExtentRequest, data []byte, sc *StreamConn) (readBytes int, err error) {
 	offset := req.FileOffset - int(reader.key.FileOffset) + int(reader.key.ExtentOffset)
 	size := req.Size
 
 	reqPacket := NewReadPacket(reader.key, offset, size, reader.inode, req.FileOffset, reader.followerRead)
-	sc := NewStreamConn(reader.dp, reader.followerRead)
+	reqPacket.ChecksumType = reader.dp.ClientWrapper.ChecksumType()
+	reqPacket.VerifyChecksum = reader.verifyChecksum
+	reqPacket.TraceContext = req.trace
+	reqPacket.RequestID = req.requestID
+	timeout := reader.dp.ClientWrapper.RetryPolicy().Timeout
 
 	log.LogDebugf("ExtentReader Read enter: size(%v) req(%v) reqPacket(%v)", size, req, reqPacket)
 
-	err = sc.Send(reqPacket, func(conn *net.TCPConn) (error, bool) {
+	err = sc.Send(reqPacket, func(conn net.Conn) (error, bool) {
 		readBytes = 0
 		for readBytes < size {
 			replyPacket := NewReply(reqPacket.ReqID, reader.dp.PartitionID, reqPacket.ExtentID)
 			bufSize := util.Min(util.ReadBlockSize, size-readBytes)
-			replyPacket.Data = req.Data[readBytes : readBytes+bufSize]
-			e := replyPacket.readFromConn(conn, proto.ReadDeadlineTime)
+			replyPacket.Data = data[readBytes : readBytes+bufSize]
+			e := replyPacket.readFromConn(conn, timeout)
 			if e != nil {
 				log.LogWarnf("Extent Reader Read: failed to read from connect, ino(%v) req(%v) readBytes(%v) err(%v)", reader.inode, reqPacket, readBytes, e)
 				// Upon receiving TryOtherAddrError, other hosts will be retried.
@@ -78,6 +139,14 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 				return nil, true
 			}
 
+			if reader.verifyChecksum && reader.corrupt(replyPacket) {
+				log.LogWarnf("Extent Reader Read: corrupt data from addr(%v), try another replica, ino(%v) req(%v) reply(%v)",
+					sc.currAddr, reader.inode, reqPacket, replyPacket)
+				// the followers refuse the reads to the leader
+				reqPacket.Opcode = proto.OpStreamFollowerRead
+				return TryOtherAddrError, false
+			}
+
 			e = reader.checkStreamReply(reqPacket, replyPacket)
 			if e != nil {
 				// Dont change the error message, since the caller will
@@ -85,6 +154,9 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 				return e, false
 			}
 
+			if readBytes == 0 && replyPacket.ArgLen > 0 && reqPacket.Opcode == proto.OpStreamFollowerRead {
+				reader.dp.ClientWrapper.UpdateHostLocation(sc.currAddr, replyPacket.Arg[:replyPacket.ArgLen])
+			}
 			readBytes += int(replyPacket.Size)
 		}
 		return nil, false
@@ -98,6 +170,18 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 	return
 }
 
+// corrupt returns true if the data of the reply is corrupt on the disk of the data node, or in the network.
+func (reader *ExtentReader) corrupt(reply *Packet) bool {
+	switch reply.ResultCode {
+	case proto.OpTryOtherAddr:
+		n := util.Min(int(reply.Size), len(reply.Data))
+		return strings.Contains(string(reply.Data[:n]), proto.ErrBlockCrcMismatch.Error())
+	case proto.OpOk:
+		return reply.CRC != reply.Checksum(reply.Data[:reply.Size])
+	}
+	return false
+}
+
 func (reader *ExtentReader) checkStreamReply(request *Packet, reply *Packet) (err error) {
 	if reply.ResultCode == proto.OpTryOtherAddr {
 		return TryOtherAddrError
@@ -116,7 +200,7 @@ func (reader *ExtentReader) checkStreamReply(request *Packet, reply *Packet) (er
 		err = errors.New(fmt.Sprintf("checkStreamReply: inconsistent req and reply, req(%v) reply(%v)", request, reply))
 		return
 	}
-	expectCrc := crc32.ChecksumIEEE(reply.Data[:reply.Size])
+	expectCrc := reply.Checksum(reply.Data[:reply.Size])
 	if reply.CRC != expectCrc {
 		err = errors.New(fmt.Sprintf("checkStreamReply: inconsistent CRC, expectCRC(%v) replyCRC(%v)", expectCrc, reply.CRC))
 		return
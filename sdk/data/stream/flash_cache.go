@@ -0,0 +1,134 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	flashReadTimeout    = time.Second
+	flashNodeRetryDelay = 30 * time.Second // a failed flash node is not read for the delay
+)
+
+// flashNodeFailures keeps when the flash nodes failed to reply, the blocks of a failed node are
+// read from the data nodes directly until it is retried.
+var flashNodeFailures sync.Map // addr -> time.Time
+
+// readFlash reads the request through the flash nodes caching the blocks of the extents. The blocks
+// missed are read from the data nodes, and cached by the flash nodes in the background. The blocks
+// beginning before the extent key are not cached, as readCached.
+func (s *Streamer) readFlash(reader *ExtentReader, req *ExtentRequest, nodes []string) (total int, err error) {
+	ek := req.ExtentKey
+	ekStart := int(ek.ExtentOffset)
+	ekEnd := ekStart + int(ek.Size)
+	start := req.FileOffset - int(ek.FileOffset) + ekStart
+	end := start + req.Size
+	for offset := start; offset < end; {
+		blockStart := offset / util.BlockSize * util.BlockSize
+		blockEnd := util.Min(blockStart+util.BlockSize, ekEnd)
+		readEnd := util.Min(end, blockEnd)
+		data := req.Data[offset-start : readEnd-start]
+		if blockStart >= ekStart && s.client.readFlashBlock(nodes, reader, offset, data, blockEnd-blockStart) {
+			total += len(data)
+			offset = readEnd
+			continue
+		}
+		var n int
+		n, err = reader.Read(&ExtentRequest{FileOffset: offset - ekStart + int(ek.FileOffset), Size: readEnd - offset,
+			Data: data, ExtentKey: ek, trace: req.trace, requestID: req.requestID})
+		total += n
+		if err != nil || n < readEnd-offset {
+			return
+		}
+		offset = readEnd
+	}
+	return
+}
+
+// readFlashBlock reads the range of the extent within a block from the flash node caching the
+// block, it returns false if the block is not cached or the node fails.
+func (client *ExtentClient) readFlashBlock(nodes []string, reader *ExtentReader, extentOffset int, data []byte, fillSize int) (ok bool) {
+	ek := reader.key
+	blockNo := uint32(extentOffset / util.BlockSize)
+	addr := proto.SelectFlashNode(availableFlashNodes(nodes), ek.PartitionId, ek.ExtentId, blockNo)
+	if addr == "" {
+		return
+	}
+	arg, err := json.Marshal(&proto.FlashCacheReadArg{Hosts: reader.dp.Hosts, FillSize: fillSize})
+	if err != nil {
+		return
+	}
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpFlashCacheRead
+	p.PartitionID = ek.PartitionId
+	p.ExtentID = ek.ExtentId
+	p.ExtentOffset = int64(extentOffset)
+	p.Size = uint32(len(data))
+	p.ChecksumType = reader.dp.ClientWrapper.ChecksumType()
+	p.Arg = arg
+	p.ArgLen = uint32(len(arg))
+
+	conn, err := StreamConnPool.GetConnect(addr)
+	if err != nil {
+		flashNodeFailed(addr, err)
+		return
+	}
+	reply := proto.NewPacket()
+	if err = p.WriteToConn(conn); err == nil {
+		err = reply.ReadFromConnTimeout(conn, flashReadTimeout)
+	}
+	if err != nil {
+		StreamConnPool.PutConnect(conn, true)
+		flashNodeFailed(addr, err)
+		return
+	}
+	StreamConnPool.PutConnect(conn, false)
+	if reply.ResultCode != proto.OpOk || reply.ReqID != p.ReqID || int(reply.Size) != len(data) ||
+		reply.CRC != p.Checksum(reply.Data[:reply.Size]) {
+		atomic.AddUint64(&client.flashMisses, 1)
+		return
+	}
+	copy(data, reply.Data[:reply.Size])
+	atomic.AddUint64(&client.flashHits, 1)
+	return true
+}
+
+// availableFlashNodes returns the nodes not failed recently.
+func availableFlashNodes(nodes []string) []string {
+	available := make([]string, 0, len(nodes))
+	for _, addr := range nodes {
+		if failTime, ok := flashNodeFailures.Load(addr); ok {
+			if time.Since(failTime.(time.Time)) < flashNodeRetryDelay {
+				continue
+			}
+			flashNodeFailures.Delete(addr)
+		}
+		available = append(available, addr)
+	}
+	return available
+}
+
+func flashNodeFailed(addr string, err error) {
+	log.LogWarnf("flashNodeFailed: addr(%v) err(%v), read from the data nodes in %v", addr, err, flashNodeRetryDelay)
+	flashNodeFailures.Store(addr, time.Now())
+}
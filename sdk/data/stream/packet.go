@@ -16,11 +16,11 @@ package stream
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/wrapper"
 	"github.com/cubefs/cubefs/util"
-	"hash/crc32"
 	"io"
 	"net"
 	"time"
@@ -35,6 +35,10 @@ type Packet struct {
 
 // String returns the string format of the packet.
 func (p *Packet) String() string {
+	if p.RequestID.IsValid() {
+		return fmt.Sprintf("ReqID(%v)RequestID(%v)Op(%v)Inode(%v)FileOffset(%v)Size(%v)PartitionID(%v)ExtentID(%v)ExtentOffset(%v)CRC(%v)ResultCode(%v)",
+			p.ReqID, p.RequestID, p.GetOpMsg(), p.inode, p.KernelOffset, p.Size, p.PartitionID, p.ExtentID, p.ExtentOffset, p.CRC, p.GetResultMsg())
+	}
 	return fmt.Sprintf("ReqID(%v)Op(%v)Inode(%v)FileOffset(%v)Size(%v)PartitionID(%v)ExtentID(%v)ExtentOffset(%v)CRC(%v)ResultCode(%v)",
 		p.ReqID, p.GetOpMsg(), p.inode, p.KernelOffset, p.Size, p.PartitionID, p.ExtentID, p.ExtentOffset, p.CRC, p.GetResultMsg())
 }
@@ -68,6 +72,7 @@ func NewOverwritePacket(dp *wrapper.DataPartition, extentID uint64, extentOffset
 	p.ArgLen = 0
 	p.RemainingFollowers = 0
 	p.Opcode = proto.OpRandomWrite
+	p.ChecksumType = dp.ClientWrapper.ChecksumType()
 	p.inode = inode
 	p.KernelOffset = uint64(fileOffset)
 	p.Data, _ = proto.Buffers.Get(util.BlockSize)
@@ -112,6 +117,23 @@ func NewCreateExtentPacket(dp *wrapper.DataPartition, inode uint64) *Packet {
 	return p
 }
 
+// NewPreallocExtentPacket returns a new packet to preallocate an extent from the given offset.
+func NewPreallocExtentPacket(dp *wrapper.DataPartition, extentID uint64, extentOffset, size int) *Packet {
+	p := new(Packet)
+	p.PartitionID = dp.PartitionID
+	p.Magic = proto.ProtoMagic
+	p.ExtentType = proto.NormalExtentType
+	p.ExtentID = extentID
+	p.Arg = ([]byte)(dp.GetAllAddrs())
+	p.ArgLen = uint32(len(p.Arg))
+	p.RemainingFollowers = uint8(len(dp.Hosts) - 1)
+	p.ReqID = proto.GenerateRequestID()
+	p.Opcode = proto.OpPreallocExtent
+	p.Data, _ = json.Marshal(&proto.ExtentKey{ExtentOffset: uint64(extentOffset), Size: uint32(size)})
+	p.Size = uint32(len(p.Data))
+	return p
+}
+
 // NewReply returns a new reply packet. TODO rename to NewReplyPacket?
 func NewReply(reqID int64, partitionID uint64, extentID uint64) *Packet {
 	p := new(Packet)
@@ -138,13 +160,15 @@ func (p *Packet) isValidReadReply(q *Packet) bool {
 }
 
 func (p *Packet) writeToConn(conn net.Conn) error {
-	p.CRC = crc32.ChecksumIEEE(p.Data[:p.Size])
+	p.CRC = p.Checksum(p.Data[:p.Size])
 	return p.WriteToConn(conn)
 }
 
-func (p *Packet) readFromConn(c net.Conn, deadlineTime time.Duration) (err error) {
-	if deadlineTime != proto.NoReadDeadlineTime {
-		c.SetReadDeadline(time.Now().Add(deadlineTime * time.Second))
+func (p *Packet) readFromConn(c net.Conn, timeout time.Duration) (err error) {
+	if timeout != 0 {
+		c.SetReadDeadline(time.Now().Add(timeout))
+	} else {
+		c.SetReadDeadline(time.Time{})
 	}
 	header, _ := proto.Buffers.Get(util.PacketHeaderSize)
 	defer proto.Buffers.Put(header)
@@ -154,6 +178,9 @@ func (p *Packet) readFromConn(c net.Conn, deadlineTime time.Duration) (err error
 	if err = p.UnmarshalHeader(header); err != nil {
 		return
 	}
+	if err = p.ReadHeaderExtensions(c); err != nil {
+		return
+	}
 
 	if p.ArgLen > 0 {
 		if err = readToBuffer(c, &p.Arg, int(p.ArgLen)); err != nil {
@@ -0,0 +1,355 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// A ReadCache keeps the blocks of the extents read by the client in a file on a local disk,
+// so that the repeated reads of the same data are served locally instead of by the data nodes.
+//
+// Each slot of the file holds a block with a header of its key, its length and the CRC of both,
+// the index of the slots is rebuilt from the headers when the client restarts, and a block is
+// dropped when its CRC does not match. The blocks are invalidated when the client overwrites
+// them, the overwrites of the other clients are not seen until the blocks are evicted or expire.
+
+const (
+	ReadCacheFileName = "READ_CACHE"
+
+	readCacheHeaderSize = 32
+	readCacheSlotSize   = readCacheHeaderSize + util.BlockSize
+	readCacheEpochCount = 256
+)
+
+type readCacheKey struct {
+	partitionID uint64
+	extentID    uint64
+	blockNo     uint32
+}
+
+type readCacheEntry struct {
+	key       readCacheKey
+	slot      int64
+	length    int
+	cacheTime uint32 // unix seconds when the block is cached
+}
+
+type ReadCache struct {
+	sync.Mutex
+	path      string
+	capacity  int64
+	fp        *os.File
+	index     map[readCacheKey]*list.Element
+	lru       *list.List // front is the most recently used
+	freeSlots []int64
+	epochs    [readCacheEpochCount]uint64
+	expiry    uint32 // seconds the blocks are cached at most, 0 if they do not expire
+	hits      uint64
+	misses    uint64
+}
+
+// NewReadCache opens the read cache of the volume in the directory, the blocks cached
+// before are kept if the capacity is not reduced.
+func NewReadCache(dir, volume string, capacity int64) (c *ReadCache, err error) {
+	slotCnt := capacity / readCacheSlotSize
+	if slotCnt <= 0 {
+		return nil, fmt.Errorf("read cache capacity(%v) is less than a block", capacity)
+	}
+	dir = path.Join(dir, volume)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	c = &ReadCache{
+		path:     dir,
+		capacity: slotCnt * readCacheSlotSize,
+		index:    make(map[readCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+	if c.fp, err = os.OpenFile(path.Join(dir, ReadCacheFileName), os.O_CREATE|os.O_RDWR, 0644); err != nil {
+		return
+	}
+	if err = c.fp.Truncate(c.capacity); err != nil {
+		c.fp.Close()
+		return
+	}
+	c.load(slotCnt)
+	log.LogInfof("action[NewReadCache] path(%v) capacity(%v) cached(%v)", dir, c.capacity, len(c.index))
+	return
+}
+
+// load rebuilds the index from the headers of the slots, the data is checked when it is read.
+func (c *ReadCache) load(slotCnt int64) {
+	header := make([]byte, readCacheHeaderSize)
+	for slot := slotCnt - 1; slot >= 0; slot-- {
+		if _, err := c.fp.ReadAt(header, slot*readCacheSlotSize); err != nil {
+			c.freeSlots = append(c.freeSlots, slot)
+			continue
+		}
+		key, length, cacheTime := decodeReadCacheHeader(header)
+		if length <= 0 || length > util.BlockSize || c.index[key] != nil {
+			c.freeSlots = append(c.freeSlots, slot)
+			continue
+		}
+		c.index[key] = c.lru.PushBack(&readCacheEntry{key: key, slot: slot, length: length, cacheTime: cacheTime})
+	}
+}
+
+func (c *ReadCache) Close() {
+	c.Lock()
+	defer c.Unlock()
+	log.LogInfof("action[ReadCache.Close] path(%v) cached(%v) hits(%v) misses(%v)",
+		c.path, len(c.index), atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses))
+	c.fp.Close()
+}
+
+// SetExpiry sets how long the blocks are cached at most, the expired blocks are dropped when they
+// are read. The blocks never expire if it is 0.
+func (c *ReadCache) SetExpiry(expiry time.Duration) {
+	atomic.StoreUint32(&c.expiry, uint32(expiry/time.Second))
+}
+
+// Get reads the block of the extent into the buffer of a block size, it returns false if at least
+// the given length of the block is not cached.
+func (c *ReadCache) Get(partitionID, extentID uint64, blockNo uint32, block []byte, length int) bool {
+	return c.read(readCacheKey{partitionID: partitionID, extentID: extentID, blockNo: blockNo}, block, length)
+}
+
+// Put caches the data of the block of the extent.
+func (c *ReadCache) Put(partitionID, extentID uint64, blockNo uint32, data []byte) {
+	key := readCacheKey{partitionID: partitionID, extentID: extentID, blockNo: blockNo}
+	c.insert(key, data, atomic.LoadUint64(c.epoch(key)))
+}
+
+// Usage returns the capacity of the cache, and the bytes of the slots used by the blocks.
+func (c *ReadCache) Usage() (total, used uint64) {
+	c.Lock()
+	defer c.Unlock()
+	return uint64(c.capacity), uint64(c.capacity - int64(len(c.freeSlots))*readCacheSlotSize)
+}
+
+// Stats returns the reads served by the cache and the ones missed.
+func (c *ReadCache) Stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+}
+
+func (c *ReadCache) epoch(key readCacheKey) *uint64 {
+	return &c.epochs[(key.partitionID*31+key.extentID*17+uint64(key.blockNo))%readCacheEpochCount]
+}
+
+func encodeReadCacheHeader(header []byte, key readCacheKey, data []byte, cacheTime uint32) {
+	binary.BigEndian.PutUint64(header[4:12], key.partitionID)
+	binary.BigEndian.PutUint64(header[12:20], key.extentID)
+	binary.BigEndian.PutUint32(header[20:24], key.blockNo)
+	binary.BigEndian.PutUint32(header[24:28], uint32(len(data)))
+	binary.BigEndian.PutUint32(header[28:32], cacheTime)
+	crc := crc32.ChecksumIEEE(header[4:readCacheHeaderSize])
+	binary.BigEndian.PutUint32(header[0:4], crc32.Update(crc, crc32.IEEETable, data))
+}
+
+func decodeReadCacheHeader(header []byte) (key readCacheKey, length int, cacheTime uint32) {
+	key.partitionID = binary.BigEndian.Uint64(header[4:12])
+	key.extentID = binary.BigEndian.Uint64(header[12:20])
+	key.blockNo = binary.BigEndian.Uint32(header[20:24])
+	length = int(binary.BigEndian.Uint32(header[24:28]))
+	cacheTime = binary.BigEndian.Uint32(header[28:32])
+	return
+}
+
+// read reads a block into the buffer of a block size, ok is false if at least the given
+// length of the block is not cached.
+func (c *ReadCache) read(key readCacheKey, block []byte, length int) (ok bool) {
+	c.Lock()
+	elem := c.index[key]
+	if elem == nil || elem.Value.(*readCacheEntry).length < length {
+		c.Unlock()
+		atomic.AddUint64(&c.misses, 1)
+		return
+	}
+	entry := *elem.Value.(*readCacheEntry)
+	if expiry := atomic.LoadUint32(&c.expiry); expiry > 0 && uint32(time.Now().Unix())-entry.cacheTime > expiry {
+		c.Unlock()
+		c.drop(key, entry.slot)
+		atomic.AddUint64(&c.misses, 1)
+		return
+	}
+	c.lru.MoveToFront(elem)
+	c.Unlock()
+
+	// the slot may be reused meanwhile, which the header tells
+	buf := make([]byte, readCacheHeaderSize+entry.length)
+	if _, err := c.fp.ReadAt(buf, entry.slot*readCacheSlotSize); err != nil {
+		log.LogWarnf("action[ReadCache.read] path(%v) key(%v) err(%v)", c.path, key, err)
+		c.drop(key, entry.slot)
+		atomic.AddUint64(&c.misses, 1)
+		return
+	}
+	cachedKey, cachedLength, _ := decodeReadCacheHeader(buf)
+	crc := crc32.ChecksumIEEE(buf[4:])
+	if cachedKey != key || cachedLength != entry.length || crc != binary.BigEndian.Uint32(buf[0:4]) {
+		log.LogWarnf("action[ReadCache.read] path(%v) key(%v) slot(%v) is corrupted or reused", c.path, key, entry.slot)
+		c.drop(key, entry.slot)
+		atomic.AddUint64(&c.misses, 1)
+		return
+	}
+	copy(block, buf[readCacheHeaderSize:])
+	atomic.AddUint64(&c.hits, 1)
+	return true
+}
+
+// drop removes the block from the index if it is still in the slot.
+func (c *ReadCache) drop(key readCacheKey, slot int64) {
+	c.Lock()
+	defer c.Unlock()
+	if elem := c.index[key]; elem != nil && elem.Value.(*readCacheEntry).slot == slot {
+		c.lru.Remove(elem)
+		delete(c.index, key)
+		c.freeSlots = append(c.freeSlots, slot)
+	}
+}
+
+// insert caches the data of a block read from the data nodes, unless the block has been
+// invalidated since the epoch was taken before the read.
+func (c *ReadCache) insert(key readCacheKey, data []byte, epoch uint64) {
+	c.Lock()
+	if atomic.LoadUint64(c.epoch(key)) != epoch {
+		c.Unlock()
+		return
+	}
+	if elem := c.index[key]; elem != nil && elem.Value.(*readCacheEntry).length >= len(data) {
+		c.Unlock()
+		return
+	}
+	c.removeLocked(key)
+	var slot int64
+	if n := len(c.freeSlots); n > 0 {
+		slot = c.freeSlots[n-1]
+		c.freeSlots = c.freeSlots[:n-1]
+	} else {
+		elem := c.lru.Back()
+		evicted := elem.Value.(*readCacheEntry)
+		c.lru.Remove(elem)
+		delete(c.index, evicted.key)
+		slot = evicted.slot
+	}
+	c.Unlock()
+
+	cacheTime := uint32(time.Now().Unix())
+	buf := make([]byte, readCacheHeaderSize+len(data))
+	encodeReadCacheHeader(buf, key, data, cacheTime)
+	copy(buf[readCacheHeaderSize:], data)
+	if _, err := c.fp.WriteAt(buf, slot*readCacheSlotSize); err != nil {
+		log.LogWarnf("action[ReadCache.insert] path(%v) key(%v) err(%v)", c.path, key, err)
+		c.Lock()
+		c.freeSlots = append(c.freeSlots, slot)
+		c.Unlock()
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if atomic.LoadUint64(c.epoch(key)) != epoch || c.index[key] != nil {
+		c.freeSlots = append(c.freeSlots, slot)
+		return
+	}
+	c.index[key] = c.lru.PushFront(&readCacheEntry{key: key, slot: slot, length: len(data), cacheTime: cacheTime})
+}
+
+// removeLocked removes the block from the index and clears its header, so that it is
+// not loaded again after a restart. Must be called with the lock held.
+func (c *ReadCache) removeLocked(key readCacheKey) {
+	elem := c.index[key]
+	if elem == nil {
+		return
+	}
+	entry := elem.Value.(*readCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.index, key)
+	if _, err := c.fp.WriteAt(make([]byte, readCacheHeaderSize), entry.slot*readCacheSlotSize); err != nil {
+		log.LogWarnf("action[ReadCache.remove] path(%v) key(%v) err(%v)", c.path, key, err)
+	}
+	c.freeSlots = append(c.freeSlots, entry.slot)
+}
+
+// invalidate removes the blocks of the extent overlapping the range written.
+func (c *ReadCache) invalidate(partitionID, extentID uint64, offset, size int) {
+	if c == nil || size <= 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for blockNo := offset / util.BlockSize; blockNo*util.BlockSize < offset+size; blockNo++ {
+		key := readCacheKey{partitionID: partitionID, extentID: extentID, blockNo: uint32(blockNo)}
+		atomic.AddUint64(c.epoch(key), 1)
+		c.removeLocked(key)
+	}
+}
+
+// readCached reads the request through the read cache, the blocks missed are read from the data
+// nodes as a whole within the extent key and cached.
+func (s *Streamer) readCached(reader *ExtentReader, req *ExtentRequest) (total int, err error) {
+	c := s.client.readCache
+	ek := req.ExtentKey
+	ekStart := int(ek.ExtentOffset)
+	ekEnd := ekStart + int(ek.Size)
+	start := req.FileOffset - int(ek.FileOffset) + ekStart
+	end := start + req.Size
+	block := make([]byte, util.BlockSize)
+	for offset := start; offset < end; {
+		blockStart := offset / util.BlockSize * util.BlockSize
+		blockEnd := util.Min(blockStart+util.BlockSize, ekEnd)
+		readEnd := util.Min(end, blockEnd)
+		if blockStart < ekStart {
+			// the block begins before the extent key, it is not cached
+			var n int
+			n, err = reader.Read(&ExtentRequest{FileOffset: offset - ekStart + int(ek.FileOffset), Size: readEnd - offset,
+				Data: req.Data[offset-start : readEnd-start], ExtentKey: ek, trace: req.trace, requestID: req.requestID})
+			total += n
+			if err != nil || n < readEnd-offset {
+				return
+			}
+			offset = readEnd
+			continue
+		}
+		key := readCacheKey{partitionID: ek.PartitionId, extentID: ek.ExtentId, blockNo: uint32(blockStart / util.BlockSize)}
+		if !c.read(key, block, readEnd-blockStart) {
+			epoch := atomic.LoadUint64(c.epoch(key))
+			var n int
+			n, err = reader.Read(&ExtentRequest{FileOffset: blockStart - ekStart + int(ek.FileOffset), Size: blockEnd - blockStart,
+				Data: block[:blockEnd-blockStart], ExtentKey: ek, trace: req.trace, requestID: req.requestID})
+			if err != nil || n < blockEnd-blockStart {
+				if n > offset-blockStart {
+					total += copy(req.Data[offset-start:readEnd-start], block[offset-blockStart:util.Min(n, readEnd-blockStart)])
+				}
+				return
+			}
+			c.insert(key, block[:n], epoch)
+		}
+		total += copy(req.Data[offset-start:readEnd-start], block[offset-blockStart:readEnd-blockStart])
+		offset = readEnd
+	}
+	return
+}
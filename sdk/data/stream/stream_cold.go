@@ -0,0 +1,56 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"syscall"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// readCold fills the hole of the file at the offset with the data migrated to the cold tier. The part
+// of the hole beyond the size of the data in the cold tier is left as it is.
+func (s *Streamer) readCold(data []byte, offset int) (err error) {
+	cold := s.extents.Cold()
+	if cold == nil || uint64(offset) >= cold.Size || len(data) == 0 {
+		return
+	}
+	if s.client.blobStore == nil {
+		log.LogErrorf("readCold: ino(%v) offset(%v) size(%v) blob store is not configured", s.inode, offset, len(data))
+		return syscall.EIO
+	}
+	start, end := uint64(offset), uint64(offset+len(data))
+	if end > cold.Size {
+		end = cold.Size
+	}
+	for _, blob := range cold.Blobs {
+		from, to := blob.Offset, blob.Offset+blob.Size
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		if from >= to {
+			continue
+		}
+		if err = s.client.blobStore.Get(blob.Location, from-blob.Offset, data[from-start:to-start]); err != nil {
+			log.LogErrorf("readCold: ino(%v) offset(%v) size(%v) blob(%v) err(%v)", s.inode, from, to-from, blob.Offset, err)
+			return syscall.EIO
+		}
+	}
+	log.LogDebugf("readCold: ino(%v) offset(%v) size(%v)", s.inode, start, end-start)
+	return
+}
@@ -20,6 +20,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/wrapper"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
@@ -30,12 +31,27 @@ var (
 	TryOtherAddrError = errors.New("TryOtherAddrError")
 )
 
+// Counters of the requests sent to the data nodes by all the extent clients of the process.
+var (
+	sendInflight        int64  // requests not replied yet
+	sendRetries         uint64 // requests sent again after a failure
+	sendPartitionErrors uint64 // requests failed after all the retries
+)
+
 const (
 	StreamSendMaxRetry      = 200
 	StreamSendSleepInterval = 100 * time.Millisecond
 )
 
-type GetReplyFunc func(conn *net.TCPConn) (err error, again bool)
+// DefaultRetryPolicy of the requests to the data nodes.
+var DefaultRetryPolicy = util.RetryPolicy{
+	Timeout:    proto.ReadDeadlineTime * time.Second,
+	MaxRetries: StreamSendMaxRetry,
+	Backoff:    StreamSendSleepInterval,
+	MaxBackoff: StreamSendSleepInterval,
+}
+
+type GetReplyFunc func(conn net.Conn) (err error, again bool)
 
 // StreamConn defines the struct of the stream connection.
 type StreamConn struct {
@@ -104,15 +120,27 @@ func (sc *StreamConn) String() string {
 // Send send the given packet over the network through the stream connection until success
 // or the maximum number of retries is reached.
 func (sc *StreamConn) Send(req *Packet, getReply GetReplyFunc) (err error) {
-	for i := 0; i < StreamSendMaxRetry; i++ {
+	atomic.AddInt64(&sendInflight, 1)
+	defer atomic.AddInt64(&sendInflight, -1)
+	policy := sc.dp.ClientWrapper.RetryPolicy()
+	start := time.Now()
+	var i int
+	for i = 0; i <= policy.MaxRetries; i++ {
+		if i > 0 {
+			atomic.AddUint64(&sendRetries, 1)
+		}
 		err = sc.sendToPartition(req, getReply)
 		if err == nil {
 			return
 		}
 		log.LogWarnf("StreamConn Send: err(%v)", err)
-		time.Sleep(StreamSendSleepInterval)
+		if policy.Expired(start) {
+			break
+		}
+		time.Sleep(policy.Interval(i + 1))
 	}
-	return errors.New(fmt.Sprintf("StreamConn Send: retried %v times and still failed, sc(%v) reqPacket(%v)", StreamSendMaxRetry, sc, req))
+	atomic.AddUint64(&sendPartitionErrors, 1)
+	return errors.New(fmt.Sprintf("StreamConn Send: retried %v times and still failed, sc(%v) reqPacket(%v)", i, sc, req))
 }
 
 func (sc *StreamConn) sendToPartition(req *Packet, getReply GetReplyFunc) (err error) {
@@ -157,8 +185,9 @@ func (sc *StreamConn) sendToPartition(req *Packet, getReply GetReplyFunc) (err e
 	return errors.New(fmt.Sprintf("sendToPatition Failed: sc(%v) reqPacket(%v)", sc, req))
 }
 
-func (sc *StreamConn) sendToConn(conn *net.TCPConn, req *Packet, getReply GetReplyFunc) (err error) {
-	for i := 0; i < StreamSendMaxRetry; i++ {
+func (sc *StreamConn) sendToConn(conn net.Conn, req *Packet, getReply GetReplyFunc) (err error) {
+	policy := sc.dp.ClientWrapper.RetryPolicy()
+	for i := 0; i <= policy.MaxRetries; i++ {
 		log.LogDebugf("sendToConn: send to addr(%v), reqPacket(%v)", sc.currAddr, req)
 		err = req.WriteToConn(conn)
 		if err != nil {
@@ -177,7 +206,7 @@ func (sc *StreamConn) sendToConn(conn *net.TCPConn, req *Packet, getReply GetRep
 		}
 
 		log.LogWarnf("sendToConn: getReply error and will RETRY, sc(%v) err(%v)", sc, err)
-		time.Sleep(StreamSendSleepInterval)
+		time.Sleep(policy.Interval(i + 1))
 	}
 
 	log.LogDebugf("sendToConn exit: send to addr(%v) reqPacket(%v) err(%v)", sc.currAddr, req, err)
@@ -0,0 +1,110 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// The file contents are encrypted by the client with AES-256 in the CTR mode if the encryption key
+// is given, so the data nodes and the meta nodes only store the ciphertext. Each file is encrypted
+// with its own key derived from the encryption key, the volume and the inode, and the counter of
+// the CTR mode is the block of the offset in the file. So the files keep their sizes, and any
+// range of a file can be read or written without the neighbouring data. The holes are not
+// encrypted and are read as zero.
+//
+// The file names and the attributes are not encrypted. Overwriting a range of a file reuses its
+// key stream, so the encryption does not hide which bytes are changed.
+
+const EncryptKeySize = 32
+
+// LoadEncryptKey reads the encryption key in hex from the file, e.g. provisioned by a KMS agent.
+func LoadEncryptKey(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key in %v: %v", path, err)
+	}
+	if len(key) != EncryptKeySize {
+		return nil, fmt.Errorf("invalid encryption key in %v: %v bytes, expect %v", path, len(key), EncryptKeySize)
+	}
+	return key, nil
+}
+
+// volumeEncryptKey derives the key of the volume from the encryption key.
+func volumeEncryptKey(key []byte, volume string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(volume))
+	return mac.Sum(nil)
+}
+
+// newFileCipher returns the cipher of the inode, or nil if the encryption is disabled.
+func (client *ExtentClient) newFileCipher(inode uint64) cipher.Block {
+	if client.encryptKey == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, client.encryptKey)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], inode)
+	mac.Write(buf[:])
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		// never happens since the derived key is always of a valid size
+		panic(err)
+	}
+	return block
+}
+
+// xorKeyStream encrypts or decrypts in place the data at the offset of the file.
+func xorKeyStream(block cipher.Block, data []byte, offset int) {
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[8:], uint64(offset/aes.BlockSize))
+	ctr := cipher.NewCTR(block, iv[:])
+	if skip := offset % aes.BlockSize; skip > 0 {
+		var pad [aes.BlockSize]byte
+		ctr.XORKeyStream(pad[:skip], pad[:skip])
+	}
+	ctr.XORKeyStream(data, data)
+}
+
+// encrypt returns the ciphertext of the data written at the offset, the data is not modified.
+func (s *Streamer) encrypt(data []byte, offset int) []byte {
+	if s.cipher == nil {
+		return data
+	}
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	xorKeyStream(s.cipher, buf, offset)
+	return buf
+}
+
+// decrypt decrypts in place the data read at the offset.
+func (s *Streamer) decrypt(data []byte, offset int) {
+	if s.cipher == nil || len(data) == 0 {
+		return
+	}
+	xorKeyStream(s.cipher, data, offset)
+}
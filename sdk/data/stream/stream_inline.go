@@ -0,0 +1,124 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"io"
+	"syscall"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// canWriteInline checks whether the write keeps the file small enough to be stored inline in the inode.
+// Only the files which have never been written to the extents or migrated to the cold tier are stored inline.
+func (s *Streamer) canWriteInline(offset, size int) bool {
+	if s.client.inlineSize <= 0 || s.handler != nil || s.dirtylist.Len() > 0 || s.extents.HasExtents() ||
+		s.extents.Cold() != nil {
+		return false
+	}
+	filesize, _ := s.extents.Size()
+	return offset+size <= s.client.inlineSize && filesize <= s.client.inlineSize
+}
+
+// writeInline writes the data to the local inline data of the file, which is sent to
+// the meta node when the streamer is flushed.
+func (s *Streamer) writeInline(data []byte, offset, size int, direct bool) (total int, err error) {
+	filesize, _ := s.extents.Size()
+	end := offset + size
+	if end < filesize {
+		end = filesize
+	}
+	// the inline data may be read concurrently, so never modify it in place
+	buf := make([]byte, end)
+	n := copy(buf, s.extents.Inline())
+	copy(buf[offset:], data[:size])
+	if offset > n {
+		// the gap is read as zero from the inline data, so it is stored encrypted
+		s.decrypt(buf[n:offset], n)
+	}
+	s.extents.SetInline(buf)
+	s.inlineDirty = true
+	if end > filesize {
+		s.extents.SetSize(uint64(end), false)
+	}
+	log.LogDebugf("writeInline: ino(%v) offset(%v) size(%v) filesize(%v)", s.inode, offset, size, end)
+
+	if direct {
+		if err = s.flush(); err != nil {
+			return
+		}
+	}
+	return size, nil
+}
+
+// flushInline stores the dirty inline data in the inode. If the meta node refuses it because
+// the file has got extents in the meanwhile, the inline data is moved to the extents instead.
+func (s *Streamer) flushInline() (err error) {
+	if !s.inlineDirty {
+		return
+	}
+	err = s.client.writeInline(s.inode, s.extents.Inline())
+	if err == syscall.EINVAL {
+		log.LogWarnf("flushInline: ino(%v) refused by meta node, move the inline data to the extents", s.inode)
+		return s.migrateInline()
+	}
+	if err != nil {
+		log.LogErrorf("flushInline: ino(%v) err(%v)", s.inode, err)
+		return
+	}
+	s.inlineDirty = false
+	return
+}
+
+// migrateInline moves the inline data to the extents before the file grows beyond the inline size.
+// The meta node drops the inline data once the extent keys are appended to the inode.
+func (s *Streamer) migrateInline() (err error) {
+	inline := s.extents.Inline()
+	if inline == nil {
+		return
+	}
+	s.extents.SetInline(nil)
+	if len(inline) > 0 {
+		if _, err = s.doWrite(inline, 0, len(inline), false); err != nil {
+			s.extents.SetInline(inline)
+			log.LogErrorf("migrateInline: ino(%v) size(%v) err(%v)", s.inode, len(inline), err)
+			return
+		}
+	}
+	s.inlineDirty = false
+	log.LogDebugf("migrateInline: ino(%v) size(%v)", s.inode, len(inline))
+	return
+}
+
+// readInline reads the data stored inline, the range beyond the inline data is filled with zero.
+func (s *Streamer) readInline(inline, data []byte, offset, size int) (total int, err error) {
+	filesize, _ := s.extents.Size()
+	if offset+size > filesize {
+		if offset > filesize {
+			return
+		}
+		size = filesize - offset
+		err = io.EOF
+	}
+	var n int
+	if offset < len(inline) {
+		n = copy(data[:size], inline[offset:])
+		s.decrypt(data[:n], offset)
+	}
+	for i := n; i < size; i++ {
+		data[i] = 0
+	}
+	return size, err
+}
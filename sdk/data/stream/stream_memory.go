@@ -0,0 +1,54 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"sync/atomic"
+)
+
+// The memory of the extent caches and the write buffers of a client is estimated for the
+// memory budget of the fuse client, which releases the idle streamers and caps the write
+// buffers when the budget is exceeded.
+
+const extentKeyMemSize = 160 // estimated bytes of an extent key in the cache, including the btree
+
+// MemoryUsage returns the estimated bytes of the extent caches of the streamers, and the
+// bytes of the writes buffered in the write-back mode.
+func (client *ExtentClient) MemoryUsage() (extentCache, writeBuffer int64) {
+	client.streamerLock.Lock()
+	for _, s := range client.streamers {
+		extentCache += int64(s.extents.Len()) * extentKeyMemSize
+	}
+	client.streamerLock.Unlock()
+	if wb := client.writeBack; wb != nil {
+		writeBuffer = atomic.LoadInt64(&wb.dirtyBytes)
+	}
+	return
+}
+
+// SetMemoryPressure makes the streamers of the closed files released with their extent caches
+// at their next check instead of after being idle for a while, and the buffered writes flushed.
+func (client *ExtentClient) SetMemoryPressure(pressure bool) {
+	var val int32
+	if pressure {
+		val = 1
+	}
+	atomic.StoreInt32(&client.memPressure, val)
+}
+
+// SetWriteBufferLimit caps the bytes buffered in the write-back mode below the dirty limit, 0 for no cap.
+func (client *ExtentClient) SetWriteBufferLimit(limit int64) {
+	atomic.StoreInt64(&client.writeBufferLimit, limit)
+}
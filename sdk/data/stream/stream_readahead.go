@@ -0,0 +1,177 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tracing"
+)
+
+// The reads of a streamer are prefetched when they are sequential. The window of the
+// prefetch starts small and doubles with each sequential read up to the maximum, and
+// is reset by a random read, so that the random reads do not waste any bandwidth.
+
+const (
+	DefaultReadAheadMax = 4 * util.MB
+
+	readAheadMinWindow = 128 * util.KB
+	// The reads of the kernel readahead in flight together may arrive out of order.
+	readAheadSlack = 1 * util.MB
+)
+
+type readAhead struct {
+	sync.Mutex
+	nextOffset int // where the next sequential read starts
+	window     int // bytes prefetched ahead of the reads, 0 if the reads are random
+	bufs       []*readAheadBuf
+}
+
+// readAheadBuf is the data of a prefetch, contiguous with the previous one.
+type readAheadBuf struct {
+	offset int
+	size   int
+	data   []byte // valid when done is closed, shorter than size at the end of the file
+	done   chan struct{}
+}
+
+// readAheadWindow returns the maximum window of the config value, 0 if the reads are not prefetched.
+func readAheadWindow(max int64) int {
+	if max == 0 {
+		return DefaultReadAheadMax
+	}
+	if max < 0 {
+		return 0
+	}
+	if max < readAheadMinWindow {
+		return readAheadMinWindow
+	}
+	return int(max)
+}
+
+// invalidate drops the data prefetched, e.g. when the file is modified.
+func (ra *readAhead) invalidate() {
+	ra.Lock()
+	ra.bufs = nil
+	ra.Unlock()
+}
+
+// observe updates the window with the read, and returns the buffers to serve it from.
+func (ra *readAhead) observe(offset, size, maxWindow int) (bufs []*readAheadBuf) {
+	ra.Lock()
+	defer ra.Unlock()
+	if offset+readAheadSlack >= ra.nextOffset && offset <= ra.nextOffset+readAheadSlack {
+		if ra.window == 0 {
+			ra.window = readAheadMinWindow
+		} else if ra.window < maxWindow {
+			ra.window *= 2
+		}
+		if ra.window > maxWindow {
+			ra.window = maxWindow
+		}
+	} else {
+		ra.window = 0
+		ra.bufs = nil
+	}
+	if end := offset + size; end > ra.nextOffset || ra.window == 0 {
+		ra.nextOffset = end
+	}
+	return append(bufs, ra.bufs...)
+}
+
+// next drops the buffers behind the end of the read, and returns the buffer to fill
+// to keep a window of data ahead of it before the end of the file.
+func (ra *readAhead) next(end, filesize int) (buf *readAheadBuf) {
+	ra.Lock()
+	defer ra.Unlock()
+	kept := ra.bufs[:0]
+	for _, b := range ra.bufs {
+		if b.offset+b.size > end {
+			kept = append(kept, b)
+		}
+	}
+	ra.bufs = kept
+	if ra.window == 0 {
+		return nil
+	}
+	ahead := end
+	if len(ra.bufs) > 0 {
+		last := ra.bufs[len(ra.bufs)-1]
+		ahead = last.offset + last.size
+	}
+	if ahead-end >= ra.window/2 || ahead >= filesize {
+		return nil
+	}
+	buf = &readAheadBuf{offset: ahead, size: ra.window, done: make(chan struct{})}
+	ra.bufs = append(ra.bufs, buf)
+	return
+}
+
+// copyTo copies the prefetched data at the offset, it returns the bytes copied.
+func (b *readAheadBuf) copyTo(data []byte, offset int) int {
+	if offset < b.offset || offset >= b.offset+b.size {
+		return 0
+	}
+	<-b.done
+	if offset >= b.offset+len(b.data) {
+		return 0
+	}
+	return copy(data, b.data[offset-b.offset:])
+}
+
+// readWithReadAhead serves the read from the data prefetched, reads the rest, and
+// prefetches the data ahead of the read if the reads are sequential.
+func (s *Streamer) readWithReadAhead(data []byte, offset int, size int, maxWindow int, trace tracing.SpanContext, requestID reqid.ID) (total int, err error) {
+	ra := s.readAhead
+	for _, b := range ra.observe(offset, size, maxWindow) {
+		if total == size {
+			break
+		}
+		total += b.copyTo(data[total:size], offset+total)
+	}
+	if total < size {
+		atomic.AddUint64(&s.client.readAheadMisses, 1)
+		var n int
+		n, err = s.read(data[total:size], offset+total, size-total, trace, requestID)
+		total += n
+	} else {
+		atomic.AddUint64(&s.client.readAheadHits, 1)
+	}
+	filesize, _ := s.extents.Size()
+	if buf := ra.next(offset+total, filesize); buf != nil {
+		go s.prefetch(buf, requestID)
+	}
+	return
+}
+
+// prefetch reads the data ahead of the read of the request ID.
+func (s *Streamer) prefetch(buf *readAheadBuf, requestID reqid.ID) {
+	defer close(buf.done)
+	// the writes buffered in the write-back mode are read from the data nodes
+	if err := s.IssueFlushRequest(); err != nil {
+		return
+	}
+	data := make([]byte, buf.size)
+	n, err := s.read(data, buf.offset, buf.size, tracing.SpanContext{}, requestID)
+	if n <= 0 {
+		log.LogDebugf("prefetch: ino(%v) offset(%v) size(%v) err(%v)", s.inode, buf.offset, buf.size, err)
+		return
+	}
+	buf.data = data[:n]
+}
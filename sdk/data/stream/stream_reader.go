@@ -15,13 +15,17 @@
 package stream
 
 import (
+	"crypto/cipher"
 	"fmt"
 	"golang.org/x/net/context"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 // One inode corresponds to one streamer. All the requests to the same inode will be queued.
@@ -45,6 +49,18 @@ type Streamer struct {
 	dirtylist *DirtyExtentList // dirty handlers
 	dirty     bool             // whether current open handler is in the dirty list
 
+	inlineDirty bool // whether the inline data has not been stored in the inode yet
+
+	writeBackBuf   []*writeBackEntry // writes buffered in the write-back mode
+	writeBackSince time.Time         // when the oldest buffered write was buffered
+
+	readAhead *readAhead
+	queueWait int64        // nanoseconds the write and flush requests waited before being served, atomic
+	cipher    cipher.Block // nil if the file contents are not encrypted
+
+	trace     tracing.SpanContext // the trace of the write being served, only accessed by the streamer goroutine
+	requestID reqid.ID            // the request ID of the write being served, as above
+
 	request chan interface{} // request channel, write/flush/close
 	done    chan struct{}    // stream writer is being closed
 
@@ -61,6 +77,8 @@ func NewStreamer(client *ExtentClient, inode uint64) *Streamer {
 	s.request = make(chan interface{}, 64)
 	s.done = make(chan struct{})
 	s.dirtylist = NewDirtyExtentList()
+	s.readAhead = new(readAhead)
+	s.cipher = client.newFileCipher(inode)
 	go s.server()
 	return s
 }
@@ -87,10 +105,11 @@ func (s *Streamer) GetExtentReader(ek *proto.ExtentKey) (*ExtentReader, error) {
 		return nil, err
 	}
 	reader := NewExtentReader(s.inode, ek, partition, s.client.dataWrapper.FollowerRead())
+	reader.verifyChecksum = s.client.verifyReadChecksum
 	return reader, nil
 }
 
-func (s *Streamer) read(data []byte, offset int, size int) (total int, err error) {
+func (s *Streamer) read(data []byte, offset int, size int, trace tracing.SpanContext, requestID reqid.ID) (total int, err error) {
 	var (
 		readBytes       int
 		reader          *ExtentReader
@@ -100,6 +119,12 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 
 	ctx := context.Background()
 	s.client.readLimiter.Wait(ctx)
+	waitBandwidth(s.client.readBandwidthLimiter, size)
+	_ = s.client.clusterLimiter.WaitN(ctx, s.client.readLimitKey, size)
+
+	if inline := s.extents.Inline(); inline != nil {
+		return s.readInline(inline, data, offset, size)
+	}
 
 	requests = s.extents.PrepareReadRequests(offset, size, data)
 	for _, req := range requests {
@@ -135,6 +160,9 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 					return
 				}
 				req.Size = filesize - req.FileOffset
+				if err = s.readCold(req.Data[:req.Size], req.FileOffset); err != nil {
+					return
+				}
 				total += req.Size
 				err = io.EOF
 				//if total == 0 {
@@ -143,7 +171,10 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 				return
 			}
 
-			// Reading a hole, just fill zero
+			// Reading a hole, just fill zero, except the data migrated to the cold tier
+			if err = s.readCold(req.Data[:req.Size], req.FileOffset); err != nil {
+				break
+			}
 			total += req.Size
 			log.LogDebugf("Stream read hole: ino(%v) req(%v) total(%v)", s.inode, req, total)
 		} else {
@@ -151,7 +182,16 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 			if err != nil {
 				break
 			}
-			readBytes, err = reader.Read(req)
+			req.trace = trace
+			req.requestID = requestID
+			if s.client.readCache != nil {
+				readBytes, err = s.readCached(reader, req)
+			} else if nodes := s.client.dataWrapper.FlashNodes(); len(nodes) > 0 {
+				readBytes, err = s.readFlash(reader, req, nodes)
+			} else {
+				readBytes, err = reader.Read(req)
+			}
+			s.decrypt(req.Data[:readBytes], req.FileOffset)
 			log.LogDebugf("Stream read: ino(%v) req(%v) readBytes(%v) err(%v)", s.inode, req, readBytes, err)
 			total += readBytes
 			if err != nil || readBytes < req.Size {
@@ -0,0 +1,159 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// In the write-back mode, the writes which are not synchronous are kept in the memory of the client
+// and acknowledged at once, and are written to the data nodes when the file is flushed or read, when
+// they have been buffered for the flush interval, or when the dirty data of the client reaches the
+// limit. The buffered writes are lost if the client crashes.
+
+const (
+	DefaultWriteBackDirtyLimit   = 256 * util.MB
+	DefaultWriteBackFlushSeconds = 5
+
+	writeBackEntryMaxSize = 8 * util.BlockSize // contiguous writes are merged up to the size
+)
+
+var ErrWriteBackDisabled = errors.New("write-back is disabled")
+
+type writeBackConfig struct {
+	dirtyLimit    int64         // atomic
+	flushInterval time.Duration // atomic
+	closeFlush    bool          // the buffered writes are flushed when the file is closed
+	dirtyBytes    int64         // buffered by all the streamers of the client
+}
+
+type writeBackEntry struct {
+	offset int
+	data   []byte
+}
+
+func newWriteBackConfig(config *ExtentConfig) *writeBackConfig {
+	if !config.WriteBack {
+		return nil
+	}
+	wb := &writeBackConfig{
+		dirtyLimit:    config.WriteBackDirtyLimit,
+		flushInterval: time.Duration(config.WriteBackFlushSeconds) * time.Second,
+		closeFlush:    config.WriteBackCloseFlush,
+	}
+	if wb.dirtyLimit <= 0 {
+		wb.dirtyLimit = DefaultWriteBackDirtyLimit
+	}
+	if wb.flushInterval <= 0 {
+		wb.flushInterval = DefaultWriteBackFlushSeconds * time.Second
+	}
+	return wb
+}
+
+func (wb *writeBackConfig) getFlushInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64((*int64)(&wb.flushInterval)))
+}
+
+// canBufferWrite returns true if the write can be buffered without exceeding the dirty limit of the client.
+func (s *Streamer) canBufferWrite(size, flags int) bool {
+	wb := s.client.writeBack
+	if wb == nil || flags&proto.FlagsSyncWrite != 0 {
+		return false
+	}
+	limit := atomic.LoadInt64(&wb.dirtyLimit)
+	if budget := atomic.LoadInt64(&s.client.writeBufferLimit); budget > 0 && budget < limit {
+		limit = budget
+	}
+	if atomic.AddInt64(&wb.dirtyBytes, int64(size)) > limit {
+		atomic.AddInt64(&wb.dirtyBytes, -int64(size))
+		return false
+	}
+	return true
+}
+
+// bufferWrite keeps a copy of the data, the dirty bytes have been counted by canBufferWrite.
+func (s *Streamer) bufferWrite(data []byte, offset, size, flags int) (total int, err error) {
+	filesize, _ := s.extents.Size()
+	if flags&proto.FlagsAppend != 0 {
+		offset = filesize
+	}
+	var last *writeBackEntry
+	if n := len(s.writeBackBuf); n > 0 {
+		last = s.writeBackBuf[n-1]
+	}
+	if last != nil && last.offset+len(last.data) == offset && len(last.data)+size <= writeBackEntryMaxSize {
+		last.data = append(last.data, data[:size]...)
+	} else {
+		entry := &writeBackEntry{offset: offset, data: make([]byte, size, util.Max(size, util.BlockSize))}
+		copy(entry.data, data[:size])
+		if last == nil {
+			s.writeBackSince = time.Now()
+		}
+		s.writeBackBuf = append(s.writeBackBuf, entry)
+	}
+	total = size
+	if offset+total > filesize {
+		s.extents.SetSize(uint64(offset+total), false)
+	}
+	log.LogDebugf("Streamer bufferWrite: ino(%v) offset(%v) size(%v) entries(%v)", s.inode, offset, total, len(s.writeBackBuf))
+	return
+}
+
+// flushWriteBack writes the buffered data to the data nodes in the order it was written.
+func (s *Streamer) flushWriteBack() (err error) {
+	if len(s.writeBackBuf) == 0 {
+		return
+	}
+	entries := s.writeBackBuf
+	s.writeBackBuf = nil
+	var dirty int
+	for _, entry := range entries {
+		dirty += len(entry.data)
+	}
+	defer atomic.AddInt64(&s.client.writeBack.dirtyBytes, -int64(dirty))
+	for _, entry := range entries {
+		var total int
+		if total, err = s.write(entry.data, entry.offset, len(entry.data), 0); err == nil && total < len(entry.data) {
+			err = syscall.EIO
+		}
+		if err != nil {
+			log.LogErrorf("Streamer flushWriteBack: ino(%v) offset(%v) size(%v) err(%v), the buffered data is lost",
+				s.inode, entry.offset, len(entry.data), err)
+			atomic.StoreInt32(&s.status, StreamerError)
+			return syscall.EIO
+		}
+	}
+	log.LogDebugf("Streamer flushWriteBack: ino(%v) entries(%v) bytes(%v)", s.inode, len(entries), dirty)
+	return
+}
+
+// expiredWriteBack returns true if the data has been buffered for the flush interval,
+// or at once if the memory of the client is under pressure.
+func (s *Streamer) expiredWriteBack() bool {
+	return len(s.writeBackBuf) > 0 && (time.Since(s.writeBackSince) >= s.client.writeBack.getFlushInterval() ||
+		atomic.LoadInt32(&s.client.memPressure) != 0)
+}
+
+// keepWriteBackOnClose returns true if the buffered data is not flushed when the file is closed.
+func (s *Streamer) keepWriteBackOnClose() bool {
+	return s.client.writeBack != nil && !s.client.writeBack.closeFlush
+}
@@ -16,8 +16,8 @@ package stream
 
 import (
 	"fmt"
-	"hash/crc32"
 	"net"
+	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -30,6 +30,8 @@ import (
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 const (
@@ -63,12 +65,16 @@ type WriteRequest struct {
 	writeBytes int
 	err        error
 	done       chan struct{}
+	issued     time.Time
+	trace      tracing.SpanContext
+	requestID  reqid.ID
 }
 
 // FlushRequest defines a flush request.
 type FlushRequest struct {
-	err  error
-	done chan struct{}
+	err    error
+	done   chan struct{}
+	issued time.Time
 }
 
 // ReleaseRequest defines a release request.
@@ -84,6 +90,22 @@ type TruncRequest struct {
 	done chan struct{}
 }
 
+// PunchRequest defines a request to punch a hole.
+type PunchRequest struct {
+	offset int
+	size   int
+	err    error
+	done   chan struct{}
+}
+
+// PreallocRequest defines a request to preallocate the space of a range.
+type PreallocRequest struct {
+	offset int
+	size   int
+	err    error
+	done   chan struct{}
+}
+
 // EvictRequest defines an evict request.
 type EvictRequest struct {
 	err  error
@@ -101,17 +123,21 @@ func (s *Streamer) IssueOpenRequest() error {
 	return nil
 }
 
-func (s *Streamer) IssueWriteRequest(offset int, data []byte, flags int) (write int, err error) {
+func (s *Streamer) IssueWriteRequest(offset int, data []byte, flags int, trace tracing.SpanContext, requestID reqid.ID) (write int, err error) {
 	if atomic.LoadInt32(&s.status) >= StreamerError {
 		return 0, errors.New(fmt.Sprintf("IssueWriteRequest: stream writer in error status, ino(%v)", s.inode))
 	}
 
+	issued := time.Now()
 	s.writeLock.Lock()
 	request := writeRequestPool.Get().(*WriteRequest)
+	request.issued = issued
 	request.data = data
 	request.fileOffset = offset
 	request.size = len(data)
 	request.flags = flags
+	request.trace = trace
+	request.requestID = requestID
 	request.done = make(chan struct{}, 1)
 	s.request <- request
 	s.writeLock.Unlock()
@@ -126,6 +152,7 @@ func (s *Streamer) IssueWriteRequest(offset int, data []byte, flags int) (write
 func (s *Streamer) IssueFlushRequest() error {
 	request := flushRequestPool.Get().(*FlushRequest)
 	request.done = make(chan struct{}, 1)
+	request.issued = time.Now()
 	s.request <- request
 	<-request.done
 	err := request.err
@@ -155,6 +182,30 @@ func (s *Streamer) IssueTruncRequest(size int) error {
 	return err
 }
 
+func (s *Streamer) IssuePunchRequest(offset, size int) error {
+	request := punchRequestPool.Get().(*PunchRequest)
+	request.offset = offset
+	request.size = size
+	request.done = make(chan struct{}, 1)
+	s.request <- request
+	<-request.done
+	err := request.err
+	punchRequestPool.Put(request)
+	return err
+}
+
+func (s *Streamer) IssuePreallocRequest(offset, size int) error {
+	request := preallocRequestPool.Get().(*PreallocRequest)
+	request.offset = offset
+	request.size = size
+	request.done = make(chan struct{}, 1)
+	s.request <- request
+	<-request.done
+	err := request.err
+	preallocRequestPool.Put(request)
+	return err
+}
+
 func (s *Streamer) IssueEvictRequest() error {
 	request := evictRequestPool.Get().(*EvictRequest)
 	request.done = make(chan struct{}, 1)
@@ -184,7 +235,8 @@ func (s *Streamer) server() {
 			s.traverse()
 			if s.refcnt <= 0 {
 				s.client.streamerLock.Lock()
-				if s.idle >= streamWriterIdleTimeoutPeriod && len(s.request) == 0 {
+				idle := s.idle >= streamWriterIdleTimeoutPeriod || atomic.LoadInt32(&s.client.memPressure) != 0
+				if idle && len(s.request) == 0 && len(s.writeBackBuf) == 0 {
 					delete(s.client.streamers, s.inode)
 					if s.client.evictIcache != nil {
 						s.client.evictIcache(s.inode)
@@ -203,6 +255,11 @@ func (s *Streamer) server() {
 	}
 }
 
+// addQueueWait accounts the time the request waited before it is served.
+func (s *Streamer) addQueueWait(issued time.Time) {
+	atomic.AddInt64(&s.queueWait, int64(time.Since(issued)))
+}
+
 func (s *Streamer) clearRequests() {
 	for {
 		select {
@@ -224,6 +281,12 @@ func (s *Streamer) abortRequest(request interface{}) {
 	case *TruncRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
+	case *PunchRequest:
+		request.err = syscall.EAGAIN
+		request.done <- struct{}{}
+	case *PreallocRequest:
+		request.err = syscall.EAGAIN
+		request.done <- struct{}{}
 	case *FlushRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
@@ -243,12 +306,26 @@ func (s *Streamer) handleRequest(request interface{}) {
 		s.open()
 		request.done <- struct{}{}
 	case *WriteRequest:
-		request.writeBytes, request.err = s.write(request.data, request.fileOffset, request.size, request.flags)
+		s.addQueueWait(request.issued)
+		if s.canBufferWrite(request.size, request.flags) {
+			request.writeBytes, request.err = s.bufferWrite(request.data, request.fileOffset, request.size, request.flags)
+		} else if request.err = s.flushWriteBack(); request.err == nil {
+			s.trace, s.requestID = request.trace, request.requestID
+			request.writeBytes, request.err = s.write(request.data, request.fileOffset, request.size, request.flags)
+			s.trace, s.requestID = tracing.SpanContext{}, reqid.ID{}
+		}
 		request.done <- struct{}{}
 	case *TruncRequest:
 		request.err = s.truncate(request.size)
 		request.done <- struct{}{}
+	case *PunchRequest:
+		request.err = s.punch(request.offset, request.size)
+		request.done <- struct{}{}
+	case *PreallocRequest:
+		request.err = s.preallocate(request.offset, request.size)
+		request.done <- struct{}{}
 	case *FlushRequest:
+		s.addQueueWait(request.issued)
 		request.err = s.flush()
 		request.done <- struct{}{}
 	case *ReleaseRequest:
@@ -277,6 +354,15 @@ func (s *Streamer) write(data []byte, offset, size, flags int) (total int, err e
 
 	ctx := context.Background()
 	s.client.writeLimiter.Wait(ctx)
+	waitBandwidth(s.client.writeBandwidthLimiter, size)
+	_ = s.client.clusterLimiter.WaitN(ctx, s.client.writeLimitKey, size)
+
+	if s.canWriteInline(offset, size) {
+		return s.writeInline(data, offset, size, direct)
+	}
+	if err = s.migrateInline(); err != nil {
+		return
+	}
 
 	requests := s.extents.PrepareWriteRequests(offset, size, data)
 	log.LogDebugf("Streamer write: ino(%v) prepared requests(%v)", s.inode, requests)
@@ -345,26 +431,32 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 
 	sc := NewStreamConn(dp, false)
 
+	// invalidate again after the write, as the blocks may be cached while they are written
+	s.client.readCache.invalidate(dp.PartitionID, req.ExtentKey.ExtentId, offset-ekFileOffset+ekExtOffset, size)
+	defer s.client.readCache.invalidate(dp.PartitionID, req.ExtentKey.ExtentId, offset-ekFileOffset+ekExtOffset, size)
+
 	for total < size {
 		reqPacket := NewOverwritePacket(dp, req.ExtentKey.ExtentId, offset-ekFileOffset+total+ekExtOffset, s.inode, offset)
+		reqPacket.TraceContext = s.trace
+		reqPacket.RequestID = s.requestID
 		if direct {
 			reqPacket.Opcode = proto.OpSyncRandomWrite
 		}
 		packSize := util.Min(size-total, util.BlockSize)
 		copy(reqPacket.Data[:packSize], req.Data[total:total+packSize])
 		reqPacket.Size = uint32(packSize)
-		reqPacket.CRC = crc32.ChecksumIEEE(reqPacket.Data[:packSize])
+		reqPacket.CRC = reqPacket.Checksum(reqPacket.Data[:packSize])
 
 		replyPacket := new(Packet)
-		err = sc.Send(reqPacket, func(conn *net.TCPConn) (error, bool) {
-			e := replyPacket.ReadFromConn(conn, proto.ReadDeadlineTime)
+		err = sc.Send(reqPacket, func(conn net.Conn) (error, bool) {
+			e := replyPacket.ReadFromConnTimeout(conn, dp.ClientWrapper.RetryPolicy().Timeout)
 			if e != nil {
 				log.LogWarnf("Stream Writer doOverwrite: ino(%v) failed to read from connect, req(%v) err(%v)", s.inode, reqPacket, e)
 				// Upon receiving TryOtherAddrError, other hosts will be retried.
 				return TryOtherAddrError, false
 			}
 
-			if replyPacket.ResultCode == proto.OpAgain {
+			if replyPacket.ResultCode == proto.OpAgain || replyPacket.ResultCode == proto.OpWriteThrottled {
 				return nil, true
 			}
 
@@ -378,6 +470,12 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		reqPacket.Data = nil
 		log.LogDebugf("doOverwrite: ino(%v) req(%v) reqPacket(%v) err(%v) replyPacket(%v)", s.inode, req, reqPacket, err, replyPacket)
 
+		if err == nil && replyPacket.ResultCode == proto.OpNotPerm {
+			// the extent is sealed as the file is set immutable or append-only
+			log.LogWarnf("doOverwrite: ino(%v) req(%v) refused by sealed extent, replyPacket(%v)", s.inode, req, replyPacket)
+			err = syscall.EPERM
+			break
+		}
 		if err != nil || replyPacket.ResultCode != proto.OpOk {
 			err = errors.New(fmt.Sprintf("doOverwrite: failed or reply NOK: err(%v) ino(%v) req(%v) replyPacket(%v)", err, s.inode, req, replyPacket))
 			break
@@ -463,6 +561,17 @@ func (s *Streamer) doWrite(data []byte, offset, size int, direct bool) (total in
 }
 
 func (s *Streamer) flush() (err error) {
+	if err = s.flushWriteBack(); err != nil {
+		return
+	}
+	return s.flushExtents()
+}
+
+// flushExtents flushes the data written to the data nodes and appends the extent keys to the inode.
+func (s *Streamer) flushExtents() (err error) {
+	if err = s.flushInline(); err != nil {
+		return
+	}
 	for {
 		element := s.dirtylist.Get()
 		if element == nil {
@@ -492,6 +601,23 @@ func (s *Streamer) flush() (err error) {
 
 func (s *Streamer) traverse() (err error) {
 	s.traversed++
+	if s.expiredWriteBack() {
+		if s.refcnt <= 0 {
+			// the file has been closed, flush it completely as it would have been on close
+			s.closeOpenHandler()
+			err = s.flush()
+		} else {
+			err = s.flushWriteBack()
+		}
+		if err != nil {
+			return
+		}
+	}
+	if s.inlineDirty && s.traversed >= streamWriterFlushPeriod {
+		if err = s.flushInline(); err != nil {
+			log.LogWarnf("Streamer traverse flush inline: ino(%v) err(%v)", s.inode, err)
+		}
+	}
 	length := s.dirtylist.Len()
 	for i := 0; i < length; i++ {
 		element := s.dirtylist.Get()
@@ -569,15 +695,27 @@ func (s *Streamer) open() {
 func (s *Streamer) release() error {
 	s.refcnt--
 	s.closeOpenHandler()
-	err := s.flush()
+	var err error
+	if s.keepWriteBackOnClose() {
+		err = s.flushExtents()
+	} else {
+		err = s.flush()
+	}
 	if err != nil {
 		s.abort()
 	}
+	if s.refcnt <= 0 {
+		s.readAhead.invalidate()
+	}
 	log.LogDebugf("release: streamer(%v) refcnt(%v)", s, s.refcnt)
 	return err
 }
 
 func (s *Streamer) evict() error {
+	// the writes buffered after the file is closed
+	if err := s.flush(); err != nil {
+		return err
+	}
 	s.client.streamerLock.Lock()
 	if s.refcnt > 0 || len(s.request) != 0 {
 		s.client.streamerLock.Unlock()
@@ -623,6 +761,119 @@ func (s *Streamer) truncate(size int) error {
 	return s.GetExtents()
 }
 
+func (s *Streamer) punch(offset, size int) error {
+	s.closeOpenHandler()
+	err := s.flush()
+	if err != nil {
+		return err
+	}
+
+	err = s.client.punchHole(s.inode, uint64(offset), uint64(size))
+	if err != nil {
+		return err
+	}
+	return s.GetExtents()
+}
+
+// preallocate allocates new extents for the holes in the range. The extents read as zeros,
+// and are overwritten in place by the writes later.
+func (s *Streamer) preallocate(offset, size int) error {
+	if s.cipher != nil || s.extents.Inline() != nil {
+		return syscall.EOPNOTSUPP
+	}
+	s.closeOpenHandler()
+	err := s.flush()
+	if err != nil {
+		return err
+	}
+
+	for _, hole := range s.extents.Holes(offset, size) {
+		end := hole.FileOffset + hole.Size
+		for off := hole.FileOffset; off < end; off += util.ExtentSize {
+			n := util.ExtentSize
+			if end-off < n {
+				n = end - off
+			}
+			if err = s.preallocExtent(off, n); err != nil {
+				return err
+			}
+		}
+	}
+	return s.GetExtents()
+}
+
+// preallocExtent creates an extent of the given size, and appends it to the file at the offset.
+func (s *Streamer) preallocExtent(fileOffset, size int) (err error) {
+	var (
+		dp    *wrapper.DataPartition
+		extID uint64
+	)
+	exclude := make(map[string]struct{})
+	for i := 0; i < MaxSelectDataPartitionForWrite; i++ {
+		if dp, err = s.client.dataWrapper.GetDataPartitionForWrite(exclude); err != nil {
+			log.LogWarnf("preallocExtent: failed to get write data partition, ino(%v) err(%v)", s.inode, err)
+			return syscall.ENOSPC
+		}
+		if extID, err = s.sendPrealloc(dp, size); err != nil {
+			log.LogWarnf("preallocExtent: exclude dp[%v] caused by preallocate failed, ino(%v) err(%v)", dp, s.inode, err)
+			exclude[dp.Hosts[0]] = struct{}{}
+			dp.CheckAllHostsIsAvail(exclude)
+			continue
+		}
+		ek := proto.ExtentKey{
+			FileOffset:  uint64(fileOffset),
+			PartitionId: dp.PartitionID,
+			ExtentId:    extID,
+			Size:        uint32(size),
+		}
+		if err = s.client.appendExtentKey(s.parentInode, s.inode, ek, nil); err != nil {
+			return
+		}
+		s.extents.Append(&ek, true)
+		return nil
+	}
+	if err != nil && strings.Contains(err.Error(), storage.NoSpaceError.Error()) {
+		err = syscall.ENOSPC
+	}
+	return
+}
+
+// sendPrealloc creates an extent in the data partition and allocates its space.
+func (s *Streamer) sendPrealloc(dp *wrapper.DataPartition, size int) (extID uint64, err error) {
+	conn, err := StreamConnPool.GetConnect(dp.Hosts[0])
+	if err != nil {
+		return
+	}
+	defer func() {
+		StreamConnPool.PutConnect(conn, err != nil)
+	}()
+
+	p := NewCreateExtentPacket(dp, s.inode)
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime*2); err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk || p.ExtentID == 0 {
+		err = errors.New(fmt.Sprintf("sendPrealloc: create extent failed, packet(%v) host(%v) msg(%v)", p, dp.Hosts[0], p.GetResultMsg()))
+		return
+	}
+	extID = p.ExtentID
+
+	p = NewPreallocExtentPacket(dp, extID, 0, size)
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime*2); err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = errors.New(fmt.Sprintf("sendPrealloc: preallocate failed, packet(%v) host(%v) msg(%v)", p, dp.Hosts[0], p.GetResultMsg()))
+	}
+	return
+}
+
 func (s *Streamer) tinySizeLimit() int {
 	return util.DefaultTinySizeLimit
 }
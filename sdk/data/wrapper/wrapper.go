@@ -23,6 +23,7 @@ import (
 
 	"github.com/cubefs/cubefs/proto"
 	masterSDK "github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/iputil"
 	"github.com/cubefs/cubefs/util/log"
@@ -37,6 +38,12 @@ type DataPartitionView struct {
 	DataPartitions []*DataPartition
 }
 
+// hostLocation is the location of a data node in the topology of the cluster.
+type hostLocation struct {
+	zoneName string
+	rack     string
+}
+
 // Wrapper TODO rename. This name does not reflect what it is doing.
 type Wrapper struct {
 	sync.RWMutex
@@ -47,9 +54,17 @@ type Wrapper struct {
 	followerRead          bool
 	followerReadClientCfg bool
 	nearRead              bool
+	zoneName              string // location of the client for the nearest reads
+	rack                  string
+	locationLock          sync.RWMutex
+	hostsLocation         map[string]hostLocation
 	dpSelectorChanged     bool
 	dpSelectorName        string
 	dpSelectorParm        string
+	checksumType          uint8
+	blobStoreAddrs        []string // addresses of the blob store of the cold tier
+	flashCache            bool     // the data read is cached by the flash nodes
+	flashNodes            []string // the active flash nodes if flashCache is enabled
 	mc                    *masterSDK.MasterClient
 	stopOnce              sync.Once
 	stopC                 chan struct{}
@@ -57,6 +72,8 @@ type Wrapper struct {
 	dpSelector DataPartitionSelector
 
 	HostsStatus map[string]bool
+
+	retryPolicy util.RetryPolicy
 }
 
 // NewDataPartitionWrapper returns a new data partition wrapper.
@@ -68,6 +85,7 @@ func NewDataPartitionWrapper(volName string, masters []string) (w *Wrapper, err
 	w.volName = volName
 	w.partitions = make(map[uint64]*DataPartition)
 	w.HostsStatus = make(map[string]bool)
+	w.hostsLocation = make(map[string]hostLocation)
 	if err = w.updateClusterInfo(); err != nil {
 		err = errors.Trace(err, "NewDataPartitionWrapper:")
 		return
@@ -79,13 +97,14 @@ func NewDataPartitionWrapper(volName string, masters []string) (w *Wrapper, err
 	if err = w.initDpSelector(); err != nil {
 		log.LogErrorf("NewDataPartitionWrapper: init initDpSelector failed, [%v]", err)
 	}
+	if err = w.updateDataNodeStatus(); err != nil {
+		log.LogErrorf("NewDataPartitionWrapper: init DataNodeStatus failed, [%v]", err)
+	}
 	if err = w.updateDataPartition(true); err != nil {
 		err = errors.Trace(err, "NewDataPartitionWrapper:")
 		return
 	}
-	if err = w.updateDataNodeStatus(); err != nil {
-		log.LogErrorf("NewDataPartitionWrapper: init DataNodeStatus failed, [%v]", err)
-	}
+	w.updateFlashNodes()
 	go w.update()
 	return
 }
@@ -93,9 +112,20 @@ func NewDataPartitionWrapper(volName string, masters []string) (w *Wrapper, err
 func (w *Wrapper) Stop() {
 	w.stopOnce.Do(func() {
 		close(w.stopC)
+		w.mc.Stop()
 	})
 }
 
+// MasterClient returns the client of the masters, which is stopped along with the wrapper.
+func (w *Wrapper) MasterClient() *masterSDK.MasterClient {
+	return w.mc
+}
+
+// BlobStoreAddrs returns the addresses of the blob store of the cold tier, nil if it is not configured.
+func (w *Wrapper) BlobStoreAddrs() []string {
+	return w.blobStoreAddrs
+}
+
 func (w *Wrapper) InitFollowerRead(clientConfig bool) {
 	w.followerReadClientCfg = clientConfig
 	w.followerRead = w.followerReadClientCfg || w.followerRead
@@ -105,6 +135,34 @@ func (w *Wrapper) FollowerRead() bool {
 	return w.followerRead
 }
 
+// FlashNodes returns the flash nodes caching the data read from the volume, nil if the flash
+// cache is not enabled.
+func (w *Wrapper) FlashNodes() []string {
+	w.RLock()
+	defer w.RUnlock()
+	return w.flashNodes
+}
+
+// SetRetryPolicy sets the retry policy of the requests to the data nodes.
+func (w *Wrapper) SetRetryPolicy(policy util.RetryPolicy) {
+	w.retryPolicy = policy
+}
+
+// RetryPolicy returns the retry policy of the requests to the data nodes.
+func (w *Wrapper) RetryPolicy() util.RetryPolicy {
+	return w.retryPolicy
+}
+
+// MasterErrorCount returns how many requests failed to reach a master.
+func (w *Wrapper) MasterErrorCount() uint64 {
+	return w.mc.ErrorCount()
+}
+
+// ChecksumType returns the checksum type of the data of the volume.
+func (w *Wrapper) ChecksumType() uint8 {
+	return w.checksumType
+}
+
 func (w *Wrapper) updateClusterInfo() (err error) {
 	var info *proto.ClusterInfo
 	if info, err = w.mc.AdminAPI().GetClusterInfo(); err != nil {
@@ -113,6 +171,7 @@ func (w *Wrapper) updateClusterInfo() (err error) {
 	}
 	log.LogInfof("UpdateClusterInfo: get cluster info: cluster(%v) localIP(%v)", info.Cluster, info.Ip)
 	w.clusterName = info.Cluster
+	w.blobStoreAddrs = info.BlobStoreAddrs
 	LocalIP = info.Ip
 	return
 }
@@ -124,15 +183,20 @@ func (w *Wrapper) getSimpleVolView() (err error) {
 		log.LogWarnf("getSimpleVolView: get volume simple info fail: volume(%v) err(%v)", w.volName, err)
 		return
 	}
+	if w.checksumType, err = proto.ParseChecksumType(view.Checksum); err != nil {
+		log.LogWarnf("getSimpleVolView: volume(%v) err(%v)", w.volName, err)
+		return
+	}
 	w.followerRead = view.FollowerRead
+	w.flashCache = view.FlashCache
 	w.dpSelectorName = view.DpSelectorName
 	w.dpSelectorParm = view.DpSelectorParm
 
 	log.LogInfof("getSimpleVolView: get volume simple info: ID(%v) name(%v) owner(%v) status(%v) capacity(%v) "+
 		"metaReplicas(%v) dataReplicas(%v) mpCnt(%v) dpCnt(%v) followerRead(%v) createTime(%v) dpSelectorName(%v) "+
-		"dpSelectorParm(%v)",
+		"dpSelectorParm(%v) checksum(%v)",
 		view.ID, view.Name, view.Owner, view.Status, view.Capacity, view.MpReplicaNum, view.DpReplicaNum, view.MpCnt,
-		view.DpCnt, view.FollowerRead, view.CreateTime, view.DpSelectorName, view.DpSelectorParm)
+		view.DpCnt, view.FollowerRead, view.CreateTime, view.DpSelectorName, view.DpSelectorParm, view.Checksum)
 	return nil
 }
 
@@ -142,8 +206,9 @@ func (w *Wrapper) update() {
 		select {
 		case <-ticker.C:
 			w.updateSimpleVolView()
-			w.updateDataPartition(false)
 			w.updateDataNodeStatus()
+			w.updateDataPartition(false)
+			w.updateFlashNodes()
 		case <-w.stopC:
 			return
 		}
@@ -163,6 +228,11 @@ func (w *Wrapper) updateSimpleVolView() (err error) {
 		w.followerRead = view.FollowerRead
 	}
 
+	if w.flashCache != view.FlashCache {
+		log.LogInfof("updateSimpleVolView: update flashCache from old(%v) to new(%v)", w.flashCache, view.FlashCache)
+		w.flashCache = view.FlashCache
+	}
+
 	if w.dpSelectorName != view.DpSelectorName || w.dpSelectorParm != view.DpSelectorParm {
 		log.LogInfof("updateSimpleVolView: update dpSelector from old(%v %v) to new(%v %v)",
 			w.dpSelectorName, w.dpSelectorParm, view.DpSelectorName, view.DpSelectorParm)
@@ -176,6 +246,26 @@ func (w *Wrapper) updateSimpleVolView() (err error) {
 	return nil
 }
 
+// updateFlashNodes updates the flash nodes of the volume, which are kept if the master fails to
+// reply, and the failed nodes are skipped by the reads until they come back.
+func (w *Wrapper) updateFlashNodes() (err error) {
+	var nodes []string
+	if w.flashCache {
+		var view *proto.FlashCacheView
+		if view, err = w.mc.ClientAPI().GetFlashNodes(w.volName); err != nil {
+			log.LogWarnf("updateFlashNodes: volume(%v) err(%v)", w.volName, err)
+			return
+		}
+		if view.Enabled {
+			nodes = view.Nodes
+		}
+	}
+	w.Lock()
+	w.flashNodes = nodes
+	w.Unlock()
+	return
+}
+
 func (w *Wrapper) updateDataPartitionByRsp(isInit bool, DataPartitions []*proto.DataPartitionResponse) (err error) {
 
 	var convert = func(response *proto.DataPartitionResponse) *DataPartition {
@@ -268,7 +358,11 @@ func (w *Wrapper) replaceOrInsertPartition(dp *DataPartition) {
 		old.Status = dp.Status
 		old.ReplicaNum = dp.ReplicaNum
 		old.Hosts = dp.Hosts
-		old.NearHosts = dp.Hosts
+		if len(dp.NearHosts) > 0 {
+			old.NearHosts = dp.NearHosts
+		} else {
+			old.NearHosts = dp.Hosts
+		}
 		dp.Metrics = old.Metrics
 	} else {
 		dp.Metrics = NewDataPartitionMetrics()
@@ -315,12 +409,24 @@ func (w *Wrapper) updateDataNodeStatus() (err error) {
 	}
 
 	newHostsStatus := make(map[string]bool)
+	newHostsLocation := make(map[string]hostLocation)
+	w.locationLock.RLock()
 	for _, node := range cv.DataNodes {
 		newHostsStatus[node.Addr] = node.Status
+		location := hostLocation{zoneName: node.ZoneName, rack: node.Rack}
+		if location.zoneName == "" {
+			// keep the location learned from the hints of the data node
+			location = w.hostsLocation[node.Addr]
+		}
+		newHostsLocation[node.Addr] = location
 	}
+	w.locationLock.RUnlock()
 	log.LogInfof("updateDataNodeStatus: update %d hosts status", len(newHostsStatus))
 
 	w.HostsStatus = newHostsStatus
+	w.locationLock.Lock()
+	w.hostsLocation = newHostsLocation
+	w.locationLock.Unlock()
 
 	return
 }
@@ -334,6 +440,41 @@ func (w *Wrapper) NearRead() bool {
 	return w.nearRead
 }
 
+// SetLocation sets the zone and the rack of the client, so that the nearest reads go to the
+// replicas in the same rack or zone first.
+func (w *Wrapper) SetLocation(zoneName, rack string) {
+	w.zoneName = zoneName
+	w.rack = rack
+	log.LogInfof("SetLocation: set zone(%v) rack(%v)", zoneName, rack)
+	if w.followerRead && w.nearRead {
+		w.RLock()
+		for _, dp := range w.partitions {
+			dp.NearHosts = w.sortHostsByDistance(dp.Hosts)
+		}
+		w.RUnlock()
+	}
+}
+
+// UpdateHostLocation records the location hint found in a read reply of a data node, which
+// is taken into account by the next update of the data partitions.
+func (w *Wrapper) UpdateHostLocation(addr string, hint []byte) {
+	zoneName, rack, ok := proto.UnmarshalLocationHint(hint)
+	if !ok {
+		return
+	}
+	location := hostLocation{zoneName: zoneName, rack: rack}
+	w.locationLock.RLock()
+	old, found := w.hostsLocation[addr]
+	w.locationLock.RUnlock()
+	if found && old == location {
+		return
+	}
+	w.locationLock.Lock()
+	w.hostsLocation[addr] = location
+	w.locationLock.Unlock()
+	log.LogInfof("UpdateHostLocation: host(%v) zone(%v) rack(%v)", addr, zoneName, rack)
+}
+
 // Sort hosts by distance form local
 func (w *Wrapper) sortHostsByDistance(srcHosts []string) []string {
 	hosts := make([]string, len(srcHosts))
@@ -341,7 +482,7 @@ func (w *Wrapper) sortHostsByDistance(srcHosts []string) []string {
 
 	for i := 0; i < len(hosts); i++ {
 		for j := i + 1; j < len(hosts); j++ {
-			if distanceFromLocal(hosts[i]) > distanceFromLocal(hosts[j]) {
+			if w.isCloser(hosts[j], hosts[i]) {
 				hosts[i], hosts[j] = hosts[j], hosts[i]
 			}
 		}
@@ -349,6 +490,33 @@ func (w *Wrapper) sortHostsByDistance(srcHosts []string) []string {
 	return hosts
 }
 
+// isCloser tells whether the host a is closer to the client than the host b, by their
+// locations first and by their IPs then.
+func (w *Wrapper) isCloser(a, b string) bool {
+	if da, db := w.locationDistance(a), w.locationDistance(b); da != db {
+		return da < db
+	}
+	return distanceFromLocal(a) < distanceFromLocal(b)
+}
+
+// locationDistance returns 0 for a host in the rack of the client, 1 for a host in its zone,
+// and 2 for the others or if the locations are unknown.
+func (w *Wrapper) locationDistance(host string) int {
+	if w.zoneName == "" {
+		return 2
+	}
+	w.locationLock.RLock()
+	location, ok := w.hostsLocation[host]
+	w.locationLock.RUnlock()
+	if !ok || location.zoneName != w.zoneName {
+		return 2
+	}
+	if w.rack != "" && location.rack == w.rack {
+		return 0
+	}
+	return 1
+}
+
 func distanceFromLocal(b string) int {
 	remote := strings.Split(b, ":")[0]
 
@@ -0,0 +1,41 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wrapper
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+func TestSortHostsByLocation(t *testing.T) {
+	w := &Wrapper{hostsLocation: make(map[string]hostLocation)}
+	w.zoneName, w.rack = "z1", "r1"
+	w.UpdateHostLocation("10.0.0.1:17310", proto.MarshalLocationHint("z2", "r1"))
+	w.UpdateHostLocation("10.0.0.2:17310", proto.MarshalLocationHint("z1", "r2"))
+	w.UpdateHostLocation("10.0.0.3:17310", proto.MarshalLocationHint("z1", "r1"))
+	w.UpdateHostLocation("10.0.0.4:17310", []byte("invalid"))
+
+	hosts := w.sortHostsByDistance([]string{"10.0.0.4:17310", "10.0.0.1:17310", "10.0.0.2:17310", "10.0.0.3:17310"})
+	expected := []string{"10.0.0.3:17310", "10.0.0.2:17310"}
+	for i, host := range expected {
+		if hosts[i] != host {
+			t.Fatalf("hosts %v, expected %v first", hosts, expected)
+		}
+	}
+	if _, ok := w.hostsLocation["10.0.0.4:17310"]; ok {
+		t.Fatalf("invalid hint recorded")
+	}
+}
@@ -127,6 +127,100 @@ func (api *AdminAPI) LoadDataPartition(volName string, partitionID uint64) (err
 	return
 }
 
+func (api *AdminAPI) CheckDataPartition(partitionID uint64, repair bool) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminCheckDataPartition)
+	request.addParam("id", strconv.Itoa(int(partitionID)))
+	request.addParam("repair", strconv.FormatBool(repair))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetDataPartitionCheck(partitionID uint64) (result *proto.CheckDataPartitionResponse, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetDataPartitionCheck)
+	request.addParam("id", strconv.Itoa(int(partitionID)))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	result = &proto.CheckDataPartitionResponse{}
+	if err = json.Unmarshal(buf, &result); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) BackupDataPartition(partitionID uint64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminBackupDataPartition)
+	request.addParam("id", strconv.Itoa(int(partitionID)))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetDataPartitionBackup(partitionID uint64) (result *proto.BackupDataPartitionResponse, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetDataPartitionBackup)
+	request.addParam("id", strconv.Itoa(int(partitionID)))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	result = &proto.BackupDataPartitionResponse{}
+	if err = json.Unmarshal(buf, &result); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) RestoreDataPartition(partitionID uint64, path string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminRestoreDataPartition)
+	request.addParam("id", strconv.Itoa(int(partitionID)))
+	request.addParam("path", path)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ConvertDataPartitionToEC(partitionID uint64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminConvertDataPartitionToEC)
+	request.addParam("id", strconv.Itoa(int(partitionID)))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetDataPartitionECStatus(partitionID uint64) (status *proto.DataPartitionECStatus, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetDataPartitionECStatus)
+	request.addParam("id", strconv.Itoa(int(partitionID)))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	status = &proto.DataPartitionECStatus{}
+	if err = json.Unmarshal(buf, &status); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) GetHotDataPartitions(count int) (hots []*proto.HotDataPartition, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetHotDataPartitions)
+	request.addParam("count", strconv.Itoa(count))
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	hots = make([]*proto.HotDataPartition, 0)
+	if err = json.Unmarshal(buf, &hots); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) CreateDataPartition(volName string, count int) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminCreateDataPartition)
 	request.addParam("name", volName)
@@ -197,6 +291,26 @@ func (api *AdminAPI) AddMetaReplica(metaPartitionID uint64, nodeAddr string) (er
 	return
 }
 
+func (api *AdminAPI) AddMetaReplicaLearner(metaPartitionID uint64, nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminAddMetaReplicaLearner)
+	request.addParam("id", strconv.FormatUint(metaPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) PromoteMetaReplicaLearner(metaPartitionID uint64, nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminPromoteMetaReplicaLearner)
+	request.addParam("id", strconv.FormatUint(metaPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) DeleteVolume(volName, authKey string) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminDeleteVol)
 	request.addParam("name", volName)
@@ -222,6 +336,83 @@ func (api *AdminAPI) UpdateVolume(volName string, capacity uint64, replicas int,
 	return
 }
 
+// RotateEncryptKey re-wraps the data keys of the encrypted volumes with the newest master key.
+func (api *AdminAPI) RotateEncryptKey() (result string, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminRotateEncryptKey)
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &result); err != nil {
+		return
+	}
+	return
+}
+
+// SetVolCompression sets the compression of the data of the volume, "none" disables it.
+func (api *AdminAPI) SetVolCompression(volName, compression, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminUpdateVol)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("compression", compression)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+// SetVolWriteLimit sets the write bytes per second of the volume on each data node, 0 disables it.
+func (api *AdminAPI) SetVolWriteLimit(volName string, writeLimit uint64, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminUpdateVol)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("writeLimit", strconv.FormatUint(writeLimit, 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+// SetVolObjectQuota sets the bytes and the count of the objects allowed to be stored in the volume
+// by the object nodes, 0 for no limit.
+func (api *AdminAPI) SetVolObjectQuota(volName string, quotaBytes, quotaCount uint64, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminUpdateVol)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("objectQuotaBytes", strconv.FormatUint(quotaBytes, 10))
+	request.addParam("objectQuotaCount", strconv.FormatUint(quotaCount, 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+// SetVolSLO sets the latency and the error rate objectives of the volume, 0 to clear an objective.
+func (api *AdminAPI) SetVolSLO(volName string, slo *proto.VolSLO, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminUpdateVol)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("sloLatencyMs", strconv.FormatInt(slo.LatencyMs, 10))
+	request.addParam("sloLatencyTarget", strconv.FormatFloat(slo.LatencyTarget, 'f', -1, 64))
+	request.addParam("sloErrorRateTarget", strconv.FormatFloat(slo.ErrorRateTarget, 'f', -1, 64))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+// SetVolFlashCache enables or disables the caching of the data read from the volume by the flash nodes.
+func (api *AdminAPI) SetVolFlashCache(volName string, enable bool, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminUpdateVol)
+	request.addParam("name", volName)
+	request.addParam("authKey", authKey)
+	request.addParam("flashCache", strconv.FormatBool(enable))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) VolShrink(volName string, capacity uint64, authKey string) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminVolShrink)
 	request.addParam("name", volName)
@@ -245,7 +436,8 @@ func (api *AdminAPI) VolExpand(volName string, capacity uint64, authKey string)
 }
 
 func (api *AdminAPI) CreateVolume(volName, owner string, mpCount int,
-	dpSize uint64, capacity uint64, replicas int, followerRead bool, zoneName string, crossZone bool) (err error) {
+	dpSize uint64, capacity uint64, replicas int, followerRead bool, zoneName string, crossZone bool,
+	caseInsensitive bool, encrypted bool, checksum string) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminCreateVol)
 	request.addParam("name", volName)
 	request.addParam("owner", owner)
@@ -255,6 +447,9 @@ func (api *AdminAPI) CreateVolume(volName, owner string, mpCount int,
 	request.addParam("followerRead", strconv.FormatBool(followerRead))
 	request.addParam("zoneName", zoneName)
 	request.addParam("crossZone", strconv.FormatBool(crossZone))
+	request.addParam("caseInsensitive", strconv.FormatBool(caseInsensitive))
+	request.addParam("encrypted", strconv.FormatBool(encrypted))
+	request.addParam("checksum", checksum)
 	if _, err = api.mc.serveRequest(request); err != nil {
 		return
 	}
@@ -354,6 +549,18 @@ func (api *AdminAPI) SetDeleteParas(batchCount, markDeleteRate, deleteWorkerSlee
 	return
 }
 
+// SetDiskIOLimits sets the per-disk rates of the client io and the repair io of the data nodes.
+func (api *AdminAPI) SetDiskIOLimits(clientIORate, repairIORate string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetNodeInfo)
+	request.addParam("diskClientIORate", clientIORate)
+	request.addParam("diskRepairIORate", repairIORate)
+
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) GetDeleteParas() (delParas map[string]string, err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminGetNodeInfo)
 	if _, err = api.mc.serveRequest(request); err != nil {
@@ -369,3 +576,181 @@ func (api *AdminAPI) GetDeleteParas() (delParas map[string]string, err error) {
 	}
 	return
 }
+
+// SetRateLimit sets the rate per second of a limit of the cluster shared by the nodes.
+func (api *AdminAPI) SetRateLimit(key string, rate float64) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetRateLimit)
+	request.addParam("key", key)
+	request.addParam("rate", strconv.FormatFloat(rate, 'f', -1, 64))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) DeleteRateLimit(key string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminDeleteRateLimit)
+	request.addParam("key", key)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) ListRateLimits() (limits []*proto.RateLimitInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListRateLimits)
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &limits); err != nil {
+		return
+	}
+	return
+}
+
+// GetSLOStatus returns the states of the objectives of the volume, or the volumes with the objectives
+// if the name is empty.
+func (api *AdminAPI) GetSLOStatus(volName string) (statuses []*proto.SLOStatus, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSLOStatus)
+	if volName != "" {
+		request.addParam("name", volName)
+	}
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &statuses); err != nil {
+		return
+	}
+	return
+}
+
+// GetQuota returns the limits and the usage of the quota of the volume, the owner or the directory of the
+// volume, the path is of the directories only.
+func (api *AdminAPI) GetQuota(kind, name, dirPath string) (quota *proto.QuotaInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetQuota)
+	request.addParam("kind", kind)
+	request.addParam("name", name)
+	if dirPath != "" {
+		request.addParam("path", dirPath)
+	}
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	quota = &proto.QuotaInfo{}
+	if err = json.Unmarshal(buf, quota); err != nil {
+		return
+	}
+	return
+}
+
+// ListQuotas returns the quotas of the kind, or of all the kinds if kind is empty.
+func (api *AdminAPI) ListQuotas(kind string) (quotas []*proto.QuotaInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListQuotas)
+	if kind != "" {
+		request.addParam("kind", kind)
+	}
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &quotas); err != nil {
+		return
+	}
+	return
+}
+
+// SetQuota sets the limits of the quota of the volume, the owner or the directory of the volume, 0 for no
+// limit. The auth key is required by the volumes and the directories, and the bytes of a volume are its capacity.
+func (api *AdminAPI) SetQuota(kind, name, dirPath string, maxBytes, maxInodes uint64, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetQuota)
+	request.addParam("kind", kind)
+	request.addParam("name", name)
+	if dirPath != "" {
+		request.addParam("path", dirPath)
+	}
+	request.addParam("maxBytes", strconv.FormatUint(maxBytes, 10))
+	request.addParam("maxInodes", strconv.FormatUint(maxInodes, 10))
+	if authKey != "" {
+		request.addParam("authKey", authKey)
+	}
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+// CreateVolSnapshot takes a consistent snapshot of the whole volume by the master.
+func (api *AdminAPI) CreateVolSnapshot(volName, name, authKey string) (snap *proto.VolSnapshotInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminCreateVolSnapshot)
+	request.addParam("name", volName)
+	request.addParam("snapshot", name)
+	request.addParam("authKey", authKey)
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	snap = &proto.VolSnapshotInfo{}
+	if err = json.Unmarshal(buf, snap); err != nil {
+		return
+	}
+	return
+}
+
+// ListVolSnapshots returns the snapshots of the volume taken by the master, or of all the volumes
+// if volName is empty.
+func (api *AdminAPI) ListVolSnapshots(volName string) (snaps []*proto.VolSnapshotInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminListVolSnapshots)
+	if volName != "" {
+		request.addParam("name", volName)
+	}
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &snaps); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) DeleteVolSnapshot(id uint64, authKey string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminDeleteVolSnapshot)
+	request.addParam("id", strconv.FormatUint(id, 10))
+	request.addParam("authKey", authKey)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	return
+}
+
+// RestoreVolSnapshot rolls the namespace of the volume back to the snapshot taken by the master.
+func (api *AdminAPI) RestoreVolSnapshot(id uint64, authKey string) (snap *proto.VolSnapshotInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminRestoreVolSnapshot)
+	request.addParam("id", strconv.FormatUint(id, 10))
+	request.addParam("authKey", authKey)
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	snap = &proto.VolSnapshotInfo{}
+	if err = json.Unmarshal(buf, snap); err != nil {
+		return
+	}
+	return
+}
+
+func (api *AdminAPI) CheckVersion() (view *proto.VersionCheckView, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminCheckVersion)
+	var buf []byte
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	view = &proto.VersionCheckView{}
+	if err = json.Unmarshal(buf, view); err != nil {
+		return
+	}
+	return
+}
@@ -98,6 +98,20 @@ func (api *ClientAPI) GetVolumeStat(volName string) (info *proto.VolStatInfo, er
 	return
 }
 
+func (api *ClientAPI) GetVolumeQuotaUsage(volName string) (usages []*proto.VolQuotaUsage, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminGetQuotaUsage)
+	request.addParam("name", volName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	usages = make([]*proto.VolQuotaUsage, 0)
+	if err = json.Unmarshal(data, &usages); err != nil {
+		return
+	}
+	return
+}
+
 func (api *ClientAPI) GetMetaPartition(partitionID uint64) (partition *proto.MetaPartitionInfo, err error) {
 	var request = newAPIRequest(http.MethodGet, proto.ClientMetaPartition)
 	request.addParam("id", strconv.FormatUint(partitionID, 10))
@@ -144,3 +158,49 @@ func (api *ClientAPI) GetDataPartitions(volName string) (view *proto.DataPartiti
 	}
 	return
 }
+
+// AllocateRateLimits reports the demands of the keys of the node, and returns the budgets allocated to it.
+func (api *ClientAPI) AllocateRateLimits(req *proto.RateLimitAllocateRequest) (resp *proto.RateLimitAllocateResponse, err error) {
+	var encoded []byte
+	if encoded, err = json.Marshal(req); err != nil {
+		return
+	}
+	var request = newAPIRequest(http.MethodPost, proto.ClientRateLimits)
+	request.addBody(encoded)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	resp = &proto.RateLimitAllocateResponse{}
+	if err = json.Unmarshal(data, resp); err != nil {
+		return
+	}
+	return
+}
+
+// ReportSLO reports the operations of the volumes served by the client since the last report.
+func (api *ClientAPI) ReportSLO(req *proto.SLOReportRequest) (err error) {
+	var encoded []byte
+	if encoded, err = json.Marshal(req); err != nil {
+		return
+	}
+	var request = newAPIRequest(http.MethodPost, proto.ClientSLOReport)
+	request.addBody(encoded)
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
+// GetFlashNodes returns the flash nodes caching the data read from the volume.
+func (api *ClientAPI) GetFlashNodes(volName string) (view *proto.FlashCacheView, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.ClientFlashNodes)
+	request.addParam("name", volName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	view = &proto.FlashCacheView{}
+	if err = json.Unmarshal(data, view); err != nil {
+		return
+	}
+	return
+}
@@ -18,18 +18,24 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 type NodeAPI struct {
-	mc *MasterClient
+	mc        *MasterClient
+	taskCodec uint32 // encoding of the task responses accepted by the master
 }
 
-func (api *NodeAPI) AddDataNode(serverAddr, zoneName string) (id uint64, err error) {
+func (api *NodeAPI) AddDataNode(serverAddr, zoneName, replicaAddr string) (id uint64, err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AddDataNode)
 	request.addParam("addr", serverAddr)
 	request.addParam("zoneName", zoneName)
+	if replicaAddr != "" {
+		request.addParam("replicaAddr", replicaAddr)
+	}
 	var data []byte
 	if data, err = api.mc.serveRequest(request); err != nil {
 		return
@@ -38,10 +44,13 @@ func (api *NodeAPI) AddDataNode(serverAddr, zoneName string) (id uint64, err err
 	return
 }
 
-func (api *NodeAPI) AddMetaNode(serverAddr, zoneName string) (id uint64, err error) {
+func (api *NodeAPI) AddMetaNode(serverAddr, zoneName, replicaAddr string) (id uint64, err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AddMetaNode)
 	request.addParam("addr", serverAddr)
 	request.addParam("zoneName", zoneName)
+	if replicaAddr != "" {
+		request.addParam("replicaAddr", replicaAddr)
+	}
 	var data []byte
 	if data, err = api.mc.serveRequest(request); err != nil {
 		return
@@ -64,6 +73,56 @@ func (api *NodeAPI) GetDataNode(serverHost string) (node *proto.DataNodeInfo, er
 	return
 }
 
+// ReportDataNodeLoadProgress reports the partitions loaded by a starting data node.
+func (api *NodeAPI) ReportDataNodeLoadProgress(serverAddr string, loaded, total int) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.ReportDataNodeLoadProgress)
+	request.addParam("addr", serverAddr)
+	request.addParam("loaded", strconv.Itoa(loaded))
+	request.addParam("total", strconv.Itoa(total))
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
+// ReportDataNodeBadDisk reports a disk isolated by a data node because of its IO errors.
+func (api *NodeAPI) ReportDataNodeBadDisk(serverAddr, diskPath string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.ReportDataNodeBadDisk)
+	request.addParam("addr", serverAddr)
+	request.addParam("disk", diskPath)
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
+// SetDataNodeRepairLimit sets the repair limits of a data node, or the ones of the cluster
+// if nodeAddr is empty. The empty values are left unchanged.
+func (api *NodeAPI) SetDataNodeRepairLimit(nodeAddr, autoRepairRate, diskRepairIORate string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetDataNodeRepairLimit)
+	request.addParam("addr", nodeAddr)
+	request.addParam("autoRepairRate", autoRepairRate)
+	request.addParam("diskRepairIORate", diskRepairIORate)
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
+// SetDataNodeRepairSchedule sets the repair limits of a data node, or the ones of the cluster
+// if nodeAddr is empty, within the time windows of the schedule, such as
+// 22:00-06:00/0/0,06:00-22:00/10/52428800. The schedule "none" removes the windows.
+func (api *NodeAPI) SetDataNodeRepairSchedule(nodeAddr, schedule string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetDataNodeRepairLimit)
+	request.addParam("addr", nodeAddr)
+	request.addParam("repairSchedule", schedule)
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
+// ClearDataNodeRepairLimit makes a data node take the repair limits of the cluster again.
+func (api *NodeAPI) ClearDataNodeRepairLimit(nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AdminSetDataNodeRepairLimit)
+	request.addParam("addr", nodeAddr)
+	request.addParam("clear", "true")
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
 func (api *NodeAPI) GetMetaNode(serverHost string) (node *proto.MetaNodeInfo, err error) {
 	var buf []byte
 	var request = newAPIRequest(http.MethodGet, proto.GetMetaNode)
@@ -78,26 +137,39 @@ func (api *NodeAPI) GetMetaNode(serverHost string) (node *proto.MetaNodeInfo, er
 	return
 }
 
+// SetTaskCodec sets the encoding of the task responses, which is advertised by the master in the heartbeats.
+func (api *NodeAPI) SetTaskCodec(codec uint8) {
+	atomic.StoreUint32(&api.taskCodec, uint32(codec))
+}
+
 func (api *NodeAPI) ResponseMetaNodeTask(task *proto.AdminTask) (err error) {
-	var encoded []byte
-	if encoded, err = json.Marshal(task); err != nil {
-		return
-	}
-	var request = newAPIRequest(http.MethodPost, proto.GetMetaNodeTaskResponse)
-	request.addBody(encoded)
-	if _, err = api.mc.serveRequest(request); err != nil {
-		return
-	}
-	return
+	return api.responseTask(proto.GetMetaNodeTaskResponse, task)
 }
 
 func (api *NodeAPI) ResponseDataNodeTask(task *proto.AdminTask) (err error) {
+	return api.responseTask(proto.GetDataNodeTaskResponse, task)
+}
 
+// responseTask sends the task response in protobuf if the master accepts it, and falls back to
+// JSON if the protobuf one is rejected, e.g. by an older leader elected since the last heartbeat.
+func (api *NodeAPI) responseTask(path string, task *proto.AdminTask) (err error) {
 	var encoded []byte
+	if uint8(atomic.LoadUint32(&api.taskCodec)) == proto.TaskCodecProtobuf {
+		if encoded, err = proto.MarshalTaskPB(task); err == nil {
+			var request = newAPIRequest(http.MethodPost, path)
+			request.addHeader("Content-Type", proto.ProtobufContentType)
+			request.addBody(encoded)
+			if _, err = api.mc.serveRequest(request); err == nil {
+				return
+			}
+		}
+		log.LogWarnf("responseTask: task(%v) in protobuf err(%v), fall back to json", task.IdString(), err)
+		api.SetTaskCodec(proto.TaskCodecJSON)
+	}
 	if encoded, err = json.Marshal(task); err != nil {
 		return
 	}
-	var request = newAPIRequest(http.MethodPost, proto.GetDataNodeTaskResponse)
+	var request = newAPIRequest(http.MethodPost, path)
 	request.addBody(encoded)
 	if _, err = api.mc.serveRequest(request); err != nil {
 		return
@@ -124,3 +196,51 @@ func (api *NodeAPI) MetaNodeDecommission(nodeAddr string) (err error) {
 	}
 	return
 }
+
+// AddFlashNode registers a flash node, and returns its ID.
+func (api *NodeAPI) AddFlashNode(serverAddr, zoneName string) (id uint64, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.AddFlashNode)
+	request.addParam("addr", serverAddr)
+	request.addParam("zoneName", zoneName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	id, err = strconv.ParseUint(string(data), 10, 64)
+	return
+}
+
+func (api *NodeAPI) GetFlashNode(serverAddr string) (node *proto.FlashNodeView, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.GetFlashNode)
+	request.addParam("addr", serverAddr)
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	node = &proto.FlashNodeView{}
+	if err = json.Unmarshal(buf, node); err != nil {
+		return
+	}
+	return
+}
+
+func (api *NodeAPI) ListFlashNodes() (nodes []*proto.FlashNodeView, err error) {
+	var buf []byte
+	var request = newAPIRequest(http.MethodGet, proto.ListFlashNodes)
+	if buf, err = api.mc.serveRequest(request); err != nil {
+		return
+	}
+	nodes = make([]*proto.FlashNodeView, 0)
+	if err = json.Unmarshal(buf, &nodes); err != nil {
+		return
+	}
+	return
+}
+
+// FlashNodeDecommission removes a flash node, whose blocks are cached by the others afterwards.
+func (api *NodeAPI) FlashNodeDecommission(nodeAddr string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.DecommissionFlashNode)
+	request.addParam("addr", nodeAddr)
+	_, err = api.mc.serveRequest(request)
+	return
+}
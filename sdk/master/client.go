@@ -24,10 +24,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tlsutil"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 const (
@@ -44,11 +48,19 @@ type MasterClient struct {
 	useSSL     bool
 	leaderAddr string
 	timeout    time.Duration
+	transport  *http.Transport
+
+	unhealthy map[string]time.Time // masters that failed to answer, by time of failure
+	probeOnce sync.Once
+	stopOnce  sync.Once
+	stopC     chan struct{}
 
 	adminAPI  *AdminAPI
 	clientAPI *ClientAPI
 	nodeAPI   *NodeAPI
 	userAPI   *UserAPI
+
+	errCount uint64 // requests failed to reach a master
 }
 
 // AddNode add the given address as the master address.
@@ -97,19 +109,17 @@ func (c *MasterClient) SetTimeout(timeout uint16) {
 }
 
 func (c *MasterClient) serveRequest(r *request) (repsData []byte, err error) {
-	leaderAddr, nodes := c.prepareRequest()
-	host := leaderAddr
-	for i := -1; i < len(nodes); i++ {
-		if i == -1 {
-			if host == "" {
-				continue
-			}
-		} else {
-			host = nodes[i]
-		}
+	c.startProbe()
+	// the request keeps its ID through the retries on the masters
+	if r.header != nil && !reqid.Parse(r.header[reqid.Header]).IsValid() {
+		r.header[reqid.Header] = reqid.New().String()
+	}
+	leaderAddr, nodes := c.candidates()
+	for i := 0; i < len(nodes); i++ {
+		host := nodes[i]
 		var resp *http.Response
 		var schema string
-		if c.useSSL {
+		if c.useSSL || tlsutil.Enabled() {
 			schema = "https"
 		} else {
 			schema = "http"
@@ -119,6 +129,8 @@ func (c *MasterClient) serveRequest(r *request) (repsData []byte, err error) {
 		resp, err = c.httpRequest(r.method, url, r.params, r.header, r.body)
 		if err != nil {
 			log.LogErrorf("serveRequest: send http request fail: method(%v) url(%v) err(%v)", r.method, url, err)
+			atomic.AddUint64(&c.errCount, 1)
+			c.markUnhealthy(host)
 			continue
 		}
 		stateCode := resp.StatusCode
@@ -126,6 +138,7 @@ func (c *MasterClient) serveRequest(r *request) (repsData []byte, err error) {
 		_ = resp.Body.Close()
 		if err != nil {
 			log.LogErrorf("serveRequest: read http response body fail: err(%v)", err)
+			atomic.AddUint64(&c.errCount, 1)
 			continue
 		}
 		switch stateCode {
@@ -138,9 +151,16 @@ func (c *MasterClient) serveRequest(r *request) (repsData []byte, err error) {
 				err = ErrNoValidMaster
 				return
 			}
+			if curMasterAddr == host {
+				err = ErrNoValidMaster
+				return
+			}
+			// follow the leader hint of the master right away
+			c.setLeader(curMasterAddr)
 			repsData, err = c.serveRequest(r)
 			return
 		case http.StatusOK:
+			c.markHealthy(host)
 			if leaderAddr != host {
 				log.LogDebugf("server Request resp new master[%v] old [%v]", host, leaderAddr)
 				c.setLeader(host)
@@ -179,17 +199,13 @@ func (c *MasterClient) Nodes() (nodes []string) {
 	return
 }
 
-// prepareRequest returns the leader address and all master addresses.
-func (c *MasterClient) prepareRequest() (addr string, nodes []string) {
-	c.RLock()
-	addr = c.leaderAddr
-	nodes = c.masters
-	c.RUnlock()
-	return
+// ErrorCount returns how many requests failed to reach a master.
+func (c *MasterClient) ErrorCount() uint64 {
+	return atomic.LoadUint64(&c.errCount)
 }
 
 func (c *MasterClient) httpRequest(method, url string, param, header map[string]string, reqData []byte) (resp *http.Response, err error) {
-	client := http.DefaultClient
+	client := &http.Client{Transport: c.transport}
 	reader := bytes.NewReader(reqData)
 	if header["isTimeOut"] != "" {
 		var isTimeOut bool
@@ -213,7 +229,15 @@ func (c *MasterClient) httpRequest(method, url string, param, header map[string]
 	for k, v := range header {
 		req.Header.Set(k, v)
 	}
+	span := tracing.StartSpan(req.URL.Path, tracing.SpanKindClient, tracing.SpanContext{})
+	span.SetAttribute("http.method", method)
+	span.SetAttribute("requestID", req.Header.Get(reqid.Header))
+	tracing.Inject(req.Header, span)
 	resp, err = client.Do(req)
+	if resp != nil {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+	}
+	span.End(err)
 	return
 }
 
@@ -253,7 +277,14 @@ func (c *MasterClient) mergeRequestUrl(url string, params map[string]string) str
 
 // NewMasterHelper returns a new MasterClient instance.
 func NewMasterClient(masters []string, useSSL bool) *MasterClient {
-	var mc = &MasterClient{masters: masters, useSSL: useSSL, timeout: requestTimeout}
+	var mc = &MasterClient{
+		masters:   masters,
+		useSSL:    useSSL,
+		timeout:   requestTimeout,
+		transport: newTransport(),
+		unhealthy: make(map[string]time.Time),
+		stopC:     make(chan struct{}),
+	}
 	mc.adminAPI = &AdminAPI{mc: mc}
 	mc.clientAPI = &ClientAPI{mc: mc}
 	mc.nodeAPI = &NodeAPI{mc: mc}
@@ -0,0 +1,191 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tlsutil"
+)
+
+const (
+	probeInterval = 5 * time.Second
+	probeTimeout  = 2 * time.Second
+	dialTimeout   = 2 * time.Second
+)
+
+func newTransport() *http.Transport {
+	return tlsutil.NewTransport(&http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	})
+}
+
+// markUnhealthy records that the given master could not be reached. An
+// unhealthy master is tried after all the healthy ones until a probe or a
+// request succeeds again. If it was the leader, the leader hint is dropped.
+func (c *MasterClient) markUnhealthy(addr string) {
+	c.Lock()
+	c.unhealthy[addr] = time.Now()
+	if c.leaderAddr == addr {
+		c.leaderAddr = ""
+	}
+	c.Unlock()
+}
+
+func (c *MasterClient) markHealthy(addr string) {
+	c.Lock()
+	delete(c.unhealthy, addr)
+	c.Unlock()
+}
+
+// candidates returns the masters in the order they should be tried: the
+// leader first, then the healthy masters, and the unhealthy ones last.
+func (c *MasterClient) candidates() (leader string, hosts []string) {
+	c.RLock()
+	defer c.RUnlock()
+	leader = c.leaderAddr
+	hosts = make([]string, 0, len(c.masters)+1)
+	if leader != "" {
+		hosts = append(hosts, leader)
+	}
+	var down []string
+	for _, addr := range c.masters {
+		if addr == leader {
+			continue
+		}
+		if _, ok := c.unhealthy[addr]; ok {
+			down = append(down, addr)
+			continue
+		}
+		hosts = append(hosts, addr)
+	}
+	hosts = append(hosts, down...)
+	return
+}
+
+func (c *MasterClient) startProbe() {
+	c.probeOnce.Do(func() {
+		if len(c.Nodes()) > 1 {
+			go c.probeLoop()
+		}
+	})
+}
+
+// Stop stops probing the masters.
+func (c *MasterClient) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopC)
+	})
+}
+
+func (c *MasterClient) probeLoop() {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for {
+		c.probe()
+		select {
+		case <-c.stopC:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe checks every master concurrently, keeps the health marks up to date
+// and follows the leader reported by the masters.
+func (c *MasterClient) probe() {
+	nodes := c.Nodes()
+	leaders := make([]string, len(nodes))
+	var wg sync.WaitGroup
+	for i, addr := range nodes {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			leader, err := c.probeMaster(addr)
+			if err != nil {
+				log.LogWarnf("probe: master(%v) err(%v)", addr, err)
+				c.markUnhealthy(addr)
+				return
+			}
+			c.markHealthy(addr)
+			leaders[i] = leader
+		}(i, addr)
+	}
+	wg.Wait()
+	for _, leader := range leaders {
+		if leader == "" {
+			continue
+		}
+		c.RLock()
+		_, down := c.unhealthy[leader]
+		old := c.leaderAddr
+		c.RUnlock()
+		if down {
+			continue
+		}
+		if leader != old {
+			log.LogInfof("probe: leader changed from(%v) to(%v)", old, leader)
+			c.setLeader(leader)
+		}
+		return
+	}
+}
+
+func (c *MasterClient) probeMaster(addr string) (leader string, err error) {
+	var schema = "http"
+	if c.useSSL || tlsutil.Enabled() {
+		schema = "https"
+	}
+	client := &http.Client{Transport: c.transport, Timeout: probeTimeout}
+	resp, err := client.Get(fmt.Sprintf("%s://%s%s", schema, addr, proto.AdminGetIP))
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("status(%v)", resp.StatusCode)
+		return
+	}
+	var body = &struct {
+		Code int32             `json:"code"`
+		Data proto.ClusterInfo `json:"data"`
+	}{}
+	if err = json.Unmarshal(data, body); err != nil {
+		return
+	}
+	if body.Code != 0 {
+		err = proto.ParseErrorCode(body.Code)
+		return
+	}
+	leader = body.Data.LeaderAddr
+	return
+}
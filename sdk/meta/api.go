@@ -17,6 +17,7 @@ package meta
 import (
 	"fmt"
 	syslog "log"
+	gopath "path"
 	"sort"
 	"strconv"
 	"strings"
@@ -35,6 +36,7 @@ import (
 
 const (
 	BatchIgetRespBuf = 1000
+	BatchStatLimit   = 1024
 )
 
 const (
@@ -65,10 +67,12 @@ func (mw *MetaWrapper) GetRootIno(subdir string) (uint64, error) {
 	return rootIno, nil
 }
 
-// Looks up absolute path and returns the ino
+// Looks up absolute path and returns the ino. The path is resolved from the root of the
+// wrapper, and cannot go above it with "..".
 func (mw *MetaWrapper) LookupPath(subdir string) (uint64, error) {
-	ino := proto.RootIno
-	if subdir == "" || subdir == "/" {
+	ino := mw.RootIno()
+	subdir = gopath.Clean("/" + subdir)
+	if subdir == "/" {
 		return ino, nil
 	}
 
@@ -94,6 +98,9 @@ func (mw *MetaWrapper) Statfs() (total, used, inodeCount uint64) {
 }
 
 func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte) (*proto.InodeInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
 	var (
 		status       int
 		err          error
@@ -107,6 +114,11 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 		log.LogErrorf("Create_ll: No parent partition, parentID(%v)", parentID)
 		return nil, syscall.ENOENT
 	}
+	// the inode is counted by the directory quotas of its parent
+	quotaIDs, err := mw.quotaIDs(parentMP, parentID)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create Inode
 
@@ -136,10 +148,14 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 	for i := 0; i < length; i++ {
 		index := (int(epoch) + i) % length
 		mp = rwPartitions[index]
-		status, info, err = mw.icreate(mp, mode, uid, gid, target)
+		status, info, err = mw.icreate(mp, mode, uid, gid, target, quotaIDs)
 		if err == nil && status == statusOK {
 			goto create_dentry
 		}
+		if status == statusQuota {
+			// the quotas are of the volume, the other partitions refuse the inode as well
+			return nil, syscall.EDQUOT
+		}
 	}
 	return nil, syscall.ENOMEM
 
@@ -256,9 +272,174 @@ func (mw *MetaWrapper) BatchInodeGet(inodes []uint64) []*proto.InodeInfo {
 	return batchInfos
 }
 
+// StatResult is the result of stating a path or an inode in a batch.
+type StatResult struct {
+	Path  string
+	Inode uint64
+	Info  *proto.InodeInfo
+	Err   error
+}
+
+// BatchStat_ll resolves the paths to their attributes. The paths are resolved one
+// level at a time, so that each level costs a single request per meta partition.
+func (mw *MetaWrapper) BatchStat_ll(paths []string) []*StatResult {
+	type dentryKey struct {
+		parentID uint64
+		name     string
+	}
+
+	root := mw.RootIno()
+	results := make([]*StatResult, len(paths))
+	components := make([][]string, len(paths))
+	for i, path := range paths {
+		results[i] = &StatResult{Path: path, Inode: root}
+		for _, name := range strings.Split(path, "/") {
+			if name != "" {
+				components[i] = append(components[i], name)
+			}
+		}
+	}
+
+	for depth := 0; ; depth++ {
+		// Paths sharing a prefix look up the same dentry only once.
+		index := make(map[dentryKey]int)
+		items := make([]proto.BatchStatItem, 0)
+		pending := make([]int, 0)
+		for i, r := range results {
+			if r.Err != nil || depth >= len(components[i]) {
+				continue
+			}
+			key := dentryKey{parentID: r.Inode, name: components[i][depth]}
+			if _, ok := index[key]; !ok {
+				index[key] = len(items)
+				items = append(items, proto.BatchStatItem{ParentID: key.parentID, Name: key.name})
+			}
+			pending = append(pending, i)
+		}
+		if len(items) == 0 {
+			break
+		}
+		stats := mw.doBatchStat(items)
+		for _, i := range pending {
+			r := results[i]
+			stat := stats[index[dentryKey{parentID: r.Inode, name: components[i][depth]}]]
+			switch {
+			case stat == nil:
+				r.Err = syscall.EIO
+			case stat.Status != proto.OpOk:
+				r.Err = statusToErrno(parseStatus(stat.Status))
+			case depth < len(components[i])-1 && !proto.IsDir(stat.Mode):
+				r.Err = syscall.ENOTDIR
+			default:
+				r.Inode = stat.Inode
+				r.Info = stat.Info
+			}
+		}
+	}
+	mw.fillStatInfos(results)
+	return results
+}
+
+// BatchStatInodes_ll gets the attributes of the inodes, the results are in the order of inodes.
+func (mw *MetaWrapper) BatchStatInodes_ll(inodes []uint64) []*StatResult {
+	results := make([]*StatResult, len(inodes))
+	for i, ino := range inodes {
+		results[i] = &StatResult{Inode: ino}
+	}
+	mw.fillStatInfos(results)
+	return results
+}
+
+// fillStatInfos gets the attributes missing in the results from the partitions owning the inodes.
+func (mw *MetaWrapper) fillStatInfos(results []*StatResult) {
+	index := make(map[uint64]int)
+	items := make([]proto.BatchStatItem, 0)
+	for _, r := range results {
+		if r.Err != nil || r.Info != nil {
+			continue
+		}
+		if _, ok := index[r.Inode]; !ok {
+			index[r.Inode] = len(items)
+			items = append(items, proto.BatchStatItem{Inode: r.Inode})
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	stats := mw.doBatchStat(items)
+	for _, r := range results {
+		if r.Err != nil || r.Info != nil {
+			continue
+		}
+		stat := stats[index[r.Inode]]
+		switch {
+		case stat == nil:
+			r.Err = syscall.EIO
+		case stat.Status != proto.OpOk:
+			r.Err = statusToErrno(parseStatus(stat.Status))
+		default:
+			r.Info = stat.Info
+		}
+	}
+}
+
+// doBatchStat sends the items to the partitions storing them, i.e. the partition of
+// the parent inode for a dentry and the partition of the inode itself otherwise.
+// The results are in the order of items, and are nil for the failed requests.
+func (mw *MetaWrapper) doBatchStat(items []proto.BatchStatItem) []*proto.BatchStatResult {
+	var wg sync.WaitGroup
+
+	results := make([]*proto.BatchStatResult, len(items))
+	candidates := make(map[uint64][]int)
+	for i, item := range items {
+		ino := item.Inode
+		if item.Name != "" {
+			ino = item.ParentID
+		}
+		mp := mw.getPartitionByInode(ino)
+		if mp == nil {
+			continue
+		}
+		candidates[mp.PartitionID] = append(candidates[mp.PartitionID], i)
+	}
+
+	for id, indexes := range candidates {
+		mp := mw.getPartitionByID(id)
+		if mp == nil {
+			continue
+		}
+		for start := 0; start < len(indexes); start += BatchStatLimit {
+			end := start + BatchStatLimit
+			if end > len(indexes) {
+				end = len(indexes)
+			}
+			wg.Add(1)
+			go func(mp *MetaPartition, indexes []int) {
+				defer wg.Done()
+				batch := make([]proto.BatchStatItem, 0, len(indexes))
+				for _, i := range indexes {
+					batch = append(batch, items[i])
+				}
+				status, stats, err := mw.batchStat(mp, batch)
+				if err != nil || status != statusOK {
+					return
+				}
+				for j, i := range indexes {
+					results[i] = stats[j]
+				}
+			}(mp, indexes[start:end])
+		}
+	}
+	wg.Wait()
+	return results
+}
+
 // InodeDelete_ll is a low-level api that removes specified inode immediately
 // and do not effect extent data managed by this inode.
 func (mw *MetaWrapper) InodeDelete_ll(inode uint64) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogErrorf("InodeDelete: No such partition, ino(%v)", inode)
@@ -333,6 +514,9 @@ func (mw *MetaWrapper) BatchGetXAttr(inodes []uint64, keys []string) ([]*proto.X
  * and the caller should make sure InodeInfo is valid before using it.
  */
 func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool) (*proto.InodeInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
 	var (
 		status int
 		inode  uint64
@@ -368,6 +552,17 @@ func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool) (*pro
 		if info == nil || info.Nlink > 2 {
 			return nil, syscall.ENOTEMPTY
 		}
+		if info.IsProtected() {
+			return nil, syscall.EPERM
+		}
+	} else {
+		status, inode, _, err = mw.lookup(parentMP, parentID, name)
+		if err != nil || status != statusOK {
+			return nil, statusToErrno(status)
+		}
+		if err = mw.checkProtected(parentMP, inode); err != nil {
+			return nil, err
+		}
 	}
 
 	status, inode, err = mw.ddelete(parentMP, parentID, name)
@@ -402,6 +597,9 @@ func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool) (*pro
 }
 
 func (mw *MetaWrapper) Rename_ll(srcParentID uint64, srcName string, dstParentID uint64, dstName string) (err error) {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	var oldInode uint64
 
 	srcParentMP := mw.getPartitionByInode(srcParentID)
@@ -422,6 +620,11 @@ func (mw *MetaWrapper) Rename_ll(srcParentID uint64, srcName string, dstParentID
 	if srcMP == nil {
 		return syscall.ENOENT
 	}
+	if srcParentID != dstParentID {
+		if err = mw.checkSameQuotas(srcMP, inode, dstParentMP, dstParentID); err != nil {
+			return err
+		}
+	}
 
 	status, _, err = mw.ilink(srcMP, inode)
 	if err != nil || status != statusOK {
@@ -442,6 +645,12 @@ func (mw *MetaWrapper) Rename_ll(srcParentID uint64, srcName string, dstParentID
 
 	// Note that only regular files are allowed to be overwritten.
 	if status == statusExist && proto.IsRegular(mode) {
+		if sts, dstInode, _, e := mw.lookup(dstParentMP, dstParentID, dstName); e == nil && sts == statusOK {
+			if err = mw.checkProtected(dstParentMP, dstInode); err != nil {
+				mw.iunlink(srcMP, inode)
+				return err
+			}
+		}
 		status, oldInode, err = mw.dupdate(dstParentMP, dstParentID, dstName, inode)
 		if err != nil {
 			return syscall.EAGAIN
@@ -544,6 +753,9 @@ func (mw *MetaWrapper) ReadDirLimit_ll(parentID uint64, from string, limit uint6
 }
 
 func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		return syscall.ENOENT
@@ -557,6 +769,9 @@ func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint6
 }
 
 func (mw *MetaWrapper) DentryUpdate_ll(parentID uint64, name string, inode uint64) (oldInode uint64, err error) {
+	if mw.snapshotID != 0 {
+		return 0, syscall.EROFS
+	}
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		err = syscall.ENOENT
@@ -573,6 +788,9 @@ func (mw *MetaWrapper) DentryUpdate_ll(parentID uint64, name string, inode uint6
 
 // Used as a callback by stream sdk
 func (mw *MetaWrapper) AppendExtentKey(parentInode, inode uint64, ek proto.ExtentKey, discard []proto.ExtentKey) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		return syscall.ENOENT
@@ -603,6 +821,9 @@ func (mw *MetaWrapper) AppendExtentKey(parentInode, inode uint64, ek proto.Exten
 
 // AppendExtentKeys append multiple extent key into specified inode with single request.
 func (mw *MetaWrapper) AppendExtentKeys(inode uint64, eks []proto.ExtentKey) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		return syscall.ENOENT
@@ -617,22 +838,46 @@ func (mw *MetaWrapper) AppendExtentKeys(inode uint64, eks []proto.ExtentKey) err
 	return nil
 }
 
-func (mw *MetaWrapper) GetExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, err error) {
+// GetExtents returns the extents of the inode, the data stored inline is returned for the small files,
+// and the location in the cold tier for the files migrated to it.
+func (mw *MetaWrapper) GetExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, inline []byte, cold *proto.ColdLocation, err error) {
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
-		return 0, 0, nil, syscall.ENOENT
+		return 0, 0, nil, nil, nil, syscall.ENOENT
 	}
 
-	status, gen, size, extents, err := mw.getExtents(mp, inode)
+	status, gen, size, extents, inline, cold, err := mw.getExtents(mp, inode)
 	if err != nil || status != statusOK {
 		log.LogErrorf("GetExtents: ino(%v) err(%v) status(%v)", inode, err, status)
-		return 0, 0, nil, statusToErrno(status)
+		return 0, 0, nil, nil, nil, statusToErrno(status)
 	}
-	log.LogDebugf("GetExtents: ino(%v) gen(%v) size(%v) extents(%v)", inode, gen, size, extents)
-	return gen, size, extents, nil
+	log.LogDebugf("GetExtents: ino(%v) gen(%v) size(%v) extents(%v) inline(%v) cold(%v)", inode, gen, size, extents, len(inline), cold != nil)
+	return gen, size, extents, inline, cold, nil
+}
+
+// WriteInline stores the whole content of a small file inline in its inode.
+// EINVAL is returned if the data of the file has been stored in the extents.
+func (mw *MetaWrapper) WriteInline(inode uint64, data []byte) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("WriteInline: No inode partition, ino(%v)", inode)
+		return syscall.ENOENT
+	}
+
+	status, err := mw.writeInline(mp, inode, data)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	return nil
 }
 
 func (mw *MetaWrapper) Truncate(inode, size uint64) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogErrorf("Truncate: No inode partition, ino(%v)", inode)
@@ -647,7 +892,28 @@ func (mw *MetaWrapper) Truncate(inode, size uint64) error {
 
 }
 
+// PunchHole deallocates the given range of an inode, the size of the inode is kept.
+func (mw *MetaWrapper) PunchHole(inode, offset, size uint64) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("PunchHole: No inode partition, ino(%v)", inode)
+		return syscall.ENOENT
+	}
+
+	status, err := mw.punchHole(mp, inode, offset, size)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	return nil
+}
+
 func (mw *MetaWrapper) Link(parentID uint64, name string, ino uint64) (*proto.InodeInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		log.LogErrorf("Link: No parent partition, parentID(%v)", parentID)
@@ -680,6 +946,9 @@ func (mw *MetaWrapper) Link(parentID uint64, name string, ino uint64) (*proto.In
 }
 
 func (mw *MetaWrapper) Evict(inode uint64) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogWarnf("Evict: No such partition, ino(%v)", inode)
@@ -695,13 +964,16 @@ func (mw *MetaWrapper) Evict(inode uint64) error {
 }
 
 func (mw *MetaWrapper) Setattr(inode uint64, valid, mode, uid, gid uint32, atime, mtime int64) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogErrorf("Setattr: No such partition, ino(%v)", inode)
 		return syscall.EINVAL
 	}
 
-	status, err := mw.setattr(mp, inode, valid, mode, uid, gid, atime, mtime)
+	status, err := mw.setattr(mp, inode, valid, mode, uid, gid, 0, atime, mtime)
 	if err != nil || status != statusOK {
 		log.LogErrorf("Setattr: ino(%v) err(%v) status(%v)", inode, err, status)
 		return statusToErrno(status)
@@ -710,7 +982,78 @@ func (mw *MetaWrapper) Setattr(inode uint64, valid, mode, uid, gid uint32, atime
 	return nil
 }
 
+// SetFlags_ll replaces the immutable and append-only flags of an inode.
+func (mw *MetaWrapper) SetFlags_ll(inode uint64, flags uint32) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetFlags_ll: No such partition, ino(%v)", inode)
+		return syscall.EINVAL
+	}
+
+	status, err := mw.setattr(mp, inode, proto.AttrFlags, 0, 0, 0, flags&proto.InodeUserFlagsMask, 0, 0)
+	if err != nil || status != statusOK {
+		log.LogErrorf("SetFlags_ll: ino(%v) flags(%v) err(%v) status(%v)", inode, flags, err, status)
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+// checkProtected returns EPERM if the inode is immutable or append-only.
+// Metanodes reject such unlinks themselves when the dentry and the inode
+// share a partition, so only the remote case needs to be checked here.
+func (mw *MetaWrapper) checkProtected(parentMP *MetaPartition, inode uint64) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil || mp == parentMP {
+		return nil
+	}
+	status, info, err := mw.iget(mp, inode)
+	if err != nil || status != statusOK {
+		return nil
+	}
+	if info.IsProtected() {
+		return syscall.EPERM
+	}
+	return nil
+}
+
+// quotaIDs returns the directory quotas counting the inode.
+func (mw *MetaWrapper) quotaIDs(mp *MetaPartition, inode uint64) ([]uint64, error) {
+	status, info, err := mw.iget(mp, inode)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return info.QuotaIDs, nil
+}
+
+// checkSameQuotas returns EXDEV if the inode is not counted by the same directory quotas as the new
+// parent, so that the file is copied into the other quotas instead, as across the file systems.
+func (mw *MetaWrapper) checkSameQuotas(mp *MetaPartition, inode uint64, parentMP *MetaPartition, parentID uint64) error {
+	quotaIDs, err := mw.quotaIDs(mp, inode)
+	if err != nil {
+		return err
+	}
+	parentQuotaIDs, err := mw.quotaIDs(parentMP, parentID)
+	if err != nil {
+		return err
+	}
+	if len(quotaIDs) != len(parentQuotaIDs) {
+		return syscall.EXDEV
+	}
+	for i := range quotaIDs {
+		if quotaIDs[i] != parentQuotaIDs[i] {
+			return syscall.EXDEV
+		}
+	}
+	return nil
+}
+
 func (mw *MetaWrapper) InodeCreate_ll(mode, uid, gid uint32, target []byte) (*proto.InodeInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
 	var (
 		status       int
 		err          error
@@ -725,16 +1068,46 @@ func (mw *MetaWrapper) InodeCreate_ll(mode, uid, gid uint32, target []byte) (*pr
 	for i := 0; i < length; i++ {
 		index := (int(epoch) + i) % length
 		mp = rwPartitions[index]
-		status, info, err = mw.icreate(mp, mode, uid, gid, target)
+		status, info, err = mw.icreate(mp, mode, uid, gid, target, nil)
 		if err == nil && status == statusOK {
 			return info, nil
 		}
+		if status == statusQuota {
+			return nil, syscall.EDQUOT
+		}
 	}
 	return nil, syscall.ENOMEM
 }
 
+// TmpfileCreate_ll creates an inode without any dentry and with nlink 0, see O_TMPFILE of open(2).
+// It is deleted once evicted unless it is linked into a directory by Link before.
+func (mw *MetaWrapper) TmpfileCreate_ll(mode, uid, gid uint32) (*proto.InodeInfo, error) {
+	info, err := mw.InodeCreate_ll(mode, uid, gid, nil)
+	if err != nil {
+		return nil, err
+	}
+	mp := mw.getPartitionByInode(info.Inode)
+	if mp == nil {
+		log.LogErrorf("TmpfileCreate_ll: No such partition, ino(%v)", info.Inode)
+		return nil, syscall.EINVAL
+	}
+	status, tmpInfo, err := mw.iunlink(mp, info.Inode)
+	if err != nil || status != statusOK {
+		log.LogErrorf("TmpfileCreate_ll: unlink ino(%v) err(%v) status(%v)", info.Inode, err, status)
+		return nil, statusToErrno(status)
+	}
+	if tmpInfo != nil {
+		info = tmpInfo
+	}
+	info.Nlink = 0
+	return info, nil
+}
+
 // InodeUnlink_ll is a low-level api that makes specified inode link value +1.
 func (mw *MetaWrapper) InodeLink_ll(inode uint64) (*proto.InodeInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogErrorf("InodeLink_ll: No such partition, ino(%v)", inode)
@@ -750,6 +1123,9 @@ func (mw *MetaWrapper) InodeLink_ll(inode uint64) (*proto.InodeInfo, error) {
 
 // InodeUnlink_ll is a low-level api that makes specified inode link value -1.
 func (mw *MetaWrapper) InodeUnlink_ll(inode uint64) (*proto.InodeInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogErrorf("InodeUnlink_ll: No such partition, ino(%v)", inode)
@@ -764,6 +1140,9 @@ func (mw *MetaWrapper) InodeUnlink_ll(inode uint64) (*proto.InodeInfo, error) {
 }
 
 func (mw *MetaWrapper) InitMultipart_ll(path string, extend map[string]string) (multipartId string, err error) {
+	if mw.snapshotID != 0 {
+		return "", syscall.EROFS
+	}
 	var (
 		status       int
 		mp           *MetaPartition
@@ -818,6 +1197,9 @@ func (mw *MetaWrapper) GetMultipart_ll(path, multipartId string) (info *proto.Mu
 }
 
 func (mw *MetaWrapper) AddMultipartPart_ll(path, multipartId string, partId uint16, size uint64, md5 string, inode uint64) (err error) {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	var (
 		mpId  uint64
 		found bool
@@ -831,7 +1213,7 @@ func (mw *MetaWrapper) AddMultipartPart_ll(path, multipartId string, partId uint
 		}
 	}
 	var mp = mw.getPartitionByID(mpId)
-	status, err := mw.addMultipartPart(mp, path, multipartId, partId, size, md5, inode)
+	status, _, err := mw.addMultipartPart(mp, path, multipartId, partId, size, md5, inode, false)
 	if err != nil || status != statusOK {
 		log.LogErrorf("AddMultipartPart_ll: err(%v) status(%v)", err, status)
 		return statusToErrno(status)
@@ -839,7 +1221,36 @@ func (mw *MetaWrapper) AddMultipartPart_ll(path, multipartId string, partId uint
 	return nil
 }
 
+// ReplaceMultipartPart_ll stores the part of the multipart upload replacing the part of the same id,
+// and returns the replaced part whose inode is no longer referenced by the multipart upload.
+func (mw *MetaWrapper) ReplaceMultipartPart_ll(path, multipartId string, partId uint16, size uint64, md5 string, inode uint64) (replaced *proto.MultipartPartInfo, err error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
+	var (
+		mpId  uint64
+		found bool
+	)
+	mpId, found = util.MultipartIDFromString(multipartId).PartitionID()
+	if !found {
+		// If meta partition not found by multipart id, broadcast to all meta partitions to find it
+		if _, mpId, err = mw.broadcastGetMultipart(path, multipartId); err != nil {
+			return
+		}
+	}
+	var mp = mw.getPartitionByID(mpId)
+	status, replaced, err := mw.addMultipartPart(mp, path, multipartId, partId, size, md5, inode, true)
+	if err != nil || status != statusOK {
+		log.LogErrorf("ReplaceMultipartPart_ll: err(%v) status(%v)", err, status)
+		return nil, statusToErrno(status)
+	}
+	return replaced, nil
+}
+
 func (mw *MetaWrapper) RemoveMultipart_ll(path, multipartID string) (err error) {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	var (
 		mpId  uint64
 		found bool
@@ -932,7 +1343,119 @@ func (mw *MetaWrapper) ListMultipart_ll(prefix, delimiter, keyMarker string, mul
 	return sessions, nil
 }
 
+// NewObjectVersionID_ll returns a version id for a new version of an object. The id carries
+// the meta partition which keeps the version once it becomes noncurrent.
+func (mw *MetaWrapper) NewObjectVersionID_ll() (versionId string, err error) {
+	var rwPartitions = mw.getRWPartitions()
+	if len(rwPartitions) == 0 {
+		log.LogErrorf("NewObjectVersionID_ll: no writable partitions")
+		return "", syscall.ENOENT
+	}
+	epoch := atomic.AddUint64(&mw.epoch, 1)
+	mp := rwPartitions[int(epoch)%len(rwPartitions)]
+	return util.CreateObjectVersionID(mp.PartitionID), nil
+}
+
+// The versions without a partition in the id, such as the null versions, are kept by
+// the meta partition of the root inode.
+func (mw *MetaWrapper) getObjectVersionPartition(versionId string) *MetaPartition {
+	if mpId, found := util.MultipartIDFromString(versionId).PartitionID(); found {
+		return mw.getPartitionByID(mpId)
+	}
+	return mw.getPartitionByInode(proto.RootIno)
+}
+
+func (mw *MetaWrapper) PutObjectVersion_ll(version *proto.ObjectVersionInfo) (err error) {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
+	mp := mw.getObjectVersionPartition(version.VersionId)
+	if mp == nil {
+		log.LogErrorf("PutObjectVersion_ll: no such partition, path(%v) versionId(%v)", version.Path, version.VersionId)
+		return syscall.ENOENT
+	}
+	status, err := mw.putObjectVersion(mp, version)
+	if err != nil || status != statusOK {
+		log.LogErrorf("PutObjectVersion_ll: put version fail: volume(%v) partitionID(%v) path(%v) versionId(%v) err(%v) status(%v)",
+			mw.volname, mp.PartitionID, version.Path, version.VersionId, err, status)
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+func (mw *MetaWrapper) GetObjectVersion_ll(path, versionId string) (version *proto.ObjectVersionInfo, err error) {
+	mp := mw.getObjectVersionPartition(versionId)
+	if mp == nil {
+		log.LogErrorf("GetObjectVersion_ll: no such partition, path(%v) versionId(%v)", path, versionId)
+		return nil, syscall.ENOENT
+	}
+	status, version, err := mw.getObjectVersion(mp, path, versionId)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return version, nil
+}
+
+func (mw *MetaWrapper) RemoveObjectVersion_ll(path, versionId string) (err error) {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
+	mp := mw.getObjectVersionPartition(versionId)
+	if mp == nil {
+		log.LogErrorf("RemoveObjectVersion_ll: no such partition, path(%v) versionId(%v)", path, versionId)
+		return syscall.ENOENT
+	}
+	status, err := mw.removeObjectVersion(mp, path, versionId)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+// ListObjectVersions_ll lists the noncurrent versions and the delete markers of the paths with
+// the prefix from all the meta partitions, ordered by the path and then the newer versions first.
+// The listing starts after the version marker of the marker path.
+func (mw *MetaWrapper) ListObjectVersions_ll(prefix, marker, versionIdMarker string, max uint64) (versions []*proto.ObjectVersionInfo, err error) {
+	partitions := mw.partitions
+	var wg = sync.WaitGroup{}
+	var wl = sync.Mutex{}
+	var errs = make([]error, 0)
+	versions = make([]*proto.ObjectVersionInfo, 0)
+
+	for _, mp := range partitions {
+		wg.Add(1)
+		go func(mp *MetaPartition) {
+			defer wg.Done()
+			status, partVersions, err := mw.listObjectVersions(mp, prefix, marker, versionIdMarker, max)
+			wl.Lock()
+			defer wl.Unlock()
+			if err != nil || status != statusOK {
+				log.LogErrorf("ListObjectVersions_ll: partition list versions fail, partitionID(%v) err(%v) status(%v)",
+					mp.PartitionID, err, status)
+				errs = append(errs, statusToErrno(status))
+				return
+			}
+			versions = append(versions, partVersions...)
+		}(mp)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return (versions[i].Path < versions[j].Path) || ((versions[i].Path == versions[j].Path) && (versions[i].VersionId < versions[j].VersionId))
+	})
+	if max > 0 && uint64(len(versions)) > max {
+		versions = versions[:max]
+	}
+	return versions, nil
+}
+
 func (mw *MetaWrapper) XAttrSet_ll(inode uint64, name, value []byte) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	var err error
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
@@ -976,6 +1499,9 @@ func (mw *MetaWrapper) XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo,
 
 // XAttrDel_ll is a low-level meta api that deletes specified xattr.
 func (mw *MetaWrapper) XAttrDel_ll(inode uint64, name string) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	var err error
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
@@ -1007,6 +1533,9 @@ func (mw *MetaWrapper) XAttrsList_ll(inode uint64) ([]string, error) {
 }
 
 func (mw *MetaWrapper) UpdateSummary_ll(parentIno uint64, filesInc int64, dirsInc int64, bytesInc int64) {
+	if mw.snapshotID != 0 {
+		return
+	}
 	if filesInc == 0 && dirsInc == 0 && bytesInc == 0 {
 		return
 	}
@@ -1203,6 +1732,9 @@ func (mw *MetaWrapper) getSummaryInfo(parentIno uint64, path string, summaryCh c
 }
 
 func (mw *MetaWrapper) RefreshSummary_ll(parentIno uint64, path string, goroutineNum int32) error {
+	if mw.snapshotID != 0 {
+		return syscall.EROFS
+	}
 	if goroutineNum > MaxSummaryGoroutineNum {
 		goroutineNum = MaxSummaryGoroutineNum
 	}
@@ -1292,3 +1824,242 @@ func (mw *MetaWrapper) refreshSummary(parentIno uint64, path string, wg *sync.Wa
 		}
 	}
 }
+
+// SnapshotID returns the ID of the snapshot served by the wrapper, zero for the live namespace.
+func (mw *MetaWrapper) SnapshotID() uint64 {
+	return mw.snapshotID
+}
+
+// CreateSnapshot_ll creates a read-only snapshot of the subtree rooted at rootIno.
+// Every meta partition freezes its namespace first, then the snapshot is scoped to
+// the inodes reachable from the root. The snapshot is consistent per partition, but
+// not across partitions: changes made to the subtree while the partitions are being
+// frozen may or may not be part of the snapshot.
+func (mw *MetaWrapper) CreateSnapshot_ll(name string, rootIno uint64) (*proto.SnapshotInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
+	partitions := mw.getPartitions()
+	snapshotID := uint64(time.Now().UnixNano())
+	for _, mp := range partitions {
+		status, err := mw.createSnapshot(mp, snapshotID, name, rootIno)
+		if err != nil || status != statusOK {
+			log.LogErrorf("CreateSnapshot_ll: create fail, partitionID(%v) snapshot(%v) err(%v) status(%v)",
+				mp.PartitionID, snapshotID, err, status)
+			mw.DeleteSnapshot_ll(snapshotID)
+			return nil, statusToErrno(status)
+		}
+	}
+
+	reachable, err := mw.walkSnapshot(snapshotID, rootIno)
+	if err != nil {
+		log.LogErrorf("CreateSnapshot_ll: walk fail, snapshot(%v) root(%v) err(%v)", snapshotID, rootIno, err)
+		mw.DeleteSnapshot_ll(snapshotID)
+		return nil, err
+	}
+	for _, mp := range partitions {
+		status, err := mw.sealSnapshot(mp, snapshotID, reachable[mp.PartitionID])
+		if err != nil || status != statusOK {
+			log.LogErrorf("CreateSnapshot_ll: seal fail, partitionID(%v) snapshot(%v) err(%v) status(%v)",
+				mp.PartitionID, snapshotID, err, status)
+			mw.DeleteSnapshot_ll(snapshotID)
+			return nil, statusToErrno(status)
+		}
+	}
+	log.LogInfof("CreateSnapshot_ll: snapshot(%v) name(%v) root(%v)", snapshotID, name, rootIno)
+	return &proto.SnapshotInfo{ID: snapshotID, Name: name, RootIno: rootIno, CreateTime: time.Now().Unix(), Sealed: true}, nil
+}
+
+// walkSnapshot collects the inodes reachable from rootIno in the snapshot, by meta partition.
+func (mw *MetaWrapper) walkSnapshot(snapshotID, rootIno uint64) (map[uint64][]uint64, error) {
+	reachable := make(map[uint64][]uint64)
+	visited := make(map[uint64]bool)
+	var addInode = func(ino uint64) error {
+		mp := mw.getPartitionByInode(ino)
+		if mp == nil {
+			return syscall.ENOENT
+		}
+		if !visited[ino] {
+			visited[ino] = true
+			reachable[mp.PartitionID] = append(reachable[mp.PartitionID], ino)
+		}
+		return nil
+	}
+	if err := addInode(rootIno); err != nil {
+		return nil, err
+	}
+	dirs := []uint64{rootIno}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		mp := mw.getPartitionByInode(dir)
+		status, children, err := mw.readdirAt(mp, snapshotID, dir)
+		if err != nil || status != statusOK {
+			return nil, statusToErrno(status)
+		}
+		for _, child := range children {
+			if visited[child.Inode] {
+				continue
+			}
+			if err = addInode(child.Inode); err != nil {
+				return nil, err
+			}
+			if proto.IsDir(child.Type) {
+				dirs = append(dirs, child.Inode)
+			}
+		}
+	}
+	return reachable, nil
+}
+
+// ListSnapshots_ll lists the subtree snapshots of the volume.
+func (mw *MetaWrapper) ListSnapshots_ll() ([]*proto.SnapshotInfo, error) {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		resultErr error
+		snapshots = make(map[uint64]*proto.SnapshotInfo)
+	)
+	for _, mp := range mw.getPartitions() {
+		wg.Add(1)
+		go func(mp *MetaPartition) {
+			defer wg.Done()
+			status, infos, err := mw.listSnapshots(mp)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || status != statusOK {
+				log.LogErrorf("ListSnapshots_ll: list fail, partitionID(%v) err(%v) status(%v)", mp.PartitionID, err, status)
+				resultErr = statusToErrno(status)
+				return
+			}
+			for _, info := range infos {
+				merged, ok := snapshots[info.ID]
+				if !ok {
+					snapshots[info.ID] = info
+					continue
+				}
+				merged.Inodes += info.Inodes
+				merged.Dentries += info.Dentries
+				merged.Sealed = merged.Sealed && info.Sealed
+			}
+		}(mp)
+	}
+	wg.Wait()
+	if resultErr != nil {
+		return nil, resultErr
+	}
+	result := make([]*proto.SnapshotInfo, 0, len(snapshots))
+	for _, info := range snapshots {
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// RestoreSnapshotFile_ll restores the file of the path from the snapshot. The data of the file is
+// rolled back in place if the path still links it, otherwise the path is linked to it again. The
+// directories are not restored, and the path linking another file is left as is with EEXIST.
+func (mw *MetaWrapper) RestoreSnapshotFile_ll(snapshotID uint64, path string) (*proto.InodeInfo, error) {
+	if mw.snapshotID != 0 {
+		return nil, syscall.EROFS
+	}
+	path = gopath.Clean("/" + path)
+	if path == "/" {
+		return nil, syscall.EISDIR
+	}
+	dir, name := gopath.Split(path)
+
+	// resolve the path in the snapshot
+	parentID := mw.RootIno()
+	for _, elem := range strings.Split(dir, "/") {
+		if elem == "" {
+			continue
+		}
+		child, mode, err := mw.lookupSnapshot(snapshotID, parentID, elem)
+		if err != nil {
+			return nil, err
+		}
+		if !proto.IsDir(mode) {
+			return nil, syscall.ENOTDIR
+		}
+		parentID = child
+	}
+	ino, mode, err := mw.lookupSnapshot(snapshotID, parentID, name)
+	if err != nil {
+		return nil, err
+	}
+	if proto.IsDir(mode) {
+		return nil, syscall.EISDIR
+	}
+
+	liveParentID, err := mw.LookupPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	linked := false
+	switch child, _, err := mw.Lookup_ll(liveParentID, name); {
+	case err == nil && child == ino:
+		linked = true
+	case err == nil:
+		return nil, syscall.EEXIST
+	case err != syscall.ENOENT:
+		return nil, err
+	}
+	parentMP := mw.getPartitionByInode(liveParentID)
+	mp := mw.getPartitionByInode(ino)
+	if parentMP == nil || mp == nil {
+		log.LogErrorf("RestoreSnapshotFile_ll: No such partition, parentID(%v) ino(%v)", liveParentID, ino)
+		return nil, syscall.ENOENT
+	}
+	// the file may still be linked by the other paths
+	status, info, err := mw.iget(mp, ino)
+	alive := err == nil && status == statusOK && info.Nlink > 0
+
+	if status, err = mw.restoreSnapshot(mp, snapshotID, []uint64{ino}); err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	if !linked {
+		if alive {
+			if status, _, err = mw.ilink(mp, ino); err != nil || status != statusOK {
+				return nil, statusToErrno(status)
+			}
+		}
+		if status, err = mw.dcreate(parentMP, liveParentID, name, ino, mode); err != nil || status != statusOK {
+			mw.iunlink(mp, ino)
+			if !alive {
+				mw.ievict(mp, ino)
+			}
+			return nil, statusToErrno(status)
+		}
+	}
+	log.LogInfof("RestoreSnapshotFile_ll: snapshot(%v) path(%v) ino(%v) relinked(%v)", snapshotID, path, ino, !linked)
+	return mw.InodeGet_ll(ino)
+}
+
+func (mw *MetaWrapper) lookupSnapshot(snapshotID, parentID uint64, name string) (inode uint64, mode uint32, err error) {
+	mp := mw.getPartitionByInode(parentID)
+	if mp == nil {
+		return 0, 0, syscall.ENOENT
+	}
+	status, inode, mode, err := mw.lookupAt(mp, snapshotID, parentID, name)
+	if err != nil || status != statusOK {
+		return 0, 0, statusToErrno(status)
+	}
+	return inode, mode, nil
+}
+
+// DeleteSnapshot_ll deletes the snapshot from all the meta partitions.
+func (mw *MetaWrapper) DeleteSnapshot_ll(snapshotID uint64) error {
+	var resultErr error
+	for _, mp := range mw.getPartitions() {
+		status, err := mw.deleteSnapshot(mp, snapshotID)
+		if err != nil || (status != statusOK && status != statusNoent) {
+			log.LogErrorf("DeleteSnapshot_ll: delete fail, partitionID(%v) snapshot(%v) err(%v) status(%v)",
+				mp.PartitionID, snapshotID, err, status)
+			resultErr = statusToErrno(status)
+		}
+	}
+	return resultErr
+}
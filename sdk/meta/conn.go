@@ -17,13 +17,17 @@ package meta
 import (
 	"fmt"
 	"net"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/cubefs/cubefs/util/errors"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/reqid"
+	"github.com/cubefs/cubefs/util/tracing"
 )
 
 const (
@@ -32,8 +36,22 @@ const (
 	SendTimeLimit     = 20 * time.Second
 )
 
+// DefaultRetryPolicy of the requests to the meta nodes.
+var DefaultRetryPolicy = util.RetryPolicy{
+	Timeout:      proto.ReadDeadlineTime * time.Second,
+	MaxRetries:   SendRetryLimit,
+	RetryTimeout: SendTimeLimit,
+	Backoff:      SendRetryInterval,
+	MaxBackoff:   SendRetryInterval,
+}
+
+// RetryPolicy returns the retry policy of the requests to the meta nodes.
+func (mw *MetaWrapper) RetryPolicy() util.RetryPolicy {
+	return mw.retryPolicy
+}
+
 type MetaConn struct {
-	conn *net.TCPConn
+	conn net.Conn
 	id   uint64 //PartitionID
 	addr string //MetaNode addr
 }
@@ -69,6 +87,27 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 	errs := make(map[int]error, len(mp.Members))
 	var j int
 
+	atomic.AddInt64(&mw.inflight, 1)
+	defer atomic.AddInt64(&mw.inflight, -1)
+
+	// the request is the user operation itself, and keeps the request ID through the retries
+	if !req.RequestID.IsValid() {
+		req.RequestID = reqid.New()
+	}
+	span := tracing.StartSpan(req.GetOpMsg(), tracing.SpanKindClient, req.TraceContext)
+	if span != nil {
+		span.SetAttribute("partition", mp.PartitionID)
+		span.SetAttribute("reqID", req.ReqID)
+		span.SetAttribute("requestID", req.RequestID)
+		req.TraceContext = span.Context()
+		defer func() {
+			if resp != nil {
+				span.SetAttribute("result", resp.GetResultMsg())
+			}
+			span.End(err)
+		}()
+	}
+
 	addr = mp.LeaderAddr
 	if addr == "" {
 		err = errors.New(fmt.Sprintf("sendToMetaPartition: failed due to empty leader addr and goto retry, req(%v) mp(%v)", req, mp))
@@ -79,7 +118,7 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 		log.LogWarnf("sendToMetaPartition: getConn failed and goto retry, req(%v) mp(%v) addr(%v) err(%v)", req, mp, addr, err)
 		goto retry
 	}
-	resp, err = mc.send(req)
+	resp, err = mc.send(req, mw.retryPolicy.Timeout)
 	mw.putConn(mc, err)
 	if err == nil && !resp.ShouldRetry() {
 		goto out
@@ -88,15 +127,16 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 
 retry:
 	start = time.Now()
-	for i := 0; i < SendRetryLimit; i++ {
+	for i := 1; i <= mw.retryPolicy.MaxRetries; i++ {
 		for j, addr = range mp.Members {
+			atomic.AddUint64(&mw.retries, 1)
 			mc, err = mw.getConn(mp.PartitionID, addr)
 			errs[j] = err
 			if err != nil {
 				log.LogWarnf("sendToMetaPartition: getConn failed and continue to retry, req(%v) mp(%v) addr(%v) err(%v)", req, mp, addr, err)
 				continue
 			}
-			resp, err = mc.send(req)
+			resp, err = mc.send(req, mw.retryPolicy.Timeout)
 			mw.putConn(mc, err)
 			if err == nil && !resp.ShouldRetry() {
 				goto out
@@ -108,29 +148,32 @@ retry:
 			}
 			log.LogWarnf("sendToMetaPartition: retry failed req(%v) mp(%v) mc(%v) errs(%v) resp(%v)", req, mp, mc, errs, resp)
 		}
-		if time.Since(start) > SendTimeLimit {
+		if mw.retryPolicy.Expired(start) {
 			log.LogWarnf("sendToMetaPartition: retry timeout req(%v) mp(%v) time(%v)", req, mp, time.Since(start))
 			break
 		}
-		log.LogWarnf("sendToMetaPartition: req(%v) mp(%v) retry in (%v)", req, mp, SendRetryInterval)
-		time.Sleep(SendRetryInterval)
+		interval := mw.retryPolicy.Interval(i)
+		log.LogWarnf("sendToMetaPartition: req(%v) mp(%v) retry in (%v)", req, mp, interval)
+		time.Sleep(interval)
 	}
 
 out:
 	if err != nil || resp == nil {
-		return nil, errors.New(fmt.Sprintf("sendToMetaPartition failed: req(%v) mp(%v) errs(%v) resp(%v)", req, mp, errs, resp))
+		atomic.AddUint64(&mw.partitionErrors, 1)
+		err = errors.New(fmt.Sprintf("sendToMetaPartition failed: req(%v) mp(%v) errs(%v) resp(%v)", req, mp, errs, resp))
+		return nil, err
 	}
 	log.LogDebugf("sendToMetaPartition: succeed! req(%v) mc(%v) resp(%v)", req, mc, resp)
 	return resp, nil
 }
 
-func (mc *MetaConn) send(req *proto.Packet) (resp *proto.Packet, err error) {
+func (mc *MetaConn) send(req *proto.Packet, timeout time.Duration) (resp *proto.Packet, err error) {
 	err = req.WriteToConn(mc.conn)
 	if err != nil {
 		return nil, errors.Trace(err, "Failed to write to conn, req(%v)", req)
 	}
 	resp = proto.NewPacket()
-	err = resp.ReadFromConn(mc.conn, proto.ReadDeadlineTime)
+	err = resp.ReadFromConnTimeout(mc.conn, timeout)
 	if err != nil {
 		return nil, errors.Trace(err, "Failed to read from conn, req(%v)", req)
 	}
@@ -0,0 +1,100 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// SetLock_ll acquires or releases a file lock of the inode. It returns EAGAIN if
+// the lock conflicts with the one of another owner.
+func (mw *MetaWrapper) SetLock_ll(inode uint64, lock *proto.FileLock) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetLock_ll: No inode partition, ino(%v)", inode)
+		return syscall.ENOENT
+	}
+	lock.Session = mw.lockSession
+	status, err := mw.setLock(mp, inode, lock)
+	if err != nil || status != statusOK {
+		if status == statusExist {
+			return syscall.EAGAIN
+		}
+		return statusToErrno(status)
+	}
+
+	mw.lockMu.Lock()
+	defer mw.lockMu.Unlock()
+	if locks := proto.ApplyFileLock(mw.heldLocks[inode], lock); len(locks) > 0 {
+		mw.heldLocks[inode] = locks
+	} else {
+		delete(mw.heldLocks, inode)
+	}
+	return nil
+}
+
+// GetLock_ll returns a lock of the inode conflicting with the given one, whose
+// type is proto.FileLockUnlock if there is none.
+func (mw *MetaWrapper) GetLock_ll(inode uint64, lock *proto.FileLock) (*proto.FileLock, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("GetLock_ll: No inode partition, ino(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+	lock.Session = mw.lockSession
+	status, conflict, err := mw.getLock(mp, inode, lock)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return conflict, nil
+}
+
+// renewLocksLoop keeps the locks held by the client alive on the meta nodes.
+func (mw *MetaWrapper) renewLocksLoop() {
+	t := time.NewTicker(proto.FileLockRenewInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-mw.closeCh:
+			return
+		case <-t.C:
+			mw.renewHeldLocks()
+		}
+	}
+}
+
+func (mw *MetaWrapper) renewHeldLocks() {
+	partitions := make(map[*MetaPartition][]*proto.InodeLocks)
+	mw.lockMu.Lock()
+	for ino, locks := range mw.heldLocks {
+		mp := mw.getPartitionByInode(ino)
+		if mp == nil {
+			continue
+		}
+		// the held locks are replaced rather than modified, they can be shared
+		partitions[mp] = append(partitions[mp], &proto.InodeLocks{Inode: ino, Locks: locks})
+	}
+	mw.lockMu.Unlock()
+
+	for mp, inodes := range partitions {
+		if status, err := mw.renewLocks(mp, inodes); err != nil || status != statusOK {
+			log.LogWarnf("renewHeldLocks: mp(%v) status(%v) err(%v)", mp, status, err)
+		}
+	}
+}
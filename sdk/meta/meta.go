@@ -16,7 +16,10 @@ package meta
 
 import (
 	"fmt"
+	"math/rand"
+	gopath "path"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -47,6 +50,7 @@ const (
 	statusInval
 	statusNotPerm
 	statusConflictExtents
+	statusQuota
 )
 
 const (
@@ -77,6 +81,12 @@ type MetaConfig struct {
 	ValidateOwner    bool
 	OnAsyncTaskError AsyncTaskErrorFunc
 	EnableSummary    bool
+	// SnapshotID makes the wrapper serve the given subtree snapshot, read-only.
+	SnapshotID uint64
+	// SubDir roots the path lookups of the wrapper at the given directory of the volume or the snapshot.
+	SubDir string
+	// RetryPolicy of the requests to the meta nodes, the zero fields take DefaultRetryPolicy.
+	RetryPolicy util.RetryPolicy
 }
 
 type MetaWrapper struct {
@@ -129,6 +139,33 @@ type MetaWrapper struct {
 	forceUpdate      chan struct{}
 	forceUpdateLimit *rate.Limiter
 	EnableSummary    bool
+
+	// Non-zero if the wrapper serves a subtree snapshot instead of the live namespace.
+	snapshotID      uint64
+	snapshotRootIno uint64
+
+	// Non-zero if the paths are looked up from a subdirectory instead of the root.
+	subdir        string
+	subdirRootIno uint64
+
+	// File locks held by the client, renewed on the meta nodes in the session.
+	lockSession uint64
+	lockMu      sync.Mutex
+	heldLocks   map[uint64][]*proto.FileLock
+
+	retryPolicy util.RetryPolicy
+
+	inflight        int64  // requests sent to the meta nodes and not replied yet
+	retries         uint64 // requests sent again to the members of the partitions
+	partitionErrors uint64 // requests failed on all the members of the partitions
+}
+
+// Stats are the counters of the requests of a client to the meta nodes.
+type Stats struct {
+	Inflight        int64
+	Retries         uint64
+	PartitionErrors uint64
+	MasterErrors    uint64
 }
 
 //the ticket from authnode
@@ -164,6 +201,7 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 	mw.ownerValidation = config.ValidateOwner
 	mw.mc = masterSDK.NewMasterClient(config.Masters, false)
 	mw.onAsyncTaskError = config.OnAsyncTaskError
+	mw.retryPolicy = config.RetryPolicy.Merge(DefaultRetryPolicy)
 	mw.conns = util.NewConnectPool()
 	mw.partitions = make(map[uint64]*MetaPartition)
 	mw.ranges = btree.New(32)
@@ -172,6 +210,10 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 	mw.forceUpdate = make(chan struct{}, 1)
 	mw.forceUpdateLimit = rate.NewLimiter(1, MinForceUpdateMetaPartitionsInterval)
 	mw.EnableSummary = config.EnableSummary
+	mw.snapshotID = config.SnapshotID
+	mw.subdir = gopath.Clean("/" + config.SubDir)
+	mw.lockSession = uint64(rand.New(rand.NewSource(time.Now().UnixNano())).Int63()) + 1
+	mw.heldLocks = make(map[uint64][]*proto.FileLock)
 
 	limit := MaxMountRetryLimit
 
@@ -195,6 +237,7 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 	}
 
 	go mw.refresh()
+	go mw.renewLocksLoop()
 	return mw, nil
 }
 
@@ -211,9 +254,70 @@ func (mw *MetaWrapper) initMetaWrapper() (err error) {
 		return err
 	}
 
+	if mw.snapshotID != 0 {
+		if err = mw.updateSnapshotRoot(); err != nil {
+			return err
+		}
+	}
+
+	if mw.subdir != "/" {
+		if err = mw.updateSubdirRoot(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateSubdirRoot resolves the directory the paths are looked up from.
+func (mw *MetaWrapper) updateSubdirRoot() error {
+	mw.subdirRootIno = 0
+	ino, err := mw.LookupPath(mw.subdir)
+	if err != nil {
+		return fmt.Errorf("lookup subdir(%v): %v", mw.subdir, err)
+	}
+	info, err := mw.InodeGet_ll(ino)
+	if err != nil {
+		return fmt.Errorf("get subdir(%v): %v", mw.subdir, err)
+	}
+	if !proto.IsDir(info.Mode) {
+		return fmt.Errorf("subdir(%v) is not a directory", mw.subdir)
+	}
+	mw.subdirRootIno = ino
 	return nil
 }
 
+// RootIno returns the inode the paths are looked up from: the subdirectory, the root of the
+// snapshot or the root of the volume.
+func (mw *MetaWrapper) RootIno() uint64 {
+	if mw.subdirRootIno != 0 {
+		return mw.subdirRootIno
+	}
+	if mw.snapshotID != 0 {
+		return mw.snapshotRootIno
+	}
+	return proto.RootIno
+}
+
+// updateSnapshotRoot resolves the root inode of the snapshot served by the wrapper.
+func (mw *MetaWrapper) updateSnapshotRoot() error {
+	snapshots, err := mw.ListSnapshots_ll()
+	if err != nil {
+		return err
+	}
+	for _, info := range snapshots {
+		if info.ID != mw.snapshotID {
+			continue
+		}
+		if !info.Sealed {
+			return fmt.Errorf("snapshot(%v) is incomplete", mw.snapshotID)
+		}
+		mw.snapshotRootIno = info.RootIno
+		return nil
+	}
+	return fmt.Errorf("snapshot(%v) not found", mw.snapshotID)
+}
+
 func (mw *MetaWrapper) Owner() string {
 	return mw.owner
 }
@@ -222,6 +326,21 @@ func (mw *MetaWrapper) OSSSecure() (accessKey, secretKey string) {
 	return mw.ossSecure.AccessKey, mw.ossSecure.SecretKey
 }
 
+// Stats returns the counters of the requests to the meta nodes and the masters.
+func (mw *MetaWrapper) Stats() *Stats {
+	return &Stats{
+		Inflight:        atomic.LoadInt64(&mw.inflight),
+		Retries:         atomic.LoadUint64(&mw.retries),
+		PartitionErrors: atomic.LoadUint64(&mw.partitionErrors),
+		MasterErrors:    mw.mc.ErrorCount(),
+	}
+}
+
+// ReportSLO reports the operations of the volumes served by the client to the master.
+func (mw *MetaWrapper) ReportSLO(reports []*proto.SLOReport) error {
+	return mw.mc.ClientAPI().ReportSLO(&proto.SLOReportRequest{Node: mw.localIP, Reports: reports})
+}
+
 func (mw *MetaWrapper) VolCreateTime() int64 {
 	return mw.volCreateTime
 }
@@ -230,6 +349,7 @@ func (mw *MetaWrapper) Close() error {
 	mw.closeOnce.Do(func() {
 		close(mw.closeCh)
 		mw.conns.Close()
+		mw.mc.Stop()
 	})
 	return nil
 }
@@ -265,6 +385,8 @@ func parseStatus(result uint8) (status int) {
 		status = statusNotPerm
 	case proto.OpConflictExtentsErr:
 		status = statusConflictExtents
+	case proto.OpQuotaExceededErr:
+		status = statusQuota
 	default:
 		status = statusError
 	}
@@ -292,6 +414,8 @@ func statusToErrno(status int) error {
 		return syscall.EAGAIN
 	case statusConflictExtents:
 		return syscall.ENOTSUP
+	case statusQuota:
+		return syscall.EDQUOT
 	default:
 	}
 	return syscall.EIO
@@ -17,6 +17,7 @@ package meta
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/cubefs/cubefs/util/errors"
 
@@ -28,7 +29,7 @@ import (
 // API implementations
 //
 
-func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target []byte) (status int, info *proto.InodeInfo, err error) {
+func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target []byte, quotaIDs []uint64) (status int, info *proto.InodeInfo, err error) {
 	req := &proto.CreateInodeRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
@@ -36,6 +37,7 @@ func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target
 		Uid:         uid,
 		Gid:         gid,
 		Target:      target,
+		QuotaIDs:    quotaIDs,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -298,11 +300,17 @@ func (mw *MetaWrapper) ddelete(mp *MetaPartition, parentID uint64, name string)
 }
 
 func (mw *MetaWrapper) lookup(mp *MetaPartition, parentID uint64, name string) (status int, inode uint64, mode uint32, err error) {
+	return mw.lookupAt(mp, mw.snapshotID, parentID, name)
+}
+
+// lookupAt looks up the name in the given snapshot, or in the live namespace if snapshotID is zero.
+func (mw *MetaWrapper) lookupAt(mp *MetaPartition, snapshotID, parentID uint64, name string) (status int, inode uint64, mode uint32, err error) {
 	req := &proto.LookupRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		ParentID:    parentID,
 		Name:        name,
+		SnapshotID:  snapshotID,
 	}
 	packet := proto.NewPacketReqID()
 	packet.Opcode = proto.OpMetaLookup
@@ -351,6 +359,7 @@ func (mw *MetaWrapper) iget(mp *MetaPartition, inode uint64) (status int, info *
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inode:       inode,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -397,6 +406,7 @@ func (mw *MetaWrapper) batchIget(wg *sync.WaitGroup, mp *MetaPartition, inodes [
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inodes:      inodes,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -441,11 +451,66 @@ func (mw *MetaWrapper) batchIget(wg *sync.WaitGroup, mp *MetaPartition, inodes [
 	}
 }
 
+func (mw *MetaWrapper) batchStat(mp *MetaPartition, items []proto.BatchStatItem) (status int, results []*proto.BatchStatResult, err error) {
+	req := &proto.BatchStatRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Items:       items,
+		SnapshotID:  mw.snapshotID,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaBatchStat
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("batchStat: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("batchStat: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("batchStat: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.BatchStatResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("batchStat: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	if len(resp.Results) != len(items) {
+		err = fmt.Errorf("batchStat: result count mismatch, expect(%v) got(%v)", len(items), len(resp.Results))
+		log.LogErrorf("%v, packet(%v) mp(%v)", err, packet, mp)
+		return
+	}
+	return statusOK, resp.Results, nil
+}
+
 func (mw *MetaWrapper) readdir(mp *MetaPartition, parentID uint64) (status int, children []proto.Dentry, err error) {
+	return mw.readdirAt(mp, mw.snapshotID, parentID)
+}
+
+// readdirAt reads the dir from the given snapshot, or from the live namespace if snapshotID is zero.
+func (mw *MetaWrapper) readdirAt(mp *MetaPartition, snapshotID, parentID uint64) (status int, children []proto.Dentry, err error) {
 	req := &proto.ReadDirRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		ParentID:    parentID,
+		SnapshotID:  snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -493,6 +558,7 @@ func (mw *MetaWrapper) readdirlimit(mp *MetaPartition, parentID uint64, from str
 		ParentID:    parentID,
 		Marker:      from,
 		Limit:       limit,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -568,11 +634,12 @@ func (mw *MetaWrapper) appendExtentKey(mp *MetaPartition, inode uint64, extent p
 	return status, nil
 }
 
-func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int, gen, size uint64, extents []proto.ExtentKey, err error) {
+func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int, gen, size uint64, extents []proto.ExtentKey, inline []byte, cold *proto.ColdLocation, err error) {
 	req := &proto.GetExtentsRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inode:       inode,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -608,7 +675,7 @@ func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int,
 		log.LogErrorf("getExtents: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
 		return
 	}
-	return statusOK, resp.Generation, resp.Size, resp.Extents, nil
+	return statusOK, resp.Generation, resp.Size, resp.Extents, resp.InlineData, resp.Cold, nil
 }
 
 func (mw *MetaWrapper) truncate(mp *MetaPartition, inode, size uint64) (status int, err error) {
@@ -651,6 +718,85 @@ func (mw *MetaWrapper) truncate(mp *MetaPartition, inode, size uint64) (status i
 	return statusOK, nil
 }
 
+func (mw *MetaWrapper) punchHole(mp *MetaPartition, inode, offset, size uint64) (status int, err error) {
+	req := &proto.PunchHoleRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Offset:      offset,
+		Size:        size,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaPunchHole
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("punchHole: ino(%v) offset(%v) size(%v) err(%v)", inode, offset, size, err)
+		return
+	}
+
+	log.LogDebugf("punchHole enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("punchHole: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("punchHole: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("punchHole exit: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return statusOK, nil
+}
+
+func (mw *MetaWrapper) writeInline(mp *MetaPartition, inode uint64, data []byte) (status int, err error) {
+	req := &proto.WriteInlineRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Data:        data,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaWriteInline
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("writeInline: ino(%v) size(%v) err(%v)", inode, len(data), err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("writeInline: packet(%v) mp(%v) ino(%v) err(%v)", packet, mp, inode, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("writeInline: packet(%v) mp(%v) ino(%v) result(%v)", packet, mp, inode, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("writeInline exit: packet(%v) mp(%v) ino(%v) size(%v)", packet, mp, inode, len(data))
+	return statusOK, nil
+}
+
 func (mw *MetaWrapper) ilink(mp *MetaPartition, inode uint64) (status int, info *proto.InodeInfo, err error) {
 	req := &proto.LinkInodeRequest{
 		VolName:     mw.volname,
@@ -701,7 +847,7 @@ func (mw *MetaWrapper) ilink(mp *MetaPartition, inode uint64) (status int, info
 	return statusOK, resp.Info, nil
 }
 
-func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid, gid uint32, atime, mtime int64) (status int, err error) {
+func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid, gid, flags uint32, atime, mtime int64) (status int, err error) {
 	req := &proto.SetAttrRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
@@ -710,9 +856,16 @@ func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid
 		Mode:        mode,
 		Uid:         uid,
 		Gid:         gid,
+		Flags:       flags,
 		AccessTime:  atime,
 		ModifyTime:  mtime,
 	}
+	// the flags are changed by the owner of the volume only
+	if valid&proto.AttrFlags != 0 {
+		if req.AuthKey, err = calculateAuthKey(mw.owner); err != nil {
+			return
+		}
+	}
 
 	packet := proto.NewPacketReqID()
 	packet.Opcode = proto.OpMetaSetattr
@@ -837,12 +990,13 @@ func (mw *MetaWrapper) getMultipart(mp *MetaPartition, path, multipartId string)
 	return statusOK, resp.Info, nil
 }
 
-func (mw *MetaWrapper) addMultipartPart(mp *MetaPartition, path, multipartId string, partId uint16, size uint64, md5 string, indoe uint64) (status int, err error) {
+func (mw *MetaWrapper) addMultipartPart(mp *MetaPartition, path, multipartId string, partId uint16, size uint64, md5 string, indoe uint64, replace bool) (status int, replaced *proto.MultipartPartInfo, err error) {
 	part := &proto.MultipartPartInfo{
-		ID:    partId,
-		Inode: indoe,
-		MD5:   md5,
-		Size:  size,
+		ID:         partId,
+		Inode:      indoe,
+		MD5:        md5,
+		Size:       size,
+		UploadTime: time.Now(),
 	}
 
 	req := &proto.AddMultipartPartRequest{
@@ -851,6 +1005,7 @@ func (mw *MetaWrapper) addMultipartPart(mp *MetaPartition, path, multipartId str
 		Path:        path,
 		MultipartId: multipartId,
 		Part:        part,
+		Replace:     replace,
 	}
 	log.LogDebugf("addMultipartPart: part(%v), req(%v)", part, req)
 	packet := proto.NewPacketReqID()
@@ -880,7 +1035,15 @@ func (mw *MetaWrapper) addMultipartPart(mp *MetaPartition, path, multipartId str
 		return
 	}
 
-	return statusOK, nil
+	if replace && len(packet.Data) > 0 {
+		resp := new(proto.AddMultipartPartResponse)
+		if err = packet.UnmarshalData(resp); err != nil {
+			log.LogErrorf("addMultipartPart: packet(%v) mp(%v) req(%v) part(%v) err(%v) PacketData(%v)", packet, mp, *req, part, err, string(packet.Data))
+			return
+		}
+		replaced = resp.Replaced
+	}
+	return statusOK, replaced, nil
 }
 
 func (mw *MetaWrapper) idelete(mp *MetaPartition, inode uint64) (status int, err error) {
@@ -1210,6 +1373,159 @@ func (mw *MetaWrapper) listMultiparts(mp *MetaPartition, prefix, delimiter, keyM
 	return statusOK, resp, nil
 }
 
+func (mw *MetaWrapper) putObjectVersion(mp *MetaPartition, version *proto.ObjectVersionInfo) (status int, err error) {
+	req := &proto.PutObjectVersionRequest{
+		PartitionId: mp.PartitionID,
+		VolName:     mw.volname,
+		Version:     version,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpPutObjectVersion
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("putObjectVersion: err(%v)", err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("putObjectVersion: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("putObjectVersion: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	return statusOK, nil
+}
+
+func (mw *MetaWrapper) getObjectVersion(mp *MetaPartition, path, versionId string) (status int, version *proto.ObjectVersionInfo, err error) {
+	req := &proto.GetObjectVersionRequest{
+		PartitionId: mp.PartitionID,
+		VolName:     mw.volname,
+		Path:        path,
+		VersionId:   versionId,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpGetObjectVersion
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("getObjectVersion: err(%v)", err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("getObjectVersion: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogDebugf("getObjectVersion: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.GetObjectVersionResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("getObjectVersion: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	return statusOK, resp.Version, nil
+}
+
+func (mw *MetaWrapper) removeObjectVersion(mp *MetaPartition, path, versionId string) (status int, err error) {
+	req := &proto.RemoveObjectVersionRequest{
+		PartitionId: mp.PartitionID,
+		VolName:     mw.volname,
+		Path:        path,
+		VersionId:   versionId,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpRemoveObjectVersion
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("removeObjectVersion: err(%v)", err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("removeObjectVersion: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("removeObjectVersion: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	return statusOK, nil
+}
+
+func (mw *MetaWrapper) listObjectVersions(mp *MetaPartition, prefix, marker, versionIdMarker string, max uint64) (status int, versions []*proto.ObjectVersionInfo, err error) {
+	req := &proto.ListObjectVersionsRequest{
+		PartitionId:     mp.PartitionID,
+		VolName:         mw.volname,
+		Prefix:          prefix,
+		Marker:          marker,
+		VersionIdMarker: versionIdMarker,
+		Max:             max,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpListObjectVersions
+	packet.PartitionID = mp.PartitionID
+	if err = packet.MarshalData(req); err != nil {
+		log.LogErrorf("listObjectVersions: err(%v)", err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("listObjectVersions: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("listObjectVersions: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.ListObjectVersionsResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("listObjectVersions: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	return statusOK, resp.Versions, nil
+}
+
 func (mw *MetaWrapper) batchGetXAttr(mp *MetaPartition, inodes []uint64, keys []string) ([]*proto.XAttrInfo, error) {
 	var (
 		err error
@@ -1304,6 +1620,7 @@ func (mw *MetaWrapper) readdironly(mp *MetaPartition, parentID uint64) (status i
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		ParentID:    parentID,
+		SnapshotID:  mw.snapshotID,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -1342,3 +1659,309 @@ func (mw *MetaWrapper) readdironly(mp *MetaPartition, parentID uint64) (status i
 	log.LogDebugf("readdironly: packet(%v) mp(%v) req(%v)", packet, mp, *req)
 	return statusOK, resp.Children, nil
 }
+
+func (mw *MetaWrapper) createSnapshot(mp *MetaPartition, snapshotID uint64, name string, rootIno uint64) (status int, err error) {
+	req := &proto.CreateSnapshotRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		SnapshotID:  snapshotID,
+		Name:        name,
+		RootIno:     rootIno,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaCreateSnapshot
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("createSnapshot: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("createSnapshot: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("createSnapshot: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("createSnapshot: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return
+}
+
+func (mw *MetaWrapper) sealSnapshot(mp *MetaPartition, snapshotID uint64, inodes []uint64) (status int, err error) {
+	req := &proto.SealSnapshotRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		SnapshotID:  snapshotID,
+		Inodes:      inodes,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSealSnapshot
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("sealSnapshot: mp(%v) snapshot(%v) err(%v)", mp, snapshotID, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("sealSnapshot: packet(%v) mp(%v) snapshot(%v) err(%v)", packet, mp, snapshotID, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("sealSnapshot: packet(%v) mp(%v) snapshot(%v) result(%v)", packet, mp, snapshotID, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("sealSnapshot: packet(%v) mp(%v) snapshot(%v) inodes(%v)", packet, mp, snapshotID, len(inodes))
+	return
+}
+
+func (mw *MetaWrapper) deleteSnapshot(mp *MetaPartition, snapshotID uint64) (status int, err error) {
+	req := &proto.DeleteSnapshotRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		SnapshotID:  snapshotID,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaDeleteSnapshot
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("deleteSnapshot: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("deleteSnapshot: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK && status != statusNoent {
+		log.LogErrorf("deleteSnapshot: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("deleteSnapshot: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+func (mw *MetaWrapper) restoreSnapshot(mp *MetaPartition, snapshotID uint64, inodes []uint64) (status int, err error) {
+	req := &proto.RestoreSnapshotRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		SnapshotID:  snapshotID,
+		Inodes:      inodes,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaRestoreSnapshot
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("restoreSnapshot: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("restoreSnapshot: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("restoreSnapshot: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("restoreSnapshot: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return
+}
+
+func (mw *MetaWrapper) listSnapshots(mp *MetaPartition) (status int, snapshots []*proto.SnapshotInfo, err error) {
+	req := &proto.ListSnapshotsRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaListSnapshots
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("listSnapshots: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("listSnapshots: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("listSnapshots: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.ListSnapshotsResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("listSnapshots: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	return statusOK, resp.Snapshots, nil
+}
+
+func (mw *MetaWrapper) setLock(mp *MetaPartition, inode uint64, lock *proto.FileLock) (status int, err error) {
+	req := &proto.SetLockRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Lock:        *lock,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSetLock
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("setLock: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("setLock: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		// a conflicting lock is not an error
+		log.LogDebugf("setLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("setLock exit: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return
+}
+
+func (mw *MetaWrapper) getLock(mp *MetaPartition, inode uint64, lock *proto.FileLock) (status int, conflict *proto.FileLock, err error) {
+	req := &proto.GetLockRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Lock:        *lock,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetLock
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("getLock: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("getLock: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("getLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.GetLockResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("getLock: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	return statusOK, &resp.Lock, nil
+}
+
+func (mw *MetaWrapper) renewLocks(mp *MetaPartition, inodes []*proto.InodeLocks) (status int, err error) {
+	req := &proto.RenewLocksRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Session:     mw.lockSession,
+		Inodes:      inodes,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaRenewLocks
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("renewLocks: mp(%v) err(%v)", mp, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("renewLocks: packet(%v) mp(%v) err(%v)", packet, mp, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("renewLocks: packet(%v) mp(%v) result(%v)", packet, mp, packet.GetResultMsg())
+		return
+	}
+	return statusOK, nil
+}
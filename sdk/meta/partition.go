@@ -107,6 +107,16 @@ func (mw *MetaWrapper) getPartitionByInode(ino uint64) *MetaPartition {
 //	return rwPartitions
 //}
 
+func (mw *MetaWrapper) getPartitions() []*MetaPartition {
+	mw.RLock()
+	defer mw.RUnlock()
+	partitions := make([]*MetaPartition, 0, len(mw.partitions))
+	for _, mp := range mw.partitions {
+		partitions = append(partitions, mp)
+	}
+	return partitions
+}
+
 func (mw *MetaWrapper) getRWPartitions() []*MetaPartition {
 	mw.RLock()
 	defer mw.RUnlock()
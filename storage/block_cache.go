@@ -0,0 +1,693 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// A BlockCache is a cache tier on an SSD in front of the extent stores on HDDs.
+//
+// The full blocks of the normal extents read several times are promoted into the cache,
+// and the least recently used blocks are evicted when it is full. The cached blocks are
+// invalidated before they are written, and the index of the cache is kept in memory, so
+// that the cache starts empty after a restart and never returns stale data.
+//
+// In the write-back mode, the synchronous writes are also appended to a journal on the SSD,
+// and the extent files are synchronized in the background instead of on each write. The
+// journal is replayed into the extent stores when they are loaded after a crash.
+//
+// The encrypted stores do not use the cache, as it would keep their data in the clear.
+
+const (
+	CacheModeWriteThrough = "writethrough"
+	CacheModeWriteBack    = "writeback"
+
+	CacheDataFileName      = "CACHE_DATA"
+	CacheJournalFilePrefix = "CACHE_JOURNAL_"
+
+	cacheJournalHeaderSize  = 40
+	cacheJournalSegmentSize = 64 * util.MB
+	cacheJournalMaxSize     = util.GB // the synchronous writes bypass the journal beyond it
+	cacheFlushInterval      = time.Second
+	cacheEpochCount         = 256
+	cachePromoteQueueSize   = 1024
+	cacheMaxTrackedBlocks   = 1 << 20
+)
+
+type cacheKey struct {
+	partitionID uint64
+	extentID    uint64
+	blockNo     int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	slot int
+	crc  uint32
+}
+
+type promoteRequest struct {
+	store    *ExtentStore
+	extentID uint64
+	blockNo  int
+	epoch    uint64
+}
+
+// BlockCacheStats defines the statistics of a block cache.
+type BlockCacheStats struct {
+	Path         string
+	Mode         string
+	Capacity     int64
+	CachedBlocks int
+	Hits         uint64
+	Misses       uint64
+	Promotions   uint64
+	JournalBytes int64
+}
+
+type BlockCache struct {
+	sync.Mutex
+	path        string
+	mode        string
+	capacity    int64
+	promoteHits int
+	dataFp      *os.File
+	index       map[cacheKey]*list.Element
+	lru         *list.List // front is the most recently used
+	freeSlots   []int
+	accesses    map[cacheKey]int // reads of the blocks not cached yet
+	epochs      [cacheEpochCount]uint64
+	promoteC    chan *promoteRequest
+	journal     *cacheJournal
+	hits        uint64
+	misses      uint64
+	promotions  uint64
+	stopC       chan struct{}
+}
+
+// NewBlockCache creates the block cache of the given capacity in the directory of an SSD.
+func NewBlockCache(dir string, capacity int64, mode string, promoteHits int) (c *BlockCache, err error) {
+	if mode != CacheModeWriteThrough && mode != CacheModeWriteBack {
+		return nil, fmt.Errorf("unknown cache mode(%v)", mode)
+	}
+	slotCnt := int(capacity / util.BlockSize)
+	if slotCnt <= 0 {
+		return nil, fmt.Errorf("cache capacity(%v) is less than a block", capacity)
+	}
+	if promoteHits <= 0 {
+		promoteHits = 1
+	}
+	c = &BlockCache{
+		path:        dir,
+		mode:        mode,
+		capacity:    int64(slotCnt) * util.BlockSize,
+		promoteHits: promoteHits,
+		index:       make(map[cacheKey]*list.Element),
+		lru:         list.New(),
+		freeSlots:   make([]int, 0, slotCnt),
+		accesses:    make(map[cacheKey]int),
+		promoteC:    make(chan *promoteRequest, cachePromoteQueueSize),
+		stopC:       make(chan struct{}),
+	}
+	for slot := slotCnt - 1; slot >= 0; slot-- {
+		c.freeSlots = append(c.freeSlots, slot)
+	}
+	if c.dataFp, err = os.OpenFile(path.Join(dir, CacheDataFileName), os.O_CREATE|os.O_RDWR, 0666); err != nil {
+		return
+	}
+	if err = c.dataFp.Truncate(c.capacity); err != nil {
+		return
+	}
+	if c.journal, err = openCacheJournal(dir); err != nil {
+		return
+	}
+	go c.promote()
+	if mode == CacheModeWriteBack {
+		go c.flush()
+	}
+	log.LogInfof("action[NewBlockCache] path(%v) capacity(%v) mode(%v) promoteHits(%v)", dir, c.capacity, mode, promoteHits)
+	return
+}
+
+func (c *BlockCache) Path() string {
+	return c.path
+}
+
+func (c *BlockCache) Stats() (stats *BlockCacheStats) {
+	c.Lock()
+	cached := len(c.index)
+	c.Unlock()
+	return &BlockCacheStats{
+		Path:         c.path,
+		Mode:         c.mode,
+		Capacity:     c.capacity,
+		CachedBlocks: cached,
+		Hits:         atomic.LoadUint64(&c.hits),
+		Misses:       atomic.LoadUint64(&c.misses),
+		Promotions:   atomic.LoadUint64(&c.promotions),
+		JournalBytes: c.journal.getSize(),
+	}
+}
+
+func (c *BlockCache) Close() {
+	close(c.stopC)
+	if c.mode == CacheModeWriteBack {
+		c.journal.flush()
+	}
+	c.dataFp.Close()
+}
+
+func (c *BlockCache) epoch(key cacheKey) *uint64 {
+	return &c.epochs[(key.partitionID*31+key.extentID*17+uint64(key.blockNo))%cacheEpochCount]
+}
+
+// read reads the data of a block from the cache, ok is false if it is not cached.
+func (c *BlockCache) read(key cacheKey, data []byte) (ok bool) {
+	c.Lock()
+	elem := c.index[key]
+	if elem == nil {
+		c.Unlock()
+		return
+	}
+	c.lru.MoveToFront(elem)
+	entry := *elem.Value.(*cacheEntry)
+	c.Unlock()
+	if _, err := c.dataFp.ReadAt(data[:util.BlockSize], int64(entry.slot)*util.BlockSize); err != nil {
+		log.LogWarnf("action[BlockCache.read] path(%v) slot(%v) err(%v)", c.path, entry.slot, err)
+		return
+	}
+	// the slot is reused if the block has been evicted meanwhile
+	return crc32.ChecksumIEEE(data[:util.BlockSize]) == entry.crc
+}
+
+// access counts a read of a block from the extent store, and queues the block to be
+// promoted once it is read often enough.
+func (c *BlockCache) access(s *ExtentStore, key cacheKey) {
+	epoch := atomic.LoadUint64(c.epoch(key))
+	c.Lock()
+	if len(c.accesses) >= cacheMaxTrackedBlocks {
+		c.accesses = make(map[cacheKey]int)
+	}
+	c.accesses[key]++
+	if c.accesses[key] < c.promoteHits {
+		c.Unlock()
+		return
+	}
+	delete(c.accesses, key)
+	c.Unlock()
+	select {
+	case c.promoteC <- &promoteRequest{store: s, extentID: key.extentID, blockNo: key.blockNo, epoch: epoch}:
+	default:
+	}
+}
+
+func (c *BlockCache) promote() {
+	data := make([]byte, util.BlockSize)
+	for {
+		select {
+		case <-c.stopC:
+			return
+		case req := <-c.promoteC:
+			key := cacheKey{partitionID: req.store.partitionID, extentID: req.extentID, blockNo: req.blockNo}
+			n, err := req.store.readBlockForCache(req.extentID, req.blockNo, data)
+			if err != nil || n != util.BlockSize {
+				continue
+			}
+			if err = c.insert(key, data, req.epoch); err != nil {
+				log.LogWarnf("action[BlockCache.promote] path(%v) err(%v)", c.path, err)
+			}
+		}
+	}
+}
+
+// insert puts a block into the cache unless it has been invalidated since the given epoch.
+func (c *BlockCache) insert(key cacheKey, data []byte, epoch uint64) (err error) {
+	c.Lock()
+	if c.index[key] != nil || atomic.LoadUint64(c.epoch(key)) != epoch {
+		c.Unlock()
+		return
+	}
+	slot := c.allocSlot()
+	c.Unlock()
+	if _, err = c.dataFp.WriteAt(data[:util.BlockSize], int64(slot)*util.BlockSize); err != nil {
+		c.Lock()
+		c.freeSlots = append(c.freeSlots, slot)
+		c.Unlock()
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.index[key] != nil || atomic.LoadUint64(c.epoch(key)) != epoch {
+		c.freeSlots = append(c.freeSlots, slot)
+		return
+	}
+	c.index[key] = c.lru.PushFront(&cacheEntry{key: key, slot: slot, crc: crc32.ChecksumIEEE(data[:util.BlockSize])})
+	atomic.AddUint64(&c.promotions, 1)
+	return
+}
+
+// allocSlot returns a free slot, evicting the least recently used block if none is free.
+func (c *BlockCache) allocSlot() (slot int) {
+	if n := len(c.freeSlots); n > 0 {
+		slot = c.freeSlots[n-1]
+		c.freeSlots = c.freeSlots[:n-1]
+		return
+	}
+	elem := c.lru.Back()
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.index, entry.key)
+	return entry.slot
+}
+
+func (c *BlockCache) invalidate(key cacheKey) {
+	atomic.AddUint64(c.epoch(key), 1)
+	c.Lock()
+	defer c.Unlock()
+	delete(c.accesses, key)
+	if elem := c.index[key]; elem != nil {
+		c.lru.Remove(elem)
+		delete(c.index, key)
+		c.freeSlots = append(c.freeSlots, elem.Value.(*cacheEntry).slot)
+	}
+}
+
+func (c *BlockCache) invalidateExtent(partitionID, extentID uint64) {
+	c.Lock()
+	defer c.Unlock()
+	for key, elem := range c.index {
+		if key.partitionID == partitionID && key.extentID == extentID {
+			atomic.AddUint64(c.epoch(key), 1)
+			c.lru.Remove(elem)
+			delete(c.index, key)
+			c.freeSlots = append(c.freeSlots, elem.Value.(*cacheEntry).slot)
+		}
+	}
+}
+
+func (c *BlockCache) flush() {
+	ticker := time.NewTicker(cacheFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopC:
+			return
+		case <-ticker.C:
+			c.journal.flush()
+		}
+	}
+}
+
+// Replay applies the journaled writes of the store which may not have reached its disk.
+func (c *BlockCache) Replay(s *ExtentStore) {
+	c.journal.replay(s)
+}
+
+// FinishReplay removes the journal replayed once all the extent stores are loaded.
+func (c *BlockCache) FinishReplay() {
+	c.journal.finishReplay()
+}
+
+// SetBlockCache makes the store use the cache tier.
+func (s *ExtentStore) SetBlockCache(c *BlockCache) {
+	s.blockCache = c
+}
+
+func (s *ExtentStore) usesBlockCache(extentID uint64) bool {
+	return s.blockCache != nil && !IsTinyExtent(extentID) && !s.IsEncrypted()
+}
+
+// readCached reads the data from the cache if all its blocks are cached, or counts the
+// reads of the full blocks otherwise. The caller holds the extent lock.
+func (s *ExtentStore) readCached(e *Extent, nbuf []byte, offset, size int64) (crc uint32, ok bool) {
+	c := s.blockCache
+	firstBlock, lastBlock := int(offset/util.BlockSize), int((offset+size-1)/util.BlockSize)
+	fullBlocks := int(e.Size() / util.BlockSize)
+	if lastBlock >= fullBlocks {
+		return
+	}
+	block := make([]byte, util.BlockSize)
+	for blockNo := firstBlock; blockNo <= lastBlock; blockNo++ {
+		key := cacheKey{partitionID: s.partitionID, extentID: e.extentID, blockNo: blockNo}
+		if !c.read(key, block) {
+			atomic.AddUint64(&c.misses, 1)
+			for ; blockNo <= lastBlock; blockNo++ {
+				c.access(s, cacheKey{partitionID: s.partitionID, extentID: e.extentID, blockNo: blockNo})
+			}
+			return 0, false
+		}
+		start := util.Max(int(offset), blockNo*util.BlockSize)
+		end := util.Min(int(offset+size), (blockNo+1)*util.BlockSize)
+		copy(nbuf[start-int(offset):end-int(offset)], block[start-blockNo*util.BlockSize:])
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return proto.Checksum(e.checksumType, nbuf[:size]), true
+}
+
+// readBlockForCache reads a full block to be promoted into the cache.
+func (s *ExtentStore) readBlockForCache(extentID uint64, blockNo int, data []byte) (n int, err error) {
+	e, err := s.extentWithHeaderByExtentID(extentID)
+	if err != nil {
+		return
+	}
+	if int64(blockNo+1)*util.BlockSize > e.Size() {
+		return 0, io.EOF
+	}
+	lock := s.compressor.extentLock(extentID)
+	lock.RLock()
+	defer lock.RUnlock()
+	return s.readBlock(e, blockNo, data)
+}
+
+func (s *ExtentStore) invalidateCachedBlocks(extentID uint64, offset, size int64) {
+	for blockNo := int(offset / util.BlockSize); int64(blockNo)*util.BlockSize < offset+size; blockNo++ {
+		s.blockCache.invalidate(cacheKey{partitionID: s.partitionID, extentID: extentID, blockNo: blockNo})
+	}
+}
+
+// journalWrite appends a synchronous write to the journal of the cache in the write-back
+// mode, it returns false if the write has to be synchronized to the extent file. Otherwise
+// done has to be called once the write is applied to the extent file.
+func (s *ExtentStore) journalWrite(extentID uint64, offset, size int64, data []byte, crc uint32, writeType int) (done func(), ok bool) {
+	c := s.blockCache
+	if c.mode != CacheModeWriteBack {
+		return
+	}
+	if err := c.journal.append(s, extentID, offset, size, data, crc, writeType); err != nil {
+		log.LogWarnf("action[journalWrite] path(%v) extent(%v) err(%v)", c.path, s.getExtentKey(extentID), err)
+		return
+	}
+	return c.journal.inflight.RUnlock, true
+}
+
+// syncExtent synchronizes the extent file, the data written through the descriptors
+// closed since is synchronized as well.
+func (s *ExtentStore) syncExtent(extentID uint64) (err error) {
+	fp, err := os.OpenFile(path.Join(s.dataPath, strconv.FormatUint(extentID, 10)), os.O_RDWR, 0666)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	return fp.Sync()
+}
+
+type journalRecordPos struct {
+	seq    uint64
+	offset int64
+}
+
+type cacheJournal struct {
+	sync.Mutex
+	inflight    sync.RWMutex // held by the writes journaled but not applied yet
+	dir         string
+	seq         uint64
+	fp          *os.File
+	segmentSize int64
+	size        int64 // size of all the segments
+	maxSize     int64 // the writes are not journaled beyond it
+	dirty       map[*ExtentStore]map[uint64]struct{}
+	replayRecs  map[uint64][]journalRecordPos // records by partition, to be replayed
+	replaySeqs  []uint64
+}
+
+func journalSegmentName(dir string, seq uint64) string {
+	return path.Join(dir, CacheJournalFilePrefix+strconv.FormatUint(seq, 10))
+}
+
+func openCacheJournal(dir string) (j *cacheJournal, err error) {
+	j = &cacheJournal{
+		dir:        dir,
+		maxSize:    cacheJournalMaxSize,
+		dirty:      make(map[*ExtentStore]map[uint64]struct{}),
+		replayRecs: make(map[uint64][]journalRecordPos),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), CacheJournalFilePrefix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimPrefix(entry.Name(), CacheJournalFilePrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		j.replaySeqs = append(j.replaySeqs, seq)
+	}
+	sort.Slice(j.replaySeqs, func(i, k int) bool { return j.replaySeqs[i] < j.replaySeqs[k] })
+	for _, seq := range j.replaySeqs {
+		if err = j.scanSegment(seq); err != nil {
+			return
+		}
+		j.seq = seq
+	}
+	err = j.openSegment(j.seq + 1)
+	return
+}
+
+// scanSegment indexes the valid records of a segment, a torn record ends the segment.
+func (j *cacheJournal) scanSegment(seq uint64) (err error) {
+	fp, err := os.Open(journalSegmentName(j.dir, seq))
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	header := make([]byte, cacheJournalHeaderSize)
+	var offset int64
+	for {
+		if _, err = fp.ReadAt(header, offset); err != nil {
+			break
+		}
+		size := int64(binary.BigEndian.Uint32(header[28:32]))
+		data := make([]byte, size)
+		if _, err = fp.ReadAt(data, offset+cacheJournalHeaderSize); err != nil {
+			break
+		}
+		crc := crc32.ChecksumIEEE(header[4:])
+		if crc32.Update(crc, crc32.IEEETable, data) != binary.BigEndian.Uint32(header[0:4]) {
+			break
+		}
+		partitionID := binary.BigEndian.Uint64(header[4:12])
+		j.replayRecs[partitionID] = append(j.replayRecs[partitionID], journalRecordPos{seq: seq, offset: offset})
+		offset += cacheJournalHeaderSize + size
+	}
+	return nil
+}
+
+func (j *cacheJournal) openSegment(seq uint64) (err error) {
+	fp, err := os.OpenFile(journalSegmentName(j.dir, seq), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+	j.fp, j.seq, j.segmentSize = fp, seq, 0
+	return
+}
+
+func (j *cacheJournal) getSize() int64 {
+	j.Lock()
+	defer j.Unlock()
+	return j.size
+}
+
+func (j *cacheJournal) append(s *ExtentStore, extentID uint64, offset, size int64, data []byte, crc uint32, writeType int) (err error) {
+	record := make([]byte, cacheJournalHeaderSize+size)
+	binary.BigEndian.PutUint64(record[4:12], s.partitionID)
+	binary.BigEndian.PutUint64(record[12:20], extentID)
+	binary.BigEndian.PutUint64(record[20:28], uint64(offset))
+	binary.BigEndian.PutUint32(record[28:32], uint32(size))
+	binary.BigEndian.PutUint32(record[32:36], crc)
+	record[36] = uint8(writeType)
+	copy(record[cacheJournalHeaderSize:], data[:size])
+	binary.BigEndian.PutUint32(record[0:4], crc32.ChecksumIEEE(record[4:]))
+
+	j.inflight.RLock()
+	j.Lock()
+	defer func() {
+		j.Unlock()
+		if err != nil {
+			j.inflight.RUnlock()
+		}
+	}()
+	if j.size+int64(len(record)) > j.maxSize {
+		return fmt.Errorf("journal is full")
+	}
+	if j.segmentSize+int64(len(record)) > cacheJournalSegmentSize {
+		return fmt.Errorf("journal segment is full")
+	}
+	if _, err = j.fp.WriteAt(record, j.segmentSize); err != nil {
+		return
+	}
+	if err = j.fp.Sync(); err != nil {
+		return
+	}
+	j.segmentSize += int64(len(record))
+	j.size += int64(len(record))
+	extents := j.dirty[s]
+	if extents == nil {
+		extents = make(map[uint64]struct{})
+		j.dirty[s] = extents
+	}
+	extents[extentID] = struct{}{}
+	return
+}
+
+// flush synchronizes the extents written since the last flush, and removes the segments
+// of the journal holding their writes.
+func (j *cacheJournal) flush() {
+	// wait for the writes journaled in the segment to be applied
+	j.inflight.Lock()
+	j.Lock()
+	if j.segmentSize == 0 {
+		j.Unlock()
+		j.inflight.Unlock()
+		return
+	}
+	dirty, flushedSeq, flushedSize := j.dirty, j.seq, j.size
+	j.dirty = make(map[*ExtentStore]map[uint64]struct{})
+	j.fp.Close()
+	if err := j.openSegment(j.seq + 1); err != nil {
+		log.LogErrorf("action[cacheJournal.flush] dir(%v) err(%v)", j.dir, err)
+	}
+	j.Unlock()
+	j.inflight.Unlock()
+
+	failed := make(map[*ExtentStore]map[uint64]struct{})
+	for s, extents := range dirty {
+		for extentID := range extents {
+			if err := s.syncExtent(extentID); err != nil {
+				log.LogErrorf("action[cacheJournal.flush] extent(%v) err(%v)", s.getExtentKey(extentID), err)
+				if failed[s] == nil {
+					failed[s] = make(map[uint64]struct{})
+				}
+				failed[s][extentID] = struct{}{}
+			}
+		}
+	}
+	if len(failed) > 0 {
+		// keep the segments, the extents are synchronized again by the next flush
+		j.Lock()
+		for s, extents := range failed {
+			if j.dirty[s] == nil {
+				j.dirty[s] = make(map[uint64]struct{})
+			}
+			for extentID := range extents {
+				j.dirty[s][extentID] = struct{}{}
+			}
+		}
+		j.Unlock()
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+	for seq := flushedSeq; seq > 0; seq-- {
+		if err := os.Remove(journalSegmentName(j.dir, seq)); err != nil {
+			break
+		}
+	}
+	j.size -= flushedSize
+}
+
+func (j *cacheJournal) replay(s *ExtentStore) {
+	j.Lock()
+	records := j.replayRecs[s.partitionID]
+	delete(j.replayRecs, s.partitionID)
+	j.Unlock()
+	var (
+		fp  *os.File
+		seq uint64
+		err error
+	)
+	defer func() {
+		if fp != nil {
+			fp.Close()
+		}
+	}()
+	header := make([]byte, cacheJournalHeaderSize)
+	for _, pos := range records {
+		if fp == nil || seq != pos.seq {
+			if fp != nil {
+				fp.Close()
+			}
+			seq = pos.seq
+			if fp, err = os.Open(journalSegmentName(j.dir, seq)); err != nil {
+				log.LogErrorf("action[cacheJournal.replay] partition(%v) err(%v)", s.partitionID, err)
+				return
+			}
+		}
+		if _, err = fp.ReadAt(header, pos.offset); err != nil {
+			log.LogErrorf("action[cacheJournal.replay] partition(%v) err(%v)", s.partitionID, err)
+			return
+		}
+		extentID := binary.BigEndian.Uint64(header[12:20])
+		offset := int64(binary.BigEndian.Uint64(header[20:28]))
+		size := int64(binary.BigEndian.Uint32(header[28:32]))
+		crc := binary.BigEndian.Uint32(header[32:36])
+		writeType := int(header[36])
+		data := make([]byte, size)
+		if _, err = fp.ReadAt(data, pos.offset+cacheJournalHeaderSize); err != nil {
+			log.LogErrorf("action[cacheJournal.replay] partition(%v) err(%v)", s.partitionID, err)
+			return
+		}
+		if !s.HasExtent(extentID) {
+			continue
+		}
+		// the appends already applied do not match the size of the extent any more
+		if IsAppendWrite(writeType) {
+			if e, err := s.extentWithHeaderByExtentID(extentID); err == nil && e.Size() >= offset+size {
+				writeType = RandomWriteType
+			}
+		}
+		if err = s.Write(extentID, offset, size, data, crc, writeType, true); err != nil {
+			log.LogErrorf("action[cacheJournal.replay] extent(%v) offset(%v) size(%v) err(%v)",
+				s.getExtentKey(extentID), offset, size, err)
+		}
+	}
+	if len(records) > 0 {
+		log.LogInfof("action[cacheJournal.replay] partition(%v) replayed(%v)", s.partitionID, len(records))
+	}
+}
+
+func (j *cacheJournal) finishReplay() {
+	j.Lock()
+	defer j.Unlock()
+	for _, seq := range j.replaySeqs {
+		if err := os.Remove(journalSegmentName(j.dir, seq)); err != nil && !os.IsNotExist(err) {
+			log.LogWarnf("action[cacheJournal.finishReplay] dir(%v) err(%v)", j.dir, err)
+		}
+	}
+	j.replaySeqs = nil
+	j.replayRecs = make(map[uint64][]journalRecordPos)
+}
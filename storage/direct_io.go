@@ -0,0 +1,117 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// DirectIOAlignment is the alignment of the offsets, sizes and buffers of the direct IO.
+const DirectIOAlignment = 4096
+
+var directIO int32
+
+// SetDirectIO makes the extents opened afterwards bypass the page cache for their data IO.
+// The IO not aligned on DirectIOAlignment is widened for the reads, and buffered for the
+// writes, which the filesystem keeps consistent with the direct IO.
+func SetDirectIO(enable bool) {
+	if enable {
+		atomic.StoreInt32(&directIO, 1)
+	} else {
+		atomic.StoreInt32(&directIO, 0)
+	}
+}
+
+func isDirectIO() bool {
+	return atomic.LoadInt32(&directIO) == 1
+}
+
+var directBufPool = sync.Pool{
+	New: func() interface{} {
+		return alignedBuffer(util.BlockSize + 2*DirectIOAlignment)
+	},
+}
+
+// alignedBuffer returns a buffer whose address is aligned on DirectIOAlignment.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+DirectIOAlignment)
+	shift := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & (DirectIOAlignment - 1)); rem != 0 {
+		shift = DirectIOAlignment - rem
+	}
+	return buf[shift : shift+size]
+}
+
+func getDirectBuf(size int) (buf []byte, put func()) {
+	if size > util.BlockSize+2*DirectIOAlignment {
+		return alignedBuffer(size), func() {}
+	}
+	pooled := directBufPool.Get().([]byte)
+	return pooled[:size], func() { directBufPool.Put(pooled) }
+}
+
+// openDirectFile opens the descriptor of the extent for the direct IO if it is enabled.
+func (e *Extent) openDirectFile() {
+	if !isDirectIO() {
+		return
+	}
+	fp, err := openDirect(e.filePath)
+	if err != nil {
+		log.LogWarnf("action[openDirectFile] extent(%v) fall back to the buffered IO, err(%v)", e.filePath, err)
+		return
+	}
+	e.directFile = fp
+}
+
+// directReadAt reads the data by the direct IO, the range read is widened to be aligned.
+func (e *Extent) directReadAt(data []byte, offset int64) (n int, err error) {
+	start := offset &^ (DirectIOAlignment - 1)
+	end := (offset + int64(len(data)) + DirectIOAlignment - 1) &^ (DirectIOAlignment - 1)
+	buf, put := getDirectBuf(int(end - start))
+	defer put()
+	m, err := dataIO.ReadAt(e.directFile, buf, start)
+	if err == io.EOF {
+		// the widened range is short at the end of the extent only
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	if skip := int(offset - start); m > skip {
+		n = copy(data, buf[skip:m])
+	}
+	if n < len(data) {
+		err = io.EOF
+	}
+	return
+}
+
+// directWriteAt writes the data by the direct IO if it is aligned, ok is false otherwise.
+func (e *Extent) directWriteAt(data []byte, offset int64) (n int, ok bool, err error) {
+	if offset%DirectIOAlignment != 0 || len(data)%DirectIOAlignment != 0 {
+		return
+	}
+	buf, put := getDirectBuf(len(data))
+	defer put()
+	copy(buf, data)
+	n, err = dataIO.WriteAt(e.directFile, buf, offset)
+	return n, true, err
+}
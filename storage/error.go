@@ -33,6 +33,8 @@ var (
 	ExtentIsFullError         = errors.New("extent is full")
 	BrokenExtentError         = errors.New("extent has been broken")
 	BrokenDiskError           = errors.New("disk has broken")
+	EncryptKeyNotLoadedError  = errors.New("encrypt key is not loaded")
+	ExtentPackedError         = errors.New("extent is packed")
 )
 
 func NewParameterMismatchErr(msg string) (err error) {
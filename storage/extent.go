@@ -27,6 +27,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/log"
 )
@@ -64,12 +65,18 @@ func (ei *ExtentInfo) String() (m string) {
 // Header of extent include inode value of this extent block and Crc blocks of data blocks.
 type Extent struct {
 	file       *os.File
+	directFile *os.File // the descriptor of the direct IO, nil if it is disabled
 	filePath   string
 	extentID   uint64
 	modifyTime int64
 	dataSize   int64
 	hasClose   int32
 	header     []byte
+	cipher     *extentCipher
+	packed     bool  // the extent is stored in a pack, the file is the descriptor of the pack
+	packOffset int64 // offset of the extent in the pack
+	// checksum type of the block crcs in the header, the crc of the extent itself is always crc32
+	checksumType uint8
 	sync.Mutex
 }
 
@@ -88,9 +95,12 @@ func (e *Extent) HasClosed() bool {
 
 // Close this extent and release FD.
 func (e *Extent) Close() (err error) {
-	if e.HasClosed() {
+	if e.HasClosed() || e.packed {
 		return
 	}
+	if e.directFile != nil {
+		e.directFile.Close()
+	}
 	if err = e.file.Close(); err != nil {
 		return
 	}
@@ -114,6 +124,7 @@ func (e *Extent) InitToFS() (err error) {
 	if e.file, err = os.OpenFile(e.filePath, ExtentOpenOpt, 0666); err != nil {
 		return err
 	}
+	e.openDirectFile()
 
 	defer func() {
 		if err != nil {
@@ -139,6 +150,7 @@ func (e *Extent) RestoreFromFS() (err error) {
 		}
 		return err
 	}
+	e.openDirectFile()
 	var (
 		info os.FileInfo
 	)
@@ -190,11 +202,11 @@ func (e *Extent) WriteTiny(data []byte, offset, size int64, crc uint32, writeTyp
 		return ParameterMismatchError
 	}
 
-	if _, err = e.file.WriteAt(data[:size], int64(offset)); err != nil {
+	if _, err = e.writeAt(data[:size], int64(offset)); err != nil {
 		return
 	}
 	if isSync {
-		if err = e.file.Sync(); err != nil {
+		if err = dataIO.Sync(e.file); err != nil {
 			return
 		}
 	}
@@ -227,7 +239,7 @@ func (e *Extent) Write(data []byte, offset, size int64, crc uint32, writeType in
 		err = NewParameterMismatchErr(fmt.Sprintf("extent current size = %v write offset=%v write size=%v", e.dataSize, offset, size))
 		return
 	}
-	if _, err = e.file.WriteAt(data[:size], int64(offset)); err != nil {
+	if _, err = e.writeAt(data[:size], int64(offset)); err != nil {
 		return
 	}
 	blockNo := offset / util.BlockSize
@@ -239,7 +251,7 @@ func (e *Extent) Write(data []byte, offset, size int64, crc uint32, writeType in
 		}
 	}()
 	if isSync {
-		if err = e.file.Sync(); err != nil {
+		if err = dataIO.Sync(e.file); err != nil {
 			return
 		}
 	}
@@ -266,20 +278,20 @@ func (e *Extent) Read(data []byte, offset, size int64, isRepairRead bool) (crc u
 	if err = e.checkOffsetAndSize(offset, size); err != nil {
 		return
 	}
-	if _, err = e.file.ReadAt(data[:size], offset); err != nil {
+	if _, err = e.readAt(data[:size], offset); err != nil {
 		return
 	}
-	crc = crc32.ChecksumIEEE(data)
+	crc = proto.Checksum(e.checksumType, data)
 	return
 }
 
 // ReadTiny read data from a tiny extent.
 func (e *Extent) ReadTiny(data []byte, offset, size int64, isRepairRead bool) (crc uint32, err error) {
-	_, err = e.file.ReadAt(data[:size], offset)
+	_, err = e.readAt(data[:size], offset)
 	if isRepairRead && err == io.EOF {
 		err = nil
 	}
-	crc = crc32.ChecksumIEEE(data[:size])
+	crc = proto.Checksum(e.checksumType, data[:size])
 
 	return
 }
@@ -300,7 +312,7 @@ func (e *Extent) checkOffsetAndSize(offset, size int64) error {
 
 // Flush synchronizes data to the disk.
 func (e *Extent) Flush() (err error) {
-	err = e.file.Sync()
+	err = dataIO.Sync(e.file)
 	return
 }
 
@@ -319,11 +331,11 @@ func (e *Extent) autoComputeExtentCrc(crcFunc UpdateCrcFunc) (crc uint32, err er
 		}
 		bdata := make([]byte, util.BlockSize)
 		offset := int64(blockNo * util.BlockSize)
-		readN, err := e.file.ReadAt(bdata[:util.BlockSize], offset)
+		readN, err := e.readAt(bdata[:util.BlockSize], offset)
 		if readN == 0 && err != nil {
 			break
 		}
-		blockCrc = crc32.ChecksumIEEE(bdata[:readN])
+		blockCrc = proto.Checksum(e.checksumType, bdata[:readN])
 		err = crcFunc(e, blockNo, blockCrc)
 		if err != nil {
 			return 0, nil
@@ -341,6 +353,53 @@ const (
 	FallocFLPunchHole = 2
 )
 
+// PunchHole deallocates the given range of the extent, the size of the extent is kept.
+func (e *Extent) PunchHole(offset, size int64) (err error) {
+	e.Lock()
+	defer e.Unlock()
+	if e.packed {
+		return ExtentPackedError
+	}
+	if err = fallocate(int(e.file.Fd()), FallocFLPunchHole|FallocFLKeepSize, offset, size); err != nil {
+		return
+	}
+	atomic.StoreInt64(&e.modifyTime, time.Now().Unix())
+	return
+}
+
+// Preallocate extends the extent from the end by the given size, the space is allocated
+// and reads as zeros. If the extent is encrypted, the zeros are encrypted and written instead.
+func (e *Extent) Preallocate(offset, size int64) (err error) {
+	e.Lock()
+	defer e.Unlock()
+	if e.packed {
+		return ExtentPackedError
+	}
+	if offset != e.dataSize {
+		return NewParameterMismatchErr(fmt.Sprintf("extent current size = %v preallocate offset=%v size=%v", e.dataSize, offset, size))
+	}
+	if offset+size > util.BlockSize*util.BlockCount {
+		return NewParameterMismatchErr(fmt.Sprintf("offset=%v size=%v", offset, size))
+	}
+	if e.cipher != nil && e.cipher.isEncrypted() {
+		zeros := make([]byte, util.BlockSize)
+		for off := offset; off < offset+size; off += util.BlockSize {
+			n := int64(util.BlockSize)
+			if off+n > offset+size {
+				n = offset + size - off
+			}
+			if _, err = e.writeAt(zeros[:n], off); err != nil {
+				return
+			}
+		}
+	} else if err = fallocate(int(e.file.Fd()), 0, offset, size); err != nil {
+		return
+	}
+	atomic.StoreInt64(&e.modifyTime, time.Now().Unix())
+	e.dataSize = offset + size
+	return
+}
+
 // DeleteTiny deletes a tiny extent.
 func (e *Extent) DeleteTiny(offset, size int64) (hasDelete bool, err error) {
 	if int(offset)%PageSize != 0 {
@@ -408,7 +467,7 @@ func (e *Extent) TinyExtentRecover(data []byte, offset, size int64, crc uint32,
 		}
 		err = fallocate(int(e.file.Fd()), FallocFLPunchHole|FallocFLKeepSize, offset, size)
 	} else {
-		_, err = e.file.WriteAt(data[:size], int64(offset))
+		_, err = e.writeAt(data[:size], int64(offset))
 	}
 	if err != nil {
 		return
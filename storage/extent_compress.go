@@ -0,0 +1,469 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The full blocks of the normal extents which are not modified any more can be stored
+// compressed. A compressed block is written at the start of the block in place, and the
+// rest of the block is punched, so that the offsets and the sizes of the extents remain.
+// The compressed blocks are recorded in the EXTENT_COMPRESS file, along with the CRC of
+// the compressed data which tells whether the block was actually rewritten when the
+// data node crashed during the compression.
+
+const (
+	CompressRecordSize       = 24
+	compressLockCount        = 64
+	compressAllBlocks        = 0xFFFFFFFF // block number of the record removing all the blocks of an extent
+	compressMinSaving        = PageSize   // a block is stored compressed only if it saves at least a page
+	compressRewriteThreshold = 1024       // obsolete records in the file triggering a rewrite on loading
+)
+
+const (
+	codecNone uint8 = iota
+	codecLZ4
+	codecFlate
+)
+
+type compressedBlock struct {
+	codec  uint8
+	length uint32 // length of the compressed data at the start of the block
+	crc    uint32 // CRC of the compressed data
+}
+
+type blockCompressor struct {
+	sync.Mutex
+	fp         *os.File
+	blocks     map[uint64]map[int]*compressedBlock
+	savedBytes int64
+	// writes and reads of an extent hold the read lock of the extent, the compression
+	// or the inflation of its blocks hold the write lock.
+	extentLocks [compressLockCount]sync.RWMutex
+}
+
+var ErrCorruptCompressedBlock = errors.New("corrupt compressed block")
+
+var flateWriterPool = sync.Pool{New: func() interface{} {
+	w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+	return w
+}}
+
+func codecByName(name string) (codec uint8, err error) {
+	switch name {
+	case proto.CompressionLZ4:
+		return codecLZ4, nil
+	case proto.CompressionFlate:
+		return codecFlate, nil
+	}
+	return codecNone, fmt.Errorf("unknown compression(%v)", name)
+}
+
+func compressData(codec uint8, src []byte) (dst []byte) {
+	switch codec {
+	case codecLZ4:
+		return lz4Compress(src)
+	case codecFlate:
+		buf := bytes.NewBuffer(make([]byte, 0, len(src)))
+		w := flateWriterPool.Get().(*flate.Writer)
+		defer flateWriterPool.Put(w)
+		w.Reset(buf)
+		if _, err := w.Write(src); err != nil {
+			return nil
+		}
+		if err := w.Close(); err != nil {
+			return nil
+		}
+		return buf.Bytes()
+	}
+	return nil
+}
+
+func decompressData(codec uint8, dst, src []byte) (n int, err error) {
+	switch codec {
+	case codecLZ4:
+		return lz4Decompress(dst, src)
+	case codecFlate:
+		r := flate.NewReader(bytes.NewReader(src))
+		defer r.Close()
+		if n, err = io.ReadFull(r, dst); err == io.ErrUnexpectedEOF || err == io.EOF {
+			err = nil
+		}
+		return
+	}
+	return 0, fmt.Errorf("unknown codec(%v)", codec)
+}
+
+func blockSaving(length uint32) int64 {
+	return int64(util.BlockSize) - roundUpToPage(int64(length))
+}
+
+func roundUpToPage(n int64) int64 {
+	return (n + PageSize - 1) / PageSize * PageSize
+}
+
+func (s *ExtentStore) loadCompressedBlocks() (err error) {
+	c := &blockCompressor{blocks: make(map[uint64]map[int]*compressedBlock)}
+	filePath := path.Join(s.dataPath, ExtCompressFileName)
+	if c.fp, err = os.OpenFile(filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666); err != nil {
+		return
+	}
+	data, err := io.ReadAll(c.fp)
+	if err != nil {
+		return
+	}
+	records := len(data) / CompressRecordSize
+	for i := 0; i < records; i++ {
+		extentID, blockNo, cb := unmarshalCompressRecord(data[i*CompressRecordSize : (i+1)*CompressRecordSize])
+		c.apply(extentID, blockNo, cb)
+	}
+	live := 0
+	for _, blocks := range c.blocks {
+		live += len(blocks)
+	}
+	if records-live > compressRewriteThreshold || len(data)%CompressRecordSize != 0 {
+		if err = c.rewrite(filePath); err != nil {
+			return
+		}
+	}
+	s.compressor = c
+	return
+}
+
+func marshalCompressRecord(extentID uint64, blockNo int, cb *compressedBlock) []byte {
+	data := make([]byte, CompressRecordSize)
+	binary.BigEndian.PutUint64(data[0:8], extentID)
+	binary.BigEndian.PutUint32(data[8:12], uint32(blockNo))
+	if cb != nil {
+		binary.BigEndian.PutUint32(data[12:16], cb.length)
+		binary.BigEndian.PutUint32(data[16:20], cb.crc)
+		data[20] = cb.codec
+	}
+	return data
+}
+
+func unmarshalCompressRecord(data []byte) (extentID uint64, blockNo int, cb *compressedBlock) {
+	extentID = binary.BigEndian.Uint64(data[0:8])
+	blockNo = int(binary.BigEndian.Uint32(data[8:12]))
+	if length := binary.BigEndian.Uint32(data[12:16]); length != 0 {
+		cb = &compressedBlock{length: length, crc: binary.BigEndian.Uint32(data[16:20]), codec: data[20]}
+	}
+	return
+}
+
+// apply adds a compressed block, or removes it if cb is nil.
+func (c *blockCompressor) apply(extentID uint64, blockNo int, cb *compressedBlock) {
+	blocks := c.blocks[extentID]
+	if blockNo == compressAllBlocks {
+		for _, old := range blocks {
+			c.savedBytes -= blockSaving(old.length)
+		}
+		delete(c.blocks, extentID)
+		return
+	}
+	if old := blocks[blockNo]; old != nil {
+		c.savedBytes -= blockSaving(old.length)
+		delete(blocks, blockNo)
+	}
+	if cb == nil {
+		if len(blocks) == 0 {
+			delete(c.blocks, extentID)
+		}
+		return
+	}
+	if blocks == nil {
+		blocks = make(map[int]*compressedBlock)
+		c.blocks[extentID] = blocks
+	}
+	blocks[blockNo] = cb
+	c.savedBytes += blockSaving(cb.length)
+}
+
+// record persists a change of the compressed blocks before it is applied.
+func (c *blockCompressor) record(extentID uint64, blockNo int, cb *compressedBlock) (err error) {
+	c.Lock()
+	defer c.Unlock()
+	if _, err = c.fp.Write(marshalCompressRecord(extentID, blockNo, cb)); err != nil {
+		return
+	}
+	if err = c.fp.Sync(); err != nil {
+		return
+	}
+	c.apply(extentID, blockNo, cb)
+	return
+}
+
+func (c *blockCompressor) rewrite(filePath string) (err error) {
+	tmpPath := filePath + ".tmp"
+	buf := make([]byte, 0)
+	for extentID, blocks := range c.blocks {
+		for blockNo, cb := range blocks {
+			buf = append(buf, marshalCompressRecord(extentID, blockNo, cb)...)
+		}
+	}
+	if err = os.WriteFile(tmpPath, buf, 0666); err != nil {
+		return
+	}
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return
+	}
+	c.fp.Close()
+	c.fp, err = os.OpenFile(filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+	return
+}
+
+func (c *blockCompressor) getBlock(extentID uint64, blockNo int) *compressedBlock {
+	c.Lock()
+	defer c.Unlock()
+	return c.blocks[extentID][blockNo]
+}
+
+func (c *blockCompressor) hasBlocks(extentID uint64) bool {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.blocks[extentID]) > 0
+}
+
+func (c *blockCompressor) extentLock(extentID uint64) *sync.RWMutex {
+	return &c.extentLocks[extentID%compressLockCount]
+}
+
+func (c *blockCompressor) close() {
+	c.fp.Sync()
+	c.fp.Close()
+}
+
+// CompressStats returns the number of the compressed blocks and the disk space saved.
+func (s *ExtentStore) CompressStats() (blocks int, savedBytes int64) {
+	c := s.compressor
+	c.Lock()
+	defer c.Unlock()
+	for _, extentBlocks := range c.blocks {
+		blocks += len(extentBlocks)
+	}
+	return blocks, c.savedBytes
+}
+
+// CompressExtent compresses the full blocks of a normal extent which have a CRC and are
+// not compressed yet. The wait function is called with the size of each block before
+// reading it, so that the caller can limit the rate.
+func (s *ExtentStore) CompressExtent(extentID uint64, compression string, wait func(n int)) (compressed int, err error) {
+	codec, err := codecByName(compression)
+	if err != nil {
+		return
+	}
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	if ei == nil || ei.IsDeleted || IsTinyExtent(extentID) || s.packer.isPacked(extentID) {
+		return
+	}
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+	blockCnt := int(e.Size() / util.BlockSize)
+	data := make([]byte, util.BlockSize)
+	for blockNo := 0; blockNo < blockCnt; blockNo++ {
+		if s.compressor.getBlock(extentID, blockNo) != nil {
+			continue
+		}
+		if wait != nil {
+			wait(util.BlockSize)
+		}
+		var ok bool
+		if ok, err = s.compressBlock(e, blockNo, codec, data); err != nil {
+			return
+		}
+		if ok {
+			compressed++
+		}
+	}
+	return
+}
+
+func (s *ExtentStore) compressBlock(e *Extent, blockNo int, codec uint8, data []byte) (ok bool, err error) {
+	lock := s.compressor.extentLock(e.extentID)
+	lock.Lock()
+	defer lock.Unlock()
+	if s.packer.isPacked(e.extentID) {
+		return
+	}
+	blockCrc := binary.BigEndian.Uint32(e.header[blockNo*util.PerBlockCrcSize : (blockNo+1)*util.PerBlockCrcSize])
+	if blockCrc == 0 || s.compressor.getBlock(e.extentID, blockNo) != nil {
+		return
+	}
+	offset := int64(blockNo) * util.BlockSize
+	if _, err = e.readAt(data, offset); err != nil {
+		return
+	}
+	// the data is being modified, or is corrupt and left to the scrubber
+	if proto.Checksum(e.checksumType, data) != blockCrc {
+		return
+	}
+	compressed := compressData(codec, data)
+	if compressed == nil || len(compressed) > util.BlockSize-compressMinSaving {
+		return
+	}
+	cb := &compressedBlock{codec: codec, length: uint32(len(compressed)), crc: crc32.ChecksumIEEE(compressed)}
+	if err = s.compressor.record(e.extentID, blockNo, cb); err != nil {
+		return
+	}
+	if _, err = e.writeAt(compressed, offset); err != nil {
+		return
+	}
+	if err = dataIO.Sync(e.file); err != nil {
+		return
+	}
+	holeOffset := roundUpToPage(int64(len(compressed)))
+	if err = fallocate(int(e.file.Fd()), FallocFLPunchHole|FallocFLKeepSize, offset+holeOffset, util.BlockSize-holeOffset); err != nil {
+		log.LogWarnf("[compressBlock] punch hole of extent(%v) block(%v) err(%v)", s.getExtentKey(e.extentID), blockNo, err)
+		err = nil
+	}
+	return true, nil
+}
+
+// readBlock reads the whole data of a block, decompressing it if it is compressed.
+// The caller holds the extent lock.
+func (s *ExtentStore) readBlock(e *Extent, blockNo int, data []byte) (n int, err error) {
+	offset := int64(blockNo) * util.BlockSize
+	if cb := s.compressor.getBlock(e.extentID, blockNo); cb != nil {
+		compressed := make([]byte, cb.length)
+		if _, err = e.readAt(compressed, offset); err != nil {
+			return
+		}
+		// if the compressed data was not written entirely, the block is still raw
+		if crc32.ChecksumIEEE(compressed) == cb.crc {
+			if n, err = decompressData(cb.codec, data[:util.BlockSize], compressed); err != nil {
+				log.LogWarnf("[readBlock] decompress extent(%v) block(%v) err(%v)", s.getExtentKey(e.extentID), blockNo, err)
+				err = ErrCorruptCompressedBlock
+			}
+			return
+		}
+	}
+	n, err = e.readAt(data[:util.BlockSize], offset)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+// readCompressed reads the data of an extent having compressed blocks.
+func (s *ExtentStore) readCompressed(e *Extent, nbuf []byte, offset, size int64) (crc uint32, err error) {
+	if err = e.checkOffsetAndSize(offset, size); err != nil {
+		return
+	}
+	block := make([]byte, util.BlockSize)
+	for read := int64(0); read < size; {
+		blockNo := int((offset + read) / util.BlockSize)
+		inBlock := (offset + read) % util.BlockSize
+		n := util.Min(int(size-read), int(util.BlockSize-inBlock))
+		if s.compressor.getBlock(e.extentID, blockNo) == nil {
+			if _, err = e.readAt(nbuf[read:read+int64(n)], offset+read); err != nil {
+				return
+			}
+		} else {
+			var blockN int
+			if blockN, err = s.readBlock(e, blockNo, block); err != nil {
+				return
+			}
+			if int(inBlock)+n > blockN {
+				return 0, io.EOF
+			}
+			copy(nbuf[read:read+int64(n)], block[inBlock:])
+		}
+		read += int64(n)
+	}
+	crc = proto.Checksum(e.checksumType, nbuf)
+	return
+}
+
+// inflateBlocks rewrites the compressed blocks in the range raw before they are modified.
+func (s *ExtentStore) inflateBlocks(e *Extent, offset, size int64) (err error) {
+	data := make([]byte, util.BlockSize)
+	for blockNo := int(offset / util.BlockSize); int64(blockNo)*util.BlockSize < offset+size; blockNo++ {
+		if s.compressor.getBlock(e.extentID, blockNo) == nil {
+			continue
+		}
+		var n int
+		n, err = s.readBlock(e, blockNo, data)
+		// a corrupt block is left to the scrubber, which rewrites it entirely
+		if err != nil && err != ErrCorruptCompressedBlock {
+			return
+		}
+		if err == nil {
+			if _, err = e.writeAt(data[:n], int64(blockNo)*util.BlockSize); err != nil {
+				return
+			}
+			if err = dataIO.Sync(e.file); err != nil {
+				return
+			}
+		}
+		if err = s.compressor.record(e.extentID, blockNo, nil); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// lockExtentForWrite holds the read lock of the extent after unpacking it and inflating
+// the compressed blocks to be written. It returns the function to release the lock.
+func (s *ExtentStore) lockExtentForWrite(extentID uint64, offset, size int64) (unlock func(), err error) {
+	lock := s.compressor.extentLock(extentID)
+	for {
+		lock.RLock()
+		if !s.packer.isPacked(extentID) && !s.hasCompressedBlockIn(extentID, offset, size) {
+			return lock.RUnlock, nil
+		}
+		lock.RUnlock()
+		lock.Lock()
+		if err = s.unpackExtent(extentID); err == nil {
+			var e *Extent
+			if e, err = s.extentWithHeaderByExtentID(extentID); err == nil {
+				err = s.inflateBlocks(e, offset, size)
+			}
+		}
+		lock.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *ExtentStore) hasCompressedBlockIn(extentID uint64, offset, size int64) bool {
+	if !s.compressor.hasBlocks(extentID) {
+		return false
+	}
+	for blockNo := int(offset / util.BlockSize); int64(blockNo)*util.BlockSize < offset+size; blockNo++ {
+		if s.compressor.getBlock(extentID, blockNo) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+)
+
+func newTestExtentStore(t *testing.T, dir string) *ExtentStore {
+	s, err := NewExtentStore(dir, 1, 100*util.GB)
+	if err != nil {
+		t.Fatalf("new extent store: %v", err)
+	}
+	return s
+}
+
+// writeTestExtent creates a normal extent and appends the data to it block by block.
+func writeTestExtent(t *testing.T, s *ExtentStore, data []byte) uint64 {
+	extentID, err := s.NextExtentID()
+	if err != nil {
+		t.Fatalf("next extent id: %v", err)
+	}
+	if err = s.Create(extentID); err != nil {
+		t.Fatalf("create extent(%v): %v", extentID, err)
+	}
+	for offset := 0; offset < len(data); offset += util.BlockSize {
+		block := data[offset:util.Min(offset+util.BlockSize, len(data))]
+		crc := proto.Checksum(s.ChecksumType(), block)
+		if err = s.Write(extentID, int64(offset), int64(len(block)), block, crc, AppendWriteType, true); err != nil {
+			t.Fatalf("write extent(%v) offset(%v): %v", extentID, offset, err)
+		}
+	}
+	return extentID
+}
+
+func checkTestExtent(t *testing.T, s *ExtentStore, extentID uint64, data []byte, offset, size int64) {
+	buf := make([]byte, size)
+	crc, err := s.Read(extentID, offset, size, buf, false)
+	if err != nil {
+		t.Fatalf("read extent(%v) offset(%v) size(%v): %v", extentID, offset, size, err)
+	}
+	if !bytes.Equal(buf, data[offset:offset+size]) {
+		t.Fatalf("read extent(%v) offset(%v) size(%v): data mismatch", extentID, offset, size)
+	}
+	if expected := proto.Checksum(s.ChecksumType(), buf); crc != expected {
+		t.Fatalf("read extent(%v) offset(%v) size(%v): crc(%v) expected(%v)", extentID, offset, size, crc, expected)
+	}
+}
+
+// checkTestExtentData reads the whole extent block by block.
+func checkTestExtentData(t *testing.T, s *ExtentStore, extentID uint64, data []byte) {
+	for offset := 0; offset < len(data); offset += util.BlockSize {
+		checkTestExtent(t, s, extentID, data, int64(offset), int64(util.Min(util.BlockSize, len(data)-offset)))
+	}
+}
+
+// compressibleData returns the data of the size made of a few repeated words.
+func compressibleData(size int, seed int64) []byte {
+	words := [][]byte{[]byte("cubefs "), []byte("extent "), []byte("block "), []byte("compress ")}
+	r := rand.New(rand.NewSource(seed))
+	buf := make([]byte, 0, size+16)
+	for len(buf) < size {
+		buf = append(buf, words[r.Intn(len(words))]...)
+	}
+	return buf[:size]
+}
+
+func TestCompressData(t *testing.T) {
+	random := make([]byte, util.BlockSize)
+	rand.New(rand.NewSource(1)).Read(random)
+	inputs := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"short", []byte("abc")},
+		{"zeros", make([]byte, util.BlockSize)},
+		{"words", compressibleData(util.BlockSize, 1)},
+		{"random", random},
+	}
+	for _, codec := range []uint8{codecLZ4, codecFlate} {
+		for _, in := range inputs {
+			compressed := compressData(codec, in.data)
+			if compressed == nil {
+				t.Errorf("codec(%v) %v: not compressed", codec, in.name)
+				continue
+			}
+			dst := make([]byte, util.BlockSize)
+			n, err := decompressData(codec, dst, compressed)
+			if err != nil || !bytes.Equal(dst[:n], in.data) {
+				t.Errorf("codec(%v) %v: decompressed %v bytes, err(%v)", codec, in.name, n, err)
+			}
+		}
+	}
+	if _, err := decompressData(codecNone, make([]byte, 1), []byte{0}); err == nil {
+		t.Errorf("decompress by an unknown codec: expect an error")
+	}
+	if _, err := lz4Decompress(make([]byte, 16), []byte{0xf0}); err == nil {
+		t.Errorf("decompress a truncated lz4 block: expect an error")
+	}
+}
+
+func TestCompressRecord(t *testing.T) {
+	cases := []struct {
+		extentID uint64
+		blockNo  int
+		cb       *compressedBlock
+	}{
+		{1025, 0, &compressedBlock{codec: codecLZ4, length: 4096, crc: 0x12345678}},
+		{1025, 3, &compressedBlock{codec: codecFlate, length: 1, crc: 1}},
+		{1026, 5, nil},
+		{1027, compressAllBlocks, nil},
+	}
+	for _, c := range cases {
+		extentID, blockNo, cb := unmarshalCompressRecord(marshalCompressRecord(c.extentID, c.blockNo, c.cb))
+		if extentID != c.extentID || blockNo != c.blockNo || (cb == nil) != (c.cb == nil) || (cb != nil && *cb != *c.cb) {
+			t.Errorf("record of extent(%v) block(%v): got extent(%v) block(%v) %+v", c.extentID, c.blockNo, extentID, blockNo, cb)
+		}
+	}
+
+	c := &blockCompressor{blocks: make(map[uint64]map[int]*compressedBlock)}
+	c.apply(1, 0, &compressedBlock{length: PageSize})
+	c.apply(1, 1, &compressedBlock{length: PageSize + 1})
+	c.apply(2, 0, &compressedBlock{length: 1})
+	if expected := 3*int64(util.BlockSize) - 4*PageSize; c.savedBytes != expected {
+		t.Errorf("saved bytes %v, expected %v", c.savedBytes, expected)
+	}
+	c.apply(1, 0, nil)
+	c.apply(2, compressAllBlocks, nil)
+	if len(c.blocks) != 1 || len(c.blocks[1]) != 1 || c.savedBytes != int64(util.BlockSize)-2*PageSize {
+		t.Errorf("unexpected blocks %v saved bytes %v", c.blocks, c.savedBytes)
+	}
+}
+
+func TestCompressedExtentReadBack(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestExtentStore(t, dir)
+	data := compressibleData(2*util.BlockSize+1000, 2)
+	extentID := writeTestExtent(t, s, data)
+
+	compressed, err := s.CompressExtent(extentID, proto.CompressionLZ4, nil)
+	if err != nil || compressed != 2 {
+		t.Fatalf("compress extent(%v): %v blocks, err(%v)", extentID, compressed, err)
+	}
+	if blocks, saved := s.CompressStats(); blocks != 2 || saved <= 0 {
+		t.Fatalf("unexpected stats: %v blocks, %v bytes saved", blocks, saved)
+	}
+	ranges := []struct {
+		offset, size int64
+	}{
+		{0, util.BlockSize},
+		{100, 1000},
+		{util.BlockSize - 10, 20},
+		{util.BlockSize, util.BlockSize},
+		{2*util.BlockSize - 1, 1001},
+		{2 * util.BlockSize, 1000},
+	}
+	for _, r := range ranges {
+		checkTestExtent(t, s, extentID, data, r.offset, r.size)
+	}
+
+	// the compressed blocks are loaded again
+	s.Close()
+	s = newTestExtentStore(t, dir)
+	defer s.Close()
+	if blocks, _ := s.CompressStats(); blocks != 2 {
+		t.Fatalf("%v compressed blocks loaded, expected 2", blocks)
+	}
+	for _, r := range ranges {
+		checkTestExtent(t, s, extentID, data, r.offset, r.size)
+	}
+
+	// a write into a compressed block inflates it first
+	patch := []byte("overwritten")
+	copy(data[util.BlockSize+10:], patch)
+	if err = s.Write(extentID, util.BlockSize+10, int64(len(patch)), patch, 0, RandomWriteType, true); err != nil {
+		t.Fatalf("write compressed block: %v", err)
+	}
+	if blocks, _ := s.CompressStats(); blocks != 1 {
+		t.Fatalf("%v compressed blocks after the write, expected 1", blocks)
+	}
+	for _, r := range ranges {
+		checkTestExtent(t, s, extentID, data, r.offset, r.size)
+	}
+}
+
+func TestCompressExtentSkipsIncompressibleBlocks(t *testing.T) {
+	s := newTestExtentStore(t, t.TempDir())
+	defer s.Close()
+	data := make([]byte, util.BlockSize)
+	rand.New(rand.NewSource(3)).Read(data)
+	extentID := writeTestExtent(t, s, data)
+	compressed, err := s.CompressExtent(extentID, proto.CompressionFlate, nil)
+	if err != nil || compressed != 0 {
+		t.Fatalf("compress random extent(%v): %v blocks, err(%v)", extentID, compressed, err)
+	}
+	if _, err = s.CompressExtent(extentID, "unknown", nil); err == nil {
+		t.Errorf("compress by an unknown compression: expect an error")
+	}
+	checkTestExtent(t, s, extentID, data, 0, util.BlockSize)
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// The data of the extents of an encrypted store is encrypted with AES-256 in CTR mode, so that
+// any range of an extent can be encrypted or decrypted alone and the offsets and sizes of the
+// data are kept. Each store uses its own key derived from the data key of the volume, and the
+// counter block of the data at an offset is made of the extent ID and the offset.
+// As the counters of the data rewritten at the same offset are the same, the encryption does
+// not protect against an attacker comparing several copies of a disk taken over time.
+
+const (
+	EncryptKeySize = 32
+)
+
+type extentCipher struct {
+	sync.RWMutex
+	encrypted bool
+	block     cipher.Block
+}
+
+// SetEncrypted marks the store as encrypted, all its data IO fails until the key is set.
+func (s *ExtentStore) SetEncrypted() {
+	s.cipher.Lock()
+	defer s.cipher.Unlock()
+	s.cipher.encrypted = true
+}
+
+// IsEncrypted returns whether the data of the store is encrypted.
+func (s *ExtentStore) IsEncrypted() bool {
+	s.cipher.RLock()
+	defer s.cipher.RUnlock()
+	return s.cipher.encrypted
+}
+
+// EncryptKeyLoaded returns whether the data of the store can be read and written, that is
+// the store is not encrypted or its key is set.
+func (s *ExtentStore) EncryptKeyLoaded() bool {
+	s.cipher.RLock()
+	defer s.cipher.RUnlock()
+	return !s.cipher.encrypted || s.cipher.block != nil
+}
+
+// SetEncryptKey sets the data key of the volume the store belongs to.
+func (s *ExtentStore) SetEncryptKey(dataKey []byte) (err error) {
+	if len(dataKey) != EncryptKeySize {
+		return NewParameterMismatchErr("invalid encrypt key size")
+	}
+	mac := hmac.New(sha256.New, dataKey)
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], s.partitionID)
+	mac.Write(id[:])
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return
+	}
+	s.cipher.Lock()
+	defer s.cipher.Unlock()
+	s.cipher.block = block
+	return
+}
+
+// xor encrypts or decrypts in place the data of an extent at the given offset.
+func (c *extentCipher) xor(data []byte, extentID uint64, offset int64) (err error) {
+	if c == nil || len(data) == 0 {
+		return
+	}
+	c.RLock()
+	encrypted, block := c.encrypted, c.block
+	c.RUnlock()
+	if !encrypted {
+		return
+	}
+	if block == nil {
+		return EncryptKeyNotLoadedError
+	}
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[0:8], extentID)
+	binary.BigEndian.PutUint64(iv[8:16], uint64(offset/aes.BlockSize))
+	stream := cipher.NewCTR(block, iv[:])
+	if skip := int(offset % aes.BlockSize); skip != 0 {
+		var pad [aes.BlockSize]byte
+		stream.XORKeyStream(pad[:skip], pad[:skip])
+	}
+	stream.XORKeyStream(data, data)
+	return
+}
+
+// readAt reads the data of the extent at the given offset and decrypts it.
+func (e *Extent) readAt(data []byte, offset int64) (n int, err error) {
+	if e.packed {
+		n, err = e.packedReadAt(data, offset)
+	} else if e.directFile != nil {
+		n, err = e.directReadAt(data, offset)
+	} else {
+		n, err = dataIO.ReadAt(e.file, data, offset)
+	}
+	if n > 0 {
+		if cerr := e.cipher.xor(data[:n], e.extentID, offset); cerr != nil {
+			return 0, cerr
+		}
+	}
+	return
+}
+
+// writeAt encrypts the data and writes it to the extent at the given offset, the data
+// of the caller is left unchanged.
+func (e *Extent) writeAt(data []byte, offset int64) (n int, err error) {
+	if e.packed {
+		return 0, ExtentPackedError
+	}
+	if e.cipher != nil && e.cipher.isEncrypted() {
+		encrypted := make([]byte, len(data))
+		copy(encrypted, data)
+		if err = e.cipher.xor(encrypted, e.extentID, offset); err != nil {
+			return
+		}
+		data = encrypted
+	}
+	if e.directFile != nil {
+		if n, ok, err := e.directWriteAt(data, offset); ok {
+			return n, err
+		}
+	}
+	return dataIO.WriteAt(e.file, data, offset)
+}
+
+func (c *extentCipher) isEncrypted() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.encrypted
+}
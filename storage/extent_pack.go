@@ -0,0 +1,410 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The small normal extents which are not modified any more can be packed into the pack
+// files of the partition, which saves the file descriptors and the inodes of the local
+// file system taken by the small writes. The data of an extent is appended to a pack file
+// as is, and its location is recorded in the EXTENT_PACK file before the extent file is
+// removed. A packed extent is read from the pack file, and unpacked back into an extent
+// file before it is modified. If both the extent file and the record of an extent are
+// found when loading, the data node crashed while packing or unpacking it, and the
+// extent file is kept.
+
+const (
+	ExtPackFileName       = "EXTENT_PACK"
+	ExtPackDataFilePrefix = "EXTENT_PACK_"
+	PackRecordSize        = 40
+	PackFileMaxSize       = 1 * util.GB
+	packRewriteThreshold  = 1024      // obsolete records in the file triggering a rewrite on loading
+	unpackTmpSuffix       = ".unpack" // suffix of the extent file being unpacked
+)
+
+type packedExtent struct {
+	packID     uint32
+	offset     int64 // offset of the data in the pack file
+	size       int64
+	modifyTime int64
+}
+
+type packFile struct {
+	fp      *os.File
+	size    int64 // size appended, aligned to the page size
+	extents int   // live extents in the pack
+}
+
+type extentPacker struct {
+	sync.Mutex
+	dataPath string
+	fp       *os.File
+	extents  map[uint64]*packedExtent
+	packs    map[uint32]*packFile
+	current  uint32 // pack the extents are appended to
+}
+
+func (p *extentPacker) packPath(packID uint32) string {
+	return path.Join(p.dataPath, ExtPackDataFilePrefix+strconv.FormatUint(uint64(packID), 10))
+}
+
+func (s *ExtentStore) loadPackedExtents() (err error) {
+	p := &extentPacker{
+		dataPath: s.dataPath,
+		extents:  make(map[uint64]*packedExtent),
+		packs:    make(map[uint32]*packFile),
+	}
+	filePath := path.Join(s.dataPath, ExtPackFileName)
+	if p.fp, err = os.OpenFile(filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666); err != nil {
+		return
+	}
+	data, err := io.ReadAll(p.fp)
+	if err != nil {
+		return
+	}
+	records := len(data) / PackRecordSize
+	for i := 0; i < records; i++ {
+		extentID, pe := unmarshalPackRecord(data[i*PackRecordSize : (i+1)*PackRecordSize])
+		if pe == nil {
+			delete(p.extents, extentID)
+		} else {
+			p.extents[extentID] = pe
+		}
+	}
+	if records-len(p.extents) > packRewriteThreshold || len(data)%PackRecordSize != 0 {
+		if err = p.rewrite(filePath); err != nil {
+			return
+		}
+	}
+	for _, pe := range p.extents {
+		if p.packs[pe.packID] == nil {
+			p.packs[pe.packID] = &packFile{}
+		}
+		p.packs[pe.packID].extents++
+		if pe.packID > p.current {
+			p.current = pe.packID
+		}
+	}
+	names, err := filepath.Glob(path.Join(s.dataPath, ExtPackDataFilePrefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		packID, perr := strconv.ParseUint(strings.TrimPrefix(path.Base(name), ExtPackDataFilePrefix), 10, 32)
+		if perr != nil {
+			continue
+		}
+		if p.packs[uint32(packID)] == nil {
+			os.Remove(name)
+		}
+	}
+	for packID, pack := range p.packs {
+		if pack.fp, err = os.OpenFile(p.packPath(packID), os.O_RDWR, 0666); err != nil {
+			err = fmt.Errorf("open pack %v: %v", packID, err)
+			return
+		}
+		var info os.FileInfo
+		if info, err = pack.fp.Stat(); err != nil {
+			return
+		}
+		pack.size = roundUpToPage(info.Size())
+	}
+	if names, err = filepath.Glob(path.Join(s.dataPath, "*"+unpackTmpSuffix)); err != nil {
+		return
+	}
+	for _, name := range names {
+		os.Remove(name)
+	}
+	s.packer = p
+	return
+}
+
+func marshalPackRecord(extentID uint64, pe *packedExtent) []byte {
+	data := make([]byte, PackRecordSize)
+	binary.BigEndian.PutUint64(data[0:8], extentID)
+	if pe != nil {
+		binary.BigEndian.PutUint32(data[8:12], pe.packID)
+		binary.BigEndian.PutUint64(data[16:24], uint64(pe.offset))
+		binary.BigEndian.PutUint64(data[24:32], uint64(pe.size))
+		binary.BigEndian.PutUint64(data[32:40], uint64(pe.modifyTime))
+	}
+	return data
+}
+
+func unmarshalPackRecord(data []byte) (extentID uint64, pe *packedExtent) {
+	extentID = binary.BigEndian.Uint64(data[0:8])
+	if packID := binary.BigEndian.Uint32(data[8:12]); packID != 0 {
+		pe = &packedExtent{
+			packID:     packID,
+			offset:     int64(binary.BigEndian.Uint64(data[16:24])),
+			size:       int64(binary.BigEndian.Uint64(data[24:32])),
+			modifyTime: int64(binary.BigEndian.Uint64(data[32:40])),
+		}
+	}
+	return
+}
+
+// record persists the location of an extent, or its removal if pe is nil. The caller
+// holds the lock of the packer.
+func (p *extentPacker) record(extentID uint64, pe *packedExtent) (err error) {
+	if _, err = p.fp.Write(marshalPackRecord(extentID, pe)); err != nil {
+		return
+	}
+	return p.fp.Sync()
+}
+
+func (p *extentPacker) rewrite(filePath string) (err error) {
+	tmpPath := filePath + ".tmp"
+	buf := make([]byte, 0, len(p.extents)*PackRecordSize)
+	for extentID, pe := range p.extents {
+		buf = append(buf, marshalPackRecord(extentID, pe)...)
+	}
+	if err = os.WriteFile(tmpPath, buf, 0666); err != nil {
+		return
+	}
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return
+	}
+	p.fp.Close()
+	p.fp, err = os.OpenFile(filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+	return
+}
+
+// add appends the raw data of an extent to the current pack, and records its location.
+func (p *extentPacker) add(extentID uint64, data []byte, modifyTime int64) (err error) {
+	p.Lock()
+	defer p.Unlock()
+	pack := p.packs[p.current]
+	if pack == nil || pack.size+int64(len(data)) > PackFileMaxSize {
+		packID := p.current + 1
+		pack = &packFile{}
+		if pack.fp, err = os.OpenFile(p.packPath(packID), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666); err != nil {
+			return
+		}
+		p.packs[packID] = pack
+		p.current = packID
+	}
+	pe := &packedExtent{packID: p.current, offset: pack.size, size: int64(len(data)), modifyTime: modifyTime}
+	if _, err = dataIO.WriteAt(pack.fp, data, pe.offset); err != nil {
+		return
+	}
+	if err = dataIO.Sync(pack.fp); err != nil {
+		return
+	}
+	pack.size += roundUpToPage(pe.size)
+	if err = p.record(extentID, pe); err != nil {
+		return
+	}
+	p.extents[extentID] = pe
+	pack.extents++
+	return
+}
+
+// remove forgets a packed extent and releases its space in the pack, the pack is removed
+// once it has no extent left unless the extents are appended to it.
+func (p *extentPacker) remove(extentID uint64) (err error) {
+	p.Lock()
+	defer p.Unlock()
+	pe := p.extents[extentID]
+	if pe == nil {
+		return
+	}
+	if err = p.record(extentID, nil); err != nil {
+		return
+	}
+	delete(p.extents, extentID)
+	pack := p.packs[pe.packID]
+	if pack == nil {
+		return
+	}
+	pack.extents--
+	if pack.extents == 0 && pe.packID != p.current {
+		pack.fp.Close()
+		delete(p.packs, pe.packID)
+		os.Remove(p.packPath(pe.packID))
+		return
+	}
+	if err = fallocate(int(pack.fp.Fd()), FallocFLPunchHole|FallocFLKeepSize, pe.offset, roundUpToPage(pe.size)); err != nil {
+		log.LogWarnf("[remove] punch hole of extent(%v) in pack(%v) err(%v)", extentID, pe.packID, err)
+		err = nil
+	}
+	return
+}
+
+func (p *extentPacker) get(extentID uint64) (pe *packedExtent, pack *packFile) {
+	p.Lock()
+	defer p.Unlock()
+	if pe = p.extents[extentID]; pe != nil {
+		pack = p.packs[pe.packID]
+	}
+	return
+}
+
+func (p *extentPacker) isPacked(extentID uint64) bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.extents[extentID] != nil
+}
+
+func (p *extentPacker) close() {
+	p.Lock()
+	defer p.Unlock()
+	for _, pack := range p.packs {
+		pack.fp.Close()
+	}
+	p.fp.Sync()
+	p.fp.Close()
+}
+
+// PackStats returns the number of the packed extents and the number of the packs.
+func (s *ExtentStore) PackStats() (extents, packs int) {
+	p := s.packer
+	p.Lock()
+	defer p.Unlock()
+	return len(p.extents), len(p.packs)
+}
+
+// IsPackedExtent tells if the extent is stored in a pack.
+func (s *ExtentStore) IsPackedExtent(extentID uint64) bool {
+	return s.packer.isPacked(extentID)
+}
+
+// PackExtent moves a small normal extent into the current pack of the partition, the
+// extents having compressed blocks are skipped. The wait function is called with the
+// size of the extent before reading it, so that the caller can limit the rate.
+func (s *ExtentStore) PackExtent(extentID uint64, wait func(n int)) (packed bool, err error) {
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	if ei == nil || ei.IsDeleted || IsTinyExtent(extentID) || ei.Size == 0 {
+		return
+	}
+	if wait != nil {
+		wait(int(ei.Size))
+	}
+	lock := s.compressor.extentLock(extentID)
+	lock.Lock()
+	defer lock.Unlock()
+	if s.packer.isPacked(extentID) || s.compressor.hasBlocks(extentID) {
+		return
+	}
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+	data := make([]byte, e.Size())
+	if _, err = dataIO.ReadAt(e.file, data, 0); err != nil {
+		return
+	}
+	if err = s.packer.add(extentID, data, e.ModifyTime()); err != nil {
+		return
+	}
+	s.cache.Del(extentID)
+	if err = os.Remove(e.filePath); err != nil {
+		// the data in the pack is the same, the extent file is kept when loading
+		log.LogWarnf("[PackExtent] remove extent file(%v) err(%v)", e.filePath, err)
+		err = nil
+	}
+	return true, nil
+}
+
+// unpackExtent restores a packed extent into an extent file. The caller holds the
+// write lock of the extent.
+func (s *ExtentStore) unpackExtent(extentID uint64) (err error) {
+	pe, pack := s.packer.get(extentID)
+	if pe == nil || pack == nil {
+		return
+	}
+	data := make([]byte, pe.size)
+	if _, err = dataIO.ReadAt(pack.fp, data, pe.offset); err != nil {
+		return
+	}
+	name := path.Join(s.dataPath, strconv.FormatUint(extentID, 10))
+	tmpName := name + unpackTmpSuffix
+	fp, err := os.OpenFile(tmpName, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmpName)
+		}
+	}()
+	if _, err = dataIO.WriteAt(fp, data, 0); err == nil {
+		err = dataIO.Sync(fp)
+	}
+	fp.Close()
+	if err != nil {
+		return
+	}
+	modifyTime := time.Unix(pe.modifyTime, 0)
+	if err = os.Chtimes(tmpName, modifyTime, modifyTime); err != nil {
+		return
+	}
+	if err = os.Rename(tmpName, name); err != nil {
+		return
+	}
+	if err = s.packer.remove(extentID); err != nil {
+		return
+	}
+	s.cache.Del(extentID)
+	return
+}
+
+// packedExtent returns the extent stored in a pack, it shares the descriptor of the pack
+// and is not put into the cache of the extents.
+func (s *ExtentStore) packedExtent(extentID uint64) (e *Extent, ok bool) {
+	pe, pack := s.packer.get(extentID)
+	if pe == nil || pack == nil {
+		return
+	}
+	e = NewExtentInCore(path.Join(s.dataPath, strconv.FormatUint(extentID, 10)), extentID)
+	e.cipher = s.cipher
+	e.checksumType = s.checksumType
+	e.file = pack.fp
+	e.packed = true
+	e.packOffset = pe.offset
+	e.dataSize = pe.size
+	e.modifyTime = pe.modifyTime
+	return e, true
+}
+
+// packedReadAt reads the raw data of a packed extent, which ends at the size of the extent.
+func (e *Extent) packedReadAt(data []byte, offset int64) (n int, err error) {
+	if offset >= e.dataSize {
+		return 0, io.EOF
+	}
+	if remain := e.dataSize - offset; int64(len(data)) > remain {
+		if n, err = dataIO.ReadAt(e.file, data[:remain], e.packOffset+offset); err == nil {
+			err = io.EOF
+		}
+		return
+	}
+	return dataIO.ReadAt(e.file, data, e.packOffset+offset)
+}
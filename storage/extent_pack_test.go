@@ -0,0 +1,137 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/cubefs/cubefs/util"
+)
+
+func TestPackRecord(t *testing.T) {
+	cases := []struct {
+		extentID uint64
+		pe       *packedExtent
+	}{
+		{1025, &packedExtent{packID: 1, offset: 0, size: 1, modifyTime: 1600000000}},
+		{1026, &packedExtent{packID: 7, offset: 3 * PageSize, size: util.BlockSize, modifyTime: 1}},
+		{1027, nil},
+	}
+	for _, c := range cases {
+		extentID, pe := unmarshalPackRecord(marshalPackRecord(c.extentID, c.pe))
+		if extentID != c.extentID || (pe == nil) != (c.pe == nil) || (pe != nil && *pe != *c.pe) {
+			t.Errorf("record of extent(%v) %+v: got extent(%v) %+v", c.extentID, c.pe, extentID, pe)
+		}
+	}
+}
+
+func TestPackExtents(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestExtentStore(t, dir)
+	sizes := []int{1, PageSize, PageSize + 1, util.BlockSize + 100}
+	extents := make(map[uint64][]byte)
+	var extentIDs []uint64
+	for i, size := range sizes {
+		data := compressibleData(size, int64(i))
+		extentID := writeTestExtent(t, s, data)
+		extents[extentID] = data
+		extentIDs = append(extentIDs, extentID)
+		if packed, err := s.PackExtent(extentID, nil); err != nil || !packed {
+			t.Fatalf("pack extent(%v): packed(%v) err(%v)", extentID, packed, err)
+		}
+		if _, err := os.Stat(path.Join(dir, strconv.FormatUint(extentID, 10))); !os.IsNotExist(err) {
+			t.Fatalf("extent file of the packed extent(%v) is kept: %v", extentID, err)
+		}
+	}
+	checkPacked := func(s *ExtentStore) {
+		for extentID, data := range extents {
+			if !s.IsPackedExtent(extentID) {
+				t.Fatalf("extent(%v) not packed", extentID)
+			}
+			checkTestExtentData(t, s, extentID, data)
+		}
+		// the extents are appended at the page aligned offsets without overlapping
+		var end int64
+		for _, extentID := range extentIDs {
+			pe, _ := s.packer.get(extentID)
+			if pe == nil {
+				continue
+			}
+			if pe.offset%PageSize != 0 || pe.offset < end {
+				t.Fatalf("extent(%v) packed at offset(%v), the previous one ends at %v", extentID, pe.offset, end)
+			}
+			end = pe.offset + pe.size
+		}
+	}
+	checkPacked(s)
+	if n, packs := s.PackStats(); n != len(sizes) || packs != 1 {
+		t.Fatalf("unexpected stats: %v extents in %v packs", n, packs)
+	}
+
+	// the space of a removed extent is not reused by the extents appended after loading
+	removed := extentIDs[1]
+	if err := s.packer.remove(removed); err != nil {
+		t.Fatalf("remove extent(%v): %v", removed, err)
+	}
+	delete(extents, removed)
+	s.Close()
+	s = newTestExtentStore(t, dir)
+	defer s.Close()
+	checkPacked(s)
+	data := compressibleData(100, 100)
+	extentID := writeTestExtent(t, s, data)
+	if packed, err := s.PackExtent(extentID, nil); err != nil || !packed {
+		t.Fatalf("pack extent(%v): packed(%v) err(%v)", extentID, packed, err)
+	}
+	extents[extentID] = data
+	extentIDs = append(extentIDs, extentID)
+	last, _ := s.packer.get(extentIDs[len(sizes)-1])
+	if pe, _ := s.packer.get(extentID); pe.offset < last.offset+last.size {
+		t.Fatalf("extent(%v) packed at offset(%v) overlapping the extent ending at %v", extentID, pe.offset, last.offset+last.size)
+	}
+	checkPacked(s)
+
+	// a write unpacks the extent
+	patch := []byte("unpacked")
+	target := extentIDs[0]
+	extents[target] = append([]byte{}, patch...)
+	if err := s.Write(target, 0, int64(len(patch)), patch, 0, RandomWriteType, true); err != nil {
+		t.Fatalf("write packed extent(%v): %v", target, err)
+	}
+	if s.IsPackedExtent(target) {
+		t.Fatalf("extent(%v) still packed after the write", target)
+	}
+	checkTestExtent(t, s, target, extents[target], 0, int64(len(patch)))
+	delete(extents, target)
+	checkPacked(s)
+}
+
+func TestPackExtentSkipped(t *testing.T) {
+	s := newTestExtentStore(t, t.TempDir())
+	defer s.Close()
+	if packed, err := s.PackExtent(TinyExtentStartID, nil); err != nil || packed {
+		t.Errorf("pack tiny extent: packed(%v) err(%v)", packed, err)
+	}
+	extentID := writeTestExtent(t, s, compressibleData(util.BlockSize, 4))
+	if _, err := s.CompressExtent(extentID, "lz4", nil); err != nil {
+		t.Fatalf("compress extent(%v): %v", extentID, err)
+	}
+	if packed, err := s.PackExtent(extentID, nil); err != nil || packed {
+		t.Errorf("pack compressed extent(%v): packed(%v) err(%v)", extentID, packed, err)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The extent infos of a partition are saved to the EXTENT_INFO file periodically and when
+// the extent store is closed, so that a restarted data node takes them from the file instead
+// of opening every extent file. The size and the modification time of each extent file are
+// saved along with its info, and an extent whose file does not match them any more was
+// modified after the snapshot, so it is loaded from the disk as before. The crc of the
+// extents taken from the snapshot is kept, and does not need to be computed again.
+
+const (
+	ExtInfoSnapshotFileName    = "EXTENT_INFO"
+	ExtInfoSnapshotRecordSize  = 48
+	ExtInfoSnapshotInterval    = 600
+	extInfoSnapshotHeaderSize  = 8
+	extInfoSnapshotTmpFileName = ExtInfoSnapshotFileName + ".tmp"
+)
+
+type extentInfoRecord struct {
+	ei        ExtentInfo
+	fileSize  int64
+	fileMtime int64 // modification time of the extent file in nanoseconds
+}
+
+func marshalExtentInfoRecord(r *extentInfoRecord) []byte {
+	data := make([]byte, ExtInfoSnapshotRecordSize)
+	binary.BigEndian.PutUint64(data[0:8], r.ei.FileID)
+	binary.BigEndian.PutUint64(data[8:16], r.ei.Size)
+	binary.BigEndian.PutUint64(data[16:24], uint64(r.ei.ModifyTime))
+	binary.BigEndian.PutUint32(data[24:28], r.ei.Crc)
+	binary.BigEndian.PutUint64(data[32:40], uint64(r.fileSize))
+	binary.BigEndian.PutUint64(data[40:48], uint64(r.fileMtime))
+	return data
+}
+
+func unmarshalExtentInfoRecord(data []byte) (r *extentInfoRecord) {
+	r = &extentInfoRecord{
+		fileSize:  int64(binary.BigEndian.Uint64(data[32:40])),
+		fileMtime: int64(binary.BigEndian.Uint64(data[40:48])),
+	}
+	r.ei.FileID = binary.BigEndian.Uint64(data[0:8])
+	r.ei.Size = binary.BigEndian.Uint64(data[8:16])
+	r.ei.ModifyTime = int64(binary.BigEndian.Uint64(data[16:24]))
+	r.ei.Crc = binary.BigEndian.Uint32(data[24:28])
+	return
+}
+
+// SaveExtentInfoSnapshot saves the infos of the extents to the snapshot file of the partition.
+func (s *ExtentStore) SaveExtentInfoSnapshot() (err error) {
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+	extentInfos := make([]*ExtentInfo, 0)
+	s.eiMutex.RLock()
+	for _, ei := range s.extentInfoMap {
+		if !ei.IsDeleted {
+			extentInfos = append(extentInfos, ei)
+		}
+	}
+	s.eiMutex.RUnlock()
+
+	buf := make([]byte, extInfoSnapshotHeaderSize, extInfoSnapshotHeaderSize+len(extentInfos)*ExtInfoSnapshotRecordSize)
+	count := 0
+	for _, ei := range extentInfos {
+		// the file is stat before the info is taken, an extent modified in between is loaded
+		// from the disk after a restart, as its file does not match the snapshot
+		info, statErr := os.Lstat(path.Join(s.dataPath, strconv.FormatUint(ei.FileID, 10)))
+		if statErr != nil {
+			continue
+		}
+		r := &extentInfoRecord{
+			ei: ExtentInfo{
+				FileID:     ei.FileID,
+				Size:       ei.Size,
+				Crc:        ei.Crc,
+				ModifyTime: ei.ModifyTime,
+			},
+			fileSize:  info.Size(),
+			fileMtime: info.ModTime().UnixNano(),
+		}
+		buf = append(buf, marshalExtentInfoRecord(r)...)
+		count++
+	}
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[extInfoSnapshotHeaderSize:]))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(count))
+
+	tmpPath := path.Join(s.dataPath, extInfoSnapshotTmpFileName)
+	fp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+	if _, err = fp.Write(buf); err == nil {
+		err = fp.Sync()
+	}
+	fp.Close()
+	if err != nil {
+		return
+	}
+	if err = os.Rename(tmpPath, path.Join(s.dataPath, ExtInfoSnapshotFileName)); err != nil {
+		return
+	}
+	s.snapshotTime = time.Now().Unix()
+	log.LogDebugf("action[SaveExtentInfoSnapshot] datadir(%v) extents(%v)", s.dataPath, count)
+	return
+}
+
+// loadExtentInfoSnapshot returns the records of the snapshot file, or nil if there is
+// no valid snapshot.
+func (s *ExtentStore) loadExtentInfoSnapshot() (records map[uint64]*extentInfoRecord) {
+	data, err := os.ReadFile(path.Join(s.dataPath, ExtInfoSnapshotFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.LogWarnf("datadir(%v) read extent info snapshot err(%v)", s.dataPath, err)
+		}
+		return nil
+	}
+	if err = checkExtentInfoSnapshot(data); err != nil {
+		log.LogWarnf("datadir(%v) ignore extent info snapshot: %v", s.dataPath, err)
+		return nil
+	}
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	records = make(map[uint64]*extentInfoRecord, count)
+	for i := 0; i < count; i++ {
+		offset := extInfoSnapshotHeaderSize + i*ExtInfoSnapshotRecordSize
+		r := unmarshalExtentInfoRecord(data[offset : offset+ExtInfoSnapshotRecordSize])
+		records[r.ei.FileID] = r
+	}
+	return
+}
+
+func checkExtentInfoSnapshot(data []byte) error {
+	if len(data) < extInfoSnapshotHeaderSize {
+		return fmt.Errorf("size(%v) too small", len(data))
+	}
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	if len(data) != extInfoSnapshotHeaderSize+count*ExtInfoSnapshotRecordSize {
+		return fmt.Errorf("size(%v) mismatch records(%v)", len(data), count)
+	}
+	if crc := crc32.ChecksumIEEE(data[extInfoSnapshotHeaderSize:]); crc != binary.BigEndian.Uint32(data[0:4]) {
+		return fmt.Errorf("crc(%v) mismatch", crc)
+	}
+	return nil
+}
+
+// snapshotExtentInfo returns the info of the extent from the snapshot if its file was not
+// modified since then.
+func snapshotExtentInfo(records map[uint64]*extentInfoRecord, extentID uint64, f os.DirEntry) *ExtentInfo {
+	r, ok := records[extentID]
+	if !ok {
+		return nil
+	}
+	info, err := f.Info()
+	if err != nil || info.Size() != r.fileSize || info.ModTime().UnixNano() != r.fileMtime {
+		return nil
+	}
+	ei := r.ei
+	return &ei
+}
+
+func (s *ExtentStore) shouldSaveExtentInfoSnapshot() bool {
+	return time.Now().Unix()-s.snapshotTime > ExtInfoSnapshotInterval
+}
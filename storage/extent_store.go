@@ -44,6 +44,7 @@ const (
 	TinyDeleteFileOpt            = os.O_CREATE | os.O_RDWR | os.O_APPEND
 	TinyExtDeletedFileName       = "TINYEXTENT_DELETE"
 	NormalExtDeletedFileName     = "NORMALEXTENT_DELETE"
+	ExtCompressFileName          = "EXTENT_COMPRESS"
 	MaxExtentCount               = 20000
 	TinyExtentCount              = 64
 	TinyExtentStartID            = 1
@@ -126,6 +127,15 @@ type ExtentStore struct {
 	verifyExtentFp                    *os.File
 	hasAllocSpaceExtentIDOnVerfiyFile uint64
 	hasDeleteNormalExtentsCache       sync.Map
+	compressor                        *blockCompressor
+	packer                            *extentPacker
+	cipher                            *extentCipher
+	blockCache                        *BlockCache
+	writeBuffer                       *WriteBuffer
+	checksumType                      uint8 // checksum type of the data, see proto.ChecksumCrc32c
+	snapshotLock                      sync.Mutex
+	snapshotTime                      int64 // unix time of the last extent info snapshot
+	ioObserver                        IOObserver
 }
 
 func MkdirAll(name string) (err error) {
@@ -136,6 +146,7 @@ func NewExtentStore(dataDir string, partitionID uint64, storeSize int) (s *Exten
 	s = new(ExtentStore)
 	s.dataPath = dataDir
 	s.partitionID = partitionID
+	s.cipher = new(extentCipher)
 	if err = MkdirAll(dataDir); err != nil {
 		return nil, fmt.Errorf("NewExtentStore [%v] err[%v]", dataDir, err)
 	}
@@ -163,6 +174,10 @@ func NewExtentStore(dataDir string, partitionID uint64, storeSize int) (s *Exten
 
 	s.extentInfoMap = make(map[uint64]*ExtentInfo, 0)
 	s.cache = NewExtentCache(100)
+	if err = s.loadPackedExtents(); err != nil {
+		err = fmt.Errorf("load packed extents: %v", err)
+		return
+	}
 	if err = s.initBaseFileID(); err != nil {
 		err = fmt.Errorf("init base field ID: %v", err)
 		return
@@ -175,9 +190,23 @@ func NewExtentStore(dataDir string, partitionID uint64, storeSize int) (s *Exten
 	if err != nil {
 		return
 	}
+	if err = s.loadCompressedBlocks(); err != nil {
+		err = fmt.Errorf("load compressed blocks: %v", err)
+		return
+	}
 	return
 }
 
+// SetChecksumType sets the checksum type of the data of the store, before the store is used.
+func (s *ExtentStore) SetChecksumType(checksumType uint8) {
+	s.checksumType = checksumType
+}
+
+// ChecksumType returns the checksum type of the data of the store.
+func (s *ExtentStore) ChecksumType() uint8 {
+	return s.checksumType
+}
+
 func (ei *ExtentInfo) UpdateExtentInfo(extent *Extent, crc uint32) {
 	extent.Lock()
 	defer extent.Unlock()
@@ -245,6 +274,8 @@ func (s *ExtentStore) Create(extentID uint64) (err error) {
 		return err
 	}
 	e = NewExtentInCore(name, extentID)
+	e.cipher = s.cipher
+	e.checksumType = s.checksumType
 	e.header = make([]byte, util.BlockHeaderSize)
 	err = e.InitToFS()
 	if err != nil {
@@ -270,37 +301,58 @@ func (s *ExtentStore) initBaseFileID() error {
 	if err != nil {
 		return err
 	}
+	snapshot := s.loadExtentInfoSnapshot()
 
 	var (
-		extentID uint64
-		isExtent bool
-		e        *Extent
-		ei       *ExtentInfo
-		loadErr  error
+		extentID    uint64
+		isExtent    bool
+		e           *Extent
+		ei          *ExtentInfo
+		loadErr     error
+		snapshotted int
 	)
 	for _, f := range files {
 		if extentID, isExtent = s.ExtentID(f.Name()); !isExtent {
 			continue
 		}
-		if e, loadErr = s.extent(extentID); loadErr != nil {
-			continue
+		// the data node crashed while packing or unpacking the extent, the file is kept
+		if s.packer.isPacked(extentID) {
+			if loadErr = s.packer.remove(extentID); loadErr != nil {
+				log.LogWarnf("datadir(%v) remove packed extent(%v) err(%v)", s.dataPath, extentID, loadErr)
+			}
+		}
+		if ei = snapshotExtentInfo(snapshot, extentID, f); ei != nil {
+			snapshotted++
+		} else {
+			if e, loadErr = s.extent(extentID); loadErr != nil {
+				continue
+			}
+			ei = &ExtentInfo{FileID: extentID}
+			ei.UpdateExtentInfo(e, 0)
+			e.Close()
 		}
-		ei = &ExtentInfo{FileID: extentID}
-		ei.UpdateExtentInfo(e, 0)
 		s.eiMutex.Lock()
 		s.extentInfoMap[extentID] = ei
 		s.eiMutex.Unlock()
 
-		e.Close()
 		if !IsTinyExtent(extentID) && extentID > baseFileID {
 			baseFileID = extentID
 		}
 	}
+	for extentID, pe := range s.packer.extents {
+		if _, ok := s.extentInfoMap[extentID]; ok {
+			continue
+		}
+		s.extentInfoMap[extentID] = &ExtentInfo{FileID: extentID, Size: uint64(pe.size), ModifyTime: pe.modifyTime}
+		if extentID > baseFileID {
+			baseFileID = extentID
+		}
+	}
 	if baseFileID < MinExtentID {
 		baseFileID = MinExtentID
 	}
 	atomic.StoreUint64(&s.baseExtentID, baseFileID)
-	log.LogInfof("datadir(%v) maxBaseId(%v)", s.dataPath, baseFileID)
+	log.LogInfof("datadir(%v) maxBaseId(%v) extents(%v) from snapshot(%v)", s.dataPath, baseFileID, len(s.extentInfoMap), snapshotted)
 	runtime.GC()
 	return nil
 }
@@ -314,17 +366,55 @@ func (s *ExtentStore) Write(extentID uint64, offset, size int64, data []byte, cr
 	s.eiMutex.RLock()
 	ei, _ = s.extentInfoMap[extentID]
 	s.eiMutex.RUnlock()
+	if ei == nil || ei.IsDeleted {
+		return ExtentNotFoundError
+	}
+	if err = s.checkOffsetAndSize(extentID, offset, size); err != nil {
+		return err
+	}
+	if !IsTinyExtent(extentID) {
+		var unlock func()
+		if unlock, err = s.lockExtentForWrite(extentID, offset, size); err != nil {
+			return err
+		}
+		defer unlock()
+	}
 	e, err = s.extentWithHeader(ei)
 	if err != nil {
 		return err
 	}
-	if err = s.checkOffsetAndSize(extentID, offset, size); err != nil {
-		return err
+	if s.usesBlockCache(extentID) {
+		// invalidate again after the write, as a block may be promoted while it is written
+		s.invalidateCachedBlocks(extentID, offset, size)
+		defer s.invalidateCachedBlocks(extentID, offset, size)
+		if isSync {
+			if done, ok := s.journalWrite(extentID, offset, size, data, crc, writeType); ok {
+				defer done()
+				isSync = false
+			}
+		}
+	}
+	if isSync && s.usesWriteBuffer(extentID, size) {
+		if done, ok := s.bufferWrite(extentID, offset, size, data, crc, writeType); ok {
+			defer done()
+			isSync = false
+		}
 	}
-	err = e.Write(data, offset, size, crc, writeType, isSync, s.PersistenceBlockCrc, ei)
+	// the fsync is timed apart from the write
+	start := time.Now()
+	err = e.Write(data, offset, size, crc, writeType, false, s.PersistenceBlockCrc, ei)
+	s.observeIO(IOTypeWrite, extentID, size, start, err)
 	if err != nil {
 		return err
 	}
+	if isSync {
+		start = time.Now()
+		err = e.Flush()
+		s.observeIO(IOTypeSync, extentID, size, start, err)
+		if err != nil {
+			return err
+		}
+	}
 	ei.UpdateExtentInfo(e, 0)
 
 	return nil
@@ -355,20 +445,76 @@ func IsTinyExtent(extentID uint64) bool {
 // Read reads the extent based on the given id.
 func (s *ExtentStore) Read(extentID uint64, offset, size int64, nbuf []byte, isRepairRead bool) (crc uint32, err error) {
 	var e *Extent
+	start := time.Now()
+	defer func() {
+		s.observeIO(IOTypeRead, extentID, size, start, err)
+	}()
 	s.eiMutex.RLock()
 	ei := s.extentInfoMap[extentID]
 	s.eiMutex.RUnlock()
+	if !IsTinyExtent(extentID) {
+		// the extent may be packed or unpacked until the lock is held
+		lock := s.compressor.extentLock(extentID)
+		lock.RLock()
+		defer lock.RUnlock()
+	}
 	if e, err = s.extentWithHeader(ei); err != nil {
 		return
 	}
 	if err = s.checkOffsetAndSize(extentID, offset, size); err != nil {
 		return
 	}
+	if IsTinyExtent(extentID) {
+		return e.Read(nbuf, offset, size, isRepairRead)
+	}
+	if s.compressor.hasBlocks(extentID) {
+		return s.readCompressed(e, nbuf[:size], offset, size)
+	}
+	if s.usesBlockCache(extentID) {
+		var ok bool
+		if crc, ok = s.readCached(e, nbuf, offset, size); ok {
+			return
+		}
+	}
 	crc, err = e.Read(nbuf, offset, size, isRepairRead)
 
 	return
 }
 
+// ReadZeroCopy calls send with the file of a normal extent, so that a full block of it at the
+// given offset can be sent without being copied through the user space, along with the CRC of
+// the block in the extent header. It returns false without calling send if the block has to be
+// read instead: it is not a full block, it has no CRC yet, its data is encrypted, packed or
+// compressed, or the extent store has a block cache which may hold newer data.
+func (s *ExtentStore) ReadZeroCopy(extentID uint64, offset, size int64, send func(file *os.File, crc uint32) error) (ok bool, err error) {
+	if IsTinyExtent(extentID) || s.IsEncrypted() || s.usesBlockCache(extentID) ||
+		size != util.BlockSize || offset%util.BlockSize != 0 {
+		return
+	}
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	lock := s.compressor.extentLock(extentID)
+	lock.RLock()
+	defer lock.RUnlock()
+	if s.compressor.hasBlocks(extentID) {
+		return
+	}
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+	if e.packed || offset+size > e.dataSize {
+		return
+	}
+	blockNo := int(offset / util.BlockSize)
+	crc := binary.BigEndian.Uint32(e.header[blockNo*util.PerBlockCrcSize : (blockNo+1)*util.PerBlockCrcSize])
+	if crc == 0 {
+		return
+	}
+	return true, send(e.file, crc)
+}
+
 func (s *ExtentStore) tinyDelete(extentID uint64, offset, size int64) (err error) {
 	e, err := s.extentWithHeaderByExtentID(extentID)
 	if err != nil {
@@ -408,8 +554,15 @@ func (s *ExtentStore) MarkDelete(extentID uint64, offset, size int64) (err error
 	if ei == nil || ei.IsDeleted {
 		return
 	}
-	extentFilePath := path.Join(s.dataPath, strconv.FormatUint(extentID, 10))
-	if err = os.Remove(extentFilePath); err != nil {
+	lock := s.compressor.extentLock(extentID)
+	lock.Lock()
+	if s.packer.isPacked(extentID) {
+		err = s.packer.remove(extentID)
+	} else {
+		err = os.Remove(path.Join(s.dataPath, strconv.FormatUint(extentID, 10)))
+	}
+	lock.Unlock()
+	if err != nil {
 		return
 	}
 	s.PersistenceHasDeleteExtent(extentID)
@@ -418,6 +571,15 @@ func (s *ExtentStore) MarkDelete(extentID uint64, offset, size int64) (err error
 	s.cache.Del(extentID)
 	s.DeleteBlockCrc(extentID)
 	s.PutNormalExtentToDeleteCache(extentID)
+	if s.blockCache != nil {
+		s.blockCache.invalidateExtent(s.partitionID, extentID)
+	}
+	if s.compressor.hasBlocks(extentID) {
+		if err = s.compressor.record(extentID, compressAllBlocks, nil); err != nil {
+			log.LogWarnf("[MarkDelete] remove compressed blocks of extent(%v) err(%v)", s.getExtentKey(extentID), err)
+			err = nil
+		}
+	}
 
 	s.eiMutex.Lock()
 	delete(s.extentInfoMap, extentID)
@@ -426,6 +588,77 @@ func (s *ExtentStore) MarkDelete(extentID uint64, offset, size int64) (err error
 	return
 }
 
+// PunchHole deallocates the blocks of a normal extent that lie entirely in the given
+// range, the partial blocks at both ends are kept. It returns the size released.
+func (s *ExtentStore) PunchHole(extentID uint64, offset, size int64) (punched int64, err error) {
+	if IsTinyExtent(extentID) {
+		return 0, ParameterMismatchError
+	}
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	if ei == nil || ei.IsDeleted {
+		return
+	}
+	lock := s.compressor.extentLock(extentID)
+	lock.Lock()
+	defer lock.Unlock()
+	if err = s.unpackExtent(extentID); err != nil {
+		return
+	}
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+	start := (offset + util.BlockSize - 1) / util.BlockSize * util.BlockSize
+	end := offset + size
+	if end > e.Size() {
+		end = e.Size()
+	}
+	end = end / util.BlockSize * util.BlockSize
+	if start >= end {
+		return
+	}
+	for blockNo := int(start / util.BlockSize); int64(blockNo)*util.BlockSize < end; blockNo++ {
+		if s.compressor.getBlock(extentID, blockNo) != nil {
+			if err = s.compressor.record(extentID, blockNo, nil); err != nil {
+				return
+			}
+		}
+		if err = s.PersistenceBlockCrc(e, blockNo, 0); err != nil {
+			return
+		}
+	}
+	if err = e.PunchHole(start, end-start); err != nil {
+		return
+	}
+	if s.blockCache != nil {
+		s.invalidateCachedBlocks(extentID, start, end-start)
+	}
+	ei.UpdateExtentInfo(e, 0)
+	return end - start, nil
+}
+
+// Preallocate allocates the space of a normal extent from its end, so that it can be
+// overwritten later without allocating.
+func (s *ExtentStore) Preallocate(extentID uint64, offset, size int64) (err error) {
+	if IsTinyExtent(extentID) {
+		return ParameterMismatchError
+	}
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+	if err = e.Preallocate(offset, size); err != nil {
+		return
+	}
+	ei.UpdateExtentInfo(e, 0)
+	return
+}
+
 func (s *ExtentStore) PutNormalExtentToDeleteCache(extentID uint64) {
 	s.hasDeleteNormalExtentsCache.Store(extentID, time.Now().Unix())
 }
@@ -446,12 +679,17 @@ func (s *ExtentStore) Close() {
 	// Release cache
 	s.cache.Flush()
 	s.cache.Clear()
+	if err := s.SaveExtentInfoSnapshot(); err != nil {
+		log.LogWarnf("datadir(%v) save extent info snapshot err(%v)", s.dataPath, err)
+	}
 	s.tinyExtentDeleteFp.Sync()
 	s.tinyExtentDeleteFp.Close()
 	s.normalExtentDeleteFp.Sync()
 	s.normalExtentDeleteFp.Close()
 	s.verifyExtentFp.Sync()
 	s.verifyExtentFp.Close()
+	s.compressor.close()
+	s.packer.close()
 	s.closed = true
 }
 
@@ -853,8 +1091,20 @@ func (s *ExtentStore) GetExtentCount() (count int) {
 }
 
 func (s *ExtentStore) loadExtentFromDisk(extentID uint64, putCache bool) (e *Extent, err error) {
+	if !IsTinyExtent(extentID) {
+		var ok bool
+		if e, ok = s.packedExtent(extentID); ok {
+			e.header = make([]byte, util.BlockHeaderSize)
+			if _, err = s.verifyExtentFp.ReadAt(e.header, int64(extentID*util.BlockHeaderSize)); err == io.EOF {
+				err = nil
+			}
+			return
+		}
+	}
 	name := path.Join(s.dataPath, strconv.Itoa(int(extentID)))
 	e = NewExtentInCore(name, extentID)
+	e.cipher = s.cipher
+	e.checksumType = s.checksumType
 	if err = e.RestoreFromFS(); err != nil {
 		err = fmt.Errorf("restore from file %v putCache %v system: %v", name, putCache, err)
 		return
@@ -895,6 +1145,113 @@ func (s *ExtentStore) ScanBlocks(extentID uint64) (bcs []*BlockCrc, err error) {
 	return
 }
 
+// VerifyExtent reads the blocks of a normal extent and checks them against the block
+// CRCs in the extent header, the blocks without a CRC are skipped. It returns the blocks
+// whose data does not match, along with the CRC expected for them. The wait function is
+// called with the size of each block before reading it, so that the caller can limit the rate.
+func (s *ExtentStore) VerifyExtent(extentID uint64, wait func(n int)) (badBlocks []*BlockCrc, err error) {
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	if ei == nil || ei.IsDeleted || IsTinyExtent(extentID) {
+		return
+	}
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+	blockCnt := int(e.Size() / util.BlockSize)
+	if e.Size()%util.BlockSize != 0 {
+		blockCnt += 1
+	}
+	data := make([]byte, util.BlockSize)
+	lock := s.compressor.extentLock(extentID)
+	for blockNo := 0; blockNo < blockCnt; blockNo++ {
+		blockCrc := binary.BigEndian.Uint32(e.header[blockNo*util.PerBlockCrcSize : (blockNo+1)*util.PerBlockCrcSize])
+		if blockCrc == 0 {
+			continue
+		}
+		if wait != nil {
+			wait(util.BlockSize)
+		}
+		var readN int
+		lock.RLock()
+		// the extent may have been packed or unpacked meanwhile
+		if e, err = s.extentWithHeader(ei); err == nil {
+			readN, err = s.readBlock(e, blockNo, data)
+		}
+		lock.RUnlock()
+		if err == ErrCorruptCompressedBlock {
+			badBlocks = append(badBlocks, &BlockCrc{BlockNo: blockNo, Crc: blockCrc})
+			err = nil
+			continue
+		}
+		if readN == 0 && err != nil {
+			return
+		}
+		err = nil
+		if proto.Checksum(s.checksumType, data[:readN]) != blockCrc {
+			badBlocks = append(badBlocks, &BlockCrc{BlockNo: blockNo, Crc: blockCrc})
+		}
+	}
+	return
+}
+
+// VerifyRead checks the data read from a normal extent against the block CRCs in the extent
+// header, and returns proto.ErrBlockCrcMismatch if a block does not match. The blocks partly read
+// are read entirely to be checked, the blocks without a CRC or not full and the extents modified
+// recently are skipped.
+func (s *ExtentStore) VerifyRead(extentID uint64, offset, size int64, data []byte) (err error) {
+	if IsTinyExtent(extentID) || s.usesBlockCache(extentID) || size <= 0 {
+		return
+	}
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	// the CRCs of the blocks being written are not up to date
+	if ei == nil || time.Now().Unix()-ei.ModifyTime <= UpdateCrcInterval {
+		return
+	}
+	lock := s.compressor.extentLock(extentID)
+	lock.RLock()
+	defer lock.RUnlock()
+	e, err := s.extentWithHeader(ei)
+	if err != nil || e.packed {
+		return
+	}
+	var block []byte
+	for blockNo := int(offset / util.BlockSize); int64(blockNo)*util.BlockSize < offset+size; blockNo++ {
+		blockStart := int64(blockNo) * util.BlockSize
+		blockEnd := blockStart + util.BlockSize
+		if blockEnd > e.dataSize {
+			break
+		}
+		blockCrc := binary.BigEndian.Uint32(e.header[blockNo*util.PerBlockCrcSize : (blockNo+1)*util.PerBlockCrcSize])
+		if blockCrc == 0 {
+			continue
+		}
+		var blockData []byte
+		if blockStart >= offset && blockEnd <= offset+size {
+			blockData = data[blockStart-offset : blockEnd-offset]
+		} else {
+			if block == nil {
+				block = make([]byte, util.BlockSize)
+			}
+			var n int
+			if n, err = s.readBlock(e, blockNo, block); err != nil {
+				return
+			}
+			blockData = block[:n]
+		}
+		if proto.Checksum(s.checksumType, blockData) != blockCrc {
+			log.LogWarnf("VerifyRead: extent(%v) block(%v) crc mismatch, offset(%v) size(%v)",
+				s.getExtentKey(extentID), blockNo, offset, size)
+			return proto.ErrBlockCrcMismatch
+		}
+	}
+	return
+}
+
 type ExtentInfoArr []*ExtentInfo
 
 func (arr ExtentInfoArr) Len() int           { return len(arr) }
@@ -904,6 +1261,11 @@ func (arr ExtentInfoArr) Swap(i, j int)      { arr[i], arr[j] = arr[j], arr[i] }
 func (s *ExtentStore) BackendTask() {
 	s.autoComputeExtentCrc()
 	s.cleanExpiredNormalExtentDeleteCache()
+	if s.shouldSaveExtentInfoSnapshot() {
+		if err := s.SaveExtentInfoSnapshot(); err != nil {
+			log.LogWarnf("datadir(%v) save extent info snapshot err(%v)", s.dataPath, err)
+		}
+	}
 }
 
 func (s *ExtentStore) cleanExpiredNormalExtentDeleteCache() {
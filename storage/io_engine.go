@@ -0,0 +1,105 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	IOEngineSync    = "sync"     // blocking IO of the os package
+	IOEngineIOUring = "io_uring" // asynchronous IO of the io_uring interface on linux
+
+	DefaultIOUringEntries = 256
+)
+
+// ioEngine performs the data IO of the extents.
+type ioEngine interface {
+	ReadAt(f *os.File, data []byte, offset int64) (n int, err error)
+	WriteAt(f *os.File, data []byte, offset int64) (n int, err error)
+	Sync(f *os.File) error
+	Close() error
+}
+
+// syncEngine performs the IO by the blocking system calls, one goroutine per request.
+type syncEngine struct{}
+
+func (syncEngine) ReadAt(f *os.File, data []byte, offset int64) (int, error) {
+	return f.ReadAt(data, offset)
+}
+
+func (syncEngine) WriteAt(f *os.File, data []byte, offset int64) (int, error) {
+	return f.WriteAt(data, offset)
+}
+
+func (syncEngine) Sync(f *os.File) error {
+	return f.Sync()
+}
+
+func (syncEngine) Close() error {
+	return nil
+}
+
+var dataIO ioEngine = syncEngine{}
+
+// SetIOEngine selects the engine performing the data IO of the extents. It must
+// be called before any extent store is loaded.
+func SetIOEngine(name string, entries uint32) (err error) {
+	switch name {
+	case "", IOEngineSync:
+		CloseIOEngine()
+	case IOEngineIOUring:
+		if entries == 0 {
+			entries = DefaultIOUringEntries
+		}
+		var ring *ioUring
+		if ring, err = newIOUring(entries); err != nil {
+			return fmt.Errorf("init io_uring: %v", err)
+		}
+		CloseIOEngine()
+		dataIO = ring
+	default:
+		err = fmt.Errorf("unknown io engine(%v)", name)
+	}
+	return
+}
+
+// CloseIOEngine releases the engine of the data IO and falls back to the blocking IO.
+// It must be called after all the extent stores are closed.
+func CloseIOEngine() (err error) {
+	err = dataIO.Close()
+	dataIO = syncEngine{}
+	return
+}
+
+// fullIO repeats the IO until the whole data is transferred, as os.File does.
+func fullIO(op string, f *os.File, data []byte, offset int64, do func(b []byte, off int64) (int, error)) (n int, err error) {
+	for n < len(data) {
+		var m int
+		if m, err = do(data[n:], offset+int64(n)); err != nil {
+			return n, &os.PathError{Op: op, Path: f.Name(), Err: err}
+		}
+		if m == 0 {
+			if op == "read" {
+				return n, io.EOF
+			}
+			return n, &os.PathError{Op: op, Path: f.Name(), Err: io.ErrShortWrite}
+		}
+		n += m
+	}
+	return
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestFullIO(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "fullio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cases := []struct {
+		name    string
+		op      string
+		size    int
+		results []int // bytes transferred by the calls, -1 for an error
+		n       int
+		err     error
+	}{
+		{"whole", "write", 10, []int{10}, 10, nil},
+		{"short transfers", "write", 10, []int{3, 3, 4}, 10, nil},
+		{"nothing read", "read", 10, []int{4, 0}, 4, io.EOF},
+		{"nothing written", "write", 10, []int{4, 0}, 4, io.ErrShortWrite},
+		{"error", "read", 10, []int{6, -1}, 6, syscall.EIO},
+		{"empty", "read", 0, nil, 0, nil},
+	}
+	for _, c := range cases {
+		var calls []int64
+		n, err := fullIO(c.op, f, make([]byte, c.size), 100, func(b []byte, off int64) (int, error) {
+			calls = append(calls, off)
+			m := c.results[len(calls)-1]
+			if m < 0 {
+				return 0, syscall.EIO
+			}
+			return m, nil
+		})
+		if n != c.n || len(calls) != len(c.results) {
+			t.Errorf("%v: %v bytes in %v calls, expected %v bytes in %v calls", c.name, n, len(calls), c.n, len(c.results))
+		}
+		// every call continues at the offset where the previous one stopped
+		offset := int64(100)
+		for i, off := range calls {
+			if off != offset {
+				t.Errorf("%v: call %v at offset %v, expected %v", c.name, i, off, offset)
+			}
+			if c.results[i] > 0 {
+				offset += int64(c.results[i])
+			}
+		}
+		if c.err == nil && err != nil || c.err == io.EOF && err != io.EOF {
+			t.Errorf("%v: err(%v), expected %v", c.name, err, c.err)
+			continue
+		}
+		if c.err != nil && c.err != io.EOF {
+			if pe, ok := err.(*os.PathError); !ok || pe.Op != c.op || pe.Err != c.err {
+				t.Errorf("%v: err(%v), expected %v", c.name, err, c.err)
+			}
+		}
+	}
+}
+
+func TestSetIOEngine(t *testing.T) {
+	defer CloseIOEngine()
+	if err := SetIOEngine("unknown", 0); err == nil {
+		t.Errorf("set unknown io engine: expect an error")
+	}
+	if _, ok := dataIO.(syncEngine); !ok {
+		t.Errorf("the io engine is changed by an unknown engine: %T", dataIO)
+	}
+	if err := SetIOEngine(IOEngineSync, 0); err != nil {
+		t.Errorf("set sync io engine: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"time"
+)
+
+// Types of the data IO reported to the IO observer of an extent store.
+const (
+	IOTypeRead  = "read"
+	IOTypeWrite = "write"
+	IOTypeSync  = "fsync"
+)
+
+// IOObserver is called after every read, write and fsync of the data of the extents, with
+// the time the IO started.
+type IOObserver func(ioType string, extentID uint64, size int64, start time.Time, err error)
+
+// SetIOObserver sets the observer of the data IO of the store, before the store is used.
+func (s *ExtentStore) SetIOObserver(observer IOObserver) {
+	s.ioObserver = observer
+}
+
+func (s *ExtentStore) observeIO(ioType string, extentID uint64, size int64, start time.Time, err error) {
+	if s.ioObserver != nil {
+		s.ioObserver(ioType, extentID, size, start, err)
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The system call numbers of io_uring are the same on all the architectures.
+const (
+	sysIOUringSetup = 425
+	sysIOUringEnter = 426
+)
+
+const (
+	ioringOpNop    = 0
+	ioringOpReadv  = 1
+	ioringOpWritev = 2
+	ioringOpFsync  = 3
+
+	ioringEnterGetEvents = 1 << 0
+
+	ioringOffSqRing = 0
+	ioringOffCqRing = 0x8000000
+	ioringOffSqes   = 0x10000000
+
+	ioUringSqeSize = 64
+	ioUringCqeSize = 16
+)
+
+type ioSqringOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ioCqringOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ioUringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        ioSqringOffsets
+	cqOff        ioCqringOffsets
+}
+
+type ioUringSqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+type ioUringCqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// stopReqID is the user data of the entry which wakes the reaper up to stop it, the IDs
+// of the requests start from 1.
+const stopReqID = 0
+
+var errIOUringClosed = errors.New("io_uring: closed")
+
+// ioUringRequest is an IO in flight. The iovec and the buffer it points to are
+// referenced by the request until the completion.
+type ioUringRequest struct {
+	iov  syscall.Iovec
+	done chan int32
+}
+
+// ioUring submits the IO of all the extents to a single ring and reaps the completions
+// in a dedicated goroutine, which saves the threads blocked by the system calls.
+type ioUring struct {
+	fd      int
+	sqRing  []byte
+	cqRing  []byte
+	sqes    []byte
+	params  ioUringParams
+	slots   chan struct{} // limits the IO in flight to the size of the rings
+	sqLock  sync.Mutex
+	reqLock sync.Mutex
+	reqID   uint64
+	pending map[uint64]*ioUringRequest
+	err     error         // the ring accepts no more IO once it is set, guarded by reqLock
+	reaped  chan struct{} // closed when the reaper exits
+	closed  sync.Once
+}
+
+func newIOUring(entries uint32) (ring *ioUring, err error) {
+	ring = &ioUring{pending: make(map[uint64]*ioUringRequest), reaped: make(chan struct{})}
+	fd, _, errno := syscall.Syscall(sysIOUringSetup, uintptr(entries), uintptr(unsafe.Pointer(&ring.params)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	ring.fd = int(fd)
+	p := &ring.params
+	sqRingSize := int(p.sqOff.array + p.sqEntries*4)
+	cqRingSize := int(p.cqOff.cqes + p.cqEntries*ioUringCqeSize)
+	if ring.sqRing, err = syscall.Mmap(ring.fd, ioringOffSqRing, sqRingSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		ring.release()
+		return nil, err
+	}
+	if ring.cqRing, err = syscall.Mmap(ring.fd, ioringOffCqRing, cqRingSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		ring.release()
+		return nil, err
+	}
+	if ring.sqes, err = syscall.Mmap(ring.fd, ioringOffSqes, int(p.sqEntries*ioUringSqeSize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		ring.release()
+		return nil, err
+	}
+	ring.slots = make(chan struct{}, p.sqEntries)
+	go ring.reap()
+	return
+}
+
+// Close refuses the new IO, stops the reaper once the IO in flight is completed and
+// releases the ring.
+func (r *ioUring) Close() (err error) {
+	r.closed.Do(func() {
+		r.reqLock.Lock()
+		if r.err == nil {
+			r.err = errIOUringClosed
+		}
+		r.reqLock.Unlock()
+		select {
+		case <-r.reaped:
+		default:
+			if err = r.push(ioUringSqe{opcode: ioringOpNop, fd: -1, userData: stopReqID}); err != nil {
+				// the reaper may still be reading the completion ring, leave it mapped
+				log.LogErrorf("action[ioUring.Close] wake up the reaper: %v", err)
+				return
+			}
+			<-r.reaped
+		}
+		r.release()
+	})
+	return
+}
+
+func (r *ioUring) release() {
+	for _, m := range [][]byte{r.sqRing, r.cqRing, r.sqes} {
+		if m != nil {
+			syscall.Munmap(m)
+		}
+	}
+	syscall.Close(r.fd)
+}
+
+func ringUint32(ring []byte, offset uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&ring[offset]))
+}
+
+func (r *ioUring) enter(toSubmit, minComplete, flags uint32) (n int, err error) {
+	for {
+		ret, _, errno := syscall.Syscall6(sysIOUringEnter, uintptr(r.fd), uintptr(toSubmit), uintptr(minComplete), uintptr(flags), 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return 0, errno
+		}
+		return int(ret), nil
+	}
+}
+
+// submit queues an IO and waits for its result.
+func (r *ioUring) submit(opcode uint8, f *os.File, data []byte, offset int64) (res int32, err error) {
+	r.slots <- struct{}{}
+	defer func() {
+		<-r.slots
+	}()
+	req := &ioUringRequest{done: make(chan int32, 1)}
+	if len(data) > 0 {
+		req.iov.Base = &data[0]
+		req.iov.SetLen(len(data))
+	}
+	r.reqLock.Lock()
+	if r.err != nil {
+		err = r.err
+		r.reqLock.Unlock()
+		return
+	}
+	r.reqID++
+	id := r.reqID
+	r.pending[id] = req
+	r.reqLock.Unlock()
+
+	sqe := ioUringSqe{opcode: opcode, fd: int32(f.Fd()), off: uint64(offset), userData: id}
+	if opcode != ioringOpFsync {
+		sqe.addr = uint64(uintptr(unsafe.Pointer(&req.iov)))
+		sqe.len = 1
+	}
+	if err = r.push(sqe); err != nil {
+		r.reqLock.Lock()
+		delete(r.pending, id)
+		r.reqLock.Unlock()
+		return
+	}
+	res = <-req.done
+	runtime.KeepAlive(f)
+	runtime.KeepAlive(data)
+	return
+}
+
+// push queues an entry to the submission ring and submits it to the kernel.
+func (r *ioUring) push(entry ioUringSqe) (err error) {
+	r.sqLock.Lock()
+	defer r.sqLock.Unlock()
+	p := &r.params
+	tail := atomic.LoadUint32(ringUint32(r.sqRing, p.sqOff.tail))
+	index := tail & *ringUint32(r.sqRing, p.sqOff.ringMask)
+	sqe := (*ioUringSqe)(unsafe.Pointer(&r.sqes[index*ioUringSqeSize]))
+	*sqe = entry
+	*ringUint32(r.sqRing, p.sqOff.array+index*4) = index
+	atomic.StoreUint32(ringUint32(r.sqRing, p.sqOff.tail), tail+1)
+	var submitted int
+	if submitted, err = r.enter(1, 0, 0); err == nil && submitted == 0 {
+		err = fmt.Errorf("io_uring: no entry submitted")
+	}
+	if err != nil {
+		// the kernel has not consumed the entry, take it back
+		atomic.StoreUint32(ringUint32(r.sqRing, p.sqOff.tail), tail)
+	}
+	return
+}
+
+func (r *ioUring) inflight() int {
+	r.reqLock.Lock()
+	defer r.reqLock.Unlock()
+	return len(r.pending)
+}
+
+// fail makes the ring refuse the new IO and completes the IO in flight with the error.
+func (r *ioUring) fail(err error) {
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		errno = syscall.ECANCELED
+	}
+	r.reqLock.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	for id, req := range r.pending {
+		delete(r.pending, id)
+		req.done <- -int32(errno)
+	}
+	r.reqLock.Unlock()
+}
+
+// reap dispatches the completions to the requests waiting for them, until the ring fails,
+// or until the IO in flight is completed once the ring is closed, as the kernel may still
+// be transferring their buffers.
+func (r *ioUring) reap() {
+	defer close(r.reaped)
+	var stopping bool
+	p := &r.params
+	headPtr := ringUint32(r.cqRing, p.cqOff.head)
+	tailPtr := ringUint32(r.cqRing, p.cqOff.tail)
+	mask := *ringUint32(r.cqRing, p.cqOff.ringMask)
+	for {
+		if stopping && r.inflight() == 0 {
+			return
+		}
+		head := atomic.LoadUint32(headPtr)
+		if head == atomic.LoadUint32(tailPtr) {
+			_, err := r.enter(0, 1, ioringEnterGetEvents)
+			if err == syscall.EAGAIN || err == syscall.EBUSY {
+				// the kernel is short of resources for the moment
+				time.Sleep(time.Millisecond)
+			} else if err != nil {
+				log.LogErrorf("action[ioUring.reap] wait for the completions: %v", err)
+				r.fail(err)
+				return
+			}
+			continue
+		}
+		cqe := (*ioUringCqe)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes+(head&mask)*ioUringCqeSize]))
+		userData, res := cqe.userData, cqe.res
+		atomic.StoreUint32(headPtr, head+1)
+		if userData == stopReqID {
+			stopping = true
+			continue
+		}
+		r.reqLock.Lock()
+		req := r.pending[userData]
+		delete(r.pending, userData)
+		r.reqLock.Unlock()
+		if req != nil {
+			req.done <- res
+		}
+	}
+}
+
+func (r *ioUring) rw(opcode uint8, f *os.File, data []byte, offset int64) (n int, err error) {
+	res, err := r.submit(opcode, f, data, offset)
+	if err != nil {
+		return
+	}
+	if res < 0 {
+		return 0, syscall.Errno(-res)
+	}
+	return int(res), nil
+}
+
+func (r *ioUring) ReadAt(f *os.File, data []byte, offset int64) (int, error) {
+	return fullIO("read", f, data, offset, func(b []byte, off int64) (int, error) {
+		return r.rw(ioringOpReadv, f, b, off)
+	})
+}
+
+func (r *ioUring) WriteAt(f *os.File, data []byte, offset int64) (int, error) {
+	return fullIO("write", f, data, offset, func(b []byte, off int64) (int, error) {
+		return r.rw(ioringOpWritev, f, b, off)
+	})
+}
+
+func (r *ioUring) Sync(f *os.File) (err error) {
+	if _, err = r.rw(ioringOpFsync, f, nil, 0); err != nil {
+		err = &os.PathError{Op: "sync", Path: f.Name(), Err: err}
+	}
+	return
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// newTestIOUring returns a ring, or skips the test if the kernel does not support io_uring.
+func newTestIOUring(t *testing.T, entries uint32) *ioUring {
+	ring, err := newIOUring(entries)
+	if err != nil {
+		t.Skipf("io_uring is not supported: %v", err)
+	}
+	return ring
+}
+
+func TestIOUringFail(t *testing.T) {
+	cases := []struct {
+		err   error
+		errno syscall.Errno
+	}{
+		{syscall.EIO, syscall.EIO},
+		{syscall.EBADF, syscall.EBADF},
+		{errIOUringClosed, syscall.ECANCELED},
+	}
+	for _, c := range cases {
+		r := &ioUring{pending: make(map[uint64]*ioUringRequest), slots: make(chan struct{}, 4)}
+		reqs := make([]*ioUringRequest, 3)
+		for i := range reqs {
+			reqs[i] = &ioUringRequest{done: make(chan int32, 1)}
+			r.pending[uint64(i+1)] = reqs[i]
+		}
+		r.fail(c.err)
+		for i, req := range reqs {
+			if res := <-req.done; res != -int32(c.errno) {
+				t.Errorf("fail(%v): request %v completed with %v, expected %v", c.err, i, res, -int32(c.errno))
+			}
+		}
+		if r.inflight() != 0 {
+			t.Errorf("fail(%v): %v requests still in flight", c.err, r.inflight())
+		}
+		// the first error is kept, and the new IO is refused
+		r.fail(syscall.EINVAL)
+		if _, err := r.submit(ioringOpNop, os.Stdin, nil, 0); err != c.err {
+			t.Errorf("fail(%v): submit err(%v)", c.err, err)
+		}
+	}
+}
+
+func TestIOUringReadWrite(t *testing.T) {
+	ring := newTestIOUring(t, 8)
+	f, err := os.CreateTemp(t.TempDir(), "uring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	data := bytes.Repeat([]byte("io_uring"), 4096)
+	if n, err := ring.WriteAt(f, data, 4096); err != nil || n != len(data) {
+		t.Fatalf("write: %v bytes err(%v)", n, err)
+	}
+	if err = ring.Sync(f); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	// more requests than the entries of the ring wait for the slots
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf := make([]byte, 1000)
+			offset := int64(i * 1000)
+			if _, err := ring.ReadAt(f, buf, 4096+offset); err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(buf, data[offset:offset+1000]) {
+				errs <- syscall.EIO
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent read: %v", err)
+	}
+
+	// a read beyond the end of the file is short
+	buf := make([]byte, 100)
+	if n, err := ring.ReadAt(f, buf, int64(4096+len(data)-10)); n != 10 || err == nil {
+		t.Errorf("read at the end: %v bytes err(%v)", n, err)
+	}
+	// the errors of the kernel are returned by the requests
+	rf, err := os.Open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	if _, err = ring.WriteAt(rf, data[:10], 0); err == nil {
+		t.Errorf("write a read-only file: expect an error")
+	}
+
+	if err = ring.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err = ring.ReadAt(f, buf, 0); err == nil {
+		t.Errorf("read after close: expect an error")
+	}
+	if err = ring.Close(); err != nil {
+		t.Errorf("close twice: %v", err)
+	}
+}
+
+func TestExtentStoreIOUring(t *testing.T) {
+	if err := SetIOEngine(IOEngineIOUring, 16); err != nil {
+		t.Skipf("io_uring is not supported: %v", err)
+	}
+	defer CloseIOEngine()
+	s := newTestExtentStore(t, t.TempDir())
+	defer s.Close()
+	data := compressibleData(3*1024*1024+7, 5)
+	extentID := writeTestExtent(t, s, data)
+	checkTestExtentData(t, s, extentID, data)
+	checkTestExtent(t, s, extentID, data, 1000, 5000)
+}
@@ -0,0 +1,45 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build !linux
+
+package storage
+
+import (
+	"os"
+	"syscall"
+)
+
+// ioUring is only available on linux.
+type ioUring struct{}
+
+func newIOUring(entries uint32) (*ioUring, error) {
+	return nil, syscall.ENOSYS
+}
+
+func (r *ioUring) ReadAt(f *os.File, data []byte, offset int64) (int, error) {
+	return 0, syscall.ENOSYS
+}
+
+func (r *ioUring) WriteAt(f *os.File, data []byte, offset int64) (int, error) {
+	return 0, syscall.ENOSYS
+}
+
+func (r *ioUring) Sync(f *os.File) error {
+	return syscall.ENOSYS
+}
+
+func (r *ioUring) Close() error {
+	return nil
+}
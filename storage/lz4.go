@@ -0,0 +1,176 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// An implementation of the LZ4 block format, see
+// https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md
+
+const (
+	lz4MinMatch     = 4
+	lz4HashLog      = 16
+	lz4MFLimit      = 12 // the last match must start at least 12 bytes before the end
+	lz4LastLiterals = 5  // the last 5 bytes are always literals
+	lz4MaxOffset    = 65535
+)
+
+var ErrLZ4Corrupt = errors.New("lz4: corrupt input")
+
+// lz4Compress compresses the source by a greedy search of the matches.
+func lz4Compress(src []byte) []byte {
+	n := len(src)
+	dst := make([]byte, 0, n+n/255+16)
+	anchor := 0
+	if n > lz4MFLimit {
+		table := make([]int32, 1<<lz4HashLog) // positions plus one, 0 for none
+		limit := n - lz4MFLimit
+		for i := 0; i < limit; {
+			seq := binary.LittleEndian.Uint32(src[i:])
+			h := (seq * 2654435761) >> (32 - lz4HashLog)
+			ref := int(table[h]) - 1
+			table[h] = int32(i + 1)
+			if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+				i++
+				continue
+			}
+			for i > anchor && ref > 0 && src[i-1] == src[ref-1] {
+				i--
+				ref--
+			}
+			end := i + lz4MinMatch
+			for end < n-lz4LastLiterals && src[end] == src[ref+end-i] {
+				end++
+			}
+			dst = lz4AppendSequence(dst, src[anchor:i], i-ref, end-i)
+			i = end
+			anchor = i
+		}
+	}
+	// the last sequence has only literals
+	litLen := n - anchor
+	if litLen >= 15 {
+		dst = append(dst, 15<<4)
+		dst = lz4AppendLength(dst, litLen-15)
+	} else {
+		dst = append(dst, byte(litLen<<4))
+	}
+	return append(dst, src[anchor:]...)
+}
+
+func lz4AppendSequence(dst, literals []byte, offset, matchLen int) []byte {
+	litLen := len(literals)
+	matchLen -= lz4MinMatch
+	var token byte
+	if litLen >= 15 {
+		token = 15 << 4
+	} else {
+		token = byte(litLen << 4)
+	}
+	if matchLen >= 15 {
+		token |= 15
+	} else {
+		token |= byte(matchLen)
+	}
+	dst = append(dst, token)
+	if litLen >= 15 {
+		dst = lz4AppendLength(dst, litLen-15)
+	}
+	dst = append(dst, literals...)
+	dst = append(dst, byte(offset), byte(offset>>8))
+	if matchLen >= 15 {
+		dst = lz4AppendLength(dst, matchLen-15)
+	}
+	return dst
+}
+
+func lz4AppendLength(dst []byte, n int) []byte {
+	for n >= 255 {
+		dst = append(dst, 255)
+		n -= 255
+	}
+	return append(dst, byte(n))
+}
+
+func lz4ReadLength(src []byte, si int) (n, next int, err error) {
+	for {
+		if si >= len(src) {
+			return 0, si, ErrLZ4Corrupt
+		}
+		b := src[si]
+		si++
+		n += int(b)
+		if b != 255 {
+			return n, si, nil
+		}
+	}
+}
+
+// lz4Decompress decompresses the source into dst and returns the size of the data.
+func lz4Decompress(dst, src []byte) (di int, err error) {
+	si := 0
+	for si < len(src) {
+		token := src[si]
+		si++
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			var n int
+			if n, si, err = lz4ReadLength(src, si); err != nil {
+				return
+			}
+			litLen += n
+		}
+		if si+litLen > len(src) || di+litLen > len(dst) {
+			return di, ErrLZ4Corrupt
+		}
+		copy(dst[di:], src[si:si+litLen])
+		si += litLen
+		di += litLen
+		if si == len(src) {
+			return di, nil
+		}
+		if si+2 > len(src) {
+			return di, ErrLZ4Corrupt
+		}
+		offset := int(src[si]) | int(src[si+1])<<8
+		si += 2
+		matchLen := int(token & 15)
+		if matchLen == 15 {
+			var n int
+			if n, si, err = lz4ReadLength(src, si); err != nil {
+				return
+			}
+			matchLen += n
+		}
+		matchLen += lz4MinMatch
+		if offset == 0 || offset > di || di+matchLen > len(dst) {
+			return di, ErrLZ4Corrupt
+		}
+		start := di - offset
+		if offset >= matchLen {
+			copy(dst[di:di+matchLen], dst[start:start+matchLen])
+		} else {
+			// the match overlaps the data being produced
+			for k := 0; k < matchLen; k++ {
+				dst[di+k] = dst[start+k]
+			}
+		}
+		di += matchLen
+	}
+	return di, ErrLZ4Corrupt
+}
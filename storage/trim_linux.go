@@ -0,0 +1,45 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const ioctlFITRIM = 0xc0185879
+
+// fstrimRange is the struct fstrim_range of linux.
+type fstrimRange struct {
+	start  uint64
+	length uint64
+	minLen uint64
+}
+
+// Trim discards the free blocks of the file system mounted at the path, the free ranges
+// smaller than minLen are skipped. It returns the number of bytes trimmed.
+func Trim(path string, minLen uint64) (trimmed uint64, err error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	r := &fstrimRange{length: ^uint64(0), minLen: minLen}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fp.Fd(), ioctlFITRIM, uintptr(unsafe.Pointer(r))); errno != 0 {
+		return 0, errno
+	}
+	return r.length, nil
+}
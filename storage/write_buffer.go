@@ -0,0 +1,139 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// A WriteBuffer is a durable staging area for the small synchronous writes on a persistent memory
+// device, mounted with a DAX file system so that the synchronization of its files is cheap.
+//
+// The synchronous writes up to the maximum size are appended to a journal on the device and are
+// acknowledged once they land there, instead of once their extent files are synchronized. The extent
+// files written are synchronized in the background, and the journal is replayed into the extent stores
+// when they are loaded after a crash, the same way as the journal of a write-back BlockCache.
+//
+// The encrypted stores do not use the buffer, as it would keep their data in the clear.
+type WriteBuffer struct {
+	path         string
+	maxWriteSize int64
+	journal      *cacheJournal
+	buffered     uint64 // writes acknowledged from the buffer
+	bypassed     uint64 // writes synchronized to the extent files as the buffer is full
+	stopC        chan struct{}
+}
+
+type WriteBufferStats struct {
+	Path         string
+	Capacity     int64
+	MaxWriteSize int64
+	JournalBytes int64
+	Buffered     uint64
+	Bypassed     uint64
+}
+
+// NewWriteBuffer opens the write buffer of the given capacity in the directory on a persistent memory device.
+func NewWriteBuffer(dir string, capacity, maxWriteSize int64) (b *WriteBuffer, err error) {
+	if capacity < cacheJournalSegmentSize {
+		return nil, fmt.Errorf("write buffer capacity(%v) is less than a journal segment(%v)", capacity, cacheJournalSegmentSize)
+	}
+	if maxWriteSize <= 0 || maxWriteSize > cacheJournalSegmentSize-cacheJournalHeaderSize {
+		return nil, fmt.Errorf("invalid write buffer max write size(%v)", maxWriteSize)
+	}
+	b = &WriteBuffer{
+		path:         dir,
+		maxWriteSize: maxWriteSize,
+		stopC:        make(chan struct{}),
+	}
+	if b.journal, err = openCacheJournal(dir); err != nil {
+		return
+	}
+	b.journal.maxSize = capacity
+	go b.flush()
+	log.LogInfof("action[NewWriteBuffer] path(%v) capacity(%v) maxWriteSize(%v)", dir, capacity, maxWriteSize)
+	return
+}
+
+func (b *WriteBuffer) Stats() *WriteBufferStats {
+	return &WriteBufferStats{
+		Path:         b.path,
+		Capacity:     b.journal.maxSize,
+		MaxWriteSize: b.maxWriteSize,
+		JournalBytes: b.journal.getSize(),
+		Buffered:     atomic.LoadUint64(&b.buffered),
+		Bypassed:     atomic.LoadUint64(&b.bypassed),
+	}
+}
+
+// Close synchronizes the extent files written and empties the journal.
+func (b *WriteBuffer) Close() {
+	close(b.stopC)
+	b.journal.flush()
+}
+
+func (b *WriteBuffer) flush() {
+	ticker := time.NewTicker(cacheFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopC:
+			return
+		case <-ticker.C:
+			b.journal.flush()
+		}
+	}
+}
+
+// Replay applies the buffered writes of the store which may not have reached its disk.
+func (b *WriteBuffer) Replay(s *ExtentStore) {
+	b.journal.replay(s)
+}
+
+// FinishReplay removes the journal replayed once all the extent stores are loaded.
+func (b *WriteBuffer) FinishReplay() {
+	b.journal.finishReplay()
+}
+
+// SetWriteBuffer makes the store acknowledge its small synchronous writes from the buffer.
+func (s *ExtentStore) SetWriteBuffer(b *WriteBuffer) {
+	s.writeBuffer = b
+}
+
+// usesWriteBuffer returns false for the extents journaled by a write-back block cache,
+// so that the writes of an extent are replayed from a single journal in order.
+func (s *ExtentStore) usesWriteBuffer(extentID uint64, size int64) bool {
+	if s.writeBuffer == nil || size > s.writeBuffer.maxWriteSize || s.IsEncrypted() {
+		return false
+	}
+	return !s.usesBlockCache(extentID) || s.blockCache.mode != CacheModeWriteBack
+}
+
+// bufferWrite appends a synchronous write to the write buffer, it returns false if the write has to be
+// synchronized to the extent file. Otherwise done has to be called once the write is applied to the extent file.
+func (s *ExtentStore) bufferWrite(extentID uint64, offset, size int64, data []byte, crc uint32, writeType int) (done func(), ok bool) {
+	b := s.writeBuffer
+	if err := b.journal.append(s, extentID, offset, size, data, crc, writeType); err != nil {
+		atomic.AddUint64(&b.bypassed, 1)
+		log.LogDebugf("action[bufferWrite] path(%v) extent(%v) err(%v)", b.path, s.getExtentKey(extentID), err)
+		return
+	}
+	atomic.AddUint64(&b.buffered, 1)
+	return b.journal.inflight.RUnlock, true
+}
@@ -0,0 +1,296 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package audit delivers the audit records of the modules, such as the admin requests of the master, the
+// operations of the meta nodes and the S3 requests of the ObjectNodes, to the sinks configured by the
+// "auditTargets" configuration. A record is a JSON object, which is delivered asynchronously by a queue per
+// sink in batches, so that the audited requests are not blocked by the sinks. If the queue of a sink is full,
+// the record waits for the queue at most "blockTimeout" milliseconds, and is dropped after it. The records
+// failed to be delivered after the retries are dropped as well. The dropped records are counted by the
+// "audit_dropped" metric with the labels of the sink and the reason.
+//
+// The sinks of the files, the HTTP webhooks and the topics of Kafka are built in, and the other sinks may be
+// plugged in by RegisterSink.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	SinkFile    = "file"
+	SinkWebhook = "webhook"
+	SinkKafka   = "kafka"
+
+	DefaultQueueSize     = 10000
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+
+	maxRetries = 3
+
+	deliveredCounter = "audit_delivered"
+	droppedCounter   = "audit_dropped"
+
+	dropReasonQueueFull = "queueFull"
+	dropReasonFailed    = "failed"
+)
+
+// SinkConfig is the configuration of a sink, the item of the "auditTargets" configuration.
+type SinkConfig struct {
+	Type         string   `json:"type"`
+	Path         string   `json:"path,omitempty"`         // file
+	MaxSize      int64    `json:"maxSize,omitempty"`      // file, rotated to path.old beyond the bytes, 0 is unlimited
+	Endpoint     string   `json:"endpoint,omitempty"`     // webhook
+	AuthToken    string   `json:"authToken,omitempty"`    // webhook
+	Brokers      []string `json:"brokers,omitempty"`      // kafka
+	Topic        string   `json:"topic,omitempty"`        // kafka
+	QueueSize    int      `json:"queueSize,omitempty"`    // records queued at most, 0 uses the default
+	BlockTimeout int64    `json:"blockTimeout,omitempty"` // milliseconds waiting for the full queue, 0 drops at once
+}
+
+// ParseSinkConfigs parses the items of the "auditTargets" configuration.
+func ParseSinkConfigs(items []interface{}) (configs []*SinkConfig, err error) {
+	if len(items) == 0 {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(items); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid audit targets: %v", err)
+	}
+	return
+}
+
+// Record is a record to be delivered, the key chooses the partition of Kafka.
+type Record struct {
+	Key  string
+	Data []byte
+}
+
+// Sink delivers the batches of the records. Write is called by a goroutine per sink, and the failed
+// batch is written again.
+type Sink interface {
+	Write(records []*Record) error
+	Close()
+}
+
+// SinkFactory returns the sink of the configuration for the module, such as "master".
+type SinkFactory func(module string, config *SinkConfig) (Sink, error)
+
+var (
+	sinkFactoriesMu sync.RWMutex
+	sinkFactories   = map[string]SinkFactory{
+		SinkFile:    newFileSink,
+		SinkWebhook: newWebhookSink,
+		SinkKafka:   newKafkaSink,
+	}
+)
+
+// RegisterSink registers the factory of the sinks of the type, which replaces the factory registered before.
+func RegisterSink(sinkType string, factory SinkFactory) {
+	sinkFactoriesMu.Lock()
+	defer sinkFactoriesMu.Unlock()
+	sinkFactories[sinkType] = factory
+}
+
+func getSinkFactory(sinkType string) SinkFactory {
+	sinkFactoriesMu.RLock()
+	defer sinkFactoriesMu.RUnlock()
+	return sinkFactories[sinkType]
+}
+
+type queue struct {
+	name         string
+	sink         Sink
+	records      chan *Record
+	blockTimeout time.Duration
+}
+
+// Logger delivers the audit records of a module to the sinks.
+type Logger struct {
+	module    string
+	queues    []*queue
+	delivered uint64
+	dropped   uint64
+	stopC     chan struct{}
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewLogger returns the started logger of the sinks, which is nil if no sink is configured.
+func NewLogger(module string, configs []*SinkConfig) (l *Logger, err error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	var queues []*queue
+	defer func() {
+		if err != nil {
+			for _, q := range queues {
+				q.sink.Close()
+			}
+		}
+	}()
+	for i, config := range configs {
+		var factory = getSinkFactory(config.Type)
+		if factory == nil {
+			return nil, fmt.Errorf("invalid type of audit target %v: %v", i, config.Type)
+		}
+		var sink Sink
+		if sink, err = factory(module, config); err != nil {
+			return nil, fmt.Errorf("audit target %v: %v", i, err)
+		}
+		var size = config.QueueSize
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+		queues = append(queues, &queue{
+			name:         fmt.Sprintf("%v-%v", config.Type, i),
+			sink:         sink,
+			records:      make(chan *Record, size),
+			blockTimeout: time.Duration(config.BlockTimeout) * time.Millisecond,
+		})
+	}
+	l = &Logger{module: module, queues: queues, stopC: make(chan struct{})}
+	for _, q := range l.queues {
+		l.wg.Add(1)
+		go l.run(q)
+	}
+	return
+}
+
+// Stop delivers the records queued, and closes the sinks.
+func (l *Logger) Stop() {
+	if l == nil {
+		return
+	}
+	l.stopOnce.Do(func() {
+		close(l.stopC)
+		l.wg.Wait()
+		for _, q := range l.queues {
+			q.sink.Close()
+		}
+	})
+}
+
+// Log queues the record in JSON to the sinks, nothing is done if the logger is nil.
+func (l *Logger) Log(key string, rec interface{}) {
+	if l == nil {
+		return
+	}
+	var data, err = json.Marshal(rec)
+	if err != nil {
+		log.LogErrorf("audit: marshal record fail: module(%v) err(%v)", l.module, err)
+		return
+	}
+	var record = &Record{Key: key, Data: data}
+	for _, q := range l.queues {
+		select {
+		case q.records <- record:
+			continue
+		default:
+		}
+		if q.blockTimeout > 0 {
+			var timer = time.NewTimer(q.blockTimeout)
+			select {
+			case q.records <- record:
+				timer.Stop()
+				continue
+			case <-timer.C:
+			}
+		}
+		l.drop(q, 1, dropReasonQueueFull)
+	}
+}
+
+// Stats returns the records delivered and dropped of all the sinks.
+func (l *Logger) Stats() (delivered, dropped uint64) {
+	if l == nil {
+		return
+	}
+	return atomic.LoadUint64(&l.delivered), atomic.LoadUint64(&l.dropped)
+}
+
+func (l *Logger) drop(q *queue, n int, reason string) {
+	var dropped = atomic.AddUint64(&l.dropped, uint64(n))
+	exporter.NewCounter(droppedCounter).AddWithLabels(int64(n), map[string]string{"sink": q.name, "reason": reason})
+	log.LogWarnf("audit: records are dropped: module(%v) sink(%v) reason(%v) count(%v) dropped(%v)",
+		l.module, q.name, reason, n, dropped)
+}
+
+func (l *Logger) run(q *queue) {
+	defer l.wg.Done()
+	var ticker = time.NewTicker(DefaultFlushInterval)
+	defer ticker.Stop()
+	var batch = make([]*Record, 0, DefaultBatchSize)
+	for {
+		select {
+		case <-l.stopC:
+			for {
+				select {
+				case record := <-q.records:
+					if batch = append(batch, record); len(batch) >= DefaultBatchSize {
+						l.write(q, batch, false)
+						batch = batch[:0]
+					}
+				default:
+					l.write(q, batch, false)
+					return
+				}
+			}
+		case record := <-q.records:
+			if batch = append(batch, record); len(batch) < DefaultBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		l.write(q, batch, true)
+		batch = batch[:0]
+	}
+}
+
+// write writes the batch to the sink, which is retried if retry is true.
+func (l *Logger) write(q *queue, batch []*Record, retry bool) {
+	if len(batch) == 0 {
+		return
+	}
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		if i > 0 {
+			if !retry {
+				break
+			}
+			select {
+			case <-l.stopC:
+				retry = false
+			case <-time.After(time.Duration(i) * time.Second):
+			}
+		}
+		if err = q.sink.Write(batch); err == nil {
+			atomic.AddUint64(&l.delivered, uint64(len(batch)))
+			exporter.NewCounter(deliveredCounter).AddWithLabels(int64(len(batch)), map[string]string{"sink": q.name})
+			return
+		}
+		log.LogWarnf("audit: write records fail: module(%v) sink(%v) retry(%v) err(%v)", l.module, q.name, i, err)
+	}
+	l.drop(q, len(batch), dropReasonFailed)
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	Op string `json:"op"`
+}
+
+func TestParseSinkConfigs(t *testing.T) {
+	var items = []interface{}{
+		map[string]interface{}{"type": "webhook", "endpoint": "http://127.0.0.1:17010/audit", "blockTimeout": float64(100)},
+		map[string]interface{}{"type": "kafka", "brokers": []interface{}{"127.0.0.1:9092"}, "topic": "audit"},
+	}
+	configs, err := ParseSinkConfigs(items)
+	if err != nil || len(configs) != 2 || configs[0].BlockTimeout != 100 || configs[1].Topic != "audit" {
+		t.Fatalf("parse sink configs mismatch: configs(%v) err(%v)", configs, err)
+	}
+	l, err := NewLogger("test", configs)
+	if err != nil {
+		t.Fatalf("new logger fail: err(%v)", err)
+	}
+	l.Stop()
+	for i, config := range []*SinkConfig{{Type: SinkFile}, {Type: SinkWebhook}, {Type: SinkKafka, Topic: "audit"}, {Type: "syslog"}} {
+		if _, err = NewLogger("test", []*SinkConfig{config}); err == nil {
+			t.Fatalf("case %v: expect invalid sink error", i)
+		}
+	}
+	if l, err = NewLogger("test", nil); l != nil || err != nil {
+		t.Fatalf("logger without sinks should be nil: err(%v)", err)
+	}
+	// the nil logger logs nothing
+	l.Log("", &testRecord{Op: "nil"})
+	l.Stop()
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("create temp dir fail: err(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	var file = path.Join(dir, "audit.log")
+	l, err := NewLogger("test", []*SinkConfig{{Type: SinkFile, Path: file, MaxSize: 30}})
+	if err != nil {
+		t.Fatalf("new logger fail: err(%v)", err)
+	}
+	l.Log("", &testRecord{Op: "create"})
+	time.Sleep(2 * DefaultFlushInterval)
+	l.Log("", &testRecord{Op: "delete"})
+	// the records queued are delivered when the logger stops
+	l.Stop()
+	if data, _ := ioutil.ReadFile(file + ".old"); string(data) != `{"op":"create"}`+"\n" {
+		t.Fatalf("unexpected rotated file: %s", data)
+	}
+	if data, _ := ioutil.ReadFile(file); string(data) != `{"op":"delete"}`+"\n" {
+		t.Fatalf("unexpected file: %s", data)
+	}
+	if delivered, dropped := l.Stats(); delivered != 2 || dropped != 0 {
+		t.Fatalf("unexpected stats: delivered(%v) dropped(%v)", delivered, dropped)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var mu sync.Mutex
+	var received [][]byte
+	var failures = 1
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// the failed batch is retried
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	l, err := NewLogger("test", []*SinkConfig{{Type: SinkWebhook, Endpoint: server.URL, AuthToken: "token"}})
+	if err != nil {
+		t.Fatalf("new logger fail: err(%v)", err)
+	}
+	defer l.Stop()
+	l.Log("", &testRecord{Op: "create"})
+	l.Log("", &testRecord{Op: "delete"})
+	for i := 0; i < 100; i++ {
+		if delivered, _ := l.Stats(); delivered == 2 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || !bytes.Equal(received[0], []byte(`{"op":"create"}`+"\n"+`{"op":"delete"}`+"\n")) {
+		t.Fatalf("unexpected batches: %q", received)
+	}
+}
+
+type blockingSink struct {
+	blockC chan struct{}
+}
+
+func (s *blockingSink) Write(records []*Record) error {
+	<-s.blockC
+	return nil
+}
+
+func (s *blockingSink) Close() {}
+
+func TestQueueFull(t *testing.T) {
+	var sink = &blockingSink{blockC: make(chan struct{})}
+	RegisterSink("blocking", func(module string, config *SinkConfig) (Sink, error) {
+		return sink, nil
+	})
+	l, err := NewLogger("test", []*SinkConfig{{Type: "blocking", QueueSize: 1, BlockTimeout: 10}})
+	if err != nil {
+		t.Fatalf("new logger fail: err(%v)", err)
+	}
+	// the first record is taken by the blocked sink after the flush interval, and the second one fills the queue
+	l.Log("", &testRecord{Op: "1"})
+	time.Sleep(2 * DefaultFlushInterval)
+	l.Log("", &testRecord{Op: "2"})
+	var start = time.Now()
+	l.Log("", &testRecord{Op: "3"})
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatalf("record should wait for the full queue")
+	}
+	if _, dropped := l.Stats(); dropped != 1 {
+		t.Fatalf("expect 1 record dropped but %v", dropped)
+	}
+	close(sink.blockC)
+	l.Stop()
+	if delivered, _ := l.Stats(); delivered != 2 {
+		t.Fatalf("expect 2 records delivered but %v", delivered)
+	}
+}
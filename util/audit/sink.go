@@ -0,0 +1,180 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cubefs/cubefs/util/kafka"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const sinkTimeout = 10 * time.Second
+
+// fileSink appends the records to the file, a line per record.
+type fileSink struct {
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+func newFileSink(module string, config *SinkConfig) (Sink, error) {
+	if config.Path == "" {
+		return nil, errors.New("no path")
+	}
+	var s = &fileSink{path: config.Path, maxSize: config.MaxSize}
+	if err := s.open(); err != nil {
+		return nil, fmt.Errorf("open audit file %v: %v", config.Path, err)
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() (err error) {
+	if s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640); err != nil {
+		return
+	}
+	var info os.FileInfo
+	if info, err = s.file.Stat(); err != nil {
+		_ = s.file.Close()
+		s.file = nil
+		return
+	}
+	s.size = info.Size()
+	return
+}
+
+func (s *fileSink) Write(records []*Record) (err error) {
+	if s.file == nil {
+		if err = s.open(); err != nil {
+			return
+		}
+	}
+	var buf bytes.Buffer
+	for _, record := range records {
+		buf.Write(record.Data)
+		buf.WriteByte('\n')
+	}
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(buf.Len()) > s.maxSize {
+		s.rotate()
+		if s.file == nil {
+			return fmt.Errorf("reopen audit file %v fail", s.path)
+		}
+	}
+	var n int
+	n, err = s.file.Write(buf.Bytes())
+	s.size += int64(n)
+	if err != nil && n > 0 {
+		// the records partly written are not written again
+		log.LogWarnf("audit: write file fail: path(%v) written(%v) err(%v)", s.path, n, err)
+		return nil
+	}
+	return
+}
+
+func (s *fileSink) rotate() {
+	_ = s.file.Close()
+	s.file = nil
+	if err := os.Rename(s.path, s.path+".old"); err != nil {
+		log.LogWarnf("audit: rotate file fail: path(%v) err(%v)", s.path, err)
+	}
+	if err := s.open(); err != nil {
+		log.LogErrorf("audit: reopen file fail: path(%v) err(%v)", s.path, err)
+	}
+}
+
+func (s *fileSink) Close() {
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+}
+
+// webhookSink posts the batches of the records to the HTTP endpoint in JSON lines, which should respond 2xx.
+type webhookSink struct {
+	endpoint  string
+	authToken string
+	client    *http.Client
+}
+
+func newWebhookSink(module string, config *SinkConfig) (Sink, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("no endpoint")
+	}
+	return &webhookSink{
+		endpoint:  config.Endpoint,
+		authToken: config.AuthToken,
+		client:    &http.Client{Timeout: sinkTimeout},
+	}, nil
+}
+
+func (s *webhookSink) Write(records []*Record) (err error) {
+	var buf bytes.Buffer
+	for _, record := range records {
+		buf.Write(record.Data)
+		buf.WriteByte('\n')
+	}
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, s.endpoint, &buf); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.authToken)
+	}
+	var resp *http.Response
+	if resp, err = s.client.Do(req); err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %v", resp.StatusCode)
+	}
+	return
+}
+
+func (s *webhookSink) Close() {
+	s.client.CloseIdleConnections()
+}
+
+// kafkaSink publishes the records to the topic of Kafka, a message per record. The records of a batch
+// produced before the failure are produced again if the batch is retried.
+type kafkaSink struct {
+	producer *kafka.Producer
+}
+
+func newKafkaSink(module string, config *SinkConfig) (Sink, error) {
+	if len(config.Brokers) == 0 || config.Topic == "" {
+		return nil, errors.New("no brokers or topic")
+	}
+	return &kafkaSink{producer: kafka.NewProducer(config.Brokers, config.Topic, "cfs-"+module, sinkTimeout)}, nil
+}
+
+func (s *kafkaSink) Write(records []*Record) (err error) {
+	for _, record := range records {
+		if err = s.producer.Produce(record.Key, record.Data); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *kafkaSink) Close() {
+	s.producer.Close()
+}
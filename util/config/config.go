@@ -21,6 +21,7 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 )
 
@@ -40,7 +41,8 @@ func newConfig() *Config {
 	return result
 }
 
-// LoadConfigFile loads config information from a JSON file.
+// LoadConfigFile loads config information from a JSON, YAML or TOML file, whose format is told by its extension.
+// The secret references in the values are resolved, with the relative paths of the files relative to the config file.
 func LoadConfigFile(filename string) (*Config, error) {
 	result := newConfig()
 	err := result.parse(filename)
@@ -60,11 +62,29 @@ func LoadConfigString(s string) *Config {
 	return result
 }
 
+// LoadConfigData loads config information from the data in the format, and resolves the secret references in the values.
+func LoadConfigData(data []byte, format string) (*Config, error) {
+	result := newConfig()
+	result.Raw = data
+	var err error
+	if result.data, err = decode(data, format); err != nil {
+		return nil, err
+	}
+	if err = resolveSecrets(result.data, ""); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (c *Config) parse(fileName string) error {
-	jsonFileBytes, err := ioutil.ReadFile(fileName)
-	c.Raw = jsonFileBytes
+	fileBytes, err := ioutil.ReadFile(fileName)
+	c.Raw = fileBytes
 	if err == nil {
-		err = json.Unmarshal(jsonFileBytes, &c.data)
+		var data map[string]interface{}
+		if data, err = decode(fileBytes, FormatOf(fileName)); err == nil && data != nil {
+			c.data = data
+			err = resolveSecrets(c.data, filepath.Dir(fileName))
+		}
 	}
 	return err
 }
@@ -119,16 +139,9 @@ func (c *Config) GetBool(key string) bool {
 	return false
 }
 
-// GetBool returns a int value for the config key.
+// GetInt returns a int value for the config key.
 func (c *Config) GetInt(key string) int64 {
-	x, present := c.data[key]
-	if !present {
-		return 0
-	}
-	if result, isInt := x.(int64); isInt {
-		return result
-	}
-	return 0
+	return c.GetInt64(key)
 }
 
 // GetBool returns a int64 value for the config key.
@@ -182,6 +195,23 @@ func (c *Config) CheckAndGetString(key string) (string, bool) {
 	return "", false
 }
 
+// CheckAndGetInt64 checks and gets a int64 value for the config key, which is a number or a string of an integer.
+func (c *Config) CheckAndGetInt64(key string) (int64, bool) {
+	x, present := c.data[key]
+	if !present {
+		return 0, false
+	}
+	if result, isFloat := x.(float64); isFloat {
+		return int64(result), true
+	}
+	if result, isString := x.(string); isString {
+		if r, err := strconv.ParseInt(result, 10, 64); err == nil {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
 // GetBool returns a bool value for the config key.
 func (c *Config) CheckAndGetBool(key string) (bool, bool) {
 	x, present := c.data[key]
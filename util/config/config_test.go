@@ -0,0 +1,191 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSchema = Schema{
+	"role":       TypeString,
+	"listen":     TypeInt,
+	"masterAddr": TypeStringSlice,
+	"enable":     TypeBool,
+	"ratio":      TypeFloat,
+	"exports":    TypeSlice,
+}
+
+func checkConfig(t *testing.T, cfg *Config) {
+	if cfg.GetString("role") != "master" || cfg.GetInt("listen") != 17010 || !cfg.GetBool("enable") ||
+		cfg.GetFloat("ratio") != 0.5 {
+		t.Fatalf("unexpected config: %v", cfg.data)
+	}
+	if addrs := cfg.GetStringSlice("masterAddr"); !reflect.DeepEqual(addrs, []string{"127.0.0.1:17010", "127.0.0.2:17010"}) {
+		t.Fatalf("unexpected masterAddr: %v", addrs)
+	}
+	exports := cfg.GetSlice("exports")
+	if len(exports) != 2 || exports[1].(map[string]interface{})["path"] != "/b" {
+		t.Fatalf("unexpected exports: %v", exports)
+	}
+	if err := cfg.Validate(testSchema); err != nil {
+		t.Fatalf("validate config fail: err(%v)", err)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"master.json": `{
+  "role": "master",
+  "listen": "17010",
+  "masterAddr": ["127.0.0.1:17010", "127.0.0.2:17010"],
+  "enable": "true",
+  "ratio": 0.5,
+  "exports": [{"path": "/a"}, {"path": "/b"}]
+}`,
+		"master.yaml": `
+role: master
+listen: 17010
+masterAddr:
+  - 127.0.0.1:17010
+  - 127.0.0.2:17010
+enable: true
+ratio: 0.5
+exports:
+  - path: /a
+  - path: /b
+`,
+		"master.toml": `
+# the master
+role = "master"
+listen = 17_010
+masterAddr = [
+  "127.0.0.1:17010",
+  '127.0.0.2:17010', # trailing comma
+]
+enable = true
+ratio = 0.5
+
+[[exports]]
+path = "/a"
+
+[[exports]]
+path = '/b'
+`,
+	}
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range files {
+		fileName := path.Join(dir, name)
+		if err = ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfigFile(fileName)
+		if err != nil {
+			t.Fatalf("load %v fail: err(%v)", name, err)
+		}
+		checkConfig(t, cfg)
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	cfg, err := LoadConfigData([]byte(`
+a.b = "x\ty\u00e9"
+c = """
+line1 \
+  line2"""
+d = { e = 1, f = [1.5, -2] }
+g = 0x10
+h = 1979-05-27T07:32:00Z
+"i j" = 'C:\path'
+`), FormatTOML)
+	if err != nil {
+		t.Fatalf("parse toml fail: err(%v)", err)
+	}
+	expected := map[string]interface{}{
+		"a":   map[string]interface{}{"b": "x\tyé"},
+		"c":   "line1 line2",
+		"d":   map[string]interface{}{"e": float64(1), "f": []interface{}{1.5, float64(-2)}},
+		"g":   float64(16),
+		"h":   "1979-05-27T07:32:00Z",
+		"i j": `C:\path`,
+	}
+	if !reflect.DeepEqual(cfg.data, expected) {
+		t.Fatalf("unexpected toml: %v", cfg.data)
+	}
+	for _, s := range []string{`a = `, `a = "x`, `a = 1 b = 2`, "a = 1\na = 2", `[a`, `a = [1, 2`} {
+		if _, err = LoadConfigData([]byte(s), FormatTOML); err == nil {
+			t.Fatalf("toml [%v] should be invalid", s)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := LoadConfigString(`{"role": "master", "listen": 1.5, "enable": "yes", "masterAddr": [1], "lsiten": 17010}`)
+	err := cfg.Validate(testSchema)
+	if err == nil {
+		t.Fatalf("config should be invalid")
+	}
+	for _, problem := range []string{
+		`unknown key "lsiten"`,
+		`key "listen" expects integer but got number 1.5`,
+		`key "enable" expects bool but got string "yes"`,
+		`key "masterAddr" expects list of strings but got list`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("error [%v] should contain [%v]", err, problem)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(path.Join(dir, "secret_key"), []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CFS_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("CFS_TEST_SECRET")
+	fileName := path.Join(dir, "client.json")
+	content := `{"accessKey": "env://CFS_TEST_SECRET", "secretKey": "file://secret_key", ` +
+		`"remotes": [{"secretKey": "file://` + path.Join(dir, "secret_key") + `"}], "masterAddr": "127.0.0.1:17010"}`
+	if err = ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfigFile(fileName)
+	if err != nil {
+		t.Fatalf("load config fail: err(%v)", err)
+	}
+	if cfg.GetString("accessKey") != "env-secret" || cfg.GetString("secretKey") != "file-secret" ||
+		cfg.GetSlice("remotes")[0].(map[string]interface{})["secretKey"] != "file-secret" ||
+		cfg.GetString("masterAddr") != "127.0.0.1:17010" {
+		t.Fatalf("unexpected config: %v", cfg.data)
+	}
+	for _, s := range []string{`{"accessKey": "env://CFS_TEST_NOT_SET"}`, `{"secretKey": "file:///not/exist"}`} {
+		if _, err = LoadConfigData([]byte(s), FormatJSON); err == nil {
+			t.Fatalf("config [%v] should be refused", s)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// the formats of the config files
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FormatOf returns the format of the config file by its extension, which is FormatJSON by default.
+func FormatOf(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// decode decodes the config in the format into the values of the types decoded from JSON, which are
+// float64 for the numbers, []interface{} for the lists and map[string]interface{} for the objects, so
+// that the getters behave the same whatever the format is.
+func decode(data []byte, format string) (result map[string]interface{}, err error) {
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &result)
+		return
+	case FormatYAML:
+		var value interface{}
+		if err = yaml.Unmarshal(data, &value); err != nil {
+			return
+		}
+		if value == nil {
+			return make(map[string]interface{}), nil
+		}
+		if value, err = normalize(value); err != nil {
+			return
+		}
+		var ok bool
+		if result, ok = value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("yaml: the config is not a mapping")
+		}
+		return
+	case FormatTOML:
+		if result, err = parseTOML(string(data)); err != nil {
+			return
+		}
+		var value interface{}
+		if value, err = normalize(result); err != nil {
+			return
+		}
+		return value.(map[string]interface{}), nil
+	default:
+		return nil, fmt.Errorf("unknown config format %v", format)
+	}
+}
+
+func normalize(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", key)
+			}
+			var err error
+			if result[name], err = normalize(item); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		for key, item := range v {
+			var err error
+			if v[key], err = normalize(item); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			var err error
+			if v[i], err = normalize(item); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the type of the value of a config key.
+type ValueType int
+
+const (
+	TypeString      ValueType = iota // a string
+	TypeBool                         // a bool, or the string "true" or "false"
+	TypeInt                          // an integer, or a string of an integer
+	TypeFloat                        // a number
+	TypeStringSlice                  // a list of strings
+	TypeSlice                        // a list of any values, such as the objects
+	TypeAny                          // any value
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeBool:
+		return "bool"
+	case TypeInt:
+		return "integer"
+	case TypeFloat:
+		return "number"
+	case TypeStringSlice:
+		return "list of strings"
+	case TypeSlice:
+		return "list"
+	default:
+		return "any"
+	}
+}
+
+// match returns if the value parsed from the config file is of the type.
+func (t ValueType) match(value interface{}) bool {
+	switch t {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeBool:
+		switch v := value.(type) {
+		case bool:
+			return true
+		case string:
+			return v == "true" || v == "false"
+		}
+		return false
+	case TypeInt:
+		switch v := value.(type) {
+		case float64:
+			return v == math.Trunc(v)
+		case string:
+			_, err := strconv.ParseInt(v, 10, 64)
+			return err == nil
+		}
+		return false
+	case TypeFloat:
+		_, ok := value.(float64)
+		return ok
+	case TypeStringSlice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range items {
+			if _, ok = item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	case TypeSlice:
+		_, ok := value.([]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// Schema declares the keys of the config of a role and the types of their values.
+type Schema map[string]ValueType
+
+// MergeSchemas returns the schema of the keys of all the schemas.
+func MergeSchemas(schemas ...Schema) Schema {
+	result := make(Schema)
+	for _, schema := range schemas {
+		for key, t := range schema {
+			result[key] = t
+		}
+	}
+	return result
+}
+
+// Validate checks the config against the schema, so that the unknown keys and the values of the wrong types
+// are refused at the startup rather than ignored silently.
+func (c *Config) Validate(schema Schema) error {
+	var problems []string
+	for key, value := range c.data {
+		t, known := schema[key]
+		if !known {
+			problems = append(problems, fmt.Sprintf("unknown key %q", key))
+			continue
+		}
+		if !t.match(value) {
+			problems = append(problems, fmt.Sprintf("key %q expects %v but got %v", key, t, describe(value)))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+}
+
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("bool %v", v)
+	case float64:
+		return fmt.Sprintf("number %v", v)
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the prefixes of the secret references, such as "env://CFS_MASTER_SERVICE_KEY" for the environment
+// variable and "file:///etc/cfs/secret_key" for the file
+const (
+	SecretEnvPrefix  = "env://"
+	SecretFilePrefix = "file://"
+)
+
+// resolveSecrets replaces the secret references in the values of the config by the secrets they refer to,
+// so that the secrets are kept out of the config files. The relative paths of the files are relative to dir.
+func resolveSecrets(data map[string]interface{}, dir string) (err error) {
+	for key, value := range data {
+		if data[key], err = resolveSecret(key, value, dir); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func resolveSecret(key string, value interface{}, dir string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return resolveSecretString(key, v, dir)
+	case []interface{}:
+		for i, item := range v {
+			var err error
+			if v[i], err = resolveSecret(key, item, dir); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[string]interface{}:
+		for name, item := range v {
+			var err error
+			if v[name], err = resolveSecret(key, item, dir); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+func resolveSecretString(key, value, dir string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SecretEnvPrefix):
+		name := strings.TrimPrefix(value, SecretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("key %q refers to the environment variable %v which is not set", key, name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, SecretFilePrefix):
+		fileName := strings.TrimPrefix(value, SecretFilePrefix)
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(dir, fileName)
+		}
+		secret, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return "", fmt.Errorf("key %q refers to the file which is not read: %v", key, err)
+		}
+		// the line break at the end of the file is not a part of the secret
+		return strings.TrimRight(string(secret), "\r\n"), nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,429 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlParser parses the TOML documents of the config files, which are the key/value pairs, the tables,
+// the arrays of tables, the inline tables and the arrays of the strings, the integers, the floats and the
+// booleans. The dates and times are kept as the strings.
+type tomlParser struct {
+	s    string
+	pos  int
+	root map[string]interface{}
+}
+
+func parseTOML(s string) (map[string]interface{}, error) {
+	p := &tomlParser{s: s, root: make(map[string]interface{})}
+	current := p.root
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return p.root, nil
+		}
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseTable()
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' && !strings.HasPrefix(p.s[p.pos:], "\r\n") {
+			return nil, p.errorf("expected the end of the line but got %q", p.peek())
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, v ...interface{}) error {
+	line := strings.Count(p.s[:p.pos], "\n") + 1
+	return fmt.Errorf("toml: line %d: %s", line, fmt.Sprintf(format, v...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *tomlParser) peek() byte {
+	return p.s[p.pos]
+}
+
+// skipBlank skips the spaces and the comments, and the line breaks as well if multiline is true.
+func (p *tomlParser) skipBlank(multiline bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case multiline && (c == '\n' || c == '\r'):
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) expect(token string) error {
+	if !strings.HasPrefix(p.s[p.pos:], token) {
+		if p.eof() {
+			return p.errorf("expected %q but got the end of the file", token)
+		}
+		return p.errorf("expected %q but got %q", token, p.peek())
+	}
+	p.pos += len(token)
+	return nil
+}
+
+func (p *tomlParser) parseTable() (table map[string]interface{}, err error) {
+	isArray := strings.HasPrefix(p.s[p.pos:], "[[")
+	if isArray {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	var keys []string
+	if keys, err = p.parseKey(); err != nil {
+		return
+	}
+	table = p.root
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = p.descend(table, key); err != nil {
+			return
+		}
+	}
+	last := keys[len(keys)-1]
+	if !isArray {
+		if err = p.expect("]"); err != nil {
+			return
+		}
+		return p.descend(table, last)
+	}
+	if err = p.expect("]]"); err != nil {
+		return
+	}
+	var array []interface{}
+	if value, present := table[last]; present {
+		var ok bool
+		if array, ok = value.([]interface{}); !ok {
+			return nil, p.errorf("key %q is not an array of tables", last)
+		}
+	}
+	element := make(map[string]interface{})
+	table[last] = append(array, element)
+	return element, nil
+}
+
+// descend returns the table of the key in the table, which is created if not present, or the last
+// table if it is an array of tables.
+func (p *tomlParser) descend(table map[string]interface{}, key string) (map[string]interface{}, error) {
+	value, present := table[key]
+	if !present {
+		child := make(map[string]interface{})
+		table[key] = child
+		return child, nil
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		if len(v) > 0 {
+			if child, ok := v[len(v)-1].(map[string]interface{}); ok {
+				return child, nil
+			}
+		}
+	}
+	return nil, p.errorf("key %q is not a table", key)
+}
+
+func (p *tomlParser) parseKey() (keys []string, err error) {
+	for {
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("expected a key but got the end of the file")
+		}
+		var key string
+		switch p.peek() {
+		case '"':
+			if key, err = p.parseBasicString(); err != nil {
+				return
+			}
+		case '\'':
+			if key, err = p.parseLiteralString(); err != nil {
+				return
+			}
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key but got %q", p.peek())
+			}
+			key = p.s[start:p.pos]
+		}
+		keys = append(keys, key)
+		p.skipBlank(false)
+		if p.eof() || p.peek() != '.' {
+			return
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseKeyValue(table map[string]interface{}) (err error) {
+	var keys []string
+	if keys, err = p.parseKey(); err != nil {
+		return
+	}
+	if err = p.expect("="); err != nil {
+		return
+	}
+	p.skipBlank(false)
+	var value interface{}
+	if value, err = p.parseValue(); err != nil {
+		return
+	}
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = p.descend(table, key); err != nil {
+			return
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, present := table[last]; present {
+		return p.errorf("duplicate key %q", last)
+	}
+	table[last] = value
+	return
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value but got the end of the file")
+	}
+	switch p.peek() {
+	case '"':
+		if strings.HasPrefix(p.s[p.pos:], `"""`) {
+			return p.parseMultilineString(`"""`)
+		}
+		return p.parseBasicString()
+	case '\'':
+		if strings.HasPrefix(p.s[p.pos:], `'''`) {
+			return p.parseMultilineString(`'''`)
+		}
+		return p.parseLiteralString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+	start := p.pos
+	for !p.eof() && (isBareKeyChar(p.peek()) || strings.IndexByte("+.:", p.peek()) >= 0) {
+		p.pos++
+	}
+	token := p.s[start:p.pos]
+	switch {
+	case token == "":
+		return nil, p.errorf("expected a value but got %q", p.peek())
+	case token == "true":
+		return true, nil
+	case token == "false":
+		return false, nil
+	case strings.Contains(token, ":") || strings.IndexByte(token[1:], '-') >= 0 && !strings.ContainsAny(token, "eE"):
+		// the dates and times
+		return token, nil
+	}
+	if i, err := strconv.ParseInt(token, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(strings.Replace(token, "_", "", -1), 64); err == nil {
+		return f, nil
+	}
+	return nil, p.errorf("invalid value %q", token)
+}
+
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+	array := make([]interface{}, 0)
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return array, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+		p.skipBlank(true)
+		if !p.eof() && p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err = p.expect("]"); err != nil {
+			return nil, err
+		}
+		return array, nil
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+	table := make(map[string]interface{})
+	p.skipBlank(false)
+	if !p.eof() && p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if !p.eof() && p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		return table, nil
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.s[p.pos:], "'\n")
+	if end < 0 || p.s[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	value := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return value, nil
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		if c == '"' {
+			p.pos++
+			return b.String(), nil
+		}
+		if c == '\\' {
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseMultilineString(delimiter string) (string, error) {
+	p.pos += len(delimiter)
+	// the line break right after the opening delimiter is trimmed
+	if strings.HasPrefix(p.s[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if !p.eof() && p.peek() == '\n' {
+		p.pos++
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.s[p.pos:], delimiter) {
+			p.pos += len(delimiter)
+			return b.String(), nil
+		}
+		if c := p.peek(); c == '\\' && delimiter == `"""` {
+			rest := strings.TrimLeft(p.s[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				// the line ending backslash trims the spaces and the line breaks after it
+				p.pos = len(p.s) - len(strings.TrimLeft(rest, " \t\r\n"))
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(p.peek())
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.s) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.s[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape %q", p.s[p.pos:p.pos+size])
+		}
+		b.WriteRune(rune(code))
+		p.pos += size
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package diag provides the diagnostics API of the process on the prof port, which are the pprof profiles,
+// the goroutine dumps, the runtime stats and the runtime toggles such as GOGC. Every request must present
+// the token configured by diagToken, and the API is disabled if the token is not configured.
+package diag
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// configuration keys
+const (
+	CfgToken = "diagToken"
+)
+
+// ConfigSchema declares the configuration keys of the diagnostics API.
+var ConfigSchema = config.Schema{
+	CfgToken: config.TypeString,
+}
+
+// paths of the diagnostics API
+const (
+	PprofPath          = "/debug/pprof/"
+	RuntimeStatsPath   = "/debug/runtime/stats"
+	RuntimeGCPath      = "/debug/runtime/gc"
+	SetGCPercentPath   = "/debug/runtime/setGCPercent"
+	SetProfileRatePath = "/debug/runtime/setProfileRate"
+)
+
+// TokenParam is the query parameter carrying the token, for the tools which cannot set the
+// Authorization header, such as go tool pprof.
+const TokenParam = "token"
+
+// token is the token of the diagnostics API, which is disabled if it is empty.
+var token string
+
+// gcPercent is the current GOGC, which the runtime does not tell without setting it.
+var gcPercent = gcPercentFromEnv()
+
+// gcPercentFromEnv returns GOGC of the environment as the runtime reads it, 100 by default.
+func gcPercentFromEnv() int32 {
+	value := os.Getenv("GOGC")
+	if value == "off" {
+		return -1
+	}
+	if percent, err := strconv.Atoi(value); err == nil {
+		return int32(percent)
+	}
+	return 100
+}
+
+// Init sets the token of the diagnostics API by the config.
+func Init(cfg *config.Config) {
+	token = cfg.GetString(CfgToken)
+}
+
+// Register registers the diagnostics API to the mux.
+func Register(mux *http.ServeMux) {
+	mux.Handle(PprofPath, Authorized(http.HandlerFunc(pprof.Index)))
+	mux.Handle(PprofPath+"cmdline", Authorized(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle(PprofPath+"profile", Authorized(http.HandlerFunc(pprof.Profile)))
+	mux.Handle(PprofPath+"symbol", Authorized(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle(PprofPath+"trace", Authorized(http.HandlerFunc(pprof.Trace)))
+	mux.Handle(RuntimeStatsPath, Authorized(http.HandlerFunc(runtimeStats)))
+	mux.Handle(RuntimeGCPath, Authorized(http.HandlerFunc(runGC)))
+	mux.Handle(SetGCPercentPath, Authorized(http.HandlerFunc(setGCPercent)))
+	mux.Handle(SetProfileRatePath, Authorized(http.HandlerFunc(setProfileRate)))
+}
+
+// Authorized serves the requests presenting the token by next, as the bearer token of the Authorization
+// header or the token parameter, and rejects the others.
+func Authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeJSON(w, http.StatusForbidden, nil, "diagnostics API is disabled without "+CfgToken)
+			return
+		}
+		presented := r.URL.Query().Get(TokenParam)
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			presented = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			log.LogWarnf("diag: unauthorized request: remote(%v) path(%v)", r.RemoteAddr, r.URL.Path)
+			writeJSON(w, http.StatusUnauthorized, nil, "invalid diagnostics token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RuntimeStats is the runtime stats of the process.
+type RuntimeStats struct {
+	GoVersion     string  `json:"goVersion"`
+	NumCPU        int     `json:"numCPU"`
+	GOMAXPROCS    int     `json:"gomaxprocs"`
+	NumGoroutine  int     `json:"numGoroutine"`
+	GCPercent     int     `json:"gcPercent"`
+	HeapAlloc     uint64  `json:"heapAlloc"`
+	HeapInuse     uint64  `json:"heapInuse"`
+	HeapIdle      uint64  `json:"heapIdle"`
+	HeapReleased  uint64  `json:"heapReleased"`
+	HeapObjects   uint64  `json:"heapObjects"`
+	Sys           uint64  `json:"sys"`
+	NextGC        uint64  `json:"nextGC"`
+	NumGC         uint32  `json:"numGC"`
+	LastGC        string  `json:"lastGC,omitempty"`
+	PauseTotalMs  float64 `json:"pauseTotalMs"`
+	LastPauseMs   float64 `json:"lastPauseMs"`
+	GCCPUFraction float64 `json:"gcCPUFraction"`
+}
+
+func runtimeStats(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	stats := &RuntimeStats{
+		GoVersion:     runtime.Version(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumGoroutine:  runtime.NumGoroutine(),
+		GCPercent:     int(atomic.LoadInt32(&gcPercent)),
+		HeapAlloc:     ms.HeapAlloc,
+		HeapInuse:     ms.HeapInuse,
+		HeapIdle:      ms.HeapIdle,
+		HeapReleased:  ms.HeapReleased,
+		HeapObjects:   ms.HeapObjects,
+		Sys:           ms.Sys,
+		NextGC:        ms.NextGC,
+		NumGC:         ms.NumGC,
+		PauseTotalMs:  float64(ms.PauseTotalNs) / float64(time.Millisecond),
+		GCCPUFraction: ms.GCCPUFraction,
+	}
+	if ms.NumGC > 0 {
+		stats.LastGC = time.Unix(0, int64(ms.LastGC)).Format(time.RFC3339)
+		stats.LastPauseMs = float64(ms.PauseNs[(ms.NumGC+255)%256]) / float64(time.Millisecond)
+	}
+	writeJSON(w, http.StatusOK, stats, "")
+}
+
+// runGC runs a garbage collection, and returns the memory to the OS if freeOSMemory is true.
+func runGC(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if r.FormValue("freeOSMemory") == "true" {
+		debug.FreeOSMemory()
+	} else {
+		runtime.GC()
+	}
+	log.LogInfof("diag: gc by request: remote(%v) freeOSMemory(%v) cost(%v)", r.RemoteAddr, r.FormValue("freeOSMemory"), time.Since(start))
+	writeJSON(w, http.StatusOK, nil, "gc done")
+}
+
+// setGCPercent sets GOGC to percent, a negative percent disables the garbage collection, and returns the previous one.
+func setGCPercent(w http.ResponseWriter, r *http.Request) {
+	percent, err := strconv.Atoi(r.FormValue("percent"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, nil, "invalid percent: "+err.Error())
+		return
+	}
+	old := debug.SetGCPercent(percent)
+	atomic.StoreInt32(&gcPercent, int32(percent))
+	log.LogWarnf("diag: GOGC set by request: remote(%v) percent(%v) old(%v)", r.RemoteAddr, percent, old)
+	writeJSON(w, http.StatusOK, map[string]int{"old": old, "new": percent}, "")
+}
+
+// setProfileRate sets the rate of the block profile and the fraction of the mutex profile, 0 disables them.
+func setProfileRate(w http.ResponseWriter, r *http.Request) {
+	var block, mutex = -1, -1
+	var err error
+	if value := r.FormValue("block"); value != "" {
+		if block, err = strconv.Atoi(value); err != nil || block < 0 {
+			writeJSON(w, http.StatusBadRequest, nil, "invalid block rate: "+value)
+			return
+		}
+	}
+	if value := r.FormValue("mutex"); value != "" {
+		if mutex, err = strconv.Atoi(value); err != nil || mutex < 0 {
+			writeJSON(w, http.StatusBadRequest, nil, "invalid mutex fraction: "+value)
+			return
+		}
+	}
+	if block >= 0 {
+		runtime.SetBlockProfileRate(block)
+	}
+	if mutex >= 0 {
+		runtime.SetMutexProfileFraction(mutex)
+	}
+	log.LogWarnf("diag: profile rate set by request: remote(%v) block(%v) mutex(%v)", r.RemoteAddr, block, mutex)
+	writeJSON(w, http.StatusOK, nil, "set profile rate success")
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}, msg string) {
+	body, err := json.Marshal(struct {
+		Code int         `json:"code"`
+		Data interface{} `json:"data"`
+		Msg  string      `json:"msg"`
+	}{Code: code, Data: data, Msg: msg})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
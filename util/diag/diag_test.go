@@ -0,0 +1,98 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package diag
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+func serve(mux *http.ServeMux, path, auth string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthorized(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux)
+	defer Init(config.LoadConfigString("{}"))
+
+	Init(config.LoadConfigString("{}"))
+	if w := serve(mux, PprofPath, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("diagnostics without token should be disabled but %v", w.Code)
+	}
+
+	Init(config.LoadConfigString(`{"diagToken": "secret"}`))
+	for auth, code := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Basic secret":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		if w := serve(mux, PprofPath, auth); w.Code != code {
+			t.Errorf("auth(%v) expect code(%v) but %v", auth, code, w.Code)
+		}
+	}
+	if w := serve(mux, PprofPath+"goroutine?debug=1&"+TokenParam+"=secret", ""); w.Code != http.StatusOK {
+		t.Errorf("token parameter expect code(%v) but %v", http.StatusOK, w.Code)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux)
+	Init(config.LoadConfigString(`{"diagToken": "secret"}`))
+	defer Init(config.LoadConfigString("{}"))
+
+	w := serve(mux, SetGCPercentPath+"?percent=200", "Bearer secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("set GOGC fail: code(%v) body(%s)", w.Code, w.Body.Bytes())
+	}
+	var old struct {
+		Data map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &old); err != nil || old.Data["new"] != 200 {
+		t.Fatalf("unexpected reply: body(%s) err(%v)", w.Body.Bytes(), err)
+	}
+	defer serve(mux, SetGCPercentPath+"?percent="+strconv.Itoa(old.Data["old"]), "Bearer secret")
+
+	w = serve(mux, RuntimeStatsPath, "Bearer secret")
+	var stats struct {
+		Data RuntimeStats `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil || stats.Data.GCPercent != 200 || stats.Data.NumGoroutine == 0 {
+		t.Fatalf("unexpected stats: body(%s) err(%v)", w.Body.Bytes(), err)
+	}
+
+	if w = serve(mux, SetGCPercentPath+"?percent=abc", "Bearer secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid percent expect code(%v) but %v", http.StatusBadRequest, w.Code)
+	}
+	if w = serve(mux, RuntimeGCPath+"?freeOSMemory=true", "Bearer secret"); w.Code != http.StatusOK {
+		t.Errorf("gc expect code(%v) but %v", http.StatusOK, w.Code)
+	}
+	if w = serve(mux, SetProfileRatePath+"?mutex=-1", "Bearer secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid mutex fraction expect code(%v) but %v", http.StatusBadRequest, w.Code)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 
 	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/ipfilter"
 	"github.com/cubefs/cubefs/util/log"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
@@ -34,16 +35,19 @@ import (
 )
 
 const (
-	PromHandlerPattern      = "/metrics"       // prometheus handler
-	AppName                 = "cfs"            //app name
-	ConfigKeyExporterEnable = "exporterEnable" //exporter enable
-	ConfigKeyExporterPort   = "exporterPort"   //exporter port
-	ConfigKeyConsulAddr     = "consulAddr"     //consul addr
-	ConfigKeyConsulMeta     = "consulMeta"     // consul meta
-	ConfigKeyIpFilter       = "ipFilter"       // add ip filter
-	ConfigKeyEnablePid      = "enablePid"      // enable report partition id
-	ConfigKeyPushAddr       = "pushAddr"       // enable push data to gateway
-	ChSize                  = 1024 * 10        //collect chan size
+	PromHandlerPattern       = "/metrics"            // prometheus handler
+	AppName                  = "cfs"                 //app name
+	ConfigKeyExporterEnable  = "exporterEnable"      //exporter enable
+	ConfigKeyExporterPort    = "exporterPort"        //exporter port
+	ConfigKeyConsulAddr      = "consulAddr"          //consul addr
+	ConfigKeyConsulMeta      = "consulMeta"          // consul meta
+	ConfigKeyIpFilter        = "ipFilter"            // add ip filter
+	ConfigKeyEnablePid       = "enablePid"           // enable report partition id
+	ConfigKeyPushAddr        = "pushAddr"            // enable push data to gateway
+	ConfigKeyRemoteWriteAddr = "remoteWriteAddr"     // push data by prometheus remote write
+	ConfigKeyOTLPEndpoint    = "otlpMetricsEndpoint" // push data to OTLP/HTTP collector
+	ConfigKeyPushInterval    = "metricsPushInterval" // push interval in seconds
+	ChSize                   = 1024 * 10             //collect chan size
 
 	// monitor label name
 	Vol    = "vol"
@@ -53,6 +57,20 @@ const (
 	Type   = "type"
 )
 
+// ConfigSchema declares the configuration keys of the exporter.
+var ConfigSchema = config.Schema{
+	ConfigKeyExporterEnable:  config.TypeBool,
+	ConfigKeyExporterPort:    config.TypeInt,
+	ConfigKeyConsulAddr:      config.TypeString,
+	ConfigKeyConsulMeta:      config.TypeString,
+	ConfigKeyIpFilter:        config.TypeString,
+	ConfigKeyEnablePid:       config.TypeBool,
+	ConfigKeyPushAddr:        config.TypeString,
+	ConfigKeyRemoteWriteAddr: config.TypeString,
+	ConfigKeyOTLPEndpoint:    config.TypeString,
+	ConfigKeyPushInterval:    config.TypeInt,
+}
+
 var (
 	namespace         string
 	clustername       string
@@ -106,7 +124,7 @@ func Init(role string, cfg *config.Config) {
 	exporterPort = int64(l.Addr().(*net.TCPAddr).Port)
 
 	go func() {
-		err = http.Serve(l, nil)
+		err = http.Serve(l, ipfilter.Handler(http.DefaultServeMux))
 		if err != nil {
 			log.LogError("exporter http serve error: ", err)
 			return
@@ -154,6 +172,8 @@ func RegistConsul(cluster string, role string, cfg *config.Config) {
 		return
 	}
 
+	startPush(cfg, role, cluster, host)
+
 	if enablePush {
 		log.LogWarnf("[RegisterConsul] use auto push data strategy, not register consul")
 		autoPush(cfg.GetString(ConfigKeyPushAddr), role, cluster, host)
@@ -0,0 +1,246 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	otlpMetricsPath = "/v1/metrics"
+	otlpScopeName   = "github.com/cubefs/cubefs"
+
+	// the metrics are cumulative since the process starts
+	aggregationTemporalityCumulative = 2
+)
+
+// otlpWriter writes the metrics to the collector by the OTLP/HTTP protocol in JSON.
+type otlpWriter struct {
+	client   *http.Client
+	url      string
+	resource otlpResource
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+}
+
+type otlpQuantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type otlpSummaryDataPoint struct {
+	Attributes        []otlpKeyValue      `json:"attributes,omitempty"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano"`
+	TimeUnixNano      string              `json:"timeUnixNano"`
+	Count             string              `json:"count"`
+	Sum               float64             `json:"sum"`
+	QuantileValues    []otlpQuantileValue `json:"quantileValues"`
+}
+
+type otlpGauge struct {
+	DataPoints []*otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []*otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                    `json:"aggregationTemporality"`
+	IsMonotonic            bool                   `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []*otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                       `json:"aggregationTemporality"`
+}
+
+type otlpSummary struct {
+	DataPoints []*otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource        `json:"resource"`
+	ScopeMetrics []*otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpRequest struct {
+	ResourceMetrics []*otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func newOTLPWriter(client *http.Client, endpoint string, labels map[string]string) *otlpWriter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, otlpMetricsPath) {
+		url += otlpMetricsPath
+	}
+	attrs := []otlpKeyValue{stringKeyValue("service.name", AppName+"_"+labels["role"])}
+	for _, k := range sortedKeys(labels) {
+		attrs = append(attrs, stringKeyValue(k, labels[k]))
+	}
+	return &otlpWriter{client: client, url: url, resource: otlpResource{Attributes: attrs}}
+}
+
+func (w *otlpWriter) name() string {
+	return w.url
+}
+
+func (w *otlpWriter) write(families []*dto.MetricFamily, now time.Time) (err error) {
+	scope := &otlpScopeMetrics{Metrics: make([]*otlpMetric, 0, len(families))}
+	scope.Scope.Name = otlpScopeName
+	start := strconv.FormatInt(processStartTime.UnixNano(), 10)
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	for _, family := range families {
+		if m := toOTLPMetric(family, start, ts); m != nil {
+			scope.Metrics = append(scope.Metrics, m)
+		}
+	}
+	req := &otlpRequest{ResourceMetrics: []*otlpResourceMetrics{{Resource: w.resource, ScopeMetrics: []*otlpScopeMetrics{scope}}}}
+	var body []byte
+	if body, err = json.Marshal(req); err != nil {
+		return
+	}
+	var httpReq *http.Request
+	if httpReq, err = http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body)); err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return post(w.client, httpReq)
+}
+
+func toOTLPMetric(family *dto.MetricFamily, start, ts string) *otlpMetric {
+	m := &otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		m.Sum = &otlpSum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}
+		for _, metric := range family.GetMetric() {
+			m.Sum.DataPoints = append(m.Sum.DataPoints, &otlpNumberDataPoint{Attributes: attributes(metric),
+				StartTimeUnixNano: start, TimeUnixNano: ts, AsDouble: metric.GetCounter().GetValue()})
+		}
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		m.Gauge = &otlpGauge{}
+		for _, metric := range family.GetMetric() {
+			value := metric.GetGauge().GetValue()
+			if family.GetType() == dto.MetricType_UNTYPED {
+				value = metric.GetUntyped().GetValue()
+			}
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, &otlpNumberDataPoint{Attributes: attributes(metric),
+				StartTimeUnixNano: start, TimeUnixNano: ts, AsDouble: value})
+		}
+	case dto.MetricType_HISTOGRAM:
+		m.Histogram = &otlpHistogram{AggregationTemporality: aggregationTemporalityCumulative}
+		for _, metric := range family.GetMetric() {
+			m.Histogram.DataPoints = append(m.Histogram.DataPoints, toOTLPHistogram(metric, start, ts))
+		}
+	case dto.MetricType_SUMMARY:
+		m.Summary = &otlpSummary{}
+		for _, metric := range family.GetMetric() {
+			s := metric.GetSummary()
+			p := &otlpSummaryDataPoint{Attributes: attributes(metric), StartTimeUnixNano: start, TimeUnixNano: ts,
+				Count: strconv.FormatUint(s.GetSampleCount(), 10), Sum: s.GetSampleSum()}
+			for _, q := range s.GetQuantile() {
+				p.QuantileValues = append(p.QuantileValues, otlpQuantileValue{Quantile: q.GetQuantile(), Value: q.GetValue()})
+			}
+			m.Summary.DataPoints = append(m.Summary.DataPoints, p)
+		}
+	default:
+		return nil
+	}
+	return m
+}
+
+// toOTLPHistogram converts the cumulative buckets of Prometheus to the counts of the buckets of OTLP,
+// where the last bucket counts the values greater than all the bounds.
+func toOTLPHistogram(metric *dto.Metric, start, ts string) *otlpHistogramDataPoint {
+	h := metric.GetHistogram()
+	p := &otlpHistogramDataPoint{Attributes: attributes(metric), StartTimeUnixNano: start, TimeUnixNano: ts,
+		Count: strconv.FormatUint(h.GetSampleCount(), 10), Sum: h.GetSampleSum()}
+	var last uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		p.ExplicitBounds = append(p.ExplicitBounds, b.GetUpperBound())
+		p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-last, 10))
+		last = b.GetCumulativeCount()
+	}
+	p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-last, 10))
+	return p
+}
+
+func attributes(metric *dto.Metric) (attrs []otlpKeyValue) {
+	for _, pair := range metric.GetLabel() {
+		attrs = append(attrs, stringKeyValue(pair.GetName(), pair.GetValue()))
+	}
+	return
+}
+
+func stringKeyValue(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: map[string]interface{}{"stringValue": value}}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
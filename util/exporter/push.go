@@ -0,0 +1,111 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	DefaultPushInterval = 15 * time.Second
+	pushTimeout         = 10 * time.Second
+)
+
+// metricsWriter writes the metrics gathered to a remote endpoint, such as the remote write of Prometheus
+// and the OTLP collector.
+type metricsWriter interface {
+	name() string
+	write(families []*dto.MetricFamily, now time.Time) error
+}
+
+var processStartTime = time.Now()
+
+// startPush starts pushing the metrics to the remote endpoints configured, so that the metrics of the nodes
+// and the clients which can not be scraped, such as those behind the NAT, are still collected.
+func startPush(cfg *config.Config, role, cluster, host string) {
+	if !enabledPrometheus {
+		return
+	}
+	labels := map[string]string{
+		"app":     AppName,
+		"role":    role,
+		"cluster": cluster,
+		"cip":     host,
+		"pid":     strconv.Itoa(os.Getpid()),
+	}
+	interval := DefaultPushInterval
+	if seconds := cfg.GetInt64(ConfigKeyPushInterval); seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+	client := &http.Client{Timeout: pushTimeout}
+	if addr := cfg.GetString(ConfigKeyRemoteWriteAddr); addr != "" {
+		go runPush(newRemoteWriter(client, addr, labels), interval)
+	}
+	if endpoint := cfg.GetString(ConfigKeyOTLPEndpoint); endpoint != "" {
+		go runPush(newOTLPWriter(client, endpoint, labels), interval)
+	}
+}
+
+func runPush(w metricsWriter, interval time.Duration) {
+	log.LogInfof("start pushing metrics to %v every %v", w.name(), interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		families, err := gatherer().Gather()
+		if err != nil {
+			log.LogWarnf("gather metrics for %v err, %v", w.name(), err)
+		}
+		if len(families) == 0 {
+			continue
+		}
+		if err = w.write(families, time.Now()); err != nil {
+			log.LogWarnf("push metrics to %v err, %v", w.name(), err)
+		}
+	}
+}
+
+// gatherer returns where the metrics are registered, which is the registry of the push gateway if it is used.
+func gatherer() prometheus.Gatherer {
+	if enablePush {
+		return registry
+	}
+	return prometheus.DefaultGatherer
+}
+
+func post(client *http.Client, req *http.Request) (err error) {
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status(%v) body(%s)", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return
+}
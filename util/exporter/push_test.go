@@ -0,0 +1,147 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func snappyDecode(t *testing.T, src []byte) []byte {
+	n, i := binary.Uvarint(src)
+	var dst []byte
+	for i < len(src) {
+		tag := src[i]
+		switch tag & 3 {
+		case 0:
+			length := int(tag>>2) + 1
+			i++
+			switch tag >> 2 {
+			case 60:
+				length = int(src[i]) + 1
+				i++
+			case 61:
+				length = int(binary.LittleEndian.Uint16(src[i:])) + 1
+				i += 2
+			}
+			dst = append(dst, src[i:i+length]...)
+			i += length
+		case 2:
+			length := int(tag>>2) + 1
+			offset := int(binary.LittleEndian.Uint16(src[i+1:]))
+			i += 3
+			for j := 0; j < length; j++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			t.Fatalf("unexpected tag %v", tag)
+		}
+	}
+	if uint64(len(dst)) != n {
+		t.Fatalf("expect %v bytes but got %v", n, len(dst))
+	}
+	return dst
+}
+
+func TestSnappyEncode(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.Read(random)
+	repeated := bytes.Repeat([]byte("cfs_metanode_op_latency{vol=\"ltptest\"} "), 5000)
+	for _, data := range [][]byte{nil, []byte("cfs"), random, repeated} {
+		encoded := snappyEncode(data)
+		if decoded := snappyDecode(t, encoded); !bytes.Equal(decoded, data) {
+			t.Fatalf("snappy round trip of %v bytes mismatch", len(data))
+		}
+	}
+	if len(snappyEncode(repeated)) > len(repeated)/10 {
+		t.Fatalf("repeated data should be compressed")
+	}
+}
+
+func gatherTestMetrics(t *testing.T) []*dto.MetricFamily {
+	r := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "cfs_test_ops", ConstLabels: prometheus.Labels{"vol": "ltptest"}})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "cfs_test_latency", Buckets: []float64{1, 10}})
+	r.MustRegister(counter, histogram)
+	counter.Add(3)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	histogram.Observe(50)
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return families
+}
+
+func TestRemoteWrite(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		body = snappyDecode(t, data)
+	}))
+	defer server.Close()
+	w := newRemoteWriter(http.DefaultClient, server.URL, map[string]string{"cluster": "test"})
+	if err := w.write(gatherTestMetrics(t), time.Now()); err != nil {
+		t.Fatalf("remote write fail: err(%v)", err)
+	}
+	for _, s := range []string{"cfs_test_ops", "ltptest", "cfs_test_latency_bucket", "+Inf", "cfs_test_latency_count", "cluster"} {
+		if !bytes.Contains(body, []byte(s)) {
+			t.Fatalf("write request should contain %v", s)
+		}
+	}
+}
+
+func TestOTLPWrite(t *testing.T) {
+	var req otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpMetricsPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+	}))
+	defer server.Close()
+	w := newOTLPWriter(http.DefaultClient, server.URL, map[string]string{"role": "metanode"})
+	if err := w.write(gatherTestMetrics(t), time.Now()); err != nil {
+		t.Fatalf("otlp write fail: err(%v)", err)
+	}
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 2 || metrics[0].Histogram == nil || metrics[1].Sum == nil {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+	p := metrics[0].Histogram.DataPoints[0]
+	if len(p.ExplicitBounds) != 2 || len(p.BucketCounts) != 3 || p.BucketCounts[0] != "1" || p.BucketCounts[2] != "1" ||
+		p.Count != "3" {
+		t.Fatalf("unexpected histogram: %+v", p)
+	}
+	if metrics[1].Sum.DataPoints[0].AsDouble != 3 || metrics[1].Sum.DataPoints[0].Attributes[0].Key != "vol" {
+		t.Fatalf("unexpected counter: %+v", metrics[1].Sum.DataPoints[0])
+	}
+}
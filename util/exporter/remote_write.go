@@ -0,0 +1,248 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// remoteWriter writes the metrics by the remote write protocol of Prometheus, that is the WriteRequest
+// in protobuf compressed by snappy.
+type remoteWriter struct {
+	client *http.Client
+	url    string
+	labels map[string]string
+}
+
+type remoteLabel struct {
+	name  string
+	value string
+}
+
+type remoteSeries struct {
+	labels []remoteLabel
+	value  float64
+}
+
+func newRemoteWriter(client *http.Client, url string, labels map[string]string) *remoteWriter {
+	return &remoteWriter{client: client, url: url, labels: labels}
+}
+
+func (w *remoteWriter) name() string {
+	return w.url
+}
+
+func (w *remoteWriter) write(families []*dto.MetricFamily, now time.Time) (err error) {
+	body := snappyEncode(w.encode(families, now))
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body)); err != nil {
+		return
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return post(w.client, req)
+}
+
+// encode returns the WriteRequest of the metrics, where the histograms and the summaries are written as
+// the series of the buckets, the quantiles, the sums and the counts as Prometheus scrapes them.
+func (w *remoteWriter) encode(families []*dto.MetricFamily, now time.Time) []byte {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	var request []byte
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			for _, series := range w.series(family, m) {
+				request = appendProtoBytes(request, 1, encodeRemoteSeries(series, timestamp))
+			}
+		}
+	}
+	return request
+}
+
+func (w *remoteWriter) series(family *dto.MetricFamily, m *dto.Metric) (series []remoteSeries) {
+	name := family.GetName()
+	add := func(suffix string, value float64, extra ...remoteLabel) {
+		labels := make([]remoteLabel, 0, len(w.labels)+len(m.GetLabel())+len(extra)+1)
+		labels = append(labels, remoteLabel{name: "__name__", value: name + suffix})
+		for k, v := range w.labels {
+			labels = append(labels, remoteLabel{name: k, value: v})
+		}
+		for _, pair := range m.GetLabel() {
+			labels = append(labels, remoteLabel{name: pair.GetName(), value: pair.GetValue()})
+		}
+		labels = append(labels, extra...)
+		series = append(series, remoteSeries{labels: labels, value: value})
+	}
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		add("", m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		add("", m.GetGauge().GetValue())
+	case dto.MetricType_UNTYPED:
+		add("", m.GetUntyped().GetValue())
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		for _, q := range s.GetQuantile() {
+			add("", q.GetValue(), remoteLabel{name: "quantile", value: formatFloat(q.GetQuantile())})
+		}
+		add("_sum", s.GetSampleSum())
+		add("_count", float64(s.GetSampleCount()))
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		hasInf := false
+		for _, b := range h.GetBucket() {
+			hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+			add("_bucket", float64(b.GetCumulativeCount()), remoteLabel{name: "le", value: formatFloat(b.GetUpperBound())})
+		}
+		if !hasInf {
+			add("_bucket", float64(h.GetSampleCount()), remoteLabel{name: "le", value: "+Inf"})
+		}
+		add("_sum", h.GetSampleSum())
+		add("_count", float64(h.GetSampleCount()))
+	}
+	return
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// encodeRemoteSeries returns the TimeSeries of the series, whose labels are sorted by their names as
+// the protocol requires.
+func encodeRemoteSeries(series remoteSeries, timestamp int64) []byte {
+	sort.Slice(series.labels, func(i, j int) bool { return series.labels[i].name < series.labels[j].name })
+	var b []byte
+	for _, label := range series.labels {
+		var l []byte
+		l = appendProtoBytes(l, 1, []byte(label.name))
+		l = appendProtoBytes(l, 2, []byte(label.value))
+		b = appendProtoBytes(b, 1, l)
+	}
+	var sample []byte
+	sample = appendProtoKey(sample, 1, 1)
+	sample = appendFixed64(sample, math.Float64bits(series.value))
+	sample = appendProtoKey(sample, 2, 0)
+	sample = appendUvarint(sample, uint64(timestamp))
+	return appendProtoBytes(b, 2, sample)
+}
+
+func appendProtoKey(b []byte, field int, wireType int) []byte {
+	return appendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendProtoBytes(b []byte, field int, data []byte) []byte {
+	b = appendProtoKey(b, field, 2)
+	b = appendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+const (
+	snappyBlockSize = 1 << 16
+	snappyTableBits = 14
+)
+
+// snappyEncode compresses the data in the block format of snappy. The data is compressed in the blocks of
+// 64KB, so that the offsets of the copies always fit in two bytes.
+func snappyEncode(src []byte) []byte {
+	dst := appendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src)))
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyBlockSize {
+			block = block[:snappyBlockSize]
+		}
+		src = src[len(block):]
+		dst = snappyEncodeBlock(dst, block)
+	}
+	return dst
+}
+
+func snappyEncodeBlock(dst, src []byte) []byte {
+	var table [1 << snappyTableBits]int32
+	load32 := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(src[i:])
+	}
+	hash := func(u uint32) uint32 {
+		return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+	}
+	literal := 0
+	for s := 1; s+4 <= len(src); {
+		h := hash(load32(s))
+		candidate := int(table[h])
+		table[h] = int32(s)
+		if load32(candidate) != load32(s) {
+			s++
+			continue
+		}
+		base := s
+		s, candidate = s+4, candidate+4
+		for s < len(src) && src[s] == src[candidate] {
+			s, candidate = s+1, candidate+1
+		}
+		dst = snappyEmitLiteral(dst, src[literal:base])
+		dst = snappyEmitCopy(dst, s-candidate, s-base)
+		literal = s
+	}
+	return snappyEmitLiteral(dst, src[literal:])
+}
+
+func snappyEmitLiteral(dst, literal []byte) []byte {
+	if len(literal) == 0 {
+		return dst
+	}
+	switch n := len(literal) - 1; {
+	case n < 60:
+		dst = append(dst, byte(n<<2))
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	default:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	}
+	return append(dst, literal...)
+}
+
+// snappyEmitCopy emits the copies with the offsets of two bytes, each of which copies at most 64 bytes.
+func snappyEmitCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	return append(dst, byte(length-1)<<2|2, byte(offset), byte(offset>>8))
+}
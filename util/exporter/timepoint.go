@@ -35,6 +35,13 @@ func NewTP(name string) (tp *TimePoint) {
 	return
 }
 
+// NewTPSince creates a time point of an operation which started at the given time.
+func NewTPSince(name string, startTime time.Time) (tp *TimePoint) {
+	tp = NewTP(name)
+	tp.startTime = startTime
+	return
+}
+
 func (tp *TimePoint) Set() {
 	if !enabledPrometheus {
 		return
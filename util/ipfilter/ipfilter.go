@@ -0,0 +1,149 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ipfilter restricts the access to the HTTP services of the process, such as the admin APIs of
+// the master and the admin and profiling APIs of the nodes, by the lists of the CIDRs of the common config.
+// A request is rejected if its peer is in the denylist, or if the allowlist is set and the peer is not in it.
+package ipfilter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// configuration keys
+const (
+	CfgAllowlist = "httpAllowlist"
+	CfgDenylist  = "httpDenylist"
+)
+
+// ConfigSchema declares the configuration keys of the filter.
+var ConfigSchema = config.Schema{
+	CfgAllowlist: config.TypeStringSlice,
+	CfgDenylist:  config.TypeStringSlice,
+}
+
+// Filter decides whether the peers are allowed by the lists of the CIDRs.
+type Filter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// processFilter is the filter of the process, which is nil if no list is configured.
+var processFilter *Filter
+
+// NewFilter returns the filter of the lists, whose items are the CIDRs or the single IPs.
+// It returns nil if both lists are empty.
+func NewFilter(allowlist, denylist []string) (f *Filter, err error) {
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return
+	}
+	f = new(Filter)
+	if f.allow, err = parseNets(allowlist); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parseNets(denylist); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func parseNets(items []string) (nets []*net.IPNet, err error) {
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			var ip = net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP or CIDR %v", item)
+			}
+			var bits = 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		var ipNet *net.IPNet
+		if _, ipNet, err = net.ParseCIDR(item); err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %v: %v", item, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed returns true if the IP is allowed by the filter, and the nil filter allows all the IPs.
+func (f *Filter) Allowed(ip net.IP) bool {
+	if f == nil {
+		return true
+	}
+	if ip == nil || contains(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || contains(f.allow, ip)
+}
+
+// Handler returns the handler serving the requests allowed by the filter by h, and rejecting the others
+// with 403. The peer is the remote address of the connection, as the forwarded headers can be forged.
+func (f *Filter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var host = r.RemoteAddr
+		if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			host = ip
+		}
+		if !f.Allowed(net.ParseIP(host)) {
+			log.LogWarnf("ipfilter: request rejected: remote(%v) method(%v) path(%v)", r.RemoteAddr, r.Method, r.URL.Path)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Init sets the filter of the process by the lists of the config, which must be called before
+// the HTTP services are started.
+func Init(cfg *config.Config) (err error) {
+	var f *Filter
+	if f, err = NewFilter(cfg.GetStringSlice(CfgAllowlist), cfg.GetStringSlice(CfgDenylist)); err != nil {
+		return
+	}
+	if f != nil {
+		log.LogInfof("ipfilter: HTTP access restricted: allowlist(%v) denylist(%v)",
+			cfg.GetStringSlice(CfgAllowlist), cfg.GetStringSlice(CfgDenylist))
+	}
+	processFilter = f
+	return
+}
+
+// Handler returns h restricted by the filter of the process, see Init.
+func Handler(h http.Handler) http.Handler {
+	if processFilter == nil {
+		return h
+	}
+	return processFilter.Handler(h)
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := NewFilter([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"}, []string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("new filter fail: err(%v)", err)
+	}
+	var cases = map[string]bool{
+		"10.2.3.4":    true,
+		"10.1.3.4":    false,
+		"192.168.1.1": true,
+		"192.168.1.2": false,
+		"fd00::1":     true,
+		"fe80::1":     false,
+	}
+	for ip, allowed := range cases {
+		if f.Allowed(net.ParseIP(ip)) != allowed {
+			t.Errorf("ip(%v) expect allowed(%v)", ip, allowed)
+		}
+	}
+
+	// the denylist alone allows the others
+	if f, err = NewFilter(nil, []string{"127.0.0.1"}); err != nil {
+		t.Fatalf("new filter fail: err(%v)", err)
+	}
+	if f.Allowed(net.ParseIP("127.0.0.1")) || !f.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Errorf("unexpected result of the denylist")
+	}
+
+	if f, err = NewFilter(nil, nil); f != nil || err != nil || !f.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Errorf("filter without lists should be nil and allow all")
+	}
+	if _, err = NewFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Errorf("expect invalid CIDR error")
+	}
+	if _, err = NewFilter(nil, []string{"host"}); err == nil {
+		t.Errorf("expect invalid IP error")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	f, err := NewFilter([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatalf("new filter fail: err(%v)", err)
+	}
+	var h = f.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for remote, code := range map[string]int{
+		"10.0.0.1:1234":   http.StatusOK,
+		"172.16.0.1:1234": http.StatusForbidden,
+		"[fd00::1]:1234":  http.StatusForbidden,
+		"invalid-remote":  http.StatusForbidden,
+	} {
+		var r = httptest.NewRequest("GET", "/disks", nil)
+		r.RemoteAddr = remote
+		// the forwarded headers are not trusted
+		r.Header.Set("X-Forwarded-For", "10.0.0.3")
+		var w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("remote(%q) expect code(%v) but %v", remote, code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package kafka provides a minimal producer of Kafka.
+// https://kafka.apache.org/protocol.html
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Producer is a minimal producer of Kafka which publishes the messages to the leaders of the
+// partitions of the topic, the partition of a message is chosen by the hash of its key. The metadata
+// of the topic is requested (Metadata v4) from the brokers when the producer starts or fails, and
+// the messages are produced (Produce v3) one per request in record batches of magic v2 with acks 1.
+
+const (
+	kafkaAPIKeyProduce  int16 = 0
+	kafkaAPIKeyMetadata int16 = 3
+
+	kafkaProduceVersion  int16 = 3
+	kafkaMetadataVersion int16 = 4
+
+	kafkaAcks            int16 = 1
+	kafkaMaxResponseSize       = 64 * 1024 * 1024
+)
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errKafkaNoPartition = errors.New("no available partition of the topic")
+)
+
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	return "kafka error code " + strconv.Itoa(int(e))
+}
+
+type Producer struct {
+	brokers  []string
+	topic    string
+	clientID string
+	timeout  time.Duration
+
+	mu            sync.Mutex
+	correlationID int32
+	leaders       []string // broker address of the leader of each partition
+	conns         map[string]net.Conn
+}
+
+// NewProducer returns the producer of the topic, which identifies itself to the brokers by the client ID.
+// The connections are made on the first message.
+func NewProducer(brokers []string, topic, clientID string, timeout time.Duration) *Producer {
+	return &Producer{
+		brokers:  brokers,
+		topic:    topic,
+		clientID: clientID,
+		timeout:  timeout,
+		conns:    make(map[string]net.Conn),
+	}
+}
+
+// Produce publishes the message to the partition chosen by the key, and waits for the leader of the
+// partition to append it.
+func (p *Producer) Produce(key string, data []byte) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.leaders) == 0 {
+		if err = p.refreshMetadata(); err != nil {
+			return
+		}
+	}
+	var hash = fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	var partition = int32(hash.Sum32() % uint32(len(p.leaders)))
+	var addr = p.leaders[partition]
+	if addr == "" {
+		p.leaders = nil
+		return errKafkaNoPartition
+	}
+	if err = p.produce(addr, partition, []byte(key), data, time.Now()); err != nil {
+		// the leaders may have changed
+		p.closeConn(addr)
+		p.leaders = nil
+	}
+	return
+}
+
+// Close closes the connections to the brokers.
+func (p *Producer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr := range p.conns {
+		p.closeConn(addr)
+	}
+}
+
+func (p *Producer) closeConn(addr string) {
+	if conn, exist := p.conns[addr]; exist {
+		_ = conn.Close()
+		delete(p.conns, addr)
+	}
+}
+
+// request sends the request to the broker and returns the body of its response.
+func (p *Producer) request(addr string, apiKey, apiVersion int16, body []byte) (resp []byte, err error) {
+	var conn, exist = p.conns[addr]
+	if !exist {
+		if conn, err = net.DialTimeout("tcp", addr, p.timeout); err != nil {
+			return
+		}
+		p.conns[addr] = conn
+	}
+	defer func() {
+		if err != nil {
+			p.closeConn(addr)
+		}
+	}()
+	if err = conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return
+	}
+
+	p.correlationID++
+	var e = &kafkaEncoder{}
+	e.putInt32(0) // size
+	e.putInt16(apiKey)
+	e.putInt16(apiVersion)
+	e.putInt32(p.correlationID)
+	e.putString(p.clientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	if _, err = conn.Write(e.buf); err != nil {
+		return
+	}
+
+	var header [8]byte
+	if _, err = io.ReadFull(conn, header[:]); err != nil {
+		return
+	}
+	var size = int32(binary.BigEndian.Uint32(header[:4]))
+	if size < 4 || size > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("invalid size of kafka response: %v", size)
+	}
+	if correlationID := int32(binary.BigEndian.Uint32(header[4:])); correlationID != p.correlationID {
+		return nil, fmt.Errorf("mismatched correlation id of kafka response: %v", correlationID)
+	}
+	resp = make([]byte, size-4)
+	if _, err = io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (p *Producer) refreshMetadata() (err error) {
+	var e = &kafkaEncoder{}
+	e.putInt32(1)
+	e.putString(p.topic)
+	e.putBool(false) // allow auto topic creation
+	var body = e.buf
+
+	for _, broker := range p.brokers {
+		var resp []byte
+		if resp, err = p.request(broker, kafkaAPIKeyMetadata, kafkaMetadataVersion, body); err != nil {
+			continue
+		}
+		if p.leaders, err = parseKafkaMetadata(resp, p.topic); err != nil {
+			continue
+		}
+		return nil
+	}
+	if err == nil {
+		err = errors.New("no kafka broker")
+	}
+	return
+}
+
+// parseKafkaMetadata returns the broker addresses of the leaders of the partitions of the topic
+// from the response of Metadata v4.
+func parseKafkaMetadata(resp []byte, topic string) (leaders []string, err error) {
+	var d = &kafkaDecoder{buf: resp}
+	d.int32() // throttle time
+	var brokers = make(map[int32]string)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		var nodeID = d.int32()
+		var host = d.string()
+		var port = d.int32()
+		d.string() // rack
+		brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster id
+	d.int32()  // controller id
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		var errorCode = d.int16()
+		var name = d.string()
+		d.int8() // is internal
+		var partitions = make(map[int32]string)
+		var maxPartition int32 = -1
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int16() // error code
+			var index = d.int32()
+			var leader = d.int32()
+			for k, l := 0, d.arrayLen(); k < l; k++ { // replicas
+				d.int32()
+			}
+			for k, l := 0, d.arrayLen(); k < l; k++ { // in-sync replicas
+				d.int32()
+			}
+			partitions[index] = brokers[leader]
+			if index > maxPartition {
+				maxPartition = index
+			}
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if name != topic {
+			continue
+		}
+		if errorCode != 0 {
+			return nil, kafkaError(errorCode)
+		}
+		if maxPartition < 0 {
+			return nil, errKafkaNoPartition
+		}
+		leaders = make([]string, maxPartition+1)
+		for index, addr := range partitions {
+			leaders[index] = addr
+		}
+		return leaders, nil
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return nil, fmt.Errorf("no metadata of topic: %v", topic)
+}
+
+func (p *Producer) produce(addr string, partition int32, key, value []byte, timestamp time.Time) (err error) {
+	var batch = encodeKafkaRecordBatch(key, value, timestamp)
+	var e = &kafkaEncoder{}
+	e.putInt16(-1) // transactional id
+	e.putInt16(kafkaAcks)
+	e.putInt32(int32(p.timeout / time.Millisecond))
+	e.putInt32(1)
+	e.putString(p.topic)
+	e.putInt32(1)
+	e.putInt32(partition)
+	e.putBytes(batch)
+
+	var resp []byte
+	if resp, err = p.request(addr, kafkaAPIKeyProduce, kafkaProduceVersion, e.buf); err != nil {
+		return
+	}
+	var d = &kafkaDecoder{buf: resp}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int32() // partition
+			var errorCode = d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+			if d.err == nil && errorCode != 0 {
+				return kafkaError(errorCode)
+			}
+		}
+	}
+	return d.err
+}
+
+// encodeKafkaRecordBatch returns the record batch (magic v2) of a record.
+func encodeKafkaRecordBatch(key, value []byte, timestamp time.Time) []byte {
+	var record = &kafkaEncoder{}
+	record.putInt8(0)   // attributes
+	record.putVarint(0) // timestamp delta
+	record.putVarint(0) // offset delta
+	record.putVarint(int64(len(key)))
+	record.buf = append(record.buf, key...)
+	record.putVarint(int64(len(value)))
+	record.buf = append(record.buf, value...)
+	record.putVarint(0) // headers
+
+	var ts = timestamp.UnixNano() / int64(time.Millisecond)
+	var e = &kafkaEncoder{}
+	e.putInt64(0)  // base offset
+	e.putInt32(0)  // batch length
+	e.putInt32(-1) // partition leader epoch
+	e.putInt8(2)   // magic
+	e.putInt32(0)  // crc
+	var crcStart = len(e.buf)
+	e.putInt16(0)  // attributes
+	e.putInt32(0)  // last offset delta
+	e.putInt64(ts) // first timestamp
+	e.putInt64(ts) // max timestamp
+	e.putInt64(-1) // producer id
+	e.putInt16(-1) // producer epoch
+	e.putInt32(-1) // base sequence
+	e.putInt32(1)  // records
+	e.putVarint(int64(len(record.buf)))
+	e.buf = append(e.buf, record.buf...)
+
+	binary.BigEndian.PutUint32(e.buf[8:], uint32(len(e.buf)-12))
+	binary.BigEndian.PutUint32(e.buf[crcStart-4:], crc32.Checksum(e.buf[crcStart:], crc32cTable))
+	return e.buf
+}
+
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) putInt8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *kafkaEncoder) putBool(v bool) {
+	if v {
+		e.putInt8(1)
+	} else {
+		e.putInt8(0)
+	}
+}
+
+func (e *kafkaEncoder) putInt16(v int16) {
+	e.buf = append(e.buf, byte(uint16(v)>>8), byte(v))
+}
+
+func (e *kafkaEncoder) putInt32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *kafkaEncoder) putInt64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.buf = append(e.buf, b[:]...)
+}
+
+// putVarint appends the zigzag varint, which is the encoding of the binary package.
+func (e *kafkaEncoder) putVarint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	var n = binary.PutVarint(b[:], v)
+	e.buf = append(e.buf, b[:n]...)
+}
+
+func (e *kafkaEncoder) putString(s string) {
+	e.putInt16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) putBytes(b []byte) {
+	e.putInt32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder decodes the responses, it keeps the first error and returns zero values after it.
+type kafkaDecoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.off+n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	var b = d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string decodes a nullable string, the null string is decoded as empty.
+func (d *kafkaDecoder) string() string {
+	var n = d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// arrayLen returns the length of an array, the null array is decoded as empty.
+func (d *kafkaDecoder) arrayLen() int {
+	var n = d.int32()
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	if int(n) > len(d.buf)-d.off {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return int(n)
+}
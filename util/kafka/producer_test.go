@@ -0,0 +1,197 @@
+// Copyright 2019 The ChubaoFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kafka
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEncodeRecordBatch(t *testing.T) {
+	var batch = encodeKafkaRecordBatch([]byte("bucket/key"), []byte("value"), time.Unix(1600000000, 0))
+	key, value, err := decodeTestKafkaRecordBatch(batch)
+	if err != nil {
+		t.Fatalf("decode record batch fail: err(%v)", err)
+	}
+	if string(key) != "bucket/key" || string(value) != "value" {
+		t.Fatalf("unexpected record: key(%s) value(%s)", key, value)
+	}
+	// corrupted batch must fail the check of CRC
+	batch[len(batch)-2] ^= 0xff
+	if _, _, err = decodeTestKafkaRecordBatch(batch); err == nil {
+		t.Fatalf("decode corrupted record batch should fail")
+	}
+}
+
+func TestProducer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail: err(%v)", err)
+	}
+	defer listener.Close()
+	var received = make(chan string, 2)
+	go serveTestKafkaBroker(t, listener, "objects", received)
+
+	var producer = NewProducer([]string{listener.Addr().String()}, "objects", "test", 5*time.Second)
+	defer producer.Close()
+	for _, key := range []string{"bucket/a", "bucket/b"} {
+		if err = producer.Produce(key, []byte(`{"Records":[]}`)); err != nil {
+			t.Fatalf("deliver fail: key(%v) err(%v)", key, err)
+		}
+		if message := <-received; message != key+"="+`{"Records":[]}` {
+			t.Fatalf("unexpected message: %v", message)
+		}
+	}
+
+	// unknown topic
+	var unknown = NewProducer([]string{listener.Addr().String()}, "unknown", "test", 5*time.Second)
+	defer unknown.Close()
+	if err = unknown.Produce("bucket/a", []byte("{}")); err == nil {
+		t.Fatalf("deliver to unknown topic should fail")
+	}
+}
+
+func decodeTestKafkaRecordBatch(batch []byte) (key, value []byte, err error) {
+	var d = &kafkaDecoder{buf: batch}
+	d.int64() // base offset
+	if length := d.int32(); int(length) != len(batch)-12 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	d.int32() // partition leader epoch
+	if magic := d.int8(); magic != 2 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	var crc = uint32(d.int32())
+	if crc32.Checksum(batch[d.off:], crc32cTable) != crc {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	d.next(2 + 4 + 8 + 8 + 8 + 2 + 4) // attributes to base sequence
+	if count := d.int32(); count != 1 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	var varint = func() int64 {
+		v, n := binary.Varint(d.buf[d.off:])
+		d.off += n
+		return v
+	}
+	varint() // length
+	d.int8() // attributes
+	varint() // timestamp delta
+	varint() // offset delta
+	key = d.next(int(varint()))
+	value = d.next(int(varint()))
+	return key, value, d.err
+}
+
+// serveTestKafkaBroker serves the Metadata and Produce requests as a broker with the topic of two partitions.
+func serveTestKafkaBroker(t *testing.T, listener net.Listener, topic string, received chan<- string) {
+	host, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			for {
+				var size [4]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				var req = make([]byte, binary.BigEndian.Uint32(size[:]))
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				var d = &kafkaDecoder{buf: req}
+				var apiKey, apiVersion, correlationID = d.int16(), d.int16(), d.int32()
+				d.string() // client id
+
+				var e = &kafkaEncoder{}
+				e.putInt32(0)
+				e.putInt32(correlationID)
+				switch {
+				case apiKey == kafkaAPIKeyMetadata && apiVersion == kafkaMetadataVersion:
+					d.int32()
+					var requested = d.string()
+					e.putInt32(0) // throttle time
+					e.putInt32(1) // brokers
+					e.putInt32(1)
+					e.putString(host)
+					e.putInt32(int32(port))
+					e.putInt16(-1) // rack
+					e.putInt16(-1) // cluster id
+					e.putInt32(1)  // controller
+					e.putInt32(1)  // topics
+					if requested != topic {
+						e.putInt16(3) // unknown topic or partition
+						e.putString(requested)
+						e.putInt8(0)
+						e.putInt32(0)
+						break
+					}
+					e.putInt16(0)
+					e.putString(topic)
+					e.putInt8(0)
+					e.putInt32(2) // partitions
+					for i := int32(0); i < 2; i++ {
+						e.putInt16(0)
+						e.putInt32(i)
+						e.putInt32(1)
+						e.putInt32(1)
+						e.putInt32(1)
+						e.putInt32(1)
+						e.putInt32(1)
+					}
+				case apiKey == kafkaAPIKeyProduce && apiVersion == kafkaProduceVersion:
+					d.string() // transactional id
+					d.int16()  // acks
+					d.int32()  // timeout
+					d.int32()
+					var name = d.string()
+					d.int32()
+					var partition = d.int32()
+					var batch = d.next(int(d.int32()))
+					var errorCode int16
+					if key, value, err := decodeTestKafkaRecordBatch(batch); err != nil || name != topic {
+						errorCode = 2 // corrupt message
+					} else {
+						received <- string(key) + "=" + string(value)
+					}
+					e.putInt32(1)
+					e.putString(name)
+					e.putInt32(1)
+					e.putInt32(partition)
+					e.putInt16(errorCode)
+					e.putInt64(0)
+					e.putInt64(-1)
+					e.putInt32(0) // throttle time
+				default:
+					t.Errorf("unexpected request: apiKey(%v) apiVersion(%v)", apiKey, apiVersion)
+					return
+				}
+				binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+				if _, err := conn.Write(e.buf); err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
@@ -24,6 +24,8 @@ type KeyInfo struct {
 	Ts        int64  `json:"create_ts"`
 	Role      string `json:"role"`
 	Caps      []byte `json:"caps"`
+	// KeyVersion is the version of AuthKey, which is embedded in the tickets of the service
+	KeyVersion uint32 `json:"key_version,omitempty"`
 }
 
 // DumpJSONFile dump KeyInfo to file in json format
@@ -51,13 +53,14 @@ func (u *KeyInfo) DumpJSONFile(filename string) (err error) {
 // DumpJSONStr dump KeyInfo to string in json format
 func (u *KeyInfo) DumpJSONStr() (r string, err error) {
 	dumpInfo := struct {
-		ID        string `json:"id"`
-		AuthKey   []byte `json:"auth_key"`
-		AccessKey string `json:"access_key"`
-		SecretKey string `json:"secret_key"`
-		Ts        int64  `json:"create_ts"`
-		Role      string `json:"role"`
-		Caps      string `json:"caps"`
+		ID         string `json:"id"`
+		AuthKey    []byte `json:"auth_key"`
+		AccessKey  string `json:"access_key"`
+		SecretKey  string `json:"secret_key"`
+		Ts         int64  `json:"create_ts"`
+		Role       string `json:"role"`
+		Caps       string `json:"caps"`
+		KeyVersion uint32 `json:"key_version,omitempty"`
 	}{
 		u.ID,
 		u.AuthKey,
@@ -66,6 +69,7 @@ func (u *KeyInfo) DumpJSONStr() (r string, err error) {
 		u.Ts,
 		u.Role,
 		string(u.Caps),
+		u.KeyVersion,
 	}
 	data, err := json.MarshalIndent(dumpInfo, "", "  ")
 	if err != nil {
@@ -103,6 +107,16 @@ func (u *KeyInfo) IsValidCaps() (err error) {
 	return
 }
 
+// IsValidAuthKey checks the validity of the AuthKey specified on the rotation, which is an AES key
+func (u *KeyInfo) IsValidAuthKey() (err error) {
+	switch len(u.AuthKey) {
+	case 16, 24, 32:
+	default:
+		err = fmt.Errorf("invalid auth key size [%d], should be 16, 24 or 32", len(u.AuthKey))
+	}
+	return
+}
+
 // IsValidKeyInfo is a valid of KeyInfo
 func (u *KeyInfo) IsValidKeyInfo() (err error) {
 	if err = u.IsValidID(); err != nil {
@@ -0,0 +1,186 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	awsContentType   = "application/x-amz-json-1.1"
+	awsTargetPrefix  = "TrentService."
+	awsKeySpecAES256 = "AES_256"
+	awsService       = "kms"
+	awsSignAlgorithm = "AWS4-HMAC-SHA256"
+	awsDateFormat    = "20060102T150405Z"
+)
+
+// awsProvider requests the AWS KMS in its JSON protocol, which is also served by the compatible KMS
+// such as the local-kms. The requests are signed in the signature version 4 if the credentials are configured.
+type awsProvider struct {
+	endpoint  string
+	keyId     string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+type awsGenerateDataKeyRequest struct {
+	KeyId   string `json:"KeyId"`
+	KeySpec string `json:"KeySpec"`
+}
+
+type awsGenerateDataKeyResponse struct {
+	KeyId          string `json:"KeyId"`
+	Plaintext      []byte `json:"Plaintext"`
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+}
+
+type awsEncryptRequest struct {
+	KeyId     string `json:"KeyId"`
+	Plaintext []byte `json:"Plaintext"`
+}
+
+type awsEncryptResponse struct {
+	KeyId          string `json:"KeyId"`
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+}
+
+type awsDecryptRequest struct {
+	KeyId          string `json:"KeyId,omitempty"`
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+}
+
+type awsDecryptResponse struct {
+	KeyId     string `json:"KeyId"`
+	Plaintext []byte `json:"Plaintext"`
+}
+
+func (p *awsProvider) key(keyId string) string {
+	if keyId == "" {
+		return p.keyId
+	}
+	return keyId
+}
+
+func (p *awsProvider) GenerateDataKey(keyId string) (usedKeyId string, plaintext, wrapped []byte, err error) {
+	keyId = p.key(keyId)
+	var resp awsGenerateDataKeyResponse
+	if err = p.call("GenerateDataKey", &awsGenerateDataKeyRequest{KeyId: keyId, KeySpec: awsKeySpecAES256}, &resp); err != nil {
+		return
+	}
+	if err = checkDataKey(resp.Plaintext); err != nil {
+		return
+	}
+	if usedKeyId = resp.KeyId; usedKeyId == "" {
+		usedKeyId = keyId
+	}
+	return usedKeyId, resp.Plaintext, resp.CiphertextBlob, nil
+}
+
+func (p *awsProvider) Encrypt(keyId string, plaintext []byte) (usedKeyId string, wrapped []byte, err error) {
+	keyId = p.key(keyId)
+	var resp awsEncryptResponse
+	if err = p.call("Encrypt", &awsEncryptRequest{KeyId: keyId, Plaintext: plaintext}, &resp); err != nil {
+		return
+	}
+	if usedKeyId = resp.KeyId; usedKeyId == "" {
+		usedKeyId = keyId
+	}
+	return usedKeyId, resp.CiphertextBlob, nil
+}
+
+// Decrypt unwraps the key, where the key of the KMS is optional since it is embedded in the wrapped key.
+func (p *awsProvider) Decrypt(keyId string, wrapped []byte) (plaintext []byte, err error) {
+	var resp awsDecryptResponse
+	if err = p.call("Decrypt", &awsDecryptRequest{KeyId: keyId, CiphertextBlob: wrapped}, &resp); err != nil {
+		return
+	}
+	return resp.Plaintext, nil
+}
+
+func (p *awsProvider) call(action string, request, response interface{}) (err error) {
+	var body []byte
+	if body, err = json.Marshal(request); err != nil {
+		return
+	}
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, p.endpoint, bytes.NewReader(body)); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", awsContentType)
+	req.Header.Set("X-Amz-Target", awsTargetPrefix+action)
+	if p.accessKey != "" {
+		p.sign(req, body, time.Now().UTC())
+	}
+	if err = doJSON(p.client, req, response); err != nil {
+		return fmt.Errorf("KMS %v fail: %v", action, err)
+	}
+	return
+}
+
+// sign signs the request in the signature version 4 of AWS.
+func (p *awsProvider) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format(awsDateFormat)
+	scope := strings.Join([]string{amzDate[:8], p.region, awsService, "aws4_request"}, "/")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		uri,
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-date:" + amzDate,
+		"x-amz-target:" + req.Header.Get("X-Amz-Target"),
+		"",
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+	stringToSign := strings.Join([]string{awsSignAlgorithm, amzDate, scope, hexSHA256([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), amzDate[:8])
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, awsService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%v Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		awsSignAlgorithm, p.accessKey, scope, signedHeaders, signature))
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// The paths of the simple HTTP KMS, which accepts the requests in JSON by POST and replies in JSON,
+// with the keys in base64. A request is authorized by the bearer token if configured.
+//   - /generateDataKey: {"keyId"} -> {"keyId", "plaintext", "ciphertext"}
+//   - /encrypt:         {"keyId", "plaintext"} -> {"keyId", "ciphertext"}
+//   - /decrypt:         {"keyId", "ciphertext"} -> {"plaintext"}
+const (
+	httpPathGenerateDataKey = "/generateDataKey"
+	httpPathEncrypt         = "/encrypt"
+	httpPathDecrypt         = "/decrypt"
+)
+
+type httpProvider struct {
+	endpoint string
+	keyId    string
+	token    string
+	client   *http.Client
+}
+
+type httpKMSMessage struct {
+	KeyId      string `json:"keyId"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+func (p *httpProvider) key(keyId string) string {
+	if keyId == "" {
+		return p.keyId
+	}
+	return keyId
+}
+
+func (p *httpProvider) GenerateDataKey(keyId string) (usedKeyId string, plaintext, wrapped []byte, err error) {
+	var resp httpKMSMessage
+	if err = p.call(httpPathGenerateDataKey, &httpKMSMessage{KeyId: p.key(keyId)}, &resp); err != nil {
+		return
+	}
+	if err = checkDataKey(resp.Plaintext); err != nil {
+		return
+	}
+	if usedKeyId = resp.KeyId; usedKeyId == "" {
+		usedKeyId = p.key(keyId)
+	}
+	return usedKeyId, resp.Plaintext, resp.Ciphertext, nil
+}
+
+func (p *httpProvider) Encrypt(keyId string, plaintext []byte) (usedKeyId string, wrapped []byte, err error) {
+	var resp httpKMSMessage
+	if err = p.call(httpPathEncrypt, &httpKMSMessage{KeyId: p.key(keyId), Plaintext: plaintext}, &resp); err != nil {
+		return
+	}
+	if usedKeyId = resp.KeyId; usedKeyId == "" {
+		usedKeyId = p.key(keyId)
+	}
+	return usedKeyId, resp.Ciphertext, nil
+}
+
+func (p *httpProvider) Decrypt(keyId string, wrapped []byte) (plaintext []byte, err error) {
+	var resp httpKMSMessage
+	if err = p.call(httpPathDecrypt, &httpKMSMessage{KeyId: p.key(keyId), Ciphertext: wrapped}, &resp); err != nil {
+		return
+	}
+	return resp.Plaintext, nil
+}
+
+func (p *httpProvider) call(path string, request, response interface{}) (err error) {
+	var body []byte
+	if body, err = json.Marshal(request); err != nil {
+		return
+	}
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, p.endpoint+path, bytes.NewReader(body)); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	if err = doJSON(p.client, req, response); err != nil {
+		return fmt.Errorf("KMS %v fail: %v", path, err)
+	}
+	return
+}
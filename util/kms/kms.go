@@ -0,0 +1,204 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package kms provides the external key management services, which keep the master keys and wrap the
+// keys of the cluster with them, so that the keys are not stored in the config files or the metadata
+// in plaintext. The server-side encryption of the ObjectNode, the at-rest encryption of the volumes and
+// the keys of the config files of the AuthNode and the Master are wrapped by the configured provider.
+package kms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+// configuration keys
+const (
+	// the provider of the KMS, "aws", "vault" or "http", which is "aws" if not specified
+	CfgProvider = "kmsProvider"
+	// the endpoint of the KMS, the KMS is not used if not specified
+	CfgEndpoint = "kmsEndpoint"
+	// the key of the KMS wrapping the keys if none is specified by the request
+	CfgKeyId = "kmsKeyId"
+	// the region and the credentials signing the requests of the AWS KMS, which are not signed if not specified
+	CfgRegion    = "kmsRegion"
+	CfgAccessKey = "kmsAccessKey"
+	CfgSecretKey = "kmsSecretKey"
+	// the token of the Vault, or the bearer token of the HTTP KMS
+	CfgToken = "kmsToken"
+	// the mount path of the transit secrets engine of the Vault, "transit" if not specified
+	CfgMount = "kmsMount"
+)
+
+// ConfigSchema declares the configuration keys of the KMS.
+var ConfigSchema = config.Schema{
+	CfgProvider:  config.TypeString,
+	CfgEndpoint:  config.TypeString,
+	CfgKeyId:     config.TypeString,
+	CfgRegion:    config.TypeString,
+	CfgAccessKey: config.TypeString,
+	CfgSecretKey: config.TypeString,
+	CfgToken:     config.TypeString,
+	CfgMount:     config.TypeString,
+}
+
+const (
+	ProviderAWS   = "aws"
+	ProviderVault = "vault"
+	ProviderHTTP  = "http"
+
+	// WrappedKeyPrefix marks the key of the config wrapped by the KMS, which is followed by the wrapped key in base64
+	WrappedKeyPrefix = "kms:"
+
+	DataKeySize = 32
+
+	requestTimeout  = 10 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+// Provider is the client of the KMS, which generates and wraps the data keys with the key of the KMS.
+// The keyId of the methods is the key of the KMS, and the default one is used if it is empty.
+type Provider interface {
+	// GenerateDataKey returns a new data key of DataKeySize bytes, its wrapped form and the key wrapping it.
+	GenerateDataKey(keyId string) (usedKeyId string, plaintext, wrapped []byte, err error)
+	// Encrypt wraps the plaintext with the key of the KMS.
+	Encrypt(keyId string, plaintext []byte) (usedKeyId string, wrapped []byte, err error)
+	// Decrypt unwraps the key wrapped by Encrypt or GenerateDataKey.
+	Decrypt(keyId string, wrapped []byte) (plaintext []byte, err error)
+}
+
+// Config is the configuration of the KMS.
+type Config struct {
+	Provider  string
+	Endpoint  string
+	KeyId     string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Token     string
+	Mount     string
+}
+
+// ParseConfig returns the configuration of the KMS, or nil if the KMS is not configured.
+func ParseConfig(cfg *config.Config) *Config {
+	if cfg.GetString(CfgEndpoint) == "" {
+		return nil
+	}
+	return &Config{
+		Provider:  cfg.GetString(CfgProvider),
+		Endpoint:  cfg.GetString(CfgEndpoint),
+		KeyId:     cfg.GetString(CfgKeyId),
+		Region:    cfg.GetString(CfgRegion),
+		AccessKey: cfg.GetString(CfgAccessKey),
+		SecretKey: cfg.GetString(CfgSecretKey),
+		Token:     cfg.GetString(CfgToken),
+		Mount:     cfg.GetString(CfgMount),
+	}
+}
+
+// NewProvider returns the provider of the configuration, or nil if the KMS is not configured.
+func NewProvider(cfg *config.Config) (p Provider, err error) {
+	c := ParseConfig(cfg)
+	if c == nil {
+		return nil, nil
+	}
+	return c.NewProvider()
+}
+
+// NewProvider returns the provider of the configuration.
+func (c *Config) NewProvider() (p Provider, err error) {
+	endpoint := strings.TrimSuffix(c.Endpoint, "/")
+	switch c.Provider {
+	case "", ProviderAWS:
+		if (c.AccessKey == "") != (c.SecretKey == "") || (c.AccessKey != "" && c.Region == "") {
+			return nil, fmt.Errorf("KMS %v needs both the credentials and the region to sign the requests", ProviderAWS)
+		}
+		return &awsProvider{endpoint: endpoint, keyId: c.KeyId, region: c.Region, accessKey: c.AccessKey,
+			secretKey: c.SecretKey, client: newHTTPClient()}, nil
+	case ProviderVault:
+		if c.Token == "" || c.KeyId == "" {
+			return nil, fmt.Errorf("KMS %v needs the token and the key", ProviderVault)
+		}
+		mount := strings.Trim(c.Mount, "/")
+		if mount == "" {
+			mount = "transit"
+		}
+		return &vaultProvider{endpoint: endpoint, keyId: c.KeyId, mount: mount, token: c.Token, client: newHTTPClient()}, nil
+	case ProviderHTTP:
+		return &httpProvider{endpoint: endpoint, keyId: c.KeyId, token: c.Token, client: newHTTPClient()}, nil
+	}
+	return nil, fmt.Errorf("unknown KMS provider [%v]", c.Provider)
+}
+
+// DecodeKey returns the key of the config, which is either the key in base64,
+// or the key wrapped by the KMS in base64 with the WrappedKeyPrefix.
+func DecodeKey(p Provider, value string) (key []byte, err error) {
+	if !strings.HasPrefix(value, WrappedKeyPrefix) {
+		return base64.StdEncoding.DecodeString(value)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("key wrapped by KMS but no KMS is configured")
+	}
+	var wrapped []byte
+	if wrapped, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(value, WrappedKeyPrefix)); err != nil {
+		return
+	}
+	return p.Decrypt("", wrapped)
+}
+
+// EncodeKey wraps the key by the KMS in the form of the config accepted by DecodeKey.
+func EncodeKey(p Provider, keyId string, key []byte) (value string, err error) {
+	var wrapped []byte
+	if _, wrapped, err = p.Encrypt(keyId, key); err != nil {
+		return
+	}
+	return WrappedKeyPrefix + base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
+
+// doJSON posts the request in JSON and decodes the response if succeeded, or returns the body as the error.
+func doJSON(client *http.Client, req *http.Request, response interface{}) (err error) {
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize)); err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status(%v) body(%s)", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return json.Unmarshal(body, response)
+}
+
+func checkDataKey(plaintext []byte) error {
+	if len(plaintext) != DataKeySize {
+		return fmt.Errorf("invalid data key size %v from KMS", len(plaintext))
+	}
+	return nil
+}
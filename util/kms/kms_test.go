@@ -0,0 +1,188 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+// xorWrap is the wrapping of the mock KMS servers.
+func xorWrap(key string, data []byte) []byte {
+	wrapped := make([]byte, len(data))
+	for i := range data {
+		wrapped[i] = data[i] ^ key[i%len(key)]
+	}
+	return wrapped
+}
+
+func testProvider(t *testing.T, p Provider, keyId string) {
+	usedKeyId, plaintext, wrapped, err := p.GenerateDataKey("")
+	if err != nil || usedKeyId != keyId || len(plaintext) != DataKeySize {
+		t.Fatalf("generate data key fail: keyId(%v) size(%v) err(%v)", usedKeyId, len(plaintext), err)
+	}
+	unwrapped, err := p.Decrypt("", wrapped)
+	if err != nil || !bytes.Equal(unwrapped, plaintext) {
+		t.Fatalf("decrypt data key fail: err(%v)", err)
+	}
+	value, err := EncodeKey(p, "", []byte("config key"))
+	if err != nil || !strings.HasPrefix(value, WrappedKeyPrefix) {
+		t.Fatalf("encode key fail: value(%v) err(%v)", value, err)
+	}
+	if key, err := DecodeKey(p, value); err != nil || string(key) != "config key" {
+		t.Fatalf("decode key fail: key(%s) err(%v)", key, err)
+	}
+	if _, err = p.Decrypt("", []byte("bad")); err == nil {
+		t.Fatalf("decrypt bad key should fail")
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), awsSignAlgorithm+" Credential=ak/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req struct {
+			KeyId          string
+			Plaintext      []byte
+			CiphertextBlob []byte
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GenerateDataKey":
+			plaintext := bytes.Repeat([]byte{1}, DataKeySize)
+			_ = json.NewEncoder(w).Encode(&awsGenerateDataKeyResponse{KeyId: req.KeyId, Plaintext: plaintext,
+				CiphertextBlob: append([]byte("aws"), xorWrap(req.KeyId, plaintext)...)})
+		case "TrentService.Encrypt":
+			_ = json.NewEncoder(w).Encode(&awsEncryptResponse{KeyId: req.KeyId,
+				CiphertextBlob: append([]byte("aws"), xorWrap(req.KeyId, req.Plaintext)...)})
+		case "TrentService.Decrypt":
+			if !bytes.HasPrefix(req.CiphertextBlob, []byte("aws")) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(&awsDecryptResponse{Plaintext: xorWrap("alias/cfs", req.CiphertextBlob[3:])})
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.LoadConfigString(`{"kmsEndpoint": "` + server.URL + `", "kmsKeyId": "alias/cfs", "kmsAccessKey": "ak"}`)
+	if _, err := NewProvider(cfg); err == nil {
+		t.Fatalf("credentials without secret key and region should be refused")
+	}
+	cfg = config.LoadConfigString(`{"kmsEndpoint": "` + server.URL + `", "kmsKeyId": "alias/cfs",
+		"kmsAccessKey": "ak", "kmsSecretKey": "sk", "kmsRegion": "us-east-1"}`)
+	p, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("new provider fail: err(%v)", err)
+	}
+	testProvider(t, p, "alias/cfs")
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(vaultTokenHeader) != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Plaintext  string `json:"plaintext"`
+			Ciphertext string `json:"ciphertext"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		var resp vaultResponse
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/cfs":
+			plaintext := bytes.Repeat([]byte{2}, DataKeySize)
+			resp.Data.Plaintext = base64.StdEncoding.EncodeToString(plaintext)
+			resp.Data.Ciphertext = "vault:v1:" + base64.StdEncoding.EncodeToString(xorWrap("cfs", plaintext))
+		case "/v1/transit/encrypt/cfs":
+			plaintext, _ := base64.StdEncoding.DecodeString(req.Plaintext)
+			resp.Data.Ciphertext = "vault:v1:" + base64.StdEncoding.EncodeToString(xorWrap("cfs", plaintext))
+		case "/v1/transit/decrypt/cfs":
+			if !strings.HasPrefix(req.Ciphertext, "vault:v1:") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			wrapped, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(req.Ciphertext, "vault:v1:"))
+			resp.Data.Plaintext = base64.StdEncoding.EncodeToString(xorWrap("cfs", wrapped))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&resp)
+	}))
+	defer server.Close()
+
+	if _, err := NewProvider(config.LoadConfigString(`{"kmsEndpoint": "` + server.URL + `", "kmsProvider": "vault"}`)); err == nil {
+		t.Fatalf("vault without token should be refused")
+	}
+	cfg := config.LoadConfigString(`{"kmsEndpoint": "` + server.URL + `/", "kmsProvider": "vault",
+		"kmsKeyId": "cfs", "kmsToken": "token"}`)
+	p, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("new provider fail: err(%v)", err)
+	}
+	testProvider(t, p, "cfs")
+}
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req httpKMSMessage
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		resp := httpKMSMessage{KeyId: req.KeyId}
+		switch r.URL.Path {
+		case httpPathGenerateDataKey:
+			resp.Plaintext = bytes.Repeat([]byte{3}, DataKeySize)
+			resp.Ciphertext = append([]byte("http"), xorWrap(req.KeyId, resp.Plaintext)...)
+		case httpPathEncrypt:
+			resp.Ciphertext = append([]byte("http"), xorWrap(req.KeyId, req.Plaintext)...)
+		case httpPathDecrypt:
+			if !bytes.HasPrefix(req.Ciphertext, []byte("http")) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			resp.Plaintext = xorWrap(req.KeyId, req.Ciphertext[4:])
+		}
+		_ = json.NewEncoder(w).Encode(&resp)
+	}))
+	defer server.Close()
+
+	p, err := NewProvider(config.LoadConfigString(`{"kmsEndpoint": "` + server.URL + `", "kmsProvider": "http", "kmsKeyId": "k1"}`))
+	if err != nil {
+		t.Fatalf("new provider fail: err(%v)", err)
+	}
+	testProvider(t, p, "k1")
+}
+
+func TestDecodeKey(t *testing.T) {
+	if p, err := NewProvider(config.LoadConfigString(`{}`)); p != nil || err != nil {
+		t.Fatalf("KMS should not be configured: err(%v)", err)
+	}
+	if key, err := DecodeKey(nil, base64.StdEncoding.EncodeToString([]byte("plain"))); err != nil || string(key) != "plain" {
+		t.Fatalf("decode plain key fail: key(%s) err(%v)", key, err)
+	}
+	if _, err := DecodeKey(nil, WrappedKeyPrefix+"AAAA"); err == nil {
+		t.Fatalf("wrapped key without KMS should fail")
+	}
+}
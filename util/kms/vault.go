@@ -0,0 +1,104 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	vaultTokenHeader = "X-Vault-Token"
+	vaultDataKeyBits = DataKeySize * 8
+)
+
+// vaultProvider requests the transit secrets engine of the Vault. The wrapped keys are the ciphertexts
+// of the Vault, such as "vault:v1:...", which carry the version of the key of the Vault.
+type vaultProvider struct {
+	endpoint string
+	keyId    string
+	mount    string
+	token    string
+	client   *http.Client
+}
+
+type vaultResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+}
+
+func (p *vaultProvider) key(keyId string) string {
+	if keyId == "" {
+		return p.keyId
+	}
+	return keyId
+}
+
+func (p *vaultProvider) GenerateDataKey(keyId string) (usedKeyId string, plaintext, wrapped []byte, err error) {
+	usedKeyId = p.key(keyId)
+	var resp vaultResponse
+	if err = p.call("datakey/plaintext", usedKeyId, map[string]interface{}{"bits": vaultDataKeyBits}, &resp); err != nil {
+		return
+	}
+	if plaintext, err = base64.StdEncoding.DecodeString(resp.Data.Plaintext); err != nil {
+		return
+	}
+	if err = checkDataKey(plaintext); err != nil {
+		return
+	}
+	return usedKeyId, plaintext, []byte(resp.Data.Ciphertext), nil
+}
+
+func (p *vaultProvider) Encrypt(keyId string, plaintext []byte) (usedKeyId string, wrapped []byte, err error) {
+	usedKeyId = p.key(keyId)
+	var resp vaultResponse
+	req := map[string]interface{}{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err = p.call("encrypt", usedKeyId, req, &resp); err != nil {
+		return
+	}
+	return usedKeyId, []byte(resp.Data.Ciphertext), nil
+}
+
+func (p *vaultProvider) Decrypt(keyId string, wrapped []byte) (plaintext []byte, err error) {
+	var resp vaultResponse
+	if err = p.call("decrypt", p.key(keyId), map[string]interface{}{"ciphertext": string(wrapped)}, &resp); err != nil {
+		return
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+func (p *vaultProvider) call(action, keyId string, request, response interface{}) (err error) {
+	var body []byte
+	if body, err = json.Marshal(request); err != nil {
+		return
+	}
+	path := fmt.Sprintf("%v/v1/%v/%v/%v", p.endpoint, p.mount, action, url.PathEscape(keyId))
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, path, bytes.NewReader(body)); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(vaultTokenHeader, p.token)
+	if err = doJSON(p.client, req, response); err != nil {
+		return fmt.Errorf("Vault %v fail: %v", action, err)
+	}
+	return
+}
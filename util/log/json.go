@@ -0,0 +1,108 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	jsonTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// The fields of the JSON log other than the message are stable, so that the logs can be indexed without parsing the message.
+// The partition, the volume and the request ID are picked from the message, where they are logged in the form of
+// "partitionID(1)" or "vol[ltptest]" by convention.
+type jsonEntry struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Module    string `json:"module"`
+	Caller    string `json:"caller"`
+	Partition string `json:"partition,omitempty"`
+	Vol       string `json:"vol,omitempty"`
+	ReqID     string `json:"reqID,omitempty"`
+	Msg       string `json:"msg"`
+}
+
+var (
+	partitionPattern = fieldPattern("partitionID", "partition", "mpID", "dpID")
+	volPattern       = fieldPattern("vol", "volume", "volName")
+	reqIDPattern     = fieldPattern("reqID", "requestID")
+)
+
+func fieldPattern(names ...string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(names, "|") + `)[(\[]([^()\[\]\s,]+)[)\]]`)
+}
+
+func findField(pattern *regexp.Regexp, msg string) string {
+	if match := pattern.FindStringSubmatch(msg); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// SetFormat sets the format of the log, which is either FormatText or FormatJSON.
+func SetFormat(format string) error {
+	var flag int
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		format = FormatText
+		flag = log.LstdFlags | log.Lmicroseconds
+	case FormatJSON:
+		format = FormatJSON
+	default:
+		return fmt.Errorf("log format only can be set: %v,%v", FormatText, FormatJSON)
+	}
+	logFormat = format
+	if gLog != nil {
+		for _, logger := range gLog.loggers() {
+			logger.SetFlags(flag)
+		}
+	}
+	return nil
+}
+
+// GetFormat returns the format of the log.
+func GetFormat() string {
+	return logFormat
+}
+
+// formatJSON returns the log line in JSON, where the level is the prefix of the level such as "[INFO ]".
+func (l *Log) formatJSON(msg, level, caller string) string {
+	msg = strings.TrimSuffix(msg, "\n")
+	entry := &jsonEntry{
+		Time:      time.Now().Format(jsonTimeFormat),
+		Level:     strings.ToLower(strings.Trim(level, "[] ")),
+		Module:    l.module,
+		Caller:    caller,
+		Partition: findField(partitionPattern, msg),
+		Vol:       findField(volPattern, msg),
+		ReqID:     findField(reqIDPattern, msg),
+		Msg:       msg,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return msg
+	}
+	return string(data)
+}
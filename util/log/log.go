@@ -54,6 +54,7 @@ const (
 	WriterBufferLenLimit   = 4 * 1024 * 1024
 	DefaultRollingInterval = 1 * time.Second
 	RolledExtension        = ".old"
+	CompressedExtension    = ".gz"
 	MaxReservedDays        = 7 * 24 * time.Hour
 )
 
@@ -87,6 +88,7 @@ type asyncWriter struct {
 	fileName    string
 	logSize     int64
 	rollingSize int64
+	compress    bool
 	buffer      *bytes.Buffer
 	flushTmp    *bytes.Buffer
 	flushC      chan bool
@@ -164,6 +166,13 @@ func (writer *asyncWriter) flushToFile() {
 					writer.file = fp
 					writer.logSize = 0
 					_ = os.Chmod(writer.fileName, 0666)
+					if writer.compress {
+						go func() {
+							if err := compressLogFile(oldFile); err != nil {
+								LogErrorf("compress log file %s: %s", oldFile, err.Error())
+							}
+						}()
+					}
 				}
 			}
 		}
@@ -181,7 +190,7 @@ func (writer *asyncWriter) rename(newName string) error {
 	return nil
 }
 
-func newAsyncWriter(fileName string, rollingSize int64) (*asyncWriter, error) {
+func newAsyncWriter(fileName string, rollingSize int64, compress bool) (*asyncWriter, error) {
 	fp, err := os.OpenFile(fileName, FileOpt, 0666)
 	if err != nil {
 		return nil, err
@@ -195,6 +204,7 @@ func newAsyncWriter(fileName string, rollingSize int64) (*asyncWriter, error) {
 		file:        fp,
 		fileName:    fileName,
 		rollingSize: rollingSize,
+		compress:    compress,
 		logSize:     fInfo.Size(),
 		buffer:      bytes.NewBuffer(make([]byte, 0, WriterBufferInitSize)),
 		flushTmp:    bytes.NewBuffer(make([]byte, 0, WriterBufferInitSize)),
@@ -232,6 +242,7 @@ func newLogObject(writer *asyncWriter, prefix string, flag int) *LogObject {
 // Log defines the log struct.
 type Log struct {
 	dir            string
+	module         string
 	errorLogger    *LogObject
 	warnLogger     *LogObject
 	debugLogger    *LogObject
@@ -257,6 +268,8 @@ var (
 
 var gLog *Log = nil
 
+var logFormat = FormatText
+
 var LogDir string
 
 // InitLog initializes the log.
@@ -264,6 +277,7 @@ func InitLog(dir, module string, level Level, rotate *LogRotate) (*Log, error) {
 	l := new(Log)
 	dir = path.Join(dir, module)
 	l.dir = dir
+	l.module = module
 	LogDir = dir
 	fi, err := os.Stat(dir)
 	if err != nil {
@@ -275,25 +289,38 @@ func InitLog(dir, module string, level Level, rotate *LogRotate) (*Log, error) {
 	}
 	_ = os.Chmod(dir, 0766)
 	if rotate == nil {
-		rotate = NewLogRotate()
+		rotate = new(LogRotate)
+	}
+	// the rolling size and the headroom not specified are derived from the disk
+	if rotate.rollingSize <= 0 || rotate.headRoom <= 0 {
 		fs := syscall.Statfs_t{}
 		if err := syscall.Statfs(dir, &fs); err != nil {
 			return nil, fmt.Errorf("[InitLog] stats disk space: %s",
 				err.Error())
 		}
-		var minRatio float64
-		if float64(fs.Bavail*uint64(fs.Bsize)) < float64(fs.Blocks*uint64(fs.Bsize))*DefaultHeadRatio {
-			minRatio = float64(fs.Bavail*uint64(fs.Bsize)) * DefaultHeadRatio / 1024 / 1024
-		} else {
-			minRatio = float64(fs.Blocks*uint64(fs.Bsize)) * DefaultHeadRatio / 1024 / 1024
+		if rotate.headRoom <= 0 {
+			var minRatio float64
+			if float64(fs.Bavail*uint64(fs.Bsize)) < float64(fs.Blocks*uint64(fs.Bsize))*DefaultHeadRatio {
+				minRatio = float64(fs.Bavail*uint64(fs.Bsize)) * DefaultHeadRatio / 1024 / 1024
+			} else {
+				minRatio = float64(fs.Blocks*uint64(fs.Bsize)) * DefaultHeadRatio / 1024 / 1024
+			}
+			rotate.SetHeadRoomMb(int64(math.Min(minRatio, DefaultHeadRoom)))
 		}
-		rotate.SetHeadRoomMb(int64(math.Min(minRatio, DefaultHeadRoom)))
-
-		minRollingSize := int64(fs.Bavail * uint64(fs.Bsize) / uint64(len(levelPrefixes)))
-		if minRollingSize < DefaultMinRollingSize {
-			minRollingSize = DefaultMinRollingSize
+		if rotate.rollingSize <= 0 {
+			minRollingSize := int64(fs.Bavail * uint64(fs.Bsize) / uint64(len(levelPrefixes)))
+			if minRollingSize < DefaultMinRollingSize {
+				minRollingSize = DefaultMinRollingSize
+			}
+			// a log file is rotated before it alone takes up the disk usage of all the log files
+			if rotate.maxDiskUsage > 0 && minRollingSize > rotate.maxDiskUsage/int64(len(levelPrefixes)) {
+				minRollingSize = rotate.maxDiskUsage / int64(len(levelPrefixes))
+			}
+			rotate.SetRollingSizeMb(int64(math.Min(float64(minRollingSize), float64(DefaultRollingSize))))
 		}
-		rotate.SetRollingSizeMb(int64(math.Min(float64(minRollingSize), float64(DefaultRollingSize))))
+	}
+	if rotate.retention <= 0 {
+		rotate.retention = MaxReservedDays
 	}
 	l.rotate = rotate
 	err = l.initLog(dir, module, level)
@@ -326,10 +353,13 @@ func OutputPid(logDir, role string) error {
 
 func (l *Log) initLog(logDir, module string, level Level) error {
 	logOpt := log.LstdFlags | log.Lmicroseconds
+	if logFormat == FormatJSON {
+		logOpt = 0
+	}
 
 	newLog := func(logFileName string) (newLogger *LogObject, err error) {
 		logName := path.Join(logDir, module+logFileName)
-		w, err := newAsyncWriter(logName, l.rotate.rollingSize)
+		w, err := newAsyncWriter(logName, l.rotate.rollingSize, l.rotate.compress)
 		if err != nil {
 			return
 		}
@@ -362,12 +392,15 @@ func (l *Log) SetPrefix(s, level string) string {
 		}
 	}
 	file = short
+	if logFormat == FormatJSON {
+		return l.formatJSON(s, level, file+":"+strconv.Itoa(line))
+	}
 	return level + " " + file + ":" + strconv.Itoa(line) + ": " + s
 }
 
-// Flush flushes the log.
-func (l *Log) Flush() {
-	loggers := []*LogObject{
+func (l *Log) loggers() []*LogObject {
+	loggers := make([]*LogObject, 0, 7)
+	for _, logger := range []*LogObject{
 		l.debugLogger,
 		l.infoLogger,
 		l.warnLogger,
@@ -375,16 +408,24 @@ func (l *Log) Flush() {
 		l.readLogger,
 		l.updateLogger,
 		l.criticalLogger,
-	}
-	for _, logger := range loggers {
+	} {
 		if logger != nil {
-			logger.Flush()
+			loggers = append(loggers, logger)
 		}
 	}
+	return loggers
+}
+
+// Flush flushes the log.
+func (l *Log) Flush() {
+	for _, logger := range l.loggers() {
+		logger.Flush()
+	}
 }
 
 const (
 	SetLogLevelPath = "/loglevel/set"
+	FlushLogPath    = "/log/flush"
 )
 
 func SetLogLevel(w http.ResponseWriter, r *http.Request) {
@@ -395,7 +436,25 @@ func SetLogLevel(w http.ResponseWriter, r *http.Request) {
 		buildFailureResp(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	levelStr := r.FormValue("level")
+	if err = SetLevel(r.FormValue("level")); err != nil {
+		buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	buildSuccessResp(w, "set log level success")
+}
+
+// FlushLog flushes the buffered logs to the files, so that the logs can be read before the next flush.
+func FlushLog(w http.ResponseWriter, r *http.Request) {
+	if gLog == nil {
+		buildFailureResp(w, http.StatusInternalServerError, "log is not initialized")
+		return
+	}
+	gLog.Flush()
+	buildSuccessResp(w, "flush log success")
+}
+
+// SetLevel sets the level of the log by its name.
+func SetLevel(levelStr string) error {
 	var level Level
 	switch strings.ToLower(levelStr) {
 	case "debug":
@@ -411,12 +470,35 @@ func SetLogLevel(w http.ResponseWriter, r *http.Request) {
 	case "fatal":
 		level = FatalLevel
 	default:
-		err = fmt.Errorf("level only can be set :debug,info,warn,error,critical,read,write,fatal")
-		buildFailureResp(w, http.StatusBadRequest, err.Error())
-		return
+		return fmt.Errorf("level only can be set :debug,info,warn,error,critical,read,write,fatal")
 	}
-	gLog.level = Level(level)
-	buildSuccessResp(w, "set log level success")
+	if gLog == nil {
+		return fmt.Errorf("log is not initialized")
+	}
+	gLog.level = level
+	return nil
+}
+
+// GetLevel returns the name of the level of the log.
+func GetLevel() string {
+	if gLog == nil {
+		return ""
+	}
+	switch gLog.level {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	case CriticalLevel:
+		return "critical"
+	case FatalLevel:
+		return "fatal"
+	}
+	return fmt.Sprintf("%v", gLog.level)
 }
 
 func buildSuccessResp(w http.ResponseWriter, data interface{}) {
@@ -680,7 +762,7 @@ func (l *Log) checkLogRotation(logDir, module string) {
 		}
 		// check if it is time to rotate
 		now := time.Now()
-		if now.Day() == l.lastRolledTime.Day() {
+		if !l.rotate.isTimeToRotate(l.lastRolledTime, now) {
 			time.Sleep(DefaultRollingInterval)
 			continue
 		}
@@ -700,9 +782,9 @@ func (l *Log) checkLogRotation(logDir, module string) {
 
 func DeleteFileFilter(info os.FileInfo, diskSpaceLeft int64) bool {
 	if diskSpaceLeft <= 0 {
-		return info.Mode().IsRegular() && strings.HasSuffix(info.Name(), RolledExtension)
+		return info.Mode().IsRegular() && isRolledFile(info.Name())
 	}
-	return time.Since(info.ModTime()) > MaxReservedDays && strings.HasSuffix(info.Name(), RolledExtension)
+	return time.Since(info.ModTime()) > MaxReservedDays && isRolledFile(info.Name())
 }
 
 func (l *Log) removeLogFile(logDir string, diskSpaceLeft int64) (err error) {
@@ -712,23 +794,33 @@ func (l *Log) removeLogFile(logDir string, diskSpaceLeft int64) (err error) {
 		LogErrorf("error read log directory files: %s", err.Error())
 		return
 	}
-	var needDelFiles RolledFile
+	var (
+		rolledFiles RolledFile
+		totalSize   int64
+	)
 	for _, info := range fInfos {
-		if DeleteFileFilter(info, diskSpaceLeft) {
-			needDelFiles = append(needDelFiles, info)
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		totalSize += info.Size()
+		if isRolledFile(info.Name()) {
+			rolledFiles = append(rolledFiles, info)
 		}
 	}
-	sort.Sort(needDelFiles)
-	// delete old file
-	for _, info := range needDelFiles {
+	sort.Sort(rolledFiles)
+	// delete the oldest files until the disk has enough space, the log files are within the max disk usage
+	// and the rest files are within the retention
+	for _, info := range rolledFiles {
+		if diskSpaceLeft > 0 && (l.rotate.maxDiskUsage <= 0 || totalSize <= l.rotate.maxDiskUsage) &&
+			time.Since(info.ModTime()) <= l.rotate.retention {
+			break
+		}
 		if err = os.Remove(path.Join(logDir, info.Name())); err != nil {
 			LogErrorf("failed delete log file %s", info.Name())
 			continue
 		}
 		diskSpaceLeft += info.Size()
-		if diskSpaceLeft > 0 && time.Since(info.ModTime()) < MaxReservedDays {
-			break
-		}
+		totalSize -= info.Size()
 	}
 	err = nil
 	return
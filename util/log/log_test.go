@@ -17,12 +17,18 @@ package log
 // These tests are too simple.
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/cubefs/cubefs/util/config"
 )
 
 func TestLog(t *testing.T) {
@@ -78,6 +84,106 @@ func TestLog(t *testing.T) {
 	}
 }
 
+func TestJSONFormat(t *testing.T) {
+	if err := SetFormat("xml"); err == nil {
+		t.Fatalf("unknown log format should be refused")
+	}
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatalf("set log format fail: err(%v)", err)
+	}
+	defer SetFormat(FormatText)
+
+	l := &Log{module: "metaNode"}
+	line := l.SetPrefix("action[test] partitionID(12) vol[ltptest] requestID(abc-1) done\n", levelPrefixes[2])
+	var entry jsonEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("log line is not JSON: line(%v) err(%v)", line, err)
+	}
+	if entry.Level != "warn" || entry.Module != "metaNode" || entry.Partition != "12" || entry.Vol != "ltptest" ||
+		entry.ReqID != "abc-1" || entry.Msg != "action[test] partitionID(12) vol[ltptest] requestID(abc-1) done" {
+		t.Fatalf("unexpected log entry: %+v", entry)
+	}
+	if entry.Time == "" || entry.Caller == "" {
+		t.Fatalf("time and caller are missing: %+v", entry)
+	}
+}
+
+func TestLogRotate(t *testing.T) {
+	if _, err := ParseLogRotate(config.LoadConfigString(`{"logRotateInterval": "1s"}`)); err == nil {
+		t.Fatalf("rotate interval less than 1m should be refused")
+	}
+	r, err := ParseLogRotate(config.LoadConfigString(`{"logRotateSize": "100", "logRotateInterval": "1h",
+		"logCompress": true, "logRetainDays": 3, "logMaxDiskUsage": 1}`))
+	if err != nil {
+		t.Fatalf("parse log rotate fail: err(%v)", err)
+	}
+	if r.rollingSize != 100*1024*1024 || r.rotateInterval != time.Hour || !r.compress ||
+		r.retention != 3*24*time.Hour || r.maxDiskUsage != 1024*1024 {
+		t.Fatalf("unexpected log rotate: %+v", r)
+	}
+	now := time.Now()
+	if r.isTimeToRotate(now.Add(-time.Minute), now) || !r.isTimeToRotate(now.Add(-time.Hour), now) {
+		t.Fatalf("log should be rotated hourly")
+	}
+
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatalf("create dir fail: err(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	rolled := path.Join(dir, "cfs_info.log.20200101000000"+RolledExtension)
+	content := strings.Repeat("rotated log\n", 64*1024)
+	if err = ioutil.WriteFile(rolled, []byte(content), 0666); err != nil {
+		t.Fatalf("write file fail: err(%v)", err)
+	}
+	_ = os.Chtimes(rolled, now.Add(-90*time.Minute), now.Add(-90*time.Minute))
+	if err = compressLogFile(rolled); err != nil {
+		t.Fatalf("compress log file fail: err(%v)", err)
+	}
+	if _, err = os.Stat(rolled); !os.IsNotExist(err) {
+		t.Fatalf("rotated file should be removed after compressed: err(%v)", err)
+	}
+	fp, err := os.Open(rolled + CompressedExtension)
+	if err != nil {
+		t.Fatalf("open compressed file fail: err(%v)", err)
+	}
+	zr, err := gzip.NewReader(fp)
+	if err != nil {
+		t.Fatalf("read compressed file fail: err(%v)", err)
+	}
+	if data, err := ioutil.ReadAll(zr); err != nil || string(data) != content {
+		t.Fatalf("compressed file mismatch: err(%v)", err)
+	}
+	fp.Close()
+
+	// the oldest rotated file is removed beyond the max disk usage, and the active log is kept
+	older := path.Join(dir, "cfs_info.log.20191231000000"+RolledExtension)
+	if err = ioutil.WriteFile(older, make([]byte, 1024*1024), 0666); err != nil {
+		t.Fatalf("write file fail: err(%v)", err)
+	}
+	_ = os.Chtimes(older, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	active := path.Join(dir, "cfs_info.log")
+	if err = ioutil.WriteFile(active, []byte("active log"), 0666); err != nil {
+		t.Fatalf("write file fail: err(%v)", err)
+	}
+	l := &Log{rotate: r}
+	if err = l.removeLogFile(dir, 1); err != nil {
+		t.Fatalf("remove log file fail: err(%v)", err)
+	}
+	for name, exist := range map[string]bool{older: false, rolled + CompressedExtension: true, active: true} {
+		if _, err = os.Stat(name); exist != (err == nil) {
+			t.Fatalf("file %v expect exist(%v) but err is [%v]", name, exist, err)
+		}
+	}
+	r.SetRetention(time.Hour)
+	if err = l.removeLogFile(dir, 1); err != nil {
+		t.Fatalf("remove log file fail: err(%v)", err)
+	}
+	if _, err = os.Stat(rolled + CompressedExtension); !os.IsNotExist(err) {
+		t.Fatalf("rotated file beyond the retention should be removed: err(%v)", err)
+	}
+}
+
 // create file and modify modTime to 7 days ago
 func createFile(logFilePath string, modTime bool) (err error) {
 	_, err = os.Create(logFilePath)
@@ -14,6 +14,40 @@
 
 package log
 
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+// configuration keys of the rotation of the logs
+const (
+	// the size in MB a log file is rotated at, which is derived from the disk if not specified
+	CfgRotateSize = "logRotateSize"
+	// the interval a log file is rotated at, such as "1h", which is daily if not specified
+	CfgRotateInterval = "logRotateInterval"
+	// whether the rotated log files are compressed by gzip
+	CfgCompress = "logCompress"
+	// the days the rotated log files are kept, MaxReservedDays if not specified
+	CfgRetainDays = "logRetainDays"
+	// the size in MB of all the log files of the module, the oldest rotated files are removed beyond it
+	CfgMaxDiskUsage = "logMaxDiskUsage"
+)
+
+// ConfigSchema declares the configuration keys of the rotation of the logs.
+var ConfigSchema = config.Schema{
+	CfgRotateSize:     config.TypeInt,
+	CfgRotateInterval: config.TypeString,
+	CfgCompress:       config.TypeBool,
+	CfgRetainDays:     config.TypeInt,
+	CfgMaxDiskUsage:   config.TypeInt,
+}
+
 const (
 	// DefaultRollingSize Specifies at what size to roll the output log at
 	// Units: byte
@@ -27,8 +61,12 @@ const (
 
 // A log can be rotated by the size or time.
 type LogRotate struct {
-	rollingSize int64 // the size of the rotated log // TODO we should either call rotate or rolling, but not both.
-	headRoom    int64 // capacity reserved for writing the next log on the disk
+	rollingSize    int64         // the size of the rotated log // TODO we should either call rotate or rolling, but not both.
+	headRoom       int64         // capacity reserved for writing the next log on the disk
+	rotateInterval time.Duration // the log is rotated daily if it is 0
+	compress       bool          // the rotated files are compressed by gzip
+	retention      time.Duration // how long the rotated files are kept
+	maxDiskUsage   int64         // the total size of the log files in bytes, 0 for unlimited
 }
 
 // NewLogRotate returns a new LogRotate instance.
@@ -48,3 +86,92 @@ func (r *LogRotate) SetRollingSizeMb(size int64) {
 func (r *LogRotate) SetHeadRoomMb(size int64) {
 	r.headRoom = size
 }
+
+// SetRotateInterval sets the interval the log is rotated at, the log is rotated daily if it is 0.
+func (r *LogRotate) SetRotateInterval(interval time.Duration) {
+	r.rotateInterval = interval
+}
+
+// SetCompress sets whether the rotated files are compressed by gzip.
+func (r *LogRotate) SetCompress(compress bool) {
+	r.compress = compress
+}
+
+// SetRetention sets how long the rotated files are kept.
+func (r *LogRotate) SetRetention(retention time.Duration) {
+	r.retention = retention
+}
+
+// SetMaxDiskUsageMb sets the total size of the log files in terms of MB, 0 for unlimited.
+func (r *LogRotate) SetMaxDiskUsageMb(size int64) {
+	r.maxDiskUsage = size * 1024 * 1024
+}
+
+// ParseLogRotate returns the rotation of the config, where the rolling size and the headroom not
+// specified are derived from the disk by InitLog.
+func ParseLogRotate(cfg *config.Config) (r *LogRotate, err error) {
+	r = new(LogRotate)
+	if size := cfg.GetInt64(CfgRotateSize); size > 0 {
+		r.rollingSize = size * 1024 * 1024
+	}
+	if value := cfg.GetString(CfgRotateInterval); value != "" {
+		if r.rotateInterval, err = time.ParseDuration(value); err != nil || r.rotateInterval < time.Minute {
+			return nil, fmt.Errorf("invalid %v [%v], which is at least 1m", CfgRotateInterval, value)
+		}
+	}
+	r.compress = cfg.GetBool(CfgCompress)
+	if days := cfg.GetInt64(CfgRetainDays); days > 0 {
+		r.retention = time.Duration(days) * 24 * time.Hour
+	}
+	if size := cfg.GetInt64(CfgMaxDiskUsage); size > 0 {
+		r.SetMaxDiskUsageMb(size)
+	}
+	return
+}
+
+// isTimeToRotate returns if the log rolled at the last time is to be rotated now.
+func (r *LogRotate) isTimeToRotate(last, now time.Time) bool {
+	if r.rotateInterval <= 0 {
+		return now.Day() != last.Day()
+	}
+	return now.Sub(last) >= r.rotateInterval
+}
+
+// isRolledFile returns if the file is a rotated log file, which may be compressed.
+func isRolledFile(name string) bool {
+	return strings.HasSuffix(name, RolledExtension) || strings.HasSuffix(name, RolledExtension+CompressedExtension)
+}
+
+// compressLogFile compresses the rotated log file by gzip and removes it. The compressed file keeps the
+// modification time of the original one, so that it is removed after the same retention.
+func compressLogFile(fileName string) (err error) {
+	var (
+		src  *os.File
+		dst  *os.File
+		info os.FileInfo
+	)
+	if src, err = os.Open(fileName); err != nil {
+		return
+	}
+	defer src.Close()
+	if info, err = src.Stat(); err != nil {
+		return
+	}
+	gzName := fileName + CompressedExtension
+	if dst, err = os.OpenFile(gzName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666); err != nil {
+		return
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(gzName)
+		return
+	}
+	_ = os.Chtimes(gzName, info.ModTime(), info.ModTime())
+	return os.Remove(fileName)
+}
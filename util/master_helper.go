@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/tlsutil"
 )
 
 const (
@@ -90,7 +91,7 @@ func (helper *masterHelper) request(method, path string, param, header map[strin
 			host = nodes[i]
 		}
 		var resp *http.Response
-		resp, err = helper.httpRequest(method, fmt.Sprintf("http://%s%s", host,
+		resp, err = helper.httpRequest(method, fmt.Sprintf("%s://%s%s", tlsutil.HTTPScheme(), host,
 			path), param, header, reqData)
 		if err != nil {
 			log.LogErrorf("[masterHelper] %s", err)
@@ -161,7 +162,7 @@ func (helper *masterHelper) prepareRequest() (addr string, nodes []string) {
 }
 
 func (helper *masterHelper) httpRequest(method, url string, param, header map[string]string, reqData []byte) (resp *http.Response, err error) {
-	client := &http.Client{}
+	client := &http.Client{Transport: tlsutil.NewTransport(&http.Transport{Proxy: http.ProxyFromEnvironment})}
 	reader := bytes.NewReader(reqData)
 	client.Timeout = requestTimeout
 	var req *http.Request
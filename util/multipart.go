@@ -16,8 +16,10 @@ package util
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -100,3 +102,10 @@ func CreateMultipartID(mpId uint64) MultipartID {
 	}
 	return MultipartID(multipartId)
 }
+
+// CreateObjectVersionID returns a version id of an object, made of the reversed creation
+// time, so that the newer versions of an object sort first, and a multipart id carrying
+// the meta partition id.
+func CreateObjectVersionID(mpId uint64) string {
+	return fmt.Sprintf("%016x%s", uint64(math.MaxInt64-time.Now().UnixNano()), CreateMultipartID(mpId))
+}
@@ -0,0 +1,240 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ratelimit provides the rate limits of the cluster shared by the nodes. The limits are set on the
+// master, which allocates their rates to the nodes as the budgets by the demands the nodes report, and the
+// nodes consume the budgets locally without asking the master for each request. The features limiting the
+// rates across the nodes, such as the QPS of the access keys of the ObjectNodes and the bandwidth of the
+// volumes of the clients, share the mechanism by the keys of their own scopes.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+// the scopes of the keys of the limits
+const (
+	ScopeS3QPS         = "s3qps"       // requests per second of an access key of the ObjectNodes
+	ScopeS3Bandwidth   = "s3bandwidth" // bytes per second uploaded and downloaded by an access key of the ObjectNodes
+	ScopeVolReadBytes  = "volread"     // bytes per second read from a volume by the clients
+	ScopeVolWriteBytes = "volwrite"    // bytes per second written to a volume by the clients
+)
+
+const (
+	DefaultInterval = 5 * time.Second
+
+	keySeparator  = ":"
+	idleIntervals = 12 // the keys not requested for the intervals are forgotten
+)
+
+// Key returns the key of the limit of the subject in the scope, such as "s3qps:<access key>".
+func Key(scope, subject string) string {
+	return scope + keySeparator + subject
+}
+
+// Allocator allocates the budgets of the limits to the node, which is the master.
+type Allocator interface {
+	AllocateRateLimits(req *proto.RateLimitAllocateRequest) (*proto.RateLimitAllocateResponse, error)
+}
+
+type bucket struct {
+	limiter   *rate.Limiter
+	requested int64 // tokens requested since the last allocation
+	idle      int
+}
+
+// setRate sets the budget allocated, whose burst is the tokens of a second.
+func (b *bucket) setRate(r float64) {
+	if r < 0 {
+		b.limiter.SetLimit(rate.Inf)
+		return
+	}
+	burst := int(math.Ceil(r))
+	if burst < 1 {
+		burst = 1
+	}
+	b.limiter.SetBurst(burst)
+	b.limiter.SetLimit(rate.Limit(r))
+}
+
+// Limiter limits the rates of the keys on the node by the budgets allocated by the master, so that the sum
+// of the rates of the nodes does not exceed the limit of the cluster. The demands of the keys requested on
+// the node are reported to the master every interval. A key is not limited until the master allocates its
+// budget, which is requested as soon as the key is requested first, and the last budget is kept if the master
+// is unavailable. All the methods accept the nil Limiter, which limits nothing.
+type Limiter struct {
+	node      string
+	allocator Allocator
+	mu        sync.RWMutex
+	buckets   map[string]*bucket
+	allocateC chan struct{}
+	stopC     chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewLimiter returns the limiter of the node, which is identified by the master by the name.
+func NewLimiter(node string, allocator Allocator) *Limiter {
+	l := &Limiter{
+		node:      node,
+		allocator: allocator,
+		buckets:   make(map[string]*bucket),
+		allocateC: make(chan struct{}, 1),
+		stopC:     make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Stop stops the allocations, after which the keys keep their last budgets.
+func (l *Limiter) Stop() {
+	if l == nil {
+		return
+	}
+	l.stopOnce.Do(func() {
+		close(l.stopC)
+	})
+}
+
+func (l *Limiter) bucket(key string) *bucket {
+	l.mu.RLock()
+	b := l.buckets[key]
+	l.mu.RUnlock()
+	if b != nil {
+		return b
+	}
+	l.mu.Lock()
+	if b = l.buckets[key]; b == nil {
+		b = &bucket{limiter: rate.NewLimiter(rate.Inf, 0)}
+		l.buckets[key] = b
+	}
+	l.mu.Unlock()
+	select {
+	case l.allocateC <- struct{}{}:
+	default:
+	}
+	return b
+}
+
+// Allow reports whether a token of the key is available now.
+func (l *Limiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n tokens of the key are available now, which are consumed if so.
+func (l *Limiter) AllowN(key string, n int) bool {
+	if l == nil {
+		return true
+	}
+	b := l.bucket(key)
+	atomic.AddInt64(&b.requested, int64(n))
+	return b.limiter.AllowN(time.Now(), n)
+}
+
+// WaitN blocks until n tokens of the key are available, which are taken by pieces no larger than the burst.
+func (l *Limiter) WaitN(ctx context.Context, key string, n int) (err error) {
+	if l == nil {
+		return
+	}
+	b := l.bucket(key)
+	atomic.AddInt64(&b.requested, int64(n))
+	for n > 0 {
+		m := n
+		if burst := b.limiter.Burst(); b.limiter.Limit() != rate.Inf && m > burst {
+			m = burst
+		}
+		if err = b.limiter.WaitN(ctx, m); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// the budget was changed concurrently, try again with the new burst
+			continue
+		}
+		n -= m
+	}
+	return nil
+}
+
+func (l *Limiter) run() {
+	interval := DefaultInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-l.stopC:
+			return
+		case <-timer.C:
+		case <-l.allocateC:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+		now := time.Now()
+		if next := l.allocate(now.Sub(last)); next > 0 {
+			interval = next
+		}
+		last = now
+		timer.Reset(interval)
+	}
+}
+
+// allocate reports the demands of the keys in the elapsed time, and returns the interval of the next
+// allocation replied by the master, which is 0 if the allocation fails. The demands of the allocations
+// requested by the new keys are measured in a second at least, so that a few requests are not taken for
+// a high rate.
+func (l *Limiter) allocate(elapsed time.Duration) time.Duration {
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	req := &proto.RateLimitAllocateRequest{Node: l.node}
+	l.mu.Lock()
+	for key, b := range l.buckets {
+		requested := atomic.SwapInt64(&b.requested, 0)
+		if requested > 0 {
+			b.idle = 0
+		} else if b.idle++; b.idle > idleIntervals {
+			delete(l.buckets, key)
+			continue
+		}
+		req.Demands = append(req.Demands, &proto.RateLimitDemand{Key: key, Demand: float64(requested) / elapsed.Seconds()})
+	}
+	l.mu.Unlock()
+	if len(req.Demands) == 0 {
+		return 0
+	}
+	resp, err := l.allocator.AllocateRateLimits(req)
+	if err != nil {
+		log.LogWarnf("action[allocateRateLimits] node(%v) keys(%v) err(%v)", l.node, len(req.Demands), err)
+		return 0
+	}
+	l.mu.RLock()
+	for _, budget := range resp.Budgets {
+		if b := l.buckets[budget.Key]; b != nil {
+			b.setRate(budget.Rate)
+		}
+	}
+	l.mu.RUnlock()
+	return time.Duration(resp.Interval) * time.Second
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"golang.org/x/time/rate"
+)
+
+type testAllocator struct {
+	sync.Mutex
+	limits  map[string]float64
+	demands map[string]float64
+}
+
+func (a *testAllocator) AllocateRateLimits(req *proto.RateLimitAllocateRequest) (*proto.RateLimitAllocateResponse, error) {
+	a.Lock()
+	defer a.Unlock()
+	resp := &proto.RateLimitAllocateResponse{Interval: 1}
+	for _, demand := range req.Demands {
+		a.demands[demand.Key] = demand.Demand
+		r, limited := a.limits[demand.Key]
+		if !limited {
+			r = -1
+		}
+		resp.Budgets = append(resp.Budgets, &proto.RateLimitBudget{Key: demand.Key, Rate: r})
+	}
+	return resp, nil
+}
+
+func TestLimiter(t *testing.T) {
+	var nilLimiter *Limiter
+	if !nilLimiter.Allow("any") || nilLimiter.WaitN(context.Background(), "any", 100) != nil {
+		t.Fatalf("nil limiter should limit nothing")
+	}
+	allocator := &testAllocator{limits: map[string]float64{Key(ScopeS3QPS, "ak"): 2}, demands: make(map[string]float64)}
+	l := NewLimiter("node1", allocator)
+	defer l.Stop()
+	limited, unlimited := Key(ScopeS3QPS, "ak"), Key(ScopeS3QPS, "other")
+	l.Allow(limited)
+	l.Allow(unlimited)
+	// the new keys request the allocation at once
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		l.mu.RLock()
+		allocated := l.buckets[limited].limiter.Limit() != rate.Inf
+		l.mu.RUnlock()
+		if allocated {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("allocation not requested")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.Allow(limited) {
+			allowed++
+		}
+	}
+	if allowed > 2 {
+		t.Fatalf("expect 2 requests allowed at most but got %v", allowed)
+	}
+	for i := 0; i < 100; i++ {
+		if !l.Allow(unlimited) {
+			t.Fatalf("unlimited key should be allowed")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := l.WaitN(ctx, limited, 10); err == nil {
+		t.Fatalf("wait should time out")
+	}
+}
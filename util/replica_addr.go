@@ -0,0 +1,84 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package util
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// ReplicaAddrs maps the service addresses of the nodes to the addresses on their dedicated
+// replication network. The nodes without a replication network are not in the table.
+type ReplicaAddrs struct {
+	table atomic.Value // *replicaAddrTable
+}
+
+type replicaAddrTable struct {
+	addrs map[string]string // service address -> replication address
+	ips   map[string]string // service ip -> replication ip
+}
+
+// NewReplicaAddrs returns an empty table of the replication addresses.
+func NewReplicaAddrs() *ReplicaAddrs {
+	r := new(ReplicaAddrs)
+	r.table.Store(&replicaAddrTable{})
+	return r
+}
+
+// Update replaces the table with the given replication addresses indexed by the service addresses.
+func (r *ReplicaAddrs) Update(addrs map[string]string) {
+	t := &replicaAddrTable{
+		addrs: make(map[string]string, len(addrs)),
+		ips:   make(map[string]string, len(addrs)),
+	}
+	for addr, replicaAddr := range addrs {
+		if replicaAddr == "" || replicaAddr == addr {
+			continue
+		}
+		t.addrs[addr] = replicaAddr
+		t.ips[hostOf(addr)] = hostOf(replicaAddr)
+	}
+	r.table.Store(t)
+}
+
+// Addr returns the replication address of the node, or the service address itself
+// if the node has no replication network.
+func (r *ReplicaAddrs) Addr(addr string) string {
+	if r == nil {
+		return addr
+	}
+	if replicaAddr, ok := r.table.Load().(*replicaAddrTable).addrs[addr]; ok {
+		return replicaAddr
+	}
+	return addr
+}
+
+// IP returns the replication ip of the node with the given service ip.
+func (r *ReplicaAddrs) IP(ip string) string {
+	if r == nil {
+		return ip
+	}
+	if replicaIP, ok := r.table.Load().(*replicaAddrTable).ips[ip]; ok {
+		return replicaIP
+	}
+	return ip
+}
+
+func hostOf(addr string) string {
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		return addr[:i]
+	}
+	return addr
+}
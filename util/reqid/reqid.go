@@ -0,0 +1,108 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package reqid provides the IDs correlating the logs, the audit records and the traces of a user operation
+// on the master, the meta nodes and the data nodes. The ID is generated at the entry of the operation, such as
+// the admin API, the S3 API or the read and the write of the SDK, and carried by the X-Request-Id header of
+// the HTTP requests and by the header of the packets, once the propagation is enabled on all the components.
+package reqid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+// configuration keys
+const (
+	CfgPropagation = "requestIDPropagation"
+)
+
+// ConfigSchema declares the configuration keys of the request IDs.
+var ConfigSchema = config.Schema{
+	CfgPropagation: config.TypeBool,
+}
+
+// Header is the HTTP header carrying the request ID.
+const Header = "X-Request-Id"
+
+// Size is the size of the request ID in bytes, which is carried by the packets.
+const Size = 16
+
+// ID is the ID of a user operation, and the zero ID is invalid.
+type ID [Size]byte
+
+// propagated tells whether the packets carry the request IDs, which the components of the old versions cannot read.
+var propagated bool
+
+// Init enables the propagation of the request IDs by the packets if it is configured.
+func Init(cfg *config.Config) {
+	propagated = cfg.GetBool(CfgPropagation)
+}
+
+// Propagated returns true if the packets carry the request IDs.
+func Propagated() bool {
+	return propagated
+}
+
+// New returns a random request ID.
+func New() (id ID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+// IsValid returns true if the ID is not zero.
+func (id ID) IsValid() bool {
+	return id != ID{}
+}
+
+// String returns the ID in hex, or the empty string if it is invalid.
+func (id ID) String() string {
+	if !id.IsValid() {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// Parse returns the ID in hex, or the invalid ID if the value is malformed.
+func Parse(value string) (id ID) {
+	if len(value) != 2*Size {
+		return
+	}
+	if _, err := hex.Decode(id[:], []byte(value)); err != nil {
+		return ID{}
+	}
+	return
+}
+
+// FromHeader returns the request ID of the HTTP header, or the invalid ID if there is none.
+func FromHeader(header http.Header) ID {
+	return Parse(header.Get(Header))
+}
+
+// Handler assigns the request ID to the request without a valid one before serving it by next,
+// which reads the ID by FromHeader, and replies the ID in the header of the response.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id = FromHeader(r.Header)
+		if !id.IsValid() {
+			id = New()
+			r.Header.Set(Header, id.String())
+		}
+		w.Header().Set(Header, id.String())
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package reqid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var id = New()
+	if !id.IsValid() || len(id.String()) != 2*Size {
+		t.Fatalf("unexpected new ID %v", id)
+	}
+	if Parse(id.String()) != id {
+		t.Fatalf("parse ID mismatch: %v", id)
+	}
+	for _, value := range []string{"", "abc", id.String() + "00", "zz" + id.String()[2:]} {
+		if Parse(value).IsValid() {
+			t.Errorf("value(%v) should be invalid", value)
+		}
+	}
+	if (ID{}).String() != "" {
+		t.Errorf("invalid ID should be empty")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var served ID
+	var h = Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = FromHeader(r.Header)
+	}))
+
+	// the request ID of the request is kept
+	var id = New()
+	var r = httptest.NewRequest("GET", "/admin/getCluster", nil)
+	r.Header.Set(Header, id.String())
+	var w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if served != id || w.Header().Get(Header) != id.String() {
+		t.Fatalf("request ID mismatch: served(%v) replied(%v) expect(%v)", served, w.Header().Get(Header), id)
+	}
+
+	// the request without a valid ID is assigned one
+	r = httptest.NewRequest("GET", "/admin/getCluster", nil)
+	r.Header.Set(Header, "invalid")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if !served.IsValid() || served == id || w.Header().Get(Header) != served.String() {
+		t.Fatalf("unexpected assigned request ID: served(%v) replied(%v)", served, w.Header().Get(Header))
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package util
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy defines how the requests of a client are retried on failures.
+type RetryPolicy struct {
+	Timeout      time.Duration // waiting for the reply of a single attempt
+	MaxRetries   int           // attempts after the first one
+	RetryTimeout time.Duration // the retries stop after it, 0 for no limit
+	Backoff      time.Duration // interval before the first retry
+	MaxBackoff   time.Duration // the interval doubles on every retry up to it
+	Jitter       float64       // fraction of the interval randomized, between 0 and 1
+	HedgeDelay   time.Duration // a read not replied in it is also sent to another replica, 0 disables
+}
+
+// Interval returns the time to sleep before the given retry, starting from 1.
+func (p *RetryPolicy) Interval(retry int) time.Duration {
+	interval := p.Backoff
+	for i := 1; i < retry && interval < p.MaxBackoff; i++ {
+		interval *= 2
+	}
+	if interval > p.MaxBackoff {
+		interval = p.MaxBackoff
+	}
+	if p.Jitter > 0 && interval > 0 {
+		jitter := time.Duration(float64(interval) * p.Jitter)
+		interval = interval - jitter + time.Duration(rand.Int63n(int64(2*jitter)+1))
+	}
+	return interval
+}
+
+// Expired returns if the retries started at the given time shall stop.
+func (p *RetryPolicy) Expired(start time.Time) bool {
+	return p.RetryTimeout > 0 && time.Since(start) > p.RetryTimeout
+}
+
+// Merge returns the policy with the zero fields of the given one taken from the default.
+func (p RetryPolicy) Merge(def RetryPolicy) RetryPolicy {
+	if p.Timeout <= 0 {
+		p.Timeout = def.Timeout
+	}
+	if p.MaxRetries <= 0 {
+		p.MaxRetries = def.MaxRetries
+	}
+	if p.RetryTimeout <= 0 {
+		p.RetryTimeout = def.RetryTimeout
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = def.Backoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = p.Backoff
+	}
+	if p.Jitter <= 0 {
+		p.Jitter = def.Jitter
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.HedgeDelay <= 0 {
+		p.HedgeDelay = def.HedgeDelay
+	}
+	return p
+}
@@ -0,0 +1,268 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package tlsutil provides the mutual TLS between the components of the cluster. Each component presents
+// the certificate issued by the CA of the cluster, and verifies the certificate of its peer against the CA,
+// on the admin APIs, the heartbeats and the data path. The certificates are renewed by replacing the files.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// configuration keys
+const (
+	CfgCertFile = "tlsCertFile"
+	CfgKeyFile  = "tlsKeyFile"
+	CfgCAFile   = "tlsCAFile"
+)
+
+// ConfigSchema declares the configuration keys of the TLS.
+var ConfigSchema = config.Schema{
+	CfgCertFile: config.TypeString,
+	CfgKeyFile:  config.TypeString,
+	CfgCAFile:   config.TypeString,
+}
+
+const (
+	handshakeTimeout = 10 * time.Second
+	expiryWarning    = 7 * 24 * time.Hour
+)
+
+// clusterConfig is the TLS config of the process, which is nil if the mutual TLS is not enabled.
+var clusterConfig *tls.Config
+
+// certReloader provides the certificate of the node to the TLS handshakes, and reloads it once
+// the certificate file or the key file is modified, so the certificate is rotated by replacing the files.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (r *certReloader, err error) {
+	r = &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err = r.certificate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// certificate returns the current certificate, which is reloaded if the files have been modified.
+// The previous certificate is kept if the modified files cannot be loaded, e.g. being written.
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return r.fallback(err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return r.fallback(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			log.LogWarnf("certReloader: reload certificate fail, keep the previous one: cert(%v) key(%v) err(%v)",
+				r.certFile, r.keyFile, err)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil {
+		log.LogInfof("certReloader: certificate reloaded: cert(%v) key(%v)", r.certFile, r.keyFile)
+	}
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		cert.Leaf = leaf
+		if time.Until(leaf.NotAfter) < expiryWarning {
+			log.LogWarnf("certReloader: certificate expires soon: cert(%v) notAfter(%v)", r.certFile, leaf.NotAfter)
+		}
+	}
+	r.cert, r.certMod, r.keyMod = &cert, certInfo.ModTime(), keyInfo.ModTime()
+	return r.cert, nil
+}
+
+func (r *certReloader) fallback(err error) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil {
+		return r.cert, nil
+	}
+	return nil, err
+}
+
+// NewConfig returns the config of the mutual TLS, by which the node presents its certificate and verifies
+// the one of its peer against the CA, as both the server and the client.
+func NewConfig(certFile, keyFile, caFile string) (config *tls.Config, err error) {
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, fmt.Errorf("TLS needs the certificate, the key and the CA: cert(%v) key(%v) ca(%v)",
+			certFile, keyFile, caFile)
+	}
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate fail: %v", err)
+	}
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("load CA fail: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in CA: %v", caFile)
+	}
+	config = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		},
+	}
+	return
+}
+
+// Init enables the mutual TLS of the process if the certificate of the node is configured,
+// which must be called before any connection is made.
+func Init(cfg *config.Config) error {
+	return Enable(cfg.GetString(CfgCertFile), cfg.GetString(CfgKeyFile), cfg.GetString(CfgCAFile))
+}
+
+// Enable enables the mutual TLS of the process with the files, or does nothing if none is specified.
+func Enable(certFile, keyFile, caFile string) (err error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return
+	}
+	if clusterConfig, err = NewConfig(certFile, keyFile, caFile); err != nil {
+		return
+	}
+	log.LogInfof("tlsutil: mutual TLS enabled: cert(%v) ca(%v)", certFile, caFile)
+	return
+}
+
+// Disable disables the mutual TLS, for the tools switching between the clusters.
+func Disable() {
+	clusterConfig = nil
+}
+
+// Enabled returns true if the mutual TLS is enabled.
+func Enabled() bool {
+	return clusterConfig != nil
+}
+
+// Config returns the TLS config of the process, or nil if the mutual TLS is not enabled.
+func Config() *tls.Config {
+	return clusterConfig
+}
+
+// HTTPScheme returns the scheme of the admin APIs.
+func HTTPScheme() string {
+	if Enabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// DialTimeout connects to the address, and completes the TLS handshake if the mutual TLS is enabled.
+func DialTimeout(addr string, timeout time.Duration) (conn net.Conn, err error) {
+	if conn, err = net.DialTimeout("tcp", addr, timeout); err != nil {
+		return
+	}
+	if c, ok := conn.(*net.TCPConn); ok {
+		c.SetKeepAlive(true)
+		c.SetNoDelay(true)
+	}
+	if !Enabled() {
+		return
+	}
+	config := clusterConfig.Clone()
+	if config.ServerName, _, err = net.SplitHostPort(addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, config)
+	if timeout <= 0 {
+		timeout = handshakeTimeout
+	}
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err = tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// TCPConn returns the TCP connection of the plain connection, or nil if conn is a TLS connection,
+// which is used by the options of the socket and the zero copy only available on the plain connections.
+func TCPConn(conn net.Conn) *net.TCPConn {
+	c, _ := conn.(*net.TCPConn)
+	return c
+}
+
+// NewListener returns the listener requiring the mutual TLS on the accepted connections if enabled.
+func NewListener(ln net.Listener) net.Listener {
+	if !Enabled() {
+		return ln
+	}
+	return tls.NewListener(ln, clusterConfig)
+}
+
+// Listen listens on the TCP address, requiring the mutual TLS if enabled.
+func Listen(addr string) (ln net.Listener, err error) {
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		return
+	}
+	return NewListener(ln), nil
+}
+
+// ListenAndServe serves the admin APIs on the server, with the mutual TLS if enabled.
+func ListenAndServe(server *http.Server) error {
+	if !Enabled() {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = clusterConfig
+	return server.ListenAndServeTLS("", "")
+}
+
+// NewTransport sets the TLS config of the transport to request the admin APIs of the cluster if enabled.
+func NewTransport(transport *http.Transport) *http.Transport {
+	if Enabled() {
+		transport.TLSClientConfig = clusterConfig.Clone()
+	}
+	return transport
+}
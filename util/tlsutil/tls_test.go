@@ -0,0 +1,186 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+func writePEM(t *testing.T, name, typ string, der []byte) {
+	if err := ioutil.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatalf("write %v fail: err(%v)", name, err)
+	}
+}
+
+// newTestCA writes a CA and a certificate of 127.0.0.1 issued by it into the dir.
+func newTestCA(t *testing.T, dir string) (caFile, certFile, keyFile string) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key fail: err(%v)", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cluster ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca fail: err(%v)", err)
+	}
+	ca, _ := x509.ParseCertificate(caDer)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key fail: err(%v)", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create certificate fail: err(%v)", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key fail: err(%v)", err)
+	}
+	caFile, certFile, keyFile = path.Join(dir, "ca.pem"), path.Join(dir, "cert.pem"), path.Join(dir, "key.pem")
+	writePEM(t, caFile, "CERTIFICATE", caDer)
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatalf("create temp dir fail: err(%v)", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { clusterConfig = nil }()
+
+	if err = Init(config.LoadConfigString(`{}`)); err != nil || Enabled() {
+		t.Fatalf("TLS should not be enabled without certificate: err(%v)", err)
+	}
+	caFile, certFile, keyFile := newTestCA(t, dir)
+	if err = Init(config.LoadConfigString(`{"tlsCertFile": "` + certFile + `"}`)); err == nil {
+		t.Fatalf("TLS without key and CA should fail")
+	}
+	cfg := config.LoadConfigString(`{"tlsCertFile": "` + certFile + `", "tlsKeyFile": "` + keyFile + `", "tlsCAFile": "` + caFile + `"}`)
+	if err = Init(cfg); err != nil || !Enabled() {
+		t.Fatalf("init TLS fail: err(%v)", err)
+	}
+
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail: err(%v)", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	conn, err := DialTimeout(ln.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("dial fail: err(%v)", err)
+	}
+	if TCPConn(conn) != nil {
+		t.Fatalf("connection should be over TLS")
+	}
+	buf := make([]byte, 4)
+	if _, err = conn.Write([]byte("ping")); err == nil {
+		_, err = io.ReadFull(conn, buf)
+	}
+	conn.Close()
+	if err != nil || string(buf) != "ping" {
+		t.Fatalf("echo fail: data(%s) err(%v)", buf, err)
+	}
+
+	// The client without certificate is refused
+	plain := &tls.Config{RootCAs: Config().RootCAs, ServerName: "127.0.0.1"}
+	if c, err := tls.Dial("tcp", ln.Addr().String(), plain); err == nil {
+		c.Write([]byte("ping"))
+		_, err = c.Read(buf)
+		c.Close()
+		if err == nil {
+			t.Fatalf("client without certificate should be refused")
+		}
+	}
+
+	// The admin APIs
+	httpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail: err(%v)", err)
+	}
+	addr := httpLn.Addr().String()
+	httpLn.Close()
+	server := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go ListenAndServe(server)
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(&http.Transport{})}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get(HTTPScheme() + "://" + addr + "/"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("request admin API fail: err(%v)", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "ok" {
+		t.Fatalf("unexpected response: %s", data)
+	}
+}
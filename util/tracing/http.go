@@ -0,0 +1,85 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/reqid"
+)
+
+// TraceParentHeader is the HTTP header carrying the trace context.
+const TraceParentHeader = "traceparent"
+
+// Inject sets the span context of the span to the header of the HTTP request.
+func Inject(header http.Header, span *Span) {
+	if span != nil {
+		header.Set(TraceParentHeader, span.ctx.TraceParent())
+	}
+}
+
+// Extract returns the span context of the HTTP request.
+func Extract(header http.Header) SpanContext {
+	return ParseTraceParent(header.Get(TraceParentHeader))
+}
+
+// statusWriter records the status of the response for the span.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// ServerHandler traces the HTTP requests carrying the trace context sampled. The span is named by the path,
+// and the request is passed on with the span of the server, e.g. to be proxied to the leader.
+func ServerHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := StartServerSpan(r.URL.Path, Extract(r.Header))
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		span.SetAttribute("http.method", r.Method)
+		if id := reqid.FromHeader(r.Header); id.IsValid() {
+			span.SetAttribute("requestID", id)
+		}
+		Inject(r.Header, span)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.statusCode == 0 {
+			sw.statusCode = http.StatusOK
+		}
+		span.SetAttribute("http.status_code", sw.statusCode)
+		var err error
+		if sw.statusCode >= http.StatusBadRequest {
+			err = fmt.Errorf("%v", http.StatusText(sw.statusCode))
+		}
+		span.End(err)
+	})
+}
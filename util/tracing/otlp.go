@@ -0,0 +1,241 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	otlpTracesPath = "/v1/traces"
+	scopeName      = "github.com/cubefs/cubefs"
+
+	queueSize      = 8192
+	batchSize      = 512
+	exportInterval = time.Second
+	exportTimeout  = 10 * time.Second
+
+	statusCodeOk    = 1
+	statusCodeError = 2
+)
+
+// otlpExporter exports the spans in batches to the collector by the OTLP/HTTP protocol in JSON.
+// The spans are dropped if the queue is full, so that the requests are never blocked by the collector.
+type otlpExporter struct {
+	url      string
+	resource otlpResource
+	client   *http.Client
+	queue    chan *Span
+	stopC    chan struct{}
+	wg       sync.WaitGroup
+	dropped  uint64
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+func newOTLPExporter(endpoint, service string) *otlpExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, otlpTracesPath) {
+		url += otlpTracesPath
+	}
+	attrs := []otlpKeyValue{keyValue("service.name", service)}
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, keyValue("host.name", host))
+	}
+	e := &otlpExporter{
+		url:      url,
+		resource: otlpResource{Attributes: attrs},
+		client:   &http.Client{Timeout: exportTimeout},
+		queue:    make(chan *Span, queueSize),
+		stopC:    make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+	return e
+}
+
+func (e *otlpExporter) export(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+func (e *otlpExporter) stop() {
+	close(e.stopC)
+	e.wg.Wait()
+}
+
+func (e *otlpExporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, batchSize)
+	for {
+		select {
+		case s := <-e.queue:
+			if batch = append(batch, s); len(batch) >= batchSize {
+				batch = e.flush(batch)
+			}
+		case <-ticker.C:
+			batch = e.flush(batch)
+		case <-e.stopC:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+				default:
+					e.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *otlpExporter) flush(batch []*Span) []*Span {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := e.post(batch); err != nil {
+		log.LogWarnf("tracing: export %v spans fail: err(%v) dropped(%v)", len(batch), err, atomic.LoadUint64(&e.dropped))
+	}
+	return batch[:0]
+}
+
+func (e *otlpExporter) post(batch []*Span) (err error) {
+	scope := &otlpScopeSpans{Spans: make([]*otlpSpan, 0, len(batch))}
+	scope.Scope.Name = scopeName
+	for _, s := range batch {
+		scope.Spans = append(scope.Spans, toOTLPSpan(s))
+	}
+	req := &otlpRequest{ResourceSpans: []*otlpResourceSpans{{Resource: e.resource, ScopeSpans: []*otlpScopeSpans{scope}}}}
+	var body []byte
+	if body, err = json.Marshal(req); err != nil {
+		return
+	}
+	var resp *http.Response
+	if resp, err = e.client.Post(e.url, "application/json", bytes.NewReader(body)); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status(%v) body(%s)", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return
+}
+
+func toOTLPSpan(s *Span) *otlpSpan {
+	span := &otlpSpan{
+		TraceID:           hex.EncodeToString(s.ctx.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.ctx.SpanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: statusCodeOk},
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for _, attr := range s.attrs {
+		span.Attributes = append(span.Attributes, keyValue(attr.key, attr.value))
+	}
+	if s.err != nil {
+		span.Status = otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+	}
+	return span
+}
+
+// keyValue returns the attribute in the AnyValue of OTLP, where the integers are in strings.
+func keyValue(key string, value interface{}) otlpKeyValue {
+	var v map[string]interface{}
+	switch val := value.(type) {
+	case string:
+		v = map[string]interface{}{"stringValue": val}
+	case bool:
+		v = map[string]interface{}{"boolValue": val}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case uint32:
+		v = map[string]interface{}{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint64:
+		v = map[string]interface{}{"intValue": strconv.FormatUint(val, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": val}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprintf("%v", val)}
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
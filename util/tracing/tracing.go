@@ -0,0 +1,249 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package tracing provides the distributed tracing of the requests across the components. The trace context
+// of the W3C Trace Context is carried by the packets and the HTTP requests, and the spans are exported to
+// the OpenTelemetry collector in the OTLP/HTTP protocol, so that a request is traced from the client through
+// the meta nodes, the data nodes and the master.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// configuration keys
+const (
+	// the OTLP/HTTP endpoint of the collector, such as "http://127.0.0.1:4318", the tracing is disabled if not specified
+	CfgEndpoint = "tracingEndpoint"
+	// the ratio of the requests traced, which is DefaultSampleRate if not specified
+	CfgSampleRate = "tracingSampleRate"
+)
+
+// ConfigSchema declares the configuration keys of the tracing.
+var ConfigSchema = config.Schema{
+	CfgEndpoint:   config.TypeString,
+	CfgSampleRate: config.TypeFloat,
+}
+
+const (
+	DefaultSampleRate = 0.01
+
+	// SpanContextSize is the size of the binary form of the span context in the packets.
+	SpanContextSize = 25
+
+	flagSampled uint8 = 0x01
+)
+
+type SpanKind int
+
+// the kinds of the spans of OTLP
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// SpanContext is the trace context propagated to the other components.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   uint8
+}
+
+// IsValid returns if the span context is of a sampled span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{} && sc.Flags&flagSampled != 0
+}
+
+// MarshalTo writes the span context to the buffer of SpanContextSize bytes.
+func (sc SpanContext) MarshalTo(out []byte) {
+	copy(out[0:16], sc.TraceID[:])
+	copy(out[16:24], sc.SpanID[:])
+	out[24] = sc.Flags
+}
+
+// UnmarshalSpanContext reads the span context written by MarshalTo.
+func UnmarshalSpanContext(in []byte) (sc SpanContext) {
+	copy(sc.TraceID[:], in[0:16])
+	copy(sc.SpanID[:], in[16:24])
+	sc.Flags = in[24]
+	return
+}
+
+// TraceParent returns the span context in the form of the traceparent header.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.Flags)
+}
+
+// ParseTraceParent parses the traceparent header, and returns the zero span context if it is malformed.
+func ParseTraceParent(value string) (sc SpanContext) {
+	if len(value) != 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return SpanContext{}
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(flags[:], []byte(value[53:55])); err != nil {
+		return SpanContext{}
+	}
+	sc.Flags = flags[0]
+	return
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span is an operation traced. All the methods accept the nil span, which is not sampled.
+type Span struct {
+	ctx      SpanContext
+	parentID [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+	end      time.Time
+	attrs    []attribute
+	err      error
+	ended    int32
+}
+
+// Context returns the span context to be propagated, which is the zero span context of the nil span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// SetAttribute sets an attribute of the span, the value is a string, a bool, an integer or a float.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+}
+
+// End ends the span with its error, and exports it.
+func (s *Span) End(err error) {
+	if s == nil || !atomic.CompareAndSwapInt32(&s.ended, 0, 1) {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	if t := gTracer; t != nil {
+		t.exporter.export(s)
+	}
+}
+
+type tracer struct {
+	sampleRate float64
+	exporter   *otlpExporter
+}
+
+// gTracer is the tracer of the process, which is nil if the tracing is not enabled.
+var gTracer *tracer
+
+// Init enables the tracing of the process as the service of the role if the endpoint is configured.
+func Init(role string, cfg *config.Config) (err error) {
+	endpoint := cfg.GetString(CfgEndpoint)
+	if endpoint == "" {
+		return
+	}
+	sampleRate := cfg.GetFloat(CfgSampleRate)
+	if sampleRate < 0 {
+		sampleRate = DefaultSampleRate
+	}
+	if sampleRate > 1 {
+		return fmt.Errorf("invalid %v [%v], which is in [0, 1]", CfgSampleRate, sampleRate)
+	}
+	gTracer = &tracer{sampleRate: sampleRate, exporter: newOTLPExporter(endpoint, role)}
+	log.LogInfof("tracing enabled: endpoint(%v) sampleRate(%v)", endpoint, sampleRate)
+	return
+}
+
+// Stop exports the spans ended and stops the tracing.
+func Stop() {
+	if t := gTracer; t != nil {
+		gTracer = nil
+		t.exporter.stop()
+	}
+}
+
+// Enabled returns if the tracing is enabled.
+func Enabled() bool {
+	return gTracer != nil
+}
+
+// StartSpan starts a span as the child of the parent, or a new trace sampled by the sample rate if the
+// parent is not valid. It returns nil if the tracing is not enabled or the span is not sampled.
+func StartSpan(name string, kind SpanKind, parent SpanContext) *Span {
+	t := gTracer
+	if t == nil {
+		return nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now()}
+	if parent.IsValid() {
+		s.ctx.TraceID = parent.TraceID
+		s.parentID = parent.SpanID
+	} else {
+		if !t.sample() {
+			return nil
+		}
+		randomID(s.ctx.TraceID[:])
+	}
+	randomID(s.ctx.SpanID[:])
+	s.ctx.Flags = flagSampled
+	return s
+}
+
+// StartServerSpan starts the span of a request received with the span context, which is only traced
+// if the trace is sampled by the sender.
+func StartServerSpan(name string, parent SpanContext) *Span {
+	if !parent.IsValid() {
+		return nil
+	}
+	return StartSpan(name, SpanKindServer, parent)
+}
+
+func (t *tracer) sample() bool {
+	if t.sampleRate <= 0 {
+		return false
+	}
+	if t.sampleRate >= 1 {
+		return true
+	}
+	var b [8]byte
+	randomID(b[:])
+	var n uint64
+	for _, v := range b {
+		n = n<<8 | uint64(v)
+	}
+	return float64(n>>11)/float64(1<<53) < t.sampleRate
+}
+
+func randomID(id []byte) {
+	_, _ = rand.Read(id)
+}
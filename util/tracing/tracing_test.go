@@ -0,0 +1,112 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+func TestSpanContext(t *testing.T) {
+	sc := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !sc.IsValid() {
+		t.Fatalf("traceparent should be valid: %+v", sc)
+	}
+	if sc.TraceParent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("unexpected traceparent: %v", sc.TraceParent())
+	}
+	buf := make([]byte, SpanContextSize)
+	sc.MarshalTo(buf)
+	if UnmarshalSpanContext(buf) != sc {
+		t.Fatalf("unmarshal span context mismatch")
+	}
+	for _, value := range []string{"", "00-xyz", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"} {
+		if ParseTraceParent(value).IsValid() {
+			t.Fatalf("traceparent [%v] should not be valid", value)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		spans []*otlpSpan
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpTracesPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req otlpRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	if StartSpan("disabled", SpanKindClient, SpanContext{}) != nil {
+		t.Fatalf("span should be nil if the tracing is not enabled")
+	}
+	if err := Init("test", config.LoadConfigString(`{"tracingEndpoint": "`+server.URL+`", "tracingSampleRate": 1}`)); err != nil {
+		t.Fatalf("init tracing fail: err(%v)", err)
+	}
+	client := StartSpan("client", SpanKindClient, SpanContext{})
+	client.SetAttribute("ino", uint64(1))
+	header := http.Header{}
+	Inject(header, client)
+	server1 := StartServerSpan("server", Extract(header))
+	server1.End(errors.New("no space"))
+	handler := ServerHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Extract(r.Header).SpanID == client.Context().SpanID {
+			t.Errorf("request should be passed on with the span of the server")
+		}
+		http.NotFound(w, r)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/admin/getCluster", nil)
+	Inject(r.Header, client)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	client.End(nil)
+	if StartServerSpan("untraced", SpanContext{}) != nil {
+		t.Fatalf("request without trace context should not be traced")
+	}
+	Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(spans) != 3 {
+		t.Fatalf("expect 3 spans but got %v", len(spans))
+	}
+	if spans[0].Name != "server" || spans[0].Kind != SpanKindServer || spans[0].TraceID != spans[2].TraceID ||
+		spans[0].ParentSpanID != spans[2].SpanID || spans[0].Status.Code != statusCodeError {
+		t.Fatalf("unexpected server span: %+v", spans[0])
+	}
+	if spans[1].Name != "/admin/getCluster" || spans[1].ParentSpanID != spans[2].SpanID || spans[1].Status.Code != statusCodeError {
+		t.Fatalf("unexpected http span: %+v", spans[1])
+	}
+	if spans[2].ParentSpanID != "" || len(spans[2].Attributes) != 1 || spans[2].Attributes[0].Value["intValue"] != "1" {
+		t.Fatalf("unexpected client span: %+v", spans[2])
+	}
+}